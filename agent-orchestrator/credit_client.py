@@ -121,15 +121,20 @@ class CreditClient:
         task_id: str,
         credits: int,
         model_name: str,
+        attempt: int = 1,
     ) -> CreditConsumeResult:
         """
         Consume credits for a completed task.
         
+        The request is keyed by task ID and dispatch attempt, so retrying it
+        returns the original transaction instead of charging twice.
+        
         Args:
             office_id: The office ID
             task_id: The task ID (for reference)
             credits: Number of credits to consume
             model_name: Model used (for description)
+            attempt: Dispatch attempt of the task
             
         Returns:
             CreditConsumeResult with transaction info
@@ -143,8 +148,12 @@ class CreditClient:
                     "task_id": task_id,
                     "credits": credits,
                     "description": f"Task execution using {model_name}",
+                    "attempt": attempt,
+                },
+                headers={
+                    **self._internal_headers(),
+                    "Idempotency-Key": f"{task_id}:{attempt}",
                 },
-                headers=self._internal_headers(),
             )
             
             if response.status_code == 200:
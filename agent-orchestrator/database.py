@@ -50,7 +50,7 @@ class Database:
         query = """
             SELECT id, sender_type, sender_id, content, created_at
             FROM messages
-            WHERE conversation_id = $1
+            WHERE conversation_id = $1 AND deleted_at IS NULL
             ORDER BY created_at DESC
             LIMIT $2
         """
@@ -64,7 +64,7 @@ class Database:
         query = """
             SELECT key, value
             FROM agent_memories
-            WHERE agent_id = $1
+            WHERE agent_id = $1 AND archived_at IS NULL
             ORDER BY updated_at DESC
             LIMIT 20
         """
@@ -83,7 +83,14 @@ class Database:
         query = """
             INSERT INTO agent_memories (id, office_id, agent_id, key, value, created_at, updated_at)
             VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
-            ON CONFLICT (agent_id, key) DO UPDATE SET value = $4, updated_at = NOW()
+            ON CONFLICT (agent_id, key) DO UPDATE SET
+                value = $4,
+                embedding = CASE WHEN agent_memories.value = $4 THEN agent_memories.embedding END,
+                archived_at = NULL,
+                archive_reason = NULL,
+                merged_into = NULL,
+                importance_decayed_at = NULL,
+                updated_at = NOW()
         """
         async with self.pool.acquire() as conn:
             await conn.execute(query, office_id, agent_id, key, value)
@@ -102,7 +109,7 @@ class Database:
                 output = COALESCE($3::TEXT, output), 
                 error = COALESCE($4::TEXT, error),
                 completed_at = CASE WHEN $2 IN ('done', 'failed') THEN NOW() ELSE completed_at END
-            WHERE id = $1::UUID
+            WHERE id = $1::UUID AND status <> 'cancelled'
         """
         async with self.pool.acquire() as conn:
             await conn.execute(query, task_id, status, output, error)
@@ -61,7 +61,8 @@ class LLMClient:
         messages.append({"role": "system", "content": system_content})
         
         # Conversation history
-        for msg in context.conversation_history[-10:]:  # Last 10 messages for context
+        # Already bounded by the backend, or to 10 messages when loaded here
+        for msg in context.conversation_history:
             messages.append({
                 "role": "user" if msg.get("sender_type") == "user" else "assistant",
                 "content": msg.get("content", ""),
@@ -82,6 +83,9 @@ class LLMClient:
             "- Respond professionally and helpfully.",
             "- Stay within your role and expertise.",
             "- If asked about something outside your expertise, acknowledge it and suggest the appropriate agent.",
+            "- Wrap finished deliverables such as reports, specs or plans in "
+            '<document title="..." format="markdown">...</document> to save them to the office\'s documents; '
+            "format may also be text, html, json or csv.",
             "",
         ]
         
@@ -93,6 +97,23 @@ class LLMClient:
                 "",
             ])
         
+        # Add the office's shared knowledge relevant to the task
+        if context.knowledge:
+            prompt_parts.extend([
+                "OFFICE KNOWLEDGE:",
+                *[f"- {knowledge}" for knowledge in context.knowledge],
+                "",
+            ])
+        
+        # Keep the agent to the tools of its skills
+        if context.skills is not None:
+            prompt_parts.extend([
+                "ENABLED SKILLS:",
+                *([f"- {skill}" for skill in context.skills] or ["- none"]),
+                "- Do not offer to use tools outside these skills.",
+                "",
+            ])
+        
         return "\n".join(prompt_parts)
 
 
@@ -1,11 +1,12 @@
 from fastapi import FastAPI, HTTPException, BackgroundTasks
 from contextlib import asynccontextmanager
+import asyncio
 import logging
 
 from config import get_settings
 from database import get_database
 from orchestrator import get_orchestrator
-from models import ExecuteRequest, ExecuteResponse, TaskStatus
+from models import CancelRequest, EstimateResponse, ExecuteRequest, ExecuteResponse, MemorySyncRequest, TaskStatus
 from tool_execution import ActionPlan, ExecutionResult
 
 # Configure logging
@@ -15,6 +16,9 @@ logging.basicConfig(
 )
 logger = logging.getLogger(__name__)
 
+# Tasks currently executing via /execute, by task ID, so /cancel can stop them
+_running_tasks: dict[str, asyncio.Task] = {}
+
 
 @asynccontextmanager
 async def lifespan(app: FastAPI):
@@ -59,11 +63,58 @@ async def execute_task(request: ExecuteRequest, background_tasks: BackgroundTask
     
     # Execute task (this is async but we await it here for simplicity)
     # In production, you might want to use background tasks or a queue
-    result = await orchestrator.execute_task(request)
+    task = asyncio.create_task(orchestrator.execute_task(request))
+    _running_tasks[request.task_id] = task
+    try:
+        result = await task
+    except asyncio.CancelledError:
+        # Re-raise if this request itself is being cancelled rather than the task
+        if asyncio.current_task().cancelling():
+            raise
+        logger.info(f"Task {request.task_id} cancelled")
+        return ExecuteResponse(
+            task_id=request.task_id,
+            status=TaskStatus.CANCELLED,
+            error="task cancelled",
+        )
+    finally:
+        _running_tasks.pop(request.task_id, None)
     
     return result
 
 
+@app.post("/cancel")
+async def cancel_task(request: CancelRequest):
+    """
+    Stop a task started through /execute.
+    
+    Cancelling a task that is not running here (already finished, or never
+    received) is not an error; "cancelled" reports whether one was stopped.
+    """
+    task = _running_tasks.get(request.task_id)
+    if task is None or task.done():
+        return {"task_id": request.task_id, "cancelled": False}
+    
+    task.cancel()
+    logger.info(f"Cancellation requested for task {request.task_id}")
+    return {"task_id": request.task_id, "cancelled": True}
+
+
+@app.post("/estimate", response_model=EstimateResponse)
+async def estimate_task(request: ExecuteRequest):
+    """
+    Estimate the credit cost of a task without executing it.
+    
+    The backend calls this before dispatching a task to check it against the
+    office's remaining budget.
+    """
+    orchestrator = get_orchestrator()
+    estimate = await orchestrator.estimate(request)
+    if estimate is None:
+        raise HTTPException(status_code=404, detail="Agent not found")
+    return estimate
+
+
 @app.post("/execute-async")
 async def execute_task_async(request: ExecuteRequest, background_tasks: BackgroundTasks):
     """
@@ -83,6 +134,40 @@ async def execute_task_async(request: ExecuteRequest, background_tasks: Backgrou
     }
 
 
+@app.post("/memories")
+async def sync_memory(request: MemorySyncRequest):
+    """
+    Index a memory created by the backend (e.g. from a user correction).
+    
+    The memory already lives in PostgreSQL; this stores its embedding so it is
+    picked up by semantic search on the agent's next task.
+    """
+    from orchestrator import _get_qdrant
+    
+    logger.info(f"Syncing {request.memory_type} memory {request.memory_id} for agent: {request.agent_id}")
+    
+    qdrant = await _get_qdrant()
+    if qdrant is None:
+        # PostgreSQL fallback already sees the memory
+        return {"memory_id": request.memory_id, "indexed": False}
+    
+    try:
+        vector_id = await qdrant.store_memory(
+            agent_id=request.agent_id,
+            office_id=request.office_id,
+            memory_key=request.key,
+            memory_value=request.value,
+            memory_type=request.memory_type,
+            importance=request.importance_score,
+            metadata={"memory_id": request.memory_id, "source": request.source, **request.metadata},
+        )
+    except Exception as e:
+        logger.error(f"Failed to index memory {request.memory_id}: {e}")
+        raise HTTPException(status_code=502, detail="failed to index memory")
+    
+    return {"memory_id": request.memory_id, "indexed": True, "vector_id": vector_id}
+
+
 @app.get("/agents")
 async def list_agent_templates():
     """List available agent templates."""
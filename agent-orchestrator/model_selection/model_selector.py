@@ -4,7 +4,7 @@ import logging
 import time
 from typing import Dict, List, Optional, Tuple
 
-from models import ExecuteRequest, AgentContext
+from models import ExecuteRequest, AgentContext, ModelPolicyRef
 from .types import (
     ModelDefinition,
     TaskCapabilityProfile,
@@ -111,11 +111,22 @@ class ModelSelector:
             scores, model_map, request.input
         )
 
+        # Apply the office's or agent's model policy
+        policy = request.model_policy
+        if policy:
+            filtered_scores = self._apply_model_policy(
+                filtered_scores,
+                model_map,
+                policy,
+                self._estimate_context_length(context) + len(request.input) // 4,
+            )
+
         # Select best model
         if not filtered_scores or not filtered_scores[0].meets_requirements:
-            # No suitable model found, fall back to default
+            # No suitable model found, fall back to default if the model
+            # policy allows it, else to the best model it allows
             default = self.registry.get_default_model()
-            if default:
+            if default and (not policy or any(s.model_name == default.name for s in filtered_scores)):
                 return SelectedModel(
                     model_name=default.name,
                     provider=default.provider,
@@ -124,7 +135,10 @@ class ModelSelector:
                     selection_reason="Fallback to default model (no suitable match)",
                     task_profile=task_profile,
                 )
-            raise ValueError("No suitable model found and no default available")
+            if not filtered_scores:
+                if policy:
+                    raise ValueError("No model is allowed by the model policy")
+                raise ValueError("No suitable model found and no default available")
 
         best = filtered_scores[0]
         alternatives = [s.model_name for s in filtered_scores[1:5]]  # Top 4 alternatives
@@ -243,12 +257,50 @@ class ModelSelector:
 
         raise RuntimeError("No models could complete the request")
 
+    def _apply_model_policy(
+        self,
+        scores: List[ModelScore],
+        models: Dict[str, ModelDefinition],
+        policy: ModelPolicyRef,
+        estimated_input_tokens: int,
+    ) -> List[ModelScore]:
+        """Keep the models a model policy allows, with its preferred model
+        first if it can handle the task."""
+        # Imported here as the cost engine depends on this package
+        from cost_engine import get_cost_engine, DEFAULT_OUTPUT_TOKENS
+        cost_engine = get_cost_engine()
+
+        allowed = set(policy.allowed_providers)
+        result = []
+        for score in scores:
+            if allowed and score.provider.value not in allowed:
+                continue
+            model = models.get(score.model_name)
+            if model and policy.max_credits_per_task is not None:
+                credits = cost_engine.estimate_credits_for_model(
+                    model, estimated_input_tokens, DEFAULT_OUTPUT_TOKENS
+                )
+                if credits > policy.max_credits_per_task:
+                    continue
+            result.append(score)
+
+        if policy.preferred_model:
+            preferred = [
+                s for s in result
+                if s.model_name == policy.preferred_model and s.meets_requirements
+            ]
+            if preferred:
+                result = preferred + [s for s in result if s is not preferred[0]]
+
+        logger.info(f"Model policy allows {len(result)} of {len(scores)} models")
+        return result
+
     def _estimate_context_length(self, context: AgentContext) -> int:
         """Estimate the context length needed."""
         base_length = len(context.system_prompt) // 4  # Rough token estimate
         history_length = sum(
             len(str(msg.get("content", ""))) // 4
-            for msg in context.conversation_history[-10:]
+            for msg in context.conversation_history
         )
         memory_length = sum(len(m) // 4 for m in context.memories)
         return base_length + history_length + memory_length + 500  # Buffer
@@ -282,7 +334,7 @@ class ModelSelector:
         messages.append({"role": "system", "content": system_content})
 
         # Conversation history
-        for msg in context.conversation_history[-10:]:
+        for msg in context.conversation_history:
             messages.append({
                 "role": "user" if msg.get("sender_type") == "user" else "assistant",
                 "content": msg.get("content", ""),
@@ -10,6 +10,53 @@ class TaskStatus(str, Enum):
     WORKING = "working"
     DONE = "done"
     FAILED = "failed"
+    CANCELLED = "cancelled"
+
+
+class AttachmentRef(BaseModel):
+    """A file sent with the task's message, downloadable from url until it expires."""
+    id: str
+    filename: str
+    content_type: str
+    size_bytes: int
+    url: str
+
+
+class AgentProfile(BaseModel):
+    """Who the agent running the task is."""
+    name: str
+    role: str = ""
+    system_prompt: str = ""
+
+
+class HistoryMessage(BaseModel):
+    """An earlier conversation message. role is "assistant" for the agent's
+    own messages and "user" for everyone else's, other agents included."""
+    role: str
+    sender_name: str = ""
+    content: str
+    created_at: Optional[str] = None
+
+
+class MemoryRef(BaseModel):
+    """One of the agent's long-term memories."""
+    key: str
+    value: str
+    type: str = "fact"
+    importance: float = 0.5
+
+
+class KnowledgeRef(BaseModel):
+    """A chunk of the office's shared knowledge base."""
+    title: str
+    content: str
+
+
+class ModelPolicyRef(BaseModel):
+    """The office's or agent's constraints on the model a task runs on."""
+    allowed_providers: list[str] = []
+    preferred_model: Optional[str] = None
+    max_credits_per_task: Optional[int] = None
 
 
 class ExecuteRequest(BaseModel):
@@ -19,6 +66,39 @@ class ExecuteRequest(BaseModel):
     office_id: str
     conversation_id: str
     input: str
+    attempt: int = 1
+    attachments: list[AttachmentRef] = []
+    # Context assembled by the backend; older backends send none, in which
+    # case it is loaded from the database
+    agent: Optional[AgentProfile] = None
+    history: Optional[list[HistoryMessage]] = None
+    memories: Optional[list[MemoryRef]] = None
+    # The office's knowledge relevant to the task
+    knowledge: list[KnowledgeRef] = []
+    # Constrains model selection; None leaves it to the registry's policies
+    model_policy: Optional[ModelPolicyRef] = None
+    # Keys of the skills the agent uses, such as web_search; only their
+    # tools are permitted. Older backends send none, which permits every tool
+    skills: Optional[list[str]] = None
+    # "chat" tasks reply in the conversation; the output of "summary" tasks
+    # is a conversation summary, which the backend posts itself
+    kind: str = "chat"
+
+    def input_with_attachments(self) -> str:
+        """The input, followed by a list of the attached files. Their signed
+        URLs are kept out of the prompt so they never reach model providers."""
+        if not self.attachments:
+            return self.input
+        files = "\n".join(
+            f"- {a.filename} ({a.content_type}, {a.size_bytes} bytes)"
+            for a in self.attachments
+        )
+        return f"{self.input}\n\nAttached files:\n{files}".strip()
+
+
+class CancelRequest(BaseModel):
+    """Request to stop a running task."""
+    task_id: str
 
 
 class ExecuteResponse(BaseModel):
@@ -30,6 +110,15 @@ class ExecuteResponse(BaseModel):
     token_usage: Dict[str, int] = {}
 
 
+class EstimateResponse(BaseModel):
+    """Estimated credit cost of executing a task."""
+    model: str
+    provider: str
+    estimated_credits: int
+    estimated_input_tokens: int
+    estimated_output_tokens: int
+
+
 class AgentContext(BaseModel):
     """Context for agent execution."""
     agent_id: str
@@ -38,6 +127,9 @@ class AgentContext(BaseModel):
     system_prompt: str
     conversation_history: list[Dict[str, Any]] = []
     memories: list[str] = []
+    knowledge: list[str] = []
+    # None when the backend did not say, leaving every tool permitted
+    skills: Optional[list[str]] = None
 
 
 class Message(BaseModel):
@@ -51,3 +143,16 @@ class AgentMemory(BaseModel):
     key: str
     value: str
     metadata: Dict[str, Any] = {}
+
+
+class MemorySyncRequest(BaseModel):
+    """Memory created by the backend that should be indexed for semantic recall."""
+    memory_id: str
+    agent_id: str
+    office_id: str
+    key: str
+    value: str
+    memory_type: str = "fact"
+    importance_score: float = 0.5
+    source: str = "system"
+    metadata: Dict[str, Any] = {}
@@ -6,17 +6,18 @@ Integrated with Credit System for monetization.
 """
 
 import logging
-from typing import Optional
+import re
+from typing import List, Optional, Tuple
 import httpx
 
 from config import get_settings
-from models import ExecuteRequest, ExecuteResponse, TaskStatus, AgentContext
+from models import ExecuteRequest, ExecuteResponse, EstimateResponse, TaskStatus, AgentContext, MemoryRef
 from database import get_database
 from model_selection import get_model_selector, ModelSelector
 from model_selection.types import CostLevel
 from metrics import get_metrics_service, MetricsService
 from credit_client import get_credit_client, CreditClient
-from cost_engine import get_cost_engine, CostEngine
+from cost_engine import get_cost_engine, CostEngine, DEFAULT_OUTPUT_TOKENS
 from rate_limiter import (
     get_rate_limiter, get_anomaly_detector, get_circuit_breaker,
     CreditRateLimiter, AnomalyDetector, CircuitBreaker, RateLimitAction
@@ -32,6 +33,12 @@ from tool_execution import (
 
 logger = logging.getLogger(__name__)
 
+# Deliverables agents wrap in <document title="..." format="...">...</document>
+DOCUMENT_PATTERN = re.compile(
+    r'<document\s+title="([^"]+)"(?:\s+format="(\w+)")?\s*>\n?(.*?)\n?</document>',
+    re.DOTALL,
+)
+
 # Lazy import to avoid startup failures if Qdrant is not available
 _qdrant_client = None
 _qdrant_available = True
@@ -175,7 +182,7 @@ class Orchestrator:
         2. Estimating and checking credit balance
         3. Selecting optimal model based on task requirements
         4. Generating LLM response with fallback support
-        5. Consuming credits based on actual usage
+        5. Reporting usage, for which the backend charges credits
         6. Saving response and updating task
         7. Persisting execution metrics
         """
@@ -313,7 +320,7 @@ class Orchestrator:
             output, token_usage, metrics = await self.model_selector.execute_with_fallback(
                 selected=selected,
                 context=context,
-                user_input=request.input,
+                user_input=request.input_with_attachments(),
             )
             
             # Calculate actual credits using per-model pricing
@@ -329,28 +336,25 @@ class Orchestrator:
                     cost_level, input_tokens, output_tokens
                 )
             
-            # Consume credits (only if non-zero)
-            if credits_consumed > 0:
-                consume_result = await self.credit_client.consume_credits(
-                    office_id=request.office_id,
-                    task_id=request.task_id,
-                    credits=credits_consumed,
-                    model_name=selected.model_name,
-                )
-                if not consume_result.success:
-                    logger.warning(f"Credit consumption failed: {consume_result.error}")
-                else:
-                    logger.info(
-                        f"Consumed {credits_consumed} credits for task {request.task_id} "
-                        f"(balance: {consume_result.new_balance})"
-                    )
-                    # Record for rate limiting
-                    await self.rate_limiter.record_consumption(
-                        office_id=request.office_id,
-                        credits=credits_consumed,
-                        model_name=selected.model_name,
-                        task_id=request.task_id,
-                    )
+            # The backend charges the credits when it receives the usage
+            # with the task-complete callback
+            usage = {
+                "model": selected.model_name,
+                "provider": getattr(selected.provider, "value", selected.provider),
+                "agent_role": context.agent_role,
+                "input_tokens": input_tokens,
+                "output_tokens": output_tokens,
+                "latency_ms": metrics.latency_ms,
+                "credits": credits_consumed,
+                "is_local_model": is_free_model,
+                "usd_cost": metrics.estimated_cost,
+            }
+            
+            # Deliverables are saved as documents; the reply keeps their content.
+            # Summaries have none.
+            documents = []
+            if request.kind != "summary":
+                output, documents = self._extract_documents(output)
             
             # Update task with output
             await self.db.update_task_status(
@@ -359,11 +363,25 @@ class Orchestrator:
                 output=output
             )
             
-            # Save response as agent message
-            await self._save_agent_response(request, output)
+            # Save response as agent message; the backend posts summaries
+            message_id = None
+            if request.kind != "summary":
+                message_id = await self._save_agent_response(request, output)
             
-            # Broadcast to WebSocket (via backend)
-            await self._notify_backend(request, output)
+            # Broadcast to WebSocket and record usage (via backend)
+            await self._notify_backend(request, output, message_id, usage)
+            
+            if credits_consumed > 0:
+                # Record for rate limiting
+                await self.rate_limiter.record_consumption(
+                    office_id=request.office_id,
+                    credits=credits_consumed,
+                    model_name=selected.model_name,
+                    task_id=request.task_id,
+                )
+            
+            for title, doc_format, content in documents:
+                await self._save_document(request, title, doc_format, content)
             
             # Persist execution metrics
             metrics.task_id = request.task_id
@@ -402,36 +420,100 @@ class Orchestrator:
                 error=str(e),
             )
     
-    async def _load_agent_context(self, request: ExecuteRequest) -> Optional[AgentContext]:
-        """Load full agent context for LLM, including semantic memory search."""
-        # Get agent info
-        agent = await self.db.get_agent(request.agent_id)
-        if not agent:
-            return None
+    async def estimate(self, request: ExecuteRequest) -> Optional[EstimateResponse]:
+        """Estimate the credits executing the request would cost.
         
-        # Get conversation history
-        history = await self.db.get_conversation_history(request.conversation_id)
+        The model is chosen as for execution; input tokens are estimated
+        from the prompt, history, memories and input, and output tokens are
+        assumed to be the default. Returns None if the agent is not found.
+        """
+        await self.initialize()
         
-        # Get memories - try semantic search first, fall back to PostgreSQL
-        memories = await self._get_relevant_memories(request.agent_id, request.input)
+        context = await self._load_agent_context(request)
+        if context is None:
+            return None
         
-        # Determine name and prompt
-        agent_name = agent.get("custom_name") or agent.get("template_name", "Agent")
-        system_prompt = agent.get("custom_system_prompt") or agent.get("template_system_prompt", "")
+        selected = await self.model_selector.select_model(request, context)
+        input_tokens = (
+            len(context.system_prompt)
+            + sum(len(str(msg.get("content", ""))) for msg in context.conversation_history)
+            + sum(len(m) for m in context.memories)
+            + sum(len(k) for k in context.knowledge)
+            + len(request.input)
+        ) // 4  # Rough token estimate
+        output_tokens = DEFAULT_OUTPUT_TOKENS
         
+        model_def = self.model_selector.registry.get_model(selected.model_name)
+        if model_def:
+            credits = self.cost_engine.estimate_credits_for_model(model_def, input_tokens, output_tokens)
+        else:
+            cost_level = self.cost_engine.get_cost_level_for_model(
+                selected.model_name, selected.provider
+            )
+            credits = self.cost_engine.estimate_credits(cost_level, input_tokens, output_tokens)
+        
+        return EstimateResponse(
+            model=selected.model_name,
+            provider=getattr(selected.provider, "value", selected.provider),
+            estimated_credits=credits,
+            estimated_input_tokens=input_tokens,
+            estimated_output_tokens=output_tokens,
+        )
+    
+    async def _load_agent_context(self, request: ExecuteRequest) -> Optional[AgentContext]:
+        """Load full agent context for LLM, including semantic memory search.
+
+        The backend sends the agent, history and memories with the request;
+        whatever it leaves out is loaded from the database.
+        """
+        if request.agent:
+            agent_name = request.agent.name or "Agent"
+            agent_role = request.agent.role or "Assistant"
+            system_prompt = request.agent.system_prompt
+        else:
+            agent = await self.db.get_agent(request.agent_id)
+            if not agent:
+                return None
+            agent_name = agent.get("custom_name") or agent.get("template_name", "Agent")
+            agent_role = agent.get("template_role", "Assistant")
+            system_prompt = agent.get("custom_system_prompt") or agent.get("template_system_prompt", "")
+
+        # Get conversation history
+        if request.history is not None:
+            history = [
+                {
+                    "sender_type": "agent" if msg.role == "assistant" else "user",
+                    # Name other speakers, who all take the user role
+                    "content": msg.content if msg.role == "assistant" or not msg.sender_name
+                    else f"{msg.sender_name}: {msg.content}",
+                }
+                for msg in request.history
+            ]
+        else:
+            history = await self.db.get_conversation_history(request.conversation_id)
+
+        # Get memories - try semantic search first, fall back to the
+        # backend's or PostgreSQL's
+        memories = await self._get_relevant_memories(request.agent_id, request.input, request.memories)
+
         return AgentContext(
             agent_id=request.agent_id,
             agent_name=agent_name,
-            agent_role=agent.get("template_role", "Assistant"),
+            agent_role=agent_role,
             system_prompt=system_prompt,
             conversation_history=history,
             memories=memories,
+            knowledge=[f"{k.title}: {k.content}" for k in request.knowledge],
+            skills=request.skills,
         )
-    
-    async def _get_relevant_memories(self, agent_id: str, query: str) -> list[str]:
+
+    async def _get_relevant_memories(
+        self, agent_id: str, query: str, fallback: Optional[list[MemoryRef]] = None
+    ) -> list[str]:
         """
-        Get relevant memories using semantic search if available,
-        otherwise fall back to PostgreSQL key-value memories.
+        Get relevant memories using semantic search if available, otherwise
+        fall back to the memories the backend sent, or to PostgreSQL
+        key-value memories.
         """
         # Try Qdrant semantic search first
         qdrant = await _get_qdrant()
@@ -454,25 +536,76 @@ class Orchestrator:
             except Exception as e:
                 logger.warning(f"Semantic memory search failed, using fallback: {e}")
         
+        if fallback is not None:
+            return [f"{mem.key}: {mem.value}" for mem in fallback]
+
         # Fall back to PostgreSQL memories
         return await self.db.get_agent_memories(agent_id)
     
-    async def _save_agent_response(self, request: ExecuteRequest, output: str):
-        """Save agent response as a message in the conversation."""
+    async def _save_agent_response(self, request: ExecuteRequest, output: str) -> str:
+        """Save agent response as a message in the conversation and return its ID."""
         async with self.db.pool.acquire() as conn:
-            await conn.execute(
+            message_id = await conn.fetchval(
                 """
                 INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content, metadata, created_at)
                 VALUES (gen_random_uuid(), $1, $2, 'agent', $3, $4, '{}', NOW())
+                RETURNING id
                 """,
                 request.office_id,
                 request.conversation_id,
                 request.agent_id,
                 output,
             )
+            return str(message_id)
+    
+    def _extract_documents(self, output: str) -> Tuple[str, List[Tuple[str, str, str]]]:
+        """Find the deliverables in an agent's output.
+        
+        Returns the output with the document tags removed, and the title,
+        format and content of each document.
+        """
+        documents = [
+            (title.strip(), doc_format or "markdown", content)
+            for title, doc_format, content in DOCUMENT_PATTERN.findall(output)
+        ]
+        return DOCUMENT_PATTERN.sub(lambda m: m.group(3), output), documents
     
-    async def _notify_backend(self, request: ExecuteRequest, output: str):
-        """Notify the backend about the completed task (for WebSocket broadcast)."""
+    async def _save_document(self, request: ExecuteRequest, title: str, doc_format: str, content: str):
+        """Save a deliverable as an office document through the backend."""
+        try:
+            async with httpx.AsyncClient() as client:
+                response = await client.post(
+                    f"{self.settings.backend_url}/api/v1/internal/documents",
+                    json={
+                        "task_id": request.task_id,
+                        "title": title,
+                        "format": doc_format,
+                        "content": content,
+                    },
+                    headers={
+                        "X-Internal-API-Key": self.settings.internal_api_key,
+                    },
+                    timeout=5.0,
+                )
+                if response.status_code != 201:
+                    logger.warning(f"Saving document failed: {response.status_code}")
+        except Exception as e:
+            # Log but don't fail - the content is in the agent's reply
+            logger.warning(f"Failed to save document: {e}")
+    
+    async def _notify_backend(
+        self,
+        request: ExecuteRequest,
+        output: str,
+        message_id: Optional[str] = None,
+        usage: Optional[dict] = None,
+    ):
+        """Notify the backend about the completed task.
+        
+        The backend broadcasts the reply over WebSocket and, given usage,
+        charges the office's credits and records the task's tokens, model
+        and latency.
+        """
         try:
             api_key = self.settings.internal_api_key
             logger.debug(f"Notifying backend with API key: {api_key[:10]}...")
@@ -484,7 +617,10 @@ class Orchestrator:
                         "task_id": request.task_id,
                         "conversation_id": request.conversation_id,
                         "agent_id": request.agent_id,
+                        "message_id": message_id,
                         "output": output,
+                        "attempt": request.attempt,
+                        "usage": usage,
                     },
                     headers={
                         "X-Internal-API-Key": api_key,
@@ -423,6 +423,11 @@ class ExecutionOrchestrator:
         """Check permissions for all tools in plan."""
         for step in plan.steps:
             tool = self._registry.get_tool(step.tool)
+            if tool and tool.skill and context.skills is not None and tool.skill not in context.skills:
+                return {
+                    'allowed': False,
+                    'reason': f"Permission denied for {step.tool}: the agent does not have the {tool.skill} skill",
+                }
             if tool:
                 result = self._security.check_permissions(tool, context.permissions)
                 if not result.allowed:
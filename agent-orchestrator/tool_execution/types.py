@@ -112,6 +112,9 @@ class ToolDefinition(BaseModel):
     max_retries: int = 3
     cost_level: CostLevel = CostLevel.LOW
     available: bool = True
+    # Agent skill the tool belongs to, such as web_search; tools without
+    # one are permitted to every agent
+    skill: Optional[str] = None
     
     # Rate limit hints
     requests_per_minute: Optional[int] = None
@@ -275,6 +278,7 @@ class ExecutionContext(BaseModel):
     permissions: PermissionScope
     shared_data: Dict[str, Any] = Field(default_factory=dict)  # Data shared between steps
     dry_run: bool = False  # If true, validate but don't execute
+    skills: Optional[List[str]] = None  # Agent's skills; None permits every tool
 
 
 # =============================================================================
@@ -0,0 +1,651 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SearchUsers returns users whose email or name contains the q parameter
+// GET /admin/users
+func (h *AdminHandler) SearchUsers(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	users, total, err := h.adminService.SearchUsers(c.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		return internalError("failed to search users", err)
+	}
+	if users == nil {
+		users = []*domain.User{}
+	}
+
+	return c.JSON(fiber.Map{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// SearchOffices returns offices whose name or owner's email contains the q
+// parameter
+// GET /admin/offices
+func (h *AdminHandler) SearchOffices(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	offices, total, err := h.adminService.SearchOffices(c.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		return internalError("failed to search offices", err)
+	}
+	if offices == nil {
+		offices = []*domain.AdminOffice{}
+	}
+
+	return c.JSON(fiber.Map{
+		"offices": offices,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetOffice returns an office with its owner, subscription and wallet
+// GET /admin/offices/:id
+func (h *AdminHandler) GetOffice(c *fiber.Ctx) error {
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	office, err := h.adminService.GetOffice(c.Context(), officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("office not found")
+	}
+	if err != nil {
+		return internalError("failed to get office", err)
+	}
+
+	return c.JSON(office)
+}
+
+// GetOfficeTransactions returns an office's credit transactions, newest
+// first
+// GET /admin/offices/:id/transactions?limit=50&cursor=...
+func (h *AdminHandler) GetOfficeTransactions(c *fiber.Ctx) error {
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	page, err := parsePageRequest(c, 50, 100)
+	if err != nil {
+		return err
+	}
+
+	transactions, total, err := h.creditService.GetTransactionHistory(c.Context(), officeID, page)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("wallet not found")
+	}
+	if err != nil {
+		return internalError("failed to get transactions", err)
+	}
+
+	return c.JSON(newPage(transactions, total, page.Limit, func(tx *domain.CreditTransaction) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+	}))
+}
+
+// AdjustCreditsRequest represents a manual credit adjustment
+type AdjustCreditsRequest struct {
+	// Amount is added to the balance; negative amounts take credits away
+	Amount int64  `json:"amount" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AdjustCredits adds credits to or removes them from an office's wallet
+// POST /admin/offices/:id/credits
+func (h *AdminHandler) AdjustCredits(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	var req AdjustCreditsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	tx, err := h.adminService.AdjustCredits(c.Context(), adminID, officeID, req.Amount, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("wallet not found")
+	}
+	if err != nil {
+		return internalError("failed to adjust credits", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tx)
+}
+
+// ChangeTierRequest represents a manual tier change
+type ChangeTierRequest struct {
+	Tier   domain.SubscriptionTier `json:"tier" validate:"required"`
+	Reason string                  `json:"reason" validate:"max=500"`
+}
+
+// ChangeTier moves an office to a tier without the checks and credit
+// changes of an upgrade or downgrade
+// PUT /admin/offices/:id/subscription
+func (h *AdminHandler) ChangeTier(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	var req ChangeTierRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	sub, err := h.adminService.ChangeTier(c.Context(), adminID, officeID, req.Tier, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("subscription not found")
+	}
+	if err != nil {
+		return internalError("failed to change tier", err)
+	}
+
+	return c.JSON(sub)
+}
+
+// ReloadTiers makes the tiers file the live tier configuration and returns
+// its tiers. An invalid file is rejected and the current tiers stay live.
+// POST /admin/tiers/reload
+func (h *AdminHandler) ReloadTiers(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	tiers, err := h.adminService.ReloadTiers(c.Context(), adminID)
+	if err != nil {
+		return internalError("failed to reload tiers", err)
+	}
+
+	return c.JSON(fiber.Map{"tiers": tierList(tiers)})
+}
+
+// ListFailedTasks returns failed and dead-lettered tasks across every office,
+// most recent first
+// GET /admin/tasks/failed
+func (h *AdminHandler) ListFailedTasks(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	tasks, total, err := h.adminService.ListFailedTasks(c.Context(), limit, offset)
+	if err != nil {
+		return internalError("failed to get failed tasks", err)
+	}
+	if tasks == nil {
+		tasks = []*domain.Task{}
+	}
+
+	return c.JSON(fiber.Map{
+		"tasks":  tasks,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ListPayouts returns authors' payout requests, oldest first. The status
+// parameter narrows them; it defaults to pending and "all" lists every one.
+// GET /admin/payouts
+func (h *AdminHandler) ListPayouts(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	status := domain.PayoutStatus(c.Query("status", string(domain.PayoutStatusPending)))
+	switch status {
+	case "all":
+		status = ""
+	case domain.PayoutStatusPending, domain.PayoutStatusProcessing, domain.PayoutStatusCompleted, domain.PayoutStatusFailed:
+	default:
+		return badRequest("invalid payout status")
+	}
+
+	payouts, total, err := h.adminService.ListPayouts(c.Context(), status, limit, offset)
+	if err != nil {
+		return internalError("failed to get payouts", err)
+	}
+	if payouts == nil {
+		payouts = []domain.PayoutRequest{}
+	}
+
+	return c.JSON(fiber.Map{
+		"payouts": payouts,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// CompletePayoutRequest records how a payout was sent
+type CompletePayoutRequest struct {
+	StripeTransferID string `json:"stripe_transfer_id" validate:"required"`
+}
+
+// CompletePayout marks a pending payout as sent
+// POST /admin/payouts/:id/complete
+func (h *AdminHandler) CompletePayout(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid payout id")
+	}
+
+	var req CompletePayoutRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	payout, err := h.adminService.CompletePayout(c.Context(), adminID, payoutID, req.StripeTransferID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("payout not found")
+	}
+	if err != nil {
+		return internalError("failed to complete payout", err)
+	}
+
+	return c.JSON(payout)
+}
+
+// RejectPayoutRequest represents a payout rejection
+type RejectPayoutRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// RejectPayout fails a payout that has not been sent and returns its amount
+// to the author's balance, with a reason shown to the author
+// POST /admin/payouts/:id/reject
+func (h *AdminHandler) RejectPayout(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid payout id")
+	}
+
+	var req RejectPayoutRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	payout, err := h.adminService.RejectPayout(c.Context(), adminID, payoutID, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("payout not found or already settled")
+	}
+	if err != nil {
+		return internalError("failed to reject payout", err)
+	}
+
+	return c.JSON(payout)
+}
+
+// ListRefunds returns a page of every office's refund requests, oldest
+// first, narrowed by the status parameter (pending unless given, or "all")
+// GET /admin/refunds
+func (h *AdminHandler) ListRefunds(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	status := domain.RefundRequestStatus(c.Query("status", string(domain.RefundRequestStatusPending)))
+	switch status {
+	case "all":
+		status = ""
+	case domain.RefundRequestStatusPending, domain.RefundRequestStatusRefunded, domain.RefundRequestStatusRejected:
+	default:
+		return badRequest("invalid refund status")
+	}
+
+	refunds, total, err := h.adminService.ListRefunds(c.Context(), status, limit, offset)
+	if err != nil {
+		return internalError("failed to get refund requests", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"refunds": refunds,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// ApproveRefund refunds the purchase of a pending refund request through
+// Stripe, reverses the author's earning and revokes the office's template
+// POST /admin/refunds/:id/approve
+func (h *AdminHandler) ApproveRefund(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	refundID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid refund request id")
+	}
+
+	refund, err := h.adminService.ApproveRefund(c.Context(), adminID, refundID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("refund request not found or already settled")
+	}
+	if err != nil {
+		return internalError("failed to approve refund", err)
+	}
+
+	return c.JSON(refund)
+}
+
+// RejectRefundRequest represents a refund rejection
+type RejectRefundRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// RejectRefund turns down a pending refund request with a reason shown to
+// the office
+// POST /admin/refunds/:id/reject
+func (h *AdminHandler) RejectRefund(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	refundID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid refund request id")
+	}
+
+	var req RejectRefundRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	refund, err := h.adminService.RejectRefund(c.Context(), adminID, refundID, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("refund request not found or already settled")
+	}
+	if err != nil {
+		return internalError("failed to reject refund", err)
+	}
+
+	return c.JSON(refund)
+}
+
+// ListPromoCodes returns a page of the promo codes, newest first
+// GET /admin/promo-codes
+func (h *AdminHandler) ListPromoCodes(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	codes, total, err := h.adminService.ListPromoCodes(c.Context(), limit, offset)
+	if err != nil {
+		return internalError("failed to get promo codes", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"promo_codes": codes,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// CreatePromoCodeRequest defines a promo code. Bonus credit codes set
+// bonus_credits; subscription discount codes set discount_percent and
+// discount_periods, the number of invoices discounted.
+type CreatePromoCodeRequest struct {
+	Code            string               `json:"code" validate:"required,max=32"`
+	Description     string               `json:"description" validate:"max=500"`
+	Kind            domain.PromoCodeKind `json:"kind" validate:"required,oneof=bonus_credits subscription_discount"`
+	BonusCredits    int64                `json:"bonus_credits" validate:"gte=0"`
+	DiscountPercent int                  `json:"discount_percent" validate:"gte=0,lte=100"`
+	DiscountPeriods int                  `json:"discount_periods" validate:"gte=0"`
+	MaxRedemptions  *int                 `json:"max_redemptions,omitempty" validate:"omitempty,gt=0"`
+	StartsAt        *time.Time           `json:"starts_at,omitempty"`
+	ExpiresAt       *time.Time           `json:"expires_at,omitempty"`
+}
+
+// CreatePromoCode starts a promo campaign
+// POST /admin/promo-codes
+func (h *AdminHandler) CreatePromoCode(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	var req CreatePromoCodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	code, err := h.adminService.CreatePromoCode(c.Context(), adminID, service.NewPromoCode{
+		Code:            req.Code,
+		Description:     req.Description,
+		Kind:            req.Kind,
+		BonusCredits:    req.BonusCredits,
+		DiscountPercent: req.DiscountPercent,
+		DiscountPeriods: req.DiscountPeriods,
+		MaxRedemptions:  req.MaxRedemptions,
+		StartsAt:        req.StartsAt,
+		ExpiresAt:       req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(code)
+}
+
+// ListPromoRedemptions returns a page of a promo code's redemptions, newest
+// first
+// GET /admin/promo-codes/:id/redemptions
+func (h *AdminHandler) ListPromoRedemptions(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	codeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid promo code id")
+	}
+
+	redemptions, total, err := h.adminService.ListPromoRedemptions(c.Context(), codeID, limit, offset)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("promo code not found")
+	}
+	if err != nil {
+		return internalError("failed to get promo redemptions", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"redemptions": redemptions,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// DeactivatePromoCode ends a promo campaign; discounts already redeemed
+// keep applying
+// POST /admin/promo-codes/:id/deactivate
+func (h *AdminHandler) DeactivatePromoCode(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	codeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid promo code id")
+	}
+
+	code, err := h.adminService.DeactivatePromoCode(c.Context(), adminID, codeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("promo code not found or already deactivated")
+	}
+	if err != nil {
+		return internalError("failed to deactivate promo code", err)
+	}
+
+	return c.JSON(code)
+}
+
+// ListAuditLog returns the audit log of every office, most recent first,
+// optionally narrowed by the actor_id, office_id, action, from and to
+// parameters
+// GET /admin/audit-log
+func (h *AdminHandler) ListAuditLog(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+	if raw := c.Query("actor_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid actor_id")
+		}
+		filter.ActorID = id
+	}
+	if raw := c.Query("office_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid office_id")
+		}
+		filter.OfficeID = id
+	}
+
+	entries, total, err := h.auditService.List(c.Context(), filter, limit, offset)
+	if err != nil {
+		return internalError("failed to get audit log", err)
+	}
+	if entries == nil {
+		entries = []*domain.AuditEntry{}
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// adminPage reads the limit and offset parameters of back-office lists
+func adminPage(c *fiber.Ctx) (limit, offset int) {
+	limit = 20
+	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset", "0")); err == nil && o >= 0 {
+		offset = o
+	}
+	return limit, offset
+}
+
+// ListAuthorVerifications returns the authors whose verification has the
+// status parameter, pending by default, oldest request first
+// GET /admin/authors/verifications
+func (h *AdminHandler) ListAuthorVerifications(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	status := domain.AuthorVerificationStatus(c.Query("status", string(domain.AuthorVerificationPending)))
+	switch status {
+	case domain.AuthorVerificationPending, domain.AuthorVerificationVerified, domain.AuthorVerificationRejected:
+	default:
+		return badRequest("invalid verification status")
+	}
+
+	authors, total, err := h.adminService.ListAuthorVerifications(c.Context(), status, limit, offset)
+	if err != nil {
+		return internalError("failed to get author verifications", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"authors": authors,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// VerifyAuthor grants the author of a pending verification request the
+// verified badge
+// POST /admin/authors/:id/verify
+func (h *AdminHandler) VerifyAuthor(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	authorID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid author id")
+	}
+
+	profile, err := h.adminService.VerifyAuthor(c.Context(), adminID, authorID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("author not found or not awaiting verification")
+	}
+	if err != nil {
+		return internalError("failed to verify author", err)
+	}
+
+	return c.JSON(profile)
+}
+
+// AuthorVerificationReviewRequest gives the reason an author's
+// verification is rejected or revoked
+type AuthorVerificationReviewRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// RejectAuthorVerification turns down a pending verification request with
+// a reason shown to the author
+// POST /admin/authors/:id/reject-verification
+func (h *AdminHandler) RejectAuthorVerification(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	authorID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid author id")
+	}
+
+	var req AuthorVerificationReviewRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	profile, err := h.adminService.RejectAuthorVerification(c.Context(), adminID, authorID, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("author not found or not awaiting verification")
+	}
+	if err != nil {
+		return internalError("failed to reject author verification", err)
+	}
+
+	return c.JSON(profile)
+}
+
+// RevokeAuthorVerification takes a verified author's badge away with a
+// reason shown to the author
+// POST /admin/authors/:id/revoke-verification
+func (h *AdminHandler) RevokeAuthorVerification(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	authorID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid author id")
+	}
+
+	var req AuthorVerificationReviewRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	profile, err := h.adminService.RevokeAuthorVerification(c.Context(), adminID, authorID, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("author not found or not verified")
+	}
+	if err != nil {
+		return internalError("failed to revoke author verification", err)
+	}
+
+	return c.JSON(profile)
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AdminHandler handles platform administration endpoints
+type AdminHandler struct {
+	moderationService *service.ModerationService
+	contentModeration *service.ContentModerationService
+	retentionService  *service.RetentionService
+	adminService      *service.AdminService
+	creditService     *service.CreditService
+	auditService      *service.AuditService
+}
+
+// NewAdminHandler creates a new AdminHandler
+func NewAdminHandler(
+	moderationService *service.ModerationService,
+	contentModeration *service.ContentModerationService,
+	retentionService *service.RetentionService,
+	adminService *service.AdminService,
+	creditService *service.CreditService,
+	auditService *service.AuditService,
+) *AdminHandler {
+	return &AdminHandler{
+		moderationService: moderationService,
+		contentModeration: contentModeration,
+		retentionService:  retentionService,
+		adminService:      adminService,
+		creditService:     creditService,
+		auditService:      auditService,
+	}
+}
+
+// GetPendingTemplates returns marketplace templates awaiting moderation
+// GET /admin/marketplace/pending
+func (h *AdminHandler) GetPendingTemplates(c *fiber.Ctx) error {
+	limit := 20
+	offset := 0
+	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset", "0")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	templates, total, err := h.moderationService.GetPendingTemplates(c.Context(), limit, offset)
+	if err != nil {
+		return internalError("failed to get pending templates", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"templates": templates,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// ApproveTemplate publishes a pending template
+// POST /admin/marketplace/templates/:id/approve
+func (h *AdminHandler) ApproveTemplate(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid template id")
+	}
+
+	template, _, err := h.moderationService.ApproveTemplate(c.Context(), adminID, templateID)
+	if template == nil {
+		return moderationError(err)
+	}
+	logNotifyError(err)
+	h.auditService.Record(c.Context(), service.AuditEvent{
+		Action:     domain.AuditActionAdminApproveTemplate,
+		EntityType: domain.AuditEntityTemplate,
+		EntityID:   templateID,
+		After:      map[string]any{"status": template.Status},
+		Details:    map[string]any{"name": template.Name},
+	})
+
+	return c.JSON(template)
+}
+
+// RejectTemplateRequest represents a template rejection
+type RejectTemplateRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// RejectTemplate rejects a pending template with a reason shown to the author
+// POST /admin/marketplace/templates/:id/reject
+func (h *AdminHandler) RejectTemplate(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid template id")
+	}
+
+	var req RejectTemplateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	template, _, err := h.moderationService.RejectTemplate(c.Context(), adminID, templateID, req.Reason)
+	if template == nil {
+		return moderationError(err)
+	}
+	logNotifyError(err)
+	h.auditService.Record(c.Context(), service.AuditEvent{
+		Action:     domain.AuditActionAdminRejectTemplate,
+		EntityType: domain.AuditEntityTemplate,
+		EntityID:   templateID,
+		After:      map[string]any{"status": template.Status},
+		Details:    map[string]any{"name": template.Name, "reason": req.Reason},
+	})
+
+	return c.JSON(template)
+}
+
+// ListModerationFlags returns the content moderation queue, oldest first.
+// Pending flags are listed unless status is given, or "all".
+// GET /admin/moderation/flags
+func (h *AdminHandler) ListModerationFlags(c *fiber.Ctx) error {
+	limit, offset := adminPage(c)
+
+	filter := domain.ModerationFlagFilter{
+		Status:      domain.ModerationFlagStatus(c.Query("status", string(domain.ModerationFlagPending))),
+		ContentType: domain.ModerationContentType(c.Query("content_type")),
+	}
+	switch filter.Status {
+	case "all":
+		filter.Status = ""
+	case domain.ModerationFlagPending, domain.ModerationFlagDismissed, domain.ModerationFlagUpheld:
+	default:
+		return badRequest("invalid flag status")
+	}
+	switch filter.ContentType {
+	case "", domain.ModerationContentMessage, domain.ModerationContentSystemPrompt, domain.ModerationContentTemplate:
+	default:
+		return badRequest("invalid content type")
+	}
+
+	flags, total, err := h.contentModeration.ListFlags(c.Context(), filter, limit, offset)
+	if err != nil {
+		return internalError("failed to get moderation flags", err)
+	}
+	if flags == nil {
+		flags = []*domain.ModerationFlag{}
+	}
+
+	return c.JSON(fiber.Map{
+		"flags":  flags,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ResolveFlagRequest records an admin's decision on flagged content
+type ResolveFlagRequest struct {
+	Status domain.ModerationFlagStatus `json:"status" validate:"required,oneof=dismissed upheld"`
+	Note   string                      `json:"note" validate:"max=500"`
+}
+
+// ResolveModerationFlag dismisses a pending flag as harmless or upholds it
+// POST /admin/moderation/flags/:id/resolve
+func (h *AdminHandler) ResolveModerationFlag(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id").(uuid.UUID)
+
+	flagID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid flag id")
+	}
+
+	var req ResolveFlagRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	flag, err := h.contentModeration.ResolveFlag(c.Context(), adminID, flagID, req.Status, req.Note)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("flag not found or already resolved")
+	}
+	if err != nil {
+		return internalError("failed to resolve flag", err)
+	}
+
+	return c.JSON(flag)
+}
+
+// RunRetentionRequest represents a manual retention run
+type RunRetentionRequest struct {
+	// DryRun overrides the configured mode when set
+	DryRun *bool `json:"dry_run"`
+}
+
+// RunRetention purges data older than each office's retention period now
+// and returns the run's report
+// POST /admin/retention/runs
+func (h *AdminHandler) RunRetention(c *fiber.Ctx) error {
+	var req RunRetentionRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	run, err := h.retentionService.Purge(c.Context(), req.DryRun)
+	if err != nil {
+		return internalError("failed to run retention", err)
+	}
+	h.auditService.Record(c.Context(), service.AuditEvent{
+		Action:     domain.AuditActionAdminRunRetention,
+		EntityType: domain.AuditEntityRetentionRun,
+		EntityID:   run.ID,
+		Details:    map[string]any{"dry_run": run.DryRun, "offices_purged": run.OfficesPurged, "deleted": run.Deleted},
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(run)
+}
+
+// ListRetentionRuns returns past retention runs, most recent first
+// GET /admin/retention/runs
+func (h *AdminHandler) ListRetentionRuns(c *fiber.Ctx) error {
+	limit := 20
+	offset := 0
+	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset", "0")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	runs, total, err := h.retentionService.ListRuns(c.Context(), limit, offset)
+	if err != nil {
+		return internalError("failed to get retention runs", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"runs":   runs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetRetentionRun returns a retention run with what it purged from each
+// office
+// GET /admin/retention/runs/:id
+func (h *AdminHandler) GetRetentionRun(c *fiber.Ctx) error {
+	runID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid run id")
+	}
+
+	run, err := h.retentionService.GetRun(c.Context(), runID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("retention run not found")
+	}
+	if err != nil {
+		return internalError("failed to get retention run", err)
+	}
+
+	return c.JSON(run)
+}
+
+// logNotifyError logs a failure to notify the template author; it does not
+// undo the moderation decision
+func logNotifyError(err error) {
+	if err != nil {
+		log.Printf("Failed to notify template author: %v", err)
+	}
+}
+
+// moderationError maps moderation errors to API errors
+func moderationError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("template not found or not pending review")
+	default:
+		return internalError("failed to moderate template", err)
+	}
+}
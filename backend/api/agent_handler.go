@@ -1,6 +1,9 @@
 package api
 
 import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -21,9 +24,7 @@ func NewAgentHandler(agentService *service.AgentService) *AgentHandler {
 func (h *AgentHandler) GetTemplates(c *fiber.Ctx) error {
 	templates, err := h.agentService.GetAvailableTemplates(c.Context())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get agent templates",
-		})
+		return internalError("failed to get agent templates", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -33,8 +34,8 @@ func (h *AgentHandler) GetTemplates(c *fiber.Ctx) error {
 
 // SelectAgentRequest represents a request to select an agent
 type SelectAgentRequest struct {
-	TemplateID string `json:"template_id"`
-	CustomName string `json:"custom_name,omitempty"`
+	TemplateID string `json:"template_id" validate:"required,uuid"`
+	CustomName string `json:"custom_name,omitempty" validate:"max=100"`
 }
 
 // SelectAgent adds an agent to the user's office
@@ -43,36 +44,28 @@ func (h *AgentHandler) SelectAgent(c *fiber.Ctx) error {
 	officeID := c.Locals("office_id").(uuid.UUID)
 
 	var req SelectAgentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	templateID, err := uuid.Parse(req.TemplateID)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid template_id",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	agent, err := h.agentService.SelectAgent(c.Context(), service.SelectAgentInput{
 		OfficeID:   officeID,
-		TemplateID: templateID,
+		UserID:     c.Locals("user_id").(uuid.UUID),
+		TemplateID: uuid.MustParse(req.TemplateID),
 		CustomName: req.CustomName,
 	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to select agent",
-		})
+		return selectAgentError(err, "failed to select agent")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(agent)
 }
 
-// SelectMultipleAgentsRequest represents a request to select multiple agents
+// SelectMultipleAgentsRequest represents a request to select multiple
+// agents, either by template ID or, to name them, as a list of selections
 type SelectMultipleAgentsRequest struct {
-	TemplateIDs []string `json:"template_ids"`
+	TemplateIDs []string             `json:"template_ids,omitempty" validate:"omitempty,max=50,dive,uuid"`
+	Agents      []SelectAgentRequest `json:"agents,omitempty" validate:"omitempty,max=50,dive"`
 }
 
 // SelectMultipleAgents adds multiple agents to the user's office
@@ -81,36 +74,34 @@ func (h *AgentHandler) SelectMultipleAgents(c *fiber.Ctx) error {
 	officeID := c.Locals("office_id").(uuid.UUID)
 
 	var req SelectMultipleAgentsRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	var templateIDs []uuid.UUID
+	selections := make([]service.AgentSelection, 0, len(req.TemplateIDs)+len(req.Agents))
 	for _, idStr := range req.TemplateIDs {
-		id, err := uuid.Parse(idStr)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid template_id: " + idStr,
-			})
-		}
-		templateIDs = append(templateIDs, id)
-	}
-
-	agents, err := h.agentService.SelectMultipleAgents(c.Context(), service.SelectMultipleAgentsInput{
-		OfficeID:    officeID,
-		TemplateIDs: templateIDs,
-	})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to select agents",
+		selections = append(selections, service.AgentSelection{TemplateID: uuid.MustParse(idStr)})
+	}
+	for _, a := range req.Agents {
+		selections = append(selections, service.AgentSelection{
+			TemplateID: uuid.MustParse(a.TemplateID),
+			CustomName: a.CustomName,
 		})
 	}
+	if len(selections) == 0 {
+		return badRequest("template_ids or agents is required")
+	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"agents": agents,
+	result, err := h.agentService.SelectMultipleAgents(c.Context(), service.SelectMultipleAgentsInput{
+		OfficeID: officeID,
+		UserID:   c.Locals("user_id").(uuid.UUID),
+		Agents:   selections,
 	})
+	if err != nil {
+		return selectAgentError(err, "failed to select agents")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
 }
 
 // GetAgents returns all agents in the user's office
@@ -120,9 +111,7 @@ func (h *AgentHandler) GetAgents(c *fiber.Ctx) error {
 
 	agents, err := h.agentService.GetOfficeAgents(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get agents",
-		})
+		return internalError("failed to get agents", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -133,40 +122,159 @@ func (h *AgentHandler) GetAgents(c *fiber.Ctx) error {
 // GetAgent returns a specific agent
 // GET /agents/:id
 func (h *AgentHandler) GetAgent(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
 	agentIDStr := c.Params("id")
 	agentID, err := uuid.Parse(agentIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid agent id",
-		})
+		return badRequest("invalid agent id")
 	}
 
-	agent, err := h.agentService.GetAgent(c.Context(), agentID)
+	agent, err := h.agentService.GetAgent(c.Context(), officeID, agentID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "agent not found",
-		})
+		return notFound("agent not found")
+	}
+
+	return c.JSON(agent)
+}
+
+// UpdateAgentRequest represents a request to customize an agent. Omitted
+// fields are left unchanged; an empty string clears the override.
+type UpdateAgentRequest struct {
+	CustomName         *string `json:"custom_name,omitempty" validate:"omitempty,max=100"`
+	CustomSystemPrompt *string `json:"custom_system_prompt,omitempty" validate:"omitempty,max=8000"`
+	CustomAvatarURL    *string `json:"custom_avatar_url,omitempty" validate:"omitempty,max=500"`
+}
+
+// UpdateAgent customizes an agent's name, system prompt and avatar
+// PUT /agents/:id
+func (h *AgentHandler) UpdateAgent(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req UpdateAgentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	agent, err := h.agentService.UpdateAgent(c.Context(), service.UpdateAgentInput{
+		OfficeID:           officeID,
+		AgentID:            agentID,
+		UserID:             userID,
+		CustomName:         req.CustomName,
+		CustomSystemPrompt: req.CustomSystemPrompt,
+		CustomAvatarURL:    req.CustomAvatarURL,
+	})
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent not found")
+	case err != nil:
+		return internalError("failed to update agent", err)
 	}
 
 	return c.JSON(agent)
 }
 
-// DeactivateAgent deactivates an agent
+// GetAgentChanges returns the customization history of an agent
+// GET /agents/:id/changes
+func (h *AgentHandler) GetAgentChanges(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	changes, err := h.agentService.GetAgentChanges(c.Context(), officeID, agentID)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent not found")
+	case err != nil:
+		return internalError("failed to get agent changes", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"changes": changes,
+	})
+}
+
+// DeleteAgent deletes an agent; it can be restored for 30 days
 // DELETE /agents/:id
-func (h *AgentHandler) DeactivateAgent(c *fiber.Ctx) error {
+func (h *AgentHandler) DeleteAgent(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
 	agentIDStr := c.Params("id")
 	agentID, err := uuid.Parse(agentIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid agent id",
-		})
+		return badRequest("invalid agent id")
 	}
 
-	if err := h.agentService.DeactivateAgent(c.Context(), agentID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to deactivate agent",
-		})
+	err = h.agentService.DeleteAgent(c.Context(), officeID, agentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("agent not found")
+	}
+	if err != nil {
+		return internalError("failed to delete agent", err)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// RestoreAgent brings back an agent deleted within the last 30 days
+// POST /agents/:id/restore
+func (h *AgentHandler) RestoreAgent(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	agent, err := h.agentService.RestoreAgent(c.Context(), officeID, agentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("no deleted agent to restore")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(agent)
+}
+
+// UpdateTemplate upgrades an agent to the latest version of its template
+// POST /agents/:id/update-template
+func (h *AgentHandler) UpdateTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	agent, err := h.agentService.UpgradeTemplate(c.Context(), officeID, agentID)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent not found")
+	case err != nil:
+		return internalError("failed to update agent template", err)
+	}
+
+	return c.JSON(agent)
+}
+
+// selectAgentError maps agent selection errors to API errors
+func selectAgentError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent template not found")
+	case errors.Is(err, domain.ErrPurchaseRequired):
+		return errorFor(domain.ErrPurchaseRequired, "this premium template must be purchased before it can be added to your office")
+	default:
+		return internalError(fallback, err)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"strconv"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -31,141 +32,160 @@ func (h *AnalyticsHandler) getOfficeID(c *fiber.Ctx) (uuid.UUID, error) {
 	return officeID, nil
 }
 
-// GetUsageSummary returns usage summary for the office
-// GET /api/v1/usage/summary?period=30d
+// usagePeriod reads the days an analytics request covers: from and to as
+// YYYY-MM-DD, or the last days days, 30 unless given within 1 to 90
+func usagePeriod(c *fiber.Ctx) service.UsagePeriod {
+	period := service.UsagePeriod{Days: 30, From: c.Query("from"), To: c.Query("to")}
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
+			period.Days = parsed
+		}
+	}
+	return period
+}
+
+// usageDates describes the days an analytics response covers
+func usageDates(dates domain.DateRange) fiber.Map {
+	return fiber.Map{
+		"days": dates.Days(),
+		"from": dates.From.Format("2006-01-02"),
+		"to":   dates.To.Format("2006-01-02"),
+	}
+}
+
+// GetUsageSummary returns usage summary for the office, compared with the
+// period just before
+// GET /api/v1/usage/summary?period=30d or ?from=2026-01-01&to=2026-01-31
 func (h *AnalyticsHandler) GetUsageSummary(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
-	period := c.Query("period", "30d")
+	period := usagePeriod(c)
+	switch c.Query("period", "30d") {
+	case "today":
+		period.Days = 1
+	case "7d":
+		period.Days = 7
+	default:
+		period.Days = 30
+	}
 
 	summary, err := h.analyticsService.GetUsageSummary(c.Context(), officeID, period)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(summary)
 }
 
 // GetUsageBreakdown returns detailed usage breakdown
-// GET /api/v1/usage/breakdown?days=30
+// GET /api/v1/usage/breakdown?days=30 or ?from=2026-01-01&to=2026-01-31
 func (h *AnalyticsHandler) GetUsageBreakdown(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
-			days = parsed
-		}
-	}
+	period := usagePeriod(c)
 
-	breakdown, err := h.analyticsService.GetUsageBreakdown(c.Context(), officeID, days)
+	breakdown, err := h.analyticsService.GetUsageBreakdown(c.Context(), officeID, period)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(breakdown)
 }
 
 // GetDailyUsage returns daily usage trends
-// GET /api/v1/usage/daily?days=30
+// GET /api/v1/usage/daily?days=30 or ?from=2026-01-01&to=2026-01-31
 func (h *AnalyticsHandler) GetDailyUsage(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
-			days = parsed
-		}
-	}
+	period := usagePeriod(c)
 
-	usage, err := h.analyticsService.GetDailyUsage(c.Context(), officeID, days)
+	usage, dates, err := h.analyticsService.GetDailyUsage(c.Context(), officeID, period)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"days":  days,
-		"usage": usage,
-	})
+	response := usageDates(dates)
+	response["usage"] = usage
+	return c.JSON(response)
 }
 
 // GetModelUsage returns usage breakdown by model
-// GET /api/v1/usage/by-model?days=30
+// GET /api/v1/usage/by-model?days=30 or ?from=2026-01-01&to=2026-01-31
 func (h *AnalyticsHandler) GetModelUsage(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
-			days = parsed
-		}
-	}
+	period := usagePeriod(c)
 
-	usage, err := h.analyticsService.GetModelUsage(c.Context(), officeID, days)
+	usage, dates, err := h.analyticsService.GetModelUsage(c.Context(), officeID, period)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"days":   days,
-		"models": usage,
-	})
+	response := usageDates(dates)
+	response["models"] = usage
+	return c.JSON(response)
 }
 
 // GetAgentUsage returns usage breakdown by agent
-// GET /api/v1/usage/by-agent?days=30
+// GET /api/v1/usage/by-agent?days=30 or ?from=2026-01-01&to=2026-01-31
 func (h *AnalyticsHandler) GetAgentUsage(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
-			days = parsed
-		}
-	}
+	period := usagePeriod(c)
 
-	usage, err := h.analyticsService.GetAgentUsage(c.Context(), officeID, days)
+	usage, dates, err := h.analyticsService.GetAgentUsage(c.Context(), officeID, period)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"days":   days,
-		"agents": usage,
-	})
+	response := usageDates(dates)
+	response["agents"] = usage
+	return c.JSON(response)
+}
+
+// BackfillUsageRequest selects the usage rollups to rebuild from tasks
+type BackfillUsageRequest struct {
+	// OfficeID limits the backfill to one office; without it every office
+	// is rebuilt
+	OfficeID string `json:"office_id,omitempty" validate:"omitempty,uuid"`
+	From     string `json:"from" validate:"required,datetime=2006-01-02"`
+	To       string `json:"to" validate:"required,datetime=2006-01-02"`
+	// Overwrite replaces rollups that exist instead of only filling days
+	// without one
+	Overwrite bool `json:"overwrite"`
+}
+
+// BackfillUsage rebuilds usage rollups from tasks and their credit charges
+// POST /internal/analytics/backfill
+func (h *AnalyticsHandler) BackfillUsage(c *fiber.Ctx) error {
+	var req BackfillUsageRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	input := service.BackfillUsageInput{From: req.From, To: req.To, Overwrite: req.Overwrite}
+	if req.OfficeID != "" {
+		officeID := uuid.MustParse(req.OfficeID)
+		input.OfficeID = &officeID
+	}
+
+	backfill, err := h.analyticsService.BackfillUsage(c.Context(), input)
+	if err != nil {
+		return internalError("failed to backfill usage", err)
+	}
+	return c.JSON(backfill)
 }
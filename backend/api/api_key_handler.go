@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APIKeyHandler handles API key management endpoints
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKeyRequest represents a request to mint an API key
+type CreateAPIKeyRequest struct {
+	Name      string               `json:"name" validate:"required,max=100"`
+	Scopes    []domain.APIKeyScope `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresAt *time.Time           `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse carries a newly minted key. Key is not stored and
+// cannot be retrieved again.
+type CreateAPIKeyResponse struct {
+	APIKey *domain.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+// CreateAPIKey mints an API key for the user's office
+// POST /api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	key, secret, err := h.apiKeyService.CreateAPIKey(c.Context(), service.CreateAPIKeyInput{
+		OfficeID:  c.Locals("office_id").(uuid.UUID),
+		UserID:    c.Locals("user_id").(uuid.UUID),
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateAPIKeyResponse{APIKey: key, Key: secret})
+}
+
+// GetAPIKeys lists the office's API keys with their usage
+// GET /api-keys
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	keys, err := h.apiKeyService.GetAPIKeys(c.Context(), officeID)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	if keys == nil {
+		keys = []*domain.APIKey{}
+	}
+
+	return c.JSON(fiber.Map{"api_keys": keys})
+}
+
+// RevokeAPIKey revokes one of the office's API keys
+// DELETE /api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid API key id")
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(c.Context(), officeID, keyID); err != nil {
+		return apiKeyError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// apiKeyError maps API key errors to API errors
+func apiKeyError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("API key not found")
+	default:
+		return internalError("failed to manage API keys", err)
+	}
+}
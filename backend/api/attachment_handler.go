@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AttachmentHandler handles file attachment endpoints
+type AttachmentHandler struct {
+	attachmentService *service.AttachmentService
+}
+
+// NewAttachmentHandler creates a new AttachmentHandler
+func NewAttachmentHandler(attachmentService *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: attachmentService}
+}
+
+// AttachmentURLResponse is a temporary download URL of an attachment
+type AttachmentURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UploadAttachment uploads a file, sent as the multipart form field "file",
+// to a conversation. The returned ID is then passed in attachment_ids when
+// sending the message.
+// POST /conversations/:id/attachments
+func (h *AttachmentHandler) UploadAttachment(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return badRequest("a multipart file field named file is required")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return internalError("failed to read upload", err)
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(c.Context(), service.UploadAttachmentInput{
+		OfficeID:       officeID,
+		ConversationID: conversationID,
+		UserID:         userID,
+		Filename:       header.Filename,
+		ContentType:    header.Header.Get("Content-Type"),
+		Size:           header.Size,
+		Body:           file,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to upload attachment", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// GetAttachmentURL returns a temporary URL that downloads an attachment
+// GET /attachments/:id
+func (h *AttachmentHandler) GetAttachmentURL(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	attachmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid attachment id")
+	}
+
+	url, expiresAt, err := h.attachmentService.DownloadURL(c.Context(), officeID, attachmentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("attachment not found")
+	}
+	if err != nil {
+		return internalError("failed to sign attachment URL", err)
+	}
+
+	return c.JSON(AttachmentURLResponse{URL: url, ExpiresAt: expiresAt})
+}
+
+// GetAttachmentContent downloads an attachment through a signed URL from
+// GetAttachmentURL, for storages without URLs of their own. The signature
+// is the only authentication.
+// GET /attachments/:id/content?expires=...&signature=...
+func (h *AttachmentHandler) GetAttachmentContent(c *fiber.Ctx) error {
+	attachmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid attachment id")
+	}
+
+	attachment, contents, err := h.attachmentService.OpenSigned(c.Context(), attachmentID, c.Query("expires"), c.Query("signature"))
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("attachment not found")
+	}
+	if err != nil {
+		return internalError("failed to download attachment", err)
+	}
+
+	// Always download rather than render, so uploaded HTML or SVG cannot
+	// run in the API's origin
+	c.Attachment(attachment.Filename)
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendStream(contents, int(attachment.SizeBytes))
+}
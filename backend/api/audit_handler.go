@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AuditHandler handles the office audit log endpoint
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditLog returns the current office's audit log, most recent first,
+// optionally narrowed by the action, from and to parameters. Only the
+// office's owner and admins may read it.
+// GET /audit-log
+func (h *AuditHandler) ListAuditLog(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+	officeID := c.Locals("office_id").(uuid.UUID)
+	limit, offset := adminPage(c)
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	entries, total, err := h.auditService.ListForOffice(c.Context(), userID, officeID, filter, limit, offset)
+	if errors.Is(err, domain.ErrForbidden) {
+		return forbidden("only the office owner can read its audit log")
+	}
+	if err != nil {
+		return internalError("failed to get audit log", err)
+	}
+	if entries == nil {
+		entries = []*domain.AuditEntry{}
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// parseAuditFilter reads the action, from and to parameters of audit log
+// lists. Dates are YYYY-MM-DD or RFC 3339; a bare to date includes the
+// whole day.
+func parseAuditFilter(c *fiber.Ctx) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{Action: domain.AuditAction(c.Query("action"))}
+
+	if raw := c.Query("from"); raw != "" {
+		from, _, err := parseAuditTime(raw)
+		if err != nil {
+			return filter, badRequest("invalid from date")
+		}
+		filter.From = &from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, dateOnly, err := parseAuditTime(raw)
+		if err != nil {
+			return filter, badRequest("invalid to date")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, badRequest("from must be before to")
+	}
+	return filter, nil
+}
+
+func parseAuditTime(raw string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.DateOnly, raw); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, raw)
+	return t, false, err
+}
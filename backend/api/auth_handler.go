@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
+
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // AuthHandler handles authentication endpoints
@@ -18,32 +21,64 @@ func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	Email string `json:"email" validate:"required,email,max=255"`
+	// bcrypt only uses the first 72 bytes of a password
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Name     string `json:"name" validate:"required,max=255"`
 }
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ForgotPasswordRequest asks for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with a token from a reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// VerifyEmailRequest confirms an email address with a token from a
+// verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// SwitchOfficeRequest names the office to sign in to
+type SwitchOfficeRequest struct {
+	OfficeID uuid.UUID `json:"office_id" validate:"required"`
+}
+
+// UpdateProfileRequest represents a partial update to the user's profile
+type UpdateProfileRequest struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email,max=255"`
+}
+
+// ChangePasswordRequest represents a password change. CurrentPassword may be
+// omitted by users who signed up through social login and have none yet.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// DeleteAccountRequest confirms an account deletion with the user's password
+type DeleteAccountRequest struct {
+	Password string `json:"password,omitempty"`
 }
 
 // Register handles user registration
 // POST /auth/register
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	// Validate input
-	if req.Email == "" || req.Password == "" || req.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "email, password, and name are required",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	result, err := h.authService.Register(c.Context(), service.RegisterInput{
@@ -53,13 +88,9 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	})
 	if err != nil {
 		if err == domain.ErrAlreadyExists {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "user already exists",
-			})
+			return conflict("user already exists")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to register user",
-		})
+		return internalError("failed to register user", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(result)
@@ -69,17 +100,8 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 // POST /auth/login
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	// Validate input
-	if req.Email == "" || req.Password == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "email and password are required",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	result, err := h.authService.Login(c.Context(), service.LoginInput{
@@ -88,13 +110,9 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 	if err != nil {
 		if err == domain.ErrInvalidCredentials {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid email or password",
-			})
+			return unauthorized("invalid email or password")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to login",
-		})
+		return internalError("failed to login", err)
 	}
 
 	return c.JSON(result)
@@ -113,3 +131,166 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 		"email":     email,
 	})
 }
+
+// SwitchOffice issues a token for another of the user's offices
+// POST /auth/switch-office
+func (h *AuthHandler) SwitchOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req SwitchOfficeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	result, err := h.authService.SwitchOffice(c.Context(), userID, req.OfficeID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("office not found")
+		}
+		return internalError("failed to switch office", err)
+	}
+
+	return c.JSON(result)
+}
+
+// UpdateProfile changes the user's name or email. A new email is pending
+// until the user follows the verification link sent to it.
+// PUT /auth/me
+func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req UpdateProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.authService.UpdateProfile(c.Context(), userID, service.UpdateProfileInput{
+		Name:  req.Name,
+		Email: req.Email,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return conflict("email is already in use")
+		}
+		return internalError("failed to update profile", err)
+	}
+
+	return c.JSON(user)
+}
+
+// ChangePassword sets a new password for the signed-in user
+// PUT /auth/password
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req ChangePasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ChangePassword(c.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			return forbidden("current password is incorrect")
+		}
+		return internalError("failed to change password", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeleteAccount closes the signed-in user's account
+// DELETE /auth/me
+func (h *AuthHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	// The body is optional for users without a password
+	var req DeleteAccountRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	if err := h.authService.DeleteAccount(c.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			return forbidden("password is incorrect")
+		}
+		return internalError("failed to delete account", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ForgotPassword emails a password reset link. It responds the same way
+// whether or not the address is registered.
+// POST /auth/forgot-password
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ForgotPassword(c.Context(), req.Email); err != nil {
+		return internalError("failed to request password reset", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "if an account exists for this email, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password
+// POST /auth/reset-password
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ResetPassword(c.Context(), req.Token, req.Password); err != nil {
+		return authTokenError(err, "failed to reset password")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VerifyEmail confirms the user's email address
+// POST /auth/verify-email
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.VerifyEmail(c.Context(), req.Token); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return conflict("email is already in use")
+		}
+		return authTokenError(err, "failed to verify email")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ResendVerification emails the signed-in user a new verification link
+// POST /auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	if err := h.authService.ResendVerification(c.Context(), userID); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return conflict("email is already verified")
+		}
+		return internalError("failed to send verification email", err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// authTokenError maps errors from redeeming an emailed token to API errors
+func authTokenError(err error, message string) error {
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return badRequest("link is invalid or has expired")
+	}
+	return internalError(message, err)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AuthorHandler handles author profile endpoints
+type AuthorHandler struct {
+	authorService *service.AuthorService
+}
+
+// NewAuthorHandler creates a new AuthorHandler
+func NewAuthorHandler(authorService *service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{authorService: authorService}
+}
+
+// GetAuthorProfile returns an author's public profile with their templates
+// and bundles on sale
+// GET /marketplace/authors/:id
+func (h *AuthorHandler) GetAuthorProfile(c *fiber.Ctx) error {
+	authorID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid author id")
+	}
+
+	profile, err := h.authorService.GetPublicProfile(c.Context(), authorID)
+	if err != nil {
+		return authorError(err)
+	}
+	return c.JSON(profile)
+}
+
+// GetProfile returns the current user's author profile and verification
+// GET /author/profile
+func (h *AuthorHandler) GetProfile(c *fiber.Ctx) error {
+	profile, err := h.authorService.GetProfile(c.Context(), c.Locals("user_id").(uuid.UUID))
+	if err != nil {
+		return authorError(err)
+	}
+	return c.JSON(profile)
+}
+
+// UpdateProfile edits the current user's display name, bio and links
+// PUT /author/profile
+func (h *AuthorHandler) UpdateProfile(c *fiber.Ctx) error {
+	var req service.AuthorProfileInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	profile, err := h.authorService.UpdateProfile(c.Context(), c.Locals("user_id").(uuid.UUID), req)
+	if err != nil {
+		return authorError(err)
+	}
+	return c.JSON(profile)
+}
+
+// VerificationRequest represents a request for the verified badge
+type VerificationRequest struct {
+	// Message tells the admins who the author is, such as the company
+	// they publish for
+	Message string `json:"message" validate:"max=2000"`
+}
+
+// RequestVerification asks the admins to verify the current user as an
+// author
+// POST /author/verification
+func (h *AuthorHandler) RequestVerification(c *fiber.Ctx) error {
+	var req VerificationRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	profile, err := h.authorService.RequestVerification(c.Context(), c.Locals("user_id").(uuid.UUID), req.Message)
+	if err != nil {
+		return authorError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(profile)
+}
+
+// authorError maps author profile errors to responses
+func authorError(err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("author not found")
+	}
+	return err
+}
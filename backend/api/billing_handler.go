@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// BillingHandler handles invoice and billing history endpoints
+type BillingHandler struct {
+	billingService *service.BillingService
+}
+
+// NewBillingHandler creates a new BillingHandler
+func NewBillingHandler(billingService *service.BillingService) *BillingHandler {
+	return &BillingHandler{billingService: billingService}
+}
+
+// ListInvoices returns the office's most recent invoices, newest first
+// GET /billing/invoices
+func (h *BillingHandler) ListInvoices(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	invoices, err := h.billingService.ListInvoices(c.Context(), officeID, limit)
+	if err != nil {
+		return internalError("failed to get invoices", err)
+	}
+	if invoices == nil {
+		invoices = []*domain.Invoice{}
+	}
+
+	return c.JSON(fiber.Map{"invoices": invoices})
+}
+
+// DownloadInvoicePDF downloads one of the office's manual invoices as a
+// PDF; Stripe invoices link to Stripe's own PDF
+// GET /billing/invoices/:id/pdf
+func (h *BillingHandler) DownloadInvoicePDF(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	invoiceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid invoice id")
+	}
+
+	invoice, err := h.billingService.GetInvoice(c.Context(), officeID, invoiceID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("invoice not found")
+	}
+	if err != nil {
+		return internalError("failed to get invoice", err)
+	}
+
+	c.Attachment(fmt.Sprintf("invoice-%s.pdf", invoice.Number))
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		err := service.WriteInvoicePDF(w, invoice)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Printf("Failed to stream invoice %s: %v", invoice.ID, err)
+		}
+	})
+	return nil
+}
+
+// GetUpcomingInvoice returns the office's projected next charge
+// GET /billing/upcoming
+func (h *BillingHandler) GetUpcomingInvoice(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	invoice, err := h.billingService.GetUpcomingInvoice(c.Context(), officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("no upcoming charge")
+	}
+	if err != nil {
+		return internalError("failed to get upcoming charge", err)
+	}
+
+	return c.JSON(invoice)
+}
@@ -1,7 +1,8 @@
 package api
 
 import (
-	"strconv"
+	"errors"
+	"net/url"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
@@ -21,9 +22,9 @@ func NewChatHandler(chatService *service.ChatService) *ChatHandler {
 
 // CreateConversationRequest represents a request to create a conversation
 type CreateConversationRequest struct {
-	Type     string   `json:"type"` // "direct" or "group"
-	Name     string   `json:"name,omitempty"`
-	AgentIDs []string `json:"agent_ids"`
+	Type     string   `json:"type" validate:"required,oneof=direct group"`
+	Name     string   `json:"name,omitempty" validate:"max=255"`
+	AgentIDs []string `json:"agent_ids" validate:"required,min=1,dive,uuid"`
 }
 
 // CreateConversation creates a new conversation
@@ -32,57 +33,38 @@ func (h *ChatHandler) CreateConversation(c *fiber.Ctx) error {
 	officeID := c.Locals("office_id").(uuid.UUID)
 
 	var req CreateConversationRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	// Validate conversation type
-	convType := domain.ConversationType(req.Type)
-	if convType != domain.ConversationTypeDirect && convType != domain.ConversationTypeGroup {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "type must be 'direct' or 'group'",
-		})
-	}
-
-	// Parse agent IDs
-	var agentIDs []uuid.UUID
-	for _, idStr := range req.AgentIDs {
-		id, err := uuid.Parse(idStr)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid agent_id: " + idStr,
-			})
-		}
-		agentIDs = append(agentIDs, id)
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	agentIDs := make([]uuid.UUID, len(req.AgentIDs))
+	for i, idStr := range req.AgentIDs {
+		agentIDs[i] = uuid.MustParse(idStr)
 	}
 
 	conversation, err := h.chatService.CreateConversation(c.Context(), service.CreateConversationInput{
 		OfficeID: officeID,
-		Type:     convType,
+		Type:     domain.ConversationType(req.Type),
 		Name:     req.Name,
 		AgentIDs: agentIDs,
 	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to create conversation",
-		})
+		return internalError("failed to create conversation", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(conversation)
 }
 
-// GetConversations returns all conversations for the office
-// GET /conversations
+// GetConversations returns the office's conversations with the user's
+// unread counts, including archived ones only when asked to
+// GET /conversations?include_archived=true
 func (h *ChatHandler) GetConversations(c *fiber.Ctx) error {
 	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
 
-	conversations, err := h.chatService.GetConversations(c.Context(), officeID)
+	conversations, err := h.chatService.GetConversations(c.Context(), officeID, userID, c.QueryBool("include_archived"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get conversations",
-		})
+		return internalError("failed to get conversations", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -93,27 +75,251 @@ func (h *ChatHandler) GetConversations(c *fiber.Ctx) error {
 // GetConversation returns a specific conversation
 // GET /conversations/:id
 func (h *ChatHandler) GetConversation(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
 	conversationIDStr := c.Params("id")
 	conversationID, err := uuid.Parse(conversationIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid conversation id",
-		})
+		return badRequest("invalid conversation id")
+	}
+
+	conversation, err := h.chatService.GetConversation(c.Context(), officeID, conversationID)
+	if err != nil {
+		return notFound("conversation not found")
+	}
+
+	return c.JSON(conversation)
+}
+
+// UpdateConversationRequest represents a request to rename or archive a
+// conversation
+type UpdateConversationRequest struct {
+	Name     *string `json:"name,omitempty" validate:"omitempty,max=255"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// UpdateConversation renames, archives or unarchives a conversation
+// PATCH /conversations/:id
+func (h *ChatHandler) UpdateConversation(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	var req UpdateConversationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	conversation, err := h.chatService.UpdateConversation(c.Context(), service.UpdateConversationInput{
+		OfficeID:       officeID,
+		ConversationID: conversationID,
+		Name:           req.Name,
+		Archived:       req.Archived,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to update conversation", err)
+	}
+
+	return c.JSON(conversation)
+}
+
+// UpdateOrchestrationRequest represents a request to change how a group
+// conversation's agents take turns
+type UpdateOrchestrationRequest struct {
+	Mode         domain.OrchestrationMode `json:"mode" validate:"required,oneof=mentions round_robin moderator all debate"`
+	ModeratorID  *uuid.UUID               `json:"moderator_id,omitempty" validate:"required_if=Mode moderator"`
+	DebateRounds *int                     `json:"debate_rounds,omitempty" validate:"omitempty,min=1,max=5"`
+}
+
+// UpdateOrchestration sets the orchestration mode of a group conversation
+// PATCH /conversations/:id/orchestration
+func (h *ChatHandler) UpdateOrchestration(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	var req UpdateOrchestrationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	conversation, err := h.chatService.UpdateOrchestration(c.Context(), service.UpdateOrchestrationInput{
+		OfficeID:       officeID,
+		ConversationID: conversationID,
+		Mode:           req.Mode,
+		ModeratorID:    req.ModeratorID,
+		DebateRounds:   req.DebateRounds,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to update orchestration", err)
+	}
+
+	return c.JSON(conversation)
+}
+
+// DeleteConversation deletes a conversation with its messages; it can be
+// restored for 30 days
+// DELETE /conversations/:id
+func (h *ChatHandler) DeleteConversation(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	err = h.chatService.DeleteConversation(c.Context(), officeID, conversationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to delete conversation", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RestoreConversation brings back a conversation deleted within the last 30
+// days
+// POST /conversations/:id/restore
+func (h *ChatHandler) RestoreConversation(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	conversation, err := h.chatService.RestoreConversation(c.Context(), officeID, conversationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("no deleted conversation to restore")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(conversation)
+}
+
+// AddParticipantRequest represents a request to add an agent to a
+// conversation
+type AddParticipantRequest struct {
+	AgentID string `json:"agent_id" validate:"required,uuid"`
+}
+
+// AddParticipant adds an agent to a group conversation
+// POST /conversations/:id/participants
+func (h *ChatHandler) AddParticipant(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	var req AddParticipantRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	conversation, err := h.chatService.GetConversation(c.Context(), conversationID)
+	conversation, err := h.chatService.AddParticipant(c.Context(), officeID, conversationID, uuid.MustParse(req.AgentID))
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "conversation not found",
-		})
+		return internalError("failed to add participant", err)
 	}
 
 	return c.JSON(conversation)
 }
 
+// RemoveParticipant removes an agent from a group conversation
+// DELETE /conversations/:id/participants/:agentId
+func (h *ChatHandler) RemoveParticipant(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+	agentID, err := uuid.Parse(c.Params("agentId"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	conversation, err := h.chatService.RemoveParticipant(c.Context(), officeID, conversationID, agentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to remove participant", err)
+	}
+
+	return c.JSON(conversation)
+}
+
+// MarkReadRequest represents a request to mark a conversation as read
+type MarkReadRequest struct {
+	// MessageID is the last message read; omitted means the latest message
+	MessageID *uuid.UUID `json:"message_id,omitempty"`
+}
+
+// MarkRead marks a conversation as read up to a message
+// POST /conversations/:id/read
+func (h *ChatHandler) MarkRead(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	var req MarkReadRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	read, err := h.chatService.MarkRead(c.Context(), service.MarkReadInput{
+		OfficeID:       officeID,
+		UserID:         userID,
+		ConversationID: conversationID,
+		MessageID:      req.MessageID,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to mark conversation as read", err)
+	}
+	if read == nil {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	return c.JSON(read)
+}
+
 // SendMessageRequest represents a request to send a message
 type SendMessageRequest struct {
-	Content string `json:"content"`
+	Content string `json:"content" validate:"required_without=AttachmentIDs"`
+	// ParentMessageID posts the message as a reply in that message's thread
+	ParentMessageID *uuid.UUID `json:"parent_message_id,omitempty"`
+	// AttachmentIDs sends files uploaded to the conversation with the message
+	AttachmentIDs []uuid.UUID `json:"attachment_ids,omitempty" validate:"max=10"`
 }
 
 // SendMessage sends a message in a conversation
@@ -125,62 +331,211 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 	conversationIDStr := c.Params("id")
 	conversationID, err := uuid.Parse(conversationIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid conversation id",
-		})
+		return badRequest("invalid conversation id")
 	}
 
 	var req SendMessageRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	if req.Content == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "content is required",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	message, err := h.chatService.SendMessage(c.Context(), service.SendMessageInput{
-		OfficeID:       officeID,
-		ConversationID: conversationID,
-		SenderType:     domain.SenderTypeUser,
-		SenderID:       userID,
-		Content:        req.Content,
+		OfficeID:        officeID,
+		ConversationID:  conversationID,
+		SenderType:      domain.SenderTypeUser,
+		SenderID:        userID,
+		Content:         req.Content,
+		ParentMessageID: req.ParentMessageID,
+		AttachmentIDs:   req.AttachmentIDs,
 	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to send message",
-		})
+		return internalError("failed to send message", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(message)
 }
 
-// GetMessages returns messages for a conversation
-// GET /conversations/:id/messages
+// GetMessages returns messages for a conversation, oldest first
+// GET /conversations/:id/messages?limit=50&cursor=...
 func (h *ChatHandler) GetMessages(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
 	conversationIDStr := c.Params("id")
 	conversationID, err := uuid.Parse(conversationIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid conversation id",
-		})
+		return badRequest("invalid conversation id")
 	}
 
-	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	page, err := parsePageRequest(c, 50, 200)
+	if err != nil {
+		return err
+	}
 
-	messages, err := h.chatService.GetMessages(c.Context(), conversationID, limit, offset)
+	messages, total, err := h.chatService.GetMessages(c.Context(), officeID, conversationID, page)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get messages",
-		})
+		return internalError("failed to get messages", err)
+	}
+
+	return c.JSON(newPage(messages, total, page.Limit, func(m *domain.Message) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: m.CreatedAt, ID: m.ID}
+	}))
+}
+
+// GetThread returns the first message of a thread and its replies, oldest
+// first
+// GET /messages/:id/thread
+func (h *ChatHandler) GetThread(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid message id")
+	}
+
+	message, replies, err := h.chatService.GetThread(c.Context(), officeID, messageID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("message not found")
+	}
+	if err != nil {
+		return internalError("failed to get thread", err)
 	}
 
 	return c.JSON(fiber.Map{
-		"messages": messages,
+		"message": message,
+		"replies": replies,
+	})
+}
+
+// EditMessageRequest represents a request to edit a message
+type EditMessageRequest struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// EditMessage changes the content of one of the user's messages
+// PATCH /messages/:id
+func (h *ChatHandler) EditMessage(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid message id")
+	}
+
+	var req EditMessageRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	message, err := h.chatService.EditMessage(c.Context(), service.EditMessageInput{
+		OfficeID:  officeID,
+		UserID:    userID,
+		MessageID: messageID,
+		Content:   req.Content,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("message not found")
+	}
+	if err != nil {
+		return internalError("failed to edit message", err)
+	}
+
+	return c.JSON(message)
+}
+
+// DeleteMessage deletes a message, erasing its content
+// DELETE /messages/:id
+func (h *ChatHandler) DeleteMessage(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid message id")
+	}
+
+	err = h.chatService.DeleteMessage(c.Context(), officeID, userID, messageID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("message not found")
+	}
+	if err != nil {
+		return internalError("failed to delete message", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AddReactionRequest represents a request to react to a message
+type AddReactionRequest struct {
+	Emoji string `json:"emoji" validate:"required,max=32"`
+}
+
+// AddReaction adds the user's emoji reaction to a message
+// POST /messages/:id/reactions
+func (h *ChatHandler) AddReaction(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid message id")
+	}
+
+	var req AddReactionRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	message, err := h.chatService.AddReaction(c.Context(), service.ReactionInput{
+		OfficeID:  officeID,
+		UserID:    userID,
+		MessageID: messageID,
+		Emoji:     req.Emoji,
 	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("message not found")
+	}
+	if err != nil {
+		return internalError("failed to add reaction", err)
+	}
+
+	return c.JSON(message)
+}
+
+// RemoveReaction removes the user's emoji reaction from a message. The emoji
+// is URL-encoded in the path.
+// DELETE /messages/:id/reactions/:emoji
+func (h *ChatHandler) RemoveReaction(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	messageID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid message id")
+	}
+	emoji, err := url.PathUnescape(c.Params("emoji"))
+	if err != nil {
+		return badRequest("invalid emoji")
+	}
+
+	message, err := h.chatService.RemoveReaction(c.Context(), service.ReactionInput{
+		OfficeID:  officeID,
+		UserID:    userID,
+		MessageID: messageID,
+		Emoji:     emoji,
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("message not found")
+	}
+	if err != nil {
+		return internalError("failed to remove reaction", err)
+	}
+
+	return c.JSON(message)
 }
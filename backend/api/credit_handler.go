@@ -1,8 +1,9 @@
 package api
 
 import (
-	"strconv"
+	"errors"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -10,12 +11,25 @@ import (
 
 // CreditHandler handles credit wallet endpoints
 type CreditHandler struct {
-	creditService *service.CreditService
+	creditService       *service.CreditService
+	costEstimateService *service.CostEstimateService
+	promoService        *service.PromoService
+	autoTopUpService    *service.AutoTopUpService
 }
 
 // NewCreditHandler creates a new CreditHandler
-func NewCreditHandler(creditService *service.CreditService) *CreditHandler {
-	return &CreditHandler{creditService: creditService}
+func NewCreditHandler(
+	creditService *service.CreditService,
+	costEstimateService *service.CostEstimateService,
+	promoService *service.PromoService,
+	autoTopUpService *service.AutoTopUpService,
+) *CreditHandler {
+	return &CreditHandler{
+		creditService:       creditService,
+		costEstimateService: costEstimateService,
+		promoService:        promoService,
+		autoTopUpService:    autoTopUpService,
+	}
 }
 
 // GetWallet returns the credit wallet for the current office
@@ -23,23 +37,17 @@ func NewCreditHandler(creditService *service.CreditService) *CreditHandler {
 func (h *CreditHandler) GetWallet(c *fiber.Ctx) error {
 	officeIDVal := c.Locals("office_id")
 	if officeIDVal == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	officeID, ok := officeIDVal.(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id type",
-		})
+		return badRequest("invalid office_id type")
 	}
 
 	wallet, err := h.creditService.GetWallet(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get wallet",
-		})
+		return internalError("failed to get wallet", err)
 	}
 
 	return c.JSON(wallet)
@@ -50,23 +58,17 @@ func (h *CreditHandler) GetWallet(c *fiber.Ctx) error {
 func (h *CreditHandler) GetBalance(c *fiber.Ctx) error {
 	officeIDVal := c.Locals("office_id")
 	if officeIDVal == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	officeID, ok := officeIDVal.(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id type",
-		})
+		return badRequest("invalid office_id type")
 	}
 
 	balance, err := h.creditService.GetBalance(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get balance",
-		})
+		return internalError("failed to get balance", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -79,106 +81,75 @@ func (h *CreditHandler) GetBalance(c *fiber.Ctx) error {
 func (h *CreditHandler) GetWalletSummary(c *fiber.Ctx) error {
 	officeIDVal := c.Locals("office_id")
 	if officeIDVal == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	officeID, ok := officeIDVal.(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id type",
-		})
+		return badRequest("invalid office_id type")
 	}
 
 	summary, err := h.creditService.GetWalletSummary(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get wallet summary",
-		})
+		return internalError("failed to get wallet summary", err)
 	}
 
 	return c.JSON(summary)
 }
 
 // GetTransactions returns credit transaction history
-// GET /credits/transactions?limit=50&offset=0
+// GET /credits/transactions?limit=50&cursor=...
 func (h *CreditHandler) GetTransactions(c *fiber.Ctx) error {
 	officeIDVal := c.Locals("office_id")
 	if officeIDVal == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	officeID, ok := officeIDVal.(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id type",
-		})
+		return badRequest("invalid office_id type")
 	}
 
-	// Parse pagination params
-	limit := 50
-	offset := 0
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
-	}
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	page, err := parsePageRequest(c, 50, 100)
+	if err != nil {
+		return err
 	}
 
-	transactions, err := h.creditService.GetTransactionHistory(c.Context(), officeID, limit, offset)
+	transactions, total, err := h.creditService.GetTransactionHistory(c.Context(), officeID, page)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get transactions",
-		})
+		return internalError("failed to get transactions", err)
 	}
 
-	return c.JSON(fiber.Map{
-		"transactions": transactions,
-		"limit":        limit,
-		"offset":       offset,
-	})
+	return c.JSON(newPage(transactions, total, page.Limit, func(tx *domain.CreditTransaction) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+	}))
 }
 
 // CheckBalance checks if there are sufficient credits for an operation
 // POST /credits/check
 type CheckBalanceRequest struct {
-	RequiredCredits int64 `json:"required_credits"`
+	RequiredCredits int64 `json:"required_credits" validate:"gte=0"`
 }
 
 func (h *CreditHandler) CheckBalance(c *fiber.Ctx) error {
 	officeIDVal := c.Locals("office_id")
 	if officeIDVal == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	officeID, ok := officeIDVal.(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id type",
-		})
+		return badRequest("invalid office_id type")
 	}
 
 	var req CheckBalanceRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	hasSufficient, currentBalance, err := h.creditService.CheckSufficientCredits(c.Context(), officeID, req.RequiredCredits)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to check balance",
-		})
+		return internalError("failed to check balance", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -187,3 +158,126 @@ func (h *CreditHandler) CheckBalance(c *fiber.Ctx) error {
 		"required_credits": req.RequiredCredits,
 	})
 }
+
+// EstimateCostRequest describes a task to estimate the cost of
+type EstimateCostRequest struct {
+	AgentID uuid.UUID `json:"agent_id" validate:"required"`
+	Input   string    `json:"input" validate:"required"`
+	// ConversationID, if set, prices the conversation's history in
+	ConversationID *uuid.UUID `json:"conversation_id,omitempty"`
+}
+
+// EstimateCost estimates the credits an agent would spend answering an input
+// POST /credits/estimate
+func (h *CreditHandler) EstimateCost(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	var req EstimateCostRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	input := service.CostEstimateInput{
+		OfficeID: officeID,
+		AgentID:  req.AgentID,
+		Input:    req.Input,
+	}
+	if req.ConversationID != nil {
+		input.ConversationID = *req.ConversationID
+	}
+
+	estimate, err := h.costEstimateService.Estimate(c.Context(), input)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent or conversation not found")
+	case err != nil:
+		return internalError("failed to estimate cost", err)
+	}
+
+	return c.JSON(estimate)
+}
+
+// RedeemPromoCodeRequest is a promo code entered by a user
+type RedeemPromoCodeRequest struct {
+	Code string `json:"code" validate:"required,max=64"`
+}
+
+// RedeemPromoCode redeems a promo code for the current office
+// POST /credits/redeem
+func (h *CreditHandler) RedeemPromoCode(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req RedeemPromoCodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	redemption, err := h.promoService.Redeem(c.Context(), officeID, userID, req.Code)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(redemption)
+}
+
+// GetPromoRedemptions lists the promo codes the current office redeemed
+// GET /credits/redemptions
+func (h *CreditHandler) GetPromoRedemptions(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	redemptions, err := h.promoService.GetOfficeRedemptions(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to get promo redemptions", err)
+	}
+
+	return c.JSON(fiber.Map{"redemptions": redemptions})
+}
+
+// UpdateLowBalanceRequest sets the current office's low balance warning and
+// auto top-up
+type UpdateLowBalanceRequest struct {
+	Threshold        int64  `json:"threshold" validate:"min=0"`
+	AutoTopUpEnabled bool   `json:"auto_top_up_enabled"`
+	Package          string `json:"package" validate:"max=50"`
+	PaymentMethodID  string `json:"payment_method_id" validate:"max=255"`
+	MaxPerDay        int    `json:"max_per_day" validate:"min=0"`
+}
+
+// UpdateLowBalance saves the current office's low balance settings
+// PUT /credits/low-balance
+func (h *CreditHandler) UpdateLowBalance(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req UpdateLowBalanceRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	wallet, err := h.autoTopUpService.UpdateSettings(c.Context(), officeID, userID, service.LowBalanceSettings{
+		Threshold:        req.Threshold,
+		AutoTopUpEnabled: req.AutoTopUpEnabled,
+		Package:          req.Package,
+		PaymentMethodID:  req.PaymentMethodID,
+		MaxPerDay:        req.MaxPerDay,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(wallet)
+}
+
+// GetAutoTopUps lists the current office's most recent auto top-ups
+// GET /credits/auto-top-ups
+func (h *CreditHandler) GetAutoTopUps(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	topUps, err := h.autoTopUpService.ListTopUps(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to get auto top-ups", err)
+	}
+
+	return c.JSON(fiber.Map{"auto_top_ups": topUps})
+}
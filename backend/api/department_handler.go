@@ -0,0 +1,245 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// DepartmentHandler handles the departments and reporting lines endpoints
+type DepartmentHandler struct {
+	departmentService *service.DepartmentService
+}
+
+// NewDepartmentHandler creates a new DepartmentHandler
+func NewDepartmentHandler(departmentService *service.DepartmentService) *DepartmentHandler {
+	return &DepartmentHandler{departmentService: departmentService}
+}
+
+// CreateDepartmentRequest represents a request to create a department
+type CreateDepartmentRequest struct {
+	Name        string    `json:"name" validate:"required,max=100"`
+	Description string    `json:"description,omitempty" validate:"max=2000"`
+	LeadAgentID uuid.UUID `json:"lead_agent_id" validate:"required"`
+	// MemberIDs are the other members; the lead is always one
+	MemberIDs []uuid.UUID `json:"member_ids,omitempty"`
+}
+
+// UpdateDepartmentRequest changes a department; omitted fields are left
+// unchanged
+type UpdateDepartmentRequest struct {
+	Name        *string    `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string    `json:"description,omitempty" validate:"omitempty,max=2000"`
+	LeadAgentID *uuid.UUID `json:"lead_agent_id,omitempty"`
+}
+
+// AddDepartmentMemberRequest represents a request to add an agent to a
+// department
+type AddDepartmentMemberRequest struct {
+	AgentID uuid.UUID `json:"agent_id" validate:"required"`
+}
+
+// SetManagerRequest sets whom an agent reports to; a null manager_id
+// removes its reporting line
+type SetManagerRequest struct {
+	ManagerID *uuid.UUID `json:"manager_id"`
+}
+
+// GetOrgChart returns the office's departments and reporting lines
+// GET /org-chart
+func (h *DepartmentHandler) GetOrgChart(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	chart, err := h.departmentService.GetOrgChart(c.Context(), officeID)
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.JSON(chart)
+}
+
+// CreateDepartment creates a department with its group conversation
+// POST /departments
+func (h *DepartmentHandler) CreateDepartment(c *fiber.Ctx) error {
+	var req CreateDepartmentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	department, err := h.departmentService.CreateDepartment(c.Context(), service.CreateDepartmentInput{
+		OfficeID:    c.Locals("office_id").(uuid.UUID),
+		Name:        req.Name,
+		Description: req.Description,
+		LeadAgentID: req.LeadAgentID,
+		MemberIDs:   req.MemberIDs,
+	})
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(department)
+}
+
+// GetDepartments lists the office's departments
+// GET /departments
+func (h *DepartmentHandler) GetDepartments(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departments, err := h.departmentService.GetDepartments(c.Context(), officeID)
+	if err != nil {
+		return departmentError(err)
+	}
+	if departments == nil {
+		departments = []*domain.Department{}
+	}
+
+	return c.JSON(fiber.Map{"departments": departments})
+}
+
+// GetDepartment returns one of the office's departments
+// GET /departments/:id
+func (h *DepartmentHandler) GetDepartment(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid department id")
+	}
+
+	department, err := h.departmentService.GetDepartment(c.Context(), officeID, departmentID)
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.JSON(department)
+}
+
+// UpdateDepartment renames a department, changes its description or lead
+// PATCH /departments/:id
+func (h *DepartmentHandler) UpdateDepartment(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid department id")
+	}
+
+	var req UpdateDepartmentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	department, err := h.departmentService.UpdateDepartment(c.Context(), service.UpdateDepartmentInput{
+		OfficeID:     officeID,
+		DepartmentID: departmentID,
+		Name:         req.Name,
+		Description:  req.Description,
+		LeadAgentID:  req.LeadAgentID,
+	})
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.JSON(department)
+}
+
+// DeleteDepartment removes one of the office's departments, keeping its
+// conversation
+// DELETE /departments/:id
+func (h *DepartmentHandler) DeleteDepartment(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid department id")
+	}
+
+	if err := h.departmentService.DeleteDepartment(c.Context(), officeID, departmentID); err != nil {
+		return departmentError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AddMember adds an agent to a department
+// POST /departments/:id/members
+func (h *DepartmentHandler) AddMember(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid department id")
+	}
+
+	var req AddDepartmentMemberRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	department, err := h.departmentService.AddMember(c.Context(), officeID, departmentID, req.AgentID)
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.JSON(department)
+}
+
+// RemoveMember removes an agent from a department
+// DELETE /departments/:id/members/:agentId
+func (h *DepartmentHandler) RemoveMember(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	departmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid department id")
+	}
+	agentID, err := uuid.Parse(c.Params("agentId"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	department, err := h.departmentService.RemoveMember(c.Context(), officeID, departmentID, agentID)
+	if err != nil {
+		return departmentError(err)
+	}
+
+	return c.JSON(department)
+}
+
+// SetManager sets whom an agent reports to
+// PUT /agents/:id/manager
+func (h *DepartmentHandler) SetManager(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req SetManagerRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.departmentService.SetManager(c.Context(), officeID, agentID, req.ManagerID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("agent not found")
+		}
+		return internalError("failed to set the agent's manager", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// departmentError maps department errors to API errors
+func departmentError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("department not found")
+	default:
+		return internalError("failed to manage departments", err)
+	}
+}
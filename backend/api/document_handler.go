@@ -0,0 +1,178 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// DocumentHandler handles the endpoints of documents agents produced
+type DocumentHandler struct {
+	documentService *service.DocumentService
+}
+
+// NewDocumentHandler creates a new DocumentHandler
+func NewDocumentHandler(documentService *service.DocumentService) *DocumentHandler {
+	return &DocumentHandler{documentService: documentService}
+}
+
+// documentFormats maps each document format to its content type and file
+// extension
+var documentFormats = map[domain.DocumentFormat]struct{ contentType, extension string }{
+	domain.DocumentFormatMarkdown: {"text/markdown; charset=utf-8", "md"},
+	domain.DocumentFormatText:     {fiber.MIMETextPlainCharsetUTF8, "txt"},
+	domain.DocumentFormatHTML:     {fiber.MIMETextHTMLCharsetUTF8, "html"},
+	domain.DocumentFormatJSON:     {fiber.MIMEApplicationJSONCharsetUTF8, "json"},
+	domain.DocumentFormatCSV:      {"text/csv; charset=utf-8", "csv"},
+}
+
+// ListDocuments returns the office's documents without their content,
+// optionally filtered by conversation or agent
+// GET /documents
+func (h *DocumentHandler) ListDocuments(c *fiber.Ctx) error {
+	filter := domain.DocumentFilter{OfficeID: c.Locals("office_id").(uuid.UUID)}
+
+	if raw := c.Query("conversation_id"); raw != "" {
+		conversationID, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid conversation_id")
+		}
+		filter.ConversationID = conversationID
+	}
+	if raw := c.Query("agent_id"); raw != "" {
+		agentID, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid agent_id")
+		}
+		filter.AgentID = agentID
+	}
+
+	page, err := parsePageRequest(c, 20, 100)
+	if err != nil {
+		return err
+	}
+
+	documents, total, err := h.documentService.ListDocuments(c.Context(), filter, page)
+	if err != nil {
+		return internalError("failed to list documents", err)
+	}
+
+	return c.JSON(newPage(documents, total, page.Limit, func(d *domain.Document) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: d.CreatedAt, ID: d.ID}
+	}))
+}
+
+// GetDocument returns a document with the content of its latest version,
+// or of an earlier one with ?version=
+// GET /documents/:id
+func (h *DocumentHandler) GetDocument(c *fiber.Ctx) error {
+	document, err := h.document(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(document)
+}
+
+// GetDocumentVersions returns a document's version history, newest first
+// GET /documents/:id/versions
+func (h *DocumentHandler) GetDocumentVersions(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	documentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid document id")
+	}
+
+	versions, err := h.documentService.GetDocumentVersions(c.Context(), officeID, documentID)
+	if err != nil {
+		return documentError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"versions": versions,
+	})
+}
+
+// DownloadDocument downloads a document's latest version, or an earlier
+// one with ?version=, as a file of its format
+// GET /documents/:id/download
+func (h *DocumentHandler) DownloadDocument(c *fiber.Ctx) error {
+	document, err := h.document(c)
+	if err != nil {
+		return err
+	}
+	format := documentFormats[document.Format]
+
+	// Always download rather than render, so agent-written HTML cannot run
+	// in the API's origin
+	c.Attachment(documentFilename(document.Title) + "." + format.extension)
+	c.Set(fiber.HeaderContentType, format.contentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendString(document.Content)
+}
+
+// document loads the document of the request at the requested version
+func (h *DocumentHandler) document(c *fiber.Ctx) (*domain.Document, error) {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	documentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, badRequest("invalid document id")
+	}
+
+	if raw := c.Query("version"); raw != "" {
+		version, err := strconv.Atoi(raw)
+		if err != nil || version < 1 {
+			return nil, badRequest("invalid version")
+		}
+		document, err := h.documentService.GetDocumentVersion(c.Context(), officeID, documentID, version)
+		if err != nil {
+			return nil, documentError(err)
+		}
+		return document, nil
+	}
+
+	document, err := h.documentService.GetDocument(c.Context(), officeID, documentID)
+	if err != nil {
+		return nil, documentError(err)
+	}
+	return document, nil
+}
+
+// documentFilename turns a document title into a file name of letters,
+// digits and dashes
+func documentFilename(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 80 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "document"
+	}
+	return b.String()
+}
+
+// documentError maps document service errors to API errors
+func documentError(err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("document not found")
+	}
+	return internalError("failed to get document", err)
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"errors"
 	"strconv"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -46,8 +48,8 @@ func (h *EarningsHandler) getOfficeID(c *fiber.Ctx) (uuid.UUID, error) {
 
 // PurchaseRequest represents a template purchase request
 type PurchaseTemplateRequest struct {
-	TemplateID            string `json:"template_id"`
-	StripePaymentIntentID string `json:"stripe_payment_intent_id"`
+	TemplateID            string `json:"template_id" validate:"required,uuid"`
+	StripePaymentIntentID string `json:"stripe_payment_intent_id" validate:"required,max=100"`
 }
 
 // PurchaseTemplate handles template purchase
@@ -55,31 +57,19 @@ type PurchaseTemplateRequest struct {
 func (h *EarningsHandler) PurchaseTemplate(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	var req PurchaseTemplateRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	templateID, err := uuid.Parse(req.TemplateID)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid template_id",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	templateID := uuid.MustParse(req.TemplateID)
 
 	earningID, err := h.earningsService.PurchaseTemplate(
 		c.Context(),
@@ -87,11 +77,13 @@ func (h *EarningsHandler) PurchaseTemplate(c *fiber.Ctx) error {
 		userID,
 		officeID,
 		req.StripePaymentIntentID,
+		c.Get("Idempotency-Key"),
 	)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return conflict("your office already owns this template")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -100,58 +92,225 @@ func (h *EarningsHandler) PurchaseTemplate(c *fiber.Ctx) error {
 	})
 }
 
-// GetAuthorEarnings retrieves earnings for the current user (author)
-// GET /api/v1/author/earnings?limit=50&offset=0
-func (h *EarningsHandler) GetAuthorEarnings(c *fiber.Ctx) error {
+// PurchaseWithCreditsRequest represents a template purchase paid with credits
+type PurchaseWithCreditsRequest struct {
+	TemplateID string `json:"template_id" validate:"required,uuid"`
+}
+
+// PurchaseTemplateWithCredits buys a template with the office's credits
+// POST /api/v1/marketplace/purchase/credits
+func (h *EarningsHandler) PurchaseTemplateWithCredits(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
-	limit := 50
-	offset := 0
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
 	}
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+
+	var req PurchaseWithCreditsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	templateID := uuid.MustParse(req.TemplateID)
+
+	purchase, err := h.earningsService.PurchaseTemplateWithCredits(
+		c.Context(),
+		templateID,
+		userID,
+		officeID,
+		c.Get("Idempotency-Key"),
+	)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return conflict("your office already owns this template")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(purchase)
+}
+
+// PurchaseBundleRequest represents a bundle purchase request
+type PurchaseBundleRequest struct {
+	BundleID              string `json:"bundle_id" validate:"required,uuid"`
+	StripePaymentIntentID string `json:"stripe_payment_intent_id" validate:"required,max=100"`
+}
+
+// PurchaseBundle buys every template of a bundle
+// POST /api/v1/marketplace/purchase/bundle
+func (h *EarningsHandler) PurchaseBundle(c *fiber.Ctx) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return unauthorized("user_id not found in context")
+	}
+
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	var req PurchaseBundleRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	bundleID := uuid.MustParse(req.BundleID)
 
-	earnings, err := h.earningsService.GetAuthorEarnings(c.Context(), userID, limit, offset)
+	purchases, err := h.earningsService.PurchaseBundle(
+		c.Context(),
+		bundleID,
+		userID,
+		officeID,
+		req.StripePaymentIntentID,
+		c.Get("Idempotency-Key"),
+	)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("bundle not found")
+	}
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return conflict("your office already owns a template in this bundle")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
-		"earnings": earnings,
-		"limit":    limit,
-		"offset":   offset,
+		"success":   true,
+		"bundle_id": bundleID,
+		"purchases": purchases,
 	})
 }
 
+// GetCreditPrice returns what a template costs in credits
+// GET /api/v1/marketplace/agents/:id/credit-price
+func (h *EarningsHandler) GetCreditPrice(c *fiber.Ctx) error {
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid template id")
+	}
+
+	price, err := h.earningsService.GetCreditPrice(c.Context(), templateID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("template not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(price)
+}
+
+// GetPurchases lists the premium templates owned by the current office
+// GET /api/v1/marketplace/purchases
+func (h *EarningsHandler) GetPurchases(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	purchases, err := h.earningsService.GetOfficePurchases(c.Context(), officeID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"purchases": purchases,
+		"count":     len(purchases),
+	})
+}
+
+// RefundRequestBody represents a purchase refund request body
+type RefundRequestBody struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// RequestRefund asks for a purchase of the current office to be refunded
+// POST /api/v1/marketplace/purchases/:id/refund-request
+func (h *EarningsHandler) RequestRefund(c *fiber.Ctx) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return unauthorized("user_id not found in context")
+	}
+
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	purchaseID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid purchase id")
+	}
+
+	var req RefundRequestBody
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	refund, err := h.earningsService.RequestRefund(c.Context(), officeID, userID, purchaseID, req.Reason)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("purchase not found")
+	}
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return conflict("a refund of this purchase was already requested")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(refund)
+}
+
+// GetRefundRequests lists the current office's refund requests
+// GET /api/v1/marketplace/refund-requests
+func (h *EarningsHandler) GetRefundRequests(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	refunds, err := h.earningsService.GetOfficeRefundRequests(c.Context(), officeID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"refund_requests": refunds,
+		"count":           len(refunds),
+	})
+}
+
+// GetAuthorEarnings retrieves earnings for the current user (author)
+// GET /api/v1/author/earnings?limit=50&offset=0
+func (h *EarningsHandler) GetAuthorEarnings(c *fiber.Ctx) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return unauthorized("user_id not found in context")
+	}
+
+	page, _ := parsePageRequest(c, 50, 100)
+
+	earnings, total, err := h.earningsService.GetAuthorEarnings(c.Context(), userID, page.Limit, page.Offset)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(newPage(earnings, total, page.Limit, nil))
+}
+
 // GetAuthorBalance retrieves balance for the current user
 // GET /api/v1/author/balance
 func (h *EarningsHandler) GetAuthorBalance(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
 	balance, err := h.earningsService.GetAuthorBalance(c.Context(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(balance)
@@ -162,16 +321,12 @@ func (h *EarningsHandler) GetAuthorBalance(c *fiber.Ctx) error {
 func (h *EarningsHandler) GetEarningsSummary(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
 	summary, err := h.earningsService.GetEarningsSummary(c.Context(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(summary)
@@ -179,7 +334,7 @@ func (h *EarningsHandler) GetEarningsSummary(c *fiber.Ctx) error {
 
 // PayoutRequest represents a payout request body
 type PayoutRequestBody struct {
-	AmountCents int `json:"amount_cents"`
+	AmountCents int `json:"amount_cents" validate:"required,gt=0"`
 }
 
 // RequestPayout creates a payout request
@@ -187,23 +342,17 @@ type PayoutRequestBody struct {
 func (h *EarningsHandler) RequestPayout(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
 	var req PayoutRequestBody
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	payoutID, err := h.earningsService.RequestPayout(c.Context(), userID, req.AmountCents)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -217,9 +366,7 @@ func (h *EarningsHandler) RequestPayout(c *fiber.Ctx) error {
 func (h *EarningsHandler) GetPayoutRequests(c *fiber.Ctx) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "user_id not found in context",
-		})
+		return unauthorized("user_id not found in context")
 	}
 
 	limit := 50
@@ -237,9 +384,7 @@ func (h *EarningsHandler) GetPayoutRequests(c *fiber.Ctx) error {
 
 	payouts, err := h.earningsService.GetPayoutRequests(c.Context(), userID, limit, offset)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
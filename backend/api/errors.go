@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// codeInternal is reported for errors outside the domain catalogue, whose
+// messages may contain internals and are never sent to clients
+const codeInternal = "internal_error"
+
+// errorStatus maps domain error codes to HTTP statuses
+var errorStatus = map[string]int{
+	domain.ErrNotFound.Code:             fiber.StatusNotFound,
+	domain.ErrAlreadyExists.Code:        fiber.StatusConflict,
+	domain.ErrInvalidInput.Code:         fiber.StatusBadRequest,
+	domain.ErrUnauthorized.Code:         fiber.StatusUnauthorized,
+	domain.ErrForbidden.Code:            fiber.StatusForbidden,
+	domain.ErrInvalidCredentials.Code:   fiber.StatusUnauthorized,
+	domain.ErrPurchaseRequired.Code:     fiber.StatusPaymentRequired,
+	domain.ErrTemplateNotOwned.Code:     fiber.StatusForbidden,
+	domain.ErrInsufficientCredits.Code:  fiber.StatusPaymentRequired,
+	domain.ErrTierLimitExceeded.Code:    fiber.StatusForbidden,
+	domain.ErrFeatureNotAvailable.Code:  fiber.StatusForbidden,
+	domain.ErrUpgradeRequired.Code:      fiber.StatusPaymentRequired,
+	domain.ErrSubscriptionInactive.Code: fiber.StatusPaymentRequired,
+	domain.ErrPaymentDeclined.Code:      fiber.StatusPaymentRequired,
+	domain.ErrLicenseRequired.Code:      fiber.StatusForbidden,
+	domain.ErrContentBlocked.Code:       fiber.StatusUnprocessableEntity,
+	domain.ErrSecretsUnavailable.Code:   fiber.StatusServiceUnavailable,
+	domain.ErrRateLimited.Code:          fiber.StatusTooManyRequests,
+	domain.ErrIdempotencyKeyReused.Code: fiber.StatusUnprocessableEntity,
+	domain.ErrRequestInProgress.Code:    fiber.StatusConflict,
+}
+
+// ErrorResponse is the body of every API error response
+type ErrorResponse struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Error is an error response built by a handler, for when the domain error's
+// own message is not what the client should see
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]any
+
+	// cause is logged for server errors but not sent to the client
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+// errorFor reports a domain error with a handler-specific message
+func errorFor(err *domain.Error, message string) error {
+	return &Error{Status: errorStatus[err.Code], Code: err.Code, Message: message}
+}
+
+func badRequest(message string) error   { return errorFor(domain.ErrInvalidInput, message) }
+func unauthorized(message string) error { return errorFor(domain.ErrUnauthorized, message) }
+func forbidden(message string) error    { return errorFor(domain.ErrForbidden, message) }
+func notFound(message string) error     { return errorFor(domain.ErrNotFound, message) }
+func conflict(message string) error     { return errorFor(domain.ErrAlreadyExists, message) }
+
+// internalError reports an unexpected failure with a handler-specific
+// message. The cause is logged but not sent; if it is a domain error, the
+// domain error is reported instead.
+func internalError(message string, cause error) error {
+	return &Error{Status: fiber.StatusInternalServerError, Code: codeInternal, Message: message, cause: cause}
+}
+
+// ErrorHandler renders every error returned by a handler or middleware as an
+// ErrorResponse. Domain errors are mapped through errorStatus; anything else
+// is logged and reported as an internal error so database and other internal
+// messages never reach clients.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status, body := errorResponse(err)
+	if status >= fiber.StatusInternalServerError {
+		log.Printf("%s %s failed: %v", c.Method(), c.Path(), err)
+	}
+	return c.Status(status).JSON(body)
+}
+
+// errorResponse resolves the status and body reported for err
+func errorResponse(err error) (int, ErrorResponse) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code != codeInternal || !isDomainError(apiErr.cause) {
+			details := apiErr.Details
+			if details == nil {
+				details = domain.ErrorDetails(err)
+			}
+			return apiErr.Status, ErrorResponse{Code: apiErr.Code, Message: apiErr.Message, Details: details}
+		}
+		// The failure was an expected domain error after all
+		err = apiErr.cause
+	}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		if status, ok := errorStatus[domainErr.Code]; ok {
+			return status, ErrorResponse{Code: domainErr.Code, Message: err.Error(), Details: domain.ErrorDetails(err)}
+		}
+	}
+
+	// Routing and body-limit errors raised by fiber itself
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code < fiber.StatusInternalServerError {
+		code := strings.ReplaceAll(strings.ToLower(utils.StatusMessage(fiberErr.Code)), " ", "_")
+		return fiberErr.Code, ErrorResponse{Code: code, Message: fiberErr.Message}
+	}
+
+	return fiber.StatusInternalServerError, ErrorResponse{Code: codeInternal, Message: "internal server error"}
+}
+
+// isDomainError reports whether err is a catalogued domain error
+func isDomainError(err error) bool {
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		return false
+	}
+	_, ok := errorStatus[domainErr.Code]
+	return ok
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ExportHandler handles CSV and Excel export endpoints
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// exportContentTypes maps each export format to its content type
+var exportContentTypes = map[service.ExportFormat]string{
+	service.ExportFormatCSV:  "text/csv; charset=utf-8",
+	service.ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportUsage downloads the office's usage per day
+// GET /usage/export?format=csv|xlsx&days=90
+func (h *ExportHandler) ExportUsage(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	format, days, err := parseExportQuery(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.exportService.ExportUsage(c.Context(), officeID, days)
+	if err != nil {
+		return internalError("failed to export usage", err)
+	}
+	return streamExport(c, export, format)
+}
+
+// ExportTransactions downloads the office's credit transactions
+// GET /credits/transactions/export?format=csv|xlsx&days=90
+func (h *ExportHandler) ExportTransactions(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	format, days, err := parseExportQuery(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.exportService.ExportTransactions(c.Context(), officeID, days)
+	if err != nil {
+		return internalError("failed to export transactions", err)
+	}
+	return streamExport(c, export, format)
+}
+
+// ExportEarnings downloads the current user's template sales
+// GET /author/earnings/export?format=csv|xlsx&days=90
+func (h *ExportHandler) ExportEarnings(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+	userID := c.Locals("user_id").(uuid.UUID)
+	format, days, err := parseExportQuery(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.exportService.ExportEarnings(c.Context(), officeID, userID, days)
+	if err != nil {
+		return internalError("failed to export earnings", err)
+	}
+	return streamExport(c, export, format)
+}
+
+// parseExportQuery reads the format and days of an export request; days
+// defaults to 30
+func parseExportQuery(c *fiber.Ctx) (service.ExportFormat, int, error) {
+	format := service.ExportFormat(c.Query("format", string(service.ExportFormatCSV)))
+	if _, ok := exportContentTypes[format]; !ok {
+		return "", 0, badRequest("format must be csv or xlsx")
+	}
+	days := 30
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 1 {
+			return "", 0, badRequest("days must be a positive integer")
+		}
+		days = parsed
+	}
+	return format, days, nil
+}
+
+// streamExport sends an export as a download, writing its rows as they are
+// read. Errors once the download has started can only be logged; the file
+// is cut short.
+func streamExport(c *fiber.Ctx, export *service.Export, format service.ExportFormat) error {
+	c.Attachment(fmt.Sprintf("%s-%s.%s", export.Name, time.Now().UTC().Format("2006-01-02"), format))
+	c.Set(fiber.HeaderContentType, exportContentTypes[format])
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request is recycled once written, so its context is not
+		// used here
+		err := export.Write(context.Background(), w, format)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Printf("Failed to stream %s export: %v", export.Name, err)
+		}
+	})
+	return nil
+}
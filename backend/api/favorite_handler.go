@@ -0,0 +1,85 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// FavoriteHandler handles favorite template endpoints
+type FavoriteHandler struct {
+	favoriteService *service.FavoriteService
+}
+
+// NewFavoriteHandler creates a new FavoriteHandler
+func NewFavoriteHandler(favoriteService *service.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{favoriteService: favoriteService}
+}
+
+// FavoriteRequest represents a request to favorite a template
+type FavoriteRequest struct {
+	// Notify asks to be told when the template, if premium, goes on sale or
+	// publishes a new version
+	Notify bool `json:"notify"`
+}
+
+// AddFavorite saves a template to the current user's favorites, or changes
+// whether the favorite notifies
+// POST /marketplace/agents/:id/favorite
+func (h *FavoriteHandler) AddFavorite(c *fiber.Ctx) error {
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid template id")
+	}
+	var req FavoriteRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	favorite, err := h.favoriteService.AddFavorite(c.Context(), c.Locals("user_id").(uuid.UUID), templateID, req.Notify)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(favorite)
+}
+
+// RemoveFavorite removes a template from the current user's favorites
+// DELETE /marketplace/agents/:id/favorite
+func (h *FavoriteHandler) RemoveFavorite(c *fiber.Ctx) error {
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid template id")
+	}
+
+	if err := h.favoriteService.RemoveFavorite(c.Context(), c.Locals("user_id").(uuid.UUID), templateID); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListFavorites returns the current user's favorite templates, newest first
+// GET /marketplace/favorites
+func (h *FavoriteHandler) ListFavorites(c *fiber.Ctx) error {
+	limit, offset := 20, 0
+	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset", "0")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	favorites, total, err := h.favoriteService.ListFavorites(c.Context(), c.Locals("user_id").(uuid.UUID), limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"favorites": favorites,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
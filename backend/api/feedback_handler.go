@@ -26,50 +26,48 @@ type CreateMessageFeedbackRequest struct {
 	FeedbackType      string `json:"feedback_type" validate:"required,oneof=positive negative correction"`
 	Rating            int    `json:"rating,omitempty" validate:"omitempty,min=1,max=5"`
 	Comment           string `json:"comment,omitempty"`
-	CorrectionContent string `json:"correction_content,omitempty"`
+	CorrectionContent string `json:"correction_content,omitempty" validate:"required_if=FeedbackType correction"`
 }
 
 // CreateMessageFeedback handles POST /api/v1/messages/:id/feedback
 func (h *FeedbackHandler) CreateMessageFeedback(c *fiber.Ctx) error {
-	// Get user_id from context (set by AuthMiddleware)
-	userID, ok := c.Locals("user_id").(uuid.UUID)
+	// Get office_id from context (set by AuthMiddleware)
+	officeID, ok := c.Locals("office_id").(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid user ID in context",
-		})
+		return unauthorized("Invalid office ID in context")
 	}
 
 	// Parse message ID
 	messageIDStr := c.Params("id")
 	messageID, err := uuid.Parse(messageIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid message ID",
-		})
+		return badRequest("Invalid message ID")
 	}
 
 	// Parse request body
 	var req CreateMessageFeedbackRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	// Create feedback
 	feedback, err := h.feedbackService.CreateMessageFeedback(
 		c.Context(),
-		userID,
+		officeID,
 		messageID,
 		domain.FeedbackType(req.FeedbackType),
 		req.Rating,
 		req.Comment,
 		req.CorrectionContent,
 	)
+	if err == domain.ErrInvalidInput {
+		return badRequest("Feedback must target an agent message, and corrections require correction_content")
+	}
+	if err == domain.ErrNotFound {
+		return notFound("Message not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(feedback)
@@ -77,29 +75,26 @@ func (h *FeedbackHandler) CreateMessageFeedback(c *fiber.Ctx) error {
 
 // GetAgentFeedbackSummary handles GET /api/v1/agents/:id/feedback-summary
 func (h *FeedbackHandler) GetAgentFeedbackSummary(c *fiber.Ctx) error {
-	// Get user_id from context (set by AuthMiddleware)
-	userID, ok := c.Locals("user_id").(uuid.UUID)
+	// Get office_id from context (set by AuthMiddleware)
+	officeID, ok := c.Locals("office_id").(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid user ID in context",
-		})
+		return unauthorized("Invalid office ID in context")
 	}
 
 	// Parse agent ID
 	agentIDStr := c.Params("id")
 	agentID, err := uuid.Parse(agentIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid agent ID",
-		})
+		return badRequest("Invalid agent ID")
 	}
 
 	// Get feedback summary
-	summary, err := h.feedbackService.GetAgentFeedbackSummary(c.Context(), userID, agentID)
+	summary, err := h.feedbackService.GetAgentFeedbackSummary(c.Context(), officeID, agentID)
+	if err == domain.ErrNotFound {
+		return notFound("Agent not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(summary)
@@ -107,21 +102,17 @@ func (h *FeedbackHandler) GetAgentFeedbackSummary(c *fiber.Ctx) error {
 
 // GetAgentMemories handles GET /api/v1/agents/:id/memories
 func (h *FeedbackHandler) GetAgentMemories(c *fiber.Ctx) error {
-	// Get user_id from context (set by AuthMiddleware)
-	userID, ok := c.Locals("user_id").(uuid.UUID)
+	// Get office_id from context (set by AuthMiddleware)
+	officeID, ok := c.Locals("office_id").(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid user ID in context",
-		})
+		return unauthorized("Invalid office ID in context")
 	}
 
 	// Parse agent ID
 	agentIDStr := c.Params("id")
 	agentID, err := uuid.Parse(agentIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid agent ID",
-		})
+		return badRequest("Invalid agent ID")
 	}
 
 	// Get query params for filtering
@@ -133,11 +124,12 @@ func (h *FeedbackHandler) GetAgentMemories(c *fiber.Ctx) error {
 	}
 
 	// Get memories
-	memories, err := h.feedbackService.GetAgentMemories(c.Context(), userID, agentID, memoryType, limit)
+	memories, err := h.feedbackService.GetAgentMemories(c.Context(), officeID, agentID, memoryType, limit)
+	if err == domain.ErrNotFound {
+		return notFound("Agent not found")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -1,8 +1,13 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/repository"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
@@ -11,81 +16,167 @@ import (
 
 // InternalHandler handles internal service-to-service endpoints
 type InternalHandler struct {
-	wsHandler        *WSHandler
-	conversationRepo *repository.ConversationRepository
-	creditService    *service.CreditService
+	events               domain.EventPublisher
+	conversationRepo     *repository.ConversationRepository
+	creditService        *service.CreditService
+	learningStatsService *service.LearningStatsService
+	chatService          *service.ChatService
+	documentService      *service.DocumentService
+	notificationService  *service.NotificationService
+	taskService          *service.TaskService
+	summaryService       *service.SummaryService
+	webhooks             *service.WebhookDispatcher
+
+	// streamOffices caches the office of each streaming task so chunks don't
+	// each need a conversation lookup
+	streamOffices sync.Map // task ID -> office ID
 }
 
 // NewInternalHandler creates a new InternalHandler
 func NewInternalHandler(
-	wsHandler *WSHandler,
+	events domain.EventPublisher,
 	conversationRepo *repository.ConversationRepository,
 	creditService *service.CreditService,
+	learningStatsService *service.LearningStatsService,
+	chatService *service.ChatService,
+	documentService *service.DocumentService,
+	notificationService *service.NotificationService,
+	taskService *service.TaskService,
+	summaryService *service.SummaryService,
+	webhooks *service.WebhookDispatcher,
 ) *InternalHandler {
 	return &InternalHandler{
-		wsHandler:        wsHandler,
-		conversationRepo: conversationRepo,
-		creditService:    creditService,
+		events:               events,
+		conversationRepo:     conversationRepo,
+		creditService:        creditService,
+		learningStatsService: learningStatsService,
+		chatService:          chatService,
+		documentService:      documentService,
+		notificationService:  notificationService,
+		taskService:          taskService,
+		summaryService:       summaryService,
+		webhooks:             webhooks,
 	}
 }
 
 // TaskCompleteRequest represents a task completion notification from the orchestrator
 type TaskCompleteRequest struct {
-	TaskID         string `json:"task_id"`
-	ConversationID string `json:"conversation_id"`
-	AgentID        string `json:"agent_id"`
-	Output         string `json:"output"`
+	TaskID         string `json:"task_id" validate:"required"`
+	ConversationID string `json:"conversation_id" validate:"required,uuid"`
+	AgentID        string `json:"agent_id" validate:"required,uuid"`
+	// MessageID is the agent's saved reply
+	MessageID string `json:"message_id,omitempty" validate:"omitempty,uuid"`
+	Output    string `json:"output"`
+	// Attempt is the dispatch attempt that ran the task
+	Attempt int `json:"attempt,omitempty" validate:"gte=0"`
+	// Usage is what running the task cost; the office is charged its
+	// credits
+	Usage *TaskUsageRequest `json:"usage,omitempty"`
+}
+
+// TaskUsageRequest reports the tokens, model, latency and credits of a task
+type TaskUsageRequest struct {
+	Model        string  `json:"model" validate:"required,max=100"`
+	Provider     string  `json:"provider" validate:"max=50"`
+	AgentRole    string  `json:"agent_role"`
+	InputTokens  int     `json:"input_tokens" validate:"gte=0"`
+	OutputTokens int     `json:"output_tokens" validate:"gte=0"`
+	LatencyMs    int     `json:"latency_ms" validate:"gte=0"`
+	Credits      int64   `json:"credits" validate:"gte=0"`
+	IsLocalModel bool    `json:"is_local_model"`
+	USDCost      float64 `json:"usd_cost" validate:"gte=0"`
 }
 
 // TaskComplete handles task completion notifications from the agent orchestrator
 // POST /internal/task-complete
 func (h *InternalHandler) TaskComplete(c *fiber.Ctx) error {
 	var req TaskCompleteRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	conversationID := uuid.MustParse(req.ConversationID)
+	agentID := uuid.MustParse(req.AgentID)
 
-	// Parse UUIDs
-	conversationID, err := uuid.Parse(req.ConversationID)
+	log.Printf("Task completed: %s for conversation %s by agent %s", req.TaskID, conversationID, agentID)
+
+	// Get the conversation to find the office_id
+	conversation, err := h.conversationRepo.GetByID(c.Context(), conversationID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid conversation_id",
-		})
+		log.Printf("Failed to get conversation: %v", err)
+		return internalError("failed to get conversation", err)
+	}
+
+	taskID, taskIDErr := uuid.Parse(req.TaskID)
+	if taskIDErr == nil {
+		// A task cancelled while it ran was refunded; what the orchestrator
+		// finished anyway is neither charged for nor delivered
+		if task, err := h.taskService.GetTask(c.Context(), taskID); err == nil && task.Status == domain.TaskStatusCancelled {
+			h.streamOffices.Delete(taskID)
+			log.Printf("Discarded completion of cancelled task %s", taskID)
+			return c.JSON(fiber.Map{
+				"status":  "ok",
+				"message": "task was cancelled; completion discarded",
+			})
+		}
+
+		// The output of a summary task is the conversation's summary, which
+		// is posted and charged for but not delivered as the agent's reply
+		handled, err := h.summaryService.CompleteSummary(c.Context(), taskID, req.Output)
+		if err != nil {
+			log.Printf("Failed to complete summary task %s: %v", taskID, err)
+		}
+		if handled {
+			if req.Usage != nil {
+				h.recordUsage(c.Context(), taskID, req)
+			}
+			return c.JSON(fiber.Map{
+				"status":  "ok",
+				"message": "conversation summary received",
+			})
+		}
 	}
 
-	agentID, err := uuid.Parse(req.AgentID)
+	// Broadcast the new message to WebSocket clients. Clients that rendered a
+	// streamed draft replace it using task_id.
+	payload := map[string]any{
+		"task_id":         req.TaskID,
+		"conversation_id": req.ConversationID,
+		"sender_type":     "agent",
+		"sender_id":       req.AgentID,
+		"content":         req.Output,
+	}
+	var messageID *uuid.UUID
+	if req.MessageID != "" {
+		id := uuid.MustParse(req.MessageID)
+		messageID = &id
+		payload["message_id"] = req.MessageID
+	}
+	err = h.events.Publish(c.Context(), domain.NewEvent(conversation.OfficeID, domain.EventNewMessage, payload))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid agent_id",
-		})
+		log.Printf("Failed to publish message for office %s: %v", conversation.OfficeID, err)
+	} else {
+		log.Printf("Broadcasted message to office %s", conversation.OfficeID)
+	}
+	if messageID != nil {
+		h.webhooks.Dispatch(c.Context(), conversation.OfficeID, domain.WebhookEventMessageCreated, payload)
 	}
 
-	log.Printf("Task completed: %s for conversation %s by agent %s", req.TaskID, conversationID, agentID)
+	if taskIDErr == nil && req.Usage != nil {
+		h.recordUsage(c.Context(), taskID, req)
+	}
 
-	// Get the conversation to find the office_id
-	conversation, err := h.conversationRepo.GetByID(c.Context(), conversationID)
-	if err != nil {
-		log.Printf("Failed to get conversation: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get conversation",
-		})
-	}
-
-	// Broadcast the new message to WebSocket clients
-	h.wsHandler.BroadcastToOffice(conversation.OfficeID, WSMessage{
-		EventID:   uuid.New().String(),
-		EventType: "new_message",
-		Payload: map[string]any{
-			"conversation_id": req.ConversationID,
-			"sender_type":     "agent",
-			"sender_id":       req.AgentID,
-			"content":         req.Output,
-		},
-	})
+	if taskIDErr == nil {
+		h.streamOffices.Delete(taskID)
 
-	log.Printf("Broadcasted message to office %s", conversation.OfficeID)
+		// Agents mentioned in the reply are delegated sub-tasks; moderated
+		// and debating agents hand over to the next ones
+		go h.chatService.HandleAgentReply(context.Background(), taskID, messageID)
+	}
+
+	// Completed tasks count towards the agent's total interactions
+	h.learningStatsService.MarkDirty(agentID)
+
+	h.notifyTaskCompleted(c.Context(), conversation, agentID, req)
 
 	return c.JSON(fiber.Map{
 		"status":  "ok",
@@ -93,39 +184,206 @@ func (h *InternalHandler) TaskComplete(c *fiber.Ctx) error {
 	})
 }
 
+// recordUsage charges the office for a completed task and records its
+// usage, logging failures; the reply is delivered either way
+func (h *InternalHandler) recordUsage(ctx context.Context, taskID uuid.UUID, req TaskCompleteRequest) {
+	tx, err := h.taskService.RecordUsage(ctx, service.RecordUsageInput{
+		TaskID:    taskID,
+		Attempt:   req.Attempt,
+		AgentRole: req.Usage.AgentRole,
+		Usage: domain.TaskUsage{
+			Model:        req.Usage.Model,
+			Provider:     req.Usage.Provider,
+			InputTokens:  req.Usage.InputTokens,
+			OutputTokens: req.Usage.OutputTokens,
+			LatencyMs:    req.Usage.LatencyMs,
+			Credits:      req.Usage.Credits,
+			IsLocalModel: req.Usage.IsLocalModel,
+			USDCost:      req.Usage.USDCost,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to record usage of task %s: %v", taskID, err)
+		return
+	}
+	if tx != nil {
+		log.Printf("Consumed %d credits for task %s (balance: %d)", req.Usage.Credits, taskID, tx.BalanceAfter)
+	}
+}
+
+// notifyTaskCompleted sends the office a task_completed notification
+// previewing the agent's reply, logging failures
+func (h *InternalHandler) notifyTaskCompleted(ctx context.Context, conversation *domain.Conversation, agentID uuid.UUID, req TaskCompleteRequest) {
+	title := "An agent finished a task"
+	participants, err := h.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		log.Printf("Failed to get participants of conversation %s: %v", conversation.ID, err)
+	}
+	for _, agent := range participants {
+		if agent.ID == agentID {
+			title = agent.GetName() + " finished a task"
+		}
+	}
+
+	preview := []rune(req.Output)
+	if len(preview) > 200 {
+		preview = append(preview[:200], '…')
+	}
+	payload := map[string]any{
+		"task_id":         req.TaskID,
+		"conversation_id": req.ConversationID,
+		"agent_id":        req.AgentID,
+	}
+	if req.MessageID != "" {
+		payload["message_id"] = req.MessageID
+	}
+
+	_, err = h.notificationService.Notify(ctx, conversation.OfficeID, domain.NotificationTypeTaskCompleted, title, string(preview), payload)
+	if err != nil {
+		log.Printf("Failed to notify office %s of task %s: %v", conversation.OfficeID, req.TaskID, err)
+	}
+}
+
+// TaskStreamChunkRequest represents a partial agent response from the orchestrator
+type TaskStreamChunkRequest struct {
+	TaskID         string `json:"task_id" validate:"required,uuid"`
+	ConversationID string `json:"conversation_id" validate:"required,uuid"`
+	AgentID        string `json:"agent_id" validate:"required,uuid"`
+	Sequence       int    `json:"sequence" validate:"gte=0"`
+	Delta          string `json:"delta"`
+	// Done marks the final chunk; Content optionally carries the full response
+	Done    bool   `json:"done"`
+	Content string `json:"content,omitempty"`
+}
+
+// TaskStreamChunk relays streamed output to WebSocket clients as message_delta
+// events, followed by message_complete on the final chunk. The persisted
+// message is still announced by TaskComplete.
+// POST /internal/task-stream-chunk
+func (h *InternalHandler) TaskStreamChunk(c *fiber.Ctx) error {
+	var req TaskStreamChunkRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	taskID := uuid.MustParse(req.TaskID)
+	conversationID := uuid.MustParse(req.ConversationID)
+	agentID := uuid.MustParse(req.AgentID)
+
+	officeID, err := h.streamOffice(c.Context(), taskID, conversationID)
+	if err != nil {
+		log.Printf("Failed to get conversation: %v", err)
+		return internalError("failed to get conversation", err)
+	}
+
+	if req.Delta != "" {
+		h.publish(c.Context(), domain.NewEvent(officeID, domain.EventMessageDelta, map[string]any{
+			"task_id":         taskID.String(),
+			"conversation_id": conversationID.String(),
+			"agent_id":        agentID.String(),
+			"sequence":        req.Sequence,
+			"delta":           req.Delta,
+		}))
+	}
+	if req.Done {
+		h.publish(c.Context(), domain.NewEvent(officeID, domain.EventMessageComplete, map[string]any{
+			"task_id":         taskID.String(),
+			"conversation_id": conversationID.String(),
+			"agent_id":        agentID.String(),
+			"content":         req.Content,
+		}))
+		h.streamOffices.Delete(taskID)
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// publish emits an event, logging failures; streamed output is best effort
+func (h *InternalHandler) publish(ctx context.Context, event domain.Event) {
+	if err := h.events.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.Type, err)
+	}
+}
+
+// streamOffice resolves the office a streaming task belongs to
+func (h *InternalHandler) streamOffice(ctx context.Context, taskID, conversationID uuid.UUID) (uuid.UUID, error) {
+	if officeID, ok := h.streamOffices.Load(taskID); ok {
+		return officeID.(uuid.UUID), nil
+	}
+
+	conversation, err := h.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	h.streamOffices.Store(taskID, conversation.OfficeID)
+	return conversation.OfficeID, nil
+}
+
+// SaveDocumentRequest represents a deliverable an agent saved while running
+// a task. Without a document_id a new document is created; with one, a new
+// version of it.
+type SaveDocumentRequest struct {
+	TaskID     string `json:"task_id" validate:"required,uuid"`
+	DocumentID string `json:"document_id,omitempty" validate:"omitempty,uuid"`
+	Title      string `json:"title" validate:"required,max=255"`
+	Format     string `json:"format,omitempty" validate:"omitempty,oneof=markdown text html json csv"`
+	Content    string `json:"content" validate:"required"`
+}
+
+// SaveDocument stores a deliverable an agent produced in the office and
+// conversation of its task
+// POST /internal/documents
+func (h *InternalHandler) SaveDocument(c *fiber.Ctx) error {
+	var req SaveDocumentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	input := service.SaveDocumentInput{
+		TaskID:  uuid.MustParse(req.TaskID),
+		Title:   req.Title,
+		Format:  domain.DocumentFormat(req.Format),
+		Content: req.Content,
+	}
+	if req.DocumentID != "" {
+		documentID := uuid.MustParse(req.DocumentID)
+		input.DocumentID = &documentID
+	}
+
+	document, err := h.documentService.SaveDocument(c.Context(), input)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("task or document not found")
+	}
+	if err != nil {
+		return internalError("failed to save document", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(document)
+}
+
 // =============================================================================
 // Internal Credit Endpoints (for orchestrator service-to-service calls)
 // =============================================================================
 
 // CreditCheckRequest represents a credit balance check request
 type CreditCheckRequest struct {
-	OfficeID        string `json:"office_id"`
-	RequiredCredits int64  `json:"required_credits"`
+	OfficeID        string `json:"office_id" validate:"required,uuid"`
+	RequiredCredits int64  `json:"required_credits" validate:"gte=0"`
 }
 
 // CheckCredits checks if an office has sufficient credits
 // POST /internal/credits/check
 func (h *InternalHandler) CheckCredits(c *fiber.Ctx) error {
 	var req CreditCheckRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-
-	officeID, err := uuid.Parse(req.OfficeID)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	officeID := uuid.MustParse(req.OfficeID)
 
 	hasSufficient, currentBalance, err := h.creditService.CheckSufficientCredits(c.Context(), officeID, req.RequiredCredits)
 	if err != nil {
 		log.Printf("Credit check failed: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to check credits",
-		})
+		return internalError("failed to check credits", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -137,42 +395,44 @@ func (h *InternalHandler) CheckCredits(c *fiber.Ctx) error {
 
 // CreditConsumeRequest represents a credit consumption request
 type CreditConsumeRequest struct {
-	OfficeID    string `json:"office_id"`
-	TaskID      string `json:"task_id"`
-	Credits     int64  `json:"credits"`
+	OfficeID    string `json:"office_id" validate:"required,uuid"`
+	TaskID      string `json:"task_id" validate:"required,uuid"`
+	Credits     int64  `json:"credits" validate:"gt=0"`
 	Description string `json:"description"`
+	// Attempt is the task's dispatch attempt. Without an Idempotency-Key
+	// header, task_id and attempt identify the request.
+	Attempt int `json:"attempt,omitempty"`
 }
 
-// ConsumeCredits consumes credits for a task execution
+// ConsumeCredits consumes credits for a task execution. Retries carrying the
+// same Idempotency-Key header (or task_id and attempt) return the original
+// transaction instead of charging again.
 // POST /internal/credits/consume
 func (h *InternalHandler) ConsumeCredits(c *fiber.Ctx) error {
 	var req CreditConsumeRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	officeID := uuid.MustParse(req.OfficeID)
+	taskID := uuid.MustParse(req.TaskID)
 
-	officeID, err := uuid.Parse(req.OfficeID)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id",
-		})
+	idempotencyKey := c.Get("Idempotency-Key")
+	if idempotencyKey == "" && req.Attempt > 0 {
+		idempotencyKey = fmt.Sprintf("%s:%d", taskID, req.Attempt)
 	}
 
-	taskID, err := uuid.Parse(req.TaskID)
+	tx, err := h.creditService.ConsumeCreditsForTask(c.Context(), officeID, taskID, req.Credits, req.Description, idempotencyKey)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid task_id",
-		})
+		log.Printf("Credit consumption failed: %v", err)
+		return err
 	}
 
-	tx, err := h.creditService.ConsumeCreditsForTask(c.Context(), officeID, taskID, req.Credits, req.Description)
-	if err != nil {
-		log.Printf("Credit consumption failed: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	// Alert the office if this consumption crossed a budget threshold
+	if _, err := h.creditService.CheckBudgetAlerts(c.Context(), officeID, req.Credits); err != nil {
+		log.Printf("Budget alert check failed: %v", err)
+	}
+	if _, err := h.creditService.CheckLowBalance(c.Context(), officeID, tx.BalanceAfter, req.Credits); err != nil {
+		log.Printf("Low credit check failed: %v", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -188,17 +448,13 @@ func (h *InternalHandler) GetBalance(c *fiber.Ctx) error {
 	officeIDStr := c.Params("officeId")
 	officeID, err := uuid.Parse(officeIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid office_id",
-		})
+		return badRequest("invalid office_id")
 	}
 
 	balance, err := h.creditService.GetBalance(c.Context(), officeID)
 	if err != nil {
 		log.Printf("Get balance failed: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get balance",
-		})
+		return internalError("failed to get balance", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxKnowledgeUploadBytes is read of an uploaded file; the service refuses
+// anything larger than its limit
+const maxKnowledgeUploadBytes = 1<<20 + 1
+
+// KnowledgeHandler handles the office knowledge base endpoints
+type KnowledgeHandler struct {
+	knowledgeService *service.KnowledgeService
+}
+
+// NewKnowledgeHandler creates a new KnowledgeHandler
+func NewKnowledgeHandler(knowledgeService *service.KnowledgeService) *KnowledgeHandler {
+	return &KnowledgeHandler{knowledgeService: knowledgeService}
+}
+
+// CreateKnowledgeRequest is a note for the knowledge base
+type CreateKnowledgeRequest struct {
+	Title   string `json:"title" validate:"required,max=255"`
+	Content string `json:"content" validate:"required"`
+}
+
+// CreateDocument adds a note, sent as JSON, or a text file, sent as the
+// multipart form field "file" with an optional "title" field, to the
+// office's knowledge base
+// POST /knowledge
+func (h *KnowledgeHandler) CreateDocument(c *fiber.Ctx) error {
+	input := service.CreateKnowledgeInput{
+		OfficeID: c.Locals("office_id").(uuid.UUID),
+		UserID:   c.Locals("user_id").(uuid.UUID),
+	}
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return badRequest("a multipart file field named file is required")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return internalError("failed to read upload", err)
+		}
+		defer file.Close()
+		content, err := io.ReadAll(io.LimitReader(file, maxKnowledgeUploadBytes))
+		if err != nil {
+			return internalError("failed to read upload", err)
+		}
+		input.Title = c.FormValue("title")
+		input.Content = string(content)
+		input.Filename = header.Filename
+	} else {
+		var req CreateKnowledgeRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		input.Title = req.Title
+		input.Content = req.Content
+	}
+
+	document, err := h.knowledgeService.CreateDocument(c.Context(), input)
+	if err != nil {
+		return internalError("failed to add knowledge", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(document)
+}
+
+// GetDocuments lists the office's knowledge documents, without their content
+// GET /knowledge
+func (h *KnowledgeHandler) GetDocuments(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	documents, err := h.knowledgeService.GetDocuments(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to get knowledge", err)
+	}
+	if documents == nil {
+		documents = []*domain.KnowledgeDocument{}
+	}
+
+	return c.JSON(fiber.Map{"documents": documents})
+}
+
+// GetDocument returns a knowledge document with its content
+// GET /knowledge/:id
+func (h *KnowledgeHandler) GetDocument(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	documentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid knowledge document id")
+	}
+
+	document, err := h.knowledgeService.GetDocument(c.Context(), officeID, documentID)
+	if err != nil {
+		return knowledgeError(err)
+	}
+
+	return c.JSON(document)
+}
+
+// DeleteDocument removes a knowledge document
+// DELETE /knowledge/:id
+func (h *KnowledgeHandler) DeleteDocument(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	documentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid knowledge document id")
+	}
+
+	if err := h.knowledgeService.DeleteDocument(c.Context(), officeID, documentID); err != nil {
+		return knowledgeError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Search returns the knowledge chunks most relevant to the query
+// GET /knowledge/search?q=
+func (h *KnowledgeHandler) Search(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	query := c.Query("q")
+	if query == "" {
+		return badRequest("q is required")
+	}
+
+	matches, err := h.knowledgeService.Search(c.Context(), officeID, query, c.QueryInt("limit"))
+	if err != nil {
+		return internalError("failed to search knowledge", err)
+	}
+	if matches == nil {
+		matches = []*domain.KnowledgeMatch{}
+	}
+
+	return c.JSON(fiber.Map{"matches": matches})
+}
+
+// knowledgeError maps knowledge base errors to API errors
+func knowledgeError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("knowledge document not found")
+	default:
+		return internalError("failed to manage knowledge", err)
+	}
+}
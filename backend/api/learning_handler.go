@@ -0,0 +1,39 @@
+package api
+
+import (
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LearningHandler handles agent learning endpoints
+type LearningHandler struct {
+	learningStatsService *service.LearningStatsService
+}
+
+// NewLearningHandler creates a new LearningHandler
+func NewLearningHandler(learningStatsService *service.LearningStatsService) *LearningHandler {
+	return &LearningHandler{learningStatsService: learningStatsService}
+}
+
+// GetLearningStats returns memory, feedback and interaction counts for an agent
+// GET /agents/:id/learning-stats
+func (h *LearningHandler) GetLearningStats(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	stats, err := h.learningStatsService.GetStats(c.Context(), officeID, agentID)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			return notFound("agent not found")
+		}
+		return internalError("failed to get learning stats", err)
+	}
+
+	return c.JSON(stats)
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"strconv"
+	"strings"
 
-	"github.com/denys89/syn-office/backend/repository"
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -19,7 +22,7 @@ func NewMarketplaceHandler(marketplaceService *service.MarketplaceService) *Mark
 
 // ListAgents handles GET /marketplace/agents
 func (h *MarketplaceHandler) ListAgents(c *fiber.Ctx) error {
-	filter := repository.MarketplaceFilter{
+	filter := domain.MarketplaceFilter{
 		Category: c.Query("category"),
 		Search:   c.Query("search"),
 		SortBy:   c.Query("sort", "featured"),
@@ -43,9 +46,48 @@ func (h *MarketplaceHandler) ListAgents(c *fiber.Ctx) error {
 		filter.IsPremium = &val
 	}
 
+	// Multiple categories as a comma-separated list
+	if categories := c.Query("categories"); categories != "" {
+		for _, slug := range strings.Split(categories, ",") {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				filter.Categories = append(filter.Categories, slug)
+			}
+		}
+	}
+
+	// Price range (cents) and rating filters
+	if v := c.Query("min_price"); v != "" {
+		price, err := strconv.Atoi(v)
+		if err != nil || price < 0 {
+			return badRequest("min_price must be a non-negative integer (cents)")
+		}
+		filter.MinPriceCents = &price
+	}
+	if v := c.Query("max_price"); v != "" {
+		price, err := strconv.Atoi(v)
+		if err != nil || price < 0 {
+			return badRequest("max_price must be a non-negative integer (cents)")
+		}
+		filter.MaxPriceCents = &price
+	}
+	if v := c.Query("min_rating"); v != "" {
+		rating, err := strconv.ParseFloat(v, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			return badRequest("min_rating must be between 0 and 5")
+		}
+		filter.MinRating = &rating
+	}
+	if v := c.Query("author_id"); v != "" {
+		authorID, err := uuid.Parse(v)
+		if err != nil {
+			return badRequest("Invalid author_id")
+		}
+		filter.AuthorID = &authorID
+	}
+
 	templates, total, err := h.marketplaceService.ListAgents(c.Context(), filter)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -60,12 +102,12 @@ func (h *MarketplaceHandler) ListAgents(c *fiber.Ctx) error {
 func (h *MarketplaceHandler) GetAgentDetails(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid agent ID"})
+		return badRequest("Invalid agent ID")
 	}
 
 	template, err := h.marketplaceService.GetAgentDetails(c.Context(), id)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		return notFound("Agent not found")
 	}
 
 	return c.JSON(template)
@@ -75,16 +117,20 @@ func (h *MarketplaceHandler) GetAgentDetails(c *fiber.Ctx) error {
 func (h *MarketplaceHandler) GetFeaturedAgents(c *fiber.Ctx) error {
 	templates, err := h.marketplaceService.GetFeaturedAgents(c.Context())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	return c.JSON(fiber.Map{"agents": templates})
+	bundles, err := h.marketplaceService.GetFeaturedBundles(c.Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"agents": templates, "bundles": bundles})
 }
 
 // GetCategories handles GET /marketplace/categories
 func (h *MarketplaceHandler) GetCategories(c *fiber.Ctx) error {
 	categories, err := h.marketplaceService.GetCategories(c.Context())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 	return c.JSON(fiber.Map{"categories": categories})
 }
@@ -93,7 +139,7 @@ func (h *MarketplaceHandler) GetCategories(c *fiber.Ctx) error {
 func (h *MarketplaceHandler) SearchAgents(c *fiber.Ctx) error {
 	query := c.Query("q")
 	if query == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Search query required"})
+		return badRequest("Search query required")
 	}
 
 	limit := 20
@@ -103,43 +149,59 @@ func (h *MarketplaceHandler) SearchAgents(c *fiber.Ctx) error {
 
 	templates, err := h.marketplaceService.SearchAgents(c.Context(), query, limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	bundles, err := h.marketplaceService.SearchBundles(c.Context(), query, limit)
+	if err != nil {
+		return err
 	}
-	return c.JSON(fiber.Map{"agents": templates})
+	return c.JSON(fiber.Map{"agents": templates, "bundles": bundles})
+}
+
+// ReviewRequest is the body for creating or editing a review
+type ReviewRequest struct {
+	Rating     int    `json:"rating" validate:"required,min=1,max=5"`
+	Title      string `json:"title" validate:"max=255"`
+	ReviewText string `json:"review_text" validate:"required"`
+}
+
+// ReviewVoteRequest is the body for voting on a review
+type ReviewVoteRequest struct {
+	Helpful *bool `json:"helpful" validate:"required"`
+}
+
+// ReviewReplyRequest is the body for an author's reply to a review
+type ReviewReplyRequest struct {
+	ReplyText string `json:"reply_text" validate:"required"`
 }
 
 // CreateReview handles POST /marketplace/agents/:id/reviews
 func (h *MarketplaceHandler) CreateReview(c *fiber.Ctx) error {
 	templateID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid agent ID"})
+		return badRequest("Invalid agent ID")
 	}
 
 	// Get user ID from context (set by auth middleware)
 	userID, ok := c.Locals("user_id").(uuid.UUID)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		return unauthorized("Unauthorized")
 	}
 
-	var req struct {
-		Rating     int    `json:"rating"`
-		Title      string `json:"title"`
-		ReviewText string `json:"review_text"`
-	}
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	var req ReviewRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if req.Rating < 1 || req.Rating > 5 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Rating must be between 1 and 5"})
+	err = h.marketplaceService.AddReview(c.Context(), userID, templateID, req.Rating, req.Title, req.ReviewText)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return conflict("You have already reviewed this agent; edit your existing review instead")
 	}
-	if req.ReviewText == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Review text is required"})
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("Agent not found")
 	}
-
-	err = h.marketplaceService.AddReview(c.Context(), userID, templateID, req.Rating, req.Title, req.ReviewText)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Review submitted successfully"})
@@ -149,22 +211,391 @@ func (h *MarketplaceHandler) CreateReview(c *fiber.Ctx) error {
 func (h *MarketplaceHandler) GetReviews(c *fiber.Ctx) error {
 	templateID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid agent ID"})
+		return badRequest("Invalid agent ID")
 	}
 
-	limit := 20
-	offset := 0
-	if l, err := strconv.Atoi(c.Query("limit", "20")); err == nil {
-		limit = l
+	page, _ := parsePageRequest(c, 20, 100)
+
+	reviews, total, err := h.marketplaceService.GetReviews(c.Context(), templateID, page.Limit, page.Offset)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(newPage(reviews, total, page.Limit, nil))
+}
+
+// UpdateReview handles PUT /marketplace/agents/:id/reviews/:reviewId
+func (h *MarketplaceHandler) UpdateReview(c *fiber.Ctx) error {
+	userID, templateID, reviewID, err := reviewParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req ReviewRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	review, err := h.marketplaceService.UpdateReview(c.Context(), userID, templateID, reviewID, req.Rating, req.Title, req.ReviewText)
+	if errors.Is(err, domain.ErrForbidden) {
+		return forbidden("You can only edit your own reviews")
+	}
+	if err != nil {
+		return reviewError(err)
+	}
+
+	return c.JSON(review)
+}
+
+// DeleteReview handles DELETE /marketplace/agents/:id/reviews/:reviewId
+func (h *MarketplaceHandler) DeleteReview(c *fiber.Ctx) error {
+	userID, templateID, reviewID, err := reviewParams(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.marketplaceService.DeleteReview(c.Context(), userID, templateID, reviewID)
+	if errors.Is(err, domain.ErrForbidden) {
+		return forbidden("You can only delete your own reviews")
+	}
+	if err != nil {
+		return reviewError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VoteReview handles POST /marketplace/agents/:id/reviews/:reviewId/vote
+func (h *MarketplaceHandler) VoteReview(c *fiber.Ctx) error {
+	userID, templateID, reviewID, err := reviewParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req ReviewVoteRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
-	if o, err := strconv.Atoi(c.Query("offset", "0")); err == nil {
-		offset = o
+
+	if err := h.marketplaceService.VoteReview(c.Context(), userID, templateID, reviewID, *req.Helpful); err != nil {
+		return reviewError(err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Vote recorded"})
+}
+
+// RemoveReviewVote handles DELETE /marketplace/agents/:id/reviews/:reviewId/vote
+func (h *MarketplaceHandler) RemoveReviewVote(c *fiber.Ctx) error {
+	userID, templateID, reviewID, err := reviewParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.marketplaceService.RemoveReviewVote(c.Context(), userID, templateID, reviewID); err != nil {
+		return reviewError(err)
 	}
 
-	reviews, err := h.marketplaceService.GetReviews(c.Context(), templateID, limit, offset)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ReplyToReview handles POST /marketplace/agents/:id/reviews/:reviewId/reply
+func (h *MarketplaceHandler) ReplyToReview(c *fiber.Ctx) error {
+	return h.saveReviewReply(c, h.marketplaceService.ReplyToReview, fiber.StatusCreated)
+}
+
+// UpdateReviewReply handles PUT /marketplace/agents/:id/reviews/:reviewId/reply
+func (h *MarketplaceHandler) UpdateReviewReply(c *fiber.Ctx) error {
+	return h.saveReviewReply(c, h.marketplaceService.UpdateReviewReply, fiber.StatusOK)
+}
+
+// saveReviewReply parses a reply request and hands it to the given service method
+func (h *MarketplaceHandler) saveReviewReply(
+	c *fiber.Ctx,
+	save func(ctx context.Context, authorID, templateID, reviewID uuid.UUID, text string) (*domain.ReviewReply, error),
+	status int,
+) error {
+	userID, templateID, reviewID, err := reviewParams(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
-	return c.JSON(fiber.Map{"reviews": reviews})
+	var req ReviewReplyRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	reply, err := save(c.Context(), userID, templateID, reviewID, req.ReplyText)
+	if err != nil {
+		return reviewError(err)
+	}
+
+	return c.Status(status).JSON(reply)
+}
+
+// reviewParams extracts the caller and the template/review IDs for review
+// engagement routes
+func reviewParams(c *fiber.Ctx) (userID, templateID, reviewID uuid.UUID, err error) {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return userID, templateID, reviewID, unauthorized("Unauthorized")
+	}
+	if templateID, err = uuid.Parse(c.Params("id")); err != nil {
+		return userID, templateID, reviewID, badRequest("Invalid agent ID")
+	}
+	if reviewID, err = uuid.Parse(c.Params("reviewId")); err != nil {
+		return userID, templateID, reviewID, badRequest("Invalid review ID")
+	}
+	return userID, templateID, reviewID, nil
+}
+
+// reviewError maps review engagement errors to API errors
+func reviewError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("Review not found")
+	case errors.Is(err, domain.ErrTemplateNotOwned):
+		return errorFor(domain.ErrTemplateNotOwned, "Only the template author can reply to reviews")
+	case errors.Is(err, domain.ErrAlreadyExists):
+		return conflict("This review already has a reply")
+	default:
+		return err
+	}
+}
+
+// SubmitTemplate handles POST /marketplace/templates
+func (h *MarketplaceHandler) SubmitTemplate(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	var req service.TemplateInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	template, err := h.marketplaceService.SubmitTemplate(c.Context(), userID, req)
+	if err != nil {
+		return templateError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateTemplate handles PUT /marketplace/templates/:id
+func (h *MarketplaceHandler) UpdateTemplate(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid template ID")
+	}
+
+	var req service.TemplateInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	template, err := h.marketplaceService.UpdateTemplate(c.Context(), userID, templateID, req)
+	if err != nil {
+		return templateError(err)
+	}
+
+	return c.JSON(template)
+}
+
+// PublishVersion handles POST /marketplace/templates/:id/versions
+func (h *MarketplaceHandler) PublishVersion(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid template ID")
+	}
+
+	var req service.PublishVersionInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	version, err := h.marketplaceService.PublishVersion(c.Context(), userID, templateID, req)
+	if err != nil {
+		return templateError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(version)
+}
+
+// GetVersions handles GET /marketplace/agents/:id/versions
+func (h *MarketplaceHandler) GetVersions(c *fiber.Ctx) error {
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid agent ID")
+	}
+
+	versions, err := h.marketplaceService.GetTemplateVersions(c.Context(), templateID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("Agent not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"versions": versions})
+}
+
+// GetAuthorTemplates handles GET /author/templates
+func (h *MarketplaceHandler) GetAuthorTemplates(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	templates, err := h.marketplaceService.GetAuthorTemplates(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"templates": templates})
+}
+
+// templateError maps template submission errors to API errors
+func templateError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("Template not found")
+	case errors.Is(err, domain.ErrTemplateNotOwned):
+		return errorFor(domain.ErrTemplateNotOwned, "You can only edit your own templates")
+	case errors.Is(err, domain.ErrAlreadyExists):
+		return conflict("This version has already been published")
+	default:
+		return err
+	}
+}
+
+// ListBundles handles GET /marketplace/bundles
+func (h *MarketplaceHandler) ListBundles(c *fiber.Ctx) error {
+	filter := domain.BundleFilter{Search: c.Query("search")}
+	if limit, err := strconv.Atoi(c.Query("limit", "20")); err == nil {
+		filter.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset", "0")); err == nil {
+		filter.Offset = offset
+	}
+	if featured := c.Query("featured"); featured != "" {
+		val := featured == "true"
+		filter.IsFeatured = &val
+	}
+	if v := c.Query("author_id"); v != "" {
+		authorID, err := uuid.Parse(v)
+		if err != nil {
+			return badRequest("Invalid author_id")
+		}
+		filter.AuthorID = &authorID
+	}
+
+	bundles, total, err := h.marketplaceService.ListBundles(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"bundles": bundles,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// GetBundleDetails handles GET /marketplace/bundles/:id
+func (h *MarketplaceHandler) GetBundleDetails(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid bundle ID")
+	}
+
+	bundle, err := h.marketplaceService.GetBundleDetails(c.Context(), id)
+	if err != nil {
+		return bundleError(err)
+	}
+
+	return c.JSON(bundle)
+}
+
+// CreateBundle handles POST /marketplace/bundles
+func (h *MarketplaceHandler) CreateBundle(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	var req service.BundleInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	bundle, err := h.marketplaceService.CreateBundle(c.Context(), userID, req)
+	if err != nil {
+		return bundleError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(bundle)
+}
+
+// UpdateBundle handles PUT /marketplace/bundles/:id
+func (h *MarketplaceHandler) UpdateBundle(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	bundleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid bundle ID")
+	}
+
+	var req service.BundleInput
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	bundle, err := h.marketplaceService.UpdateBundle(c.Context(), userID, bundleID, req)
+	if err != nil {
+		return bundleError(err)
+	}
+
+	return c.JSON(bundle)
+}
+
+// GetAuthorBundles handles GET /author/bundles
+func (h *MarketplaceHandler) GetAuthorBundles(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	bundles, err := h.marketplaceService.GetAuthorBundles(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{"bundles": bundles})
+}
+
+// bundleError maps bundle errors to API errors
+func bundleError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("Bundle not found")
+	case errors.Is(err, domain.ErrTemplateNotOwned):
+		return errorFor(domain.ErrTemplateNotOwned, "You can only edit your own bundles and bundle your own templates")
+	default:
+		return err
+	}
 }
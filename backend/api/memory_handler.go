@@ -0,0 +1,235 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// MemoryHandler handles agent memory management endpoints
+type MemoryHandler struct {
+	memoryService *service.MemoryService
+}
+
+// NewMemoryHandler creates a new MemoryHandler
+func NewMemoryHandler(memoryService *service.MemoryService) *MemoryHandler {
+	return &MemoryHandler{memoryService: memoryService}
+}
+
+// CreateMemoryRequest represents a request to add a memory to an agent
+type CreateMemoryRequest struct {
+	Key             string         `json:"key" validate:"required,max=255"`
+	Value           string         `json:"value" validate:"required"`
+	MemoryType      string         `json:"memory_type,omitempty" validate:"omitempty,oneof=fact preference correction insight task_result"`
+	ImportanceScore *float64       `json:"importance_score,omitempty" validate:"omitempty,min=0,max=1"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+}
+
+// UpdateMemoryRequest represents a partial update to an agent memory
+type UpdateMemoryRequest struct {
+	Key             *string        `json:"key,omitempty" validate:"omitempty,min=1,max=255"`
+	Value           *string        `json:"value,omitempty" validate:"omitempty,min=1"`
+	MemoryType      *string        `json:"memory_type,omitempty" validate:"omitempty,oneof=fact preference correction insight task_result"`
+	ImportanceScore *float64       `json:"importance_score,omitempty" validate:"omitempty,min=0,max=1"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+}
+
+// StoreEmbeddingRequest carries the orchestrator's embedding of a memory
+type StoreEmbeddingRequest struct {
+	Embedding []float32 `json:"embedding" validate:"required"`
+	// VectorID is the memory's vector in the orchestrator's vector store
+	VectorID string `json:"vector_id,omitempty" validate:"max=100"`
+}
+
+// RetrieveMemoriesRequest asks for an agent's memories most relevant to a
+// task, by the embedding of its input or by its text
+type RetrieveMemoriesRequest struct {
+	Query     string    `json:"query,omitempty" validate:"max=1000"`
+	Embedding []float32 `json:"embedding,omitempty"`
+	Limit     int       `json:"limit,omitempty" validate:"gte=0,lte=50"`
+}
+
+// CreateMemory adds a user-provided memory to an agent
+// POST /agents/:id/memories
+func (h *MemoryHandler) CreateMemory(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req CreateMemoryRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	memory, err := h.memoryService.CreateMemory(c.Context(), service.CreateMemoryInput{
+		OfficeID:        officeID,
+		AgentID:         agentID,
+		Key:             req.Key,
+		Value:           req.Value,
+		MemoryType:      req.MemoryType,
+		ImportanceScore: req.ImportanceScore,
+		Source:          domain.MemorySourceUser,
+		Metadata:        req.Metadata,
+	})
+	if err != nil {
+		return memoryError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(memory)
+}
+
+// UpdateMemory edits an existing agent memory
+// PUT /agents/:id/memories/:memoryId
+func (h *MemoryHandler) UpdateMemory(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	memoryID, err := uuid.Parse(c.Params("memoryId"))
+	if err != nil {
+		return badRequest("invalid memory id")
+	}
+
+	var req UpdateMemoryRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	memory, err := h.memoryService.UpdateMemory(c.Context(), officeID, agentID, memoryID, service.UpdateMemoryInput{
+		Key:             req.Key,
+		Value:           req.Value,
+		MemoryType:      req.MemoryType,
+		ImportanceScore: req.ImportanceScore,
+		Metadata:        req.Metadata,
+	})
+	if err != nil {
+		return memoryError(err)
+	}
+
+	return c.JSON(memory)
+}
+
+// DeleteMemory removes an agent memory
+// DELETE /agents/:id/memories/:memoryId
+func (h *MemoryHandler) DeleteMemory(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	memoryID, err := uuid.Parse(c.Params("memoryId"))
+	if err != nil {
+		return badRequest("invalid memory id")
+	}
+
+	if err := h.memoryService.DeleteMemory(c.Context(), officeID, agentID, memoryID); err != nil {
+		return memoryError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SearchMemories finds the agent's memories most relevant to ?q=
+// GET /agents/:id/memories/search
+func (h *MemoryHandler) SearchMemories(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	query := c.Query("q")
+	if query == "" {
+		return badRequest("q is required")
+	}
+
+	matches, err := h.memoryService.SearchMemories(c.Context(), officeID, agentID, query, c.QueryInt("limit"))
+	if err != nil {
+		return memorySearchError(err)
+	}
+	if matches == nil {
+		matches = []*domain.MemoryMatch{}
+	}
+
+	return c.JSON(fiber.Map{"memories": matches})
+}
+
+// StoreEmbedding saves the orchestrator's embedding of a memory
+// PUT /internal/memories/:id/embedding
+func (h *MemoryHandler) StoreEmbedding(c *fiber.Ctx) error {
+	memoryID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid memory id")
+	}
+
+	var req StoreEmbeddingRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.memoryService.StoreEmbedding(c.Context(), memoryID, req.Embedding, req.VectorID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("memory not found")
+		}
+		return internalError("failed to store memory embedding", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RetrieveMemories returns the agent's top memories for a task's context
+// POST /internal/agents/:id/memories/search
+func (h *MemoryHandler) RetrieveMemories(c *fiber.Ctx) error {
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req RetrieveMemoriesRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	matches, err := h.memoryService.RetrieveMemories(c.Context(), agentID, req.Query, req.Embedding, req.Limit)
+	if err != nil {
+		return memorySearchError(err)
+	}
+	if matches == nil {
+		matches = []*domain.MemoryMatch{}
+	}
+
+	return c.JSON(fiber.Map{"memories": matches})
+}
+
+// memorySearchError maps memory search errors to API errors
+func memorySearchError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent not found")
+	default:
+		return internalError("failed to search memories", err)
+	}
+}
+
+// memoryError maps memory service errors to API errors
+func memoryError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		return badRequest("key and value are required, memory_type must be fact, preference, correction, insight or task_result, and importance_score must be between 0 and 1")
+	case errors.Is(err, domain.ErrAlreadyExists):
+		return conflict("a memory with this key already exists for the agent")
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent or memory not found")
+	default:
+		return internalError("failed to manage memory", err)
+	}
+}
@@ -1,43 +1,243 @@
 package api
 
 import (
+	"errors"
 	"log"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
-// AuthMiddleware handles JWT authentication
-func AuthMiddleware(authService *service.AuthService) fiber.Handler {
+// AuthMiddleware authenticates requests with a JWT in the Authorization
+// header or an API key in X-API-Key. API keys act for the user who created
+// them, within their scopes: read for GET requests, write for the rest.
+func AuthMiddleware(authService *service.AuthService, apiKeyService *service.APIKeyService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			return authenticateAPIKey(c, apiKeyService, apiKey)
+		}
+
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "missing authorization header",
-			})
+			return unauthorized("missing authorization header")
 		}
 
 		// Extract token from "Bearer <token>"
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid authorization header format",
-			})
+			return unauthorized("invalid authorization header format")
 		}
 
 		token := parts[1]
-		claims, err := authService.ValidateToken(token)
+		claims, err := authService.ValidateToken(c.Context(), token)
+		if errors.Is(err, domain.ErrUnauthorized) {
+			return unauthorized("invalid or expired token")
+		}
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid or expired token",
-			})
+			return internalError("failed to authenticate", err)
 		}
 
 		// Store claims in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("office_id", claims.OfficeID)
 		c.Locals("email", claims.Email)
+		setAuditActor(c, claims.UserID, claims.OfficeID)
+
+		return c.Next()
+	}
+}
+
+// OptionalAuthMiddleware signs in the caller of a public route that sends a
+// valid JWT, setting the same locals as AuthMiddleware. Requests without
+// one, or with an invalid one, go through anonymously.
+func OptionalAuthMiddleware(authService *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+		if !ok {
+			return c.Next()
+		}
+		if claims, err := authService.ValidateToken(c.Context(), token); err == nil {
+			c.Locals("user_id", claims.UserID)
+			c.Locals("office_id", claims.OfficeID)
+			c.Locals("email", claims.Email)
+		}
+		return c.Next()
+	}
+}
+
+// authenticateAPIKey authenticates a request made with an API key
+func authenticateAPIKey(c *fiber.Ctx, apiKeyService *service.APIKeyService, apiKey string) error {
+	key, err := apiKeyService.Authenticate(c.Context(), apiKey)
+	if errors.Is(err, domain.ErrUnauthorized) {
+		return unauthorized("invalid, expired or revoked API key")
+	}
+	if err != nil {
+		return internalError("failed to authenticate API key", err)
+	}
+
+	scope := domain.APIKeyScopeWrite
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		scope = domain.APIKeyScopeRead
+	}
+	if !key.HasScope(scope) {
+		return forbidden("API key lacks the " + string(scope) + " scope")
+	}
+
+	c.Locals("user_id", key.UserID)
+	c.Locals("office_id", key.OfficeID)
+	c.Locals("api_key_id", key.ID)
+	setAuditActor(c, key.UserID, key.OfficeID)
+
+	return c.Next()
+}
+
+// AuditActorMiddleware records the client's IP for the audit log. The
+// services read it, and the user AuthMiddleware signs in, from the request's
+// context.
+func AuditActorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(service.AuditActorKey{}, &service.AuditActor{IP: c.IP()})
+		return c.Next()
+	}
+}
+
+// setAuditActor attributes the rest of the request's audited actions to the
+// signed-in user
+func setAuditActor(c *fiber.Ctx, userID, officeID uuid.UUID) {
+	if actor, ok := c.Locals(service.AuditActorKey{}).(*service.AuditActor); ok {
+		actor.UserID = userID
+		actor.OfficeID = officeID
+		return
+	}
+	c.Locals(service.AuditActorKey{}, &service.AuditActor{UserID: userID, OfficeID: officeID, IP: c.IP()})
+}
+
+// SessionOnlyMiddleware rejects requests authenticated with an API key, for
+// routes such as key management that need the user to be signed in. Must run
+// after AuthMiddleware.
+func SessionOnlyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("api_key_id").(uuid.UUID); ok {
+			return forbidden("this endpoint cannot be called with an API key")
+		}
+		return c.Next()
+	}
+}
+
+// RequireFeature rejects requests from offices whose subscription tier does
+// not include the feature, one of the service.Feature* names, with a 402
+// upgrade_required error naming the tier that does. Must run after
+// AuthMiddleware.
+func RequireFeature(subscriptionService *service.SubscriptionService, feature string) fiber.Handler {
+	if !service.IsFeature(feature) {
+		panic("api: unknown feature " + feature)
+	}
+	return func(c *fiber.Ctx) error {
+		officeID, ok := c.Locals("office_id").(uuid.UUID)
+		if !ok {
+			return unauthorized("office_id not found in context")
+		}
+		if err := subscriptionService.RequireFeature(c.Context(), officeID, feature); err != nil {
+			return internalError("failed to check subscription features", err)
+		}
+		return c.Next()
+	}
+}
+
+// RateLimitMiddleware meters requests per office at the rate of the office's
+// subscription tier. Must run after AuthMiddleware.
+func RateLimitMiddleware(rateLimitService *service.RateLimitService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		officeID, ok := c.Locals("office_id").(uuid.UUID)
+		if !ok {
+			return c.Next()
+		}
+		state, err := rateLimitService.TakeOffice(c.Context(), officeID)
+		return applyRateLimit(c, state, err)
+	}
+}
+
+// AuthRateLimitMiddleware meters requests per client address, for the
+// unauthenticated auth endpoints where password guessing would happen
+func AuthRateLimitMiddleware(rateLimitService *service.RateLimitService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		state, err := rateLimitService.TakeAuth(c.Context(), c.IP())
+		return applyRateLimit(c, state, err)
+	}
+}
+
+// WidgetTokenMiddleware authenticates the chat widget token in the :token
+// route parameter against the page's Origin and meters the request against
+// the token's per-minute limit. The token is stored as "widget_token".
+func WidgetTokenMiddleware(widgetService *service.WidgetService, rateLimitService *service.RateLimitService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, err := widgetService.Authenticate(c.Context(), c.Params("token"), c.Get(fiber.HeaderOrigin))
+		if errors.Is(err, domain.ErrUnauthorized) {
+			return unauthorized("invalid or revoked widget token")
+		}
+		if err != nil {
+			return internalError("failed to authenticate widget token", err)
+		}
+		c.Locals("widget_token", token)
+
+		state, err := rateLimitService.TakeWidget(c.Context(), token.ID, token.RateLimit)
+		return applyRateLimit(c, state, err)
+	}
+}
+
+// applyRateLimit reports a rate limit in the X-RateLimit-* headers and
+// rejects the request once the limit is used up. If the limiter fails the
+// request is let through: an outage should not take the API down with it.
+func applyRateLimit(c *fiber.Ctx, state domain.RateLimit, err error) error {
+	if err != nil {
+		log.Printf("Rate limiter error, allowing request: %v", err)
+		return c.Next()
+	}
+
+	c.Set("X-RateLimit-Limit", strconv.Itoa(state.Limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(state.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(state.Reset)))
+
+	if !state.Allowed {
+		retryAfter := ceilSeconds(state.RetryAfter)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return &Error{
+			Status:  fiber.StatusTooManyRequests,
+			Code:    domain.ErrRateLimited.Code,
+			Message: "rate limit exceeded, retry later",
+			Details: map[string]any{"retry_after_seconds": retryAfter},
+		}
+	}
+	return c.Next()
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// AdminMiddleware restricts a route group to admin users. Must run after AuthMiddleware.
+// The role is read from the database on each request so revoking admin access takes effect immediately.
+func AdminMiddleware(authService *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(uuid.UUID)
+		if !ok {
+			return unauthorized("unauthorized")
+		}
+
+		isAdmin, err := authService.IsAdmin(c.Context(), userID)
+		if err != nil {
+			return unauthorized("unauthorized")
+		}
+		if !isAdmin {
+			return forbidden("admin access required")
+		}
 
 		return c.Next()
 	}
@@ -54,16 +254,12 @@ func InternalAPIKeyMiddleware(expectedKey string) fiber.Handler {
 
 		if apiKey == "" {
 			log.Printf("[Internal API] Missing API key")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "missing internal API key",
-			})
+			return unauthorized("missing internal API key")
 		}
 
 		if apiKey != expectedKey {
 			log.Printf("[Internal API] Key mismatch!")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid internal API key",
-			})
+			return unauthorized("invalid internal API key")
 		}
 
 		log.Printf("[Internal API] Authentication successful")
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ModelPolicyHandler handles model routing policy endpoints
+type ModelPolicyHandler struct {
+	modelPolicyService *service.ModelPolicyService
+}
+
+// NewModelPolicyHandler creates a new ModelPolicyHandler
+func NewModelPolicyHandler(modelPolicyService *service.ModelPolicyService) *ModelPolicyHandler {
+	return &ModelPolicyHandler{modelPolicyService: modelPolicyService}
+}
+
+// SetModelPolicyRequest represents a model policy to set. The preferred
+// model and provider are set together.
+type SetModelPolicyRequest struct {
+	AllowedProviders  []string `json:"allowed_providers" validate:"omitempty,dive,oneof=ollama groq openai anthropic"`
+	PreferredModel    string   `json:"preferred_model" validate:"omitempty,max=100"`
+	PreferredProvider string   `json:"preferred_provider" validate:"omitempty,oneof=ollama groq openai anthropic"`
+	MaxCreditsPerTask *int64   `json:"max_credits_per_task,omitempty" validate:"omitempty,gt=0"`
+}
+
+// ListModelPolicies returns the office's model policy and its agents' own
+// GET /model-policies
+func (h *ModelPolicyHandler) ListModelPolicies(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	policies, err := h.modelPolicyService.ListPolicies(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to get model policies", err)
+	}
+
+	return c.JSON(fiber.Map{"policies": policies})
+}
+
+// GetOfficeModelPolicy returns the office's model policy
+// GET /model-policies/office
+func (h *ModelPolicyHandler) GetOfficeModelPolicy(c *fiber.Ctx) error {
+	return h.getPolicy(c, nil)
+}
+
+// SetOfficeModelPolicy sets the office's model policy
+// PUT /model-policies/office
+func (h *ModelPolicyHandler) SetOfficeModelPolicy(c *fiber.Ctx) error {
+	return h.setPolicy(c, nil)
+}
+
+// DeleteOfficeModelPolicy removes the office's model policy
+// DELETE /model-policies/office
+func (h *ModelPolicyHandler) DeleteOfficeModelPolicy(c *fiber.Ctx) error {
+	return h.deletePolicy(c, nil)
+}
+
+// GetAgentModelPolicy returns an agent's own model policy
+// GET /agents/:id/model-policy
+func (h *ModelPolicyHandler) GetAgentModelPolicy(c *fiber.Ctx) error {
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	return h.getPolicy(c, &agentID)
+}
+
+// SetAgentModelPolicy sets an agent's own model policy, which replaces the
+// office's for its tasks
+// PUT /agents/:id/model-policy
+func (h *ModelPolicyHandler) SetAgentModelPolicy(c *fiber.Ctx) error {
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	return h.setPolicy(c, &agentID)
+}
+
+// DeleteAgentModelPolicy removes an agent's own model policy
+// DELETE /agents/:id/model-policy
+func (h *ModelPolicyHandler) DeleteAgentModelPolicy(c *fiber.Ctx) error {
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+	return h.deletePolicy(c, &agentID)
+}
+
+func (h *ModelPolicyHandler) getPolicy(c *fiber.Ctx, agentID *uuid.UUID) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	policy, err := h.modelPolicyService.GetPolicy(c.Context(), officeID, agentID)
+	if err != nil {
+		return modelPolicyError(err, "model policy not found", "failed to get model policy")
+	}
+
+	return c.JSON(policy)
+}
+
+func (h *ModelPolicyHandler) setPolicy(c *fiber.Ctx, agentID *uuid.UUID) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	var req SetModelPolicyRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	policy, err := h.modelPolicyService.SetPolicy(c.Context(), service.SetModelPolicyInput{
+		OfficeID:          officeID,
+		AgentID:           agentID,
+		AllowedProviders:  req.AllowedProviders,
+		PreferredModel:    req.PreferredModel,
+		PreferredProvider: req.PreferredProvider,
+		MaxCreditsPerTask: req.MaxCreditsPerTask,
+	})
+	if err != nil {
+		return modelPolicyError(err, "agent not found", "failed to set model policy")
+	}
+
+	return c.JSON(policy)
+}
+
+func (h *ModelPolicyHandler) deletePolicy(c *fiber.Ctx, agentID *uuid.UUID) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	if err := h.modelPolicyService.DeletePolicy(c.Context(), officeID, agentID); err != nil {
+		return modelPolicyError(err, "model policy not found", "failed to delete model policy")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// modelPolicyError maps model policy errors to API errors. Policies that
+// break the tier's limits are reported as the domain errors they are.
+func modelPolicyError(err error, missing, fallback string) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound(missing)
+	}
+	return internalError(fallback, err)
+}
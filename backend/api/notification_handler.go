@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// NotificationHandler handles the notification center endpoints
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+// NewNotificationHandler creates a new NotificationHandler
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// NotificationPreferenceRequest is a user's choice for one notification type
+type NotificationPreferenceRequest struct {
+	Type  string `json:"notification_type" validate:"required"`
+	InApp *bool  `json:"in_app" validate:"required"`
+	Email *bool  `json:"email" validate:"required"`
+}
+
+// UpdateNotificationPreferencesRequest represents a change of notification
+// preferences; types left out keep theirs
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreferenceRequest `json:"preferences" validate:"required,min=1,dive"`
+}
+
+// ListNotifications returns the office's notifications, newest first,
+// optionally only the unread ones
+// GET /notifications
+func (h *NotificationHandler) ListNotifications(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	page, err := parsePageRequest(c, 20, 100)
+	if err != nil {
+		return err
+	}
+
+	notifications, total, err := h.notificationService.ListNotifications(c.Context(), officeID, c.QueryBool("unread"), page)
+	if err != nil {
+		return internalError("failed to list notifications", err)
+	}
+
+	return c.JSON(newPage(notifications, total, page.Limit, func(n *domain.Notification) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	}))
+}
+
+// MarkNotificationRead marks a notification as read
+// POST /notifications/:id/read
+func (h *NotificationHandler) MarkNotificationRead(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	notificationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid notification id")
+	}
+
+	err = h.notificationService.MarkRead(c.Context(), officeID, notificationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("notification not found")
+	}
+	if err != nil {
+		return internalError("failed to mark notification read", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// MarkAllNotificationsRead marks all of the office's notifications as read
+// POST /notifications/read-all
+func (h *NotificationHandler) MarkAllNotificationsRead(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	count, err := h.notificationService.MarkAllRead(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to mark notifications read", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"marked_read": count,
+	})
+}
+
+// GetNotificationPreferences returns how the user is notified of each type
+// GET /notifications/preferences
+func (h *NotificationHandler) GetNotificationPreferences(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	preferences, err := h.notificationService.GetPreferences(c.Context(), userID)
+	if err != nil {
+		return internalError("failed to get notification preferences", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"preferences": preferences,
+	})
+}
+
+// UpdateNotificationPreferences changes whether the user is notified of
+// some types in the app and by email
+// PUT /notifications/preferences
+func (h *NotificationHandler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var req UpdateNotificationPreferencesRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	changed := make([]domain.NotificationPreference, len(req.Preferences))
+	for i, p := range req.Preferences {
+		changed[i] = domain.NotificationPreference{
+			Type:  domain.NotificationType(p.Type),
+			InApp: *p.InApp,
+			Email: *p.Email,
+		}
+	}
+
+	preferences, err := h.notificationService.UpdatePreferences(c.Context(), userID, changed)
+	if err != nil {
+		return internalError("failed to update notification preferences", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"preferences": preferences,
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// oauthStateCookie holds the state parameter of a sign-in in progress,
+	// binding the provider's callback to the browser that started it
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// OAuthHandler handles social login endpoints. Sign-ins are browser
+// redirects: the callback sends the user back to the frontend with the JWT,
+// or an error code, in the URL fragment.
+type OAuthHandler struct {
+	oauthService *service.OAuthService
+	// appURL is the frontend base URL the callback redirects to
+	appURL string
+	// secureCookies marks the state cookie HTTPS-only
+	secureCookies bool
+}
+
+// NewOAuthHandler creates a new OAuthHandler
+func NewOAuthHandler(oauthService *service.OAuthService, appURL string, secureCookies bool) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService:  oauthService,
+		appURL:        strings.TrimRight(appURL, "/"),
+		secureCookies: secureCookies,
+	}
+}
+
+// GetProviders lists the configured OAuth providers
+// GET /auth/oauth
+func (h *OAuthHandler) GetProviders(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"providers": h.oauthService.Providers()})
+}
+
+// Start redirects the user to the provider to approve the sign-in
+// GET /auth/oauth/:provider
+func (h *OAuthHandler) Start(c *fiber.Ctx) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return internalError("failed to start sign-in", err)
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	authURL, err := h.oauthService.AuthCodeURL(c.Params("provider"), state)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("unknown OAuth provider")
+		}
+		return internalError("failed to start sign-in", err)
+	}
+
+	h.setStateCookie(c, state, time.Now().Add(oauthStateTTL))
+	return c.Redirect(authURL)
+}
+
+// Callback completes the sign-in and redirects to the frontend
+// GET /auth/oauth/:provider/callback
+func (h *OAuthHandler) Callback(c *fiber.Ctx) error {
+	provider := c.Params("provider")
+	if !h.oauthService.HasProvider(provider) {
+		return notFound("unknown OAuth provider")
+	}
+
+	// The state is single use
+	state := c.Cookies(oauthStateCookie)
+	h.setStateCookie(c, "", time.Now().Add(-time.Hour))
+
+	if c.Query("error") != "" {
+		// The user declined, or the provider refused the request
+		return h.redirectWith(c, "error", "access_denied")
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		return h.redirectWith(c, "error", "invalid_state")
+	}
+
+	result, err := h.oauthService.Login(c.Context(), provider, c.Query("code"))
+	switch {
+	case err == nil:
+		return h.redirectWith(c, "token", result.Token)
+	case errors.Is(err, domain.ErrInvalidInput):
+		return h.redirectWith(c, "error", "email_not_verified")
+	case errors.Is(err, domain.ErrAlreadyExists):
+		return h.redirectWith(c, "error", "account_not_verified")
+	case errors.Is(err, domain.ErrUnauthorized):
+		log.Printf("OAuth sign-in failed: %v", err)
+		return h.redirectWith(c, "error", "oauth_failed")
+	default:
+		log.Printf("OAuth sign-in with %s failed: %v", provider, err)
+		return h.redirectWith(c, "error", "server_error")
+	}
+}
+
+// setStateCookie sets or, with an expiry in the past, deletes the state
+// cookie
+func (h *OAuthHandler) setStateCookie(c *fiber.Ctx, state string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oauth",
+		Expires:  expires,
+		Secure:   h.secureCookies,
+		HTTPOnly: true,
+		// Lax lets the cookie through on the provider's top-level redirect back
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// redirectWith sends the user to the frontend's OAuth page with key=value
+// in the fragment, which browsers do not send to servers
+func (h *OAuthHandler) redirectWith(c *fiber.Ctx, key, value string) error {
+	fragment := url.Values{key: {value}}.Encode()
+	return c.Redirect(h.appURL + "/oauth/callback#" + fragment)
+}
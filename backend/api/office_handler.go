@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// OfficeHandler handles office management endpoints
+type OfficeHandler struct {
+	officeService  *service.OfficeService
+	archiveService *service.OfficeArchiveService
+}
+
+// NewOfficeHandler creates a new OfficeHandler
+func NewOfficeHandler(officeService *service.OfficeService, archiveService *service.OfficeArchiveService) *OfficeHandler {
+	return &OfficeHandler{officeService: officeService, archiveService: archiveService}
+}
+
+// UpdateOfficeRequest represents a request to rename an office or change its
+// timezone; omitted fields are kept
+type UpdateOfficeRequest struct {
+	Name *string `json:"name,omitempty" validate:"omitempty,max=255"`
+	// Timezone is the IANA time zone usage analytics count days in
+	Timezone *string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+// GetOffices lists the user's offices
+// GET /offices
+func (h *OfficeHandler) GetOffices(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	offices, err := h.officeService.GetOffices(c.Context(), userID)
+	if err != nil {
+		return internalError("failed to get offices", err)
+	}
+	if offices == nil {
+		offices = []*domain.Office{}
+	}
+
+	return c.JSON(fiber.Map{"offices": offices})
+}
+
+// UpdateOffice renames one of the user's offices or changes its timezone
+// PUT /offices/:id
+func (h *OfficeHandler) UpdateOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	var req UpdateOfficeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	office, err := h.officeService.UpdateOffice(c.Context(), userID, officeID, service.UpdateOfficeInput{
+		Name:     req.Name,
+		Timezone: req.Timezone,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("office not found")
+		}
+		return internalError("failed to update office", err)
+	}
+
+	return c.JSON(office)
+}
+
+// DeleteOffice deletes one of the user's offices other than the current one;
+// it can be restored for 30 days
+// DELETE /offices/:id
+func (h *OfficeHandler) DeleteOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+	currentOfficeID := c.Locals("office_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	err = h.officeService.DeleteOffice(c.Context(), userID, currentOfficeID, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("office not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RestoreOffice brings back one of the user's offices deleted within the
+// last 30 days
+// POST /offices/:id/restore
+func (h *OfficeHandler) RestoreOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	office, err := h.officeService.RestoreOffice(c.Context(), userID, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("no deleted office to restore")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(office)
+}
+
+// ExportOffice downloads one of the user's offices as a JSON archive
+// GET /offices/:id/export
+func (h *OfficeHandler) ExportOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	officeID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid office id")
+	}
+
+	archive, err := h.archiveService.ExportOffice(c.Context(), userID, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("office not found")
+	}
+	if err != nil {
+		return internalError("failed to export office", err)
+	}
+
+	c.Attachment("synoffice-office-" + archive.ExportedAt.Format("2006-01-02") + ".json")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(archive)
+}
+
+// ImportOffice recreates an exported office as a new office of the user
+// POST /offices/import
+func (h *OfficeHandler) ImportOffice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	var archive domain.OfficeArchive
+	if err := parseBody(c, &archive); err != nil {
+		return err
+	}
+
+	result, err := h.archiveService.ImportOffice(c.Context(), userID, &archive)
+	if err != nil {
+		return internalError("failed to import office", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
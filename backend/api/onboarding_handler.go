@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// OnboardingHandler handles the starter team endpoints
+type OnboardingHandler struct {
+	onboardingService *service.OnboardingService
+}
+
+// NewOnboardingHandler creates a new OnboardingHandler
+func NewOnboardingHandler(onboardingService *service.OnboardingService) *OnboardingHandler {
+	return &OnboardingHandler{onboardingService: onboardingService}
+}
+
+// ApplyPresetRequest selects the preset to hire
+type ApplyPresetRequest struct {
+	Preset string `json:"preset" validate:"required"`
+}
+
+// GetPresets lists the starter teams offices can hire
+// GET /onboarding/presets
+func (h *OnboardingHandler) GetPresets(c *fiber.Ctx) error {
+	presets := h.onboardingService.GetPresets()
+	if presets == nil {
+		presets = []domain.OnboardingPreset{}
+	}
+
+	return c.JSON(fiber.Map{"presets": presets})
+}
+
+// ApplyPreset hires a starter team, starts its group conversation and posts
+// its welcome message
+// POST /onboarding/apply
+func (h *OnboardingHandler) ApplyPreset(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	var req ApplyPresetRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	result, err := h.onboardingService.ApplyPreset(c.Context(), service.ApplyPresetInput{
+		OfficeID: officeID,
+		Preset:   req.Preset,
+	})
+	if err != nil {
+		return onboardingError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// onboardingError maps onboarding errors to API errors. Teams that do not
+// fit the office's tier are reported as the limit they exceed.
+func onboardingError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("onboarding preset not found")
+	default:
+		return internalError("failed to apply onboarding preset", err)
+	}
+}
@@ -0,0 +1,1136 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/openapi"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Security schemes referenced by the spec
+const (
+	jwtAuth      = "bearerAuth"
+	apiKeyAuth   = "apiKey"
+	internalAuth = "internalApiKey"
+)
+
+// swaggerUI renders the spec with Swagger UI loaded from a CDN
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Synoffice API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+// setupDocs serves the OpenAPI spec at /api/v1/openapi.json and, outside
+// production, Swagger UI at /api/v1/docs. Both are public, so this must run
+// before the JWT middleware is installed on /api/v1.
+func (r *Router) setupDocs(v1 fiber.Router) *openapi.Document {
+	spec := apiSpec()
+	body, err := json.Marshal(spec)
+	if err != nil {
+		log.Printf("Failed to encode OpenAPI spec: %v", err)
+		return spec
+	}
+	v1.Get("/openapi.json", func(c *fiber.Ctx) error {
+		c.Type("json")
+		return c.Send(body)
+	})
+
+	if r.environment != "production" {
+		v1.Get("/docs", func(c *fiber.Ctx) error {
+			c.Type("html")
+			return c.SendString(swaggerUI)
+		})
+	}
+	return spec
+}
+
+// checkDocs logs every /api/v1 route the spec does not document, other than
+// the documentation routes themselves, so new routes are not left out of
+// generated SDKs
+func checkDocs(app *fiber.App, spec *openapi.Document) {
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		if route.Path == "/api/v1/openapi.json" || route.Path == "/api/v1/docs" {
+			continue
+		}
+		if !spec.Has(route.Method, route.Path) {
+			log.Printf("OpenAPI spec does not document %s %s", route.Method, route.Path)
+		}
+	}
+}
+
+// withPage documents the limit and offset parameters read by
+// parsePageRequest, and cursor on endpoints with keyset pagination
+func withPage(op *openapi.Operation, cursor bool) *openapi.Operation {
+	op.Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip")
+	if cursor {
+		op.Query("cursor", "string", "Opaque next_cursor from the previous page")
+	}
+	return op
+}
+
+// tierFields describes a tier rendered by tierList
+var tierFields = openapi.Fields{
+	"id":                  "",
+	"name":                "",
+	"description":         "",
+	"price_monthly_cents": (*int)(nil),
+	"credits_per_period":  int64(0),
+	"features":            []string{},
+}
+
+// apiSpec describes every /api/v1 route. Keep it in step with Setup; routes
+// missing from it are logged at startup.
+func apiSpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "Synoffice API",
+		Version: "1.0.0",
+		Description: "Errors are reported as {code, message, details}; clients should branch on code. " +
+			"Authenticated requests are rate limited per office by subscription tier, and the unauthenticated auth endpoints per client; " +
+			"limits are reported in X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset, and exceeding one returns 429 with Retry-After.",
+	})
+	doc.AddSecurityScheme(jwtAuth, &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	doc.AddSecurityScheme(apiKeyAuth, &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "X-API-Key",
+		Description: "Office API key; read keys may only make GET requests",
+	})
+	doc.AddSecurityScheme(internalAuth, &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "X-Internal-API-Key",
+		Description: "Service-to-service key shared with the agent orchestrator",
+	})
+	doc.SetErrorBody(ErrorResponse{})
+
+	// authed starts an operation that requires a user session or API key;
+	// session starts one that API keys cannot call
+	authed := func(id, tag, summary string) *openapi.Operation {
+		return openapi.Op(id, tag, summary).Secured(jwtAuth).Secured(apiKeyAuth)
+	}
+	session := func(id, tag, summary string) *openapi.Operation {
+		return openapi.Op(id, tag, summary).Secured(jwtAuth)
+	}
+	internal := func(id, summary string) *openapi.Operation {
+		return openapi.Op(id, "Internal", summary).Secured(internalAuth)
+	}
+	idempotent := "Replaying a request with the same key returns the original result instead of repeating it"
+
+	// Auth
+	doc.Add("POST", "/api/v1/auth/register", openapi.Op("register", "Auth", "Create a user and their office").
+		Body(RegisterRequest{}).Returns(fiber.StatusCreated, service.AuthResponse{}))
+	doc.Add("POST", "/api/v1/auth/login", openapi.Op("login", "Auth", "Log in with email and password").
+		Body(LoginRequest{}).Returns(fiber.StatusOK, service.AuthResponse{}))
+	doc.Add("POST", "/api/v1/auth/forgot-password", openapi.Op("forgotPassword", "Auth", "Email a password reset link").
+		Describe("Responds the same way whether or not the address is registered.").
+		Body(ForgotPasswordRequest{}).Returns(fiber.StatusAccepted, openapi.Fields{"message": ""}))
+	doc.Add("POST", "/api/v1/auth/reset-password", openapi.Op("resetPassword", "Auth", "Set a new password with a reset token").
+		Describe("Ends the user's sessions: tokens issued before the reset are refused.").
+		Body(ResetPasswordRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/auth/verify-email", openapi.Op("verifyEmail", "Auth", "Confirm an email address with a verification token").
+		Describe("A pending email becomes the user's email; 409 if another account has taken the address meanwhile.").
+		Body(VerifyEmailRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("GET", "/api/v1/auth/oauth", openapi.Op("listOAuthProviders", "Auth", "List the configured OAuth providers").
+		Returns(fiber.StatusOK, openapi.Fields{"providers": []string{}}))
+	doc.Add("GET", "/api/v1/auth/oauth/:provider", openapi.Op("startOAuth", "Auth", "Start signing in with an OAuth provider").
+		Describe("Redirects the browser to the provider (google or github) to approve the sign-in.").
+		Returns(fiber.StatusFound, nil))
+	doc.Add("GET", "/api/v1/auth/oauth/:provider/callback", openapi.Op("completeOAuth", "Auth", "Complete an OAuth sign-in").
+		Describe("Called by the provider. Redirects to APP_URL/oauth/callback with #token=<jwt> on success or #error=<code> "+
+			"(access_denied, invalid_state, email_not_verified, account_not_verified, oauth_failed, server_error). "+
+			"A provider account is linked to the user with the same verified email, or a new account is created. "+
+			"An existing account with the email that is not verified yet is not linked: account_not_verified.").
+		Query("code", "string", "Authorization code from the provider").
+		Query("state", "string", "State issued when the sign-in started").
+		Returns(fiber.StatusFound, nil))
+	doc.Add("GET", "/api/v1/auth/me", authed("getCurrentUser", "Auth", "Get the authenticated user").
+		Returns(fiber.StatusOK, openapi.Fields{"user_id": uuid.UUID{}, "office_id": uuid.UUID{}, "email": ""}))
+	doc.Add("PUT", "/api/v1/auth/me", session("updateProfile", "Auth", "Update the user's name or email").
+		Describe("A new email is kept as pending_email and a verification link is sent to it; the current email stays in use "+
+			"until the link is followed. Sending the current email again drops the pending one.").
+		Body(UpdateProfileRequest{}).Returns(fiber.StatusOK, domain.User{}))
+	doc.Add("DELETE", "/api/v1/auth/me", session("deleteAccount", "Auth", "Delete the user's account").
+		Describe("Erases the user's details and sign-in methods, revokes their API keys, pauses their offices' schedules "+
+			"and stops their subscriptions renewing. Its tokens are refused from then on. Billing and marketplace records are kept.").
+		Body(DeleteAccountRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/auth/me/data-export", session("requestDataExport", "Auth", "Request an export of all of the user's data").
+		Describe("Prepares a ZIP archive of JSON files in the background: the profile, the user's offices, and each office's "+
+			"conversation transcripts, tasks and credit transactions. The user is emailed when it is ready; it can be downloaded "+
+			"for 7 days. Only one export is prepared at a time (409 otherwise).").
+		Returns(fiber.StatusAccepted, domain.DataExport{}))
+	doc.Add("GET", "/api/v1/auth/me/data-exports", session("listDataExports", "Auth", "List the user's recent data exports").
+		Returns(fiber.StatusOK, openapi.Fields{"exports": []domain.DataExport{}}))
+	doc.Add("GET", "/api/v1/auth/me/data-exports/:id/download", session("downloadDataExport", "Auth", "Download a ready data export").
+		Describe("Streams the ZIP archive; exports that are not ready or have expired are not found.").
+		Returns(fiber.StatusOK, nil))
+	doc.Add("POST", "/api/v1/auth/me/erase", session("eraseAccount", "Auth", "Erase the user's account and data").
+		Describe("Closes the account like deleteAccount and erases everything the user stored: conversations, messages and "+
+			"attachments, memories, documents, notifications, reviews and data exports. Task inputs and outputs and credit "+
+			"transaction descriptions are cleared, and the offices are deleted and renamed. Credit ledgers, task charges, "+
+			"invoices and marketplace earnings are kept for accounting, referring to the anonymized account.").
+		Body(EraseAccountRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("PUT", "/api/v1/auth/password", session("changePassword", "Auth", "Change the user's password").
+		Describe("Ends the user's sessions, this one included: tokens issued before the change are refused.").
+		Body(ChangePasswordRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/auth/switch-office", session("switchOffice", "Auth", "Sign in to another of the user's offices").
+		Describe("Returns a token for office_id, which must be one of the user's offices that is not deleted. Sessions start "+
+			"in the user's oldest office.").
+		Body(SwitchOfficeRequest{}).Returns(fiber.StatusOK, service.AuthResponse{}))
+	doc.Add("POST", "/api/v1/auth/resend-verification", session("resendVerification", "Auth", "Email a new verification link").
+		Describe("Sent to the pending email if there is one. 409 if the email is verified and none is pending.").
+		Returns(fiber.StatusAccepted, nil))
+
+	// Marketplace browsing
+	doc.Add("GET", "/api/v1/marketplace/agents", openapi.Op("listMarketplaceAgents", "Marketplace", "List marketplace templates").
+		Query("category", "string", "Filter by category").
+		Query("categories", "string", "Comma-separated categories").
+		Query("search", "string", "Full-text search").
+		Query("author_id", "string", "Filter by author").
+		Query("min_price", "integer", "Minimum price in cents").
+		Query("max_price", "integer", "Maximum price in cents").
+		Query("min_rating", "number", "Minimum average rating").
+		Query("featured", "boolean", "Only featured templates").
+		Query("premium", "boolean", "Only premium templates").
+		Query("sort", "string", "Sort order").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"agents": []domain.AgentTemplate{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/marketplace/agents/:id", openapi.Op("getMarketplaceAgent", "Marketplace", "Get a marketplace template").
+		Describe("license is the personal or team license the template is sold under, whether it prohibits resale and the author's terms.").
+		Returns(fiber.StatusOK, domain.AgentTemplate{}))
+	doc.Add("GET", "/api/v1/marketplace/agents/:id/reviews", withPage(openapi.Op("listReviews", "Marketplace", "List a template's reviews"), false).
+		Returns(fiber.StatusOK, Page[domain.AgentReview]{}))
+	doc.Add("GET", "/api/v1/marketplace/agents/:id/versions", openapi.Op("listTemplateVersions", "Marketplace", "List a template's published versions").
+		Returns(fiber.StatusOK, openapi.Fields{"versions": []*domain.TemplateVersion{}}))
+	doc.Add("GET", "/api/v1/marketplace/featured", openapi.Op("listFeaturedAgents", "Marketplace", "List featured templates and bundles").
+		Returns(fiber.StatusOK, openapi.Fields{"agents": []domain.AgentTemplate{}, "bundles": []domain.TemplateBundle{}}))
+	doc.Add("GET", "/api/v1/marketplace/categories", openapi.Op("listCategories", "Marketplace", "List template categories").
+		Returns(fiber.StatusOK, openapi.Fields{"categories": []domain.AgentCategory{}}))
+	doc.Add("GET", "/api/v1/marketplace/search", openapi.Op("searchMarketplaceAgents", "Marketplace", "Search templates").
+		Describe("Bundles match on their name, description and templates' names; limit applies to templates and bundles separately.").
+		Query("q", "string", "Search query").
+		Query("limit", "integer", "Maximum number of items to return").
+		Returns(fiber.StatusOK, openapi.Fields{"agents": []domain.AgentTemplate{}, "bundles": []domain.TemplateBundle{}}))
+	doc.Add("GET", "/api/v1/marketplace/bundles", openapi.Op("listBundles", "Marketplace", "List template bundles on sale").
+		Query("search", "string", "Match the bundle's name, description or templates' names").
+		Query("author_id", "string", "Filter by author").
+		Query("featured", "boolean", "Only featured bundles").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"bundles": []domain.TemplateBundle{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/marketplace/bundles/:id", openapi.Op("getBundle", "Marketplace", "Get a template bundle with its templates").
+		Returns(fiber.StatusOK, domain.TemplateBundle{}))
+	doc.Add("GET", "/api/v1/marketplace/authors/:id", openapi.Op("getAuthorProfile", "Marketplace", "Get an author's public profile").
+		Describe("Shows the author's bio, links and verified badge, the downloads and ratings of their approved public "+
+			"templates, and the templates and bundles they have on sale. Users with nothing approved are not found.").
+		Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("POST", "/api/v1/marketplace/agents/:id/view", openapi.Op("recordTemplateView", "Marketplace", "Count a visitor opening a template's details").
+		Describe("Each visitor counts once a day: the signed in user when a bearer token is sent, else the X-Session-ID, "+
+			"else the client's address and browser. Templates that are not approved are ignored.").
+		Header("X-Session-ID", "An ID the client keeps for the browser session").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/marketplace/impressions", openapi.Op("recordTemplateImpressions", "Marketplace", "Count a visitor being shown templates in results").
+		Describe("Visitors are identified and counted as for views.").
+		Header("X-Session-ID", "An ID the client keeps for the browser session").
+		Body(ImpressionsRequest{}).Returns(fiber.StatusNoContent, nil))
+
+	// Marketplace reviews, purchases and authoring
+	doc.Add("POST", "/api/v1/marketplace/agents/:id/reviews", authed("createReview", "Marketplace", "Review a template").
+		Body(ReviewRequest{}).Returns(fiber.StatusCreated, openapi.Fields{"message": ""}))
+	doc.Add("PUT", "/api/v1/marketplace/agents/:id/reviews/:reviewId", authed("updateReview", "Marketplace", "Update your review").
+		Body(ReviewRequest{}).Returns(fiber.StatusOK, domain.AgentReview{}))
+	doc.Add("DELETE", "/api/v1/marketplace/agents/:id/reviews/:reviewId", authed("deleteReview", "Marketplace", "Delete your review").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/marketplace/agents/:id/reviews/:reviewId/vote", authed("voteReview", "Marketplace", "Vote on whether a review is helpful").
+		Body(ReviewVoteRequest{}).Returns(fiber.StatusOK, openapi.Fields{"message": ""}))
+	doc.Add("DELETE", "/api/v1/marketplace/agents/:id/reviews/:reviewId/vote", authed("removeReviewVote", "Marketplace", "Remove your vote on a review").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/marketplace/agents/:id/reviews/:reviewId/reply", authed("replyToReview", "Marketplace", "Reply to a review of your template").
+		Body(ReviewReplyRequest{}).Returns(fiber.StatusCreated, domain.ReviewReply{}))
+	doc.Add("PUT", "/api/v1/marketplace/agents/:id/reviews/:reviewId/reply", authed("updateReviewReply", "Marketplace", "Update your reply to a review").
+		Body(ReviewReplyRequest{}).Returns(fiber.StatusOK, domain.ReviewReply{}))
+	doc.Add("POST", "/api/v1/marketplace/agents/:id/favorite", authed("addFavorite", "Marketplace", "Save a template to your favorites").
+		Describe("With notify set, you are told when the template, if premium, goes on sale or publishes a new version. "+
+			"Favoriting a template again changes notify. Templates that are not approved and public are not found.").
+		Body(FavoriteRequest{}).Returns(fiber.StatusCreated, domain.TemplateFavorite{}))
+	doc.Add("DELETE", "/api/v1/marketplace/agents/:id/favorite", authed("removeFavorite", "Marketplace", "Remove a template from your favorites").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("GET", "/api/v1/marketplace/favorites", authed("listFavorites", "Marketplace", "List your favorite templates, newest first").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"favorites": []domain.TemplateFavorite{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/marketplace/purchase", authed("purchaseTemplate", "Marketplace", "Purchase a premium template").
+		Describe("Buying accepts the template's license, which the purchase records. Templates with a personal license "+
+			"return 403 license_required to offices whose tier has more than 1 seat.").
+		Header("Idempotency-Key", idempotent).
+		Body(PurchaseTemplateRequest{}).Returns(fiber.StatusOK, openapi.Fields{"success": true, "earning_id": uuid.UUID{}}))
+	doc.Add("POST", "/api/v1/marketplace/purchase/credits", authed("purchaseTemplateWithCredits", "Marketplace", "Purchase a premium template with credits").
+		Describe("Consumes the template's price in credits from the office's wallet and records the sale for the author in cents. "+
+			"Returns 402 if the wallet cannot cover the price and 400 if templates cannot be bought with credits.").
+		Header("Idempotency-Key", idempotent).
+		Body(PurchaseWithCreditsRequest{}).Returns(fiber.StatusOK, domain.TemplatePurchase{}))
+	doc.Add("POST", "/api/v1/marketplace/purchase/bundle", authed("purchaseBundle", "Marketplace", "Purchase every template of a bundle").
+		Describe("The bundle's price is split between its templates in proportion to their own prices, and each share is recorded "+
+			"as a sale of that template. Returns 409 if the office already owns one of the templates, and 403 license_required "+
+			"if one of their licenses does not cover the office. Templates bought in a bundle cannot be refunded one at a time.").
+		Header("Idempotency-Key", idempotent).
+		Body(PurchaseBundleRequest{}).Returns(fiber.StatusOK, openapi.Fields{"success": true, "bundle_id": uuid.UUID{}, "purchases": []*domain.TemplatePurchase{}}))
+	doc.Add("GET", "/api/v1/marketplace/agents/:id/credit-price", authed("getCreditPrice", "Marketplace", "Get what a premium template costs in credits").
+		Returns(fiber.StatusOK, service.CreditPrice{}))
+	doc.Add("GET", "/api/v1/marketplace/purchases", authed("listPurchases", "Marketplace", "List the office's template purchases").
+		Describe("Each purchase's license is the license and template version the office accepted when it bought the template; "+
+			"purchases made before templates were licensed have none.").
+		Returns(fiber.StatusOK, openapi.Fields{"purchases": []*domain.TemplatePurchase{}, "count": 0}))
+	doc.Add("POST", "/api/v1/marketplace/purchases/:id/refund-request", authed("requestRefund", "Marketplace", "Ask for a template purchase to be refunded").
+		Describe("An admin approves or rejects the request. A purchase has at most one open request; another returns 409.").
+		Body(RefundRequestBody{}).Returns(fiber.StatusCreated, domain.RefundRequest{}))
+	doc.Add("GET", "/api/v1/marketplace/refund-requests", authed("listRefundRequests", "Marketplace", "List the office's refund requests, newest first").
+		Returns(fiber.StatusOK, openapi.Fields{"refund_requests": []*domain.RefundRequest{}, "count": 0}))
+	doc.Add("POST", "/api/v1/marketplace/templates", authed("submitTemplate", "Marketplace", "Submit a template for moderation").
+		Describe("The template is scanned for prompt injection: risk_score runs from 0 to 100 and risk_findings lists the rules it matched. With TEMPLATE_AUTO_APPROVE, templates scoring at most TEMPLATE_RISK_THRESHOLD are approved right away. "+
+			"license takes a type of personal or team, the default, resale_prohibited and terms. A system prompt copying another "+
+			"author's resale-prohibited template is refused with 403 license_required.").
+		Body(service.TemplateInput{}).Returns(fiber.StatusCreated, domain.AgentTemplate{}))
+	doc.Add("PUT", "/api/v1/marketplace/templates/:id", authed("updateMarketplaceTemplate", "Marketplace", "Update your template").
+		Body(service.TemplateInput{}).Returns(fiber.StatusOK, domain.AgentTemplate{}))
+	doc.Add("POST", "/api/v1/marketplace/templates/:id/versions", authed("publishTemplateVersion", "Marketplace", "Publish a new version of your template").
+		Describe("The version is staged with status pending until an admin approves it, and the approved version stays "+
+			"listed and hireable meanwhile; versions approved by TEMPLATE_AUTO_APPROVE go live right away. 409 if a version "+
+			"is already awaiting review. A license given applies from the new version on; each version keeps the license it was published with.").
+		Body(service.PublishVersionInput{}).Returns(fiber.StatusCreated, domain.TemplateVersion{}))
+	doc.Add("POST", "/api/v1/marketplace/bundles", authed("createBundle", "Marketplace", "Sell several of your templates as a bundle").
+		Describe("A bundle holds 2 to 10 of your approved premium templates and must cost less than they do separately. "+
+			"It is listed while it is public and that stays true; bundles flagged by content moderation are saved unlisted.").
+		Body(service.BundleInput{}).Returns(fiber.StatusCreated, domain.TemplateBundle{}))
+	doc.Add("PUT", "/api/v1/marketplace/bundles/:id", authed("updateBundle", "Marketplace", "Update your bundle").
+		Body(service.BundleInput{}).Returns(fiber.StatusOK, domain.TemplateBundle{}))
+
+	// Offices
+	doc.Add("GET", "/api/v1/offices", authed("listOffices", "Offices", "List the user's offices").
+		Returns(fiber.StatusOK, openapi.Fields{"offices": []domain.Office{}}))
+	doc.Add("PUT", "/api/v1/offices/:id", authed("updateOffice", "Offices", "Rename one of the user's offices or change its timezone").
+		Describe("Usage analytics count days in the office's timezone. A new timezone applies to usage recorded from then on; "+
+			"earlier days keep their dates.").
+		Body(UpdateOfficeRequest{}).Returns(fiber.StatusOK, domain.Office{}))
+	doc.Add("DELETE", "/api/v1/offices/:id", session("deleteOffice", "Offices", "Delete one of the user's offices").
+		Describe("The office the session is signed in to, and the user's only office, cannot be deleted. "+
+			"The office's schedules are paused, its API keys and widget tokens revoked and its subscription is not renewed; "+
+			"its wallet, invoices and audit log are kept. It can be restored for 30 days.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/offices/:id/restore", session("restoreOffice", "Offices", "Restore an office deleted in the last 30 days").
+		Describe("Paused schedules, revoked API keys and widget tokens and the subscription renewal stay off.").
+		Returns(fiber.StatusOK, domain.Office{}))
+	doc.Add("GET", "/api/v1/offices/:id/export", session("exportOffice", "Offices", "Download one of the user's offices as a JSON archive").
+		Describe("The archive holds the office's agents with their skills and memories, its conversations with their "+
+			"undeleted messages, and its model policies and web research settings. Attachments, documents and the "+
+			"knowledge base are left out.").
+		Returns(fiber.StatusOK, domain.OfficeArchive{}))
+	doc.Add("POST", "/api/v1/offices/import", session("importOffice", "Offices", "Recreate an exported office as a new office").
+		Describe("Everything in the archive gets a new ID, and the user's messages are attributed to the importing user. "+
+			"The new office starts on the free tier: fails with 403 tier_limit_exceeded when the archive's agents do not fit it. "+
+			"Agents whose template is not installed are hired from the built-in template with the same role; agents with "+
+			"neither, or with a premium template, are skipped, and custom prompts the tier does not include are dropped. "+
+			"Sign in to the new office with switchOffice.").
+		Body(domain.OfficeArchive{}).Returns(fiber.StatusCreated, service.ImportOfficeResult{}))
+
+	// Agents
+	doc.Add("GET", "/api/v1/agents/templates", authed("listAgentTemplates", "Agents", "List templates available to hire").
+		Returns(fiber.StatusOK, openapi.Fields{"templates": []*domain.AgentTemplate{}}))
+	doc.Add("POST", "/api/v1/agents/select", authed("selectAgent", "Agents", "Hire an agent from a template").
+		Describe("Fails with 403 tier_limit_exceeded, with the limit in details, once the office has as many agents as its tier allows, "+
+			"and with 403 license_required for templates with a personal license in offices whose tier has more than 1 seat.").
+		Body(SelectAgentRequest{}).Returns(fiber.StatusCreated, domain.Agent{}))
+	doc.Add("POST", "/api/v1/agents/select-multiple", authed("selectMultipleAgents", "Agents", "Hire several agents").
+		Describe("Give template_ids, or agents to set custom names. Templates the office already has an agent from are "+
+			"skipped and listed in skipped. Adds all of the other agents or, if any template is unavailable or the "+
+			"tier limit would be exceeded, none.").
+		Body(SelectMultipleAgentsRequest{}).Returns(fiber.StatusCreated, service.SelectMultipleAgentsResult{}))
+	doc.Add("GET", "/api/v1/agents", authed("listAgents", "Agents", "List the office's agents").
+		Returns(fiber.StatusOK, openapi.Fields{"agents": []*domain.Agent{}}))
+	doc.Add("GET", "/api/v1/agents/performance", authed("getAgentLeaderboard", "Agents", "Rank the office's agents by performance").
+		Describe("Scores each agent from 0 to 100 over the last days days: 35% feedback, 35% task success, 15% latency "+
+			"and 15% credits per task, with the change since the window before. Agents that finished no tasks are not "+
+			"ranked. Requires a tier that includes analytics.").
+		Query("days", "integer", "Length of the window in days, at most 90").
+		Returns(fiber.StatusOK, domain.AgentLeaderboard{}))
+	doc.Add("GET", "/api/v1/agents/:id", authed("getAgent", "Agents", "Get an agent").
+		Returns(fiber.StatusOK, domain.Agent{}))
+	doc.Add("POST", "/api/v1/agents/:id/update-template", authed("upgradeAgentTemplate", "Agents", "Move an agent to its template's latest version").
+		Returns(fiber.StatusOK, domain.Agent{}))
+	doc.Add("PUT", "/api/v1/agents/:id", authed("updateAgent", "Agents", "Customize an agent's name, system prompt and avatar").
+		Describe("Omitted fields are left unchanged; an empty string reverts to the template's value. "+
+			"Custom system prompts require a tier with the custom_prompts feature, and content moderation may refuse them with content_blocked (422).").
+		Body(UpdateAgentRequest{}).Returns(fiber.StatusOK, domain.Agent{}))
+	doc.Add("GET", "/api/v1/agents/:id/changes", authed("listAgentChanges", "Agents", "List an agent's customization history").
+		Returns(fiber.StatusOK, openapi.Fields{"changes": []*domain.AgentChange{}}))
+	doc.Add("GET", "/api/v1/agents/:id/model-policy", authed("getAgentModelPolicy", "Model Policies", "Get an agent's own model policy").
+		Returns(fiber.StatusOK, domain.ModelPolicy{}))
+	doc.Add("PUT", "/api/v1/agents/:id/model-policy", authed("setAgentModelPolicy", "Model Policies", "Set an agent's own model policy").
+		Describe("The agent's policy replaces the office's for its tasks. Providers must be included in the office's tier.").
+		Body(SetModelPolicyRequest{}).Returns(fiber.StatusOK, domain.ModelPolicy{}))
+	doc.Add("DELETE", "/api/v1/agents/:id/model-policy", authed("deleteAgentModelPolicy", "Model Policies", "Remove an agent's own model policy").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("GET", "/api/v1/agents/:id/skills", authed("getAgentSkills", "Skills", "List the skills catalog as configured for an agent").
+		Describe("The agent uses the skills that are both enabled and available on the office's tier; the orchestrator only "+
+			"permits their tools. Skills never configured for the agent keep their default, and unavailable ones name the "+
+			"cheapest tier that includes them.").
+		Returns(fiber.StatusOK, openapi.Fields{"skills": []*domain.AgentSkill{}}))
+	doc.Add("PUT", "/api/v1/agents/:id/skills", authed("updateAgentSkills", "Skills", "Enable or disable skills for an agent").
+		Describe("Skills are given by key; those left out keep their configuration. Enabling a skill the office's tier does "+
+			"not include returns 402 upgrade_required with the tier that does; disabling is always allowed.").
+		Body(UpdateAgentSkillsRequest{}).Returns(fiber.StatusOK, openapi.Fields{"skills": []*domain.AgentSkill{}}))
+	doc.Add("PUT", "/api/v1/agents/:id/manager", authed("setAgentManager", "Departments", "Set the agent an agent reports to").
+		Describe("The manager must be another agent of the office that does not already report to this one, directly or "+
+			"indirectly. A null manager_id removes the agent's reporting line.").
+		Body(SetManagerRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("DELETE", "/api/v1/agents/:id", authed("deleteAgent", "Agents", "Delete an agent").
+		Describe("The agent stops running, leaves its conversations and its schedules are paused. "+
+			"It can be restored for 30 days, after which data retention purges it.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/agents/:id/restore", authed("restoreAgent", "Agents", "Restore an agent deleted in the last 30 days").
+		Describe("The agent rejoins its conversations; its schedules stay paused. "+
+			"It must fit the tier's agent limit.").
+		Returns(fiber.StatusOK, domain.Agent{}))
+	doc.Add("GET", "/api/v1/agents/:id/feedback-summary", authed("getAgentFeedbackSummary", "Agents", "Summarise feedback on an agent").
+		Returns(fiber.StatusOK, service.FeedbackSummary{}))
+	doc.Add("GET", "/api/v1/agents/:id/learning-stats", authed("getAgentLearningStats", "Agents", "Get an agent's learning statistics").
+		Describe("Memory counts leave out memories the daily consolidation merged into a near-duplicate or archived past MEMORY_MAX_PER_AGENT, which are counted separately.").
+		Returns(fiber.StatusOK, domain.AgentLearningStats{}))
+
+	// Onboarding
+	doc.Add("GET", "/api/v1/onboarding/presets", authed("listOnboardingPresets", "Onboarding", "List the starter teams offices can hire").
+		Returns(fiber.StatusOK, openapi.Fields{"presets": []domain.OnboardingPreset{}}))
+	doc.Add("POST", "/api/v1/onboarding/apply", authed("applyOnboardingPreset", "Onboarding", "Hire a starter team").
+		Describe("Hires the preset's members, starts a group conversation with the team and posts the welcome message from "+
+			"its first member, all or nothing. Members whose template the office already has an agent from are not hired "+
+			"again, and that agent joins the conversation. The new agents must fit the tier's agent limit.").
+		Body(ApplyPresetRequest{}).Returns(fiber.StatusCreated, service.OnboardingResult{}))
+
+	// Agent memories
+	doc.Add("GET", "/api/v1/agents/:id/memories", authed("listAgentMemories", "Memories", "List an agent's memories").
+		Query("type", "string", "Filter by memory type").
+		Query("limit", "integer", "Maximum number of items to return").
+		Returns(fiber.StatusOK, openapi.Fields{"memories": []*domain.AgentMemory{}, "count": 0}))
+	doc.Add("GET", "/api/v1/agents/:id/memories/search", authed("searchAgentMemories", "Memories", "Search an agent's memories").
+		Describe("Returns the memories closest in meaning to the query, most similar first, among those the orchestrator has "+
+			"embedded. Without an embeddings API configured, returns the memories whose key or value contains the query.").
+		Query("q", "string", "What to search for").
+		Query("limit", "integer", "Maximum number of memories to return, at most 50").
+		Returns(fiber.StatusOK, openapi.Fields{"memories": []*domain.MemoryMatch{}}))
+	doc.Add("POST", "/api/v1/agents/:id/memories", authed("createAgentMemory", "Memories", "Add a memory to an agent").
+		Body(CreateMemoryRequest{}).Returns(fiber.StatusCreated, domain.AgentMemory{}))
+	doc.Add("PUT", "/api/v1/agents/:id/memories/:memoryId", authed("updateAgentMemory", "Memories", "Update an agent memory").
+		Body(UpdateMemoryRequest{}).Returns(fiber.StatusOK, domain.AgentMemory{}))
+	doc.Add("DELETE", "/api/v1/agents/:id/memories/:memoryId", authed("deleteAgentMemory", "Memories", "Delete an agent memory").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Schedules
+	doc.Add("GET", "/api/v1/agents/:id/schedules", authed("listSchedules", "Schedules", "List an agent's scheduled tasks").
+		Returns(fiber.StatusOK, []*domain.ScheduledTask{}))
+	doc.Add("POST", "/api/v1/agents/:id/schedules", authed("createSchedule", "Schedules", "Schedule a one-off or recurring task").
+		Body(CreateScheduleRequest{}).Returns(fiber.StatusCreated, domain.ScheduledTask{}))
+	doc.Add("GET", "/api/v1/agents/:id/schedules/:scheduleId", authed("getSchedule", "Schedules", "Get a scheduled task").
+		Returns(fiber.StatusOK, domain.ScheduledTask{}))
+	doc.Add("PUT", "/api/v1/agents/:id/schedules/:scheduleId", authed("updateSchedule", "Schedules", "Update a scheduled task").
+		Body(UpdateScheduleRequest{}).Returns(fiber.StatusOK, domain.ScheduledTask{}))
+	doc.Add("DELETE", "/api/v1/agents/:id/schedules/:scheduleId", authed("deleteSchedule", "Schedules", "Delete a scheduled task").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("GET", "/api/v1/agents/:id/schedules/:scheduleId/runs", withPage(authed("listScheduleRuns", "Schedules", "List a scheduled task's runs"), false).
+		Returns(fiber.StatusOK, Page[*domain.ScheduledTaskRun]{}))
+
+	// Task templates
+	doc.Add("POST", "/api/v1/task-templates", authed("createTaskTemplate", "Task Templates", "Create a task template").
+		Describe("The body marks placeholders as {{name}}; defaults fill in placeholders given no value.").
+		Body(CreateTaskTemplateRequest{}).Returns(fiber.StatusCreated, domain.TaskTemplate{}))
+	doc.Add("GET", "/api/v1/task-templates", authed("listTaskTemplates", "Task Templates", "List the office's task templates").
+		Returns(fiber.StatusOK, openapi.Fields{"templates": []*domain.TaskTemplate{}}))
+	doc.Add("GET", "/api/v1/task-templates/:id", authed("getTaskTemplate", "Task Templates", "Get a task template with its placeholders").
+		Returns(fiber.StatusOK, domain.TaskTemplate{}))
+	doc.Add("PUT", "/api/v1/task-templates/:id", authed("updateTaskTemplate", "Task Templates", "Update a task template").
+		Describe("Schedules filled in from the template keep their input.").
+		Body(UpdateTaskTemplateRequest{}).Returns(fiber.StatusOK, domain.TaskTemplate{}))
+	doc.Add("DELETE", "/api/v1/task-templates/:id", authed("deleteTaskTemplate", "Task Templates", "Delete a task template").
+		Describe("Schedules filled in from the template keep running.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/task-templates/:id/run", authed("runTaskTemplate", "Task Templates", "Fill in a task template and dispatch it to an agent").
+		Describe("Every placeholder needs a value or a default. The agent answers in the conversation, which it must take part in.").
+		Body(RunTaskTemplateRequest{}).Returns(fiber.StatusCreated, domain.Task{}))
+	doc.Add("POST", "/api/v1/task-templates/:id/schedules", authed("scheduleTaskTemplate", "Task Templates", "Fill in a task template and schedule it for an agent").
+		Describe("The schedule is named after the template unless named otherwise, and keeps the input it was filled in with.").
+		Body(ScheduleTaskTemplateRequest{}).Returns(fiber.StatusCreated, domain.ScheduledTask{}))
+	doc.Add("GET", "/api/v1/task-templates/:id/schedules", authed("listTaskTemplateSchedules", "Task Templates", "List the schedules filled in from a task template").
+		Returns(fiber.StatusOK, openapi.Fields{"schedules": []*domain.ScheduledTask{}}))
+
+	// Project boards
+	doc.Add("POST", "/api/v1/projects", authed("createProject", "Projects", "Create a project with its board").
+		Describe("columns names the board's first columns; without any it gets To do, In progress and Done.").
+		Body(CreateProjectRequest{}).Returns(fiber.StatusCreated, domain.Project{}))
+	doc.Add("GET", "/api/v1/projects", authed("listProjects", "Projects", "List the office's projects").
+		Returns(fiber.StatusOK, openapi.Fields{"projects": []*domain.Project{}}))
+	doc.Add("GET", "/api/v1/projects/:id", authed("getProjectBoard", "Projects", "Get a project's board").
+		Describe("Columns and their cards are in order, each card with a summary of its task.").
+		Returns(fiber.StatusOK, domain.Project{}))
+	doc.Add("PUT", "/api/v1/projects/:id", authed("updateProject", "Projects", "Rename a project or change its description").
+		Body(UpdateProjectRequest{}).Returns(fiber.StatusOK, domain.Project{}))
+	doc.Add("DELETE", "/api/v1/projects/:id", authed("deleteProject", "Projects", "Delete a project with its board").
+		Describe("The tasks on the board are kept.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/projects/:id/columns", authed("createBoardColumn", "Projects", "Add a column at the end of a board").
+		Describe("A board has at most 20 columns.").
+		Body(CreateBoardColumnRequest{}).Returns(fiber.StatusCreated, domain.BoardColumn{}))
+	doc.Add("PUT", "/api/v1/projects/:id/columns/:columnId", authed("updateBoardColumn", "Projects", "Rename or move a board column").
+		Describe("Positions count from 0; a position past the last column moves it to the end.").
+		Body(UpdateBoardColumnRequest{}).Returns(fiber.StatusOK, domain.BoardColumn{}))
+	doc.Add("DELETE", "/api/v1/projects/:id/columns/:columnId", authed("deleteBoardColumn", "Projects", "Delete a board column with its cards").
+		Describe("The tasks on its cards are kept.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/projects/:id/cards", authed("addBoardCard", "Projects", "Place a task on a board").
+		Describe("A task is a card on one board at most; placing it again is a conflict. Without a position the card goes last in its column.").
+		Body(AddBoardCardRequest{}).Returns(fiber.StatusCreated, domain.BoardCard{}))
+	doc.Add("PUT", "/api/v1/projects/:id/cards/:taskId", authed("moveBoardCard", "Projects", "Move a card to a column and position").
+		Describe("Cards move between the columns of their board only. Without a position the card goes last in the column.").
+		Body(MoveBoardCardRequest{}).Returns(fiber.StatusOK, domain.BoardCard{}))
+	doc.Add("DELETE", "/api/v1/projects/:id/cards/:taskId", authed("removeBoardCard", "Projects", "Take a task off a board").
+		Describe("The task is kept.").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Search
+	doc.Add("GET", "/api/v1/search", withPage(authed("search", "Search", "Search the office's messages, task outputs and documents"), false).
+		Describe("q takes web search syntax: \"quoted phrases\", or, and -word to exclude. Results are ranked best first; "+
+			"each headline is HTML-escaped with the matched words in <mark> tags. "+
+			"Deleted messages and the messages of deleted conversations are left out.").
+		Query("q", "string", "What to search for, at most 200 characters").
+		Query("type", "string", "Comma-separated result types: message, task, document").
+		Query("agent_id", "string", "Only what the agent wrote").
+		Returns(fiber.StatusOK, Page[*domain.SearchResult]{}))
+
+	// Conversations and messages
+	doc.Add("POST", "/api/v1/conversations", authed("createConversation", "Conversations", "Start a conversation with agents").
+		Body(CreateConversationRequest{}).Returns(fiber.StatusCreated, domain.Conversation{}))
+	doc.Add("GET", "/api/v1/conversations", authed("listConversations", "Conversations", "List the office's conversations").
+		Describe("Each conversation has the caller's unread_count, not counting their own messages, and a preview of its last_message.").
+		Query("include_archived", "boolean", "Also list archived conversations").
+		Returns(fiber.StatusOK, openapi.Fields{"conversations": []*domain.Conversation{}}))
+	doc.Add("GET", "/api/v1/conversations/:id", authed("getConversation", "Conversations", "Get a conversation").
+		Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("PATCH", "/api/v1/conversations/:id", authed("updateConversation", "Conversations", "Rename or archive a conversation").
+		Describe("Agents do not respond to messages or run schedules in archived conversations.").
+		Body(UpdateConversationRequest{}).Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("DELETE", "/api/v1/conversations/:id", authed("deleteConversation", "Conversations", "Delete a conversation with its messages").
+		Describe("The conversation's schedules are paused. It can be restored for 30 days, "+
+			"after which data retention purges it with its messages.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/conversations/:id/restore", authed("restoreConversation", "Conversations", "Restore a conversation deleted in the last 30 days").
+		Describe("Its schedules stay paused.").
+		Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("POST", "/api/v1/conversations/:id/read", authed("markConversationRead", "Conversations", "Mark a conversation as read").
+		Describe("The body is optional; without message_id the conversation is read up to its latest message. "+
+			"The read position never moves back. Responds 204 when the conversation has no messages.").
+		Body(MarkReadRequest{}).Returns(fiber.StatusOK, domain.ConversationRead{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("PATCH", "/api/v1/conversations/:id/orchestration", authed("updateConversationOrchestration", "Conversations", "Set how a group conversation's agents take turns").
+		Describe("mentions: only @mentioned agents answer. round_robin: the agents answer in turn. all: every agent answers. "+
+			"moderator: moderator_id answers or passes messages on to the agents it @mentions. "+
+			"debate: the agents answer one after another for debate_rounds rounds. @mentioned agents always answer. "+
+			"Modes other than mentions require a tier with advanced orchestration; other offices get 402 upgrade_required.").
+		Body(UpdateOrchestrationRequest{}).Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("POST", "/api/v1/conversations/:id/participants", authed("addConversationParticipant", "Conversations", "Add an agent to a group conversation").
+		Body(AddParticipantRequest{}).Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("DELETE", "/api/v1/conversations/:id/participants/:agentId", authed("removeConversationParticipant", "Conversations", "Remove an agent from a group conversation").
+		Describe("The last participant cannot be removed.").
+		Returns(fiber.StatusOK, domain.Conversation{}))
+	doc.Add("POST", "/api/v1/conversations/:id/messages", authed("sendMessage", "Conversations", "Send a message to the conversation's agents").
+		Describe("Set parent_message_id to reply in a thread; agents answering a reply are given the thread as context. "+
+			"attachment_ids sends files uploaded with uploadAttachment, up to 10; content may then be empty. "+
+			"Content moderation may refuse the message with content_blocked (422).").
+		Body(SendMessageRequest{}).Returns(fiber.StatusCreated, domain.Message{}))
+	doc.Add("POST", "/api/v1/conversations/:id/attachments", authed("uploadAttachment", "Conversations", "Upload a file to send with a message").
+		Describe("The tier limits the size and type of each file. The file belongs to the next message that lists its ID in attachment_ids.").
+		FileUpload("file", "The file's contents; its name and Content-Type are kept").
+		Returns(fiber.StatusCreated, domain.Attachment{}))
+	doc.Add("GET", "/api/v1/conversations/:id/export", authed("exportConversation", "Conversations", "Export a conversation's transcript").
+		Describe("Downloads every message, oldest first, with sender names, timestamps, attachments and the agent tasks each message started. "+
+			"Markdown and PDF list tasks by status; JSON includes them in full. Exports stop at 10000 messages, setting truncated.").
+		Query("format", "string", "json (the default), markdown or pdf").
+		Returns(fiber.StatusOK, domain.ConversationTranscript{}))
+	doc.Add("POST", "/api/v1/conversations/:id/summarize", authed("summarizeConversation", "Conversations", "Have an agent summarize a conversation").
+		Describe("agent_id, optional, picks the participant to summarize; by default the first one does. The agent summarizes the messages "+
+			"since the previous summary together with it, and the run's task is charged like any other. Responds 202 with the pending run; "+
+			"while a run is pending, within SUMMARY_CACHE_WINDOW of a summary or when no messages were sent since, responds 200 with that run "+
+			"instead. The summary becomes the conversation's summary and is posted as a pinned system message, with a conversation_summarized event.").
+		Body(SummarizeRequest{}).
+		Returns(fiber.StatusAccepted, domain.ConversationSummary{}).
+		Returns(fiber.StatusOK, domain.ConversationSummary{}))
+	doc.Add("GET", "/api/v1/conversations/:id/summary", authed("getConversationSummary", "Conversations", "Get a conversation's latest summary run").
+		Describe("Responds 404 if the conversation was never summarized.").
+		Returns(fiber.StatusOK, domain.ConversationSummary{}))
+	doc.Add("GET", "/api/v1/attachments/:id", authed("getAttachmentURL", "Conversations", "Get a download URL for an attachment").
+		Describe("The URL needs no authentication and expires after 15 minutes.").
+		Returns(fiber.StatusOK, AttachmentURLResponse{}))
+	doc.Add("GET", "/api/v1/attachments/:id/content", openapi.Op("getAttachmentContent", "Conversations", "Download an attachment").
+		Describe("Served for storages without URLs of their own; use the URL from getAttachmentURL.").
+		Query("expires", "integer", "Unix time the URL expires at").
+		Query("signature", "string", "Signature of the URL").
+		Returns(fiber.StatusOK, nil))
+	doc.Add("GET", "/api/v1/conversations/:id/messages", withPage(authed("listMessages", "Conversations", "List a conversation's messages"), true).
+		Returns(fiber.StatusOK, Page[*domain.Message]{}))
+	doc.Add("GET", "/api/v1/messages/:id/thread", authed("getMessageThread", "Conversations", "Get a message's thread").
+		Describe("Returns the thread's first message and its replies, oldest first. For a reply, its whole thread is returned.").
+		Returns(fiber.StatusOK, openapi.Fields{"message": domain.Message{}, "replies": []*domain.Message{}}))
+	doc.Add("PATCH", "/api/v1/messages/:id", authed("editMessage", "Conversations", "Edit one of your messages").
+		Describe("The previous content is appended to metadata.edit_history. Clients are sent a message_edited event.").
+		Body(EditMessageRequest{}).Returns(fiber.StatusOK, domain.Message{}))
+	doc.Add("DELETE", "/api/v1/messages/:id", authed("deleteMessage", "Conversations", "Delete one of your or an agent's messages").
+		Describe("The message stays in the conversation with its content erased and deleted_at set. "+
+			"Clients are sent a message_deleted event.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/messages/:id/reactions", authed("addReaction", "Conversations", "React to a message with an emoji").
+		Describe("Clients are sent a reaction_added event.").
+		Body(AddReactionRequest{}).Returns(fiber.StatusOK, domain.Message{}))
+	doc.Add("DELETE", "/api/v1/messages/:id/reactions/:emoji", authed("removeReaction", "Conversations", "Remove your emoji reaction").
+		Describe("The emoji is URL-encoded in the path. Clients are sent a reaction_removed event.").
+		Returns(fiber.StatusOK, domain.Message{}))
+	doc.Add("POST", "/api/v1/messages/:id/feedback", authed("createMessageFeedback", "Conversations", "Give feedback on an agent message").
+		Body(CreateMessageFeedbackRequest{}).Returns(fiber.StatusCreated, domain.AgentFeedback{}))
+
+	// Documents
+	doc.Add("GET", "/api/v1/documents", withPage(authed("listDocuments", "Documents", "List the documents the office's agents produced"), true).
+		Describe("Documents are listed newest first, without their content.").
+		Query("conversation_id", "string", "Filter by conversation").
+		Query("agent_id", "string", "Filter by agent").
+		Returns(fiber.StatusOK, Page[*domain.Document]{}))
+	doc.Add("GET", "/api/v1/documents/:id", authed("getDocument", "Documents", "Get a document").
+		Describe("Returns the latest version's title and content, or those of an earlier version.").
+		Query("version", "integer", "Version to return instead of the latest").
+		Returns(fiber.StatusOK, domain.Document{}))
+	doc.Add("GET", "/api/v1/documents/:id/versions", authed("listDocumentVersions", "Documents", "List a document's versions").
+		Describe("Versions are listed newest first, without their content; get one with getDocument's version parameter.").
+		Returns(fiber.StatusOK, openapi.Fields{"versions": []*domain.DocumentVersion{}}))
+	doc.Add("GET", "/api/v1/documents/:id/download", authed("downloadDocument", "Documents", "Download a document").
+		Describe("Downloads the content as a .md, .txt, .html, .json or .csv file, named after the title.").
+		Query("version", "integer", "Version to download instead of the latest").
+		Returns(fiber.StatusOK, nil))
+
+	// Tasks
+	doc.Add("GET", "/api/v1/tasks", withPage(authed("listTasks", "Tasks", "List the office's agent tasks"), true).
+		Query("status", "string", "Filter by task status").
+		Query("agent_id", "string", "Filter by agent").
+		Query("conversation_id", "string", "Filter by conversation").
+		Returns(fiber.StatusOK, Page[*domain.Task]{}))
+	doc.Add("GET", "/api/v1/tasks/:id", authed("getTask", "Tasks", "Get a task").
+		Returns(fiber.StatusOK, domain.Task{}))
+	doc.Add("GET", "/api/v1/tasks/:id/subtasks", authed("getSubtasks", "Tasks", "Get a task's sub-task tree").
+		Describe("Agents delegate sub-tasks to the participants they @mention in a reply. "+
+			"Returns the task with its sub-tasks nested under subtasks, oldest first.").
+		Returns(fiber.StatusOK, domain.TaskTree{}))
+	doc.Add("DELETE", "/api/v1/tasks/:id", authed("deleteTask", "Tasks", "Cancel a task").
+		Describe("Same as POST /tasks/{id}/cancel.").
+		Returns(fiber.StatusOK, domain.Task{}))
+	doc.Add("POST", "/api/v1/tasks/:id/cancel", authed("cancelTask", "Tasks", "Cancel a task").
+		Returns(fiber.StatusOK, domain.Task{}))
+	doc.Add("POST", "/api/v1/tasks/:id/retry", authed("retryTask", "Tasks", "Retry a failed or cancelled task").
+		Returns(fiber.StatusOK, domain.Task{}))
+
+	// Notifications
+	doc.Add("GET", "/api/v1/notifications", withPage(authed("listNotifications", "Notifications", "List the office's notifications"), true).
+		Describe("Newest first. New notifications are also pushed over the WebSocket, with notification_type as the event type.").
+		Query("unread", "boolean", "Only list unread notifications").
+		Returns(fiber.StatusOK, Page[*domain.Notification]{}))
+	doc.Add("POST", "/api/v1/notifications/:id/read", authed("markNotificationRead", "Notifications", "Mark a notification read").
+		Describe("The office's other clients are sent a notifications_read event.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/notifications/read-all", authed("markAllNotificationsRead", "Notifications", "Mark all notifications read").
+		Returns(fiber.StatusOK, openapi.Fields{"marked_read": 0}))
+	doc.Add("GET", "/api/v1/notifications/preferences", authed("getNotificationPreferences", "Notifications", "Get your notification preferences").
+		Describe("Lists every notification type with whether it is shown in the app and whether it is emailed to your verified address.").
+		Returns(fiber.StatusOK, openapi.Fields{"preferences": []domain.NotificationPreference{}}))
+	doc.Add("PUT", "/api/v1/notifications/preferences", authed("updateNotificationPreferences", "Notifications", "Change your notification preferences").
+		Describe("Types left out keep their preference. Returns the preferences for every type.").
+		Body(UpdateNotificationPreferencesRequest{}).
+		Returns(fiber.StatusOK, openapi.Fields{"preferences": []domain.NotificationPreference{}}))
+
+	// Exports
+	exportDescription := "Rows are newest first and streamed as they are read. The office's tier limits how many days " +
+		"back an export reaches (max_export_days); longer ranges get 403 tier_limit_exceeded."
+	export := func(id, tag, summary string) *openapi.Operation {
+		return authed(id, tag, summary).Describe(exportDescription).
+			Query("format", "string", "csv (the default) or xlsx").
+			Query("days", "integer", "Number of days to cover, 30 by default").
+			Returns(fiber.StatusOK, nil)
+	}
+
+	// Credits
+	doc.Add("GET", "/api/v1/credits/wallet", authed("getWallet", "Credits", "Get the office's credit wallet").
+		Returns(fiber.StatusOK, domain.CreditWallet{}))
+	doc.Add("GET", "/api/v1/credits/balance", authed("getCreditBalance", "Credits", "Get the office's credit balance").
+		Returns(fiber.StatusOK, openapi.Fields{"balance": int64(0)}))
+	doc.Add("GET", "/api/v1/credits/summary", authed("getWalletSummary", "Credits", "Summarise the office's credit wallet").
+		Returns(fiber.StatusOK, service.WalletSummary{}))
+	doc.Add("GET", "/api/v1/credits/transactions", withPage(authed("listCreditTransactions", "Credits", "List credit transactions"), true).
+		Returns(fiber.StatusOK, Page[*domain.CreditTransaction]{}))
+	doc.Add("GET", "/api/v1/credits/transactions/export", export("exportCreditTransactions", "Credits", "Download credit transactions as CSV or Excel"))
+	doc.Add("POST", "/api/v1/credits/check", authed("checkCreditBalance", "Credits", "Check whether the office can afford an amount").
+		Body(CheckBalanceRequest{}).
+		Returns(fiber.StatusOK, openapi.Fields{"has_sufficient": true, "current_balance": int64(0), "required_credits": int64(0)}))
+	doc.Add("POST", "/api/v1/credits/estimate", authed("estimateCreditCost", "Credits", "Estimate the credit cost of a task").
+		Describe("Prices the input, with the agent's context, on the model the orchestrator would route it to, and compares it with the office's remaining budget.").
+		Body(EstimateCostRequest{}).
+		Returns(fiber.StatusOK, domain.CostEstimate{}))
+	doc.Add("POST", "/api/v1/credits/redeem", session("redeemPromoCode", "Credits", "Redeem a promo code").
+		Describe("Bonus credit codes add a bonus transaction referring to the code to the office's wallet. Subscription "+
+			"discount codes take a percentage off the subscription price of the office's next invoices; offices billed "+
+			"through Stripe cannot redeem them. Each office and each user redeems a code once.").
+		Body(RedeemPromoCodeRequest{}).Returns(fiber.StatusCreated, domain.PromoRedemption{}))
+	doc.Add("GET", "/api/v1/credits/redemptions", authed("listPromoRedemptions", "Credits", "List the promo codes the office redeemed, newest first").
+		Returns(fiber.StatusOK, openapi.Fields{"redemptions": []*domain.PromoRedemption{}}))
+	doc.Add("PUT", "/api/v1/credits/low-balance", session("updateLowBalance", "Credits", "Set the low balance warning and auto top-up").
+		Describe("The office is warned by notification and email when its balance drops below the threshold; 0 turns the "+
+			"warning off. With auto top-up on, the chosen credit package is bought with the saved Stripe payment method "+
+			"whenever the balance is below the threshold, at most max_per_day times a UTC day. A declined payment turns "+
+			"auto top-up off. Auto top-up needs a subscription billed through Stripe.").
+		Body(UpdateLowBalanceRequest{}).Returns(fiber.StatusOK, domain.CreditWallet{}))
+	doc.Add("GET", "/api/v1/credits/auto-top-ups", authed("listAutoTopUps", "Credits", "List the office's most recent auto top-ups, newest first").
+		Returns(fiber.StatusOK, openapi.Fields{"auto_top_ups": []*domain.AutoTopUp{}}))
+
+	// Model Policies
+	doc.Add("GET", "/api/v1/model-policies", authed("listModelPolicies", "Model Policies", "List the office's and its agents' model policies").
+		Returns(fiber.StatusOK, openapi.Fields{"policies": []*domain.ModelPolicy{}}))
+	doc.Add("GET", "/api/v1/model-policies/office", authed("getOfficeModelPolicy", "Model Policies", "Get the office's model policy").
+		Returns(fiber.StatusOK, domain.ModelPolicy{}))
+	doc.Add("PUT", "/api/v1/model-policies/office", authed("setOfficeModelPolicy", "Model Policies", "Set the office's model policy").
+		Describe("Constrains the models the office's tasks are routed to, except for agents with a policy of their own. Providers must be included in the office's tier.").
+		Body(SetModelPolicyRequest{}).Returns(fiber.StatusOK, domain.ModelPolicy{}))
+	doc.Add("DELETE", "/api/v1/model-policies/office", authed("deleteOfficeModelPolicy", "Model Policies", "Remove the office's model policy").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Subscription
+	doc.Add("GET", "/api/v1/subscription", authed("getSubscription", "Subscription", "Get the office's subscription").
+		Describe("trial_days_left is set while the subscription is trialing.").
+		Returns(fiber.StatusOK, domain.Subscription{}))
+	doc.Add("GET", "/api/v1/subscription/summary", authed("getSubscriptionSummary", "Subscription", "Summarise the subscription and its usage").
+		Returns(fiber.StatusOK, domain.SubscriptionSummary{}))
+	doc.Add("GET", "/api/v1/subscription/tiers", authed("listTiers", "Subscription", "List subscription tiers").
+		Describe("Lists the live tiers, lowest first. price_monthly_cents is null for tiers sold on request, and "+
+			"credits_per_period is -1 for unlimited credits.").
+		Returns(fiber.StatusOK, openapi.Fields{"tiers": []openapi.Fields{tierFields}}))
+	doc.Add("GET", "/api/v1/subscription/tiers/:tier", authed("getTier", "Subscription", "Get a subscription tier").
+		Returns(fiber.StatusOK, domain.TierDefinition{}))
+	doc.Add("POST", "/api/v1/subscription/upgrade", authed("upgradeTier", "Subscription", "Change the office's subscription tier").
+		Describe("Moves the office to a higher tier and adds the difference in monthly credits. Lower tiers are rejected; "+
+			"use the downgrade endpoint for those.").
+		Body(UpgradeRequest{}).Returns(fiber.StatusOK, openapi.Fields{"message": "", "tier": ""}))
+	doc.Add("POST", "/api/v1/subscription/downgrade", authed("downgradeTier", "Subscription", "Move the office to a lower tier").
+		Describe("The office must already fit the lower tier's agent and seat limits. The downgrade takes effect at the end of "+
+			"the billing period unless immediate is set, in which case it applies now and the unused share of the difference "+
+			"in monthly credits, pro-rated to the rest of the period, is taken back from the wallet.").
+		Body(DowngradeRequest{}).Returns(fiber.StatusOK, service.TierDowngrade{}))
+	doc.Add("DELETE", "/api/v1/subscription/downgrade", authed("cancelDowngrade", "Subscription", "Cancel a scheduled downgrade").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/subscription/cancel", authed("cancelSubscription", "Subscription", "Cancel the office's subscription").
+		Describe("Cancels at the end of the billing period unless immediate is set. Subscriptions billed through Stripe are "+
+			"cancelled there too. Once cancelled, agents no longer run tasks and messages to them get 402 subscription_inactive, "+
+			"but the office's data stays readable for its tier's retention period.").
+		Body(CancelSubscriptionRequest{}).Returns(fiber.StatusOK, domain.Subscription{}))
+	doc.Add("POST", "/api/v1/subscription/pause", authed("pauseSubscription", "Subscription", "Pause the office's subscription").
+		Describe("Agents do not run tasks while the subscription is paused.").
+		Returns(fiber.StatusOK, domain.Subscription{}))
+	doc.Add("POST", "/api/v1/subscription/resume", authed("resumeSubscription", "Subscription", "Resume a paused subscription").
+		Returns(fiber.StatusOK, domain.Subscription{}))
+	doc.Add("POST", "/api/v1/subscription/check-model-access", authed("checkModelAccess", "Subscription", "Check whether the tier includes a model provider").
+		Body(CheckModelAccessRequest{}).Returns(fiber.StatusOK, openapi.Fields{"provider": "", "has_access": true}))
+	doc.Add("POST", "/api/v1/webhooks/stripe", openapi.Op("stripeWebhook", "Subscription", "Receive Stripe events").
+		Body(map[string]any{}).Returns(fiber.StatusOK, openapi.Fields{"received": true}))
+
+	// Billing
+	doc.Add("GET", "/api/v1/billing/invoices", authed("listInvoices", "Billing", "List the office's invoices").
+		Describe("Lists the invoices Stripe issued for offices billed through Stripe, and the manual invoices issued at the "+
+			"start of each period for offices on a paid tier that are not, newest first. Stripe invoices link to Stripe's "+
+			"hosted page and PDF; pdf_url of manual invoices is the API path that downloads them.").
+		Query("limit", "integer", "Maximum number of items to return, at most 100").
+		Returns(fiber.StatusOK, openapi.Fields{"invoices": []domain.Invoice{}}))
+	doc.Add("GET", "/api/v1/billing/invoices/:id/pdf", authed("downloadInvoicePDF", "Billing", "Download a manual invoice as a PDF").
+		Returns(fiber.StatusOK, nil))
+	doc.Add("GET", "/api/v1/billing/upcoming", authed("getUpcomingInvoice", "Billing", "Project the office's next charge").
+		Describe("The next billing period of the tier the office will be on then, with the add-on credits bought since the "+
+			"last invoice. Offices billed through Stripe get Stripe's preview of their next invoice. Returns 404 when no "+
+			"charge is coming, for example when the subscription ends with its period.").
+		Returns(fiber.StatusOK, domain.Invoice{}))
+
+	// Audit log
+	auditFrom := "Only entries from this date or time on, as YYYY-MM-DD or RFC 3339"
+	auditTo := "Only entries before this time, or up to the end of this YYYY-MM-DD date"
+	doc.Add("GET", "/api/v1/audit-log", session("listAuditLog", "Audit Log", "List the office's audit log, most recent first").
+		Describe("Logins, tier changes, credit adjustments, agent deletions, payout requests and admin actions affecting "+
+			"the office, with who took them, from which IP and the values before and after. Only the office's owner "+
+			"and admins can read it.").
+		Query("action", "string", "Only this action, e.g. subscription.change_tier").
+		Query("from", "string", auditFrom).
+		Query("to", "string", auditTo).
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"entries": []*domain.AuditEntry{}, "total": 0, "limit": 0, "offset": 0}))
+
+	// Usage analytics
+	analytics := func(id, summary string) *openapi.Operation {
+		return authed(id, "Usage", summary).
+			Describe("Requires a tier that includes analytics; other offices get 402 upgrade_required with the tier that does. " +
+				"Days are counted in the office's timezone; a custom range covers at most 366 days, and an invalid one is a 400.")
+	}
+	ranged := func(op *openapi.Operation) *openapi.Operation {
+		return op.Query("days", "integer", "Number of days up to today to cover, at most 90").
+			Query("from", "string", "First day to cover as YYYY-MM-DD, instead of days").
+			Query("to", "string", "Last day to cover as YYYY-MM-DD; defaults to today")
+	}
+	doc.Add("GET", "/api/v1/usage/summary", analytics("getUsageSummary", "Summarise credit usage and compare it with the period before").
+		Query("period", "string", "Period to cover: today, 7d or 30d").
+		Query("from", "string", "First day to cover as YYYY-MM-DD, instead of period").
+		Query("to", "string", "Last day to cover as YYYY-MM-DD; defaults to today").
+		Returns(fiber.StatusOK, domain.UsageSummary{}))
+	doc.Add("GET", "/api/v1/usage/breakdown", ranged(analytics("getUsageBreakdown", "Break usage down by model and agent")).
+		Returns(fiber.StatusOK, domain.UsageBreakdown{}))
+	doc.Add("GET", "/api/v1/usage/daily", ranged(analytics("getDailyUsage", "Get usage per day")).
+		Returns(fiber.StatusOK, openapi.Fields{"days": 0, "from": "", "to": "", "usage": []domain.UsageDaily{}}))
+	doc.Add("GET", "/api/v1/usage/by-model", ranged(analytics("getModelUsage", "Get usage per model")).
+		Returns(fiber.StatusOK, openapi.Fields{"days": 0, "from": "", "to": "", "models": []domain.UsageByModel{}}))
+	doc.Add("GET", "/api/v1/usage/by-agent", ranged(analytics("getAgentUsage", "Get usage per agent with performance scores")).
+		Returns(fiber.StatusOK, openapi.Fields{"days": 0, "from": "", "to": "", "agents": []domain.UsageByAgent{}}))
+	doc.Add("GET", "/api/v1/usage/export", export("exportUsage", "Usage", "Download usage per day as CSV or Excel").
+		Describe("Requires a tier that includes analytics. "+exportDescription))
+
+	// Author earnings
+	doc.Add("GET", "/api/v1/author/earnings", withPage(authed("listAuthorEarnings", "Author", "List your template sales"), false).
+		Returns(fiber.StatusOK, Page[domain.AuthorEarning]{}))
+	doc.Add("GET", "/api/v1/author/earnings/export", export("exportAuthorEarnings", "Author", "Download your template sales as CSV or Excel"))
+	doc.Add("GET", "/api/v1/author/balance", authed("getAuthorBalance", "Author", "Get your earnings balance").
+		Returns(fiber.StatusOK, domain.AuthorBalance{}))
+	doc.Add("GET", "/api/v1/author/summary", authed("getEarningsSummary", "Author", "Summarise your earnings").
+		Returns(fiber.StatusOK, domain.EarningsSummary{}))
+	doc.Add("POST", "/api/v1/author/payout/request", authed("requestPayout", "Author", "Request a payout of your balance").
+		Body(PayoutRequestBody{}).Returns(fiber.StatusOK, openapi.Fields{"success": true, "payout_id": uuid.UUID{}}))
+	doc.Add("GET", "/api/v1/author/payouts", authed("listPayouts", "Author", "List your payout requests").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"payouts": []domain.PayoutRequest{}, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/author/templates", authed("listAuthorTemplates", "Author", "List your templates").
+		Returns(fiber.StatusOK, openapi.Fields{"templates": []domain.AgentTemplate{}}))
+	doc.Add("GET", "/api/v1/author/bundles", authed("listAuthorBundles", "Author", "List your bundles, including unlisted ones").
+		Returns(fiber.StatusOK, openapi.Fields{"bundles": []domain.TemplateBundle{}}))
+	doc.Add("GET", "/api/v1/author/profile", authed("getAuthorProfileSettings", "Author", "Get your author profile and verification").
+		Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("PUT", "/api/v1/author/profile", authed("updateAuthorProfile", "Author", "Edit your display name, bio and links").
+		Describe("Omitted fields are left unchanged; an empty links list removes them. A new display name is shown "+
+			"on your templates and bundles too.").
+		Body(service.AuthorProfileInput{}).Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("POST", "/api/v1/author/verification", authed("requestAuthorVerification", "Author", "Ask to be verified").
+		Describe("Requires an approved public template. Admins grant the verified badge or reject the request "+
+			"with a reason; you may ask again after a rejection. 409 if a request is pending or you are verified.").
+		Body(VerificationRequest{}).Returns(fiber.StatusCreated, domain.AuthorProfile{}))
+	doc.Add("GET", "/api/v1/author/templates/analytics", authed("getAuthorTemplateAnalytics", "Author", "Get your templates' impressions, views, hires and sales").
+		Describe("Days are in UTC. The view rate is the percentage of impressions followed by a view, the conversion rate "+
+			"the percentage of views followed by a hire.").
+		Query("days", "integer", "Number of days up to today to cover, at most 90").
+		Returns(fiber.StatusOK, domain.TemplateAnalytics{}))
+
+	// API keys
+	doc.Add("POST", "/api/v1/api-keys", session("createAPIKey", "API Keys", "Mint an API key for the office").
+		Describe("Requires a tier with API access. The key is only returned here; store it securely.").
+		Body(CreateAPIKeyRequest{}).Returns(fiber.StatusCreated, CreateAPIKeyResponse{}))
+	doc.Add("GET", "/api/v1/api-keys", session("listAPIKeys", "API Keys", "List the office's API keys and their usage").
+		Returns(fiber.StatusOK, openapi.Fields{"api_keys": []*domain.APIKey{}}))
+	doc.Add("DELETE", "/api/v1/api-keys/:id", session("revokeAPIKey", "API Keys", "Revoke an API key").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Chat widgets
+	doc.Add("POST", "/api/v1/widget-tokens", session("createWidgetToken", "Chat Widget", "Create a token embedding one of the office's agents on a site").
+		Describe("The token is published in the embedding page, so it only reaches the chosen agent. Visitors' tasks are charged "+
+			"to the office. The token is only returned here.").
+		Body(CreateWidgetTokenRequest{}).Returns(fiber.StatusCreated, CreateWidgetTokenResponse{}))
+	doc.Add("GET", "/api/v1/widget-tokens", session("listWidgetTokens", "Chat Widget", "List the office's widget tokens with their usage and credits").
+		Returns(fiber.StatusOK, openapi.Fields{"widget_tokens": []*domain.WidgetToken{}}))
+	doc.Add("DELETE", "/api/v1/widget-tokens/:id", session("revokeWidgetToken", "Chat Widget", "Revoke a widget token").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/public/widget/:token/messages", openapi.Op("sendVisitorMessage", "Chat Widget", "Send a visitor's message to the widget's agent").
+		Describe("Called from the embedding page; the page's Origin must be allowed by the token. Omit session_id on the first "+
+			"message and send the returned one afterwards. Replies arrive on the WebSocket at /ws/widget/:token?session_id=<id>. "+
+			"Requests count against the token's per-minute rate limit.").
+		Body(VisitorMessageRequest{}).Returns(fiber.StatusCreated, VisitorMessageResponse{}))
+	doc.Add("GET", "/api/v1/public/widget/:token/messages", withPage(openapi.Op("listVisitorMessages", "Chat Widget", "List the messages of a visitor's conversation"), true).
+		Query("session_id", "string", "The visitor's session").
+		Returns(fiber.StatusOK, Page[WidgetMessage]{}))
+
+	// Webhooks
+	doc.Add("POST", "/api/v1/webhooks", authed("createWebhook", "Webhooks", "Register an outbound webhook").
+		Describe("Requires a tier with API access. Deliveries are POSTed as JSON and signed in the "+service.WebhookSignatureHeader+
+			" header as t=<unix time>,v1=<hex HMAC-SHA256 of \"<t>.<body>\" keyed with the secret>. Failed deliveries are "+
+			"retried with backoff. The secret is only returned here.").
+		Body(CreateWebhookRequest{}).Returns(fiber.StatusCreated, CreateWebhookResponse{}))
+	doc.Add("GET", "/api/v1/webhooks", authed("listWebhooks", "Webhooks", "List the office's webhooks").
+		Returns(fiber.StatusOK, openapi.Fields{"webhooks": []*domain.Webhook{}}))
+	doc.Add("DELETE", "/api/v1/webhooks/:id", authed("deleteWebhook", "Webhooks", "Delete a webhook and its delivery log").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("GET", "/api/v1/webhooks/:id/deliveries", withPage(authed("listWebhookDeliveries", "Webhooks", "List a webhook's deliveries with every attempt and response code"), false).
+		Returns(fiber.StatusOK, Page[*domain.WebhookDelivery]{}))
+
+	// Secrets
+	doc.Add("POST", "/api/v1/secrets", session("createSecret", "Secrets", "Store a secret for the office's agents' tools").
+		Describe("The value is encrypted at rest and never returned; agents get it only while running a task. Names are upper "+
+			"case, like environment variables, and unique in the office (409 otherwise). Returns 503 when the vault is not configured.").
+		Body(CreateSecretRequest{}).Returns(fiber.StatusCreated, domain.OfficeSecret{}))
+	doc.Add("GET", "/api/v1/secrets", session("listSecrets", "Secrets", "List the office's secrets without their values").
+		Returns(fiber.StatusOK, openapi.Fields{"secrets": []*domain.OfficeSecret{}}))
+	doc.Add("PUT", "/api/v1/secrets/:id", session("updateSecret", "Secrets", "Replace a secret's value, description or agents").
+		Body(UpdateSecretRequest{}).Returns(fiber.StatusOK, domain.OfficeSecret{}))
+	doc.Add("DELETE", "/api/v1/secrets/:id", session("deleteSecret", "Secrets", "Delete a secret").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Web research
+	doc.Add("GET", "/api/v1/web-research/settings", authed("getWebResearchSettings", "Web Research", "Get the office's web research settings").
+		Describe("Requires a tier with web research. Offices that never changed them get the defaults, allowing every domain.").
+		Returns(fiber.StatusOK, domain.WebResearchSettings{}))
+	doc.Add("PUT", "/api/v1/web-research/settings", authed("updateWebResearchSettings", "Web Research", "Change the office's web research settings").
+		Describe("Requires a tier with web research. Allowed domains are given as domains or URLs and also allow their "+
+			"subdomains; an empty list allows every domain. Tasks may run at most 50 searches.").
+		Body(UpdateWebResearchSettingsRequest{}).Returns(fiber.StatusOK, domain.WebResearchSettings{}))
+
+	// Knowledge base
+	doc.Add("POST", "/api/v1/knowledge", authed("createKnowledge", "Knowledge", "Add a note or text file to the office's knowledge base").
+		Describe("Send a note as JSON, or upload a UTF-8 text file such as Markdown as multipart form data, with an optional "+
+			"title field defaulting to the file's name. Documents are at most 1 MiB, and an office has at most 200. Every agent "+
+			"of the office is given the parts relevant to its task.").
+		Body(CreateKnowledgeRequest{}).
+		FileUpload("file", "The text file").
+		Returns(fiber.StatusCreated, domain.KnowledgeDocument{}))
+	doc.Add("GET", "/api/v1/knowledge", authed("listKnowledge", "Knowledge", "List the office's knowledge documents without their content").
+		Returns(fiber.StatusOK, openapi.Fields{"documents": []*domain.KnowledgeDocument{}}))
+	doc.Add("GET", "/api/v1/knowledge/search", authed("searchKnowledge", "Knowledge", "Search the office's knowledge base").
+		Describe("Returns the chunks of documents closest in meaning to the query, most similar first; new documents are "+
+			"searchable once embedded, within a minute. Without an embeddings API configured, returns the chunks with the "+
+			"query's words, best matches first.").
+		Query("q", "string", "What to search for").
+		Query("limit", "integer", "Most chunks to return, 10 by default and at most 50").
+		Returns(fiber.StatusOK, openapi.Fields{"matches": []*domain.KnowledgeMatch{}}))
+	doc.Add("GET", "/api/v1/knowledge/:id", authed("getKnowledge", "Knowledge", "Get a knowledge document with its content").
+		Returns(fiber.StatusOK, domain.KnowledgeDocument{}))
+	doc.Add("DELETE", "/api/v1/knowledge/:id", authed("deleteKnowledge", "Knowledge", "Remove a document from the knowledge base").
+		Returns(fiber.StatusNoContent, nil))
+
+	// Skills
+	doc.Add("GET", "/api/v1/skills", authed("listSkills", "Skills", "List every skill agents can be given").
+		Describe("Each tier lists the skills it includes under features.skills of GET /subscription/tiers.").
+		Returns(fiber.StatusOK, openapi.Fields{"skills": []domain.Skill{}}))
+
+	// Departments
+	doc.Add("POST", "/api/v1/departments", authed("createDepartment", "Departments", "Create a department led by one of its members").
+		Describe("The department gets a group conversation with its members. Messages there that mention no one go to the "+
+			"lead, who answers or delegates to members by @mentioning them. An agent can be in one department; adding one "+
+			"already in another returns 409.").
+		Body(CreateDepartmentRequest{}).Returns(fiber.StatusCreated, domain.Department{}))
+	doc.Add("GET", "/api/v1/departments", authed("listDepartments", "Departments", "List the office's departments").
+		Returns(fiber.StatusOK, openapi.Fields{"departments": []*domain.Department{}}))
+	doc.Add("GET", "/api/v1/departments/:id", authed("getDepartment", "Departments", "Get a department with its members").
+		Returns(fiber.StatusOK, domain.Department{}))
+	doc.Add("PATCH", "/api/v1/departments/:id", authed("updateDepartment", "Departments", "Rename a department or change its lead").
+		Describe("Renaming a department renames its conversation. The new lead must be a member.").
+		Body(UpdateDepartmentRequest{}).Returns(fiber.StatusOK, domain.Department{}))
+	doc.Add("DELETE", "/api/v1/departments/:id", authed("deleteDepartment", "Departments", "Remove a department").
+		Describe("Its conversation is kept as a plain group conversation.").
+		Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/departments/:id/members", authed("addDepartmentMember", "Departments", "Add an agent to a department").
+		Describe("The agent also joins the department's conversation.").
+		Body(AddDepartmentMemberRequest{}).Returns(fiber.StatusOK, domain.Department{}))
+	doc.Add("DELETE", "/api/v1/departments/:id/members/:agentId", authed("removeDepartmentMember", "Departments", "Remove an agent from a department").
+		Describe("The agent also leaves the department's conversation. The lead cannot be removed until another member leads.").
+		Returns(fiber.StatusOK, domain.Department{}))
+	doc.Add("GET", "/api/v1/org-chart", authed("getOrgChart", "Departments", "Get the office's departments and reporting lines").
+		Returns(fiber.StatusOK, domain.OrgChart{}))
+
+	// Admin
+	doc.Add("GET", "/api/v1/admin/marketplace/pending", session("listPendingTemplates", "Admin", "List templates awaiting moderation").
+		Describe("Includes approved templates with a new version awaiting review, given as pending_version.").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"templates": []domain.AgentTemplate{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/marketplace/templates/:id/approve", session("approveTemplate", "Admin", "Approve a pending template").
+		Describe("A pending version of the template replaces the live one.").
+		Returns(fiber.StatusOK, domain.AgentTemplate{}))
+	doc.Add("POST", "/api/v1/admin/marketplace/templates/:id/reject", session("rejectTemplate", "Admin", "Reject a pending template").
+		Describe("Rejecting a pending version keeps the template listed at its approved version.").
+		Body(RejectTemplateRequest{}).Returns(fiber.StatusOK, domain.AgentTemplate{}))
+	doc.Add("GET", "/api/v1/admin/moderation/flags", session("listModerationFlags", "Admin", "List content flagged by moderation, oldest first").
+		Describe("Messages, custom system prompts and template submissions flagged by the blocklist or the moderation API. "+
+			"Flags with the block action are of content that was refused and has no entity_id.").
+		Query("status", "string", "pending (default), dismissed, upheld or all").
+		Query("content_type", "string", "message, system_prompt or template").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"flags": []*domain.ModerationFlag{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/moderation/flags/:id/resolve", session("resolveModerationFlag", "Admin", "Dismiss or uphold a pending moderation flag").
+		Describe("Records the decision only; the flagged content is left as it is.").
+		Body(ResolveFlagRequest{}).Returns(fiber.StatusOK, domain.ModerationFlag{}))
+	doc.Add("POST", "/api/v1/admin/retention/runs", session("runRetention", "Admin", "Purge data past each office's retention period now").
+		Describe("Deletes messages, tasks, usage rows and deleted agents and conversations older than the retention period of each office's tier, "+
+			"except for the entities exempted in `RETENTION_EXEMPT`. Credit transactions are never purged. "+
+			"`dry_run` overrides `RETENTION_DRY_RUN`; a dry run only reports what it would purge.").
+		Body(RunRetentionRequest{}).Returns(fiber.StatusCreated, domain.RetentionRun{}))
+	doc.Add("GET", "/api/v1/admin/retention/runs", session("listRetentionRuns", "Admin", "List retention runs").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"runs": []domain.RetentionRun{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/admin/retention/runs/:id", session("getRetentionRun", "Admin", "Get what a retention run purged from each office").
+		Returns(fiber.StatusOK, domain.RetentionRun{}))
+	doc.Add("GET", "/api/v1/admin/users", session("searchUsers", "Admin", "Search users by email or name").
+		Query("q", "string", "Text the email or name contains").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"users": []*domain.User{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/admin/offices", session("searchOffices", "Admin", "Search offices by name or owner email").
+		Query("q", "string", "Text the office name or owner's email contains").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"offices": []*domain.AdminOffice{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/admin/offices/:id", session("getAdminOffice", "Admin", "Get an office with its owner, subscription and wallet").
+		Returns(fiber.StatusOK, service.AdminOfficeDetail{}))
+	doc.Add("GET", "/api/v1/admin/offices/:id/transactions", withPage(session("listOfficeTransactions", "Admin", "List an office's credit transactions"), true).
+		Returns(fiber.StatusOK, Page[*domain.CreditTransaction]{}))
+	doc.Add("POST", "/api/v1/admin/offices/:id/credits", session("adjustOfficeCredits", "Admin", "Add or remove an office's credits").
+		Describe("Records an `adjustment` transaction; a negative amount removes credits and cannot take the balance below zero.").
+		Body(AdjustCreditsRequest{}).Returns(fiber.StatusCreated, domain.CreditTransaction{}))
+	doc.Add("PUT", "/api/v1/admin/offices/:id/subscription", session("changeOfficeTier", "Admin", "Move an office to a tier").
+		Describe("Changes the tier immediately, without the limit checks and credit changes of an upgrade or downgrade, "+
+			"and cancels any pending tier change.").
+		Body(ChangeTierRequest{}).Returns(fiber.StatusOK, domain.Subscription{}))
+	doc.Add("POST", "/api/v1/admin/tiers/reload", session("reloadTiers", "Admin", "Reload the subscription tiers file").
+		Describe("Makes the tiers file the live tier configuration and returns its tiers. A file that fails validation, "+
+			"such as one granting an unknown provider or negative credits, is rejected with 400 and the current tiers stay live.").
+		Returns(fiber.StatusOK, openapi.Fields{"tiers": []openapi.Fields{tierFields}}))
+	doc.Add("GET", "/api/v1/admin/tasks/failed", session("listFailedTasks", "Admin", "List failed and dead-lettered tasks of every office").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"tasks": []*domain.Task{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("GET", "/api/v1/admin/payouts", session("listPayouts", "Admin", "List authors' payout requests, oldest first").
+		Query("status", "string", "pending (default), processing, completed, failed or all").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"payouts": []domain.PayoutRequest{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/payouts/:id/complete", session("completePayout", "Admin", "Mark a pending payout as sent").
+		Body(CompletePayoutRequest{}).Returns(fiber.StatusOK, domain.PayoutRequest{}))
+	doc.Add("POST", "/api/v1/admin/payouts/:id/reject", session("rejectPayout", "Admin", "Reject a payout that has not been sent").
+		Describe("Returns the amount to the author's available balance and tells the author the reason.").
+		Body(RejectPayoutRequest{}).Returns(fiber.StatusOK, domain.PayoutRequest{}))
+	doc.Add("GET", "/api/v1/admin/refunds", session("listRefunds", "Admin", "List offices' refund requests, oldest first").
+		Query("status", "string", "pending (default), refunded, rejected or all").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"refunds": []*domain.RefundRequest{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/refunds/:id/approve", session("approveRefund", "Admin", "Refund the purchase of a pending refund request").
+		Describe("Refunds the payment through Stripe, reverses the author's earning with a negative earning and "+
+			"revokes the office's entitlement to the template. Agents already hired from it are kept.").
+		Returns(fiber.StatusOK, domain.RefundRequest{}))
+	doc.Add("POST", "/api/v1/admin/refunds/:id/reject", session("rejectRefund", "Admin", "Reject a pending refund request").
+		Describe("Tells the office the reason.").
+		Body(RejectRefundRequest{}).Returns(fiber.StatusOK, domain.RefundRequest{}))
+	doc.Add("GET", "/api/v1/admin/authors/verifications", session("listAuthorVerifications", "Admin", "List authors by verification status, oldest request first").
+		Query("status", "string", "pending (default), verified or rejected").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"authors": []*domain.AuthorProfile{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/authors/:id/verify", session("verifyAuthor", "Admin", "Grant a pending request's author the verified badge").
+		Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("POST", "/api/v1/admin/authors/:id/reject-verification", session("rejectAuthorVerification", "Admin", "Reject a pending verification request").
+		Describe("Tells the author the reason.").
+		Body(AuthorVerificationReviewRequest{}).Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("POST", "/api/v1/admin/authors/:id/revoke-verification", session("revokeAuthorVerification", "Admin", "Take a verified author's badge away").
+		Describe("Tells the author the reason; they may ask to be verified again.").
+		Body(AuthorVerificationReviewRequest{}).Returns(fiber.StatusOK, domain.AuthorProfile{}))
+	doc.Add("GET", "/api/v1/admin/promo-codes", session("listPromoCodes", "Admin", "List promo codes, newest first").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"promo_codes": []*domain.PromoCode{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/promo-codes", session("createPromoCode", "Admin", "Create a promo code").
+		Describe("Codes are case-insensitive. bonus_credits codes set bonus_credits; subscription_discount codes set "+
+			"discount_percent and discount_periods, the number of invoices discounted.").
+		Body(CreatePromoCodeRequest{}).Returns(fiber.StatusCreated, domain.PromoCode{}))
+	doc.Add("GET", "/api/v1/admin/promo-codes/:id/redemptions", session("listPromoCodeRedemptions", "Admin", "List a promo code's redemptions, newest first").
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"redemptions": []*domain.PromoRedemption{}, "total": 0, "limit": 0, "offset": 0}))
+	doc.Add("POST", "/api/v1/admin/promo-codes/:id/deactivate", session("deactivatePromoCode", "Admin", "End a promo code's campaign").
+		Describe("Discounts already redeemed keep applying.").
+		Returns(fiber.StatusOK, domain.PromoCode{}))
+	doc.Add("GET", "/api/v1/admin/audit-log", session("listAdminAuditLog", "Admin", "List the audit log of every office, most recent first").
+		Query("actor_id", "string", "Only actions taken by this user").
+		Query("office_id", "string", "Only actions affecting this office").
+		Query("action", "string", "Only this action, e.g. admin.credits.adjust").
+		Query("from", "string", auditFrom).
+		Query("to", "string", auditTo).
+		Query("limit", "integer", "Maximum number of items to return").
+		Query("offset", "integer", "Number of items to skip").
+		Returns(fiber.StatusOK, openapi.Fields{"entries": []*domain.AuditEntry{}, "total": 0, "limit": 0, "offset": 0}))
+
+	// Internal service-to-service routes
+	doc.Add("POST", "/api/v1/internal/task-complete", internal("internalTaskComplete", "Report a finished agent task").
+		Body(TaskCompleteRequest{}).Returns(fiber.StatusOK, openapi.Fields{"status": "", "message": ""}))
+	doc.Add("POST", "/api/v1/internal/task-stream-chunk", internal("internalTaskStreamChunk", "Relay streamed agent output").
+		Body(TaskStreamChunkRequest{}).Returns(fiber.StatusOK, openapi.Fields{"status": ""}))
+	doc.Add("POST", "/api/v1/internal/documents", internal("internalSaveDocument", "Save a document an agent produced").
+		Describe("Creates a document in the task's office and conversation, or with document_id saves its next version.").
+		Body(SaveDocumentRequest{}).Returns(fiber.StatusCreated, domain.Document{}))
+	doc.Add("POST", "/api/v1/internal/credits/check", internal("internalCheckCredits", "Check an office's credit balance").
+		Body(CreditCheckRequest{}).
+		Returns(fiber.StatusOK, openapi.Fields{"has_sufficient": true, "current_balance": int64(0), "required_credits": int64(0)}))
+	doc.Add("POST", "/api/v1/internal/credits/consume", internal("internalConsumeCredits", "Charge credits for a task").
+		Header("Idempotency-Key", idempotent).
+		Body(CreditConsumeRequest{}).
+		Returns(fiber.StatusOK, openapi.Fields{"success": true, "transaction_id": uuid.UUID{}, "new_balance": int64(0)}))
+	doc.Add("GET", "/api/v1/internal/credits/balance/:officeId", internal("internalGetBalance", "Get an office's credit balance").
+		Returns(fiber.StatusOK, openapi.Fields{"balance": int64(0)}))
+	doc.Add("GET", "/api/v1/internal/metrics/connections", internal("internalConnectionMetrics", "Count open WebSocket connections").
+		Returns(fiber.StatusOK, ConnectionStats{}))
+	doc.Add("POST", "/api/v1/internal/analytics/backfill", internal("internalBackfillUsage", "Rebuild usage rollups from tasks").
+		Describe("Adds up the usage of finished tasks and the credits charged for them into the daily, per-model and per-agent "+
+			"rollups, for the days from and to in each office's timezone, at most 366. Days that already have rollups are kept "+
+			"unless overwrite is set; overwriting days whose tasks have been purged empties them.").
+		Body(BackfillUsageRequest{}).Returns(fiber.StatusOK, domain.UsageBackfill{}))
+	doc.Add("GET", "/api/v1/internal/tasks/:id/secrets", internal("internalGetTaskSecrets", "Get the decrypted secrets of a running task").
+		Describe("Returns the secrets of the task's office its agent may use, while the task is pending or running (403 "+
+			"afterwards). Every secret returned is recorded in the office's audit log as secret.access with the task.").
+		Query("names", "string", "Comma-separated names of the secrets to return; all of them when omitted").
+		Returns(fiber.StatusOK, openapi.Fields{"secrets": []service.TaskSecret{}}))
+	doc.Add("PUT", "/api/v1/internal/memories/:id/embedding", internal("internalStoreMemoryEmbedding", "Store the embedding of a memory").
+		Describe("The embedding of \"<key>: <value>\" with 1536 dimensions. It is dropped when the memory's key or value changes.").
+		Body(StoreEmbeddingRequest{}).Returns(fiber.StatusNoContent, nil))
+	doc.Add("POST", "/api/v1/internal/agents/:id/memories/search", internal("internalRetrieveMemories", "Get an agent's memories for a task's context").
+		Describe("Returns the agent's memories most similar to the embedding, or to the query when no embedding is given, "+
+			"10 unless limit is set.").
+		Body(RetrieveMemoriesRequest{}).Returns(fiber.StatusOK, openapi.Fields{"memories": []*domain.MemoryMatch{}}))
+	doc.Add("POST", "/api/v1/internal/web-research/lookup", internal("internalWebResearchLookup", "Look up a running task's query in the search cache").
+		Describe("Returns the cached results in the office's allowed domains when the query was searched recently by any office, "+
+			"recording a free search for the task. Otherwise returns how many searches the task has left and what one costs.").
+		Body(WebResearchLookupRequest{}).Returns(fiber.StatusOK, service.WebResearchLookup{}))
+	doc.Add("POST", "/api/v1/internal/web-research/searches", internal("internalLogWebSearch", "Charge a web search a running task ran").
+		Describe("Charges the task's office for the search, caches its unfiltered results for other lookups and returns those "+
+			"in the office's allowed domains. Returns 429 rate_limited once the task used its office's searches per task.").
+		Header("Idempotency-Key", idempotent).
+		Body(LogWebSearchRequest{}).Returns(fiber.StatusCreated, service.WebSearchLog{}))
+
+	return doc
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Page is the standard envelope for paginated list responses.
+// NextCursor is only set on endpoints that support keyset pagination and
+// is omitted once the last page has been reached.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parsePageRequest reads limit, offset and cursor query parameters.
+// Out-of-range limits fall back to defaultLimit.
+func parsePageRequest(c *fiber.Ctx, defaultLimit, maxLimit int) (domain.PageRequest, error) {
+	page := domain.PageRequest{Limit: defaultLimit}
+
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= maxLimit {
+		page.Limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o >= 0 {
+		page.Offset = o
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+	return page, nil
+}
+
+// newPage builds a response page. When cursorOf is non-nil and the page is
+// full, NextCursor points after the last item.
+func newPage[T any](items []T, total, limit int, cursorOf func(T) domain.PageCursor) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	page := Page[T]{Items: items, Total: total}
+	if cursorOf != nil && limit > 0 && len(items) == limit {
+		page.NextCursor = encodeCursor(cursorOf(items[len(items)-1]))
+	}
+	return page
+}
+
+// encodeCursor serialises a cursor into an opaque URL-safe token
+func encodeCursor(cursor domain.PageCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by encodeCursor
+func decodeCursor(token string) (*domain.PageCursor, error) {
+	invalid := badRequest("invalid cursor")
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, invalid
+	}
+
+	cursor := &domain.PageCursor{}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, invalid
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return nil, invalid
+	}
+	return cursor, nil
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PerformanceHandler handles agent performance endpoints
+type PerformanceHandler struct {
+	performanceService *service.PerformanceService
+}
+
+// NewPerformanceHandler creates a new PerformanceHandler
+func NewPerformanceHandler(performanceService *service.PerformanceService) *PerformanceHandler {
+	return &PerformanceHandler{performanceService: performanceService}
+}
+
+// GetLeaderboard ranks the office's agents by performance score
+// GET /agents/performance?days=30
+func (h *PerformanceHandler) GetLeaderboard(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	days := 30
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
+			days = parsed
+		}
+	}
+
+	leaderboard, err := h.performanceService.GetLeaderboard(c.Context(), officeID, days)
+	if err != nil {
+		return internalError("failed to score agents", err)
+	}
+	return c.JSON(leaderboard)
+}
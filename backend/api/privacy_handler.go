@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PrivacyHandler handles the data export and account erasure endpoints
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{privacyService: privacyService}
+}
+
+// EraseAccountRequest confirms an account erasure with the user's password
+type EraseAccountRequest struct {
+	Password string `json:"password,omitempty"`
+}
+
+// RequestDataExport queues an export of all of the signed-in user's data.
+// The user is emailed when it can be downloaded.
+// POST /auth/me/data-export
+func (h *PrivacyHandler) RequestDataExport(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	export, err := h.privacyService.RequestExport(c.Context(), userID)
+	if err != nil {
+		return internalError("failed to request data export", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(export)
+}
+
+// ListDataExports lists the signed-in user's recent data exports
+// GET /auth/me/data-exports
+func (h *PrivacyHandler) ListDataExports(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	exports, err := h.privacyService.ListExports(c.Context(), userID)
+	if err != nil {
+		return internalError("failed to list data exports", err)
+	}
+	if exports == nil {
+		exports = []*domain.DataExport{}
+	}
+
+	return c.JSON(fiber.Map{"exports": exports})
+}
+
+// DownloadDataExport streams a ready data export's ZIP archive
+// GET /auth/me/data-exports/:id/download
+func (h *PrivacyHandler) DownloadDataExport(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	exportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid export id")
+	}
+
+	export, archive, err := h.privacyService.OpenExport(c.Context(), userID, exportID)
+	if err != nil {
+		return internalError("failed to download data export", err)
+	}
+
+	c.Attachment("synoffice-export-" + export.CreatedAt.Format("2006-01-02") + ".zip")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.SendStream(archive, int(export.SizeBytes))
+}
+
+// EraseAccount closes the signed-in user's account and erases their data
+// POST /auth/me/erase
+func (h *PrivacyHandler) EraseAccount(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	// The body is optional for users without a password
+	var req EraseAccountRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	if err := h.privacyService.EraseAccount(c.Context(), userID, req.Password); err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			return forbidden("password is incorrect")
+		}
+		return internalError("failed to erase account", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ProjectHandler handles the project board endpoints
+type ProjectHandler struct {
+	projectService *service.ProjectService
+}
+
+// NewProjectHandler creates a new ProjectHandler
+func NewProjectHandler(projectService *service.ProjectService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService}
+}
+
+// CreateProjectRequest represents a request to create a project. Columns
+// name its first columns; without any it gets "To do", "In progress" and
+// "Done".
+type CreateProjectRequest struct {
+	Name        string   `json:"name" validate:"required,max=255"`
+	Description string   `json:"description,omitempty" validate:"max=2000"`
+	Columns     []string `json:"columns,omitempty" validate:"max=20,dive,required,max=100"`
+}
+
+// UpdateProjectRequest changes a project; omitted fields are left unchanged
+type UpdateProjectRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=2000"`
+}
+
+// CreateBoardColumnRequest represents a request to add a column to a board
+type CreateBoardColumnRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// UpdateBoardColumnRequest renames a column or moves it to a position
+// counted from 0; omitted fields are left unchanged
+type UpdateBoardColumnRequest struct {
+	Name     *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Position *int    `json:"position,omitempty" validate:"omitempty,min=0"`
+}
+
+// AddBoardCardRequest places a task in a column of a board, at a position
+// counted from 0 or at the end of the column
+type AddBoardCardRequest struct {
+	TaskID   uuid.UUID `json:"task_id" validate:"required"`
+	ColumnID uuid.UUID `json:"column_id" validate:"required"`
+	Position *int      `json:"position,omitempty" validate:"omitempty,min=0"`
+}
+
+// MoveBoardCardRequest moves a card to a column of its board, at a
+// position counted from 0 or at the end of the column
+type MoveBoardCardRequest struct {
+	ColumnID uuid.UUID `json:"column_id" validate:"required"`
+	Position *int      `json:"position,omitempty" validate:"omitempty,min=0"`
+}
+
+// CreateProject creates a project with its board
+// POST /projects
+func (h *ProjectHandler) CreateProject(c *fiber.Ctx) error {
+	var req CreateProjectRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	project, err := h.projectService.CreateProject(c.Context(), service.CreateProjectInput{
+		OfficeID:    c.Locals("office_id").(uuid.UUID),
+		Name:        req.Name,
+		Description: req.Description,
+		Columns:     req.Columns,
+	})
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(project)
+}
+
+// GetProjects lists the office's projects
+// GET /projects
+func (h *ProjectHandler) GetProjects(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projects, err := h.projectService.GetProjects(c.Context(), officeID)
+	if err != nil {
+		return projectError(err)
+	}
+	if projects == nil {
+		projects = []*domain.Project{}
+	}
+
+	return c.JSON(fiber.Map{"projects": projects})
+}
+
+// GetBoard returns one of the office's projects with its columns and cards
+// GET /projects/:id
+func (h *ProjectHandler) GetBoard(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+
+	project, err := h.projectService.GetBoard(c.Context(), officeID, projectID)
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.JSON(project)
+}
+
+// UpdateProject renames a project or changes its description
+// PUT /projects/:id
+func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+
+	var req UpdateProjectRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	project, err := h.projectService.UpdateProject(c.Context(), service.UpdateProjectInput{
+		OfficeID:    officeID,
+		ProjectID:   projectID,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.JSON(project)
+}
+
+// DeleteProject removes one of the office's projects, keeping its tasks
+// DELETE /projects/:id
+func (h *ProjectHandler) DeleteProject(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+
+	if err := h.projectService.DeleteProject(c.Context(), officeID, projectID); err != nil {
+		return projectError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateColumn adds a column at the end of a project's board
+// POST /projects/:id/columns
+func (h *ProjectHandler) CreateColumn(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+
+	var req CreateBoardColumnRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	column, err := h.projectService.CreateColumn(c.Context(), officeID, projectID, req.Name)
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(column)
+}
+
+// UpdateColumn renames or moves a column of a project's board
+// PUT /projects/:id/columns/:columnId
+func (h *ProjectHandler) UpdateColumn(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+	columnID, err := uuid.Parse(c.Params("columnId"))
+	if err != nil {
+		return badRequest("invalid column id")
+	}
+
+	var req UpdateBoardColumnRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	column, err := h.projectService.UpdateColumn(c.Context(), service.UpdateColumnInput{
+		OfficeID:  officeID,
+		ProjectID: projectID,
+		ColumnID:  columnID,
+		Name:      req.Name,
+		Position:  req.Position,
+	})
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.JSON(column)
+}
+
+// DeleteColumn removes a column of a project's board with its cards
+// DELETE /projects/:id/columns/:columnId
+func (h *ProjectHandler) DeleteColumn(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+	columnID, err := uuid.Parse(c.Params("columnId"))
+	if err != nil {
+		return badRequest("invalid column id")
+	}
+
+	if err := h.projectService.DeleteColumn(c.Context(), officeID, projectID, columnID); err != nil {
+		return projectError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AddCard places a task on a project's board
+// POST /projects/:id/cards
+func (h *ProjectHandler) AddCard(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+
+	var req AddBoardCardRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	card, err := h.projectService.AddCard(c.Context(), service.AddCardInput{
+		OfficeID:  officeID,
+		ProjectID: projectID,
+		TaskID:    req.TaskID,
+		ColumnID:  req.ColumnID,
+		Position:  req.Position,
+	})
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(card)
+}
+
+// MoveCard moves a card to a column of its board and a position in it
+// PUT /projects/:id/cards/:taskId
+func (h *ProjectHandler) MoveCard(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+	taskID, err := uuid.Parse(c.Params("taskId"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	var req MoveBoardCardRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	card, err := h.projectService.MoveCard(c.Context(), service.MoveCardInput{
+		OfficeID:  officeID,
+		ProjectID: projectID,
+		TaskID:    taskID,
+		ColumnID:  req.ColumnID,
+		Position:  req.Position,
+	})
+	if err != nil {
+		return projectError(err)
+	}
+
+	return c.JSON(card)
+}
+
+// RemoveCard takes a task off a project's board, keeping the task
+// DELETE /projects/:id/cards/:taskId
+func (h *ProjectHandler) RemoveCard(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	projectID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid project id")
+	}
+	taskID, err := uuid.Parse(c.Params("taskId"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	if err := h.projectService.RemoveCard(c.Context(), officeID, projectID, taskID); err != nil {
+		return projectError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// projectError maps project board errors to API errors
+func projectError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("project, column or task not found")
+	default:
+		return internalError("failed to manage project boards", err)
+	}
+}
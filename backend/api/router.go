@@ -1,6 +1,8 @@
 package api
 
 import (
+	"strings"
+
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -22,8 +24,47 @@ type Router struct {
 	subscriptionHandler *SubscriptionHandler
 	analyticsHandler    *AnalyticsHandler
 	earningsHandler     *EarningsHandler
+	memoryHandler       *MemoryHandler
+	learningHandler     *LearningHandler
+	adminHandler        *AdminHandler
+	taskHandler         *TaskHandler
+	scheduleHandler     *ScheduleHandler
+	apiKeyHandler       *APIKeyHandler
+	webhookHandler      *WebhookHandler
+	widgetHandler       *WidgetHandler
+	oauthHandler        *OAuthHandler
+	officeHandler       *OfficeHandler
+	attachmentHandler   *AttachmentHandler
+	transcriptHandler   *TranscriptHandler
+	exportHandler       *ExportHandler
+	performanceHandler  *PerformanceHandler
+	templateViewHandler *TemplateViewHandler
+	documentHandler     *DocumentHandler
+	notificationHandler *NotificationHandler
+	modelPolicyHandler  *ModelPolicyHandler
+	billingHandler      *BillingHandler
+	auditHandler        *AuditHandler
+	privacyHandler      *PrivacyHandler
+	secretHandler       *SecretHandler
+	webResearchHandler  *WebResearchHandler
+	knowledgeHandler    *KnowledgeHandler
+	skillHandler        *SkillHandler
+	onboardingHandler   *OnboardingHandler
+	departmentHandler   *DepartmentHandler
+	taskTemplateHandler *TaskTemplateHandler
+	projectHandler      *ProjectHandler
+	searchHandler       *SearchHandler
+	summaryHandler      *SummaryHandler
+	authorHandler       *AuthorHandler
+	favoriteHandler     *FavoriteHandler
 	authService         *service.AuthService
+	apiKeyService       *service.APIKeyService
+	widgetService       *service.WidgetService
+	rateLimitService    *service.RateLimitService
+	subscriptionService *service.SubscriptionService
 	internalAPIKey      string
+	corsOrigins         []string
+	environment         string
 }
 
 // NewRouter creates a new Router
@@ -39,8 +80,47 @@ func NewRouter(
 	subscriptionHandler *SubscriptionHandler,
 	analyticsHandler *AnalyticsHandler,
 	earningsHandler *EarningsHandler,
+	memoryHandler *MemoryHandler,
+	learningHandler *LearningHandler,
+	adminHandler *AdminHandler,
+	taskHandler *TaskHandler,
+	scheduleHandler *ScheduleHandler,
+	apiKeyHandler *APIKeyHandler,
+	webhookHandler *WebhookHandler,
+	widgetHandler *WidgetHandler,
+	oauthHandler *OAuthHandler,
+	officeHandler *OfficeHandler,
+	attachmentHandler *AttachmentHandler,
+	transcriptHandler *TranscriptHandler,
+	exportHandler *ExportHandler,
+	performanceHandler *PerformanceHandler,
+	templateViewHandler *TemplateViewHandler,
+	documentHandler *DocumentHandler,
+	notificationHandler *NotificationHandler,
+	modelPolicyHandler *ModelPolicyHandler,
+	billingHandler *BillingHandler,
+	auditHandler *AuditHandler,
+	privacyHandler *PrivacyHandler,
+	secretHandler *SecretHandler,
+	webResearchHandler *WebResearchHandler,
+	knowledgeHandler *KnowledgeHandler,
+	skillHandler *SkillHandler,
+	onboardingHandler *OnboardingHandler,
+	departmentHandler *DepartmentHandler,
+	taskTemplateHandler *TaskTemplateHandler,
+	projectHandler *ProjectHandler,
+	searchHandler *SearchHandler,
+	summaryHandler *SummaryHandler,
+	authorHandler *AuthorHandler,
+	favoriteHandler *FavoriteHandler,
 	authService *service.AuthService,
+	apiKeyService *service.APIKeyService,
+	widgetService *service.WidgetService,
+	rateLimitService *service.RateLimitService,
+	subscriptionService *service.SubscriptionService,
 	internalAPIKey string,
+	corsOrigins []string,
+	environment string,
 ) *Router {
 	return &Router{
 		authHandler:         authHandler,
@@ -54,21 +134,70 @@ func NewRouter(
 		subscriptionHandler: subscriptionHandler,
 		analyticsHandler:    analyticsHandler,
 		earningsHandler:     earningsHandler,
+		memoryHandler:       memoryHandler,
+		learningHandler:     learningHandler,
+		adminHandler:        adminHandler,
+		taskHandler:         taskHandler,
+		scheduleHandler:     scheduleHandler,
+		apiKeyHandler:       apiKeyHandler,
+		webhookHandler:      webhookHandler,
+		widgetHandler:       widgetHandler,
+		oauthHandler:        oauthHandler,
+		officeHandler:       officeHandler,
+		attachmentHandler:   attachmentHandler,
+		transcriptHandler:   transcriptHandler,
+		exportHandler:       exportHandler,
+		performanceHandler:  performanceHandler,
+		templateViewHandler: templateViewHandler,
+		documentHandler:     documentHandler,
+		notificationHandler: notificationHandler,
+		modelPolicyHandler:  modelPolicyHandler,
+		billingHandler:      billingHandler,
+		auditHandler:        auditHandler,
+		privacyHandler:      privacyHandler,
+		secretHandler:       secretHandler,
+		webResearchHandler:  webResearchHandler,
+		knowledgeHandler:    knowledgeHandler,
+		skillHandler:        skillHandler,
+		onboardingHandler:   onboardingHandler,
+		departmentHandler:   departmentHandler,
+		taskTemplateHandler: taskTemplateHandler,
+		projectHandler:      projectHandler,
+		searchHandler:       searchHandler,
+		summaryHandler:      summaryHandler,
+		authorHandler:       authorHandler,
+		favoriteHandler:     favoriteHandler,
 		authService:         authService,
+		apiKeyService:       apiKeyService,
+		widgetService:       widgetService,
+		rateLimitService:    rateLimitService,
+		subscriptionService: subscriptionService,
 		internalAPIKey:      internalAPIKey,
+		corsOrigins:         corsOrigins,
+		environment:         environment,
 	}
 }
 
+// isWidgetRoute reports whether a request is for the public chat widget
+// endpoints
+func isWidgetRoute(c *fiber.Ctx) bool {
+	return strings.HasPrefix(c.Path(), "/api/v1/public/widget/")
+}
+
 // Setup configures all routes
 func (r *Router) Setup(app *fiber.App) {
 	// Middleware
 	app.Use(logger.New())
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		// Chat widgets are embedded on any site; their routes set their own
+		Next:          isWidgetRoute,
+		AllowOrigins:  strings.Join(r.corsOrigins, ","),
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Session-ID",
+		ExposeHeaders: "X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,Retry-After",
 	}))
+	app.Use(AuditActorMiddleware())
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -78,36 +207,101 @@ func (r *Router) Setup(app *fiber.App) {
 	// API v1
 	v1 := app.Group("/api/v1")
 
-	// Public routes
+	// Public routes, limited per client to slow password guessing
+	authLimit := AuthRateLimitMiddleware(r.rateLimitService)
 	auth := v1.Group("/auth")
-	auth.Post("/register", r.authHandler.Register)
-	auth.Post("/login", r.authHandler.Login)
+	auth.Post("/register", authLimit, r.authHandler.Register)
+	auth.Post("/login", authLimit, r.authHandler.Login)
+	auth.Post("/forgot-password", authLimit, r.authHandler.ForgotPassword)
+	auth.Post("/reset-password", authLimit, r.authHandler.ResetPassword)
+	auth.Post("/verify-email", authLimit, r.authHandler.VerifyEmail)
+	auth.Get("/oauth", r.oauthHandler.GetProviders)
+	auth.Get("/oauth/:provider", authLimit, r.oauthHandler.Start)
+	auth.Get("/oauth/:provider/callback", authLimit, r.oauthHandler.Callback)
 
 	// Marketplace routes (public for browsing)
 	marketplace := v1.Group("/marketplace")
 	marketplace.Get("/agents", r.marketplaceHandler.ListAgents)
 	marketplace.Get("/agents/:id", r.marketplaceHandler.GetAgentDetails)
 	marketplace.Get("/agents/:id/reviews", r.marketplaceHandler.GetReviews)
+	marketplace.Get("/agents/:id/versions", r.marketplaceHandler.GetVersions)
 	marketplace.Get("/featured", r.marketplaceHandler.GetFeaturedAgents)
 	marketplace.Get("/categories", r.marketplaceHandler.GetCategories)
 	marketplace.Get("/search", r.marketplaceHandler.SearchAgents)
+	marketplace.Get("/bundles", r.marketplaceHandler.ListBundles)
+	marketplace.Get("/bundles/:id", r.marketplaceHandler.GetBundleDetails)
+	marketplace.Get("/authors/:id", r.authorHandler.GetAuthorProfile)
+
+	// Impressions and views are counted once a day per visitor, who is the
+	// signed in user when the request carries a valid token
+	optionalAuth := OptionalAuthMiddleware(r.authService)
+	marketplace.Post("/agents/:id/view", optionalAuth, r.templateViewHandler.RecordView)
+	marketplace.Post("/impressions", optionalAuth, r.templateViewHandler.RecordImpressions)
+
+	// Attachment downloads (public, verified by the URL's signature)
+	v1.Get("/attachments/:id/content", r.attachmentHandler.GetAttachmentContent)
+
+	// Chat widget (public, authenticated by the widget token in the path).
+	// Registered before the protected routes so their middleware is skipped.
+	widgetToken := WidgetTokenMiddleware(r.widgetService, r.rateLimitService)
+	widget := v1.Group("/public/widget/:token", cors.New(cors.Config{
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept",
+		ExposeHeaders: "X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,Retry-After",
+	}), widgetToken)
+	widget.Post("/messages", r.widgetHandler.SendVisitorMessage)
+	widget.Get("/messages", r.widgetHandler.GetVisitorMessages)
+
+	// Stripe webhook (public, verified by signature). Registered before the
+	// protected routes so their middleware is skipped.
+	v1.Post("/webhooks/stripe", r.subscriptionHandler.HandleStripeWebhook)
+
+	// API description (public, no JWT)
+	spec := r.setupDocs(v1)
 
 	// Internal routes (for service-to-service communication)
 	// IMPORTANT: Must be defined BEFORE protected routes to avoid JWT middleware
 	internal := v1.Group("/internal")
 	internal.Use(InternalAPIKeyMiddleware(r.internalAPIKey))
 	internal.Post("/task-complete", r.internalHandler.TaskComplete)
+	internal.Post("/task-stream-chunk", r.internalHandler.TaskStreamChunk)
+	internal.Post("/documents", r.internalHandler.SaveDocument)
 	// Credit routes for orchestrator
 	internal.Post("/credits/check", r.internalHandler.CheckCredits)
 	internal.Post("/credits/consume", r.internalHandler.ConsumeCredits)
 	internal.Get("/credits/balance/:officeId", r.internalHandler.GetBalance)
+	internal.Get("/metrics/connections", r.wsHandler.GetConnectionMetrics)
+	internal.Post("/analytics/backfill", r.analyticsHandler.BackfillUsage)
+	internal.Get("/tasks/:id/secrets", r.secretHandler.GetTaskSecrets)
+	internal.Put("/memories/:id/embedding", r.memoryHandler.StoreEmbedding)
+	internal.Post("/agents/:id/memories/search", r.memoryHandler.RetrieveMemories)
+	internal.Post("/web-research/lookup", r.webResearchHandler.Lookup)
+	internal.Post("/web-research/searches", r.webResearchHandler.LogSearch)
 
 	// Protected routes
 	protected := v1.Group("")
-	protected.Use(AuthMiddleware(r.authService))
+	protected.Use(AuthMiddleware(r.authService, r.apiKeyService), RateLimitMiddleware(r.rateLimitService))
 
 	// Auth routes (protected)
 	protected.Get("/auth/me", r.authHandler.Me)
+	protected.Put("/auth/me", SessionOnlyMiddleware(), r.authHandler.UpdateProfile)
+	protected.Delete("/auth/me", SessionOnlyMiddleware(), r.authHandler.DeleteAccount)
+	protected.Put("/auth/password", SessionOnlyMiddleware(), authLimit, r.authHandler.ChangePassword)
+	protected.Post("/auth/resend-verification", SessionOnlyMiddleware(), authLimit, r.authHandler.ResendVerification)
+	protected.Post("/auth/switch-office", SessionOnlyMiddleware(), r.authHandler.SwitchOffice)
+	protected.Post("/auth/me/data-export", SessionOnlyMiddleware(), authLimit, r.privacyHandler.RequestDataExport)
+	protected.Get("/auth/me/data-exports", SessionOnlyMiddleware(), r.privacyHandler.ListDataExports)
+	protected.Get("/auth/me/data-exports/:id/download", SessionOnlyMiddleware(), r.privacyHandler.DownloadDataExport)
+	protected.Post("/auth/me/erase", SessionOnlyMiddleware(), authLimit, r.privacyHandler.EraseAccount)
+
+	// Office routes
+	protected.Get("/offices", r.officeHandler.GetOffices)
+	protected.Post("/offices/import", SessionOnlyMiddleware(), r.officeHandler.ImportOffice)
+	protected.Put("/offices/:id", r.officeHandler.UpdateOffice)
+	protected.Delete("/offices/:id", SessionOnlyMiddleware(), r.officeHandler.DeleteOffice)
+	protected.Post("/offices/:id/restore", SessionOnlyMiddleware(), r.officeHandler.RestoreOffice)
+	protected.Get("/offices/:id/export", SessionOnlyMiddleware(), r.officeHandler.ExportOffice)
 
 	// Agent routes
 	agents := protected.Group("/agents")
@@ -115,30 +309,113 @@ func (r *Router) Setup(app *fiber.App) {
 	agents.Post("/select", r.agentHandler.SelectAgent)
 	agents.Post("/select-multiple", r.agentHandler.SelectMultipleAgents)
 	agents.Get("", r.agentHandler.GetAgents)
+	agents.Get("/performance", RequireFeature(r.subscriptionService, service.FeatureAnalytics), r.performanceHandler.GetLeaderboard)
 	agents.Get("/:id", r.agentHandler.GetAgent)
 	agents.Get("/:id/feedback-summary", r.feedbackHandler.GetAgentFeedbackSummary)
+	agents.Get("/:id/learning-stats", r.learningHandler.GetLearningStats)
 	agents.Get("/:id/memories", r.feedbackHandler.GetAgentMemories)
-	agents.Delete("/:id", r.agentHandler.DeactivateAgent)
+	agents.Get("/:id/memories/search", r.memoryHandler.SearchMemories)
+	agents.Post("/:id/memories", r.memoryHandler.CreateMemory)
+	agents.Put("/:id/memories/:memoryId", r.memoryHandler.UpdateMemory)
+	agents.Delete("/:id/memories/:memoryId", r.memoryHandler.DeleteMemory)
+	agents.Get("/:id/schedules", r.scheduleHandler.GetSchedules)
+	agents.Post("/:id/schedules", r.scheduleHandler.CreateSchedule)
+	agents.Get("/:id/schedules/:scheduleId", r.scheduleHandler.GetSchedule)
+	agents.Put("/:id/schedules/:scheduleId", r.scheduleHandler.UpdateSchedule)
+	agents.Delete("/:id/schedules/:scheduleId", r.scheduleHandler.DeleteSchedule)
+	agents.Get("/:id/schedules/:scheduleId/runs", r.scheduleHandler.GetScheduleRuns)
+	agents.Post("/:id/update-template", r.agentHandler.UpdateTemplate)
+	agents.Put("/:id", r.agentHandler.UpdateAgent)
+	agents.Get("/:id/changes", r.agentHandler.GetAgentChanges)
+	agents.Get("/:id/model-policy", r.modelPolicyHandler.GetAgentModelPolicy)
+	agents.Put("/:id/model-policy", r.modelPolicyHandler.SetAgentModelPolicy)
+	agents.Delete("/:id/model-policy", r.modelPolicyHandler.DeleteAgentModelPolicy)
+	agents.Get("/:id/skills", r.skillHandler.GetAgentSkills)
+	agents.Put("/:id/skills", r.skillHandler.UpdateAgentSkills)
+	agents.Put("/:id/manager", r.departmentHandler.SetManager)
+	agents.Delete("/:id", r.agentHandler.DeleteAgent)
+	agents.Post("/:id/restore", r.agentHandler.RestoreAgent)
+
+	// Onboarding starter teams
+	onboarding := protected.Group("/onboarding")
+	onboarding.Get("/presets", r.onboardingHandler.GetPresets)
+	onboarding.Post("/apply", r.onboardingHandler.ApplyPreset)
 
 	// Conversation routes
 	conversations := protected.Group("/conversations")
 	conversations.Post("", r.chatHandler.CreateConversation)
 	conversations.Get("", r.chatHandler.GetConversations)
 	conversations.Get("/:id", r.chatHandler.GetConversation)
+	conversations.Patch("/:id", r.chatHandler.UpdateConversation)
+	conversations.Delete("/:id", r.chatHandler.DeleteConversation)
+	conversations.Post("/:id/restore", r.chatHandler.RestoreConversation)
+	conversations.Patch("/:id/orchestration", r.chatHandler.UpdateOrchestration)
+	conversations.Post("/:id/participants", r.chatHandler.AddParticipant)
+	conversations.Post("/:id/read", r.chatHandler.MarkRead)
+	conversations.Delete("/:id/participants/:agentId", r.chatHandler.RemoveParticipant)
 	conversations.Post("/:id/messages", r.chatHandler.SendMessage)
 	conversations.Get("/:id/messages", r.chatHandler.GetMessages)
+	conversations.Post("/:id/attachments", r.attachmentHandler.UploadAttachment)
+	conversations.Get("/:id/export", r.transcriptHandler.ExportConversation)
+	conversations.Post("/:id/summarize", r.summaryHandler.Summarize)
+	conversations.Get("/:id/summary", r.summaryHandler.GetSummary)
+
+	// Attachment routes
+	protected.Get("/attachments/:id", r.attachmentHandler.GetAttachmentURL)
+
+	// Document routes
+	documents := protected.Group("/documents")
+	documents.Get("", r.documentHandler.ListDocuments)
+	documents.Get("/:id", r.documentHandler.GetDocument)
+	documents.Get("/:id/versions", r.documentHandler.GetDocumentVersions)
+	documents.Get("/:id/download", r.documentHandler.DownloadDocument)
 
 	// Message feedback routes
 	messages := protected.Group("/messages")
+	messages.Patch("/:id", r.chatHandler.EditMessage)
+	messages.Delete("/:id", r.chatHandler.DeleteMessage)
+	messages.Get("/:id/thread", r.chatHandler.GetThread)
+	messages.Post("/:id/reactions", r.chatHandler.AddReaction)
+	messages.Delete("/:id/reactions/:emoji", r.chatHandler.RemoveReaction)
 	messages.Post("/:id/feedback", r.feedbackHandler.CreateMessageFeedback)
 
+	// Task routes
+	tasks := protected.Group("/tasks")
+	tasks.Get("", r.taskHandler.ListTasks)
+	tasks.Get("/:id", r.taskHandler.GetTask)
+	tasks.Get("/:id/subtasks", r.taskHandler.GetSubtasks)
+	tasks.Delete("/:id", r.taskHandler.CancelTask)
+	tasks.Post("/:id/cancel", r.taskHandler.CancelTask)
+	tasks.Post("/:id/retry", r.taskHandler.RetryTask)
+
+	// Notification routes
+	notifications := protected.Group("/notifications")
+	notifications.Get("", r.notificationHandler.ListNotifications)
+	notifications.Post("/read-all", r.notificationHandler.MarkAllNotificationsRead)
+	notifications.Get("/preferences", r.notificationHandler.GetNotificationPreferences)
+	notifications.Put("/preferences", r.notificationHandler.UpdateNotificationPreferences)
+	notifications.Post("/:id/read", r.notificationHandler.MarkNotificationRead)
+
 	// Credit routes (protected)
 	credits := protected.Group("/credits")
 	credits.Get("/wallet", r.creditHandler.GetWallet)
 	credits.Get("/balance", r.creditHandler.GetBalance)
 	credits.Get("/summary", r.creditHandler.GetWalletSummary)
 	credits.Get("/transactions", r.creditHandler.GetTransactions)
+	credits.Get("/transactions/export", r.exportHandler.ExportTransactions)
 	credits.Post("/check", r.creditHandler.CheckBalance)
+	credits.Post("/estimate", r.creditHandler.EstimateCost)
+	credits.Post("/redeem", SessionOnlyMiddleware(), r.creditHandler.RedeemPromoCode)
+	credits.Get("/redemptions", r.creditHandler.GetPromoRedemptions)
+	credits.Put("/low-balance", SessionOnlyMiddleware(), r.creditHandler.UpdateLowBalance)
+	credits.Get("/auto-top-ups", r.creditHandler.GetAutoTopUps)
+
+	// Model policy routes
+	modelPolicies := protected.Group("/model-policies")
+	modelPolicies.Get("", r.modelPolicyHandler.ListModelPolicies)
+	modelPolicies.Get("/office", r.modelPolicyHandler.GetOfficeModelPolicy)
+	modelPolicies.Put("/office", r.modelPolicyHandler.SetOfficeModelPolicy)
+	modelPolicies.Delete("/office", r.modelPolicyHandler.DeleteOfficeModelPolicy)
 
 	// Subscription routes
 	subscription := protected.Group("/subscription")
@@ -147,31 +424,185 @@ func (r *Router) Setup(app *fiber.App) {
 	subscription.Get("/tiers", r.subscriptionHandler.GetTiers)
 	subscription.Get("/tiers/:tier", r.subscriptionHandler.GetTier)
 	subscription.Post("/upgrade", r.subscriptionHandler.UpgradeTier)
+	subscription.Post("/downgrade", r.subscriptionHandler.DowngradeTier)
+	subscription.Delete("/downgrade", r.subscriptionHandler.CancelDowngrade)
+	subscription.Post("/cancel", r.subscriptionHandler.CancelSubscription)
+	subscription.Post("/pause", r.subscriptionHandler.PauseSubscription)
+	subscription.Post("/resume", r.subscriptionHandler.ResumeSubscription)
 	subscription.Post("/check-model-access", r.subscriptionHandler.CheckModelAccess)
 
-	// Stripe webhook (public, verified by signature)
-	v1.Post("/webhooks/stripe", r.subscriptionHandler.HandleStripeWebhook)
+	// Billing routes
+	billing := protected.Group("/billing")
+	billing.Get("/invoices", r.billingHandler.ListInvoices)
+	billing.Get("/invoices/:id/pdf", r.billingHandler.DownloadInvoicePDF)
+	billing.Get("/upcoming", r.billingHandler.GetUpcomingInvoice)
+
+	// Audit log routes
+	protected.Get("/audit-log", SessionOnlyMiddleware(), r.auditHandler.ListAuditLog)
 
 	// Usage analytics routes
-	usage := protected.Group("/usage")
+	usage := protected.Group("/usage", RequireFeature(r.subscriptionService, service.FeatureAnalytics))
 	usage.Get("/summary", r.analyticsHandler.GetUsageSummary)
 	usage.Get("/breakdown", r.analyticsHandler.GetUsageBreakdown)
 	usage.Get("/daily", r.analyticsHandler.GetDailyUsage)
 	usage.Get("/by-model", r.analyticsHandler.GetModelUsage)
 	usage.Get("/by-agent", r.analyticsHandler.GetAgentUsage)
+	usage.Get("/export", r.exportHandler.ExportUsage)
 
 	// Marketplace routes (protected for reviews and purchases)
 	protectedMarketplace := protected.Group("/marketplace")
 	protectedMarketplace.Post("/agents/:id/reviews", r.marketplaceHandler.CreateReview)
+	protectedMarketplace.Put("/agents/:id/reviews/:reviewId", r.marketplaceHandler.UpdateReview)
+	protectedMarketplace.Delete("/agents/:id/reviews/:reviewId", r.marketplaceHandler.DeleteReview)
+	protectedMarketplace.Post("/agents/:id/reviews/:reviewId/vote", r.marketplaceHandler.VoteReview)
+	protectedMarketplace.Delete("/agents/:id/reviews/:reviewId/vote", r.marketplaceHandler.RemoveReviewVote)
+	protectedMarketplace.Post("/agents/:id/reviews/:reviewId/reply", r.marketplaceHandler.ReplyToReview)
+	protectedMarketplace.Put("/agents/:id/reviews/:reviewId/reply", r.marketplaceHandler.UpdateReviewReply)
+	protectedMarketplace.Post("/agents/:id/favorite", r.favoriteHandler.AddFavorite)
+	protectedMarketplace.Delete("/agents/:id/favorite", r.favoriteHandler.RemoveFavorite)
+	protectedMarketplace.Get("/favorites", r.favoriteHandler.ListFavorites)
 	protectedMarketplace.Post("/purchase", r.earningsHandler.PurchaseTemplate)
+	protectedMarketplace.Post("/purchase/credits", r.earningsHandler.PurchaseTemplateWithCredits)
+	protectedMarketplace.Post("/purchase/bundle", r.earningsHandler.PurchaseBundle)
+	protectedMarketplace.Get("/agents/:id/credit-price", r.earningsHandler.GetCreditPrice)
+	protectedMarketplace.Get("/purchases", r.earningsHandler.GetPurchases)
+	protectedMarketplace.Post("/purchases/:id/refund-request", r.earningsHandler.RequestRefund)
+	protectedMarketplace.Get("/refund-requests", r.earningsHandler.GetRefundRequests)
+	protectedMarketplace.Post("/templates", r.marketplaceHandler.SubmitTemplate)
+	protectedMarketplace.Put("/templates/:id", r.marketplaceHandler.UpdateTemplate)
+	protectedMarketplace.Post("/templates/:id/versions", r.marketplaceHandler.PublishVersion)
+	protectedMarketplace.Post("/bundles", r.marketplaceHandler.CreateBundle)
+	protectedMarketplace.Put("/bundles/:id", r.marketplaceHandler.UpdateBundle)
 
 	// Author earnings routes
 	author := protected.Group("/author")
 	author.Get("/earnings", r.earningsHandler.GetAuthorEarnings)
+	author.Get("/earnings/export", r.exportHandler.ExportEarnings)
 	author.Get("/balance", r.earningsHandler.GetAuthorBalance)
 	author.Get("/summary", r.earningsHandler.GetEarningsSummary)
 	author.Post("/payout/request", r.earningsHandler.RequestPayout)
 	author.Get("/payouts", r.earningsHandler.GetPayoutRequests)
+	author.Get("/templates", r.marketplaceHandler.GetAuthorTemplates)
+	author.Get("/templates/analytics", r.templateViewHandler.GetAuthorAnalytics)
+	author.Get("/bundles", r.marketplaceHandler.GetAuthorBundles)
+	author.Get("/profile", r.authorHandler.GetProfile)
+	author.Put("/profile", r.authorHandler.UpdateProfile)
+	author.Post("/verification", r.authorHandler.RequestVerification)
+
+	// API key management (signed-in users only, not other API keys)
+	apiKeys := protected.Group("/api-keys", SessionOnlyMiddleware())
+	apiKeys.Post("", r.apiKeyHandler.CreateAPIKey)
+	apiKeys.Get("", r.apiKeyHandler.GetAPIKeys)
+	apiKeys.Delete("/:id", r.apiKeyHandler.RevokeAPIKey)
+
+	// Chat widget tokens (signed-in users only)
+	widgetTokens := protected.Group("/widget-tokens", SessionOnlyMiddleware())
+	widgetTokens.Post("", r.widgetHandler.CreateWidgetToken)
+	widgetTokens.Get("", r.widgetHandler.GetWidgetTokens)
+	widgetTokens.Delete("/:id", r.widgetHandler.RevokeWidgetToken)
+
+	// Outbound webhook management
+	webhooks := protected.Group("/webhooks")
+	webhooks.Post("", r.webhookHandler.CreateWebhook)
+	webhooks.Get("", r.webhookHandler.GetWebhooks)
+	webhooks.Delete("/:id", r.webhookHandler.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", r.webhookHandler.GetDeliveries)
+
+	// Secrets vault (signed-in users only, not API keys)
+	secrets := protected.Group("/secrets", SessionOnlyMiddleware())
+	secrets.Post("", r.secretHandler.CreateSecret)
+	secrets.Get("", r.secretHandler.GetSecrets)
+	secrets.Put("/:id", r.secretHandler.UpdateSecret)
+	secrets.Delete("/:id", r.secretHandler.DeleteSecret)
+
+	// Web research settings
+	webResearch := protected.Group("/web-research", RequireFeature(r.subscriptionService, service.FeatureWebResearch))
+	webResearch.Get("/settings", r.webResearchHandler.GetSettings)
+	webResearch.Put("/settings", r.webResearchHandler.UpdateSettings)
+
+	// Office knowledge base
+	knowledge := protected.Group("/knowledge")
+	knowledge.Post("", r.knowledgeHandler.CreateDocument)
+	knowledge.Get("", r.knowledgeHandler.GetDocuments)
+	knowledge.Get("/search", r.knowledgeHandler.Search)
+	knowledge.Get("/:id", r.knowledgeHandler.GetDocument)
+	knowledge.Delete("/:id", r.knowledgeHandler.DeleteDocument)
+
+	// Skills catalog; agents' skills are under /agents
+	protected.Get("/skills", r.skillHandler.GetCatalog)
+
+	// Departments and reporting lines; agents' managers are under /agents
+	departments := protected.Group("/departments")
+	departments.Post("", r.departmentHandler.CreateDepartment)
+	departments.Get("", r.departmentHandler.GetDepartments)
+	departments.Get("/:id", r.departmentHandler.GetDepartment)
+	departments.Patch("/:id", r.departmentHandler.UpdateDepartment)
+	departments.Delete("/:id", r.departmentHandler.DeleteDepartment)
+	departments.Post("/:id/members", r.departmentHandler.AddMember)
+	departments.Delete("/:id/members/:agentId", r.departmentHandler.RemoveMember)
+	protected.Get("/org-chart", r.departmentHandler.GetOrgChart)
+
+	// Task templates, dispatched to agents once or on a schedule
+	taskTemplates := protected.Group("/task-templates")
+	taskTemplates.Post("", r.taskTemplateHandler.CreateTemplate)
+	taskTemplates.Get("", r.taskTemplateHandler.GetTemplates)
+	taskTemplates.Get("/:id", r.taskTemplateHandler.GetTemplate)
+	taskTemplates.Put("/:id", r.taskTemplateHandler.UpdateTemplate)
+	taskTemplates.Delete("/:id", r.taskTemplateHandler.DeleteTemplate)
+	taskTemplates.Post("/:id/run", r.taskTemplateHandler.RunTemplate)
+	taskTemplates.Post("/:id/schedules", r.taskTemplateHandler.ScheduleTemplate)
+	taskTemplates.Get("/:id/schedules", r.taskTemplateHandler.GetTemplateSchedules)
+
+	// Project boards over the office's tasks
+	projects := protected.Group("/projects")
+	projects.Post("", r.projectHandler.CreateProject)
+	projects.Get("", r.projectHandler.GetProjects)
+	projects.Get("/:id", r.projectHandler.GetBoard)
+	projects.Put("/:id", r.projectHandler.UpdateProject)
+	projects.Delete("/:id", r.projectHandler.DeleteProject)
+	projects.Post("/:id/columns", r.projectHandler.CreateColumn)
+	projects.Put("/:id/columns/:columnId", r.projectHandler.UpdateColumn)
+	projects.Delete("/:id/columns/:columnId", r.projectHandler.DeleteColumn)
+	projects.Post("/:id/cards", r.projectHandler.AddCard)
+	projects.Put("/:id/cards/:taskId", r.projectHandler.MoveCard)
+	projects.Delete("/:id/cards/:taskId", r.projectHandler.RemoveCard)
+
+	// Search across the office's messages, task outputs and documents
+	protected.Get("/search", r.searchHandler.Search)
+
+	// Admin routes (admin role required)
+	admin := protected.Group("/admin", SessionOnlyMiddleware(), AdminMiddleware(r.authService))
+	admin.Get("/marketplace/pending", r.adminHandler.GetPendingTemplates)
+	admin.Post("/marketplace/templates/:id/approve", r.adminHandler.ApproveTemplate)
+	admin.Post("/marketplace/templates/:id/reject", r.adminHandler.RejectTemplate)
+	admin.Get("/moderation/flags", r.adminHandler.ListModerationFlags)
+	admin.Post("/moderation/flags/:id/resolve", r.adminHandler.ResolveModerationFlag)
+	admin.Post("/retention/runs", r.adminHandler.RunRetention)
+	admin.Get("/retention/runs", r.adminHandler.ListRetentionRuns)
+	admin.Get("/retention/runs/:id", r.adminHandler.GetRetentionRun)
+	admin.Get("/users", r.adminHandler.SearchUsers)
+	admin.Get("/offices", r.adminHandler.SearchOffices)
+	admin.Get("/offices/:id", r.adminHandler.GetOffice)
+	admin.Get("/offices/:id/transactions", r.adminHandler.GetOfficeTransactions)
+	admin.Post("/offices/:id/credits", r.adminHandler.AdjustCredits)
+	admin.Put("/offices/:id/subscription", r.adminHandler.ChangeTier)
+	admin.Post("/tiers/reload", r.adminHandler.ReloadTiers)
+	admin.Get("/tasks/failed", r.adminHandler.ListFailedTasks)
+	admin.Get("/payouts", r.adminHandler.ListPayouts)
+	admin.Post("/payouts/:id/complete", r.adminHandler.CompletePayout)
+	admin.Post("/payouts/:id/reject", r.adminHandler.RejectPayout)
+	admin.Get("/refunds", r.adminHandler.ListRefunds)
+	admin.Post("/refunds/:id/approve", r.adminHandler.ApproveRefund)
+	admin.Post("/refunds/:id/reject", r.adminHandler.RejectRefund)
+	admin.Get("/authors/verifications", r.adminHandler.ListAuthorVerifications)
+	admin.Post("/authors/:id/verify", r.adminHandler.VerifyAuthor)
+	admin.Post("/authors/:id/reject-verification", r.adminHandler.RejectAuthorVerification)
+	admin.Post("/authors/:id/revoke-verification", r.adminHandler.RevokeAuthorVerification)
+	admin.Get("/promo-codes", r.adminHandler.ListPromoCodes)
+	admin.Post("/promo-codes", r.adminHandler.CreatePromoCode)
+	admin.Get("/promo-codes/:id/redemptions", r.adminHandler.ListPromoRedemptions)
+	admin.Post("/promo-codes/:id/deactivate", r.adminHandler.DeactivatePromoCode)
+	admin.Get("/audit-log", r.adminHandler.ListAuditLog)
 
 	// WebSocket route (with upgrade middleware)
 	app.Use("/ws", func(c *fiber.Ctx) error {
@@ -181,4 +612,7 @@ func (r *Router) Setup(app *fiber.App) {
 		return fiber.ErrUpgradeRequired
 	})
 	app.Get("/ws", websocket.New(r.wsHandler.HandleWS))
+	app.Get("/ws/widget/:token", widgetToken, websocket.New(r.wsHandler.HandleWidgetWS))
+
+	checkDocs(app, spec)
 }
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStripeWebhookSkipsSessionAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	subs := mocks.NewMockSubscriptionRepository(ctrl)
+	subService := service.NewSubscriptionService(subs, mocks.NewMockCreditRepository(ctrl), mocks.NewMockAgentRepository(ctrl),
+		mocks.NewMockTxManager(ctrl), nil, nil, nil, "../config/subscription_tiers.yaml")
+	router := &Router{subscriptionHandler: NewSubscriptionHandler(subService, "whsec_test")}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	router.Setup(app)
+
+	// Reaching the repository shows the event was processed
+	subs.EXPECT().GetByStripeID(gomock.Any(), "sub_123").Return(nil, domain.ErrNotFound)
+
+	payload := `{"type":"invoice.payment_failed","data":{"object":{"id":"in_123","subscription":"sub_123"}}}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(timestamp + "." + payload))
+
+	req := httptest.NewRequest("POST", "/api/v1/webhooks/stripe", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST /api/v1/webhooks/stripe = %d %s, want 200", resp.StatusCode, body)
+	}
+}
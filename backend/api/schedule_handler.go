@@ -0,0 +1,211 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ScheduleHandler handles scheduled agent work endpoints
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+// NewScheduleHandler creates a new ScheduleHandler
+func NewScheduleHandler(scheduleService *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{scheduleService: scheduleService}
+}
+
+// CreateScheduleRequest represents a request to schedule agent work. Set
+// cron_expression for recurring work or run_at for a one-off run.
+type CreateScheduleRequest struct {
+	ConversationID uuid.UUID  `json:"conversation_id" validate:"required"`
+	Name           string     `json:"name" validate:"required,max=255"`
+	Input          string     `json:"input" validate:"required"`
+	CronExpression string     `json:"cron_expression,omitempty" validate:"required_without=RunAt,excluded_with=RunAt"`
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	Timezone       string     `json:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+// UpdateScheduleRequest represents a partial update to a schedule
+type UpdateScheduleRequest struct {
+	ConversationID *uuid.UUID `json:"conversation_id,omitempty"`
+	Name           *string    `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Input          *string    `json:"input,omitempty" validate:"omitempty,min=1"`
+	CronExpression *string    `json:"cron_expression,omitempty"`
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	Timezone       *string    `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	IsActive       *bool      `json:"is_active,omitempty"`
+}
+
+// GetSchedules returns all schedules of an agent
+// GET /agents/:id/schedules
+func (h *ScheduleHandler) GetSchedules(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	schedules, err := h.scheduleService.GetSchedules(c.Context(), officeID, agentID)
+	if err != nil {
+		return scheduleError(err)
+	}
+	if schedules == nil {
+		schedules = []*domain.ScheduledTask{}
+	}
+
+	return c.JSON(schedules)
+}
+
+// CreateSchedule schedules work for an agent
+// POST /agents/:id/schedules
+func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req CreateScheduleRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(c.Context(), service.CreateScheduleInput{
+		OfficeID:       officeID,
+		AgentID:        agentID,
+		ConversationID: req.ConversationID,
+		Name:           req.Name,
+		Input:          req.Input,
+		CronExpression: req.CronExpression,
+		RunAt:          req.RunAt,
+		Timezone:       req.Timezone,
+	})
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// GetSchedule returns a single schedule of an agent
+// GET /agents/:id/schedules/:scheduleId
+func (h *ScheduleHandler) GetSchedule(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, scheduleID, err := scheduleParams(c)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(c.Context(), officeID, agentID, scheduleID)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return c.JSON(schedule)
+}
+
+// UpdateSchedule edits a schedule, including pausing and resuming it
+// PUT /agents/:id/schedules/:scheduleId
+func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, scheduleID, err := scheduleParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateScheduleRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	schedule, err := h.scheduleService.UpdateSchedule(c.Context(), officeID, agentID, scheduleID, service.UpdateScheduleInput{
+		ConversationID: req.ConversationID,
+		Name:           req.Name,
+		Input:          req.Input,
+		CronExpression: req.CronExpression,
+		RunAt:          req.RunAt,
+		Timezone:       req.Timezone,
+		IsActive:       req.IsActive,
+	})
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return c.JSON(schedule)
+}
+
+// DeleteSchedule removes a schedule
+// DELETE /agents/:id/schedules/:scheduleId
+func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, scheduleID, err := scheduleParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.scheduleService.DeleteSchedule(c.Context(), officeID, agentID, scheduleID); err != nil {
+		return scheduleError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetScheduleRuns returns the run history of a schedule
+// GET /agents/:id/schedules/:scheduleId/runs
+func (h *ScheduleHandler) GetScheduleRuns(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, scheduleID, err := scheduleParams(c)
+	if err != nil {
+		return err
+	}
+
+	limit := 20
+	offset := 0
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.Query("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	runs, total, err := h.scheduleService.GetRuns(c.Context(), officeID, agentID, scheduleID, limit, offset)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return c.JSON(newPage(runs, total, limit, nil))
+}
+
+// scheduleParams extracts the agent and schedule IDs for schedule routes
+func scheduleParams(c *fiber.Ctx) (agentID, scheduleID uuid.UUID, err error) {
+	if agentID, err = uuid.Parse(c.Params("id")); err != nil {
+		return agentID, scheduleID, badRequest("invalid agent id")
+	}
+	if scheduleID, err = uuid.Parse(c.Params("scheduleId")); err != nil {
+		return agentID, scheduleID, badRequest("invalid schedule id")
+	}
+	return agentID, scheduleID, nil
+}
+
+// scheduleError maps schedule service errors to API errors
+func scheduleError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent or schedule not found")
+	default:
+		return internalError("failed to manage schedule", err)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SearchHandler handles the office search endpoint
+type SearchHandler struct {
+	searchService *service.SearchService
+}
+
+// NewSearchHandler creates a new SearchHandler
+func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search finds the office's messages, task outputs and documents matching
+// q, optionally of the comma-separated types or by an agent
+// GET /search
+func (h *SearchHandler) Search(c *fiber.Ctx) error {
+	filter := domain.SearchFilter{
+		OfficeID: c.Locals("office_id").(uuid.UUID),
+		Query:    c.Query("q"),
+	}
+	if raw := c.Query("type"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			filter.Types = append(filter.Types, domain.SearchResultType(strings.TrimSpace(t)))
+		}
+	}
+	if raw := c.Query("agent_id"); raw != "" {
+		agentID, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid agent_id")
+		}
+		filter.AgentID = agentID
+	}
+
+	page, err := parsePageRequest(c, 20, 50)
+	if err != nil {
+		return err
+	}
+
+	results, total, err := h.searchService.Search(c.Context(), filter, page)
+	if err != nil {
+		return internalError("failed to search the office", err)
+	}
+
+	return c.JSON(newPage(results, total, page.Limit, nil))
+}
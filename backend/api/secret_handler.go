@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SecretHandler handles the office secrets vault endpoints
+type SecretHandler struct {
+	secretService *service.SecretService
+}
+
+// NewSecretHandler creates a new SecretHandler
+func NewSecretHandler(secretService *service.SecretService) *SecretHandler {
+	return &SecretHandler{secretService: secretService}
+}
+
+// CreateSecretRequest represents a request to store a secret
+type CreateSecretRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	Value       string `json:"value" validate:"required,max=8192"`
+	Description string `json:"description,omitempty" validate:"max=500"`
+	// AgentIDs limits the secret to these agents; omit it for all agents
+	AgentIDs []uuid.UUID `json:"agent_ids,omitempty"`
+}
+
+// UpdateSecretRequest replaces a secret's value, description or agents;
+// omitted fields are left unchanged
+type UpdateSecretRequest struct {
+	Value       *string `json:"value,omitempty" validate:"omitempty,min=1,max=8192"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
+	// AgentIDs replaces the agents the secret is limited to; an empty list
+	// makes it available to all agents
+	AgentIDs []uuid.UUID `json:"agent_ids,omitempty"`
+}
+
+// CreateSecret stores a secret for the user's office
+// POST /secrets
+func (h *SecretHandler) CreateSecret(c *fiber.Ctx) error {
+	var req CreateSecretRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	secret, err := h.secretService.CreateSecret(c.Context(), service.CreateSecretInput{
+		OfficeID:    c.Locals("office_id").(uuid.UUID),
+		UserID:      c.Locals("user_id").(uuid.UUID),
+		Name:        req.Name,
+		Value:       req.Value,
+		Description: req.Description,
+		AgentIDs:    req.AgentIDs,
+	})
+	if err != nil {
+		return secretError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(secret)
+}
+
+// GetSecrets lists the office's secrets without their values
+// GET /secrets
+func (h *SecretHandler) GetSecrets(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	secrets, err := h.secretService.GetSecrets(c.Context(), officeID)
+	if err != nil {
+		return secretError(err)
+	}
+	if secrets == nil {
+		secrets = []*domain.OfficeSecret{}
+	}
+
+	return c.JSON(fiber.Map{"secrets": secrets})
+}
+
+// UpdateSecret changes one of the office's secrets
+// PUT /secrets/:id
+func (h *SecretHandler) UpdateSecret(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	secretID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid secret id")
+	}
+
+	var req UpdateSecretRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	secret, err := h.secretService.UpdateSecret(c.Context(), officeID, secretID, service.UpdateSecretInput{
+		Value:       req.Value,
+		Description: req.Description,
+		AgentIDs:    req.AgentIDs,
+	})
+	if err != nil {
+		return secretError(err)
+	}
+
+	return c.JSON(secret)
+}
+
+// DeleteSecret removes one of the office's secrets
+// DELETE /secrets/:id
+func (h *SecretHandler) DeleteSecret(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	secretID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid secret id")
+	}
+
+	if err := h.secretService.DeleteSecret(c.Context(), officeID, secretID); err != nil {
+		return secretError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetTaskSecrets hands the orchestrator the decrypted secrets a running
+// task's agent may use, optionally only those listed in ?names=
+// GET /internal/tasks/:id/secrets
+func (h *SecretHandler) GetTaskSecrets(c *fiber.Ctx) error {
+	taskID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	var names []string
+	for _, name := range strings.Split(c.Query("names"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	secrets, err := h.secretService.TaskSecrets(c.Context(), taskID, names)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return notFound("task not found")
+		}
+		return internalError("failed to get task secrets", err)
+	}
+	if secrets == nil {
+		secrets = []service.TaskSecret{}
+	}
+
+	// Decrypted values must not linger in caches
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{"secrets": secrets})
+}
+
+// secretError maps secret errors to API errors
+func secretError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("secret not found")
+	default:
+		return internalError("failed to manage secrets", err)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SkillHandler handles the skills catalog and agents' skill endpoints
+type SkillHandler struct {
+	skillService *service.SkillService
+}
+
+// NewSkillHandler creates a new SkillHandler
+func NewSkillHandler(skillService *service.SkillService) *SkillHandler {
+	return &SkillHandler{skillService: skillService}
+}
+
+// UpdateAgentSkillsRequest enables or disables skills by key; skills left
+// out keep their configuration
+type UpdateAgentSkillsRequest struct {
+	Skills map[string]bool `json:"skills" validate:"required"`
+}
+
+// GetCatalog lists every skill agents can be given
+// GET /skills
+func (h *SkillHandler) GetCatalog(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"skills": h.skillService.Catalog()})
+}
+
+// GetAgentSkills lists the catalog as configured for an agent
+// GET /agents/:id/skills
+func (h *SkillHandler) GetAgentSkills(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	skills, err := h.skillService.GetAgentSkills(c.Context(), officeID, agentID)
+	if err != nil {
+		return skillError(err)
+	}
+
+	return c.JSON(fiber.Map{"skills": skills})
+}
+
+// UpdateAgentSkills enables or disables skills for an agent
+// PUT /agents/:id/skills
+func (h *SkillHandler) UpdateAgentSkills(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	agentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid agent id")
+	}
+
+	var req UpdateAgentSkillsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	skills, err := h.skillService.UpdateAgentSkills(c.Context(), officeID, agentID, req.Skills)
+	if err != nil {
+		return skillError(err)
+	}
+
+	return c.JSON(fiber.Map{"skills": skills})
+}
+
+// skillError maps skill errors to API errors. Skills the office's tier does
+// not include are reported as the upgrade they require.
+func skillError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("agent not found")
+	default:
+		return internalError("failed to manage agent skills", err)
+	}
+}
@@ -1,6 +1,11 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/fiber/v2"
@@ -10,11 +15,14 @@ import (
 // SubscriptionHandler handles subscription API endpoints
 type SubscriptionHandler struct {
 	subService *service.SubscriptionService
+	// webhookSecret verifies Stripe's webhook signatures; without it no
+	// event is processed
+	webhookSecret string
 }
 
 // NewSubscriptionHandler creates a new subscription handler
-func NewSubscriptionHandler(subService *service.SubscriptionService) *SubscriptionHandler {
-	return &SubscriptionHandler{subService: subService}
+func NewSubscriptionHandler(subService *service.SubscriptionService, webhookSecret string) *SubscriptionHandler {
+	return &SubscriptionHandler{subService: subService, webhookSecret: webhookSecret}
 }
 
 // getOfficeID extracts office ID from context
@@ -35,16 +43,12 @@ func (h *SubscriptionHandler) getOfficeID(c *fiber.Ctx) (uuid.UUID, error) {
 func (h *SubscriptionHandler) GetSubscription(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	sub, err := h.subService.GetSubscriptionByOffice(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "subscription not found",
-		})
+		return notFound("subscription not found")
 	}
 
 	return c.JSON(sub)
@@ -55,43 +59,44 @@ func (h *SubscriptionHandler) GetSubscription(c *fiber.Ctx) error {
 func (h *SubscriptionHandler) GetSubscriptionSummary(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	summary, err := h.subService.GetSubscriptionSummary(c.Context(), officeID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(summary)
 }
 
-// GetTiers returns all available tiers
+// GetTiers returns the live tiers, lowest first
 // GET /api/v1/subscription/tiers
 func (h *SubscriptionHandler) GetTiers(c *fiber.Ctx) error {
-	tiers := h.subService.GetAllTiers()
+	return c.JSON(fiber.Map{"tiers": tierList(h.subService.ListTiers())})
+}
 
-	tierList := make([]fiber.Map, 0, len(tiers))
-	for tierID, def := range tiers {
-		priceMonthly := 0
-		if def.PriceMonthlyUSD != nil {
-			priceMonthly = int(*def.PriceMonthlyUSD * 100) // Convert to cents
+// tierList renders tiers for the tiers endpoints. Tiers sold on request
+// have no monthly price.
+func tierList(tiers []service.ListedTier) []fiber.Map {
+	list := make([]fiber.Map, len(tiers))
+	for i, tier := range tiers {
+		var priceMonthly *int
+		if tier.PriceMonthlyUSD != nil {
+			cents := int(math.Round(*tier.PriceMonthlyUSD * 100))
+			priceMonthly = &cents
 		}
 
-		tierList = append(tierList, fiber.Map{
-			"id":                  string(tierID),
-			"name":                def.Name,
+		list[i] = fiber.Map{
+			"id":                  string(tier.ID),
+			"name":                tier.Name,
+			"description":         tier.Description,
 			"price_monthly_cents": priceMonthly,
-			"credits_per_period":  def.Features.MonthlyCredits,
-			"features":            []string{def.Description}, // Simple feature list
-		})
+			"credits_per_period":  tier.Features.MonthlyCredits,
+			"features":            []string{tier.Description}, // Simple feature list
+		}
 	}
-
-	return c.JSON(fiber.Map{"tiers": tierList})
+	return list
 }
 
 // GetTier returns a specific tier definition
@@ -102,9 +107,7 @@ func (h *SubscriptionHandler) GetTier(c *fiber.Ctx) error {
 
 	def, err := h.subService.GetTier(tier)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "tier not found",
-		})
+		return notFound("tier not found")
 	}
 
 	return c.JSON(def)
@@ -112,7 +115,7 @@ func (h *SubscriptionHandler) GetTier(c *fiber.Ctx) error {
 
 // UpgradeRequest represents a tier upgrade request
 type UpgradeRequest struct {
-	Tier string `json:"tier"`
+	Tier string `json:"tier" validate:"required"`
 }
 
 // UpgradeTier upgrades the office's subscription tier
@@ -120,29 +123,21 @@ type UpgradeRequest struct {
 func (h *SubscriptionHandler) UpgradeTier(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	var req UpgradeRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	tier := domain.SubscriptionTier(req.Tier)
 	if _, err := h.subService.GetTier(tier); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid tier",
-		})
+		return badRequest("invalid tier")
 	}
 
 	if err := h.subService.UpgradeTier(c.Context(), officeID, tier); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -151,9 +146,136 @@ func (h *SubscriptionHandler) UpgradeTier(c *fiber.Ctx) error {
 	})
 }
 
+// DowngradeRequest represents a tier downgrade request
+type DowngradeRequest struct {
+	Tier string `json:"tier" validate:"required"`
+	// Immediate downgrades now, giving up the rest of the period's credits
+	Immediate bool `json:"immediate"`
+}
+
+// DowngradeTier moves the office to a lower tier, by default at the end of
+// the billing period
+// POST /api/v1/subscription/downgrade
+func (h *SubscriptionHandler) DowngradeTier(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	var req DowngradeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	tier := domain.SubscriptionTier(req.Tier)
+	if _, err := h.subService.GetTier(tier); err != nil {
+		return badRequest("invalid tier")
+	}
+
+	downgrade, err := h.subService.DowngradeTier(c.Context(), service.DowngradeInput{
+		OfficeID:  officeID,
+		Tier:      tier,
+		Immediate: req.Immediate,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(downgrade)
+}
+
+// CancelDowngrade cancels the office's scheduled downgrade
+// DELETE /api/v1/subscription/downgrade
+func (h *SubscriptionHandler) CancelDowngrade(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	err = h.subService.CancelDowngrade(c.Context(), officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("no downgrade is scheduled")
+	}
+	if err != nil {
+		return internalError("failed to cancel downgrade", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CancelSubscriptionRequest represents a subscription cancellation request
+type CancelSubscriptionRequest struct {
+	// Immediate ends the subscription now instead of at the end of the period
+	Immediate bool `json:"immediate"`
+}
+
+// CancelSubscription cancels the office's subscription, by default at the
+// end of the billing period
+// POST /api/v1/subscription/cancel
+func (h *SubscriptionHandler) CancelSubscription(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	var req CancelSubscriptionRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	sub, err := h.subService.CancelSubscription(c.Context(), officeID, req.Immediate)
+	if err != nil {
+		return subscriptionError(err, "failed to cancel subscription")
+	}
+
+	return c.JSON(sub)
+}
+
+// PauseSubscription pauses the office's subscription
+// POST /api/v1/subscription/pause
+func (h *SubscriptionHandler) PauseSubscription(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	sub, err := h.subService.PauseSubscription(c.Context(), officeID)
+	if err != nil {
+		return subscriptionError(err, "failed to pause subscription")
+	}
+
+	return c.JSON(sub)
+}
+
+// ResumeSubscription resumes the office's paused subscription
+// POST /api/v1/subscription/resume
+func (h *SubscriptionHandler) ResumeSubscription(c *fiber.Ctx) error {
+	officeID, err := h.getOfficeID(c)
+	if err != nil {
+		return unauthorized("office_id not found in context")
+	}
+
+	sub, err := h.subService.ResumeSubscription(c.Context(), officeID)
+	if err != nil {
+		return subscriptionError(err, "failed to resume subscription")
+	}
+
+	return c.JSON(sub)
+}
+
+// subscriptionError maps subscription errors to API errors
+func subscriptionError(err error, fallback string) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("subscription not found")
+	}
+	return internalError(fallback, err)
+}
+
 // CheckModelAccessRequest represents a model access check request
 type CheckModelAccessRequest struct {
-	Provider string `json:"provider"`
+	Provider string `json:"provider" validate:"required"`
 }
 
 // CheckModelAccess checks if office has access to a model provider
@@ -161,23 +283,17 @@ type CheckModelAccessRequest struct {
 func (h *SubscriptionHandler) CheckModelAccess(c *fiber.Ctx) error {
 	officeID, err := h.getOfficeID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "office_id not found in context",
-		})
+		return unauthorized("office_id not found in context")
 	}
 
 	var req CheckModelAccessRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	hasAccess, err := h.subService.CheckModelAccess(c.Context(), officeID, req.Provider)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -186,33 +302,32 @@ func (h *SubscriptionHandler) CheckModelAccess(c *fiber.Ctx) error {
 	})
 }
 
-// HandleStripeWebhook handles incoming Stripe webhook events
+// HandleStripeWebhook handles incoming Stripe webhook events, once their
+// signature checks out
 // POST /api/v1/webhooks/stripe
 func (h *SubscriptionHandler) HandleStripeWebhook(c *fiber.Ctx) error {
-	// TODO: Verify Stripe signature
-	// signature := c.Get("Stripe-Signature")
-	// webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if h.webhookSecret == "" {
+		// Refused rather than acknowledged, so Stripe retries once configured
+		return &Error{Status: fiber.StatusServiceUnavailable, Code: "webhook_not_configured", Message: "Stripe webhooks are not configured"}
+	}
+	if err := service.VerifyStripeSignature(c.Body(), c.Get("Stripe-Signature"), h.webhookSecret, time.Now()); err != nil {
+		return unauthorized("invalid Stripe signature")
+	}
 
 	var payload map[string]any
-	if err := c.BodyParser(&payload); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid payload",
-		})
+	if err := json.Unmarshal(c.Body(), &payload); err != nil {
+		return badRequest("invalid payload")
 	}
 
 	eventType, ok := payload["type"].(string)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "missing event type",
-		})
+		return badRequest("missing event type")
 	}
 
 	data, _ := payload["data"].(map[string]any)
 
 	if err := h.subService.ProcessStripeWebhook(c.Context(), eventType, data); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{"received": true})
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SummaryHandler handles conversation summary endpoints
+type SummaryHandler struct {
+	summaryService *service.SummaryService
+}
+
+// NewSummaryHandler creates a new SummaryHandler
+func NewSummaryHandler(summaryService *service.SummaryService) *SummaryHandler {
+	return &SummaryHandler{summaryService: summaryService}
+}
+
+// SummarizeRequest represents a request to summarize a conversation
+type SummarizeRequest struct {
+	// AgentID is the participant to summarize the conversation; omitted
+	// means the first participant
+	AgentID *uuid.UUID `json:"agent_id,omitempty"`
+}
+
+// Summarize has one of a conversation's agents summarize it. A new run is
+// accepted with 202; a pending run, or a recent summary, is returned with 200.
+// POST /conversations/:id/summarize
+func (h *SummaryHandler) Summarize(c *fiber.Ctx) error {
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	var req SummarizeRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+	input := service.SummarizeInput{
+		OfficeID:       c.Locals("office_id").(uuid.UUID),
+		ConversationID: conversationID,
+	}
+	if req.AgentID != nil {
+		input.AgentID = *req.AgentID
+	}
+
+	summary, started, err := h.summaryService.Summarize(c.Context(), input)
+	if err != nil {
+		return summaryError(err)
+	}
+	if started {
+		return c.Status(fiber.StatusAccepted).JSON(summary)
+	}
+	return c.JSON(summary)
+}
+
+// GetSummary returns the latest summarization run of a conversation
+// GET /conversations/:id/summary
+func (h *SummaryHandler) GetSummary(c *fiber.Ctx) error {
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+
+	summary, err := h.summaryService.GetSummary(c.Context(), c.Locals("office_id").(uuid.UUID), conversationID)
+	if err != nil {
+		return summaryError(err)
+	}
+	return c.JSON(summary)
+}
+
+// summaryError maps summary service errors to API errors
+func summaryError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("conversation or summary not found")
+	default:
+		return internalError("failed to summarize the conversation", err)
+	}
+}
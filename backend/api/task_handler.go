@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TaskHandler handles agent task endpoints
+type TaskHandler struct {
+	taskService *service.TaskService
+}
+
+// NewTaskHandler creates a new TaskHandler
+func NewTaskHandler(taskService *service.TaskService) *TaskHandler {
+	return &TaskHandler{taskService: taskService}
+}
+
+// ListTasks returns the office's tasks, optionally filtered by status, agent
+// or conversation
+// GET /tasks
+func (h *TaskHandler) ListTasks(c *fiber.Ctx) error {
+	filter := domain.TaskFilter{
+		OfficeID: c.Locals("office_id").(uuid.UUID),
+		Status:   domain.TaskStatus(c.Query("status")),
+	}
+
+	if raw := c.Query("agent_id"); raw != "" {
+		agentID, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid agent_id")
+		}
+		filter.AgentID = agentID
+	}
+	if raw := c.Query("conversation_id"); raw != "" {
+		conversationID, err := uuid.Parse(raw)
+		if err != nil {
+			return badRequest("invalid conversation_id")
+		}
+		filter.ConversationID = conversationID
+	}
+
+	page, err := parsePageRequest(c, 20, 100)
+	if err != nil {
+		return err
+	}
+
+	tasks, total, err := h.taskService.ListTasks(c.Context(), filter, page)
+	if err != nil {
+		return taskError(err)
+	}
+
+	return c.JSON(newPage(tasks, total, page.Limit, func(t *domain.Task) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+	}))
+}
+
+// GetTask returns a single task with its output, error and token usage
+// GET /tasks/:id
+func (h *TaskHandler) GetTask(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	taskID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	task, err := h.taskService.GetOfficeTask(c.Context(), officeID, taskID)
+	if err != nil {
+		return taskError(err)
+	}
+
+	return c.JSON(task)
+}
+
+// GetSubtasks returns a task with the sub-tasks other agents were delegated
+// from it, nested to any depth
+// GET /tasks/:id/subtasks
+func (h *TaskHandler) GetSubtasks(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	taskID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	tree, err := h.taskService.GetSubtaskTree(c.Context(), officeID, taskID)
+	if err != nil {
+		return taskError(err)
+	}
+
+	return c.JSON(tree)
+}
+
+// CancelTask cancels a task that has not finished yet, refunding its credits
+// POST /tasks/:id/cancel (also DELETE /tasks/:id)
+func (h *TaskHandler) CancelTask(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	taskID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	task, err := h.taskService.CancelTask(c.Context(), officeID, taskID)
+	if err != nil {
+		return taskError(err)
+	}
+
+	return c.JSON(task)
+}
+
+// RetryTask re-queues a failed or dead-lettered task for dispatch
+// POST /tasks/:id/retry
+func (h *TaskHandler) RetryTask(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	taskID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task id")
+	}
+
+	task, err := h.taskService.RetryTask(c.Context(), officeID, taskID)
+	if err != nil {
+		return taskError(err)
+	}
+
+	return c.JSON(task)
+}
+
+// taskError maps task errors to API errors
+func taskError(err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("task not found")
+	}
+	return internalError("failed to process task request", err)
+}
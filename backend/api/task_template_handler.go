@@ -0,0 +1,254 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TaskTemplateHandler handles the task template endpoints
+type TaskTemplateHandler struct {
+	templateService *service.TaskTemplateService
+}
+
+// NewTaskTemplateHandler creates a new TaskTemplateHandler
+func NewTaskTemplateHandler(templateService *service.TaskTemplateService) *TaskTemplateHandler {
+	return &TaskTemplateHandler{templateService: templateService}
+}
+
+// CreateTaskTemplateRequest represents a request to create a task template.
+// The body marks placeholders as {{name}}.
+type CreateTaskTemplateRequest struct {
+	Name        string            `json:"name" validate:"required,max=255"`
+	Description string            `json:"description,omitempty" validate:"max=1000"`
+	Body        string            `json:"body" validate:"required,max=8000"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// UpdateTaskTemplateRequest changes a task template; omitted fields are left
+// unchanged and defaults replaces all of them
+type UpdateTaskTemplateRequest struct {
+	Name        *string           `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string           `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Body        *string           `json:"body,omitempty" validate:"omitempty,min=1,max=8000"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// RunTaskTemplateRequest fills in a task template for an agent
+type RunTaskTemplateRequest struct {
+	AgentID        uuid.UUID         `json:"agent_id" validate:"required"`
+	ConversationID uuid.UUID         `json:"conversation_id" validate:"required"`
+	Values         map[string]string `json:"values,omitempty"`
+}
+
+// ScheduleTaskTemplateRequest fills in a task template for an agent on a
+// schedule. Set cron_expression for recurring runs or run_at for a one-off.
+type ScheduleTaskTemplateRequest struct {
+	RunTaskTemplateRequest
+	Name           string     `json:"name,omitempty" validate:"max=255"`
+	CronExpression string     `json:"cron_expression,omitempty" validate:"required_without=RunAt,excluded_with=RunAt"`
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	Timezone       string     `json:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+// CreateTemplate creates a task template for the office
+// POST /task-templates
+func (h *TaskTemplateHandler) CreateTemplate(c *fiber.Ctx) error {
+	var req CreateTaskTemplateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	template, err := h.templateService.CreateTemplate(c.Context(), service.CreateTaskTemplateInput{
+		OfficeID:    c.Locals("office_id").(uuid.UUID),
+		Name:        req.Name,
+		Description: req.Description,
+		Body:        req.Body,
+		Defaults:    req.Defaults,
+	})
+	if err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// GetTemplates lists the office's task templates
+// GET /task-templates
+func (h *TaskTemplateHandler) GetTemplates(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templates, err := h.templateService.GetTemplates(c.Context(), officeID)
+	if err != nil {
+		return taskTemplateError(err)
+	}
+	if templates == nil {
+		templates = []*domain.TaskTemplate{}
+	}
+
+	return c.JSON(fiber.Map{"templates": templates})
+}
+
+// GetTemplate returns one of the office's task templates
+// GET /task-templates/:id
+func (h *TaskTemplateHandler) GetTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	template, err := h.templateService.GetTemplate(c.Context(), officeID, templateID)
+	if err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.JSON(template)
+}
+
+// UpdateTemplate edits one of the office's task templates
+// PUT /task-templates/:id
+func (h *TaskTemplateHandler) UpdateTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	var req UpdateTaskTemplateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	template, err := h.templateService.UpdateTemplate(c.Context(), service.UpdateTaskTemplateInput{
+		OfficeID:    officeID,
+		TemplateID:  templateID,
+		Name:        req.Name,
+		Description: req.Description,
+		Body:        req.Body,
+		Defaults:    req.Defaults,
+	})
+	if err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.JSON(template)
+}
+
+// DeleteTemplate removes one of the office's task templates
+// DELETE /task-templates/:id
+func (h *TaskTemplateHandler) DeleteTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	if err := h.templateService.DeleteTemplate(c.Context(), officeID, templateID); err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RunTemplate fills in a task template and dispatches it to an agent
+// POST /task-templates/:id/run
+func (h *TaskTemplateHandler) RunTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	var req RunTaskTemplateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	task, err := h.templateService.RunTemplate(c.Context(), service.RunTaskTemplateInput{
+		OfficeID:       officeID,
+		TemplateID:     templateID,
+		AgentID:        req.AgentID,
+		ConversationID: req.ConversationID,
+		Values:         req.Values,
+	})
+	if err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(task)
+}
+
+// ScheduleTemplate fills in a task template and schedules it for an agent
+// POST /task-templates/:id/schedules
+func (h *TaskTemplateHandler) ScheduleTemplate(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	var req ScheduleTaskTemplateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	schedule, err := h.templateService.ScheduleTemplate(c.Context(), service.ScheduleTaskTemplateInput{
+		RunTaskTemplateInput: service.RunTaskTemplateInput{
+			OfficeID:       officeID,
+			TemplateID:     templateID,
+			AgentID:        req.AgentID,
+			ConversationID: req.ConversationID,
+			Values:         req.Values,
+		},
+		Name:           req.Name,
+		CronExpression: req.CronExpression,
+		RunAt:          req.RunAt,
+		Timezone:       req.Timezone,
+	})
+	if err != nil {
+		return taskTemplateError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// GetTemplateSchedules lists the schedules filled in from a task template
+// GET /task-templates/:id/schedules
+func (h *TaskTemplateHandler) GetTemplateSchedules(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid task template id")
+	}
+
+	schedules, err := h.templateService.GetTemplateSchedules(c.Context(), officeID, templateID)
+	if err != nil {
+		return taskTemplateError(err)
+	}
+	if schedules == nil {
+		schedules = []*domain.ScheduledTask{}
+	}
+
+	return c.JSON(fiber.Map{"schedules": schedules})
+}
+
+// taskTemplateError maps task template errors to API errors
+func taskTemplateError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("task template or agent not found")
+	default:
+		return internalError("failed to manage task templates", err)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxSessionIDLength caps the X-Session-ID a marketplace client sends
+const maxSessionIDLength = 128
+
+// TemplateViewHandler handles marketplace impression and view tracking and
+// the analytics authors get from it
+type TemplateViewHandler struct {
+	viewService *service.TemplateViewService
+}
+
+// NewTemplateViewHandler creates a new TemplateViewHandler
+func NewTemplateViewHandler(viewService *service.TemplateViewService) *TemplateViewHandler {
+	return &TemplateViewHandler{viewService: viewService}
+}
+
+// ImpressionsRequest lists the templates a visitor was shown
+type ImpressionsRequest struct {
+	TemplateIDs []uuid.UUID `json:"template_ids" validate:"required,min=1,max=100"`
+}
+
+// RecordView counts a visitor opening a template's details
+// POST /marketplace/agents/:id/view
+func (h *TemplateViewHandler) RecordView(c *fiber.Ctx) error {
+	templateID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("Invalid agent ID")
+	}
+
+	err = h.viewService.RecordViews(c.Context(), domain.TemplateDetailView, []uuid.UUID{templateID}, viewVisitor(c))
+	if err != nil {
+		return internalError("failed to record view", err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RecordImpressions counts a visitor being shown templates in browse or
+// search results
+// POST /marketplace/impressions
+func (h *TemplateViewHandler) RecordImpressions(c *fiber.Ctx) error {
+	var req ImpressionsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	err := h.viewService.RecordViews(c.Context(), domain.TemplateImpression, req.TemplateIDs, viewVisitor(c))
+	if err != nil {
+		return internalError("failed to record impressions", err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetAuthorAnalytics reports the impressions, views, hires and sales of the
+// author's templates
+// GET /author/templates/analytics?days=30
+func (h *TemplateViewHandler) GetAuthorAnalytics(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return unauthorized("Unauthorized")
+	}
+
+	days := 30
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 90 {
+			days = parsed
+		}
+	}
+
+	analytics, err := h.viewService.GetAuthorAnalytics(c.Context(), userID, days)
+	if err != nil {
+		return internalError("failed to load template analytics", err)
+	}
+	return c.JSON(analytics)
+}
+
+// viewVisitor identifies who is browsing the marketplace: the signed in
+// user, else the session the client sends in X-Session-ID, else the
+// client's address and browser
+func viewVisitor(c *fiber.Ctx) string {
+	if userID, ok := c.Locals("user_id").(uuid.UUID); ok {
+		return "user:" + userID.String()
+	}
+	if session := c.Get("X-Session-ID"); session != "" && len(session) <= maxSessionIDLength {
+		return "session:" + session
+	}
+	return "client:" + c.IP() + " " + c.Get(fiber.HeaderUserAgent)
+}
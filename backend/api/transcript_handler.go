@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TranscriptHandler handles conversation export endpoints
+type TranscriptHandler struct {
+	transcriptService *service.TranscriptService
+}
+
+// NewTranscriptHandler creates a new TranscriptHandler
+func NewTranscriptHandler(transcriptService *service.TranscriptService) *TranscriptHandler {
+	return &TranscriptHandler{transcriptService: transcriptService}
+}
+
+// transcriptFormats maps each export format to its content type and file
+// extension
+var transcriptFormats = map[string]struct{ contentType, extension string }{
+	"json":     {fiber.MIMEApplicationJSONCharsetUTF8, "json"},
+	"markdown": {"text/markdown; charset=utf-8", "md"},
+	"pdf":      {"application/pdf", "pdf"},
+}
+
+// ExportConversation downloads the full history of a conversation, with
+// sender names, timestamps and agent tasks, as JSON, Markdown or PDF
+// GET /conversations/:id/export?format=json|markdown|pdf
+func (h *TranscriptHandler) ExportConversation(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	conversationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid conversation id")
+	}
+	formatName := c.Query("format", "json")
+	format, ok := transcriptFormats[formatName]
+	if !ok {
+		return badRequest("format must be json, markdown or pdf")
+	}
+
+	transcript, err := h.transcriptService.GetTranscript(c.Context(), officeID, conversationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("conversation not found")
+	}
+	if err != nil {
+		return internalError("failed to export conversation", err)
+	}
+
+	c.Attachment(fmt.Sprintf("conversation-%s.%s", conversationID, format.extension))
+	c.Set(fiber.HeaderContentType, format.contentType)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		switch formatName {
+		case "markdown":
+			err = service.WriteTranscriptMarkdown(w, transcript)
+		case "pdf":
+			err = service.WriteTranscriptPDF(w, transcript)
+		default:
+			err = json.NewEncoder(w).Encode(transcript)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Printf("Failed to stream conversation %s export: %v", conversationID, err)
+		}
+	})
+	return nil
+}
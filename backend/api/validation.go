@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validate checks request DTOs against their `validate` struct tags
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON names, which is what clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// parseBody parses the JSON body into req and validates it. Invalid fields are
+// reported together in the details of an invalid_input error, keyed by field.
+func parseBody(c *fiber.Ctx, req any) error {
+	if err := c.BodyParser(req); err != nil {
+		return badRequest("invalid request body")
+	}
+
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return internalError("failed to validate request", err)
+	}
+
+	fields := make(map[string]any, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		fields[fieldPath(fe)] = fieldMessage(fe)
+	}
+	return &Error{
+		Status:  fiber.StatusBadRequest,
+		Code:    domain.ErrInvalidInput.Code,
+		Message: "request validation failed",
+		Details: map[string]any{"fields": fields},
+	}
+}
+
+// fieldPath is the field's JSON path without the struct name, e.g.
+// "template_ids[2]"
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+// fieldMessage describes a failed rule in plain words
+func fieldMessage(fe validator.FieldError) string {
+	isText := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		field, value, _ := strings.Cut(fe.Param(), " ")
+		return "is required when " + snakeCase(field) + " is " + value
+	case "email":
+		return "must be a valid email address"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "required_without":
+		return "is required unless " + snakeCase(fe.Param()) + " is set"
+	case "excluded_with":
+		return "must not be set together with " + snakeCase(fe.Param())
+	case "timezone":
+		return "must be an IANA time zone such as Europe/Berlin"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "gte":
+		switch {
+		case isText:
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		switch {
+		case isText:
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+// snakeCase converts a Go field name referenced by a cross-field rule, such
+// as RunAt, to its JSON form run_at
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WebResearchHandler handles the web research settings and the
+// orchestrator's search metering endpoints
+type WebResearchHandler struct {
+	webResearchService *service.WebResearchService
+}
+
+// NewWebResearchHandler creates a new WebResearchHandler
+func NewWebResearchHandler(webResearchService *service.WebResearchService) *WebResearchHandler {
+	return &WebResearchHandler{webResearchService: webResearchService}
+}
+
+// UpdateWebResearchSettingsRequest changes the office's web research
+// settings; omitted fields are left unchanged
+type UpdateWebResearchSettingsRequest struct {
+	// AllowedDomains replaces the domains results are limited to; an empty
+	// list allows every domain
+	AllowedDomains     []string `json:"allowed_domains,omitempty" validate:"omitempty,max=100"`
+	MaxSearchesPerTask *int     `json:"max_searches_per_task,omitempty" validate:"omitempty,gte=0,lte=50"`
+}
+
+// WebResearchLookupRequest asks for the cached results of a task's query
+type WebResearchLookupRequest struct {
+	TaskID string `json:"task_id" validate:"required,uuid"`
+	Query  string `json:"query" validate:"required,max=500"`
+}
+
+// LogWebSearchRequest reports a search the orchestrator ran for a task
+type LogWebSearchRequest struct {
+	TaskID   string                   `json:"task_id" validate:"required,uuid"`
+	Query    string                   `json:"query" validate:"required,max=500"`
+	Provider string                   `json:"provider,omitempty" validate:"max=50"`
+	Results  []domain.WebSearchResult `json:"results" validate:"max=50"`
+}
+
+// GetSettings returns the office's web research settings
+// GET /web-research/settings
+func (h *WebResearchHandler) GetSettings(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	settings, err := h.webResearchService.GetSettings(c.Context(), officeID)
+	if err != nil {
+		return internalError("failed to get web research settings", err)
+	}
+
+	return c.JSON(settings)
+}
+
+// UpdateSettings changes the office's web research settings
+// PUT /web-research/settings
+func (h *WebResearchHandler) UpdateSettings(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	var req UpdateWebResearchSettingsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	settings, err := h.webResearchService.UpdateSettings(c.Context(), officeID, service.UpdateWebResearchSettingsInput{
+		AllowedDomains:     req.AllowedDomains,
+		MaxSearchesPerTask: req.MaxSearchesPerTask,
+	})
+	if err != nil {
+		return internalError("failed to update web research settings", err)
+	}
+
+	return c.JSON(settings)
+}
+
+// Lookup answers a running task's query from the shared result cache, or
+// tells the orchestrator how many searches the task has left
+// POST /internal/web-research/lookup
+func (h *WebResearchHandler) Lookup(c *fiber.Ctx) error {
+	var req WebResearchLookupRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	lookup, err := h.webResearchService.Lookup(c.Context(), uuid.MustParse(req.TaskID), req.Query)
+	if err != nil {
+		return webResearchError(err)
+	}
+
+	return c.JSON(lookup)
+}
+
+// LogSearch charges a search the orchestrator ran for a task and caches
+// its results. Retries carrying the same Idempotency-Key header are not
+// charged again.
+// POST /internal/web-research/searches
+func (h *WebResearchHandler) LogSearch(c *fiber.Ctx) error {
+	var req LogWebSearchRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	searchLog, err := h.webResearchService.LogSearch(c.Context(), service.LogWebSearchInput{
+		TaskID:         uuid.MustParse(req.TaskID),
+		Query:          req.Query,
+		Provider:       req.Provider,
+		Results:        req.Results,
+		IdempotencyKey: c.Get("Idempotency-Key"),
+	})
+	if err != nil {
+		return webResearchError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(searchLog)
+}
+
+// webResearchError maps web research errors to API errors
+func webResearchError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("task not found")
+	default:
+		return internalError("failed to meter web research", err)
+	}
+}
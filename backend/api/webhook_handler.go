@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WebhookHandler handles outbound webhook management endpoints
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// CreateWebhookRequest represents a request to register a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url" validate:"required,max=2048"`
+	// Secret signs deliveries; one is generated when it is omitted
+	Secret     string                    `json:"secret,omitempty" validate:"omitempty,min=16,max=256"`
+	EventTypes []domain.WebhookEventType `json:"event_types" validate:"required,min=1,dive,oneof=task.completed message.created credits.low subscription.updated"`
+}
+
+// CreateWebhookResponse carries a newly registered webhook. Secret cannot be
+// retrieved again.
+type CreateWebhookResponse struct {
+	Webhook *domain.Webhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+// CreateWebhook registers a webhook for the user's office
+// POST /webhooks
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req CreateWebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	webhook, secret, err := h.webhookService.CreateWebhook(c.Context(), service.CreateWebhookInput{
+		OfficeID:   c.Locals("office_id").(uuid.UUID),
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		return webhookError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhooks lists the office's webhooks
+// GET /webhooks
+func (h *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	webhooks, err := h.webhookService.GetWebhooks(c.Context(), officeID)
+	if err != nil {
+		return webhookError(err)
+	}
+	if webhooks == nil {
+		webhooks = []*domain.Webhook{}
+	}
+
+	return c.JSON(fiber.Map{"webhooks": webhooks})
+}
+
+// DeleteWebhook removes one of the office's webhooks
+// DELETE /webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	webhookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid webhook id")
+	}
+
+	if err := h.webhookService.DeleteWebhook(c.Context(), officeID, webhookID); err != nil {
+		return webhookError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetDeliveries returns the delivery log of one of the office's webhooks
+// GET /webhooks/:id/deliveries
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	webhookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid webhook id")
+	}
+
+	page, err := parsePageRequest(c, 20, 100)
+	if err != nil {
+		return err
+	}
+
+	deliveries, total, err := h.webhookService.GetDeliveries(c.Context(), officeID, webhookID, page.Limit, page.Offset)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	return c.JSON(newPage(deliveries, total, page.Limit, nil))
+}
+
+// webhookError maps webhook errors to API errors
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("webhook not found")
+	default:
+		return internalError("failed to manage webhooks", err)
+	}
+}
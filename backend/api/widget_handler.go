@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WidgetHandler handles chat widget token management and the public
+// endpoints embedded widgets call
+type WidgetHandler struct {
+	widgetService *service.WidgetService
+}
+
+// NewWidgetHandler creates a new WidgetHandler
+func NewWidgetHandler(widgetService *service.WidgetService) *WidgetHandler {
+	return &WidgetHandler{widgetService: widgetService}
+}
+
+// CreateWidgetTokenRequest represents a request to create a widget token
+type CreateWidgetTokenRequest struct {
+	AgentID string `json:"agent_id" validate:"required,uuid"`
+	Name    string `json:"name" validate:"required,max=100"`
+	// AllowedOrigins are the origins the widget may be embedded on, such
+	// as https://example.com; empty allows any
+	AllowedOrigins []string `json:"allowed_origins,omitempty" validate:"max=20"`
+	// RateLimit is requests per minute across all visitors; 20 when omitted
+	RateLimit int `json:"rate_limit,omitempty" validate:"gte=0,lte=600"`
+}
+
+// CreateWidgetTokenResponse carries a newly created token. Token is not
+// stored and cannot be retrieved again.
+type CreateWidgetTokenResponse struct {
+	WidgetToken *domain.WidgetToken `json:"widget_token"`
+	Token       string              `json:"token"`
+}
+
+// CreateWidgetToken creates a token exposing one of the office's agents
+// POST /widget-tokens
+func (h *WidgetHandler) CreateWidgetToken(c *fiber.Ctx) error {
+	var req CreateWidgetTokenRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	token, secret, err := h.widgetService.CreateWidgetToken(c.Context(), service.CreateWidgetTokenInput{
+		OfficeID:       c.Locals("office_id").(uuid.UUID),
+		UserID:         c.Locals("user_id").(uuid.UUID),
+		AgentID:        uuid.MustParse(req.AgentID),
+		Name:           req.Name,
+		AllowedOrigins: req.AllowedOrigins,
+		RateLimit:      req.RateLimit,
+	})
+	if err != nil {
+		return widgetError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateWidgetTokenResponse{WidgetToken: token, Token: secret})
+}
+
+// GetWidgetTokens lists the office's widget tokens with their usage
+// GET /widget-tokens
+func (h *WidgetHandler) GetWidgetTokens(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	tokens, err := h.widgetService.GetWidgetTokens(c.Context(), officeID)
+	if err != nil {
+		return widgetError(err)
+	}
+	if tokens == nil {
+		tokens = []*domain.WidgetToken{}
+	}
+
+	return c.JSON(fiber.Map{"widget_tokens": tokens})
+}
+
+// RevokeWidgetToken revokes one of the office's widget tokens
+// DELETE /widget-tokens/:id
+func (h *WidgetHandler) RevokeWidgetToken(c *fiber.Ctx) error {
+	officeID := c.Locals("office_id").(uuid.UUID)
+
+	tokenID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequest("invalid widget token id")
+	}
+
+	if err := h.widgetService.RevokeWidgetToken(c.Context(), officeID, tokenID); err != nil {
+		return widgetError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VisitorMessageRequest represents a message from a widget visitor
+type VisitorMessageRequest struct {
+	// SessionID continues the visitor's earlier conversation; omit it on
+	// the first message
+	SessionID string `json:"session_id,omitempty" validate:"omitempty,uuid"`
+	Content   string `json:"content" validate:"required,max=16000"`
+}
+
+// WidgetMessage is a message as widget visitors see it
+type WidgetMessage struct {
+	ID         uuid.UUID         `json:"id"`
+	SenderType domain.SenderType `json:"sender_type"`
+	Content    string            `json:"content"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// VisitorMessageResponse carries the visitor's session and saved message.
+// The agent's reply is delivered on the session's WebSocket channel.
+type VisitorMessageResponse struct {
+	SessionID uuid.UUID     `json:"session_id"`
+	Message   WidgetMessage `json:"message"`
+}
+
+// SendVisitorMessage posts a visitor's message to the widget's agent
+// POST /public/widget/:token/messages
+func (h *WidgetHandler) SendVisitorMessage(c *fiber.Ctx) error {
+	token := c.Locals("widget_token").(*domain.WidgetToken)
+
+	var req VisitorMessageRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	input := service.VisitorMessageInput{Content: req.Content}
+	if req.SessionID != "" {
+		id := uuid.MustParse(req.SessionID)
+		input.SessionID = &id
+	}
+
+	session, message, err := h.widgetService.SendVisitorMessage(c.Context(), token, input)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("session not found")
+	}
+	if err != nil {
+		return internalError("failed to send message", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(VisitorMessageResponse{
+		SessionID: session.ID,
+		Message:   widgetMessage(message),
+	})
+}
+
+// GetVisitorMessages returns a page of a visitor's conversation, oldest
+// first
+// GET /public/widget/:token/messages?session_id=...
+func (h *WidgetHandler) GetVisitorMessages(c *fiber.Ctx) error {
+	token := c.Locals("widget_token").(*domain.WidgetToken)
+
+	sessionID, err := uuid.Parse(c.Query("session_id"))
+	if err != nil {
+		return badRequest("invalid session_id")
+	}
+	page, err := parsePageRequest(c, 50, 100)
+	if err != nil {
+		return err
+	}
+
+	messages, total, err := h.widgetService.GetVisitorMessages(c.Context(), token, sessionID, page)
+	if errors.Is(err, domain.ErrNotFound) {
+		return notFound("session not found")
+	}
+	if err != nil {
+		return internalError("failed to get messages", err)
+	}
+
+	visible := make([]WidgetMessage, len(messages))
+	for i, message := range messages {
+		visible[i] = widgetMessage(message)
+	}
+	return c.JSON(newPage(visible, total, page.Limit, func(m WidgetMessage) domain.PageCursor {
+		return domain.PageCursor{CreatedAt: m.CreatedAt, ID: m.ID}
+	}))
+}
+
+// widgetMessage returns the parts of a message shown to widget visitors
+func widgetMessage(message *domain.Message) WidgetMessage {
+	return WidgetMessage{
+		ID:         message.ID,
+		SenderType: message.SenderType,
+		Content:    message.Content,
+		CreatedAt:  message.CreatedAt,
+	}
+}
+
+// widgetError maps widget token errors to API errors
+func widgetError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return notFound("widget token not found")
+	default:
+		return internalError("failed to manage widget tokens", err)
+	}
+}
@@ -1,28 +1,114 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/service"
 	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+const (
+	// wsWriteWait bounds how long a write may block on a slow client
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a connection may stay silent before it is
+	// considered dead; any pong or client message resets it
+	wsPongWait = 60 * time.Second
+	// wsPingInterval must be shorter than wsPongWait
+	wsPingInterval = 25 * time.Second
+
+	maxConnectionsPerOffice = 50
+	maxConnectionsPerUser   = 5
+	// maxConnectionsPerWidgetSession allows a visitor a few open tabs
+	maxConnectionsPerWidgetSession = 3
+)
+
+var (
+	errOfficeConnectionLimit = errors.New("too many connections for this office")
+	errUserConnectionLimit   = errors.New("too many connections for this user")
+	errWidgetConnectionLimit = errors.New("too many connections for this session")
+)
+
+// widgetEvents are the events chat widget visitors receive, and
+// widgetPayloadFields the payload fields passed on to them. Visitors only
+// see their own conversation.
+var (
+	widgetEvents = map[domain.EventType]bool{
+		domain.EventNewMessage:      true,
+		domain.EventMessageDelta:    true,
+		domain.EventMessageComplete: true,
+		domain.EventMessageEdited:   true,
+		domain.EventMessageDeleted:  true,
+	}
+	widgetPayloadFields = []string{
+		"message_id", "conversation_id", "task_id", "sender_type", "content", "sequence", "delta", "edited_at",
+	}
+)
+
 // WSHandler handles WebSocket connections
 type WSHandler struct {
-	authService *service.AuthService
-	clients     map[uuid.UUID]map[*websocket.Conn]bool
-	mu          sync.RWMutex
+	authService         *service.AuthService
+	notificationService *service.NotificationService
+	widgetService       *service.WidgetService
+	clients             map[uuid.UUID]map[*websocket.Conn]*wsClient
+	userConnections     map[uuid.UUID]int
+	// widgetClients are chat widget visitors by conversation
+	widgetClients map[uuid.UUID]map[*websocket.Conn]*wsClient
+	mu            sync.RWMutex
 }
 
-// NewWSHandler creates a new WSHandler
-func NewWSHandler(authService *service.AuthService) *WSHandler {
-	return &WSHandler{
-		authService: authService,
-		clients:     make(map[uuid.UUID]map[*websocket.Conn]bool),
+// wsClient serialises writes to a connection. Streaming deltas and other
+// broadcasts are sent from concurrent request goroutines, but a connection
+// only supports one writer at a time.
+type wsClient struct {
+	conn     *websocket.Conn
+	officeID uuid.UUID
+	userID   uuid.UUID
+	// conversationID is the conversation of a chat widget visitor
+	conversationID uuid.UUID
+	mu             sync.Mutex
+}
+
+// writeJSON sends a message to the client
+func (c *wsClient) writeJSON(msg any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(msg)
+}
+
+// ping sends a WebSocket ping control frame
+func (c *wsClient) ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+}
+
+// NewWSHandler creates a new WSHandler delivering events from the bus to
+// the clients connected to this instance
+func NewWSHandler(
+	authService *service.AuthService,
+	notificationService *service.NotificationService,
+	widgetService *service.WidgetService,
+	events domain.EventSubscriber,
+) *WSHandler {
+	h := &WSHandler{
+		authService:         authService,
+		notificationService: notificationService,
+		widgetService:       widgetService,
+		clients:             make(map[uuid.UUID]map[*websocket.Conn]*wsClient),
+		userConnections:     make(map[uuid.UUID]int),
+		widgetClients:       make(map[uuid.UUID]map[*websocket.Conn]*wsClient),
 	}
+	events.Subscribe(h.deliverEvent)
+	return h
 }
 
 // WSMessage represents a WebSocket message
@@ -46,7 +132,7 @@ func (h *WSHandler) HandleWS(c *websocket.Conn) {
 	}
 
 	// Validate token
-	claims, err := h.authService.ValidateToken(token)
+	claims, err := h.authService.ValidateToken(context.Background(), token)
 	if err != nil {
 		c.WriteJSON(WSMessage{
 			EventType: "error",
@@ -59,11 +145,31 @@ func (h *WSHandler) HandleWS(c *websocket.Conn) {
 	officeID := claims.OfficeID
 
 	// Register client
-	h.registerClient(officeID, c)
-	defer h.unregisterClient(officeID, c)
+	client, err := h.registerClient(officeID, claims.UserID, c)
+	if err != nil {
+		c.WriteJSON(WSMessage{
+			EventType: "error",
+			Payload:   map[string]any{"message": err.Error()},
+		})
+		c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()),
+			time.Now().Add(wsWriteWait))
+		c.Close()
+		return
+	}
+	defer h.unregisterClient(client)
+
+	// Connections that stop answering pings are dropped by the read deadline
+	c.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go h.keepAlive(client, done)
 
 	// Send connected event
-	c.WriteJSON(WSMessage{
+	client.writeJSON(WSMessage{
 		EventID:   uuid.New().String(),
 		EventType: "connected",
 		Payload: map[string]any{
@@ -72,6 +178,9 @@ func (h *WSHandler) HandleWS(c *websocket.Conn) {
 		},
 	})
 
+	// Replay unread notifications missed while disconnected
+	h.sendUnreadNotifications(client, officeID)
+
 	// Listen for messages
 	for {
 		_, msg, err := c.ReadMessage()
@@ -79,6 +188,7 @@ func (h *WSHandler) HandleWS(c *websocket.Conn) {
 			log.Printf("WebSocket read error: %v", err)
 			break
 		}
+		c.SetReadDeadline(time.Now().Add(wsPongWait))
 
 		var wsMsg WSMessage
 		if err := json.Unmarshal(msg, &wsMsg); err != nil {
@@ -87,15 +197,126 @@ func (h *WSHandler) HandleWS(c *websocket.Conn) {
 		}
 
 		// Handle different event types
-		h.handleMessage(c, officeID, &wsMsg)
+		h.handleMessage(client, officeID, &wsMsg)
+	}
+}
+
+// HandleWidgetWS handles a chat widget visitor's connection, authenticated
+// by WidgetTokenMiddleware. The visitor receives the message events of their
+// own conversation and may only send pings.
+func (h *WSHandler) HandleWidgetWS(c *websocket.Conn) {
+	token := c.Locals("widget_token").(*domain.WidgetToken)
+
+	sessionID, err := uuid.Parse(c.Query("session_id"))
+	var session *domain.WidgetSession
+	if err == nil {
+		session, err = h.widgetService.GetSession(context.Background(), token, sessionID)
+	}
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Printf("Failed to load widget session %s: %v", c.Query("session_id"), err)
+		}
+		c.WriteJSON(WSMessage{
+			EventType: "error",
+			Payload:   map[string]any{"message": "unknown session"},
+		})
+		c.Close()
+		return
+	}
+
+	client, err := h.registerWidgetClient(token.OfficeID, session, c)
+	if err != nil {
+		c.WriteJSON(WSMessage{
+			EventType: "error",
+			Payload:   map[string]any{"message": err.Error()},
+		})
+		c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()),
+			time.Now().Add(wsWriteWait))
+		c.Close()
+		return
+	}
+	defer h.unregisterWidgetClient(client)
+
+	c.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go h.keepAlive(client, done)
+
+	client.writeJSON(WSMessage{
+		EventID:   uuid.New().String(),
+		EventType: "connected",
+		Payload:   map[string]any{"session_id": session.ID.String()},
+	})
+
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			break
+		}
+		c.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var wsMsg WSMessage
+		if err := json.Unmarshal(msg, &wsMsg); err == nil && wsMsg.EventType == "ping" {
+			client.writeJSON(WSMessage{EventID: wsMsg.EventID, EventType: "pong", Payload: map[string]any{}})
+		}
+	}
+}
+
+// keepAlive pings the client until done is closed. A failed ping closes the
+// connection, which ends the read loop and unregisters the client.
+func (h *WSHandler) keepAlive(c *wsClient, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.ping(); err != nil {
+				log.Printf("WebSocket ping failed, closing connection: %v", err)
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// sendUnreadNotifications pushes an office's unread notifications to a single client
+func (h *WSHandler) sendUnreadNotifications(c *wsClient, officeID uuid.UUID) {
+	notifications, err := h.notificationService.GetUnread(context.Background(), officeID, 20)
+	if err != nil {
+		log.Printf("Failed to load unread notifications: %v", err)
+		return
+	}
+
+	// Oldest first so clients receive them in the order they happened
+	for i := len(notifications) - 1; i >= 0; i-- {
+		if err := c.writeJSON(eventMessage(notifications[i].Event())); err != nil {
+			log.Printf("WebSocket write error: %v", err)
+			return
+		}
+	}
+}
+
+// eventMessage converts a bus event into a WebSocket message
+func eventMessage(event domain.Event) WSMessage {
+	return WSMessage{
+		EventID:   event.ID,
+		EventType: string(event.Type),
+		Payload:   event.Payload,
 	}
 }
 
 // handleMessage processes incoming WebSocket messages
-func (h *WSHandler) handleMessage(c *websocket.Conn, officeID uuid.UUID, msg *WSMessage) {
+func (h *WSHandler) handleMessage(c *wsClient, officeID uuid.UUID, msg *WSMessage) {
 	switch msg.EventType {
 	case "ping":
-		c.WriteJSON(WSMessage{
+		c.writeJSON(WSMessage{
 			EventID:   msg.EventID,
 			EventType: "pong",
 			Payload:   map[string]any{},
@@ -106,39 +327,126 @@ func (h *WSHandler) handleMessage(c *websocket.Conn, officeID uuid.UUID, msg *WS
 			EventID:   uuid.New().String(),
 			EventType: "typing",
 			Payload:   msg.Payload,
-		}, c)
+		}, c.conn)
 	default:
 		log.Printf("Unknown event type: %s", msg.EventType)
 	}
 }
 
-// registerClient adds a client to the office clients map
-func (h *WSHandler) registerClient(officeID uuid.UUID, c *websocket.Conn) {
+// registerClient adds a client to the office clients map, enforcing the
+// per-office and per-user connection caps
+func (h *WSHandler) registerClient(officeID, userID uuid.UUID, c *websocket.Conn) (*wsClient, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if len(h.clients[officeID]) >= maxConnectionsPerOffice {
+		return nil, errOfficeConnectionLimit
+	}
+	if h.userConnections[userID] >= maxConnectionsPerUser {
+		return nil, errUserConnectionLimit
+	}
+
 	if h.clients[officeID] == nil {
-		h.clients[officeID] = make(map[*websocket.Conn]bool)
+		h.clients[officeID] = make(map[*websocket.Conn]*wsClient)
 	}
-	h.clients[officeID][c] = true
+	client := &wsClient{conn: c, officeID: officeID, userID: userID}
+	h.clients[officeID][c] = client
+	h.userConnections[userID]++
+	return client, nil
 }
 
 // unregisterClient removes a client from the office clients map
-func (h *WSHandler) unregisterClient(officeID uuid.UUID, c *websocket.Conn) {
+func (h *WSHandler) unregisterClient(client *wsClient) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if h.clients[officeID] != nil {
-		delete(h.clients[officeID], c)
-		if len(h.clients[officeID]) == 0 {
-			delete(h.clients, officeID)
+	if clients := h.clients[client.officeID]; clients != nil {
+		if _, ok := clients[client.conn]; !ok {
+			return
+		}
+		delete(clients, client.conn)
+		if len(clients) == 0 {
+			delete(h.clients, client.officeID)
 		}
 	}
+	if h.userConnections[client.userID]--; h.userConnections[client.userID] <= 0 {
+		delete(h.userConnections, client.userID)
+	}
 }
 
-// BroadcastToOffice sends a message to all clients in an office
-func (h *WSHandler) BroadcastToOffice(officeID uuid.UUID, msg WSMessage) {
-	h.broadcastToOffice(officeID, msg, nil)
+// registerWidgetClient adds a widget visitor's connection, enforcing the
+// per-session connection cap
+func (h *WSHandler) registerWidgetClient(officeID uuid.UUID, session *domain.WidgetSession, c *websocket.Conn) (*wsClient, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := h.widgetClients[session.ConversationID]
+	if len(clients) >= maxConnectionsPerWidgetSession {
+		return nil, errWidgetConnectionLimit
+	}
+	if clients == nil {
+		clients = make(map[*websocket.Conn]*wsClient)
+		h.widgetClients[session.ConversationID] = clients
+	}
+	client := &wsClient{conn: c, officeID: officeID, userID: session.ID, conversationID: session.ConversationID}
+	clients[c] = client
+	return client, nil
+}
+
+// unregisterWidgetClient removes a widget visitor's connection
+func (h *WSHandler) unregisterWidgetClient(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if clients := h.widgetClients[client.conversationID]; clients != nil {
+		delete(clients, client.conn)
+		if len(clients) == 0 {
+			delete(h.widgetClients, client.conversationID)
+		}
+	}
+}
+
+// deliverEvent sends a bus event to the office's clients on this instance,
+// and the message events of widget conversations to their visitors
+func (h *WSHandler) deliverEvent(event domain.Event) {
+	h.broadcastToOffice(event.OfficeID, eventMessage(event), nil)
+	if widgetEvents[event.Type] {
+		h.deliverToWidget(event)
+	}
+}
+
+// deliverToWidget sends a message event to the visitors of its conversation,
+// with only the payload fields they may see
+func (h *WSHandler) deliverToWidget(event domain.Event) {
+	raw, _ := event.Payload["conversation_id"].(string)
+	conversationID, err := uuid.Parse(raw)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	clients := h.widgetClients[conversationID]
+	if len(clients) == 0 {
+		return
+	}
+
+	payload := make(map[string]any, len(widgetPayloadFields))
+	for _, field := range widgetPayloadFields {
+		if value, ok := event.Payload[field]; ok {
+			payload[field] = value
+		}
+	}
+	msg := WSMessage{EventID: event.ID, EventType: string(event.Type), Payload: payload}
+	for conn, client := range clients {
+		if client.officeID != event.OfficeID {
+			continue
+		}
+		if err := client.writeJSON(msg); err != nil {
+			log.Printf("WebSocket write error, closing widget connection: %v", err)
+			conn.Close()
+		}
+	}
 }
 
 // broadcastToOffice sends a message to all clients in an office, optionally excluding one
@@ -147,11 +455,76 @@ func (h *WSHandler) broadcastToOffice(officeID uuid.UUID, msg WSMessage, exclude
 	defer h.mu.RUnlock()
 
 	clients := h.clients[officeID]
-	for client := range clients {
-		if client != exclude {
-			if err := client.WriteJSON(msg); err != nil {
-				log.Printf("WebSocket write error: %v", err)
+	for conn, client := range clients {
+		if conn != exclude {
+			if err := client.writeJSON(msg); err != nil {
+				// Drop the dead connection; its read loop unregisters it
+				log.Printf("WebSocket write error, closing connection: %v", err)
+				conn.Close()
+			}
+		}
+	}
+}
+
+// Shutdown tells every connected client the server is restarting and closes
+// its connection, so clients reconnect to another instance or after the restart
+func (h *WSHandler) Shutdown() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	msg := WSMessage{
+		EventID:   uuid.New().String(),
+		EventType: "server_restarting",
+		Payload:   map[string]any{"message": "server is restarting, please reconnect"},
+	}
+	for _, registry := range []map[uuid.UUID]map[*websocket.Conn]*wsClient{h.clients, h.widgetClients} {
+		for _, clients := range registry {
+			for conn, client := range clients {
+				client.writeJSON(msg)
+				client.mu.Lock()
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting"),
+					time.Now().Add(wsWriteWait))
+				client.mu.Unlock()
+				conn.Close()
 			}
 		}
 	}
 }
+
+// ConnectionStats summarises the WebSocket connections held by this instance
+type ConnectionStats struct {
+	Connections int            `json:"connections"`
+	Offices     int            `json:"offices"`
+	Users       int            `json:"users"`
+	ByOffice    map[string]int `json:"by_office"`
+	// Widget counts chat widget visitor connections, which are not
+	// included in the other counts
+	Widget int `json:"widget"`
+}
+
+// Stats returns the current connection counts
+func (h *WSHandler) Stats() ConnectionStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := ConnectionStats{
+		Offices:  len(h.clients),
+		Users:    len(h.userConnections),
+		ByOffice: make(map[string]int, len(h.clients)),
+	}
+	for officeID, clients := range h.clients {
+		stats.Connections += len(clients)
+		stats.ByOffice[officeID.String()] = len(clients)
+	}
+	for _, clients := range h.widgetClients {
+		stats.Widget += len(clients)
+	}
+	return stats
+}
+
+// GetConnectionMetrics returns WebSocket connection counts for this instance
+// GET /internal/metrics/connections
+func (h *WSHandler) GetConnectionMetrics(c *fiber.Ctx) error {
+	return c.JSON(h.Stats())
+}
@@ -1,20 +1,90 @@
+// Command migrate applies and reverts the SQL migrations in infra/migrations.
+//
+//	migrate [--dry-run] [--dir DIR] status|up|down N|baseline
+//
+// A migration is either a single NNN_name.sql file, which cannot be
+// reverted, or a pair of NNN_name.up.sql and NNN_name.down.sql files.
+// Applied migrations are recorded in schema_migrations with a checksum of
+// their up file; up and down refuse to run when an applied file has changed
+// since. A Postgres advisory lock keeps replicas starting together from
+// migrating at the same time.
 package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/denys89/syn-office/backend/config"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// migrationLockID is the advisory lock key held while migrating
+const migrationLockID = 7265123401
+
+const usage = `Usage: migrate [--dry-run] [--dir DIR] <command>
+
+Commands:
+  status    List migrations and whether they are applied
+  up        Apply every pending migration (the default)
+  down N    Revert the N most recently applied migrations
+  baseline  Record every migration as applied without running it, for a
+            database the Postgres container created from infra/migrations
+`
+
 func main() {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	dryRun := flags.Bool("dry-run", false, "print what would be applied or reverted without changing the database")
+	dir := flags.String("dir", "", "directory holding the migration files")
+	args := parseInterspersed(flags, os.Args[1:])
+
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	steps := 0
+	switch command {
+	case "status", "up", "baseline":
+		if len(args) != 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+	case "down":
+		if len(args) != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			log.Fatalf("down needs a positive number of migrations to revert, got %q", args[0])
+		}
+		steps = n
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	migrationDir := *dir
+	if migrationDir == "" {
+		migrationDir = findMigrationDir()
+	}
+	migrations, err := loadMigrations(migrationDir)
+	if err != nil {
+		log.Fatalf("Failed to read migrations: %v", err)
+	}
+	log.Printf("Found %d migrations in %s", len(migrations), migrationDir)
+
 	// Load configuration
 	cfg := config.MustLoad()
 
@@ -26,162 +96,440 @@ func main() {
 	}
 	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+	ctx := context.Background()
+	// Session-level advisory locks belong to one connection, so everything
+	// runs on the same one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	defer conn.Close()
 	log.Println("Connected to database")
 
-	// 1. Create migration table
-	if err := createMigrationTable(db); err != nil {
-		log.Fatalf("Failed to create migration table: %v", err)
+	m := &migrator{conn: conn, dir: migrationDir, migrations: migrations, dryRun: *dryRun}
+	if command != "status" {
+		unlock, err := m.lock(ctx)
+		if err != nil {
+			log.Fatalf("Failed to take the migration lock: %v", err)
+		}
+		defer unlock()
 	}
 
-	// Check if users table exists (heuristic for initial schema)
-	usersExists, err := tableExists(db, "users")
+	switch command {
+	case "status":
+		err = m.status(ctx)
+	case "up":
+		err = m.up(ctx)
+	case "down":
+		err = m.down(ctx, steps)
+	case "baseline":
+		err = m.baseline(ctx)
+	}
 	if err != nil {
-		log.Fatalf("Failed to check if users table exists: %v", err)
+		// Exiting closes the connection, which releases the lock
+		log.Fatalf("Migration failed: %v", err)
 	}
+}
 
-	// 2. Get applied migrations
-	applied, err := getAppliedMigrations(db)
-	if err != nil {
-		log.Fatalf("Failed to get applied migrations: %v", err)
+// parseInterspersed parses flags given before, between or after the
+// command's arguments and returns the arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
+}
 
-	// Special case: if users table exists but 001 is not marked as applied, mark it.
-	// This handles the case where DB was initialized via Docker volume but not tracked.
-	initialMigration := "001_initial_schema.sql"
-	if usersExists && !applied[initialMigration] {
-		log.Printf("Detected existing 'users' table. Marking %s as applied.", initialMigration)
-		if err := markMigrationApplied(db, initialMigration); err != nil {
-			log.Fatalf("Failed to mark initial migration as applied: %v", err)
+// findMigrationDir looks for infra/migrations from the backend directory,
+// from cmd/migrate and from infra
+func findMigrationDir() string {
+	for _, dir := range []string{"../infra/migrations", "../../infra/migrations", "migrations"} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
 		}
-		applied[initialMigration] = true
 	}
+	return "../infra/migrations"
+}
+
+// migration is one schema change, identified by its file name without the
+// .up.sql or .sql suffix
+type migration struct {
+	name     string
+	upFile   string
+	downFile string
+	checksum string
+}
 
-	// 3. Read migration files
-	// Assuming running from backend root or having correct path
-	// We try to find the infra/migrations directory
-	migrationDir := "../infra/migrations"
-	if _, err := os.Stat(migrationDir); os.IsNotExist(err) {
-		// Try absolute path or relative to current wd
-		log.Printf("Migration dir %s not found, checking current dir...", migrationDir)
-		migrationDir = "migrations" // Fallback if running from infra?
+// migrationName returns the migration a file belongs to and whether the
+// file reverts it
+func migrationName(file string) (name string, down bool) {
+	switch {
+	case strings.HasSuffix(file, ".down.sql"):
+		return strings.TrimSuffix(file, ".down.sql"), true
+	case strings.HasSuffix(file, ".up.sql"):
+		return strings.TrimSuffix(file, ".up.sql"), false
+	default:
+		return strings.TrimSuffix(file, ".sql"), false
 	}
+}
 
-	files, err := os.ReadDir(migrationDir)
+// loadMigrations reads the migration files of dir, ordered by name
+func loadMigrations(dir string) ([]*migration, error) {
+	files, err := os.ReadDir(dir)
 	if err != nil {
-		// Try to look up one level if we are in cmd/migrate
-		migrationDir = "../../infra/migrations"
-		files, err = os.ReadDir(migrationDir)
-		if err != nil {
-			log.Fatalf("Failed to read migration directory: %v", err)
-		}
+		return nil, err
 	}
-	log.Printf("Found migration directory: %s", migrationDir)
 
-	var migrationFiles []string
+	byName := make(map[string]*migration)
 	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".sql") {
-			migrationFiles = append(migrationFiles, f.Name())
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".sql") {
+			continue
 		}
-	}
-	sort.Strings(migrationFiles)
-
-	// 4. Apply new migrations
-	for _, file := range migrationFiles {
-		if applied[file] {
+		name, down := migrationName(f.Name())
+		m := byName[name]
+		if m == nil {
+			m = &migration{name: name}
+			byName[name] = m
+		}
+		if down {
+			m.downFile = f.Name()
 			continue
 		}
+		if m.upFile != "" {
+			return nil, fmt.Errorf("%s and %s are the same migration", m.upFile, f.Name())
+		}
+		m.upFile = f.Name()
+	}
 
-		log.Printf("Applying migration: %s", file)
-		content, err := os.ReadFile(filepath.Join(migrationDir, file))
+	migrations := make([]*migration, 0, len(byName))
+	for _, m := range byName {
+		if m.upFile == "" {
+			return nil, fmt.Errorf("%s has no up migration", m.downFile)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, m.upFile))
 		if err != nil {
-			log.Fatalf("Failed to read file %s: %v", file, err)
+			return nil, err
 		}
+		m.checksum = checksum(content)
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].name < migrations[j].name })
+	return migrations, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// appliedMigration is a row of schema_migrations. Rows recorded before
+// checksums were kept have none until the next up or down.
+type appliedMigration struct {
+	filename  string
+	checksum  sql.NullString
+	appliedAt time.Time
+}
 
-		if err := applyMigration(db, file, string(content)); err != nil {
-			log.Fatalf("Failed to apply migration %s: %v", file, err)
+type migrator struct {
+	conn       *sql.Conn
+	dir        string
+	migrations []*migration
+	dryRun     bool
+}
+
+// lock waits for the migration advisory lock and returns its release
+func (m *migrator) lock(ctx context.Context) (func(), error) {
+	var locked bool
+	if err := m.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockID).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if !locked {
+		log.Println("Another migration is running, waiting for it to finish...")
+		if _, err := m.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+			return nil, err
 		}
-		log.Printf("Successfully applied: %s", file)
 	}
-
-	log.Println("All migrations applied successfully!")
+	return func() {
+		if _, err := m.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			log.Printf("Failed to release the migration lock: %v", err)
+		}
+	}, nil
 }
 
-func createMigrationTable(db *sql.DB) error {
-	query := `
+// prepare creates schema_migrations, or adds the checksum column to one
+// created by earlier versions. A dry run leaves the database untouched.
+func (m *migrator) prepare(ctx context.Context) error {
+	if m.dryRun {
+		return nil
+	}
+	_, err := m.conn.ExecContext(ctx, `
 	CREATE TABLE IF NOT EXISTS schema_migrations (
 		id SERIAL PRIMARY KEY,
 		filename VARCHAR(255) NOT NULL UNIQUE,
 		applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err := db.Exec(query)
+	);
+	ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum VARCHAR(64);`)
 	return err
 }
 
-func tableExists(db *sql.DB, tableName string) (bool, error) {
+// applied returns the applied migrations by name
+func (m *migrator) applied(ctx context.Context) (map[string]appliedMigration, error) {
 	var exists bool
-	query := `SELECT EXISTS (
-		SELECT FROM information_schema.tables 
-		WHERE  table_schema = 'public'
-		AND    table_name   = $1
-	);`
-	err := db.QueryRow(query, tableName).Scan(&exists)
-	return exists, err
-}
+	err := m.conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = 'schema_migrations' AND column_name = 'checksum'
+	)`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT filename, checksum, applied_at FROM schema_migrations"
+	if !exists {
+		// Not prepared yet: a dry run or status on an older table, or none
+		var tableExists bool
+		if err := m.conn.QueryRowContext(ctx, "SELECT to_regclass('public.schema_migrations') IS NOT NULL").Scan(&tableExists); err != nil {
+			return nil, err
+		}
+		if !tableExists {
+			return map[string]appliedMigration{}, nil
+		}
+		query = "SELECT filename, NULL, applied_at FROM schema_migrations"
+	}
 
-func getAppliedMigrations(db *sql.DB) (map[string]bool, error) {
-	rows, err := db.Query("SELECT filename FROM schema_migrations")
+	rows, err := m.conn.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	applied := make(map[string]bool)
+	applied := make(map[string]appliedMigration)
 	for rows.Next() {
-		var filename string
-		if err := rows.Scan(&filename); err != nil {
+		var a appliedMigration
+		if err := rows.Scan(&a.filename, &a.checksum, &a.appliedAt); err != nil {
 			return nil, err
 		}
-		applied[filename] = true
+		name, _ := migrationName(a.filename)
+		applied[name] = a
 	}
-	return applied, nil
+	return applied, rows.Err()
 }
 
-func markMigrationApplied(db *sql.DB, filename string) error {
-	_, err := db.Exec("INSERT INTO schema_migrations (filename) VALUES ($1)", filename)
-	return err
+// verify fails when an applied migration's file changed after it was
+// applied. Rows without a checksum get the file's current one.
+func (m *migrator) verify(ctx context.Context, applied map[string]appliedMigration) error {
+	var changed []string
+	for _, mig := range m.migrations {
+		a, ok := applied[mig.name]
+		if !ok {
+			continue
+		}
+		if !a.checksum.Valid {
+			if !m.dryRun {
+				if _, err := m.conn.ExecContext(ctx, "UPDATE schema_migrations SET checksum = $1 WHERE filename = $2", mig.checksum, a.filename); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if a.checksum.String != mig.checksum {
+			changed = append(changed, mig.upFile)
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("applied migrations were modified since: %s", strings.Join(changed, ", "))
+	}
+	return nil
 }
 
-func applyMigration(db *sql.DB, filename, content string) error {
-	ctx := context.Background()
-	tx, err := db.BeginTx(ctx, nil)
+// status lists every migration with when it was applied
+func (m *migrator) status(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, mig := range m.migrations {
+		known[mig.name] = true
+		state, when := "pending", ""
+		if a, ok := applied[mig.name]; ok {
+			state, when = "applied", a.appliedAt.Format(time.RFC3339)
+			if a.checksum.Valid && a.checksum.String != mig.checksum {
+				state = "modified"
+			}
+		}
+		revertible := ""
+		if mig.downFile != "" {
+			revertible = "down"
+		}
+		fmt.Printf("%-9s %-25s %-4s %s\n", state, when, revertible, mig.name)
+	}
+	for name, a := range applied {
+		if !known[name] {
+			fmt.Printf("%-9s %-25s %-4s %s\n", "missing", a.appliedAt.Format(time.RFC3339), "", name)
+		}
+	}
+	return nil
+}
+
+// up applies every pending migration in order, each in its own transaction
+func (m *migrator) up(ctx context.Context) error {
+	if err := m.prepare(ctx); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(ctx, applied); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.name]; ok {
+			continue
+		}
+		count++
+		if m.dryRun {
+			log.Printf("Would apply: %s", mig.upFile)
+			continue
+		}
+
+		log.Printf("Applying migration: %s", mig.upFile)
+		err := m.run(ctx, mig.upFile, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (filename, checksum) VALUES ($1, $2)", mig.upFile, mig.checksum)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", mig.upFile, err)
+		}
+		log.Printf("Successfully applied: %s", mig.upFile)
+	}
+
+	switch {
+	case count == 0:
+		log.Println("Database is up to date")
+	case m.dryRun:
+		log.Printf("%d migrations would be applied", count)
+	default:
+		log.Println("All migrations applied successfully!")
+	}
+	return nil
+}
+
+// down reverts the steps most recently applied migrations, newest first.
+// Nothing is reverted unless every one of them has a down file.
+func (m *migrator) down(ctx context.Context, steps int) error {
+	if err := m.prepare(ctx); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(ctx, applied); err != nil {
+		return err
+	}
+
+	byName := make(map[string]*migration)
+	for _, mig := range m.migrations {
+		byName[mig.name] = mig
+	}
+	names := make([]string, 0, len(applied))
+	for name := range applied {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	if steps > len(names) {
+		return fmt.Errorf("only %d migrations are applied", len(names))
+	}
+
+	var revert []*migration
+	for _, name := range names[:steps] {
+		mig := byName[name]
+		switch {
+		case mig == nil:
+			return fmt.Errorf("%s has no migration files", name)
+		case mig.downFile == "":
+			return fmt.Errorf("%s has no down migration", mig.upFile)
+		}
+		revert = append(revert, mig)
+	}
+
+	for _, mig := range revert {
+		if m.dryRun {
+			log.Printf("Would revert: %s", mig.downFile)
+			continue
+		}
+
+		log.Printf("Reverting migration: %s", mig.downFile)
+		filename := applied[mig.name].filename
+		err := m.run(ctx, mig.downFile, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE filename = $1", filename)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", mig.downFile, err)
+		}
+		log.Printf("Successfully reverted: %s", mig.downFile)
+	}
+	return nil
+}
+
+// baseline records every pending migration as applied without running it
+func (m *migrator) baseline(ctx context.Context) error {
+	if err := m.prepare(ctx); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.name]; ok {
+			continue
+		}
+		if m.dryRun {
+			log.Printf("Would mark as applied: %s", mig.upFile)
+			continue
+		}
+		if _, err := m.conn.ExecContext(ctx, "INSERT INTO schema_migrations (filename, checksum) VALUES ($1, $2)", mig.upFile, mig.checksum); err != nil {
+			return err
+		}
+		log.Printf("Marked as applied: %s", mig.upFile)
+	}
+	return m.verify(ctx, applied)
+}
+
+// run executes a migration file and records the result in one transaction
+func (m *migrator) run(ctx context.Context, file string, record func(tx *sql.Tx) error) error {
+	content, err := os.ReadFile(filepath.Join(m.dir, file))
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	// Defer rollback, but successful commit will make it distinct
 	defer tx.Rollback()
 
-	// Execute SQL
-	if _, err := tx.ExecContext(ctx, content); err != nil {
-		// If the error indicates that the objects already exist, we assume the migration
-		// was previously applied (e.g. via Docker init) but not tracked.
+	if _, err := tx.ExecContext(ctx, string(content)); err != nil {
 		if isAlreadyExistsError(err) {
-			log.Printf("Migration %s seems already applied (error: %v). Marking as applied.", filename, err)
-			// We must rollback the failed transaction
-			tx.Rollback()
-			// Mark as applied in a separate operation
-			return markMigrationApplied(db, filename)
+			return fmt.Errorf("executing sql: %w (if the Postgres container created this database from infra/migrations, run migrate baseline first)", err)
 		}
 		return fmt.Errorf("executing sql: %w", err)
 	}
-
-	// Record migration
-	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (filename) VALUES ($1)", filename); err != nil {
+	if err := record(tx); err != nil {
 		return fmt.Errorf("recording migration: %w", err)
 	}
-
 	return tx.Commit()
 }
 
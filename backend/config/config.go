@@ -1,11 +1,28 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
+// Defaults of the secrets, which only development may run with
+const (
+	defaultJWTSecret      = "your-super-secret-jwt-key-change-in-production"
+	defaultInternalAPIKey = "dev-internal-key-change-in-production"
+)
+
+// minSecretLength is the shortest JWT secret and internal API key accepted
+// in production
+const minSecretLength = 32
+
 // Config holds all application configuration
 type Config struct {
 	// Database
@@ -14,9 +31,166 @@ type Config struct {
 	// JWT
 	JWTSecret string `envconfig:"JWT_SECRET" default:"your-super-secret-jwt-key-change-in-production"`
 
+	// CORSOrigins are the origins browsers may call the API from; "*"
+	// allows any and is refused in production
+	CORSOrigins []string `envconfig:"CORS_ORIGINS" default:"*"`
+
+	// Requests from TrustedProxies, addresses or CIDR ranges such as the
+	// load balancer's, are attributed to the client address in their
+	// ProxyHeader, which the proxy must set rather than append to; other
+	// requests, and all of them without trusted proxies, to the address
+	// they come from
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+	ProxyHeader    string   `envconfig:"PROXY_HEADER" default:"X-Real-IP"`
+
 	// Services
 	OrchestratorURL string `envconfig:"ORCHESTRATOR_URL" default:"http://localhost:8000"`
 
+	// Context sent with each agent task: at most ContextHistoryMessages
+	// recent messages, ContextMemories memories and ContextKnowledgeChunks
+	// chunks of the office's knowledge base, within an estimated
+	// ContextTokenBudget tokens
+	ContextHistoryMessages int `envconfig:"CONTEXT_HISTORY_MESSAGES" default:"20"`
+	ContextMemories        int `envconfig:"CONTEXT_MEMORIES" default:"10"`
+	ContextTokenBudget     int `envconfig:"CONTEXT_TOKEN_BUDGET" default:"4000"`
+	ContextKnowledgeChunks int `envconfig:"CONTEXT_KNOWLEDGE_CHUNKS" default:"5"`
+
+	// Conversation summaries: a summary completed within SummaryCacheWindow
+	// is returned instead of summarizing again, and conversations with
+	// AutoSummarizeMessages messages since their latest summary are
+	// summarized in the background; 0 turns that off
+	SummaryCacheWindow    time.Duration `envconfig:"SUMMARY_CACHE_WINDOW" default:"10m"`
+	AutoSummarizeMessages int           `envconfig:"AUTO_SUMMARIZE_MESSAGES" default:"100"`
+
+	// Agent-to-agent delegation: sub-task trees at most MaxDelegationDepth
+	// deep holding at most MaxDelegatedTasks sub-tasks
+	MaxDelegationDepth int `envconfig:"MAX_DELEGATION_DEPTH" default:"3"`
+	MaxDelegatedTasks  int `envconfig:"MAX_DELEGATED_TASKS" default:"10"`
+
+	// What happens to a chat task whose estimated credit cost exceeds the
+	// office's remaining budget: "off" skips the estimate, "warn" warns the
+	// office, "block" also holds the task back
+	CostEstimatePolicy string `envconfig:"COST_ESTIMATE_POLICY" default:"warn"`
+
+	// AnalyticsFallback adds up usage analytics from tasks for days whose
+	// usage rollups are missing
+	AnalyticsFallback bool `envconfig:"ANALYTICS_FALLBACK" default:"true"`
+
+	// WebhookAllowPrivateNetworks lets office webhooks deliver to loopback
+	// and private addresses, for local development
+	WebhookAllowPrivateNetworks bool `envconfig:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" default:"false"`
+
+	// Realtime events: "local" keeps them in-process, "redis" shares them
+	// between backend replicas
+	EventBus string `envconfig:"EVENT_BUS" default:"local"`
+	RedisURL string `envconfig:"REDIS_URL" default:"redis://localhost:6379"`
+
+	// Request rate limits: "local" keeps counters per replica, "redis"
+	// shares them between replicas
+	RateLimiter string `envconfig:"RATE_LIMITER" default:"local"`
+
+	// Email: "log" writes emails to the log, "smtp" sends them through
+	// SMTPHost, "sendgrid" through SendGrid's API. AppURL is the frontend
+	// base URL that emailed links point to.
+	Mailer         string `envconfig:"MAILER" default:"log"`
+	SMTPHost       string `envconfig:"SMTP_HOST"`
+	SMTPPort       int    `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUsername   string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword   string `envconfig:"SMTP_PASSWORD"`
+	SendGridAPIKey string `envconfig:"SENDGRID_API_KEY"`
+	MailFrom       string `envconfig:"MAIL_FROM" default:"Synoffice <no-reply@synoffice.local>"`
+	AppURL         string `envconfig:"APP_URL" default:"http://localhost:3000"`
+
+	// OAuth social login; a provider is enabled when its client ID is set.
+	// PublicURL is this backend's public base URL, used for the callback
+	// URLs registered with the providers.
+	GoogleClientID     string `envconfig:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `envconfig:"GOOGLE_CLIENT_SECRET"`
+	GitHubClientID     string `envconfig:"GITHUB_CLIENT_ID"`
+	GitHubClientSecret string `envconfig:"GITHUB_CLIENT_SECRET"`
+	PublicURL          string `envconfig:"PUBLIC_URL" default:"http://localhost:8080"`
+
+	// Attachment storage: "local" keeps files under StoragePath, which all
+	// replicas must share, "s3" keeps them in an S3 compatible bucket such
+	// as MinIO. MaxUploadMB caps request bodies; tiers set lower per-file
+	// limits.
+	Storage          string `envconfig:"STORAGE" default:"local"`
+	StoragePath      string `envconfig:"STORAGE_PATH" default:"data/attachments"`
+	S3Endpoint       string `envconfig:"S3_ENDPOINT"`
+	S3PublicEndpoint string `envconfig:"S3_PUBLIC_ENDPOINT"`
+	S3Region         string `envconfig:"S3_REGION" default:"us-east-1"`
+	S3Bucket         string `envconfig:"S3_BUCKET" default:"synoffice-attachments"`
+	S3AccessKeyID    string `envconfig:"S3_ACCESS_KEY_ID"`
+	S3SecretKey      string `envconfig:"S3_SECRET_ACCESS_KEY"`
+	S3UsePathStyle   bool   `envconfig:"S3_USE_PATH_STYLE" default:"false"`
+	MaxUploadMB      int    `envconfig:"MAX_UPLOAD_MB" default:"100"`
+
+	// Billing: StripeSecretKey lets subscription cancellations and pauses
+	// reach Stripe; StripeWebhookSecret verifies the events Stripe sends,
+	// which are refused without it
+	StripeSecretKey     string `envconfig:"STRIPE_SECRET_KEY"`
+	StripeWebhookSecret string `envconfig:"STRIPE_WEBHOOK_SECRET"`
+
+	// MarketplaceCreditsPerDollar prices premium templates bought with
+	// wallet credits instead of a card; 0 only allows cards
+	MarketplaceCreditsPerDollar int64 `envconfig:"MARKETPLACE_CREDITS_PER_DOLLAR" default:"500"`
+
+	// TemplateAutoApprove publishes template submissions without admin
+	// review, unless content moderation flags them or the security scan
+	// scores them above TemplateRiskThreshold (0-100)
+	TemplateAutoApprove   bool `envconfig:"TEMPLATE_AUTO_APPROVE" default:"false"`
+	TemplateRiskThreshold int  `envconfig:"TEMPLATE_RISK_THRESHOLD" default:"20"`
+
+	// Content moderation of user messages, custom system prompts and
+	// marketplace templates: each is "off", "flag" to queue flagged content
+	// for admin review, or "block" to also refuse it. ModerationBlocklist
+	// lists words and phrases, or regular expressions prefixed with "re:",
+	// separated by commas; with ModerationAPIKey set, content is also
+	// screened by the OpenAI compatible moderation API at ModerationAPIURL.
+	ModerationMessages      string   `envconfig:"MODERATION_MESSAGES" default:"flag"`
+	ModerationSystemPrompts string   `envconfig:"MODERATION_SYSTEM_PROMPTS" default:"flag"`
+	ModerationTemplates     string   `envconfig:"MODERATION_TEMPLATES" default:"flag"`
+	ModerationBlocklist     []string `envconfig:"MODERATION_BLOCKLIST"`
+	ModerationAPIKey        string   `envconfig:"MODERATION_API_KEY"`
+	ModerationAPIURL        string   `envconfig:"MODERATION_API_URL" default:"https://api.openai.com/v1/moderations"`
+	ModerationAPIModel      string   `envconfig:"MODERATION_API_MODEL" default:"omni-moderation-latest"`
+
+	// Memory and knowledge search embed queries, and the knowledge base its
+	// documents, with the OpenAI compatible embeddings API at
+	// EmbeddingsAPIURL, using the orchestrator's embedding model; without
+	// EmbeddingsAPIKey queries match memories' and documents' text instead
+	EmbeddingsAPIKey string `envconfig:"EMBEDDINGS_API_KEY"`
+	EmbeddingsAPIURL string `envconfig:"EMBEDDINGS_API_URL" default:"https://api.openai.com/v1/embeddings"`
+	EmbeddingsModel  string `envconfig:"EMBEDDINGS_MODEL" default:"text-embedding-3-small"`
+
+	// Memory consolidation: memories lose half their importance every
+	// MemoryDecayHalfLife, memories at least MemoryDuplicateSimilarity
+	// similar are merged daily, and agents keep their MemoryMaxPerAgent most
+	// important memories. Zero turns decay or the cap off.
+	MemoryDecayHalfLife       time.Duration `envconfig:"MEMORY_DECAY_HALF_LIFE" default:"2160h"`
+	MemoryMaxPerAgent         int           `envconfig:"MEMORY_MAX_PER_AGENT" default:"500"`
+	MemoryDuplicateSimilarity float64       `envconfig:"MEMORY_DUPLICATE_SIMILARITY" default:"0.95"`
+
+	// SecretsMasterKey is the base64 encoded 32 byte AES-256 key office
+	// secrets are encrypted with; without it the secrets vault is off
+	SecretsMasterKey string `envconfig:"SECRETS_MASTER_KEY"`
+
+	// Web research: searches not answered from the result cache cost
+	// WebResearchSearchCredits, and tasks may run WebResearchMaxSearches of
+	// them unless their office chose otherwise. Results are cached for
+	// WebResearchCacheTTL in "postgres", or in "redis" at RedisURL.
+	WebResearchCache         string        `envconfig:"WEB_RESEARCH_CACHE" default:"postgres"`
+	WebResearchCacheTTL      time.Duration `envconfig:"WEB_RESEARCH_CACHE_TTL" default:"24h"`
+	WebResearchSearchCredits int64         `envconfig:"WEB_RESEARCH_SEARCH_CREDITS" default:"2"`
+	WebResearchMaxSearches   int           `envconfig:"WEB_RESEARCH_MAX_SEARCHES" default:"5"`
+
+	// Data retention: data older than a tier's retention period is purged
+	// daily. RetentionDryRun only reports what would be purged;
+	// RetentionExempt lists entities (messages, tasks, usage, deleted) never
+	// purged.
+	RetentionDryRun bool     `envconfig:"RETENTION_DRY_RUN" default:"false"`
+	RetentionExempt []string `envconfig:"RETENTION_EXEMPT"`
+
 	// Internal API
 	InternalAPIKey string `envconfig:"INTERNAL_API_KEY" default:"dev-internal-key-change-in-production"`
 
@@ -25,24 +199,112 @@ type Config struct {
 	Environment string `envconfig:"ENVIRONMENT" default:"development"`
 }
 
-// Load loads configuration from environment variables
+// Load loads configuration from environment variables. Each secret can
+// instead be read from a file named by the variable with a _FILE suffix,
+// such as JWT_SECRET_FILE, as Docker and Kubernetes secrets are mounted.
 func Load() (*Config, error) {
 	var cfg Config
 	err := envconfig.Process("", &cfg)
 	if err != nil {
 		return nil, err
 	}
+	for i, origin := range cfg.CORSOrigins {
+		cfg.CORSOrigins[i] = strings.TrimRight(strings.TrimSpace(origin), "/")
+	}
+	for i, proxy := range cfg.TrustedProxies {
+		cfg.TrustedProxies[i] = strings.TrimSpace(proxy)
+	}
+	if err := cfg.loadSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	log.Printf("Configuration loaded: Environment=%s, Port=%s", cfg.Environment, cfg.BackendPort)
-	log.Printf("Internal API Key: %s... (length: %d)", cfg.InternalAPIKey[:min(10, len(cfg.InternalAPIKey))], len(cfg.InternalAPIKey))
 	return &cfg, nil
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// secrets returns the settings that may be read from files, by variable
+func (c *Config) secrets() map[string]*string {
+	return map[string]*string{
+		"DATABASE_URL":          &c.DatabaseURL,
+		"JWT_SECRET":            &c.JWTSecret,
+		"REDIS_URL":             &c.RedisURL,
+		"SMTP_PASSWORD":         &c.SMTPPassword,
+		"SENDGRID_API_KEY":      &c.SendGridAPIKey,
+		"GOOGLE_CLIENT_SECRET":  &c.GoogleClientSecret,
+		"GITHUB_CLIENT_SECRET":  &c.GitHubClientSecret,
+		"S3_SECRET_ACCESS_KEY":  &c.S3SecretKey,
+		"STRIPE_SECRET_KEY":     &c.StripeSecretKey,
+		"STRIPE_WEBHOOK_SECRET": &c.StripeWebhookSecret,
+		"MODERATION_API_KEY":    &c.ModerationAPIKey,
+		"EMBEDDINGS_API_KEY":    &c.EmbeddingsAPIKey,
+		"SECRETS_MASTER_KEY":    &c.SecretsMasterKey,
+		"INTERNAL_API_KEY":      &c.InternalAPIKey,
+	}
+}
+
+// loadSecretFiles replaces the secrets whose _FILE variable is set with the
+// content of the file it names, less trailing newlines
+func (c *Config) loadSecretFiles() error {
+	for name, value := range c.secrets() {
+		path, ok := os.LookupEnv(name + "_FILE")
+		if !ok || path == "" {
+			continue
+		}
+		if _, set := os.LookupEnv(name); set {
+			return fmt.Errorf("%s and %s_FILE are both set", name, name)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+		*value = strings.TrimRight(string(content), "\r\n")
+	}
+	return nil
+}
+
+// Validate reports settings the backend cannot run with. In production the
+// JWT secret and internal API key must be changed from their defaults and be
+// at least minSecretLength characters long, and CORS must name its origins.
+func (c *Config) Validate() error {
+	var errs []error
+	if len(c.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ORIGINS must list at least one origin"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q is not an address or CIDR range", proxy))
+			}
+		}
+	}
+	if c.Environment == "production" {
+		errs = append(errs,
+			checkSecret("JWT_SECRET", c.JWTSecret, defaultJWTSecret),
+			checkSecret("INTERNAL_API_KEY", c.InternalAPIKey, defaultInternalAPIKey),
+		)
+		if slices.Contains(c.CORSOrigins, "*") {
+			errs = append(errs, errors.New("CORS_ORIGINS must list the allowed origins instead of * in production"))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// checkSecret rejects a production secret left at its default or too short
+// to resist guessing
+func checkSecret(name, value, defaultValue string) error {
+	switch {
+	case value == defaultValue:
+		return fmt.Errorf("%s must be changed from its default in production", name)
+	case len(value) < minSecretLength:
+		return fmt.Errorf("%s must be at least %d characters in production", name, minSecretLength)
 	}
-	return b
+	return nil
 }
 
 // MustLoad loads configuration and panics if it fails
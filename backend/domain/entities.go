@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"encoding/json"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,21 +11,46 @@ import (
 
 // User represents the Boss (human user) of an office
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Never expose password hash
-	Name         string    `json:"name"`
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"` // Never expose password hash
+	Name            string     `json:"name"`
+	Role            UserRole   `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// PendingEmail is a new address that replaces Email once verified
+	PendingEmail string    `json:"pending_email,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// TokenVersion moves on when the password changes; sessions signed in
+	// with an earlier version are no longer accepted
+	TokenVersion int `json:"-"`
+}
+
+// UserRole defines the access level of a user
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+// IsAdmin reports whether the user has platform admin access
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
 }
 
 // Office represents a virtual workspace owned by a user
 type Office struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Name      string    `json:"name"`
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	// Timezone is the IANA time zone usage analytics count days in
+	Timezone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set once the office is deleted. Deleted offices are
+	// hidden from their owner but kept for billing and the audit log.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // AgentTemplate represents a predefined agent type (extended for marketplace)
@@ -47,8 +75,86 @@ type AgentTemplate struct {
 	RatingCount   int        `json:"rating_count"`
 	Version       string     `json:"version"`
 	Status        string     `json:"status"` // pending, approved, rejected
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	// Moderation fields
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// Security scan of the author's text, from 0 (nothing found) to 100
+	RiskScore    int               `json:"risk_score"`
+	RiskFindings []TemplateFinding `json:"risk_findings,omitempty"`
+
+	// FavoriteCount is how many users saved the template to their favorites
+	FavoriteCount int `json:"favorite_count"`
+
+	// License is what offices agree to when they buy or use the template
+	License TemplateLicense `json:"license"`
+
+	// PendingVersion is the new version awaiting moderation, set in the
+	// moderation queue
+	PendingVersion *TemplateVersion `json:"pending_version,omitempty"`
+}
+
+// LicenseType is the kind of office a template license covers
+type LicenseType string
+
+const (
+	// LicensePersonal covers offices with few seats; larger offices need a
+	// template with a team license
+	LicensePersonal LicenseType = "personal"
+	// LicenseTeam covers offices of any size
+	LicenseTeam LicenseType = "team"
+)
+
+// TemplateLicense is the license a template is sold under
+type TemplateLicense struct {
+	Type LicenseType `json:"type"`
+	// ResaleProhibited forbids publishing a copy of the template's system
+	// prompt as another author's template
+	ResaleProhibited bool `json:"resale_prohibited"`
+	// Terms is the author's license text
+	Terms string `json:"terms"`
+}
+
+// LicenseAcceptance is the license an office accepted when it bought a
+// template
+type LicenseAcceptance struct {
+	License TemplateLicense `json:"license"`
+	// Version is the template version whose license was accepted
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// TemplateFinding is a risky instruction the security scanner found in a
+// template
+type TemplateFinding struct {
+	Rule string `json:"rule"`
+	// Category is exfiltration, tool_abuse or hidden_instructions
+	Category string `json:"category"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// TemplateVersion is a published snapshot of a template's behaviour
+type TemplateVersion struct {
+	ID           uuid.UUID `json:"id"`
+	TemplateID   uuid.UUID `json:"template_id"`
+	Version      string    `json:"version"`
+	SystemPrompt string    `json:"system_prompt"`
+	SkillTags    []string  `json:"skill_tags"`
+	Changelog    string    `json:"changelog"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// License is the template's license as of the version
+	License TemplateLicense `json:"license"`
+
+	// Status is pending until an admin approves the version, which then
+	// replaces the live template, or rejects it
+	Status          string     `json:"status"` // pending, approved, rejected
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	// Security scan of the version, like the template's
+	RiskScore    int               `json:"risk_score"`
+	RiskFindings []TemplateFinding `json:"risk_findings,omitempty"`
 }
 
 // AgentCategory represents a marketplace category
@@ -62,6 +168,22 @@ type AgentCategory struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// MarketplaceFilter defines filtering options for marketplace queries
+type MarketplaceFilter struct {
+	Category      string
+	Categories    []string
+	Search        string
+	IsFeatured    *bool
+	IsPremium     *bool
+	MinPriceCents *int
+	MaxPriceCents *int
+	MinRating     *float64
+	AuthorID      *uuid.UUID
+	SortBy        string // "popular", "rating", "newest", "price_asc", "price_desc"
+	Limit         int
+	Offset        int
+}
+
 // AgentReview represents a user review of an agent template
 type AgentReview struct {
 	ID         uuid.UUID `json:"id"`
@@ -70,8 +192,23 @@ type AgentReview struct {
 	Rating     int       `json:"rating"`
 	Title      string    `json:"title,omitempty"`
 	ReviewText string    `json:"review_text"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	// Engagement, computed when reviews are listed
+	HelpfulCount       int          `json:"helpful_count"`
+	UnhelpfulCount     int          `json:"unhelpful_count"`
+	IsVerifiedPurchase bool         `json:"is_verified_purchase"`
+	Reply              *ReviewReply `json:"reply,omitempty"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+}
+
+// ReviewReply is a template author's public response to a review
+type ReviewReply struct {
+	ID        uuid.UUID `json:"id"`
+	ReviewID  uuid.UUID `json:"review_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+	ReplyText string    `json:"reply_text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // Agent represents an AI agent selected for an office
@@ -82,9 +219,15 @@ type Agent struct {
 	Template           *AgentTemplate `json:"template,omitempty"`
 	CustomName         string         `json:"custom_name,omitempty"`
 	CustomSystemPrompt string         `json:"custom_system_prompt,omitempty"`
+	CustomAvatarURL    string         `json:"custom_avatar_url,omitempty"`
 	IsActive           bool           `json:"is_active"`
-	CreatedAt          time.Time      `json:"created_at"`
-	UpdatedAt          time.Time      `json:"updated_at"`
+	// TemplateVersion is the template version this agent runs. When it lags
+	// behind the template, Template holds that version's snapshot instead.
+	TemplateVersion string    `json:"template_version,omitempty"`
+	LatestVersion   string    `json:"latest_version,omitempty"`
+	UpdateAvailable bool      `json:"update_available"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // GetName returns the agent's display name (custom or template name)
@@ -98,6 +241,17 @@ func (a *Agent) GetName() string {
 	return ""
 }
 
+// GetAvatarURL returns the agent's avatar (custom or template avatar)
+func (a *Agent) GetAvatarURL() string {
+	if a.CustomAvatarURL != "" {
+		return a.CustomAvatarURL
+	}
+	if a.Template != nil {
+		return a.Template.AvatarURL
+	}
+	return ""
+}
+
 // GetSystemPrompt returns the agent's system prompt (custom or template prompt)
 func (a *Agent) GetSystemPrompt() string {
 	if a.CustomSystemPrompt != "" {
@@ -109,6 +263,19 @@ func (a *Agent) GetSystemPrompt() string {
 	return ""
 }
 
+// AgentChange records one customization of an agent. Nil values mean the
+// field was unset and the template's value applied.
+type AgentChange struct {
+	ID        uuid.UUID  `json:"id"`
+	AgentID   uuid.UUID  `json:"agent_id"`
+	OfficeID  uuid.UUID  `json:"office_id"`
+	ChangedBy *uuid.UUID `json:"changed_by,omitempty"`
+	Field     string     `json:"field"`
+	OldValue  *string    `json:"old_value"`
+	NewValue  *string    `json:"new_value"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 // ConversationType defines the type of conversation
 type ConversationType string
 
@@ -117,6 +284,28 @@ const (
 	ConversationTypeGroup  ConversationType = "group"
 )
 
+// OrchestrationMode decides which agents of a group conversation answer a
+// user's message. Agents @mentioned in a message always answer it instead.
+type OrchestrationMode string
+
+const (
+	// OrchestrationMentions lets only @mentioned agents answer
+	OrchestrationMentions OrchestrationMode = "mentions"
+	// OrchestrationRoundRobin has the agents answer in turn
+	OrchestrationRoundRobin OrchestrationMode = "round_robin"
+	// OrchestrationModerator has the moderator agent answer or pass the
+	// message on to the agents it @mentions
+	OrchestrationModerator OrchestrationMode = "moderator"
+	// OrchestrationAll has every agent answer
+	OrchestrationAll OrchestrationMode = "all"
+	// OrchestrationDebate has the agents answer one after another, each
+	// responding to the ones before, for DebateRounds rounds
+	OrchestrationDebate OrchestrationMode = "debate"
+)
+
+// DefaultDebateRounds is how many times each agent speaks in a debate
+const DefaultDebateRounds = 2
+
 // Conversation represents a chat thread
 type Conversation struct {
 	ID           uuid.UUID        `json:"id"`
@@ -124,8 +313,49 @@ type Conversation struct {
 	Type         ConversationType `json:"type"`
 	Name         string           `json:"name,omitempty"`
 	Participants []*Agent         `json:"participants,omitempty"`
-	CreatedAt    time.Time        `json:"created_at"`
-	UpdatedAt    time.Time        `json:"updated_at"`
+	// ArchivedAt is set while the conversation is archived. Archived
+	// conversations are left out of the default listing and agents do not
+	// respond in them.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	// OrchestrationMode is how group agents take turns; ModeratorID is the
+	// routing agent in moderator mode
+	OrchestrationMode OrchestrationMode `json:"orchestration_mode"`
+	ModeratorID       *uuid.UUID        `json:"moderator_id,omitempty"`
+	DebateRounds      int               `json:"debate_rounds"`
+	// UnreadCount and LastMessage are filled in when listing conversations
+	UnreadCount int       `json:"unread_count"`
+	LastMessage *Message  `json:"last_message,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Summary is the latest summary of the conversation, written by one of
+	// its agents at SummarizedAt
+	Summary      string     `json:"summary,omitempty"`
+	SummarizedAt *time.Time `json:"summarized_at,omitempty"`
+}
+
+// IsArchived reports whether the conversation is archived
+func (c *Conversation) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// ConversationRead is how far a user has read a conversation. Messages after
+// the last read one, other than the user's own, are unread.
+type ConversationRead struct {
+	ConversationID    uuid.UUID `json:"conversation_id"`
+	UserID            uuid.UUID `json:"user_id"`
+	LastReadMessageID uuid.UUID `json:"last_read_message_id"`
+	// LastReadAt is the creation time of the last read message
+	LastReadAt  time.Time `json:"last_read_at"`
+	UnreadCount int       `json:"unread_count"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ConversationActivity summarises a conversation for a user: how many
+// messages they have not read and a preview of the latest one
+type ConversationActivity struct {
+	UnreadCount int
+	LastMessage *Message
 }
 
 // SenderType defines who sent a message
@@ -134,6 +364,12 @@ type SenderType string
 const (
 	SenderTypeUser  SenderType = "user"
 	SenderTypeAgent SenderType = "agent"
+	// SenderTypeVisitor marks messages from chat widget visitors; their
+	// SenderID is the widget session
+	SenderTypeVisitor SenderType = "visitor"
+	// SenderTypeSystem marks conversation summaries; their SenderID is the
+	// ConversationSummary
+	SenderTypeSystem SenderType = "system"
 )
 
 // Message represents a chat message
@@ -146,6 +382,122 @@ type Message struct {
 	Content        string         `json:"content"`
 	Metadata       map[string]any `json:"metadata,omitempty"`
 	CreatedAt      time.Time      `json:"created_at"`
+	// ParentMessageID is set on thread replies; it is always the thread's
+	// first message, so threads are one level deep
+	ParentMessageID *uuid.UUID `json:"parent_message_id,omitempty"`
+	// EditedAt is set on edited messages, whose previous contents are kept
+	// in Metadata's edit_history, oldest first. DeletedAt is set on deleted
+	// messages, whose content is erased.
+	EditedAt  *time.Time         `json:"edited_at,omitempty"`
+	DeletedAt *time.Time         `json:"deleted_at,omitempty"`
+	Reactions []*MessageReaction `json:"reactions,omitempty"`
+	// Attachments are the files sent with the message
+	Attachments []*Attachment `json:"attachments,omitempty"`
+
+	// PinnedAt is set on the conversation's latest summary
+	PinnedAt *time.Time `json:"pinned_at,omitempty"`
+}
+
+// IsDeleted reports whether the message was deleted
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// MessageReaction is one emoji reaction on a message and who reacted with it
+type MessageReaction struct {
+	Emoji   string      `json:"emoji"`
+	Count   int         `json:"count"`
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+// Attachment is a file uploaded to a conversation. It is uploaded first
+// and belongs to the message it is then sent with; MessageID is nil until
+// then. The contents live in object storage under StorageKey.
+type Attachment struct {
+	ID             uuid.UUID  `json:"id"`
+	OfficeID       uuid.UUID  `json:"office_id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	MessageID      *uuid.UUID `json:"message_id,omitempty"`
+	UploadedBy     uuid.UUID  `json:"uploaded_by"`
+	Filename       string     `json:"filename"`
+	ContentType    string     `json:"content_type"`
+	SizeBytes      int64      `json:"size_bytes"`
+	StorageKey     string     `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ConversationTranscript is a conversation's whole history, for exporting
+type ConversationTranscript struct {
+	Conversation *Conversation        `json:"conversation"`
+	Messages     []*TranscriptMessage `json:"messages"`
+	// Truncated is set when the conversation had more messages than an
+	// export holds; the oldest ones are included
+	Truncated  bool      `json:"truncated,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// TranscriptMessage is a message in a transcript, with its sender's name
+// and the agent tasks it started, oldest first
+type TranscriptMessage struct {
+	*Message
+	SenderName string  `json:"sender_name"`
+	Tasks      []*Task `json:"tasks,omitempty"`
+}
+
+// DocumentFormat is the format of a document's content
+type DocumentFormat string
+
+const (
+	DocumentFormatMarkdown DocumentFormat = "markdown"
+	DocumentFormatText     DocumentFormat = "text"
+	DocumentFormatHTML     DocumentFormat = "html"
+	DocumentFormatJSON     DocumentFormat = "json"
+	DocumentFormatCSV      DocumentFormat = "csv"
+)
+
+// IsValid reports whether the format is a known document format
+func (f DocumentFormat) IsValid() bool {
+	switch f {
+	case DocumentFormatMarkdown, DocumentFormatText, DocumentFormatHTML, DocumentFormatJSON, DocumentFormatCSV:
+		return true
+	}
+	return false
+}
+
+// Document is a deliverable an agent produced, such as a report or a spec.
+// Content and Title are those of the latest version. ConversationID and
+// AgentID are nil once the conversation or agent is deleted; the document
+// stays with the office.
+type Document struct {
+	ID             uuid.UUID      `json:"id"`
+	OfficeID       uuid.UUID      `json:"office_id"`
+	ConversationID *uuid.UUID     `json:"conversation_id,omitempty"`
+	AgentID        *uuid.UUID     `json:"agent_id,omitempty"`
+	Title          string         `json:"title"`
+	Format         DocumentFormat `json:"format"`
+	Content        string         `json:"content,omitempty"`
+	Version        int            `json:"version"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// DocumentVersion is one saved version of a document
+type DocumentVersion struct {
+	ID         uuid.UUID `json:"id"`
+	DocumentID uuid.UUID `json:"document_id"`
+	Version    int       `json:"version"`
+	// TaskID is the task whose agent saved the version
+	TaskID    *uuid.UUID `json:"task_id,omitempty"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// DocumentFilter narrows a document listing; zero values match everything
+type DocumentFilter struct {
+	OfficeID       uuid.UUID
+	ConversationID uuid.UUID
+	AgentID        uuid.UUID
 }
 
 // TaskStatus defines the current status of a task
@@ -157,8 +509,22 @@ const (
 	TaskStatusWorking  TaskStatus = "working"
 	TaskStatusDone     TaskStatus = "done"
 	TaskStatusFailed   TaskStatus = "failed"
+	// TaskStatusDeadLetter marks a task that could not be dispatched within
+	// its maximum attempts; it is only retried manually
+	TaskStatusDeadLetter TaskStatus = "dead_letter"
+	TaskStatusCancelled  TaskStatus = "cancelled"
 )
 
+// IsValid reports whether the status is a known task status
+func (s TaskStatus) IsValid() bool {
+	switch s {
+	case TaskStatusPending, TaskStatusThinking, TaskStatusWorking, TaskStatusDone,
+		TaskStatusFailed, TaskStatusDeadLetter, TaskStatusCancelled:
+		return true
+	}
+	return false
+}
+
 // Task represents a task assigned to an agent
 type Task struct {
 	ID             uuid.UUID      `json:"id"`
@@ -172,9 +538,79 @@ type Task struct {
 	Output         string         `json:"output,omitempty"`
 	Error          string         `json:"error,omitempty"`
 	TokenUsage     map[string]int `json:"token_usage,omitempty"`
+	Attempts       int            `json:"attempts"`
+	MaxAttempts    int            `json:"max_attempts"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"`
 	StartedAt      *time.Time     `json:"started_at,omitempty"`
 	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
 	CreatedAt      time.Time      `json:"created_at"`
+	// ParentTaskID is set on sub-tasks, which an agent delegated by
+	// @mentioning another agent in its output. RootTaskID is the top of the
+	// tree and Depth the number of delegations below it.
+	ParentTaskID *uuid.UUID `json:"parent_task_id,omitempty"`
+	RootTaskID   *uuid.UUID `json:"root_task_id,omitempty"`
+	Depth        int        `json:"depth"`
+
+	// Model and Provider ran the task; LatencyMs is how long generating the
+	// reply took and CreditsConsumed what the office was charged
+	Model           string `json:"model,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	LatencyMs       int    `json:"latency_ms,omitempty"`
+	CreditsConsumed int64  `json:"credits_consumed"`
+
+	// Kind is what the task's output is for; an empty Kind is a chat task
+	Kind TaskKind `json:"kind"`
+}
+
+// TaskKind defines what a task's output is for
+type TaskKind string
+
+const (
+	// TaskKindChat tasks reply in their conversation
+	TaskKindChat TaskKind = "chat"
+	// TaskKindSummary tasks summarize their conversation; the output becomes
+	// a ConversationSummary instead of a reply
+	TaskKindSummary TaskKind = "summary"
+)
+
+// TaskUsage is what running a task cost, as reported by the orchestrator
+type TaskUsage struct {
+	Model        string
+	Provider     string
+	InputTokens  int
+	OutputTokens int
+	LatencyMs    int
+	Credits      int64
+	// IsLocalModel marks models run on the orchestrator's own hardware,
+	// which cost no credits
+	IsLocalModel bool
+	USDCost      float64
+}
+
+// TaskTree is a task with the sub-tasks delegated from it, recursively
+type TaskTree struct {
+	*Task
+	Subtasks []*TaskTree `json:"subtasks"`
+}
+
+// IsFinished reports whether the task will make no further progress on its
+// own. Failed tasks with a scheduled retry are still unfinished.
+func (t *Task) IsFinished() bool {
+	switch t.Status {
+	case TaskStatusDone, TaskStatusDeadLetter, TaskStatusCancelled:
+		return true
+	case TaskStatusFailed:
+		return t.NextAttemptAt == nil
+	}
+	return false
+}
+
+// TaskFilter selects an office's tasks; zero-valued fields are not filtered on
+type TaskFilter struct {
+	OfficeID       uuid.UUID
+	Status         TaskStatus
+	AgentID        uuid.UUID
+	ConversationID uuid.UUID
 }
 
 // AgentMemory represents long-term memory for an agent
@@ -194,6 +630,47 @@ type AgentMemory struct {
 	UpdatedAt       time.Time      `json:"updated_at"`
 }
 
+// MemoryEmbeddingDimensions is the size of memory embeddings, that of the
+// orchestrator's text-embedding-3-small embeddings
+const MemoryEmbeddingDimensions = 1536
+
+// MemoryMatch is a memory found by a search with how similar it is to the
+// query, from -1 to 1; matches by text have no similarity
+type MemoryMatch struct {
+	*AgentMemory
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// MemoryPair is two of an agent's memories that say nearly the same
+type MemoryPair struct {
+	FirstID  uuid.UUID
+	SecondID uuid.UUID
+}
+
+// Reasons a memory was archived
+const (
+	MemoryArchiveMerged = "merged"
+	MemoryArchiveCapped = "capped"
+)
+
+// Memory types used to classify agent memories
+const (
+	MemoryTypeFact       = "fact"
+	MemoryTypePreference = "preference"
+	MemoryTypeCorrection = "correction"
+	MemoryTypeInsight    = "insight"
+	MemoryTypeTaskResult = "task_result"
+)
+
+// Memory sources describing where an agent memory came from
+const (
+	MemorySourceSystem       = "system"
+	MemorySourceUser         = "user"
+	MemorySourceConversation = "conversation"
+	MemorySourceFeedback     = "feedback"
+	MemorySourceExtraction   = "extraction"
+)
+
 // FeedbackType defines the type of user feedback
 type FeedbackType string
 
@@ -232,6 +709,12 @@ type AgentLearningStats struct {
 	TotalInteractions     int       `json:"total_interactions"`
 	CreatedAt             time.Time `json:"created_at"`
 	UpdatedAt             time.Time `json:"updated_at"`
+
+	// Consolidation: memories merged into a near-duplicate and memories
+	// archived past the cap per agent, which are no longer recalled
+	MergedMemoryCount   int        `json:"merged_memory_count"`
+	ArchivedMemoryCount int        `json:"archived_memory_count"`
+	LastConsolidatedAt  *time.Time `json:"last_consolidated_at,omitempty"`
 }
 
 // =============================================================================
@@ -246,6 +729,15 @@ type CreditWallet struct {
 	TotalPurchased int64     `json:"total_purchased"` // Lifetime purchased credits
 	TotalBonus     int64     `json:"total_bonus"`     // Lifetime bonus credits
 	TotalConsumed  int64     `json:"total_consumed"`  // Lifetime consumed credits
+	// Low balance warning: the office is warned when the balance drops below
+	// LowBalanceThreshold (0 turns it off). Auto top-up then buys the
+	// AutoTopUpPackage credit pack with the saved Stripe payment method, at
+	// most AutoTopUpMaxPerDay times a UTC day.
+	LowBalanceThreshold    int64  `json:"low_balance_threshold"`
+	AutoTopUpEnabled       bool   `json:"auto_top_up_enabled"`
+	AutoTopUpPackage       string `json:"auto_top_up_package,omitempty"`
+	AutoTopUpPaymentMethod string `json:"auto_top_up_payment_method,omitempty"`
+	AutoTopUpMaxPerDay     int    `json:"auto_top_up_max_per_day"`
 	// Budget controls (Phase 2)
 	HourlyLimit          *int64    `json:"hourly_limit,omitempty"` // Max credits per hour
 	DailyLimit           *int64    `json:"daily_limit,omitempty"`  // Max credits per day
@@ -255,6 +747,37 @@ type CreditWallet struct {
 	UpdatedAt            time.Time `json:"updated_at"`
 }
 
+// AutoTopUpStatus defines the state of an automatic credit top-up
+type AutoTopUpStatus string
+
+const (
+	// AutoTopUpStatusPending is being charged
+	AutoTopUpStatusPending   AutoTopUpStatus = "pending"
+	AutoTopUpStatusSucceeded AutoTopUpStatus = "succeeded"
+	// AutoTopUpStatusFailed could not be charged, which turns auto top-up
+	// off for the wallet
+	AutoTopUpStatusFailed AutoTopUpStatus = "failed"
+)
+
+// AutoTopUp is a credit pack bought automatically because the wallet's
+// balance dropped below its low balance threshold
+type AutoTopUp struct {
+	ID                    uuid.UUID       `json:"id"`
+	WalletID              uuid.UUID       `json:"wallet_id"`
+	OfficeID              uuid.UUID       `json:"office_id"`
+	Package               string          `json:"package"`
+	Credits               int64           `json:"credits"`
+	AmountCents           int64           `json:"amount_cents"`
+	PaymentMethodID       string          `json:"payment_method_id"`
+	Status                AutoTopUpStatus `json:"status"`
+	StripePaymentIntentID string          `json:"stripe_payment_intent_id,omitempty"`
+	// CreditTransactionID is the purchase that added the credits
+	CreditTransactionID *uuid.UUID `json:"credit_transaction_id,omitempty"`
+	Error               string     `json:"error,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	CompletedAt         *time.Time `json:"completed_at,omitempty"`
+}
+
 // CreditUsageHourly tracks hourly credit consumption for rate limiting
 type CreditUsageHourly struct {
 	ID              uuid.UUID `json:"id"`
@@ -301,6 +824,87 @@ type CreditTransaction struct {
 	CreatedAt     time.Time       `json:"created_at"`
 }
 
+// Idempotency scopes name the operations that accept an idempotency key
+const (
+	IdempotencyScopeCreditConsume    = "credit_consume"
+	IdempotencyScopeCreditTopUp      = "credit_topup"
+	IdempotencyScopeTemplatePurchase = "template_purchase"
+	// IdempotencyScopeCreditPurchase covers templates bought with credits
+	IdempotencyScopeCreditPurchase = "template_credit_purchase"
+	// IdempotencyScopeBundlePurchase covers bundles bought by card
+	IdempotencyScopeBundlePurchase = "bundle_purchase"
+)
+
+// IdempotencyKey records a client-supplied key for a side-effecting request
+// so that retries replay the original result instead of repeating it.
+// ResourceID is the transaction or earning the first request created, and
+// is nil while that request is still running.
+type IdempotencyKey struct {
+	OfficeID    uuid.UUID
+	Scope       string
+	Key         string
+	RequestHash string
+	ResourceID  *uuid.UUID
+	CreatedAt   time.Time
+}
+
+// =============================================================================
+// Promotional Codes
+// =============================================================================
+
+// PromoCodeKind is what redeeming a promo code grants
+type PromoCodeKind string
+
+const (
+	// PromoKindBonusCredits adds bonus credits to the office's wallet
+	PromoKindBonusCredits PromoCodeKind = "bonus_credits"
+	// PromoKindSubscriptionDiscount takes a percentage off the subscription
+	// price of the office's next invoices
+	PromoKindSubscriptionDiscount PromoCodeKind = "subscription_discount"
+)
+
+// PromoCode is an admin-defined code of a promotional campaign. Each office
+// and each user can redeem a code once.
+type PromoCode struct {
+	ID          uuid.UUID     `json:"id"`
+	Code        string        `json:"code"`
+	Description string        `json:"description,omitempty"`
+	Kind        PromoCodeKind `json:"kind"`
+	// BonusCredits is granted by bonus_credits codes
+	BonusCredits int64 `json:"bonus_credits,omitempty"`
+	// DiscountPercent comes off the subscription price of DiscountPeriods
+	// invoices for subscription_discount codes
+	DiscountPercent int `json:"discount_percent,omitempty"`
+	DiscountPeriods int `json:"discount_periods,omitempty"`
+	// MaxRedemptions caps redemptions across all offices; nil is unlimited
+	MaxRedemptions *int       `json:"max_redemptions,omitempty"`
+	Redemptions    int        `json:"redemptions"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	// DeactivatedAt ends a campaign before it expires
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedBy     *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PromoRedemption records an office redeeming a promo code
+type PromoRedemption struct {
+	ID          uuid.UUID     `json:"id"`
+	PromoCodeID uuid.UUID     `json:"promo_code_id"`
+	Code        string        `json:"code"`
+	Kind        PromoCodeKind `json:"kind"`
+	OfficeID    uuid.UUID     `json:"office_id"`
+	// UserID is nil once the user closed their account
+	UserID       *uuid.UUID `json:"user_id,omitempty"`
+	BonusCredits int64      `json:"bonus_credits,omitempty"`
+	// CreditTransactionID is the bonus transaction of a bonus_credits code
+	CreditTransactionID *uuid.UUID `json:"credit_transaction_id,omitempty"`
+	DiscountPercent     int        `json:"discount_percent,omitempty"`
+	// DiscountPeriodsLeft counts the invoices still to be discounted
+	DiscountPeriodsLeft int       `json:"discount_periods_left,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
 // =============================================================================
 // Subscription System Entities (Phase 3)
 // =============================================================================
@@ -354,6 +958,29 @@ type Subscription struct {
 	Metadata             map[string]any     `json:"metadata,omitempty"`
 	CreatedAt            time.Time          `json:"created_at"`
 	UpdatedAt            time.Time          `json:"updated_at"`
+
+	// PendingTier is a downgrade that takes effect at PendingTierAt, the end
+	// of the billing period it was requested in
+	PendingTier   *SubscriptionTier `json:"pending_tier,omitempty"`
+	PendingTierAt *time.Time        `json:"pending_tier_at,omitempty"`
+
+	// TrialDaysLeft is the number of days, rounded up, until a trialing
+	// subscription's trial ends. It is worked out when the subscription is
+	// read and not stored.
+	TrialDaysLeft *int `json:"trial_days_left,omitempty"`
+}
+
+// DaysLeftInTrial returns the days, rounded up, until the trial ends, or
+// false if the subscription is not trialing
+func (s *Subscription) DaysLeftInTrial(now time.Time) (int, bool) {
+	if s.Status != SubscriptionStatusTrialing || s.TrialEnd == nil {
+		return 0, false
+	}
+	remaining := s.TrialEnd.Sub(now)
+	if remaining <= 0 {
+		return 0, true
+	}
+	return int((remaining + 24*time.Hour - 1) / (24 * time.Hour)), true
 }
 
 // CreditAllocation represents credits allocated per billing period
@@ -370,6 +997,21 @@ type CreditAllocation struct {
 	CreatedAt        time.Time `json:"created_at"`
 }
 
+// PeriodRenewal moves a subscription into its next billing period. The
+// unused credits of the period that ended are either carried over into the
+// new one or expired.
+type PeriodRenewal struct {
+	SubscriptionID uuid.UUID
+	WalletID       uuid.UUID
+	// PeriodEnd is the end of the period being renewed, which the new period
+	// starts at; NextPeriodEnd is the new period's end
+	PeriodEnd       time.Time
+	NextPeriodEnd   time.Time
+	Credits         int64
+	RolloverCredits int64
+	ExpiredCredits  int64
+}
+
 // TierFeatures defines the capabilities of a subscription tier
 type TierFeatures struct {
 	MaxAgents             int      `json:"max_agents" yaml:"max_agents"`
@@ -382,9 +1024,25 @@ type TierFeatures struct {
 	AdvancedOrchestration bool     `json:"advanced_orchestration" yaml:"advanced_orchestration"`
 	Analytics             bool     `json:"analytics" yaml:"analytics"`
 	APIAccess             bool     `json:"api_access" yaml:"api_access"`
+	CustomPrompts         bool     `json:"custom_prompts" yaml:"custom_prompts"`
 	SLA                   bool     `json:"sla,omitempty" yaml:"sla"`
 	DedicatedSupport      bool     `json:"dedicated_support,omitempty" yaml:"dedicated_support"`
 	OnPremiseOption       bool     `json:"on_premise_option,omitempty" yaml:"on_premise_option"`
+	// MaxAttachmentMB caps the size of each uploaded file; 0 disables
+	// attachments. AttachmentTypes lists the accepted MIME types; a
+	// trailing "*" matches any ending, as in "image/*", and "*/*" matches
+	// every type.
+	MaxAttachmentMB int      `json:"max_attachment_mb" yaml:"max_attachment_mb"`
+	AttachmentTypes []string `json:"attachment_types" yaml:"attachment_types"`
+	// RolloverPercent is the share of a period's unused subscription
+	// credits carried into the next period; the rest expire at renewal
+	RolloverPercent int `json:"rollover_percent" yaml:"rollover_percent"`
+	// MaxExportDays is how many days back usage, transaction and earnings
+	// exports may reach; 0 disables exports and -1 is unlimited
+	MaxExportDays int `json:"max_export_days" yaml:"max_export_days"`
+	// Skills are the keys of the skills, such as web_search, the tier's
+	// agents can be given
+	Skills []string `json:"skills" yaml:"skills"`
 }
 
 // TierDefinition defines a subscription tier's config
@@ -398,6 +1056,78 @@ type TierDefinition struct {
 	Features             TierFeatures `json:"features" yaml:"features"`
 }
 
+// TrialDefinition defines the free trial new offices start on. Days of 0
+// turns trials off.
+type TrialDefinition struct {
+	Tier    SubscriptionTier `json:"tier" yaml:"tier"`
+	Days    int              `json:"days" yaml:"days"`
+	Credits int64            `json:"credits" yaml:"credits"`
+}
+
+// CreditPackage is an add-on pack of credits offices can buy
+type CreditPackage struct {
+	Credits       int64   `json:"credits" yaml:"credits"`
+	PriceUSD      float64 `json:"price_usd" yaml:"price_usd"`
+	StripePriceID string  `json:"stripe_price_id,omitempty" yaml:"stripe_price_id"`
+}
+
+// InvoiceStatus defines the payment state of an invoice
+type InvoiceStatus string
+
+const (
+	InvoiceStatusDraft         InvoiceStatus = "draft"
+	InvoiceStatusOpen          InvoiceStatus = "open"
+	InvoiceStatusPaid          InvoiceStatus = "paid"
+	InvoiceStatusVoid          InvoiceStatus = "void"
+	InvoiceStatusUncollectible InvoiceStatus = "uncollectible"
+	// InvoiceStatusUpcoming marks the projection of the next invoice, which
+	// has not been issued
+	InvoiceStatusUpcoming InvoiceStatus = "upcoming"
+)
+
+// InvoiceSource is who issued an invoice
+type InvoiceSource string
+
+const (
+	InvoiceSourceStripe InvoiceSource = "stripe"
+	// InvoiceSourceManual invoices are issued by the backend for offices on
+	// a paid tier that are not billed through Stripe
+	InvoiceSourceManual InvoiceSource = "manual"
+)
+
+// Invoice is a bill for a subscription period and the add-on credits bought
+// during the period before it
+type Invoice struct {
+	// ID is Stripe's invoice ID or, for manual invoices, a UUID; upcoming
+	// invoices have none
+	ID              string        `json:"id,omitempty"`
+	OfficeID        uuid.UUID     `json:"-"`
+	SubscriptionID  uuid.UUID     `json:"-"`
+	Source          InvoiceSource `json:"source"`
+	Number          string        `json:"number,omitempty"`
+	Status          InvoiceStatus `json:"status"`
+	Currency        string        `json:"currency"`
+	AmountCents     int64         `json:"amount_cents"`
+	AmountPaidCents int64         `json:"amount_paid_cents"`
+	PeriodStart     time.Time     `json:"period_start"`
+	PeriodEnd       time.Time     `json:"period_end"`
+	Lines           []InvoiceLine `json:"lines"`
+	// HostedURL is Stripe's payment page for the invoice; PDFURL downloads
+	// it as a PDF
+	HostedURL string     `json:"hosted_url,omitempty"`
+	PDFURL    string     `json:"pdf_url,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+}
+
+// InvoiceLine is one charge on an invoice
+type InvoiceLine struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
 // SubscriptionSummary combines subscription with current usage
 type SubscriptionSummary struct {
 	Subscription           *Subscription   `json:"subscription"`
@@ -408,6 +1138,30 @@ type SubscriptionSummary struct {
 	DaysRemaining          int             `json:"days_remaining"`
 }
 
+// =============================================================================
+// Model Policy Entities
+// =============================================================================
+
+// ModelPolicy constrains which models the orchestrator routes an office's
+// tasks to. The office's policy has no AgentID; an agent's own policy
+// replaces it for that agent's tasks.
+type ModelPolicy struct {
+	ID       uuid.UUID  `json:"id"`
+	OfficeID uuid.UUID  `json:"office_id"`
+	AgentID  *uuid.UUID `json:"agent_id,omitempty"`
+	// AllowedProviders are the providers tasks may run on; empty allows
+	// every provider the tier includes
+	AllowedProviders []string `json:"allowed_providers"`
+	// PreferredModel, from PreferredProvider, is used whenever it can
+	// handle the task
+	PreferredModel    string `json:"preferred_model,omitempty"`
+	PreferredProvider string `json:"preferred_provider,omitempty"`
+	// MaxCreditsPerTask rules out models estimated to cost more per task
+	MaxCreditsPerTask *int64    `json:"max_credits_per_task,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
 // =============================================================================
 // Analytics & Usage Entities (Phase 4)
 // =============================================================================
@@ -427,6 +1181,9 @@ type UsageDaily struct {
 	LocalModelTasks int       `json:"local_model_tasks"`
 	PaidModelTasks  int       `json:"paid_model_tasks"`
 	EstimatedUSD    float64   `json:"estimated_usd"`
+	// FromTasks marks a day added up from the office's tasks because its
+	// rollup was missing
+	FromTasks bool `json:"from_tasks,omitempty"`
 }
 
 // UsageByModel represents usage aggregated by model
@@ -456,11 +1213,111 @@ type UsageByAgent struct {
 	InputTokens     int64     `json:"input_tokens"`
 	OutputTokens    int64     `json:"output_tokens"`
 	AvgScore        *float64  `json:"avg_score,omitempty"`
+	// Score is the agent's performance score over the same days and
+	// ScoreChange how it moved since the days before; both are nil when the
+	// agent finished no tasks then
+	Score       *float64 `json:"score,omitempty"`
+	ScoreChange *float64 `json:"score_change,omitempty"`
+}
+
+// AgentStats is what an agent did over a window of time, the inputs of its
+// performance score
+type AgentStats struct {
+	AgentID        uuid.UUID
+	TasksFinished  int
+	TasksSucceeded int
+	// AvgLatencyMs is nil when no finished task reported its latency
+	AvgLatencyMs    *float64
+	CreditsConsumed int64
+	// Feedback other than positive, corrections included, is negative
+	PositiveFeedback int
+	NegativeFeedback int
+	AvgRating        *float64
+}
+
+// AgentScore is an agent's performance score with the parts it is made of,
+// all from 0 to 100
+type AgentScore struct {
+	Score    float64 `json:"score"`
+	Feedback float64 `json:"feedback"`
+	Success  float64 `json:"success"`
+	Latency  float64 `json:"latency"`
+	Cost     float64 `json:"cost"`
+}
+
+// AgentPerformance is an agent's place on its office's leaderboard
+type AgentPerformance struct {
+	// Rank is 1 for the best score; agents without a score are not ranked
+	// and have 0
+	Rank    int         `json:"rank"`
+	AgentID uuid.UUID   `json:"agent_id"`
+	Name    string      `json:"name"`
+	Role    string      `json:"role"`
+	Score   *AgentScore `json:"score"`
+	// PreviousScore is the score over the window before and ScoreChange
+	// the difference
+	PreviousScore  *float64 `json:"previous_score"`
+	ScoreChange    *float64 `json:"score_change"`
+	TasksFinished  int      `json:"tasks_finished"`
+	SuccessRate    float64  `json:"success_rate"`
+	AvgLatencyMs   int      `json:"avg_latency_ms"`
+	CreditsPerTask float64  `json:"credits_per_task"`
+	FeedbackCount  int      `json:"feedback_count"`
+}
+
+// AgentLeaderboard ranks an office's agents over a rolling window
+type AgentLeaderboard struct {
+	Days   int                `json:"days"`
+	Since  time.Time          `json:"since"`
+	Until  time.Time          `json:"until"`
+	Agents []AgentPerformance `json:"agents"`
+}
+
+// UsageBackfill is the outcome of rebuilding usage rollups from tasks
+type UsageBackfill struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// OfficeDays is how many days of how many offices were written
+	OfficeDays int64 `json:"office_days"`
+	Overwrite  bool  `json:"overwrite"`
+}
+
+// DateRange is a span of whole days in an office's timezone, From and To
+// included. Both are dates at midnight UTC.
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Days returns how many days the range covers
+func (r DateRange) Days() int {
+	return int(r.To.Sub(r.From).Hours()/24) + 1
+}
+
+// Dates returns each day of the range, from the first
+func (r DateRange) Dates() []time.Time {
+	dates := make([]time.Time, 0, r.Days())
+	for d := r.From; !d.After(r.To); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// Previous returns the range of the same length that ends the day before r
+// starts
+func (r DateRange) Previous() DateRange {
+	to := r.From.AddDate(0, 0, -1)
+	return DateRange{From: to.AddDate(0, 0, 1-r.Days()), To: to}
 }
 
 // UsageSummary represents a summary of usage for an office
 type UsageSummary struct {
-	Period           string  `json:"period"` // "30d", "7d", "today"
+	Period string `json:"period"` // "30d", "7d", "today" or "custom"
+	// From and To are the first and last day covered, as YYYY-MM-DD in
+	// Timezone
+	From             string  `json:"from"`
+	To               string  `json:"to"`
+	Timezone         string  `json:"timezone"`
 	CreditsUsed      int64   `json:"credits_used"`
 	CreditsRemaining int64   `json:"credits_remaining"`
 	TasksExecuted    int     `json:"tasks_executed"`
@@ -469,10 +1326,51 @@ type UsageSummary struct {
 	TokensProcessed  int64   `json:"tokens_processed"`
 	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
 	LocalModelRatio  float64 `json:"local_model_ratio"` // % of tasks using free local models
+	// Previous is the period of the same length just before this one, and
+	// Change how this period differs from it
+	Previous *UsageTotals `json:"previous,omitempty"`
+	Change   *UsageChange `json:"change,omitempty"`
+}
+
+// UsageTotals are the totals of a period compared with in a UsageSummary
+type UsageTotals struct {
+	From             string  `json:"from"`
+	To               string  `json:"to"`
+	CreditsUsed      int64   `json:"credits_used"`
+	TasksExecuted    int     `json:"tasks_executed"`
+	TasksSucceeded   int     `json:"tasks_succeeded"`
+	TasksFailed      int     `json:"tasks_failed"`
+	TokensProcessed  int64   `json:"tokens_processed"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	LocalModelRatio  float64 `json:"local_model_ratio"`
+}
+
+// UsageChange compares each total of a UsageSummary with the previous
+// period
+type UsageChange struct {
+	CreditsUsed      UsageDelta `json:"credits_used"`
+	TasksExecuted    UsageDelta `json:"tasks_executed"`
+	TasksSucceeded   UsageDelta `json:"tasks_succeeded"`
+	TasksFailed      UsageDelta `json:"tasks_failed"`
+	TokensProcessed  UsageDelta `json:"tokens_processed"`
+	EstimatedCostUSD UsageDelta `json:"estimated_cost_usd"`
+	// LocalModelRatio's delta is in percentage points
+	LocalModelRatio UsageDelta `json:"local_model_ratio"`
+}
+
+// UsageDelta is how a metric changed from the previous period
+type UsageDelta struct {
+	Delta float64 `json:"delta"`
+	// Percent is the change relative to the previous period, nil when the
+	// previous period was zero
+	Percent *float64 `json:"percent"`
 }
 
 // UsageBreakdown represents detailed usage breakdown
 type UsageBreakdown struct {
+	// From and To are the first and last day covered, as YYYY-MM-DD
+	From    string         `json:"from"`
+	To      string         `json:"to"`
 	ByModel []UsageByModel `json:"by_model"`
 	ByAgent []UsageByAgent `json:"by_agent"`
 	ByDay   []UsageDaily   `json:"by_day"`
@@ -525,8 +1423,15 @@ type AuthorEarning struct {
 	CommissionCents       int       `json:"commission_cents"`
 	AuthorEarningCents    int       `json:"author_earning_cents"`
 	StripePaymentIntentID string    `json:"stripe_payment_intent_id,omitempty"`
-	Status                string    `json:"status"`
-	CreatedAt             time.Time `json:"created_at"`
+	// Status is completed, refunded for a sale that was refunded, or
+	// refund for the negative earning that reverses it
+	Status string `json:"status"`
+	// RefundOf is the sale a refund reverses
+	RefundOf  *uuid.UUID `json:"refund_of,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// BundleID is the bundle this sale is the template's share of
+	BundleID *uuid.UUID `json:"bundle_id,omitempty"`
 }
 
 // PayoutRequest represents an author's payout request
@@ -561,9 +1466,1530 @@ type EarningsSummary struct {
 	PendingPayout    int64 `json:"pending_payout_cents"`
 }
 
+// TemplateViewKind is how a marketplace visitor came across a template
+type TemplateViewKind string
+
+const (
+	// TemplateImpression is the template being listed in browse or search results
+	TemplateImpression TemplateViewKind = "impression"
+	// TemplateDetailView is the template's details being opened
+	TemplateDetailView TemplateViewKind = "view"
+)
+
+// TemplateFunnel counts how visitors went from seeing templates to hiring
+// them. Impressions and views count each visitor once a day.
+type TemplateFunnel struct {
+	Impressions  int   `json:"impressions"`
+	Views        int   `json:"views"`
+	Hires        int   `json:"hires"`
+	Sales        int   `json:"sales"`
+	RevenueCents int64 `json:"revenue_cents"`
+	// ViewRate is the percentage of impressions that were followed by a
+	// view, and ConversionRate the percentage of views followed by a
+	// hire; each is null when there is nothing to divide by
+	ViewRate       *float64 `json:"view_rate"`
+	ConversionRate *float64 `json:"conversion_rate"`
+}
+
+// TemplateStats is how one of an author's templates did
+type TemplateStats struct {
+	TemplateID uuid.UUID `json:"template_id"`
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	TemplateFunnel
+}
+
+// TemplateAnalytics is how an author's templates did over a range of days
+type TemplateAnalytics struct {
+	Days      int             `json:"days"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Totals    TemplateFunnel  `json:"totals"`
+	Templates []TemplateStats `json:"templates"`
+}
+
+// TemplatePurchaseStatus defines the state of a template entitlement
+type TemplatePurchaseStatus string
+
+const (
+	TemplatePurchaseStatusActive   TemplatePurchaseStatus = "active"
+	TemplatePurchaseStatusRefunded TemplatePurchaseStatus = "refunded"
+)
+
+// TemplatePurchase records an office's entitlement to a premium template
+type TemplatePurchase struct {
+	ID          uuid.UUID  `json:"id"`
+	OfficeID    uuid.UUID  `json:"office_id"`
+	TemplateID  uuid.UUID  `json:"template_id"`
+	PurchasedBy uuid.UUID  `json:"purchased_by"`
+	EarningID   *uuid.UUID `json:"earning_id,omitempty"`
+	PriceCents  int        `json:"price_cents"`
+	// PriceCredits is what the office paid in credits when it bought the
+	// template with its wallet instead of a card
+	PriceCredits int64                  `json:"price_credits,omitempty"`
+	Status       TemplatePurchaseStatus `json:"status"`
+	CreatedAt    time.Time              `json:"created_at"`
+	Template     *AgentTemplate         `json:"template,omitempty"`
+
+	// BundleID is the bundle the template was bought with; PriceCents is
+	// then its share of the bundle's price
+	BundleID *uuid.UUID `json:"bundle_id,omitempty"`
+
+	// License is the license accepted with the purchase; nil for purchases
+	// made before templates were licensed
+	License *LicenseAcceptance `json:"license,omitempty"`
+}
+
+// RefundRequestStatus is where a purchase refund request stands
+type RefundRequestStatus string
+
+const (
+	RefundRequestStatusPending  RefundRequestStatus = "pending"
+	RefundRequestStatusRefunded RefundRequestStatus = "refunded"
+	RefundRequestStatusRejected RefundRequestStatus = "rejected"
+)
+
+// RefundRequest is an office asking for a template purchase to be refunded
+type RefundRequest struct {
+	ID          uuid.UUID           `json:"id"`
+	PurchaseID  uuid.UUID           `json:"purchase_id"`
+	OfficeID    uuid.UUID           `json:"office_id"`
+	RequestedBy *uuid.UUID          `json:"requested_by,omitempty"`
+	Reason      string              `json:"reason"`
+	Status      RefundRequestStatus `json:"status"`
+	// RejectionReason tells the office why a rejected request was turned down
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	StripeRefundID  string     `json:"stripe_refund_id,omitempty"`
+	ReviewedBy      *uuid.UUID `json:"reviewed_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	// The purchase's template, price and sale
+	TemplateID   uuid.UUID  `json:"template_id"`
+	TemplateName string     `json:"template_name"`
+	PriceCents   int        `json:"price_cents"`
+	PriceCredits int64      `json:"price_credits,omitempty"`
+	EarningID    *uuid.UUID `json:"earning_id,omitempty"`
+}
+
 // PurchaseRequest represents a marketplace purchase request
 type PurchaseRequest struct {
 	TemplateID uuid.UUID `json:"template_id"`
 	OfficeID   uuid.UUID `json:"office_id"`
 	UserID     uuid.UUID `json:"user_id"`
 }
+
+// =============================================================================
+// Template Bundles
+// =============================================================================
+
+// TemplateBundle is a set of an author's premium templates sold together
+// for less than they cost one by one
+type TemplateBundle struct {
+	ID          uuid.UUID `json:"id"`
+	AuthorID    uuid.UUID `json:"author_id"`
+	AuthorName  string    `json:"author_name"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	PriceCents  int       `json:"price_cents"`
+	// ListPriceCents is what the member templates cost bought separately
+	ListPriceCents int  `json:"list_price_cents"`
+	IsFeatured     bool `json:"is_featured"`
+	IsPublic       bool `json:"is_public"`
+	// Listed reports whether the bundle is on sale: it is public, every
+	// member template is approved, public and premium, and it costs less
+	// than its members do separately
+	Listed      bool            `json:"listed"`
+	TemplateIDs []uuid.UUID     `json:"template_ids"`
+	Templates   []AgentTemplate `json:"templates"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// BundleFilter selects listed bundles in the marketplace
+type BundleFilter struct {
+	// Search matches the bundle's name and description and its members'
+	// names
+	Search     string
+	AuthorID   *uuid.UUID
+	IsFeatured *bool
+	Limit      int
+	Offset     int
+}
+
+// =============================================================================
+// Template Favorites
+// =============================================================================
+
+// TemplateFavorite is a template a user saved to their favorites
+type TemplateFavorite struct {
+	UserID     uuid.UUID `json:"user_id"`
+	TemplateID uuid.UUID `json:"template_id"`
+	// Notify asks for a notification when the template, if premium, goes
+	// on sale or publishes a new version
+	Notify    bool           `json:"notify"`
+	CreatedAt time.Time      `json:"created_at"`
+	Template  *AgentTemplate `json:"template,omitempty"`
+}
+
+// FavoriteUpdate is a favorite whose template's version or price changed
+// since its user last heard of them
+type FavoriteUpdate struct {
+	UserID             uuid.UUID
+	Notify             bool
+	PreviousVersion    string
+	PreviousPriceCents int
+}
+
+// =============================================================================
+// Author Profiles
+// =============================================================================
+
+// AuthorProfile is a template author's public marketplace profile. Authors
+// who never edited theirs are shown under their account name.
+type AuthorProfile struct {
+	UserID      uuid.UUID    `json:"user_id"`
+	DisplayName string       `json:"display_name"`
+	Bio         string       `json:"bio"`
+	Links       []AuthorLink `json:"links"`
+	// IsVerified is the badge an admin grants authors whose identity they
+	// checked
+	IsVerified bool       `json:"is_verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	// Verification is only shown to the author and admins
+	Verification *AuthorVerification `json:"verification,omitempty"`
+	Stats        AuthorStats         `json:"stats"`
+	// Templates and Bundles are what the author has on sale, filled in for
+	// the public profile
+	Templates []AgentTemplate  `json:"templates,omitempty"`
+	Bundles   []TemplateBundle `json:"bundles,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// AuthorLink is a link an author shows on their profile
+type AuthorLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// AuthorVerificationStatus is where an author's verification stands
+type AuthorVerificationStatus string
+
+const (
+	AuthorVerificationNone     AuthorVerificationStatus = "none"
+	AuthorVerificationPending  AuthorVerificationStatus = "pending"
+	AuthorVerificationVerified AuthorVerificationStatus = "verified"
+	AuthorVerificationRejected AuthorVerificationStatus = "rejected"
+)
+
+// AuthorVerification is an author's request for the verified badge and how
+// an admin decided it
+type AuthorVerification struct {
+	Status AuthorVerificationStatus `json:"status"`
+	// Message is what the author told the admins to support the request
+	Message         string     `json:"message,omitempty"`
+	RequestedAt     *time.Time `json:"requested_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReviewedBy      *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// AuthorStats sums up an author's approved public templates
+type AuthorStats struct {
+	TemplateCount  int `json:"template_count"`
+	TotalDownloads int `json:"total_downloads"`
+	// RatingAverage weighs each template's average by its number of ratings
+	RatingAverage float64 `json:"rating_average"`
+	RatingCount   int     `json:"rating_count"`
+}
+
+// =============================================================================
+// Notification Entities
+// =============================================================================
+
+// NotificationType defines the category of an office notification
+type NotificationType string
+
+const (
+	NotificationTypeBudgetAlert      NotificationType = "budget_alert"
+	NotificationTypeTemplateApproved NotificationType = "template_approved"
+	NotificationTypeTemplateRejected NotificationType = "template_rejected"
+	// NotificationTypeTaskCompleted is sent when an agent finishes a task
+	NotificationTypeTaskCompleted NotificationType = "task_completed"
+	// NotificationTypeMarketplaceSale tells an author a template sold
+	NotificationTypeMarketplaceSale NotificationType = "marketplace_sale"
+	// NotificationTypePayoutProcessed tells an author a payout was sent
+	NotificationTypePayoutProcessed NotificationType = "payout_processed"
+	// NotificationTypePayoutFailed tells an author a payout was rejected
+	// and its amount returned to their balance
+	NotificationTypePayoutFailed NotificationType = "payout_failed"
+	// NotificationTypeMarketplaceRefund tells an office how its refund
+	// request was decided, and an author that a sale was refunded
+	NotificationTypeMarketplaceRefund NotificationType = "marketplace_refund"
+	// NotificationTypeSubscriptionUpdated is sent when the office's tier changes
+	NotificationTypeSubscriptionUpdated NotificationType = "subscription_updated"
+	// NotificationTypeSubscriptionRenewed is sent when a new billing period
+	// starts with its credits
+	NotificationTypeSubscriptionRenewed NotificationType = "subscription_renewed"
+	// NotificationTypePaymentFailed is sent when a subscription renewal
+	// could not be charged
+	NotificationTypePaymentFailed NotificationType = "payment_failed"
+	// NotificationTypeLowCredits is sent when the office's credit balance
+	// runs low
+	NotificationTypeLowCredits NotificationType = "low_credits"
+	// NotificationTypeAutoTopUp is sent when credits were bought
+	// automatically, or could not be
+	NotificationTypeAutoTopUp NotificationType = "auto_top_up"
+	// NotificationTypeAuthorVerification tells an author how their
+	// verification was decided, or that their badge was revoked
+	NotificationTypeAuthorVerification NotificationType = "author_verification"
+	// NotificationTypeFavoriteUpdate tells a user a premium template they
+	// favorited went on sale or published a new version
+	NotificationTypeFavoriteUpdate NotificationType = "favorite_update"
+)
+
+// NotificationTypes lists every notification type, in the order preferences
+// are shown
+var NotificationTypes = []NotificationType{
+	NotificationTypeTaskCompleted,
+	NotificationTypeBudgetAlert,
+	NotificationTypeLowCredits,
+	NotificationTypeAutoTopUp,
+	NotificationTypeSubscriptionUpdated,
+	NotificationTypeSubscriptionRenewed,
+	NotificationTypePaymentFailed,
+	NotificationTypeMarketplaceSale,
+	NotificationTypeMarketplaceRefund,
+	NotificationTypePayoutProcessed,
+	NotificationTypePayoutFailed,
+	NotificationTypeTemplateApproved,
+	NotificationTypeTemplateRejected,
+	NotificationTypeAuthorVerification,
+	NotificationTypeFavoriteUpdate,
+}
+
+// IsValid reports whether the type is a known notification type
+func (t NotificationType) IsValid() bool {
+	return slices.Contains(NotificationTypes, t)
+}
+
+// NotificationPreference is how a user is told about one notification type:
+// in the app, by email, both or not at all
+type NotificationPreference struct {
+	Type  NotificationType `json:"notification_type"`
+	InApp bool             `json:"in_app"`
+	Email bool             `json:"email"`
+}
+
+// DefaultNotificationPreference applies to types a user has not chosen for.
+// Everything is shown in the app; budget and credit alerts, billing,
+// payouts and refunds are also emailed.
+func DefaultNotificationPreference(t NotificationType) NotificationPreference {
+	pref := NotificationPreference{Type: t, InApp: true}
+	switch t {
+	case NotificationTypeBudgetAlert, NotificationTypeLowCredits, NotificationTypeAutoTopUp, NotificationTypeSubscriptionUpdated,
+		NotificationTypePaymentFailed, NotificationTypePayoutProcessed, NotificationTypePayoutFailed,
+		NotificationTypeMarketplaceRefund:
+		pref.Email = true
+	}
+	return pref
+}
+
+// Notification represents a persisted notification for an office
+type Notification struct {
+	ID        uuid.UUID        `json:"id"`
+	OfficeID  uuid.UUID        `json:"office_id"`
+	Type      NotificationType `json:"notification_type"`
+	Title     string           `json:"title"`
+	Message   string           `json:"message"`
+	Payload   map[string]any   `json:"payload,omitempty"`
+	IsRead    bool             `json:"is_read"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// BudgetPeriod identifies the window a budget limit applies to
+type BudgetPeriod string
+
+const (
+	BudgetPeriodHourly BudgetPeriod = "hourly"
+	BudgetPeriodDaily  BudgetPeriod = "daily"
+)
+
+// BudgetAlert describes a budget limit whose alert threshold has been crossed
+type BudgetAlert struct {
+	Period      BudgetPeriod `json:"period"`
+	Limit       int64        `json:"limit"`
+	Used        int64        `json:"used"`
+	Remaining   int64        `json:"remaining"`
+	PercentUsed float64      `json:"percent_used"`
+	Threshold   int          `json:"threshold"` // Alert at X% remaining
+}
+
+// CostEstimate is the estimated credit cost of an agent answering an input,
+// set against what the office can still spend
+type CostEstimate struct {
+	AgentID          uuid.UUID `json:"agent_id"`
+	Model            string    `json:"model"`
+	Provider         string    `json:"provider"`
+	EstimatedCredits int64     `json:"estimated_credits"`
+	InputTokens      int       `json:"estimated_input_tokens"`
+	OutputTokens     int       `json:"estimated_output_tokens"`
+	// RemainingBudget is the office's balance, or less if its hourly or
+	// daily limit leaves less
+	RemainingBudget int64 `json:"remaining_budget"`
+	ExceedsBudget   bool  `json:"exceeds_budget"`
+}
+
+// =============================================================================
+// Scheduled Task Entities
+// =============================================================================
+
+// ScheduledTask is agent work that runs once at RunAt or repeatedly on a
+// cron schedule evaluated in Timezone. Exactly one of CronExpression and
+// RunAt is set.
+type ScheduledTask struct {
+	ID             uuid.UUID  `json:"id"`
+	OfficeID       uuid.UUID  `json:"office_id"`
+	AgentID        uuid.UUID  `json:"agent_id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	Name           string     `json:"name"`
+	Input          string     `json:"input"`
+	CronExpression string     `json:"cron_expression,omitempty"`
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	Timezone       string     `json:"timezone"`
+	IsActive       bool       `json:"is_active"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// TaskTemplateID is the task template the input was filled in from
+	TaskTemplateID *uuid.UUID `json:"task_template_id,omitempty"`
+}
+
+// IsRecurring reports whether the schedule runs on a cron expression
+func (s *ScheduledTask) IsRecurring() bool {
+	return s.CronExpression != ""
+}
+
+// ScheduledTaskRunStatus defines the outcome of a schedule firing
+type ScheduledTaskRunStatus string
+
+const (
+	ScheduledTaskRunCreated ScheduledTaskRunStatus = "created"
+	ScheduledTaskRunFailed  ScheduledTaskRunStatus = "failed"
+)
+
+// ScheduledTaskRun records one firing of a schedule and the task it created
+type ScheduledTaskRun struct {
+	ID           uuid.UUID              `json:"id"`
+	ScheduleID   uuid.UUID              `json:"schedule_id"`
+	TaskID       *uuid.UUID             `json:"task_id,omitempty"`
+	Status       ScheduledTaskRunStatus `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	ScheduledFor time.Time              `json:"scheduled_for"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// =============================================================================
+// Task Templates
+// =============================================================================
+
+// TaskTemplate is reusable task input with {{placeholders}}, filled in to
+// dispatch to an agent once or on a schedule
+type TaskTemplate struct {
+	ID          uuid.UUID `json:"id"`
+	OfficeID    uuid.UUID `json:"office_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Body        string    `json:"body"`
+	// Placeholders are the names of the placeholders in Body, in the order
+	// they first appear
+	Placeholders []string `json:"placeholders"`
+	// Defaults fill in placeholders given no value
+	Defaults  map[string]string `json:"defaults"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// =============================================================================
+// Project Boards
+// =============================================================================
+
+// Project is a kanban-style board over an office's tasks, which are placed
+// as cards in its ordered columns
+type Project struct {
+	ID          uuid.UUID `json:"id"`
+	OfficeID    uuid.UUID `json:"office_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Columns are loaded with the board, in order
+	Columns []*BoardColumn `json:"columns,omitempty"`
+}
+
+// BoardColumn is a column of a project's board, positioned from 0
+type BoardColumn struct {
+	ID        uuid.UUID `json:"id"`
+	ProjectID uuid.UUID `json:"project_id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	// Cards are loaded with the board, in order
+	Cards []*BoardCard `json:"cards"`
+}
+
+// BoardCard places a task in a column of a board, positioned from 0. A
+// task is a card on at most one board.
+type BoardCard struct {
+	TaskID    uuid.UUID `json:"task_id"`
+	ColumnID  uuid.UUID `json:"column_id"`
+	Position  int       `json:"position"`
+	AddedAt   time.Time `json:"added_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Task summarizes the task with its agent, status, input and times
+	Task *Task `json:"task,omitempty"`
+}
+
+// =============================================================================
+// Search
+// =============================================================================
+
+// SearchResultType is the kind of content a search result is
+type SearchResultType string
+
+const (
+	SearchResultMessage  SearchResultType = "message"
+	SearchResultTask     SearchResultType = "task"
+	SearchResultDocument SearchResultType = "document"
+)
+
+// SearchResultTypes are the kinds of content an office can search
+var SearchResultTypes = []SearchResultType{SearchResultMessage, SearchResultTask, SearchResultDocument}
+
+// SearchMarkStart and SearchMarkEnd delimit the matched words in the
+// headlines repositories return, which the search service renders as HTML
+const (
+	SearchMarkStart = "\x01"
+	SearchMarkEnd   = "\x02"
+)
+
+// SearchFilter selects the content an office search matches. Query is in
+// web search syntax: quoted phrases, or and -word. Empty Types match every
+// type and a zero AgentID any author.
+type SearchFilter struct {
+	OfficeID uuid.UUID
+	Query    string
+	Types    []SearchResultType
+	AgentID  uuid.UUID
+}
+
+// SearchResult is a message, task output or document matching a search.
+// Headline is an excerpt with the matched words in <mark> tags and the rest
+// HTML-escaped.
+type SearchResult struct {
+	Type           SearchResultType `json:"type"`
+	ID             uuid.UUID        `json:"id"`
+	ConversationID *uuid.UUID       `json:"conversation_id,omitempty"`
+	// AgentID is the agent that wrote the message, ran the task or saved
+	// the document; it is unset on user messages
+	AgentID   *uuid.UUID `json:"agent_id,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Headline  string     `json:"headline"`
+	Rank      float64    `json:"rank"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// =============================================================================
+// Conversation Summaries
+// =============================================================================
+
+// SummaryStatus is how far a summarization run got
+type SummaryStatus string
+
+const (
+	SummaryStatusPending SummaryStatus = "pending"
+	SummaryStatusDone    SummaryStatus = "done"
+	// SummaryStatusFailed marks runs whose task failed or is gone; they are
+	// never completed
+	SummaryStatusFailed SummaryStatus = "failed"
+)
+
+// ConversationSummary is one summarization run of a conversation. An agent
+// summarizes the messages after the previous run's through message, up to
+// ThroughMessageID, together with the previous summary, so Content covers
+// the whole conversation up to ThroughAt.
+type ConversationSummary struct {
+	ID             uuid.UUID     `json:"id"`
+	OfficeID       uuid.UUID     `json:"office_id"`
+	ConversationID uuid.UUID     `json:"conversation_id"`
+	TaskID         *uuid.UUID    `json:"task_id,omitempty"`
+	AgentID        uuid.UUID     `json:"agent_id"`
+	Status         SummaryStatus `json:"status"`
+	// ThroughMessageID and ThroughAt are the last message the run
+	// summarized, and MessageCount how many it summarized
+	ThroughMessageID uuid.UUID `json:"through_message_id"`
+	ThroughAt        time.Time `json:"through_at"`
+	MessageCount     int       `json:"message_count"`
+	// Auto marks runs started because the conversation grew long
+	Auto    bool   `json:"auto"`
+	Content string `json:"content,omitempty"`
+	// MessageID is the pinned system message posting the summary
+	MessageID   *uuid.UUID `json:"message_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// =============================================================================
+// API Key Entities
+// =============================================================================
+
+// APIKeyScope is a permission granted to an API key
+type APIKeyScope string
+
+const (
+	// APIKeyScopeRead allows GET requests
+	APIKeyScopeRead APIKeyScope = "read"
+	// APIKeyScopeWrite allows requests that change data, and implies read
+	APIKeyScopeWrite APIKeyScope = "write"
+)
+
+// APIKey grants programmatic access to an office's API on behalf of the user
+// who created it. Only a hash of the key is stored.
+type APIKey struct {
+	ID         uuid.UUID     `json:"id"`
+	OfficeID   uuid.UUID     `json:"office_id"`
+	UserID     uuid.UUID     `json:"user_id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	KeyHash    string        `json:"-"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	UsageCount int64         `json:"usage_count"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == APIKeyScopeWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// Auth Token Entities
+// =============================================================================
+
+// AuthTokenPurpose is what a single-use auth token may be redeemed for
+type AuthTokenPurpose string
+
+const (
+	AuthTokenPasswordReset     AuthTokenPurpose = "password_reset"
+	AuthTokenEmailVerification AuthTokenPurpose = "email_verification"
+)
+
+// AuthToken is a single-use token emailed to a user to reset their password
+// or verify their address. Only a signature of the token is stored.
+type AuthToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   AuthTokenPurpose
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// OAuthIdentity links a user to their account at an OAuth provider
+type OAuthIdentity struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	Provider       string
+	ProviderUserID string
+	Email          string
+	CreatedAt      time.Time
+}
+
+// OAuthProfile is what an OAuth provider reports about the signed-in user
+type OAuthProfile struct {
+	ProviderUserID string
+	Email          string
+	// EmailVerified is whether the provider has confirmed the user owns Email
+	EmailVerified bool
+	Name          string
+}
+
+// Email is an outgoing email. Body is the plain-text version; HTMLBody is
+// optional and sent as an alternative part when set.
+type Email struct {
+	To       string
+	Subject  string
+	Body     string
+	HTMLBody string
+	// Template names the template the email was rendered from, if any
+	Template string
+}
+
+// OutboxEmailStatus defines the delivery state of a queued email
+type OutboxEmailStatus string
+
+const (
+	OutboxEmailPending OutboxEmailStatus = "pending"
+	OutboxEmailSent    OutboxEmailStatus = "sent"
+	// OutboxEmailFailed emails used up their attempts and are not retried
+	OutboxEmailFailed OutboxEmailStatus = "failed"
+)
+
+// OutboxEmail is an email queued for delivery
+type OutboxEmail struct {
+	ID            uuid.UUID
+	Email         Email
+	Status        OutboxEmailStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+// =============================================================================
+// Data Exports
+// =============================================================================
+
+// DataExportStatus defines the state of a user's data export
+type DataExportStatus string
+
+const (
+	// DataExportPending exports are waiting to be built or being built
+	DataExportPending DataExportStatus = "pending"
+	DataExportReady   DataExportStatus = "ready"
+	// DataExportFailed exports used up their attempts and are not retried
+	DataExportFailed DataExportStatus = "failed"
+	// DataExportExpired exports had their archive removed from storage
+	DataExportExpired DataExportStatus = "expired"
+)
+
+// DataExport is an archive of everything a user has stored: their profile,
+// and the conversations, tasks and credit transactions of their offices
+type DataExport struct {
+	ID            uuid.UUID        `json:"id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	Status        DataExportStatus `json:"status"`
+	Attempts      int              `json:"-"`
+	NextAttemptAt time.Time        `json:"-"`
+	StorageKey    string           `json:"-"`
+	SizeBytes     int64            `json:"size_bytes,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
+	// ExpiresAt is when a ready archive stops being downloadable
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// =============================================================================
+// Webhooks
+// =============================================================================
+
+// WebhookEventType names an event delivered to webhooks
+type WebhookEventType string
+
+const (
+	// WebhookEventTaskCompleted is sent when an agent finishes a task
+	WebhookEventTaskCompleted WebhookEventType = "task.completed"
+	// WebhookEventMessageCreated is sent for every message posted by a user
+	// or agent
+	WebhookEventMessageCreated WebhookEventType = "message.created"
+	// WebhookEventCreditsLow is sent when the office's balance drops below
+	// the low credit threshold
+	WebhookEventCreditsLow WebhookEventType = "credits.low"
+	// WebhookEventSubscriptionUpdated is sent when the office's tier or
+	// subscription status changes
+	WebhookEventSubscriptionUpdated WebhookEventType = "subscription.updated"
+)
+
+// WebhookEventTypes lists every event webhooks can subscribe to
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventTaskCompleted,
+	WebhookEventMessageCreated,
+	WebhookEventCreditsLow,
+	WebhookEventSubscriptionUpdated,
+}
+
+// Webhook is a URL an office receives events at
+type Webhook struct {
+	ID       uuid.UUID `json:"id"`
+	OfficeID uuid.UUID `json:"office_id"`
+	URL      string    `json:"url"`
+	// Secret signs deliveries; it is only returned when the webhook is
+	// created
+	Secret     string             `json:"-"`
+	EventTypes []WebhookEventType `json:"event_types"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// Subscribes reports whether the webhook receives events of eventType
+func (w *Webhook) Subscribes(eventType WebhookEventType) bool {
+	return slices.Contains(w.EventTypes, eventType)
+}
+
+// WebhookDeliveryStatus defines the delivery state of a webhook event
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryFailed deliveries used up their attempts and are not
+	// retried
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event queued for delivery to one webhook
+type WebhookDelivery struct {
+	ID        uuid.UUID        `json:"id"`
+	WebhookID uuid.UUID        `json:"webhook_id"`
+	EventID   uuid.UUID        `json:"event_id"`
+	EventType WebhookEventType `json:"event_type"`
+	// Payload is the request body sent to the webhook
+	Payload       json.RawMessage       `json:"payload"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"next_attempt_at"`
+	CreatedAt     time.Time             `json:"created_at"`
+	DeliveredAt   *time.Time            `json:"delivered_at,omitempty"`
+	// AttemptLog holds the attempts made so far, oldest first
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log"`
+}
+
+// WebhookDeliveryAttempt records one try at delivering an event
+type WebhookDeliveryAttempt struct {
+	Attempt int `json:"attempt"`
+	// StatusCode is the HTTP status of the response, nil when none was
+	// received
+	StatusCode  *int      `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int       `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// =============================================================================
+// Chat Widgets
+// =============================================================================
+
+// WidgetToken exposes one of an office's agents to visitors of the office's
+// own site through the public widget endpoints. Only a hash of the token is
+// stored.
+type WidgetToken struct {
+	ID        uuid.UUID  `json:"id"`
+	OfficeID  uuid.UUID  `json:"office_id"`
+	AgentID   uuid.UUID  `json:"agent_id"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	TokenHash string     `json:"-"`
+	// AllowedOrigins are the origins embedding pages may be served from;
+	// empty allows any
+	AllowedOrigins []string `json:"allowed_origins"`
+	// RateLimit is how many requests the token accepts per minute, across
+	// all of its visitors
+	RateLimit    int        `json:"rate_limit"`
+	MessageCount int64      `json:"message_count"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// CreditsUsed is what the tasks of the token's conversations were
+	// charged to the office. It is only loaded when listing tokens.
+	CreditsUsed int64 `json:"credits_used"`
+}
+
+// AllowsOrigin reports whether a page served from origin may use the token
+func (t *WidgetToken) AllowsOrigin(origin string) bool {
+	if len(t.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range t.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// WidgetSession is one visitor's chat through a widget token. Its ID is
+// the visitor's handle on the conversation, so it is only given to them.
+type WidgetSession struct {
+	ID             uuid.UUID `json:"id"`
+	TokenID        uuid.UUID `json:"token_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+}
+
+// =============================================================================
+// Data Retention
+// =============================================================================
+
+// RetentionEntity is a kind of office data that is purged once it is older
+// than the office's tier retains data. Credit transactions and allocations
+// are billing records and are never purged.
+type RetentionEntity string
+
+const (
+	RetentionEntityMessages RetentionEntity = "messages"
+	RetentionEntityTasks    RetentionEntity = "tasks"
+	// RetentionEntityUsage covers the analytics and hourly usage rollups
+	RetentionEntityUsage RetentionEntity = "usage"
+	// RetentionEntityDeleted covers deleted agents and conversations, and
+	// those of deleted offices, counted from when they were deleted
+	RetentionEntityDeleted RetentionEntity = "deleted"
+)
+
+// RetentionEntities lists every kind of data retention applies to
+var RetentionEntities = []RetentionEntity{
+	RetentionEntityMessages,
+	RetentionEntityTasks,
+	RetentionEntityUsage,
+	RetentionEntityDeleted,
+}
+
+// RetentionCounts counts the rows a purge deleted, or would have deleted in
+// a dry run
+type RetentionCounts struct {
+	Messages    int64 `json:"messages"`
+	Attachments int64 `json:"attachments"`
+	Tasks       int64 `json:"tasks"`
+	UsageRows   int64 `json:"usage_rows"`
+	// Conversations and Agents are deleted ones purged for good
+	Conversations int64 `json:"conversations"`
+	Agents        int64 `json:"agents"`
+}
+
+// Empty reports whether nothing was counted
+func (c RetentionCounts) Empty() bool {
+	return c == RetentionCounts{}
+}
+
+// Add adds other's counts to c
+func (c *RetentionCounts) Add(other RetentionCounts) {
+	c.Messages += other.Messages
+	c.Attachments += other.Attachments
+	c.Tasks += other.Tasks
+	c.UsageRows += other.UsageRows
+	c.Conversations += other.Conversations
+	c.Agents += other.Agents
+}
+
+// RetentionRun is one pass of the retention worker over every office
+type RetentionRun struct {
+	ID     uuid.UUID `json:"id"`
+	DryRun bool      `json:"dry_run"`
+	// Exempt are the entities the run left alone
+	Exempt        []RetentionEntity `json:"exempt"`
+	OfficesPurged int               `json:"offices_purged"`
+	Deleted       RetentionCounts   `json:"deleted"`
+	StartedAt     time.Time         `json:"started_at"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+	// Purges break the run down by office
+	Purges []*RetentionPurge `json:"purges,omitempty"`
+}
+
+// RetentionPurge is what a retention run purged from one office
+type RetentionPurge struct {
+	ID            uuid.UUID        `json:"id"`
+	RunID         uuid.UUID        `json:"run_id"`
+	OfficeID      uuid.UUID        `json:"office_id"`
+	Tier          SubscriptionTier `json:"tier"`
+	RetentionDays int              `json:"retention_days"`
+	// Cutoff is the time data created before was purged
+	Cutoff    time.Time       `json:"cutoff"`
+	Deleted   RetentionCounts `json:"deleted"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// =============================================================================
+// Content Moderation
+// =============================================================================
+
+// ModerationContentType is a kind of user-written content that is screened
+// by content moderation
+type ModerationContentType string
+
+const (
+	// ModerationContentMessage is a message users and widget visitors send
+	// to agents
+	ModerationContentMessage ModerationContentType = "message"
+	// ModerationContentSystemPrompt is an agent's custom system prompt
+	ModerationContentSystemPrompt ModerationContentType = "system_prompt"
+	// ModerationContentTemplate is a marketplace template submission
+	ModerationContentTemplate ModerationContentType = "template"
+)
+
+// ModerationAction is what happens to content a moderator flags
+type ModerationAction string
+
+const (
+	// ModerationActionOff does not screen the content
+	ModerationActionOff ModerationAction = "off"
+	// ModerationActionFlag accepts the content and queues it for admin
+	// review
+	ModerationActionFlag ModerationAction = "flag"
+	// ModerationActionBlock refuses the content; it is queued too, so
+	// admins can see what was blocked
+	ModerationActionBlock ModerationAction = "block"
+)
+
+// ModerationResult is a moderator's verdict on a piece of content
+type ModerationResult struct {
+	Flagged bool
+	// Categories name the rules the content broke, such as "blocklist" or
+	// an external provider's "harassment"
+	Categories []string
+}
+
+// ModerationFlagStatus is where a moderation flag stands in admin review
+type ModerationFlagStatus string
+
+const (
+	ModerationFlagPending ModerationFlagStatus = "pending"
+	// ModerationFlagDismissed is a flag an admin found harmless
+	ModerationFlagDismissed ModerationFlagStatus = "dismissed"
+	// ModerationFlagUpheld is a flag an admin agreed with
+	ModerationFlagUpheld ModerationFlagStatus = "upheld"
+)
+
+// ModerationFlag is content a moderator flagged, queued for admin review
+type ModerationFlag struct {
+	ID          uuid.UUID             `json:"id"`
+	ContentType ModerationContentType `json:"content_type"`
+	// EntityID is the message, agent or template the content was saved
+	// as; it is nil for blocked content, which was never saved
+	EntityID *uuid.UUID `json:"entity_id,omitempty"`
+	// OfficeID and UserID are where the content came from; templates
+	// have no office and widget visitors no user
+	OfficeID   *uuid.UUID           `json:"office_id,omitempty"`
+	UserID     *uuid.UUID           `json:"user_id,omitempty"`
+	Content    string               `json:"content"`
+	Categories []string             `json:"categories"`
+	Action     ModerationAction     `json:"action"`
+	Status     ModerationFlagStatus `json:"status"`
+	ReviewedBy *uuid.UUID           `json:"reviewed_by,omitempty"`
+	ReviewNote string               `json:"review_note,omitempty"`
+	ReviewedAt *time.Time           `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+// ModerationFlagFilter narrows the moderation queue; zero fields match
+// everything
+type ModerationFlagFilter struct {
+	Status      ModerationFlagStatus
+	ContentType ModerationContentType
+}
+
+// =============================================================================
+// Secrets Vault
+// =============================================================================
+
+// OfficeSecret is a credential an office keeps for its agents' tools, such
+// as a GitHub token. The value is only stored encrypted and is never
+// returned by the API; the orchestrator fetches it for the tasks that use
+// it.
+type OfficeSecret struct {
+	ID       uuid.UUID `json:"id"`
+	OfficeID uuid.UUID `json:"office_id"`
+	// Name is how tools refer to the secret, e.g. GITHUB_TOKEN; it is
+	// unique within the office
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// AgentIDs limits the secret to these agents; empty makes it available
+	// to all of the office's agents
+	AgentIDs []uuid.UUID `json:"agent_ids"`
+	// Ciphertext is the value sealed by the SecretCipher
+	Ciphertext []byte `json:"-"`
+	// Hint is the end of the value, so owners can tell secrets apart
+	Hint           string     `json:"hint,omitempty"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// AvailableTo reports whether an agent may use the secret
+func (s *OfficeSecret) AvailableTo(agentID uuid.UUID) bool {
+	return len(s.AgentIDs) == 0 || slices.Contains(s.AgentIDs, agentID)
+}
+
+// =============================================================================
+// Web Research
+// =============================================================================
+
+// WebResearchSettings is how an office's agents may search the web
+type WebResearchSettings struct {
+	OfficeID uuid.UUID `json:"office_id"`
+	// AllowedDomains limits search results to these domains and their
+	// subdomains; empty allows every domain
+	AllowedDomains     []string  `json:"allowed_domains"`
+	MaxSearchesPerTask int       `json:"max_searches_per_task"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// WebSearchResult is one result of a web search
+type WebSearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// WebSearch is a web search an agent ran for a task. Searches answered
+// from the shared result cache are free and do not count towards the
+// task's limit.
+type WebSearch struct {
+	ID          uuid.UUID `json:"id"`
+	OfficeID    uuid.UUID `json:"office_id"`
+	TaskID      uuid.UUID `json:"task_id"`
+	AgentID     uuid.UUID `json:"agent_id"`
+	Query       string    `json:"query"`
+	Provider    string    `json:"provider,omitempty"`
+	Cached      bool      `json:"cached"`
+	ResultCount int       `json:"result_count"`
+	Credits     int64     `json:"credits"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// =============================================================================
+// Knowledge Base
+// =============================================================================
+
+// Knowledge sources
+const (
+	KnowledgeSourceNote = "note"
+	KnowledgeSourceFile = "file"
+)
+
+// KnowledgeDocument is a note or file in an office's knowledge base, such as
+// company information or a style guide, shared by all of its agents. It is
+// stored in chunks so that tasks are given only the relevant parts.
+type KnowledgeDocument struct {
+	ID       uuid.UUID `json:"id"`
+	OfficeID uuid.UUID `json:"office_id"`
+	Title    string    `json:"title"`
+	Source   string    `json:"source"`
+	Filename string    `json:"filename,omitempty"`
+	// Content is left out of listings
+	Content    string     `json:"content,omitempty"`
+	SizeBytes  int        `json:"size_bytes"`
+	ChunkCount int        `json:"chunk_count"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// KnowledgeChunk is a consecutive part of a knowledge document's content
+type KnowledgeChunk struct {
+	ID         uuid.UUID `json:"id"`
+	DocumentID uuid.UUID `json:"document_id"`
+	OfficeID   uuid.UUID `json:"office_id"`
+	Position   int       `json:"position"`
+	Content    string    `json:"content"`
+}
+
+// KnowledgeMatch is a knowledge chunk found by a search, with its
+// document's title. Similarity is the cosine similarity of its embedding to
+// the query's, and zero for matches by text.
+type KnowledgeMatch struct {
+	*KnowledgeChunk
+	DocumentTitle string  `json:"document_title"`
+	Similarity    float64 `json:"similarity,omitempty"`
+}
+
+// =============================================================================
+// Agent Skills
+// =============================================================================
+
+// Skills agents can be given
+const (
+	SkillWebSearch  = "web_search"
+	SkillCodeExec   = "code_exec"
+	SkillEmailDraft = "email_draft"
+	SkillCalendar   = "calendar"
+)
+
+// Skill is a tool capability in the skills catalog. The orchestrator only
+// permits an agent the tools of its skills.
+type Skill struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// DefaultEnabled is whether agents have the skill before it is
+	// configured for them
+	DefaultEnabled bool `json:"default_enabled"`
+}
+
+// AgentSkill is a skill as configured for an agent. The agent uses it when
+// it is both enabled and available on the office's tier; a skill enabled on
+// a higher tier stays enabled through a downgrade, unused.
+type AgentSkill struct {
+	Skill
+	Enabled      bool             `json:"enabled"`
+	Available    bool             `json:"available"`
+	RequiredTier SubscriptionTier `json:"required_tier,omitempty"`
+}
+
+// =============================================================================
+// Onboarding
+// =============================================================================
+
+// OnboardingPreset is a curated starter team an office can hire at once,
+// defined in the onboarding presets file
+type OnboardingPreset struct {
+	Key              string             `json:"key" yaml:"key"`
+	Name             string             `json:"name" yaml:"name"`
+	Description      string             `json:"description" yaml:"description"`
+	ConversationName string             `json:"conversation_name" yaml:"conversation_name"`
+	Members          []OnboardingMember `json:"members" yaml:"members"`
+	// Welcome is posted to the team's conversation by its first member
+	Welcome string `json:"welcome" yaml:"welcome"`
+}
+
+// OnboardingMember is an agent of a preset: the role of the built-in
+// template it is hired from and an optional custom name
+type OnboardingMember struct {
+	Role string `json:"role" yaml:"role"`
+	Name string `json:"name,omitempty" yaml:"name"`
+}
+
+// =============================================================================
+// Departments
+// =============================================================================
+
+// Department is a group of an office's agents led by one of its members.
+// Messages to the department's conversation that mention no one go to the
+// lead, who delegates to the other members by @mentioning them.
+type Department struct {
+	ID          uuid.UUID `json:"id"`
+	OfficeID    uuid.UUID `json:"office_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	// LeadAgentID is nil once the lead is removed from the office
+	LeadAgentID    *uuid.UUID  `json:"lead_agent_id,omitempty"`
+	ConversationID *uuid.UUID  `json:"conversation_id,omitempty"`
+	MemberIDs      []uuid.UUID `json:"member_ids"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// ReportingLine is an agent reporting to a manager agent of its office
+type ReportingLine struct {
+	AgentID   uuid.UUID `json:"agent_id"`
+	ManagerID uuid.UUID `json:"manager_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrgChart is how an office's agents are organized
+type OrgChart struct {
+	Departments    []*Department    `json:"departments"`
+	ReportingLines []*ReportingLine `json:"reporting_lines"`
+}
+
+// =============================================================================
+// Office Archives
+// =============================================================================
+
+// OfficeArchiveVersion is the format version of the office archives written
+const OfficeArchiveVersion = 1
+
+// OfficeArchive is an office exported as portable JSON: its agents with
+// their skills and memories, its conversations with their messages, and its
+// settings. IDs are those of the exporting office; importing the archive
+// gives everything new IDs.
+type OfficeArchive struct {
+	Version       int                     `json:"version"`
+	ExportedAt    time.Time               `json:"exported_at"`
+	Office        ArchivedOffice          `json:"office"`
+	Agents        []*ArchivedAgent        `json:"agents"`
+	Conversations []*ArchivedConversation `json:"conversations"`
+	Settings      ArchivedSettings        `json:"settings"`
+}
+
+// ArchivedOffice is the office of an archive
+type ArchivedOffice struct {
+	Name     string `json:"name"`
+	Timezone string `json:"timezone"`
+}
+
+// ArchivedAgent is an agent of an archive. TemplateRole lets an instance
+// without the template stand in its built-in template for the role.
+type ArchivedAgent struct {
+	ID                 uuid.UUID       `json:"id"`
+	TemplateID         uuid.UUID       `json:"template_id"`
+	TemplateRole       string          `json:"template_role"`
+	CustomName         string          `json:"custom_name,omitempty"`
+	CustomSystemPrompt string          `json:"custom_system_prompt,omitempty"`
+	CustomAvatarURL    string          `json:"custom_avatar_url,omitempty"`
+	Skills             map[string]bool `json:"skills,omitempty"`
+	Memories           []*AgentMemory  `json:"memories"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+// ArchivedConversation is a conversation of an archive with its
+// participants and its messages, oldest first. Deleted messages are left
+// out.
+type ArchivedConversation struct {
+	ID                uuid.UUID         `json:"id"`
+	Type              ConversationType  `json:"type"`
+	Name              string            `json:"name,omitempty"`
+	AgentIDs          []uuid.UUID       `json:"agent_ids"`
+	OrchestrationMode OrchestrationMode `json:"orchestration_mode"`
+	ModeratorID       *uuid.UUID        `json:"moderator_id,omitempty"`
+	DebateRounds      int               `json:"debate_rounds"`
+	ArchivedAt        *time.Time        `json:"archived_at,omitempty"`
+	Messages          []*Message        `json:"messages"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// ArchivedSettings are the office settings of an archive: its model
+// policies, the office's first, and its web research settings, if any
+type ArchivedSettings struct {
+	ModelPolicies []*ModelPolicy       `json:"model_policies"`
+	WebResearch   *WebResearchSettings `json:"web_research,omitempty"`
+}
+
+// =============================================================================
+// Admin Back Office
+// =============================================================================
+
+// AdminOffice is an office as the back office lists it, with its owner,
+// subscription and credit balance
+type AdminOffice struct {
+	Office
+	OwnerEmail string `json:"owner_email"`
+	// Tier and SubscriptionStatus are empty for offices without a
+	// subscription
+	Tier               SubscriptionTier   `json:"tier,omitempty"`
+	SubscriptionStatus SubscriptionStatus `json:"subscription_status,omitempty"`
+	Balance            int64              `json:"balance"`
+}
+
+// =============================================================================
+// Audit Log
+// =============================================================================
+
+// AuditAction names a sensitive operation recorded in the audit log.
+// Actions only admins take are prefixed with "admin.".
+type AuditAction string
+
+const (
+	AuditActionLogin       AuditAction = "auth.login"
+	AuditActionLoginFailed AuditAction = "auth.login_failed"
+	// AuditActionTierChange is an upgrade, or a downgrade applied now or
+	// scheduled for the end of the period
+	AuditActionTierChange    AuditAction = "subscription.change_tier"
+	AuditActionAgentDelete   AuditAction = "agent.delete"
+	AuditActionAgentRestore  AuditAction = "agent.restore"
+	AuditActionPayoutRequest AuditAction = "payout.request"
+	AuditActionRefundRequest AuditAction = "purchase.refund_request"
+	AuditActionPromoRedeem   AuditAction = "promo.redeem"
+	// AuditActionLowBalanceSettings changes a wallet's low balance warning
+	// or auto top-up
+	AuditActionLowBalanceSettings AuditAction = "credits.low_balance_settings"
+	AuditActionAutoTopUp          AuditAction = "credits.auto_top_up"
+	AuditActionAutoTopUpFailed    AuditAction = "credits.auto_top_up_failed"
+
+	AuditActionConversationDelete  AuditAction = "conversation.delete"
+	AuditActionConversationRestore AuditAction = "conversation.restore"
+	AuditActionOfficeDelete        AuditAction = "office.delete"
+	AuditActionOfficeRestore       AuditAction = "office.restore"
+	AuditActionOfficeExport        AuditAction = "office.export"
+	AuditActionOfficeImport        AuditAction = "office.import"
+
+	AuditActionDataExport   AuditAction = "account.data_export"
+	AuditActionAccountErase AuditAction = "account.erase"
+
+	AuditActionAdminAdjustCredits   AuditAction = "admin.credits.adjust"
+	AuditActionAdminChangeTier      AuditAction = "admin.subscription.change_tier"
+	AuditActionAdminCompletePayout  AuditAction = "admin.payout.complete"
+	AuditActionAdminRejectPayout    AuditAction = "admin.payout.reject"
+	AuditActionAdminApproveRefund   AuditAction = "admin.refund.approve"
+	AuditActionAdminRejectRefund    AuditAction = "admin.refund.reject"
+	AuditActionAdminApproveTemplate AuditAction = "admin.template.approve"
+	AuditActionAdminRejectTemplate  AuditAction = "admin.template.reject"
+	AuditActionAdminRunRetention    AuditAction = "admin.retention.run"
+	AuditActionAdminReloadTiers     AuditAction = "admin.tiers.reload"
+	AuditActionAdminCreatePromo     AuditAction = "admin.promo.create"
+	AuditActionAdminDeactivatePromo AuditAction = "admin.promo.deactivate"
+
+	AuditActionAdminResolveFlag AuditAction = "admin.moderation.resolve"
+
+	AuditActionAdminVerifyAuthor       AuditAction = "admin.author.verify"
+	AuditActionAdminRejectVerification AuditAction = "admin.author.reject_verification"
+	AuditActionAdminRevokeVerification AuditAction = "admin.author.revoke_verification"
+
+	AuditActionSecretCreate AuditAction = "secret.create"
+	AuditActionSecretUpdate AuditAction = "secret.update"
+	AuditActionSecretDelete AuditAction = "secret.delete"
+	// AuditActionSecretAccess is the orchestrator decrypting a secret for a
+	// task
+	AuditActionSecretAccess AuditAction = "secret.access"
+)
+
+// Kinds of entities audited actions are taken on
+const (
+	AuditEntityUser         = "user"
+	AuditEntityOffice       = "office"
+	AuditEntityAgent        = "agent"
+	AuditEntityConversation = "conversation"
+	AuditEntityPayout       = "payout"
+	AuditEntityRefund       = "refund_request"
+	AuditEntityPromoCode    = "promo_code"
+	AuditEntityWallet       = "wallet"
+	AuditEntityAutoTopUp    = "auto_top_up"
+	AuditEntityTemplate     = "template"
+	AuditEntityRetentionRun = "retention_run"
+	AuditEntityDataExport   = "data_export"
+	AuditEntityModeration   = "moderation_flag"
+	AuditEntitySecret       = "secret"
+	// AuditEntityTierConfig is the subscription tiers file; its entries have
+	// no entity ID
+	AuditEntityTierConfig = "tier_config"
+)
+
+// AuditEntry records one sensitive operation. Entries are never changed or
+// deleted once written.
+type AuditEntry struct {
+	ID uuid.UUID `json:"id"`
+	// ActorID is the user who acted; it is nil for actions the platform
+	// took on its own
+	ActorID *uuid.UUID `json:"actor_id,omitempty"`
+	// OfficeID is the office the action affected, if any
+	OfficeID   *uuid.UUID  `json:"office_id,omitempty"`
+	Action     AuditAction `json:"action"`
+	EntityType string      `json:"entity_type"`
+	EntityID   uuid.UUID   `json:"entity_id"`
+	// Before and After are the parts of the entity the action changed
+	Before map[string]any `json:"before,omitempty"`
+	After  map[string]any `json:"after,omitempty"`
+	// Details is anything else worth keeping, such as the reason given
+	Details   map[string]any `json:"details,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter narrows the audit log; zero fields match everything
+type AuditFilter struct {
+	ActorID  uuid.UUID
+	OfficeID uuid.UUID
+	Action   AuditAction
+	// From and To bound when the action was taken, From inclusive and To
+	// exclusive
+	From *time.Time
+	To   *time.Time
+}
+
+// =============================================================================
+// Rate Limiting
+// =============================================================================
+
+// RateLimit is the state of a rate limit bucket after a request was counted
+// against it
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Allowed   bool
+	// RetryAfter is how long until a rejected request would be allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// =============================================================================
+// Pagination
+// =============================================================================
+
+// PageCursor marks a position in a list ordered by (created_at, id)
+type PageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// PageRequest selects a page of results. When Cursor is set the page starts
+// right after it (keyset pagination) and Offset is ignored.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor *PageCursor
+}
+
+// =============================================================================
+// Realtime Events
+// =============================================================================
+
+// EventType identifies a realtime event pushed to office clients
+type EventType string
+
+const (
+	EventNewMessage      EventType = "new_message"
+	EventMessageDelta    EventType = "message_delta"
+	EventMessageComplete EventType = "message_complete"
+	EventAgentTyping     EventType = "agent_typing"
+	EventAgentWorking    EventType = "agent_working"
+	EventAgentIdle       EventType = "agent_idle"
+	EventTaskCancelled   EventType = "task_cancelled"
+	EventMessageEdited   EventType = "message_edited"
+	EventMessageDeleted  EventType = "message_deleted"
+	EventReactionAdded   EventType = "reaction_added"
+	EventReactionRemoved EventType = "reaction_removed"
+	EventDocumentSaved   EventType = "document_saved"
+	// EventNotificationsRead tells an office's other clients which
+	// notifications were read
+	EventNotificationsRead EventType = "notifications_read"
+	// EventCostWarning tells an office that a task's estimated cost exceeds
+	// its remaining budget, and whether the task was blocked
+	EventCostWarning EventType = "cost_warning"
+	// EventBoardChanged tells an office's clients that a project board
+	// changed, and how
+	EventBoardChanged EventType = "board_changed"
+	// EventConversationSummarized tells an office's clients that a
+	// conversation has a new summary
+	EventConversationSummarized EventType = "conversation_summarized"
+)
+
+// Event is an office-scoped realtime event. It is delivered to every client
+// connected to the office, whichever backend replica holds the connection.
+// Its JSON form is the WebSocket message sent to clients.
+type Event struct {
+	ID       string         `json:"event_id"`
+	Type     EventType      `json:"event_type"`
+	OfficeID uuid.UUID      `json:"-"`
+	Payload  map[string]any `json:"payload"`
+}
+
+// NewEvent creates an event with a fresh ID
+func NewEvent(officeID uuid.UUID, eventType EventType, payload map[string]any) Event {
+	return Event{
+		ID:       uuid.New().String(),
+		Type:     eventType,
+		OfficeID: officeID,
+		Payload:  payload,
+	}
+}
+
+// Event converts a stored notification into the realtime event announcing it
+func (n *Notification) Event() Event {
+	return Event{
+		ID:       n.ID.String(),
+		Type:     EventType(n.Type),
+		OfficeID: n.OfficeID,
+		Payload: map[string]any{
+			"notification_id": n.ID.String(),
+			"title":           n.Title,
+			"message":         n.Message,
+			"data":            n.Payload,
+			"created_at":      n.CreatedAt,
+		},
+	}
+}
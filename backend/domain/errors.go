@@ -2,12 +2,103 @@ package domain
 
 import "errors"
 
-// Common domain errors
+// Error is a domain error with a stable, machine-readable code. API error
+// responses report the code next to the message, so clients can branch on it;
+// codes must not change once released.
+type Error struct {
+	Code    string
+	Message string
+}
+
+// NewError creates a domain error
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Error catalogue. Services return these directly or wrapped with
+// fmt.Errorf("%w: ...") to add a client-facing explanation; callers match
+// them with errors.Is.
 var (
-	ErrNotFound           = errors.New("resource not found")
-	ErrAlreadyExists      = errors.New("resource already exists")
-	ErrInvalidInput       = errors.New("invalid input")
-	ErrUnauthorized       = errors.New("unauthorized")
-	ErrForbidden          = errors.New("forbidden")
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrNotFound           = NewError("not_found", "resource not found")
+	ErrAlreadyExists      = NewError("already_exists", "resource already exists")
+	ErrInvalidInput       = NewError("invalid_input", "invalid input")
+	ErrUnauthorized       = NewError("unauthorized", "unauthorized")
+	ErrForbidden          = NewError("forbidden", "forbidden")
+	ErrInvalidCredentials = NewError("invalid_credentials", "invalid credentials")
+	ErrPurchaseRequired   = NewError("purchase_required", "template purchase required")
+
+	// ErrTemplateNotOwned is returned when a user manages a marketplace
+	// template they are not the author of
+	ErrTemplateNotOwned = NewError("template_not_owned", "template is owned by another author")
+
+	// ErrInsufficientCredits is returned when a debit would take a wallet's
+	// balance below zero
+	ErrInsufficientCredits = NewError("insufficient_credits", "insufficient credits")
+	// ErrTierLimitExceeded is returned when an office has used up a limit of
+	// its subscription tier
+	ErrTierLimitExceeded = NewError("tier_limit_exceeded", "subscription tier limit exceeded")
+	// ErrFeatureNotAvailable is returned when an office uses a feature its
+	// subscription tier does not include
+	ErrFeatureNotAvailable = NewError("feature_not_available", "feature not included in subscription tier")
+	// ErrUpgradeRequired is returned when an office calls an endpoint gated
+	// by a feature its tier does not include; the details name the feature
+	// and the tier that does
+	ErrUpgradeRequired = NewError("upgrade_required", "subscription upgrade required")
+	// ErrSubscriptionInactive is returned when an office whose subscription
+	// is cancelled, paused or unpaid tries to run agent tasks
+	ErrSubscriptionInactive = NewError("subscription_inactive", "subscription is not active")
+	// ErrPaymentDeclined is returned when a saved payment method is refused
+	// by the payment provider
+	ErrPaymentDeclined = NewError("payment_declined", "payment declined")
+
+	// ErrLicenseRequired is returned when a template's license does not
+	// allow what is done with it, such as using a personal license in an
+	// office with many seats or republishing a resale-prohibited template
+	ErrLicenseRequired = NewError("license_required", "template license does not allow this")
+
+	// ErrContentBlocked is returned when content moderation refuses a
+	// message, system prompt or template
+	ErrContentBlocked = NewError("content_blocked", "content blocked by moderation")
+
+	// ErrSecretsUnavailable is returned when office secrets are used while
+	// no master key is configured to encrypt them
+	ErrSecretsUnavailable = NewError("secrets_unavailable", "secrets vault is not configured")
+
+	// ErrRateLimited is returned when a client has used up its request rate
+	ErrRateLimited = NewError("rate_limited", "too many requests")
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed
+	// with a different request than the one it was first used for
+	ErrIdempotencyKeyReused = NewError("idempotency_key_reused", "idempotency key reused for a different request")
+	// ErrRequestInProgress is returned when the first request with an
+	// idempotency key has not finished yet
+	ErrRequestInProgress = NewError("request_in_progress", "request with this idempotency key is still in progress")
 )
+
+// detailedError attaches structured details to an error
+type detailedError struct {
+	err     error
+	details map[string]any
+}
+
+func (e *detailedError) Error() string { return e.err.Error() }
+func (e *detailedError) Unwrap() error { return e.err }
+
+// WithDetails attaches details to err, such as the limit that was exceeded.
+// They are reported in the details field of API error responses.
+func WithDetails(err error, details map[string]any) error {
+	return &detailedError{err: err, details: details}
+}
+
+// ErrorDetails returns the details attached to err with WithDetails, or nil
+func ErrorDetails(err error) map[string]any {
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		return detailed.details
+	}
+	return nil
+}
@@ -1,63 +1,238 @@
 package domain
 
+//go:generate go run go.uber.org/mock/mockgen -source=interfaces.go -destination=mocks/mocks.go -package=mocks
+
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// TxManager runs a unit of work in one database transaction. The
+// repositories called with the context fn is given take part in it, so
+// their writes commit together or not at all.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 // UserRepository defines database operations for users
 type UserRepository interface {
 	Create(ctx context.Context, user *User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	Update(ctx context.Context, user *User) error
+	// UpdatePassword replaces the password hash and moves the token version
+	// on, ending the user's sessions
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	// ConfirmPendingEmail replaces the user's email with their verified
+	// pending email. It returns ErrNotFound if there is none, and
+	// ErrAlreadyExists if another account took the address meanwhile.
+	ConfirmPendingEmail(ctx context.Context, id uuid.UUID) error
+	// SoftDelete closes an account, erasing its personal details
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	// Erase closes an account like SoftDelete and erases everything else
+	// the user stored, anonymizing what is kept for accounting. It returns
+	// the storage keys of the files to remove.
+	Erase(ctx context.Context, id uuid.UUID) ([]string, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 // OfficeRepository defines database operations for offices
 type OfficeRepository interface {
 	Create(ctx context.Context, office *Office) error
+	// GetByID returns deleted offices too, with DeletedAt set
 	GetByID(ctx context.Context, id uuid.UUID) (*Office, error)
+	// GetByUserID returns the user's offices that are not deleted
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Office, error)
 	Update(ctx context.Context, office *Office) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	// SoftDelete deletes an office, pausing its schedules, revoking its API
+	// keys and not renewing its subscription, or returns ErrNotFound if it
+	// is already deleted
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	// Restore undeletes an office deleted after deletedAfter, or returns
+	// ErrNotFound
+	Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error
 }
 
 // AgentTemplateRepository defines database operations for agent templates
 type AgentTemplateRepository interface {
+	// GetAll returns the approved templates
 	GetAll(ctx context.Context) ([]*AgentTemplate, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*AgentTemplate, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*AgentTemplate, error)
+	// GetByRole returns the built-in template with the role, leaving out
+	// marketplace templates by authors
 	GetByRole(ctx context.Context, role string) (*AgentTemplate, error)
 }
 
+// TemplateVersionRepository defines database operations for template version history
+type TemplateVersionRepository interface {
+	// Create stores a version, replacing a rejected one of the same number
+	Create(ctx context.Context, version *TemplateVersion) error
+	// GetByTemplateID returns the approved versions of a template
+	GetByTemplateID(ctx context.Context, templateID uuid.UUID) ([]*TemplateVersion, error)
+	// GetByVersion returns an approved version of a template
+	GetByVersion(ctx context.Context, templateID uuid.UUID, version string) (*TemplateVersion, error)
+	// GetPending returns the version of a template awaiting moderation, or
+	// ErrNotFound
+	GetPending(ctx context.Context, templateID uuid.UUID) (*TemplateVersion, error)
+	// SetStatus records a moderation decision on a pending version, or
+	// returns ErrNotFound if it is no longer pending
+	SetStatus(ctx context.Context, id uuid.UUID, status, rejectionReason string, reviewerID uuid.UUID) error
+}
+
+// MarketplaceRepository defines database operations for marketplace
+// templates, categories and reviews
+type MarketplaceRepository interface {
+	// Template operations
+	ListTemplates(ctx context.Context, filter MarketplaceFilter) ([]AgentTemplate, int, error)
+	GetTemplateByID(ctx context.Context, id uuid.UUID) (*AgentTemplate, error)
+	CreateTemplate(ctx context.Context, template *AgentTemplate) error
+	UpdateTemplate(ctx context.Context, template *AgentTemplate) error
+	GetTemplatesByAuthor(ctx context.Context, authorID uuid.UUID) ([]AgentTemplate, error)
+	GetPendingTemplates(ctx context.Context, limit, offset int) ([]AgentTemplate, int, error)
+	// SetTemplateStatus returns ErrNotFound unless the template is pending
+	SetTemplateStatus(ctx context.Context, id uuid.UUID, status string, rejectionReason string, reviewerID uuid.UUID) error
+	IncrementDownload(ctx context.Context, templateID uuid.UUID) error
+	// IsResaleProhibitedCopy reports whether another author's template with
+	// the system prompt, ignoring case and whitespace, prohibits resale
+	IsResaleProhibitedCopy(ctx context.Context, systemPrompt string, authorID uuid.UUID) (bool, error)
+
+	// Category operations
+	CategoryExists(ctx context.Context, slug string) (bool, error)
+	GetCategories(ctx context.Context) ([]AgentCategory, error)
+
+	// Review operations
+	CreateReview(ctx context.Context, review *AgentReview) error
+	UpdateReview(ctx context.Context, review *AgentReview) error
+	DeleteReview(ctx context.Context, id uuid.UUID) error
+	GetReviews(ctx context.Context, templateID uuid.UUID, limit, offset int) ([]AgentReview, error)
+	CountReviews(ctx context.Context, templateID uuid.UUID) (int, error)
+	GetReviewByID(ctx context.Context, id uuid.UUID) (*AgentReview, error)
+	VoteReview(ctx context.Context, reviewID, userID uuid.UUID, helpful bool) error
+	DeleteReviewVote(ctx context.Context, reviewID, userID uuid.UUID) error
+	CreateReviewReply(ctx context.Context, reply *ReviewReply) error
+	UpdateReviewReply(ctx context.Context, reply *ReviewReply) error
+}
+
 // AgentRepository defines database operations for agents
 type AgentRepository interface {
 	Create(ctx context.Context, agent *Agent) error
+	// CreateMany creates all of the agents or, on error, none
+	CreateMany(ctx context.Context, agents []*Agent) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Agent, error)
 	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*Agent, error)
 	Update(ctx context.Context, agent *Agent) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	// SoftDelete deactivates and hides an agent and pauses its schedules, or
+	// returns ErrNotFound if it is already deleted
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	// Restore reactivates an agent of the office deleted after deletedAfter,
+	// or returns ErrNotFound
+	Restore(ctx context.Context, officeID, id uuid.UUID, deletedAfter time.Time) error
+	// LockOffice holds the office's lock on adding agents until the
+	// transaction ends, or returns ErrNotFound if there is no such office
+	LockOffice(ctx context.Context, officeID uuid.UUID) error
+}
+
+// AgentChangeRepository defines database operations for the agent
+// customization history
+type AgentChangeRepository interface {
+	Create(ctx context.Context, changes []*AgentChange) error
+	// GetByAgentID returns an agent's changes, newest first
+	GetByAgentID(ctx context.Context, agentID uuid.UUID, limit int) ([]*AgentChange, error)
 }
 
 // ConversationRepository defines database operations for conversations
 type ConversationRepository interface {
 	Create(ctx context.Context, conversation *Conversation) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Conversation, error)
-	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*Conversation, error)
+	// GetByOfficeID returns the office's conversations, leaving out archived
+	// ones unless includeArchived is set
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID, includeArchived bool) ([]*Conversation, error)
+	// GetPreviewsByOfficeID returns what GetByOfficeID returns with each
+	// conversation's participants, the user's unread count and the latest
+	// message, in one round trip
+	GetPreviewsByOfficeID(ctx context.Context, officeID, userID uuid.UUID, includeArchived bool) ([]*Conversation, error)
 	AddParticipant(ctx context.Context, conversationID, agentID uuid.UUID) error
 	RemoveParticipant(ctx context.Context, conversationID, agentID uuid.UUID) error
 	GetParticipants(ctx context.Context, conversationID uuid.UUID) ([]*Agent, error)
 	Update(ctx context.Context, conversation *Conversation) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	// SoftDelete hides a conversation and pauses its schedules, or returns
+	// ErrNotFound if it is already deleted
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	// Restore undeletes a conversation of the office deleted after
+	// deletedAfter, or returns ErrNotFound
+	Restore(ctx context.Context, officeID, id uuid.UUID, deletedAfter time.Time) error
 }
 
 // MessageRepository defines database operations for messages
 type MessageRepository interface {
 	Create(ctx context.Context, message *Message) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Message, error)
-	GetByConversationID(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*Message, error)
+	GetByConversationID(ctx context.Context, conversationID uuid.UUID, page PageRequest) ([]*Message, error)
+	CountByConversationID(ctx context.Context, conversationID uuid.UUID) (int, error)
+	// GetReplies returns the replies in a message's thread, oldest first
+	GetReplies(ctx context.Context, parentID uuid.UUID) ([]*Message, error)
+	// GetRecentByConversationID returns up to limit of a conversation's
+	// newest top-level, undeleted messages before the cursor, oldest first
+	GetRecentByConversationID(ctx context.Context, conversationID uuid.UUID, before *PageCursor, limit int) ([]*Message, error)
+	// GetNextByConversationID returns up to limit of a conversation's
+	// oldest top-level, undeleted messages after the cursor, oldest first
+	GetNextByConversationID(ctx context.Context, conversationID uuid.UUID, after *PageCursor, limit int) ([]*Message, error)
+	// GetLatestByConversationID returns a conversation's newest message
+	GetLatestByConversationID(ctx context.Context, conversationID uuid.UUID) (*Message, error)
+	// Update saves a message's content, metadata and edited_at
+	Update(ctx context.Context, message *Message) error
+	// SoftDelete marks a message deleted and erases its content
+	SoftDelete(ctx context.Context, id uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	AddReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) error
+	RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) error
+	// GetReactions returns the reactions of each message, in the order they
+	// were first used
+	GetReactions(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]*MessageReaction, error)
+}
+
+// ConversationReadRepository defines database operations for users' read
+// positions in conversations
+type ConversationReadRepository interface {
+	// MarkRead moves the user's read position forward to the given message;
+	// it never moves it back. It returns the resulting position.
+	MarkRead(ctx context.Context, read *ConversationRead) (*ConversationRead, error)
+	// GetActivity returns the user's unread count and the latest message of
+	// each conversation
+	GetActivity(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]*ConversationActivity, error)
+}
+
+// AttachmentRepository defines database operations for message attachments
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *Attachment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Attachment, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Attachment, error)
+	// GetByMessageIDs returns the attachments of each message, oldest first
+	GetByMessageIDs(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]*Attachment, error)
+	// AttachToMessage links unlinked attachments to a message
+	AttachToMessage(ctx context.Context, ids []uuid.UUID, messageID uuid.UUID) error
+	DeleteByMessageID(ctx context.Context, messageID uuid.UUID) ([]*Attachment, error)
+}
+
+// DocumentRepository defines database operations for agent documents and
+// their versions
+type DocumentRepository interface {
+	// Create stores a document and its first version
+	Create(ctx context.Context, document *Document, version *DocumentVersion) error
+	// AddVersion stores a new version and makes it the document's latest
+	AddVersion(ctx context.Context, document *Document, version *DocumentVersion) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Document, error)
+	// List returns documents without their content, newest first
+	List(ctx context.Context, filter DocumentFilter, page PageRequest) ([]*Document, error)
+	Count(ctx context.Context, filter DocumentFilter) (int, error)
+	// GetVersions returns a document's versions without their content, newest first
+	GetVersions(ctx context.Context, documentID uuid.UUID) ([]*DocumentVersion, error)
+	GetVersion(ctx context.Context, documentID uuid.UUID, version int) (*DocumentVersion, error)
 }
 
 // TaskRepository defines database operations for tasks
@@ -67,17 +242,104 @@ type TaskRepository interface {
 	GetByAgentID(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]*Task, error)
 	GetByOfficeID(ctx context.Context, officeID uuid.UUID, limit, offset int) ([]*Task, error)
 	GetPending(ctx context.Context, limit int) ([]*Task, error)
+	// GetByRootID returns the sub-tasks of a task tree, oldest first
+	GetByRootID(ctx context.Context, rootID uuid.UUID) ([]*Task, error)
+	// CountByRootID returns the number of sub-tasks in a task tree
+	CountByRootID(ctx context.Context, rootID uuid.UUID) (int, error)
+	List(ctx context.Context, filter TaskFilter, page PageRequest) ([]*Task, error)
+	Count(ctx context.Context, filter TaskFilter) (int, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status TaskStatus, output, errMsg string) error
+	// RecordUsage stores what running the task cost
+	RecordUsage(ctx context.Context, id uuid.UUID, usage TaskUsage) error
+	Cancel(ctx context.Context, id uuid.UUID) (bool, error)
+	ClaimForDispatch(ctx context.Context, task *Task) (bool, error)
+	ScheduleRetry(ctx context.Context, id uuid.UUID, errMsg string, nextAttemptAt time.Time) error
+	RequeueInProgress(ctx context.Context, ids []uuid.UUID, reason string) (int, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ScheduledTaskRepository defines database operations for scheduled tasks
+// and their run history
+type ScheduledTaskRepository interface {
+	Create(ctx context.Context, schedule *ScheduledTask) error
+	GetByID(ctx context.Context, id uuid.UUID) (*ScheduledTask, error)
+	GetByAgentID(ctx context.Context, agentID uuid.UUID) ([]*ScheduledTask, error)
+	GetDue(ctx context.Context, now time.Time, limit int) ([]*ScheduledTask, error)
+	Update(ctx context.Context, schedule *ScheduledTask) error
+	ClaimRun(ctx context.Context, schedule *ScheduledTask, nextRunAt *time.Time) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	CreateRun(ctx context.Context, run *ScheduledTaskRun) error
+	GetRuns(ctx context.Context, scheduleID uuid.UUID, limit, offset int) ([]*ScheduledTaskRun, error)
+	CountRuns(ctx context.Context, scheduleID uuid.UUID) (int, error)
+	// GetByTaskTemplateID returns the schedules filled in from a task
+	// template, newest first
+	GetByTaskTemplateID(ctx context.Context, templateID uuid.UUID) ([]*ScheduledTask, error)
+}
+
+// TaskTemplateRepository defines database operations for task templates
+type TaskTemplateRepository interface {
+	// Create returns ErrAlreadyExists if the office has a template with the
+	// name
+	Create(ctx context.Context, template *TaskTemplate) error
+	GetByID(ctx context.Context, id uuid.UUID) (*TaskTemplate, error)
+	// GetByOfficeID returns the office's templates by name
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*TaskTemplate, error)
+	// Update saves a template's name, description, body and defaults,
+	// returning ErrAlreadyExists if the office has another template with the
+	// name
+	Update(ctx context.Context, template *TaskTemplate) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 // AgentMemoryRepository defines database operations for agent memories
 type AgentMemoryRepository interface {
 	Create(ctx context.Context, memory *AgentMemory) error
+	GetByID(ctx context.Context, id uuid.UUID) (*AgentMemory, error)
 	GetByAgentID(ctx context.Context, agentID uuid.UUID) ([]*AgentMemory, error)
 	GetByKey(ctx context.Context, agentID uuid.UUID, key string) (*AgentMemory, error)
 	Upsert(ctx context.Context, memory *AgentMemory) error
+	Update(ctx context.Context, memory *AgentMemory) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// SetEmbedding stores the embedding of a memory's key and value; it is
+	// dropped when either changes
+	SetEmbedding(ctx context.Context, id uuid.UUID, embedding []float32, vectorID string) error
+	// SearchByEmbedding returns the agent's memories most similar to the
+	// embedding, most similar first
+	SearchByEmbedding(ctx context.Context, agentID uuid.UUID, embedding []float32, limit int) ([]*MemoryMatch, error)
+	// SearchByText returns the agent's memories whose key or value contains
+	// the query, most important first
+	SearchByText(ctx context.Context, agentID uuid.UUID, query string, limit int) ([]*MemoryMatch, error)
+
+	// Consolidation. The other methods do not return archived memories.
+
+	// GetConsolidationDue returns agents with memories that were not
+	// consolidated since before
+	GetConsolidationDue(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error)
+	// DecayImportance halves memories' importance every halfLife since it
+	// last decayed, returning how many memories decayed
+	DecayImportance(ctx context.Context, halfLife time.Duration, at time.Time) (int64, error)
+	// FindDuplicates returns the agent's memories whose keys match but for
+	// case and punctuation, or whose embeddings are at least minSimilarity
+	// similar
+	FindDuplicates(ctx context.Context, agentID uuid.UUID, minSimilarity float64) ([]MemoryPair, error)
+	// Merge archives the merged memories into the survivor and sets its
+	// importance
+	Merge(ctx context.Context, survivorID uuid.UUID, importance float64, mergedIDs []uuid.UUID, at time.Time) error
+	// ArchiveOverCap archives the agent's least important memories past
+	// the first maxMemories, returning how many were archived
+	ArchiveOverCap(ctx context.Context, agentID uuid.UUID, maxMemories int, at time.Time) (int64, error)
+}
+
+// FeedbackRepository defines database operations for feedback on agent
+// messages and the learning data derived from it
+type FeedbackRepository interface {
+	CreateFeedback(ctx context.Context, feedback *AgentFeedback) error
+	GetFeedbackByAgentID(ctx context.Context, agentID uuid.UUID, limit int) ([]*AgentFeedback, error)
+	GetFeedbackSummary(ctx context.Context, agentID uuid.UUID) (positive, negative, correction int, avgRating float64, err error)
+	GetMessageByID(ctx context.Context, messageID uuid.UUID) (*Message, error)
+	GetAgentMemories(ctx context.Context, agentID uuid.UUID, memoryType string, limit int) ([]*AgentMemory, error)
+	GetAgentMemoryCount(ctx context.Context, agentID uuid.UUID) (int, error)
+	GetAgentInteractionCount(ctx context.Context, agentID uuid.UUID) (int, error)
 }
 
 // CreditRepository defines database operations for credit wallets and transactions
@@ -92,8 +354,102 @@ type CreditRepository interface {
 	// Transaction operations
 	AddCredits(ctx context.Context, walletID uuid.UUID, amount int64, txType TransactionType, description string, refType string, refID *uuid.UUID) (*CreditTransaction, error)
 	ConsumeCredits(ctx context.Context, walletID uuid.UUID, amount int64, taskID uuid.UUID, description string) (*CreditTransaction, error)
-	GetTransactions(ctx context.Context, walletID uuid.UUID, limit int, offset int) ([]*CreditTransaction, error)
+	GetTransactions(ctx context.Context, walletID uuid.UUID, page PageRequest) ([]*CreditTransaction, error)
+	CountTransactions(ctx context.Context, walletID uuid.UUID) (int, error)
 	GetTransactionsByType(ctx context.Context, walletID uuid.UUID, txType TransactionType, limit int) ([]*CreditTransaction, error)
+	GetConsumedSince(ctx context.Context, walletID uuid.UUID, since time.Time) (int64, error)
+	GetTaskNetCredits(ctx context.Context, walletID uuid.UUID, taskID uuid.UUID) (int64, error)
+	GetTransactionByID(ctx context.Context, id uuid.UUID) (*CreditTransaction, error)
+
+	// UpdateLowBalanceSettings saves the wallet's low balance threshold and
+	// auto top-up settings
+	UpdateLowBalanceSettings(ctx context.Context, wallet *CreditWallet) error
+}
+
+// AutoTopUpRepository defines database operations for automatic credit
+// top-ups
+type AutoTopUpRepository interface {
+	// GetDueWallets returns wallets with auto top-up enabled whose balance
+	// is below their threshold, that have no top-up being charged and fewer
+	// than their daily maximum since dayStart
+	GetDueWallets(ctx context.Context, dayStart time.Time, limit int) ([]*CreditWallet, error)
+	// Claim records a pending top-up if its wallet is still due one, and
+	// returns ErrNotFound otherwise
+	Claim(ctx context.Context, topUp *AutoTopUp, dayStart time.Time) error
+	// GetStalePending returns top-ups pending since before, oldest first
+	GetStalePending(ctx context.Context, before time.Time, limit int) ([]*AutoTopUp, error)
+	// Resolve records how a pending top-up ended, returning ErrNotFound if
+	// it is no longer pending
+	Resolve(ctx context.Context, topUp *AutoTopUp) error
+	// ListByOffice returns the office's most recent top-ups, newest first
+	ListByOffice(ctx context.Context, officeID uuid.UUID, limit int) ([]*AutoTopUp, error)
+}
+
+// AnalyticsRepository defines database operations for an office's usage
+// statistics over ranges of days
+type AnalyticsRepository interface {
+	GetDailyUsage(ctx context.Context, officeID uuid.UUID, dates DateRange) ([]UsageDaily, error)
+	GetUsageByModel(ctx context.Context, officeID uuid.UUID, dates DateRange) ([]UsageByModel, error)
+	GetUsageByAgent(ctx context.Context, officeID uuid.UUID, dates DateRange) ([]UsageByAgent, error)
+	GetUsageSummary(ctx context.Context, officeID uuid.UUID, dates DateRange) (*UsageSummary, error)
+	// The Aggregate methods add usage up from the office's tasks instead of
+	// the rollups, for days the rollups are missing
+	AggregateDailyUsage(ctx context.Context, officeID uuid.UUID, days []time.Time) ([]UsageDaily, error)
+	AggregateUsageByModel(ctx context.Context, officeID uuid.UUID, days []time.Time) ([]UsageByModel, error)
+	AggregateUsageByAgent(ctx context.Context, officeID uuid.UUID, days []time.Time) ([]UsageByAgent, error)
+	// RebuildUsage writes rollups from the tasks of the office, or of every
+	// office when nil, and returns how many office days it wrote. Days with
+	// rollups are only replaced when overwrite is set.
+	RebuildUsage(ctx context.Context, officeID *uuid.UUID, days []time.Time, overwrite bool) (int64, error)
+	// GetAgentStats returns what each of the office's agents did from since
+	// until before until, for agents that finished tasks or got feedback
+	GetAgentStats(ctx context.Context, officeID uuid.UUID, since, until time.Time) ([]AgentStats, error)
+	RecordTaskUsage(
+		ctx context.Context,
+		officeID, agentID uuid.UUID,
+		agentRole, modelName, provider string,
+		credits, inputTokens, outputTokens int,
+		isLocalModel bool,
+		usdCost float64,
+		success bool,
+	) error
+}
+
+// IdempotencyRepository defines database operations for idempotency keys
+type IdempotencyRepository interface {
+	// Claim records the key for a new request. If the key is already taken
+	// it returns the existing record instead and claims nothing.
+	Claim(ctx context.Context, key *IdempotencyKey) (*IdempotencyKey, error)
+	Complete(ctx context.Context, key *IdempotencyKey, resourceID uuid.UUID) error
+	Release(ctx context.Context, key *IdempotencyKey) error
+}
+
+// PromoCodeRepository defines database operations for promo codes and their
+// redemptions
+type PromoCodeRepository interface {
+	// Create returns ErrAlreadyExists if the code is taken
+	Create(ctx context.Context, code *PromoCode) error
+	GetByID(ctx context.Context, id uuid.UUID) (*PromoCode, error)
+	GetByCode(ctx context.Context, code string) (*PromoCode, error)
+	// List returns a page of the codes, newest first, and how many there are
+	List(ctx context.Context, limit, offset int) ([]*PromoCode, int, error)
+	// Deactivate ends a code's campaign, returning ErrNotFound if it is
+	// already deactivated
+	Deactivate(ctx context.Context, id uuid.UUID) error
+	// Redeem counts the redemption against the code's limit and records it.
+	// It returns ErrNotFound if the code is inactive, expired or used up, and
+	// ErrAlreadyExists if the office or the user already redeemed it.
+	Redeem(ctx context.Context, redemption *PromoRedemption) error
+	// ListRedemptions returns a page of a code's redemptions, newest first,
+	// and how many there are
+	ListRedemptions(ctx context.Context, codeID uuid.UUID, limit, offset int) ([]*PromoRedemption, int, error)
+	// GetByOfficeID returns an office's redemptions, newest first
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*PromoRedemption, error)
+	// GetActiveDiscount returns the office's redeemed discount with periods
+	// left, or ErrNotFound if there is none
+	GetActiveDiscount(ctx context.Context, officeID uuid.UUID) (*PromoRedemption, error)
+	// UseDiscountPeriod counts one invoice against a redeemed discount
+	UseDiscountPeriod(ctx context.Context, redemptionID uuid.UUID) error
 }
 
 // SubscriptionRepository defines database operations for subscriptions
@@ -107,9 +463,696 @@ type SubscriptionRepository interface {
 	UpdateStatus(ctx context.Context, id uuid.UUID, status SubscriptionStatus) error
 	UpdateTier(ctx context.Context, id uuid.UUID, tier SubscriptionTier) error
 
+	// Scheduled tier changes
+	ScheduleTierChange(ctx context.Context, id uuid.UUID, tier SubscriptionTier, at time.Time) error
+	ClearTierChange(ctx context.Context, id uuid.UUID) error
+	ApplyTierChange(ctx context.Context, id uuid.UUID, tier SubscriptionTier) error
+	// GetDueTierChanges returns subscriptions whose pending tier change is
+	// due, earliest first
+	GetDueTierChanges(ctx context.Context, now time.Time, limit int) ([]*Subscription, error)
+
+	// Cancellation
+	SetCancelAtPeriodEnd(ctx context.Context, id uuid.UUID, cancel bool) error
+	// Cancel marks the subscription cancelled as of cancelledAt, dropping
+	// any pending tier change
+	Cancel(ctx context.Context, id uuid.UUID, cancelledAt time.Time) error
+	// GetDueCancellations returns subscriptions set to cancel at the end of
+	// a period that has ended by now
+	GetDueCancellations(ctx context.Context, now time.Time, limit int) ([]*Subscription, error)
+
+	// Trials
+	StartTrial(ctx context.Context, id uuid.UUID, tier SubscriptionTier, start, end time.Time) error
+	// EndTrial moves a trialing subscription to tier and status. It returns
+	// ErrNotFound if the subscription is no longer trialing.
+	EndTrial(ctx context.Context, id uuid.UUID, tier SubscriptionTier, status SubscriptionStatus) error
+	// GetDueTrials returns trialing subscriptions whose trial has ended by
+	// now, earliest first
+	GetDueTrials(ctx context.Context, now time.Time, limit int) ([]*Subscription, error)
+
+	// Renewals
+	// GetDueRenewals returns active subscriptions whose period has ended by
+	// now and that neither cancel nor change tier at its end, earliest first
+	GetDueRenewals(ctx context.Context, now time.Time, limit int) ([]*Subscription, error)
+	// RenewPeriod starts the next period with its allocation and wallet
+	// credits, all or nothing. It returns ErrNotFound if the period was
+	// already renewed.
+	RenewPeriod(ctx context.Context, renewal *PeriodRenewal) error
+
 	// Credit allocation operations
 	CreateAllocation(ctx context.Context, allocation *CreditAllocation) error
 	GetCurrentAllocation(ctx context.Context, subscriptionID uuid.UUID) (*CreditAllocation, error)
 	GetAllocationsBySubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*CreditAllocation, error)
 	UpdateAllocationConsumed(ctx context.Context, allocationID uuid.UUID, consumed int64) error
 }
+
+// NotificationRepository defines database operations for office notifications
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *Notification) error
+	// List returns a page of an office's notifications, newest first
+	List(ctx context.Context, officeID uuid.UUID, unreadOnly bool, page PageRequest) ([]*Notification, error)
+	Count(ctx context.Context, officeID uuid.UUID, unreadOnly bool) (int, error)
+	GetUnread(ctx context.Context, officeID uuid.UUID, limit int) ([]*Notification, error)
+	// MarkRead marks an office's notification read, or returns ErrNotFound
+	MarkRead(ctx context.Context, officeID, id uuid.UUID) error
+	// MarkAllRead marks all of an office's notifications read and returns
+	// how many were unread
+	MarkAllRead(ctx context.Context, officeID uuid.UUID) (int, error)
+}
+
+// NotificationPreferenceRepository defines database operations for users'
+// notification preferences
+type NotificationPreferenceRepository interface {
+	// GetByUserID returns the preferences a user has chosen; types without
+	// one are left out
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*NotificationPreference, error)
+	Upsert(ctx context.Context, userID uuid.UUID, preferences []*NotificationPreference) error
+}
+
+// ModelPolicyRepository defines database operations for model policies.
+// A nil agent ID selects the office's own policy.
+type ModelPolicyRepository interface {
+	Get(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) (*ModelPolicy, error)
+	// GetEffective returns the policy routing the agent's tasks: its own,
+	// or else the office's
+	GetEffective(ctx context.Context, officeID, agentID uuid.UUID) (*ModelPolicy, error)
+	// ListByOffice returns the office's policy, if any, followed by its
+	// agents' policies
+	ListByOffice(ctx context.Context, officeID uuid.UUID) ([]*ModelPolicy, error)
+	// Upsert creates the policy or replaces the one for the same office and
+	// agent, keeping its ID and creation time
+	Upsert(ctx context.Context, policy *ModelPolicy) error
+	Delete(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) error
+}
+
+// EmailOutboxRepository defines database operations for queued emails
+type EmailOutboxRepository interface {
+	Create(ctx context.Context, email *OutboxEmail) error
+	// ClaimDue returns up to limit pending emails due at now, counting an
+	// attempt for each and deferring their next attempt by lease, so that
+	// concurrent workers do not send an email twice
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*OutboxEmail, error)
+	MarkSent(ctx context.Context, id uuid.UUID) error
+	// MarkFailed records a failed attempt. The email is retried at
+	// nextAttemptAt, or given up on when nextAttemptAt is nil.
+	MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error
+}
+
+// DataExportRepository defines database operations for users' data exports
+type DataExportRepository interface {
+	// Create queues an export, or returns ErrAlreadyExists if one of the
+	// user's exports is still pending
+	Create(ctx context.Context, export *DataExport) error
+	GetByID(ctx context.Context, id uuid.UUID) (*DataExport, error)
+	// ListByUser returns the user's most recent exports, newest first
+	ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*DataExport, error)
+	// ClaimDue returns up to limit pending exports due at now, counting an
+	// attempt for each and deferring their next attempt by lease
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*DataExport, error)
+	MarkReady(ctx context.Context, id uuid.UUID, storageKey string, sizeBytes int64, expiresAt time.Time) error
+	// MarkFailed records a failed attempt. The export is retried at
+	// nextAttemptAt, or given up on when nextAttemptAt is nil.
+	MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error
+	// GetExpired returns up to limit ready exports that expired before now
+	GetExpired(ctx context.Context, now time.Time, limit int) ([]*DataExport, error)
+	// MarkExpired records that an export's archive was removed
+	MarkExpired(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookRepository defines database operations for webhooks
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	// GetByOfficeID returns an office's webhooks, newest first
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*Webhook, error)
+	// GetSubscribed returns the office's webhooks that receive eventType
+	GetSubscribed(ctx context.Context, officeID uuid.UUID, eventType WebhookEventType) ([]*Webhook, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookDeliveryRepository defines database operations for queued webhook
+// deliveries
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, deliveries []*WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries due at now, counting
+	// an attempt for each and deferring their next attempt by lease, so that
+	// concurrent workers do not send a delivery twice
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+	// RecordAttempt logs an attempt and sets the delivery's status. A
+	// pending delivery is retried at nextAttemptAt.
+	RecordAttempt(ctx context.Context, deliveryID uuid.UUID, attempt WebhookDeliveryAttempt, status WebhookDeliveryStatus, nextAttemptAt *time.Time) error
+	// GetByWebhookID returns a page of a webhook's deliveries with their
+	// attempts, newest first, and the total number of deliveries
+	GetByWebhookID(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*WebhookDelivery, int, error)
+}
+
+// WidgetTokenRepository defines database operations for chat widget tokens
+type WidgetTokenRepository interface {
+	Create(ctx context.Context, token *WidgetToken) error
+	GetByID(ctx context.Context, id uuid.UUID) (*WidgetToken, error)
+	// GetByOfficeID returns an office's tokens, including revoked ones, with
+	// the credits their conversations used
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*WidgetToken, error)
+	// GetActiveByHash returns the unrevoked token with the hash, or
+	// ErrNotFound
+	GetActiveByHash(ctx context.Context, tokenHash string) (*WidgetToken, error)
+	// RecordMessage counts a visitor message sent through the token
+	RecordMessage(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+// WidgetSessionRepository defines database operations for chat widget
+// visitor sessions
+type WidgetSessionRepository interface {
+	Create(ctx context.Context, session *WidgetSession) error
+	GetByID(ctx context.Context, id uuid.UUID) (*WidgetSession, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+}
+
+// LearningStatsRepository defines database operations for agent learning statistics
+type LearningStatsRepository interface {
+	GetByAgentID(ctx context.Context, agentID uuid.UUID) (*AgentLearningStats, error)
+	Refresh(ctx context.Context, agentID uuid.UUID) error
+	// RecordConsolidation records when the agent's memories were
+	// consolidated
+	RecordConsolidation(ctx context.Context, agentID uuid.UUID, at time.Time) error
+}
+
+// TemplatePurchaseRepository defines database operations for template entitlements
+type TemplatePurchaseRepository interface {
+	Create(ctx context.Context, purchase *TemplatePurchase) error
+	GetByID(ctx context.Context, id uuid.UUID) (*TemplatePurchase, error)
+	HasPurchased(ctx context.Context, officeID uuid.UUID, templateID uuid.UUID) (bool, error)
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*TemplatePurchase, error)
+	// Revoke ends an active entitlement, returning ErrNotFound if the
+	// purchase is not active
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+// TemplateBundleRepository defines database operations for template bundles
+type TemplateBundleRepository interface {
+	// Create and Update save a bundle together with its member templates
+	Create(ctx context.Context, bundle *TemplateBundle) error
+	Update(ctx context.Context, bundle *TemplateBundle) error
+	// GetByID returns a bundle with its members, listed or not
+	GetByID(ctx context.Context, id uuid.UUID) (*TemplateBundle, error)
+	GetByAuthor(ctx context.Context, authorID uuid.UUID) ([]TemplateBundle, error)
+	// List returns a page of listed bundles, featured first, and how many
+	// match the filter
+	List(ctx context.Context, filter BundleFilter) ([]TemplateBundle, int, error)
+}
+
+// TemplateFavoriteRepository defines database operations for users'
+// favorite templates
+type TemplateFavoriteRepository interface {
+	// Add saves a favorite, or changes whether it notifies, remembering the
+	// template's current version and price
+	Add(ctx context.Context, favorite *TemplateFavorite) error
+	Remove(ctx context.Context, userID, templateID uuid.UUID) error
+	// ListByUser returns a page of the user's favorites whose templates are
+	// approved and public, newest first, with their templates, and how many
+	// there are
+	ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]TemplateFavorite, int, error)
+	// TakeUpdates returns the template's favorites whose remembered version
+	// or price differ from those given, and remembers those instead
+	TakeUpdates(ctx context.Context, templateID uuid.UUID, version string, priceCents int) ([]FavoriteUpdate, error)
+}
+
+// AuthorProfileRepository defines database operations for author profiles
+type AuthorProfileRepository interface {
+	// GetByUserID returns a user's profile, under their account name if
+	// they never edited it, or ErrNotFound for closed accounts
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*AuthorProfile, error)
+	// Save stores the profile's display name, bio and links, renaming the
+	// author on their templates and bundles
+	Save(ctx context.Context, profile *AuthorProfile) error
+	// SetVerification stores the profile's verification and badge if its
+	// verification status is one of from, or returns ErrNotFound
+	SetVerification(ctx context.Context, profile *AuthorProfile, from ...AuthorVerificationStatus) error
+	// ListByVerificationStatus returns a page of profiles whose
+	// verification has the status, oldest request first, and how many there are
+	ListByVerificationStatus(ctx context.Context, status AuthorVerificationStatus, limit, offset int) ([]*AuthorProfile, int, error)
+	// GetStats sums up the author's approved public templates
+	GetStats(ctx context.Context, userID uuid.UUID) (AuthorStats, error)
+}
+
+// RefundRequestRepository defines database operations for purchase refund
+// requests
+type RefundRequestRepository interface {
+	// Create returns ErrAlreadyExists if the purchase has an open request
+	Create(ctx context.Context, request *RefundRequest) error
+	GetByID(ctx context.Context, id uuid.UUID) (*RefundRequest, error)
+	// List returns a page of every office's requests with the status,
+	// oldest first, and how many there are. An empty status lists them all.
+	List(ctx context.Context, status RefundRequestStatus, limit, offset int) ([]*RefundRequest, int, error)
+	// GetByOfficeID returns an office's requests, newest first
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*RefundRequest, error)
+	// Resolve records the decision on a pending request, returning
+	// ErrNotFound if it is no longer pending
+	Resolve(ctx context.Context, request *RefundRequest) error
+}
+
+// EarningsRepository defines database operations for marketplace author
+// earnings and payouts
+type EarningsRepository interface {
+	// RecordSale records a template sale and the author's share of it,
+	// returning the earning's ID
+	RecordSale(
+		ctx context.Context,
+		authorID, templateID, purchaserID, purchaserOfficeID uuid.UUID,
+		saleAmountCents int,
+		stripePaymentIntentID string,
+	) (uuid.UUID, error)
+	// RecordBundleSale records a template's share of a bundle sale, which
+	// may be below the minimum template price
+	RecordBundleSale(
+		ctx context.Context,
+		authorID, templateID, bundleID, purchaserID, purchaserOfficeID uuid.UUID,
+		saleAmountCents int,
+		stripePaymentIntentID string,
+	) (uuid.UUID, error)
+	// IsPaymentIntentUsed reports whether a sale was recorded with the card
+	// payment
+	IsPaymentIntentUsed(ctx context.Context, stripePaymentIntentID string) (bool, error)
+	GetEarning(ctx context.Context, id uuid.UUID) (*AuthorEarning, error)
+	GetAuthorEarnings(ctx context.Context, authorID uuid.UUID, limit, offset int) ([]AuthorEarning, error)
+	// ReverseSale marks a completed sale refunded, records the negative
+	// earning reversing it and takes the author's share back out of their
+	// balance. It returns the reversal, or ErrNotFound if the sale is not
+	// completed.
+	ReverseSale(ctx context.Context, earningID uuid.UUID) (*AuthorEarning, error)
+	CountAuthorEarnings(ctx context.Context, authorID uuid.UUID) (int, error)
+	GetAuthorBalance(ctx context.Context, authorID uuid.UUID) (*AuthorBalance, error)
+	GetEarningsSummary(ctx context.Context, authorID uuid.UUID) (*EarningsSummary, error)
+
+	// Payout operations
+	RequestPayout(ctx context.Context, authorID uuid.UUID, amountCents int) (uuid.UUID, error)
+	GetPayoutRequests(ctx context.Context, authorID uuid.UUID, limit, offset int) ([]PayoutRequest, error)
+	ListPayoutRequests(ctx context.Context, status PayoutStatus, limit, offset int) ([]PayoutRequest, int, error)
+	GetPayoutRequest(ctx context.Context, payoutID uuid.UUID) (*PayoutRequest, error)
+	CompletePayout(ctx context.Context, payoutID uuid.UUID, stripeTransferID string) error
+	FailPayout(ctx context.Context, payoutID uuid.UUID, reason string) (*PayoutRequest, error)
+}
+
+// TemplateViewRepository defines database operations for marketplace
+// template impressions and views
+type TemplateViewRepository interface {
+	// RecordViews counts the visitor once on date for each of the approved
+	// templates it has not been counted for yet, returning how many it was
+	// counted for. Other templates are skipped.
+	RecordViews(ctx context.Context, templateIDs []uuid.UUID, kind TemplateViewKind, visitorHash string, date time.Time) (int64, error)
+	// PurgeVisitors forgets the visitors counted before date
+	PurgeVisitors(ctx context.Context, before time.Time) (int64, error)
+	// GetAuthorTemplateStats returns the impressions, views, hires and sales
+	// of each of the author's templates in dates, most viewed first
+	GetAuthorTemplateStats(ctx context.Context, authorID uuid.UUID, dates DateRange) ([]TemplateStats, error)
+}
+
+// APIKeyRepository defines database operations for API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByID(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*APIKey, error)
+	// Use looks up a usable key by hash and records the use, returning
+	// ErrNotFound for unknown, revoked and expired keys
+	Use(ctx context.Context, keyHash string) (*APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+// AuthTokenRepository defines database operations for password reset and
+// email verification tokens
+type AuthTokenRepository interface {
+	Create(ctx context.Context, token *AuthToken) error
+	// Consume marks an unused, unexpired token as used and returns it, or
+	// returns ErrNotFound. A token can only be consumed once.
+	Consume(ctx context.Context, tokenHash string, purpose AuthTokenPurpose) (*AuthToken, error)
+	// Invalidate marks the user's outstanding tokens for purpose as used
+	Invalidate(ctx context.Context, userID uuid.UUID, purpose AuthTokenPurpose) error
+}
+
+// OAuthIdentityRepository defines database operations for OAuth identities
+type OAuthIdentityRepository interface {
+	Create(ctx context.Context, identity *OAuthIdentity) error
+	GetByProviderUserID(ctx context.Context, provider, providerUserID string) (*OAuthIdentity, error)
+}
+
+// InvoiceRepository defines database operations for manually billed invoices
+type InvoiceRepository interface {
+	// Create issues the invoice, assigning its number, or returns
+	// ErrAlreadyExists if the subscription's period was already invoiced
+	Create(ctx context.Context, invoice *Invoice) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Invoice, error)
+	// ListByOffice returns the office's most recent invoices, newest first
+	ListByOffice(ctx context.Context, officeID uuid.UUID, limit int) ([]*Invoice, error)
+	// GetLatestBySubscription returns the subscription's most recent
+	// invoice, or ErrNotFound
+	GetLatestBySubscription(ctx context.Context, subscriptionID uuid.UUID) (*Invoice, error)
+	// GetUninvoiced returns active subscriptions on one of tiers that are not
+	// billed through Stripe and whose current period has no invoice yet
+	GetUninvoiced(ctx context.Context, tiers []SubscriptionTier, limit int) ([]*Subscription, error)
+}
+
+// RetentionRepository defines database operations for data retention
+type RetentionRepository interface {
+	// ListOffices returns up to limit IDs, ordered and after afterID, of the
+	// offices on tier. Offices without a subscription are on the solo tier.
+	ListOffices(ctx context.Context, tier SubscriptionTier, afterID uuid.UUID, limit int) ([]uuid.UUID, error)
+	// Purge deletes the office's data of the given entities created before
+	// cutoff, and its agents and conversations deleted before cutoff, and
+	// returns what it deleted, with the storage keys of the deleted
+	// attachments. A dry run counts the same rows and deletes nothing.
+	Purge(ctx context.Context, officeID uuid.UUID, cutoff time.Time, entities []RetentionEntity, dryRun bool) (RetentionCounts, []string, error)
+	CreateRun(ctx context.Context, run *RetentionRun) error
+	// FinishRun records the run's totals and finish time
+	FinishRun(ctx context.Context, run *RetentionRun) error
+	CreatePurge(ctx context.Context, purge *RetentionPurge) error
+	GetRun(ctx context.Context, id uuid.UUID) (*RetentionRun, error)
+	// GetLatestRun returns the most recently started run, or ErrNotFound
+	GetLatestRun(ctx context.Context) (*RetentionRun, error)
+	// ListRuns returns a page of runs, most recent first, and how many there are
+	ListRuns(ctx context.Context, limit, offset int) ([]*RetentionRun, int, error)
+	ListPurges(ctx context.Context, runID uuid.UUID) ([]*RetentionPurge, error)
+}
+
+// AdminRepository defines the cross-office queries of the admin back office
+type AdminRepository interface {
+	// SearchUsers returns a page of users whose email or name contains
+	// query, newest first, and how many match
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]*User, int, error)
+	// SearchOffices returns a page of offices whose name or owner's email
+	// contains query, newest first, and how many match
+	SearchOffices(ctx context.Context, query string, limit, offset int) ([]*AdminOffice, int, error)
+	// ListFailedTasks returns a page of failed and dead-lettered tasks of
+	// every office, most recent first, and how many there are
+	ListFailedTasks(ctx context.Context, limit, offset int) ([]*Task, int, error)
+}
+
+// AuditRepository defines database operations for the append-only audit log
+type AuditRepository interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	// List returns a page of entries, most recent first, and how many match
+	List(ctx context.Context, filter AuditFilter, limit, offset int) ([]*AuditEntry, int, error)
+}
+
+// ModerationFlagRepository defines database operations for the content
+// moderation queue
+type ModerationFlagRepository interface {
+	Create(ctx context.Context, flag *ModerationFlag) error
+	GetByID(ctx context.Context, id uuid.UUID) (*ModerationFlag, error)
+	// List returns a page of flags, oldest first, and how many match
+	List(ctx context.Context, filter ModerationFlagFilter, limit, offset int) ([]*ModerationFlag, int, error)
+	// Resolve records an admin's decision on a pending flag, or returns
+	// ErrNotFound if the flag is not pending
+	Resolve(ctx context.Context, id uuid.UUID, status ModerationFlagStatus, reviewerID uuid.UUID, note string) error
+}
+
+// OfficeSecretRepository defines database operations for office secrets
+type OfficeSecretRepository interface {
+	// Create stores a new secret, or returns ErrAlreadyExists if the office
+	// has one by that name
+	Create(ctx context.Context, secret *OfficeSecret) error
+	GetByID(ctx context.Context, id uuid.UUID) (*OfficeSecret, error)
+	// GetByOfficeID returns an office's secrets by name
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*OfficeSecret, error)
+	// Update saves a secret's description, agents and value
+	Update(ctx context.Context, secret *OfficeSecret) error
+	// MarkAccessed records that the secrets were decrypted at the time
+	MarkAccessed(ctx context.Context, ids []uuid.UUID, at time.Time) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// WebResearchRepository defines database operations for offices' web
+// research settings and the searches their agents run
+type WebResearchRepository interface {
+	// GetSettings returns the office's settings, or ErrNotFound if it
+	// never changed the defaults
+	GetSettings(ctx context.Context, officeID uuid.UUID) (*WebResearchSettings, error)
+	UpsertSettings(ctx context.Context, settings *WebResearchSettings) error
+	RecordSearch(ctx context.Context, search *WebSearch) error
+	// CountSearches returns how many searches the task ran that were not
+	// answered from the cache
+	CountSearches(ctx context.Context, taskID uuid.UUID) (int, error)
+}
+
+// OAuthProvider signs users in with an OAuth2 authorization code flow
+type OAuthProvider interface {
+	// Name identifies the provider in URLs, e.g. "google"
+	Name() string
+	// AuthCodeURL is where to send the user to approve the sign-in
+	AuthCodeURL(state, redirectURL string) string
+	// Exchange redeems the code the provider sent to redirectURL and
+	// returns the user's profile
+	Exchange(ctx context.Context, code, redirectURL string) (*OAuthProfile, error)
+}
+
+// ObjectStorage stores file contents by key
+type ObjectStorage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns the object's contents, or ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that downloads the object, saved as
+	// filename, until ttl passes. Storages that cannot sign URLs return an
+	// empty URL; their objects are served through the API instead.
+	PresignGet(ctx context.Context, key, filename string, ttl time.Duration) (string, error)
+}
+
+// ContentModerator screens user-written text, such as a keyword blocklist
+// or an external moderation API
+type ContentModerator interface {
+	Moderate(ctx context.Context, text string) (ModerationResult, error)
+}
+
+// KnowledgeRepository defines database operations for offices' knowledge
+// bases
+type KnowledgeRepository interface {
+	// Create stores a document and its chunks
+	Create(ctx context.Context, document *KnowledgeDocument, chunks []*KnowledgeChunk) error
+	GetByID(ctx context.Context, id uuid.UUID) (*KnowledgeDocument, error)
+	// GetByOfficeID returns the office's documents without their content,
+	// newest first
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*KnowledgeDocument, error)
+	CountByOfficeID(ctx context.Context, officeID uuid.UUID) (int, error)
+	// Delete removes a document and its chunks
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetUnembedded returns chunks without an embedding, oldest first
+	GetUnembedded(ctx context.Context, limit int) ([]*KnowledgeChunk, error)
+	SetEmbedding(ctx context.Context, chunkID uuid.UUID, embedding []float32) error
+	// SearchByEmbedding returns the office's embedded chunks most similar
+	// to the embedding
+	SearchByEmbedding(ctx context.Context, officeID uuid.UUID, embedding []float32, limit int) ([]*KnowledgeMatch, error)
+	// SearchByText returns the office's chunks with any of the query's
+	// words, best matches first
+	SearchByText(ctx context.Context, officeID uuid.UUID, query string, limit int) ([]*KnowledgeMatch, error)
+}
+
+// AgentSkillRepository defines database operations for agents' skill
+// configuration
+type AgentSkillRepository interface {
+	// GetByAgentID returns whether each skill configured for the agent is
+	// enabled, by key; skills never configured are left out
+	GetByAgentID(ctx context.Context, agentID uuid.UUID) (map[string]bool, error)
+	// Set enables or disables the skills for the agent, leaving the others
+	// as they are
+	Set(ctx context.Context, agentID uuid.UUID, skills map[string]bool) error
+}
+
+// SearchRepository defines full-text search over an office's messages,
+// task outputs and documents. Deleted messages and the messages of deleted
+// conversations are left out.
+type SearchRepository interface {
+	// Search returns a page of matches, best first
+	Search(ctx context.Context, filter SearchFilter, page PageRequest) ([]*SearchResult, error)
+	Count(ctx context.Context, filter SearchFilter) (int, error)
+}
+
+// ConversationSummaryRepository defines database operations for
+// conversation summaries. A run's status follows its task until the
+// summary is completed.
+type ConversationSummaryRepository interface {
+	// Create stores a pending run, claiming its conversation, or returns
+	// ErrAlreadyExists while another run of the conversation is pending
+	Create(ctx context.Context, summary *ConversationSummary) error
+	// SetTask records the task a pending run started
+	SetTask(ctx context.Context, id, taskID uuid.UUID) error
+	// Fail marks a pending run whose task could not be started failed,
+	// releasing its conversation's claim
+	Fail(ctx context.Context, id uuid.UUID) error
+	GetByTaskID(ctx context.Context, taskID uuid.UUID) (*ConversationSummary, error)
+	// GetLatest returns the conversation's newest run, or ErrNotFound
+	GetLatest(ctx context.Context, conversationID uuid.UUID) (*ConversationSummary, error)
+	// GetLatestDone returns the conversation's newest completed run, or
+	// ErrNotFound
+	GetLatestDone(ctx context.Context, conversationID uuid.UUID) (*ConversationSummary, error)
+	// Complete saves the summary's content and posts it as message, which
+	// replaces the pinned previous summary and becomes the conversation's
+	// summary. It returns ErrNotFound if the run is already completed.
+	Complete(ctx context.Context, summary *ConversationSummary, message *Message) error
+	// GetUnsummarized returns up to limit active conversations with at
+	// least minMessages top-level messages since their latest summary and
+	// no run started after since
+	GetUnsummarized(ctx context.Context, minMessages int, since time.Time, limit int) ([]uuid.UUID, error)
+}
+
+// ProjectRepository defines database operations for project boards.
+// Columns and cards are kept at consecutive positions from 0; positions
+// past the end are clamped to it, and moves of one board are serialized.
+type ProjectRepository interface {
+	// Create stores a project with its first columns, returning
+	// ErrAlreadyExists if the office has a project with the name
+	Create(ctx context.Context, project *Project, columns []*BoardColumn) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Project, error)
+	// GetByOfficeID returns the office's projects by name, without columns
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*Project, error)
+	// Update saves a project's name and description, returning
+	// ErrAlreadyExists if the office has another project with the name
+	Update(ctx context.Context, project *Project) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetColumns returns a project's columns in order, without cards
+	GetColumns(ctx context.Context, projectID uuid.UUID) ([]*BoardColumn, error)
+	GetColumn(ctx context.Context, id uuid.UUID) (*BoardColumn, error)
+	// CreateColumn adds a column at the end of its project's board
+	CreateColumn(ctx context.Context, column *BoardColumn) error
+	// UpdateColumn renames a column and moves it to its Position, which is
+	// set to where it ends up
+	UpdateColumn(ctx context.Context, column *BoardColumn) error
+	// DeleteColumn removes a column with its cards
+	DeleteColumn(ctx context.Context, id uuid.UUID) error
+
+	// GetCards returns the cards of a project's board by column and
+	// position, with their tasks
+	GetCards(ctx context.Context, projectID uuid.UUID) ([]*BoardCard, error)
+	GetCard(ctx context.Context, taskID uuid.UUID) (*BoardCard, error)
+	// AddCard places a task at its Position in its column, returning
+	// ErrAlreadyExists if the task is already a card
+	AddCard(ctx context.Context, card *BoardCard) error
+	// MoveCard moves a card to its ColumnID and Position, which is set to
+	// where it ends up
+	MoveCard(ctx context.Context, card *BoardCard) error
+	RemoveCard(ctx context.Context, taskID uuid.UUID) error
+}
+
+// DepartmentRepository defines database operations for departments and
+// the reporting lines between agents. Departments list their members by
+// when they joined, leaving out deleted agents.
+type DepartmentRepository interface {
+	// Create returns ErrAlreadyExists if the office has a department with
+	// the name
+	Create(ctx context.Context, department *Department) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Department, error)
+	// GetByOfficeID returns the office's departments by name
+	GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*Department, error)
+	GetByConversationID(ctx context.Context, conversationID uuid.UUID) (*Department, error)
+	// Update saves a department's name, description and lead, returning
+	// ErrAlreadyExists if the office has another department with the name
+	Update(ctx context.Context, department *Department) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// AddMember returns ErrAlreadyExists if the agent is in a department
+	AddMember(ctx context.Context, departmentID, agentID uuid.UUID) error
+	RemoveMember(ctx context.Context, departmentID, agentID uuid.UUID) error
+
+	// GetReportingLines returns the reporting lines of the office's
+	// undeleted agents
+	GetReportingLines(ctx context.Context, officeID uuid.UUID) ([]*ReportingLine, error)
+	// SetManager makes the agent report to the manager, or to no one when
+	// managerID is nil
+	SetManager(ctx context.Context, agentID uuid.UUID, managerID *uuid.UUID) error
+}
+
+// Embedder turns text into an embedding of MemoryEmbeddingDimensions
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// WebSearchCache keeps web search results shared by all offices, by query
+type WebSearchCache interface {
+	// Get returns the results cached for key, or false if there are none
+	// or they expired
+	Get(ctx context.Context, key string) ([]WebSearchResult, bool, error)
+	Set(ctx context.Context, key string, results []WebSearchResult, ttl time.Duration) error
+	// PurgeExpired deletes expired results from caches that do not expire
+	// them on their own
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// SecretCipher encrypts office secrets at rest, such as with a master key
+// or a key management service. Additional data binds a ciphertext to what
+// it was sealed for, so it cannot be opened as another secret.
+type SecretCipher interface {
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	Open(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// BillingProvider manages subscriptions and payments with the payment
+// provider, by the provider's IDs
+type BillingProvider interface {
+	// CancelSubscription cancels now, or stops renewal at the end of the
+	// current period
+	CancelSubscription(ctx context.Context, subscriptionID string, atPeriodEnd bool) error
+	// PauseSubscription stops collecting payments until resumed
+	PauseSubscription(ctx context.Context, subscriptionID string) error
+	ResumeSubscription(ctx context.Context, subscriptionID string) error
+	// ListInvoices returns the customer's most recent invoices, newest first
+	ListInvoices(ctx context.Context, customerID string, limit int) ([]*Invoice, error)
+	// UpcomingInvoice previews the customer's next invoice, or returns
+	// ErrNotFound if none is coming
+	UpcomingInvoice(ctx context.Context, customerID string) (*Invoice, error)
+	// RefundPayment refunds a payment in full and returns the refund's ID.
+	// Retries with the same idempotency key refund it only once.
+	RefundPayment(ctx context.Context, paymentIntentID, idempotencyKey string) (string, error)
+	// ChargePaymentMethod charges a customer's saved payment method while
+	// they are away and returns the payment's ID. Retries with the same
+	// idempotency key charge it only once.
+	ChargePaymentMethod(ctx context.Context, charge PaymentCharge) (string, error)
+	// GetPaymentIntent returns a payment made by a customer, or ErrNotFound
+	// if there is none with the ID
+	GetPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentIntent, error)
+}
+
+// PaymentIntentSucceeded is the status of a payment that went through
+const PaymentIntentSucceeded = "succeeded"
+
+// PaymentIntent is a payment as the payment provider reports it
+type PaymentIntent struct {
+	ID          string
+	Status      string
+	AmountCents int64
+	Currency    string
+	// Metadata is what was attached to the payment when it was created
+	Metadata map[string]string
+}
+
+// PaymentCharge is an off-session payment from a saved payment method
+type PaymentCharge struct {
+	CustomerID      string
+	PaymentMethodID string
+	AmountCents     int64
+	Currency        string
+	Description     string
+	IdempotencyKey  string
+	// Metadata is attached to the payment for reconciliation
+	Metadata map[string]string
+}
+
+// RateLimiter meters requests with token buckets
+type RateLimiter interface {
+	// Take counts a request against key's bucket, which holds up to limit
+	// tokens and refills completely over period
+	Take(ctx context.Context, key string, limit int, period time.Duration) (RateLimit, error)
+}
+
+// EventPublisher emits office-scoped realtime events
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// EventSubscriber registers handlers for published events
+type EventSubscriber interface {
+	Subscribe(handler func(Event))
+}
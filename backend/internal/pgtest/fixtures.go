@@ -0,0 +1,66 @@
+//go:build integration
+
+package pgtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// The factories insert rows with raw SQL so that they do not depend on the
+// repositories under test. Each row gets fresh IDs and a unique email, so
+// tests sharing the database do not see each other's fixtures.
+
+// User inserts a user and returns its ID
+func (db *DB) User(t testing.TB) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO users (id, email, password_hash, name) VALUES ($1, $2, 'x', 'Test User')`,
+		id, id.String()+"@test.synoffice.local")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return id
+}
+
+// Office inserts an office owned by userID and returns its ID. The wallet
+// and the solo subscription are created by the offices triggers.
+func (db *DB) Office(t testing.TB, userID uuid.UUID) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO offices (id, user_id, name) VALUES ($1, $2, 'Test Office')`, id, userID)
+	if err != nil {
+		t.Fatalf("create office: %v", err)
+	}
+	return id
+}
+
+// Wallet returns the ID of the office's wallet after setting its balance
+func (db *DB) Wallet(t testing.TB, officeID uuid.UUID, balance int64) uuid.UUID {
+	t.Helper()
+	var id uuid.UUID
+	err := db.Pool.QueryRow(context.Background(),
+		`UPDATE credit_wallets SET balance = $2 WHERE office_id = $1 RETURNING id`, officeID, balance).Scan(&id)
+	if err != nil {
+		t.Fatalf("set wallet balance: %v", err)
+	}
+	return id
+}
+
+// Template inserts an approved public template by authorID and returns its ID
+func (db *DB) Template(t testing.TB, authorID uuid.UUID) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Pool.Exec(context.Background(), `
+		INSERT INTO agent_templates (id, name, role, system_prompt, author_id, author_name, category, status)
+		VALUES ($1, 'Test Agent', 'Tester', 'You test things.', $2, 'Test Author', 'general', 'approved')
+	`, id, authorID)
+	if err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	return id
+}
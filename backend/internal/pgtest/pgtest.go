@@ -0,0 +1,128 @@
+//go:build integration
+
+// Package pgtest provides a Postgres database with the schema of
+// infra/migrations applied, for the integration tests of the repositories.
+//
+// Start runs pgvector/pgvector:pg15, the image of infra/docker-compose.yml,
+// through testcontainers. When TEST_DATABASE_URL is set that database is
+// used instead; it must be empty, since the migrations are applied to it.
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// image is the Postgres image the tests run against
+const image = "pgvector/pgvector:pg15"
+
+// DB is a migrated test database
+type DB struct {
+	Pool      *pgxpool.Pool
+	container *postgres.PostgresContainer
+}
+
+// Start starts the test database and applies the migrations
+func Start(ctx context.Context) (*DB, error) {
+	db := &DB{}
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		container, err := postgres.Run(ctx, image,
+			postgres.WithDatabase("synoffice_test"),
+			postgres.WithUsername("synoffice"),
+			postgres.WithPassword("synoffice"),
+			testcontainers.WithWaitStrategy(
+				wait.ForLog("database system is ready to accept connections").
+					WithOccurrence(2).
+					WithStartupTimeout(time.Minute),
+			),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("start postgres: %w", err)
+		}
+		db.container = container
+
+		url, err = container.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			db.Close(ctx)
+			return nil, err
+		}
+	}
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+	db.Pool = pool
+
+	if err := migrate(ctx, pool); err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the pool and removes the container
+func (db *DB) Close(ctx context.Context) {
+	if db.Pool != nil {
+		db.Pool.Close()
+	}
+	if db.container != nil {
+		_ = db.container.Terminate(ctx)
+	}
+}
+
+// migrationDir returns infra/migrations, found from this file so the tests
+// of any package can apply it
+func migrationDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "infra", "migrations")
+}
+
+// migrate applies the up migrations in order, as cmd/migrate up does
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	dir := migrationDir()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return migrationName(names[i]) < migrationName(names[j]) })
+
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if _, err := pool.Exec(ctx, string(content)); err != nil {
+			return fmt.Errorf("apply %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// migrationName returns the migration an up file belongs to
+func migrationName(file string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(file, ".sql"), ".up")
+}
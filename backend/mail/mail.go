@@ -0,0 +1,138 @@
+// Package mail renders the transactional emails Synoffice sends. Every
+// template has a plain-text version, templates/<name>.txt, which defines the
+// subject, and an HTML version, templates/<name>.html, which is set in the
+// shared layout. Files starting with an underscore are partials shared by
+// all templates.
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+//go:embed templates/*
+var files embed.FS
+
+// AccountData is the data of the account emails, verify_email,
+// password_reset and data_export_ready
+type AccountData struct {
+	Name string
+	// Link is the URL the user must open
+	Link string
+}
+
+// NotificationData is the data of notification emails. Templates named
+// after a notification type draw on its payload; others fall back to the
+// generic notification template, which shows Title and Message.
+type NotificationData struct {
+	Name    string
+	Title   string
+	Message string
+	Payload map[string]any
+	// AppURL is the frontend base URL links point to
+	AppURL string
+}
+
+// Notification is the generic notification template
+const Notification = "notification"
+
+type templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var registry = mustLoad()
+
+// Exists reports whether there is a template called name
+func Exists(name string) bool {
+	_, ok := registry[name]
+	return ok
+}
+
+// Render renders the named template with data into an email to the given
+// address
+func Render(name, to string, data any) (domain.Email, error) {
+	t, ok := registry[name]
+	if !ok {
+		return domain.Email{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return domain.Email{}, fmt.Errorf("mail: %s subject: %w", name, err)
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return domain.Email{}, fmt.Errorf("mail: %s text: %w", name, err)
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return domain.Email{}, fmt.Errorf("mail: %s html: %w", name, err)
+	}
+
+	return domain.Email{
+		To:       to,
+		Subject:  strings.Join(strings.Fields(subject.String()), " "),
+		Body:     strings.TrimSpace(text.String()) + "\n",
+		HTMLBody: html.String(),
+		Template: name,
+	}, nil
+}
+
+// mustLoad parses every template. The templates are embedded, so a parse
+// error is a bug and panics at startup.
+func mustLoad() map[string]templates {
+	entries, err := files.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+
+	registry := make(map[string]templates)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if !ok || strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		text := texttemplate.Must(texttemplate.New(name+".txt").
+			Funcs(texttemplate.FuncMap{"cents": cents}).
+			ParseFS(files, path.Join("templates", name+".txt"), "templates/_*.txt"))
+
+		html := htmltemplate.Must(htmltemplate.New("_layout.html").
+			Funcs(htmltemplate.FuncMap{"cents": cents, "button": button}).
+			ParseFS(files, "templates/_*.html", path.Join("templates", name+".html")))
+
+		registry[name] = templates{text: text, html: html}
+	}
+	return registry
+}
+
+// cents formats an amount in cents as dollars
+func cents(amount any) string {
+	var c int64
+	switch v := amount.(type) {
+	case int:
+		c = int64(v)
+	case int64:
+		c = v
+	case float64:
+		c = int64(v)
+	}
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, c/100, c%100)
+}
+
+// button renders a link styled as a button
+func button(href, label string) htmltemplate.HTML {
+	return htmltemplate.HTML(fmt.Sprintf(
+		`<a href="%s" style="display:inline-block;background:#4f46e5;color:#ffffff;text-decoration:none;padding:10px 20px;border-radius:6px;font-weight:600;">%s</a>`,
+		htmltemplate.HTMLEscapeString(href), htmltemplate.HTMLEscapeString(label)))
+}
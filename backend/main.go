@@ -3,15 +3,26 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/denys89/syn-office/backend/api"
 	"github.com/denys89/syn-office/backend/config"
+	"github.com/denys89/syn-office/backend/domain"
 	"github.com/denys89/syn-office/backend/repository"
 	"github.com/denys89/syn-office/backend/service"
+	"github.com/denys89/syn-office/backend/transport"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take to drain
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load configuration
 	cfg := config.MustLoad()
@@ -22,8 +33,16 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	// Deferred calls run in reverse order, so the pool closes last
 	defer pool.Close()
 
+	// shutdownCtx is cancelled on SIGINT/SIGTERM; background workers get
+	// their own context so they keep running until requests have drained
+	shutdownCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
 	// Verify database connection
 	if err := pool.Ping(ctx); err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
@@ -34,41 +53,410 @@ func main() {
 	userRepo := repository.NewUserRepository(pool)
 	officeRepo := repository.NewOfficeRepository(pool)
 	agentTemplateRepo := repository.NewAgentTemplateRepository(pool)
-	agentRepo := repository.NewAgentRepository(pool, agentTemplateRepo)
-	conversationRepo := repository.NewConversationRepository(pool, agentRepo)
+	agentRepo := repository.NewAgentRepository(pool)
+	conversationRepo := repository.NewConversationRepository(pool)
 	messageRepo := repository.NewMessageRepository(pool)
 	taskRepo := repository.NewTaskRepository(pool)
 	marketplaceRepo := repository.NewMarketplaceRepository(pool)
 	feedbackRepo := repository.NewFeedbackRepository(pool)
 	creditRepo := repository.NewCreditRepository(pool)
+	promoRepo := repository.NewPromoCodeRepository(pool)
+	autoTopUpRepo := repository.NewAutoTopUpRepository(pool)
 	subscriptionRepo := repository.NewSubscriptionRepository(pool)
 	analyticsRepo := repository.NewAnalyticsRepository(pool)
 	earningsRepo := repository.NewEarningsRepository(pool)
+	notificationRepo := repository.NewNotificationRepository(pool)
+	memoryRepo := repository.NewAgentMemoryRepository(pool)
+	learningStatsRepo := repository.NewLearningStatsRepository(pool)
+	purchaseRepo := repository.NewTemplatePurchaseRepository(pool)
+	refundRequestRepo := repository.NewRefundRequestRepository(pool)
+	templateVersionRepo := repository.NewTemplateVersionRepository(pool)
+	templateBundleRepo := repository.NewTemplateBundleRepository(pool)
+	authorProfileRepo := repository.NewAuthorProfileRepository(pool)
+	templateFavoriteRepo := repository.NewTemplateFavoriteRepository(pool)
+	scheduleRepo := repository.NewScheduledTaskRepository(pool)
+	idempotencyRepo := repository.NewIdempotencyRepository(pool)
+	apiKeyRepo := repository.NewAPIKeyRepository(pool)
+	authTokenRepo := repository.NewAuthTokenRepository(pool)
+	oauthIdentityRepo := repository.NewOAuthIdentityRepository(pool)
+	agentChangeRepo := repository.NewAgentChangeRepository(pool)
+	conversationReadRepo := repository.NewConversationReadRepository(pool)
+	attachmentRepo := repository.NewAttachmentRepository(pool)
+	documentRepo := repository.NewDocumentRepository(pool)
+	notificationPreferenceRepo := repository.NewNotificationPreferenceRepository(pool)
+	emailOutboxRepo := repository.NewEmailOutboxRepository(pool)
+	modelPolicyRepo := repository.NewModelPolicyRepository(pool)
+	retentionRepo := repository.NewRetentionRepository(pool)
+	invoiceRepo := repository.NewInvoiceRepository(pool)
+	adminRepo := repository.NewAdminRepository(pool)
+	auditRepo := repository.NewAuditRepository(pool)
+	dataExportRepo := repository.NewDataExportRepository(pool)
+	moderationFlagRepo := repository.NewModerationFlagRepository(pool)
+	webhookRepo := repository.NewWebhookRepository(pool)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(pool)
+	widgetTokenRepo := repository.NewWidgetTokenRepository(pool)
+	widgetSessionRepo := repository.NewWidgetSessionRepository(pool)
+	templateViewRepo := repository.NewTemplateViewRepository(pool)
+	officeSecretRepo := repository.NewOfficeSecretRepository(pool)
+	webResearchRepo := repository.NewWebResearchRepository(pool)
+	knowledgeRepo := repository.NewKnowledgeRepository(pool)
+	agentSkillRepo := repository.NewAgentSkillRepository(pool)
+	departmentRepo := repository.NewDepartmentRepository(pool)
+	taskTemplateRepo := repository.NewTaskTemplateRepository(pool)
+	projectRepo := repository.NewProjectRepository(pool)
+	searchRepo := repository.NewSearchRepository(pool)
+	conversationSummaryRepo := repository.NewConversationSummaryRepository(pool)
+	txManager := repository.NewTxManager(pool)
+
+	// Initialize the realtime event bus. With Redis, events published on any
+	// replica reach WebSocket clients connected to every replica; while Redis
+	// is unreachable, events are delivered to this replica's clients only.
+	var eventBus transport.EventBus
+	switch cfg.EventBus {
+	case "redis":
+		redisBus, err := transport.NewRedisEventBus(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize event bus: %v", err)
+		}
+		defer redisBus.Close()
+		go redisBus.Run(workerCtx)
+		eventBus = redisBus
+		log.Println("Using Redis event bus")
+	case "local":
+		eventBus = transport.NewLocalEventBus()
+	default:
+		log.Fatalf("Unknown EVENT_BUS %q (expected local or redis)", cfg.EventBus)
+	}
+
+	// Initialize the request rate limiter
+	var rateLimiter domain.RateLimiter
+	switch cfg.RateLimiter {
+	case "redis":
+		redisLimiter, err := transport.NewRedisRateLimiter(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize rate limiter: %v", err)
+		}
+		defer redisLimiter.Close()
+		rateLimiter = redisLimiter
+		log.Println("Using Redis rate limiter")
+	case "local":
+		rateLimiter = transport.NewLocalRateLimiter()
+	default:
+		log.Fatalf("Unknown RATE_LIMITER %q (expected local or redis)", cfg.RateLimiter)
+	}
+
+	// Initialize the web search result cache, shared by all offices
+	var webSearchCache domain.WebSearchCache
+	switch cfg.WebResearchCache {
+	case "redis":
+		redisCache, err := transport.NewRedisSearchCache(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize web search cache: %v", err)
+		}
+		defer redisCache.Close()
+		webSearchCache = redisCache
+		log.Println("Using Redis web search cache")
+	case "postgres":
+		webSearchCache = repository.NewWebSearchCacheRepository(pool)
+	default:
+		log.Fatalf("Unknown WEB_RESEARCH_CACHE %q (expected postgres or redis)", cfg.WebResearchCache)
+	}
+
+	// Initialize the mail provider; emails are queued in the outbox and
+	// delivered through it by the mail worker
+	var mailer domain.Mailer
+	switch cfg.Mailer {
+	case "smtp":
+		smtpMailer, err := transport.NewSMTPMailer(transport.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize mailer: %v", err)
+		}
+		mailer = smtpMailer
+	case "sendgrid":
+		sendGridMailer, err := transport.NewSendGridMailer(cfg.SendGridAPIKey, cfg.MailFrom)
+		if err != nil {
+			log.Fatalf("Failed to initialize mailer: %v", err)
+		}
+		mailer = sendGridMailer
+	case "log":
+		if cfg.Environment == "production" {
+			log.Println("Warning: MAILER=log in production, emails will only be logged")
+		}
+		mailer = transport.NewLogMailer()
+	default:
+		log.Fatalf("Unknown MAILER %q (expected log, smtp or sendgrid)", cfg.Mailer)
+	}
+
+	// Subscriptions billed through Stripe are kept in sync with it when a
+	// Stripe key is configured
+	var billing domain.BillingProvider
+	if cfg.StripeSecretKey != "" {
+		stripeBilling, err := transport.NewStripeBilling(cfg.StripeSecretKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize Stripe billing: %v", err)
+		}
+		billing = stripeBilling
+	} else if cfg.Environment == "production" {
+		log.Println("Warning: STRIPE_SECRET_KEY is not set, subscription changes will not reach Stripe")
+	}
+	if cfg.StripeWebhookSecret == "" && cfg.Environment == "production" {
+		log.Println("Warning: STRIPE_WEBHOOK_SECRET is not set, Stripe webhook events will be refused")
+	}
+
+	// Initialize the object storage for message attachments
+	var storage domain.ObjectStorage
+	switch cfg.Storage {
+	case "s3":
+		s3Storage, err := transport.NewS3Storage(transport.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			PublicEndpoint:  cfg.S3PublicEndpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretKey,
+			PathStyle:       cfg.S3UsePathStyle,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize storage: %v", err)
+		}
+		storage = s3Storage
+		log.Printf("Using S3 storage bucket %s", cfg.S3Bucket)
+	case "local":
+		localStorage, err := transport.NewLocalStorage(cfg.StoragePath)
+		if err != nil {
+			log.Fatalf("Failed to initialize storage: %v", err)
+		}
+		storage = localStorage
+	default:
+		log.Fatalf("Unknown STORAGE %q (expected local or s3)", cfg.Storage)
+	}
+
+	// Decide what happens to chat tasks estimated to cost more than the
+	// office's remaining budget
+	costPolicy := service.CostPolicy(cfg.CostEstimatePolicy)
+	switch costPolicy {
+	case service.CostPolicyOff, service.CostPolicyWarn, service.CostPolicyBlock:
+	default:
+		log.Fatalf("Unknown COST_ESTIMATE_POLICY %q (expected off, warn or block)", cfg.CostEstimatePolicy)
+	}
+
+	// Content moderation screens with the blocklist first, then with the
+	// moderation API when one is configured
+	moderationActions := make(service.ModerationActions)
+	for _, setting := range []struct {
+		variable    string
+		contentType domain.ModerationContentType
+		action      string
+	}{
+		{"MODERATION_MESSAGES", domain.ModerationContentMessage, cfg.ModerationMessages},
+		{"MODERATION_SYSTEM_PROMPTS", domain.ModerationContentSystemPrompt, cfg.ModerationSystemPrompts},
+		{"MODERATION_TEMPLATES", domain.ModerationContentTemplate, cfg.ModerationTemplates},
+	} {
+		action := domain.ModerationAction(setting.action)
+		switch action {
+		case domain.ModerationActionOff, domain.ModerationActionFlag, domain.ModerationActionBlock:
+		default:
+			log.Fatalf("Unknown %s %q (expected off, flag or block)", setting.variable, setting.action)
+		}
+		moderationActions[setting.contentType] = action
+	}
+	keywordModerator, err := transport.NewKeywordModerator(cfg.ModerationBlocklist)
+	if err != nil {
+		log.Fatalf("Failed to initialize content moderation: %v", err)
+	}
+	moderators := []domain.ContentModerator{keywordModerator}
+	if cfg.ModerationAPIKey != "" {
+		apiModerator, err := transport.NewAPIModerator(cfg.ModerationAPIKey, cfg.ModerationAPIURL, cfg.ModerationAPIModel)
+		if err != nil {
+			log.Fatalf("Failed to initialize content moderation: %v", err)
+		}
+		moderators = append(moderators, apiModerator)
+	}
+
+	// Memory search by meaning needs query embeddings; without them it
+	// matches the memories' text
+	var embedder domain.Embedder
+	if cfg.EmbeddingsAPIKey != "" {
+		apiEmbedder, err := transport.NewAPIEmbedder(cfg.EmbeddingsAPIKey, cfg.EmbeddingsAPIURL, cfg.EmbeddingsModel)
+		if err != nil {
+			log.Fatalf("Failed to initialize embeddings: %v", err)
+		}
+		embedder = apiEmbedder
+	}
+
+	// Office secrets are sealed with the master key; without one the
+	// vault is unavailable
+	var secretCipher domain.SecretCipher
+	if cfg.SecretsMasterKey != "" {
+		aesCipher, err := transport.NewAESCipher(cfg.SecretsMasterKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize the secrets vault: %v", err)
+		}
+		secretCipher = aesCipher
+	} else if cfg.Environment == "production" {
+		log.Println("Warning: SECRETS_MASTER_KEY is not set, office secrets cannot be stored")
+	}
+
+	var retentionExempt []domain.RetentionEntity
+	for _, name := range cfg.RetentionExempt {
+		entity := domain.RetentionEntity(strings.TrimSpace(name))
+		if !slices.Contains(domain.RetentionEntities, entity) {
+			log.Fatalf("Unknown RETENTION_EXEMPT entity %q (expected messages, tasks, usage or deleted)", name)
+		}
+		retentionExempt = append(retentionExempt, entity)
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, officeRepo, cfg.JWTSecret)
-	agentService := service.NewAgentService(agentRepo, agentTemplateRepo)
-	taskService := service.NewTaskService(taskRepo, cfg.OrchestratorURL)
-	chatService := service.NewChatService(conversationRepo, messageRepo, agentRepo, taskService)
-	marketplaceService := service.NewMarketplaceService(marketplaceRepo)
-	feedbackService := service.NewFeedbackService(feedbackRepo, agentRepo, officeRepo)
-	creditService := service.NewCreditService(creditRepo, officeRepo)
-	subscriptionService := service.NewSubscriptionService(subscriptionRepo, creditRepo, "config/subscription_tiers.yaml")
-	analyticsService := service.NewAnalyticsService(analyticsRepo, creditRepo)
-	earningsService := service.NewEarningsService(earningsRepo, marketplaceRepo)
+	mailService := service.NewMailService(emailOutboxRepo, mailer)
+	webhookDispatcher := service.NewWebhookDispatcher(webhookRepo, webhookDeliveryRepo, cfg.WebhookAllowPrivateNetworks)
+	notificationService := service.NewNotificationService(notificationRepo, notificationPreferenceRepo, officeRepo, userRepo, mailService, eventBus, webhookDispatcher, cfg.AppURL)
+	auditService := service.NewAuditService(auditRepo, officeRepo, userRepo)
+	contentModerationService := service.NewContentModerationService(moderationFlagRepo, moderators, moderationActions, auditService)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, creditRepo, agentRepo, txManager, billing, notificationService, auditService, "config/subscription_tiers.yaml")
+	authService := service.NewAuthService(userRepo, officeRepo, authTokenRepo, txManager, mailService, subscriptionService, auditService, cfg.JWTSecret, cfg.AppURL)
+	agentService := service.NewAgentService(agentRepo, agentTemplateRepo, purchaseRepo, agentChangeRepo, txManager, subscriptionService, auditService, contentModerationService)
+	creditService := service.NewCreditService(creditRepo, officeRepo, idempotencyRepo, txManager, notificationService)
+	promoService := service.NewPromoService(promoRepo, subscriptionRepo, creditRepo, creditService, txManager, auditService)
+	autoTopUpService := service.NewAutoTopUpService(creditRepo, autoTopUpRepo, subscriptionRepo, txManager, creditService, subscriptionService, billing, notificationService, auditService)
+	attachmentService := service.NewAttachmentService(attachmentRepo, conversationRepo, storage, subscriptionService, cfg.JWTSecret, cfg.PublicURL)
+	knowledgeService := service.NewKnowledgeService(knowledgeRepo, embedder)
+	skillService := service.NewSkillService(agentSkillRepo, agentRepo, subscriptionService)
+	taskContextBuilder := service.NewTaskContextBuilder(messageRepo, agentRepo, memoryRepo, userRepo, modelPolicyRepo, knowledgeService, skillService, conversationSummaryRepo, service.TaskContextConfig{
+		HistoryMessages: cfg.ContextHistoryMessages,
+		Memories:        cfg.ContextMemories,
+		TokenBudget:     cfg.ContextTokenBudget,
+		KnowledgeChunks: cfg.ContextKnowledgeChunks,
+	})
+	analyticsService := service.NewAnalyticsService(analyticsRepo, creditRepo, officeRepo, cfg.AnalyticsFallback)
+	performanceService := service.NewPerformanceService(analyticsRepo, agentRepo)
+	templateViewService := service.NewTemplateViewService(templateViewRepo)
+	exportService := service.NewExportService(analyticsService, creditRepo, earningsRepo, subscriptionService)
+	taskService := service.NewTaskService(taskRepo, creditService, subscriptionService, analyticsService, attachmentService, taskContextBuilder, eventBus, cfg.OrchestratorURL, service.DelegationConfig{
+		MaxDepth: cfg.MaxDelegationDepth,
+		MaxTasks: cfg.MaxDelegatedTasks,
+	})
+	costEstimateService := service.NewCostEstimateService(agentRepo, conversationRepo, creditService, taskContextBuilder, eventBus, cfg.OrchestratorURL, costPolicy)
+	chatService := service.NewChatService(conversationRepo, messageRepo, conversationReadRepo, agentRepo, departmentRepo, txManager, attachmentService, subscriptionService, taskService, costEstimateService, eventBus, webhookDispatcher, auditService, contentModerationService)
+	onboardingService := service.NewOnboardingService(agentTemplateRepo, agentRepo, txManager, agentService, chatService, "config/onboarding_presets.yaml")
+	departmentService := service.NewDepartmentService(departmentRepo, agentRepo, conversationRepo, txManager, chatService)
+	widgetService := service.NewWidgetService(widgetTokenRepo, widgetSessionRepo, agentRepo, txManager, chatService)
+	transcriptService := service.NewTranscriptService(conversationRepo, messageRepo, taskRepo, agentRepo, userRepo, attachmentService)
+	secretService := service.NewSecretService(officeSecretRepo, agentRepo, taskRepo, secretCipher, auditService)
+	webResearchService := service.NewWebResearchService(webResearchRepo, webSearchCache, taskRepo, creditService, subscriptionService, service.WebResearchConfig{
+		SearchCredits:      cfg.WebResearchSearchCredits,
+		DefaultMaxSearches: cfg.WebResearchMaxSearches,
+		CacheTTL:           cfg.WebResearchCacheTTL,
+	})
+	privacyService := service.NewPrivacyService(dataExportRepo, userRepo, officeRepo, conversationRepo, taskRepo, creditRepo, transcriptService, storage, mailService, auditService, cfg.AppURL)
+	documentService := service.NewDocumentService(documentRepo, taskRepo, eventBus)
+	favoriteService := service.NewFavoriteService(templateFavoriteRepo, marketplaceRepo, notificationService)
+	marketplaceService := service.NewMarketplaceService(marketplaceRepo, authorProfileRepo, templateVersionRepo, templateBundleRepo, txManager, contentModerationService, favoriteService, service.TemplateReviewConfig{
+		AutoApprove:   cfg.TemplateAutoApprove,
+		RiskThreshold: cfg.TemplateRiskThreshold,
+	})
+	learningStatsService := service.NewLearningStatsService(learningStatsRepo, agentRepo)
+	memoryService := service.NewMemoryService(memoryRepo, agentRepo, learningStatsService, embedder)
+	memoryConsolidationService := service.NewMemoryConsolidationService(memoryRepo, learningStatsRepo, learningStatsService, txManager, service.MemoryConsolidationConfig{
+		HalfLife:            cfg.MemoryDecayHalfLife,
+		MaxPerAgent:         cfg.MemoryMaxPerAgent,
+		DuplicateSimilarity: cfg.MemoryDuplicateSimilarity,
+	})
+	feedbackService := service.NewFeedbackService(feedbackRepo, agentRepo, memoryService, learningStatsService, cfg.OrchestratorURL)
+	earningsService := service.NewEarningsService(earningsRepo, marketplaceRepo, purchaseRepo, templateBundleRepo, refundRequestRepo, creditRepo, idempotencyRepo, txManager, billing, notificationService, auditService, cfg.MarketplaceCreditsPerDollar, subscriptionService)
+	moderationService := service.NewModerationService(marketplaceRepo, templateVersionRepo, officeRepo, txManager, notificationService, favoriteService)
+	authorService := service.NewAuthorService(authorProfileRepo, marketplaceRepo, templateBundleRepo, contentModerationService, notificationService)
+	scheduleService := service.NewScheduleService(scheduleRepo, agentRepo, conversationRepo, taskService)
+	taskTemplateService := service.NewTaskTemplateService(taskTemplateRepo, scheduleRepo, agentRepo, conversationRepo, taskService, scheduleService)
+	projectService := service.NewProjectService(projectRepo, taskRepo, eventBus)
+	searchService := service.NewSearchService(searchRepo)
+	summaryService := service.NewSummaryService(conversationSummaryRepo, conversationRepo, messageRepo, agentRepo, userRepo, taskService, creditService, eventBus, service.SummaryConfig{
+		CacheWindow:  cfg.SummaryCacheWindow,
+		AutoMessages: cfg.AutoSummarizeMessages,
+	})
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, subscriptionService)
+	webhookService := service.NewWebhookService(webhookRepo, webhookDeliveryRepo, subscriptionService)
+	oauthService := service.NewOAuthService(oauthProviders(cfg), oauthIdentityRepo, userRepo, authService, cfg.PublicURL)
+	officeService := service.NewOfficeService(officeRepo, auditService)
+	officeArchiveService := service.NewOfficeArchiveService(officeService, officeRepo, agentRepo, agentTemplateRepo, agentSkillRepo, memoryRepo, embedder,
+		conversationRepo, messageRepo, modelPolicyRepo, webResearchRepo, txManager, agentService, subscriptionService, contentModerationService, auditService)
+	rateLimitService := service.NewRateLimitService(rateLimiter, subscriptionService)
+	modelPolicyService := service.NewModelPolicyService(modelPolicyRepo, agentRepo, subscriptionService)
+	billingService := service.NewBillingService(invoiceRepo, subscriptionRepo, creditRepo, promoRepo, txManager, subscriptionService, billing)
+	retentionService := service.NewRetentionService(retentionRepo, subscriptionService, storage, service.RetentionConfig{
+		DryRun: cfg.RetentionDryRun,
+		Exempt: retentionExempt,
+	})
+	adminService := service.NewAdminService(adminRepo, officeRepo, userRepo, creditRepo, subscriptionService, earningsService, promoService, authorService, auditService)
+
+	// Start background workers
+	go learningStatsService.Run(workerCtx)
+	go taskService.Run(workerCtx)
+	go scheduleService.Run(workerCtx)
+	go mailService.Run(workerCtx)
+	go subscriptionService.Run(workerCtx)
+	go subscriptionService.WatchTiers(workerCtx)
+	go retentionService.Run(workerCtx)
+	go billingService.Run(workerCtx)
+	go autoTopUpService.Run(workerCtx)
+	go webhookDispatcher.Run(workerCtx)
+	go templateViewService.Run(workerCtx)
+	go privacyService.Run(workerCtx)
+	go webResearchService.Run(workerCtx)
+	go memoryConsolidationService.Run(workerCtx)
+	go knowledgeService.Run(workerCtx)
+	go summaryService.Run(workerCtx)
 
 	// Initialize handlers
+	wsHandler := api.NewWSHandler(authService, notificationService, widgetService, eventBus)
 	authHandler := api.NewAuthHandler(authService)
 	agentHandler := api.NewAgentHandler(agentService)
 	chatHandler := api.NewChatHandler(chatService)
-	wsHandler := api.NewWSHandler(authService)
+	attachmentHandler := api.NewAttachmentHandler(attachmentService)
+	transcriptHandler := api.NewTranscriptHandler(transcriptService)
+	exportHandler := api.NewExportHandler(exportService)
+	performanceHandler := api.NewPerformanceHandler(performanceService)
+	templateViewHandler := api.NewTemplateViewHandler(templateViewService)
+	documentHandler := api.NewDocumentHandler(documentService)
+	notificationHandler := api.NewNotificationHandler(notificationService)
 	marketplaceHandler := api.NewMarketplaceHandler(marketplaceService)
 	feedbackHandler := api.NewFeedbackHandler(feedbackService)
-	internalHandler := api.NewInternalHandler(wsHandler, conversationRepo, creditService)
-	creditHandler := api.NewCreditHandler(creditService)
-	subscriptionHandler := api.NewSubscriptionHandler(subscriptionService)
+	internalHandler := api.NewInternalHandler(eventBus, conversationRepo, creditService, learningStatsService, chatService, documentService, notificationService, taskService, summaryService, webhookDispatcher)
+	creditHandler := api.NewCreditHandler(creditService, costEstimateService, promoService, autoTopUpService)
+	subscriptionHandler := api.NewSubscriptionHandler(subscriptionService, cfg.StripeWebhookSecret)
 	analyticsHandler := api.NewAnalyticsHandler(analyticsService)
 	earningsHandler := api.NewEarningsHandler(earningsService)
+	memoryHandler := api.NewMemoryHandler(memoryService)
+	learningHandler := api.NewLearningHandler(learningStatsService)
+	adminHandler := api.NewAdminHandler(moderationService, contentModerationService, retentionService, adminService, creditService, auditService)
+	billingHandler := api.NewBillingHandler(billingService)
+	auditHandler := api.NewAuditHandler(auditService)
+	privacyHandler := api.NewPrivacyHandler(privacyService)
+	secretHandler := api.NewSecretHandler(secretService)
+	webResearchHandler := api.NewWebResearchHandler(webResearchService)
+	knowledgeHandler := api.NewKnowledgeHandler(knowledgeService)
+	skillHandler := api.NewSkillHandler(skillService)
+	onboardingHandler := api.NewOnboardingHandler(onboardingService)
+	departmentHandler := api.NewDepartmentHandler(departmentService)
+	taskTemplateHandler := api.NewTaskTemplateHandler(taskTemplateService)
+	projectHandler := api.NewProjectHandler(projectService)
+	searchHandler := api.NewSearchHandler(searchService)
+	summaryHandler := api.NewSummaryHandler(summaryService)
+	authorHandler := api.NewAuthorHandler(authorService)
+	favoriteHandler := api.NewFavoriteHandler(favoriteService)
+	taskHandler := api.NewTaskHandler(taskService)
+	scheduleHandler := api.NewScheduleHandler(scheduleService)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
+	webhookHandler := api.NewWebhookHandler(webhookService)
+	widgetHandler := api.NewWidgetHandler(widgetService)
+	officeHandler := api.NewOfficeHandler(officeService, officeArchiveService)
+	modelPolicyHandler := api.NewModelPolicyHandler(modelPolicyService)
+	oauthHandler := api.NewOAuthHandler(oauthService, cfg.AppURL, cfg.Environment == "production")
 
 	router := api.NewRouter(
 		authHandler,
@@ -82,21 +470,106 @@ func main() {
 		subscriptionHandler,
 		analyticsHandler,
 		earningsHandler,
+		memoryHandler,
+		learningHandler,
+		adminHandler,
+		taskHandler,
+		scheduleHandler,
+		apiKeyHandler,
+		webhookHandler,
+		widgetHandler,
+		oauthHandler,
+		officeHandler,
+		attachmentHandler,
+		transcriptHandler,
+		exportHandler,
+		performanceHandler,
+		templateViewHandler,
+		documentHandler,
+		notificationHandler,
+		modelPolicyHandler,
+		billingHandler,
+		auditHandler,
+		privacyHandler,
+		secretHandler,
+		webResearchHandler,
+		knowledgeHandler,
+		skillHandler,
+		onboardingHandler,
+		departmentHandler,
+		taskTemplateHandler,
+		projectHandler,
+		searchHandler,
+		summaryHandler,
+		authorHandler,
+		favoriteHandler,
 		authService,
+		apiKeyService,
+		widgetService,
+		rateLimitService,
+		subscriptionService,
 		cfg.InternalAPIKey,
+		cfg.CORSOrigins,
+		cfg.Environment,
 	)
 
 	// Create Fiber app
+	// Bodies may be as large as the biggest attachment upload plus its
+	// multipart encoding; tiers set lower limits per file. Rate limits and
+	// audit entries key on c.IP(), the forwarded client address when the
+	// request comes through a trusted proxy.
 	app := fiber.New(fiber.Config{
-		AppName: "Synoffice API",
+		AppName:                 "Synoffice API",
+		ErrorHandler:            api.ErrorHandler,
+		BodyLimit:               (cfg.MaxUploadMB + 1) << 20,
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	// Setup routes
 	router.Setup(app)
 
 	// Start server
-	log.Printf("Starting server on port %s", cfg.BackendPort)
-	if err := app.Listen(":" + cfg.BackendPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	go func() {
+		log.Printf("Starting server on port %s", cfg.BackendPort)
+		if err := app.Listen(":" + cfg.BackendPort); err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for a termination signal, then drain before exiting
+	<-shutdownCtx.Done()
+	log.Println("Shutting down server...")
+
+	wsHandler.Shutdown()
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		log.Printf("Server shutdown did not complete: %v", err)
+	}
+
+	// Requests have drained; tasks still waiting on the orchestrator will
+	// not be finished by this instance
+	requeueCtx, cancelRequeue := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelRequeue()
+	if n, err := taskService.RequeueInFlight(requeueCtx); err != nil {
+		log.Printf("Failed to requeue in-flight tasks: %v", err)
+	} else if n > 0 {
+		log.Printf("Requeued %d in-flight tasks", n)
+	}
+
+	stopWorkers()
+	log.Println("Server stopped")
+}
+
+// oauthProviders returns the OAuth providers that have credentials configured
+func oauthProviders(cfg *config.Config) []domain.OAuthProvider {
+	var providers []domain.OAuthProvider
+	if cfg.GoogleClientID != "" {
+		providers = append(providers, transport.NewGoogleOAuthProvider(cfg.GoogleClientID, cfg.GoogleClientSecret))
+	}
+	if cfg.GitHubClientID != "" {
+		providers = append(providers, transport.NewGitHubOAuthProvider(cfg.GitHubClientID, cfg.GitHubClientSecret))
 	}
+	return providers
 }
@@ -0,0 +1,139 @@
+// Package openapi builds OpenAPI 3 documents by hand. Operations are declared
+// in code and their request and response schemas are derived from Go types,
+// including the constraints in their `validate` struct tags.
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// errorBody is the schema of the default (error) response of every operation
+	errorBody *Schema
+	// schemaTypes records the Go type behind each component schema name
+	schemaTypes map[string]reflect.Type
+	// schemaNames is the inverse of schemaTypes
+	schemaNames map[reflect.Type]string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on one path, keyed by lower-case method
+type PathItem map[string]*Operation
+
+// Components holds the reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how a client authenticates
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// New creates an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		schemaTypes: make(map[string]reflect.Type),
+		schemaNames: make(map[reflect.Type]string),
+	}
+}
+
+// AddSecurityScheme declares a security scheme operations can require
+func (d *Document) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	d.Components.SecuritySchemes[name] = scheme
+}
+
+// SetErrorBody sets the body of the default response added to every
+// operation, e.g. the API's error envelope
+func (d *Document) SetErrorBody(body any) {
+	d.errorBody = d.SchemaOf(body)
+}
+
+// fiberParam matches route parameters such as :id
+var fiberParam = regexp.MustCompile(`:(\w+)`)
+
+// Add documents an operation. Path is written in fiber syntax (/agents/:id);
+// its parameters are documented automatically as strings, and as UUIDs when
+// named id or ending in Id. Call SetErrorBody first; operations added before
+// it get no default response.
+func (d *Document) Add(method, path string, op *Operation) {
+	var params []Parameter
+	for _, m := range fiberParam.FindAllStringSubmatch(path, -1) {
+		schema := &Schema{Type: "string"}
+		if m[1] == "id" || strings.HasSuffix(m[1], "Id") {
+			schema.Format = "uuid"
+		}
+		params = append(params, Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	op.Parameters = append(params, op.Parameters...)
+
+	if op.body != nil {
+		if op.RequestBody == nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+		}
+		op.RequestBody.Content["application/json"] = MediaType{Schema: d.SchemaOf(op.body)}
+	}
+	op.Responses = make(map[string]*Response, len(op.responses)+1)
+	for _, r := range op.responses {
+		response := &Response{Description: r.description}
+		if r.body != nil {
+			response.Content = jsonContent(d.SchemaOf(r.body))
+		}
+		op.Responses[r.status] = response
+	}
+	if d.errorBody != nil {
+		op.Responses["default"] = &Response{Description: "Error", Content: jsonContent(d.errorBody)}
+	}
+
+	path = fiberParam.ReplaceAllString(path, "{$1}")
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Has reports whether an operation is documented for a route in fiber syntax
+func (d *Document) Has(method, path string) bool {
+	item, ok := d.Paths[fiberParam.ReplaceAllString(path, "{$1}")]
+	if !ok {
+		return false
+	}
+	_, ok = (*item)[strings.ToLower(method)]
+	return ok
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
@@ -0,0 +1,109 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Operation is a single API operation
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	body      any
+	responses []response
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an operation's request body
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one of an operation's responses
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// response is a response declared with Returns, converted by Document.Add
+type response struct {
+	status      string
+	description string
+	body        any
+}
+
+// Op starts an operation. The ID should be stable, since generated SDKs name
+// their methods after it.
+func Op(id, tag, summary string) *Operation {
+	return &Operation{OperationID: id, Tags: []string{tag}, Summary: summary}
+}
+
+// Describe sets the operation's longer description
+func (o *Operation) Describe(description string) *Operation {
+	o.Description = description
+	return o
+}
+
+// Secured requires the named security scheme
+func (o *Operation) Secured(scheme string) *Operation {
+	o.Security = append(o.Security, map[string][]string{scheme: {}})
+	return o
+}
+
+// Body sets the JSON request body; body is a value of the request type
+func (o *Operation) Body(body any) *Operation {
+	o.body = body
+	return o
+}
+
+// FileUpload sets a multipart/form-data request body holding one file
+// in field. With Body too, the operation takes either.
+func (o *Operation) FileUpload(field, description string) *Operation {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{field: {Type: "string", Format: "binary", Description: description}},
+		Required:   []string{field},
+	}
+	o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"multipart/form-data": {Schema: schema}}}
+	return o
+}
+
+// Query documents a query parameter of the given schema type
+func (o *Operation) Query(name, typ, description string) *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}})
+	return o
+}
+
+// Header documents an optional request header
+func (o *Operation) Header(name, description string) *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: "string"}})
+	return o
+}
+
+// Returns documents a response; body is a value of the response type, or nil
+// for responses without a body
+func (o *Operation) Returns(status int, body any) *Operation {
+	o.responses = append(o.responses, response{status: strconv.Itoa(status), description: http.StatusText(status), body: body})
+	return o
+}
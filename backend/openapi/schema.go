@@ -0,0 +1,249 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+}
+
+// Fields describes an ad-hoc JSON object, such as a fiber.Map response, by
+// mapping each property to a value of its type
+type Fields map[string]any
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf returns the schema of v's type. Named struct types are added to
+// the document's components and referenced; Fields become inline objects.
+func (d *Document) SchemaOf(v any) *Schema {
+	if fields, ok := v.(Fields); ok {
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema, len(fields))}
+		for name, value := range fields {
+			schema.Properties[name] = d.SchemaOf(value)
+		}
+		return schema
+	}
+	if v == nil {
+		return &Schema{}
+	}
+	return d.schemaFor(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := d.schemaFor(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.ref(t)
+	}
+	// Interfaces accept any JSON value
+	return &Schema{}
+}
+
+// ref registers a named struct type as a component schema and references it
+func (d *Document) ref(t reflect.Type) *Schema {
+	name, ok := d.schemaNames[t]
+	if !ok {
+		name = d.schemaName(t)
+		d.schemaNames[t] = name
+		d.schemaTypes[name] = t
+		// Registered before the fields are walked so recursive types terminate
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaName names a component schema after its Go type. Generic types are
+// named after their type arguments, so Page[*domain.Message] becomes
+// MessagePage; names taken by another package's type get a package prefix.
+func (d *Document) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = ""
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			arg = arg[strings.LastIndex(arg, ".")+1:]
+			name += strings.TrimLeft(arg, "*[]")
+		}
+		name += base
+	}
+
+	if _, taken := d.schemaTypes[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+// structSchema describes a struct's JSON fields. Embedded structs without a
+// JSON name are flattened into the parent, as encoding/json does.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := d.structSchema(ft)
+				for k, v := range embedded.Properties {
+					schema.Properties[k] = v
+				}
+				schema.Required = append(schema.Required, embedded.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := d.schemaFor(f.Type)
+		if rules := f.Tag.Get("validate"); rules != "" {
+			if applyRules(field, rules) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = field
+	}
+	return schema
+}
+
+// applyRules adds the constraints of a `validate` tag to a field's schema and
+// reports whether the field is required. Rules after dive apply to the items
+// of a list; cross-field and custom rules are not expressible and skipped.
+func applyRules(schema *Schema, rules string) (required bool) {
+	target := schema
+	for _, rule := range strings.Split(rules, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = target == schema
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+		case "email":
+			target.Format = "email"
+		case "uuid", "uuid4":
+			target.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				target.Enum = append(target.Enum, v)
+			}
+		case "min", "gte":
+			setBound(target, param, true)
+		case "max", "lte":
+			setBound(target, param, false)
+		case "gt":
+			setBound(target, param, true)
+			target.ExclusiveMinimum = true
+		}
+	}
+	return required
+}
+
+// setBound sets a lower or upper bound, which constrains the length of
+// strings, the size of lists and the value of numbers. Bounds on map sizes
+// are not documented.
+func setBound(schema *Schema, param string, lower bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	length := int(n)
+
+	switch schema.Type {
+	case "string":
+		if lower {
+			schema.MinLength = &length
+		} else {
+			schema.MaxLength = &length
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &length
+		} else {
+			schema.MaxItems = &length
+		}
+	case "integer", "number":
+		if lower {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package pdf writes simple text documents as PDF by hand. Text is set in
+// the standard Helvetica fonts, which every PDF reader provides, so no fonts
+// are embedded; characters outside the Windows-1252 character set are
+// replaced with question marks.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4 page size and margins, in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+	// lineSpacing is the line height as a multiple of the font size
+	lineSpacing = 1.35
+)
+
+// Style selects how a paragraph is set
+type Style struct {
+	Size float64
+	Bold bool
+	// Gray is the text's gray level, from 0 (black) to 1 (white)
+	Gray float64
+	// Indent moves the paragraph right, in points
+	Indent float64
+}
+
+// Common styles
+var (
+	Title   = Style{Size: 18, Bold: true}
+	Heading = Style{Size: 11, Bold: true}
+	Body    = Style{Size: 10}
+	Small   = Style{Size: 8, Gray: 0.4}
+)
+
+// Document is a PDF being built, a paragraph at a time
+type Document struct {
+	title   string
+	created time.Time
+	pages   []*bytes.Buffer
+	// y is the baseline of the next line on the current page, from the
+	// bottom
+	y float64
+}
+
+// New creates an empty document with a title for its metadata
+func New(title string) *Document {
+	return &Document{title: title, created: time.Now()}
+}
+
+// Paragraph adds text, wrapped to the page width. Newlines in text start
+// new lines.
+func (d *Document) Paragraph(text string, style Style) {
+	width := pageWidth - 2*margin - style.Indent
+	for _, line := range strings.Split(text, "\n") {
+		for _, wrapped := range wrap(encode(line), style, width) {
+			d.line(wrapped, style)
+		}
+	}
+}
+
+// Space adds vertical space, in points
+func (d *Document) Space(height float64) {
+	if len(d.pages) > 0 {
+		d.y -= height
+	}
+}
+
+// line sets one already wrapped line, starting a page when it is full
+func (d *Document) line(text []byte, style Style) {
+	height := style.Size * lineSpacing
+	if len(d.pages) == 0 || d.y-height < margin {
+		d.pages = append(d.pages, &bytes.Buffer{})
+		d.y = pageHeight - margin
+	}
+	d.y -= height
+
+	font := "F1"
+	if style.Bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f g %.2f %.2f Td (%s) Tj ET\n",
+		font, style.Size, style.Gray, margin+style.Indent, d.y, escape(text))
+}
+
+// WriteTo writes the document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.pages = append(d.pages, &bytes.Buffer{})
+	}
+
+	out := &countingWriter{w: w}
+	var offsets []int64
+	object := func(body string) {
+		offsets = append(offsets, out.n)
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	fmt.Fprint(out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 4 are fixed; each page then takes a page object and its
+	// content stream
+	const firstPage = 5
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(content.Bytes())
+		zw.Close()
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	info := len(offsets) + 1
+	object(fmt.Sprintf("<< /Title %s /Producer (Synoffice) /CreationDate (D:%s) >>",
+		textString(d.title), d.created.UTC().Format("20060102150405Z")))
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+	return out.n, out.err
+}
+
+// wrap breaks text into lines no wider than width, between words where it
+// can
+func wrap(text []byte, style Style, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	lineWidth := 0.0
+	for _, word := range bytes.SplitAfter(text, []byte(" ")) {
+		wordWidth := textWidth(word, style)
+		if lineWidth+textWidth(bytes.TrimRight(word, " "), style) > width && len(line) > 0 {
+			lines = append(lines, bytes.TrimRight(line, " "))
+			line, lineWidth = nil, 0
+		}
+		// Words longer than a line are broken anywhere
+		for textWidth(bytes.TrimRight(word, " "), style) > width {
+			n := fitting(word, style, width)
+			lines = append(lines, word[:n])
+			word = word[n:]
+			wordWidth = textWidth(word, style)
+		}
+		line = append(line, word...)
+		lineWidth += wordWidth
+	}
+	return append(lines, bytes.TrimRight(line, " "))
+}
+
+// fitting returns how many leading bytes of text fit in width, at least one
+func fitting(text []byte, style Style, width float64) int {
+	total := 0.0
+	for i, c := range text {
+		total += charWidth(c, style)
+		if total > width {
+			return max(i, 1)
+		}
+	}
+	return len(text)
+}
+
+func textWidth(text []byte, style Style) float64 {
+	total := 0.0
+	for _, c := range text {
+		total += charWidth(c, style)
+	}
+	return total
+}
+
+// charWidth is a character's advance width in points
+func charWidth(c byte, style Style) float64 {
+	widths := &helveticaWidths
+	if style.Bold {
+		widths = &helveticaBoldWidths
+	}
+	w := 556 // close to the average of the characters without a table entry
+	if c >= 32 && c < 127 {
+		w = widths[c-32]
+	}
+	return float64(w) * style.Size / 1000
+}
+
+// escape quotes a string for a PDF literal string
+func escape(text []byte) []byte {
+	var b bytes.Buffer
+	for _, c := range text {
+		if c == '\\' || c == '(' || c == ')' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.Bytes()
+}
+
+// textString encodes metadata text as UTF-16, which readers show in full
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// encode converts text to Windows-1252, the fonts' encoding. Tabs become
+// spaces and other control characters are dropped.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b = append(b, "    "...)
+		case r < 32 || r == 127:
+		case r < 128 || (r >= 0xA0 && r <= 0xFF):
+			b = append(b, byte(r))
+		default:
+			if c, ok := winAnsi[r]; ok {
+				b = append(b, c)
+			} else {
+				b = append(b, '?')
+			}
+		}
+	}
+	return b
+}
+
+// winAnsi maps the characters Windows-1252 places in 0x80-0x9F
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// Advance widths of the printable ASCII characters, in thousandths of the
+// font size, from the fonts' Adobe metrics
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// countingWriter tracks the offset objects are written at and keeps the
+// first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
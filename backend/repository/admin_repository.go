@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminRepository implements domain.AdminRepository
+type AdminRepository struct {
+	db conn
+}
+
+// NewAdminRepository creates a new AdminRepository
+func NewAdminRepository(db *pgxpool.Pool) *AdminRepository {
+	return &AdminRepository{db: conn{db}}
+}
+
+// SearchUsers returns a page of users whose email or name contains query,
+// newest first, and how many match. Closed accounts are left out.
+func (r *AdminRepository) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, int, error) {
+	q := &queryBuilder{}
+	q.where("deleted_at IS NULL")
+	if query != "" {
+		search := q.arg("%" + query + "%")
+		q.where("(email ILIKE " + search + " OR name ILIKE " + search + ")")
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM users`+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sql := `SELECT id, email, name, role, email_verified_at, created_at, updated_at FROM users` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	rows, err := r.db.Query(ctx, sql, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(
+			&user.ID, &user.Email, &user.Name, &user.Role, &user.EmailVerifiedAt,
+			&user.CreatedAt, &user.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, &user)
+	}
+	return users, total, rows.Err()
+}
+
+// SearchOffices returns a page of offices whose name or owner's email
+// contains query, newest first, and how many match
+func (r *AdminRepository) SearchOffices(ctx context.Context, query string, limit, offset int) ([]*domain.AdminOffice, int, error) {
+	q := &queryBuilder{}
+	if query != "" {
+		search := q.arg("%" + query + "%")
+		q.where("(o.name ILIKE " + search + " OR u.email ILIKE " + search + ")")
+	}
+
+	from := ` FROM offices o
+		JOIN users u ON u.id = o.user_id
+		LEFT JOIN subscriptions s ON s.office_id = o.id
+		LEFT JOIN credit_wallets w ON w.office_id = o.id`
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sql := `SELECT o.id, o.user_id, o.name, o.timezone, o.created_at, o.updated_at, u.email,
+			COALESCE(s.tier, ''), COALESCE(s.status, ''), COALESCE(w.balance, 0)` + from + q.whereClause() + `
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	rows, err := r.db.Query(ctx, sql, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var offices []*domain.AdminOffice
+	for rows.Next() {
+		var office domain.AdminOffice
+		if err := rows.Scan(
+			&office.ID, &office.UserID, &office.Name, &office.Timezone, &office.CreatedAt, &office.UpdatedAt, &office.OwnerEmail,
+			&office.Tier, &office.SubscriptionStatus, &office.Balance,
+		); err != nil {
+			return nil, 0, err
+		}
+		offices = append(offices, &office)
+	}
+	return offices, total, rows.Err()
+}
+
+// ListFailedTasks returns a page of failed and dead-lettered tasks of every
+// office, most recent first, and how many there are
+func (r *AdminRepository) ListFailedTasks(ctx context.Context, limit, offset int) ([]*domain.Task, int, error) {
+	statuses := []string{string(domain.TaskStatusFailed), string(domain.TaskStatusDeadLetter)}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM tasks WHERE status = ANY($1)`, statuses).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks
+		WHERE status = ANY($1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(ctx, query, statuses, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	return tasks, total, err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentChangeRepository implements domain.AgentChangeRepository
+type AgentChangeRepository struct {
+	db conn
+}
+
+// NewAgentChangeRepository creates a new AgentChangeRepository
+func NewAgentChangeRepository(db *pgxpool.Pool) *AgentChangeRepository {
+	return &AgentChangeRepository{db: conn{db}}
+}
+
+// Create records changes made to an agent in one round trip
+func (r *AgentChangeRepository) Create(ctx context.Context, changes []*domain.AgentChange) error {
+	query := `
+		INSERT INTO agent_changes (id, agent_id, office_id, changed_by, field, old_value, new_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	batch := &pgx.Batch{}
+	for _, c := range changes {
+		batch.Queue(query, c.ID, c.AgentID, c.OfficeID, c.ChangedBy, c.Field, c.OldValue, c.NewValue, c.CreatedAt)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// GetByAgentID returns an agent's changes, newest first
+func (r *AgentChangeRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID, limit int) ([]*domain.AgentChange, error) {
+	query := `
+		SELECT id, agent_id, office_id, changed_by, field, old_value, new_value, created_at
+		FROM agent_changes WHERE agent_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*domain.AgentChange
+	for rows.Next() {
+		var c domain.AgentChange
+		if err := rows.Scan(&c.ID, &c.AgentID, &c.OfficeID, &c.ChangedBy, &c.Field, &c.OldValue, &c.NewValue, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,101 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestAgentMemoryConsolidation(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentMemoryRepository(testDB.Pool)
+	stats := repository.NewLearningStatsRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	agent := newAgent(t, office, testDB.Template(t, testDB.User(t)), "1.0.0", time.Now())
+
+	newMemory := func(key string, importance float64) *domain.AgentMemory {
+		memory := &domain.AgentMemory{
+			ID: uuid.New(), OfficeID: office, AgentID: agent.ID, Key: key, Value: "value of " + key,
+			MemoryType: domain.MemoryTypeFact, ImportanceScore: importance, Source: domain.MemorySourceUser,
+			Metadata: map[string]any{}, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		}
+		if err := repo.Create(ctx, memory); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return memory
+	}
+	tone := newMemory("Client Tone", 0.8)
+	toneAgain := newMemory("client_tone", 0.3)
+	deadline := newMemory("deadline", 0.6)
+	similar := newMemory("report day", 0.2)
+	if err := repo.SetEmbedding(ctx, deadline.ID, unitEmbedding(1), ""); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+	if err := repo.SetEmbedding(ctx, similar.ID, unitEmbedding(1), ""); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+
+	due, err := repo.GetConsolidationDue(ctx, time.Now(), 1000)
+	if err != nil || !slices.Contains(due, agent.ID) {
+		t.Fatalf("GetConsolidationDue = %v, %v; want the agent", due, err)
+	}
+	pairs, err := repo.FindDuplicates(ctx, agent.ID, 0.95)
+	if err != nil || len(pairs) != 2 {
+		t.Fatalf("FindDuplicates = %v, %v; want the tone keys and the deadline embeddings", pairs, err)
+	}
+
+	now := time.Now()
+	if err := repo.Merge(ctx, toneAgain.ID, 0.8, []uuid.UUID{tone.ID}, now); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, tone.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID merged memory error = %v, want ErrNotFound", err)
+	}
+	if survivor, err := repo.GetByID(ctx, toneAgain.ID); err != nil || survivor.ImportanceScore != 0.8 {
+		t.Errorf("GetByID survivor = %+v, %v; want importance 0.8", survivor, err)
+	}
+	if archived, err := repo.ArchiveOverCap(ctx, agent.ID, 2, now); err != nil || archived != 1 {
+		t.Errorf("ArchiveOverCap = %d, %v; want the least important memory archived", archived, err)
+	}
+	if err := stats.RecordConsolidation(ctx, agent.ID, now); err != nil {
+		t.Fatalf("RecordConsolidation: %v", err)
+	}
+	if err := stats.Refresh(ctx, agent.ID); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	got, err := stats.GetByAgentID(ctx, agent.ID)
+	if err != nil || got.FactCount != 2 || got.MergedMemoryCount != 1 || got.ArchivedMemoryCount != 1 || got.LastConsolidatedAt == nil {
+		t.Fatalf("GetByAgentID = %+v, %v; want 2 facts, 1 merged, 1 archived", got, err)
+	}
+
+	// Decay halves importance every half-life since the last update; a
+	// second run at the same time decays nothing more
+	later := time.Now().Add(48 * time.Hour)
+	if _, err := repo.DecayImportance(ctx, 48*time.Hour, later); err != nil {
+		t.Fatalf("DecayImportance: %v", err)
+	}
+	if decayed, err := repo.DecayImportance(ctx, 48*time.Hour, later); err != nil || decayed != 0 {
+		t.Errorf("DecayImportance again = %d, %v; want no memory decayed twice", decayed, err)
+	}
+	if survivor, err := repo.GetByID(ctx, toneAgain.ID); err != nil || survivor.ImportanceScore != 0.4 {
+		t.Errorf("GetByID decayed survivor = %+v, %v; want importance 0.4", survivor, err)
+	}
+
+	// Creating a memory with an archived memory's key restores it
+	recreated := &domain.AgentMemory{
+		ID: uuid.New(), OfficeID: office, AgentID: agent.ID, Key: similar.Key, Value: "Reports are due on Mondays",
+		MemoryType: domain.MemoryTypeFact, ImportanceScore: 0.5, Source: domain.MemorySourceUser,
+		Metadata: map[string]any{}, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if err := repo.Create(ctx, recreated); err != nil || recreated.ID != similar.ID {
+		t.Fatalf("Create over an archived memory = %s, %v; want its ID restored", recreated.ID, err)
+	}
+}
@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentMemoryRepository implements domain.AgentMemoryRepository
+type AgentMemoryRepository struct {
+	db conn
+}
+
+// NewAgentMemoryRepository creates a new AgentMemoryRepository
+func NewAgentMemoryRepository(db *pgxpool.Pool) *AgentMemoryRepository {
+	return &AgentMemoryRepository{db: conn{db}}
+}
+
+// unarchiveMemory restores an archived memory as it is recreated, its importance decaying anew
+const unarchiveMemory = `archived_at = NULL, archive_reason = NULL, merged_into = NULL, importance_decayed_at = NULL`
+
+const agentMemoryColumns = `
+	id, office_id, agent_id, key, value, COALESCE(vector_id, ''),
+	COALESCE(memory_type, 'fact'), COALESCE(importance_score, 0.5),
+	COALESCE(source, 'system'), source_id, COALESCE(metadata, '{}'::jsonb),
+	created_at, updated_at
+`
+
+// Create inserts a new memory, returning domain.ErrAlreadyExists if the agent already has the key.
+// An archived memory with the key is replaced, keeping its ID.
+func (r *AgentMemoryRepository) Create(ctx context.Context, memory *domain.AgentMemory) error {
+	query := `
+		INSERT INTO agent_memories (id, office_id, agent_id, key, value, vector_id, memory_type, importance_score, source, source_id, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (agent_id, key) DO UPDATE SET
+			value = EXCLUDED.value,
+			vector_id = EXCLUDED.vector_id,
+			embedding = NULL,
+			memory_type = EXCLUDED.memory_type,
+			importance_score = EXCLUDED.importance_score,
+			source = EXCLUDED.source,
+			source_id = EXCLUDED.source_id,
+			metadata = EXCLUDED.metadata,
+			created_at = EXCLUDED.created_at,
+			` + unarchiveMemory + `
+		WHERE agent_memories.archived_at IS NOT NULL
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		memory.ID,
+		memory.OfficeID,
+		memory.AgentID,
+		memory.Key,
+		memory.Value,
+		nullableString(memory.VectorID),
+		memory.MemoryType,
+		memory.ImportanceScore,
+		memory.Source,
+		memory.SourceID,
+		memory.Metadata,
+		memory.CreatedAt,
+		memory.UpdatedAt,
+	).Scan(&memory.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID retrieves a memory by ID
+func (r *AgentMemoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AgentMemory, error) {
+	query := `SELECT ` + agentMemoryColumns + ` FROM agent_memories WHERE id = $1 AND archived_at IS NULL`
+	return r.scanMemory(r.db.QueryRow(ctx, query, id))
+}
+
+// GetByAgentID retrieves all memories for an agent, most important first
+func (r *AgentMemoryRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID) ([]*domain.AgentMemory, error) {
+	query := `
+		SELECT ` + agentMemoryColumns + `
+		FROM agent_memories
+		WHERE agent_id = $1 AND archived_at IS NULL
+		ORDER BY importance_score DESC, updated_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []*domain.AgentMemory
+	for rows.Next() {
+		m, err := r.scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, m)
+	}
+	return memories, rows.Err()
+}
+
+// GetByKey retrieves an agent's memory by key
+func (r *AgentMemoryRepository) GetByKey(ctx context.Context, agentID uuid.UUID, key string) (*domain.AgentMemory, error) {
+	query := `SELECT ` + agentMemoryColumns + ` FROM agent_memories WHERE agent_id = $1 AND key = $2 AND archived_at IS NULL`
+	return r.scanMemory(r.db.QueryRow(ctx, query, agentID, key))
+}
+
+// Upsert inserts a memory or replaces the value of the agent's existing memory with the same key,
+// dropping its embedding if the value changed. An archived memory is restored.
+func (r *AgentMemoryRepository) Upsert(ctx context.Context, memory *domain.AgentMemory) error {
+	query := `
+		INSERT INTO agent_memories (id, office_id, agent_id, key, value, vector_id, memory_type, importance_score, source, source_id, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (agent_id, key) DO UPDATE SET
+			value = EXCLUDED.value,
+			vector_id = COALESCE(EXCLUDED.vector_id, agent_memories.vector_id),
+			embedding = CASE WHEN agent_memories.value = EXCLUDED.value THEN agent_memories.embedding END,
+			memory_type = EXCLUDED.memory_type,
+			importance_score = EXCLUDED.importance_score,
+			source = EXCLUDED.source,
+			source_id = EXCLUDED.source_id,
+			metadata = EXCLUDED.metadata,
+			` + unarchiveMemory + `
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(ctx, query,
+		memory.ID,
+		memory.OfficeID,
+		memory.AgentID,
+		memory.Key,
+		memory.Value,
+		nullableString(memory.VectorID),
+		memory.MemoryType,
+		memory.ImportanceScore,
+		memory.Source,
+		memory.SourceID,
+		memory.Metadata,
+		memory.CreatedAt,
+		memory.UpdatedAt,
+	).Scan(&memory.ID, &memory.CreatedAt, &memory.UpdatedAt)
+}
+
+// Update modifies the editable fields of an existing memory, dropping its embedding if the key or
+// value changed, or returns domain.ErrAlreadyExists if renamed to a key the agent already has. Its
+// importance decays from now on.
+func (r *AgentMemoryRepository) Update(ctx context.Context, memory *domain.AgentMemory) error {
+	query := `
+		UPDATE agent_memories
+		SET key = $2, value = $3, memory_type = $4, importance_score = $5, metadata = $6,
+			embedding = CASE WHEN key = $2 AND value = $3 THEN embedding END,
+			importance_decayed_at = NULL
+		WHERE id = $1 AND archived_at IS NULL
+		RETURNING updated_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		memory.ID,
+		memory.Key,
+		memory.Value,
+		memory.MemoryType,
+		memory.ImportanceScore,
+		memory.Metadata,
+	).Scan(&memory.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// Delete removes a memory
+func (r *AgentMemoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM agent_memories WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SetEmbedding stores a memory's embedding and, if given, the ID of its vector elsewhere
+func (r *AgentMemoryRepository) SetEmbedding(ctx context.Context, id uuid.UUID, embedding []float32, vectorID string) error {
+	query := `
+		UPDATE agent_memories
+		SET embedding = $2::vector, vector_id = COALESCE($3, vector_id)
+		WHERE id = $1
+	`
+	result, err := r.db.Exec(ctx, query, id, vectorLiteral(embedding), nullableString(vectorID))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SearchByEmbedding returns the agent's embedded memories by cosine similarity to the embedding
+func (r *AgentMemoryRepository) SearchByEmbedding(ctx context.Context, agentID uuid.UUID, embedding []float32, limit int) ([]*domain.MemoryMatch, error) {
+	query := `
+		SELECT ` + agentMemoryColumns + `, 1 - (embedding <=> $2::vector)
+		FROM agent_memories
+		WHERE agent_id = $1 AND embedding IS NOT NULL AND archived_at IS NULL
+		ORDER BY embedding <=> $2::vector
+		LIMIT $3
+	`
+	rows, err := r.db.Query(ctx, query, agentID, vectorLiteral(embedding), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*domain.MemoryMatch
+	for rows.Next() {
+		var m domain.AgentMemory
+		match := &domain.MemoryMatch{AgentMemory: &m}
+		if err := rows.Scan(
+			&m.ID, &m.OfficeID, &m.AgentID, &m.Key, &m.Value, &m.VectorID,
+			&m.MemoryType, &m.ImportanceScore, &m.Source, &m.SourceID, &m.Metadata,
+			&m.CreatedAt, &m.UpdatedAt, &match.Similarity,
+		); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// SearchByText returns the agent's memories whose key or value contains the query, ignoring case
+func (r *AgentMemoryRepository) SearchByText(ctx context.Context, agentID uuid.UUID, query string, limit int) ([]*domain.MemoryMatch, error) {
+	sql := `
+		SELECT ` + agentMemoryColumns + `
+		FROM agent_memories
+		WHERE agent_id = $1 AND archived_at IS NULL AND (key ILIKE $2 OR value ILIKE $2)
+		ORDER BY importance_score DESC, updated_at DESC
+		LIMIT $3
+	`
+	rows, err := r.db.Query(ctx, sql, agentID, "%"+query+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*domain.MemoryMatch
+	for rows.Next() {
+		m, err := r.scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, &domain.MemoryMatch{AgentMemory: m})
+	}
+	return matches, rows.Err()
+}
+
+// GetConsolidationDue returns agents with memories that were not consolidated since before, those
+// never consolidated first
+func (r *AgentMemoryRepository) GetConsolidationDue(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT m.agent_id
+		FROM agent_memories m
+		LEFT JOIN agent_learning_stats s ON s.agent_id = m.agent_id
+		WHERE m.archived_at IS NULL AND (s.last_consolidated_at IS NULL OR s.last_consolidated_at < $1)
+		GROUP BY m.agent_id, s.last_consolidated_at
+		ORDER BY s.last_consolidated_at NULLS FIRST
+		LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agentIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		agentIDs = append(agentIDs, id)
+	}
+	return agentIDs, rows.Err()
+}
+
+// DecayImportance halves the importance of memories every halfLife since they last decayed, or were
+// last updated. A memory only decays once its score drops by a rounded hundredth, so running it often
+// or from several replicas decays no faster.
+func (r *AgentMemoryRepository) DecayImportance(ctx context.Context, halfLife time.Duration, at time.Time) (int64, error) {
+	query := `
+		UPDATE agent_memories m
+		SET importance_score = d.score, importance_decayed_at = $2
+		FROM (
+			SELECT id, ROUND((importance_score::float8 * power(0.5,
+				EXTRACT(EPOCH FROM $2::timestamptz - COALESCE(importance_decayed_at, updated_at))::float8 / $1))::numeric, 2) AS score
+			FROM agent_memories
+			WHERE archived_at IS NULL AND importance_score > 0
+		) d
+		WHERE m.id = d.id AND d.score < m.importance_score
+	`
+	result, err := r.db.Exec(ctx, query, halfLife.Seconds(), at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// FindDuplicates returns pairs of the agent's memories of a type whose keys match ignoring case and
+// punctuation, or whose embeddings are at least minSimilarity similar
+func (r *AgentMemoryRepository) FindDuplicates(ctx context.Context, agentID uuid.UUID, minSimilarity float64) ([]domain.MemoryPair, error) {
+	query := `
+		WITH active AS (
+			SELECT id, COALESCE(memory_type, 'fact') AS memory_type, embedding,
+			       NULLIF(lower(regexp_replace(key, '[^[:alnum:]]+', '', 'g')), '') AS normalized_key
+			FROM agent_memories
+			WHERE agent_id = $1 AND archived_at IS NULL
+		)
+		SELECT a.id, b.id
+		FROM active a
+		JOIN active b ON a.id < b.id AND a.memory_type = b.memory_type
+		WHERE a.normalized_key = b.normalized_key OR 1 - (a.embedding <=> b.embedding) >= $2
+	`
+	rows, err := r.db.Query(ctx, query, agentID, minSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []domain.MemoryPair
+	for rows.Next() {
+		var pair domain.MemoryPair
+		if err := rows.Scan(&pair.FirstID, &pair.SecondID); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, rows.Err()
+}
+
+// Merge archives the agent's merged memories into the survivor, raising its importance to at least
+// importance
+func (r *AgentMemoryRepository) Merge(ctx context.Context, survivorID uuid.UUID, importance float64, mergedIDs []uuid.UUID, at time.Time) error {
+	query := `
+		WITH merged AS (
+			UPDATE agent_memories
+			SET archived_at = $4, archive_reason = '` + domain.MemoryArchiveMerged + `', merged_into = $1
+			WHERE id = ANY($3) AND id <> $1 AND archived_at IS NULL
+			  AND agent_id = (SELECT agent_id FROM agent_memories WHERE id = $1)
+		)
+		UPDATE agent_memories
+		SET importance_score = GREATEST(COALESCE(importance_score, 0), $2)
+		WHERE id = $1 AND archived_at IS NULL
+	`
+	result, err := r.db.Exec(ctx, query, survivorID, importance, mergedIDs, at)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ArchiveOverCap archives the agent's least important memories past the first maxMemories, the
+// least recently updated first among equals
+func (r *AgentMemoryRepository) ArchiveOverCap(ctx context.Context, agentID uuid.UUID, maxMemories int, at time.Time) (int64, error) {
+	query := `
+		UPDATE agent_memories
+		SET archived_at = $3, archive_reason = '` + domain.MemoryArchiveCapped + `'
+		WHERE id IN (
+			SELECT id FROM agent_memories
+			WHERE agent_id = $1 AND archived_at IS NULL
+			ORDER BY importance_score DESC NULLS LAST, updated_at DESC
+			OFFSET $2
+		)
+	`
+	result, err := r.db.Exec(ctx, query, agentID, maxMemories, at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// vectorLiteral formats an embedding as pgvector's text input, e.g. [0.1,-0.2]
+func vectorLiteral(embedding []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func (r *AgentMemoryRepository) scanMemory(row pgx.Row) (*domain.AgentMemory, error) {
+	var m domain.AgentMemory
+	err := row.Scan(
+		&m.ID, &m.OfficeID, &m.AgentID, &m.Key, &m.Value, &m.VectorID,
+		&m.MemoryType, &m.ImportanceScore, &m.Source, &m.SourceID, &m.Metadata,
+		&m.CreatedAt, &m.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,75 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// unitEmbedding returns an embedding pointing along dimension i
+func unitEmbedding(i int) []float32 {
+	embedding := make([]float32, domain.MemoryEmbeddingDimensions)
+	embedding[i] = 1
+	return embedding
+}
+
+func TestAgentMemorySearchByEmbedding(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentMemoryRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	agent := newAgent(t, office, testDB.Template(t, testDB.User(t)), "1.0.0", time.Now())
+
+	newMemory := func(key, value string) *domain.AgentMemory {
+		memory := &domain.AgentMemory{
+			ID: uuid.New(), OfficeID: office, AgentID: agent.ID, Key: key, Value: value,
+			MemoryType: domain.MemoryTypeFact, ImportanceScore: 0.5, Source: domain.MemorySourceUser,
+			Metadata: map[string]any{}, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		}
+		if err := repo.Create(ctx, memory); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return memory
+	}
+	tone := newMemory("tone", "The client prefers a formal tone")
+	deadline := newMemory("deadline", "Reports are due on Fridays")
+	if err := repo.SetEmbedding(ctx, tone.ID, unitEmbedding(0), "vec-1"); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+	if err := repo.SetEmbedding(ctx, deadline.ID, unitEmbedding(1), ""); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+
+	matches, err := repo.SearchByEmbedding(ctx, agent.ID, unitEmbedding(1), 10)
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("SearchByEmbedding = %d matches, %v; want 2", len(matches), err)
+	}
+	if matches[0].ID != deadline.ID || matches[0].Similarity < 0.99 || matches[1].Similarity > 0.01 {
+		t.Errorf("SearchByEmbedding = %s %.2f, %s %.2f; want the deadline first", matches[0].Key, matches[0].Similarity, matches[1].Key, matches[1].Similarity)
+	}
+
+	// Changing a memory's value drops its stale embedding
+	deadline.Value = "Reports are due on Mondays"
+	if err := repo.Update(ctx, deadline); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if matches, err = repo.SearchByEmbedding(ctx, agent.ID, unitEmbedding(1), 10); err != nil || len(matches) != 1 || matches[0].ID != tone.ID {
+		t.Errorf("SearchByEmbedding after the update = %d matches, %v; want only the tone", len(matches), err)
+	}
+
+	if matches, err = repo.SearchByText(ctx, agent.ID, "FORMAL", 10); err != nil || len(matches) != 1 || matches[0].ID != tone.ID {
+		t.Errorf("SearchByText = %d matches, %v; want the tone", len(matches), err)
+	}
+
+	// Renaming a memory onto another's key is refused
+	deadline.Key = "tone"
+	if err := repo.Update(ctx, deadline); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Update onto an existing key error = %v, want ErrAlreadyExists", err)
+	}
+}
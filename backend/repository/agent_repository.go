@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -13,17 +14,23 @@ import (
 
 // AgentTemplateRepository implements domain.AgentTemplateRepository
 type AgentTemplateRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewAgentTemplateRepository creates a new AgentTemplateRepository
 func NewAgentTemplateRepository(db *pgxpool.Pool) *AgentTemplateRepository {
-	return &AgentTemplateRepository{db: db}
+	return &AgentTemplateRepository{db: conn{db}}
 }
 
-// GetAll returns all agent templates
+// GetAll returns all approved agent templates; submissions awaiting or
+// refused moderation are left out
 func (r *AgentTemplateRepository) GetAll(ctx context.Context) ([]*domain.AgentTemplate, error) {
-	query := `SELECT id, name, role, system_prompt, avatar_url, skill_tags, created_at FROM agent_templates ORDER BY name`
+	query := `
+		SELECT id, name, role, system_prompt, avatar_url, skill_tags, created_at
+		FROM agent_templates
+		WHERE COALESCE(status, 'approved') = 'approved'
+		ORDER BY name
+	`
 
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
@@ -56,36 +63,53 @@ func (r *AgentTemplateRepository) GetAll(ctx context.Context) ([]*domain.AgentTe
 
 // GetByID returns an agent template by ID
 func (r *AgentTemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AgentTemplate, error) {
-	query := `SELECT id, name, role, system_prompt, avatar_url, skill_tags, created_at FROM agent_templates WHERE id = $1`
-
-	var template domain.AgentTemplate
-	var skillTagsJSON []byte
-	var avatarURL *string
+	query := `SELECT ` + templateColumns + ` FROM agent_templates WHERE id = $1`
 
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&template.ID, &template.Name, &template.Role, &template.SystemPrompt, &avatarURL, &skillTagsJSON, &template.CreatedAt,
-	)
+	template, err := scanTemplate(r.db.QueryRow(ctx, query, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	return template, nil
+}
 
-	if avatarURL != nil {
-		template.AvatarURL = *avatarURL
+// GetByIDs returns the templates with the given IDs in one query, keyed by
+// ID. Unknown IDs are left out.
+func (r *AgentTemplateRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*domain.AgentTemplate, error) {
+	templates := make(map[uuid.UUID]*domain.AgentTemplate, len(ids))
+	if len(ids) == 0 {
+		return templates, nil
 	}
 
-	if err := json.Unmarshal(skillTagsJSON, &template.SkillTags); err != nil {
-		template.SkillTags = []string{}
+	query := `SELECT ` + templateColumns + ` FROM agent_templates WHERE id = ANY($1)`
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return &template, nil
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates[template.ID] = template
+	}
+	return templates, rows.Err()
 }
 
-// GetByRole returns an agent template by role
+// GetByRole returns the oldest built-in agent template with the role; marketplace templates by
+// authors are left out
 func (r *AgentTemplateRepository) GetByRole(ctx context.Context, role string) (*domain.AgentTemplate, error) {
-	query := `SELECT id, name, role, system_prompt, avatar_url, skill_tags, created_at FROM agent_templates WHERE role = $1`
+	query := `
+		SELECT id, name, role, system_prompt, avatar_url, skill_tags, created_at
+		FROM agent_templates
+		WHERE role = $1 AND author_id IS NULL
+		ORDER BY created_at, id
+		LIMIT 1
+	`
 
 	var template domain.AgentTemplate
 	var skillTagsJSON []byte
@@ -114,127 +138,200 @@ func (r *AgentTemplateRepository) GetByRole(ctx context.Context, role string) (*
 
 // AgentRepository implements domain.AgentRepository
 type AgentRepository struct {
-	db           *pgxpool.Pool
-	templateRepo *AgentTemplateRepository
+	db conn
 }
 
 // NewAgentRepository creates a new AgentRepository
-func NewAgentRepository(db *pgxpool.Pool, templateRepo *AgentTemplateRepository) *AgentRepository {
-	return &AgentRepository{db: db, templateRepo: templateRepo}
+func NewAgentRepository(db *pgxpool.Pool) *AgentRepository {
+	return &AgentRepository{db: conn{db}}
 }
 
 // Create creates a new agent
 func (r *AgentRepository) Create(ctx context.Context, agent *domain.Agent) error {
-	query := `
-		INSERT INTO agents (id, office_id, template_id, custom_name, custom_system_prompt, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`
-	_, err := r.db.Exec(ctx, query,
-		agent.ID, agent.OfficeID, agent.TemplateID,
-		nullableString(agent.CustomName), nullableString(agent.CustomSystemPrompt),
-		agent.IsActive, agent.CreatedAt, agent.UpdatedAt,
-	)
+	_, err := r.db.Exec(ctx, insertAgentQuery, insertAgentArgs(agent)...)
 	return err
 }
 
-// GetByID returns an agent by ID with template loaded
-func (r *AgentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Agent, error) {
-	query := `SELECT id, office_id, template_id, custom_name, custom_system_prompt, is_active, created_at, updated_at FROM agents WHERE id = $1`
-
-	agent, err := r.scanAgent(ctx, r.db.QueryRow(ctx, query, id))
+// CreateMany creates agents in one transaction, so either all of them are
+// added or none
+func (r *AgentRepository) CreateMany(ctx context.Context, agents []*domain.Agent) error {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	// Load template
-	template, err := r.templateRepo.GetByID(ctx, agent.TemplateID)
-	if err == nil {
-		agent.Template = template
+	for _, agent := range agents {
+		if _, err := tx.Exec(ctx, insertAgentQuery, insertAgentArgs(agent)...); err != nil {
+			return err
+		}
 	}
 
-	return agent, nil
+	return tx.Commit(ctx)
 }
 
-// GetByOfficeID returns all agents for an office
-func (r *AgentRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Agent, error) {
-	query := `SELECT id, office_id, template_id, custom_name, custom_system_prompt, is_active, created_at, updated_at FROM agents WHERE office_id = $1 AND is_active = true ORDER BY created_at`
+const insertAgentQuery = `
+	INSERT INTO agents (id, office_id, template_id, custom_name, custom_system_prompt, custom_avatar_url, is_active, template_version, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
 
-	rows, err := r.db.Query(ctx, query, officeID)
-	if err != nil {
-		return nil, err
+// insertAgentArgs returns the arguments of insertAgentQuery for agent
+func insertAgentArgs(agent *domain.Agent) []any {
+	return []any{
+		agent.ID, agent.OfficeID, agent.TemplateID,
+		nullableString(agent.CustomName), nullableString(agent.CustomSystemPrompt), nullableString(agent.CustomAvatarURL),
+		agent.IsActive, nullableString(agent.TemplateVersion), agent.CreatedAt, agent.UpdatedAt,
 	}
-	defer rows.Close()
-
-	var agents []*domain.Agent
-	for rows.Next() {
-		agent, err := r.scanAgentFromRows(rows)
-		if err != nil {
-			return nil, err
-		}
+}
 
-		// Load template
-		template, err := r.templateRepo.GetByID(ctx, agent.TemplateID)
-		if err == nil {
-			agent.Template = template
-		}
+// agentColumns are the columns scanned by scanAgent: the agent, aliased as
+// a, its template t and, if the agent is pinned to a version that has an
+// approved snapshot, the snapshot tv, all joined by agentTables
+const agentColumns = `a.id, a.office_id, a.template_id, a.custom_name, a.custom_system_prompt, a.custom_avatar_url,
+	       a.is_active, a.template_version, a.created_at, a.updated_at,
+	       t.id, t.name, t.role, t.system_prompt, t.avatar_url, t.skill_tags, t.author_id,
+	       COALESCE(t.is_premium, false), COALESCE(t.price_cents, 0), COALESCE(t.version, '1.0.0'),
+	       COALESCE(t.status, 'approved'), t.created_at,
+	       tv.system_prompt, tv.skill_tags`
+
+const agentTables = `agents a
+	JOIN agent_templates t ON t.id = a.template_id
+	LEFT JOIN template_versions tv ON tv.template_id = a.template_id AND tv.version = a.template_version
+		AND tv.status = 'approved'
+`
+
+// agentSelect selects agents with their templates for scanAgent
+const agentSelect = `SELECT ` + agentColumns + ` FROM ` + agentTables
 
-		agents = append(agents, agent)
+// GetByID returns an agent by ID with template loaded
+func (r *AgentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Agent, error) {
+	agent, err := scanAgent(r.db.QueryRow(ctx, agentSelect+`WHERE a.id = $1 AND a.deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
 	}
-	return agents, rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+// GetByOfficeID returns all agents for an office
+func (r *AgentRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Agent, error) {
+	query := agentSelect + `WHERE a.office_id = $1 AND a.is_active = true AND a.deleted_at IS NULL ORDER BY a.created_at`
+	return queryAgents(ctx, r.db, query, officeID)
 }
 
 // Update updates an agent
 func (r *AgentRepository) Update(ctx context.Context, agent *domain.Agent) error {
-	query := `UPDATE agents SET custom_name = $2, custom_system_prompt = $3, is_active = $4, updated_at = $5 WHERE id = $1`
+	query := `
+		UPDATE agents SET custom_name = $2, custom_system_prompt = $3, custom_avatar_url = $4,
+			is_active = $5, template_version = $6, updated_at = $7
+		WHERE id = $1
+	`
 	_, err := r.db.Exec(ctx, query,
-		agent.ID, nullableString(agent.CustomName), nullableString(agent.CustomSystemPrompt),
-		agent.IsActive, agent.UpdatedAt,
+		agent.ID, nullableString(agent.CustomName), nullableString(agent.CustomSystemPrompt), nullableString(agent.CustomAvatarURL),
+		agent.IsActive, nullableString(agent.TemplateVersion), agent.UpdatedAt,
 	)
 	return err
 }
 
-// Delete deletes an agent
-func (r *AgentRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM agents WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, id)
-	return err
+// SoftDelete deactivates an agent and hides it. Its tasks, usage and
+// conversation memberships stay for when it is restored, but its schedules
+// are paused.
+func (r *AgentRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE agents SET is_active = FALSE, deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE scheduled_tasks SET is_active = FALSE, next_run_at = NULL
+		WHERE is_active AND agent_id = $1
+	`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *AgentRepository) scanAgent(ctx context.Context, row pgx.Row) (*domain.Agent, error) {
-	var agent domain.Agent
-	var customName, customSystemPrompt *string
+// Restore reactivates an agent of the office that was deleted after
+// deletedAfter. Its schedules stay paused.
+func (r *AgentRepository) Restore(ctx context.Context, officeID, id uuid.UUID, deletedAfter time.Time) error {
+	query := `
+		UPDATE agents SET is_active = TRUE, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND office_id = $2 AND deleted_at > $3
+	`
+	tag, err := r.db.Exec(ctx, query, id, officeID, deletedAfter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
 
-	err := row.Scan(
-		&agent.ID, &agent.OfficeID, &agent.TemplateID,
-		&customName, &customSystemPrompt,
-		&agent.IsActive, &agent.CreatedAt, &agent.UpdatedAt,
-	)
+// LockOffice locks the office's row until the transaction ends, so that
+// concurrent hires into the office count each other's agents against its
+// tier limit. NO KEY UPDATE leaves rows referencing the office insertable.
+func (r *AgentRepository) LockOffice(ctx context.Context, officeID uuid.UUID) error {
+	var id uuid.UUID
+	err := r.db.QueryRow(ctx, `SELECT id FROM offices WHERE id = $1 FOR NO KEY UPDATE`, officeID).Scan(&id)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, domain.ErrNotFound
+		return domain.ErrNotFound
 	}
+	return err
+}
+
+// queryAgents returns the agents an agentSelect query finds
+func queryAgents(ctx context.Context, db conn, query string, args ...any) ([]*domain.Agent, error) {
+	rows, err := db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	if customName != nil {
-		agent.CustomName = *customName
-	}
-	if customSystemPrompt != nil {
-		agent.CustomSystemPrompt = *customSystemPrompt
+	var agents []*domain.Agent
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, agent)
 	}
-
-	return &agent, nil
+	return agents, rows.Err()
 }
 
-func (r *AgentRepository) scanAgentFromRows(rows pgx.Rows) (*domain.Agent, error) {
+// scanAgent scans a row of agentColumns, followed by the extra columns into
+// extra. Agents pinned to an older version get that version's snapshot so
+// upgrades stay opt-in; if no snapshot was recorded the live template is
+// used.
+func scanAgent(row pgx.Row, extra ...any) (*domain.Agent, error) {
 	var agent domain.Agent
-	var customName, customSystemPrompt *string
+	var template domain.AgentTemplate
+	var customName, customSystemPrompt, customAvatarURL, templateVersion, avatarURL, versionPrompt *string
+	var skillTagsJSON, versionSkillTagsJSON []byte
 
-	err := rows.Scan(
+	err := row.Scan(append([]any{
 		&agent.ID, &agent.OfficeID, &agent.TemplateID,
-		&customName, &customSystemPrompt,
-		&agent.IsActive, &agent.CreatedAt, &agent.UpdatedAt,
-	)
+		&customName, &customSystemPrompt, &customAvatarURL,
+		&agent.IsActive, &templateVersion, &agent.CreatedAt, &agent.UpdatedAt,
+		&template.ID, &template.Name, &template.Role, &template.SystemPrompt, &avatarURL, &skillTagsJSON, &template.AuthorID,
+		&template.IsPremium, &template.PriceCents, &template.Version, &template.Status, &template.CreatedAt,
+		&versionPrompt, &versionSkillTagsJSON,
+	}, extra...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -245,6 +342,30 @@ func (r *AgentRepository) scanAgentFromRows(rows pgx.Rows) (*domain.Agent, error
 	if customSystemPrompt != nil {
 		agent.CustomSystemPrompt = *customSystemPrompt
 	}
+	if customAvatarURL != nil {
+		agent.CustomAvatarURL = *customAvatarURL
+	}
+	if templateVersion != nil {
+		agent.TemplateVersion = *templateVersion
+	}
+
+	if avatarURL != nil {
+		template.AvatarURL = *avatarURL
+	}
+	template.SkillTags = parseSkillTags(skillTagsJSON)
+
+	agent.Template = &template
+	agent.LatestVersion = template.Version
+	if agent.TemplateVersion == "" || agent.TemplateVersion == template.Version {
+		return &agent, nil
+	}
+	agent.UpdateAvailable = template.Status == "approved"
+
+	if versionPrompt != nil {
+		template.SystemPrompt = *versionPrompt
+		template.Version = agent.TemplateVersion
+		template.SkillTags = parseSkillTags(versionSkillTagsJSON)
+	}
 
 	return &agent, nil
 }
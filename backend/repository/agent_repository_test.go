@@ -0,0 +1,186 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// newAgent creates an agent of the office from templateID, pinned to
+// version, and returns it
+func newAgent(t *testing.T, officeID, templateID uuid.UUID, version string, createdAt time.Time) *domain.Agent {
+	t.Helper()
+	agent := &domain.Agent{
+		ID:              uuid.New(),
+		OfficeID:        officeID,
+		TemplateID:      templateID,
+		IsActive:        true,
+		TemplateVersion: version,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+	if err := repository.NewAgentRepository(testDB.Pool).Create(context.Background(), agent); err != nil {
+		t.Fatalf("Create agent: %v", err)
+	}
+	return agent
+}
+
+// publishVersion moves the template to version 2.0.0 and keeps a snapshot
+// of version 1.0.0
+func publishVersion(t *testing.T, templateID uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := testDB.Pool.Exec(ctx, `
+		INSERT INTO template_versions (template_id, version, system_prompt, skill_tags)
+		VALUES ($1, '1.0.0', 'You tested things before.', '["legacy"]')
+	`, templateID)
+	if err != nil {
+		t.Fatalf("snapshot template version: %v", err)
+	}
+	_, err = testDB.Pool.Exec(ctx,
+		`UPDATE agent_templates SET version = '2.0.0', system_prompt = 'You test things.' WHERE id = $1`, templateID)
+	if err != nil {
+		t.Fatalf("publish template version: %v", err)
+	}
+}
+
+func TestAgentTemplateGetByIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentTemplateRepository(testDB.Pool)
+	author := testDB.User(t)
+	first, second := testDB.Template(t, author), testDB.Template(t, author)
+
+	templates, err := repo.GetByIDs(ctx, []uuid.UUID{first, second, uuid.New()})
+	if err != nil {
+		t.Fatalf("GetByIDs: %v", err)
+	}
+	if len(templates) != 2 || templates[first] == nil || templates[second] == nil {
+		t.Fatalf("GetByIDs returned %d templates, want the 2 known ones", len(templates))
+	}
+	if templates[first].ID != first || templates[first].Name != "Test Agent" {
+		t.Errorf("template = %+v, want the created template", templates[first])
+	}
+
+	templates, err = repo.GetByIDs(ctx, nil)
+	if err != nil || len(templates) != 0 {
+		t.Errorf("GetByIDs(nil) = %d templates, %v; want none", len(templates), err)
+	}
+}
+
+func TestAgentTemplateGetAllLeavesOutUnapprovedTemplates(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentTemplateRepository(testDB.Pool)
+	author := testDB.User(t)
+	approved, pending := testDB.Template(t, author), testDB.Template(t, author)
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET status = 'pending' WHERE id = $1`, pending); err != nil {
+		t.Fatalf("set template pending: %v", err)
+	}
+
+	templates, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	listed := map[uuid.UUID]bool{}
+	for _, template := range templates {
+		listed[template.ID] = true
+	}
+	if !listed[approved] || listed[pending] {
+		t.Errorf("GetAll lists approved %v and pending %v, want only the approved template", listed[approved], listed[pending])
+	}
+}
+
+func TestAgentGetByOfficeIDLoadsPinnedTemplates(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	templateID := testDB.Template(t, testDB.User(t))
+	publishVersion(t, templateID)
+
+	now := time.Now()
+	current := newAgent(t, office, templateID, "2.0.0", now.Add(-3*time.Minute))
+	pinned := newAgent(t, office, templateID, "1.0.0", now.Add(-2*time.Minute))
+	// No snapshot was recorded for this version
+	unrecorded := newAgent(t, office, templateID, "0.1.0", now.Add(-time.Minute))
+	inactive := newAgent(t, office, templateID, "2.0.0", now)
+	inactive.IsActive = false
+	if err := repo.Update(ctx, inactive); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	agents, err := repo.GetByOfficeID(ctx, office)
+	if err != nil {
+		t.Fatalf("GetByOfficeID: %v", err)
+	}
+	if len(agents) != 3 || agents[0].ID != current.ID || agents[1].ID != pinned.ID || agents[2].ID != unrecorded.ID {
+		t.Fatalf("GetByOfficeID returned %d agents, want the 3 active ones in creation order", len(agents))
+	}
+
+	tests := []struct {
+		agent           *domain.Agent
+		prompt, version string
+		updateAvailable bool
+	}{
+		{agents[0], "You test things.", "2.0.0", false},
+		{agents[1], "You tested things before.", "1.0.0", true},
+		{agents[2], "You test things.", "2.0.0", true},
+	}
+	for _, tt := range tests {
+		if tt.agent.Template == nil {
+			t.Errorf("agent %s has no template", tt.agent.ID)
+			continue
+		}
+		if tt.agent.Template.SystemPrompt != tt.prompt || tt.agent.Template.Version != tt.version ||
+			tt.agent.LatestVersion != "2.0.0" || tt.agent.UpdateAvailable != tt.updateAvailable {
+			t.Errorf("agent pinned to %s runs %q at %s, latest %s, update available %v; want %q at %s, latest 2.0.0, %v",
+				tt.agent.TemplateVersion, tt.agent.Template.SystemPrompt, tt.agent.Template.Version,
+				tt.agent.LatestVersion, tt.agent.UpdateAvailable, tt.prompt, tt.version, tt.updateAvailable)
+		}
+	}
+	if tags := agents[1].Template.SkillTags; len(tags) != 1 || tags[0] != "legacy" {
+		t.Errorf("pinned agent's skill tags = %v, want the snapshot's [legacy]", tags)
+	}
+
+	got, err := repo.GetByID(ctx, pinned.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Template == nil || got.Template.SystemPrompt != "You tested things before." {
+		t.Errorf("GetByID template = %+v, want the pinned snapshot", got.Template)
+	}
+}
+
+func TestAgentLockOfficeHoldsOffConcurrentHires(t *testing.T) {
+	ctx := context.Background()
+	agents := repository.NewAgentRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	template := testDB.Template(t, testDB.User(t))
+
+	if err := agents.LockOffice(ctx, uuid.New()); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("LockOffice of an unknown office error = %v, want ErrNotFound", err)
+	}
+
+	err := repository.NewTxManager(testDB.Pool).WithinTx(ctx, func(txCtx context.Context) error {
+		if err := agents.LockOffice(txCtx, office); err != nil {
+			return err
+		}
+		// Another hire waits for the lock, while rows referencing the
+		// office are still written
+		var id uuid.UUID
+		err := testDB.Pool.QueryRow(ctx, `SELECT id FROM offices WHERE id = $1 FOR NO KEY UPDATE NOWAIT`, office).Scan(&id)
+		if err == nil {
+			t.Error("a concurrent hire took the office lock")
+		}
+		newAgent(t, office, template, "1.0.0", time.Now())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+}
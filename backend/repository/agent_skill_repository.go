@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentSkillRepository implements domain.AgentSkillRepository
+type AgentSkillRepository struct {
+	db conn
+}
+
+// NewAgentSkillRepository creates a new AgentSkillRepository
+func NewAgentSkillRepository(db *pgxpool.Pool) *AgentSkillRepository {
+	return &AgentSkillRepository{db: conn{db}}
+}
+
+// GetByAgentID returns whether each skill configured for the agent is enabled, by key
+func (r *AgentSkillRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID) (map[string]bool, error) {
+	rows, err := r.db.Query(ctx, `SELECT skill, enabled FROM agent_skills WHERE agent_id = $1`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := make(map[string]bool)
+	for rows.Next() {
+		var skill string
+		var enabled bool
+		if err := rows.Scan(&skill, &enabled); err != nil {
+			return nil, err
+		}
+		skills[skill] = enabled
+	}
+	return skills, rows.Err()
+}
+
+// Set enables or disables the skills for the agent in one statement
+func (r *AgentSkillRepository) Set(ctx context.Context, agentID uuid.UUID, skills map[string]bool) error {
+	if len(skills) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(skills))
+	enabled := make([]bool, 0, len(skills))
+	for key, on := range skills {
+		keys = append(keys, key)
+		enabled = append(enabled, on)
+	}
+
+	query := `
+		INSERT INTO agent_skills (agent_id, skill, enabled, updated_at)
+		SELECT $1, skill, enabled, NOW()
+		FROM unnest($2::text[], $3::boolean[]) AS s(skill, enabled)
+		ON CONFLICT (agent_id, skill) DO UPDATE
+		SET enabled = EXCLUDED.enabled,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.Exec(ctx, query, agentID, keys, enabled)
+	return err
+}
@@ -0,0 +1,34 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+)
+
+func TestAgentSkillsSet(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAgentSkillRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	agent := newAgent(t, office, testDB.Template(t, testDB.User(t)), "1.0.0", time.Now())
+
+	if err := repo.Set(ctx, agent.ID, map[string]bool{domain.SkillCodeExec: true, domain.SkillWebSearch: true}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// Skills left out keep their configuration
+	if err := repo.Set(ctx, agent.ID, map[string]bool{domain.SkillWebSearch: false}); err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+
+	skills, err := repo.GetByAgentID(ctx, agent.ID)
+	want := map[string]bool{domain.SkillCodeExec: true, domain.SkillWebSearch: false}
+	if err != nil || !maps.Equal(skills, want) {
+		t.Errorf("GetByAgentID = %v, %v; want %v", skills, err, want)
+	}
+}
@@ -9,32 +9,33 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// AnalyticsRepository implements analytics data access
+// AnalyticsRepository implements domain.AnalyticsRepository
 type AnalyticsRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewAnalyticsRepository creates a new analytics repository
 func NewAnalyticsRepository(db *pgxpool.Pool) *AnalyticsRepository {
-	return &AnalyticsRepository{db: db}
+	return &AnalyticsRepository{db: conn{db}}
 }
 
-// GetDailyUsage retrieves daily usage for an office within a date range
+// GetDailyUsage retrieves daily usage for an office within a date range,
+// newest first
 func (r *AnalyticsRepository) GetDailyUsage(
 	ctx context.Context,
 	officeID uuid.UUID,
-	days int,
+	dates domain.DateRange,
 ) ([]domain.UsageDaily, error) {
 	query := `
 		SELECT id, office_id, date, credits_consumed, tasks_executed, 
 		       tasks_succeeded, tasks_failed, input_tokens, output_tokens, 
 		       total_tokens, local_model_tasks, paid_model_tasks, estimated_usd
 		FROM usage_daily
-		WHERE office_id = $1 AND date >= CURRENT_DATE - $2 * INTERVAL '1 day'
+		WHERE office_id = $1 AND date BETWEEN $2 AND $3
 		ORDER BY date DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, officeID, days)
+	rows, err := r.db.Query(ctx, query, officeID, dates.From, dates.To)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +63,7 @@ func (r *AnalyticsRepository) GetDailyUsage(
 func (r *AnalyticsRepository) GetUsageByModel(
 	ctx context.Context,
 	officeID uuid.UUID,
-	days int,
+	dates domain.DateRange,
 ) ([]domain.UsageByModel, error) {
 	query := `
 		SELECT model_name, provider, 
@@ -73,12 +74,12 @@ func (r *AnalyticsRepository) GetUsageByModel(
 		       SUM(estimated_usd) as estimated_usd,
 		       AVG(avg_latency_ms) as avg_latency_ms
 		FROM usage_by_model
-		WHERE office_id = $1 AND date >= CURRENT_DATE - $2 * INTERVAL '1 day'
+		WHERE office_id = $1 AND date BETWEEN $2 AND $3
 		GROUP BY model_name, provider
 		ORDER BY credits_consumed DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, officeID, days)
+	rows, err := r.db.Query(ctx, query, officeID, dates.From, dates.To)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +105,7 @@ func (r *AnalyticsRepository) GetUsageByModel(
 func (r *AnalyticsRepository) GetUsageByAgent(
 	ctx context.Context,
 	officeID uuid.UUID,
-	days int,
+	dates domain.DateRange,
 ) ([]domain.UsageByAgent, error) {
 	query := `
 		SELECT agent_id, agent_role,
@@ -114,12 +115,12 @@ func (r *AnalyticsRepository) GetUsageByAgent(
 		       SUM(output_tokens) as output_tokens,
 		       AVG(avg_score) as avg_score
 		FROM usage_by_agent
-		WHERE office_id = $1 AND date >= CURRENT_DATE - $2 * INTERVAL '1 day'
+		WHERE office_id = $1 AND date BETWEEN $2 AND $3
 		GROUP BY agent_id, agent_role
 		ORDER BY credits_consumed DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, officeID, days)
+	rows, err := r.db.Query(ctx, query, officeID, dates.From, dates.To)
 	if err != nil {
 		return nil, err
 	}
@@ -141,11 +142,12 @@ func (r *AnalyticsRepository) GetUsageByAgent(
 	return results, rows.Err()
 }
 
-// GetUsageSummary retrieves aggregated usage summary
+// GetUsageSummary retrieves aggregated usage summary. The period is left to
+// the caller to set.
 func (r *AnalyticsRepository) GetUsageSummary(
 	ctx context.Context,
 	officeID uuid.UUID,
-	days int,
+	dates domain.DateRange,
 ) (*domain.UsageSummary, error) {
 	query := `
 		SELECT 
@@ -158,13 +160,13 @@ func (r *AnalyticsRepository) GetUsageSummary(
 		    COALESCE(SUM(local_model_tasks), 0),
 		    COALESCE(SUM(paid_model_tasks), 0)
 		FROM usage_daily
-		WHERE office_id = $1 AND date >= CURRENT_DATE - $2 * INTERVAL '1 day'
+		WHERE office_id = $1 AND date BETWEEN $2 AND $3
 	`
 
 	var summary domain.UsageSummary
 	var localTasks, paidTasks int
 
-	err := r.db.QueryRow(ctx, query, officeID, days).Scan(
+	err := r.db.QueryRow(ctx, query, officeID, dates.From, dates.To).Scan(
 		&summary.CreditsUsed, &summary.TasksExecuted,
 		&summary.TasksSucceeded, &summary.TasksFailed,
 		&summary.TokensProcessed, &summary.EstimatedCostUSD,
@@ -180,18 +182,6 @@ func (r *AnalyticsRepository) GetUsageSummary(
 		summary.LocalModelRatio = float64(localTasks) / float64(totalTasks) * 100
 	}
 
-	// Set period label
-	switch days {
-	case 1:
-		summary.Period = "today"
-	case 7:
-		summary.Period = "7d"
-	case 30:
-		summary.Period = "30d"
-	default:
-		summary.Period = "custom"
-	}
-
 	return &summary, nil
 }
 
@@ -218,3 +208,291 @@ func (r *AnalyticsRepository) RecordTaskUsage(
 	)
 	return err
 }
+
+// taskUsage is a common table expression of the usage of finished tasks,
+// read from the tasks and the credits charged and refunded for them: those
+// of the office $1, or of every office when NULL, that finished on one of
+// the days $2 in their office's timezone. Tasks that have not reported
+// their model, such as failed ones, count under "unknown".
+const taskUsage = `
+	WITH task_usage AS (
+		SELECT t.office_id, t.agent_id,
+		       COALESCE(tpl.role, 'unknown') AS agent_role,
+		       (t.completed_at AT TIME ZONE o.timezone)::date AS date,
+		       COALESCE(t.model_name, 'unknown') AS model_name,
+		       COALESCE(t.provider, 'unknown') AS provider,
+		       t.status = 'done' AS succeeded,
+		       COALESCE((t.token_usage->>'prompt_tokens')::bigint, 0) AS input_tokens,
+		       COALESCE((t.token_usage->>'completion_tokens')::bigint, 0) AS output_tokens,
+		       COALESCE(t.is_local_model, t.provider = 'ollama', false) AS is_local_model,
+		       COALESCE(t.usd_cost, 0) AS usd_cost,
+		       t.latency_ms,
+		       COALESCE((
+		           SELECT -SUM(ct.amount) FROM credit_transactions ct
+		           WHERE ct.reference_type = 'task' AND ct.reference_id = t.id
+		             AND ct.transaction_type IN ('consumption', 'refund')
+		       ), 0) AS credits
+		FROM tasks t
+		JOIN offices o ON o.id = t.office_id
+		LEFT JOIN agents a ON a.id = t.agent_id
+		LEFT JOIN agent_templates tpl ON tpl.id = a.template_id
+		WHERE ($1::uuid IS NULL OR t.office_id = $1)
+		  AND t.status IN ('done', 'failed', 'dead_letter')
+		  -- A day in any timezone lies within a day either side of it in UTC
+		  AND t.completed_at >= (SELECT MIN(d) FROM unnest($2::date[]) d) - INTERVAL '1 day'
+		  AND t.completed_at < (SELECT MAX(d) FROM unnest($2::date[]) d) + INTERVAL '2 days'
+		  AND (t.completed_at AT TIME ZONE o.timezone)::date = ANY($2::date[])
+	)`
+
+// AggregateDailyUsage adds up the office's usage per day from its tasks for
+// the given days, newest first. Days without finished tasks are left out.
+func (r *AnalyticsRepository) AggregateDailyUsage(
+	ctx context.Context,
+	officeID uuid.UUID,
+	days []time.Time,
+) ([]domain.UsageDaily, error) {
+	query := taskUsage + `
+		SELECT office_id, date, SUM(credits), COUNT(*),
+		       COUNT(*) FILTER (WHERE succeeded), COUNT(*) FILTER (WHERE NOT succeeded),
+		       SUM(input_tokens), SUM(output_tokens), SUM(input_tokens + output_tokens),
+		       COUNT(*) FILTER (WHERE is_local_model), COUNT(*) FILTER (WHERE NOT is_local_model),
+		       SUM(usd_cost)
+		FROM task_usage
+		GROUP BY office_id, date
+		ORDER BY date DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, officeID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.UsageDaily
+	for rows.Next() {
+		var u domain.UsageDaily
+		var date time.Time
+		if err := rows.Scan(
+			&u.OfficeID, &date, &u.CreditsConsumed,
+			&u.TasksExecuted, &u.TasksSucceeded, &u.TasksFailed,
+			&u.InputTokens, &u.OutputTokens, &u.TotalTokens,
+			&u.LocalModelTasks, &u.PaidModelTasks, &u.EstimatedUSD,
+		); err != nil {
+			return nil, err
+		}
+		u.Date = date.Format("2006-01-02")
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
+
+// AggregateUsageByModel adds up the office's usage per model from its tasks
+// for the given days
+func (r *AnalyticsRepository) AggregateUsageByModel(
+	ctx context.Context,
+	officeID uuid.UUID,
+	days []time.Time,
+) ([]domain.UsageByModel, error) {
+	query := taskUsage + `
+		SELECT model_name, MIN(provider), COUNT(*), SUM(credits),
+		       SUM(input_tokens), SUM(output_tokens), SUM(usd_cost),
+		       COALESCE(AVG(latency_ms), 0)::int
+		FROM task_usage
+		GROUP BY model_name
+		ORDER BY SUM(credits) DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, officeID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.UsageByModel
+	for rows.Next() {
+		var u domain.UsageByModel
+		if err := rows.Scan(
+			&u.ModelName, &u.Provider, &u.TaskCount,
+			&u.CreditsConsumed, &u.InputTokens, &u.OutputTokens,
+			&u.EstimatedUSD, &u.AvgLatencyMs,
+		); err != nil {
+			return nil, err
+		}
+		u.OfficeID = officeID
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
+
+// AggregateUsageByAgent adds up the office's usage per agent from its tasks
+// for the given days
+func (r *AnalyticsRepository) AggregateUsageByAgent(
+	ctx context.Context,
+	officeID uuid.UUID,
+	days []time.Time,
+) ([]domain.UsageByAgent, error) {
+	query := taskUsage + `
+		SELECT agent_id, MIN(agent_role), COUNT(*), SUM(credits),
+		       SUM(input_tokens), SUM(output_tokens)
+		FROM task_usage
+		GROUP BY agent_id
+		ORDER BY SUM(credits) DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, officeID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.UsageByAgent
+	for rows.Next() {
+		var u domain.UsageByAgent
+		if err := rows.Scan(
+			&u.AgentID, &u.AgentRole, &u.TaskCount,
+			&u.CreditsConsumed, &u.InputTokens, &u.OutputTokens,
+		); err != nil {
+			return nil, err
+		}
+		u.OfficeID = officeID
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
+
+// usageRebuilds write the rollups of the days of task_usage that have no
+// daily rollup yet. The daily rollup goes last, as it marks a day as
+// recorded for the others.
+var usageRebuilds = []string{
+	taskUsage + `
+		INSERT INTO usage_by_model (
+		    office_id, date, model_name, provider, task_count, credits_consumed,
+		    input_tokens, output_tokens, estimated_usd, avg_latency_ms
+		)
+		SELECT office_id, date, model_name, MIN(provider), COUNT(*), SUM(credits),
+		       SUM(input_tokens), SUM(output_tokens), SUM(usd_cost), COALESCE(AVG(latency_ms), 0)::int
+		FROM task_usage u
+		WHERE NOT EXISTS (SELECT 1 FROM usage_daily d WHERE d.office_id = u.office_id AND d.date = u.date)
+		GROUP BY office_id, date, model_name
+		ON CONFLICT (office_id, date, model_name) DO NOTHING`,
+	taskUsage + `
+		INSERT INTO usage_by_agent (
+		    office_id, date, agent_id, agent_role, task_count, credits_consumed, input_tokens, output_tokens
+		)
+		SELECT office_id, date, agent_id, MIN(agent_role), COUNT(*), SUM(credits),
+		       SUM(input_tokens), SUM(output_tokens)
+		FROM task_usage u
+		WHERE NOT EXISTS (SELECT 1 FROM usage_daily d WHERE d.office_id = u.office_id AND d.date = u.date)
+		GROUP BY office_id, date, agent_id
+		ON CONFLICT (office_id, date, agent_id) DO NOTHING`,
+	taskUsage + `
+		INSERT INTO usage_daily (
+		    office_id, date, credits_consumed, tasks_executed, tasks_succeeded, tasks_failed,
+		    input_tokens, output_tokens, total_tokens, local_model_tasks, paid_model_tasks, estimated_usd
+		)
+		SELECT office_id, date, SUM(credits), COUNT(*),
+		       COUNT(*) FILTER (WHERE succeeded), COUNT(*) FILTER (WHERE NOT succeeded),
+		       SUM(input_tokens), SUM(output_tokens), SUM(input_tokens + output_tokens),
+		       COUNT(*) FILTER (WHERE is_local_model), COUNT(*) FILTER (WHERE NOT is_local_model),
+		       SUM(usd_cost)
+		FROM task_usage
+		GROUP BY office_id, date
+		ON CONFLICT (office_id, date) DO NOTHING`,
+}
+
+// usageClears delete the rollups of the office $1, or of every office when
+// NULL, on the days $2
+var usageClears = []string{
+	`DELETE FROM usage_daily WHERE ($1::uuid IS NULL OR office_id = $1) AND date = ANY($2::date[])`,
+	`DELETE FROM usage_by_model WHERE ($1::uuid IS NULL OR office_id = $1) AND date = ANY($2::date[])`,
+	`DELETE FROM usage_by_agent WHERE ($1::uuid IS NULL OR office_id = $1) AND date = ANY($2::date[])`,
+}
+
+// RebuildUsage writes usage rollups from the tasks of the office, or of
+// every office when officeID is nil, for the given days in one transaction.
+// Days that already have a daily rollup are kept unless overwrite is set,
+// in which case all their rollups are replaced; days whose tasks have been
+// purged are then emptied. It returns how many office days were written.
+func (r *AnalyticsRepository) RebuildUsage(
+	ctx context.Context,
+	officeID *uuid.UUID,
+	days []time.Time,
+	overwrite bool,
+) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if overwrite {
+		for _, query := range usageClears {
+			if _, err := tx.Exec(ctx, query, officeID, days); err != nil {
+				return 0, err
+			}
+		}
+	}
+	var written int64
+	for _, query := range usageRebuilds {
+		tag, err := tx.Exec(ctx, query, officeID, days)
+		if err != nil {
+			return 0, err
+		}
+		// The last one writes the daily rollups, a row per office day
+		written = tag.RowsAffected()
+	}
+	return written, tx.Commit(ctx)
+}
+
+// GetAgentStats returns what each of the office's agents did over a window
+func (r *AnalyticsRepository) GetAgentStats(
+	ctx context.Context,
+	officeID uuid.UUID,
+	since, until time.Time,
+) ([]domain.AgentStats, error) {
+	query := `
+		WITH finished AS (
+			SELECT agent_id, COUNT(*) AS tasks,
+			       COUNT(*) FILTER (WHERE status = 'done') AS succeeded,
+			       AVG(latency_ms)::float8 AS avg_latency_ms,
+			       SUM(credits_consumed) AS credits
+			FROM tasks
+			WHERE office_id = $1 AND status IN ('done', 'failed', 'dead_letter')
+			  AND completed_at >= $2 AND completed_at < $3
+			GROUP BY agent_id
+		), feedback AS (
+			SELECT agent_id,
+			       COUNT(*) FILTER (WHERE feedback_type = 'positive') AS positive,
+			       COUNT(*) FILTER (WHERE feedback_type <> 'positive') AS negative,
+			       AVG(rating)::float8 AS avg_rating
+			FROM agent_feedback
+			WHERE office_id = $1 AND created_at >= $2 AND created_at < $3
+			GROUP BY agent_id
+		)
+		SELECT COALESCE(f.agent_id, fb.agent_id), COALESCE(f.tasks, 0), COALESCE(f.succeeded, 0),
+		       f.avg_latency_ms, COALESCE(f.credits, 0),
+		       COALESCE(fb.positive, 0), COALESCE(fb.negative, 0), fb.avg_rating
+		FROM finished f
+		FULL JOIN feedback fb ON fb.agent_id = f.agent_id
+	`
+
+	rows, err := r.db.Query(ctx, query, officeID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.AgentStats
+	for rows.Next() {
+		var s domain.AgentStats
+		if err := rows.Scan(
+			&s.AgentID, &s.TasksFinished, &s.TasksSucceeded,
+			&s.AvgLatencyMs, &s.CreditsConsumed,
+			&s.PositiveFeedback, &s.NegativeFeedback, &s.AvgRating,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
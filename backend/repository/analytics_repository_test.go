@@ -0,0 +1,97 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// newFinishedTask inserts a done task of the agent that finished at
+// completedAt, used tokens of model and was charged credits
+func newFinishedTask(t *testing.T, officeID, agentID uuid.UUID, completedAt time.Time, model string, credits int64) {
+	t.Helper()
+	ctx := context.Background()
+	taskID := uuid.New()
+	_, err := testDB.Pool.Exec(ctx, `
+		INSERT INTO tasks (id, office_id, agent_id, status, input, token_usage, model_name, provider,
+			credits_consumed, latency_ms, completed_at)
+		VALUES ($1, $2, $3, 'done', 'Test', '{"prompt_tokens": 100, "completion_tokens": 50}', $4, 'ollama', $5, 40, $6)
+	`, taskID, officeID, agentID, model, credits, completedAt)
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	_, err = testDB.Pool.Exec(ctx, `
+		INSERT INTO credit_transactions (wallet_id, transaction_type, amount, balance_after, reference_type, reference_id)
+		SELECT id, 'consumption', $2, balance, 'task', $3 FROM credit_wallets WHERE office_id = $1
+	`, officeID, -credits, taskID)
+	if err != nil {
+		t.Fatalf("charge task: %v", err)
+	}
+}
+
+func TestRebuildUsageFillsMissingDaysFromTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAnalyticsRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	agent := newAgent(t, office, testDB.Template(t, testDB.User(t)), "1.0.0", time.Now())
+
+	// 8pm on the 4th in UTC is already the 5th in Tokyo
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE offices SET timezone = 'Asia/Tokyo' WHERE id = $1`, office); err != nil {
+		t.Fatalf("set timezone: %v", err)
+	}
+	newFinishedTask(t, office, agent.ID, time.Date(2026, 1, 4, 20, 0, 0, 0, time.UTC), "llama3", 3)
+	newFinishedTask(t, office, agent.ID, time.Date(2026, 1, 5, 1, 0, 0, 0, time.UTC), "llama3", 4)
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	days := []time.Time{day}
+
+	daily, err := repo.AggregateDailyUsage(ctx, office, days)
+	if err != nil {
+		t.Fatalf("AggregateDailyUsage: %v", err)
+	}
+	if len(daily) != 1 || daily[0].TasksExecuted != 2 || daily[0].CreditsConsumed != 7 ||
+		daily[0].TotalTokens != 300 || daily[0].LocalModelTasks != 2 {
+		t.Fatalf("AggregateDailyUsage = %+v, want both tasks on the 5th in Tokyo", daily)
+	}
+
+	written, err := repo.RebuildUsage(ctx, &office, days, false)
+	if err != nil || written != 1 {
+		t.Fatalf("RebuildUsage = %d, %v; want 1 office day", written, err)
+	}
+	written, err = repo.RebuildUsage(ctx, &office, days, false)
+	if err != nil || written != 0 {
+		t.Errorf("RebuildUsage again = %d, %v; want the recorded day kept", written, err)
+	}
+
+	dates := domain.DateRange{From: day, To: day}
+	rollup, err := repo.GetDailyUsage(ctx, office, dates)
+	if err != nil || len(rollup) != 1 || rollup[0].CreditsConsumed != 7 {
+		t.Fatalf("GetDailyUsage = %+v, %v; want the rebuilt day", rollup, err)
+	}
+	models, err := repo.GetUsageByModel(ctx, office, dates)
+	if err != nil || len(models) != 1 || models[0].TaskCount != 2 || models[0].AvgLatencyMs != 40 {
+		t.Errorf("GetUsageByModel = %+v, %v; want llama3 with 2 tasks", models, err)
+	}
+	agents, err := repo.GetUsageByAgent(ctx, office, dates)
+	if err != nil || len(agents) != 1 || agents[0].AgentID != agent.ID || agents[0].AgentRole != "Tester" {
+		t.Errorf("GetUsageByAgent = %+v, %v; want the agent as Tester", agents, err)
+	}
+
+	// Overwriting replaces the day instead of adding to it
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE usage_daily SET credits_consumed = 999 WHERE office_id = $1`, office); err != nil {
+		t.Fatalf("corrupt rollup: %v", err)
+	}
+	written, err = repo.RebuildUsage(ctx, &office, days, true)
+	if err != nil || written != 1 {
+		t.Fatalf("RebuildUsage overwriting = %d, %v; want 1 office day", written, err)
+	}
+	summary, err := repo.GetUsageSummary(ctx, office, dates)
+	if err != nil || summary.CreditsUsed != 7 || summary.TasksExecuted != 2 {
+		t.Errorf("GetUsageSummary = %+v, %v; want the day rebuilt from its tasks", summary, err)
+	}
+}
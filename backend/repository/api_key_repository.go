@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKeyRepository implements domain.APIKeyRepository
+type APIKeyRepository struct {
+	db conn
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{db: conn{db}}
+}
+
+const apiKeyColumns = `id, office_id, user_id, name, key_prefix, key_hash, scopes,
+	usage_count, last_used_at, expires_at, revoked_at, created_at`
+
+// Create stores a new API key
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	query := `
+		INSERT INTO api_keys (id, office_id, user_id, name, key_prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.Exec(ctx, query,
+		key.ID, key.OfficeID, key.UserID, key.Name, key.Prefix, key.KeyHash,
+		scopes, key.ExpiresAt, key.CreatedAt,
+	)
+	return err
+}
+
+// GetByID returns an API key by ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return key, err
+}
+
+// GetByOfficeID returns an office's API keys, including revoked ones, newest first
+func (r *APIKeyRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE office_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Use looks up an active, unexpired key by hash and counts the request
+// against it in the same statement
+func (r *APIKeyRepository) Use(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET usage_count = usage_count + 1, last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return key, err
+}
+
+// Revoke marks a key revoked; revoking an already revoked key is a no-op
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes []string
+
+	err := row.Scan(
+		&key.ID, &key.OfficeID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes,
+		&key.UsageCount, &key.LastUsedAt, &key.ExpiresAt, &key.RevokedAt, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = make([]domain.APIKeyScope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = domain.APIKeyScope(scope)
+	}
+	return &key, nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AttachmentRepository implements domain.AttachmentRepository
+type AttachmentRepository struct {
+	db conn
+}
+
+// NewAttachmentRepository creates a new AttachmentRepository
+func NewAttachmentRepository(db *pgxpool.Pool) *AttachmentRepository {
+	return &AttachmentRepository{db: conn{db}}
+}
+
+const attachmentColumns = `id, office_id, conversation_id, message_id, uploaded_by, filename, content_type, size_bytes,
+	storage_key, created_at`
+
+// Create creates a new attachment
+func (r *AttachmentRepository) Create(ctx context.Context, attachment *domain.Attachment) error {
+	query := `
+		INSERT INTO message_attachments (` + attachmentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.Exec(ctx, query,
+		attachment.ID, attachment.OfficeID, attachment.ConversationID, attachment.MessageID, attachment.UploadedBy,
+		attachment.Filename, attachment.ContentType, attachment.SizeBytes, attachment.StorageKey, attachment.CreatedAt,
+	)
+	return err
+}
+
+// GetByID returns an attachment by ID
+func (r *AttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM message_attachments WHERE id = $1`
+
+	attachment, err := scanAttachment(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// GetByIDs returns the attachments with the given IDs; unknown IDs are
+// left out
+func (r *AttachmentRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + attachmentColumns + ` FROM message_attachments WHERE id = ANY($1) ORDER BY created_at, id`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAttachments(rows)
+}
+
+// GetByMessageIDs returns the attachments of each message, oldest first
+func (r *AttachmentRepository) GetByMessageIDs(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]*domain.Attachment, error) {
+	attachments := make(map[uuid.UUID][]*domain.Attachment)
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM message_attachments WHERE message_id = ANY($1)
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list, err := scanAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range list {
+		attachments[*attachment.MessageID] = append(attachments[*attachment.MessageID], attachment)
+	}
+	return attachments, nil
+}
+
+// AttachToMessage links unlinked attachments to a message. It returns
+// ErrNotFound, and links none, if any of them is missing or already linked
+// to a message.
+func (r *AttachmentRepository) AttachToMessage(ctx context.Context, ids []uuid.UUID, messageID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE message_attachments SET message_id = $2
+		WHERE id = ANY($1) AND message_id IS NULL
+	`, ids, messageID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() != int64(len(ids)) {
+		return domain.ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteByMessageID deletes a message's attachments and returns them, so
+// their contents can be removed from storage
+func (r *AttachmentRepository) DeleteByMessageID(ctx context.Context, messageID uuid.UUID) ([]*domain.Attachment, error) {
+	query := `DELETE FROM message_attachments WHERE message_id = $1 RETURNING ` + attachmentColumns
+
+	rows, err := r.db.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAttachments(rows)
+}
+
+// scanAttachment scans a row selected with attachmentColumns
+func scanAttachment(row pgx.Row) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	err := row.Scan(
+		&attachment.ID, &attachment.OfficeID, &attachment.ConversationID, &attachment.MessageID, &attachment.UploadedBy,
+		&attachment.Filename, &attachment.ContentType, &attachment.SizeBytes, &attachment.StorageKey, &attachment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// scanAttachments scans all rows selected with attachmentColumns
+func scanAttachments(rows pgx.Rows) ([]*domain.Attachment, error) {
+	var attachments []*domain.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditRepository implements domain.AuditRepository
+type AuditRepository struct {
+	db conn
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: conn{db}}
+}
+
+const auditColumns = `id, actor_id, office_id, action, entity_type, entity_id, before, after, details, ip, created_at`
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, entry *domain.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (` + auditColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err := r.db.Exec(ctx, query,
+		entry.ID,
+		entry.ActorID,
+		entry.OfficeID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		entry.Before,
+		entry.After,
+		entry.Details,
+		nullableString(entry.IP),
+		entry.CreatedAt,
+	)
+	return err
+}
+
+// List returns a page of the audit log, most recent first, and how many
+// entries match the filter
+func (r *AuditRepository) List(ctx context.Context, filter domain.AuditFilter, limit, offset int) ([]*domain.AuditEntry, int, error) {
+	q := &queryBuilder{}
+	if filter.ActorID != uuid.Nil {
+		q.where("actor_id = " + q.arg(filter.ActorID))
+	}
+	if filter.OfficeID != uuid.Nil {
+		q.where("office_id = " + q.arg(filter.OfficeID))
+	}
+	if filter.Action != "" {
+		q.where("action = " + q.arg(filter.Action))
+	}
+	if filter.From != nil {
+		q.where("created_at >= " + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where("created_at < " + q.arg(*filter.To))
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log`+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+func scanAuditEntry(row pgx.Row) (*domain.AuditEntry, error) {
+	var entry domain.AuditEntry
+	var ip *string
+	err := row.Scan(
+		&entry.ID,
+		&entry.ActorID,
+		&entry.OfficeID,
+		&entry.Action,
+		&entry.EntityType,
+		&entry.EntityID,
+		&entry.Before,
+		&entry.After,
+		&entry.Details,
+		&ip,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if ip != nil {
+		entry.IP = *ip
+	}
+	return &entry, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuthTokenRepository implements domain.AuthTokenRepository
+type AuthTokenRepository struct {
+	db conn
+}
+
+// NewAuthTokenRepository creates a new AuthTokenRepository
+func NewAuthTokenRepository(db *pgxpool.Pool) *AuthTokenRepository {
+	return &AuthTokenRepository{db: conn{db}}
+}
+
+// Create stores a new auth token
+func (r *AuthTokenRepository) Create(ctx context.Context, token *domain.AuthToken) error {
+	query := `
+		INSERT INTO auth_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	return err
+}
+
+// Consume marks a token as used. The check and the update are one
+// statement, so concurrent requests cannot redeem the same token twice.
+func (r *AuthTokenRepository) Consume(ctx context.Context, tokenHash string, purpose domain.AuthTokenPurpose) (*domain.AuthToken, error) {
+	query := `
+		UPDATE auth_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+	`
+
+	var token domain.AuthToken
+	err := r.db.QueryRow(ctx, query, tokenHash, purpose).Scan(
+		&token.ID, &token.UserID, &token.Purpose, &token.TokenHash,
+		&token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Invalidate marks the user's outstanding tokens for purpose as used
+func (r *AuthTokenRepository) Invalidate(ctx context.Context, userID uuid.UUID, purpose domain.AuthTokenPurpose) error {
+	query := `UPDATE auth_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	_, err := r.db.Exec(ctx, query, userID, purpose)
+	return err
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuthorProfileRepository implements domain.AuthorProfileRepository
+type AuthorProfileRepository struct {
+	db conn
+}
+
+// NewAuthorProfileRepository creates a new AuthorProfileRepository
+func NewAuthorProfileRepository(db *pgxpool.Pool) *AuthorProfileRepository {
+	return &AuthorProfileRepository{db: conn{db}}
+}
+
+// profileSelect selects what scanProfile reads. Users without a profile row
+// get the defaults: their account name and no verification.
+const profileSelect = `
+	SELECT u.id, COALESCE(p.display_name, u.name), COALESCE(p.bio, ''), COALESCE(p.links, '[]'),
+	       COALESCE(p.verification_status, 'none'), COALESCE(p.verification_message, ''),
+	       p.verification_requested_at, COALESCE(p.verification_rejection_reason, ''),
+	       p.verification_reviewed_by, p.verification_reviewed_at, p.verified_at,
+	       COALESCE(p.created_at, u.created_at), COALESCE(p.updated_at, u.updated_at)
+	FROM users u
+	LEFT JOIN author_profiles p ON p.user_id = u.id`
+
+// scanProfile scans a row selected with profileSelect
+func scanProfile(row pgx.Row) (*domain.AuthorProfile, error) {
+	p := &domain.AuthorProfile{Verification: &domain.AuthorVerification{}}
+	var links []byte
+	err := row.Scan(
+		&p.UserID, &p.DisplayName, &p.Bio, &links,
+		&p.Verification.Status, &p.Verification.Message,
+		&p.Verification.RequestedAt, &p.Verification.RejectionReason,
+		&p.Verification.ReviewedBy, &p.Verification.ReviewedAt, &p.VerifiedAt,
+		&p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(links, &p.Links); err != nil {
+		return nil, err
+	}
+	p.IsVerified = p.Verification.Status == domain.AuthorVerificationVerified
+	return p, nil
+}
+
+// GetByUserID returns a user's profile
+func (r *AuthorProfileRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*domain.AuthorProfile, error) {
+	profile, err := scanProfile(r.db.QueryRow(ctx, profileSelect+`
+		WHERE u.id = $1 AND u.deleted_at IS NULL
+	`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return profile, err
+}
+
+// Save upserts the profile's editable fields and puts its display name on
+// the author's templates and bundles, in one transaction
+func (r *AuthorProfileRepository) Save(ctx context.Context, profile *domain.AuthorProfile) error {
+	links, err := marshalAuthorLinks(profile.Links)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO author_profiles (user_id, display_name, bio, links, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET display_name = EXCLUDED.display_name, bio = EXCLUDED.bio, links = EXCLUDED.links,
+		    updated_at = EXCLUDED.updated_at
+	`, profile.UserID, profile.DisplayName, profile.Bio, links, profile.CreatedAt, profile.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	renames := []string{
+		`UPDATE agent_templates SET author_name = $2 WHERE author_id = $1 AND author_name IS DISTINCT FROM $2`,
+		`UPDATE template_bundles SET author_name = $2 WHERE author_id = $1 AND author_name <> $2`,
+	}
+	for _, query := range renames {
+		if _, err := tx.Exec(ctx, query, profile.UserID, profile.DisplayName); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// SetVerification upserts the profile's verification. A user without a
+// profile row has no verification, so the row is only inserted when from
+// includes AuthorVerificationNone.
+func (r *AuthorProfileRepository) SetVerification(
+	ctx context.Context,
+	profile *domain.AuthorProfile,
+	from ...domain.AuthorVerificationStatus,
+) error {
+	links, err := marshalAuthorLinks(profile.Links)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]string, len(from))
+	for i, status := range from {
+		statuses[i] = string(status)
+	}
+
+	v := profile.Verification
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO author_profiles (
+			user_id, display_name, bio, links, verification_status, verification_message,
+			verification_requested_at, verification_rejection_reason, verification_reviewed_by,
+			verification_reviewed_at, verified_at, created_at, updated_at
+		)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+		WHERE 'none' = ANY($14::text[])
+		ON CONFLICT (user_id) DO UPDATE
+		SET verification_status = EXCLUDED.verification_status,
+		    verification_message = EXCLUDED.verification_message,
+		    verification_requested_at = EXCLUDED.verification_requested_at,
+		    verification_rejection_reason = EXCLUDED.verification_rejection_reason,
+		    verification_reviewed_by = EXCLUDED.verification_reviewed_by,
+		    verification_reviewed_at = EXCLUDED.verification_reviewed_at,
+		    verified_at = EXCLUDED.verified_at
+		WHERE author_profiles.verification_status = ANY($14::text[])
+	`, profile.UserID, profile.DisplayName, profile.Bio, links, v.Status, v.Message,
+		v.RequestedAt, v.RejectionReason, v.ReviewedBy, v.ReviewedAt, profile.VerifiedAt,
+		profile.CreatedAt, profile.UpdatedAt, statuses)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	profile.IsVerified = v.Status == domain.AuthorVerificationVerified
+	return nil
+}
+
+// ListByVerificationStatus returns a page of profiles by verification status
+func (r *AuthorProfileRepository) ListByVerificationStatus(
+	ctx context.Context,
+	status domain.AuthorVerificationStatus,
+	limit, offset int,
+) ([]*domain.AuthorProfile, int, error) {
+	var total int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM author_profiles p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.verification_status = $1 AND u.deleted_at IS NULL
+	`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(ctx, profileSelect+`
+		WHERE p.verification_status = $1 AND u.deleted_at IS NULL
+		ORDER BY p.verification_requested_at, p.user_id
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	profiles := []*domain.AuthorProfile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, total, rows.Err()
+}
+
+// GetStats sums up the author's approved public templates. The rating
+// average weighs each template's average by its number of ratings.
+func (r *AuthorProfileRepository) GetStats(ctx context.Context, userID uuid.UUID) (domain.AuthorStats, error) {
+	var stats domain.AuthorStats
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(download_count), 0),
+		       COALESCE(ROUND(SUM(rating_average * rating_count) / NULLIF(SUM(rating_count), 0), 2), 0)::float8,
+		       COALESCE(SUM(rating_count), 0)
+		FROM agent_templates
+		WHERE author_id = $1
+		  AND COALESCE(is_public, true) = true
+		  AND COALESCE(status, 'approved') = 'approved'
+	`, userID).Scan(&stats.TemplateCount, &stats.TotalDownloads, &stats.RatingAverage, &stats.RatingCount)
+	return stats, err
+}
+
+// marshalAuthorLinks encodes a profile's links, storing none as an empty
+// array
+func marshalAuthorLinks(links []domain.AuthorLink) ([]byte, error) {
+	if links == nil {
+		links = []domain.AuthorLink{}
+	}
+	return json.Marshal(links)
+}
@@ -0,0 +1,97 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestAuthorProfileSaveRenamesTemplates(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAuthorProfileRepository(testDB.Pool)
+	author := testDB.User(t)
+	template := testDB.Template(t, author)
+
+	profile, err := repo.GetByUserID(ctx, author)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if profile.DisplayName != "Test User" || profile.Verification.Status != domain.AuthorVerificationNone || len(profile.Links) != 0 {
+		t.Errorf("GetByUserID = %+v, want the account name and no verification", profile)
+	}
+
+	profile.DisplayName = "Ada Labs"
+	profile.Bio = "Agents for support teams."
+	profile.Links = []domain.AuthorLink{{Label: "Website", URL: "https://ada.example.com"}}
+	profile.UpdatedAt = time.Now()
+	if err := repo.Save(ctx, profile); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := repo.GetByUserID(ctx, author)
+	if err != nil || got.DisplayName != "Ada Labs" || got.Bio != profile.Bio || len(got.Links) != 1 || got.Links[0].URL != "https://ada.example.com" {
+		t.Errorf("GetByUserID = %+v, %v; want the saved profile", got, err)
+	}
+	var authorName string
+	if err := testDB.Pool.QueryRow(ctx, `SELECT author_name FROM agent_templates WHERE id = $1`, template).Scan(&authorName); err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+	if authorName != "Ada Labs" {
+		t.Errorf("template author_name = %q, want the new display name", authorName)
+	}
+
+	if _, err := repo.GetByUserID(ctx, uuid.New()); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByUserID of a missing user error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAuthorProfileSetVerificationFromStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewAuthorProfileRepository(testDB.Pool)
+	author := testDB.User(t)
+	testDB.Template(t, author)
+
+	profile, err := repo.GetByUserID(ctx, author)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+
+	// The author has no profile row yet; asking inserts one
+	now := time.Now()
+	profile.Verification = &domain.AuthorVerification{Status: domain.AuthorVerificationPending, Message: "hi", RequestedAt: &now}
+	if err := repo.SetVerification(ctx, profile, domain.AuthorVerificationNone, domain.AuthorVerificationRejected); err != nil {
+		t.Fatalf("SetVerification request: %v", err)
+	}
+	pending, _, err := repo.ListByVerificationStatus(ctx, domain.AuthorVerificationPending, 1000, 0)
+	if err != nil || !slices.ContainsFunc(pending, func(p *domain.AuthorProfile) bool { return p.UserID == author }) {
+		t.Errorf("ListByVerificationStatus = %v, %v; want the pending author", pending, err)
+	}
+
+	// Asking again while pending does not match
+	if err := repo.SetVerification(ctx, profile, domain.AuthorVerificationNone, domain.AuthorVerificationRejected); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second request error = %v, want ErrNotFound", err)
+	}
+
+	profile.Verification.Status = domain.AuthorVerificationVerified
+	profile.VerifiedAt = &now
+	if err := repo.SetVerification(ctx, profile, domain.AuthorVerificationPending); err != nil {
+		t.Fatalf("SetVerification verify: %v", err)
+	}
+	got, err := repo.GetByUserID(ctx, author)
+	if err != nil || !got.IsVerified || got.VerifiedAt == nil || got.Verification.Message != "hi" {
+		t.Errorf("GetByUserID = %+v, %v; want the author verified", got, err)
+	}
+
+	stats, err := repo.GetStats(ctx, author)
+	if err != nil || stats.TemplateCount != 1 {
+		t.Errorf("GetStats = %+v, %v; want one template", stats, err)
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AutoTopUpRepository implements domain.AutoTopUpRepository
+type AutoTopUpRepository struct {
+	db conn
+}
+
+// NewAutoTopUpRepository creates a new AutoTopUpRepository
+func NewAutoTopUpRepository(db *pgxpool.Pool) *AutoTopUpRepository {
+	return &AutoTopUpRepository{db: conn{db}}
+}
+
+// autoTopUpColumns are the columns scanned by scanAutoTopUp
+const autoTopUpColumns = `id, wallet_id, office_id, package, credits, amount_cents, payment_method_id, status,
+	       COALESCE(stripe_payment_intent_id, ''), credit_transaction_id, COALESCE(error, ''), created_at, completed_at`
+
+// scanAutoTopUp scans a row of autoTopUpColumns
+func scanAutoTopUp(row pgx.Row) (*domain.AutoTopUp, error) {
+	var t domain.AutoTopUp
+	if err := row.Scan(
+		&t.ID, &t.WalletID, &t.OfficeID, &t.Package, &t.Credits, &t.AmountCents, &t.PaymentMethodID, &t.Status,
+		&t.StripePaymentIntentID, &t.CreditTransactionID, &t.Error, &t.CreatedAt, &t.CompletedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// walletDueTopUp matches wallets w whose auto top-up is on, whose balance is
+// below their threshold and that have no top-up being charged and fewer
+// than their daily maximum since the first parameter. Failed top-ups do not
+// count.
+const walletDueTopUp = `
+	w.auto_top_up_enabled AND w.balance < w.low_balance_threshold
+	AND NOT EXISTS (SELECT 1 FROM credit_auto_top_ups p WHERE p.wallet_id = w.id AND p.status = 'pending')
+	AND (SELECT COUNT(*) FROM credit_auto_top_ups t
+	     WHERE t.wallet_id = w.id AND t.status <> 'failed' AND t.created_at >= $1) < w.auto_top_up_max_per_day`
+
+// GetDueWallets retrieves wallets due a top-up, least recently updated
+// first
+func (r *AutoTopUpRepository) GetDueWallets(ctx context.Context, dayStart time.Time, limit int) ([]*domain.CreditWallet, error) {
+	query := `SELECT ` + walletColumns + ` FROM credit_wallets w WHERE ` + walletDueTopUp + `
+		ORDER BY w.updated_at ASC
+		LIMIT $2`
+	rows, err := r.db.Query(ctx, query, dayStart, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []*domain.CreditWallet{}
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, wallet)
+	}
+	return wallets, rows.Err()
+}
+
+// Claim inserts a pending top-up if its wallet is still due one with the
+// same package and payment method. A wallet's pending top-up is unique, so
+// replicas claiming the same wallet insert one between them.
+func (r *AutoTopUpRepository) Claim(ctx context.Context, topUp *domain.AutoTopUp, dayStart time.Time) error {
+	query := `
+		INSERT INTO credit_auto_top_ups (id, wallet_id, office_id, package, credits, amount_cents, payment_method_id,
+		                                 status, created_at)
+		SELECT $2::uuid, w.id, w.office_id, $4::varchar, $5::bigint, $6::bigint, $7::varchar, 'pending', $8::timestamptz
+		FROM credit_wallets w
+		WHERE w.id = $3 AND w.auto_top_up_package = $4 AND w.auto_top_up_payment_method = $7 AND ` + walletDueTopUp + `
+		ON CONFLICT DO NOTHING
+		RETURNING office_id
+	`
+	err := r.db.QueryRow(ctx, query,
+		dayStart, topUp.ID, topUp.WalletID, topUp.Package, topUp.Credits, topUp.AmountCents,
+		topUp.PaymentMethodID, topUp.CreatedAt,
+	).Scan(&topUp.OfficeID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	topUp.Status = domain.AutoTopUpStatusPending
+	return nil
+}
+
+// GetStalePending retrieves top-ups pending since before, oldest first
+func (r *AutoTopUpRepository) GetStalePending(ctx context.Context, before time.Time, limit int) ([]*domain.AutoTopUp, error) {
+	query := `SELECT ` + autoTopUpColumns + ` FROM credit_auto_top_ups
+		WHERE status = 'pending' AND created_at < $1
+		ORDER BY created_at ASC
+		LIMIT $2`
+	return r.queryTopUps(ctx, query, before, limit)
+}
+
+// Resolve records a pending top-up's status, payment, credit transaction
+// and error
+func (r *AutoTopUpRepository) Resolve(ctx context.Context, topUp *domain.AutoTopUp) error {
+	query := `
+		UPDATE credit_auto_top_ups
+		SET status = $2, stripe_payment_intent_id = $3, credit_transaction_id = $4, error = $5, completed_at = $6
+		WHERE id = $1 AND status = 'pending'
+	`
+	tag, err := r.db.Exec(ctx, query,
+		topUp.ID, topUp.Status, nullableString(topUp.StripePaymentIntentID), topUp.CreditTransactionID,
+		nullableString(topUp.Error), topUp.CompletedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ListByOffice retrieves the office's most recent top-ups, newest first
+func (r *AutoTopUpRepository) ListByOffice(ctx context.Context, officeID uuid.UUID, limit int) ([]*domain.AutoTopUp, error) {
+	query := `SELECT ` + autoTopUpColumns + ` FROM credit_auto_top_ups
+		WHERE office_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+	return r.queryTopUps(ctx, query, officeID, limit)
+}
+
+// queryTopUps runs a query of autoTopUpColumns
+func (r *AutoTopUpRepository) queryTopUps(ctx context.Context, query string, args ...any) ([]*domain.AutoTopUp, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topUps := []*domain.AutoTopUp{}
+	for rows.Next() {
+		topUp, err := scanAutoTopUp(rows)
+		if err != nil {
+			return nil, err
+		}
+		topUps = append(topUps, topUp)
+	}
+	return topUps, rows.Err()
+}
@@ -0,0 +1,109 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestAutoTopUpsAreCappedPerDay(t *testing.T) {
+	ctx := context.Background()
+	credits := repository.NewCreditRepository(testDB.Pool)
+	repo := repository.NewAutoTopUpRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	testDB.Wallet(t, office, 40)
+
+	wallet, err := credits.GetWalletByOfficeID(ctx, office)
+	if err != nil {
+		t.Fatalf("GetWalletByOfficeID: %v", err)
+	}
+	if wallet.LowBalanceThreshold != 100 || wallet.AutoTopUpEnabled || wallet.AutoTopUpMaxPerDay != 1 {
+		t.Errorf("new wallet = %+v, want the default warning and auto top-up off", wallet)
+	}
+	wallet.LowBalanceThreshold, wallet.AutoTopUpEnabled = 500, true
+	wallet.AutoTopUpPackage, wallet.AutoTopUpPaymentMethod, wallet.AutoTopUpMaxPerDay = "medium", "pm_card", 2
+	if err := credits.UpdateLowBalanceSettings(ctx, wallet); err != nil {
+		t.Fatalf("UpdateLowBalanceSettings: %v", err)
+	}
+
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	due := func() bool {
+		wallets, err := repo.GetDueWallets(ctx, dayStart, 1000)
+		if err != nil {
+			t.Fatalf("GetDueWallets: %v", err)
+		}
+		for _, w := range wallets {
+			if w.ID == wallet.ID {
+				return true
+			}
+		}
+		return false
+	}
+	claim := func() (*domain.AutoTopUp, error) {
+		topUp := &domain.AutoTopUp{
+			ID: uuid.New(), WalletID: wallet.ID, Package: "medium", Credits: 25000, AmountCents: 4000,
+			PaymentMethodID: "pm_card", CreatedAt: time.Now(),
+		}
+		return topUp, repo.Claim(ctx, topUp, dayStart)
+	}
+	resolve := func(topUp *domain.AutoTopUp, status domain.AutoTopUpStatus) error {
+		now := time.Now()
+		topUp.Status, topUp.CompletedAt = status, &now
+		return repo.Resolve(ctx, topUp)
+	}
+
+	if !due() {
+		t.Fatal("wallet below its threshold is not due a top-up")
+	}
+	first, err := claim()
+	if err != nil || first.OfficeID != office {
+		t.Fatalf("Claim = %+v, %v; want the office's pending top-up", first, err)
+	}
+	// One top-up is charged at a time
+	if _, err := claim(); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Claim while pending error = %v, want ErrNotFound", err)
+	}
+	if due() {
+		t.Error("wallet with a pending top-up is due another")
+	}
+	if err := resolve(first, domain.AutoTopUpStatusSucceeded); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if err := resolve(first, domain.AutoTopUpStatusFailed); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Resolve again error = %v, want ErrNotFound", err)
+	}
+
+	// Failed top-ups do not count against the cap
+	failed, err := claim()
+	if err != nil {
+		t.Fatalf("Claim second: %v", err)
+	}
+	if err := resolve(failed, domain.AutoTopUpStatusFailed); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	second, err := claim()
+	if err != nil {
+		t.Fatalf("Claim third: %v", err)
+	}
+	if err := resolve(second, domain.AutoTopUpStatusSucceeded); err != nil {
+		t.Fatalf("Resolve third: %v", err)
+	}
+	if _, err := claim(); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Claim over the daily cap error = %v, want ErrNotFound", err)
+	}
+	if due() {
+		t.Error("wallet over its daily cap is due a top-up")
+	}
+
+	topUps, err := repo.ListByOffice(ctx, office, 10)
+	if err != nil || len(topUps) != 3 || topUps[0].ID != second.ID {
+		t.Errorf("ListByOffice = %d top-ups, %v; want the 3, newest first", len(topUps), err)
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lastMessagePreviewLength is how many characters of a conversation's latest
+// message are returned for previews
+const lastMessagePreviewLength = 200
+
+// ConversationReadRepository implements domain.ConversationReadRepository
+type ConversationReadRepository struct {
+	db conn
+}
+
+// NewConversationReadRepository creates a new ConversationReadRepository
+func NewConversationReadRepository(db *pgxpool.Pool) *ConversationReadRepository {
+	return &ConversationReadRepository{db: conn{db}}
+}
+
+// MarkRead upserts the user's read position, keeping the existing one when
+// it is already past the given message
+func (r *ConversationReadRepository) MarkRead(ctx context.Context, read *domain.ConversationRead) (*domain.ConversationRead, error) {
+	query := `
+		INSERT INTO conversation_reads (conversation_id, user_id, last_read_message_id, last_read_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET
+			last_read_message_id = EXCLUDED.last_read_message_id,
+			last_read_at = EXCLUDED.last_read_at,
+			updated_at = NOW()
+		WHERE (EXCLUDED.last_read_at, EXCLUDED.last_read_message_id)
+			> (conversation_reads.last_read_at, conversation_reads.last_read_message_id)
+	`
+	if _, err := r.db.Exec(ctx, query, read.ConversationID, read.UserID, read.LastReadMessageID, read.LastReadAt); err != nil {
+		return nil, err
+	}
+
+	var current domain.ConversationRead
+	err := r.db.QueryRow(ctx, `
+		SELECT conversation_id, user_id, last_read_message_id, last_read_at, updated_at
+		FROM conversation_reads WHERE conversation_id = $1 AND user_id = $2
+	`, read.ConversationID, read.UserID).Scan(
+		&current.ConversationID, &current.UserID, &current.LastReadMessageID, &current.LastReadAt, &current.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// GetActivity counts, per conversation, the messages after the user's read
+// position that others sent, and loads a preview of the latest message.
+// Conversations the user never read count every such message as unread.
+func (r *ConversationReadRepository) GetActivity(
+	ctx context.Context,
+	userID uuid.UUID,
+	conversationIDs []uuid.UUID,
+) (map[uuid.UUID]*domain.ConversationActivity, error) {
+	activity := make(map[uuid.UUID]*domain.ConversationActivity, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return activity, nil
+	}
+
+	query := `SELECT c.id, ` + activityColumns + ` FROM unnest($2::uuid[]) AS c(id) ` + activityJoins
+
+	rows, err := r.db.Query(ctx, query, userID, conversationIDs, lastMessagePreviewLength)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID uuid.UUID
+		var a domain.ConversationActivity
+		var preview messagePreview
+		if err := rows.Scan(append([]any{&conversationID, &a.UnreadCount}, preview.dest()...)...); err != nil {
+			return nil, err
+		}
+		a.LastMessage = preview.message(conversationID)
+		activity[conversationID] = &a
+	}
+	return activity, rows.Err()
+}
+
+// activityColumns select the unread count of a conversation, aliased as c,
+// and a preview of its latest message, scanned by messagePreview. The
+// query joins activityJoins and takes the user as $1 and the preview
+// length as $3.
+const activityColumns = `
+	(SELECT COUNT(*) FROM messages m
+		WHERE m.conversation_id = c.id AND m.deleted_at IS NULL
+		AND NOT (m.sender_type = 'user' AND m.sender_id = $1)
+		AND (cr.last_read_at IS NULL OR (m.created_at, m.id) > (cr.last_read_at, cr.last_read_message_id))),
+	lm.id, lm.office_id, lm.sender_type, lm.sender_id, LEFT(lm.content, $3), lm.created_at
+`
+
+const activityJoins = `
+	LEFT JOIN conversation_reads cr ON cr.conversation_id = c.id AND cr.user_id = $1
+	LEFT JOIN LATERAL (
+		SELECT id, office_id, sender_type, sender_id, content, created_at FROM messages
+		WHERE conversation_id = c.id AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	) lm ON TRUE
+`
+
+// messagePreview holds the latest message columns of activityColumns,
+// which are null for conversations without messages
+type messagePreview struct {
+	id, officeID, senderID *uuid.UUID
+	senderType, content    *string
+	createdAt              *time.Time
+}
+
+func (p *messagePreview) dest() []any {
+	return []any{&p.id, &p.officeID, &p.senderType, &p.senderID, &p.content, &p.createdAt}
+}
+
+// message returns the previewed message of the conversation, or nil if it
+// has none
+func (p *messagePreview) message(conversationID uuid.UUID) *domain.Message {
+	if p.id == nil {
+		return nil
+	}
+	return &domain.Message{
+		ID:             *p.id,
+		OfficeID:       *p.officeID,
+		ConversationID: conversationID,
+		SenderType:     domain.SenderType(*p.senderType),
+		SenderID:       *p.senderID,
+		Content:        *p.content,
+		CreatedAt:      *p.createdAt,
+	}
+}
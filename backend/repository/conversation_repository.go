@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -12,58 +13,55 @@ import (
 
 // ConversationRepository implements domain.ConversationRepository
 type ConversationRepository struct {
-	db        *pgxpool.Pool
-	agentRepo *AgentRepository
+	db conn
 }
 
 // NewConversationRepository creates a new ConversationRepository
-func NewConversationRepository(db *pgxpool.Pool, agentRepo *AgentRepository) *ConversationRepository {
-	return &ConversationRepository{db: db, agentRepo: agentRepo}
+func NewConversationRepository(db *pgxpool.Pool) *ConversationRepository {
+	return &ConversationRepository{db: conn{db}}
 }
 
 // Create creates a new conversation
 func (r *ConversationRepository) Create(ctx context.Context, conversation *domain.Conversation) error {
 	query := `
-		INSERT INTO conversations (id, office_id, type, name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO conversations (id, office_id, type, name, orchestration_mode, moderator_agent_id, debate_rounds, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	_, err := r.db.Exec(ctx, query,
-		conversation.ID, conversation.OfficeID, conversation.Type,
-		nullableString(conversation.Name), conversation.CreatedAt, conversation.UpdatedAt,
+		conversation.ID, conversation.OfficeID, conversation.Type, nullableString(conversation.Name),
+		conversation.OrchestrationMode, conversation.ModeratorID, conversation.DebateRounds,
+		conversation.CreatedAt, conversation.UpdatedAt,
 	)
 	return err
 }
 
+const conversationColumns = `c.id, c.office_id, c.type, c.name, c.archived_at, c.orchestration_mode, c.moderator_agent_id,
+	c.debate_rounds, c.created_at, c.updated_at, c.summary, c.summarized_at`
+
 // GetByID returns a conversation by ID
 func (r *ConversationRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Conversation, error) {
-	query := `SELECT id, office_id, type, name, created_at, updated_at FROM conversations WHERE id = $1`
-
-	var conversation domain.Conversation
-	var name *string
+	query := `SELECT ` + conversationColumns + ` FROM conversations c WHERE c.id = $1 AND c.deleted_at IS NULL`
 
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&conversation.ID, &conversation.OfficeID, &conversation.Type,
-		&name, &conversation.CreatedAt, &conversation.UpdatedAt,
-	)
+	conversation, err := scanConversation(r.db.QueryRow(ctx, query, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	if name != nil {
-		conversation.Name = *name
-	}
-
-	return &conversation, nil
+	return conversation, nil
 }
 
-// GetByOfficeID returns all conversations for an office
-func (r *ConversationRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Conversation, error) {
-	query := `SELECT id, office_id, type, name, created_at, updated_at FROM conversations WHERE office_id = $1 ORDER BY updated_at DESC`
+// GetByOfficeID returns the conversations of an office, without archived
+// ones unless includeArchived is set
+func (r *ConversationRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID, includeArchived bool) ([]*domain.Conversation, error) {
+	query := `
+		SELECT ` + conversationColumns + ` FROM conversations c
+		WHERE c.office_id = $1 AND c.deleted_at IS NULL AND ($2 OR c.archived_at IS NULL)
+		ORDER BY c.updated_at DESC
+	`
 
-	rows, err := r.db.Query(ctx, query, officeID)
+	rows, err := r.db.Query(ctx, query, officeID, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -71,21 +69,95 @@ func (r *ConversationRepository) GetByOfficeID(ctx context.Context, officeID uui
 
 	var conversations []*domain.Conversation
 	for rows.Next() {
-		var conversation domain.Conversation
-		var name *string
-
-		if err := rows.Scan(
-			&conversation.ID, &conversation.OfficeID, &conversation.Type,
-			&name, &conversation.CreatedAt, &conversation.UpdatedAt,
-		); err != nil {
+		conversation, err := scanConversation(rows)
+		if err != nil {
 			return nil, err
 		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, rows.Err()
+}
+
+// GetPreviewsByOfficeID returns the conversations GetByOfficeID returns,
+// each with its participants, the user's unread count and a preview of the
+// latest message. Both queries are sent in one batch, so the list takes a
+// single round trip.
+func (r *ConversationRepository) GetPreviewsByOfficeID(
+	ctx context.Context,
+	officeID, userID uuid.UUID,
+	includeArchived bool,
+) ([]*domain.Conversation, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		SELECT `+conversationColumns+`, `+activityColumns+`
+		FROM conversations c `+activityJoins+`
+		WHERE c.office_id = $2 AND c.deleted_at IS NULL AND ($4 OR c.archived_at IS NULL)
+		ORDER BY c.updated_at DESC
+	`, userID, officeID, lastMessagePreviewLength, includeArchived)
+	batch.Queue(`
+		SELECT `+agentColumns+`, cp.conversation_id
+		FROM `+agentTables+`
+		JOIN conversation_participants cp ON cp.agent_id = a.id
+		JOIN conversations c ON c.id = cp.conversation_id
+		WHERE c.office_id = $1 AND c.deleted_at IS NULL AND a.deleted_at IS NULL AND ($2 OR c.archived_at IS NULL)
+		ORDER BY cp.joined_at, cp.agent_id
+	`, officeID, includeArchived)
+
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	conversations, err := scanConversationPreviews(results)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*domain.Conversation, len(conversations))
+	for _, conversation := range conversations {
+		byID[conversation.ID] = conversation
+	}
 
-		if name != nil {
-			conversation.Name = *name
+	rows, err := results.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var conversationID uuid.UUID
+		agent, err := scanAgent(rows, &conversationID)
+		if err != nil {
+			return nil, err
 		}
+		// A conversation created or unarchived between the two queries is
+		// not listed
+		if conversation, ok := byID[conversationID]; ok {
+			conversation.Participants = append(conversation.Participants, agent)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// scanConversationPreviews reads the conversations of the first query of
+// GetPreviewsByOfficeID
+func scanConversationPreviews(results pgx.BatchResults) ([]*domain.Conversation, error) {
+	rows, err := results.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		conversations = append(conversations, &conversation)
+	var conversations []*domain.Conversation
+	for rows.Next() {
+		var unread int
+		var preview messagePreview
+		conversation, err := scanConversation(rows, append([]any{&unread}, preview.dest()...)...)
+		if err != nil {
+			return nil, err
+		}
+		conversation.UnreadCount = unread
+		conversation.LastMessage = preview.message(conversation.ID)
+		conversations = append(conversations, conversation)
 	}
 	return conversations, rows.Err()
 }
@@ -108,42 +180,86 @@ func (r *ConversationRepository) RemoveParticipant(ctx context.Context, conversa
 	return err
 }
 
-// GetParticipants returns all agents in a conversation
+// GetParticipants returns all agents in a conversation, in the order they
+// joined. Deleted agents are left out until they are restored.
 func (r *ConversationRepository) GetParticipants(ctx context.Context, conversationID uuid.UUID) ([]*domain.Agent, error) {
-	query := `SELECT agent_id FROM conversation_participants WHERE conversation_id = $1`
+	query := agentSelect + `
+		JOIN conversation_participants cp ON cp.agent_id = a.id
+		WHERE cp.conversation_id = $1 AND a.deleted_at IS NULL
+		ORDER BY cp.joined_at, cp.agent_id
+	`
+	return queryAgents(ctx, r.db, query, conversationID)
+}
 
-	rows, err := r.db.Query(ctx, query, conversationID)
+// Update updates a conversation
+func (r *ConversationRepository) Update(ctx context.Context, conversation *domain.Conversation) error {
+	query := `
+		UPDATE conversations
+		SET name = $2, archived_at = $3, orchestration_mode = $4, moderator_agent_id = $5, debate_rounds = $6, updated_at = $7
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query,
+		conversation.ID, nullableString(conversation.Name), conversation.ArchivedAt,
+		conversation.OrchestrationMode, conversation.ModeratorID, conversation.DebateRounds, conversation.UpdatedAt,
+	)
+	return err
+}
+
+// SoftDelete hides a conversation with its messages and pauses its
+// schedules
+func (r *ConversationRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback(ctx)
 
-	var agents []*domain.Agent
-	for rows.Next() {
-		var agentID uuid.UUID
-		if err := rows.Scan(&agentID); err != nil {
-			return nil, err
-		}
+	tag, err := tx.Exec(ctx, `UPDATE conversations SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
 
-		agent, err := r.agentRepo.GetByID(ctx, agentID)
-		if err != nil {
-			continue // Skip if agent not found
-		}
-		agents = append(agents, agent)
+	if _, err := tx.Exec(ctx, `
+		UPDATE scheduled_tasks SET is_active = FALSE, next_run_at = NULL
+		WHERE is_active AND conversation_id = $1
+	`, id); err != nil {
+		return err
 	}
-	return agents, rows.Err()
+
+	return tx.Commit(ctx)
 }
 
-// Update updates a conversation
-func (r *ConversationRepository) Update(ctx context.Context, conversation *domain.Conversation) error {
-	query := `UPDATE conversations SET name = $2, updated_at = $3 WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, conversation.ID, nullableString(conversation.Name), conversation.UpdatedAt)
-	return err
+// Restore undeletes a conversation of the office that was deleted after
+// deletedAfter. Its schedules stay paused.
+func (r *ConversationRepository) Restore(ctx context.Context, officeID, id uuid.UUID, deletedAfter time.Time) error {
+	query := `UPDATE conversations SET deleted_at = NULL WHERE id = $1 AND office_id = $2 AND deleted_at > $3`
+	tag, err := r.db.Exec(ctx, query, id, officeID, deletedAfter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
 }
 
-// Delete deletes a conversation
-func (r *ConversationRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM conversations WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, id)
-	return err
+// scanConversation scans a row of conversationColumns, followed by the extra
+// columns into extra
+func scanConversation(row pgx.Row, extra ...any) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	var name *string
+	if err := row.Scan(append([]any{
+		&conversation.ID, &conversation.OfficeID, &conversation.Type, &name, &conversation.ArchivedAt,
+		&conversation.OrchestrationMode, &conversation.ModeratorID, &conversation.DebateRounds,
+		&conversation.CreatedAt, &conversation.UpdatedAt, &conversation.Summary, &conversation.SummarizedAt,
+	}, extra...)...); err != nil {
+		return nil, err
+	}
+	if name != nil {
+		conversation.Name = *name
+	}
+	return &conversation, nil
 }
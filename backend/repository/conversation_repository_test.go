@@ -0,0 +1,148 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// newConversation creates a group conversation of the office updated at
+// updatedAt, with an agent of templateID for each participant
+func newConversation(t *testing.T, officeID, templateID uuid.UUID, updatedAt time.Time, participants int) (*domain.Conversation, []uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewConversationRepository(testDB.Pool)
+
+	conversation := &domain.Conversation{
+		ID:                uuid.New(),
+		OfficeID:          officeID,
+		Type:              domain.ConversationTypeGroup,
+		Name:              "Standup",
+		OrchestrationMode: domain.OrchestrationMentions,
+		DebateRounds:      domain.DefaultDebateRounds,
+		CreatedAt:         updatedAt,
+		UpdatedAt:         updatedAt,
+	}
+	if err := repo.Create(ctx, conversation); err != nil {
+		t.Fatalf("Create conversation: %v", err)
+	}
+
+	var joined []uuid.UUID
+	for i := 0; i < participants; i++ {
+		agent := newAgent(t, officeID, templateID, "1.0.0", updatedAt)
+		if err := repo.AddParticipant(ctx, conversation.ID, agent.ID); err != nil {
+			t.Fatalf("AddParticipant: %v", err)
+		}
+		joined = append(joined, agent.ID)
+	}
+	return conversation, joined
+}
+
+func TestConversationGetParticipants(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewConversationRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	templateID := testDB.Template(t, testDB.User(t))
+
+	// Agents of the office outside the conversation are not participants
+	newAgent(t, office, templateID, "1.0.0", time.Now())
+	conversation, joined := newConversation(t, office, templateID, time.Now(), 3)
+
+	participants, err := repo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("GetParticipants: %v", err)
+	}
+	if len(participants) != len(joined) {
+		t.Fatalf("GetParticipants returned %d agents, want %d", len(participants), len(joined))
+	}
+	for i, agent := range participants {
+		if agent.Template == nil || agent.Template.ID != templateID {
+			t.Errorf("participant %s has template %+v, want %s", agent.ID, agent.Template, templateID)
+		}
+		if agent.ID != joined[i] {
+			t.Errorf("participant %d is %s, want %s", i, agent.ID, joined[i])
+		}
+	}
+}
+
+func TestConversationGetPreviewsByOfficeID(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewConversationRepository(testDB.Pool)
+	messages := repository.NewMessageRepository(testDB.Pool)
+	user := testDB.User(t)
+	office := testDB.Office(t, user)
+	templateID := testDB.Template(t, testDB.User(t))
+	now := time.Now()
+
+	quiet, _ := newConversation(t, office, templateID, now.Add(-time.Hour), 1)
+	busy, busyAgents := newConversation(t, office, templateID, now, 2)
+	archived, _ := newConversation(t, office, templateID, now.Add(-2*time.Hour), 1)
+	archived.ArchivedAt = &now
+	if err := repo.Update(ctx, archived); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// The user's own message is not unread
+	for i, sender := range []struct {
+		senderType domain.SenderType
+		senderID   uuid.UUID
+	}{
+		{domain.SenderTypeUser, user},
+		{domain.SenderTypeAgent, busyAgents[0]},
+		{domain.SenderTypeAgent, busyAgents[1]},
+	} {
+		message := &domain.Message{
+			ID:             uuid.New(),
+			OfficeID:       office,
+			ConversationID: busy.ID,
+			SenderType:     sender.senderType,
+			SenderID:       sender.senderID,
+			Content:        "update",
+			CreatedAt:      now.Add(time.Duration(i) * time.Second),
+		}
+		if err := messages.Create(ctx, message); err != nil {
+			t.Fatalf("Create message: %v", err)
+		}
+	}
+
+	conversations, err := repo.GetPreviewsByOfficeID(ctx, office, user, false)
+	if err != nil {
+		t.Fatalf("GetPreviewsByOfficeID: %v", err)
+	}
+	if len(conversations) != 2 || conversations[0].ID != busy.ID || conversations[1].ID != quiet.ID {
+		t.Fatalf("GetPreviewsByOfficeID returned %d conversations, want the 2 unarchived ones, latest first", len(conversations))
+	}
+
+	got := conversations[0]
+	if len(got.Participants) != 2 || got.Participants[0].ID != busyAgents[0] || got.Participants[1].ID != busyAgents[1] {
+		t.Errorf("participants = %d agents, want both agents in join order", len(got.Participants))
+	}
+	if got.Participants[0].Template == nil {
+		t.Errorf("participant has no template")
+	}
+	if got.UnreadCount != 2 {
+		t.Errorf("unread count = %d, want 2", got.UnreadCount)
+	}
+	if got.LastMessage == nil || got.LastMessage.SenderID != busyAgents[1] || got.LastMessage.ConversationID != busy.ID {
+		t.Errorf("last message = %+v, want the second agent's message", got.LastMessage)
+	}
+
+	if quietPreview := conversations[1]; len(quietPreview.Participants) != 1 || quietPreview.LastMessage != nil || quietPreview.UnreadCount != 0 {
+		t.Errorf("quiet conversation has %d participants, last message %v and %d unread; want 1, none and 0",
+			len(quietPreview.Participants), quietPreview.LastMessage, quietPreview.UnreadCount)
+	}
+
+	conversations, err = repo.GetPreviewsByOfficeID(ctx, office, user, true)
+	if err != nil {
+		t.Fatalf("GetPreviewsByOfficeID: %v", err)
+	}
+	if len(conversations) != 3 || conversations[2].ID != archived.ID || len(conversations[2].Participants) != 1 {
+		t.Errorf("GetPreviewsByOfficeID with archived returned %d conversations, want 3 with the archived one last", len(conversations))
+	}
+}
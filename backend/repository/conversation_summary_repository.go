@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ConversationSummaryRepository implements domain.ConversationSummaryRepository
+type ConversationSummaryRepository struct {
+	db conn
+}
+
+// NewConversationSummaryRepository creates a new ConversationSummaryRepository
+func NewConversationSummaryRepository(db *pgxpool.Pool) *ConversationSummaryRepository {
+	return &ConversationSummaryRepository{db: conn{db}}
+}
+
+// summaryRunning holds for a run of summaryTables whose task may still run,
+// or whose task is still being created
+const summaryRunning = `(t.status IN ('pending', 'thinking', 'working')
+	OR (t.status = 'failed' AND t.next_attempt_at IS NOT NULL)
+	OR (s.task_id IS NULL AND s.created_at > NOW() - INTERVAL '5 minutes'))`
+
+// summaryColumns are the columns scanned by scanSummary, selected from
+// summaryTables. Runs are pending while they hold their conversation's claim
+// and their task may still run, then failed until they are completed.
+const summaryColumns = `s.id, s.office_id, s.conversation_id, s.task_id, s.agent_id,
+	CASE
+		WHEN s.completed_at IS NOT NULL THEN 'done'
+		WHEN s.status = 'pending' AND ` + summaryRunning + ` THEN 'pending'
+		ELSE 'failed'
+	END,
+	s.through_message_id, s.through_at, s.message_count, s.auto, s.content, s.message_id, s.created_at, s.completed_at`
+
+const summaryTables = `conversation_summaries s LEFT JOIN tasks t ON t.id = s.task_id`
+
+// Create stores a pending summarization run, claiming its conversation. A
+// claim left by a run whose task failed or is gone is released first; one
+// held by a run in progress makes Create return domain.ErrAlreadyExists.
+func (r *ConversationSummaryRepository) Create(ctx context.Context, summary *domain.ConversationSummary) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE conversation_summaries SET status = 'failed'
+		WHERE id IN (
+			SELECT s.id FROM `+summaryTables+`
+			WHERE s.conversation_id = $1 AND s.status = 'pending' AND NOT `+summaryRunning+`
+		)
+	`, summary.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_summaries (id, office_id, conversation_id, task_id, agent_id, status,
+			through_message_id, through_at, message_count, auto, created_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $7, $8, $9, $10)
+	`, summary.ID, summary.OfficeID, summary.ConversationID, summary.TaskID, summary.AgentID,
+		summary.ThroughMessageID, summary.ThroughAt, summary.MessageCount, summary.Auto, summary.CreatedAt,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return domain.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetTask records the task a pending run started
+func (r *ConversationSummaryRepository) SetTask(ctx context.Context, id, taskID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `UPDATE conversation_summaries SET task_id = $2 WHERE id = $1`, id, taskID)
+	return err
+}
+
+// Fail marks a pending run failed, releasing its conversation's claim
+func (r *ConversationSummaryRepository) Fail(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `UPDATE conversation_summaries SET status = 'failed' WHERE id = $1 AND status = 'pending'`, id)
+	return err
+}
+
+// GetByTaskID returns the run of a summary task
+func (r *ConversationSummaryRepository) GetByTaskID(ctx context.Context, taskID uuid.UUID) (*domain.ConversationSummary, error) {
+	query := `SELECT ` + summaryColumns + ` FROM ` + summaryTables + ` WHERE s.task_id = $1`
+	return r.get(ctx, query, taskID)
+}
+
+// GetLatest returns the newest run of a conversation
+func (r *ConversationSummaryRepository) GetLatest(ctx context.Context, conversationID uuid.UUID) (*domain.ConversationSummary, error) {
+	query := `
+		SELECT ` + summaryColumns + ` FROM ` + summaryTables + `
+		WHERE s.conversation_id = $1
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT 1
+	`
+	return r.get(ctx, query, conversationID)
+}
+
+// GetLatestDone returns the completed run of a conversation that
+// summarized the furthest
+func (r *ConversationSummaryRepository) GetLatestDone(ctx context.Context, conversationID uuid.UUID) (*domain.ConversationSummary, error) {
+	query := `
+		SELECT ` + summaryColumns + ` FROM ` + summaryTables + `
+		WHERE s.conversation_id = $1 AND s.completed_at IS NOT NULL
+		ORDER BY s.through_at DESC, s.completed_at DESC
+		LIMIT 1
+	`
+	return r.get(ctx, query, conversationID)
+}
+
+func (r *ConversationSummaryRepository) get(ctx context.Context, query string, args ...any) (*domain.ConversationSummary, error) {
+	summary, err := scanSummary(r.db.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Complete saves a run's summary and posts it as a pinned system message in
+// place of the conversation's previous one, returning domain.ErrNotFound if
+// the run was already completed
+func (r *ConversationSummaryRepository) Complete(ctx context.Context, summary *domain.ConversationSummary, message *domain.Message) error {
+	metadataJSON, err := json.Marshal(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content, metadata, created_at, pinned_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, message.ID, message.OfficeID, message.ConversationID, message.SenderType, message.SenderID,
+		message.Content, metadataJSON, message.CreatedAt, message.PinnedAt)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE conversation_summaries SET content = $2, message_id = $3, completed_at = $4, status = 'done'
+		WHERE id = $1 AND completed_at IS NULL
+	`, summary.ID, summary.Content, summary.MessageID, summary.CompletedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE messages SET pinned_at = NULL
+		WHERE conversation_id = $1 AND sender_type = 'system' AND pinned_at IS NOT NULL AND id <> $2
+	`, summary.ConversationID, message.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE conversations SET summary = $2, summarized_at = $3 WHERE id = $1`,
+		summary.ConversationID, summary.Content, summary.CompletedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetUnsummarized returns up to limit conversations that are neither
+// archived nor deleted, received a message after since and have at least
+// minMessages top-level, undeleted messages after their latest summary.
+// Conversations with a run started after since are left out, so failed runs
+// are not retried right away.
+func (r *ConversationSummaryRepository) GetUnsummarized(ctx context.Context, minMessages int, since time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT c.id
+		FROM conversations c
+		LEFT JOIN LATERAL (
+			SELECT s.through_message_id, s.through_at FROM conversation_summaries s
+			WHERE s.conversation_id = c.id AND s.completed_at IS NOT NULL
+			ORDER BY s.through_at DESC
+			LIMIT 1
+		) last ON true
+		WHERE c.deleted_at IS NULL AND c.archived_at IS NULL
+			AND EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.created_at > $2)
+			AND NOT EXISTS (SELECT 1 FROM conversation_summaries s WHERE s.conversation_id = c.id AND s.created_at > $2)
+			AND (
+				SELECT COUNT(*) FROM messages m
+				WHERE m.conversation_id = c.id AND m.parent_message_id IS NULL AND m.deleted_at IS NULL
+					AND m.sender_type <> 'system'
+					AND (last.through_at IS NULL OR (m.created_at, m.id) > (last.through_at, last.through_message_id))
+			) >= $1
+		ORDER BY c.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, minMessages, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// scanSummary scans a row selected with summaryColumns
+func scanSummary(row pgx.Row) (*domain.ConversationSummary, error) {
+	var summary domain.ConversationSummary
+	err := row.Scan(
+		&summary.ID, &summary.OfficeID, &summary.ConversationID, &summary.TaskID, &summary.AgentID, &summary.Status,
+		&summary.ThroughMessageID, &summary.ThroughAt, &summary.MessageCount, &summary.Auto, &summary.Content,
+		&summary.MessageID, &summary.CreatedAt, &summary.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
@@ -0,0 +1,98 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestConversationSummaryCompletesOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewConversationSummaryRepository(testDB.Pool)
+	messageRepo := repository.NewMessageRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	conversation, agents := newConversation(t, office, testDB.Template(t, testDB.User(t)), time.Now(), 1)
+
+	start := time.Now().Add(-time.Hour)
+	messages := make([]uuid.UUID, 3)
+	for i := range messages {
+		messages[i] = uuid.New()
+		_, err := testDB.Pool.Exec(ctx, `
+			INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content, created_at)
+			VALUES ($1, $2, $3, 'agent', $4, 'Status update', $5)
+		`, messages[i], office, conversation.ID, agents[0], start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("insert message: %v", err)
+		}
+	}
+	long, err := repo.GetUnsummarized(ctx, 3, start.Add(-time.Minute), 100)
+	if err != nil || !slices.Contains(long, conversation.ID) {
+		t.Errorf("GetUnsummarized(3) = %v, %v; want the conversation", long, err)
+	}
+
+	taskID := uuid.New()
+	_, err = testDB.Pool.Exec(ctx, `
+		INSERT INTO tasks (id, office_id, conversation_id, agent_id, status, input, kind)
+		VALUES ($1, $2, $3, $4, 'working', 'Summarize', 'summary')
+	`, taskID, office, conversation.ID, agents[0])
+	if err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	run := &domain.ConversationSummary{
+		ID: uuid.New(), OfficeID: office, ConversationID: conversation.ID, TaskID: &taskID, AgentID: agents[0],
+		ThroughMessageID: messages[1], ThroughAt: start.Add(time.Minute), MessageCount: 2, CreatedAt: time.Now(),
+	}
+	if err := repo.Create(ctx, run); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if latest, err := repo.GetLatest(ctx, conversation.ID); err != nil || latest.Status != domain.SummaryStatusPending {
+		t.Errorf("GetLatest = %+v, %v; want the pending run", latest, err)
+	}
+	if _, err := repo.GetLatestDone(ctx, conversation.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetLatestDone before completion error = %v, want ErrNotFound", err)
+	}
+	if long, _ := repo.GetUnsummarized(ctx, 1, start.Add(-time.Minute), 100); slices.Contains(long, conversation.ID) {
+		t.Errorf("GetUnsummarized includes a conversation with a run started since")
+	}
+
+	complete := func() error {
+		now := time.Now()
+		message := &domain.Message{
+			ID: uuid.New(), OfficeID: office, ConversationID: conversation.ID, SenderType: domain.SenderTypeSystem,
+			SenderID: run.ID, Content: "Two updates so far", CreatedAt: now, PinnedAt: &now,
+		}
+		run.Content, run.MessageID, run.CompletedAt = message.Content, &message.ID, &now
+		return repo.Complete(ctx, run, message)
+	}
+	if err := complete(); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	posted := *run.MessageID
+	if err := complete(); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Complete again error = %v, want ErrNotFound", err)
+	}
+
+	done, err := repo.GetByTaskID(ctx, taskID)
+	if err != nil || done.Status != domain.SummaryStatusDone || done.MessageID == nil || *done.MessageID != posted {
+		t.Errorf("GetByTaskID = %+v, %v; want the run done with its first message", done, err)
+	}
+	updated, err := repository.NewConversationRepository(testDB.Pool).GetByID(ctx, conversation.ID)
+	if err != nil || updated.Summary != "Two updates so far" || updated.SummarizedAt == nil {
+		t.Errorf("conversation = %+v, %v; want its summary set", updated, err)
+	}
+
+	// The run covered the first two messages; the third and the summary follow
+	after := &domain.PageCursor{CreatedAt: done.ThroughAt, ID: done.ThroughMessageID}
+	next, err := messageRepo.GetNextByConversationID(ctx, conversation.ID, after, 10)
+	if err != nil || len(next) != 2 || next[0].ID != messages[2] || next[1].ID != posted || next[1].PinnedAt == nil {
+		t.Errorf("GetNextByConversationID = %v, %v; want the third message and the pinned summary", next, err)
+	}
+}
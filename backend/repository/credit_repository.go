@@ -3,54 +3,58 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // CreditRepository implements credit wallet and transaction operations
 type CreditRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewCreditRepository creates a new CreditRepository
 func NewCreditRepository(db *pgxpool.Pool) *CreditRepository {
-	return &CreditRepository{db: db}
+	return &CreditRepository{db: conn{db}}
 }
 
-// CreateWallet creates a new credit wallet for an office
-func (r *CreditRepository) CreateWallet(ctx context.Context, officeID uuid.UUID, initialBalance int64) (*domain.CreditWallet, error) {
-	wallet := &domain.CreditWallet{
-		ID:             uuid.New(),
-		OfficeID:       officeID,
-		Balance:        initialBalance,
-		TotalPurchased: 0,
-		TotalBonus:     initialBalance, // Initial balance is a bonus
-		TotalConsumed:  0,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+// walletColumns are the columns scanned by scanWallet
+const walletColumns = `id, office_id, balance, total_purchased, total_bonus, total_consumed,
+		       hourly_limit, daily_limit, COALESCE(budget_alert_threshold, 20), COALESCE(budget_pause_enabled, FALSE),
+		       low_balance_threshold, auto_top_up_enabled, COALESCE(auto_top_up_package, ''),
+		       COALESCE(auto_top_up_payment_method, ''), auto_top_up_max_per_day, created_at, updated_at`
+
+// scanWallet scans a row of walletColumns
+func scanWallet(row pgx.Row) (*domain.CreditWallet, error) {
+	var wallet domain.CreditWallet
+	if err := row.Scan(
+		&wallet.ID, &wallet.OfficeID, &wallet.Balance,
+		&wallet.TotalPurchased, &wallet.TotalBonus, &wallet.TotalConsumed,
+		&wallet.HourlyLimit, &wallet.DailyLimit, &wallet.BudgetAlertThreshold, &wallet.BudgetPauseEnabled,
+		&wallet.LowBalanceThreshold, &wallet.AutoTopUpEnabled, &wallet.AutoTopUpPackage,
+		&wallet.AutoTopUpPaymentMethod, &wallet.AutoTopUpMaxPerDay, &wallet.CreatedAt, &wallet.UpdatedAt,
+	); err != nil {
+		return nil, err
 	}
+	return &wallet, nil
+}
 
+// CreateWallet creates a new credit wallet for an office
+func (r *CreditRepository) CreateWallet(ctx context.Context, officeID uuid.UUID, initialBalance int64) (*domain.CreditWallet, error) {
+	now := time.Now()
 	query := `
 		INSERT INTO credit_wallets (id, office_id, balance, total_purchased, total_bonus, total_consumed, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (office_id) DO NOTHING
-		RETURNING id, office_id, balance, total_purchased, total_bonus, total_consumed, created_at, updated_at
-	`
-
-	err := r.db.QueryRow(ctx, query,
-		wallet.ID, wallet.OfficeID, wallet.Balance,
-		wallet.TotalPurchased, wallet.TotalBonus, wallet.TotalConsumed,
-		wallet.CreatedAt, wallet.UpdatedAt,
-	).Scan(
-		&wallet.ID, &wallet.OfficeID, &wallet.Balance,
-		&wallet.TotalPurchased, &wallet.TotalBonus, &wallet.TotalConsumed,
-		&wallet.CreatedAt, &wallet.UpdatedAt,
-	)
+		RETURNING ` + walletColumns
 
+	// The initial balance is a bonus
+	wallet, err := scanWallet(r.db.QueryRow(ctx, query, uuid.New(), officeID, initialBalance, 0, initialBalance, 0, now, now))
 	if err != nil {
 		// If conflict, return existing wallet
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -64,49 +68,75 @@ func (r *CreditRepository) CreateWallet(ctx context.Context, officeID uuid.UUID,
 
 // GetWalletByID retrieves a credit wallet by ID
 func (r *CreditRepository) GetWalletByID(ctx context.Context, id uuid.UUID) (*domain.CreditWallet, error) {
-	query := `
-		SELECT id, office_id, balance, total_purchased, total_bonus, total_consumed, created_at, updated_at
-		FROM credit_wallets WHERE id = $1
-	`
-
-	var wallet domain.CreditWallet
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&wallet.ID, &wallet.OfficeID, &wallet.Balance,
-		&wallet.TotalPurchased, &wallet.TotalBonus, &wallet.TotalConsumed,
-		&wallet.CreatedAt, &wallet.UpdatedAt,
-	)
+	wallet, err := scanWallet(r.db.QueryRow(ctx, `SELECT `+walletColumns+` FROM credit_wallets WHERE id = $1`, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &wallet, nil
+	return wallet, nil
 }
 
 // GetWalletByOfficeID retrieves a credit wallet by office ID
 func (r *CreditRepository) GetWalletByOfficeID(ctx context.Context, officeID uuid.UUID) (*domain.CreditWallet, error) {
-	query := `
-		SELECT id, office_id, balance, total_purchased, total_bonus, total_consumed, created_at, updated_at
-		FROM credit_wallets WHERE office_id = $1
-	`
-
-	var wallet domain.CreditWallet
-	err := r.db.QueryRow(ctx, query, officeID).Scan(
-		&wallet.ID, &wallet.OfficeID, &wallet.Balance,
-		&wallet.TotalPurchased, &wallet.TotalBonus, &wallet.TotalConsumed,
-		&wallet.CreatedAt, &wallet.UpdatedAt,
-	)
+	wallet, err := scanWallet(r.db.QueryRow(ctx, `SELECT `+walletColumns+` FROM credit_wallets WHERE office_id = $1`, officeID))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &wallet, nil
+	return wallet, nil
+}
+
+// UpdateLowBalanceSettings saves the wallet's low balance threshold and auto
+// top-up settings
+func (r *CreditRepository) UpdateLowBalanceSettings(ctx context.Context, wallet *domain.CreditWallet) error {
+	query := `
+		UPDATE credit_wallets
+		SET low_balance_threshold = $2, auto_top_up_enabled = $3, auto_top_up_package = $4,
+		    auto_top_up_payment_method = $5, auto_top_up_max_per_day = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		wallet.ID, wallet.LowBalanceThreshold, wallet.AutoTopUpEnabled, nullableString(wallet.AutoTopUpPackage),
+		nullableString(wallet.AutoTopUpPaymentMethod), wallet.AutoTopUpMaxPerDay,
+	).Scan(&wallet.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// walletBalanceConstraint is the check constraint update_wallet_balance
+// reports when a debit exceeds the balance
+const walletBalanceConstraint = "credit_wallets_balance_non_negative"
+
+// walletError maps errors raised by update_wallet_balance to domain errors
+func walletError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == "23514" && pgErr.ConstraintName == walletBalanceConstraint:
+		if pgErr.Detail == "" {
+			return domain.ErrInsufficientCredits
+		}
+		return fmt.Errorf("%w: %s", domain.ErrInsufficientCredits, pgErr.Detail)
+	case pgErr.Code == "P0002":
+		return domain.ErrNotFound
+	default:
+		return err
+	}
 }
 
-// AddCredits adds credits to a wallet (uses DB function for atomicity)
+// AddCredits adds credits to a wallet (uses DB function for atomicity).
+// The wallet row is locked while the balance is checked, so concurrent debits
+// cannot overdraw it; a debit larger than the balance fails with
+// domain.ErrInsufficientCredits.
 func (r *CreditRepository) AddCredits(
 	ctx context.Context,
 	walletID uuid.UUID,
@@ -126,7 +156,7 @@ func (r *CreditRepository) AddCredits(
 		&tx.ReferenceType, &tx.ReferenceID, &tx.Description, &tx.Metadata, &tx.CreatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, walletError(err)
 	}
 	return &tx, nil
 }
@@ -157,23 +187,30 @@ func (r *CreditRepository) GetBalance(ctx context.Context, walletID uuid.UUID) (
 	return balance, err
 }
 
-// GetTransactions retrieves transaction history for a wallet
+// GetTransactions retrieves transaction history for a wallet, newest first.
+// A cursor continues before the last transaction of the previous page.
 func (r *CreditRepository) GetTransactions(
 	ctx context.Context,
 	walletID uuid.UUID,
-	limit int,
-	offset int,
+	page domain.PageRequest,
 ) ([]*domain.CreditTransaction, error) {
+	q := &queryBuilder{}
+	q.where("wallet_id = " + q.arg(walletID))
+	if page.Cursor != nil {
+		q.where("(created_at, id) < (" + q.arg(page.Cursor.CreatedAt) + ", " + q.arg(page.Cursor.ID) + ")")
+	}
+
 	query := `
-		SELECT id, wallet_id, transaction_type, amount, balance_after, 
+		SELECT id, wallet_id, transaction_type, amount, balance_after,
 		       reference_type, reference_id, description, metadata, created_at
-		FROM credit_transactions
-		WHERE wallet_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+		FROM credit_transactions` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(page.Limit)
+	if page.Cursor == nil && page.Offset > 0 {
+		query += ` OFFSET ` + q.arg(page.Offset)
+	}
 
-	rows, err := r.db.Query(ctx, query, walletID, limit, offset)
+	rows, err := r.db.Query(ctx, query, q.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -194,6 +231,13 @@ func (r *CreditRepository) GetTransactions(
 	return transactions, rows.Err()
 }
 
+// CountTransactions returns the number of transactions recorded for a wallet
+func (r *CreditRepository) CountTransactions(ctx context.Context, walletID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM credit_transactions WHERE wallet_id = $1`, walletID).Scan(&count)
+	return count, err
+}
+
 // GetTransactionsByType retrieves transactions of a specific type
 func (r *CreditRepository) GetTransactionsByType(
 	ctx context.Context,
@@ -231,6 +275,56 @@ func (r *CreditRepository) GetTransactionsByType(
 	return transactions, rows.Err()
 }
 
+// GetConsumedSince returns the total credits consumed by a wallet since the given time
+func (r *CreditRepository) GetConsumedSince(ctx context.Context, walletID uuid.UUID, since time.Time) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(-amount), 0)
+		FROM credit_transactions
+		WHERE wallet_id = $1 AND transaction_type = $2 AND created_at >= $3
+	`
+	var consumed int64
+	err := r.db.QueryRow(ctx, query, walletID, string(domain.TransactionTypeConsumption), since).Scan(&consumed)
+	return consumed, err
+}
+
+// GetTaskNetCredits returns the net credit movement for a task: consumption
+// minus any refunds, as a negative number while credits remain charged
+func (r *CreditRepository) GetTaskNetCredits(ctx context.Context, walletID uuid.UUID, taskID uuid.UUID) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM credit_transactions
+		WHERE wallet_id = $1 AND reference_type = 'task' AND reference_id = $2
+		  AND transaction_type IN ($3, $4)
+	`
+	var net int64
+	err := r.db.QueryRow(ctx, query, walletID, taskID,
+		string(domain.TransactionTypeConsumption), string(domain.TransactionTypeRefund),
+	).Scan(&net)
+	return net, err
+}
+
+// GetTransactionByID retrieves a single credit transaction
+func (r *CreditRepository) GetTransactionByID(ctx context.Context, id uuid.UUID) (*domain.CreditTransaction, error) {
+	query := `
+		SELECT id, wallet_id, transaction_type, amount, balance_after,
+		       reference_type, reference_id, description, metadata, created_at
+		FROM credit_transactions WHERE id = $1
+	`
+
+	var tx domain.CreditTransaction
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&tx.ID, &tx.WalletID, &tx.Type, &tx.Amount, &tx.BalanceAfter,
+		&tx.ReferenceType, &tx.ReferenceID, &tx.Description, &tx.Metadata, &tx.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
 // HasSufficientBalance checks if wallet has enough credits for a task
 func (r *CreditRepository) HasSufficientBalance(ctx context.Context, walletID uuid.UUID, requiredCredits int64) (bool, int64, error) {
 	balance, err := r.GetBalance(ctx, walletID)
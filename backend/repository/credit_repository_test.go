@@ -0,0 +1,239 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// newWallet creates an office and returns its wallet with the given balance
+func newWallet(t *testing.T, balance int64) uuid.UUID {
+	t.Helper()
+	office := testDB.Office(t, testDB.User(t))
+	return testDB.Wallet(t, office, balance)
+}
+
+func TestCreateWalletReturnsExistingWallet(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+
+	existing, err := repo.GetWalletByOfficeID(ctx, office)
+	if err != nil {
+		t.Fatalf("GetWalletByOfficeID: %v", err)
+	}
+	wallet, err := repo.CreateWallet(ctx, office, 5000)
+	if err != nil {
+		t.Fatalf("CreateWallet: %v", err)
+	}
+	if wallet.ID != existing.ID || wallet.Balance != existing.Balance {
+		t.Errorf("CreateWallet = %s with %d, want the existing wallet %s with %d",
+			wallet.ID, wallet.Balance, existing.ID, existing.Balance)
+	}
+}
+
+func TestConsumeCreditsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+
+	const (
+		balance    = 100
+		cost       = 10
+		workers    = 25
+		affordable = balance / cost
+	)
+	walletID := newWallet(t, balance)
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		succeeded    int
+		insufficient int
+		unexpected   []error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.ConsumeCredits(ctx, walletID, cost, uuid.New(), "concurrent task")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, domain.ErrInsufficientCredits):
+				insufficient++
+			default:
+				unexpected = append(unexpected, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(unexpected) > 0 {
+		t.Fatalf("unexpected errors: %v", unexpected)
+	}
+	if succeeded != affordable || insufficient != workers-affordable {
+		t.Errorf("got %d debits and %d insufficient, want %d and %d",
+			succeeded, insufficient, affordable, workers-affordable)
+	}
+
+	wallet, err := repo.GetWalletByID(ctx, walletID)
+	if err != nil {
+		t.Fatalf("GetWalletByID: %v", err)
+	}
+	if wallet.Balance != 0 {
+		t.Errorf("balance = %d, want 0", wallet.Balance)
+	}
+	if wallet.TotalConsumed != balance {
+		t.Errorf("total consumed = %d, want %d", wallet.TotalConsumed, balance)
+	}
+	count, err := repo.CountTransactions(ctx, walletID)
+	if err != nil {
+		t.Fatalf("CountTransactions: %v", err)
+	}
+	if count != affordable {
+		t.Errorf("%d transactions recorded, want %d", count, affordable)
+	}
+}
+
+func TestConsumeCreditsInsufficientBalance(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	walletID := newWallet(t, 5)
+
+	_, err := repo.ConsumeCredits(ctx, walletID, 10, uuid.New(), "too expensive")
+	if !errors.Is(err, domain.ErrInsufficientCredits) {
+		t.Fatalf("ConsumeCredits error = %v, want ErrInsufficientCredits", err)
+	}
+
+	balance, err := repo.GetBalance(ctx, walletID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 5 {
+		t.Errorf("balance = %d, want 5", balance)
+	}
+	count, err := repo.CountTransactions(ctx, walletID)
+	if err != nil {
+		t.Fatalf("CountTransactions: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d transactions recorded for a refused debit, want 0", count)
+	}
+}
+
+func TestConsumeCreditsExactBalance(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	walletID := newWallet(t, 10)
+
+	tx, err := repo.ConsumeCredits(ctx, walletID, 10, uuid.New(), "whole balance")
+	if err != nil {
+		t.Fatalf("ConsumeCredits: %v", err)
+	}
+	if tx.Amount != -10 || tx.BalanceAfter != 0 {
+		t.Errorf("transaction amount %d, balance after %d, want -10 and 0", tx.Amount, tx.BalanceAfter)
+	}
+}
+
+func TestAddCreditsUnknownWallet(t *testing.T) {
+	repo := repository.NewCreditRepository(testDB.Pool)
+
+	_, err := repo.AddCredits(context.Background(), uuid.New(), 10, domain.TransactionTypePurchase, "purchase", "", nil)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("AddCredits error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAddCreditsUpdatesTotals(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	walletID := newWallet(t, 0)
+
+	if _, err := repo.AddCredits(ctx, walletID, 300, domain.TransactionTypePurchase, "purchase", "", nil); err != nil {
+		t.Fatalf("AddCredits purchase: %v", err)
+	}
+	if _, err := repo.AddCredits(ctx, walletID, 50, domain.TransactionTypeBonus, "bonus", "", nil); err != nil {
+		t.Fatalf("AddCredits bonus: %v", err)
+	}
+	if _, err := repo.ConsumeCredits(ctx, walletID, 120, uuid.New(), "task"); err != nil {
+		t.Fatalf("ConsumeCredits: %v", err)
+	}
+
+	wallet, err := repo.GetWalletByID(ctx, walletID)
+	if err != nil {
+		t.Fatalf("GetWalletByID: %v", err)
+	}
+	if wallet.Balance != 230 || wallet.TotalPurchased != 300 || wallet.TotalBonus != 50 || wallet.TotalConsumed != 120 {
+		t.Errorf("wallet balance %d, purchased %d, bonus %d, consumed %d; want 230, 300, 50, 120",
+			wallet.Balance, wallet.TotalPurchased, wallet.TotalBonus, wallet.TotalConsumed)
+	}
+}
+
+func TestGetTransactionsPagesByCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	walletID := newWallet(t, 100)
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.ConsumeCredits(ctx, walletID, 1, uuid.New(), "task"); err != nil {
+			t.Fatalf("ConsumeCredits: %v", err)
+		}
+	}
+
+	first, err := repo.GetTransactions(ctx, walletID, domain.PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("first page has %d transactions, want 2", len(first))
+	}
+	last := first[len(first)-1]
+	second, err := repo.GetTransactions(ctx, walletID, domain.PageRequest{
+		Limit:  2,
+		Cursor: &domain.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID},
+	})
+	if err != nil {
+		t.Fatalf("GetTransactions after cursor: %v", err)
+	}
+	if len(second) != 1 {
+		t.Fatalf("second page has %d transactions, want 1", len(second))
+	}
+	if second[0].BalanceAfter != 99 {
+		t.Errorf("oldest transaction balance after = %d, want 99", second[0].BalanceAfter)
+	}
+}
+
+func TestConsumeCreditsRolledBackWithTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCreditRepository(testDB.Pool)
+	txManager := repository.NewTxManager(testDB.Pool)
+	walletID := newWallet(t, 100)
+
+	errAbort := errors.New("abort")
+	err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := repo.ConsumeCredits(ctx, walletID, 40, uuid.New(), "task"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx error = %v, want %v", err, errAbort)
+	}
+
+	balance, err := repo.GetBalance(ctx, walletID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 100 {
+		t.Errorf("balance = %d after rollback, want 100", balance)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DataExportRepository implements domain.DataExportRepository
+type DataExportRepository struct {
+	db conn
+}
+
+// NewDataExportRepository creates a new DataExportRepository
+func NewDataExportRepository(db *pgxpool.Pool) *DataExportRepository {
+	return &DataExportRepository{db: conn{db}}
+}
+
+const dataExportColumns = `id, user_id, status, attempts, next_attempt_at, storage_key, size_bytes, error,
+	created_at, completed_at, expires_at`
+
+// Create queues an export, returning domain.ErrAlreadyExists if one of the
+// user's exports is still pending
+func (r *DataExportRepository) Create(ctx context.Context, export *domain.DataExport) error {
+	query := `
+		INSERT INTO data_exports (id, user_id, status, next_attempt_at, created_at)
+		VALUES ($1, $2, 'pending', $3, $4)
+		ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+		RETURNING status
+	`
+	err := r.db.QueryRow(ctx, query, export.ID, export.UserID, export.NextAttemptAt, export.CreatedAt).Scan(&export.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID retrieves an export by ID
+func (r *DataExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+
+	export, err := scanDataExport(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return export, err
+}
+
+// ListByUser returns the user's most recent exports, newest first
+func (r *DataExportRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.DataExport, error) {
+	query := `
+		SELECT ` + dataExportColumns + ` FROM data_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	return r.query(ctx, query, userID, limit)
+}
+
+// ClaimDue claims up to limit pending exports due at now. The status and
+// due time are checked again in the UPDATE, so an export claimed by another
+// worker meanwhile is skipped.
+func (r *DataExportRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.DataExport, error) {
+	query := `
+		UPDATE data_exports
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM data_exports
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		) AND status = 'pending' AND next_attempt_at <= $1
+		RETURNING ` + dataExportColumns
+	return r.query(ctx, query, now, now.Add(lease), limit)
+}
+
+// MarkReady records where a built export's archive was stored
+func (r *DataExportRepository) MarkReady(ctx context.Context, id uuid.UUID, storageKey string, sizeBytes int64, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', storage_key = $2, size_bytes = $3, expires_at = $4, error = '', completed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+	tag, err := r.db.Exec(ctx, query, id, storageKey, sizeBytes, expiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt, scheduling the next one or giving up
+// when nextAttemptAt is nil
+func (r *DataExportRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET error = $2,
+			status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			completed_at = CASE WHEN $3::timestamptz IS NULL THEN NOW() END
+		WHERE id = $1 AND status = 'pending'
+	`
+	_, err := r.db.Exec(ctx, query, id, lastError, nextAttemptAt)
+	return err
+}
+
+// GetExpired returns up to limit ready exports that expired before now
+func (r *DataExportRepository) GetExpired(ctx context.Context, now time.Time, limit int) ([]*domain.DataExport, error) {
+	query := `
+		SELECT ` + dataExportColumns + ` FROM data_exports
+		WHERE status = 'ready' AND expires_at < $1
+		ORDER BY expires_at
+		LIMIT $2
+	`
+	return r.query(ctx, query, now, limit)
+}
+
+// MarkExpired records that an export's archive was removed
+func (r *DataExportRepository) MarkExpired(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE data_exports SET status = 'expired', storage_key = '' WHERE id = $1 AND status = 'ready'`
+	tag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *DataExportRepository) query(ctx context.Context, query string, args ...any) ([]*domain.DataExport, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []*domain.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+func scanDataExport(row pgx.Row) (*domain.DataExport, error) {
+	var export domain.DataExport
+	err := row.Scan(
+		&export.ID, &export.UserID, &export.Status, &export.Attempts, &export.NextAttemptAt, &export.StorageKey,
+		&export.SizeBytes, &export.Error, &export.CreatedAt, &export.CompletedAt, &export.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
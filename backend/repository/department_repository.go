@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DepartmentRepository implements domain.DepartmentRepository
+type DepartmentRepository struct {
+	db conn
+}
+
+// NewDepartmentRepository creates a new DepartmentRepository
+func NewDepartmentRepository(db *pgxpool.Pool) *DepartmentRepository {
+	return &DepartmentRepository{db: conn{db}}
+}
+
+// departmentSelect selects the columns scanned by scanDepartment: the
+// department d with its undeleted lead and members
+const departmentSelect = `
+	SELECT d.id, d.office_id, d.name, d.description, l.id, d.conversation_id, d.created_at, d.updated_at,
+	       COALESCE((
+	           SELECT array_agg(m.agent_id ORDER BY m.joined_at, m.agent_id)
+	           FROM department_members m
+	           JOIN agents a ON a.id = m.agent_id AND a.deleted_at IS NULL
+	           WHERE m.department_id = d.id
+	       ), '{}')
+	FROM departments d
+	LEFT JOIN agents l ON l.id = d.lead_agent_id AND l.deleted_at IS NULL
+`
+
+// Create stores a new department, returning domain.ErrAlreadyExists if
+// the office already has one by its name
+func (r *DepartmentRepository) Create(ctx context.Context, department *domain.Department) error {
+	query := `
+		INSERT INTO departments (id, office_id, name, description, lead_agent_id, conversation_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (office_id, name) DO NOTHING
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		department.ID, department.OfficeID, department.Name, department.Description,
+		department.LeadAgentID, department.ConversationID, department.CreatedAt, department.UpdatedAt,
+	).Scan(&department.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID returns a department by ID
+func (r *DepartmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Department, error) {
+	return r.getOne(ctx, departmentSelect+`WHERE d.id = $1`, id)
+}
+
+// GetByConversationID returns the department whose conversation it is
+func (r *DepartmentRepository) GetByConversationID(ctx context.Context, conversationID uuid.UUID) (*domain.Department, error) {
+	return r.getOne(ctx, departmentSelect+`WHERE d.conversation_id = $1`, conversationID)
+}
+
+func (r *DepartmentRepository) getOne(ctx context.Context, query string, arg any) (*domain.Department, error) {
+	department, err := scanDepartment(r.db.QueryRow(ctx, query, arg))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return department, err
+}
+
+// GetByOfficeID returns an office's departments by name
+func (r *DepartmentRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Department, error) {
+	rows, err := r.db.Query(ctx, departmentSelect+`WHERE d.office_id = $1 ORDER BY d.name`, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var departments []*domain.Department
+	for rows.Next() {
+		department, err := scanDepartment(rows)
+		if err != nil {
+			return nil, err
+		}
+		departments = append(departments, department)
+	}
+	return departments, rows.Err()
+}
+
+// Update saves a department's name, description and lead, returning
+// domain.ErrAlreadyExists if the office has another department by the name
+func (r *DepartmentRepository) Update(ctx context.Context, department *domain.Department) error {
+	query := `
+		UPDATE departments d
+		SET name = $2, description = $3, lead_agent_id = $4, updated_at = $5
+		WHERE d.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM departments o WHERE o.office_id = d.office_id AND o.name = $2 AND o.id <> d.id
+		)
+	`
+	tag, err := r.db.Exec(ctx, query,
+		department.ID, department.Name, department.Description, department.LeadAgentID, department.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := r.GetByID(ctx, department.ID); err != nil {
+			return err
+		}
+		return domain.ErrAlreadyExists
+	}
+	return nil
+}
+
+// Delete removes a department; its conversation is kept
+func (r *DepartmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM departments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// AddMember adds an agent to a department, returning
+// domain.ErrAlreadyExists if the agent is in a department
+func (r *DepartmentRepository) AddMember(ctx context.Context, departmentID, agentID uuid.UUID) error {
+	query := `
+		INSERT INTO department_members (department_id, agent_id, joined_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT DO NOTHING
+	`
+	tag, err := r.db.Exec(ctx, query, departmentID, agentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAlreadyExists
+	}
+	return nil
+}
+
+// RemoveMember removes an agent from a department
+func (r *DepartmentRepository) RemoveMember(ctx context.Context, departmentID, agentID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM department_members WHERE department_id = $1 AND agent_id = $2`, departmentID, agentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// GetReportingLines returns the reporting lines between an office's
+// undeleted agents
+func (r *DepartmentRepository) GetReportingLines(ctx context.Context, officeID uuid.UUID) ([]*domain.ReportingLine, error) {
+	query := `
+		SELECT rl.agent_id, rl.manager_id, rl.created_at
+		FROM agent_reporting_lines rl
+		JOIN agents a ON a.id = rl.agent_id AND a.deleted_at IS NULL
+		JOIN agents m ON m.id = rl.manager_id AND m.deleted_at IS NULL
+		WHERE a.office_id = $1
+		ORDER BY rl.created_at, rl.agent_id
+	`
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*domain.ReportingLine
+	for rows.Next() {
+		var line domain.ReportingLine
+		if err := rows.Scan(&line.AgentID, &line.ManagerID, &line.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &line)
+	}
+	return lines, rows.Err()
+}
+
+// SetManager makes an agent report to a manager, or removes its reporting
+// line when managerID is nil
+func (r *DepartmentRepository) SetManager(ctx context.Context, agentID uuid.UUID, managerID *uuid.UUID) error {
+	if managerID == nil {
+		_, err := r.db.Exec(ctx, `DELETE FROM agent_reporting_lines WHERE agent_id = $1`, agentID)
+		return err
+	}
+	query := `
+		INSERT INTO agent_reporting_lines (agent_id, manager_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (agent_id) DO UPDATE
+		SET manager_id = EXCLUDED.manager_id,
+			created_at = EXCLUDED.created_at
+	`
+	_, err := r.db.Exec(ctx, query, agentID, *managerID)
+	return err
+}
+
+// scanDepartment scans a row selected with departmentSelect
+func scanDepartment(row pgx.Row) (*domain.Department, error) {
+	var department domain.Department
+	err := row.Scan(
+		&department.ID,
+		&department.OfficeID,
+		&department.Name,
+		&department.Description,
+		&department.LeadAgentID,
+		&department.ConversationID,
+		&department.CreatedAt,
+		&department.UpdatedAt,
+		&department.MemberIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &department, nil
+}
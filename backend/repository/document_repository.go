@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DocumentRepository implements domain.DocumentRepository
+type DocumentRepository struct {
+	db conn
+}
+
+// NewDocumentRepository creates a new DocumentRepository
+func NewDocumentRepository(db *pgxpool.Pool) *DocumentRepository {
+	return &DocumentRepository{db: conn{db}}
+}
+
+const documentColumns = `id, office_id, conversation_id, agent_id, title, format, content, version, created_at, updated_at`
+
+// documentSummaryColumns selects documentColumns with an empty content, for
+// listings
+const documentSummaryColumns = `id, office_id, conversation_id, agent_id, title, format, '', version, created_at, updated_at`
+
+// Create stores a document and its first version
+func (r *DocumentRepository) Create(ctx context.Context, document *domain.Document, version *domain.DocumentVersion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO documents (`+documentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`,
+		document.ID, document.OfficeID, document.ConversationID, document.AgentID, document.Title,
+		document.Format, document.Content, document.Version, document.CreatedAt, document.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := insertDocumentVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AddVersion stores a new version and makes it the document's latest. The
+// version number is taken under a row lock, so concurrent saves each get
+// their own; document and version are updated with it.
+func (r *DocumentRepository) AddVersion(ctx context.Context, document *domain.Document, version *domain.DocumentVersion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE documents SET title = $2, content = $3, version = version + 1, updated_at = $4
+		WHERE id = $1
+		RETURNING version
+	`, document.ID, version.Title, version.Content, version.CreatedAt).Scan(&version.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := insertDocumentVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	document.Title = version.Title
+	document.Content = version.Content
+	document.Version = version.Version
+	document.UpdatedAt = version.CreatedAt
+	return nil
+}
+
+// insertDocumentVersion stores a document version within a transaction
+func insertDocumentVersion(ctx context.Context, tx pgx.Tx, version *domain.DocumentVersion) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO document_versions (id, document_id, version, task_id, title, content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, version.ID, version.DocumentID, version.Version, version.TaskID, version.Title, version.Content, version.CreatedAt)
+	return err
+}
+
+// GetByID returns a document with its latest content
+func (r *DocumentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE id = $1`
+
+	document, err := scanDocument(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// List returns a page of documents matching the filter, without their
+// content, newest first. A cursor continues before the last document of the
+// previous page.
+func (r *DocumentRepository) List(ctx context.Context, filter domain.DocumentFilter, page domain.PageRequest) ([]*domain.Document, error) {
+	q := documentFilterQuery(filter)
+	if page.Cursor != nil {
+		q.where("(created_at, id) < (" + q.arg(page.Cursor.CreatedAt) + ", " + q.arg(page.Cursor.ID) + ")")
+	}
+
+	query := `SELECT ` + documentSummaryColumns + ` FROM documents` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(page.Limit)
+	if page.Cursor == nil && page.Offset > 0 {
+		query += ` OFFSET ` + q.arg(page.Offset)
+	}
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*domain.Document
+	for rows.Next() {
+		document, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
+
+// Count returns the number of documents matching the filter
+func (r *DocumentRepository) Count(ctx context.Context, filter domain.DocumentFilter) (int, error) {
+	q := documentFilterQuery(filter)
+
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM documents`+q.whereClause(), q.args...).Scan(&count)
+	return count, err
+}
+
+// documentFilterQuery builds the WHERE conditions for a document filter
+func documentFilterQuery(filter domain.DocumentFilter) *queryBuilder {
+	q := &queryBuilder{}
+	q.where("office_id = " + q.arg(filter.OfficeID))
+	if filter.ConversationID != uuid.Nil {
+		q.where("conversation_id = " + q.arg(filter.ConversationID))
+	}
+	if filter.AgentID != uuid.Nil {
+		q.where("agent_id = " + q.arg(filter.AgentID))
+	}
+	return q
+}
+
+// GetVersions returns a document's versions without their content, newest
+// first
+func (r *DocumentRepository) GetVersions(ctx context.Context, documentID uuid.UUID) ([]*domain.DocumentVersion, error) {
+	query := `
+		SELECT id, document_id, version, task_id, title, '', created_at
+		FROM document_versions WHERE document_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*domain.DocumentVersion
+	for rows.Next() {
+		version, err := scanDocumentVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion returns one version of a document with its content
+func (r *DocumentRepository) GetVersion(ctx context.Context, documentID uuid.UUID, version int) (*domain.DocumentVersion, error) {
+	query := `
+		SELECT id, document_id, version, task_id, title, content, created_at
+		FROM document_versions WHERE document_id = $1 AND version = $2
+	`
+
+	v, err := scanDocumentVersion(r.db.QueryRow(ctx, query, documentID, version))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// scanDocument scans a row selected with documentColumns or
+// documentSummaryColumns
+func scanDocument(row pgx.Row) (*domain.Document, error) {
+	var d domain.Document
+	err := row.Scan(
+		&d.ID, &d.OfficeID, &d.ConversationID, &d.AgentID, &d.Title,
+		&d.Format, &d.Content, &d.Version, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// scanDocumentVersion scans a document_versions row
+func scanDocumentVersion(row pgx.Row) (*domain.DocumentVersion, error) {
+	var v domain.DocumentVersion
+	if err := row.Scan(&v.ID, &v.DocumentID, &v.Version, &v.TaskID, &v.Title, &v.Content, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -2,21 +2,23 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// EarningsRepository implements earnings data access
+// EarningsRepository implements domain.EarningsRepository
 type EarningsRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewEarningsRepository creates a new earnings repository
 func NewEarningsRepository(db *pgxpool.Pool) *EarningsRepository {
-	return &EarningsRepository{db: db}
+	return &EarningsRepository{db: conn{db}}
 }
 
 // RecordSale records a marketplace sale using the database function
@@ -40,6 +42,69 @@ func (r *EarningsRepository) RecordSale(
 	return earningID, err
 }
 
+// RecordBundleSale records a template's share of a bundle sale using the
+// database function
+func (r *EarningsRepository) RecordBundleSale(
+	ctx context.Context,
+	authorID uuid.UUID,
+	templateID uuid.UUID,
+	bundleID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	saleAmountCents int,
+	stripePaymentIntentID string,
+) (uuid.UUID, error) {
+	var earningID uuid.UUID
+	query := `SELECT record_marketplace_sale($1, $2, $3, $4, $5, $6, $7)`
+
+	err := r.db.QueryRow(ctx, query,
+		authorID, templateID, purchaserID, purchaserOfficeID,
+		saleAmountCents, stripePaymentIntentID, bundleID,
+	).Scan(&earningID)
+
+	return earningID, err
+}
+
+// IsPaymentIntentUsed reports whether any earning, including the reversal of
+// a refunded sale, was recorded with the payment
+func (r *EarningsRepository) IsPaymentIntentUsed(ctx context.Context, stripePaymentIntentID string) (bool, error) {
+	var used bool
+	query := `SELECT EXISTS (SELECT 1 FROM author_earnings WHERE stripe_payment_intent_id = $1)`
+	err := r.db.QueryRow(ctx, query, stripePaymentIntentID).Scan(&used)
+	return used, err
+}
+
+// earningColumns are the author_earnings columns scanEarning reads
+const earningColumns = `id, author_id, template_id, purchaser_id, purchaser_office_id,
+	sale_amount_cents, commission_cents, author_earning_cents,
+	stripe_payment_intent_id, status, refund_of, created_at, bundle_id`
+
+// scanEarning scans an author_earnings row of earningColumns
+func scanEarning(row pgx.Row) (*domain.AuthorEarning, error) {
+	var e domain.AuthorEarning
+	var stripeID *string
+	if err := row.Scan(
+		&e.ID, &e.AuthorID, &e.TemplateID, &e.PurchaserID, &e.PurchaserOfficeID,
+		&e.SaleAmountCents, &e.CommissionCents, &e.AuthorEarningCents,
+		&stripeID, &e.Status, &e.RefundOf, &e.CreatedAt, &e.BundleID,
+	); err != nil {
+		return nil, err
+	}
+	if stripeID != nil {
+		e.StripePaymentIntentID = *stripeID
+	}
+	return &e, nil
+}
+
+// GetEarning retrieves a single earning
+func (r *EarningsRepository) GetEarning(ctx context.Context, id uuid.UUID) (*domain.AuthorEarning, error) {
+	e, err := scanEarning(r.db.QueryRow(ctx, `SELECT `+earningColumns+` FROM author_earnings WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return e, err
+}
+
 // GetAuthorEarnings retrieves earnings for an author
 func (r *EarningsRepository) GetAuthorEarnings(
 	ctx context.Context,
@@ -47,9 +112,7 @@ func (r *EarningsRepository) GetAuthorEarnings(
 	limit, offset int,
 ) ([]domain.AuthorEarning, error) {
 	query := `
-		SELECT id, author_id, template_id, purchaser_id, purchaser_office_id,
-		       sale_amount_cents, commission_cents, author_earning_cents,
-		       stripe_payment_intent_id, status, created_at
+		SELECT ` + earningColumns + `
 		FROM author_earnings
 		WHERE author_id = $1
 		ORDER BY created_at DESC
@@ -64,23 +127,75 @@ func (r *EarningsRepository) GetAuthorEarnings(
 
 	var earnings []domain.AuthorEarning
 	for rows.Next() {
-		var e domain.AuthorEarning
-		var stripeID *string
-		if err := rows.Scan(
-			&e.ID, &e.AuthorID, &e.TemplateID, &e.PurchaserID, &e.PurchaserOfficeID,
-			&e.SaleAmountCents, &e.CommissionCents, &e.AuthorEarningCents,
-			&stripeID, &e.Status, &e.CreatedAt,
-		); err != nil {
+		e, err := scanEarning(rows)
+		if err != nil {
 			return nil, err
 		}
-		if stripeID != nil {
-			e.StripePaymentIntentID = *stripeID
-		}
-		earnings = append(earnings, e)
+		earnings = append(earnings, *e)
 	}
 	return earnings, rows.Err()
 }
 
+// ReverseSale marks a completed sale refunded and records its reversal, an
+// earning with the sale's amounts negated, taking the author's share back
+// out of their balance. The balance can go below zero when the share was
+// already paid out; later sales make up for it.
+func (r *EarningsRepository) ReverseSale(ctx context.Context, earningID uuid.UUID) (*domain.AuthorEarning, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sale, err := scanEarning(tx.QueryRow(ctx, `
+		UPDATE author_earnings SET status = 'refunded'
+		WHERE id = $1 AND status = 'completed'
+		RETURNING `+earningColumns, earningID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reversal, err := scanEarning(tx.QueryRow(ctx, `
+		INSERT INTO author_earnings (
+			author_id, template_id, purchaser_id, purchaser_office_id,
+			sale_amount_cents, commission_cents, author_earning_cents,
+			stripe_payment_intent_id, status, refund_of, bundle_id
+		)
+		SELECT author_id, template_id, purchaser_id, purchaser_office_id,
+		       -sale_amount_cents, -commission_cents, -author_earning_cents,
+		       stripe_payment_intent_id, 'refund', id, bundle_id
+		FROM author_earnings WHERE id = $1
+		RETURNING `+earningColumns, earningID))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE author_balances
+		SET total_earned_cents = total_earned_cents - $2,
+		    updated_at = NOW()
+		WHERE author_id = $1
+	`, sale.AuthorID, sale.AuthorEarningCents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// CountAuthorEarnings returns the number of sales recorded for an author
+func (r *EarningsRepository) CountAuthorEarnings(ctx context.Context, authorID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM author_earnings WHERE author_id = $1`, authorID).Scan(&count)
+	return count, err
+}
+
 // GetAuthorBalance retrieves the author's current balance
 func (r *EarningsRepository) GetAuthorBalance(
 	ctx context.Context,
@@ -141,27 +256,97 @@ func (r *EarningsRepository) GetPayoutRequests(
 
 	var payouts []domain.PayoutRequest
 	for rows.Next() {
-		var p domain.PayoutRequest
-		var stripeID, failureReason *string
-		var processedAt *time.Time
-		if err := rows.Scan(
-			&p.ID, &p.AuthorID, &p.AmountCents, &p.Status,
-			&stripeID, &failureReason, &p.CreatedAt, &processedAt,
-		); err != nil {
+		p, err := scanPayoutRequest(rows)
+		if err != nil {
 			return nil, err
 		}
-		if stripeID != nil {
-			p.StripeTransferID = *stripeID
-		}
-		if failureReason != nil {
-			p.FailureReason = *failureReason
-		}
-		p.ProcessedAt = processedAt
-		payouts = append(payouts, p)
+		payouts = append(payouts, *p)
 	}
 	return payouts, rows.Err()
 }
 
+// ListPayoutRequests retrieves a page of every author's payout requests,
+// oldest first so the queue is worked in order, and how many there are. An
+// empty status lists them all.
+func (r *EarningsRepository) ListPayoutRequests(
+	ctx context.Context,
+	status domain.PayoutStatus,
+	limit, offset int,
+) ([]domain.PayoutRequest, int, error) {
+	q := &queryBuilder{}
+	if status != "" {
+		q.where("status = " + q.arg(status))
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM payout_requests`+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, author_id, amount_cents, status,
+		       stripe_transfer_id, failure_reason, created_at, processed_at
+		FROM payout_requests` + q.whereClause() + `
+		ORDER BY created_at ASC
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var payouts []domain.PayoutRequest
+	for rows.Next() {
+		p, err := scanPayoutRequest(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		payouts = append(payouts, *p)
+	}
+	return payouts, total, rows.Err()
+}
+
+// GetPayoutRequest retrieves a single payout request
+func (r *EarningsRepository) GetPayoutRequest(ctx context.Context, payoutID uuid.UUID) (*domain.PayoutRequest, error) {
+	query := `
+		SELECT id, author_id, amount_cents, status,
+		       stripe_transfer_id, failure_reason, created_at, processed_at
+		FROM payout_requests
+		WHERE id = $1
+	`
+
+	p, err := scanPayoutRequest(r.db.QueryRow(ctx, query, payoutID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// scanPayoutRequest scans a payout_requests row
+func scanPayoutRequest(row pgx.Row) (*domain.PayoutRequest, error) {
+	var p domain.PayoutRequest
+	var stripeID, failureReason *string
+	var processedAt *time.Time
+	if err := row.Scan(
+		&p.ID, &p.AuthorID, &p.AmountCents, &p.Status,
+		&stripeID, &failureReason, &p.CreatedAt, &processedAt,
+	); err != nil {
+		return nil, err
+	}
+	if stripeID != nil {
+		p.StripeTransferID = *stripeID
+	}
+	if failureReason != nil {
+		p.FailureReason = *failureReason
+	}
+	p.ProcessedAt = processedAt
+	return &p, nil
+}
+
 // CompletePayout marks a payout as completed
 func (r *EarningsRepository) CompletePayout(
 	ctx context.Context,
@@ -173,6 +358,52 @@ func (r *EarningsRepository) CompletePayout(
 	return err
 }
 
+// FailPayout marks a pending or processing payout as failed and returns its
+// amount to the author's available balance. It returns ErrNotFound if the
+// payout does not exist or was already settled.
+func (r *EarningsRepository) FailPayout(
+	ctx context.Context,
+	payoutID uuid.UUID,
+	reason string,
+) (*domain.PayoutRequest, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE payout_requests
+		SET status = $2, failure_reason = $3, processed_at = NOW()
+		WHERE id = $1 AND status IN ($4, $5)
+		RETURNING id, author_id, amount_cents, status,
+		          stripe_transfer_id, failure_reason, created_at, processed_at
+	`
+	p, err := scanPayoutRequest(tx.QueryRow(ctx, query, payoutID,
+		domain.PayoutStatusFailed, reason, domain.PayoutStatusPending, domain.PayoutStatusProcessing))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE author_balances
+		SET pending_payout_cents = pending_payout_cents - $2,
+		    updated_at = NOW()
+		WHERE author_id = $1
+	`, p.AuthorID, p.AmountCents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // GetEarningsSummary retrieves earnings summary for an author
 func (r *EarningsRepository) GetEarningsSummary(
 	ctx context.Context,
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmailOutboxRepository implements domain.EmailOutboxRepository
+type EmailOutboxRepository struct {
+	db conn
+}
+
+// NewEmailOutboxRepository creates a new EmailOutboxRepository
+func NewEmailOutboxRepository(db *pgxpool.Pool) *EmailOutboxRepository {
+	return &EmailOutboxRepository{db: conn{db}}
+}
+
+const outboxEmailColumns = `id, to_address, subject, body, html_body, template, status, attempts,
+	next_attempt_at, last_error, created_at, sent_at`
+
+// Create queues an email
+func (r *EmailOutboxRepository) Create(ctx context.Context, email *domain.OutboxEmail) error {
+	query := `
+		INSERT INTO email_outbox (id, to_address, subject, body, html_body, template, status, attempts,
+			next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.Exec(ctx, query,
+		email.ID, email.Email.To, email.Email.Subject, email.Email.Body, email.Email.HTMLBody, email.Email.Template,
+		email.Status, email.Attempts, email.NextAttemptAt, email.CreatedAt,
+	)
+	return err
+}
+
+// ClaimDue claims up to limit pending emails due at now. The status and due
+// time are checked again in the UPDATE, so an email claimed by another
+// worker meanwhile is skipped.
+func (r *EmailOutboxRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxEmail, error) {
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		) AND status = 'pending' AND next_attempt_at <= $1
+		RETURNING ` + outboxEmailColumns
+
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*domain.OutboxEmail
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// MarkSent records that an email was delivered
+func (r *EmailOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE email_outbox SET status = 'sent', sent_at = NOW(), last_error = '' WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// MarkFailed records a failed attempt, scheduling the next one or giving up
+// when nextAttemptAt is nil
+func (r *EmailOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET last_error = $2,
+			status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($3, next_attempt_at)
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, lastError, nextAttemptAt)
+	return err
+}
+
+func scanOutboxEmail(row pgx.Row) (*domain.OutboxEmail, error) {
+	var email domain.OutboxEmail
+	err := row.Scan(
+		&email.ID, &email.Email.To, &email.Email.Subject, &email.Email.Body, &email.Email.HTMLBody, &email.Email.Template,
+		&email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError, &email.CreatedAt, &email.SentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
@@ -10,14 +10,14 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// FeedbackRepository handles feedback data operations
+// FeedbackRepository implements domain.FeedbackRepository
 type FeedbackRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewFeedbackRepository creates a new FeedbackRepository
 func NewFeedbackRepository(db *pgxpool.Pool) *FeedbackRepository {
-	return &FeedbackRepository{db: db}
+	return &FeedbackRepository{db: conn{db}}
 }
 
 // CreateFeedback creates a new feedback record
@@ -105,26 +105,21 @@ func (r *FeedbackRepository) GetMessageByID(ctx context.Context, messageID uuid.
 
 // GetAgentMemories returns memories for an agent with optional type filter
 func (r *FeedbackRepository) GetAgentMemories(ctx context.Context, agentID uuid.UUID, memoryType string, limit int) ([]*domain.AgentMemory, error) {
-	query := `
-		SELECT id, office_id, agent_id, key, value, COALESCE(vector_id, '') as vector_id, 
-		       COALESCE(memory_type, 'fact') as memory_type, COALESCE(importance_score, 0.5) as importance_score,
-			   created_at, updated_at
-		FROM agent_memories
-		WHERE agent_id = $1
-	`
-	args := []interface{}{agentID}
-	argNum := 2
-
+	q := &queryBuilder{}
+	q.where("agent_id = " + q.arg(agentID))
+	q.where("archived_at IS NULL")
 	if memoryType != "" {
-		query += ` AND memory_type = $` + string(rune('0'+argNum))
-		args = append(args, memoryType)
-		argNum++
+		q.where("memory_type = " + q.arg(memoryType))
 	}
 
-	query += ` ORDER BY importance_score DESC, updated_at DESC LIMIT $` + string(rune('0'+argNum))
-	args = append(args, limit)
+	query := `
+		SELECT id, office_id, agent_id, key, value, COALESCE(vector_id, '') as vector_id,
+		       COALESCE(memory_type, 'fact') as memory_type, COALESCE(importance_score, 0.5) as importance_score,
+		       COALESCE(source, 'system') as source, source_id, created_at, updated_at
+		FROM agent_memories` + q.whereClause() + `
+		ORDER BY importance_score DESC, updated_at DESC LIMIT ` + q.arg(limit)
 
-	rows, err := r.db.Query(ctx, query, args...)
+	rows, err := r.db.Query(ctx, query, q.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +128,7 @@ func (r *FeedbackRepository) GetAgentMemories(ctx context.Context, agentID uuid.
 	var memories []*domain.AgentMemory
 	for rows.Next() {
 		var m domain.AgentMemory
-		if err := rows.Scan(&m.ID, &m.OfficeID, &m.AgentID, &m.Key, &m.Value, &m.VectorID, &m.MemoryType, &m.ImportanceScore, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.OfficeID, &m.AgentID, &m.Key, &m.Value, &m.VectorID, &m.MemoryType, &m.ImportanceScore, &m.Source, &m.SourceID, &m.CreatedAt, &m.UpdatedAt); err != nil {
 			return nil, err
 		}
 		memories = append(memories, &m)
@@ -143,7 +138,7 @@ func (r *FeedbackRepository) GetAgentMemories(ctx context.Context, agentID uuid.
 
 // GetAgentMemoryCount returns the count of memories for an agent
 func (r *FeedbackRepository) GetAgentMemoryCount(ctx context.Context, agentID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1`
+	query := `SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archived_at IS NULL`
 	var count int
 	err := r.db.QueryRow(ctx, query, agentID).Scan(&count)
 	return count, err
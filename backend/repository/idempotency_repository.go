@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdempotencyRepository implements domain.IdempotencyRepository
+type IdempotencyRepository struct {
+	db conn
+}
+
+// NewIdempotencyRepository creates a new IdempotencyRepository
+func NewIdempotencyRepository(db *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{db: conn{db}}
+}
+
+// Claim inserts the key for a new request. A key whose first request never
+// completed (e.g. the process died mid-request) can be claimed again once it
+// is five minutes old. Otherwise the existing record is returned.
+func (r *IdempotencyRepository) Claim(ctx context.Context, key *domain.IdempotencyKey) (*domain.IdempotencyKey, error) {
+	query := `
+		INSERT INTO idempotency_keys (office_id, scope, idempotency_key, request_hash, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (office_id, scope, idempotency_key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, created_at = NOW()
+			WHERE idempotency_keys.resource_id IS NULL
+				AND idempotency_keys.created_at < NOW() - INTERVAL '5 minutes'
+		RETURNING created_at
+	`
+	err := r.db.QueryRow(ctx, query, key.OfficeID, key.Scope, key.Key, key.RequestHash).Scan(&key.CreatedAt)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	existing := domain.IdempotencyKey{OfficeID: key.OfficeID, Scope: key.Scope, Key: key.Key}
+	query = `
+		SELECT request_hash, resource_id, created_at
+		FROM idempotency_keys
+		WHERE office_id = $1 AND scope = $2 AND idempotency_key = $3
+	`
+	err = r.db.QueryRow(ctx, query, key.OfficeID, key.Scope, key.Key).
+		Scan(&existing.RequestHash, &existing.ResourceID, &existing.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete records the resource created by the request that claimed the key
+func (r *IdempotencyRepository) Complete(ctx context.Context, key *domain.IdempotencyKey, resourceID uuid.UUID) error {
+	query := `
+		UPDATE idempotency_keys
+		SET resource_id = $4, completed_at = NOW()
+		WHERE office_id = $1 AND scope = $2 AND idempotency_key = $3
+	`
+	_, err := r.db.Exec(ctx, query, key.OfficeID, key.Scope, key.Key, resourceID)
+	if err == nil {
+		key.ResourceID = &resourceID
+	}
+	return err
+}
+
+// Release drops an uncompleted claim so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, key *domain.IdempotencyKey) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE office_id = $1 AND scope = $2 AND idempotency_key = $3 AND resource_id IS NULL
+	`
+	_, err := r.db.Exec(ctx, query, key.OfficeID, key.Scope, key.Key)
+	return err
+}
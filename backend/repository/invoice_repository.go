@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InvoiceRepository implements domain.InvoiceRepository
+type InvoiceRepository struct {
+	db conn
+}
+
+// NewInvoiceRepository creates a new InvoiceRepository
+func NewInvoiceRepository(db *pgxpool.Pool) *InvoiceRepository {
+	return &InvoiceRepository{db: conn{db}}
+}
+
+const invoiceColumns = `id, office_id, subscription_id, number, status, currency, amount_cents, amount_paid_cents,
+	period_start, period_end, lines, due_at, paid_at, created_at`
+
+// Create issues a manual invoice. Numbers are assigned in sequence.
+func (r *InvoiceRepository) Create(ctx context.Context, invoice *domain.Invoice) error {
+	id, err := uuid.Parse(invoice.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO invoices (id, office_id, subscription_id, number, status, currency, amount_cents, amount_paid_cents,
+			period_start, period_end, lines, due_at, paid_at, created_at)
+		VALUES ($1, $2, $3, 'INV-' || LPAD(nextval('invoice_numbers')::text, 6, '0'), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (subscription_id, period_start) DO NOTHING
+		RETURNING number
+	`
+	err = r.db.QueryRow(ctx, query,
+		id,
+		invoice.OfficeID,
+		invoice.SubscriptionID,
+		invoice.Status,
+		invoice.Currency,
+		invoice.AmountCents,
+		invoice.AmountPaidCents,
+		invoice.PeriodStart,
+		invoice.PeriodEnd,
+		invoice.Lines,
+		invoice.DueAt,
+		invoice.PaidAt,
+		invoice.CreatedAt,
+	).Scan(&invoice.Number)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID retrieves a manual invoice by ID
+func (r *InvoiceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices WHERE id = $1`
+
+	invoice, err := scanInvoice(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return invoice, err
+}
+
+// ListByOffice returns the office's most recent manual invoices
+func (r *InvoiceRepository) ListByOffice(ctx context.Context, officeID uuid.UUID, limit int) ([]*domain.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices
+		WHERE office_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, officeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []*domain.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// GetLatestBySubscription returns the subscription's most recent manual
+// invoice
+func (r *InvoiceRepository) GetLatestBySubscription(ctx context.Context, subscriptionID uuid.UUID) (*domain.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	invoice, err := scanInvoice(r.db.QueryRow(ctx, query, subscriptionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return invoice, err
+}
+
+// GetUninvoiced returns active subscriptions on tiers that are not billed
+// through Stripe and have no invoice for their current period, earliest
+// period first
+func (r *InvoiceRepository) GetUninvoiced(ctx context.Context, tiers []domain.SubscriptionTier, limit int) ([]*domain.Subscription, error) {
+	names := make([]string, len(tiers))
+	for i, tier := range tiers {
+		names[i] = string(tier)
+	}
+
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		WHERE s.status = $1 AND s.tier = ANY($2)
+			AND COALESCE(s.stripe_subscription_id, '') = ''
+			AND NOT EXISTS (
+				SELECT 1 FROM invoices i
+				WHERE i.subscription_id = s.id AND i.period_start = s.current_period_start
+			)
+		ORDER BY s.current_period_start ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, domain.SubscriptionStatusActive, names, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+func scanInvoice(row pgx.Row) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	var id uuid.UUID
+	err := row.Scan(
+		&id,
+		&invoice.OfficeID,
+		&invoice.SubscriptionID,
+		&invoice.Number,
+		&invoice.Status,
+		&invoice.Currency,
+		&invoice.AmountCents,
+		&invoice.AmountPaidCents,
+		&invoice.PeriodStart,
+		&invoice.PeriodEnd,
+		&invoice.Lines,
+		&invoice.DueAt,
+		&invoice.PaidAt,
+		&invoice.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	invoice.ID = id.String()
+	invoice.Source = domain.InvoiceSourceManual
+	return &invoice, nil
+}
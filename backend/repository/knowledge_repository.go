@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KnowledgeRepository implements domain.KnowledgeRepository
+type KnowledgeRepository struct {
+	db conn
+}
+
+// NewKnowledgeRepository creates a new KnowledgeRepository
+func NewKnowledgeRepository(db *pgxpool.Pool) *KnowledgeRepository {
+	return &KnowledgeRepository{db: conn{db}}
+}
+
+const knowledgeDocumentColumns = `
+	id, office_id, title, source, COALESCE(filename, ''), size_bytes, chunk_count, created_by, created_at
+`
+
+const knowledgeMatchColumns = `c.id, c.document_id, c.office_id, c.position, c.content, d.title`
+
+// Create stores a document and its chunks in one round trip, which runs as one transaction
+func (r *KnowledgeRepository) Create(ctx context.Context, document *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		INSERT INTO knowledge_documents (id, office_id, title, source, filename, content, size_bytes, chunk_count, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`,
+		document.ID,
+		document.OfficeID,
+		document.Title,
+		document.Source,
+		nullableString(document.Filename),
+		document.Content,
+		document.SizeBytes,
+		document.ChunkCount,
+		document.CreatedBy,
+		document.CreatedAt,
+	)
+	for _, chunk := range chunks {
+		batch.Queue(`
+			INSERT INTO knowledge_chunks (id, document_id, office_id, position, content, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, chunk.ID, chunk.DocumentID, chunk.OfficeID, chunk.Position, chunk.Content, document.CreatedAt)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// GetByID retrieves a document with its content
+func (r *KnowledgeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.KnowledgeDocument, error) {
+	query := `SELECT ` + knowledgeDocumentColumns + `, content FROM knowledge_documents WHERE id = $1`
+	d, err := scanKnowledgeDocument(r.db.QueryRow(ctx, query, id), true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return d, err
+}
+
+// GetByOfficeID returns the office's documents without their content, newest first
+func (r *KnowledgeRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.KnowledgeDocument, error) {
+	query := `
+		SELECT ` + knowledgeDocumentColumns + `
+		FROM knowledge_documents
+		WHERE office_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*domain.KnowledgeDocument
+	for rows.Next() {
+		d, err := scanKnowledgeDocument(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, d)
+	}
+	return documents, rows.Err()
+}
+
+// CountByOfficeID returns how many documents the office has
+func (r *KnowledgeRepository) CountByOfficeID(ctx context.Context, officeID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM knowledge_documents WHERE office_id = $1`, officeID).Scan(&count)
+	return count, err
+}
+
+// Delete removes a document; its chunks are deleted with it
+func (r *KnowledgeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM knowledge_documents WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// GetUnembedded returns chunks without an embedding, oldest first
+func (r *KnowledgeRepository) GetUnembedded(ctx context.Context, limit int) ([]*domain.KnowledgeChunk, error) {
+	query := `
+		SELECT id, document_id, office_id, position, content
+		FROM knowledge_chunks
+		WHERE embedding IS NULL
+		ORDER BY created_at, position
+		LIMIT $1
+	`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []*domain.KnowledgeChunk
+	for rows.Next() {
+		var c domain.KnowledgeChunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.OfficeID, &c.Position, &c.Content); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, &c)
+	}
+	return chunks, rows.Err()
+}
+
+// SetEmbedding stores a chunk's embedding
+func (r *KnowledgeRepository) SetEmbedding(ctx context.Context, chunkID uuid.UUID, embedding []float32) error {
+	result, err := r.db.Exec(ctx, `UPDATE knowledge_chunks SET embedding = $2::vector WHERE id = $1`, chunkID, vectorLiteral(embedding))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SearchByEmbedding returns the office's embedded chunks by cosine similarity to the embedding
+func (r *KnowledgeRepository) SearchByEmbedding(ctx context.Context, officeID uuid.UUID, embedding []float32, limit int) ([]*domain.KnowledgeMatch, error) {
+	query := `
+		SELECT ` + knowledgeMatchColumns + `, 1 - (c.embedding <=> $2::vector)
+		FROM knowledge_chunks c
+		JOIN knowledge_documents d ON d.id = c.document_id
+		WHERE c.office_id = $1 AND c.embedding IS NOT NULL
+		ORDER BY c.embedding <=> $2::vector
+		LIMIT $3
+	`
+	return r.queryMatches(ctx, query, true, officeID, vectorLiteral(embedding), limit)
+}
+
+// SearchByText returns the office's chunks containing any of the query's words, ranked by how well
+// they match
+func (r *KnowledgeRepository) SearchByText(ctx context.Context, officeID uuid.UUID, query string, limit int) ([]*domain.KnowledgeMatch, error) {
+	// plainto_tsquery requires every word; any of them will do
+	sql := `
+		WITH q AS (
+			SELECT NULLIF(replace(plainto_tsquery('english', $2)::text, '&', '|'), '')::tsquery AS words
+		)
+		SELECT ` + knowledgeMatchColumns + `
+		FROM knowledge_chunks c
+		JOIN knowledge_documents d ON d.id = c.document_id, q
+		WHERE c.office_id = $1 AND to_tsvector('english', c.content) @@ q.words
+		ORDER BY ts_rank(to_tsvector('english', c.content), q.words) DESC, d.created_at DESC, c.position
+		LIMIT $3
+	`
+	return r.queryMatches(ctx, sql, false, officeID, query, limit)
+}
+
+// queryMatches runs a search selecting knowledgeMatchColumns and, if withSimilarity, the similarity
+func (r *KnowledgeRepository) queryMatches(ctx context.Context, query string, withSimilarity bool, args ...any) ([]*domain.KnowledgeMatch, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*domain.KnowledgeMatch
+	for rows.Next() {
+		var c domain.KnowledgeChunk
+		match := &domain.KnowledgeMatch{KnowledgeChunk: &c}
+		dest := []any{&c.ID, &c.DocumentID, &c.OfficeID, &c.Position, &c.Content, &match.DocumentTitle}
+		if withSimilarity {
+			dest = append(dest, &match.Similarity)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+func scanKnowledgeDocument(row pgx.Row, withContent bool) (*domain.KnowledgeDocument, error) {
+	var d domain.KnowledgeDocument
+	dest := []any{&d.ID, &d.OfficeID, &d.Title, &d.Source, &d.Filename, &d.SizeBytes, &d.ChunkCount, &d.CreatedBy, &d.CreatedAt}
+	if withContent {
+		dest = append(dest, &d.Content)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
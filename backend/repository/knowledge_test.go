@@ -0,0 +1,66 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestKnowledgeSearch(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewKnowledgeRepository(testDB.Pool)
+	user := testDB.User(t)
+	office := testDB.Office(t, user)
+
+	document := &domain.KnowledgeDocument{
+		ID: uuid.New(), OfficeID: office, Title: "Style guide", Source: domain.KnowledgeSourceNote,
+		Content: "Write in British English.\n\nInvoices are due within 30 days.", SizeBytes: 60, ChunkCount: 2,
+		CreatedBy: &user, CreatedAt: time.Now(),
+	}
+	chunks := []*domain.KnowledgeChunk{
+		{ID: uuid.New(), DocumentID: document.ID, OfficeID: office, Position: 0, Content: "Write in British English."},
+		{ID: uuid.New(), DocumentID: document.ID, OfficeID: office, Position: 1, Content: "Invoices are due within 30 days."},
+	}
+	if err := repo.Create(ctx, document, chunks); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Any of the query's words matches
+	matches, err := repo.SearchByText(ctx, office, "when are invoices paid", 10)
+	if err != nil || len(matches) != 1 || matches[0].ID != chunks[1].ID || matches[0].DocumentTitle != "Style guide" {
+		t.Fatalf("SearchByText = %v, %v; want the invoice chunk", matches, err)
+	}
+
+	unembedded, err := repo.GetUnembedded(ctx, 1000)
+	if err != nil {
+		t.Fatalf("GetUnembedded: %v", err)
+	}
+	for _, chunk := range unembedded {
+		if chunk.OfficeID == office {
+			if err := repo.SetEmbedding(ctx, chunk.ID, unitEmbedding(chunk.Position)); err != nil {
+				t.Fatalf("SetEmbedding: %v", err)
+			}
+		}
+	}
+	matches, err = repo.SearchByEmbedding(ctx, office, unitEmbedding(0), 1)
+	if err != nil || len(matches) != 1 || matches[0].ID != chunks[0].ID || matches[0].Similarity < 0.99 {
+		t.Fatalf("SearchByEmbedding = %v, %v; want the style chunk", matches, err)
+	}
+
+	if err := repo.Delete(ctx, document.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, document.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID deleted document error = %v, want ErrNotFound", err)
+	}
+	if matches, err := repo.SearchByText(ctx, office, "invoices", 10); err != nil || len(matches) != 0 {
+		t.Errorf("SearchByText after Delete = %v, %v; want the chunks deleted", matches, err)
+	}
+}
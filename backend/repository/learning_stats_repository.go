@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LearningStatsRepository implements domain.LearningStatsRepository
+type LearningStatsRepository struct {
+	db conn
+}
+
+// NewLearningStatsRepository creates a new LearningStatsRepository
+func NewLearningStatsRepository(db *pgxpool.Pool) *LearningStatsRepository {
+	return &LearningStatsRepository{db: conn{db}}
+}
+
+// GetByAgentID retrieves the learning stats row for an agent
+func (r *LearningStatsRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID) (*domain.AgentLearningStats, error) {
+	query := `
+		SELECT id, agent_id,
+		       COALESCE(fact_count, 0), COALESCE(preference_count, 0),
+		       COALESCE(correction_count, 0), COALESCE(insight_count, 0),
+		       COALESCE(positive_feedback_count, 0), COALESCE(negative_feedback_count, 0),
+		       COALESCE(average_rating, 0), COALESCE(total_interactions, 0),
+		       created_at, updated_at,
+		       COALESCE(merged_memory_count, 0), COALESCE(archived_memory_count, 0), last_consolidated_at
+		FROM agent_learning_stats
+		WHERE agent_id = $1
+	`
+	var stats domain.AgentLearningStats
+	err := r.db.QueryRow(ctx, query, agentID).Scan(
+		&stats.ID, &stats.AgentID,
+		&stats.FactCount, &stats.PreferenceCount,
+		&stats.CorrectionCount, &stats.InsightCount,
+		&stats.PositiveFeedbackCount, &stats.NegativeFeedbackCount,
+		&stats.AverageRating, &stats.TotalInteractions,
+		&stats.CreatedAt, &stats.UpdatedAt,
+		&stats.MergedMemoryCount, &stats.ArchivedMemoryCount, &stats.LastConsolidatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Refresh recomputes an agent's learning stats row from its memories, feedback and tasks
+func (r *LearningStatsRepository) Refresh(ctx context.Context, agentID uuid.UUID) error {
+	query := `
+		INSERT INTO agent_learning_stats (
+			agent_id, fact_count, preference_count, correction_count, insight_count,
+			positive_feedback_count, negative_feedback_count, average_rating, total_interactions,
+			merged_memory_count, archived_memory_count
+		)
+		SELECT
+			$1,
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archived_at IS NULL AND COALESCE(memory_type, 'fact') = 'fact'),
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archived_at IS NULL AND memory_type = 'preference'),
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archived_at IS NULL AND memory_type = 'correction'),
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archived_at IS NULL AND memory_type = 'insight'),
+			(SELECT COUNT(*) FROM agent_feedback WHERE agent_id = $1 AND feedback_type = 'positive'),
+			(SELECT COUNT(*) FROM agent_feedback WHERE agent_id = $1 AND feedback_type = 'negative'),
+			(SELECT COALESCE(AVG(rating), 0)::DECIMAL(3,2) FROM agent_feedback WHERE agent_id = $1),
+			(SELECT COUNT(*) FROM tasks WHERE agent_id = $1 AND status = 'done'),
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archive_reason = 'merged'),
+			(SELECT COUNT(*) FROM agent_memories WHERE agent_id = $1 AND archive_reason = 'capped')
+		ON CONFLICT (agent_id) DO UPDATE SET
+			fact_count = EXCLUDED.fact_count,
+			preference_count = EXCLUDED.preference_count,
+			correction_count = EXCLUDED.correction_count,
+			insight_count = EXCLUDED.insight_count,
+			positive_feedback_count = EXCLUDED.positive_feedback_count,
+			negative_feedback_count = EXCLUDED.negative_feedback_count,
+			average_rating = EXCLUDED.average_rating,
+			total_interactions = EXCLUDED.total_interactions,
+			merged_memory_count = EXCLUDED.merged_memory_count,
+			archived_memory_count = EXCLUDED.archived_memory_count
+	`
+	_, err := r.db.Exec(ctx, query, agentID)
+	return err
+}
+
+// RecordConsolidation records when an agent's memories were consolidated
+func (r *LearningStatsRepository) RecordConsolidation(ctx context.Context, agentID uuid.UUID, at time.Time) error {
+	query := `
+		INSERT INTO agent_learning_stats (agent_id, last_consolidated_at)
+		VALUES ($1, $2)
+		ON CONFLICT (agent_id) DO UPDATE SET last_consolidated_at = EXCLUDED.last_consolidated_at
+	`
+	_, err := r.db.Exec(ctx, query, agentID, at)
+	return err
+}
@@ -0,0 +1,29 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/internal/pgtest"
+)
+
+// testDB is the database shared by the integration tests of the package
+var testDB *pgtest.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	db, err := pgtest.Start(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "start test database: %v\n", err)
+		os.Exit(1)
+	}
+	testDB = db
+
+	code := m.Run()
+	db.Close(ctx)
+	os.Exit(code)
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -10,12 +12,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// MarketplaceRepository implements domain.MarketplaceRepository
 type MarketplaceRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 func NewMarketplaceRepository(db *pgxpool.Pool) *MarketplaceRepository {
-	return &MarketplaceRepository{db: db}
+	return &MarketplaceRepository{db: conn{db}}
 }
 
 // parseSkillTags parses JSON skill tags from database
@@ -27,91 +30,140 @@ func parseSkillTags(data []byte) []string {
 	return tags
 }
 
-// ListTemplates returns templates with marketplace filtering
-func (r *MarketplaceRepository) ListTemplates(ctx context.Context, filter MarketplaceFilter) ([]domain.AgentTemplate, int, error) {
-	// Build query with filters
-	baseQuery := `
-		SELECT id, name, role, system_prompt, avatar_url, skill_tags,
-		       author_id, COALESCE(author_name, 'Synoffice Team') as author_name, 
+// templateColumns is the select list read by scanTemplate
+const templateColumns = `id, name, role, system_prompt, avatar_url, skill_tags,
+		       author_id, COALESCE(author_name, 'Synoffice Team') as author_name,
 		       COALESCE(category, 'general') as category, COALESCE(description, '') as description,
 		       COALESCE(is_featured, false) as is_featured, COALESCE(is_public, true) as is_public,
 		       COALESCE(is_premium, false) as is_premium, COALESCE(price_cents, 0) as price_cents,
 		       COALESCE(download_count, 0) as download_count, COALESCE(rating_average, 0) as rating_average,
 		       COALESCE(rating_count, 0) as rating_count, COALESCE(version, '1.0.0') as version,
-		       COALESCE(status, 'approved') as status, created_at, COALESCE(updated_at, created_at) as updated_at
-		FROM agent_templates
-		WHERE COALESCE(is_public, true) = true AND COALESCE(status, 'approved') = 'approved'
-	`
-	countQuery := `SELECT COUNT(*) FROM agent_templates WHERE COALESCE(is_public, true) = true AND COALESCE(status, 'approved') = 'approved'`
+		       COALESCE(status, 'approved') as status, COALESCE(rejection_reason, '') as rejection_reason, reviewed_at,
+		       created_at, COALESCE(updated_at, created_at) as updated_at, risk_score, risk_findings,
+		       favorite_count, license_type, license_resale_prohibited, license_terms`
+
+// scanTemplate scans a row selected with templateColumns
+func scanTemplate(row pgx.Row) (*domain.AgentTemplate, error) {
+	var t domain.AgentTemplate
+	var skillTags, riskFindings []byte
+	var avatarURL *string
+	err := row.Scan(
+		&t.ID, &t.Name, &t.Role, &t.SystemPrompt, &avatarURL, &skillTags,
+		&t.AuthorID, &t.AuthorName, &t.Category, &t.Description,
+		&t.IsFeatured, &t.IsPublic, &t.IsPremium, &t.PriceCents,
+		&t.DownloadCount, &t.RatingAverage, &t.RatingCount, &t.Version,
+		&t.Status, &t.RejectionReason, &t.ReviewedAt, &t.CreatedAt, &t.UpdatedAt,
+		&t.RiskScore, &riskFindings, &t.FavoriteCount,
+		&t.License.Type, &t.License.ResaleProhibited, &t.License.Terms,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(riskFindings, &t.RiskFindings); err != nil {
+		return nil, err
+	}
+
+	if avatarURL != nil {
+		t.AvatarURL = *avatarURL
+	}
+	t.SkillTags = parseSkillTags(skillTags)
+	return &t, nil
+}
+
+// marshalRiskFindings encodes a template's scan findings, storing none as
+// an empty array
+func marshalRiskFindings(findings []domain.TemplateFinding) ([]byte, error) {
+	if findings == nil {
+		findings = []domain.TemplateFinding{}
+	}
+	return json.Marshal(findings)
+}
 
-	args := []interface{}{}
-	argCount := 0
+// ListTemplates returns templates with marketplace filtering
+func (r *MarketplaceRepository) ListTemplates(ctx context.Context, filter domain.MarketplaceFilter) ([]domain.AgentTemplate, int, error) {
+	q := &queryBuilder{}
+	q.where("COALESCE(is_public, true) = true")
+	q.where("COALESCE(status, 'approved') = 'approved'")
 
-	// Category filter
+	// Category filters
+	categories := filter.Categories
 	if filter.Category != "" {
-		argCount++
-		baseQuery += " AND category = $" + string(rune('0'+argCount))
-		countQuery += " AND category = $" + string(rune('0'+argCount))
-		args = append(args, filter.Category)
+		categories = append(categories, filter.Category)
+	}
+	if len(categories) == 1 {
+		q.where("category = " + q.arg(categories[0]))
+	} else if len(categories) > 1 {
+		q.where("category = ANY(" + q.arg(categories) + ")")
 	}
 
 	// Featured filter
 	if filter.IsFeatured != nil {
-		argCount++
-		baseQuery += " AND is_featured = $" + string(rune('0'+argCount))
-		countQuery += " AND is_featured = $" + string(rune('0'+argCount))
-		args = append(args, *filter.IsFeatured)
+		q.where("is_featured = " + q.arg(*filter.IsFeatured))
 	}
 
 	// Premium filter
 	if filter.IsPremium != nil {
-		argCount++
-		baseQuery += " AND is_premium = $" + string(rune('0'+argCount))
-		countQuery += " AND is_premium = $" + string(rune('0'+argCount))
-		args = append(args, *filter.IsPremium)
+		q.where("is_premium = " + q.arg(*filter.IsPremium))
+	}
+
+	// Price range filter (cents)
+	if filter.MinPriceCents != nil {
+		q.where("COALESCE(price_cents, 0) >= " + q.arg(*filter.MinPriceCents))
+	}
+	if filter.MaxPriceCents != nil {
+		q.where("COALESCE(price_cents, 0) <= " + q.arg(*filter.MaxPriceCents))
+	}
+
+	// Rating filter
+	if filter.MinRating != nil {
+		q.where("COALESCE(rating_average, 0) >= " + q.arg(*filter.MinRating))
+	}
+
+	// Author filter
+	if filter.AuthorID != nil {
+		q.where("author_id = " + q.arg(*filter.AuthorID))
 	}
 
 	// Search filter
 	if filter.Search != "" {
-		argCount++
-		searchArg := "%" + filter.Search + "%"
-		baseQuery += " AND (name ILIKE $" + string(rune('0'+argCount)) + " OR description ILIKE $" + string(rune('0'+argCount)) + ")"
-		countQuery += " AND (name ILIKE $" + string(rune('0'+argCount)) + " OR description ILIKE $" + string(rune('0'+argCount)) + ")"
-		args = append(args, searchArg)
+		search := q.arg(containing(filter.Search))
+		q.where(`(name ILIKE ` + search + ` ESCAPE '\' OR description ILIKE ` + search + ` ESCAPE '\')`)
 	}
 
-	// Get total count
+	// Get total count before pagination arguments are added
 	var total int
-	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&total)
-	if err != nil {
+	countQuery := `SELECT COUNT(*) FROM agent_templates` + q.whereClause()
+	if err := r.db.QueryRow(ctx, countQuery, q.args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
+	query := `SELECT ` + templateColumns + ` FROM agent_templates` + q.whereClause()
+
 	// Sort
 	switch filter.SortBy {
 	case "popular":
-		baseQuery += " ORDER BY download_count DESC"
+		query += " ORDER BY download_count DESC, id"
 	case "rating":
-		baseQuery += " ORDER BY rating_average DESC, rating_count DESC"
+		query += " ORDER BY rating_average DESC, rating_count DESC, id"
 	case "newest":
-		baseQuery += " ORDER BY created_at DESC"
+		query += " ORDER BY created_at DESC, id"
+	case "price_asc":
+		query += " ORDER BY price_cents ASC, download_count DESC, id"
+	case "price_desc":
+		query += " ORDER BY price_cents DESC, download_count DESC, id"
 	default:
-		baseQuery += " ORDER BY is_featured DESC, download_count DESC"
+		query += " ORDER BY is_featured DESC, download_count DESC, id"
 	}
 
 	// Pagination
 	if filter.Limit > 0 {
-		argCount++
-		baseQuery += " LIMIT $" + string(rune('0'+argCount))
-		args = append(args, filter.Limit)
+		query += " LIMIT " + q.arg(filter.Limit)
 	}
 	if filter.Offset > 0 {
-		argCount++
-		baseQuery += " OFFSET $" + string(rune('0'+argCount))
-		args = append(args, filter.Offset)
+		query += " OFFSET " + q.arg(filter.Offset)
 	}
 
-	rows, err := r.db.Query(ctx, baseQuery, args...)
+	rows, err := r.db.Query(ctx, query, q.args...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -119,116 +171,175 @@ func (r *MarketplaceRepository) ListTemplates(ctx context.Context, filter Market
 
 	templates := []domain.AgentTemplate{}
 	for rows.Next() {
-		var t domain.AgentTemplate
-		var skillTags []byte
-		var avatarURL, authorName, category, description, version, status *string
-		err := rows.Scan(
-			&t.ID, &t.Name, &t.Role, &t.SystemPrompt, &avatarURL, &skillTags,
-			&t.AuthorID, &authorName, &category, &description,
-			&t.IsFeatured, &t.IsPublic, &t.IsPremium, &t.PriceCents,
-			&t.DownloadCount, &t.RatingAverage, &t.RatingCount, &version,
-			&status, &t.CreatedAt, &t.UpdatedAt,
-		)
+		t, err := scanTemplate(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-
-		// Handle nullable fields
-		if avatarURL != nil {
-			t.AvatarURL = *avatarURL
-		}
-		if authorName != nil {
-			t.AuthorName = *authorName
-		} else {
-			t.AuthorName = "Synoffice Team"
-		}
-		if category != nil {
-			t.Category = *category
-		} else {
-			t.Category = "general"
-		}
-		if description != nil {
-			t.Description = *description
-		}
-		if version != nil {
-			t.Version = *version
-		} else {
-			t.Version = "1.0.0"
-		}
-		if status != nil {
-			t.Status = *status
-		} else {
-			t.Status = "approved"
-		}
-
-		t.SkillTags = parseSkillTags(skillTags)
-		templates = append(templates, t)
+		templates = append(templates, *t)
 	}
 
-	return templates, total, nil
+	return templates, total, rows.Err()
 }
 
 // GetTemplateByID returns a single template by ID
 func (r *MarketplaceRepository) GetTemplateByID(ctx context.Context, id uuid.UUID) (*domain.AgentTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM agent_templates WHERE id = $1`
+
+	t, err := scanTemplate(r.db.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CreateTemplate inserts an author-submitted template, under the team
+// license unless it has a license type
+func (r *MarketplaceRepository) CreateTemplate(ctx context.Context, t *domain.AgentTemplate) error {
+	skillTags, err := json.Marshal(t.SkillTags)
+	if err != nil {
+		return err
+	}
+	riskFindings, err := marshalRiskFindings(t.RiskFindings)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		SELECT id, name, role, system_prompt, avatar_url, skill_tags,
-		       author_id, COALESCE(author_name, 'Synoffice Team') as author_name, 
-		       COALESCE(category, 'general') as category, COALESCE(description, '') as description,
-		       COALESCE(is_featured, false) as is_featured, COALESCE(is_public, true) as is_public,
-		       COALESCE(is_premium, false) as is_premium, COALESCE(price_cents, 0) as price_cents,
-		       COALESCE(download_count, 0) as download_count, COALESCE(rating_average, 0) as rating_average,
-		       COALESCE(rating_count, 0) as rating_count, COALESCE(version, '1.0.0') as version,
-		       COALESCE(status, 'approved') as status, created_at, COALESCE(updated_at, created_at) as updated_at
-		FROM agent_templates WHERE id = $1
+		INSERT INTO agent_templates (
+			id, name, role, system_prompt, avatar_url, skill_tags,
+			author_id, author_name, category, description,
+			is_featured, is_public, is_premium, price_cents, version, status, created_at, updated_at,
+			risk_score, risk_findings, license_type, license_resale_prohibited, license_terms
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+			COALESCE(NULLIF($21::text, ''), 'team'), $22, $23)
 	`
+	_, err = r.db.Exec(ctx, query,
+		t.ID, t.Name, t.Role, t.SystemPrompt, nullableString(t.AvatarURL), skillTags,
+		t.AuthorID, t.AuthorName, t.Category, t.Description,
+		t.IsFeatured, t.IsPublic, t.IsPremium, t.PriceCents, t.Version, t.Status, t.CreatedAt, t.UpdatedAt,
+		t.RiskScore, riskFindings, t.License.Type, t.License.ResaleProhibited, t.License.Terms,
+	)
+	return err
+}
 
-	var t domain.AgentTemplate
-	var skillTags []byte
-	var avatarURL, authorName, category, description, version, status *string
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&t.ID, &t.Name, &t.Role, &t.SystemPrompt, &avatarURL, &skillTags,
-		&t.AuthorID, &authorName, &category, &description,
-		&t.IsFeatured, &t.IsPublic, &t.IsPremium, &t.PriceCents,
-		&t.DownloadCount, &t.RatingAverage, &t.RatingCount, &version,
-		&status, &t.CreatedAt, &t.UpdatedAt,
+// UpdateTemplate updates the author-editable fields, version and status of a template
+func (r *MarketplaceRepository) UpdateTemplate(ctx context.Context, t *domain.AgentTemplate) error {
+	skillTags, err := json.Marshal(t.SkillTags)
+	if err != nil {
+		return err
+	}
+	riskFindings, err := marshalRiskFindings(t.RiskFindings)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE agent_templates SET
+			name = $2, role = $3, system_prompt = $4, avatar_url = $5, skill_tags = $6,
+			category = $7, description = $8, is_premium = $9, price_cents = $10,
+			status = $11, version = $12, rejection_reason = NULL, updated_at = $13,
+			risk_score = $14, risk_findings = $15,
+			license_type = $16, license_resale_prohibited = $17, license_terms = $18
+		WHERE id = $1
+	`
+	result, err := r.db.Exec(ctx, query,
+		t.ID, t.Name, t.Role, t.SystemPrompt, nullableString(t.AvatarURL), skillTags,
+		t.Category, t.Description, t.IsPremium, t.PriceCents,
+		t.Status, t.Version, t.UpdatedAt,
+		t.RiskScore, riskFindings, t.License.Type, t.License.ResaleProhibited, t.License.Terms,
 	)
-	if err == pgx.ErrNoRows {
-		return nil, domain.ErrNotFound
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
 	}
+	return nil
+}
+
+// GetTemplatesByAuthor returns all templates submitted by an author regardless of status
+func (r *MarketplaceRepository) GetTemplatesByAuthor(ctx context.Context, authorID uuid.UUID) ([]domain.AgentTemplate, error) {
+	query := `SELECT ` + templateColumns + ` FROM agent_templates WHERE author_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, authorID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Handle nullable fields
-	if avatarURL != nil {
-		t.AvatarURL = *avatarURL
+	templates := []domain.AgentTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
 	}
-	if authorName != nil {
-		t.AuthorName = *authorName
-	} else {
-		t.AuthorName = "Synoffice Team"
+	return templates, rows.Err()
+}
+
+// pendingTemplates matches templates awaiting moderation themselves or with a
+// new version awaiting it
+const pendingTemplates = `status = 'pending' OR EXISTS (
+	SELECT 1 FROM template_versions v WHERE v.template_id = agent_templates.id AND v.status = 'pending'
+)`
+
+// GetPendingTemplates returns templates awaiting moderation, or with a version awaiting it, oldest first
+func (r *MarketplaceRepository) GetPendingTemplates(ctx context.Context, limit, offset int) ([]domain.AgentTemplate, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM agent_templates WHERE `+pendingTemplates).Scan(&total); err != nil {
+		return nil, 0, err
 	}
-	if category != nil {
-		t.Category = *category
-	} else {
-		t.Category = "general"
+
+	query := `SELECT ` + templateColumns + `
+		FROM agent_templates
+		WHERE ` + pendingTemplates + `
+		ORDER BY COALESCE(updated_at, created_at) ASC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
-	if description != nil {
-		t.Description = *description
+	defer rows.Close()
+
+	templates := []domain.AgentTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		templates = append(templates, *t)
 	}
-	if version != nil {
-		t.Version = *version
-	} else {
-		t.Version = "1.0.0"
+	return templates, total, rows.Err()
+}
+
+// SetTemplateStatus records a moderation decision on a pending template.
+// Returns domain.ErrNotFound if the template does not exist or is no longer pending.
+func (r *MarketplaceRepository) SetTemplateStatus(ctx context.Context, id uuid.UUID, status string, rejectionReason string, reviewerID uuid.UUID) error {
+	query := `
+		UPDATE agent_templates SET
+			status = $2, rejection_reason = $3, reviewed_by = $4, reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+	result, err := r.db.Exec(ctx, query, id, status, nullableString(rejectionReason), reviewerID)
+	if err != nil {
+		return err
 	}
-	if status != nil {
-		t.Status = *status
-	} else {
-		t.Status = "approved"
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
 	}
+	return nil
+}
 
-	t.SkillTags = parseSkillTags(skillTags)
-	return &t, nil
+// CategoryExists reports whether a category slug is defined
+func (r *MarketplaceRepository) CategoryExists(ctx context.Context, slug string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM agent_categories WHERE slug = $1)`, slug).Scan(&exists)
+	return exists, err
 }
 
 // GetCategories returns all categories
@@ -260,18 +371,112 @@ func (r *MarketplaceRepository) IncrementDownload(ctx context.Context, templateI
 	return err
 }
 
-// CreateReview creates a new review
+// IsResaleProhibitedCopy reports whether another author's template with the
+// system prompt prohibits resale. Prompts are compared ignoring case and
+// runs of whitespace, so it is a best-effort check: a reworded copy passes.
+func (r *MarketplaceRepository) IsResaleProhibitedCopy(ctx context.Context, systemPrompt string, authorID uuid.UUID) (bool, error) {
+	// The expression matches idx_agent_templates_resale_prohibited
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM agent_templates
+			WHERE license_resale_prohibited
+			  AND md5(btrim(lower(regexp_replace(system_prompt, '\s+', ' ', 'g'))))
+			    = md5(btrim(lower(regexp_replace($1, '\s+', ' ', 'g'))))
+			  AND author_id IS DISTINCT FROM $2
+		)
+	`
+	var copied bool
+	err := r.db.QueryRow(ctx, query, systemPrompt, authorID).Scan(&copied)
+	return copied, err
+}
+
+// CreateReview creates a new review, returning domain.ErrAlreadyExists if the user
+// already reviewed the template. The template's rating aggregate is maintained by
+// the update_template_rating trigger in the same transaction.
 func (r *MarketplaceRepository) CreateReview(ctx context.Context, review *domain.AgentReview) error {
 	query := `INSERT INTO agent_reviews (template_id, user_id, rating, title, review_text)
-	          VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
-	return r.db.QueryRow(ctx, query, review.TemplateID, review.UserID, review.Rating, review.Title, review.ReviewText).
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (template_id, user_id) DO NOTHING
+	          RETURNING id, created_at, updated_at`
+	err := r.db.QueryRow(ctx, query, review.TemplateID, review.UserID, review.Rating, review.Title, review.ReviewText).
 		Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// UpdateReview edits the rating and text of a review
+func (r *MarketplaceRepository) UpdateReview(ctx context.Context, review *domain.AgentReview) error {
+	query := `UPDATE agent_reviews SET rating = $2, title = $3, review_text = $4
+	          WHERE id = $1 RETURNING updated_at`
+	err := r.db.QueryRow(ctx, query, review.ID, review.Rating, review.Title, review.ReviewText).Scan(&review.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
 }
 
-// GetReviews returns reviews for a template
+// DeleteReview removes a review along with its votes and reply
+func (r *MarketplaceRepository) DeleteReview(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM agent_reviews WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// reviewColumns is the select list read by scanReview. It expects agent_reviews
+// aliased as r and review_replies LEFT JOINed as rr.
+const reviewColumns = `r.id, r.template_id, r.user_id, r.rating, COALESCE(r.title, '') as title, r.review_text,
+		       (SELECT COUNT(*) FROM review_votes v WHERE v.review_id = r.id AND v.is_helpful) as helpful_count,
+		       (SELECT COUNT(*) FROM review_votes v WHERE v.review_id = r.id AND NOT v.is_helpful) as unhelpful_count,
+		       EXISTS(
+		           SELECT 1 FROM template_purchases p
+		           JOIN offices o ON o.id = p.office_id
+		           WHERE p.template_id = r.template_id AND p.status = 'active'
+		             AND (p.purchased_by = r.user_id OR o.user_id = r.user_id)
+		       ) as is_verified_purchase,
+		       rr.id, rr.author_id, rr.reply_text, rr.created_at, rr.updated_at,
+		       r.created_at, r.updated_at`
+
+// scanReview scans a row selected with reviewColumns
+func scanReview(row pgx.Row) (*domain.AgentReview, error) {
+	var rev domain.AgentReview
+	var replyID, replyAuthorID *uuid.UUID
+	var replyText *string
+	var replyCreatedAt, replyUpdatedAt *time.Time
+	err := row.Scan(
+		&rev.ID, &rev.TemplateID, &rev.UserID, &rev.Rating, &rev.Title, &rev.ReviewText,
+		&rev.HelpfulCount, &rev.UnhelpfulCount, &rev.IsVerifiedPurchase,
+		&replyID, &replyAuthorID, &replyText, &replyCreatedAt, &replyUpdatedAt,
+		&rev.CreatedAt, &rev.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if replyID != nil {
+		rev.Reply = &domain.ReviewReply{
+			ID:        *replyID,
+			ReviewID:  rev.ID,
+			AuthorID:  *replyAuthorID,
+			ReplyText: *replyText,
+			CreatedAt: *replyCreatedAt,
+			UpdatedAt: *replyUpdatedAt,
+		}
+	}
+	return &rev, nil
+}
+
+// GetReviews returns reviews for a template with votes, verified-purchase flags and author replies
 func (r *MarketplaceRepository) GetReviews(ctx context.Context, templateID uuid.UUID, limit, offset int) ([]domain.AgentReview, error) {
-	query := `SELECT id, template_id, user_id, rating, COALESCE(title, '') as title, review_text, created_at, updated_at
-	          FROM agent_reviews WHERE template_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	query := `SELECT ` + reviewColumns + `
+	          FROM agent_reviews r
+	          LEFT JOIN review_replies rr ON rr.review_id = r.id
+	          WHERE r.template_id = $1 ORDER BY r.created_at DESC LIMIT $2 OFFSET $3`
 
 	rows, err := r.db.Query(ctx, query, templateID, limit, offset)
 	if err != nil {
@@ -281,23 +486,86 @@ func (r *MarketplaceRepository) GetReviews(ctx context.Context, templateID uuid.
 
 	reviews := []domain.AgentReview{}
 	for rows.Next() {
-		var rev domain.AgentReview
-		err := rows.Scan(&rev.ID, &rev.TemplateID, &rev.UserID, &rev.Rating, &rev.Title, &rev.ReviewText, &rev.CreatedAt, &rev.UpdatedAt)
+		rev, err := scanReview(rows)
 		if err != nil {
 			return nil, err
 		}
-		reviews = append(reviews, rev)
+		reviews = append(reviews, *rev)
 	}
-	return reviews, nil
+	return reviews, rows.Err()
 }
 
-// MarketplaceFilter defines filtering options for marketplace queries
-type MarketplaceFilter struct {
-	Category   string
-	Search     string
-	IsFeatured *bool
-	IsPremium  *bool
-	SortBy     string // "popular", "rating", "newest"
-	Limit      int
-	Offset     int
+// CountReviews returns the number of reviews for a template
+func (r *MarketplaceRepository) CountReviews(ctx context.Context, templateID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM agent_reviews WHERE template_id = $1`, templateID).Scan(&count)
+	return count, err
+}
+
+// GetReviewByID returns a single review
+func (r *MarketplaceRepository) GetReviewByID(ctx context.Context, id uuid.UUID) (*domain.AgentReview, error) {
+	query := `SELECT ` + reviewColumns + `
+	          FROM agent_reviews r
+	          LEFT JOIN review_replies rr ON rr.review_id = r.id
+	          WHERE r.id = $1`
+
+	rev, err := scanReview(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// VoteReview records or changes a user's helpful/unhelpful vote on a review
+func (r *MarketplaceRepository) VoteReview(ctx context.Context, reviewID, userID uuid.UUID, helpful bool) error {
+	query := `
+		INSERT INTO review_votes (review_id, user_id, is_helpful)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (review_id, user_id) DO UPDATE SET is_helpful = EXCLUDED.is_helpful, updated_at = NOW()
+	`
+	_, err := r.db.Exec(ctx, query, reviewID, userID, helpful)
+	return err
+}
+
+// DeleteReviewVote removes a user's vote on a review
+func (r *MarketplaceRepository) DeleteReviewVote(ctx context.Context, reviewID, userID uuid.UUID) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2`, reviewID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// CreateReviewReply stores the author's reply, returning domain.ErrAlreadyExists if the review already has one
+func (r *MarketplaceRepository) CreateReviewReply(ctx context.Context, reply *domain.ReviewReply) error {
+	query := `
+		INSERT INTO review_replies (id, review_id, author_id, reply_text, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (review_id) DO NOTHING
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		reply.ID, reply.ReviewID, reply.AuthorID, reply.ReplyText, reply.CreatedAt, reply.UpdatedAt,
+	).Scan(&reply.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// UpdateReviewReply edits the text of an existing reply
+func (r *MarketplaceRepository) UpdateReviewReply(ctx context.Context, reply *domain.ReviewReply) error {
+	query := `UPDATE review_replies SET reply_text = $2, updated_at = $3 WHERE review_id = $1 RETURNING id, author_id, created_at`
+	err := r.db.QueryRow(ctx, query, reply.ReviewID, reply.ReplyText, reply.UpdatedAt).
+		Scan(&reply.ID, &reply.AuthorID, &reply.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
 }
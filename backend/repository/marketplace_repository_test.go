@@ -0,0 +1,365 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestMarketplaceCreateTemplate(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	author := testDB.User(t)
+	now := time.Now()
+
+	template := &domain.AgentTemplate{
+		ID:           uuid.New(),
+		Name:         "Reviewer",
+		Role:         "Code Reviewer",
+		SystemPrompt: "You review code.",
+		SkillTags:    []string{"review", "go"},
+		AuthorID:     &author,
+		AuthorName:   "Test Author",
+		Category:     "general",
+		Description:  "Reviews pull requests",
+		IsPublic:     true,
+		IsPremium:    true,
+		PriceCents:   499,
+		Version:      "1.0.0",
+		Status:       "pending",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := repo.CreateTemplate(ctx, template); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+
+	got, err := repo.GetTemplateByID(ctx, template.ID)
+	if err != nil {
+		t.Fatalf("GetTemplateByID: %v", err)
+	}
+	if got.Name != template.Name || got.Status != "pending" || got.PriceCents != 499 || !got.IsPremium {
+		t.Errorf("GetTemplateByID = %+v, want the created template", got)
+	}
+	if len(got.SkillTags) != 2 || got.SkillTags[0] != "review" || got.SkillTags[1] != "go" {
+		t.Errorf("skill tags = %v, want [review go]", got.SkillTags)
+	}
+	if got.AvatarURL != "" {
+		t.Errorf("avatar url = %q, want empty", got.AvatarURL)
+	}
+
+	// Pending templates are not listed in the marketplace
+	listed, _, err := repo.ListTemplates(ctx, domain.MarketplaceFilter{AuthorID: &author, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListTemplates: %v", err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("ListTemplates returned %d pending templates, want 0", len(listed))
+	}
+}
+
+func TestMarketplaceListTemplatesSearchesWildcardsLiterally(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	author := testDB.User(t)
+	now := time.Now()
+	for _, name := range []string{"100% uptime", "1000 uptime", "on_call", "on-call", `back\slash`} {
+		if err := repo.CreateTemplate(ctx, &domain.AgentTemplate{
+			ID: uuid.New(), Name: name, Role: "Operator", SystemPrompt: "You keep services up.", AuthorID: &author,
+			Category: "general", IsPublic: true, Version: "1.0.0", Status: "approved", CreatedAt: now, UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("CreateTemplate: %v", err)
+		}
+	}
+
+	for search, want := range map[string]string{"100%": "100% uptime", "on_": "on_call", `k\s`: `back\slash`} {
+		listed, total, err := repo.ListTemplates(ctx, domain.MarketplaceFilter{AuthorID: &author, Search: search, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListTemplates: %v", err)
+		}
+		if total != 1 || len(listed) != 1 || listed[0].Name != want {
+			t.Errorf("ListTemplates searching %q = %d templates of %d, want only %q", search, len(listed), total, want)
+		}
+	}
+}
+
+func TestMarketplaceListTemplatesPagesTiesInAStableOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	author := testDB.User(t)
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := repo.CreateTemplate(ctx, &domain.AgentTemplate{
+			ID: uuid.New(), Name: "Helper", Role: "Assistant", SystemPrompt: "You help.", AuthorID: &author,
+			Category: "general", IsPublic: true, Version: "1.0.0", Status: "approved", CreatedAt: now, UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("CreateTemplate: %v", err)
+		}
+	}
+
+	for _, sortBy := range []string{"", "popular", "rating", "newest", "price_asc", "price_desc"} {
+		seen := map[uuid.UUID]bool{}
+		for offset := 0; offset < 4; offset++ {
+			page, _, err := repo.ListTemplates(ctx, domain.MarketplaceFilter{AuthorID: &author, SortBy: sortBy, Limit: 1, Offset: offset})
+			if err != nil {
+				t.Fatalf("ListTemplates: %v", err)
+			}
+			for _, template := range page {
+				seen[template.ID] = true
+			}
+		}
+		if len(seen) != 4 {
+			t.Errorf("pages sorted by %q listed %d of the 4 templates", sortBy, len(seen))
+		}
+	}
+}
+
+func TestMarketplaceTemplateNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+
+	if _, err := repo.GetTemplateByID(ctx, uuid.New()); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetTemplateByID error = %v, want ErrNotFound", err)
+	}
+	err := repo.UpdateTemplate(ctx, &domain.AgentTemplate{ID: uuid.New(), Status: "pending", Version: "1.0.0"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("UpdateTemplate error = %v, want ErrNotFound", err)
+	}
+	err = repo.SetTemplateStatus(ctx, uuid.New(), "approved", "", testDB.User(t))
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("SetTemplateStatus error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMarketplaceSetTemplateStatusOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	author := testDB.User(t)
+	reviewer := testDB.User(t)
+	templateID := testDB.Template(t, author)
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET status = 'pending' WHERE id = $1`, templateID); err != nil {
+		t.Fatalf("mark template pending: %v", err)
+	}
+
+	if err := repo.SetTemplateStatus(ctx, templateID, "rejected", "Too vague", reviewer); err != nil {
+		t.Fatalf("SetTemplateStatus: %v", err)
+	}
+	if err := repo.SetTemplateStatus(ctx, templateID, "approved", "", reviewer); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second SetTemplateStatus: error = %v, want ErrNotFound", err)
+	}
+
+	got, err := repo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		t.Fatalf("GetTemplateByID: %v", err)
+	}
+	if got.Status != "rejected" || got.RejectionReason != "Too vague" || got.ReviewedAt == nil {
+		t.Errorf("template status %s, reason %q, reviewed at %v; want rejected with the reason",
+			got.Status, got.RejectionReason, got.ReviewedAt)
+	}
+}
+
+func TestTemplateVersionStagedUntilReviewed(t *testing.T) {
+	ctx := context.Background()
+	marketplace := repository.NewMarketplaceRepository(testDB.Pool)
+	versions := repository.NewTemplateVersionRepository(testDB.Pool)
+	reviewer := testDB.User(t)
+	templateID := testDB.Template(t, testDB.User(t))
+
+	staged := func(version, prompt string) *domain.TemplateVersion {
+		return &domain.TemplateVersion{
+			ID: uuid.New(), TemplateID: templateID, Version: version, SystemPrompt: prompt,
+			SkillTags: []string{}, CreatedAt: time.Now(), Status: "pending", RiskScore: 40,
+			RiskFindings: []domain.TemplateFinding{{Rule: "html_comment", Category: "hidden_instructions"}},
+		}
+	}
+	first := staged("2.0.0", "You test things twice.")
+	if err := versions.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := versions.Create(ctx, staged("2.1.0", "You test things thrice.")); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create of a second pending version error = %v, want ErrAlreadyExists", err)
+	}
+
+	// The staged version is only shown to moderation
+	got, err := versions.GetPending(ctx, templateID)
+	if err != nil || got.ID != first.ID || got.RiskScore != 40 || len(got.RiskFindings) != 1 {
+		t.Errorf("GetPending = %+v, %v; want the staged version with its findings", got, err)
+	}
+	if listed, err := versions.GetByTemplateID(ctx, templateID); err != nil || len(listed) != 0 {
+		t.Errorf("GetByTemplateID = %d versions, %v; want none approved", len(listed), err)
+	}
+	if _, err := versions.GetByVersion(ctx, templateID, "2.0.0"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByVersion of the staged version error = %v, want ErrNotFound", err)
+	}
+	pending, _, err := marketplace.GetPendingTemplates(ctx, 100, 0)
+	if err != nil {
+		t.Fatalf("GetPendingTemplates: %v", err)
+	}
+	queued := false
+	for _, template := range pending {
+		queued = queued || template.ID == templateID
+	}
+	if !queued {
+		t.Error("GetPendingTemplates leaves out the approved template with a staged version")
+	}
+
+	if err := versions.SetStatus(ctx, first.ID, "rejected", "Hidden instructions", reviewer); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	if err := versions.SetStatus(ctx, first.ID, "approved", "", reviewer); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second SetStatus error = %v, want ErrNotFound", err)
+	}
+
+	// A rejected version can be published again under its number
+	retry := staged("2.0.0", "You test things twice, openly.")
+	if err := versions.Create(ctx, retry); err != nil {
+		t.Fatalf("Create of a rejected version again: %v", err)
+	}
+	got, err = versions.GetPending(ctx, templateID)
+	if err != nil || got.SystemPrompt != retry.SystemPrompt || got.RejectionReason != "" {
+		t.Errorf("GetPending = %+v, %v; want the new submission", got, err)
+	}
+	if err := versions.SetStatus(ctx, retry.ID, "approved", "", reviewer); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	if v, err := versions.GetByVersion(ctx, templateID, "2.0.0"); err != nil || v.Status != "approved" || v.ReviewedAt == nil {
+		t.Errorf("GetByVersion = %+v, %v; want the approved version", v, err)
+	}
+}
+
+func TestMarketplaceIncrementDownloadConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	templateID := testDB.Template(t, testDB.User(t))
+
+	const downloads = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, downloads)
+	for i := 0; i < downloads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.IncrementDownload(ctx, templateID)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("IncrementDownload: %v", err)
+		}
+	}
+
+	got, err := repo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		t.Fatalf("GetTemplateByID: %v", err)
+	}
+	if got.DownloadCount != downloads {
+		t.Errorf("download count = %d, want %d", got.DownloadCount, downloads)
+	}
+}
+
+func TestMarketplaceReviewsMaintainRating(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	templateID := testDB.Template(t, testDB.User(t))
+
+	var reviews []*domain.AgentReview
+	for _, rating := range []int{5, 4, 3} {
+		review := &domain.AgentReview{
+			TemplateID: templateID,
+			UserID:     testDB.User(t),
+			Rating:     rating,
+			ReviewText: "Works as described",
+		}
+		if err := repo.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		reviews = append(reviews, review)
+	}
+	assertRating(t, repo, templateID, 4, 3)
+
+	duplicate := &domain.AgentReview{TemplateID: templateID, UserID: reviews[0].UserID, Rating: 1, ReviewText: "Again"}
+	if err := repo.CreateReview(ctx, duplicate); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("second review by the same user: error = %v, want ErrAlreadyExists", err)
+	}
+	assertRating(t, repo, templateID, 4, 3)
+
+	reviews[2].Rating = 1
+	if err := repo.UpdateReview(ctx, reviews[2]); err != nil {
+		t.Fatalf("UpdateReview: %v", err)
+	}
+	assertRating(t, repo, templateID, 3.33, 3)
+
+	if err := repo.DeleteReview(ctx, reviews[0].ID); err != nil {
+		t.Fatalf("DeleteReview: %v", err)
+	}
+	assertRating(t, repo, templateID, 2.5, 2)
+
+	if err := repo.DeleteReview(ctx, reviews[0].ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second DeleteReview: error = %v, want ErrNotFound", err)
+	}
+	count, err := repo.CountReviews(ctx, templateID)
+	if err != nil {
+		t.Fatalf("CountReviews: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("CountReviews = %d, want 2", count)
+	}
+}
+
+func TestMarketplaceReviewVotes(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMarketplaceRepository(testDB.Pool)
+	templateID := testDB.Template(t, testDB.User(t))
+	review := &domain.AgentReview{TemplateID: templateID, UserID: testDB.User(t), Rating: 4, ReviewText: "Good"}
+	if err := repo.CreateReview(ctx, review); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+
+	voter, other := testDB.User(t), testDB.User(t)
+	// Voting again replaces the voter's earlier vote
+	for _, vote := range []struct {
+		user    uuid.UUID
+		helpful bool
+	}{{voter, true}, {voter, false}, {other, true}} {
+		if err := repo.VoteReview(ctx, review.ID, vote.user, vote.helpful); err != nil {
+			t.Fatalf("VoteReview: %v", err)
+		}
+	}
+
+	got, err := repo.GetReviewByID(ctx, review.ID)
+	if err != nil {
+		t.Fatalf("GetReviewByID: %v", err)
+	}
+	if got.HelpfulCount != 1 || got.UnhelpfulCount != 1 {
+		t.Errorf("votes = %d helpful, %d unhelpful; want 1 and 1", got.HelpfulCount, got.UnhelpfulCount)
+	}
+
+	if err := repo.DeleteReviewVote(ctx, review.ID, voter); err != nil {
+		t.Fatalf("DeleteReviewVote: %v", err)
+	}
+	if err := repo.DeleteReviewVote(ctx, review.ID, voter); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second DeleteReviewVote: error = %v, want ErrNotFound", err)
+	}
+}
+
+func assertRating(t *testing.T, repo *repository.MarketplaceRepository, templateID uuid.UUID, average float64, count int) {
+	t.Helper()
+	got, err := repo.GetTemplateByID(context.Background(), templateID)
+	if err != nil {
+		t.Fatalf("GetTemplateByID: %v", err)
+	}
+	if math.Abs(got.RatingAverage-average) > 0.005 || got.RatingCount != count {
+		t.Errorf("rating = %.2f over %d reviews, want %.2f over %d", got.RatingAverage, got.RatingCount, average, count)
+	}
+}
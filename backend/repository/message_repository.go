@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -13,14 +14,17 @@ import (
 
 // MessageRepository implements domain.MessageRepository
 type MessageRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewMessageRepository creates a new MessageRepository
 func NewMessageRepository(db *pgxpool.Pool) *MessageRepository {
-	return &MessageRepository{db: db}
+	return &MessageRepository{db: conn{db}}
 }
 
+const messageColumns = `id, office_id, conversation_id, parent_message_id, sender_type, sender_id, content, metadata,
+	created_at, edited_at, deleted_at, pinned_at`
+
 // Create creates a new message
 func (r *MessageRepository) Create(ctx context.Context, message *domain.Message) error {
 	metadataJSON, err := json.Marshal(message.Metadata)
@@ -29,11 +33,11 @@ func (r *MessageRepository) Create(ctx context.Context, message *domain.Message)
 	}
 
 	query := `
-		INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content, metadata, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO messages (id, office_id, conversation_id, parent_message_id, sender_type, sender_id, content, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	_, err = r.db.Exec(ctx, query,
-		message.ID, message.OfficeID, message.ConversationID,
+		message.ID, message.OfficeID, message.ConversationID, message.ParentMessageID,
 		message.SenderType, message.SenderID, message.Content,
 		metadataJSON, message.CreatedAt,
 	)
@@ -42,66 +46,179 @@ func (r *MessageRepository) Create(ctx context.Context, message *domain.Message)
 
 // GetByID returns a message by ID
 func (r *MessageRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Message, error) {
-	query := `SELECT id, office_id, conversation_id, sender_type, sender_id, content, metadata, created_at FROM messages WHERE id = $1`
-
-	var message domain.Message
-	var metadataJSON []byte
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
 
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&message.ID, &message.OfficeID, &message.ConversationID,
-		&message.SenderType, &message.SenderID, &message.Content,
-		&metadataJSON, &message.CreatedAt,
-	)
+	message, err := scanMessage(r.db.QueryRow(ctx, query, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	return message, nil
+}
 
-	if err := json.Unmarshal(metadataJSON, &message.Metadata); err != nil {
-		message.Metadata = make(map[string]any)
+// GetByConversationID returns messages for a conversation, oldest first. A
+// cursor continues after the last message of the previous page.
+func (r *MessageRepository) GetByConversationID(ctx context.Context, conversationID uuid.UUID, page domain.PageRequest) ([]*domain.Message, error) {
+	q := &queryBuilder{}
+	q.where("conversation_id = " + q.arg(conversationID))
+	if page.Cursor != nil {
+		q.where("(created_at, id) > (" + q.arg(page.Cursor.CreatedAt) + ", " + q.arg(page.Cursor.ID) + ")")
 	}
 
-	return &message, nil
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages` + q.whereClause() + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT ` + q.arg(page.Limit)
+	if page.Cursor == nil && page.Offset > 0 {
+		query += ` OFFSET ` + q.arg(page.Offset)
+	}
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows)
 }
 
-// GetByConversationID returns messages for a conversation with pagination
-func (r *MessageRepository) GetByConversationID(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*domain.Message, error) {
+// GetReplies returns the replies to a message, oldest first
+func (r *MessageRepository) GetReplies(ctx context.Context, parentID uuid.UUID) ([]*domain.Message, error) {
 	query := `
-		SELECT id, office_id, conversation_id, sender_type, sender_id, content, metadata, created_at 
-		FROM messages 
-		WHERE conversation_id = $1 
-		ORDER BY created_at ASC
-		LIMIT $2 OFFSET $3
+		SELECT ` + messageColumns + `
+		FROM messages WHERE parent_message_id = $1
+		ORDER BY created_at ASC, id ASC
 	`
 
-	rows, err := r.db.Query(ctx, query, conversationID, limit, offset)
+	rows, err := r.db.Query(ctx, query, parentID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanMessages(rows)
+}
 
-	var messages []*domain.Message
-	for rows.Next() {
-		var message domain.Message
-		var metadataJSON []byte
-
-		if err := rows.Scan(
-			&message.ID, &message.OfficeID, &message.ConversationID,
-			&message.SenderType, &message.SenderID, &message.Content,
-			&metadataJSON, &message.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
+// CountByConversationID returns the number of messages in a conversation
+func (r *MessageRepository) CountByConversationID(ctx context.Context, conversationID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM messages WHERE conversation_id = $1`, conversationID).Scan(&count)
+	return count, err
+}
 
-		if err := json.Unmarshal(metadataJSON, &message.Metadata); err != nil {
-			message.Metadata = make(map[string]any)
-		}
+// GetLatestByConversationID returns the newest message of a conversation
+func (r *MessageRepository) GetLatestByConversationID(ctx context.Context, conversationID uuid.UUID) (*domain.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages WHERE conversation_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
 
-		messages = append(messages, &message)
+	message, err := scanMessage(r.db.QueryRow(ctx, query, conversationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
 	}
-	return messages, rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// GetRecentByConversationID returns up to limit of the newest messages in a
+// conversation created before the cursor, or overall when it is nil, oldest
+// first. Thread replies and deleted messages are left out.
+func (r *MessageRepository) GetRecentByConversationID(ctx context.Context, conversationID uuid.UUID, before *domain.PageCursor, limit int) ([]*domain.Message, error) {
+	q := &queryBuilder{}
+	q.where("conversation_id = " + q.arg(conversationID))
+	q.where("parent_message_id IS NULL")
+	q.where("deleted_at IS NULL")
+	if before != nil {
+		q.where("(created_at, id) < (" + q.arg(before.CreatedAt) + ", " + q.arg(before.ID) + ")")
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(limit)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// GetNextByConversationID returns up to limit of the oldest messages in a
+// conversation created after the cursor, or overall when it is nil, oldest
+// first. Thread replies and deleted messages are left out.
+func (r *MessageRepository) GetNextByConversationID(ctx context.Context, conversationID uuid.UUID, after *domain.PageCursor, limit int) ([]*domain.Message, error) {
+	q := &queryBuilder{}
+	q.where("conversation_id = " + q.arg(conversationID))
+	q.where("parent_message_id IS NULL")
+	q.where("deleted_at IS NULL")
+	if after != nil {
+		q.where("(created_at, id) > (" + q.arg(after.CreatedAt) + ", " + q.arg(after.ID) + ")")
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages` + q.whereClause() + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT ` + q.arg(limit)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows)
+}
+
+// Update saves an edited message
+func (r *MessageRepository) Update(ctx context.Context, message *domain.Message) error {
+	metadataJSON, err := json.Marshal(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE messages SET content = $2, metadata = $3, edited_at = $4 WHERE id = $1`
+	_, err = r.db.Exec(ctx, query, message.ID, message.Content, metadataJSON, message.EditedAt)
+	return err
+}
+
+// SoftDelete marks a message deleted, erasing its content, edit history and
+// reactions
+func (r *MessageRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE messages SET content = '', metadata = '{}', deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM message_reactions WHERE message_id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 // Delete deletes a message
@@ -110,3 +227,88 @@ func (r *MessageRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.Exec(ctx, query, id)
 	return err
 }
+
+// AddReaction records a user's emoji reaction; reacting twice with the same
+// emoji is a no-op
+func (r *MessageRepository) AddReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) error {
+	query := `
+		INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+	`
+	_, err := r.db.Exec(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+// RemoveReaction removes a user's emoji reaction
+func (r *MessageRepository) RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) error {
+	query := `DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3`
+	_, err := r.db.Exec(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+// GetReactions groups the reactions of each message by emoji
+func (r *MessageRepository) GetReactions(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]*domain.MessageReaction, error) {
+	reactions := make(map[uuid.UUID][]*domain.MessageReaction)
+	if len(messageIDs) == 0 {
+		return reactions, nil
+	}
+
+	query := `
+		SELECT message_id, emoji, array_agg(user_id ORDER BY created_at)
+		FROM message_reactions WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at)
+	`
+
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID uuid.UUID
+		var reaction domain.MessageReaction
+		if err := rows.Scan(&messageID, &reaction.Emoji, &reaction.UserIDs); err != nil {
+			return nil, err
+		}
+		reaction.Count = len(reaction.UserIDs)
+		reactions[messageID] = append(reactions[messageID], &reaction)
+	}
+	return reactions, rows.Err()
+}
+
+// scanMessage scans a row selected with messageColumns
+func scanMessage(row pgx.Row) (*domain.Message, error) {
+	var message domain.Message
+	var metadataJSON []byte
+
+	err := row.Scan(
+		&message.ID, &message.OfficeID, &message.ConversationID, &message.ParentMessageID,
+		&message.SenderType, &message.SenderID, &message.Content,
+		&metadataJSON, &message.CreatedAt, &message.EditedAt, &message.DeletedAt, &message.PinnedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(metadataJSON, &message.Metadata); err != nil {
+		message.Metadata = make(map[string]any)
+	}
+
+	return &message, nil
+}
+
+// scanMessages scans all rows selected with messageColumns
+func scanMessages(rows pgx.Rows) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ModelPolicyRepository implements domain.ModelPolicyRepository
+type ModelPolicyRepository struct {
+	db conn
+}
+
+// NewModelPolicyRepository creates a new ModelPolicyRepository
+func NewModelPolicyRepository(db *pgxpool.Pool) *ModelPolicyRepository {
+	return &ModelPolicyRepository{db: conn{db}}
+}
+
+const modelPolicyColumns = `id, office_id, agent_id, allowed_providers, preferred_model, preferred_provider,
+	max_credits_per_task, created_at, updated_at`
+
+// Get returns the office's policy, or with agentID the agent's own
+func (r *ModelPolicyRepository) Get(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) (*domain.ModelPolicy, error) {
+	query := `SELECT ` + modelPolicyColumns + ` FROM model_policies
+		WHERE office_id = $1 AND agent_id IS NOT DISTINCT FROM $2::uuid`
+
+	policy, err := scanModelPolicy(r.db.QueryRow(ctx, query, officeID, agentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return policy, err
+}
+
+// GetEffective returns the agent's own policy, or else the office's
+func (r *ModelPolicyRepository) GetEffective(ctx context.Context, officeID, agentID uuid.UUID) (*domain.ModelPolicy, error) {
+	query := `SELECT ` + modelPolicyColumns + ` FROM model_policies
+		WHERE office_id = $1 AND (agent_id = $2 OR agent_id IS NULL)
+		ORDER BY agent_id NULLS LAST
+		LIMIT 1`
+
+	policy, err := scanModelPolicy(r.db.QueryRow(ctx, query, officeID, agentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return policy, err
+}
+
+// ListByOffice returns the office's policy followed by its agents' policies
+func (r *ModelPolicyRepository) ListByOffice(ctx context.Context, officeID uuid.UUID) ([]*domain.ModelPolicy, error) {
+	query := `SELECT ` + modelPolicyColumns + ` FROM model_policies
+		WHERE office_id = $1
+		ORDER BY agent_id NULLS FIRST, created_at`
+
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*domain.ModelPolicy
+	for rows.Next() {
+		policy, err := scanModelPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Upsert creates or replaces the policy of the same office and agent. The
+// stored ID and creation time are written back to policy.
+func (r *ModelPolicyRepository) Upsert(ctx context.Context, policy *domain.ModelPolicy) error {
+	query := `
+		INSERT INTO model_policies (id, office_id, agent_id, allowed_providers, preferred_model, preferred_provider,
+			max_credits_per_task, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT ON CONSTRAINT model_policies_office_agent_key
+		DO UPDATE SET allowed_providers = EXCLUDED.allowed_providers,
+			preferred_model = EXCLUDED.preferred_model,
+			preferred_provider = EXCLUDED.preferred_provider,
+			max_credits_per_task = EXCLUDED.max_credits_per_task,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+	return r.db.QueryRow(ctx, query,
+		policy.ID, policy.OfficeID, policy.AgentID, policy.AllowedProviders, policy.PreferredModel,
+		policy.PreferredProvider, policy.MaxCreditsPerTask, policy.CreatedAt, policy.UpdatedAt,
+	).Scan(&policy.ID, &policy.CreatedAt)
+}
+
+// Delete removes the office's policy, or with agentID the agent's own
+func (r *ModelPolicyRepository) Delete(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) error {
+	result, err := r.db.Exec(ctx,
+		`DELETE FROM model_policies WHERE office_id = $1 AND agent_id IS NOT DISTINCT FROM $2::uuid`,
+		officeID, agentID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func scanModelPolicy(row pgx.Row) (*domain.ModelPolicy, error) {
+	var policy domain.ModelPolicy
+	err := row.Scan(
+		&policy.ID, &policy.OfficeID, &policy.AgentID, &policy.AllowedProviders, &policy.PreferredModel,
+		&policy.PreferredProvider, &policy.MaxCreditsPerTask, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ModerationFlagRepository implements domain.ModerationFlagRepository
+type ModerationFlagRepository struct {
+	db conn
+}
+
+// NewModerationFlagRepository creates a new ModerationFlagRepository
+func NewModerationFlagRepository(db *pgxpool.Pool) *ModerationFlagRepository {
+	return &ModerationFlagRepository{db: conn{db}}
+}
+
+const moderationFlagColumns = `id, content_type, entity_id, office_id, user_id, content, categories, action, status,
+	reviewed_by, review_note, reviewed_at, created_at`
+
+// Create queues a flag for review
+func (r *ModerationFlagRepository) Create(ctx context.Context, flag *domain.ModerationFlag) error {
+	query := `
+		INSERT INTO moderation_flags (id, content_type, entity_id, office_id, user_id, content, categories, action, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	categories := flag.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	_, err := r.db.Exec(ctx, query,
+		flag.ID,
+		flag.ContentType,
+		flag.EntityID,
+		flag.OfficeID,
+		flag.UserID,
+		flag.Content,
+		categories,
+		flag.Action,
+		flag.Status,
+		flag.CreatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a flag by ID
+func (r *ModerationFlagRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ModerationFlag, error) {
+	query := `SELECT ` + moderationFlagColumns + ` FROM moderation_flags WHERE id = $1`
+
+	flag, err := scanModerationFlag(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return flag, err
+}
+
+// List returns a page of flags, oldest first, and how many match the filter
+func (r *ModerationFlagRepository) List(ctx context.Context, filter domain.ModerationFlagFilter, limit, offset int) ([]*domain.ModerationFlag, int, error) {
+	q := &queryBuilder{}
+	if filter.Status != "" {
+		q.where("status = " + q.arg(filter.Status))
+	}
+	if filter.ContentType != "" {
+		q.where("content_type = " + q.arg(filter.ContentType))
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM moderation_flags`+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + moderationFlagColumns + ` FROM moderation_flags` + q.whereClause() + `
+		ORDER BY created_at, id
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var flags []*domain.ModerationFlag
+	for rows.Next() {
+		flag, err := scanModerationFlag(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, total, rows.Err()
+}
+
+// Resolve records an admin's decision on a pending flag
+func (r *ModerationFlagRepository) Resolve(
+	ctx context.Context,
+	id uuid.UUID,
+	status domain.ModerationFlagStatus,
+	reviewerID uuid.UUID,
+	note string,
+) error {
+	query := `
+		UPDATE moderation_flags
+		SET status = $2, reviewed_by = $3, review_note = $4, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+	tag, err := r.db.Exec(ctx, query, id, status, reviewerID, note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func scanModerationFlag(row pgx.Row) (*domain.ModerationFlag, error) {
+	var flag domain.ModerationFlag
+	err := row.Scan(
+		&flag.ID, &flag.ContentType, &flag.EntityID, &flag.OfficeID, &flag.UserID, &flag.Content, &flag.Categories,
+		&flag.Action, &flag.Status, &flag.ReviewedBy, &flag.ReviewNote, &flag.ReviewedAt, &flag.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
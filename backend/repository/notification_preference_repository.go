@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotificationPreferenceRepository implements domain.NotificationPreferenceRepository
+type NotificationPreferenceRepository struct {
+	db conn
+}
+
+// NewNotificationPreferenceRepository creates a new NotificationPreferenceRepository
+func NewNotificationPreferenceRepository(db *pgxpool.Pool) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{db: conn{db}}
+}
+
+// GetByUserID returns the preferences a user has chosen
+func (r *NotificationPreferenceRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.NotificationPreference, error) {
+	query := `
+		SELECT notification_type, in_app, email
+		FROM notification_preferences WHERE user_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []*domain.NotificationPreference
+	for rows.Next() {
+		var p domain.NotificationPreference
+		if err := rows.Scan(&p.Type, &p.InApp, &p.Email); err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, &p)
+	}
+	return preferences, rows.Err()
+}
+
+// Upsert stores a user's preferences in one round trip, replacing those of
+// the same types
+func (r *NotificationPreferenceRepository) Upsert(ctx context.Context, userID uuid.UUID, preferences []*domain.NotificationPreference) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, notification_type, in_app, email, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, notification_type)
+		DO UPDATE SET in_app = EXCLUDED.in_app, email = EXCLUDED.email, updated_at = EXCLUDED.updated_at
+	`
+	batch := &pgx.Batch{}
+	for _, p := range preferences {
+		batch.Queue(query, userID, p.Type, p.InApp, p.Email)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotificationRepository implements domain.NotificationRepository
+type NotificationRepository struct {
+	db conn
+}
+
+// NewNotificationRepository creates a new NotificationRepository
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: conn{db}}
+}
+
+// Create persists a new notification
+func (r *NotificationRepository) Create(ctx context.Context, notification *domain.Notification) error {
+	query := `
+		INSERT INTO notifications (id, office_id, notification_type, title, message, payload, is_read, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(ctx, query,
+		notification.ID,
+		notification.OfficeID,
+		notification.Type,
+		notification.Title,
+		notification.Message,
+		notification.Payload,
+		notification.IsRead,
+		notification.CreatedAt,
+	)
+	return err
+}
+
+const notificationColumns = `id, office_id, notification_type, title, message, payload, is_read, read_at, created_at`
+
+// List returns a page of an office's notifications, newest first. A cursor
+// continues before the last notification of the previous page.
+func (r *NotificationRepository) List(ctx context.Context, officeID uuid.UUID, unreadOnly bool, page domain.PageRequest) ([]*domain.Notification, error) {
+	q := notificationFilterQuery(officeID, unreadOnly)
+	if page.Cursor != nil {
+		q.where("(created_at, id) < (" + q.arg(page.Cursor.CreatedAt) + ", " + q.arg(page.Cursor.ID) + ")")
+	}
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(page.Limit)
+	if page.Cursor == nil && page.Offset > 0 {
+		query += ` OFFSET ` + q.arg(page.Offset)
+	}
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotifications(rows)
+}
+
+// Count returns the number of an office's notifications
+func (r *NotificationRepository) Count(ctx context.Context, officeID uuid.UUID, unreadOnly bool) (int, error) {
+	q := notificationFilterQuery(officeID, unreadOnly)
+
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM notifications`+q.whereClause(), q.args...).Scan(&count)
+	return count, err
+}
+
+// notificationFilterQuery builds the WHERE conditions of a notification listing
+func notificationFilterQuery(officeID uuid.UUID, unreadOnly bool) *queryBuilder {
+	q := &queryBuilder{}
+	q.where("office_id = " + q.arg(officeID))
+	if unreadOnly {
+		q.where("is_read = FALSE")
+	}
+	return q
+}
+
+// GetUnread retrieves unread notifications for an office, newest first
+func (r *NotificationRepository) GetUnread(ctx context.Context, officeID uuid.UUID, limit int) ([]*domain.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE office_id = $1 AND is_read = FALSE
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, query, officeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotifications(rows)
+}
+
+// MarkRead marks an office's notification as read. Notifications already
+// read keep their read_at.
+func (r *NotificationRepository) MarkRead(ctx context.Context, officeID, id uuid.UUID) error {
+	query := `
+		UPDATE notifications SET is_read = TRUE, read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND office_id = $2
+	`
+	result, err := r.db.Exec(ctx, query, id, officeID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks all of an office's unread notifications as read and
+// returns how many there were
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, officeID uuid.UUID) (int, error) {
+	query := `UPDATE notifications SET is_read = TRUE, read_at = NOW() WHERE office_id = $1 AND is_read = FALSE`
+	result, err := r.db.Exec(ctx, query, officeID)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// scanNotifications reads notification rows into entities
+func scanNotifications(rows pgx.Rows) ([]*domain.Notification, error) {
+	var notifications []*domain.Notification
+	for rows.Next() {
+		var n domain.Notification
+		if err := rows.Scan(
+			&n.ID, &n.OfficeID, &n.Type, &n.Title, &n.Message,
+			&n.Payload, &n.IsRead, &n.ReadAt, &n.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
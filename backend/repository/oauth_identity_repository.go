@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OAuthIdentityRepository implements domain.OAuthIdentityRepository
+type OAuthIdentityRepository struct {
+	db conn
+}
+
+// NewOAuthIdentityRepository creates a new OAuthIdentityRepository
+func NewOAuthIdentityRepository(db *pgxpool.Pool) *OAuthIdentityRepository {
+	return &OAuthIdentityRepository{db: conn{db}}
+}
+
+// Create links a user to a provider account
+func (r *OAuthIdentityRepository) Create(ctx context.Context, identity *domain.OAuthIdentity) error {
+	query := `
+		INSERT INTO oauth_identities (id, user_id, provider, provider_user_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(ctx, query,
+		identity.ID, identity.UserID, identity.Provider, identity.ProviderUserID, identity.Email, identity.CreatedAt,
+	)
+	return err
+}
+
+// GetByProviderUserID returns the identity for a provider account
+func (r *OAuthIdentityRepository) GetByProviderUserID(ctx context.Context, provider, providerUserID string) (*domain.OAuthIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, provider_user_id, email, created_at
+		FROM oauth_identities WHERE provider = $1 AND provider_user_id = $2
+	`
+
+	var identity domain.OAuthIdentity
+	err := r.db.QueryRow(ctx, query, provider, providerUserID).Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.ProviderUserID,
+		&identity.Email, &identity.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
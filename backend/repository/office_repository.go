@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -12,44 +13,55 @@ import (
 
 // OfficeRepository implements domain.OfficeRepository
 type OfficeRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewOfficeRepository creates a new OfficeRepository
 func NewOfficeRepository(db *pgxpool.Pool) *OfficeRepository {
-	return &OfficeRepository{db: db}
+	return &OfficeRepository{db: conn{db}}
 }
 
 // Create creates a new office
 func (r *OfficeRepository) Create(ctx context.Context, office *domain.Office) error {
 	query := `
-		INSERT INTO offices (id, user_id, name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO offices (id, user_id, name, timezone, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
-	_, err := r.db.Exec(ctx, query, office.ID, office.UserID, office.Name, office.CreatedAt, office.UpdatedAt)
+	_, err := r.db.Exec(ctx, query, office.ID, office.UserID, office.Name, office.Timezone, office.CreatedAt, office.UpdatedAt)
 	return err
 }
 
-// GetByID retrieves an office by ID
-func (r *OfficeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Office, error) {
-	query := `SELECT id, user_id, name, created_at, updated_at FROM offices WHERE id = $1`
+// officeColumns are the columns scanned by scanOffice
+const officeColumns = `id, user_id, name, timezone, created_at, updated_at, deleted_at`
 
+// scanOffice scans a row of officeColumns
+func scanOffice(row pgx.Row) (*domain.Office, error) {
 	var office domain.Office
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&office.ID, &office.UserID, &office.Name, &office.CreatedAt, &office.UpdatedAt,
-	)
+	if err := row.Scan(
+		&office.ID, &office.UserID, &office.Name, &office.Timezone, &office.CreatedAt, &office.UpdatedAt, &office.DeletedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &office, nil
+}
+
+// GetByID retrieves an office by ID, even if it is deleted
+func (r *OfficeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Office, error) {
+	query := `SELECT ` + officeColumns + ` FROM offices WHERE id = $1`
+
+	office, err := scanOffice(r.db.QueryRow(ctx, query, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &office, nil
+	return office, nil
 }
 
-// GetByUserID retrieves all offices for a user
+// GetByUserID retrieves all offices for a user that are not deleted
 func (r *OfficeRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Office, error) {
-	query := `SELECT id, user_id, name, created_at, updated_at FROM offices WHERE user_id = $1 ORDER BY created_at`
+	query := `SELECT ` + officeColumns + ` FROM offices WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at`
 
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
@@ -59,25 +71,66 @@ func (r *OfficeRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([
 
 	var offices []*domain.Office
 	for rows.Next() {
-		var office domain.Office
-		if err := rows.Scan(&office.ID, &office.UserID, &office.Name, &office.CreatedAt, &office.UpdatedAt); err != nil {
+		office, err := scanOffice(rows)
+		if err != nil {
 			return nil, err
 		}
-		offices = append(offices, &office)
+		offices = append(offices, office)
 	}
 	return offices, rows.Err()
 }
 
 // Update updates an office
 func (r *OfficeRepository) Update(ctx context.Context, office *domain.Office) error {
-	query := `UPDATE offices SET name = $2, updated_at = $3 WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, office.ID, office.Name, office.UpdatedAt)
+	query := `UPDATE offices SET name = $2, timezone = $3, updated_at = $4 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, office.ID, office.Name, office.Timezone, office.UpdatedAt)
 	return err
 }
 
-// Delete deletes an office
-func (r *OfficeRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM offices WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, id)
-	return err
+// SoftDelete deletes an office. The row, its wallet, invoices and audit log
+// stay, but its schedules stop, its API keys and widget tokens are revoked
+// and its subscription is not renewed.
+func (r *OfficeRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE offices SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	cleanup := []string{
+		`UPDATE scheduled_tasks SET is_active = FALSE, next_run_at = NULL WHERE is_active AND office_id = $1`,
+		`UPDATE api_keys SET revoked_at = NOW() WHERE office_id = $1 AND revoked_at IS NULL`,
+		`UPDATE widget_tokens SET revoked_at = NOW() WHERE office_id = $1 AND revoked_at IS NULL`,
+		`UPDATE subscriptions SET cancel_at_period_end = TRUE WHERE office_id = $1`,
+	}
+	for _, query := range cleanup {
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Restore undeletes an office that was deleted after deletedAfter. Its
+// schedules, API keys, widget tokens and subscription renewal are not turned
+// back on.
+func (r *OfficeRepository) Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error {
+	query := `UPDATE offices SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at > $2`
+	tag, err := r.db.Exec(ctx, query, id, deletedAfter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
 }
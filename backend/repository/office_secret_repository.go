@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OfficeSecretRepository implements domain.OfficeSecretRepository
+type OfficeSecretRepository struct {
+	db conn
+}
+
+// NewOfficeSecretRepository creates a new OfficeSecretRepository
+func NewOfficeSecretRepository(db *pgxpool.Pool) *OfficeSecretRepository {
+	return &OfficeSecretRepository{db: conn{db}}
+}
+
+const officeSecretColumns = `id, office_id, name, description, agent_ids, ciphertext, hint, created_by,
+	last_accessed_at, created_at, updated_at`
+
+// Create stores a new secret, returning domain.ErrAlreadyExists if the
+// office already has one by its name
+func (r *OfficeSecretRepository) Create(ctx context.Context, secret *domain.OfficeSecret) error {
+	query := `
+		INSERT INTO office_secrets (id, office_id, name, description, agent_ids, ciphertext, hint, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (office_id, name) DO NOTHING
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		secret.ID,
+		secret.OfficeID,
+		secret.Name,
+		secret.Description,
+		secretAgentIDs(secret),
+		secret.Ciphertext,
+		secret.Hint,
+		secret.CreatedBy,
+		secret.CreatedAt,
+		secret.UpdatedAt,
+	).Scan(&secret.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID returns a secret by ID
+func (r *OfficeSecretRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.OfficeSecret, error) {
+	query := `SELECT ` + officeSecretColumns + ` FROM office_secrets WHERE id = $1`
+
+	secret, err := scanOfficeSecret(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return secret, err
+}
+
+// GetByOfficeID returns an office's secrets by name
+func (r *OfficeSecretRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.OfficeSecret, error) {
+	query := `SELECT ` + officeSecretColumns + ` FROM office_secrets WHERE office_id = $1 ORDER BY name`
+
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*domain.OfficeSecret
+	for rows.Next() {
+		secret, err := scanOfficeSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+// Update saves a secret's description, agents and value
+func (r *OfficeSecretRepository) Update(ctx context.Context, secret *domain.OfficeSecret) error {
+	query := `
+		UPDATE office_secrets
+		SET description = $2, agent_ids = $3, ciphertext = $4, hint = $5, updated_at = $6
+		WHERE id = $1
+	`
+	tag, err := r.db.Exec(ctx, query,
+		secret.ID,
+		secret.Description,
+		secretAgentIDs(secret),
+		secret.Ciphertext,
+		secret.Hint,
+		secret.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// MarkAccessed records that the secrets were decrypted at the time
+func (r *OfficeSecretRepository) MarkAccessed(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(ctx, `UPDATE office_secrets SET last_accessed_at = $2 WHERE id = ANY($1)`, ids, at)
+	return err
+}
+
+// Delete removes a secret
+func (r *OfficeSecretRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM office_secrets WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// secretAgentIDs returns the agents a secret is limited to, never nil, as
+// the column is NOT NULL
+func secretAgentIDs(secret *domain.OfficeSecret) []uuid.UUID {
+	if secret.AgentIDs == nil {
+		return []uuid.UUID{}
+	}
+	return secret.AgentIDs
+}
+
+// scanOfficeSecret scans a row selected with officeSecretColumns
+func scanOfficeSecret(row pgx.Row) (*domain.OfficeSecret, error) {
+	var secret domain.OfficeSecret
+	err := row.Scan(
+		&secret.ID,
+		&secret.OfficeID,
+		&secret.Name,
+		&secret.Description,
+		&secret.AgentIDs,
+		&secret.Ciphertext,
+		&secret.Hint,
+		&secret.CreatedBy,
+		&secret.LastAccessedAt,
+		&secret.CreatedAt,
+		&secret.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
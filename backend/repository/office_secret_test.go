@@ -0,0 +1,67 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestOfficeSecretNamesAreUniquePerOffice(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewOfficeSecretRepository(testDB.Pool)
+	user := testDB.User(t)
+	office := testDB.Office(t, user)
+
+	now := time.Now()
+	secret := &domain.OfficeSecret{
+		ID:         uuid.New(),
+		OfficeID:   office,
+		Name:       "GITHUB_TOKEN",
+		Ciphertext: []byte{1, 2, 3},
+		Hint:       "cdef",
+		CreatedBy:  &user,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := repo.Create(ctx, secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	duplicate := *secret
+	duplicate.ID = uuid.New()
+	if err := repo.Create(ctx, &duplicate); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create with a taken name error = %v, want ErrAlreadyExists", err)
+	}
+
+	agent := uuid.New()
+	secret.AgentIDs = []uuid.UUID{agent}
+	secret.Ciphertext = []byte{4, 5, 6}
+	if err := repo.Update(ctx, secret); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.MarkAccessed(ctx, []uuid.UUID{secret.ID}, now); err != nil {
+		t.Fatalf("MarkAccessed: %v", err)
+	}
+
+	secrets, err := repo.GetByOfficeID(ctx, office)
+	if err != nil || len(secrets) != 1 {
+		t.Fatalf("GetByOfficeID = %d secrets, %v; want 1", len(secrets), err)
+	}
+	got := secrets[0]
+	if !got.AvailableTo(agent) || got.AvailableTo(uuid.New()) || got.Ciphertext[0] != 4 || got.LastAccessedAt == nil {
+		t.Errorf("GetByOfficeID secret = %+v, want the updated secret limited to the agent", got)
+	}
+
+	if err := repo.Delete(ctx, secret.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, secret.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID deleted secret error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,144 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestDataExportsAreBuiltOneAtATime(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewDataExportRepository(testDB.Pool)
+	user := testDB.User(t)
+	request := func() (*domain.DataExport, error) {
+		export := &domain.DataExport{ID: uuid.New(), UserID: user, NextAttemptAt: time.Now(), CreatedAt: time.Now()}
+		return export, repo.Create(ctx, export)
+	}
+
+	first, err := request()
+	if err != nil || first.Status != domain.DataExportPending {
+		t.Fatalf("Create = %+v, %v; want a pending export", first, err)
+	}
+	if _, err := request(); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create while pending error = %v, want ErrAlreadyExists", err)
+	}
+
+	claimed, err := repo.ClaimDue(ctx, time.Now().Add(time.Second), time.Minute, 1000)
+	if err != nil {
+		t.Fatalf("ClaimDue: %v", err)
+	}
+	var found bool
+	for _, export := range claimed {
+		found = found || export.ID == first.ID && export.Attempts == 1
+	}
+	if !found {
+		t.Fatal("ClaimDue did not claim the pending export")
+	}
+	if err := repo.MarkReady(ctx, first.ID, "exports/archive.zip", 2048, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("MarkReady: %v", err)
+	}
+
+	// Once built, another export can be requested
+	if _, err := request(); err != nil {
+		t.Errorf("Create after the export was built: %v", err)
+	}
+	expired, err := repo.GetExpired(ctx, time.Now(), 1000)
+	if err != nil || len(expired) == 0 {
+		t.Fatalf("GetExpired = %d exports, %v; want the expired one", len(expired), err)
+	}
+	if err := repo.MarkExpired(ctx, first.ID); err != nil {
+		t.Fatalf("MarkExpired: %v", err)
+	}
+	if export, err := repo.GetByID(ctx, first.ID); err != nil || export.Status != domain.DataExportExpired || export.StorageKey != "" {
+		t.Errorf("GetByID expired export = %+v, %v; want it expired without its archive", export, err)
+	}
+}
+
+func TestEraseKeepsAnonymizedAccountingRecords(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewUserRepository(testDB.Pool)
+	credits := repository.NewCreditRepository(testDB.Pool)
+	conversations := repository.NewConversationRepository(testDB.Pool)
+	user := testDB.User(t)
+	office := testDB.Office(t, user)
+	wallet := testDB.Wallet(t, office, 100)
+	template := testDB.Template(t, testDB.User(t))
+	conversation, _ := newConversation(t, office, template, time.Now(), 1)
+	lead := newAgent(t, office, template, "1.0.0", time.Now())
+	member := newAgent(t, office, template, "1.0.0", time.Now())
+	if _, err := testDB.Pool.Exec(ctx, `
+		WITH department AS (
+			INSERT INTO departments (office_id, name, description, lead_agent_id)
+			VALUES ($1, 'Ada''s research', 'Led by Ada Lovelace', $2) RETURNING id
+		)
+		INSERT INTO department_members (department_id, agent_id) SELECT id, $3 FROM department
+	`, office, lead.ID, member.ID); err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	if _, err := testDB.Pool.Exec(ctx,
+		`INSERT INTO agent_reporting_lines (agent_id, manager_id) VALUES ($1, $2)`, member.ID, lead.ID); err != nil {
+		t.Fatalf("create reporting line: %v", err)
+	}
+	if _, err := testDB.Pool.Exec(ctx,
+		`INSERT INTO agent_skills (agent_id, skill, enabled) VALUES ($1, 'web_search', true)`, lead.ID); err != nil {
+		t.Fatalf("create agent skill: %v", err)
+	}
+	if _, err := testDB.Pool.Exec(ctx,
+		`INSERT INTO model_policies (office_id, preferred_model) VALUES ($1, 'gpt-4o')`, office); err != nil {
+		t.Fatalf("create model policy: %v", err)
+	}
+
+	if _, err := credits.AddCredits(ctx, wallet, 50, domain.TransactionTypeAdjustment, "Goodwill for Ada Lovelace", "admin", nil); err != nil {
+		t.Fatalf("AddCredits: %v", err)
+	}
+	export := &domain.DataExport{ID: uuid.New(), UserID: user, NextAttemptAt: time.Now(), CreatedAt: time.Now()}
+	if err := repository.NewDataExportRepository(testDB.Pool).Create(ctx, export); err != nil {
+		t.Fatalf("Create export: %v", err)
+	}
+
+	if _, err := users.Erase(ctx, user); err != nil {
+		t.Fatalf("Erase: %v", err)
+	}
+	if _, err := users.Erase(ctx, user); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Erase again error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := users.GetByID(ctx, user); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID erased user error = %v, want ErrNotFound", err)
+	}
+	if _, err := conversations.GetByID(ctx, conversation.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID conversation of an erased user error = %v, want ErrNotFound", err)
+	}
+	transactions, err := credits.GetTransactions(ctx, wallet, domain.PageRequest{Limit: 10})
+	if err != nil || len(transactions) == 0 {
+		t.Fatalf("GetTransactions = %d transactions, %v; want the ledger kept", len(transactions), err)
+	}
+	for _, tx := range transactions {
+		if tx.Description != "" {
+			t.Errorf("transaction %s kept its description %q", tx.ID, tx.Description)
+		}
+	}
+	leftovers := map[string]string{
+		"departments":     `SELECT COUNT(*) FROM departments WHERE office_id = $1`,
+		"reporting lines": `SELECT COUNT(*) FROM agent_reporting_lines l JOIN agents a ON a.id = l.agent_id WHERE a.office_id = $1`,
+		"agent skills":    `SELECT COUNT(*) FROM agent_skills s JOIN agents a ON a.id = s.agent_id WHERE a.office_id = $1`,
+		"model policies":  `SELECT COUNT(*) FROM model_policies WHERE office_id = $1`,
+	}
+	for name, query := range leftovers {
+		var n int
+		if err := testDB.Pool.QueryRow(ctx, query, office).Scan(&n); err != nil || n != 0 {
+			t.Errorf("%s of the erased office = %d, %v; want none", name, n, err)
+		}
+	}
+	erased, err := repository.NewOfficeRepository(testDB.Pool).GetByID(ctx, office)
+	if err != nil || erased.Name != "Erased office" || erased.DeletedAt == nil {
+		t.Errorf("GetByID office of an erased user = %+v, %v; want it renamed and deleted", erased, err)
+	}
+}
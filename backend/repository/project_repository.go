@@ -0,0 +1,509 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProjectRepository implements domain.ProjectRepository
+type ProjectRepository struct {
+	db conn
+}
+
+// NewProjectRepository creates a new ProjectRepository
+func NewProjectRepository(db *pgxpool.Pool) *ProjectRepository {
+	return &ProjectRepository{db: conn{db}}
+}
+
+const projectColumns = `id, office_id, name, description, created_at, updated_at`
+
+const boardColumnColumns = `id, project_id, name, position, created_at`
+
+// boardCardColumns are the columns scanned by scanBoardCard: the card c
+// and a summary of its task t
+const boardCardColumns = `c.task_id, c.column_id, c.position, c.added_at, c.updated_at,
+	t.office_id, t.conversation_id, t.agent_id, t.status, t.input, t.created_at, t.completed_at`
+
+// Create stores a project with its first columns, returning
+// domain.ErrAlreadyExists if the office already has a project by its name
+func (r *ProjectRepository) Create(ctx context.Context, project *domain.Project, columns []*domain.BoardColumn) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO projects (id, office_id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (office_id, name) DO NOTHING
+		RETURNING id
+	`
+	err = tx.QueryRow(ctx, query,
+		project.ID, project.OfficeID, project.Name, project.Description, project.CreatedAt, project.UpdatedAt,
+	).Scan(&project.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, column := range columns {
+		column.Position = i
+		_, err := tx.Exec(ctx, `
+			INSERT INTO board_columns (id, project_id, name, position, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, column.ID, column.ProjectID, column.Name, column.Position, column.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// GetByID returns a project by ID, without columns
+func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+
+	var project domain.Project
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&project.ID, &project.OfficeID, &project.Name, &project.Description, &project.CreatedAt, &project.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// GetByOfficeID returns an office's projects by name, without columns
+func (r *ProjectRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE office_id = $1 ORDER BY name`
+
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []*domain.Project
+	for rows.Next() {
+		var project domain.Project
+		err := rows.Scan(
+			&project.ID, &project.OfficeID, &project.Name, &project.Description, &project.CreatedAt, &project.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, &project)
+	}
+	return projects, rows.Err()
+}
+
+// Update saves a project's name and description, returning
+// domain.ErrAlreadyExists if the office has another project by the name
+func (r *ProjectRepository) Update(ctx context.Context, project *domain.Project) error {
+	query := `
+		UPDATE projects p
+		SET name = $2, description = $3, updated_at = $4
+		WHERE p.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM projects o WHERE o.office_id = p.office_id AND o.name = $2 AND o.id <> p.id
+		)
+	`
+	tag, err := r.db.Exec(ctx, query, project.ID, project.Name, project.Description, project.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := r.GetByID(ctx, project.ID); err != nil {
+			return err
+		}
+		return domain.ErrAlreadyExists
+	}
+	return nil
+}
+
+// Delete removes a project with its board; its tasks are kept
+func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM projects WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// GetColumns returns a project's columns in order, without cards
+func (r *ProjectRepository) GetColumns(ctx context.Context, projectID uuid.UUID) ([]*domain.BoardColumn, error) {
+	query := `SELECT ` + boardColumnColumns + ` FROM board_columns WHERE project_id = $1 ORDER BY position`
+
+	rows, err := r.db.Query(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*domain.BoardColumn
+	for rows.Next() {
+		column, err := scanBoardColumn(rows)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// GetColumn returns a board column by ID, without cards
+func (r *ProjectRepository) GetColumn(ctx context.Context, id uuid.UUID) (*domain.BoardColumn, error) {
+	query := `SELECT ` + boardColumnColumns + ` FROM board_columns WHERE id = $1`
+
+	column, err := scanBoardColumn(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return column, nil
+}
+
+// CreateColumn adds a column at the end of its project's board
+func (r *ProjectRepository) CreateColumn(ctx context.Context, column *domain.BoardColumn) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockBoard(ctx, tx, column.ProjectID); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO board_columns (id, project_id, name, position, created_at)
+		SELECT $1, $2, $3, COUNT(*), $4 FROM board_columns WHERE project_id = $2
+		RETURNING position
+	`
+	err = tx.QueryRow(ctx, query, column.ID, column.ProjectID, column.Name, column.CreatedAt).Scan(&column.Position)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateColumn renames a column and moves it to its Position, clamped to
+// the end of the board
+func (r *ProjectRepository) UpdateColumn(ctx context.Context, column *domain.BoardColumn) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockBoard(ctx, tx, column.ProjectID); err != nil {
+		return err
+	}
+	var from int
+	err = tx.QueryRow(ctx, `SELECT position FROM board_columns WHERE id = $1`, column.ID).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := columnPositions.close(ctx, tx, column.ProjectID, from); err != nil {
+		return err
+	}
+	if column.Position, err = columnPositions.open(ctx, tx, column.ProjectID, column.ID, column.Position); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE board_columns SET name = $2, position = $3 WHERE id = $1`,
+		column.ID, column.Name, column.Position)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteColumn removes a column with its cards, closing the gap it leaves
+func (r *ProjectRepository) DeleteColumn(ctx context.Context, id uuid.UUID) error {
+	column, err := r.GetColumn(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockBoard(ctx, tx, column.ProjectID); err != nil {
+		return err
+	}
+	var position int
+	err = tx.QueryRow(ctx, `DELETE FROM board_columns WHERE id = $1 RETURNING position`, id).Scan(&position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := columnPositions.close(ctx, tx, column.ProjectID, position); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetCards returns the cards of a project's board by column and position,
+// with summaries of their tasks
+func (r *ProjectRepository) GetCards(ctx context.Context, projectID uuid.UUID) ([]*domain.BoardCard, error) {
+	query := `
+		SELECT ` + boardCardColumns + `
+		FROM board_cards c
+		JOIN board_columns bc ON bc.id = c.column_id
+		JOIN tasks t ON t.id = c.task_id
+		WHERE bc.project_id = $1
+		ORDER BY bc.position, c.position
+	`
+	rows, err := r.db.Query(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []*domain.BoardCard
+	for rows.Next() {
+		card, err := scanBoardCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// GetCard returns the card of a task with a summary of the task
+func (r *ProjectRepository) GetCard(ctx context.Context, taskID uuid.UUID) (*domain.BoardCard, error) {
+	query := `
+		SELECT ` + boardCardColumns + `
+		FROM board_cards c
+		JOIN tasks t ON t.id = c.task_id
+		WHERE c.task_id = $1
+	`
+	card, err := scanBoardCard(r.db.QueryRow(ctx, query, taskID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// AddCard places a task at its Position in its column, clamped to the end
+// of the column, returning domain.ErrAlreadyExists if the task is already a
+// card
+func (r *ProjectRepository) AddCard(ctx context.Context, card *domain.BoardCard) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockColumnBoard(ctx, tx, card.ColumnID); err != nil {
+		return err
+	}
+	if card.Position, err = cardPositions.open(ctx, tx, card.ColumnID, card.TaskID, card.Position); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO board_cards (task_id, column_id, position, added_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (task_id) DO NOTHING
+	`
+	tag, err := tx.Exec(ctx, query, card.TaskID, card.ColumnID, card.Position, card.AddedAt, card.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAlreadyExists
+	}
+	return tx.Commit(ctx)
+}
+
+// MoveCard moves a card to its ColumnID and Position, clamped to the end
+// of the column
+func (r *ProjectRepository) MoveCard(ctx context.Context, card *domain.BoardCard) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Cards only move within a board, so locking the destination's board
+	// also locks the card's
+	if err := lockColumnBoard(ctx, tx, card.ColumnID); err != nil {
+		return err
+	}
+	var fromColumn uuid.UUID
+	var from int
+	err = tx.QueryRow(ctx, `SELECT column_id, position FROM board_cards WHERE task_id = $1`, card.TaskID).Scan(&fromColumn, &from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := cardPositions.close(ctx, tx, fromColumn, from); err != nil {
+		return err
+	}
+	if card.Position, err = cardPositions.open(ctx, tx, card.ColumnID, card.TaskID, card.Position); err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, `
+		UPDATE board_cards SET column_id = $2, position = $3, updated_at = NOW()
+		WHERE task_id = $1
+		RETURNING updated_at
+	`, card.TaskID, card.ColumnID, card.Position).Scan(&card.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RemoveCard takes a task off its board, closing the gap it leaves
+func (r *ProjectRepository) RemoveCard(ctx context.Context, taskID uuid.UUID) error {
+	card, err := r.GetCard(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockColumnBoard(ctx, tx, card.ColumnID); err != nil {
+		return err
+	}
+	var column uuid.UUID
+	var position int
+	err = tx.QueryRow(ctx, `DELETE FROM board_cards WHERE task_id = $1 RETURNING column_id, position`, taskID).Scan(&column, &position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := cardPositions.close(ctx, tx, column, position); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockBoard serializes changes to the positions on a project's board
+func lockBoard(ctx context.Context, tx pgx.Tx, projectID uuid.UUID) error {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR UPDATE`, projectID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// lockColumnBoard locks the board of a column
+func lockColumnBoard(ctx context.Context, tx pgx.Tx, columnID uuid.UUID) error {
+	var projectID uuid.UUID
+	err := tx.QueryRow(ctx, `SELECT project_id FROM board_columns WHERE id = $1`, columnID).Scan(&projectID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return lockBoard(ctx, tx, projectID)
+}
+
+// positions are the consecutive positions of a table's rows within their
+// group, such as the cards of a column
+type positions struct {
+	table string
+	group string
+	key   string
+}
+
+var (
+	columnPositions = positions{table: "board_columns", group: "project_id", key: "id"}
+	cardPositions   = positions{table: "board_cards", group: "column_id", key: "task_id"}
+)
+
+// close closes the gap a row left at position in a group
+func (p positions) close(ctx context.Context, tx pgx.Tx, group uuid.UUID, position int) error {
+	query := fmt.Sprintf(`UPDATE %s SET position = position - 1 WHERE %s = $1 AND position > $2`, p.table, p.group)
+	_, err := tx.Exec(ctx, query, group, position)
+	return err
+}
+
+// open makes room at position in a group for the row with key, clamping
+// the position to the end of the group's other rows, and returns it
+func (p positions) open(ctx context.Context, tx pgx.Tx, group, key uuid.UUID, position int) (int, error) {
+	var count int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s = $1 AND %s <> $2`, p.table, p.group, p.key)
+	if err := tx.QueryRow(ctx, query, group, key).Scan(&count); err != nil {
+		return 0, err
+	}
+	if position < 0 || position > count {
+		position = count
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET position = position + 1 WHERE %s = $1 AND position >= $2 AND %s <> $3`,
+		p.table, p.group, p.key)
+	_, err := tx.Exec(ctx, query, group, position, key)
+	return position, err
+}
+
+// scanBoardColumn scans a row selected with boardColumnColumns
+func scanBoardColumn(row pgx.Row) (*domain.BoardColumn, error) {
+	var column domain.BoardColumn
+	err := row.Scan(&column.ID, &column.ProjectID, &column.Name, &column.Position, &column.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &column, nil
+}
+
+// scanBoardCard scans a row selected with boardCardColumns
+func scanBoardCard(row pgx.Row) (*domain.BoardCard, error) {
+	var card domain.BoardCard
+	task := domain.Task{}
+	var conversationID *uuid.UUID
+	err := row.Scan(
+		&card.TaskID, &card.ColumnID, &card.Position, &card.AddedAt, &card.UpdatedAt,
+		&task.OfficeID, &conversationID, &task.AgentID, &task.Status, &task.Input, &task.CreatedAt, &task.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	task.ID = card.TaskID
+	if conversationID != nil {
+		task.ConversationID = *conversationID
+	}
+	card.Task = &task
+	return &card, nil
+}
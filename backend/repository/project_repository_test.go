@@ -0,0 +1,99 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestProjectBoardKeepsPositionsConsecutive(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewProjectRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	agent := newAgent(t, office, testDB.Template(t, testDB.User(t)), "1.0.0", time.Now())
+
+	now := time.Now()
+	project := &domain.Project{ID: uuid.New(), OfficeID: office, Name: "Launch", CreatedAt: now, UpdatedAt: now}
+	todo := &domain.BoardColumn{ID: uuid.New(), ProjectID: project.ID, Name: "To do", CreatedAt: now}
+	done := &domain.BoardColumn{ID: uuid.New(), ProjectID: project.ID, Name: "Done", CreatedAt: now}
+	if err := repo.Create(ctx, project, []*domain.BoardColumn{todo, done}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	duplicate := &domain.Project{ID: uuid.New(), OfficeID: office, Name: "Launch", CreatedAt: now, UpdatedAt: now}
+	if err := repo.Create(ctx, duplicate, nil); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create duplicate error = %v, want ErrAlreadyExists", err)
+	}
+
+	// Cards past the end of a column go last
+	tasks := make([]uuid.UUID, 3)
+	for i := range tasks {
+		tasks[i] = uuid.New()
+		_, err := testDB.Pool.Exec(ctx, `
+			INSERT INTO tasks (id, office_id, agent_id, status, input) VALUES ($1, $2, $3, 'pending', 'Test')
+		`, tasks[i], office, agent.ID)
+		if err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+		card := &domain.BoardCard{TaskID: tasks[i], ColumnID: todo.ID, Position: 10, AddedAt: now, UpdatedAt: now}
+		if err := repo.AddCard(ctx, card); err != nil || card.Position != i {
+			t.Fatalf("AddCard %d = position %d, %v", i, card.Position, err)
+		}
+	}
+	again := &domain.BoardCard{TaskID: tasks[0], ColumnID: done.ID, AddedAt: now, UpdatedAt: now}
+	if err := repo.AddCard(ctx, again); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("AddCard again error = %v, want ErrAlreadyExists", err)
+	}
+
+	// Move the last card to the top of To do, then the middle one to Done
+	if err := repo.MoveCard(ctx, &domain.BoardCard{TaskID: tasks[2], ColumnID: todo.ID, Position: 0}); err != nil {
+		t.Fatalf("MoveCard within the column: %v", err)
+	}
+	if err := repo.MoveCard(ctx, &domain.BoardCard{TaskID: tasks[0], ColumnID: done.ID, Position: 0}); err != nil {
+		t.Fatalf("MoveCard across columns: %v", err)
+	}
+
+	cards, err := repo.GetCards(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("GetCards: %v", err)
+	}
+	type placement struct {
+		task     uuid.UUID
+		column   uuid.UUID
+		position int
+	}
+	var got []placement
+	for _, card := range cards {
+		got = append(got, placement{card.TaskID, card.ColumnID, card.Position})
+		if card.Task == nil || card.Task.AgentID != agent.ID {
+			t.Errorf("card %s task = %+v, want a task of agent %s", card.TaskID, card.Task, agent.ID)
+		}
+	}
+	want := []placement{{tasks[2], todo.ID, 0}, {tasks[1], todo.ID, 1}, {tasks[0], done.ID, 0}}
+	if !slices.Equal(got, want) {
+		t.Errorf("cards = %v, want %v", got, want)
+	}
+
+	// Removing a card and moving a column close the gaps they leave
+	if err := repo.RemoveCard(ctx, tasks[2]); err != nil {
+		t.Fatalf("RemoveCard: %v", err)
+	}
+	if card, err := repo.GetCard(ctx, tasks[1]); err != nil || card.Position != 0 {
+		t.Errorf("GetCard after RemoveCard = %+v, %v; want position 0", card, err)
+	}
+	done.Position = 0
+	if err := repo.UpdateColumn(ctx, done); err != nil {
+		t.Fatalf("UpdateColumn: %v", err)
+	}
+	columns, err := repo.GetColumns(ctx, project.ID)
+	if err != nil || len(columns) != 2 || columns[0].ID != done.ID || columns[1].Position != 1 {
+		t.Errorf("GetColumns after moving Done first = %+v, %v", columns, err)
+	}
+}
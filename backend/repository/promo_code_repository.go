@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PromoCodeRepository implements domain.PromoCodeRepository
+type PromoCodeRepository struct {
+	db conn
+}
+
+// NewPromoCodeRepository creates a new PromoCodeRepository
+func NewPromoCodeRepository(db *pgxpool.Pool) *PromoCodeRepository {
+	return &PromoCodeRepository{db: conn{db}}
+}
+
+// promoCodeColumns are the columns scanned by scanPromoCode
+const promoCodeColumns = `id, code, COALESCE(description, ''), kind, bonus_credits, discount_percent, discount_periods,
+	       max_redemptions, redemption_count, starts_at, expires_at, deactivated_at, created_by, created_at`
+
+// scanPromoCode scans a row of promoCodeColumns
+func scanPromoCode(row pgx.Row) (*domain.PromoCode, error) {
+	var p domain.PromoCode
+	if err := row.Scan(
+		&p.ID, &p.Code, &p.Description, &p.Kind, &p.BonusCredits, &p.DiscountPercent, &p.DiscountPeriods,
+		&p.MaxRedemptions, &p.Redemptions, &p.StartsAt, &p.ExpiresAt, &p.DeactivatedAt, &p.CreatedBy, &p.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// redemptionSelect reads redemptions with their code for scanRedemption
+const redemptionSelect = `
+	SELECT r.id, r.promo_code_id, p.code, p.kind, r.office_id, r.user_id, r.bonus_credits, r.credit_transaction_id,
+	       r.discount_percent, r.discount_periods_left, r.created_at
+	FROM promo_redemptions r
+	JOIN promo_codes p ON p.id = r.promo_code_id`
+
+// scanRedemption scans a row of redemptionSelect
+func scanRedemption(row pgx.Row) (*domain.PromoRedemption, error) {
+	var r domain.PromoRedemption
+	if err := row.Scan(
+		&r.ID, &r.PromoCodeID, &r.Code, &r.Kind, &r.OfficeID, &r.UserID, &r.BonusCredits, &r.CreditTransactionID,
+		&r.DiscountPercent, &r.DiscountPeriodsLeft, &r.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Create inserts a promo code, returning domain.ErrAlreadyExists if the code
+// is taken
+func (r *PromoCodeRepository) Create(ctx context.Context, code *domain.PromoCode) error {
+	query := `
+		INSERT INTO promo_codes (id, code, description, kind, bonus_credits, discount_percent, discount_periods,
+		                         max_redemptions, starts_at, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		code.ID, code.Code, nullableString(code.Description), code.Kind, code.BonusCredits,
+		code.DiscountPercent, code.DiscountPeriods, code.MaxRedemptions, code.StartsAt, code.ExpiresAt,
+		code.CreatedBy, code.CreatedAt,
+	).Scan(&code.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID retrieves a single promo code
+func (r *PromoCodeRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.PromoCode, error) {
+	code, err := scanPromoCode(r.db.QueryRow(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return code, err
+}
+
+// GetByCode retrieves a promo code by the code users enter
+func (r *PromoCodeRepository) GetByCode(ctx context.Context, code string) (*domain.PromoCode, error) {
+	promo, err := scanPromoCode(r.db.QueryRow(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes WHERE code = $1`, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return promo, err
+}
+
+// List retrieves a page of the promo codes, newest first, and how many
+// there are
+func (r *PromoCodeRepository) List(ctx context.Context, limit, offset int) ([]*domain.PromoCode, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM promo_codes`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	codes := []*domain.PromoCode{}
+	for rows.Next() {
+		code, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, total, rows.Err()
+}
+
+// Deactivate ends a promo code's campaign
+func (r *PromoCodeRepository) Deactivate(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `UPDATE promo_codes SET deactivated_at = NOW() WHERE id = $1 AND deactivated_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// Redeem counts a redemption against its code and records it in one
+// transaction. Counting first locks the code's row, so concurrent
+// redemptions cannot exceed its limit.
+func (r *PromoCodeRepository) Redeem(ctx context.Context, redemption *domain.PromoRedemption) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	counted := `
+		UPDATE promo_codes SET redemption_count = redemption_count + 1
+		WHERE id = $1 AND deactivated_at IS NULL
+		  AND (starts_at IS NULL OR starts_at <= NOW())
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_redemptions IS NULL OR redemption_count < max_redemptions)
+	`
+	tag, err := tx.Exec(ctx, counted, redemption.PromoCodeID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	query := `
+		INSERT INTO promo_redemptions (id, promo_code_id, office_id, user_id, bonus_credits, credit_transaction_id,
+		                               discount_percent, discount_periods_left, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`
+	err = tx.QueryRow(ctx, query,
+		redemption.ID, redemption.PromoCodeID, redemption.OfficeID, redemption.UserID, redemption.BonusCredits,
+		redemption.CreditTransactionID, redemption.DiscountPercent, redemption.DiscountPeriodsLeft, redemption.CreatedAt,
+	).Scan(&redemption.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListRedemptions retrieves a page of a code's redemptions, newest first,
+// and how many there are
+func (r *PromoCodeRepository) ListRedemptions(
+	ctx context.Context,
+	codeID uuid.UUID,
+	limit, offset int,
+) ([]*domain.PromoRedemption, int, error) {
+	var total int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM promo_redemptions WHERE promo_code_id = $1`, codeID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := redemptionSelect + ` WHERE r.promo_code_id = $1 ORDER BY r.created_at DESC LIMIT $2 OFFSET $3`
+	redemptions, err := r.queryRedemptions(ctx, query, codeID, limit, offset)
+	return redemptions, total, err
+}
+
+// GetByOfficeID retrieves an office's redemptions, newest first
+func (r *PromoCodeRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.PromoRedemption, error) {
+	return r.queryRedemptions(ctx, redemptionSelect+` WHERE r.office_id = $1 ORDER BY r.created_at DESC`, officeID)
+}
+
+// GetActiveDiscount retrieves the office's oldest redeemed discount that
+// still has invoices to discount
+func (r *PromoCodeRepository) GetActiveDiscount(ctx context.Context, officeID uuid.UUID) (*domain.PromoRedemption, error) {
+	query := redemptionSelect + `
+		WHERE r.office_id = $1 AND r.discount_periods_left > 0
+		ORDER BY r.created_at ASC
+		LIMIT 1`
+	redemption, err := scanRedemption(r.db.QueryRow(ctx, query, officeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return redemption, err
+}
+
+// UseDiscountPeriod counts one invoice against a redeemed discount
+func (r *PromoCodeRepository) UseDiscountPeriod(ctx context.Context, redemptionID uuid.UUID) error {
+	query := `UPDATE promo_redemptions SET discount_periods_left = discount_periods_left - 1
+	          WHERE id = $1 AND discount_periods_left > 0`
+	tag, err := r.db.Exec(ctx, query, redemptionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// queryRedemptions runs a redemptionSelect query
+func (r *PromoCodeRepository) queryRedemptions(ctx context.Context, query string, args ...any) ([]*domain.PromoRedemption, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []*domain.PromoRedemption{}
+	for rows.Next() {
+		redemption, err := scanRedemption(rows)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	return redemptions, rows.Err()
+}
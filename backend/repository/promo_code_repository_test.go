@@ -0,0 +1,87 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestPromoRedemptionsAreLimited(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewPromoCodeRepository(testDB.Pool)
+	admin := testDB.User(t)
+	limit := 2
+	code := &domain.PromoCode{
+		ID: uuid.New(), Code: "SPRING-" + uuid.NewString()[:8], Kind: domain.PromoKindSubscriptionDiscount,
+		DiscountPercent: 25, DiscountPeriods: 2, MaxRedemptions: &limit, CreatedBy: &admin, CreatedAt: time.Now(),
+	}
+	if err := repo.Create(ctx, code); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	duplicate := *code
+	duplicate.ID = uuid.New()
+	if err := repo.Create(ctx, &duplicate); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create duplicate code error = %v, want ErrAlreadyExists", err)
+	}
+
+	redeem := func(office, user uuid.UUID) error {
+		return repo.Redeem(ctx, &domain.PromoRedemption{
+			ID: uuid.New(), PromoCodeID: code.ID, OfficeID: office, UserID: &user,
+			DiscountPercent: 25, DiscountPeriodsLeft: 2, CreatedAt: time.Now(),
+		})
+	}
+	user := testDB.User(t)
+	office := testDB.Office(t, user)
+	if err := redeem(office, user); err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	// Neither the office nor the user can redeem it again
+	if err := redeem(office, testDB.User(t)); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Redeem for the same office error = %v, want ErrAlreadyExists", err)
+	}
+	if err := redeem(testDB.Office(t, user), user); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Redeem by the same user error = %v, want ErrAlreadyExists", err)
+	}
+	// The refused redemptions did not count against the limit
+	other := testDB.User(t)
+	if err := redeem(testDB.Office(t, other), other); err != nil {
+		t.Fatalf("Redeem second office: %v", err)
+	}
+	last := testDB.User(t)
+	if err := redeem(testDB.Office(t, last), last); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Redeem over the limit error = %v, want ErrNotFound", err)
+	}
+	if got, err := repo.GetByID(ctx, code.ID); err != nil || got.Redemptions != 2 {
+		t.Errorf("GetByID = %+v, %v; want 2 redemptions", got, err)
+	}
+
+	discount, err := repo.GetActiveDiscount(ctx, office)
+	if err != nil || discount.Code != code.Code || discount.DiscountPeriodsLeft != 2 {
+		t.Fatalf("GetActiveDiscount = %+v, %v; want the redeemed discount", discount, err)
+	}
+	for range 2 {
+		if err := repo.UseDiscountPeriod(ctx, discount.ID); err != nil {
+			t.Fatalf("UseDiscountPeriod: %v", err)
+		}
+	}
+	if _, err := repo.GetActiveDiscount(ctx, office); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetActiveDiscount after its periods error = %v, want ErrNotFound", err)
+	}
+	if redemptions, err := repo.GetByOfficeID(ctx, office); err != nil || len(redemptions) != 1 {
+		t.Errorf("GetByOfficeID = %d redemptions, %v; want the office's one", len(redemptions), err)
+	}
+
+	if err := repo.Deactivate(ctx, code.ID); err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	if err := repo.Deactivate(ctx, code.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Deactivate again error = %v, want ErrNotFound", err)
+	}
+}
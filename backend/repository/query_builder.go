@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+)
+
+// queryBuilder accumulates AND-ed WHERE conditions and their positional
+// arguments so optional filters can be composed without hand-numbering
+// $n placeholders.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg registers a query argument and returns its placeholder
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// where adds a condition; build placeholders for it with arg
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// whereClause renders the accumulated conditions, or an empty string if there are none
+func (b *queryBuilder) whereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// likeEscaper escapes the wildcards of ILIKE patterns, with \ as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containing returns an ILIKE pattern matching text that contains s literally.
+// Conditions using it must declare ESCAPE '\'.
+func containing(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RefundRequestRepository implements domain.RefundRequestRepository
+type RefundRequestRepository struct {
+	db conn
+}
+
+// NewRefundRequestRepository creates a new RefundRequestRepository
+func NewRefundRequestRepository(db *pgxpool.Pool) *RefundRequestRepository {
+	return &RefundRequestRepository{db: conn{db}}
+}
+
+// refundRequestSelect reads requests with their purchase and template for
+// scanRefundRequest
+const refundRequestSelect = `
+	SELECT r.id, r.purchase_id, r.office_id, r.requested_by, r.reason, r.status,
+	       COALESCE(r.rejection_reason, ''), COALESCE(r.stripe_refund_id, ''), r.reviewed_by,
+	       r.created_at, r.resolved_at,
+	       p.template_id, t.name, p.price_cents, COALESCE(p.price_credits, 0), p.earning_id
+	FROM purchase_refund_requests r
+	JOIN template_purchases p ON p.id = r.purchase_id
+	JOIN agent_templates t ON t.id = p.template_id`
+
+// scanRefundRequest scans a row of refundRequestSelect
+func scanRefundRequest(row pgx.Row) (*domain.RefundRequest, error) {
+	var r domain.RefundRequest
+	if err := row.Scan(
+		&r.ID, &r.PurchaseID, &r.OfficeID, &r.RequestedBy, &r.Reason, &r.Status,
+		&r.RejectionReason, &r.StripeRefundID, &r.ReviewedBy,
+		&r.CreatedAt, &r.ResolvedAt,
+		&r.TemplateID, &r.TemplateName, &r.PriceCents, &r.PriceCredits, &r.EarningID,
+	); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Create opens a refund request for a purchase, returning
+// domain.ErrAlreadyExists if the purchase already has an open one
+func (r *RefundRequestRepository) Create(ctx context.Context, request *domain.RefundRequest) error {
+	query := `
+		INSERT INTO purchase_refund_requests (id, purchase_id, office_id, requested_by, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (purchase_id) WHERE status = 'pending' DO NOTHING
+		RETURNING id
+	`
+	err := r.db.QueryRow(ctx, query,
+		request.ID, request.PurchaseID, request.OfficeID, request.RequestedBy,
+		request.Reason, request.Status, request.CreatedAt,
+	).Scan(&request.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID retrieves a single refund request
+func (r *RefundRequestRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.RefundRequest, error) {
+	request, err := scanRefundRequest(r.db.QueryRow(ctx, refundRequestSelect+` WHERE r.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return request, err
+}
+
+// List retrieves a page of every office's refund requests, oldest first so
+// the queue is worked in order, and how many there are. An empty status
+// lists them all.
+func (r *RefundRequestRepository) List(
+	ctx context.Context,
+	status domain.RefundRequestStatus,
+	limit, offset int,
+) ([]*domain.RefundRequest, int, error) {
+	q := &queryBuilder{}
+	if status != "" {
+		q.where("r.status = " + q.arg(status))
+	}
+
+	var total int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM purchase_refund_requests r`+q.whereClause(), q.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := refundRequestSelect + q.whereClause() + `
+		ORDER BY r.created_at ASC
+		LIMIT ` + q.arg(limit) + ` OFFSET ` + q.arg(offset)
+	requests, err := r.query(ctx, query, q.args...)
+	return requests, total, err
+}
+
+// GetByOfficeID retrieves an office's refund requests, newest first
+func (r *RefundRequestRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.RefundRequest, error) {
+	return r.query(ctx, refundRequestSelect+` WHERE r.office_id = $1 ORDER BY r.created_at DESC`, officeID)
+}
+
+// Resolve records the status, rejection reason, Stripe refund and reviewer
+// of a pending request
+func (r *RefundRequestRepository) Resolve(ctx context.Context, request *domain.RefundRequest) error {
+	query := `
+		UPDATE purchase_refund_requests
+		SET status = $2, rejection_reason = NULLIF($3, ''), stripe_refund_id = NULLIF($4, ''),
+		    reviewed_by = $5, resolved_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING resolved_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		request.ID, request.Status, request.RejectionReason, request.StripeRefundID, request.ReviewedBy,
+	).Scan(&request.ResolvedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// query runs a refundRequestSelect query
+func (r *RefundRequestRepository) query(ctx context.Context, query string, args ...any) ([]*domain.RefundRequest, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*domain.RefundRequest{}
+	for rows.Next() {
+		request, err := scanRefundRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
@@ -0,0 +1,103 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestRefundReversesSaleAndRevokesPurchase(t *testing.T) {
+	ctx := context.Background()
+	earnings := repository.NewEarningsRepository(testDB.Pool)
+	purchases := repository.NewTemplatePurchaseRepository(testDB.Pool)
+	refunds := repository.NewRefundRequestRepository(testDB.Pool)
+	author := testDB.User(t)
+	template := testDB.Template(t, author)
+	buyer := testDB.User(t)
+	office := testDB.Office(t, buyer)
+
+	earningID, err := earnings.RecordSale(ctx, author, template, buyer, office, 1500, "pi_123")
+	if err != nil {
+		t.Fatalf("RecordSale: %v", err)
+	}
+	purchase := &domain.TemplatePurchase{
+		ID: uuid.New(), OfficeID: office, TemplateID: template, PurchasedBy: buyer, EarningID: &earningID,
+		PriceCents: 1500, Status: domain.TemplatePurchaseStatusActive, CreatedAt: time.Now(),
+	}
+	if err := purchases.Create(ctx, purchase); err != nil {
+		t.Fatalf("Create purchase: %v", err)
+	}
+
+	request := &domain.RefundRequest{
+		ID: uuid.New(), PurchaseID: purchase.ID, OfficeID: office, RequestedBy: &buyer,
+		Reason: "Not what was described", Status: domain.RefundRequestStatusPending, CreatedAt: time.Now(),
+	}
+	if err := refunds.Create(ctx, request); err != nil {
+		t.Fatalf("Create refund request: %v", err)
+	}
+	again := *request
+	again.ID = uuid.New()
+	if err := refunds.Create(ctx, &again); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Create second open request error = %v, want ErrAlreadyExists", err)
+	}
+
+	reversal, err := earnings.ReverseSale(ctx, earningID)
+	if err != nil {
+		t.Fatalf("ReverseSale: %v", err)
+	}
+	if reversal.SaleAmountCents != -1500 || reversal.RefundOf == nil || *reversal.RefundOf != earningID || reversal.Status != "refund" {
+		t.Errorf("reversal = %+v, want the negated sale referring to it", reversal)
+	}
+	if _, err := earnings.ReverseSale(ctx, earningID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ReverseSale again error = %v, want ErrNotFound", err)
+	}
+	balance, err := earnings.GetAuthorBalance(ctx, author)
+	if err != nil || balance.TotalEarnedCents != 0 {
+		t.Errorf("GetAuthorBalance = %+v, %v; want the author's share taken back", balance, err)
+	}
+
+	if err := purchases.Revoke(ctx, purchase.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := purchases.Revoke(ctx, purchase.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Revoke again error = %v, want ErrNotFound", err)
+	}
+	if owned, err := purchases.HasPurchased(ctx, office, template); err != nil || owned {
+		t.Errorf("HasPurchased = %v, %v; want the template revoked", owned, err)
+	}
+
+	request.Status = domain.RefundRequestStatusRefunded
+	request.StripeRefundID = "re_123"
+	if err := refunds.Resolve(ctx, request); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if err := refunds.Resolve(ctx, request); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Resolve again error = %v, want ErrNotFound", err)
+	}
+	got, err := refunds.GetByID(ctx, request.ID)
+	if err != nil || got.Status != domain.RefundRequestStatusRefunded || got.StripeRefundID != "re_123" ||
+		got.ResolvedAt == nil || got.PriceCents != 1500 || got.TemplateID != template {
+		t.Errorf("GetByID = %+v, %v; want the refunded request with its purchase", got, err)
+	}
+
+	// The template can be bought again, this time with credits
+	rebought := *purchase
+	rebought.ID = uuid.New()
+	rebought.PriceCredits = 7500
+	if err := purchases.Create(ctx, &rebought); err != nil {
+		t.Fatalf("Create purchase after refund: %v", err)
+	}
+	if got, err := purchases.GetByID(ctx, rebought.ID); err != nil || got.PriceCredits != 7500 || got.Status != domain.TemplatePurchaseStatusActive {
+		t.Errorf("GetByID = %+v, %v; want the active purchase paid with 7500 credits", got, err)
+	}
+	if owned, err := purchases.HasPurchased(ctx, office, template); err != nil || !owned {
+		t.Errorf("HasPurchased = %v, %v; want the template bought again", owned, err)
+	}
+}
@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RetentionRepository implements domain.RetentionRepository
+type RetentionRepository struct {
+	db conn
+}
+
+// NewRetentionRepository creates a new RetentionRepository
+func NewRetentionRepository(db *pgxpool.Pool) *RetentionRepository {
+	return &RetentionRepository{db: conn{db}}
+}
+
+const retentionRunColumns = `id, dry_run, exempt_entities, offices_purged,
+	messages_deleted, attachments_deleted, tasks_deleted, usage_rows_deleted,
+	conversations_deleted, agents_deleted, started_at, finished_at`
+
+const retentionPurgeColumns = `id, run_id, office_id, tier, retention_days, cutoff,
+	messages_deleted, attachments_deleted, tasks_deleted, usage_rows_deleted,
+	conversations_deleted, agents_deleted, created_at`
+
+// purgeableMessages selects the office's ($1) messages created before the
+// cutoff ($2). Replies are deleted with their parent, so a message with
+// replies after the cutoff is kept.
+const purgeableMessages = `
+	SELECT m.id FROM messages m
+	WHERE m.office_id = $1 AND m.created_at < $2
+		AND NOT EXISTS (
+			SELECT 1 FROM messages r
+			WHERE r.parent_message_id = m.id AND r.created_at >= $2
+		)`
+
+// purgeableConversations selects the office's ($1) conversations deleted
+// before the cutoff ($2), or all of them if the office was
+const purgeableConversations = `
+	SELECT c.id FROM conversations c JOIN offices o ON o.id = c.office_id
+	WHERE c.office_id = $1 AND (c.deleted_at < $2 OR o.deleted_at < $2)`
+
+// purgeableAgents selects the office's ($1) agents deleted before the
+// cutoff ($2), or all of them if the office was. Deleting an agent deletes
+// its tasks, which hold what they were charged, and usage rows refer to
+// it, so agents are kept until retention has purged those.
+const purgeableAgents = `
+	SELECT a.id FROM agents a JOIN offices o ON o.id = a.office_id
+	WHERE a.office_id = $1 AND (a.deleted_at < $2 OR o.deleted_at < $2)
+		AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.agent_id = a.id)
+		AND NOT EXISTS (SELECT 1 FROM usage_by_agent u WHERE u.agent_id = a.id)`
+
+// purgeableTaskStatuses are the statuses of tasks that are no longer running
+var purgeableTaskStatuses = []string{
+	string(domain.TaskStatusDone),
+	string(domain.TaskStatusFailed),
+	string(domain.TaskStatusDeadLetter),
+	string(domain.TaskStatusCancelled),
+}
+
+// usagePurges delete the office's ($1) usage rollups from before the
+// cutoff ($2)
+var usagePurges = []string{
+	`DELETE FROM usage_daily WHERE office_id = $1 AND date < $2::date`,
+	`DELETE FROM usage_by_model WHERE office_id = $1 AND date < $2::date`,
+	`DELETE FROM usage_by_agent WHERE office_id = $1 AND date < $2::date`,
+	`DELETE FROM credit_usage_hourly
+		WHERE wallet_id IN (SELECT id FROM credit_wallets WHERE office_id = $1) AND hour_start < $2`,
+}
+
+// ListOffices returns the IDs of offices on tier, in ID order after afterID
+func (r *RetentionRepository) ListOffices(ctx context.Context, tier domain.SubscriptionTier, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT o.id FROM offices o
+		LEFT JOIN subscriptions s ON s.office_id = o.id
+		WHERE COALESCE(s.tier, $1) = $2 AND o.id > $3
+		ORDER BY o.id
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(ctx, query, domain.TierSolo, tier, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge deletes the office's old data in one transaction. A dry run makes
+// the same deletions and rolls them back, so its counts are exact.
+func (r *RetentionRepository) Purge(ctx context.Context, officeID uuid.UUID, cutoff time.Time, entities []domain.RetentionEntity, dryRun bool) (domain.RetentionCounts, []string, error) {
+	var counts domain.RetentionCounts
+	var storageKeys []string
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return counts, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if slices.Contains(entities, domain.RetentionEntityTasks) {
+		// Delegated sub-tasks go with their tree, so a tree is only purged
+		// once all of it is old and finished
+		tag, err := tx.Exec(ctx, `
+			DELETE FROM tasks t
+			WHERE t.office_id = $1 AND t.created_at < $2 AND t.status = ANY($3)
+				AND NOT EXISTS (
+					SELECT 1 FROM tasks o
+					WHERE o.office_id = t.office_id
+						AND COALESCE(o.root_task_id, o.id) = COALESCE(t.root_task_id, t.id)
+						AND (o.created_at >= $2 OR o.status <> ALL($3))
+				)
+		`, officeID, cutoff, purgeableTaskStatuses)
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Tasks = tag.RowsAffected()
+	}
+
+	if slices.Contains(entities, domain.RetentionEntityMessages) {
+		// Attachments would go with their messages anyway; deleting them
+		// first returns the contents to remove from storage
+		rows, err := tx.Query(ctx, `
+			DELETE FROM message_attachments
+			WHERE message_id IN (`+purgeableMessages+`)
+			RETURNING storage_key
+		`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		storageKeys, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Attachments = int64(len(storageKeys))
+
+		tag, err := tx.Exec(ctx, `DELETE FROM messages WHERE id IN (`+purgeableMessages+`)`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Messages = tag.RowsAffected()
+	}
+
+	if slices.Contains(entities, domain.RetentionEntityUsage) {
+		for _, query := range usagePurges {
+			tag, err := tx.Exec(ctx, query, officeID, cutoff)
+			if err != nil {
+				return counts, nil, err
+			}
+			counts.UsageRows += tag.RowsAffected()
+		}
+	}
+
+	if slices.Contains(entities, domain.RetentionEntityDeleted) {
+		// Messages and attachments would go with their conversations too
+		rows, err := tx.Query(ctx, `
+			DELETE FROM message_attachments
+			WHERE conversation_id IN (`+purgeableConversations+`)
+			RETURNING storage_key
+		`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return counts, nil, err
+		}
+		storageKeys = append(storageKeys, keys...)
+		counts.Attachments += int64(len(keys))
+
+		tag, err := tx.Exec(ctx, `DELETE FROM messages WHERE conversation_id IN (`+purgeableConversations+`)`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Messages += tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `DELETE FROM conversations WHERE id IN (`+purgeableConversations+`)`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Conversations = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `DELETE FROM agents WHERE id IN (`+purgeableAgents+`)`, officeID, cutoff)
+		if err != nil {
+			return counts, nil, err
+		}
+		counts.Agents = tag.RowsAffected()
+	}
+
+	if dryRun {
+		return counts, nil, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.RetentionCounts{}, nil, err
+	}
+	return counts, storageKeys, nil
+}
+
+// CreateRun records the start of a retention run
+func (r *RetentionRepository) CreateRun(ctx context.Context, run *domain.RetentionRun) error {
+	query := `
+		INSERT INTO retention_runs (id, dry_run, exempt_entities, started_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.Exec(ctx, query, run.ID, run.DryRun, retentionEntityNames(run.Exempt), run.StartedAt)
+	return err
+}
+
+// FinishRun records the run's totals and when it finished
+func (r *RetentionRepository) FinishRun(ctx context.Context, run *domain.RetentionRun) error {
+	query := `
+		UPDATE retention_runs SET offices_purged = $2, messages_deleted = $3, attachments_deleted = $4,
+			tasks_deleted = $5, usage_rows_deleted = $6, conversations_deleted = $7, agents_deleted = $8, finished_at = $9
+		WHERE id = $1
+	`
+	tag, err := r.db.Exec(ctx, query, run.ID, run.OfficesPurged, run.Deleted.Messages, run.Deleted.Attachments,
+		run.Deleted.Tasks, run.Deleted.UsageRows, run.Deleted.Conversations, run.Deleted.Agents, run.FinishedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// CreatePurge records what a run purged from an office
+func (r *RetentionRepository) CreatePurge(ctx context.Context, purge *domain.RetentionPurge) error {
+	query := `
+		INSERT INTO retention_purges (` + retentionPurgeColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	_, err := r.db.Exec(ctx, query, purge.ID, purge.RunID, purge.OfficeID, purge.Tier, purge.RetentionDays, purge.Cutoff,
+		purge.Deleted.Messages, purge.Deleted.Attachments, purge.Deleted.Tasks, purge.Deleted.UsageRows,
+		purge.Deleted.Conversations, purge.Deleted.Agents, purge.CreatedAt)
+	return err
+}
+
+// GetRun retrieves a retention run by ID
+func (r *RetentionRepository) GetRun(ctx context.Context, id uuid.UUID) (*domain.RetentionRun, error) {
+	query := `SELECT ` + retentionRunColumns + ` FROM retention_runs WHERE id = $1`
+
+	run, err := scanRetentionRun(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return run, err
+}
+
+// GetLatestRun returns the most recently started retention run
+func (r *RetentionRepository) GetLatestRun(ctx context.Context) (*domain.RetentionRun, error) {
+	query := `SELECT ` + retentionRunColumns + ` FROM retention_runs ORDER BY started_at DESC LIMIT 1`
+
+	run, err := scanRetentionRun(r.db.QueryRow(ctx, query))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return run, err
+}
+
+// ListRuns returns a page of retention runs, most recent first, and the
+// total number of runs
+func (r *RetentionRepository) ListRuns(ctx context.Context, limit, offset int) ([]*domain.RetentionRun, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM retention_runs`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + retentionRunColumns + ` FROM retention_runs
+		ORDER BY started_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var runs []*domain.RetentionRun
+	for rows.Next() {
+		run, err := scanRetentionRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, total, rows.Err()
+}
+
+// ListPurges returns what a run purged from each office
+func (r *RetentionRepository) ListPurges(ctx context.Context, runID uuid.UUID) ([]*domain.RetentionPurge, error) {
+	query := `SELECT ` + retentionPurgeColumns + ` FROM retention_purges
+		WHERE run_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := r.db.Query(ctx, query, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purges []*domain.RetentionPurge
+	for rows.Next() {
+		var purge domain.RetentionPurge
+		err := rows.Scan(
+			&purge.ID, &purge.RunID, &purge.OfficeID, &purge.Tier, &purge.RetentionDays, &purge.Cutoff,
+			&purge.Deleted.Messages, &purge.Deleted.Attachments, &purge.Deleted.Tasks, &purge.Deleted.UsageRows,
+			&purge.Deleted.Conversations, &purge.Deleted.Agents, &purge.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		purges = append(purges, &purge)
+	}
+	return purges, rows.Err()
+}
+
+func scanRetentionRun(row pgx.Row) (*domain.RetentionRun, error) {
+	var run domain.RetentionRun
+	var exempt []string
+	err := row.Scan(
+		&run.ID, &run.DryRun, &exempt, &run.OfficesPurged,
+		&run.Deleted.Messages, &run.Deleted.Attachments, &run.Deleted.Tasks, &run.Deleted.UsageRows,
+		&run.Deleted.Conversations, &run.Deleted.Agents, &run.StartedAt, &run.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	run.Exempt = make([]domain.RetentionEntity, len(exempt))
+	for i, name := range exempt {
+		run.Exempt[i] = domain.RetentionEntity(name)
+	}
+	return &run, nil
+}
+
+func retentionEntityNames(entities []domain.RetentionEntity) []string {
+	names := make([]string, len(entities))
+	for i, entity := range entities {
+		names[i] = string(entity)
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ScheduledTaskRepository implements domain.ScheduledTaskRepository
+type ScheduledTaskRepository struct {
+	db conn
+}
+
+// NewScheduledTaskRepository creates a new ScheduledTaskRepository
+func NewScheduledTaskRepository(db *pgxpool.Pool) *ScheduledTaskRepository {
+	return &ScheduledTaskRepository{db: conn{db}}
+}
+
+const scheduledTaskColumns = `id, office_id, agent_id, conversation_id, name, input, cron_expression, run_at,
+	timezone, is_active, next_run_at, last_run_at, created_at, updated_at, task_template_id`
+
+// Create creates a new scheduled task
+func (r *ScheduledTaskRepository) Create(ctx context.Context, schedule *domain.ScheduledTask) error {
+	query := `
+		INSERT INTO scheduled_tasks (id, office_id, agent_id, conversation_id, name, input, cron_expression, run_at,
+			timezone, is_active, next_run_at, last_run_at, created_at, updated_at, task_template_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+	_, err := r.db.Exec(ctx, query,
+		schedule.ID, schedule.OfficeID, schedule.AgentID, schedule.ConversationID,
+		schedule.Name, schedule.Input, nullableString(schedule.CronExpression), schedule.RunAt,
+		schedule.Timezone, schedule.IsActive, schedule.NextRunAt, schedule.LastRunAt,
+		schedule.CreatedAt, schedule.UpdatedAt, schedule.TaskTemplateID,
+	)
+	return err
+}
+
+// GetByID returns a scheduled task by ID
+func (r *ScheduledTaskRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ScheduledTask, error) {
+	query := `SELECT ` + scheduledTaskColumns + ` FROM scheduled_tasks WHERE id = $1`
+
+	schedule, err := scanScheduledTask(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetByAgentID returns all schedules of an agent, newest first
+func (r *ScheduledTaskRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID) ([]*domain.ScheduledTask, error) {
+	query := `
+		SELECT ` + scheduledTaskColumns + `
+		FROM scheduled_tasks
+		WHERE agent_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledTasks(rows)
+}
+
+// GetByTaskTemplateID returns the schedules filled in from a task template,
+// newest first
+func (r *ScheduledTaskRepository) GetByTaskTemplateID(ctx context.Context, templateID uuid.UUID) ([]*domain.ScheduledTask, error) {
+	query := `
+		SELECT ` + scheduledTaskColumns + `
+		FROM scheduled_tasks
+		WHERE task_template_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledTasks(rows)
+}
+
+// GetDue returns active schedules whose next run is at or before now,
+// earliest first
+func (r *ScheduledTaskRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]*domain.ScheduledTask, error) {
+	query := `
+		SELECT ` + scheduledTaskColumns + `
+		FROM scheduled_tasks
+		WHERE is_active = TRUE AND next_run_at IS NOT NULL AND next_run_at <= $1
+		ORDER BY next_run_at ASC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledTasks(rows)
+}
+
+// Update saves the editable fields of a schedule
+func (r *ScheduledTaskRepository) Update(ctx context.Context, schedule *domain.ScheduledTask) error {
+	query := `
+		UPDATE scheduled_tasks
+		SET conversation_id = $2, name = $3, input = $4, cron_expression = $5, run_at = $6,
+			timezone = $7, is_active = $8, next_run_at = $9
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	err := r.db.QueryRow(ctx, query,
+		schedule.ID, schedule.ConversationID, schedule.Name, schedule.Input,
+		nullableString(schedule.CronExpression), schedule.RunAt,
+		schedule.Timezone, schedule.IsActive, schedule.NextRunAt,
+	).Scan(&schedule.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// ClaimRun atomically records that the schedule's due run has fired and
+// advances it to nextRunAt. A nil nextRunAt deactivates the schedule. It
+// reports false if the run was already claimed or the schedule changed since
+// it was loaded, so concurrent schedulers never fire a run twice.
+func (r *ScheduledTaskRepository) ClaimRun(ctx context.Context, schedule *domain.ScheduledTask, nextRunAt *time.Time) (bool, error) {
+	query := `
+		UPDATE scheduled_tasks
+		SET last_run_at = NOW(), next_run_at = $3, is_active = $3::timestamptz IS NOT NULL
+		WHERE id = $1 AND is_active = TRUE AND next_run_at = $2
+		RETURNING last_run_at, is_active, updated_at
+	`
+	err := r.db.QueryRow(ctx, query, schedule.ID, schedule.NextRunAt, nextRunAt).
+		Scan(&schedule.LastRunAt, &schedule.IsActive, &schedule.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	schedule.NextRunAt = nextRunAt
+	return true, nil
+}
+
+// Delete deletes a schedule and its run history
+func (r *ScheduledTaskRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM scheduled_tasks WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// CreateRun records a firing of a schedule
+func (r *ScheduledTaskRepository) CreateRun(ctx context.Context, run *domain.ScheduledTaskRun) error {
+	query := `
+		INSERT INTO scheduled_task_runs (id, schedule_id, task_id, status, error, scheduled_for, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.Exec(ctx, query,
+		run.ID, run.ScheduleID, run.TaskID, run.Status, nullableString(run.Error),
+		run.ScheduledFor, run.CreatedAt,
+	)
+	return err
+}
+
+// GetRuns returns a schedule's run history, newest first
+func (r *ScheduledTaskRepository) GetRuns(ctx context.Context, scheduleID uuid.UUID, limit, offset int) ([]*domain.ScheduledTaskRun, error) {
+	query := `
+		SELECT id, schedule_id, task_id, status, error, scheduled_for, created_at
+		FROM scheduled_task_runs
+		WHERE schedule_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, scheduleID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*domain.ScheduledTaskRun
+	for rows.Next() {
+		var run domain.ScheduledTaskRun
+		var errMsg *string
+		err := rows.Scan(&run.ID, &run.ScheduleID, &run.TaskID, &run.Status, &errMsg, &run.ScheduledFor, &run.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if errMsg != nil {
+			run.Error = *errMsg
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// CountRuns returns the number of recorded runs of a schedule
+func (r *ScheduledTaskRepository) CountRuns(ctx context.Context, scheduleID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM scheduled_task_runs WHERE schedule_id = $1`, scheduleID).Scan(&count)
+	return count, err
+}
+
+// scanScheduledTask scans a row selected with scheduledTaskColumns
+func scanScheduledTask(row pgx.Row) (*domain.ScheduledTask, error) {
+	var schedule domain.ScheduledTask
+	var cronExpression *string
+
+	err := row.Scan(
+		&schedule.ID, &schedule.OfficeID, &schedule.AgentID, &schedule.ConversationID,
+		&schedule.Name, &schedule.Input, &cronExpression, &schedule.RunAt,
+		&schedule.Timezone, &schedule.IsActive, &schedule.NextRunAt, &schedule.LastRunAt,
+		&schedule.CreatedAt, &schedule.UpdatedAt, &schedule.TaskTemplateID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if cronExpression != nil {
+		schedule.CronExpression = *cronExpression
+	}
+	return &schedule, nil
+}
+
+// scanScheduledTasks scans all rows selected with scheduledTaskColumns
+func scanScheduledTasks(rows pgx.Rows) ([]*domain.ScheduledTask, error) {
+	var schedules []*domain.ScheduledTask
+	for rows.Next() {
+		schedule, err := scanScheduledTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
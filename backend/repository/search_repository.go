@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SearchRepository implements domain.SearchRepository
+type SearchRepository struct {
+	db conn
+}
+
+// NewSearchRepository creates a new SearchRepository
+func NewSearchRepository(db *pgxpool.Pool) *SearchRepository {
+	return &SearchRepository{db: conn{db}}
+}
+
+// searchHeadlineOptions shows up to two fragments of a match, its words
+// delimited by domain.SearchMarkStart and domain.SearchMarkEnd
+const searchHeadlineOptions = `StartSel="` + domain.SearchMarkStart + `", StopSel="` + domain.SearchMarkEnd + `", ` +
+	`MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "`
+
+// Search returns a page of the office's matches, best first. The to_tsvector
+// expressions match the indexes of migration 065.
+func (r *SearchRepository) Search(ctx context.Context, filter domain.SearchFilter, page domain.PageRequest) ([]*domain.SearchResult, error) {
+	q := &queryBuilder{}
+	matches := searchMatches(q, filter)
+	if matches == "" {
+		return nil, nil
+	}
+
+	query := matches + `
+		SELECT type, id, conversation_id, agent_id, title,
+			ts_headline('english', content, q.words, ` + q.arg(searchHeadlineOptions) + `), rank, created_at
+		FROM matches, q
+		ORDER BY rank DESC, created_at DESC, id
+		LIMIT ` + q.arg(page.Limit) + ` OFFSET ` + q.arg(page.Offset)
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.SearchResult
+	for rows.Next() {
+		var result domain.SearchResult
+		var rank float32
+		err := rows.Scan(&result.Type, &result.ID, &result.ConversationID, &result.AgentID, &result.Title,
+			&result.Headline, &rank, &result.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result.Rank = float64(rank)
+		results = append(results, &result)
+	}
+	return results, rows.Err()
+}
+
+// Count returns the number of the office's matches
+func (r *SearchRepository) Count(ctx context.Context, filter domain.SearchFilter) (int, error) {
+	q := &queryBuilder{}
+	matches := searchMatches(q, filter)
+	if matches == "" {
+		return 0, nil
+	}
+
+	var count int
+	err := r.db.QueryRow(ctx, matches+` SELECT COUNT(*) FROM matches`, q.args...).Scan(&count)
+	return count, err
+}
+
+// searchMatches builds the common table expressions q, the parsed query,
+// and matches, the office's content matching it with its rank. Content of
+// deleted conversations, and task outputs and documents of deleted agents,
+// is left out. It returns an empty string when the filter selects no
+// content types.
+func searchMatches(q *queryBuilder, filter domain.SearchFilter) string {
+	office := q.arg(filter.OfficeID)
+	var agent string
+	if filter.AgentID != uuid.Nil {
+		agent = q.arg(filter.AgentID)
+	}
+	includes := func(t domain.SearchResultType) bool {
+		return len(filter.Types) == 0 || slices.Contains(filter.Types, t)
+	}
+
+	var parts []string
+	if includes(domain.SearchResultMessage) {
+		part := `
+			SELECT 'message' AS type, m.id, m.conversation_id,
+				CASE WHEN m.sender_type = 'agent' THEN m.sender_id END AS agent_id, '' AS title, m.content,
+				ts_rank(to_tsvector('english', m.content), q.words) AS rank, m.created_at
+			FROM messages m
+			JOIN conversations c ON c.id = m.conversation_id, q
+			WHERE m.office_id = ` + office + ` AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+				AND to_tsvector('english', m.content) @@ q.words`
+		if agent != "" {
+			part += ` AND m.sender_type = 'agent' AND m.sender_id = ` + agent
+		}
+		parts = append(parts, part)
+	}
+	if includes(domain.SearchResultTask) {
+		part := `
+			SELECT 'task', t.id, t.conversation_id, t.agent_id, '', t.output,
+				ts_rank(to_tsvector('english', t.output), q.words), t.created_at
+			FROM tasks t
+			JOIN agents a ON a.id = t.agent_id
+			LEFT JOIN conversations c ON c.id = t.conversation_id, q
+			WHERE t.office_id = ` + office + ` AND t.output IS NOT NULL
+				AND a.deleted_at IS NULL AND c.deleted_at IS NULL
+				AND to_tsvector('english', t.output) @@ q.words`
+		if agent != "" {
+			part += ` AND t.agent_id = ` + agent
+		}
+		parts = append(parts, part)
+	}
+	if includes(domain.SearchResultDocument) {
+		part := `
+			SELECT 'document', d.id, d.conversation_id, d.agent_id, d.title, d.content,
+				ts_rank(to_tsvector('english', d.title || ' ' || d.content), q.words), d.created_at
+			FROM documents d
+			LEFT JOIN agents a ON a.id = d.agent_id
+			LEFT JOIN conversations c ON c.id = d.conversation_id, q
+			WHERE d.office_id = ` + office + ` AND a.deleted_at IS NULL AND c.deleted_at IS NULL
+				AND to_tsvector('english', d.title || ' ' || d.content) @@ q.words`
+		if agent != "" {
+			part += ` AND d.agent_id = ` + agent
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return `
+		WITH q AS (
+			SELECT websearch_to_tsquery('english', ` + q.arg(filter.Query) + `) AS words
+		), matches AS (` + strings.Join(parts, `
+			UNION ALL`) + `
+		)`
+}
@@ -0,0 +1,81 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestSearchAcrossMessagesTasksAndDocuments(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSearchRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	conversation, agents := newConversation(t, office, testDB.Template(t, testDB.User(t)), time.Now(), 1)
+	marketer := agents[0]
+
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := testDB.Pool.Exec(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", strings.Fields(query)[0], err)
+		}
+	}
+	message, deleted, task, document := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	exec(`INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content) VALUES ($1, $2, $3, 'agent', $4, $5)`,
+		message, office, conversation.ID, marketer, "Here is the launch campaign plan for the spring newsletter")
+	exec(`INSERT INTO messages (id, office_id, conversation_id, sender_type, sender_id, content, deleted_at) VALUES ($1, $2, $3, 'agent', $4, $5, NOW())`,
+		deleted, office, conversation.ID, marketer, "An old campaign idea")
+	exec(`INSERT INTO tasks (id, office_id, conversation_id, agent_id, status, input, output) VALUES ($1, $2, $3, $4, 'done', 'Plan', $5)`,
+		task, office, conversation.ID, marketer, "Campaign budget: two newsletters and one webinar")
+	exec(`INSERT INTO documents (id, office_id, agent_id, title, format, content) VALUES ($1, $2, $3, $4, 'markdown', $5)`,
+		document, office, marketer, "Spring plan", "Goals and channels of the campaign")
+
+	filter := domain.SearchFilter{OfficeID: office, Query: "campaigns"}
+	results, err := repo.Search(ctx, filter, domain.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	found := map[uuid.UUID]domain.SearchResultType{}
+	for _, result := range results {
+		found[result.ID] = result.Type
+		if !strings.Contains(result.Headline, domain.SearchMarkStart) {
+			t.Errorf("headline of %s %q marks no words", result.Type, result.Headline)
+		}
+		if result.AgentID == nil || *result.AgentID != marketer {
+			t.Errorf("%s agent = %v, want %s", result.Type, result.AgentID, marketer)
+		}
+	}
+	want := map[uuid.UUID]domain.SearchResultType{
+		message:  domain.SearchResultMessage,
+		task:     domain.SearchResultTask,
+		document: domain.SearchResultDocument,
+	}
+	if len(found) != len(want) {
+		t.Errorf("Search found %v, want %v", found, want)
+	}
+	for id, typ := range want {
+		if found[id] != typ {
+			t.Errorf("Search found %s as %q, want %q", id, found[id], typ)
+		}
+	}
+	if total, err := repo.Count(ctx, filter); err != nil || total != 3 {
+		t.Errorf("Count = %d, %v; want 3", total, err)
+	}
+
+	// Narrowed to documents and tasks, leaving out those mentioning a webinar
+	filter.Types = []domain.SearchResultType{domain.SearchResultDocument, domain.SearchResultTask}
+	filter.Query = `campaign -webinar`
+	results, err = repo.Search(ctx, filter, domain.PageRequest{Limit: 10})
+	if err != nil || len(results) != 1 || results[0].ID != document {
+		t.Errorf("Search of documents and tasks without webinar = %v, %v; want the document", results, err)
+	}
+	if results, err := repo.Search(ctx, domain.SearchFilter{OfficeID: uuid.New(), Query: "campaign"}, domain.PageRequest{Limit: 10}); err != nil || len(results) != 0 {
+		t.Errorf("Search of another office = %v, %v; want nothing", results, err)
+	}
+}
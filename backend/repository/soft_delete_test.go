@@ -0,0 +1,101 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+)
+
+func TestSoftDeletedAgentsAndConversationsAreRestorable(t *testing.T) {
+	ctx := context.Background()
+	agents := repository.NewAgentRepository(testDB.Pool)
+	conversations := repository.NewConversationRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	templateID := testDB.Template(t, testDB.User(t))
+	conversation, joined := newConversation(t, office, templateID, time.Now(), 2)
+
+	if err := agents.SoftDelete(ctx, joined[0]); err != nil {
+		t.Fatalf("SoftDelete agent: %v", err)
+	}
+	if err := agents.SoftDelete(ctx, joined[0]); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("SoftDelete agent again error = %v, want ErrNotFound", err)
+	}
+	if _, err := agents.GetByID(ctx, joined[0]); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID deleted agent error = %v, want ErrNotFound", err)
+	}
+	if participants, err := conversations.GetParticipants(ctx, conversation.ID); err != nil || len(participants) != 1 {
+		t.Errorf("GetParticipants = %d agents, %v; want the one not deleted", len(participants), err)
+	}
+
+	// Only agents deleted after the grace period's start and of the office
+	// are restored
+	if err := agents.Restore(ctx, office, joined[0], time.Now()); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Restore past the grace period error = %v, want ErrNotFound", err)
+	}
+	if err := agents.Restore(ctx, testDB.Office(t, testDB.User(t)), joined[0], time.Now().Add(-time.Hour)); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Restore from another office error = %v, want ErrNotFound", err)
+	}
+	if err := agents.Restore(ctx, office, joined[0], time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Restore agent: %v", err)
+	}
+	if agent, err := agents.GetByID(ctx, joined[0]); err != nil || !agent.IsActive {
+		t.Errorf("GetByID restored agent = %+v, %v; want it active", agent, err)
+	}
+
+	if err := conversations.SoftDelete(ctx, conversation.ID); err != nil {
+		t.Fatalf("SoftDelete conversation: %v", err)
+	}
+	if listed, err := conversations.GetByOfficeID(ctx, office, true); err != nil || len(listed) != 0 {
+		t.Errorf("GetByOfficeID = %d conversations, %v; want the deleted one left out", len(listed), err)
+	}
+	if err := conversations.Restore(ctx, office, conversation.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Restore conversation: %v", err)
+	}
+	if _, err := conversations.GetByID(ctx, conversation.ID); err != nil {
+		t.Errorf("GetByID restored conversation: %v", err)
+	}
+}
+
+func TestRetentionPurgesDeletedConversationsAndAgents(t *testing.T) {
+	ctx := context.Background()
+	conversations := repository.NewConversationRepository(testDB.Pool)
+	retention := repository.NewRetentionRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	templateID := testDB.Template(t, testDB.User(t))
+	deleted, joined := newConversation(t, office, templateID, time.Now(), 1)
+	kept, _ := newConversation(t, office, templateID, time.Now(), 0)
+
+	if err := conversations.SoftDelete(ctx, deleted.ID); err != nil {
+		t.Fatalf("SoftDelete conversation: %v", err)
+	}
+	if err := repository.NewAgentRepository(testDB.Pool).SoftDelete(ctx, joined[0]); err != nil {
+		t.Fatalf("SoftDelete agent: %v", err)
+	}
+
+	entities := []domain.RetentionEntity{domain.RetentionEntityDeleted}
+	// Nothing was deleted before a cutoff an hour ago
+	counts, _, err := retention.Purge(ctx, office, time.Now().Add(-time.Hour), entities, false)
+	if err != nil || !counts.Empty() {
+		t.Fatalf("Purge before the deletions = %+v, %v; want nothing purged", counts, err)
+	}
+	counts, _, err = retention.Purge(ctx, office, time.Now().Add(time.Minute), entities, true)
+	if err != nil || counts.Conversations != 1 || counts.Agents != 1 {
+		t.Fatalf("dry run Purge = %+v, %v; want the deleted conversation and agent", counts, err)
+	}
+	if _, _, err := retention.Purge(ctx, office, time.Now().Add(time.Minute), entities, false); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	if err := conversations.Restore(ctx, office, deleted.ID, time.Now().Add(-time.Hour)); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Restore purged conversation error = %v, want ErrNotFound", err)
+	}
+	if _, err := conversations.GetByID(ctx, kept.ID); err != nil {
+		t.Errorf("GetByID conversation not deleted: %v", err)
+	}
+}
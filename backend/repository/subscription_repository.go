@@ -2,21 +2,23 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // SubscriptionRepository implements domain.SubscriptionRepository
 type SubscriptionRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewSubscriptionRepository creates a new subscription repository
 func NewSubscriptionRepository(db *pgxpool.Pool) *SubscriptionRepository {
-	return &SubscriptionRepository{db: db}
+	return &SubscriptionRepository{db: conn{db}}
 }
 
 // Create creates a new subscription
@@ -48,101 +50,86 @@ func (r *SubscriptionRepository) Create(ctx context.Context, sub *domain.Subscri
 	return err
 }
 
+const subscriptionColumns = `id, office_id, tier, status, billing_interval,
+	stripe_customer_id, stripe_subscription_id, stripe_price_id,
+	current_period_start, current_period_end, cancel_at_period_end,
+	cancelled_at, trial_start, trial_end, metadata, created_at, updated_at,
+	pending_tier, pending_tier_at`
+
 // GetByID retrieves a subscription by ID
 func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Subscription, error) {
-	query := `
-		SELECT id, office_id, tier, status, billing_interval,
-		       stripe_customer_id, stripe_subscription_id, stripe_price_id,
-		       current_period_start, current_period_end, cancel_at_period_end,
-		       cancelled_at, trial_start, trial_end, metadata, created_at, updated_at
-		FROM subscriptions
-		WHERE id = $1
-	`
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
 
-	var sub domain.Subscription
-	var stripeCustomerID, stripeSubscriptionID, stripePriceID *string
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&sub.ID, &sub.OfficeID, &sub.Tier, &sub.Status, &sub.BillingInterval,
-		&stripeCustomerID, &stripeSubscriptionID, &stripePriceID,
-		&sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CancelAtPeriodEnd,
-		&sub.CancelledAt, &sub.TrialStart, &sub.TrialEnd, &sub.Metadata,
-		&sub.CreatedAt, &sub.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
 	}
+	return sub, err
+}
 
-	// Convert nullable strings
-	if stripeCustomerID != nil {
-		sub.StripeCustomerID = *stripeCustomerID
-	}
-	if stripeSubscriptionID != nil {
-		sub.StripeSubscriptionID = *stripeSubscriptionID
-	}
-	if stripePriceID != nil {
-		sub.StripePriceID = *stripePriceID
+// GetByOfficeID retrieves a subscription by office ID
+func (r *SubscriptionRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) (*domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE office_id = $1`
+
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, officeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
 	}
+	return sub, err
+}
 
-	return &sub, nil
+// GetByStripeID retrieves a subscription by Stripe subscription ID
+func (r *SubscriptionRepository) GetByStripeID(ctx context.Context, stripeID string) (*domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE stripe_subscription_id = $1`
+
+	sub, err := scanSubscription(r.db.QueryRow(ctx, query, stripeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return sub, err
 }
 
-// GetByOfficeID retrieves a subscription by office ID
-func (r *SubscriptionRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) (*domain.Subscription, error) {
+// GetDueTierChanges returns subscriptions whose pending tier change is due
+// at or before now, earliest first
+func (r *SubscriptionRepository) GetDueTierChanges(ctx context.Context, now time.Time, limit int) ([]*domain.Subscription, error) {
 	query := `
-		SELECT id, office_id, tier, status, billing_interval,
-		       stripe_customer_id, stripe_subscription_id, stripe_price_id,
-		       current_period_start, current_period_end, cancel_at_period_end,
-		       cancelled_at, trial_start, trial_end, metadata, created_at, updated_at
+		SELECT ` + subscriptionColumns + `
 		FROM subscriptions
-		WHERE office_id = $1
+		WHERE pending_tier IS NOT NULL AND pending_tier_at <= $1
+		ORDER BY pending_tier_at ASC
+		LIMIT $2
 	`
 
-	var sub domain.Subscription
-	var stripeCustomerID, stripeSubscriptionID, stripePriceID *string
-	err := r.db.QueryRow(ctx, query, officeID).Scan(
-		&sub.ID, &sub.OfficeID, &sub.Tier, &sub.Status, &sub.BillingInterval,
-		&stripeCustomerID, &stripeSubscriptionID, &stripePriceID,
-		&sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CancelAtPeriodEnd,
-		&sub.CancelledAt, &sub.TrialStart, &sub.TrialEnd, &sub.Metadata,
-		&sub.CreatedAt, &sub.UpdatedAt,
-	)
+	rows, err := r.db.Query(ctx, query, now, limit)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Convert nullable strings
-	if stripeCustomerID != nil {
-		sub.StripeCustomerID = *stripeCustomerID
-	}
-	if stripeSubscriptionID != nil {
-		sub.StripeSubscriptionID = *stripeSubscriptionID
-	}
-	if stripePriceID != nil {
-		sub.StripePriceID = *stripePriceID
-	}
-
-	return &sub, nil
+	return scanSubscriptions(rows)
 }
 
-// GetByStripeID retrieves a subscription by Stripe subscription ID
-func (r *SubscriptionRepository) GetByStripeID(ctx context.Context, stripeID string) (*domain.Subscription, error) {
-	query := `
-		SELECT id, office_id, tier, status, billing_interval,
-		       stripe_customer_id, stripe_subscription_id, stripe_price_id,
-		       current_period_start, current_period_end, cancel_at_period_end,
-		       cancelled_at, trial_start, trial_end, metadata, created_at, updated_at
-		FROM subscriptions
-		WHERE stripe_subscription_id = $1
-	`
+func scanSubscriptions(rows pgx.Rows) ([]*domain.Subscription, error) {
+	var subs []*domain.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
 
+func scanSubscription(row pgx.Row) (*domain.Subscription, error) {
 	var sub domain.Subscription
 	var stripeCustomerID, stripeSubscriptionID, stripePriceID *string
-	err := r.db.QueryRow(ctx, query, stripeID).Scan(
+	err := row.Scan(
 		&sub.ID, &sub.OfficeID, &sub.Tier, &sub.Status, &sub.BillingInterval,
 		&stripeCustomerID, &stripeSubscriptionID, &stripePriceID,
 		&sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CancelAtPeriodEnd,
 		&sub.CancelledAt, &sub.TrialStart, &sub.TrialEnd, &sub.Metadata,
-		&sub.CreatedAt, &sub.UpdatedAt,
+		&sub.CreatedAt, &sub.UpdatedAt, &sub.PendingTier, &sub.PendingTierAt,
 	)
 	if err != nil {
 		return nil, err
@@ -196,6 +183,210 @@ func (r *SubscriptionRepository) UpdateTier(ctx context.Context, id uuid.UUID, t
 	return err
 }
 
+// ScheduleTierChange sets the tier the subscription moves to at the given
+// time, replacing any change already pending
+func (r *SubscriptionRepository) ScheduleTierChange(ctx context.Context, id uuid.UUID, tier domain.SubscriptionTier, at time.Time) error {
+	query := `UPDATE subscriptions SET pending_tier = $2, pending_tier_at = $3, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, tier, at)
+	return err
+}
+
+// ClearTierChange cancels the subscription's pending tier change. It returns
+// ErrNotFound if none is pending.
+func (r *SubscriptionRepository) ClearTierChange(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE subscriptions SET pending_tier = NULL, pending_tier_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND pending_tier IS NOT NULL
+	`
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ApplyTierChange moves the subscription to its pending tier if that is
+// still tier, so a change cancelled or replaced in the meantime is left
+// alone. It returns ErrNotFound if the change is no longer pending.
+func (r *SubscriptionRepository) ApplyTierChange(ctx context.Context, id uuid.UUID, tier domain.SubscriptionTier) error {
+	query := `
+		UPDATE subscriptions SET tier = pending_tier, pending_tier = NULL, pending_tier_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND pending_tier = $2
+	`
+	result, err := r.db.Exec(ctx, query, id, tier)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SetCancelAtPeriodEnd sets whether the subscription stops at the end of
+// its current period
+func (r *SubscriptionRepository) SetCancelAtPeriodEnd(ctx context.Context, id uuid.UUID, cancel bool) error {
+	query := `UPDATE subscriptions SET cancel_at_period_end = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, cancel)
+	return err
+}
+
+// Cancel marks the subscription cancelled as of cancelledAt, dropping any
+// pending tier change
+func (r *SubscriptionRepository) Cancel(ctx context.Context, id uuid.UUID, cancelledAt time.Time) error {
+	query := `
+		UPDATE subscriptions SET status = $2, cancelled_at = $3, pending_tier = NULL, pending_tier_at = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, domain.SubscriptionStatusCancelled, cancelledAt)
+	return err
+}
+
+// GetDueCancellations returns subscriptions set to cancel at the end of a
+// period that has ended by now, earliest first
+func (r *SubscriptionRepository) GetDueCancellations(ctx context.Context, now time.Time, limit int) ([]*domain.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE cancel_at_period_end = TRUE AND status <> $2 AND current_period_end <= $1
+		ORDER BY current_period_end ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, now, domain.SubscriptionStatusCancelled, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// StartTrial puts the subscription on a trial of tier from start to end
+func (r *SubscriptionRepository) StartTrial(ctx context.Context, id uuid.UUID, tier domain.SubscriptionTier, start, end time.Time) error {
+	query := `
+		UPDATE subscriptions SET tier = $2, status = $3, trial_start = $4, trial_end = $5, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, tier, domain.SubscriptionStatusTrialing, start, end)
+	return err
+}
+
+// EndTrial moves a trialing subscription to tier and status. It returns
+// ErrNotFound if the subscription is no longer trialing.
+func (r *SubscriptionRepository) EndTrial(ctx context.Context, id uuid.UUID, tier domain.SubscriptionTier, status domain.SubscriptionStatus) error {
+	query := `UPDATE subscriptions SET tier = $2, status = $3, updated_at = NOW() WHERE id = $1 AND status = $4`
+	result, err := r.db.Exec(ctx, query, id, tier, status, domain.SubscriptionStatusTrialing)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// GetDueTrials returns trialing subscriptions whose trial has ended by now,
+// earliest first
+func (r *SubscriptionRepository) GetDueTrials(ctx context.Context, now time.Time, limit int) ([]*domain.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE status = $2 AND trial_end <= $1
+		ORDER BY trial_end ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, now, domain.SubscriptionStatusTrialing, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// GetDueRenewals returns active subscriptions whose period has ended by now
+// and that neither cancel nor change tier at its end, earliest first
+func (r *SubscriptionRepository) GetDueRenewals(ctx context.Context, now time.Time, limit int) ([]*domain.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE status = $2 AND current_period_end <= $1
+			AND cancel_at_period_end IS NOT TRUE
+			AND (pending_tier IS NULL OR pending_tier_at > $1)
+		ORDER BY current_period_end ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, now, domain.SubscriptionStatusActive, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSubscriptions(rows)
+}
+
+// RenewPeriod moves the subscription into its next period, records the new
+// period's allocation and updates the wallet, all in one transaction. The
+// period only moves if it still ends at renewal.PeriodEnd, so each period
+// is renewed once; otherwise ErrNotFound is returned.
+func (r *SubscriptionRepository) RenewPeriod(ctx context.Context, renewal *domain.PeriodRenewal) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE subscriptions SET current_period_start = $2, current_period_end = $3, updated_at = NOW()
+		WHERE id = $1 AND current_period_end = $2
+	`, renewal.SubscriptionID, renewal.PeriodEnd, renewal.NextPeriodEnd)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO credit_allocations (
+			id, subscription_id, wallet_id, period_start, period_end,
+			credits_allocated, credits_consumed, rollover_credits, source, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, 0, $7, 'subscription', NOW())
+	`, uuid.New(), renewal.SubscriptionID, renewal.WalletID, renewal.PeriodEnd, renewal.NextPeriodEnd,
+		renewal.Credits, renewal.RolloverCredits)
+	if err != nil {
+		return err
+	}
+
+	walletChanges := []struct {
+		amount      int64
+		txType      domain.TransactionType
+		description string
+	}{
+		{-renewal.ExpiredCredits, domain.TransactionTypeAdjustment, "Unused credits expired at renewal"},
+		{renewal.Credits, domain.TransactionTypeSubscription, "Monthly credit allocation"},
+	}
+	for _, change := range walletChanges {
+		if change.amount == 0 {
+			continue
+		}
+		_, err := tx.Exec(ctx, `SELECT update_wallet_balance($1, $2, $3, 'subscription', $4, $5, NULL)`,
+			renewal.WalletID, change.amount, string(change.txType), renewal.SubscriptionID, change.description)
+		if err != nil {
+			return walletError(err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
 // CreateAllocation creates a new credit allocation
 func (r *SubscriptionRepository) CreateAllocation(ctx context.Context, alloc *domain.CreditAllocation) error {
 	query := `
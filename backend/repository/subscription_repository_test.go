@@ -0,0 +1,243 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+// newSubscription creates an office and returns the subscription the
+// offices trigger gave it
+func newSubscription(t *testing.T) *domain.Subscription {
+	t.Helper()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+	sub, err := repo.GetByOfficeID(context.Background(), office)
+	if err != nil {
+		t.Fatalf("GetByOfficeID: %v", err)
+	}
+	return sub
+}
+
+func TestSubscriptionCreatedForNewOffice(t *testing.T) {
+	sub := newSubscription(t)
+
+	if sub.Tier != domain.TierSolo || sub.Status != domain.SubscriptionStatusActive {
+		t.Errorf("new subscription is %s/%s, want %s/%s",
+			sub.Tier, sub.Status, domain.TierSolo, domain.SubscriptionStatusActive)
+	}
+	if sub.PendingTier != nil {
+		t.Errorf("new subscription has pending tier %s", *sub.PendingTier)
+	}
+}
+
+func TestSubscriptionGetByIDUnknown(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+
+	if _, err := repo.GetByID(context.Background(), uuid.New()); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSubscriptionUpdateTier(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	sub := newSubscription(t)
+
+	if err := repo.UpdateTier(ctx, sub.ID, domain.TierBusiness); err != nil {
+		t.Fatalf("UpdateTier: %v", err)
+	}
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Tier != domain.TierBusiness {
+		t.Errorf("tier = %s, want %s", got.Tier, domain.TierBusiness)
+	}
+}
+
+func TestSubscriptionTierChangeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	sub := newSubscription(t)
+
+	if err := repo.ClearTierChange(ctx, sub.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("ClearTierChange without a pending change: error = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.ScheduleTierChange(ctx, sub.ID, domain.TierProfessional, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("ScheduleTierChange: %v", err)
+	}
+	due, err := repo.GetDueTierChanges(ctx, time.Now(), 1000)
+	if err != nil {
+		t.Fatalf("GetDueTierChanges: %v", err)
+	}
+	if !containsSubscription(due, sub.ID) {
+		t.Errorf("GetDueTierChanges does not include the scheduled change")
+	}
+
+	if err := repo.ApplyTierChange(ctx, sub.ID, domain.TierBusiness); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ApplyTierChange for another tier: error = %v, want ErrNotFound", err)
+	}
+	if err := repo.ApplyTierChange(ctx, sub.ID, domain.TierProfessional); err != nil {
+		t.Fatalf("ApplyTierChange: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Tier != domain.TierProfessional || got.PendingTier != nil || got.PendingTierAt != nil {
+		t.Errorf("after ApplyTierChange tier = %s, pending %v at %v; want %s with nothing pending",
+			got.Tier, got.PendingTier, got.PendingTierAt, domain.TierProfessional)
+	}
+	if err := repo.ApplyTierChange(ctx, sub.ID, domain.TierProfessional); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second ApplyTierChange: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSubscriptionReplacedTierChangeIsNotApplied(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	sub := newSubscription(t)
+	at := time.Now().Add(time.Hour)
+
+	if err := repo.ScheduleTierChange(ctx, sub.ID, domain.TierProfessional, at); err != nil {
+		t.Fatalf("ScheduleTierChange: %v", err)
+	}
+	if err := repo.ScheduleTierChange(ctx, sub.ID, domain.TierBusiness, at); err != nil {
+		t.Fatalf("ScheduleTierChange: %v", err)
+	}
+
+	if err := repo.ApplyTierChange(ctx, sub.ID, domain.TierProfessional); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ApplyTierChange for the replaced tier: error = %v, want ErrNotFound", err)
+	}
+	due, err := repo.GetDueTierChanges(ctx, time.Now(), 1000)
+	if err != nil {
+		t.Fatalf("GetDueTierChanges: %v", err)
+	}
+	if containsSubscription(due, sub.ID) {
+		t.Errorf("GetDueTierChanges includes a change scheduled in the future")
+	}
+}
+
+func TestSubscriptionRenewPeriod(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	credits := repository.NewCreditRepository(testDB.Pool)
+	sub := newSubscription(t)
+	walletID := testDB.Wallet(t, sub.OfficeID, 100)
+
+	renewal := &domain.PeriodRenewal{
+		SubscriptionID: sub.ID,
+		WalletID:       walletID,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		NextPeriodEnd:  sub.CurrentPeriodEnd.AddDate(0, 1, 0),
+		Credits:        500,
+		ExpiredCredits: 100,
+	}
+	before := countAllocations(t, repo, sub.ID)
+	if err := repo.RenewPeriod(ctx, renewal); err != nil {
+		t.Fatalf("RenewPeriod: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !got.CurrentPeriodStart.Equal(renewal.PeriodEnd) || !got.CurrentPeriodEnd.Equal(renewal.NextPeriodEnd) {
+		t.Errorf("period is %s to %s, want %s to %s",
+			got.CurrentPeriodStart, got.CurrentPeriodEnd, renewal.PeriodEnd, renewal.NextPeriodEnd)
+	}
+	balance, err := credits.GetBalance(ctx, walletID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 500 {
+		t.Errorf("balance = %d after renewal, want 500", balance)
+	}
+	allocations, err := repo.GetAllocationsBySubscription(ctx, sub.ID, 10)
+	if err != nil {
+		t.Fatalf("GetAllocationsBySubscription: %v", err)
+	}
+	if len(allocations) != before+1 {
+		t.Errorf("got %d allocations, want %d", len(allocations), before+1)
+	} else if !containsAllocation(allocations, renewal.PeriodEnd, 500) {
+		t.Errorf("no allocation of 500 credits starting at %s", renewal.PeriodEnd)
+	}
+
+	// The period already moved, so renewing it again does nothing
+	if err := repo.RenewPeriod(ctx, renewal); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second RenewPeriod: error = %v, want ErrNotFound", err)
+	}
+	if balance, _ := credits.GetBalance(ctx, walletID); balance != 500 {
+		t.Errorf("balance = %d after the second renewal, want 500", balance)
+	}
+}
+
+func TestSubscriptionRenewPeriodRollsBackOnWalletError(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewSubscriptionRepository(testDB.Pool)
+	sub := newSubscription(t)
+	walletID := testDB.Wallet(t, sub.OfficeID, 50)
+
+	before := countAllocations(t, repo, sub.ID)
+
+	// Expiring more credits than the wallet holds fails the whole renewal
+	err := repo.RenewPeriod(ctx, &domain.PeriodRenewal{
+		SubscriptionID: sub.ID,
+		WalletID:       walletID,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		NextPeriodEnd:  sub.CurrentPeriodEnd.AddDate(0, 1, 0),
+		Credits:        500,
+		ExpiredCredits: 100,
+	})
+	if !errors.Is(err, domain.ErrInsufficientCredits) {
+		t.Fatalf("RenewPeriod error = %v, want ErrInsufficientCredits", err)
+	}
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !got.CurrentPeriodEnd.Equal(sub.CurrentPeriodEnd) {
+		t.Errorf("period end moved to %s, want %s", got.CurrentPeriodEnd, sub.CurrentPeriodEnd)
+	}
+	if after := countAllocations(t, repo, sub.ID); after != before {
+		t.Errorf("got %d allocations after a failed renewal, want %d", after, before)
+	}
+}
+
+func containsSubscription(subs []*domain.Subscription, id uuid.UUID) bool {
+	for _, sub := range subs {
+		if sub.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func countAllocations(t *testing.T, repo *repository.SubscriptionRepository, subID uuid.UUID) int {
+	t.Helper()
+	allocations, err := repo.GetAllocationsBySubscription(context.Background(), subID, 100)
+	if err != nil {
+		t.Fatalf("GetAllocationsBySubscription: %v", err)
+	}
+	return len(allocations)
+}
+
+func containsAllocation(allocations []*domain.CreditAllocation, start time.Time, credits int64) bool {
+	for _, alloc := range allocations {
+		if alloc.PeriodStart.Equal(start) && alloc.CreditsAllocated == credits {
+			return true
+		}
+	}
+	return false
+}
@@ -14,41 +14,51 @@ import (
 
 // TaskRepository implements domain.TaskRepository
 type TaskRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewTaskRepository creates a new TaskRepository
 func NewTaskRepository(db *pgxpool.Pool) *TaskRepository {
-	return &TaskRepository{db: db}
+	return &TaskRepository{db: conn{db}}
 }
 
+const taskColumns = `id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage,
+	attempts, max_attempts, next_attempt_at, started_at, completed_at, created_at, parent_task_id, root_task_id, depth,
+	model_name, provider, latency_ms, credits_consumed, kind`
+
 // Create creates a new task
 func (r *TaskRepository) Create(ctx context.Context, task *domain.Task) error {
 	tokenUsageJSON, err := json.Marshal(task.TokenUsage)
 	if err != nil {
 		tokenUsageJSON = []byte("{}")
 	}
+	kind := task.Kind
+	if kind == "" {
+		kind = domain.TaskKindChat
+	}
 
 	query := `
-		INSERT INTO tasks (id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage, started_at, completed_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		INSERT INTO tasks (id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage,
+			attempts, max_attempts, next_attempt_at, started_at, completed_at, created_at, parent_task_id, root_task_id, depth, kind)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
 	_, err = r.db.Exec(ctx, query,
 		task.ID, task.OfficeID, nullableUUID(task.ConversationID), nullableUUID(task.MessageID),
 		task.AgentID, task.Status, task.Input, nullableString(task.Output), nullableString(task.Error),
-		tokenUsageJSON, task.StartedAt, task.CompletedAt, task.CreatedAt,
+		tokenUsageJSON, task.Attempts, task.MaxAttempts, task.NextAttemptAt,
+		task.StartedAt, task.CompletedAt, task.CreatedAt, task.ParentTaskID, task.RootTaskID, task.Depth, kind,
 	)
 	return err
 }
 
 // GetByID returns a task by ID
 func (r *TaskRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Task, error) {
-	query := `
-		SELECT id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage, started_at, completed_at, created_at 
-		FROM tasks WHERE id = $1
-	`
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1`
 
-	task, err := r.scanTask(r.db.QueryRow(ctx, query, id))
+	task, err := scanTask(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -58,9 +68,9 @@ func (r *TaskRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Tas
 // GetByAgentID returns tasks for an agent
 func (r *TaskRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]*domain.Task, error) {
 	query := `
-		SELECT id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage, started_at, completed_at, created_at 
-		FROM tasks 
-		WHERE agent_id = $1 
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE agent_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -71,15 +81,15 @@ func (r *TaskRepository) GetByAgentID(ctx context.Context, agentID uuid.UUID, li
 	}
 	defer rows.Close()
 
-	return r.scanTasks(rows)
+	return scanTasks(rows)
 }
 
 // GetByOfficeID returns tasks for an office
 func (r *TaskRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID, limit, offset int) ([]*domain.Task, error) {
 	query := `
-		SELECT id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage, started_at, completed_at, created_at 
-		FROM tasks 
-		WHERE office_id = $1 
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE office_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -90,15 +100,17 @@ func (r *TaskRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID,
 	}
 	defer rows.Close()
 
-	return r.scanTasks(rows)
+	return scanTasks(rows)
 }
 
-// GetPending returns pending tasks
+// GetPending returns tasks awaiting dispatch: pending tasks and failed tasks
+// whose next retry is due
 func (r *TaskRepository) GetPending(ctx context.Context, limit int) ([]*domain.Task, error) {
 	query := `
-		SELECT id, office_id, conversation_id, message_id, agent_id, status, input, output, error, token_usage, started_at, completed_at, created_at 
-		FROM tasks 
-		WHERE status = 'pending' 
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE status = 'pending'
+			OR (status = 'failed' AND next_attempt_at IS NOT NULL AND next_attempt_at <= NOW())
 		ORDER BY created_at ASC
 		LIMIT $1
 	`
@@ -109,10 +121,85 @@ func (r *TaskRepository) GetPending(ctx context.Context, limit int) ([]*domain.T
 	}
 	defer rows.Close()
 
-	return r.scanTasks(rows)
+	return scanTasks(rows)
 }
 
-// UpdateStatus updates the status of a task
+// GetByRootID returns the sub-tasks of a task tree, oldest first
+func (r *TaskRepository) GetByRootID(ctx context.Context, rootID uuid.UUID) ([]*domain.Task, error) {
+	query := `
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE root_task_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// CountByRootID returns the number of sub-tasks in a task tree
+func (r *TaskRepository) CountByRootID(ctx context.Context, rootID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM tasks WHERE root_task_id = $1`, rootID).Scan(&count)
+	return count, err
+}
+
+// List returns a page of tasks matching the filter, newest first.
+// A cursor continues before the last task of the previous page.
+func (r *TaskRepository) List(ctx context.Context, filter domain.TaskFilter, page domain.PageRequest) ([]*domain.Task, error) {
+	q := taskFilterQuery(filter)
+	if page.Cursor != nil {
+		q.where("(created_at, id) < (" + q.arg(page.Cursor.CreatedAt) + ", " + q.arg(page.Cursor.ID) + ")")
+	}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks` + q.whereClause() + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + q.arg(page.Limit)
+	if page.Cursor == nil && page.Offset > 0 {
+		query += ` OFFSET ` + q.arg(page.Offset)
+	}
+
+	rows, err := r.db.Query(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// Count returns the number of tasks matching the filter
+func (r *TaskRepository) Count(ctx context.Context, filter domain.TaskFilter) (int, error) {
+	q := taskFilterQuery(filter)
+
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM tasks`+q.whereClause(), q.args...).Scan(&count)
+	return count, err
+}
+
+// taskFilterQuery builds the WHERE conditions for a task filter
+func taskFilterQuery(filter domain.TaskFilter) *queryBuilder {
+	q := &queryBuilder{}
+	q.where("office_id = " + q.arg(filter.OfficeID))
+	if filter.Status != "" {
+		q.where("status = " + q.arg(filter.Status))
+	}
+	if filter.AgentID != uuid.Nil {
+		q.where("agent_id = " + q.arg(filter.AgentID))
+	}
+	if filter.ConversationID != uuid.Nil {
+		q.where("conversation_id = " + q.arg(filter.ConversationID))
+	}
+	return q
+}
+
+// UpdateStatus updates the status of a task. Cancelled tasks are final and
+// are left untouched.
 func (r *TaskRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.TaskStatus, output, errMsg string) error {
 	var completedAt *time.Time
 	var startedAt *time.Time
@@ -121,20 +208,116 @@ func (r *TaskRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status
 	if status == domain.TaskStatusThinking || status == domain.TaskStatusWorking {
 		startedAt = &now
 	}
-	if status == domain.TaskStatusDone || status == domain.TaskStatusFailed {
+	if status == domain.TaskStatusDone || status == domain.TaskStatusFailed || status == domain.TaskStatusDeadLetter {
 		completedAt = &now
 	}
 
 	query := `
-		UPDATE tasks 
-		SET status = $2, output = COALESCE($3, output), error = COALESCE($4, error), 
-			started_at = COALESCE($5, started_at), completed_at = COALESCE($6, completed_at)
-		WHERE id = $1
+		UPDATE tasks
+		SET status = $2, output = COALESCE($3, output), error = COALESCE($4, error),
+			started_at = COALESCE($5, started_at), completed_at = COALESCE($6, completed_at),
+			next_attempt_at = NULL
+		WHERE id = $1 AND status <> 'cancelled'
 	`
 	_, err := r.db.Exec(ctx, query, id, status, nullableString(output), nullableString(errMsg), startedAt, completedAt)
 	return err
 }
 
+// RecordUsage stores the task's token counts, model, latency, credits and
+// cost
+func (r *TaskRepository) RecordUsage(ctx context.Context, id uuid.UUID, usage domain.TaskUsage) error {
+	tokenUsageJSON, err := json.Marshal(map[string]int{
+		"prompt_tokens":     usage.InputTokens,
+		"completion_tokens": usage.OutputTokens,
+		"total_tokens":      usage.InputTokens + usage.OutputTokens,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE tasks
+		SET token_usage = $2, model_name = $3, provider = $4, latency_ms = $5, credits_consumed = $6,
+			is_local_model = $7, usd_cost = $8
+		WHERE id = $1
+	`
+	_, err = r.db.Exec(ctx, query, id, tokenUsageJSON, nullableString(usage.Model), nullableString(usage.Provider),
+		usage.LatencyMs, usage.Credits, usage.IsLocalModel, usage.USDCost)
+	return err
+}
+
+// ClaimForDispatch atomically moves a pending or due task to thinking and
+// counts the attempt, so concurrent dispatchers never send a task twice.
+// It reports false if the task was not available to claim.
+func (r *TaskRepository) ClaimForDispatch(ctx context.Context, task *domain.Task) (bool, error) {
+	query := `
+		UPDATE tasks
+		SET status = 'thinking', attempts = attempts + 1, next_attempt_at = NULL,
+			error = NULL, started_at = NOW(), completed_at = NULL
+		WHERE id = $1 AND (
+			status = 'pending'
+			OR (status = 'failed' AND next_attempt_at IS NOT NULL AND next_attempt_at <= NOW())
+		)
+		RETURNING attempts, max_attempts, started_at
+	`
+	err := r.db.QueryRow(ctx, query, task.ID).Scan(&task.Attempts, &task.MaxAttempts, &task.StartedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	task.Status = domain.TaskStatusThinking
+	task.Error = ""
+	task.NextAttemptAt = nil
+	task.CompletedAt = nil
+	return true, nil
+}
+
+// ScheduleRetry marks a task as failed with a retry due at nextAttemptAt
+func (r *TaskRepository) ScheduleRetry(ctx context.Context, id uuid.UUID, errMsg string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE tasks
+		SET status = 'failed', error = $2, next_attempt_at = $3, completed_at = NULL
+		WHERE id = $1 AND status <> 'cancelled'
+	`
+	_, err := r.db.Exec(ctx, query, id, errMsg, nextAttemptAt)
+	return err
+}
+
+// RequeueInProgress moves the given tasks back to pending if they are still
+// thinking or working, returning how many were requeued
+func (r *TaskRepository) RequeueInProgress(ctx context.Context, ids []uuid.UUID, reason string) (int, error) {
+	query := `
+		UPDATE tasks
+		SET status = 'pending', error = $2, started_at = NULL
+		WHERE id = ANY($1) AND status IN ('thinking', 'working')
+	`
+	result, err := r.db.Exec(ctx, query, ids, reason)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// Cancel marks an unfinished task as cancelled, reporting false if it had
+// already finished
+func (r *TaskRepository) Cancel(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE tasks
+		SET status = 'cancelled', next_attempt_at = NULL, completed_at = NOW()
+		WHERE id = $1 AND (
+			status IN ('pending', 'thinking', 'working')
+			OR (status = 'failed' AND next_attempt_at IS NOT NULL)
+		)
+	`
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
 // Delete deletes a task
 func (r *TaskRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM tasks WHERE id = $1`
@@ -142,20 +325,21 @@ func (r *TaskRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-func (r *TaskRepository) scanTask(row pgx.Row) (*domain.Task, error) {
+// scanTask scans a row selected with taskColumns
+func scanTask(row pgx.Row) (*domain.Task, error) {
 	var task domain.Task
 	var conversationID, messageID *uuid.UUID
-	var output, errMsg *string
+	var output, errMsg, model, provider *string
+	var latencyMs *int
 	var tokenUsageJSON []byte
 
 	err := row.Scan(
 		&task.ID, &task.OfficeID, &conversationID, &messageID,
 		&task.AgentID, &task.Status, &task.Input, &output, &errMsg,
-		&tokenUsageJSON, &task.StartedAt, &task.CompletedAt, &task.CreatedAt,
+		&tokenUsageJSON, &task.Attempts, &task.MaxAttempts, &task.NextAttemptAt,
+		&task.StartedAt, &task.CompletedAt, &task.CreatedAt, &task.ParentTaskID, &task.RootTaskID, &task.Depth,
+		&model, &provider, &latencyMs, &task.CreditsConsumed, &task.Kind,
 	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, domain.ErrNotFound
-	}
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +356,15 @@ func (r *TaskRepository) scanTask(row pgx.Row) (*domain.Task, error) {
 	if errMsg != nil {
 		task.Error = *errMsg
 	}
+	if model != nil {
+		task.Model = *model
+	}
+	if provider != nil {
+		task.Provider = *provider
+	}
+	if latencyMs != nil {
+		task.LatencyMs = *latencyMs
+	}
 
 	if err := json.Unmarshal(tokenUsageJSON, &task.TokenUsage); err != nil {
 		task.TokenUsage = make(map[string]int)
@@ -180,41 +373,15 @@ func (r *TaskRepository) scanTask(row pgx.Row) (*domain.Task, error) {
 	return &task, nil
 }
 
-func (r *TaskRepository) scanTasks(rows pgx.Rows) ([]*domain.Task, error) {
+// scanTasks scans all rows selected with taskColumns
+func scanTasks(rows pgx.Rows) ([]*domain.Task, error) {
 	var tasks []*domain.Task
 	for rows.Next() {
-		var task domain.Task
-		var conversationID, messageID *uuid.UUID
-		var output, errMsg *string
-		var tokenUsageJSON []byte
-
-		err := rows.Scan(
-			&task.ID, &task.OfficeID, &conversationID, &messageID,
-			&task.AgentID, &task.Status, &task.Input, &output, &errMsg,
-			&tokenUsageJSON, &task.StartedAt, &task.CompletedAt, &task.CreatedAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if conversationID != nil {
-			task.ConversationID = *conversationID
-		}
-		if messageID != nil {
-			task.MessageID = *messageID
-		}
-		if output != nil {
-			task.Output = *output
-		}
-		if errMsg != nil {
-			task.Error = *errMsg
-		}
-
-		if err := json.Unmarshal(tokenUsageJSON, &task.TokenUsage); err != nil {
-			task.TokenUsage = make(map[string]int)
-		}
-
-		tasks = append(tasks, &task)
+		tasks = append(tasks, task)
 	}
 	return tasks, rows.Err()
 }
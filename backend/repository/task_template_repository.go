@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TaskTemplateRepository implements domain.TaskTemplateRepository
+type TaskTemplateRepository struct {
+	db conn
+}
+
+// NewTaskTemplateRepository creates a new TaskTemplateRepository
+func NewTaskTemplateRepository(db *pgxpool.Pool) *TaskTemplateRepository {
+	return &TaskTemplateRepository{db: conn{db}}
+}
+
+const taskTemplateColumns = `id, office_id, name, description, body, defaults, created_at, updated_at`
+
+// Create stores a new task template, returning domain.ErrAlreadyExists if
+// the office already has one by its name
+func (r *TaskTemplateRepository) Create(ctx context.Context, template *domain.TaskTemplate) error {
+	defaults, err := json.Marshal(template.Defaults)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO task_templates (id, office_id, name, description, body, defaults, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (office_id, name) DO NOTHING
+		RETURNING id
+	`
+	err = r.db.QueryRow(ctx, query,
+		template.ID, template.OfficeID, template.Name, template.Description, template.Body,
+		defaults, template.CreatedAt, template.UpdatedAt,
+	).Scan(&template.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID returns a task template by ID
+func (r *TaskTemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.TaskTemplate, error) {
+	query := `SELECT ` + taskTemplateColumns + ` FROM task_templates WHERE id = $1`
+
+	template, err := scanTaskTemplate(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// GetByOfficeID returns an office's task templates by name
+func (r *TaskTemplateRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.TaskTemplate, error) {
+	query := `SELECT ` + taskTemplateColumns + ` FROM task_templates WHERE office_id = $1 ORDER BY name`
+
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*domain.TaskTemplate
+	for rows.Next() {
+		template, err := scanTaskTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+// Update saves a task template's name, description, body and defaults,
+// returning domain.ErrAlreadyExists if the office has another template by
+// the name
+func (r *TaskTemplateRepository) Update(ctx context.Context, template *domain.TaskTemplate) error {
+	defaults, err := json.Marshal(template.Defaults)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE task_templates t
+		SET name = $2, description = $3, body = $4, defaults = $5, updated_at = $6
+		WHERE t.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM task_templates o WHERE o.office_id = t.office_id AND o.name = $2 AND o.id <> t.id
+		)
+	`
+	tag, err := r.db.Exec(ctx, query,
+		template.ID, template.Name, template.Description, template.Body, defaults, template.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := r.GetByID(ctx, template.ID); err != nil {
+			return err
+		}
+		return domain.ErrAlreadyExists
+	}
+	return nil
+}
+
+// Delete removes a task template; schedules filled in from it are kept
+func (r *TaskTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM task_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// scanTaskTemplate scans a row selected with taskTemplateColumns
+func scanTaskTemplate(row pgx.Row) (*domain.TaskTemplate, error) {
+	var template domain.TaskTemplate
+	var defaults []byte
+	err := row.Scan(
+		&template.ID, &template.OfficeID, &template.Name, &template.Description, &template.Body,
+		&defaults, &template.CreatedAt, &template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(defaults, &template.Defaults); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TemplateBundleRepository implements domain.TemplateBundleRepository
+type TemplateBundleRepository struct {
+	db conn
+}
+
+// NewTemplateBundleRepository creates a new TemplateBundleRepository
+func NewTemplateBundleRepository(db *pgxpool.Pool) *TemplateBundleRepository {
+	return &TemplateBundleRepository{db: conn{db}}
+}
+
+// bundleListed is true for a public bundle whose members are all approved,
+// public and premium, and which costs less than they do separately
+const bundleListed = `(b.is_public AND NOT EXISTS (
+		SELECT 1 FROM template_bundle_items li
+		JOIN agent_templates lt ON lt.id = li.template_id
+		WHERE li.bundle_id = b.id
+		  AND (COALESCE(lt.status, 'approved') <> 'approved' OR NOT COALESCE(lt.is_public, true)
+		       OR COALESCE(lt.price_cents, 0) < 199)
+	) AND b.price_cents < (
+		SELECT COALESCE(SUM(lt.price_cents), 0) FROM template_bundle_items li
+		JOIN agent_templates lt ON lt.id = li.template_id
+		WHERE li.bundle_id = b.id
+	))`
+
+// bundleColumns is the select list read by scanBundle
+const bundleColumns = `b.id, b.author_id, b.author_name, b.name, b.description, b.price_cents,
+	b.is_featured, b.is_public, ` + bundleListed + `,
+	ARRAY(SELECT i.template_id FROM template_bundle_items i WHERE i.bundle_id = b.id ORDER BY i.position),
+	b.created_at, b.updated_at`
+
+// scanBundle scans a row selected with bundleColumns
+func scanBundle(row pgx.Row) (*domain.TemplateBundle, error) {
+	var b domain.TemplateBundle
+	err := row.Scan(
+		&b.ID, &b.AuthorID, &b.AuthorName, &b.Name, &b.Description, &b.PriceCents,
+		&b.IsFeatured, &b.IsPublic, &b.Listed, &b.TemplateIDs,
+		&b.CreatedAt, &b.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Create inserts a bundle and its members
+func (r *TemplateBundleRepository) Create(ctx context.Context, bundle *domain.TemplateBundle) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO template_bundles (
+			id, author_id, author_name, name, description, price_cents, is_featured, is_public, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, bundle.ID, bundle.AuthorID, bundle.AuthorName, bundle.Name, bundle.Description, bundle.PriceCents,
+		bundle.IsFeatured, bundle.IsPublic, bundle.CreatedAt, bundle.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertBundleItems(ctx, tx, bundle); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update saves a bundle's author-editable fields and replaces its members
+func (r *TemplateBundleRepository) Update(ctx context.Context, bundle *domain.TemplateBundle) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE template_bundles
+		SET name = $2, description = $3, price_cents = $4, is_public = $5, updated_at = $6
+		WHERE id = $1
+	`, bundle.ID, bundle.Name, bundle.Description, bundle.PriceCents, bundle.IsPublic, bundle.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM template_bundle_items WHERE bundle_id = $1`, bundle.ID); err != nil {
+		return err
+	}
+	if err := insertBundleItems(ctx, tx, bundle); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// insertBundleItems adds a bundle's members in the order they are listed
+func insertBundleItems(ctx context.Context, tx pgx.Tx, bundle *domain.TemplateBundle) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO template_bundle_items (bundle_id, template_id, position)
+		SELECT $1, template_id, position - 1
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS m(template_id, position)
+	`, bundle.ID, bundle.TemplateIDs)
+	return err
+}
+
+// GetByID returns a bundle with its members
+func (r *TemplateBundleRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.TemplateBundle, error) {
+	b, err := scanBundle(r.db.QueryRow(ctx, `SELECT `+bundleColumns+` FROM template_bundles b WHERE b.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := []domain.TemplateBundle{*b}
+	if err := r.loadTemplates(ctx, bundles); err != nil {
+		return nil, err
+	}
+	return &bundles[0], nil
+}
+
+// GetByAuthor returns every bundle of an author, listed or not, newest first
+func (r *TemplateBundleRepository) GetByAuthor(ctx context.Context, authorID uuid.UUID) ([]domain.TemplateBundle, error) {
+	return r.query(ctx, `SELECT `+bundleColumns+` FROM template_bundles b
+		WHERE b.author_id = $1
+		ORDER BY b.created_at DESC`, authorID)
+}
+
+// List returns listed bundles matching the filter, featured first
+func (r *TemplateBundleRepository) List(ctx context.Context, filter domain.BundleFilter) ([]domain.TemplateBundle, int, error) {
+	q := &queryBuilder{}
+	q.where(bundleListed)
+	if filter.IsFeatured != nil {
+		q.where("b.is_featured = " + q.arg(*filter.IsFeatured))
+	}
+	if filter.AuthorID != nil {
+		q.where("b.author_id = " + q.arg(*filter.AuthorID))
+	}
+	if filter.Search != "" {
+		search := q.arg("%" + filter.Search + "%")
+		q.where(`(b.name ILIKE ` + search + ` OR b.description ILIKE ` + search + ` OR EXISTS (
+			SELECT 1 FROM template_bundle_items si
+			JOIN agent_templates st ON st.id = si.template_id
+			WHERE si.bundle_id = b.id AND st.name ILIKE ` + search + `
+		))`)
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM template_bundles b` + q.whereClause()
+	if err := r.db.QueryRow(ctx, countQuery, q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + bundleColumns + ` FROM template_bundles b` + q.whereClause() +
+		` ORDER BY b.is_featured DESC, b.created_at DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT " + q.arg(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += " OFFSET " + q.arg(filter.Offset)
+	}
+
+	bundles, err := r.query(ctx, query, q.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bundles, total, nil
+}
+
+// query runs a bundleColumns query and loads the members of its bundles
+func (r *TemplateBundleRepository) query(ctx context.Context, query string, args ...any) ([]domain.TemplateBundle, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bundles := []domain.TemplateBundle{}
+	for rows.Next() {
+		b, err := scanBundle(rows)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadTemplates(ctx, bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// loadTemplates fills in the member templates of the bundles, in bundle
+// order, and what they cost bought separately
+func (r *TemplateBundleRepository) loadTemplates(ctx context.Context, bundles []domain.TemplateBundle) error {
+	var ids []uuid.UUID
+	for _, b := range bundles {
+		ids = append(ids, b.TemplateIDs...)
+	}
+	templates := map[uuid.UUID]*domain.AgentTemplate{}
+	if len(ids) > 0 {
+		rows, err := r.db.Query(ctx, `SELECT `+templateColumns+` FROM agent_templates WHERE id = ANY($1)`, ids)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			t, err := scanTemplate(rows)
+			if err != nil {
+				return err
+			}
+			templates[t.ID] = t
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range bundles {
+		b := &bundles[i]
+		b.Templates = make([]domain.AgentTemplate, 0, len(b.TemplateIDs))
+		for _, id := range b.TemplateIDs {
+			if t, ok := templates[id]; ok {
+				b.Templates = append(b.Templates, *t)
+				b.ListPriceCents += t.PriceCents
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestTemplateBundleListedWhileItsTemplatesAre(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewTemplateBundleRepository(testDB.Pool)
+	author := testDB.User(t)
+	templates := []uuid.UUID{testDB.Template(t, author), testDB.Template(t, author)}
+	for i, id := range templates {
+		if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET price_cents = $2, is_premium = true WHERE id = $1`,
+			id, 1000*(i+1)); err != nil {
+			t.Fatalf("price template: %v", err)
+		}
+	}
+
+	now := time.Now()
+	bundle := &domain.TemplateBundle{
+		ID: uuid.New(), AuthorID: author, AuthorName: "Test Author", Name: "Bundle " + uuid.NewString(),
+		PriceCents: 2500, IsPublic: true, TemplateIDs: []uuid.UUID{templates[1], templates[0]}, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := repo.Create(ctx, bundle); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, bundle.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !got.Listed || got.ListPriceCents != 3000 || !slices.Equal(got.TemplateIDs, bundle.TemplateIDs) ||
+		len(got.Templates) != 2 || got.Templates[0].ID != templates[1] {
+		t.Errorf("GetByID = %+v, want the listed bundle with its templates in order", got)
+	}
+	listed, _, err := repo.List(ctx, domain.BundleFilter{Search: bundle.Name, Limit: 10})
+	if err != nil || len(listed) != 1 || listed[0].ID != bundle.ID {
+		t.Errorf("List = %v, %v; want the bundle", listed, err)
+	}
+
+	// A template back in moderation takes the bundle off sale
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET status = 'pending' WHERE id = $1`, templates[0]); err != nil {
+		t.Fatalf("unapprove template: %v", err)
+	}
+	if got, err := repo.GetByID(ctx, bundle.ID); err != nil || got.Listed {
+		t.Errorf("GetByID = %+v, %v; want the bundle unlisted", got, err)
+	}
+	if listed, _, _ := repo.List(ctx, domain.BundleFilter{Search: bundle.Name, Limit: 10}); len(listed) != 0 {
+		t.Errorf("List = %v, want no bundles", listed)
+	}
+
+	bundle.TemplateIDs = []uuid.UUID{templates[1]}
+	bundle.UpdatedAt = time.Now()
+	if err := repo.Update(ctx, bundle); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if mine, err := repo.GetByAuthor(ctx, author); err != nil || len(mine) != 1 || len(mine[0].TemplateIDs) != 1 {
+		t.Errorf("GetByAuthor = %+v, %v; want the bundle with one template", mine, err)
+	}
+	missing := *bundle
+	missing.ID = uuid.New()
+	if err := repo.Update(ctx, &missing); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Update of a missing bundle error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRecordBundleSaleAllowsSmallShares(t *testing.T) {
+	ctx := context.Background()
+	earnings := repository.NewEarningsRepository(testDB.Pool)
+	author := testDB.User(t)
+	template := testDB.Template(t, author)
+	buyer := testDB.User(t)
+	office := testDB.Office(t, buyer)
+
+	bundleID := uuid.New()
+	_, err := testDB.Pool.Exec(ctx, `
+		INSERT INTO template_bundles (id, author_id, author_name, name, price_cents) VALUES ($1, $2, 'Test Author', 'Bundle', 500)
+	`, bundleID, author)
+	if err != nil {
+		t.Fatalf("insert bundle: %v", err)
+	}
+
+	if _, err := earnings.RecordSale(ctx, author, template, buyer, office, 150, ""); err == nil {
+		t.Errorf("RecordSale below the minimum price succeeded")
+	}
+	earningID, err := earnings.RecordBundleSale(ctx, author, template, bundleID, buyer, office, 150, "pi_123")
+	if err != nil {
+		t.Fatalf("RecordBundleSale: %v", err)
+	}
+	sale, err := earnings.GetEarning(ctx, earningID)
+	if err != nil || sale.BundleID == nil || *sale.BundleID != bundleID || sale.AuthorEarningCents != 120 {
+		t.Errorf("GetEarning = %+v, %v; want the bundle share with the usual commission", sale, err)
+	}
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TemplateFavoriteRepository implements domain.TemplateFavoriteRepository
+type TemplateFavoriteRepository struct {
+	db conn
+}
+
+// NewTemplateFavoriteRepository creates a new TemplateFavoriteRepository
+func NewTemplateFavoriteRepository(db *pgxpool.Pool) *TemplateFavoriteRepository {
+	return &TemplateFavoriteRepository{db: conn{db}}
+}
+
+// favoriteListed is true for a favorite whose template is approved and public
+const favoriteListed = `EXISTS (
+		SELECT 1 FROM agent_templates t
+		WHERE t.id = f.template_id
+		  AND COALESCE(t.status, 'approved') = 'approved' AND COALESCE(t.is_public, true)
+	)`
+
+// Add saves a favorite, or changes whether an existing one notifies. A new
+// favorite remembers the template's current version and price, so only
+// later changes are notified; it returns ErrNotFound if there is no such
+// template.
+func (r *TemplateFavoriteRepository) Add(ctx context.Context, favorite *domain.TemplateFavorite) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO template_favorites (user_id, template_id, notify, notified_version, notified_price_cents, created_at)
+		SELECT $1, t.id, $3, COALESCE(t.version, '1.0.0'), COALESCE(t.price_cents, 0), $4
+		FROM agent_templates t WHERE t.id = $2
+		ON CONFLICT (user_id, template_id) DO UPDATE SET notify = EXCLUDED.notify
+		RETURNING created_at
+	`, favorite.UserID, favorite.TemplateID, favorite.Notify, favorite.CreatedAt).Scan(&favorite.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// Remove deletes a favorite, or returns ErrNotFound if there is none
+func (r *TemplateFavoriteRepository) Remove(ctx context.Context, userID, templateID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM template_favorites WHERE user_id = $1 AND template_id = $2`, userID, templateID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ListByUser returns a page of the user's favorites whose templates are
+// listed, newest first, with their templates
+func (r *TemplateFavoriteRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.TemplateFavorite, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM template_favorites f WHERE f.user_id = $1 AND `+favoriteListed,
+		userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT f.user_id, f.template_id, f.notify, f.created_at
+		FROM template_favorites f
+		WHERE f.user_id = $1 AND `+favoriteListed+`
+		ORDER BY f.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	favorites := []domain.TemplateFavorite{}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var f domain.TemplateFavorite
+		if err := rows.Scan(&f.UserID, &f.TemplateID, &f.Notify, &f.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		favorites = append(favorites, f)
+		ids = append(ids, f.TemplateID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return favorites, total, nil
+	}
+	templates := map[uuid.UUID]*domain.AgentTemplate{}
+	tRows, err := r.db.Query(ctx, `SELECT `+templateColumns+` FROM agent_templates WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tRows.Close()
+	for tRows.Next() {
+		t, err := scanTemplate(tRows)
+		if err != nil {
+			return nil, 0, err
+		}
+		templates[t.ID] = t
+	}
+	if err := tRows.Err(); err != nil {
+		return nil, 0, err
+	}
+	for i := range favorites {
+		favorites[i].Template = templates[favorites[i].TemplateID]
+	}
+	return favorites, total, nil
+}
+
+// TakeUpdates returns the template's favorites whose remembered version or
+// price differ from those given, with what they remembered, and remembers
+// the given ones instead, so each change is returned once
+func (r *TemplateFavoriteRepository) TakeUpdates(ctx context.Context, templateID uuid.UUID, version string, priceCents int) ([]domain.FavoriteUpdate, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH previous AS (
+			SELECT user_id, notified_version, notified_price_cents
+			FROM template_favorites
+			WHERE template_id = $1 AND (notified_version <> $2 OR notified_price_cents <> $3)
+			FOR UPDATE
+		)
+		UPDATE template_favorites f
+		SET notified_version = $2, notified_price_cents = $3
+		FROM previous p
+		WHERE f.template_id = $1 AND f.user_id = p.user_id
+		RETURNING f.user_id, f.notify, p.notified_version, p.notified_price_cents
+	`, templateID, version, priceCents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []domain.FavoriteUpdate
+	for rows.Next() {
+		var u domain.FavoriteUpdate
+		if err := rows.Scan(&u.UserID, &u.Notify, &u.PreviousVersion, &u.PreviousPriceCents); err != nil {
+			return nil, err
+		}
+		updates = append(updates, u)
+	}
+	return updates, rows.Err()
+}
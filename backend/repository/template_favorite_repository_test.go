@@ -0,0 +1,77 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+)
+
+func TestTemplateFavoriteAddCountsAndLists(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewTemplateFavoriteRepository(testDB.Pool)
+	marketplace := repository.NewMarketplaceRepository(testDB.Pool)
+	user := testDB.User(t)
+	template := testDB.Template(t, testDB.User(t))
+
+	favorite := &domain.TemplateFavorite{UserID: user, TemplateID: template, CreatedAt: time.Now()}
+	if err := repo.Add(ctx, favorite); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// Adding again only changes notify
+	favorite.Notify = true
+	if err := repo.Add(ctx, favorite); err != nil {
+		t.Fatalf("Add again: %v", err)
+	}
+
+	got, err := marketplace.GetTemplateByID(ctx, template)
+	if err != nil || got.FavoriteCount != 1 {
+		t.Errorf("GetTemplateByID = %+v, %v; want favorite_count 1", got, err)
+	}
+	favorites, total, err := repo.ListByUser(ctx, user, 10, 0)
+	if err != nil || total != 1 || len(favorites) != 1 || !favorites[0].Notify || favorites[0].Template == nil {
+		t.Errorf("ListByUser = %+v, %d, %v; want the notifying favorite with its template", favorites, total, err)
+	}
+
+	if err := repo.Remove(ctx, user, template); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := repo.Remove(ctx, user, template); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("second Remove error = %v, want ErrNotFound", err)
+	}
+	got, err = marketplace.GetTemplateByID(ctx, template)
+	if err != nil || got.FavoriteCount != 0 {
+		t.Errorf("GetTemplateByID = %+v, %v; want favorite_count 0", got, err)
+	}
+}
+
+func TestTemplateFavoriteTakeUpdatesOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewTemplateFavoriteRepository(testDB.Pool)
+	user := testDB.User(t)
+	template := testDB.Template(t, testDB.User(t))
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET price_cents = 1999, version = '1.0.0' WHERE id = $1`, template); err != nil {
+		t.Fatalf("price template: %v", err)
+	}
+	if err := repo.Add(ctx, &domain.TemplateFavorite{UserID: user, TemplateID: template, Notify: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	updates, err := repo.TakeUpdates(ctx, template, "1.0.0", 1999)
+	if err != nil || len(updates) != 0 {
+		t.Errorf("TakeUpdates unchanged = %+v, %v; want none", updates, err)
+	}
+	updates, err = repo.TakeUpdates(ctx, template, "1.0.0", 999)
+	if err != nil || len(updates) != 1 || updates[0].UserID != user || updates[0].PreviousPriceCents != 1999 || !updates[0].Notify {
+		t.Errorf("TakeUpdates on sale = %+v, %v; want the favorite with its previous price", updates, err)
+	}
+	updates, err = repo.TakeUpdates(ctx, template, "1.0.0", 999)
+	if err != nil || len(updates) != 0 {
+		t.Errorf("TakeUpdates again = %+v, %v; want the change taken once", updates, err)
+	}
+}
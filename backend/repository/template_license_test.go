@@ -0,0 +1,81 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestTemplateLicenseStoredWithTemplatesVersionsAndPurchases(t *testing.T) {
+	ctx := context.Background()
+	marketplace := repository.NewMarketplaceRepository(testDB.Pool)
+	versions := repository.NewTemplateVersionRepository(testDB.Pool)
+	earnings := repository.NewEarningsRepository(testDB.Pool)
+	purchases := repository.NewTemplatePurchaseRepository(testDB.Pool)
+	author := testDB.User(t)
+	buyer := testDB.User(t)
+	office := testDB.Office(t, buyer)
+	now := time.Now()
+
+	prompt := "You audit invoices. " + uuid.NewString()
+	license := domain.TemplateLicense{Type: domain.LicensePersonal, ResaleProhibited: true, Terms: "One user only."}
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Auditor", Role: "Auditor", SystemPrompt: prompt, SkillTags: []string{},
+		AuthorID: &author, AuthorName: "Author", Category: "general", IsPublic: true, IsPremium: true,
+		PriceCents: 1500, Version: "1.0.0", Status: "approved", CreatedAt: now, UpdatedAt: now, License: license,
+	}
+	if err := marketplace.CreateTemplate(ctx, template); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+	got, err := marketplace.GetTemplateByID(ctx, template.ID)
+	if err != nil || got.License != license {
+		t.Errorf("GetTemplateByID license = %+v, %v; want %+v", got.License, err, license)
+	}
+
+	// Only other authors copying the prompt break the license
+	if copied, err := marketplace.IsResaleProhibitedCopy(ctx, prompt, uuid.New()); err != nil || !copied {
+		t.Errorf("IsResaleProhibitedCopy by another author = %v, %v; want true", copied, err)
+	}
+	if copied, err := marketplace.IsResaleProhibitedCopy(ctx, strings.ReplaceAll(strings.ToUpper(prompt), " ", "\n  "), uuid.New()); err != nil || !copied {
+		t.Errorf("IsResaleProhibitedCopy respaced = %v, %v; want true", copied, err)
+	}
+	if copied, err := marketplace.IsResaleProhibitedCopy(ctx, prompt, author); err != nil || copied {
+		t.Errorf("IsResaleProhibitedCopy by the author = %v, %v; want false", copied, err)
+	}
+
+	version := &domain.TemplateVersion{
+		ID: uuid.New(), TemplateID: template.ID, Version: "1.0.0", SystemPrompt: prompt,
+		SkillTags: []string{}, CreatedAt: now, License: license,
+	}
+	if err := versions.Create(ctx, version); err != nil {
+		t.Fatalf("Create version: %v", err)
+	}
+	v, err := versions.GetByVersion(ctx, template.ID, "1.0.0")
+	if err != nil || v.License != license {
+		t.Errorf("GetByVersion license = %+v, %v; want %+v", v.License, err, license)
+	}
+
+	earningID, err := earnings.RecordSale(ctx, author, template.ID, buyer, office, 1500, "pi_license")
+	if err != nil {
+		t.Fatalf("RecordSale: %v", err)
+	}
+	purchase := &domain.TemplatePurchase{
+		ID: uuid.New(), OfficeID: office, TemplateID: template.ID, PurchasedBy: buyer, EarningID: &earningID,
+		PriceCents: 1500, Status: domain.TemplatePurchaseStatusActive, CreatedAt: now,
+		License: &domain.LicenseAcceptance{License: license, Version: "1.0.0", AcceptedAt: now},
+	}
+	if err := purchases.Create(ctx, purchase); err != nil {
+		t.Fatalf("Create purchase: %v", err)
+	}
+	owned, err := purchases.GetByOfficeID(ctx, office)
+	if err != nil || len(owned) != 1 || owned[0].License == nil || owned[0].License.License != license || owned[0].License.Version != "1.0.0" {
+		t.Errorf("GetByOfficeID = %+v, %v; want the purchase with its accepted license", owned, err)
+	}
+}
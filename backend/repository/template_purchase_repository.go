@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TemplatePurchaseRepository implements domain.TemplatePurchaseRepository
+type TemplatePurchaseRepository struct {
+	db conn
+}
+
+// NewTemplatePurchaseRepository creates a new TemplatePurchaseRepository
+func NewTemplatePurchaseRepository(db *pgxpool.Pool) *TemplatePurchaseRepository {
+	return &TemplatePurchaseRepository{db: conn{db}}
+}
+
+// Create records a new entitlement, returning domain.ErrAlreadyExists if the office already owns the template.
+// Buying a template again after a refund replaces the refunded purchase.
+func (r *TemplatePurchaseRepository) Create(ctx context.Context, purchase *domain.TemplatePurchase) error {
+	query := `
+		INSERT INTO template_purchases (
+			id, office_id, template_id, purchased_by, earning_id, price_cents, price_credits, status, created_at,
+			bundle_id, license_type, license_resale_prohibited, license_terms, license_version, license_accepted_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7::bigint, 0), $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (office_id, template_id) DO UPDATE SET
+			purchased_by = EXCLUDED.purchased_by, earning_id = EXCLUDED.earning_id,
+			price_cents = EXCLUDED.price_cents, price_credits = EXCLUDED.price_credits,
+			status = EXCLUDED.status, created_at = EXCLUDED.created_at, bundle_id = EXCLUDED.bundle_id,
+			license_type = EXCLUDED.license_type, license_resale_prohibited = EXCLUDED.license_resale_prohibited,
+			license_terms = EXCLUDED.license_terms, license_version = EXCLUDED.license_version,
+			license_accepted_at = EXCLUDED.license_accepted_at
+			WHERE template_purchases.status = 'refunded'
+		RETURNING id
+	`
+	var license purchaseLicense
+	if a := purchase.License; a != nil {
+		license = purchaseLicense{&a.License.Type, &a.License.ResaleProhibited, &a.License.Terms, &a.Version, &a.AcceptedAt}
+	}
+	err := r.db.QueryRow(ctx, query,
+		purchase.ID, purchase.OfficeID, purchase.TemplateID, purchase.PurchasedBy,
+		purchase.EarningID, purchase.PriceCents, purchase.PriceCredits, purchase.Status, purchase.CreatedAt,
+		purchase.BundleID, license.licenseType, license.resaleProhibited, license.terms, license.version, license.acceptedAt,
+	).Scan(&purchase.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByID returns a purchase, whatever its status
+func (r *TemplatePurchaseRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.TemplatePurchase, error) {
+	query := `
+		SELECT id, office_id, template_id, purchased_by, earning_id, price_cents, COALESCE(price_credits, 0),
+		       status, created_at, bundle_id,
+		       license_type, license_resale_prohibited, license_terms, license_version, license_accepted_at
+		FROM template_purchases
+		WHERE id = $1
+	`
+	var p domain.TemplatePurchase
+	var license purchaseLicense
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.OfficeID, &p.TemplateID, &p.PurchasedBy, &p.EarningID, &p.PriceCents, &p.PriceCredits, &p.Status, &p.CreatedAt,
+		&p.BundleID, &license.licenseType, &license.resaleProhibited, &license.terms, &license.version, &license.acceptedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.License = license.acceptance()
+	return &p, nil
+}
+
+// Revoke marks an active purchase refunded
+func (r *TemplatePurchaseRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `UPDATE template_purchases SET status = $2 WHERE id = $1 AND status = $3`,
+		id, domain.TemplatePurchaseStatusRefunded, domain.TemplatePurchaseStatusActive)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// HasPurchased reports whether an office holds an active entitlement to a template
+func (r *TemplatePurchaseRepository) HasPurchased(ctx context.Context, officeID uuid.UUID, templateID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM template_purchases
+			WHERE office_id = $1 AND template_id = $2 AND status = 'active'
+		)
+	`
+	var owned bool
+	err := r.db.QueryRow(ctx, query, officeID, templateID).Scan(&owned)
+	return owned, err
+}
+
+// GetByOfficeID returns an office's active purchases with their templates, newest first
+func (r *TemplatePurchaseRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.TemplatePurchase, error) {
+	query := `
+		SELECT p.id, p.office_id, p.template_id, p.purchased_by, p.earning_id, p.price_cents,
+		       COALESCE(p.price_credits, 0), p.status, p.created_at, p.bundle_id,
+		       p.license_type, p.license_resale_prohibited, p.license_terms, p.license_version, p.license_accepted_at,
+		       t.name, t.role, COALESCE(t.author_name, 'Synoffice Team'), COALESCE(t.category, 'general'),
+		       COALESCE(t.description, ''), COALESCE(t.version, '1.0.0'),
+		       COALESCE(t.is_premium, false), COALESCE(t.price_cents, 0)
+		FROM template_purchases p
+		JOIN agent_templates t ON t.id = p.template_id
+		WHERE p.office_id = $1 AND p.status = 'active'
+		ORDER BY p.created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []*domain.TemplatePurchase{}
+	for rows.Next() {
+		var p domain.TemplatePurchase
+		var license purchaseLicense
+		t := &domain.AgentTemplate{}
+		if err := rows.Scan(
+			&p.ID, &p.OfficeID, &p.TemplateID, &p.PurchasedBy, &p.EarningID, &p.PriceCents, &p.PriceCredits, &p.Status, &p.CreatedAt,
+			&p.BundleID, &license.licenseType, &license.resaleProhibited, &license.terms, &license.version, &license.acceptedAt,
+			&t.Name, &t.Role, &t.AuthorName, &t.Category, &t.Description, &t.Version,
+			&t.IsPremium, &t.PriceCents,
+		); err != nil {
+			return nil, err
+		}
+		t.ID = p.TemplateID
+		p.Template = t
+		p.License = license.acceptance()
+		purchases = append(purchases, &p)
+	}
+	return purchases, rows.Err()
+}
+
+// purchaseLicense holds the license columns of a purchase, which are NULL
+// for purchases made before templates were licensed
+type purchaseLicense struct {
+	licenseType      *domain.LicenseType
+	resaleProhibited *bool
+	terms            *string
+	version          *string
+	acceptedAt       *time.Time
+}
+
+// acceptance returns the license accepted with the purchase, or nil
+func (l purchaseLicense) acceptance() *domain.LicenseAcceptance {
+	if l.licenseType == nil {
+		return nil
+	}
+	a := &domain.LicenseAcceptance{License: domain.TemplateLicense{Type: *l.licenseType}}
+	if l.resaleProhibited != nil {
+		a.License.ResaleProhibited = *l.resaleProhibited
+	}
+	if l.terms != nil {
+		a.License.Terms = *l.terms
+	}
+	if l.version != nil {
+		a.Version = *l.version
+	}
+	if l.acceptedAt != nil {
+		a.AcceptedAt = *l.acceptedAt
+	}
+	return a
+}
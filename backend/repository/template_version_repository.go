@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TemplateVersionRepository implements domain.TemplateVersionRepository
+type TemplateVersionRepository struct {
+	db conn
+}
+
+// NewTemplateVersionRepository creates a new TemplateVersionRepository
+func NewTemplateVersionRepository(db *pgxpool.Pool) *TemplateVersionRepository {
+	return &TemplateVersionRepository{db: conn{db}}
+}
+
+const templateVersionColumns = `id, template_id, version, system_prompt, skill_tags, changelog, created_at,
+	license_type, license_resale_prohibited, license_terms,
+	status, COALESCE(rejection_reason, ''), reviewed_at, risk_score, risk_findings`
+
+// Create stores a version snapshot, returning domain.ErrAlreadyExists if the version was already published
+// or another version of the template is awaiting review. A rejected version is replaced by a new one with
+// the same number. Versions without a license type get the team license, and without a status are approved.
+func (r *TemplateVersionRepository) Create(ctx context.Context, version *domain.TemplateVersion) error {
+	skillTags, err := json.Marshal(version.SkillTags)
+	if err != nil {
+		return err
+	}
+	riskFindings, err := marshalRiskFindings(version.RiskFindings)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO template_versions (
+			id, template_id, version, system_prompt, skill_tags, changelog, created_at,
+			license_type, license_resale_prohibited, license_terms, status, risk_score, risk_findings
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8::text, ''), 'team'), $9, $10,
+			COALESCE(NULLIF($11::text, ''), 'approved'), $12, $13)
+		ON CONFLICT (template_id, version) DO UPDATE SET
+			id = EXCLUDED.id, system_prompt = EXCLUDED.system_prompt, skill_tags = EXCLUDED.skill_tags,
+			changelog = EXCLUDED.changelog, created_at = EXCLUDED.created_at,
+			license_type = EXCLUDED.license_type, license_resale_prohibited = EXCLUDED.license_resale_prohibited,
+			license_terms = EXCLUDED.license_terms, status = EXCLUDED.status,
+			rejection_reason = NULL, reviewed_by = NULL, reviewed_at = NULL,
+			risk_score = EXCLUDED.risk_score, risk_findings = EXCLUDED.risk_findings
+		WHERE template_versions.status = 'rejected'
+		RETURNING id
+	`
+	err = r.db.QueryRow(ctx, query,
+		version.ID, version.TemplateID, version.Version, version.SystemPrompt,
+		skillTags, version.Changelog, version.CreatedAt,
+		version.License.Type, version.License.ResaleProhibited, version.License.Terms,
+		version.Status, version.RiskScore, riskFindings,
+	).Scan(&version.ID)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// GetByTemplateID returns a template's approved versions, newest first
+func (r *TemplateVersionRepository) GetByTemplateID(ctx context.Context, templateID uuid.UUID) ([]*domain.TemplateVersion, error) {
+	query := `SELECT ` + templateVersionColumns + ` FROM template_versions
+		WHERE template_id = $1 AND status = 'approved' ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*domain.TemplateVersion
+	for rows.Next() {
+		v, err := scanTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetByVersion returns a single approved version of a template
+func (r *TemplateVersionRepository) GetByVersion(ctx context.Context, templateID uuid.UUID, version string) (*domain.TemplateVersion, error) {
+	query := `SELECT ` + templateVersionColumns + ` FROM template_versions
+		WHERE template_id = $1 AND version = $2 AND status = 'approved'`
+
+	v, err := scanTemplateVersion(r.db.QueryRow(ctx, query, templateID, version))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetPending returns the version of a template awaiting moderation
+func (r *TemplateVersionRepository) GetPending(ctx context.Context, templateID uuid.UUID) (*domain.TemplateVersion, error) {
+	query := `SELECT ` + templateVersionColumns + ` FROM template_versions WHERE template_id = $1 AND status = 'pending'`
+
+	v, err := scanTemplateVersion(r.db.QueryRow(ctx, query, templateID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SetStatus records a moderation decision on a pending version.
+// Returns domain.ErrNotFound if the version does not exist or is no longer pending.
+func (r *TemplateVersionRepository) SetStatus(ctx context.Context, id uuid.UUID, status, rejectionReason string, reviewerID uuid.UUID) error {
+	query := `
+		UPDATE template_versions SET
+			status = $2, rejection_reason = $3, reviewed_by = $4, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`
+	result, err := r.db.Exec(ctx, query, id, status, nullableString(rejectionReason), reviewerID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// scanTemplateVersion scans a row selected with templateVersionColumns
+func scanTemplateVersion(row pgx.Row) (*domain.TemplateVersion, error) {
+	var v domain.TemplateVersion
+	var skillTags, riskFindings []byte
+	if err := row.Scan(
+		&v.ID, &v.TemplateID, &v.Version, &v.SystemPrompt, &skillTags, &v.Changelog, &v.CreatedAt,
+		&v.License.Type, &v.License.ResaleProhibited, &v.License.Terms,
+		&v.Status, &v.RejectionReason, &v.ReviewedAt, &v.RiskScore, &riskFindings,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(skillTags, &v.SkillTags); err != nil {
+		v.SkillTags = []string{}
+	}
+	if err := json.Unmarshal(riskFindings, &v.RiskFindings); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TemplateViewRepository implements domain.TemplateViewRepository
+type TemplateViewRepository struct {
+	db conn
+}
+
+// NewTemplateViewRepository creates a new TemplateViewRepository
+func NewTemplateViewRepository(db *pgxpool.Pool) *TemplateViewRepository {
+	return &TemplateViewRepository{db: conn{db}}
+}
+
+// RecordViews counts the visitor once on date for each of the approved
+// templates. The visitor row decides whether it is new; only new visitors
+// are added to the day's counters.
+func (r *TemplateViewRepository) RecordViews(
+	ctx context.Context,
+	templateIDs []uuid.UUID,
+	kind domain.TemplateViewKind,
+	visitorHash string,
+	date time.Time,
+) (int64, error) {
+	query := `
+		WITH counted AS (
+			INSERT INTO template_view_visitors (template_id, date, kind, visitor_hash)
+			SELECT id, $3::date, $2::text, $4 FROM agent_templates
+			WHERE id = ANY($1) AND status = 'approved'
+			ON CONFLICT DO NOTHING
+			RETURNING template_id
+		)
+		INSERT INTO template_views (template_id, date, impressions, views)
+		SELECT template_id, $3::date,
+		       CASE WHEN $2::text = 'impression' THEN 1 ELSE 0 END,
+		       CASE WHEN $2::text = 'view' THEN 1 ELSE 0 END
+		FROM counted
+		ON CONFLICT (template_id, date) DO UPDATE SET
+			impressions = template_views.impressions + EXCLUDED.impressions,
+			views = template_views.views + EXCLUDED.views
+	`
+	tag, err := r.db.Exec(ctx, query, templateIDs, string(kind), date, visitorHash)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeVisitors forgets the visitors counted before date; the counters
+// they added are kept
+func (r *TemplateViewRepository) PurgeVisitors(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM template_view_visitors WHERE date < $1::date`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetAuthorTemplateStats returns the impressions and views of each of the
+// author's templates in dates, with the agents hired from it and its
+// completed sales over the same UTC days
+func (r *TemplateViewRepository) GetAuthorTemplateStats(
+	ctx context.Context,
+	authorID uuid.UUID,
+	dates domain.DateRange,
+) ([]domain.TemplateStats, error) {
+	query := `
+		SELECT t.id, t.name, t.status,
+		       COALESCE(v.impressions, 0), COALESCE(v.views, 0),
+		       COALESCE(h.hires, 0), COALESCE(s.sales, 0), COALESCE(s.revenue, 0)
+		FROM agent_templates t
+		LEFT JOIN (
+			SELECT template_id, SUM(impressions) AS impressions, SUM(views) AS views
+			FROM template_views
+			WHERE date BETWEEN $2 AND $3
+			GROUP BY template_id
+		) v ON v.template_id = t.id
+		LEFT JOIN (
+			SELECT template_id, COUNT(*) AS hires
+			FROM agents
+			WHERE (created_at AT TIME ZONE 'UTC')::date BETWEEN $2 AND $3
+			GROUP BY template_id
+		) h ON h.template_id = t.id
+		LEFT JOIN (
+			SELECT template_id, COUNT(*) AS sales, SUM(sale_amount_cents) AS revenue
+			FROM author_earnings
+			WHERE author_id = $1 AND status = 'completed'
+			  AND (created_at AT TIME ZONE 'UTC')::date BETWEEN $2 AND $3
+			GROUP BY template_id
+		) s ON s.template_id = t.id
+		WHERE t.author_id = $1
+		ORDER BY COALESCE(v.views, 0) DESC, COALESCE(v.impressions, 0) DESC, t.name
+	`
+
+	rows, err := r.db.Query(ctx, query, authorID, dates.From, dates.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.TemplateStats
+	for rows.Next() {
+		var s domain.TemplateStats
+		if err := rows.Scan(
+			&s.TemplateID, &s.Name, &s.Status,
+			&s.Impressions, &s.Views, &s.Hires, &s.Sales, &s.RevenueCents,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,63 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestRecordViewsCountsEachVisitorOnceADay(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewTemplateViewRepository(testDB.Pool)
+	author := testDB.User(t)
+	template := testDB.Template(t, author)
+	pending := testDB.Template(t, author)
+	if _, err := testDB.Pool.Exec(ctx, `UPDATE agent_templates SET status = 'pending' WHERE id = $1`, pending); err != nil {
+		t.Fatalf("unapprove template: %v", err)
+	}
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	both := []uuid.UUID{template, pending}
+
+	counted, err := repo.RecordViews(ctx, both, domain.TemplateImpression, "alice", day)
+	if err != nil || counted != 1 {
+		t.Fatalf("RecordViews = %d, %v; want the approved template counted", counted, err)
+	}
+	if counted, err = repo.RecordViews(ctx, both, domain.TemplateImpression, "alice", day); err != nil || counted != 0 {
+		t.Errorf("RecordViews again = %d, %v; want alice counted once", counted, err)
+	}
+	for _, visit := range []struct {
+		kind    domain.TemplateViewKind
+		visitor string
+		day     time.Time
+	}{
+		{domain.TemplateImpression, "bob", day},
+		{domain.TemplateDetailView, "alice", day},
+		{domain.TemplateDetailView, "alice", day.AddDate(0, 0, 1)},
+	} {
+		if counted, err = repo.RecordViews(ctx, both, visit.kind, visit.visitor, visit.day); err != nil || counted != 1 {
+			t.Errorf("RecordViews(%s by %s on %s) = %d, %v; want it counted", visit.kind, visit.visitor, visit.day, counted, err)
+		}
+	}
+
+	purged, err := repo.PurgeVisitors(ctx, day.AddDate(0, 0, 1))
+	if err != nil || purged != 3 {
+		t.Errorf("PurgeVisitors = %d, %v; want the 3 visitors of the 1st", purged, err)
+	}
+
+	stats, err := repo.GetAuthorTemplateStats(ctx, author, domain.DateRange{From: day, To: day.AddDate(0, 0, 1)})
+	if err != nil || len(stats) != 2 {
+		t.Fatalf("GetAuthorTemplateStats = %+v, %v; want both templates", stats, err)
+	}
+	if s := stats[0]; s.TemplateID != template || s.Impressions != 2 || s.Views != 2 || s.Status != "approved" {
+		t.Errorf("stats = %+v, want 2 impressions and 2 views first", s)
+	}
+	if s := stats[1]; s.TemplateID != pending || s.Impressions != 0 || s.Views != 0 {
+		t.Errorf("stats = %+v, want the pending template unseen", s)
+	}
+}
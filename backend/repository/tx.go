@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// txKey is the context key of the transaction a TxManager started
+type txKey struct{}
+
+// TxManager implements domain.TxManager on a pgx pool
+type TxManager struct {
+	db *pgxpool.Pool
+}
+
+// NewTxManager creates a new TxManager
+func NewTxManager(db *pgxpool.Pool) *TxManager {
+	return &TxManager{db: db}
+}
+
+// WithinTx runs fn in a transaction, committed when fn returns nil and
+// rolled back otherwise. Called within another WithinTx, fn joins the outer
+// transaction.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// querier is what pgxpool.Pool and pgx.Tx have in common
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// conn is the database handle of the repositories. It runs queries in the
+// context's transaction when there is one, so that a service's writes
+// through several repositories commit together, and on the pool otherwise.
+// Begin within a transaction starts a savepoint.
+type conn struct {
+	pool *pgxpool.Pool
+}
+
+func (c conn) current(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return c.pool
+}
+
+func (c conn) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return c.current(ctx).Exec(ctx, sql, args...)
+}
+
+func (c conn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return c.current(ctx).Query(ctx, sql, args...)
+}
+
+func (c conn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return c.current(ctx).QueryRow(ctx, sql, args...)
+}
+
+func (c conn) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return c.current(ctx).SendBatch(ctx, b)
+}
+
+func (c conn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return c.current(ctx).Begin(ctx)
+}
@@ -7,36 +7,45 @@ import (
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // UserRepository implements domain.UserRepository
 type UserRepository struct {
-	db *pgxpool.Pool
+	db conn
 }
 
 // NewUserRepository creates a new UserRepository
 func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{db: db}
+	return &UserRepository{db: conn{db}}
 }
 
 // Create creates a new user
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (id, email, password_hash, name, role, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
-	_, err := r.db.Exec(ctx, query, user.ID, user.Email, user.PasswordHash, user.Name, user.CreatedAt, user.UpdatedAt)
+	if user.Role == "" {
+		user.Role = domain.UserRoleUser
+	}
+	_, err := r.db.Exec(ctx, query,
+		user.ID, user.Email, user.PasswordHash, user.Name, user.Role, user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt,
+	)
 	return err
 }
 
-// GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	query := `SELECT id, email, password_hash, name, created_at, updated_at FROM users WHERE id = $1`
+// userColumns are the columns scanned by scanUser
+const userColumns = `id, email, password_hash, name, role, email_verified_at, COALESCE(pending_email, ''),
+	token_version, created_at, updated_at`
 
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (*domain.User, error) {
 	var user domain.User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Role, &user.EmailVerifiedAt, &user.PendingEmail,
+		&user.TokenVersion, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
@@ -47,28 +56,236 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Use
 	return &user, nil
 }
 
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+	return scanUser(r.db.QueryRow(ctx, query, id))
+}
+
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, email, password_hash, name, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+	return scanUser(r.db.QueryRow(ctx, query, email))
+}
 
-	var user domain.User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.CreatedAt, &user.UpdatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, domain.ErrNotFound
+// Update updates a user's profile
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	query := `UPDATE users SET email = $2, name = $3, email_verified_at = $4, pending_email = $5, updated_at = $6 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, user.ID, user.Email, user.Name, user.EmailVerifiedAt, nullableString(user.PendingEmail), user.UpdatedAt)
+	return err
+}
+
+// UpdatePassword replaces a user's password hash, ending their sessions
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, token_version = token_version + 1, updated_at = NOW() WHERE id = $1`
+	tag, err := r.db.Exec(ctx, query, id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// MarkEmailVerified records that a user confirmed their email address. It
+// keeps the first verification time if the address was already verified.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1`
+	tag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ConfirmPendingEmail makes a user's pending email their verified email
+func (r *UserRepository) ConfirmPendingEmail(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users SET email = pending_email, pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND pending_email IS NOT NULL AND deleted_at IS NULL
+	`
+	tag, err := r.db.Exec(ctx, query, id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return domain.ErrAlreadyExists
 	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SoftDelete closes an account. The user row stays so billing and
+// marketplace records keep their references, but the email and name are
+// erased, sign-in methods and API keys are removed, the user's offices stop
+// running schedules and their subscriptions are not renewed.
+func (r *UserRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := closeAccount(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// userOffices selects the IDs of the offices of the user ($1)
+const userOffices = `SELECT id FROM offices WHERE user_id = $1`
+
+// erasures delete or anonymize what the user ($1) stored. Earnings, payouts,
+// purchases, invoices, credit transactions and task charges are kept for
+// accounting, without their free-text descriptions; the audit log is kept
+// as is.
+var erasures = []string{
+	// Messages, their reactions and reads, and schedules go with their
+	// conversations
+	`DELETE FROM conversations WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM agent_memories WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM agent_feedback WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM agent_changes WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM documents WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM knowledge_documents WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM task_templates WHERE office_id IN (` + userOffices + `)`,
+	// Board columns and cards go with their projects
+	`DELETE FROM projects WHERE office_id IN (` + userOffices + `)`,
+	// Department members go with their departments
+	`DELETE FROM departments WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM agent_reporting_lines WHERE agent_id IN (SELECT id FROM agents WHERE office_id IN (` + userOffices + `))`,
+	`DELETE FROM agent_skills WHERE agent_id IN (SELECT id FROM agents WHERE office_id IN (` + userOffices + `))`,
+	`DELETE FROM model_policies WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM notifications WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM webhooks WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM office_secrets WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM web_searches WHERE office_id IN (` + userOffices + `)`,
+	`DELETE FROM office_web_research_settings WHERE office_id IN (` + userOffices + `)`,
+	`UPDATE widget_tokens SET revoked_at = NOW() WHERE revoked_at IS NULL AND office_id IN (` + userOffices + `)`,
+	`UPDATE tasks SET input = '', output = NULL, error = NULL WHERE office_id IN (` + userOffices + `)`,
+	`UPDATE agents SET custom_name = NULL, custom_system_prompt = NULL, custom_avatar_url = NULL,
+		is_active = FALSE, deleted_at = COALESCE(deleted_at, NOW())
+		WHERE office_id IN (` + userOffices + `)`,
+	`UPDATE credit_transactions SET description = '', metadata = NULL
+		WHERE wallet_id IN (SELECT id FROM credit_wallets WHERE office_id IN (` + userOffices + `))`,
+	`UPDATE purchase_refund_requests SET reason = '' WHERE office_id IN (` + userOffices + `)`,
+	`UPDATE offices SET name = 'Erased office', deleted_at = COALESCE(deleted_at, NOW()), updated_at = NOW()
+		WHERE user_id = $1`,
+	`UPDATE agent_templates SET author_name = 'Deleted user' WHERE author_id = $1`,
+	`UPDATE template_bundles SET author_name = 'Deleted user' WHERE author_id = $1`,
+	`DELETE FROM author_profiles WHERE user_id = $1`,
+	`DELETE FROM template_favorites WHERE user_id = $1`,
+	`DELETE FROM agent_reviews WHERE user_id = $1`,
+	`DELETE FROM review_votes WHERE user_id = $1`,
+	`DELETE FROM review_replies WHERE author_id = $1`,
+	`DELETE FROM notification_preferences WHERE user_id = $1`,
+	`DELETE FROM data_exports WHERE user_id = $1`,
+	`DELETE FROM moderation_flags WHERE user_id = $1 OR office_id IN (` + userOffices + `)`,
+}
+
+// Erase closes an account like SoftDelete and erases everything else the
+// user stored in one transaction. It returns the storage keys of the
+// attachments and data export archives to remove.
+func (r *UserRepository) Erase(ctx context.Context, id uuid.UUID) ([]string, error) {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	defer tx.Rollback(ctx)
+
+	// Queued and sent emails hold the address, so they go before it is
+	// erased
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM email_outbox WHERE to_address = (SELECT email FROM users WHERE id = $1 AND deleted_at IS NULL)
+	`, id); err != nil {
+		return nil, err
+	}
+	if err := closeAccount(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	// Attachments would go with their conversations anyway; deleting them
+	// first returns the contents to remove from storage
+	rows, err := tx.Query(ctx, `
+		DELETE FROM message_attachments WHERE office_id IN (`+userOffices+`)
+		RETURNING storage_key
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	storageKeys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	rows, err = tx.Query(ctx, `SELECT storage_key FROM data_exports WHERE user_id = $1 AND storage_key <> ''`, id)
+	if err != nil {
+		return nil, err
+	}
+	archives, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	storageKeys = append(storageKeys, archives...)
+
+	for _, query := range erasures {
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return storageKeys, nil
 }
 
-// Update updates a user
-func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
-	query := `UPDATE users SET email = $2, name = $3, updated_at = $4 WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, user.ID, user.Email, user.Name, user.UpdatedAt)
-	return err
+// closeAccount erases the user's email and name, removes their sign-in
+// methods and API keys, revokes their offices' widget tokens and stops their
+// offices' schedules and renewals, or returns ErrNotFound if the account is
+// already closed
+func closeAccount(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE users SET
+			email = 'deleted-' || id || '@deleted.invalid',
+			name = 'Deleted user',
+			password_hash = '',
+			email_verified_at = NULL,
+			pending_email = NULL,
+			deleted_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	cleanup := []string{
+		`DELETE FROM oauth_identities WHERE user_id = $1`,
+		`DELETE FROM auth_tokens WHERE user_id = $1`,
+		`UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`,
+		`UPDATE widget_tokens SET revoked_at = NOW()
+			WHERE revoked_at IS NULL AND office_id IN (SELECT id FROM offices WHERE user_id = $1)`,
+		`UPDATE scheduled_tasks SET is_active = FALSE, next_run_at = NULL
+			WHERE is_active AND office_id IN (SELECT id FROM offices WHERE user_id = $1)`,
+		`UPDATE subscriptions SET cancel_at_period_end = TRUE
+			WHERE office_id IN (SELECT id FROM offices WHERE user_id = $1)`,
+	}
+	for _, query := range cleanup {
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Delete deletes a user
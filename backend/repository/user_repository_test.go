@@ -0,0 +1,79 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+)
+
+func TestUserPendingEmailReplacesEmailOnlyWhenConfirmed(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewUserRepository(testDB.Pool)
+	user, err := repo.GetByID(ctx, testDB.User(t))
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if err := repo.ConfirmPendingEmail(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ConfirmPendingEmail without a pending email error = %v, want ErrNotFound", err)
+	}
+
+	current := user.Email
+	user.PendingEmail = "new-" + current
+	user.UpdatedAt = time.Now()
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.GetByEmail(ctx, current)
+	if err != nil || got.PendingEmail != user.PendingEmail || got.EmailVerifiedAt != nil {
+		t.Fatalf("GetByEmail = %+v, %v; want the current email with the new one pending", got, err)
+	}
+
+	if err := repo.ConfirmPendingEmail(ctx, user.ID); err != nil {
+		t.Fatalf("ConfirmPendingEmail: %v", err)
+	}
+	got, err = repo.GetByID(ctx, user.ID)
+	if err != nil || got.Email != "new-"+current || got.PendingEmail != "" || got.EmailVerifiedAt == nil {
+		t.Errorf("GetByID = %+v, %v; want the new email verified", got, err)
+	}
+
+	// Another account took the address before it was confirmed
+	other, err := repo.GetByID(ctx, testDB.User(t))
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	other.PendingEmail = got.Email
+	other.UpdatedAt = time.Now()
+	if err := repo.Update(ctx, other); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.ConfirmPendingEmail(ctx, other.ID); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("ConfirmPendingEmail of a taken address error = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestUserUpdatePasswordMovesTheTokenVersionOn(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewUserRepository(testDB.Pool)
+	id := testDB.User(t)
+	before, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	if err := repo.UpdatePassword(ctx, id, "hash"); err != nil {
+		t.Fatalf("UpdatePassword: %v", err)
+	}
+	after, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if after.TokenVersion != before.TokenVersion+1 {
+		t.Errorf("token version after UpdatePassword = %d, want %d", after.TokenVersion, before.TokenVersion+1)
+	}
+}
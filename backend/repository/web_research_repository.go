@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WebResearchRepository implements domain.WebResearchRepository
+type WebResearchRepository struct {
+	db conn
+}
+
+// NewWebResearchRepository creates a new WebResearchRepository
+func NewWebResearchRepository(db *pgxpool.Pool) *WebResearchRepository {
+	return &WebResearchRepository{db: conn{db}}
+}
+
+// GetSettings returns an office's web research settings
+func (r *WebResearchRepository) GetSettings(ctx context.Context, officeID uuid.UUID) (*domain.WebResearchSettings, error) {
+	query := `
+		SELECT office_id, allowed_domains, max_searches_per_task, updated_at
+		FROM office_web_research_settings
+		WHERE office_id = $1
+	`
+	var settings domain.WebResearchSettings
+	err := r.db.QueryRow(ctx, query, officeID).Scan(
+		&settings.OfficeID,
+		&settings.AllowedDomains,
+		&settings.MaxSearchesPerTask,
+		&settings.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpsertSettings saves an office's web research settings
+func (r *WebResearchRepository) UpsertSettings(ctx context.Context, settings *domain.WebResearchSettings) error {
+	query := `
+		INSERT INTO office_web_research_settings (office_id, allowed_domains, max_searches_per_task, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (office_id) DO UPDATE
+		SET allowed_domains = EXCLUDED.allowed_domains,
+			max_searches_per_task = EXCLUDED.max_searches_per_task,
+			updated_at = EXCLUDED.updated_at
+	`
+	allowedDomains := settings.AllowedDomains
+	if allowedDomains == nil {
+		allowedDomains = []string{}
+	}
+	_, err := r.db.Exec(ctx, query, settings.OfficeID, allowedDomains, settings.MaxSearchesPerTask, settings.UpdatedAt)
+	return err
+}
+
+// RecordSearch stores a search an agent ran. A search already recorded
+// under its ID is left as it is.
+func (r *WebResearchRepository) RecordSearch(ctx context.Context, search *domain.WebSearch) error {
+	query := `
+		INSERT INTO web_searches (id, office_id, task_id, agent_id, query, provider, cached, result_count, credits, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err := r.db.Exec(ctx, query,
+		search.ID,
+		search.OfficeID,
+		search.TaskID,
+		search.AgentID,
+		search.Query,
+		search.Provider,
+		search.Cached,
+		search.ResultCount,
+		search.Credits,
+		search.CreatedAt,
+	)
+	return err
+}
+
+// CountSearches returns how many of a task's searches were not answered
+// from the cache
+func (r *WebResearchRepository) CountSearches(ctx context.Context, taskID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM web_searches WHERE task_id = $1 AND NOT cached`, taskID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,67 @@
+//go:build integration
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/repository"
+	"github.com/google/uuid"
+)
+
+func TestWebResearchSettingsUpsert(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewWebResearchRepository(testDB.Pool)
+	office := testDB.Office(t, testDB.User(t))
+
+	if _, err := repo.GetSettings(ctx, office); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("GetSettings before any change error = %v, want ErrNotFound", err)
+	}
+	settings := &domain.WebResearchSettings{OfficeID: office, MaxSearchesPerTask: 3, UpdatedAt: time.Now()}
+	if err := repo.UpsertSettings(ctx, settings); err != nil {
+		t.Fatalf("UpsertSettings: %v", err)
+	}
+	settings.AllowedDomains = []string{"go.dev"}
+	settings.MaxSearchesPerTask = 10
+	if err := repo.UpsertSettings(ctx, settings); err != nil {
+		t.Fatalf("UpsertSettings again: %v", err)
+	}
+
+	got, err := repo.GetSettings(ctx, office)
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if !slices.Equal(got.AllowedDomains, []string{"go.dev"}) || got.MaxSearchesPerTask != 10 {
+		t.Errorf("GetSettings = %+v, want the updated settings", got)
+	}
+}
+
+func TestWebSearchCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	cache := repository.NewWebSearchCacheRepository(testDB.Pool)
+	fresh, stale := uuid.NewString(), uuid.NewString()
+	results := []domain.WebSearchResult{{Title: "Go", URL: "https://go.dev"}}
+
+	if err := cache.Set(ctx, fresh, results, time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.Set(ctx, stale, results, -time.Second); err != nil {
+		t.Fatalf("Set expired: %v", err)
+	}
+
+	got, ok, err := cache.Get(ctx, fresh)
+	if err != nil || !ok || len(got) != 1 || got[0] != results[0] {
+		t.Errorf("Get = %v, %t, %v; want the cached results", got, ok, err)
+	}
+	if _, ok, err := cache.Get(ctx, stale); err != nil || ok {
+		t.Errorf("Get expired = %t, %v; want a miss", ok, err)
+	}
+	if purged, err := cache.PurgeExpired(ctx); err != nil || purged < 1 {
+		t.Errorf("PurgeExpired = %d, %v; want the expired results purged", purged, err)
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WebSearchCacheRepository implements domain.WebSearchCache in Postgres
+type WebSearchCacheRepository struct {
+	db conn
+}
+
+// NewWebSearchCacheRepository creates a new WebSearchCacheRepository
+func NewWebSearchCacheRepository(db *pgxpool.Pool) *WebSearchCacheRepository {
+	return &WebSearchCacheRepository{db: conn{db}}
+}
+
+// Get returns the unexpired results cached for key
+func (r *WebSearchCacheRepository) Get(ctx context.Context, key string) ([]domain.WebSearchResult, bool, error) {
+	var raw []byte
+	err := r.db.QueryRow(ctx, `SELECT results FROM web_search_cache WHERE key_hash = $1 AND expires_at > NOW()`, key).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var results []domain.WebSearchResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// Set caches results for key until the TTL passes, replacing what was
+// cached before
+func (r *WebSearchCacheRepository) Set(ctx context.Context, key string, results []domain.WebSearchResult, ttl time.Duration) error {
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO web_search_cache (key_hash, results, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key_hash) DO UPDATE
+		SET results = EXCLUDED.results, expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+	`
+	_, err = r.db.Exec(ctx, query, key, resultsJSON, time.Now().Add(ttl))
+	return err
+}
+
+// PurgeExpired deletes expired results
+func (r *WebSearchCacheRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM web_search_cache WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WebhookDeliveryRepository implements domain.WebhookDeliveryRepository
+type WebhookDeliveryRepository struct {
+	db conn
+}
+
+// NewWebhookDeliveryRepository creates a new WebhookDeliveryRepository
+func NewWebhookDeliveryRepository(db *pgxpool.Pool) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{db: conn{db}}
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, created_at, delivered_at`
+
+// Create queues deliveries in one round trip
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, attempts,
+			next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	batch := &pgx.Batch{}
+	for _, d := range deliveries {
+		batch.Queue(query, d.ID, d.WebhookID, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts,
+			d.NextAttemptAt, d.CreatedAt)
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// ClaimDue claims up to limit pending deliveries due at now. The status and
+// due time are checked again in the UPDATE, so a delivery claimed by another
+// worker meanwhile is skipped.
+func (r *WebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		) AND status = 'pending' AND next_attempt_at <= $1
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt logs an attempt and updates the delivery in one round trip
+func (r *WebhookDeliveryRepository) RecordAttempt(
+	ctx context.Context,
+	deliveryID uuid.UUID,
+	attempt domain.WebhookDeliveryAttempt,
+	status domain.WebhookDeliveryStatus,
+	nextAttemptAt *time.Time,
+) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		INSERT INTO webhook_delivery_attempts (id, delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, uuid.New(), deliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs, attempt.AttemptedAt)
+	batch.Queue(`
+		UPDATE webhook_deliveries
+		SET status = $2,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN $4 ELSE delivered_at END
+		WHERE id = $1
+	`, deliveryID, status, nextAttemptAt, attempt.AttemptedAt)
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// GetByWebhookID returns a page of a webhook's deliveries, newest first,
+// with their attempts loaded in a second query
+func (r *WebhookDeliveryRepository) GetByWebhookID(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*domain.WebhookDelivery, int, error) {
+	var total int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`, webhookID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Query(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	byID := make(map[uuid.UUID]*domain.WebhookDelivery)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+		byID[delivery.ID] = delivery
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(deliveries) == 0 {
+		return deliveries, total, nil
+	}
+
+	ids := make([]uuid.UUID, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+	attempts, err := r.db.Query(ctx, `
+		SELECT delivery_id, attempt, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY attempt
+	`, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer attempts.Close()
+
+	for attempts.Next() {
+		var deliveryID uuid.UUID
+		var attempt domain.WebhookDeliveryAttempt
+		err := attempts.Scan(&deliveryID, &attempt.Attempt, &attempt.StatusCode, &attempt.Error,
+			&attempt.DurationMs, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		delivery := byID[deliveryID]
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	return deliveries, total, attempts.Err()
+}
+
+// scanWebhookDelivery scans a row selected with webhookDeliveryColumns
+func scanWebhookDelivery(row pgx.Row) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	err := row.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.AttemptLog = []domain.WebhookDeliveryAttempt{}
+	return &delivery, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WebhookRepository implements domain.WebhookRepository
+type WebhookRepository struct {
+	db conn
+}
+
+// NewWebhookRepository creates a new WebhookRepository
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{db: conn{db}}
+}
+
+const webhookColumns = `id, office_id, url, secret, event_types, created_at`
+
+// Create stores a new webhook
+func (r *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	eventTypes := make([]string, len(webhook.EventTypes))
+	for i, eventType := range webhook.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+
+	query := `
+		INSERT INTO webhooks (id, office_id, url, secret, event_types, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(ctx, query,
+		webhook.ID, webhook.OfficeID, webhook.URL, webhook.Secret, eventTypes, webhook.CreatedAt,
+	)
+	return err
+}
+
+// GetByID returns a webhook by ID
+func (r *WebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return webhook, err
+}
+
+// GetByOfficeID returns an office's webhooks, newest first
+func (r *WebhookRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE office_id = $1 ORDER BY created_at DESC`
+	return r.query(ctx, query, officeID)
+}
+
+// GetSubscribed returns the office's webhooks that receive eventType
+func (r *WebhookRepository) GetSubscribed(ctx context.Context, officeID uuid.UUID, eventType domain.WebhookEventType) ([]*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE office_id = $1 AND $2 = ANY(event_types)`
+	return r.query(ctx, query, officeID, string(eventType))
+}
+
+// Delete removes a webhook and its deliveries
+func (r *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) query(ctx context.Context, query string, args ...any) ([]*domain.Webhook, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*domain.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	var eventTypes []string
+
+	err := row.Scan(&webhook.ID, &webhook.OfficeID, &webhook.URL, &webhook.Secret, &eventTypes, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.EventTypes = make([]domain.WebhookEventType, len(eventTypes))
+	for i, eventType := range eventTypes {
+		webhook.EventTypes[i] = domain.WebhookEventType(eventType)
+	}
+	return &webhook, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WidgetSessionRepository implements domain.WidgetSessionRepository
+type WidgetSessionRepository struct {
+	db conn
+}
+
+// NewWidgetSessionRepository creates a new WidgetSessionRepository
+func NewWidgetSessionRepository(db *pgxpool.Pool) *WidgetSessionRepository {
+	return &WidgetSessionRepository{db: conn{db}}
+}
+
+// Create stores a new visitor session
+func (r *WidgetSessionRepository) Create(ctx context.Context, session *domain.WidgetSession) error {
+	query := `
+		INSERT INTO widget_sessions (id, token_id, conversation_id, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.Exec(ctx, query,
+		session.ID, session.TokenID, session.ConversationID, session.CreatedAt, session.LastSeenAt,
+	)
+	return err
+}
+
+// GetByID returns a visitor session by ID
+func (r *WidgetSessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.WidgetSession, error) {
+	query := `SELECT id, token_id, conversation_id, created_at, last_seen_at FROM widget_sessions WHERE id = $1`
+
+	var session domain.WidgetSession
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&session.ID, &session.TokenID, &session.ConversationID, &session.CreatedAt, &session.LastSeenAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Touch records that the session's visitor is still around
+func (r *WidgetSessionRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `UPDATE widget_sessions SET last_seen_at = NOW() WHERE id = $1`, id)
+	return err
+}
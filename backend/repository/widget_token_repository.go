@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WidgetTokenRepository implements domain.WidgetTokenRepository
+type WidgetTokenRepository struct {
+	db conn
+}
+
+// NewWidgetTokenRepository creates a new WidgetTokenRepository
+func NewWidgetTokenRepository(db *pgxpool.Pool) *WidgetTokenRepository {
+	return &WidgetTokenRepository{db: conn{db}}
+}
+
+const widgetTokenColumns = `w.id, w.office_id, w.agent_id, w.created_by, w.name, w.token_prefix, w.token_hash,
+	w.allowed_origins, w.rate_limit, w.message_count, w.last_used_at, w.revoked_at, w.created_at`
+
+// Create stores a new widget token
+func (r *WidgetTokenRepository) Create(ctx context.Context, token *domain.WidgetToken) error {
+	query := `
+		INSERT INTO widget_tokens (id, office_id, agent_id, created_by, name, token_prefix, token_hash,
+			allowed_origins, rate_limit, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.OfficeID, token.AgentID, token.CreatedBy, token.Name, token.Prefix, token.TokenHash,
+		token.AllowedOrigins, token.RateLimit, token.CreatedAt,
+	)
+	return err
+}
+
+// GetByID returns a widget token by ID
+func (r *WidgetTokenRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.WidgetToken, error) {
+	query := `SELECT ` + widgetTokenColumns + ` FROM widget_tokens w WHERE w.id = $1`
+
+	token, err := scanWidgetToken(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return token, err
+}
+
+// GetByOfficeID returns an office's widget tokens, including revoked ones,
+// newest first, with the credits charged for their conversations' tasks
+func (r *WidgetTokenRepository) GetByOfficeID(ctx context.Context, officeID uuid.UUID) ([]*domain.WidgetToken, error) {
+	query := `
+		SELECT ` + widgetTokenColumns + `,
+			COALESCE((
+				SELECT SUM(t.credits_consumed)
+				FROM widget_sessions s
+				JOIN tasks t ON t.conversation_id = s.conversation_id
+				WHERE s.token_id = w.id
+			), 0)
+		FROM widget_tokens w
+		WHERE w.office_id = $1
+		ORDER BY w.created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, officeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*domain.WidgetToken
+	for rows.Next() {
+		var token domain.WidgetToken
+		err := rows.Scan(append(widgetTokenFields(&token), &token.CreditsUsed)...)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetActiveByHash looks up an unrevoked token by hash
+func (r *WidgetTokenRepository) GetActiveByHash(ctx context.Context, tokenHash string) (*domain.WidgetToken, error) {
+	query := `SELECT ` + widgetTokenColumns + ` FROM widget_tokens w WHERE w.token_hash = $1 AND w.revoked_at IS NULL`
+
+	token, err := scanWidgetToken(r.db.QueryRow(ctx, query, tokenHash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return token, err
+}
+
+// RecordMessage counts a visitor message sent through the token
+func (r *WidgetTokenRepository) RecordMessage(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE widget_tokens SET message_count = message_count + 1, last_used_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Revoke marks a token revoked; revoking an already revoked token is a no-op
+func (r *WidgetTokenRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE widget_tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// widgetTokenFields returns the scan destinations of widgetTokenColumns
+func widgetTokenFields(token *domain.WidgetToken) []any {
+	return []any{
+		&token.ID, &token.OfficeID, &token.AgentID, &token.CreatedBy, &token.Name, &token.Prefix, &token.TokenHash,
+		&token.AllowedOrigins, &token.RateLimit, &token.MessageCount, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt,
+	}
+}
+
+// scanWidgetToken scans a row selected with widgetTokenColumns
+func scanWidgetToken(row pgx.Row) (*domain.WidgetToken, error) {
+	var token domain.WidgetToken
+	if err := row.Scan(widgetTokenFields(&token)...); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// AdminService backs the admin back office: finding users and offices,
+// correcting their credits and tiers, watching failed tasks, settling
+// author payouts, verifying authors and running promo campaigns. Every
+// change an admin makes is recorded in the audit log.
+type AdminService struct {
+	adminRepo           domain.AdminRepository
+	officeRepo          domain.OfficeRepository
+	userRepo            domain.UserRepository
+	creditRepo          domain.CreditRepository
+	subscriptionService *SubscriptionService
+	earningsService     *EarningsService
+	promoService        *PromoService
+	authorService       *AuthorService
+	audit               *AuditService
+}
+
+// NewAdminService creates a new AdminService instance
+func NewAdminService(
+	adminRepo domain.AdminRepository,
+	officeRepo domain.OfficeRepository,
+	userRepo domain.UserRepository,
+	creditRepo domain.CreditRepository,
+	subscriptionService *SubscriptionService,
+	earningsService *EarningsService,
+	promoService *PromoService,
+	authorService *AuthorService,
+	audit *AuditService,
+) *AdminService {
+	return &AdminService{
+		adminRepo:           adminRepo,
+		officeRepo:          officeRepo,
+		userRepo:            userRepo,
+		creditRepo:          creditRepo,
+		subscriptionService: subscriptionService,
+		earningsService:     earningsService,
+		promoService:        promoService,
+		authorService:       authorService,
+		audit:               audit,
+	}
+}
+
+// AdminOfficeDetail is what the back office shows of one office
+type AdminOfficeDetail struct {
+	Office *domain.Office `json:"office"`
+	// Owner is nil once the owner closed their account
+	Owner        *domain.User         `json:"owner,omitempty"`
+	Subscription *domain.Subscription `json:"subscription,omitempty"`
+	Wallet       *domain.CreditWallet `json:"wallet,omitempty"`
+}
+
+// SearchUsers returns a page of users whose email or name contains query
+func (s *AdminService) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, int, error) {
+	return s.adminRepo.SearchUsers(ctx, strings.TrimSpace(query), limit, offset)
+}
+
+// SearchOffices returns a page of offices whose name or owner's email
+// contains query
+func (s *AdminService) SearchOffices(ctx context.Context, query string, limit, offset int) ([]*domain.AdminOffice, int, error) {
+	return s.adminRepo.SearchOffices(ctx, strings.TrimSpace(query), limit, offset)
+}
+
+// GetOffice returns an office with its owner, subscription and wallet
+func (s *AdminService) GetOffice(ctx context.Context, officeID uuid.UUID) (*AdminOfficeDetail, error) {
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	detail := &AdminOfficeDetail{Office: office}
+
+	detail.Owner, err = s.userRepo.GetByID(ctx, office.UserID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	detail.Subscription, err = s.subscriptionService.GetSubscriptionByOffice(ctx, officeID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	detail.Wallet, err = s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// AdjustCredits adds credits to an office's wallet, or takes them away if
+// amount is negative, as an adjustment transaction. Taking away more than
+// the balance returns ErrInsufficientCredits.
+func (s *AdminService) AdjustCredits(ctx context.Context, adminID, officeID uuid.UUID, amount int64, reason string) (*domain.CreditTransaction, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("%w: amount must not be zero", domain.ErrInvalidInput)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", domain.ErrInvalidInput)
+	}
+
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.creditRepo.AddCredits(ctx, wallet.ID, amount, domain.TransactionTypeAdjustment, reason, "admin", &adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminAdjustCredits,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   officeID,
+		OfficeID:   officeID,
+		Before:     map[string]any{"balance": wallet.Balance},
+		After:      map[string]any{"balance": tx.BalanceAfter},
+		Details:    map[string]any{"amount": amount, "reason": reason, "transaction_id": tx.ID},
+	})
+	return tx, nil
+}
+
+// ChangeTier moves an office to a tier directly; see SubscriptionService.SetTier
+func (s *AdminService) ChangeTier(ctx context.Context, adminID, officeID uuid.UUID, tier domain.SubscriptionTier, reason string) (*domain.Subscription, error) {
+	previous, err := s.subscriptionService.SetTier(ctx, officeID, tier)
+	if err != nil {
+		return nil, err
+	}
+
+	event := AuditEvent{
+		Action:     domain.AuditActionAdminChangeTier,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   officeID,
+		OfficeID:   officeID,
+		Before:     map[string]any{"tier": previous.Tier},
+		After:      map[string]any{"tier": tier},
+	}
+	if previous.PendingTier != nil {
+		event.Before["pending_tier"] = *previous.PendingTier
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		event.Details = map[string]any{"reason": reason}
+	}
+	s.audit.Record(ctx, event)
+	return s.subscriptionService.GetSubscriptionByOffice(ctx, officeID)
+}
+
+// ReloadTiers makes the tiers file the live tier configuration; see
+// SubscriptionService.ReloadTiers. It returns the tiers now live.
+func (s *AdminService) ReloadTiers(ctx context.Context, adminID uuid.UUID) ([]ListedTier, error) {
+	before := tierIDs(s.subscriptionService.ListTiers())
+	if err := s.subscriptionService.ReloadTiers(); err != nil {
+		return nil, err
+	}
+	tiers := s.subscriptionService.ListTiers()
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminReloadTiers,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityTierConfig,
+		Before:     map[string]any{"tiers": before},
+		After:      map[string]any{"tiers": tierIDs(tiers)},
+	})
+	return tiers, nil
+}
+
+// tierIDs returns the IDs of tiers
+func tierIDs(tiers []ListedTier) []domain.SubscriptionTier {
+	ids := make([]domain.SubscriptionTier, len(tiers))
+	for i, tier := range tiers {
+		ids[i] = tier.ID
+	}
+	return ids
+}
+
+// ListFailedTasks returns a page of failed and dead-lettered tasks across
+// every office
+func (s *AdminService) ListFailedTasks(ctx context.Context, limit, offset int) ([]*domain.Task, int, error) {
+	return s.adminRepo.ListFailedTasks(ctx, limit, offset)
+}
+
+// ListPayouts returns a page of every author's payout requests with the
+// given status, oldest first; an empty status lists them all
+func (s *AdminService) ListPayouts(ctx context.Context, status domain.PayoutStatus, limit, offset int) ([]domain.PayoutRequest, int, error) {
+	return s.earningsService.ListPayoutRequests(ctx, status, limit, offset)
+}
+
+// CompletePayout records that a pending payout was sent, with the Stripe
+// transfer that sent it
+func (s *AdminService) CompletePayout(ctx context.Context, adminID, payoutID uuid.UUID, stripeTransferID string) (*domain.PayoutRequest, error) {
+	payout, err := s.earningsService.GetPayoutRequest(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	if payout.Status != domain.PayoutStatusPending {
+		return nil, fmt.Errorf("%w: payout is %s, not pending", domain.ErrInvalidInput, payout.Status)
+	}
+
+	if err := s.earningsService.CompletePayout(ctx, payoutID, stripeTransferID); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminCompletePayout,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityPayout,
+		EntityID:   payoutID,
+		Before:     map[string]any{"status": payout.Status},
+		After:      map[string]any{"status": domain.PayoutStatusCompleted, "stripe_transfer_id": stripeTransferID},
+		Details:    map[string]any{"author_id": payout.AuthorID, "amount_cents": payout.AmountCents},
+	})
+	return s.earningsService.GetPayoutRequest(ctx, payoutID)
+}
+
+// RejectPayout fails a payout that has not been sent, returning its amount
+// to the author's balance
+func (s *AdminService) RejectPayout(ctx context.Context, adminID, payoutID uuid.UUID, reason string) (*domain.PayoutRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", domain.ErrInvalidInput)
+	}
+
+	previous, err := s.earningsService.GetPayoutRequest(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	payout, err := s.earningsService.RejectPayout(ctx, payoutID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminRejectPayout,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityPayout,
+		EntityID:   payoutID,
+		Before:     map[string]any{"status": previous.Status},
+		After:      map[string]any{"status": payout.Status},
+		Details:    map[string]any{"author_id": payout.AuthorID, "amount_cents": payout.AmountCents, "reason": reason},
+	})
+	return payout, nil
+}
+
+// ListRefunds returns a page of every office's refund requests with the
+// given status, oldest first; an empty status lists them all
+func (s *AdminService) ListRefunds(ctx context.Context, status domain.RefundRequestStatus, limit, offset int) ([]*domain.RefundRequest, int, error) {
+	return s.earningsService.ListRefundRequests(ctx, status, limit, offset)
+}
+
+// ApproveRefund refunds the purchase of a pending refund request; see
+// EarningsService.ApproveRefund
+func (s *AdminService) ApproveRefund(ctx context.Context, adminID, refundID uuid.UUID) (*domain.RefundRequest, error) {
+	refund, err := s.earningsService.ApproveRefund(ctx, refundID, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminApproveRefund,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityRefund,
+		EntityID:   refundID,
+		OfficeID:   refund.OfficeID,
+		Before:     map[string]any{"status": domain.RefundRequestStatusPending},
+		After:      map[string]any{"status": refund.Status, "stripe_refund_id": refund.StripeRefundID},
+		Details:    map[string]any{"purchase_id": refund.PurchaseID, "template_id": refund.TemplateID, "price_cents": refund.PriceCents},
+	})
+	return refund, nil
+}
+
+// RejectRefund turns down a pending refund request with the reason given
+// to the office
+func (s *AdminService) RejectRefund(ctx context.Context, adminID, refundID uuid.UUID, reason string) (*domain.RefundRequest, error) {
+	refund, err := s.earningsService.RejectRefund(ctx, refundID, adminID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminRejectRefund,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityRefund,
+		EntityID:   refundID,
+		OfficeID:   refund.OfficeID,
+		Before:     map[string]any{"status": domain.RefundRequestStatusPending},
+		After:      map[string]any{"status": refund.Status},
+		Details:    map[string]any{"purchase_id": refund.PurchaseID, "template_id": refund.TemplateID, "reason": refund.RejectionReason},
+	})
+	return refund, nil
+}
+
+// ListPromoCodes returns a page of the promo codes, newest first
+func (s *AdminService) ListPromoCodes(ctx context.Context, limit, offset int) ([]*domain.PromoCode, int, error) {
+	return s.promoService.ListCodes(ctx, limit, offset)
+}
+
+// ListPromoRedemptions returns a page of a promo code's redemptions, newest
+// first
+func (s *AdminService) ListPromoRedemptions(ctx context.Context, codeID uuid.UUID, limit, offset int) ([]*domain.PromoRedemption, int, error) {
+	return s.promoService.ListRedemptions(ctx, codeID, limit, offset)
+}
+
+// CreatePromoCode starts a promo campaign; see PromoService.CreateCode
+func (s *AdminService) CreatePromoCode(ctx context.Context, adminID uuid.UUID, input NewPromoCode) (*domain.PromoCode, error) {
+	code, err := s.promoService.CreateCode(ctx, adminID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminCreatePromo,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityPromoCode,
+		EntityID:   code.ID,
+		After: map[string]any{
+			"code":             code.Code,
+			"kind":             code.Kind,
+			"bonus_credits":    code.BonusCredits,
+			"discount_percent": code.DiscountPercent,
+			"discount_periods": code.DiscountPeriods,
+			"max_redemptions":  code.MaxRedemptions,
+			"expires_at":       code.ExpiresAt,
+		},
+	})
+	return code, nil
+}
+
+// DeactivatePromoCode ends a promo campaign before it expires
+func (s *AdminService) DeactivatePromoCode(ctx context.Context, adminID, codeID uuid.UUID) (*domain.PromoCode, error) {
+	code, err := s.promoService.DeactivateCode(ctx, codeID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminDeactivatePromo,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityPromoCode,
+		EntityID:   codeID,
+		Before:     map[string]any{"deactivated_at": nil},
+		After:      map[string]any{"deactivated_at": code.DeactivatedAt},
+		Details:    map[string]any{"code": code.Code, "redemptions": code.Redemptions},
+	})
+	return code, nil
+}
+
+// ListAuthorVerifications returns a page of the authors whose verification
+// has the status, oldest request first
+func (s *AdminService) ListAuthorVerifications(
+	ctx context.Context,
+	status domain.AuthorVerificationStatus,
+	limit, offset int,
+) ([]*domain.AuthorProfile, int, error) {
+	return s.authorService.ListVerificationRequests(ctx, status, limit, offset)
+}
+
+// VerifyAuthor grants the author of a pending verification request the
+// verified badge; see AuthorService.VerifyAuthor
+func (s *AdminService) VerifyAuthor(ctx context.Context, adminID, authorID uuid.UUID) (*domain.AuthorProfile, error) {
+	profile, err := s.authorService.VerifyAuthor(ctx, adminID, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminVerifyAuthor,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   authorID,
+		Before:     map[string]any{"verification_status": domain.AuthorVerificationPending},
+		After:      map[string]any{"verification_status": profile.Verification.Status},
+		Details:    map[string]any{"display_name": profile.DisplayName},
+	})
+	return profile, nil
+}
+
+// RejectAuthorVerification turns down a pending verification request with
+// the reason given to the author
+func (s *AdminService) RejectAuthorVerification(ctx context.Context, adminID, authorID uuid.UUID, reason string) (*domain.AuthorProfile, error) {
+	profile, err := s.authorService.RejectVerification(ctx, adminID, authorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminRejectVerification,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   authorID,
+		Before:     map[string]any{"verification_status": domain.AuthorVerificationPending},
+		After:      map[string]any{"verification_status": profile.Verification.Status},
+		Details:    map[string]any{"display_name": profile.DisplayName, "reason": profile.Verification.RejectionReason},
+	})
+	return profile, nil
+}
+
+// RevokeAuthorVerification takes a verified author's badge away with the
+// reason given to them
+func (s *AdminService) RevokeAuthorVerification(ctx context.Context, adminID, authorID uuid.UUID, reason string) (*domain.AuthorProfile, error) {
+	profile, err := s.authorService.RevokeVerification(ctx, adminID, authorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAdminRevokeVerification,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   authorID,
+		Before:     map[string]any{"verification_status": domain.AuthorVerificationVerified},
+		After:      map[string]any{"verification_status": profile.Verification.Status},
+		Details:    map[string]any{"display_name": profile.DisplayName, "reason": profile.Verification.RejectionReason},
+	})
+	return profile, nil
+}
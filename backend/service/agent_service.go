@@ -2,87 +2,269 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
 )
 
+const (
+	maxAgentNameLength       = 100
+	maxAgentPromptLength     = 8000
+	maxAgentAvatarURLLength  = 500
+	agentChangeHistoryLength = 100
+)
+
 // AgentService handles agent-related operations
 type AgentService struct {
-	agentRepo         domain.AgentRepository
-	agentTemplateRepo domain.AgentTemplateRepository
+	agentRepo           domain.AgentRepository
+	agentTemplateRepo   domain.AgentTemplateRepository
+	purchaseRepo        domain.TemplatePurchaseRepository
+	agentChangeRepo     domain.AgentChangeRepository
+	txManager           domain.TxManager
+	subscriptionService *SubscriptionService
+	audit               *AuditService
+	moderation          *ContentModerationService
 }
 
 // NewAgentService creates a new AgentService instance
-func NewAgentService(agentRepo domain.AgentRepository, agentTemplateRepo domain.AgentTemplateRepository) *AgentService {
+func NewAgentService(
+	agentRepo domain.AgentRepository,
+	agentTemplateRepo domain.AgentTemplateRepository,
+	purchaseRepo domain.TemplatePurchaseRepository,
+	agentChangeRepo domain.AgentChangeRepository,
+	txManager domain.TxManager,
+	subscriptionService *SubscriptionService,
+	audit *AuditService,
+	moderation *ContentModerationService,
+) *AgentService {
 	return &AgentService{
-		agentRepo:         agentRepo,
-		agentTemplateRepo: agentTemplateRepo,
+		agentRepo:           agentRepo,
+		agentTemplateRepo:   agentTemplateRepo,
+		purchaseRepo:        purchaseRepo,
+		agentChangeRepo:     agentChangeRepo,
+		txManager:           txManager,
+		subscriptionService: subscriptionService,
+		audit:               audit,
+		moderation:          moderation,
 	}
 }
 
-// GetAvailableTemplates returns all available agent templates
+// GetAvailableTemplates returns all approved agent templates
 func (s *AgentService) GetAvailableTemplates(ctx context.Context) ([]*domain.AgentTemplate, error) {
 	return s.agentTemplateRepo.GetAll(ctx)
 }
 
 // SelectAgentInput contains input for selecting an agent
 type SelectAgentInput struct {
-	OfficeID   uuid.UUID
+	OfficeID uuid.UUID
+	// UserID is the user hiring, who may try out their own templates
+	// before they are approved
+	UserID     uuid.UUID
 	TemplateID uuid.UUID
 	CustomName string
 }
 
-// SelectAgent adds an agent template to an office
+// SelectAgent adds an agent template to an office. It fails with
+// ErrTierLimitExceeded once the office has as many agents as its tier allows.
 func (s *AgentService) SelectAgent(ctx context.Context, input SelectAgentInput) (*domain.Agent, error) {
-	// Verify template exists
-	template, err := s.agentTemplateRepo.GetByID(ctx, input.TemplateID)
+	template, err := s.selectableTemplate(ctx, input.OfficeID, input.UserID, input.TemplateID)
 	if err != nil {
-		return nil, domain.ErrNotFound
-	}
-
-	// Create agent for office
-	agent := &domain.Agent{
-		ID:         uuid.New(),
-		OfficeID:   input.OfficeID,
-		TemplateID: input.TemplateID,
-		Template:   template,
-		CustomName: input.CustomName,
-		IsActive:   true,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		return nil, err
 	}
 
-	if err := s.agentRepo.Create(ctx, agent); err != nil {
+	agent := newOfficeAgent(input.OfficeID, template, input.CustomName)
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		agents, err := s.lockOfficeAgents(ctx, input.OfficeID)
+		if err != nil {
+			return err
+		}
+		if err := s.requireAgentCapacity(ctx, input.OfficeID, len(agents), 1); err != nil {
+			return err
+		}
+		return s.agentRepo.Create(ctx, agent)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return agent, nil
 }
 
+// AgentSelection is one agent to add in a batch selection
+type AgentSelection struct {
+	TemplateID uuid.UUID
+	CustomName string
+}
+
 // SelectMultipleAgentsInput contains input for selecting multiple agents
 type SelectMultipleAgentsInput struct {
-	OfficeID    uuid.UUID
-	TemplateIDs []uuid.UUID
+	OfficeID uuid.UUID
+	// UserID is the user hiring, as for SelectAgentInput
+	UserID uuid.UUID
+	Agents []AgentSelection
 }
 
-// SelectMultipleAgents adds multiple agent templates to an office
-func (s *AgentService) SelectMultipleAgents(ctx context.Context, input SelectMultipleAgentsInput) ([]*domain.Agent, error) {
-	var agents []*domain.Agent
+// SelectMultipleAgentsResult lists the agents added by a batch selection and
+// the templates skipped because the office already has an agent from them
+type SelectMultipleAgentsResult struct {
+	Agents  []*domain.Agent `json:"agents"`
+	Skipped []uuid.UUID     `json:"skipped"`
+}
 
-	for _, templateID := range input.TemplateIDs {
-		agent, err := s.SelectAgent(ctx, SelectAgentInput{
-			OfficeID:   input.OfficeID,
-			TemplateID: templateID,
-		})
-		if err != nil {
+// SelectMultipleAgents adds multiple agent templates to an office. Templates
+// the office already has an active agent from, or that are repeated, are
+// skipped. All templates and the tier limit are checked before anything is
+// written, and the agents are created in one transaction, so a failed
+// request adds none.
+func (s *AgentService) SelectMultipleAgents(ctx context.Context, input SelectMultipleAgentsInput) (*SelectMultipleAgentsResult, error) {
+	var result *SelectMultipleAgentsResult
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.selectMultipleAgents(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// selectMultipleAgents is SelectMultipleAgents within its transaction
+func (s *AgentService) selectMultipleAgents(ctx context.Context, input SelectMultipleAgentsInput) (*SelectMultipleAgentsResult, error) {
+	existing, err := s.lockOfficeAgents(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[uuid.UUID]bool, len(existing))
+	for _, agent := range existing {
+		present[agent.TemplateID] = true
+	}
+
+	result := &SelectMultipleAgentsResult{Agents: []*domain.Agent{}, Skipped: []uuid.UUID{}}
+	var selected []AgentSelection
+	for _, selection := range input.Agents {
+		if present[selection.TemplateID] {
+			result.Skipped = append(result.Skipped, selection.TemplateID)
+			continue
+		}
+		present[selection.TemplateID] = true
+		selected = append(selected, selection)
+	}
+
+	templateIDs := make([]uuid.UUID, len(selected))
+	for i, selection := range selected {
+		templateIDs[i] = selection.TemplateID
+	}
+	templates, err := s.agentTemplateRepo.GetByIDs(ctx, templateIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, selection := range selected {
+		template, ok := templates[selection.TemplateID]
+		if !ok {
+			return nil, domain.ErrNotFound
+		}
+		if err := s.requireSelectable(ctx, input.OfficeID, input.UserID, template); err != nil {
 			return nil, err
 		}
-		agents = append(agents, agent)
+		result.Agents = append(result.Agents, newOfficeAgent(input.OfficeID, template, selection.CustomName))
 	}
 
-	return agents, nil
+	if len(result.Agents) == 0 {
+		return result, nil
+	}
+	if err := s.requireAgentCapacity(ctx, input.OfficeID, len(existing), len(result.Agents)); err != nil {
+		return nil, err
+	}
+	if err := s.agentRepo.CreateMany(ctx, result.Agents); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// selectableTemplate returns a template the office may hire from
+func (s *AgentService) selectableTemplate(ctx context.Context, officeID, userID, templateID uuid.UUID) (*domain.AgentTemplate, error) {
+	template, err := s.agentTemplateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, domain.ErrNotFound
+	}
+	if err := s.requireSelectable(ctx, officeID, userID, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// requireSelectable returns ErrNotFound for templates not approved by
+// moderation, unless the user hiring wrote them, such as those held back
+// by the security scan, ErrPurchaseRequired for premium templates the
+// office has not bought, and ErrLicenseRequired for templates whose license
+// does not cover the office
+func (s *AgentService) requireSelectable(ctx context.Context, officeID, userID uuid.UUID, template *domain.AgentTemplate) error {
+	if template.Status != "approved" && (template.AuthorID == nil || *template.AuthorID != userID) {
+		return domain.ErrNotFound
+	}
+	if template.IsPremium {
+		owned, err := s.purchaseRepo.HasPurchased(ctx, officeID, template.ID)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return domain.ErrPurchaseRequired
+		}
+	}
+	return requireTemplateLicense(ctx, s.subscriptionService, officeID, template)
+}
+
+// lockOfficeAgents returns the office's active agents, locking the office
+// against concurrent hires until the transaction in ctx ends, so that
+// counting them and adding more cannot together exceed the tier limit
+func (s *AgentService) lockOfficeAgents(ctx context.Context, officeID uuid.UUID) ([]*domain.Agent, error) {
+	if err := s.agentRepo.LockOffice(ctx, officeID); err != nil {
+		return nil, err
+	}
+	return s.agentRepo.GetByOfficeID(ctx, officeID)
+}
+
+// requireAgentCapacity returns ErrTierLimitExceeded, with the limit and the
+// current count as details, unless the office's tier has room for adding
+// more agents on top of its current active ones
+func (s *AgentService) requireAgentCapacity(ctx context.Context, officeID uuid.UUID, current, adding int) error {
+	// CheckAgentLimit asks whether one more agent fits, which for a batch is
+	// the question for its last agent
+	allowed, limit, err := s.subscriptionService.CheckAgentLimit(ctx, officeID, current+adding-1)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return domain.WithDetails(
+			fmt.Errorf("%w: your tier allows at most %d agents", domain.ErrTierLimitExceeded, limit),
+			map[string]any{"limit": limit, "current": current},
+		)
+	}
+	return nil
+}
+
+// newOfficeAgent creates an active agent from a template, pinned to the
+// template's current release; later versions are opt-in upgrades
+func newOfficeAgent(officeID uuid.UUID, template *domain.AgentTemplate, customName string) *domain.Agent {
+	return &domain.Agent{
+		ID:              uuid.New(),
+		OfficeID:        officeID,
+		TemplateID:      template.ID,
+		Template:        template,
+		CustomName:      customName,
+		IsActive:        true,
+		TemplateVersion: template.Version,
+		LatestVersion:   template.Version,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
 }
 
 // GetOfficeAgents returns all agents in an office
@@ -90,20 +272,229 @@ func (s *AgentService) GetOfficeAgents(ctx context.Context, officeID uuid.UUID)
 	return s.agentRepo.GetByOfficeID(ctx, officeID)
 }
 
-// GetAgent returns an agent by ID
-func (s *AgentService) GetAgent(ctx context.Context, agentID uuid.UUID) (*domain.Agent, error) {
-	return s.agentRepo.GetByID(ctx, agentID)
+// GetAgent returns an agent of the office
+func (s *AgentService) GetAgent(ctx context.Context, officeID, agentID uuid.UUID) (*domain.Agent, error) {
+	return officeAgent(ctx, s.agentRepo, officeID, agentID)
 }
 
-// DeactivateAgent marks an agent as inactive
-func (s *AgentService) DeactivateAgent(ctx context.Context, agentID uuid.UUID) error {
-	agent, err := s.agentRepo.GetByID(ctx, agentID)
+// UpdateAgentInput contains customization changes for an agent. Nil fields
+// are left as is; empty strings clear the override so the template's value
+// applies again.
+type UpdateAgentInput struct {
+	OfficeID           uuid.UUID
+	AgentID            uuid.UUID
+	UserID             uuid.UUID
+	CustomName         *string
+	CustomSystemPrompt *string
+	CustomAvatarURL    *string
+}
+
+// UpdateAgent customizes an agent of the office and records each changed
+// field in the agent's history. Setting a custom system prompt requires a
+// tier with custom prompts, and the prompt is screened by content moderation.
+func (s *AgentService) UpdateAgent(ctx context.Context, input UpdateAgentInput) (*domain.Agent, error) {
+	agent, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID)
 	if err != nil {
-		return domain.ErrNotFound
+		return nil, err
+	}
+
+	var changes []*domain.AgentChange
+	var screenPrompt bool
+	change := func(field string, current *string, value *string) {
+		if value == nil || *value == *current {
+			return
+		}
+		changes = append(changes, &domain.AgentChange{
+			ID:        uuid.New(),
+			AgentID:   agent.ID,
+			OfficeID:  agent.OfficeID,
+			ChangedBy: &input.UserID,
+			Field:     field,
+			OldValue:  nullableValue(*current),
+			NewValue:  nullableValue(*value),
+			CreatedAt: time.Now(),
+		})
+		*current = *value
+	}
+
+	if input.CustomName != nil {
+		name := strings.TrimSpace(*input.CustomName)
+		if len(name) > maxAgentNameLength {
+			return nil, fmt.Errorf("%w: custom_name must be at most %d characters", domain.ErrInvalidInput, maxAgentNameLength)
+		}
+		change("custom_name", &agent.CustomName, &name)
 	}
 
-	agent.IsActive = false
+	if input.CustomSystemPrompt != nil {
+		prompt := strings.TrimSpace(*input.CustomSystemPrompt)
+		if len(prompt) > maxAgentPromptLength {
+			return nil, fmt.Errorf("%w: custom_system_prompt must be at most %d characters", domain.ErrInvalidInput, maxAgentPromptLength)
+		}
+		// Clearing a prompt is always allowed, so offices that downgrade
+		// can go back to the template's
+		if prompt != "" && prompt != agent.CustomSystemPrompt {
+			allowed, err := s.subscriptionService.CheckCustomPrompts(ctx, input.OfficeID)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				return nil, domain.WithDetails(
+					fmt.Errorf("%w: custom system prompts require a higher tier", domain.ErrFeatureNotAvailable),
+					map[string]any{"feature": "custom_prompts"},
+				)
+			}
+			screenPrompt = true
+		}
+		change("custom_system_prompt", &agent.CustomSystemPrompt, &prompt)
+	}
+
+	if input.CustomAvatarURL != nil {
+		avatarURL := strings.TrimSpace(*input.CustomAvatarURL)
+		if err := validateAvatarURL(avatarURL); err != nil {
+			return nil, err
+		}
+		change("custom_avatar_url", &agent.CustomAvatarURL, &avatarURL)
+	}
+
+	if len(changes) == 0 {
+		return agent, nil
+	}
+	if screenPrompt {
+		_, err := s.moderation.Screen(ctx, ModerationTarget{
+			Type:     domain.ModerationContentSystemPrompt,
+			Content:  agent.CustomSystemPrompt,
+			EntityID: agent.ID,
+			OfficeID: agent.OfficeID,
+			UserID:   input.UserID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The agent only changes together with its change log
 	agent.UpdatedAt = time.Now()
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.agentRepo.Update(ctx, agent); err != nil {
+			return err
+		}
+		return s.agentChangeRepo.Create(ctx, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
 
-	return s.agentRepo.Update(ctx, agent)
+// GetAgentChanges returns the most recent customization changes of an agent
+// of the office, newest first
+func (s *AgentService) GetAgentChanges(ctx context.Context, officeID, agentID uuid.UUID) ([]*domain.AgentChange, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+	return s.agentChangeRepo.GetByAgentID(ctx, agentID, agentChangeHistoryLength)
+}
+
+// validateAvatarURL accepts an empty string, which clears the override, or
+// an absolute http(s) URL
+func validateAvatarURL(avatarURL string) error {
+	if avatarURL == "" {
+		return nil
+	}
+	if len(avatarURL) > maxAgentAvatarURLLength {
+		return fmt.Errorf("%w: custom_avatar_url must be at most %d characters", domain.ErrInvalidInput, maxAgentAvatarURLLength)
+	}
+	u, err := url.Parse(avatarURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: custom_avatar_url must be an http or https URL", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+// nullableValue returns nil for an empty string
+func nullableValue(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// UpgradeTemplate moves an agent onto the latest approved version of its template
+func (s *AgentService) UpgradeTemplate(ctx context.Context, officeID, agentID uuid.UUID) (*domain.Agent, error) {
+	agent, err := officeAgent(ctx, s.agentRepo, officeID, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent.TemplateVersion == agent.LatestVersion {
+		return agent, nil
+	}
+	if !agent.UpdateAvailable {
+		return nil, fmt.Errorf("%w: the latest template version is awaiting moderation", domain.ErrInvalidInput)
+	}
+
+	agent.TemplateVersion = agent.LatestVersion
+	agent.UpdatedAt = time.Now()
+	if err := s.agentRepo.Update(ctx, agent); err != nil {
+		return nil, err
+	}
+
+	// Reload so the template reflects the new version
+	return s.agentRepo.GetByID(ctx, agentID)
+}
+
+// DeleteAgent deletes an agent of the office. It stops running and leaves
+// its conversations, and can be restored for deletionGracePeriod.
+func (s *AgentService) DeleteAgent(ctx context.Context, officeID, agentID uuid.UUID) error {
+	agent, err := officeAgent(ctx, s.agentRepo, officeID, agentID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.agentRepo.SoftDelete(ctx, agentID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAgentDelete,
+		EntityType: domain.AuditEntityAgent,
+		EntityID:   agentID,
+		OfficeID:   officeID,
+		Before:     map[string]any{"is_active": agent.IsActive},
+		After:      map[string]any{"is_active": false, "deleted": true},
+		Details:    map[string]any{"name": agent.GetName(), "template_id": agent.TemplateID},
+	})
+	return nil
+}
+
+// RestoreAgent brings back an agent of the office deleted within
+// deletionGracePeriod. It fails with ErrNotFound for agents deleted longer
+// ago, and with ErrTierLimitExceeded if the tier has no room for it.
+// Its schedules stay paused.
+func (s *AgentService) RestoreAgent(ctx context.Context, officeID, agentID uuid.UUID) (*domain.Agent, error) {
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		agents, err := s.lockOfficeAgents(ctx, officeID)
+		if err != nil {
+			return err
+		}
+		if err := s.requireAgentCapacity(ctx, officeID, len(agents), 1); err != nil {
+			return err
+		}
+		return s.agentRepo.Restore(ctx, officeID, agentID, restorableSince())
+	})
+	if err != nil {
+		return nil, err
+	}
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAgentRestore,
+		EntityType: domain.AuditEntityAgent,
+		EntityID:   agentID,
+		OfficeID:   officeID,
+		Before:     map[string]any{"is_active": false, "deleted": true},
+		After:      map[string]any{"is_active": true},
+		Details:    map[string]any{"name": agent.GetName(), "template_id": agent.TemplateID},
+	})
+	return agent, nil
 }
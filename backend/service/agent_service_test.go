@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// txKeyForTest marks contexts passed into the fake transaction
+type txKeyForTest struct{}
+
+// newTestTxManager returns a TxManager running fn directly, with a context
+// inTx recognizes
+func newTestTxManager(ctrl *gomock.Controller) *mocks.MockTxManager {
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(context.WithValue(ctx, txKeyForTest{}, true))
+		})
+	return txManager
+}
+
+// inTx reports whether ctx was passed in by newTestTxManager
+func inTx(ctx context.Context) bool {
+	tx, _ := ctx.Value(txKeyForTest{}).(bool)
+	return tx
+}
+
+func TestUpdateAgentWritesItsHistoryInTheSameTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	changes := mocks.NewMockAgentChangeRepository(ctrl)
+	svc := NewAgentService(agents, nil, nil, changes, newTestTxManager(ctrl), nil, nil, nil)
+
+	officeID := uuid.New()
+	agent := newOfficeAgent(officeID, &domain.AgentTemplate{ID: uuid.New(), Name: "Alex"}, "")
+	agents.EXPECT().GetByID(gomock.Any(), agent.ID).Return(agent, nil)
+	agents.EXPECT().Update(gomock.Any(), agent).DoAndReturn(func(ctx context.Context, _ *domain.Agent) error {
+		if !inTx(ctx) {
+			t.Error("Update ran outside the transaction")
+		}
+		return nil
+	})
+	historyErr := errors.New("history insert failed")
+	changes.EXPECT().Create(gomock.Any(), gomock.Len(1)).DoAndReturn(func(ctx context.Context, _ []*domain.AgentChange) error {
+		if !inTx(ctx) {
+			t.Error("the history was written outside the transaction")
+		}
+		return historyErr
+	})
+
+	name := "Sam"
+	_, err := svc.UpdateAgent(context.Background(), UpdateAgentInput{
+		OfficeID: officeID, AgentID: agent.ID, UserID: uuid.New(), CustomName: &name,
+	})
+	if !errors.Is(err, historyErr) {
+		t.Errorf("UpdateAgent error = %v, want the history insert's", err)
+	}
+}
+
+func TestSelectAgentHidesUnapprovedTemplatesFromAllButTheirAuthor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	templates := mocks.NewMockAgentTemplateRepository(ctrl)
+	subscriptions, m := newTestSubscriptionService(t)
+	svc := NewAgentService(agents, templates, nil, nil, newTestTxManager(ctrl), subscriptions, nil, nil)
+
+	officeID, authorID := uuid.New(), uuid.New()
+	for _, status := range []string{"pending", "rejected"} {
+		template := &domain.AgentTemplate{ID: uuid.New(), Name: "Auditor", AuthorID: &authorID, Status: status}
+		templates.EXPECT().GetByID(gomock.Any(), template.ID).Return(template, nil).Times(2)
+
+		_, err := svc.SelectAgent(context.Background(), SelectAgentInput{OfficeID: officeID, UserID: uuid.New(), TemplateID: template.ID})
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("SelectAgent of a %s template error = %v, want ErrNotFound", status, err)
+		}
+
+		m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+		agents.EXPECT().LockOffice(gomock.Any(), officeID).Return(nil)
+		agents.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return([]*domain.Agent{}, nil)
+		agents.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+		if _, err := svc.SelectAgent(context.Background(), SelectAgentInput{OfficeID: officeID, UserID: authorID, TemplateID: template.ID}); err != nil {
+			t.Errorf("SelectAgent of their own %s template by the author: %v", status, err)
+		}
+	}
+}
+
+func TestSelectMultipleAgentsRefusesTemplatesHeldBackByModeration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	templates := mocks.NewMockAgentTemplateRepository(ctrl)
+	svc := NewAgentService(agents, templates, nil, nil, newTestTxManager(ctrl), nil, nil, nil)
+
+	// Held back by the security scan, it awaits review; nothing is hired
+	officeID, authorID := uuid.New(), uuid.New()
+	approved := &domain.AgentTemplate{ID: uuid.New(), Name: "Helper", Status: "approved"}
+	flagged := &domain.AgentTemplate{ID: uuid.New(), Name: "Injector", AuthorID: &authorID, Status: "pending", RiskScore: 80}
+	agents.EXPECT().LockOffice(gomock.Any(), officeID).Return(nil)
+	agents.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return([]*domain.Agent{}, nil)
+	templates.EXPECT().GetByIDs(gomock.Any(), []uuid.UUID{approved.ID, flagged.ID}).
+		Return(map[uuid.UUID]*domain.AgentTemplate{approved.ID: approved, flagged.ID: flagged}, nil)
+
+	_, err := svc.SelectMultipleAgents(context.Background(), SelectMultipleAgentsInput{
+		OfficeID: officeID,
+		UserID:   uuid.New(),
+		Agents:   []AgentSelection{{TemplateID: approved.ID}, {TemplateID: flagged.ID}},
+	})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("SelectMultipleAgents error = %v, want ErrNotFound", err)
+	}
+}
@@ -2,50 +2,116 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
-	"github.com/denys89/syn-office/backend/repository"
 	"github.com/google/uuid"
 )
 
-// AnalyticsService handles usage analytics business logic
+const (
+	// defaultUsageDays is how many days analytics cover unless asked
+	defaultUsageDays = 30
+	// maxUsageRangeDays caps custom date ranges
+	maxUsageRangeDays = 366
+)
+
+// usageDateLayout is how analytics dates are written, YYYY-MM-DD
+const usageDateLayout = "2006-01-02"
+
+// AnalyticsService handles usage analytics business logic. Analytics are
+// read from the daily rollups the usage of each task is recorded into. With
+// fallback set, days without a rollup, because recording failed or the
+// usage predates it, are added up from the office's tasks instead.
 type AnalyticsService struct {
-	analyticsRepo *repository.AnalyticsRepository
+	analyticsRepo domain.AnalyticsRepository
 	creditRepo    domain.CreditRepository
+	officeRepo    domain.OfficeRepository
+	fallback      bool
 }
 
 // NewAnalyticsService creates a new analytics service
 func NewAnalyticsService(
-	analyticsRepo *repository.AnalyticsRepository,
+	analyticsRepo domain.AnalyticsRepository,
 	creditRepo domain.CreditRepository,
+	officeRepo domain.OfficeRepository,
+	fallback bool,
 ) *AnalyticsService {
 	return &AnalyticsService{
 		analyticsRepo: analyticsRepo,
 		creditRepo:    creditRepo,
+		officeRepo:    officeRepo,
+		fallback:      fallback,
 	}
 }
 
-// GetUsageSummary retrieves usage summary for an office
-func (s *AnalyticsService) GetUsageSummary(
-	ctx context.Context,
-	officeID uuid.UUID,
-	period string, // "today", "7d", "30d"
-) (*domain.UsageSummary, error) {
-	days := 30
-	switch period {
-	case "today":
-		days = 1
-	case "7d":
-		days = 7
-	case "30d":
-		days = 30
+// UsagePeriod selects the days analytics cover, in the office's timezone:
+// From to To, as YYYY-MM-DD and both included. A missing To is today and a
+// missing From is Days days back from To, so Days alone covers the last
+// days up to and including today.
+type UsagePeriod struct {
+	Days int
+	From string
+	To   string
+}
+
+// IsCustom reports whether the period names its days
+func (p UsagePeriod) IsCustom() bool {
+	return p.From != "" || p.To != ""
+}
+
+// GetUsageSummary summarises an office's usage over a period and compares
+// it with the period of the same length just before
+func (s *AnalyticsService) GetUsageSummary(ctx context.Context, officeID uuid.UUID, period UsagePeriod) (*domain.UsageSummary, error) {
+	dates, loc, err := s.resolvePeriod(ctx, officeID, period)
+	if err != nil {
+		return nil, err
 	}
 
-	summary, err := s.analyticsRepo.GetUsageSummary(ctx, officeID, days)
+	summary, err := s.usageSummary(ctx, officeID, dates)
+	if err != nil {
+		return nil, err
+	}
+	previousDates := dates.Previous()
+	previous, err := s.usageSummary(ctx, officeID, previousDates)
 	if err != nil {
 		return nil, err
 	}
 
+	switch days := dates.Days(); {
+	case period.IsCustom():
+		summary.Period = "custom"
+	case days == 1:
+		summary.Period = "today"
+	default:
+		summary.Period = fmt.Sprintf("%dd", days)
+	}
+	summary.From = dates.From.Format(usageDateLayout)
+	summary.To = dates.To.Format(usageDateLayout)
+	summary.Timezone = loc.String()
+	summary.Previous = &domain.UsageTotals{
+		From:             previousDates.From.Format(usageDateLayout),
+		To:               previousDates.To.Format(usageDateLayout),
+		CreditsUsed:      previous.CreditsUsed,
+		TasksExecuted:    previous.TasksExecuted,
+		TasksSucceeded:   previous.TasksSucceeded,
+		TasksFailed:      previous.TasksFailed,
+		TokensProcessed:  previous.TokensProcessed,
+		EstimatedCostUSD: previous.EstimatedCostUSD,
+		LocalModelRatio:  previous.LocalModelRatio,
+	}
+	summary.Change = &domain.UsageChange{
+		CreditsUsed:      usageDelta(float64(summary.CreditsUsed), float64(previous.CreditsUsed)),
+		TasksExecuted:    usageDelta(float64(summary.TasksExecuted), float64(previous.TasksExecuted)),
+		TasksSucceeded:   usageDelta(float64(summary.TasksSucceeded), float64(previous.TasksSucceeded)),
+		TasksFailed:      usageDelta(float64(summary.TasksFailed), float64(previous.TasksFailed)),
+		TokensProcessed:  usageDelta(float64(summary.TokensProcessed), float64(previous.TokensProcessed)),
+		EstimatedCostUSD: usageDelta(summary.EstimatedCostUSD, previous.EstimatedCostUSD),
+		LocalModelRatio:  usageDelta(summary.LocalModelRatio, previous.LocalModelRatio),
+	}
+
 	// Get current balance
 	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
 	if err == nil {
@@ -56,71 +122,110 @@ func (s *AnalyticsService) GetUsageSummary(
 }
 
 // GetUsageBreakdown retrieves detailed usage breakdown
-func (s *AnalyticsService) GetUsageBreakdown(
-	ctx context.Context,
-	officeID uuid.UUID,
-	days int,
-) (*domain.UsageBreakdown, error) {
-	if days <= 0 {
-		days = 30
+func (s *AnalyticsService) GetUsageBreakdown(ctx context.Context, officeID uuid.UUID, period UsagePeriod) (*domain.UsageBreakdown, error) {
+	dates, loc, err := s.resolvePeriod(ctx, officeID, period)
+	if err != nil {
+		return nil, err
 	}
 
-	byModel, err := s.analyticsRepo.GetUsageByModel(ctx, officeID, days)
+	byDay, fromTasks, err := s.dailyUsage(ctx, officeID, dates)
 	if err != nil {
 		return nil, err
 	}
 
-	byAgent, err := s.analyticsRepo.GetUsageByAgent(ctx, officeID, days)
+	byModel, err := s.modelUsage(ctx, officeID, dates, fromTasks)
 	if err != nil {
 		return nil, err
 	}
 
-	byDay, err := s.analyticsRepo.GetDailyUsage(ctx, officeID, days)
+	byAgent, err := s.agentUsage(ctx, officeID, dates, loc, fromTasks)
 	if err != nil {
 		return nil, err
 	}
 
 	return &domain.UsageBreakdown{
+		From:    dates.From.Format(usageDateLayout),
+		To:      dates.To.Format(usageDateLayout),
 		ByModel: byModel,
 		ByAgent: byAgent,
 		ByDay:   byDay,
 	}, nil
 }
 
-// GetDailyUsage retrieves daily usage trends
-func (s *AnalyticsService) GetDailyUsage(
-	ctx context.Context,
-	officeID uuid.UUID,
-	days int,
-) ([]domain.UsageDaily, error) {
-	if days <= 0 {
-		days = 30
+// GetDailyUsage retrieves daily usage trends, newest first, with the days
+// they cover
+func (s *AnalyticsService) GetDailyUsage(ctx context.Context, officeID uuid.UUID, period UsagePeriod) ([]domain.UsageDaily, domain.DateRange, error) {
+	dates, _, err := s.resolvePeriod(ctx, officeID, period)
+	if err != nil {
+		return nil, dates, err
 	}
-	return s.analyticsRepo.GetDailyUsage(ctx, officeID, days)
+	usage, _, err := s.dailyUsage(ctx, officeID, dates)
+	return usage, dates, err
 }
 
-// GetModelUsage retrieves usage breakdown by model
-func (s *AnalyticsService) GetModelUsage(
-	ctx context.Context,
-	officeID uuid.UUID,
-	days int,
-) ([]domain.UsageByModel, error) {
-	if days <= 0 {
-		days = 30
+// GetModelUsage retrieves usage breakdown by model, with the days it covers
+func (s *AnalyticsService) GetModelUsage(ctx context.Context, officeID uuid.UUID, period UsagePeriod) ([]domain.UsageByModel, domain.DateRange, error) {
+	dates, _, err := s.resolvePeriod(ctx, officeID, period)
+	if err != nil {
+		return nil, dates, err
 	}
-	return s.analyticsRepo.GetUsageByModel(ctx, officeID, days)
+	fromTasks, err := s.daysFromTasks(ctx, officeID, dates)
+	if err != nil {
+		return nil, dates, err
+	}
+	usage, err := s.modelUsage(ctx, officeID, dates, fromTasks)
+	return usage, dates, err
 }
 
-// GetAgentUsage retrieves usage breakdown by agent
-func (s *AnalyticsService) GetAgentUsage(
-	ctx context.Context,
-	officeID uuid.UUID,
-	days int,
-) ([]domain.UsageByAgent, error) {
-	if days <= 0 {
-		days = 30
+// GetAgentUsage retrieves usage breakdown by agent, with the agents'
+// performance scores and the days it covers
+func (s *AnalyticsService) GetAgentUsage(ctx context.Context, officeID uuid.UUID, period UsagePeriod) ([]domain.UsageByAgent, domain.DateRange, error) {
+	dates, loc, err := s.resolvePeriod(ctx, officeID, period)
+	if err != nil {
+		return nil, dates, err
+	}
+	fromTasks, err := s.daysFromTasks(ctx, officeID, dates)
+	if err != nil {
+		return nil, dates, err
 	}
-	return s.analyticsRepo.GetUsageByAgent(ctx, officeID, days)
+	usage, err := s.agentUsage(ctx, officeID, dates, loc, fromTasks)
+	return usage, dates, err
+}
+
+// BackfillUsageInput selects the usage rollups to rebuild from tasks
+type BackfillUsageInput struct {
+	// OfficeID limits the backfill to one office; nil rebuilds every office
+	OfficeID *uuid.UUID
+	// From and To are the first and last day to rebuild, as YYYY-MM-DD in
+	// each office's timezone
+	From string
+	To   string
+	// Overwrite replaces rollups that exist; otherwise only days without
+	// one are filled
+	Overwrite bool
+}
+
+// BackfillUsage rebuilds usage rollups from tasks and the credits charged
+// for them, for days whose usage was never recorded or was recorded wrong
+func (s *AnalyticsService) BackfillUsage(ctx context.Context, input BackfillUsageInput) (*domain.UsageBackfill, error) {
+	if input.From == "" || input.To == "" {
+		return nil, fmt.Errorf("%w: from and to are required", domain.ErrInvalidInput)
+	}
+	dates, err := usageDates(UsagePeriod{From: input.From, To: input.To}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := s.analyticsRepo.RebuildUsage(ctx, input.OfficeID, dates.Dates(), input.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UsageBackfill{
+		From:       dates.From.Format(usageDateLayout),
+		To:         dates.To.Format(usageDateLayout),
+		OfficeDays: written,
+		Overwrite:  input.Overwrite,
+	}, nil
 }
 
 // RecordTaskUsage records usage metrics for a completed task
@@ -143,3 +248,252 @@ func (s *AnalyticsService) RecordTaskUsage(
 		credits, inputTokens, outputTokens, isLocalModel, usdCost, success,
 	)
 }
+
+// resolvePeriod turns a period into the days it covers in the office's
+// timezone, which it also returns
+func (s *AnalyticsService) resolvePeriod(ctx context.Context, officeID uuid.UUID, period UsagePeriod) (domain.DateRange, *time.Location, error) {
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return domain.DateRange{}, nil, err
+	}
+	loc := officeLocation(office)
+
+	dates, err := usageDates(period, time.Now().In(loc))
+	if err != nil {
+		return domain.DateRange{}, nil, err
+	}
+	return dates, loc, nil
+}
+
+// usageDates resolves a period relative to now, a time in the office's
+// timezone
+func usageDates(period UsagePeriod, now time.Time) (domain.DateRange, error) {
+	days := period.Days
+	if days <= 0 {
+		days = defaultUsageDays
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var dates domain.DateRange
+	var err error
+	if period.To == "" {
+		dates.To = today
+	} else if dates.To, err = time.Parse(usageDateLayout, period.To); err != nil {
+		return dates, fmt.Errorf("%w: to must be a date as YYYY-MM-DD", domain.ErrInvalidInput)
+	}
+	if period.From == "" {
+		dates.From = dates.To.AddDate(0, 0, 1-days)
+	} else if dates.From, err = time.Parse(usageDateLayout, period.From); err != nil {
+		return dates, fmt.Errorf("%w: from must be a date as YYYY-MM-DD", domain.ErrInvalidInput)
+	}
+
+	if dates.From.After(dates.To) {
+		return dates, fmt.Errorf("%w: from must not be after to", domain.ErrInvalidInput)
+	}
+	if dates.Days() > maxUsageRangeDays {
+		return dates, fmt.Errorf("%w: date ranges cover at most %d days", domain.ErrInvalidInput, maxUsageRangeDays)
+	}
+	return dates, nil
+}
+
+// usageSummary totals the office's usage over dates. When falling back it
+// is added up from the daily usage, so that days from tasks count.
+func (s *AnalyticsService) usageSummary(ctx context.Context, officeID uuid.UUID, dates domain.DateRange) (*domain.UsageSummary, error) {
+	if !s.fallback {
+		return s.analyticsRepo.GetUsageSummary(ctx, officeID, dates)
+	}
+	daily, _, err := s.dailyUsage(ctx, officeID, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	// The same totals GetUsageSummary reads from the rollups
+	var summary domain.UsageSummary
+	var localTasks, paidTasks int
+	for _, d := range daily {
+		summary.CreditsUsed += d.CreditsConsumed
+		summary.TasksExecuted += d.TasksExecuted
+		summary.TasksSucceeded += d.TasksSucceeded
+		summary.TasksFailed += d.TasksFailed
+		summary.TokensProcessed += d.TotalTokens
+		summary.EstimatedCostUSD += d.EstimatedUSD
+		localTasks += d.LocalModelTasks
+		paidTasks += d.PaidModelTasks
+	}
+	if total := localTasks + paidTasks; total > 0 {
+		summary.LocalModelRatio = float64(localTasks) / float64(total) * 100
+	}
+	return &summary, nil
+}
+
+// dailyUsage reads the office's daily rollups over dates, newest first.
+// When falling back, days without a rollup are added up from the office's
+// tasks; it also returns those of them that had any.
+func (s *AnalyticsService) dailyUsage(ctx context.Context, officeID uuid.UUID, dates domain.DateRange) ([]domain.UsageDaily, []time.Time, error) {
+	usage, err := s.analyticsRepo.GetDailyUsage(ctx, officeID, dates)
+	if err != nil || !s.fallback || len(usage) == dates.Days() {
+		return usage, nil, err
+	}
+
+	recorded := make(map[string]bool, len(usage))
+	for _, u := range usage {
+		recorded[u.Date] = true
+	}
+	var missing []time.Time
+	for _, day := range dates.Dates() {
+		if !recorded[day.Format(usageDateLayout)] {
+			missing = append(missing, day)
+		}
+	}
+	fromTasks, err := s.analyticsRepo.AggregateDailyUsage(ctx, officeID, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	days := make([]time.Time, 0, len(fromTasks))
+	for _, u := range fromTasks {
+		u.FromTasks = true
+		usage = append(usage, u)
+		day, _ := time.Parse(usageDateLayout, u.Date)
+		days = append(days, day)
+	}
+	// Dates as YYYY-MM-DD sort as text
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Date > usage[j].Date })
+	return usage, days, nil
+}
+
+// daysFromTasks returns the days over dates whose usage is added up from
+// the office's tasks, if any
+func (s *AnalyticsService) daysFromTasks(ctx context.Context, officeID uuid.UUID, dates domain.DateRange) ([]time.Time, error) {
+	if !s.fallback {
+		return nil, nil
+	}
+	_, days, err := s.dailyUsage(ctx, officeID, dates)
+	return days, err
+}
+
+// modelUsage reads the office's usage per model over dates, adding in what
+// its tasks used on fromTasks
+func (s *AnalyticsService) modelUsage(ctx context.Context, officeID uuid.UUID, dates domain.DateRange, fromTasks []time.Time) ([]domain.UsageByModel, error) {
+	usage, err := s.analyticsRepo.GetUsageByModel(ctx, officeID, dates)
+	if err != nil || len(fromTasks) == 0 {
+		return usage, err
+	}
+	extra, err := s.analyticsRepo.AggregateUsageByModel(ctx, officeID, fromTasks)
+	if err != nil {
+		return nil, err
+	}
+
+	byModel := make(map[string]int, len(usage))
+	for i, u := range usage {
+		byModel[u.ModelName] = i
+	}
+	for _, e := range extra {
+		i, ok := byModel[e.ModelName]
+		if !ok {
+			usage = append(usage, e)
+			continue
+		}
+		u := &usage[i]
+		if tasks := u.TaskCount + e.TaskCount; tasks > 0 {
+			u.AvgLatencyMs = (u.AvgLatencyMs*u.TaskCount + e.AvgLatencyMs*e.TaskCount) / tasks
+		}
+		u.TaskCount += e.TaskCount
+		u.CreditsConsumed += e.CreditsConsumed
+		u.InputTokens += e.InputTokens
+		u.OutputTokens += e.OutputTokens
+		u.EstimatedUSD += e.EstimatedUSD
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].CreditsConsumed > usage[j].CreditsConsumed })
+	return usage, nil
+}
+
+// agentUsage reads the office's usage per agent over dates, adding in what
+// its tasks used on fromTasks, and scores the agents
+func (s *AnalyticsService) agentUsage(ctx context.Context, officeID uuid.UUID, dates domain.DateRange, loc *time.Location, fromTasks []time.Time) ([]domain.UsageByAgent, error) {
+	usage, err := s.analyticsRepo.GetUsageByAgent(ctx, officeID, dates)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromTasks) > 0 {
+		extra, err := s.analyticsRepo.AggregateUsageByAgent(ctx, officeID, fromTasks)
+		if err != nil {
+			return nil, err
+		}
+		usage = mergeAgentUsage(usage, extra)
+	}
+	if err := s.scoreAgentUsage(ctx, officeID, dates, loc, usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// mergeAgentUsage adds extra into usage, agent by agent
+func mergeAgentUsage(usage, extra []domain.UsageByAgent) []domain.UsageByAgent {
+	byAgent := make(map[uuid.UUID]int, len(usage))
+	for i, u := range usage {
+		byAgent[u.AgentID] = i
+	}
+	for _, e := range extra {
+		i, ok := byAgent[e.AgentID]
+		if !ok {
+			usage = append(usage, e)
+			continue
+		}
+		u := &usage[i]
+		u.TaskCount += e.TaskCount
+		u.CreditsConsumed += e.CreditsConsumed
+		u.InputTokens += e.InputTokens
+		u.OutputTokens += e.OutputTokens
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].CreditsConsumed > usage[j].CreditsConsumed })
+	return usage
+}
+
+// scoreAgentUsage sets the performance score of each agent over dates and
+// its change since the days before
+func (s *AnalyticsService) scoreAgentUsage(ctx context.Context, officeID uuid.UUID, dates domain.DateRange, loc *time.Location, usage []domain.UsageByAgent) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	// The days start and end at midnight in the office's timezone
+	window := func(dates domain.DateRange) (time.Time, time.Time) {
+		since := time.Date(dates.From.Year(), dates.From.Month(), dates.From.Day(), 0, 0, 0, 0, loc)
+		until := time.Date(dates.To.Year(), dates.To.Month(), dates.To.Day()+1, 0, 0, 0, 0, loc)
+		return since, until
+	}
+	since, until := window(dates)
+	current, err := s.analyticsRepo.GetAgentStats(ctx, officeID, since, until)
+	if err != nil {
+		return err
+	}
+	since, until = window(dates.Previous())
+	previous, err := s.analyticsRepo.GetAgentStats(ctx, officeID, since, until)
+	if err != nil {
+		return err
+	}
+
+	scores, previousScores := agentScores(current), agentScores(previous)
+	for i := range usage {
+		score, ok := scores[usage[i].AgentID]
+		if !ok {
+			continue
+		}
+		usage[i].Score = &score
+		if prev, ok := previousScores[usage[i].AgentID]; ok {
+			change := roundTo(score-prev, 1)
+			usage[i].ScoreChange = &change
+		}
+	}
+	return nil
+}
+
+// usageDelta compares a period's total with the previous period's
+func usageDelta(current, previous float64) domain.UsageDelta {
+	d := domain.UsageDelta{Delta: math.Round((current-previous)*10000) / 10000}
+	if previous != 0 {
+		percent := math.Round((current-previous)/math.Abs(previous)*10000) / 100
+		d.Percent = &percent
+	}
+	return d
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func parseDate(s string) time.Time {
+	t, err := time.Parse(usageDateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestUsageDates(t *testing.T) {
+	// Late in the evening in Tokyo, which is already the next day in UTC
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	now := time.Date(2026, 3, 10, 23, 30, 0, 0, tokyo)
+
+	tests := []struct {
+		name     string
+		period   UsagePeriod
+		from, to string
+		wantErr  bool
+	}{
+		{"default", UsagePeriod{}, "2026-02-09", "2026-03-10", false},
+		{"today", UsagePeriod{Days: 1}, "2026-03-10", "2026-03-10", false},
+		{"last week", UsagePeriod{Days: 7}, "2026-03-04", "2026-03-10", false},
+		{"custom", UsagePeriod{From: "2026-01-01", To: "2026-01-31"}, "2026-01-01", "2026-01-31", false},
+		{"from until today", UsagePeriod{From: "2026-03-01"}, "2026-03-01", "2026-03-10", false},
+		{"days back from to", UsagePeriod{Days: 7, To: "2026-01-31"}, "2026-01-25", "2026-01-31", false},
+		{"from after to", UsagePeriod{From: "2026-02-01", To: "2026-01-31"}, "", "", true},
+		{"too long", UsagePeriod{From: "2025-01-01", To: "2026-01-31"}, "", "", true},
+		{"bad date", UsagePeriod{From: "01/01/2026"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates, err := usageDates(tt.period, now)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("usageDates(%+v) error = %v, want ErrInvalidInput", tt.period, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("usageDates(%+v): %v", tt.period, err)
+			}
+			if !dates.From.Equal(parseDate(tt.from)) || !dates.To.Equal(parseDate(tt.to)) {
+				t.Errorf("usageDates(%+v) = %s to %s, want %s to %s", tt.period,
+					dates.From.Format(usageDateLayout), dates.To.Format(usageDateLayout), tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestGetUsageSummaryComparesWithThePreviousPeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	analytics := mocks.NewMockAnalyticsRepository(ctrl)
+	credits := mocks.NewMockCreditRepository(ctrl)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	svc := NewAnalyticsService(analytics, credits, offices, false)
+
+	officeID := uuid.New()
+	offices.EXPECT().GetByID(gomock.Any(), officeID).Return(&domain.Office{ID: officeID, Timezone: "Europe/Berlin"}, nil)
+	current := domain.DateRange{From: parseDate("2026-01-01"), To: parseDate("2026-01-31")}
+	previous := domain.DateRange{From: parseDate("2025-12-01"), To: parseDate("2025-12-31")}
+	analytics.EXPECT().GetUsageSummary(gomock.Any(), officeID, current).
+		Return(&domain.UsageSummary{CreditsUsed: 150, TasksExecuted: 10, LocalModelRatio: 0.5}, nil)
+	analytics.EXPECT().GetUsageSummary(gomock.Any(), officeID, previous).
+		Return(&domain.UsageSummary{CreditsUsed: 100, LocalModelRatio: 0.25}, nil)
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(&domain.CreditWallet{Balance: 42}, nil)
+
+	summary, err := svc.GetUsageSummary(context.Background(), officeID, UsagePeriod{From: "2026-01-01", To: "2026-01-31"})
+	if err != nil {
+		t.Fatalf("GetUsageSummary: %v", err)
+	}
+
+	if summary.Period != "custom" || summary.Timezone != "Europe/Berlin" || summary.CreditsRemaining != 42 {
+		t.Errorf("summary = %+v, want a custom period in Europe/Berlin with 42 credits remaining", summary)
+	}
+	if summary.Previous == nil || summary.Previous.From != "2025-12-01" || summary.Previous.CreditsUsed != 100 {
+		t.Errorf("previous = %+v, want December with 100 credits used", summary.Previous)
+	}
+	if c := summary.Change.CreditsUsed; c.Delta != 50 || c.Percent == nil || *c.Percent != 50 {
+		t.Errorf("credits change = %+v, want +50 (50%%)", c)
+	}
+	if c := summary.Change.TasksExecuted; c.Delta != 10 || c.Percent != nil {
+		t.Errorf("tasks change = %+v, want +10 without a percentage", c)
+	}
+	if c := summary.Change.LocalModelRatio; c.Delta != 0.25 || *c.Percent != 100 {
+		t.Errorf("local model ratio change = %+v, want +0.25 (100%%)", c)
+	}
+}
+
+func TestFallbackFillsMissingDaysFromTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	analytics := mocks.NewMockAnalyticsRepository(ctrl)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	svc := NewAnalyticsService(analytics, mocks.NewMockCreditRepository(ctrl), offices, true)
+
+	officeID := uuid.New()
+	agentID := uuid.New()
+	offices.EXPECT().GetByID(gomock.Any(), officeID).Return(&domain.Office{ID: officeID, Timezone: "UTC"}, nil)
+	dates := domain.DateRange{From: parseDate("2026-01-01"), To: parseDate("2026-01-03")}
+
+	// Only the 2nd has a rollup; the tasks of the 1st and 3rd are looked
+	// up and only the 3rd had any
+	analytics.EXPECT().GetDailyUsage(gomock.Any(), officeID, dates).
+		Return([]domain.UsageDaily{{Date: "2026-01-02", CreditsConsumed: 10, TasksExecuted: 1}}, nil)
+	analytics.EXPECT().AggregateDailyUsage(gomock.Any(), officeID, []time.Time{parseDate("2026-01-01"), parseDate("2026-01-03")}).
+		Return([]domain.UsageDaily{{Date: "2026-01-03", CreditsConsumed: 5, TasksExecuted: 2}}, nil)
+	fromTasks := []time.Time{parseDate("2026-01-03")}
+	analytics.EXPECT().GetUsageByModel(gomock.Any(), officeID, dates).
+		Return([]domain.UsageByModel{{ModelName: "llama3", TaskCount: 1, CreditsConsumed: 10, AvgLatencyMs: 100}}, nil)
+	analytics.EXPECT().AggregateUsageByModel(gomock.Any(), officeID, fromTasks).
+		Return([]domain.UsageByModel{{ModelName: "llama3", TaskCount: 1, CreditsConsumed: 3, AvgLatencyMs: 300}, {ModelName: "gpt-4o", TaskCount: 1, CreditsConsumed: 2}}, nil)
+	analytics.EXPECT().GetUsageByAgent(gomock.Any(), officeID, dates).Return(nil, nil)
+	analytics.EXPECT().AggregateUsageByAgent(gomock.Any(), officeID, fromTasks).
+		Return([]domain.UsageByAgent{{AgentID: agentID, TaskCount: 2, CreditsConsumed: 5}}, nil)
+	analytics.EXPECT().GetAgentStats(gomock.Any(), officeID, gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+
+	breakdown, err := svc.GetUsageBreakdown(context.Background(), officeID, UsagePeriod{From: "2026-01-01", To: "2026-01-03"})
+	if err != nil {
+		t.Fatalf("GetUsageBreakdown: %v", err)
+	}
+
+	if len(breakdown.ByDay) != 2 || breakdown.ByDay[0].Date != "2026-01-03" || !breakdown.ByDay[0].FromTasks || breakdown.ByDay[1].FromTasks {
+		t.Errorf("by day = %+v, want the 3rd from tasks before the 2nd's rollup", breakdown.ByDay)
+	}
+	if len(breakdown.ByModel) != 2 {
+		t.Fatalf("by model = %+v, want llama3 and gpt-4o", breakdown.ByModel)
+	}
+	if m := breakdown.ByModel[0]; m.ModelName != "llama3" || m.TaskCount != 2 || m.CreditsConsumed != 13 || m.AvgLatencyMs != 200 {
+		t.Errorf("llama3 = %+v, want 2 tasks, 13 credits and 200ms on average", m)
+	}
+	if len(breakdown.ByAgent) != 1 || breakdown.ByAgent[0].AgentID != agentID {
+		t.Errorf("by agent = %+v, want the agent from tasks", breakdown.ByAgent)
+	}
+}
+
+func TestBackfillUsageRequiresARange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	analytics := mocks.NewMockAnalyticsRepository(ctrl)
+	svc := NewAnalyticsService(analytics, mocks.NewMockCreditRepository(ctrl), mocks.NewMockOfficeRepository(ctrl), true)
+
+	if _, err := svc.BackfillUsage(context.Background(), BackfillUsageInput{From: "2026-01-01"}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("BackfillUsage without to error = %v, want ErrInvalidInput", err)
+	}
+
+	officeID := uuid.New()
+	analytics.EXPECT().RebuildUsage(gomock.Any(), &officeID, domain.DateRange{From: parseDate("2026-01-30"), To: parseDate("2026-02-01")}.Dates(), true).
+		Return(int64(3), nil)
+	backfill, err := svc.BackfillUsage(context.Background(), BackfillUsageInput{OfficeID: &officeID, From: "2026-01-30", To: "2026-02-01", Overwrite: true})
+	if err != nil {
+		t.Fatalf("BackfillUsage: %v", err)
+	}
+	if backfill.OfficeDays != 3 || backfill.From != "2026-01-30" || backfill.To != "2026-02-01" {
+		t.Errorf("backfill = %+v, want 3 office days from 2026-01-30 to 2026-02-01", backfill)
+	}
+}
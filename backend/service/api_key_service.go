@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix marks Synoffice API keys so they are easy to spot in
+	// logs and secret scanners
+	apiKeyPrefix = "syn_"
+	// apiKeyDisplayLength is how much of a key is stored in the clear
+	apiKeyDisplayLength = 12
+
+	maxAPIKeyNameLength = 100
+)
+
+// APIKeyService mints, authenticates and revokes API keys. Keys are bound
+// to an office and act on behalf of the user who created them.
+type APIKeyService struct {
+	apiKeyRepo          domain.APIKeyRepository
+	subscriptionService *SubscriptionService
+}
+
+// NewAPIKeyService creates a new APIKeyService instance
+func NewAPIKeyService(apiKeyRepo domain.APIKeyRepository, subscriptionService *SubscriptionService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:          apiKeyRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// CreateAPIKeyInput represents input for minting an API key
+type CreateAPIKeyInput struct {
+	OfficeID  uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	Scopes    []domain.APIKeyScope
+	ExpiresAt *time.Time
+}
+
+// CreateAPIKey mints a key for an office whose tier includes API access. The
+// returned secret is the only time the full key is available.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, input CreateAPIKeyInput) (*domain.APIKey, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return nil, "", fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxAPIKeyNameLength)
+	}
+	if len(input.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", domain.ErrInvalidInput)
+	}
+	for _, scope := range input.Scopes {
+		if scope != domain.APIKeyScopeRead && scope != domain.APIKeyScopeWrite {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", domain.ErrInvalidInput, scope)
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", domain.ErrInvalidInput)
+	}
+
+	if err := s.requireAPIAccess(ctx, input.OfficeID); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := &domain.APIKey{
+		ID:        uuid.New(),
+		OfficeID:  input.OfficeID,
+		UserID:    input.UserID,
+		Name:      name,
+		Prefix:    secret[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(secret),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// GetAPIKeys returns an office's API keys, including revoked ones
+func (s *APIKeyService) GetAPIKeys(ctx context.Context, officeID uuid.UUID) ([]*domain.APIKey, error) {
+	return s.apiKeyRepo.GetByOfficeID(ctx, officeID)
+}
+
+// RevokeAPIKey revokes one of the office's keys; it stops working immediately
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, officeID, keyID uuid.UUID) error {
+	key, err := s.apiKeyRepo.GetByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	if key.OfficeID != officeID {
+		return domain.ErrNotFound
+	}
+	return s.apiKeyRepo.Revoke(ctx, keyID)
+}
+
+// Authenticate resolves a presented key and records its use. Unknown,
+// revoked and expired keys, and keys of offices whose tier no longer
+// includes API access, are rejected with ErrUnauthorized.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, domain.ErrUnauthorized
+	}
+
+	key, err := s.apiKeyRepo.Use(ctx, hashAPIKey(secret))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireAPIAccess(ctx, key.OfficeID); err != nil {
+		if errors.Is(err, domain.ErrFeatureNotAvailable) {
+			return nil, domain.ErrUnauthorized
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// requireAPIAccess returns ErrFeatureNotAvailable unless the office's tier
+// includes API access
+func (s *APIKeyService) requireAPIAccess(ctx context.Context, officeID uuid.UUID) error {
+	allowed, err := s.subscriptionService.CheckAPIAccess(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return domain.WithDetails(
+			fmt.Errorf("%w: API access requires a tier that includes it", domain.ErrFeatureNotAvailable),
+			map[string]any{"feature": "api_access"},
+		)
+	}
+	return nil
+}
+
+// newAPIKeySecret generates a random key
+func newAPIKeySecret() (string, error) {
+	return newSecretToken(apiKeyPrefix)
+}
+
+// newSecretToken generates a random token: prefix followed by 32 random
+// bytes, base64url-encoded
+func newSecretToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the stored form of a key. Keys are random and long, so
+// a fast unsalted hash is enough and keeps lookups by hash possible.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
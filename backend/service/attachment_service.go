@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// attachmentURLTTL is how long download URLs handed to users work
+	attachmentURLTTL = 15 * time.Minute
+	// maxAttachmentsPerMessage caps the files sent with one message
+	maxAttachmentsPerMessage = 10
+	// maxAttachmentFilenameLength is the longest kept filename, in
+	// characters
+	maxAttachmentFilenameLength = 255
+)
+
+// AttachmentService handles files uploaded to conversations
+type AttachmentService struct {
+	attachmentRepo      domain.AttachmentRepository
+	conversationRepo    domain.ConversationRepository
+	storage             domain.ObjectStorage
+	subscriptionService *SubscriptionService
+	// signingKey signs the download URLs of storages that cannot sign their
+	// own; publicURL is the backend base URL they point to
+	signingKey []byte
+	publicURL  string
+}
+
+// NewAttachmentService creates a new AttachmentService instance
+func NewAttachmentService(
+	attachmentRepo domain.AttachmentRepository,
+	conversationRepo domain.ConversationRepository,
+	storage domain.ObjectStorage,
+	subscriptionService *SubscriptionService,
+	signingSecret string,
+	publicURL string,
+) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo:      attachmentRepo,
+		conversationRepo:    conversationRepo,
+		storage:             storage,
+		subscriptionService: subscriptionService,
+		signingKey:          []byte(signingSecret),
+		publicURL:           strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// UploadAttachmentInput contains input for uploading a file
+type UploadAttachmentInput struct {
+	OfficeID       uuid.UUID
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	Filename       string
+	// ContentType is the type the client declared; it is guessed from the
+	// filename when missing
+	ContentType string
+	Size        int64
+	Body        io.Reader
+}
+
+// Upload stores a file in a conversation of the office, within the size and
+// type limits of the office's tier. The file is sent by passing its ID with
+// a message.
+func (s *AttachmentService) Upload(ctx context.Context, input UploadAttachmentInput) (*domain.Attachment, error) {
+	if _, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID); err != nil {
+		return nil, err
+	}
+
+	filename := cleanFilename(input.Filename)
+	if filename == "" {
+		return nil, fmt.Errorf("%w: a filename is required", domain.ErrInvalidInput)
+	}
+	if input.Size <= 0 {
+		return nil, fmt.Errorf("%w: the file is empty", domain.ErrInvalidInput)
+	}
+	contentType := attachmentContentType(input.ContentType, filename)
+
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	if features.MaxAttachmentMB <= 0 {
+		return nil, domain.WithDetails(
+			fmt.Errorf("%w: file attachments require a higher tier", domain.ErrFeatureNotAvailable),
+			map[string]any{"feature": "attachments"},
+		)
+	}
+	if limit := int64(features.MaxAttachmentMB) << 20; input.Size > limit {
+		return nil, domain.WithDetails(
+			fmt.Errorf("%w: files can be at most %d MB", domain.ErrTierLimitExceeded, features.MaxAttachmentMB),
+			map[string]any{"limit": limit, "current": input.Size},
+		)
+	}
+	if !attachmentTypeAllowed(contentType, features.AttachmentTypes) {
+		return nil, domain.WithDetails(
+			fmt.Errorf("%w: %s files are not accepted on this tier", domain.ErrFeatureNotAvailable, contentType),
+			map[string]any{"feature": "attachment_types", "content_type": contentType},
+		)
+	}
+
+	attachment := &domain.Attachment{
+		ID:             uuid.New(),
+		OfficeID:       input.OfficeID,
+		ConversationID: input.ConversationID,
+		UploadedBy:     input.UserID,
+		Filename:       filename,
+		ContentType:    contentType,
+		SizeBytes:      input.Size,
+		CreatedAt:      time.Now(),
+	}
+	attachment.StorageKey = "offices/" + attachment.OfficeID.String() +
+		"/conversations/" + attachment.ConversationID.String() + "/" + attachment.ID.String()
+
+	if err := s.storage.Put(ctx, attachment.StorageKey, io.LimitReader(input.Body, input.Size), input.Size, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+		s.deleteContents(ctx, attachment)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// DownloadURL returns a URL that downloads an attachment of the office and
+// when it expires
+func (s *AttachmentService) DownloadURL(ctx context.Context, officeID, attachmentID uuid.UUID) (string, time.Time, error) {
+	attachment, err := officeAttachment(ctx, s.attachmentRepo, officeID, attachmentID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return s.SignedURL(ctx, attachment, attachmentURLTTL)
+}
+
+// SignedURL returns a URL that downloads the attachment until ttl passes,
+// without further authentication. It is the storage's own pre-signed URL
+// where it has one, and otherwise a signed URL of the API's content
+// endpoint.
+func (s *AttachmentService) SignedURL(ctx context.Context, attachment *domain.Attachment, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	signed, err := s.storage.PresignGet(ctx, attachment.StorageKey, attachment.Filename, ttl)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if signed != "" {
+		return signed, expiresAt, nil
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {s.contentSignature(attachment.ID, expires)},
+	}
+	return s.publicURL + "/api/v1/attachments/" + attachment.ID.String() + "/content?" + query.Encode(), expiresAt, nil
+}
+
+// OpenSigned checks a signed content URL's parameters and opens the
+// attachment's contents; the caller closes them. Invalid and expired
+// signatures are ErrForbidden.
+func (s *AttachmentService) OpenSigned(ctx context.Context, attachmentID uuid.UUID, expires, signature string) (*domain.Attachment, io.ReadCloser, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(s.contentSignature(attachmentID, expires))) {
+		return nil, nil, fmt.Errorf("%w: invalid download link", domain.ErrForbidden)
+	}
+	if time.Now().Unix() > expiresAt {
+		return nil, nil, fmt.Errorf("%w: the download link has expired", domain.ErrForbidden)
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := s.storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, contents, nil
+}
+
+// PendingAttachments loads attachments about to be sent with a message. They
+// must have been uploaded to the conversation by the sender and not sent yet.
+func (s *AttachmentService) PendingAttachments(ctx context.Context, conversationID, userID uuid.UUID, ids []uuid.UUID) ([]*domain.Attachment, error) {
+	ids = uniqueIDs(ids)
+	if len(ids) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("%w: a message can have at most %d attachments", domain.ErrInvalidInput, maxAttachmentsPerMessage)
+	}
+
+	attachments, err := s.attachmentRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) != len(ids) {
+		return nil, fmt.Errorf("%w: unknown attachment", domain.ErrInvalidInput)
+	}
+	for _, attachment := range attachments {
+		if attachment.ConversationID != conversationID || attachment.UploadedBy != userID {
+			return nil, fmt.Errorf("%w: attachment %s was not uploaded by you to this conversation", domain.ErrInvalidInput, attachment.ID)
+		}
+		if attachment.MessageID != nil {
+			return nil, fmt.Errorf("%w: attachment %s was already sent", domain.ErrInvalidInput, attachment.ID)
+		}
+	}
+	return attachments, nil
+}
+
+// AttachToMessage links pending attachments to the message they were sent
+// with
+func (s *AttachmentService) AttachToMessage(ctx context.Context, attachments []*domain.Attachment, messageID uuid.UUID) error {
+	ids := make([]uuid.UUID, len(attachments))
+	for i, attachment := range attachments {
+		ids[i] = attachment.ID
+	}
+	err := s.attachmentRepo.AttachToMessage(ctx, ids, messageID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: attachments were already sent", domain.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		attachment.MessageID = &messageID
+	}
+	return nil
+}
+
+// GetForMessages returns the attachments of each message
+func (s *AttachmentService) GetForMessages(ctx context.Context, messageIDs ...uuid.UUID) (map[uuid.UUID][]*domain.Attachment, error) {
+	return s.attachmentRepo.GetByMessageIDs(ctx, messageIDs)
+}
+
+// DeleteForMessage deletes a message's attachments and their contents
+func (s *AttachmentService) DeleteForMessage(ctx context.Context, messageID uuid.UUID) error {
+	attachments, err := s.attachmentRepo.DeleteByMessageID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		s.deleteContents(ctx, attachment)
+	}
+	return nil
+}
+
+// deleteContents removes an attachment's contents from storage. Failures
+// only leave an unreferenced object behind, so they are logged.
+func (s *AttachmentService) deleteContents(ctx context.Context, attachment *domain.Attachment) {
+	if err := s.storage.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("Failed to delete contents of attachment %s: %v", attachment.ID, err)
+	}
+}
+
+// contentSignature signs a content URL; the prefix keeps it from being valid
+// as any other signature made with the same secret
+func (s *AttachmentService) contentSignature(attachmentID uuid.UUID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte("attachment:" + attachmentID.String() + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// cleanFilename drops any directories and control characters from an
+// uploaded filename and shortens it to maxAttachmentFilenameLength
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentFilenameLength {
+		name = string([]rune(name)[:maxAttachmentFilenameLength])
+	}
+	return name
+}
+
+// attachmentContentType normalizes a declared media type, falling back to
+// the filename's extension and then to a generic binary type
+func attachmentContentType(declared, filename string) string {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err == nil && strings.Contains(mediaType, "/") && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(filename))); err == nil {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// attachmentTypeAllowed reports whether contentType matches one of a tier's
+// accepted types
+func attachmentTypeAllowed(contentType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		switch {
+		case pattern == "*/*":
+			return true
+		case strings.HasSuffix(pattern, "*"):
+			if strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case pattern == contentType:
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence of each
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// AuditActor is who is behind a request, as the audit log records it
+type AuditActor struct {
+	UserID   uuid.UUID
+	OfficeID uuid.UUID
+	IP       string
+}
+
+// AuditActorKey is the context key of a request's *AuditActor. The API
+// stores the actor in the request's locals, which its contexts expose as
+// values.
+type AuditActorKey struct{}
+
+// auditActorFrom returns the actor ctx attributes actions to, if any
+func auditActorFrom(ctx context.Context) AuditActor {
+	if actor, ok := ctx.Value(AuditActorKey{}).(*AuditActor); ok && actor != nil {
+		return *actor
+	}
+	return AuditActor{}
+}
+
+// AuditEvent is a sensitive operation to record. Who took it and from where
+// comes from the context it is recorded with.
+type AuditEvent struct {
+	Action     domain.AuditAction
+	EntityType string
+	EntityID   uuid.UUID
+	// OfficeID is the office affected, if any; its owner can read the entry
+	OfficeID uuid.UUID
+	// ActorID overrides the context's actor, as for a login, where nobody
+	// is signed in yet
+	ActorID uuid.UUID
+	Before  map[string]any
+	After   map[string]any
+	Details map[string]any
+}
+
+// AuditService writes the append-only audit log of sensitive operations
+// and reads it back for office owners and admins
+type AuditService struct {
+	auditRepo  domain.AuditRepository
+	officeRepo domain.OfficeRepository
+	userRepo   domain.UserRepository
+}
+
+// NewAuditService creates a new AuditService instance
+func NewAuditService(auditRepo domain.AuditRepository, officeRepo domain.OfficeRepository, userRepo domain.UserRepository) *AuditService {
+	return &AuditService{
+		auditRepo:  auditRepo,
+		officeRepo: officeRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// Record appends an event to the audit log. The operation has already
+// happened by then, so a failure to record it is logged rather than
+// returned.
+func (s *AuditService) Record(ctx context.Context, event AuditEvent) {
+	actor := auditActorFrom(ctx)
+	entry := &domain.AuditEntry{
+		ID:         uuid.New(),
+		Action:     event.Action,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		Before:     event.Before,
+		After:      event.After,
+		Details:    event.Details,
+		IP:         actor.IP,
+		CreatedAt:  time.Now(),
+	}
+	if event.ActorID != uuid.Nil {
+		entry.ActorID = &event.ActorID
+	} else if actor.UserID != uuid.Nil {
+		entry.ActorID = &actor.UserID
+	}
+	if event.OfficeID != uuid.Nil {
+		entry.OfficeID = &event.OfficeID
+	}
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		log.Printf("Failed to record %s of %s %s: %v", event.Action, event.EntityType, event.EntityID, err)
+	}
+}
+
+// ListForOffice returns a page of the office's audit log. Only the office's
+// owner and admins may read it.
+func (s *AuditService) ListForOffice(ctx context.Context, userID, officeID uuid.UUID, filter domain.AuditFilter, limit, offset int) ([]*domain.AuditEntry, int, error) {
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if office.UserID != userID {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, 0, err
+		}
+		if user == nil || !user.IsAdmin() {
+			return nil, 0, domain.ErrForbidden
+		}
+	}
+
+	filter.OfficeID = officeID
+	return s.auditRepo.List(ctx, filter, limit, offset)
+}
+
+// List returns a page of the audit log of every office (admin use)
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter, limit, offset int) ([]*domain.AuditEntry, int, error) {
+	return s.auditRepo.List(ctx, filter, limit, offset)
+}
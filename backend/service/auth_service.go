@@ -2,27 +2,70 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/mail"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// passwordResetTTL is how long a password reset link stays valid
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is how long an email verification link stays valid
+	emailVerificationTTL = 48 * time.Hour
+)
+
 // AuthService handles authentication operations
 type AuthService struct {
-	userRepo   domain.UserRepository
-	officeRepo domain.OfficeRepository
-	jwtSecret  []byte
+	userRepo      domain.UserRepository
+	officeRepo    domain.OfficeRepository
+	authTokenRepo domain.AuthTokenRepository
+	txManager     domain.TxManager
+	mailer        domain.Mailer
+	jwtSecret     []byte
+	// appURL is the frontend base URL that emailed links point to
+	appURL string
+
+	// subscriptions starts new offices on a trial
+	subscriptions *SubscriptionService
+	audit         *AuditService
 }
 
 // NewAuthService creates a new AuthService instance
-func NewAuthService(userRepo domain.UserRepository, officeRepo domain.OfficeRepository, jwtSecret string) *AuthService {
+func NewAuthService(
+	userRepo domain.UserRepository,
+	officeRepo domain.OfficeRepository,
+	authTokenRepo domain.AuthTokenRepository,
+	txManager domain.TxManager,
+	mailer domain.Mailer,
+	subscriptions *SubscriptionService,
+	audit *AuditService,
+	jwtSecret string,
+	appURL string,
+) *AuthService {
 	return &AuthService{
-		userRepo:   userRepo,
-		officeRepo: officeRepo,
-		jwtSecret:  []byte(jwtSecret),
+		userRepo:      userRepo,
+		officeRepo:    officeRepo,
+		authTokenRepo: authTokenRepo,
+		txManager:     txManager,
+		mailer:        mailer,
+		subscriptions: subscriptions,
+		audit:         audit,
+		jwtSecret:     []byte(jwtSecret),
+		appURL:        strings.TrimRight(appURL, "/"),
 	}
 }
 
@@ -51,6 +94,8 @@ type JWTClaims struct {
 	UserID   uuid.UUID `json:"user_id"`
 	OfficeID uuid.UUID `json:"office_id"`
 	Email    string    `json:"email"`
+	// TokenVersion is the user's token version when the token was issued
+	TokenVersion int `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
@@ -78,21 +123,15 @@ func (s *AuthService) Register(ctx context.Context, input RegisterInput) (*AuthR
 		UpdatedAt:    time.Now(),
 	}
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	office, err := s.createAccount(ctx, user, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	// Create default office
-	office := &domain.Office{
-		ID:        uuid.New(),
-		UserID:    user.ID,
-		Name:      user.Name + "'s Office",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := s.officeRepo.Create(ctx, office); err != nil {
-		return nil, err
+	// The account is usable straight away; a lost verification email can be
+	// resent, so it does not fail the registration
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
 	}
 
 	// Generate JWT token
@@ -118,9 +157,76 @@ func (s *AuthService) Login(ctx context.Context, input LoginInput) (*AuthRespons
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		s.audit.Record(ctx, AuditEvent{
+			Action:     domain.AuditActionLoginFailed,
+			EntityType: domain.AuditEntityUser,
+			EntityID:   user.ID,
+			Details:    map[string]any{"method": "password"},
+		})
 		return nil, domain.ErrInvalidCredentials
 	}
 
+	resp, err := s.session(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, resp, "password")
+	return resp, nil
+}
+
+// recordLogin adds a sign-in to the audit log
+func (s *AuthService) recordLogin(ctx context.Context, resp *AuthResponse, method string) {
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionLogin,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   resp.User.ID,
+		OfficeID:   resp.Office.ID,
+		ActorID:    resp.User.ID,
+		Details:    map[string]any{"method": method},
+	})
+}
+
+// createAccount stores a new user along with their default office, which
+// starts on a trial. The user, the office with its wallet and whatever link
+// stores for the user, if set, are created together or not at all.
+func (s *AuthService) createAccount(ctx context.Context, user *domain.User, link func(ctx context.Context) error) (*domain.Office, error) {
+	// Create default office
+	office := &domain.Office{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      user.Name + "'s Office",
+		Timezone:  "UTC",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := s.officeRepo.Create(ctx, office); err != nil {
+			return err
+		}
+		if link != nil {
+			return link(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Creating the office gave it the free tier, which it keeps if the
+	// trial cannot be started
+	if err := s.subscriptions.StartTrial(ctx, office.ID); err != nil {
+		log.Printf("Failed to start trial for office %s: %v", office.ID, err)
+	}
+	return office, nil
+}
+
+// session signs a user in to their oldest office; SwitchOffice moves them
+// to another
+func (s *AuthService) session(ctx context.Context, user *domain.User) (*AuthResponse, error) {
 	// Get user's office
 	offices, err := s.officeRepo.GetByUserID(ctx, user.ID)
 	if err != nil || len(offices) == 0 {
@@ -142,8 +248,36 @@ func (s *AuthService) Login(ctx context.Context, input LoginInput) (*AuthRespons
 	}, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
+// SwitchOffice signs the user in to another of their offices. It returns
+// ErrNotFound unless the office is the user's and not deleted.
+func (s *AuthService) SwitchOffice(ctx context.Context, userID, officeID uuid.UUID) (*AuthResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if office.UserID != user.ID || office.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+
+	token, err := s.generateToken(user, office)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		User:   user,
+		Office: office,
+		Token:  token,
+	}, nil
+}
+
+// ValidateToken validates a JWT token and returns the claims. Tokens of
+// deleted users or offices, and tokens issued before the user's password last
+// changed, are refused with ErrUnauthorized.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return s.jwtSecret, nil
 	})
@@ -157,15 +291,317 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, domain.ErrUnauthorized
 	}
 
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, domain.ErrUnauthorized
+	}
+
+	office, err := s.officeRepo.GetByID(ctx, claims.OfficeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if office.DeletedAt != nil {
+		return nil, domain.ErrUnauthorized
+	}
+
 	return claims, nil
 }
 
+// IsAdmin reports whether the user currently has the admin role
+func (s *AuthService) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin(), nil
+}
+
+// UpdateProfileInput contains profile changes; nil fields are left as is
+type UpdateProfileInput struct {
+	Name  *string
+	Email *string
+}
+
+// UpdateProfile changes a user's name or email. A new email address stays
+// pending, and the current one in use, until the user follows the link sent
+// to it; asking for the current address again drops the pending one.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, input UpdateProfileInput) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name must not be empty", domain.ErrInvalidInput)
+		}
+		user.Name = name
+	}
+
+	emailChanged := false
+	if input.Email != nil {
+		pending := *input.Email
+		if pending == user.Email {
+			pending = ""
+		}
+		if pending != "" {
+			existing, err := s.userRepo.GetByEmail(ctx, pending)
+			if err == nil && existing.ID != user.ID {
+				return nil, domain.ErrAlreadyExists
+			}
+			if err != nil && !errors.Is(err, domain.ErrNotFound) {
+				return nil, err
+			}
+		}
+		emailChanged = pending != user.PendingEmail
+		user.PendingEmail = pending
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		// Links sent to another address must not confirm this one
+		if err := s.authTokenRepo.Invalidate(ctx, user.ID, domain.AuthTokenEmailVerification); err != nil {
+			return nil, err
+		}
+		if user.PendingEmail != "" {
+			if err := s.sendVerificationEmail(ctx, user); err != nil {
+				log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+			}
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword sets a new password after checking the current one, ending
+// the user's sessions. Accounts created through social login have no
+// password yet and can set one without.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, currentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	// Reset links requested before the change must not undo it
+	return s.authTokenRepo.Invalidate(ctx, user.ID, domain.AuthTokenPasswordReset)
+}
+
+// DeleteAccount closes the user's account after checking their password,
+// which ends its sessions. Billing and marketplace records are kept, without
+// the user's details.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return err
+	}
+	return s.userRepo.SoftDelete(ctx, user.ID)
+}
+
+// checkPassword returns ErrInvalidCredentials unless password is the
+// user's password. Users without a password pass.
+func checkPassword(user *domain.User, password string) error {
+	if user.PasswordHash == "" {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return domain.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// ForgotPassword emails the user a link to reset their password. It succeeds
+// whether or not the address belongs to an account, so the endpoint cannot
+// be used to find out which addresses are registered.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the newest link works
+	if err := s.authTokenRepo.Invalidate(ctx, user.ID, domain.AuthTokenPasswordReset); err != nil {
+		return err
+	}
+	token, err := s.issueAuthToken(ctx, user.ID, domain.AuthTokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	message, err := mail.Render("password_reset", user.Email, mail.AccountData{
+		Name: user.Name,
+		Link: s.link("/reset-password", token),
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, message)
+	}
+	if err != nil {
+		// Not reported to the caller, as that would reveal the account exists
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword,
+// ending the user's sessions. The token can only be used once; receiving it
+// also proves the user owns the address, so it verifies the email too.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	authToken, err := s.authTokenRepo.Consume(ctx, s.hashAuthToken(domain.AuthTokenPasswordReset, token), domain.AuthTokenPasswordReset)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: reset link is invalid or has expired", domain.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, authToken.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := s.authTokenRepo.Invalidate(ctx, authToken.UserID, domain.AuthTokenPasswordReset); err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(ctx, authToken.UserID)
+}
+
+// VerifyEmail confirms a user's address using a token from their
+// verification email. A pending email replaces the user's email; it returns
+// ErrAlreadyExists if another account has taken the address since.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	authToken, err := s.authTokenRepo.Consume(ctx, s.hashAuthToken(domain.AuthTokenEmailVerification, token), domain.AuthTokenEmailVerification)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: verification link is invalid or has expired", domain.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, authToken.UserID)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail == "" {
+		return s.userRepo.MarkEmailVerified(ctx, user.ID)
+	}
+	return s.userRepo.ConfirmPendingEmail(ctx, user.ID)
+}
+
+// ResendVerification emails the user a new verification link, replacing
+// any earlier one. It returns ErrAlreadyExists if the address is verified
+// and no new one is pending.
+func (s *AuthService) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil && user.PendingEmail == "" {
+		return fmt.Errorf("%w: email is already verified", domain.ErrAlreadyExists)
+	}
+
+	if err := s.authTokenRepo.Invalidate(ctx, user.ID, domain.AuthTokenEmailVerification); err != nil {
+		return err
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// sendVerificationEmail issues a verification token and emails it to the
+// user's pending email, or their email if none is pending
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *domain.User) error {
+	token, err := s.issueAuthToken(ctx, user.ID, domain.AuthTokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	address := user.Email
+	if user.PendingEmail != "" {
+		address = user.PendingEmail
+	}
+	message, err := mail.Render("verify_email", address, mail.AccountData{
+		Name: user.Name,
+		Link: s.link("/verify-email", token),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, message)
+}
+
+// issueAuthToken stores a new single-use token for the user and returns it
+func (s *AuthService) issueAuthToken(ctx context.Context, userID uuid.UUID, purpose domain.AuthTokenPurpose, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	err := s.authTokenRepo.Create(ctx, &domain.AuthToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: s.hashAuthToken(purpose, token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// hashAuthToken signs a token with the server secret. Only the signature is
+// stored, so tokens cannot be recovered or forged from a copy of the
+// database; binding the purpose keeps one kind of token from redeeming
+// another.
+func (s *AuthService) hashAuthToken(purpose domain.AuthTokenPurpose, token string) string {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte(string(purpose) + ":" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// link builds a frontend URL carrying token
+func (s *AuthService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
 // generateToken creates a new JWT token
 func (s *AuthService) generateToken(user *domain.User, office *domain.Office) (string, error) {
 	claims := JWTClaims{
-		UserID:   user.ID,
-		OfficeID: office.ID,
-		Email:    user.Email,
+		UserID:       user.ID,
+		OfficeID:     office.ID,
+		Email:        user.Email,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
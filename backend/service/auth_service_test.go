@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestValidateTokenRefusesEndedSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	users := mocks.NewMockUserRepository(ctrl)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	svc := NewAuthService(users, offices, nil, nil, nil, nil, nil, "secret", "https://app.example.com")
+
+	user := &domain.User{ID: uuid.New(), Email: "ana@example.com", TokenVersion: 2}
+	office := &domain.Office{ID: uuid.New(), UserID: user.ID}
+	token, err := svc.generateToken(user, office)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+
+	deletedAt := time.Now()
+	tests := []struct {
+		name      string
+		user      *domain.User
+		err       error
+		office    *domain.Office
+		officeErr error
+		want      error
+	}{
+		{"current", &domain.User{ID: user.ID, TokenVersion: 2}, nil, office, nil, nil},
+		{"password changed since", &domain.User{ID: user.ID, TokenVersion: 3}, nil, nil, nil, domain.ErrUnauthorized},
+		{"account deleted", nil, domain.ErrNotFound, nil, nil, domain.ErrUnauthorized},
+		{"office deleted", &domain.User{ID: user.ID, TokenVersion: 2}, nil,
+			&domain.Office{ID: office.ID, UserID: user.ID, DeletedAt: &deletedAt}, nil, domain.ErrUnauthorized},
+		{"office gone", &domain.User{ID: user.ID, TokenVersion: 2}, nil, nil, domain.ErrNotFound, domain.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users.EXPECT().GetByID(gomock.Any(), user.ID).Return(tt.user, tt.err)
+			if tt.office != nil || tt.officeErr != nil {
+				offices.EXPECT().GetByID(gomock.Any(), office.ID).Return(tt.office, tt.officeErr)
+			}
+			claims, err := svc.ValidateToken(context.Background(), token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ValidateToken error = %v, want %v", err, tt.want)
+			}
+			if err == nil && claims.UserID != user.ID {
+				t.Errorf("claims are of user %s, want %s", claims.UserID, user.ID)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileKeepsANewEmailPendingUntilVerified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	users := mocks.NewMockUserRepository(ctrl)
+	tokens := mocks.NewMockAuthTokenRepository(ctrl)
+	mailer := mocks.NewMockMailer(ctrl)
+	svc := NewAuthService(users, nil, tokens, nil, mailer, nil, nil, "secret", "https://app.example.com")
+
+	user := &domain.User{ID: uuid.New(), Email: "ana@example.com", Name: "Ana"}
+	users.EXPECT().GetByID(gomock.Any(), user.ID).Return(user, nil)
+	users.EXPECT().GetByEmail(gomock.Any(), "someone@example.com").Return(nil, domain.ErrNotFound)
+	users.EXPECT().Update(gomock.Any(), user).Return(nil)
+	tokens.EXPECT().Invalidate(gomock.Any(), user.ID, domain.AuthTokenEmailVerification).Return(nil)
+	var issued *domain.AuthToken
+	tokens.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, token *domain.AuthToken) error {
+		issued = token
+		return nil
+	})
+	mailer.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, email domain.Email) error {
+		if email.To != "someone@example.com" {
+			t.Errorf("verification link sent to %s, want the new address", email.To)
+		}
+		return nil
+	})
+
+	email := "someone@example.com"
+	updated, err := svc.UpdateProfile(context.Background(), user.ID, UpdateProfileInput{Email: &email})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.Email != "ana@example.com" || updated.PendingEmail != email {
+		t.Errorf("user has email %s, pending %s; want the current one kept and the new one pending", updated.Email, updated.PendingEmail)
+	}
+
+	// Following the link swaps the address in
+	tokens.EXPECT().Consume(gomock.Any(), gomock.Any(), domain.AuthTokenEmailVerification).Return(issued, nil)
+	users.EXPECT().GetByID(gomock.Any(), user.ID).Return(updated, nil)
+	users.EXPECT().ConfirmPendingEmail(gomock.Any(), user.ID).Return(nil)
+	if err := svc.VerifyEmail(context.Background(), "token"); err != nil {
+		t.Errorf("VerifyEmail: %v", err)
+	}
+}
+
+func TestSwitchOfficeOnlyToTheUsersOffices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	users := mocks.NewMockUserRepository(ctrl)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	svc := NewAuthService(users, offices, nil, nil, nil, nil, nil, "secret", "https://app.example.com")
+
+	user := &domain.User{ID: uuid.New(), Email: "ana@example.com"}
+	deletedAt := time.Now()
+	imported := &domain.Office{ID: uuid.New(), UserID: user.ID}
+	deleted := &domain.Office{ID: uuid.New(), UserID: user.ID, DeletedAt: &deletedAt}
+	others := &domain.Office{ID: uuid.New(), UserID: uuid.New()}
+	users.EXPECT().GetByID(gomock.Any(), user.ID).Return(user, nil).AnyTimes()
+	for _, office := range []*domain.Office{imported, deleted, others} {
+		offices.EXPECT().GetByID(gomock.Any(), office.ID).Return(office, nil).AnyTimes()
+	}
+
+	resp, err := svc.SwitchOffice(context.Background(), user.ID, imported.ID)
+	if err != nil {
+		t.Fatalf("SwitchOffice: %v", err)
+	}
+	claims, err := svc.ValidateToken(context.Background(), resp.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.OfficeID != imported.ID || resp.Office != imported {
+		t.Errorf("SwitchOffice signed in to office %s, want %s", claims.OfficeID, imported.ID)
+	}
+
+	for name, office := range map[string]*domain.Office{"deleted": deleted, "another user's": others} {
+		if _, err := svc.SwitchOffice(context.Background(), user.ID, office.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("SwitchOffice to a %s office error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
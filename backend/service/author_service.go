@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// Author profile limits
+const (
+	MaxAuthorDisplayNameLength = 100
+	MaxAuthorBioLength         = 2000
+	MaxAuthorLinks             = 5
+	MaxAuthorLinkLabelLength   = 50
+	MaxAuthorLinkURLLength     = 500
+)
+
+// authorProfileListingLimit caps how many templates and bundles a public
+// profile shows
+const authorProfileListingLimit = 50
+
+// AuthorService handles template authors' public profiles and their
+// verification
+type AuthorService struct {
+	profileRepo     domain.AuthorProfileRepository
+	marketplaceRepo domain.MarketplaceRepository
+	bundleRepo      domain.TemplateBundleRepository
+	moderation      *ContentModerationService
+	notifications   *NotificationService
+}
+
+// NewAuthorService creates a new AuthorService instance
+func NewAuthorService(
+	profileRepo domain.AuthorProfileRepository,
+	marketplaceRepo domain.MarketplaceRepository,
+	bundleRepo domain.TemplateBundleRepository,
+	moderation *ContentModerationService,
+	notifications *NotificationService,
+) *AuthorService {
+	return &AuthorService{
+		profileRepo:     profileRepo,
+		marketplaceRepo: marketplaceRepo,
+		bundleRepo:      bundleRepo,
+		moderation:      moderation,
+		notifications:   notifications,
+	}
+}
+
+// GetPublicProfile returns an author's marketplace profile with what they
+// have on sale. Users with no approved public template have no public
+// profile.
+func (s *AuthorService) GetPublicProfile(ctx context.Context, authorID uuid.UUID) (*domain.AuthorProfile, error) {
+	profile, err := s.getProfile(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Stats.TemplateCount == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	profile.Templates, _, err = s.marketplaceRepo.ListTemplates(ctx, domain.MarketplaceFilter{
+		AuthorID: &authorID,
+		SortBy:   "popular",
+		Limit:    authorProfileListingLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	profile.Bundles, _, err = s.bundleRepo.List(ctx, domain.BundleFilter{
+		AuthorID: &authorID,
+		Limit:    authorProfileListingLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	profile.Verification = nil
+	return profile, nil
+}
+
+// GetProfile returns the author's own profile, with their verification
+func (s *AuthorService) GetProfile(ctx context.Context, userID uuid.UUID) (*domain.AuthorProfile, error) {
+	return s.getProfile(ctx, userID)
+}
+
+// AuthorProfileInput contains the author-editable fields of a profile. Nil
+// fields are left unchanged; an empty list of links removes them all.
+type AuthorProfileInput struct {
+	DisplayName *string             `json:"display_name"`
+	Bio         *string             `json:"bio"`
+	Links       []domain.AuthorLink `json:"links"`
+}
+
+// UpdateProfile edits the author's profile. A new display name is also put
+// on their templates and bundles. Profiles whose text content moderation
+// flags are saved and queued for admin review.
+func (s *AuthorService) UpdateProfile(ctx context.Context, userID uuid.UUID, input AuthorProfileInput) (*domain.AuthorProfile, error) {
+	profile, err := s.getProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*input.DisplayName)
+	}
+	if input.Bio != nil {
+		profile.Bio = strings.TrimSpace(*input.Bio)
+	}
+	if input.Links != nil {
+		links := make([]domain.AuthorLink, len(input.Links))
+		for i, link := range input.Links {
+			links[i] = domain.AuthorLink{Label: strings.TrimSpace(link.Label), URL: strings.TrimSpace(link.URL)}
+		}
+		profile.Links = links
+	}
+	if err := validateAuthorProfile(profile); err != nil {
+		return nil, err
+	}
+
+	content := []string{profile.DisplayName, profile.Bio}
+	for _, link := range profile.Links {
+		content = append(content, link.Label)
+	}
+	_, err = s.moderation.Screen(ctx, ModerationTarget{
+		Type:     domain.ModerationContentTemplate,
+		Content:  strings.Join(content, "\n\n"),
+		EntityID: userID,
+		UserID:   userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	profile.UpdatedAt = time.Now()
+	if err := s.profileRepo.Save(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// validateAuthorProfile checks an edited profile's display name, bio and
+// links
+func validateAuthorProfile(p *domain.AuthorProfile) error {
+	switch {
+	case p.DisplayName == "" || len(p.DisplayName) > MaxAuthorDisplayNameLength:
+		return fmt.Errorf("%w: display_name is required and must be at most %d characters", domain.ErrInvalidInput, MaxAuthorDisplayNameLength)
+	case len(p.Bio) > MaxAuthorBioLength:
+		return fmt.Errorf("%w: bio must be at most %d characters", domain.ErrInvalidInput, MaxAuthorBioLength)
+	case len(p.Links) > MaxAuthorLinks:
+		return fmt.Errorf("%w: at most %d links are allowed", domain.ErrInvalidInput, MaxAuthorLinks)
+	}
+	for _, link := range p.Links {
+		if link.Label == "" || len(link.Label) > MaxAuthorLinkLabelLength {
+			return fmt.Errorf("%w: link labels are required and must be at most %d characters", domain.ErrInvalidInput, MaxAuthorLinkLabelLength)
+		}
+		u, err := url.Parse(link.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(link.URL) > MaxAuthorLinkURLLength {
+			return fmt.Errorf("%w: link %q must be an http or https URL of at most %d characters",
+				domain.ErrInvalidInput, link.Label, MaxAuthorLinkURLLength)
+		}
+	}
+	return nil
+}
+
+// RequestVerification asks the admins to verify the author. Authors need an
+// approved public template to ask, and may ask again after a rejection.
+func (s *AuthorService) RequestVerification(ctx context.Context, userID uuid.UUID, message string) (*domain.AuthorProfile, error) {
+	profile, err := s.getProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch profile.Verification.Status {
+	case domain.AuthorVerificationPending:
+		return nil, fmt.Errorf("%w: verification was already requested", domain.ErrAlreadyExists)
+	case domain.AuthorVerificationVerified:
+		return nil, fmt.Errorf("%w: the author is already verified", domain.ErrAlreadyExists)
+	}
+	if profile.Stats.TemplateCount == 0 {
+		return nil, fmt.Errorf("%w: publish a template before asking to be verified", domain.ErrInvalidInput)
+	}
+
+	now := time.Now()
+	profile.Verification = &domain.AuthorVerification{
+		Status:      domain.AuthorVerificationPending,
+		Message:     strings.TrimSpace(message),
+		RequestedAt: &now,
+	}
+	err = s.profileRepo.SetVerification(ctx, profile, domain.AuthorVerificationNone, domain.AuthorVerificationRejected)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// ListVerificationRequests returns a page of the profiles whose
+// verification has the status, oldest request first (admin use)
+func (s *AuthorService) ListVerificationRequests(
+	ctx context.Context,
+	status domain.AuthorVerificationStatus,
+	limit, offset int,
+) ([]*domain.AuthorProfile, int, error) {
+	return s.profileRepo.ListByVerificationStatus(ctx, status, limit, offset)
+}
+
+// VerifyAuthor grants a pending request's author the verified badge and
+// tells them (admin use)
+func (s *AuthorService) VerifyAuthor(ctx context.Context, adminID, authorID uuid.UUID) (*domain.AuthorProfile, error) {
+	profile, err := s.getProfile(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	profile.Verification.Status = domain.AuthorVerificationVerified
+	profile.Verification.RejectionReason = ""
+	profile.Verification.ReviewedBy = &adminID
+	profile.Verification.ReviewedAt = &now
+	profile.VerifiedAt = &now
+	if err := s.profileRepo.SetVerification(ctx, profile, domain.AuthorVerificationPending); err != nil {
+		return nil, err
+	}
+
+	s.notifyVerification(ctx, profile, "You are now a verified author",
+		"Your marketplace profile now shows the verified badge.")
+	return profile, nil
+}
+
+// RejectVerification turns down a pending request, telling the author why
+// (admin use)
+func (s *AuthorService) RejectVerification(ctx context.Context, adminID, authorID uuid.UUID, reason string) (*domain.AuthorProfile, error) {
+	profile, err := s.reviewVerification(ctx, adminID, authorID, reason, domain.AuthorVerificationPending)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyVerification(ctx, profile, "Verification declined",
+		fmt.Sprintf("Your request to be verified was declined: %s", profile.Verification.RejectionReason))
+	return profile, nil
+}
+
+// RevokeVerification takes a verified author's badge away, telling them why
+// (admin use). They may ask to be verified again.
+func (s *AuthorService) RevokeVerification(ctx context.Context, adminID, authorID uuid.UUID, reason string) (*domain.AuthorProfile, error) {
+	profile, err := s.reviewVerification(ctx, adminID, authorID, reason, domain.AuthorVerificationVerified)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyVerification(ctx, profile, "Verified badge removed",
+		fmt.Sprintf("Your verified badge was removed: %s", profile.Verification.RejectionReason))
+	return profile, nil
+}
+
+// reviewVerification rejects the author's verification with the reason if
+// its status is from
+func (s *AuthorService) reviewVerification(
+	ctx context.Context,
+	adminID, authorID uuid.UUID,
+	reason string,
+	from domain.AuthorVerificationStatus,
+) (*domain.AuthorProfile, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", domain.ErrInvalidInput)
+	}
+	profile, err := s.getProfile(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	profile.Verification.Status = domain.AuthorVerificationRejected
+	profile.Verification.RejectionReason = reason
+	profile.Verification.ReviewedBy = &adminID
+	profile.Verification.ReviewedAt = &now
+	profile.VerifiedAt = nil
+	if err := s.profileRepo.SetVerification(ctx, profile, from); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// getProfile returns a user's profile with their stats
+func (s *AuthorService) getProfile(ctx context.Context, userID uuid.UUID) (*domain.AuthorProfile, error) {
+	profile, err := s.profileRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Stats, err = s.profileRepo.GetStats(ctx, userID); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// notifyVerification tells an author how their verification was decided
+func (s *AuthorService) notifyVerification(ctx context.Context, profile *domain.AuthorProfile, title, message string) {
+	_, err := s.notifications.NotifyUser(ctx, profile.UserID, domain.NotificationTypeAuthorVerification, title, message,
+		map[string]any{
+			"user_id": profile.UserID.String(),
+			"status":  string(profile.Verification.Status),
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify author %s of their verification: %v", profile.UserID, err)
+	}
+}
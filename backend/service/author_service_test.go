@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+type authorMocks struct {
+	profiles *mocks.MockAuthorProfileRepository
+	offices  *mocks.MockOfficeRepository
+}
+
+func newTestAuthorService(t *testing.T) (*AuthorService, authorMocks) {
+	ctrl := gomock.NewController(t)
+	m := authorMocks{
+		profiles: mocks.NewMockAuthorProfileRepository(ctrl),
+		offices:  mocks.NewMockOfficeRepository(ctrl),
+	}
+	notifications := NewNotificationService(
+		mocks.NewMockNotificationRepository(ctrl), mocks.NewMockNotificationPreferenceRepository(ctrl),
+		m.offices, mocks.NewMockUserRepository(ctrl), mocks.NewMockMailer(ctrl), mocks.NewMockEventPublisher(ctrl),
+		newTestWebhookDispatcher(ctrl), "")
+	moderation := NewContentModerationService(nil, nil, nil, nil)
+	svc := NewAuthorService(m.profiles, mocks.NewMockMarketplaceRepository(ctrl), mocks.NewMockTemplateBundleRepository(ctrl),
+		moderation, notifications)
+	return svc, m
+}
+
+// expectProfile returns the author's profile, with verification status and
+// published template count as given
+func (m authorMocks) expectProfile(userID uuid.UUID, status domain.AuthorVerificationStatus, templates int) {
+	m.profiles.EXPECT().GetByUserID(gomock.Any(), userID).Return(&domain.AuthorProfile{
+		UserID:       userID,
+		DisplayName:  "Ada",
+		Verification: &domain.AuthorVerification{Status: status},
+	}, nil)
+	m.profiles.EXPECT().GetStats(gomock.Any(), userID).Return(domain.AuthorStats{TemplateCount: templates}, nil)
+}
+
+func TestUpdateAuthorProfile(t *testing.T) {
+	userID := uuid.New()
+	name, bio := "  Ada Labs ", "Agents for support teams."
+	links := []domain.AuthorLink{{Label: "Website", URL: "https://ada.example.com"}}
+
+	tests := []struct {
+		name  string
+		input AuthorProfileInput
+		want  error
+	}{
+		{"valid", AuthorProfileInput{DisplayName: &name, Bio: &bio, Links: links}, nil},
+		{"blank display name", AuthorProfileInput{DisplayName: new(string)}, domain.ErrInvalidInput},
+		{"link that is not http", AuthorProfileInput{Links: []domain.AuthorLink{{Label: "Site", URL: "javascript:alert(1)"}}}, domain.ErrInvalidInput},
+		{"link without a label", AuthorProfileInput{Links: []domain.AuthorLink{{URL: "https://ada.example.com"}}}, domain.ErrInvalidInput},
+		{"too many links", AuthorProfileInput{Links: make([]domain.AuthorLink, MaxAuthorLinks+1)}, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestAuthorService(t)
+			m.expectProfile(userID, domain.AuthorVerificationNone, 1)
+			if tt.want == nil {
+				m.profiles.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, p *domain.AuthorProfile) error {
+						if p.DisplayName != "Ada Labs" || p.Bio != bio || len(p.Links) != 1 {
+							t.Errorf("saved %+v, want the trimmed display name, bio and links", p)
+						}
+						return nil
+					})
+			}
+
+			_, err := svc.UpdateProfile(context.Background(), userID, tt.input)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UpdateProfile error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    domain.AuthorVerificationStatus
+		templates int
+		want      error
+	}{
+		{"first request", domain.AuthorVerificationNone, 1, nil},
+		{"after a rejection", domain.AuthorVerificationRejected, 2, nil},
+		{"already pending", domain.AuthorVerificationPending, 1, domain.ErrAlreadyExists},
+		{"already verified", domain.AuthorVerificationVerified, 1, domain.ErrAlreadyExists},
+		{"nothing published", domain.AuthorVerificationNone, 0, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestAuthorService(t)
+			userID := uuid.New()
+			m.expectProfile(userID, tt.status, tt.templates)
+			if tt.want == nil {
+				m.profiles.EXPECT().SetVerification(gomock.Any(), gomock.Any(),
+					domain.AuthorVerificationNone, domain.AuthorVerificationRejected).Return(nil)
+			}
+
+			profile, err := svc.RequestVerification(context.Background(), userID, " We publish for Ada Labs. ")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RequestVerification error = %v, want %v", err, tt.want)
+			}
+			if err == nil && (profile.Verification.Status != domain.AuthorVerificationPending ||
+				profile.Verification.Message != "We publish for Ada Labs." || profile.Verification.RequestedAt == nil) {
+				t.Errorf("verification = %+v, want a pending request with the message", profile.Verification)
+			}
+		})
+	}
+}
+
+func TestVerifyAuthorGrantsBadgeToPendingRequest(t *testing.T) {
+	svc, m := newTestAuthorService(t)
+	adminID, authorID := uuid.New(), uuid.New()
+	m.expectProfile(authorID, domain.AuthorVerificationPending, 1)
+	m.profiles.EXPECT().SetVerification(gomock.Any(), gomock.Any(), domain.AuthorVerificationPending).DoAndReturn(
+		func(_ context.Context, p *domain.AuthorProfile, _ ...domain.AuthorVerificationStatus) error {
+			p.IsVerified = true
+			return nil
+		})
+	// The author has no office to notify
+	m.offices.EXPECT().GetByUserID(gomock.Any(), authorID).Return(nil, nil)
+
+	profile, err := svc.VerifyAuthor(context.Background(), adminID, authorID)
+	if err != nil {
+		t.Fatalf("VerifyAuthor: %v", err)
+	}
+	v := profile.Verification
+	if v.Status != domain.AuthorVerificationVerified || v.ReviewedBy == nil || *v.ReviewedBy != adminID || profile.VerifiedAt == nil {
+		t.Errorf("profile = %+v, verification = %+v; want the author verified by the admin", profile, v)
+	}
+}
+
+func TestRevokeVerificationRequiresReason(t *testing.T) {
+	svc, _ := newTestAuthorService(t)
+
+	_, err := svc.RevokeVerification(context.Background(), uuid.New(), uuid.New(), "  ")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("RevokeVerification error = %v, want ErrInvalidInput", err)
+	}
+}
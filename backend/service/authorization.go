@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// Office-scoped lookups report resources of other offices as
+// domain.ErrNotFound, so a caller cannot tell a foreign ID from an unknown one.
+
+// ensureOffice verifies that a resource owned by resourceOfficeID belongs to
+// the caller's office
+func ensureOffice(resourceOfficeID, officeID uuid.UUID) error {
+	if resourceOfficeID != officeID {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// officeAgent loads an agent of the caller's office
+func officeAgent(ctx context.Context, agentRepo domain.AgentRepository, officeID, agentID uuid.UUID) (*domain.Agent, error) {
+	agent, err := agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(agent.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+// officeConversation loads a conversation of the caller's office
+func officeConversation(
+	ctx context.Context,
+	conversationRepo domain.ConversationRepository,
+	officeID uuid.UUID,
+	conversationID uuid.UUID,
+) (*domain.Conversation, error) {
+	conversation, err := conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(conversation.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// officeMessage loads a message of the caller's office
+func officeMessage(ctx context.Context, messageRepo domain.MessageRepository, officeID, messageID uuid.UUID) (*domain.Message, error) {
+	message, err := messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(message.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// officeAttachment loads an attachment of the caller's office
+func officeAttachment(ctx context.Context, attachmentRepo domain.AttachmentRepository, officeID, attachmentID uuid.UUID) (*domain.Attachment, error) {
+	attachment, err := attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(attachment.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// runningTask loads a task the orchestrator may still be working on. What
+// it hands to running tasks, such as secrets, is refused once the task
+// finished.
+func runningTask(ctx context.Context, taskRepo domain.TaskRepository, taskID uuid.UUID) (*domain.Task, error) {
+	task, err := taskRepo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	switch task.Status {
+	case domain.TaskStatusPending, domain.TaskStatusThinking, domain.TaskStatusWorking:
+		return task, nil
+	}
+	return nil, fmt.Errorf("%w: the task is no longer running", domain.ErrForbidden)
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// autoTopUpPollInterval is how often the auto top-up worker looks for
+	// wallets below their threshold
+	autoTopUpPollInterval = time.Minute
+	autoTopUpBatchSize    = 50
+	// autoTopUpRetryAfter is how long a top-up stays pending before the
+	// worker charges it again, with the same idempotency key, assuming the
+	// first attempt was interrupted
+	autoTopUpRetryAfter = 10 * time.Minute
+	// autoTopUpGiveUpAfter is how long interrupted top-ups are retried.
+	// Stripe forgets idempotency keys after a day, so a later retry could
+	// charge twice.
+	autoTopUpGiveUpAfter = 23 * time.Hour
+	// autoTopUpHistoryLimit is how many past top-ups an office is shown
+	autoTopUpHistoryLimit = 50
+
+	maxLowBalanceThreshold = 1_000_000
+	maxAutoTopUpsPerDay    = 10
+
+	// autoTopUpReference is the reference type of the purchase transactions
+	// adding auto top-up credits. They are paid when charged, so they are
+	// not invoiced again.
+	autoTopUpReference = "auto_top_up"
+)
+
+// AutoTopUpService manages each wallet's low balance threshold and buys a
+// credit pack with the office's saved Stripe payment method when the
+// balance drops below it, if the office opted in
+type AutoTopUpService struct {
+	creditRepo          domain.CreditRepository
+	topUpRepo           domain.AutoTopUpRepository
+	subRepo             domain.SubscriptionRepository
+	txManager           domain.TxManager
+	creditService       *CreditService
+	subscriptionService *SubscriptionService
+	// billing charges the payment methods; auto top-up is unavailable
+	// without it
+	billing             domain.BillingProvider
+	notificationService *NotificationService
+	audit               *AuditService
+}
+
+// NewAutoTopUpService creates a new AutoTopUpService instance
+func NewAutoTopUpService(
+	creditRepo domain.CreditRepository,
+	topUpRepo domain.AutoTopUpRepository,
+	subRepo domain.SubscriptionRepository,
+	txManager domain.TxManager,
+	creditService *CreditService,
+	subscriptionService *SubscriptionService,
+	billing domain.BillingProvider,
+	notificationService *NotificationService,
+	audit *AuditService,
+) *AutoTopUpService {
+	return &AutoTopUpService{
+		creditRepo:          creditRepo,
+		topUpRepo:           topUpRepo,
+		subRepo:             subRepo,
+		txManager:           txManager,
+		creditService:       creditService,
+		subscriptionService: subscriptionService,
+		billing:             billing,
+		notificationService: notificationService,
+		audit:               audit,
+	}
+}
+
+// LowBalanceSettings is what an office sets for its low balance warning and
+// auto top-up
+type LowBalanceSettings struct {
+	Threshold        int64
+	AutoTopUpEnabled bool
+	// Package and PaymentMethodID are the credit pack bought and the saved
+	// Stripe payment method charged for it
+	Package         string
+	PaymentMethodID string
+	// MaxPerDay defaults to 1
+	MaxPerDay int
+}
+
+// UpdateSettings validates and saves the office's low balance settings.
+// Auto top-up needs a threshold, a credit pack on sale and a subscription
+// billed to a Stripe customer whose payment method it charges.
+func (s *AutoTopUpService) UpdateSettings(
+	ctx context.Context,
+	officeID, userID uuid.UUID,
+	settings LowBalanceSettings,
+) (*domain.CreditWallet, error) {
+	if settings.Threshold < 0 || settings.Threshold > maxLowBalanceThreshold {
+		return nil, fmt.Errorf("%w: threshold must be between 0 and %d", domain.ErrInvalidInput, maxLowBalanceThreshold)
+	}
+	if settings.MaxPerDay == 0 {
+		settings.MaxPerDay = 1
+	}
+	if settings.MaxPerDay < 1 || settings.MaxPerDay > maxAutoTopUpsPerDay {
+		return nil, fmt.Errorf("%w: max_per_day must be between 1 and %d", domain.ErrInvalidInput, maxAutoTopUpsPerDay)
+	}
+	settings.Package = strings.TrimSpace(settings.Package)
+	settings.PaymentMethodID = strings.TrimSpace(settings.PaymentMethodID)
+	if settings.AutoTopUpEnabled {
+		if err := s.checkAutoTopUp(ctx, officeID, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	wallet, err := s.creditService.EnsureWallet(ctx, officeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	before := lowBalanceSnapshot(wallet)
+	wallet.LowBalanceThreshold = settings.Threshold
+	wallet.AutoTopUpEnabled = settings.AutoTopUpEnabled
+	wallet.AutoTopUpPackage = settings.Package
+	wallet.AutoTopUpPaymentMethod = settings.PaymentMethodID
+	wallet.AutoTopUpMaxPerDay = settings.MaxPerDay
+	if err := s.creditRepo.UpdateLowBalanceSettings(ctx, wallet); err != nil {
+		return nil, fmt.Errorf("failed to save low balance settings: %w", err)
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionLowBalanceSettings,
+		ActorID:    userID,
+		EntityType: domain.AuditEntityWallet,
+		EntityID:   wallet.ID,
+		OfficeID:   officeID,
+		Before:     before,
+		After:      lowBalanceSnapshot(wallet),
+	})
+	return wallet, nil
+}
+
+// checkAutoTopUp rejects auto top-up settings that could not be charged
+func (s *AutoTopUpService) checkAutoTopUp(ctx context.Context, officeID uuid.UUID, settings LowBalanceSettings) error {
+	if settings.Threshold == 0 {
+		return fmt.Errorf("%w: auto top-up needs a threshold above 0", domain.ErrInvalidInput)
+	}
+	if _, ok := s.subscriptionService.GetCreditPackages()[settings.Package]; !ok {
+		return fmt.Errorf("%w: unknown credit package %q", domain.ErrInvalidInput, settings.Package)
+	}
+	if !strings.HasPrefix(settings.PaymentMethodID, "pm_") {
+		return fmt.Errorf("%w: payment_method_id must be a saved Stripe payment method", domain.ErrInvalidInput)
+	}
+	if s.billing == nil {
+		return fmt.Errorf("%w: auto top-up is not available", domain.ErrInvalidInput)
+	}
+
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if sub == nil || sub.StripeCustomerID == "" {
+		return fmt.Errorf("%w: auto top-up needs a subscription billed through Stripe", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+// ListTopUps returns the office's most recent top-ups, newest first
+func (s *AutoTopUpService) ListTopUps(ctx context.Context, officeID uuid.UUID) ([]*domain.AutoTopUp, error) {
+	return s.topUpRepo.ListByOffice(ctx, officeID, autoTopUpHistoryLimit)
+}
+
+// Run tops up wallets below their threshold, until ctx is cancelled. It does
+// nothing without a billing provider.
+func (s *AutoTopUpService) Run(ctx context.Context) {
+	if s.billing == nil {
+		return
+	}
+	ticker := time.NewTicker(autoTopUpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retryInterrupted(ctx)
+			s.topUpDueWallets(ctx)
+		}
+	}
+}
+
+// retryInterrupted charges top-ups again that stayed pending, such as those
+// of a replica that stopped mid-charge. The idempotency key makes Stripe
+// charge each once.
+func (s *AutoTopUpService) retryInterrupted(ctx context.Context) {
+	now := time.Now()
+	topUps, err := s.topUpRepo.GetStalePending(ctx, now.Add(-autoTopUpRetryAfter), autoTopUpBatchSize)
+	if err != nil {
+		log.Printf("Failed to load interrupted auto top-ups: %v", err)
+		return
+	}
+
+	for _, topUp := range topUps {
+		if ctx.Err() != nil {
+			return
+		}
+		if now.Sub(topUp.CreatedAt) > autoTopUpGiveUpAfter {
+			err = s.fail(ctx, topUp, errors.New("the payment could not be confirmed"))
+		} else {
+			err = s.charge(ctx, topUp)
+		}
+		if err != nil {
+			log.Printf("Failed to retry auto top-up %s: %v", topUp.ID, err)
+		}
+	}
+}
+
+// topUpDueWallets tops up one batch of wallets due a top-up
+func (s *AutoTopUpService) topUpDueWallets(ctx context.Context) {
+	dayStart := startOfUTCDay(time.Now())
+	wallets, err := s.topUpRepo.GetDueWallets(ctx, dayStart, autoTopUpBatchSize)
+	if err != nil {
+		log.Printf("Failed to load wallets to top up: %v", err)
+		return
+	}
+
+	for _, wallet := range wallets {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.topUp(ctx, wallet, dayStart); err != nil {
+			log.Printf("Failed to top up wallet %s: %v", wallet.ID, err)
+		}
+	}
+}
+
+// topUp claims and charges a top-up of the wallet's credit pack
+func (s *AutoTopUpService) topUp(ctx context.Context, wallet *domain.CreditWallet, dayStart time.Time) error {
+	pkg, ok := s.subscriptionService.GetCreditPackages()[wallet.AutoTopUpPackage]
+	if !ok || pkg.Credits <= 0 || pkg.PriceUSD <= 0 {
+		return s.disable(ctx, wallet, fmt.Sprintf("the %s credit pack is no longer sold", wallet.AutoTopUpPackage))
+	}
+
+	topUp := &domain.AutoTopUp{
+		ID:              uuid.New(),
+		WalletID:        wallet.ID,
+		Package:         wallet.AutoTopUpPackage,
+		Credits:         pkg.Credits,
+		AmountCents:     usdCents(pkg.PriceUSD),
+		PaymentMethodID: wallet.AutoTopUpPaymentMethod,
+		CreatedAt:       time.Now(),
+	}
+	err := s.topUpRepo.Claim(ctx, topUp, dayStart)
+	if errors.Is(err, domain.ErrNotFound) {
+		// Topped up, turned off or claimed by another replica since
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim top-up: %w", err)
+	}
+	return s.charge(ctx, topUp)
+}
+
+// charge charges a pending top-up and adds its credits. A declined payment
+// fails the top-up; other errors leave it pending to be retried.
+func (s *AutoTopUpService) charge(ctx context.Context, topUp *domain.AutoTopUp) error {
+	sub, err := s.subRepo.GetByOfficeID(ctx, topUp.OfficeID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if sub == nil || sub.StripeCustomerID == "" {
+		return s.fail(ctx, topUp, errors.New("the office is not billed through Stripe"))
+	}
+
+	intentID, err := s.billing.ChargePaymentMethod(ctx, domain.PaymentCharge{
+		CustomerID:      sub.StripeCustomerID,
+		PaymentMethodID: topUp.PaymentMethodID,
+		AmountCents:     topUp.AmountCents,
+		Currency:        invoiceCurrency,
+		Description:     fmt.Sprintf("Auto top-up: %d credits", topUp.Credits),
+		IdempotencyKey:  "auto-top-up-" + topUp.ID.String(),
+		Metadata: map[string]string{
+			"office_id":      topUp.OfficeID.String(),
+			"auto_top_up_id": topUp.ID.String(),
+			"credit_pack":    topUp.Package,
+		},
+	})
+	if errors.Is(err, domain.ErrPaymentDeclined) {
+		return s.fail(ctx, topUp, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to charge top-up %s: %w", topUp.ID, err)
+	}
+	return s.complete(ctx, topUp, intentID)
+}
+
+// complete adds a paid top-up's credits as a purchase referring to it
+func (s *AutoTopUpService) complete(ctx context.Context, topUp *domain.AutoTopUp, intentID string) error {
+	now := time.Now()
+	topUp.Status = domain.AutoTopUpStatusSucceeded
+	topUp.StripePaymentIntentID = intentID
+	topUp.CompletedAt = &now
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		tx, err := s.creditRepo.AddCredits(ctx, topUp.WalletID, topUp.Credits, domain.TransactionTypePurchase,
+			fmt.Sprintf("Auto top-up: %s credit pack", topUp.Package), autoTopUpReference, &topUp.ID)
+		if err != nil {
+			return fmt.Errorf("failed to add credits: %w", err)
+		}
+		topUp.CreditTransactionID = &tx.ID
+		return s.topUpRepo.Resolve(ctx, topUp)
+	})
+	if errors.Is(err, domain.ErrNotFound) {
+		// Another replica retried it first; the credits were added once
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.notify(ctx, topUp.OfficeID, "Credits topped up",
+		fmt.Sprintf("Your balance was low, so auto top-up bought %d credits for $%.2f.", topUp.Credits, float64(topUp.AmountCents)/100),
+		topUp)
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAutoTopUp,
+		EntityType: domain.AuditEntityAutoTopUp,
+		EntityID:   topUp.ID,
+		OfficeID:   topUp.OfficeID,
+		Details:    autoTopUpDetails(topUp),
+	})
+	return nil
+}
+
+// fail records that a top-up could not be charged and turns auto top-up off
+// for its wallet, so a refused payment method is not charged again
+func (s *AutoTopUpService) fail(ctx context.Context, topUp *domain.AutoTopUp, cause error) error {
+	now := time.Now()
+	topUp.Status = domain.AutoTopUpStatusFailed
+	topUp.Error = cause.Error()
+	topUp.CompletedAt = &now
+	err := s.topUpRepo.Resolve(ctx, topUp)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record failed top-up: %w", err)
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionAutoTopUpFailed,
+		EntityType: domain.AuditEntityAutoTopUp,
+		EntityID:   topUp.ID,
+		OfficeID:   topUp.OfficeID,
+		Details:    autoTopUpDetails(topUp),
+	})
+
+	wallet, err := s.creditRepo.GetWalletByID(ctx, topUp.WalletID)
+	if err != nil {
+		return fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return s.disable(ctx, wallet, fmt.Sprintf("your payment method could not be charged for the %s credit pack", topUp.Package))
+}
+
+// disable turns the wallet's auto top-up off and tells the office why
+func (s *AutoTopUpService) disable(ctx context.Context, wallet *domain.CreditWallet, reason string) error {
+	if !wallet.AutoTopUpEnabled {
+		return nil
+	}
+	before := lowBalanceSnapshot(wallet)
+	wallet.AutoTopUpEnabled = false
+	if err := s.creditRepo.UpdateLowBalanceSettings(ctx, wallet); err != nil {
+		return fmt.Errorf("failed to turn auto top-up off: %w", err)
+	}
+
+	s.notify(ctx, wallet.OfficeID, "Auto top-up is off",
+		fmt.Sprintf("Auto top-up was turned off because %s. Check your payment method and turn it back on to keep buying credits automatically.", reason),
+		nil)
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionLowBalanceSettings,
+		EntityType: domain.AuditEntityWallet,
+		EntityID:   wallet.ID,
+		OfficeID:   wallet.OfficeID,
+		Before:     before,
+		After:      lowBalanceSnapshot(wallet),
+		Details:    map[string]any{"reason": reason},
+	})
+	return nil
+}
+
+// notify sends an auto_top_up notification about topUp, if any. The top-up
+// has happened by then, so a failure is logged rather than returned.
+func (s *AutoTopUpService) notify(ctx context.Context, officeID uuid.UUID, title, message string, topUp *domain.AutoTopUp) {
+	var payload map[string]any
+	if topUp != nil {
+		payload = autoTopUpDetails(topUp)
+		payload["auto_top_up_id"] = topUp.ID
+	}
+	if _, err := s.notificationService.Notify(ctx, officeID, domain.NotificationTypeAutoTopUp, title, message, payload); err != nil {
+		log.Printf("Failed to send auto top-up notification to office %s: %v", officeID, err)
+	}
+}
+
+// lowBalanceSnapshot is the audited state of a wallet's low balance settings
+func lowBalanceSnapshot(wallet *domain.CreditWallet) map[string]any {
+	return map[string]any{
+		"low_balance_threshold":      wallet.LowBalanceThreshold,
+		"auto_top_up_enabled":        wallet.AutoTopUpEnabled,
+		"auto_top_up_package":        wallet.AutoTopUpPackage,
+		"auto_top_up_payment_method": wallet.AutoTopUpPaymentMethod,
+		"auto_top_up_max_per_day":    wallet.AutoTopUpMaxPerDay,
+	}
+}
+
+// autoTopUpDetails are the audited and notified details of a top-up
+func autoTopUpDetails(topUp *domain.AutoTopUp) map[string]any {
+	details := map[string]any{
+		"package":                  topUp.Package,
+		"credits":                  topUp.Credits,
+		"amount_cents":             topUp.AmountCents,
+		"status":                   topUp.Status,
+		"stripe_payment_intent_id": topUp.StripePaymentIntentID,
+	}
+	if topUp.CreditTransactionID != nil {
+		details["credit_transaction_id"] = *topUp.CreditTransactionID
+	}
+	if topUp.Error != "" {
+		details["error"] = topUp.Error
+	}
+	return details
+}
+
+// startOfUTCDay is midnight UTC of t's day, when daily top-up caps reset
+func startOfUTCDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
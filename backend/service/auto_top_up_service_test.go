@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// autoTopUpMocks are the repositories behind an AutoTopUpService under test
+type autoTopUpMocks struct {
+	credits *mocks.MockCreditRepository
+	topUps  *mocks.MockAutoTopUpRepository
+	subs    *mocks.MockSubscriptionRepository
+	billing *mocks.MockBillingProvider
+	audit   *mocks.MockAuditRepository
+}
+
+// newTestAutoTopUpService creates an AutoTopUpService on the shipped credit
+// packages. Its notifications fail for the unknown offices and are only
+// logged.
+func newTestAutoTopUpService(t *testing.T) (*AutoTopUpService, autoTopUpMocks) {
+	ctrl := gomock.NewController(t)
+	m := autoTopUpMocks{
+		credits: mocks.NewMockCreditRepository(ctrl),
+		topUps:  mocks.NewMockAutoTopUpRepository(ctrl),
+		subs:    mocks.NewMockSubscriptionRepository(ctrl),
+		billing: mocks.NewMockBillingProvider(ctrl),
+		audit:   mocks.NewMockAuditRepository(ctrl),
+	}
+
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	offices.EXPECT().GetByID(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound).AnyTimes()
+	users := mocks.NewMockUserRepository(ctrl)
+	notifications := NewNotificationService(
+		mocks.NewMockNotificationRepository(ctrl), mocks.NewMockNotificationPreferenceRepository(ctrl),
+		offices, users, mocks.NewMockMailer(ctrl), mocks.NewMockEventPublisher(ctrl), newTestWebhookDispatcher(ctrl), "")
+	audit := NewAuditService(m.audit, offices, users)
+	creditService := NewCreditService(m.credits, offices, mocks.NewMockIdempotencyRepository(ctrl), newTestTxManager(ctrl), notifications)
+	subscriptions, _ := newTestSubscriptionService(t)
+
+	svc := NewAutoTopUpService(m.credits, m.topUps, m.subs, txManager, creditService, subscriptions, m.billing, notifications, audit)
+	return svc, m
+}
+
+// testAutoTopUpWallet is a wallet below its threshold with auto top-up of
+// the medium pack on
+func testAutoTopUpWallet() *domain.CreditWallet {
+	return &domain.CreditWallet{
+		ID: uuid.New(), OfficeID: uuid.New(), Balance: 40, LowBalanceThreshold: 500,
+		AutoTopUpEnabled: true, AutoTopUpPackage: "medium", AutoTopUpPaymentMethod: "pm_card", AutoTopUpMaxPerDay: 2,
+	}
+}
+
+func TestUpdateSettingsEnablesAutoTopUp(t *testing.T) {
+	svc, m := newTestAutoTopUpService(t)
+	officeID := uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID, LowBalanceThreshold: 100, AutoTopUpMaxPerDay: 1}
+
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(&domain.Subscription{OfficeID: officeID, StripeCustomerID: "cus_123"}, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	m.credits.EXPECT().UpdateLowBalanceSettings(gomock.Any(), wallet).DoAndReturn(
+		func(_ context.Context, w *domain.CreditWallet) error {
+			if w.LowBalanceThreshold != 500 || !w.AutoTopUpEnabled || w.AutoTopUpPackage != "medium" ||
+				w.AutoTopUpPaymentMethod != "pm_card" || w.AutoTopUpMaxPerDay != 1 {
+				t.Errorf("wallet = %+v, want auto top-up of the medium pack once a day below 500", w)
+			}
+			return nil
+		})
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, entry *domain.AuditEntry) error {
+			if entry.Action != domain.AuditActionLowBalanceSettings || entry.Before["auto_top_up_enabled"] != false ||
+				entry.After["auto_top_up_enabled"] != true {
+				t.Errorf("audit entry = %+v, want the settings before and after", entry)
+			}
+			return nil
+		})
+
+	_, err := svc.UpdateSettings(context.Background(), officeID, uuid.New(), LowBalanceSettings{
+		Threshold: 500, AutoTopUpEnabled: true, Package: "medium", PaymentMethodID: "pm_card",
+	})
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+}
+
+func TestUpdateSettingsRejected(t *testing.T) {
+	autoTopUp := func(edit func(*LowBalanceSettings)) LowBalanceSettings {
+		settings := LowBalanceSettings{Threshold: 500, AutoTopUpEnabled: true, Package: "medium", PaymentMethodID: "pm_card"}
+		edit(&settings)
+		return settings
+	}
+	tests := []struct {
+		name     string
+		settings LowBalanceSettings
+		// stripe is whether the office is billed through Stripe
+		stripe bool
+	}{
+		{"negative threshold", LowBalanceSettings{Threshold: -1}, true},
+		{"too many a day", autoTopUp(func(s *LowBalanceSettings) { s.MaxPerDay = 11 }), true},
+		{"without threshold", autoTopUp(func(s *LowBalanceSettings) { s.Threshold = 0 }), true},
+		{"unknown package", autoTopUp(func(s *LowBalanceSettings) { s.Package = "huge" }), true},
+		{"not a payment method", autoTopUp(func(s *LowBalanceSettings) { s.PaymentMethodID = "card_123" }), true},
+		{"not billed through Stripe", autoTopUp(func(*LowBalanceSettings) {}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestAutoTopUpService(t)
+			officeID := uuid.New()
+
+			// Nothing is saved in any of these cases
+			sub := &domain.Subscription{OfficeID: officeID}
+			if tt.stripe {
+				sub.StripeCustomerID = "cus_123"
+			}
+			m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil).AnyTimes()
+
+			_, err := svc.UpdateSettings(context.Background(), officeID, uuid.New(), tt.settings)
+			if !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("UpdateSettings error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
+
+func TestTopUpChargesAndAddsCredits(t *testing.T) {
+	svc, m := newTestAutoTopUpService(t)
+	wallet := testAutoTopUpWallet()
+	purchase := &domain.CreditTransaction{ID: uuid.New()}
+	var topUpID uuid.UUID
+
+	m.topUps.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, topUp *domain.AutoTopUp, _ time.Time) error {
+			// The medium pack is 25000 credits for $40
+			if topUp.WalletID != wallet.ID || topUp.Credits != 25000 || topUp.AmountCents != 4000 || topUp.PaymentMethodID != "pm_card" {
+				t.Errorf("top-up = %+v, want the wallet's medium pack", topUp)
+			}
+			topUpID, topUp.OfficeID = topUp.ID, wallet.OfficeID
+			return nil
+		})
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), wallet.OfficeID).Return(&domain.Subscription{StripeCustomerID: "cus_123"}, nil)
+	m.billing.EXPECT().ChargePaymentMethod(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, charge domain.PaymentCharge) (string, error) {
+			// Retries of the top-up reuse its key, so it is charged once
+			if charge.CustomerID != "cus_123" || charge.PaymentMethodID != "pm_card" || charge.AmountCents != 4000 ||
+				charge.IdempotencyKey != "auto-top-up-"+topUpID.String() {
+				t.Errorf("charge = %+v, want the top-up's price from the saved payment method", charge)
+			}
+			return "pi_123", nil
+		})
+	m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, int64(25000), domain.TransactionTypePurchase,
+		gomock.Any(), autoTopUpReference, gomock.Any()).Return(purchase, nil)
+	m.topUps.EXPECT().Resolve(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, topUp *domain.AutoTopUp) error {
+			if topUp.Status != domain.AutoTopUpStatusSucceeded || topUp.StripePaymentIntentID != "pi_123" ||
+				topUp.CreditTransactionID == nil || *topUp.CreditTransactionID != purchase.ID {
+				t.Errorf("top-up = %+v, want it succeeded with the payment and purchase", topUp)
+			}
+			return nil
+		})
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+	if err := svc.topUp(context.Background(), wallet, startOfUTCDay(time.Now())); err != nil {
+		t.Fatalf("topUp: %v", err)
+	}
+}
+
+func TestTopUpDeclinedTurnsAutoTopUpOff(t *testing.T) {
+	svc, m := newTestAutoTopUpService(t)
+	wallet := testAutoTopUpWallet()
+
+	m.topUps.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, topUp *domain.AutoTopUp, _ time.Time) error {
+			topUp.OfficeID = wallet.OfficeID
+			return nil
+		})
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), wallet.OfficeID).Return(&domain.Subscription{StripeCustomerID: "cus_123"}, nil)
+	m.billing.EXPECT().ChargePaymentMethod(gomock.Any(), gomock.Any()).Return("", domain.ErrPaymentDeclined)
+	// No credits are added
+	m.topUps.EXPECT().Resolve(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, topUp *domain.AutoTopUp) error {
+			if topUp.Status != domain.AutoTopUpStatusFailed || topUp.Error == "" {
+				t.Errorf("top-up = %+v, want it failed with the decline", topUp)
+			}
+			return nil
+		})
+	m.credits.EXPECT().GetWalletByID(gomock.Any(), wallet.ID).Return(wallet, nil)
+	m.credits.EXPECT().UpdateLowBalanceSettings(gomock.Any(), wallet).DoAndReturn(
+		func(_ context.Context, w *domain.CreditWallet) error {
+			if w.AutoTopUpEnabled || w.LowBalanceThreshold != 500 {
+				t.Errorf("wallet = %+v, want auto top-up off and the warning kept", w)
+			}
+			return nil
+		})
+	// The failed top-up and the settings change
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	if err := svc.topUp(context.Background(), wallet, startOfUTCDay(time.Now())); err != nil {
+		t.Fatalf("topUp: %v", err)
+	}
+}
+
+func TestTopUpInterruptedStaysPending(t *testing.T) {
+	svc, m := newTestAutoTopUpService(t)
+	topUp := &domain.AutoTopUp{
+		ID: uuid.New(), WalletID: uuid.New(), OfficeID: uuid.New(), Package: "small", Credits: 5000, AmountCents: 1000,
+		PaymentMethodID: "pm_card", Status: domain.AutoTopUpStatusPending, CreatedAt: time.Now().Add(-time.Hour),
+	}
+
+	// Stripe could not be reached; the top-up is not resolved, so the next
+	// retry charges it with the same key
+	m.topUps.EXPECT().GetStalePending(gomock.Any(), gomock.Any(), autoTopUpBatchSize).Return([]*domain.AutoTopUp{topUp}, nil)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), topUp.OfficeID).Return(&domain.Subscription{StripeCustomerID: "cus_123"}, nil)
+	m.billing.EXPECT().ChargePaymentMethod(gomock.Any(), gomock.Any()).Return("", errors.New("stripe: connection reset"))
+
+	svc.retryInterrupted(context.Background())
+}
+
+func TestTopUpOfUnavailableWalletIsSkipped(t *testing.T) {
+	svc, m := newTestAutoTopUpService(t)
+
+	// Another replica claimed it, or it was topped up or turned off since
+	m.topUps.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ErrNotFound)
+
+	if err := svc.topUp(context.Background(), testAutoTopUpWallet(), startOfUTCDay(time.Now())); err != nil {
+		t.Fatalf("topUp: %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// invoicePollInterval is how often the billing worker looks for periods
+	// of manually billed subscriptions to invoice
+	invoicePollInterval = 5 * time.Minute
+	invoiceBatchSize    = 50
+	// invoiceDueDays is how long a manual invoice is payable for
+	invoiceDueDays = 14
+	// invoiceCurrency is the currency tier and credit package prices are in
+	invoiceCurrency = "usd"
+	// addOnPurchaseLimit is the most add-on purchases one invoice lists
+	addOnPurchaseLimit = 100
+)
+
+// BillingService reports an office's invoices and its next charge. Offices
+// billed through Stripe are reported from Stripe; for offices on a paid tier
+// that are not, it issues an invoice at the start of each billing period.
+type BillingService struct {
+	invoiceRepo         domain.InvoiceRepository
+	subRepo             domain.SubscriptionRepository
+	creditRepo          domain.CreditRepository
+	promoRepo           domain.PromoCodeRepository
+	txManager           domain.TxManager
+	subscriptionService *SubscriptionService
+	// billing, if set, reports the invoices of offices billed through Stripe
+	billing domain.BillingProvider
+}
+
+// NewBillingService creates a new BillingService instance
+func NewBillingService(
+	invoiceRepo domain.InvoiceRepository,
+	subRepo domain.SubscriptionRepository,
+	creditRepo domain.CreditRepository,
+	promoRepo domain.PromoCodeRepository,
+	txManager domain.TxManager,
+	subscriptionService *SubscriptionService,
+	billing domain.BillingProvider,
+) *BillingService {
+	return &BillingService{
+		invoiceRepo:         invoiceRepo,
+		subRepo:             subRepo,
+		creditRepo:          creditRepo,
+		promoRepo:           promoRepo,
+		txManager:           txManager,
+		subscriptionService: subscriptionService,
+		billing:             billing,
+	}
+}
+
+// ListInvoices returns the office's most recent invoices, newest first:
+// those Stripe issued and those issued here
+func (s *BillingService) ListInvoices(ctx context.Context, officeID uuid.UUID, limit int) ([]*domain.Invoice, error) {
+	invoices, err := s.invoiceRepo.ListByOffice(ctx, officeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, invoice := range invoices {
+		invoice.PDFURL = invoicePDFPath(invoice.ID)
+	}
+
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return invoices, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sub.StripeCustomerID != "" {
+		if s.billing == nil {
+			log.Printf("Not listing the Stripe invoices of office %s: no Stripe key is configured", officeID)
+			return invoices, nil
+		}
+		stripeInvoices, err := s.billing.ListInvoices(ctx, sub.StripeCustomerID, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Stripe invoices: %w", err)
+		}
+		invoices = append(invoices, stripeInvoices...)
+	}
+
+	slices.SortFunc(invoices, func(a, b *domain.Invoice) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(invoices) > limit {
+		invoices = invoices[:limit]
+	}
+	return invoices, nil
+}
+
+// GetInvoice returns one of the office's manual invoices
+func (s *BillingService) GetInvoice(ctx context.Context, officeID, invoiceID uuid.UUID) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.OfficeID != officeID {
+		return nil, domain.ErrNotFound
+	}
+	invoice.PDFURL = invoicePDFPath(invoice.ID)
+	return invoice, nil
+}
+
+// GetUpcomingInvoice projects the office's next charge: the next period of
+// the tier it will be on then, plus the add-on credits bought since it was
+// last invoiced. It returns ErrNotFound if no charge is coming, such as
+// when the subscription ends with its period.
+func (s *BillingService) GetUpcomingInvoice(ctx context.Context, officeID uuid.UUID) (*domain.Invoice, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.StripeSubscriptionID != "" && sub.StripeCustomerID != "" {
+		if s.billing == nil {
+			return nil, errors.New("no Stripe key is configured")
+		}
+		return s.billing.UpcomingInvoice(ctx, sub.StripeCustomerID)
+	}
+
+	if sub.Status == domain.SubscriptionStatusCancelled || sub.CancelAtPeriodEnd {
+		return nil, domain.ErrNotFound
+	}
+	tier := sub.Tier
+	if sub.Status == domain.SubscriptionStatusTrialing {
+		// Trials not billed through Stripe end on the free tier
+		tier = domain.TierSolo
+	}
+	if sub.PendingTier != nil && sub.PendingTierAt != nil && !sub.PendingTierAt.After(sub.CurrentPeriodEnd) {
+		tier = *sub.PendingTier
+	}
+
+	start := sub.CurrentPeriodEnd
+	invoice, _, err := s.buildInvoice(ctx, sub, tier, start, nextPeriodEnd(start, sub.BillingInterval))
+	if err != nil {
+		return nil, err
+	}
+	invoice.Status = domain.InvoiceStatusUpcoming
+	invoice.DueAt = &start
+	return invoice, nil
+}
+
+// Run issues the invoices of manually billed periods as they start, until
+// ctx is cancelled
+func (s *BillingService) Run(ctx context.Context) {
+	ticker := time.NewTicker(invoicePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.issueDueInvoices(ctx)
+		}
+	}
+}
+
+// issueDueInvoices invoices one batch of manually billed subscriptions whose
+// current period has no invoice yet
+func (s *BillingService) issueDueInvoices(ctx context.Context) {
+	var paidTiers []domain.SubscriptionTier
+	for tier, tierDef := range s.subscriptionService.GetAllTiers() {
+		monthly, _ := tierPriceCents(tierDef, domain.BillingIntervalMonthly)
+		yearly, _ := tierPriceCents(tierDef, domain.BillingIntervalYearly)
+		if monthly > 0 || yearly > 0 {
+			paidTiers = append(paidTiers, tier)
+		}
+	}
+	if len(paidTiers) == 0 {
+		return
+	}
+
+	subs, err := s.invoiceRepo.GetUninvoiced(ctx, paidTiers, invoiceBatchSize)
+	if err != nil {
+		log.Printf("Failed to load subscriptions to invoice: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.issueInvoice(ctx, sub); err != nil {
+			log.Printf("Failed to invoice subscription %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// issueInvoice invoices the subscription's current period. A promo
+// discount applied to the invoice loses one of its periods in the same
+// transaction.
+func (s *BillingService) issueInvoice(ctx context.Context, sub *domain.Subscription) error {
+	invoice, discount, err := s.buildInvoice(ctx, sub, sub.Tier, sub.CurrentPeriodStart, sub.CurrentPeriodEnd)
+	if err != nil {
+		return err
+	}
+	invoice.ID = uuid.New().String()
+	invoice.Status = domain.InvoiceStatusOpen
+	dueAt := invoice.CreatedAt.AddDate(0, 0, invoiceDueDays)
+	invoice.DueAt = &dueAt
+
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.invoiceRepo.Create(ctx, invoice)
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil
+		}
+		if err != nil || discount == nil {
+			return err
+		}
+		return s.promoRepo.UseDiscountPeriod(ctx, discount.ID)
+	})
+}
+
+// buildInvoice prices a period of tier for the subscription, with the
+// add-on credits bought since its last invoice. It also returns the promo
+// discount it applied to the tier's price, if any.
+func (s *BillingService) buildInvoice(
+	ctx context.Context,
+	sub *domain.Subscription,
+	tier domain.SubscriptionTier,
+	start, end time.Time,
+) (*domain.Invoice, *domain.PromoRedemption, error) {
+	tierDef, err := s.subscriptionService.GetTier(tier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invoice := &domain.Invoice{
+		OfficeID:       sub.OfficeID,
+		SubscriptionID: sub.ID,
+		Source:         domain.InvoiceSourceManual,
+		Currency:       invoiceCurrency,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		Lines:          []domain.InvoiceLine{},
+		CreatedAt:      time.Now(),
+	}
+	var discount *domain.PromoRedemption
+	if price, ok := tierPriceCents(tierDef, sub.BillingInterval); ok && price > 0 {
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+			Description: fmt.Sprintf("%s subscription, %s to %s", tierDef.Name, start.Format("Jan 2, 2006"), end.Format("Jan 2, 2006")),
+			Quantity:    1,
+			AmountCents: price,
+		})
+
+		discount, err = s.promoRepo.GetActiveDiscount(ctx, sub.OfficeID)
+		switch {
+		case err == nil:
+			invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+				Description: fmt.Sprintf("%d%% off the subscription, promo code %s", discount.DiscountPercent, discount.Code),
+				Quantity:    1,
+				AmountCents: -price * int64(discount.DiscountPercent) / 100,
+			})
+		case !errors.Is(err, domain.ErrNotFound):
+			return nil, nil, err
+		}
+	}
+
+	purchases, err := s.addOnPurchases(ctx, sub)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, purchase := range purchases {
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+			Description: fmt.Sprintf("%d add-on credits, bought %s", purchase.Amount, purchase.CreatedAt.Format("Jan 2, 2006")),
+			Quantity:    1,
+			AmountCents: s.creditsPriceCents(purchase.Amount),
+		})
+	}
+
+	for _, line := range invoice.Lines {
+		invoice.AmountCents += line.AmountCents
+	}
+	return invoice, discount, nil
+}
+
+// addOnPurchases returns the credit purchases the office made since the
+// subscription was last invoiced, oldest first. Auto top-ups were paid when
+// charged and are left out.
+func (s *BillingService) addOnPurchases(ctx context.Context, sub *domain.Subscription) ([]*domain.CreditTransaction, error) {
+	since := sub.CreatedAt
+	last, err := s.invoiceRepo.GetLatestBySubscription(ctx, sub.ID)
+	if err == nil {
+		since = last.CreatedAt
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, sub.OfficeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.creditRepo.GetTransactionsByType(ctx, wallet.ID, domain.TransactionTypePurchase, addOnPurchaseLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	var purchases []*domain.CreditTransaction
+	for _, tx := range txs {
+		if tx.CreatedAt.After(since) && tx.Amount > 0 && tx.ReferenceType != autoTopUpReference {
+			purchases = append(purchases, tx)
+		}
+	}
+	slices.SortFunc(purchases, func(a, b *domain.CreditTransaction) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return purchases, nil
+}
+
+// creditsPriceCents prices an add-on purchase at the rate of the largest
+// credit package it covers, or of the smallest package if it covers none
+func (s *BillingService) creditsPriceCents(credits int64) int64 {
+	var packages []domain.CreditPackage
+	for _, pkg := range s.subscriptionService.GetCreditPackages() {
+		if pkg.Credits > 0 {
+			packages = append(packages, pkg)
+		}
+	}
+	if len(packages) == 0 {
+		return 0
+	}
+	slices.SortFunc(packages, func(a, b domain.CreditPackage) int {
+		return cmp.Compare(a.Credits, b.Credits)
+	})
+
+	rate := packages[0]
+	for _, pkg := range packages {
+		if pkg.Credits <= credits {
+			rate = pkg
+		}
+	}
+	return usdCents(float64(credits) * rate.PriceUSD / float64(rate.Credits))
+}
+
+// tierPriceCents returns what a billing period of the tier costs, or false
+// for tiers priced individually
+func tierPriceCents(tierDef *domain.TierDefinition, interval domain.BillingInterval) (int64, bool) {
+	price := tierDef.PriceMonthlyUSD
+	if interval == domain.BillingIntervalYearly {
+		price = tierDef.PriceYearlyUSD
+	}
+	if price == nil {
+		return 0, false
+	}
+	return usdCents(*price), true
+}
+
+// usdCents converts dollars to cents
+func usdCents(usd float64) int64 {
+	return int64(math.Round(usd * 100))
+}
+
+// invoicePDFPath is the API path that downloads a manual invoice as a PDF
+func invoicePDFPath(invoiceID string) string {
+	return "/api/v1/billing/invoices/" + invoiceID + "/pdf"
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestBillingService creates a BillingService on the shipped tiers
+// whose transactions run fn directly
+func newTestBillingService(t *testing.T) (*BillingService, *mocks.MockInvoiceRepository, *mocks.MockCreditRepository, *mocks.MockPromoCodeRepository) {
+	ctrl := gomock.NewController(t)
+	subscriptions, _ := newTestSubscriptionService(t)
+	invoices := mocks.NewMockInvoiceRepository(ctrl)
+	credits := mocks.NewMockCreditRepository(ctrl)
+	promos := mocks.NewMockPromoCodeRepository(ctrl)
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+	svc := NewBillingService(invoices, mocks.NewMockSubscriptionRepository(ctrl), credits, promos, txManager, subscriptions, nil)
+	return svc, invoices, credits, promos
+}
+
+// testManualSubscription is a monthly Professional subscription invoiced by
+// the backend, in its second period
+func testManualSubscription() *domain.Subscription {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	return &domain.Subscription{
+		ID: uuid.New(), OfficeID: uuid.New(), Tier: domain.TierProfessional, BillingInterval: domain.BillingIntervalMonthly,
+		CurrentPeriodStart: start, CurrentPeriodEnd: start.AddDate(0, 1, 0), CreatedAt: start.AddDate(0, -1, 0),
+	}
+}
+
+func TestIssueInvoiceAppliesPromoDiscount(t *testing.T) {
+	svc, invoices, credits, promos := newTestBillingService(t)
+	sub := testManualSubscription()
+	discount := &domain.PromoRedemption{ID: uuid.New(), Code: "SPRING25", DiscountPercent: 25, DiscountPeriodsLeft: 3}
+
+	promos.EXPECT().GetActiveDiscount(gomock.Any(), sub.OfficeID).Return(discount, nil)
+	invoices.EXPECT().GetLatestBySubscription(gomock.Any(), sub.ID).Return(nil, domain.ErrNotFound)
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), sub.OfficeID).Return(nil, domain.ErrNotFound)
+	invoices.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, invoice *domain.Invoice) error {
+			// 25% off the $29.00 Professional price
+			if len(invoice.Lines) != 2 || invoice.Lines[1].AmountCents != -725 || invoice.AmountCents != 2175 {
+				t.Errorf("invoice = %+v, want the subscription less 725 cents", invoice)
+			}
+			return nil
+		})
+	// The invoice uses up one of the discounted periods
+	promos.EXPECT().UseDiscountPeriod(gomock.Any(), discount.ID).Return(nil)
+
+	if err := svc.issueInvoice(context.Background(), sub); err != nil {
+		t.Fatalf("issueInvoice: %v", err)
+	}
+}
+
+func TestIssueInvoiceOfInvoicedPeriodKeepsDiscount(t *testing.T) {
+	svc, invoices, credits, promos := newTestBillingService(t)
+	sub := testManualSubscription()
+
+	// Another replica invoiced the period first; no period is used up
+	promos.EXPECT().GetActiveDiscount(gomock.Any(), sub.OfficeID).
+		Return(&domain.PromoRedemption{ID: uuid.New(), DiscountPercent: 25, DiscountPeriodsLeft: 3}, nil)
+	invoices.EXPECT().GetLatestBySubscription(gomock.Any(), sub.ID).Return(nil, domain.ErrNotFound)
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), sub.OfficeID).Return(nil, domain.ErrNotFound)
+	invoices.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.ErrAlreadyExists)
+
+	if err := svc.issueInvoice(context.Background(), sub); err != nil {
+		t.Fatalf("issueInvoice: %v", err)
+	}
+}
+
+func TestIssueInvoiceLeavesOutAutoTopUps(t *testing.T) {
+	svc, invoices, credits, promos := newTestBillingService(t)
+	sub := testManualSubscription()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: sub.OfficeID}
+	topUpID := uuid.New()
+
+	promos.EXPECT().GetActiveDiscount(gomock.Any(), sub.OfficeID).Return(nil, domain.ErrNotFound)
+	invoices.EXPECT().GetLatestBySubscription(gomock.Any(), sub.ID).Return(nil, domain.ErrNotFound)
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), sub.OfficeID).Return(wallet, nil)
+	credits.EXPECT().GetTransactionsByType(gomock.Any(), wallet.ID, domain.TransactionTypePurchase, addOnPurchaseLimit).
+		Return([]*domain.CreditTransaction{
+			{ID: uuid.New(), Amount: 5000, CreatedAt: sub.CurrentPeriodStart.Add(-time.Hour)},
+			// Paid through Stripe when it was charged
+			{ID: uuid.New(), Amount: 25000, ReferenceType: autoTopUpReference, ReferenceID: &topUpID, CreatedAt: sub.CurrentPeriodStart.Add(-time.Hour)},
+		}, nil)
+	invoices.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, invoice *domain.Invoice) error {
+			// The subscription and the small pack's $10.00
+			if len(invoice.Lines) != 2 || invoice.Lines[1].AmountCents != 1000 {
+				t.Errorf("invoice lines = %+v, want the subscription and one add-on purchase", invoice.Lines)
+			}
+			return nil
+		})
+
+	if err := svc.issueInvoice(context.Background(), sub); err != nil {
+		t.Fatalf("issueInvoice: %v", err)
+	}
+}
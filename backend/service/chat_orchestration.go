@@ -0,0 +1,490 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// maxDebateRounds bounds how many times each agent speaks in a debate
+const maxDebateRounds = 5
+
+// UpdateOrchestrationInput contains a group conversation's new orchestration
+// settings. ModeratorID is required in moderator mode; a nil DebateRounds
+// keeps the current number of rounds.
+type UpdateOrchestrationInput struct {
+	OfficeID       uuid.UUID
+	ConversationID uuid.UUID
+	Mode           domain.OrchestrationMode
+	ModeratorID    *uuid.UUID
+	DebateRounds   *int
+}
+
+// UpdateOrchestration sets how the agents of a group conversation take turns
+// answering. Modes other than mentions require a tier with advanced
+// orchestration; going back to mentions is always allowed.
+func (s *ChatService) UpdateOrchestration(ctx context.Context, input UpdateOrchestrationInput) (*domain.Conversation, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.Type != domain.ConversationTypeGroup {
+		return nil, fmt.Errorf("%w: orchestration modes only apply to group conversations", domain.ErrInvalidInput)
+	}
+
+	switch input.Mode {
+	case domain.OrchestrationMentions:
+	case domain.OrchestrationRoundRobin, domain.OrchestrationModerator, domain.OrchestrationAll, domain.OrchestrationDebate:
+		if err := s.subscriptionService.RequireFeature(ctx, input.OfficeID, FeatureAdvancedOrchestration); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown orchestration mode %q", domain.ErrInvalidInput, input.Mode)
+	}
+
+	conversation.ModeratorID = nil
+	if input.Mode == domain.OrchestrationModerator {
+		if input.ModeratorID == nil {
+			return nil, fmt.Errorf("%w: moderator mode needs a moderator_id", domain.ErrInvalidInput)
+		}
+		participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+		if err != nil {
+			return nil, err
+		}
+		if findAgent(participants, *input.ModeratorID) == nil {
+			return nil, fmt.Errorf("%w: the moderator must be a participant", domain.ErrInvalidInput)
+		}
+		conversation.ModeratorID = input.ModeratorID
+	}
+	if input.DebateRounds != nil {
+		if *input.DebateRounds < 1 || *input.DebateRounds > maxDebateRounds {
+			return nil, fmt.Errorf("%w: debate_rounds must be between 1 and %d", domain.ErrInvalidInput, maxDebateRounds)
+		}
+		conversation.DebateRounds = *input.DebateRounds
+	}
+
+	conversation.OrchestrationMode = input.Mode
+	conversation.UpdatedAt = time.Now()
+	if err := s.conversationRepo.Update(ctx, conversation); err != nil {
+		return nil, err
+	}
+	return s.GetConversation(ctx, input.OfficeID, conversation.ID)
+}
+
+// orchestrationMode returns the mode agents answer a conversation's messages
+// in. Offices that lost advanced orchestration fall back to mentions, as do
+// moderated conversations whose moderator has left.
+func (s *ChatService) orchestrationMode(ctx context.Context, conversation *domain.Conversation, participants []*domain.Agent) domain.OrchestrationMode {
+	mode := conversation.OrchestrationMode
+	if conversation.Type != domain.ConversationTypeGroup || mode == "" || mode == domain.OrchestrationMentions {
+		return domain.OrchestrationMentions
+	}
+	if mode == domain.OrchestrationModerator && s.moderator(conversation, participants) == nil {
+		return domain.OrchestrationMentions
+	}
+
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, conversation.OfficeID)
+	if err != nil {
+		log.Printf("Failed to load features of office %s: %v", conversation.OfficeID, err)
+		return domain.OrchestrationMentions
+	}
+	if !features.AdvancedOrchestration {
+		return domain.OrchestrationMentions
+	}
+	return mode
+}
+
+// moderator returns the conversation's moderator if it is a participant
+func (s *ChatService) moderator(conversation *domain.Conversation, participants []*domain.Agent) *domain.Agent {
+	if conversation.ModeratorID == nil {
+		return nil
+	}
+	return findAgent(participants, *conversation.ModeratorID)
+}
+
+// agentTurn is a task to create: an agent and the input it answers
+type agentTurn struct {
+	agent *domain.Agent
+	input string
+}
+
+// userMessageTurns decides which agents answer a user's message, and with
+// what input, under the conversation's orchestration mode
+func (s *ChatService) userMessageTurns(
+	ctx context.Context,
+	conversation *domain.Conversation,
+	message *domain.Message,
+	participants []*domain.Agent,
+	input string,
+) []agentTurn {
+	turns := func(agents ...*domain.Agent) []agentTurn {
+		result := make([]agentTurn, len(agents))
+		for i, agent := range agents {
+			result[i] = agentTurn{agent: agent, input: input}
+		}
+		return result
+	}
+
+	// Mentioned agents answer whatever the mode
+	if mentioned := mentionedAgents(message.Content, participants); len(mentioned) > 0 {
+		return turns(mentioned...)
+	}
+
+	switch s.orchestrationMode(ctx, conversation, participants) {
+	case domain.OrchestrationAll:
+		return turns(participants...)
+
+	case domain.OrchestrationRoundRobin:
+		next, err := s.nextSpeaker(ctx, conversation.ID, participants)
+		if err != nil {
+			log.Printf("Failed to pick the next agent in conversation %s: %v", conversation.ID, err)
+			return nil
+		}
+		return turns(next)
+
+	case domain.OrchestrationModerator:
+		moderator := s.moderator(conversation, participants)
+		return []agentTurn{{agent: moderator, input: moderatorInput(moderator, participants, input)}}
+
+	case domain.OrchestrationDebate:
+		if len(participants) < 2 {
+			return turns(participants...)
+		}
+		return []agentTurn{{agent: participants[0], input: debateInput(participants, 1, debateRounds(conversation), input)}}
+	}
+	return turns(s.determineRespondingAgents(message.Content, participants)...)
+}
+
+// departmentTurns hands a user's message in a department's conversation
+// that mentions no one to the department's lead, who answers it or
+// delegates it to members by @mentioning them. It returns nil for other
+// conversations, and for department conversations given another
+// orchestration mode.
+func (s *ChatService) departmentTurns(
+	ctx context.Context,
+	conversation *domain.Conversation,
+	message *domain.Message,
+	participants []*domain.Agent,
+	input string,
+) []agentTurn {
+	if len(mentionedAgents(message.Content, participants)) > 0 ||
+		s.orchestrationMode(ctx, conversation, participants) != domain.OrchestrationMentions {
+		return nil
+	}
+	department, err := s.departmentRepo.GetByConversationID(ctx, conversation.ID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Printf("Failed to load the department of conversation %s: %v", conversation.ID, err)
+		}
+		return nil
+	}
+	if department.LeadAgentID == nil {
+		return nil
+	}
+	lead := findAgent(participants, *department.LeadAgentID)
+	if lead == nil {
+		return nil
+	}
+	return []agentTurn{{agent: lead, input: departmentInput(department, lead, participants, input)}}
+}
+
+// HandleAgentReply follows up once an agent has answered: agents the reply
+// @mentions are delegated sub-tasks, a moderator's reply passes the message
+// on to the agents it mentions, and each debate turn hands over to the next
+// agent. messageID is the agent's reply; when it is nil the agent's latest
+// message is used. Failures are logged.
+func (s *ChatService) HandleAgentReply(ctx context.Context, taskID uuid.UUID, messageID *uuid.UUID) {
+	if err := s.handleAgentReply(ctx, taskID, messageID); err != nil {
+		log.Printf("Failed to continue conversation after task %s: %v", taskID, err)
+	}
+}
+
+func (s *ChatService) handleAgentReply(ctx context.Context, taskID uuid.UUID, messageID *uuid.UUID) error {
+	task, err := s.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	// Tasks without a message, such as scheduled ones, and cancelled tasks
+	// start no follow-ups
+	if task.MessageID == uuid.Nil || task.Status == domain.TaskStatusCancelled {
+		return nil
+	}
+	conversation, err := s.conversationRepo.GetByID(ctx, task.ConversationID)
+	if err != nil {
+		return err
+	}
+	if conversation.IsArchived() {
+		return nil
+	}
+	participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return err
+	}
+
+	trigger, err := s.messageRepo.GetByID(ctx, task.MessageID)
+	if err != nil {
+		return err
+	}
+	reply, err := s.agentReply(ctx, task, messageID)
+	if err != nil {
+		return err
+	}
+
+	var turns []agentTurn
+	switch s.orchestrationMode(ctx, conversation, participants) {
+	case domain.OrchestrationDebate:
+		// Debaters address each other; turns follow the debate, not mentions
+		turns, err := s.debateTurns(ctx, conversation, participants, task, trigger, reply)
+		if err != nil {
+			return err
+		}
+		for _, turn := range turns {
+			s.createTurnTask(ctx, reply, turn)
+		}
+		return nil
+	case domain.OrchestrationModerator:
+		turns = s.moderatorTurns(conversation, participants, task, trigger, reply)
+	}
+	if turns == nil {
+		turns = delegationTurns(participants, task, reply)
+	}
+
+	for _, turn := range turns {
+		_, err := s.taskService.Delegate(ctx, DelegateInput{
+			Parent:    task,
+			MessageID: reply.ID,
+			AgentID:   turn.agent.ID,
+			Input:     turn.input,
+		})
+		if err != nil {
+			log.Printf("Failed to delegate task %s to agent %s: %v", task.ID, turn.agent.ID, err)
+		}
+	}
+	return nil
+}
+
+// delegationTurns hands sub-tasks to the other participants an agent's
+// reply @mentions
+func delegationTurns(participants []*domain.Agent, task *domain.Task, reply *domain.Message) []agentTurn {
+	delegator := findAgent(participants, task.AgentID)
+	if delegator == nil {
+		return nil
+	}
+	var turns []agentTurn
+	for _, agent := range mentionedAgents(reply.Content, participants) {
+		if agent.ID != delegator.ID {
+			turns = append(turns, agentTurn{agent: agent, input: delegatedInput(delegator, reply.Content)})
+		}
+	}
+	return turns
+}
+
+// agentReply loads the message a task's agent answered with
+func (s *ChatService) agentReply(ctx context.Context, task *domain.Task, messageID *uuid.UUID) (*domain.Message, error) {
+	var reply *domain.Message
+	var err error
+	if messageID != nil {
+		reply, err = s.messageRepo.GetByID(ctx, *messageID)
+	} else {
+		reply, err = s.messageRepo.GetLatestByConversationID(ctx, task.ConversationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reply.ConversationID != task.ConversationID || reply.SenderType != domain.SenderTypeAgent || reply.SenderID != task.AgentID {
+		return nil, fmt.Errorf("%w: message %s is not the reply of task %s", domain.ErrInvalidInput, reply.ID, task.ID)
+	}
+	return reply, nil
+}
+
+// moderatorTurns passes a user's message on to the agents the moderator
+// @mentioned in its reply. Only the moderator's answers to user messages
+// that did not mention anyone are routed.
+func (s *ChatService) moderatorTurns(
+	conversation *domain.Conversation,
+	participants []*domain.Agent,
+	task *domain.Task,
+	trigger, reply *domain.Message,
+) []agentTurn {
+	moderator := s.moderator(conversation, participants)
+	if task.AgentID != moderator.ID || trigger.SenderType != domain.SenderTypeUser ||
+		len(mentionedAgents(trigger.Content, participants)) > 0 {
+		return nil
+	}
+
+	var turns []agentTurn
+	for _, agent := range mentionedAgents(reply.Content, participants) {
+		if agent.ID != moderator.ID {
+			turns = append(turns, agentTurn{agent: agent, input: routedInput(moderator, trigger.Content)})
+		}
+	}
+	return turns
+}
+
+// debateTurns hands a debate over to the next agent until every agent has
+// spoken for the conversation's number of rounds. A new user message ends
+// the debate in progress.
+func (s *ChatService) debateTurns(
+	ctx context.Context,
+	conversation *domain.Conversation,
+	participants []*domain.Agent,
+	task *domain.Task,
+	trigger, reply *domain.Message,
+) ([]agentTurn, error) {
+	if len(participants) < 2 || trigger.ParentMessageID != nil {
+		return nil, nil
+	}
+	// Debates start from a user message that mentions no one; every later
+	// turn answers the previous agent
+	if trigger.SenderType == domain.SenderTypeUser && len(mentionedAgents(trigger.Content, participants)) > 0 {
+		return nil, nil
+	}
+
+	total := len(participants) * debateRounds(conversation)
+	recent, err := s.messageRepo.GetRecentByConversationID(ctx, conversation.ID, nil, total+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from the newest message to the user message that started
+	// the debate, counting the turns taken
+	spoken := 0
+	seenReply := false
+	var topic *domain.Message
+	for i := len(recent) - 1; i >= 0; i-- {
+		m := recent[i]
+		if m.SenderType == domain.SenderTypeUser {
+			topic = m
+			break
+		}
+		if m.ID == reply.ID {
+			seenReply = true
+		}
+		spoken++
+	}
+	if topic == nil || !seenReply || spoken >= total {
+		return nil, nil
+	}
+
+	next := participants[0]
+	for i, agent := range participants {
+		if agent.ID == task.AgentID {
+			next = participants[(i+1)%len(participants)]
+			break
+		}
+	}
+	round := spoken/len(participants) + 1
+	return []agentTurn{{agent: next, input: debateInput(participants, round, debateRounds(conversation), topic.Content)}}, nil
+}
+
+// createTurnTask starts the next debate turn, answering an agent's reply,
+// logging rather than failing when it cannot be created
+func (s *ChatService) createTurnTask(ctx context.Context, reply *domain.Message, turn agentTurn) {
+	_, err := s.taskService.CreateTask(ctx, CreateTaskInput{
+		OfficeID:       reply.OfficeID,
+		ConversationID: reply.ConversationID,
+		MessageID:      reply.ID,
+		AgentID:        turn.agent.ID,
+		Input:          turn.input,
+	})
+	if err != nil {
+		log.Printf("Failed to create task for agent %s after message %s: %v", turn.agent.ID, reply.ID, err)
+	}
+}
+
+// nextSpeaker returns the participant after the one that spoke last, or the
+// first participant when none has spoken yet
+func (s *ChatService) nextSpeaker(ctx context.Context, conversationID uuid.UUID, participants []*domain.Agent) (*domain.Agent, error) {
+	recent, err := s.messageRepo.GetRecentByConversationID(ctx, conversationID, nil, 50)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].SenderType != domain.SenderTypeAgent {
+			continue
+		}
+		for j, agent := range participants {
+			if agent.ID == recent[i].SenderID {
+				return participants[(j+1)%len(participants)], nil
+			}
+		}
+	}
+	return participants[0], nil
+}
+
+// debateRounds returns a conversation's debate rounds, defaulting when unset
+func debateRounds(conversation *domain.Conversation) int {
+	if conversation.DebateRounds < 1 {
+		return domain.DefaultDebateRounds
+	}
+	return min(conversation.DebateRounds, maxDebateRounds)
+}
+
+// moderatorInput asks the moderator to answer a message or pass it on
+func moderatorInput(moderator *domain.Agent, participants []*domain.Agent, input string) string {
+	var others []string
+	for _, agent := range participants {
+		if agent.ID != moderator.ID {
+			others = append(others, "@"+agent.GetName())
+		}
+	}
+	return fmt.Sprintf("You are moderating this conversation with %s. Answer the message below yourself, "+
+		"or pass it on by @mentioning the agents who should answer it, with a note on what each should cover.\n\nMessage:\n%s",
+		strings.Join(others, ", "), input)
+}
+
+// departmentInput asks a department's lead to answer a message or delegate
+// it to the members
+func departmentInput(department *domain.Department, lead *domain.Agent, participants []*domain.Agent, input string) string {
+	var members []string
+	for _, agent := range participants {
+		if agent.ID != lead.ID {
+			members = append(members, "@"+agent.GetName())
+		}
+	}
+	team := "no other members yet"
+	if len(members) > 0 {
+		team = "members " + strings.Join(members, ", ")
+	}
+	return fmt.Sprintf("You lead the %s department, with %s. Answer the message below yourself, "+
+		"or delegate it by @mentioning the members who should work on it, with a note on what each should do.\n\nMessage:\n%s",
+		department.Name, team, input)
+}
+
+// routedInput is the input of an agent the moderator passed a message on to
+func routedInput(moderator *domain.Agent, content string) string {
+	return fmt.Sprintf("%s, the moderator, passed this message on to you; their note is the latest message in the conversation.\n\nMessage:\n%s",
+		moderator.GetName(), content)
+}
+
+// delegatedInput is the input of an agent another agent delegated to
+func delegatedInput(delegator *domain.Agent, content string) string {
+	return fmt.Sprintf("%s mentioned you in their reply and is handing part of the work to you. Do what they asked of you.\n\n%s's reply:\n%s",
+		delegator.GetName(), delegator.GetName(), content)
+}
+
+// debateInput asks an agent for its turn in a debate about the topic
+func debateInput(participants []*domain.Agent, round, rounds int, topic string) string {
+	names := make([]string, len(participants))
+	for i, agent := range participants {
+		names[i] = agent.GetName()
+	}
+	return fmt.Sprintf("This is round %d of %d of a debate between %s. Respond to the arguments made so far, "+
+		"then give your own position.\n\nTopic:\n%s", round, rounds, strings.Join(names, ", "), topic)
+}
+
+// findAgent returns the agent with the ID, or nil
+func findAgent(agents []*domain.Agent, id uuid.UUID) *domain.Agent {
+	for _, agent := range agents {
+		if agent.ID == id {
+			return agent
+		}
+	}
+	return nil
+}
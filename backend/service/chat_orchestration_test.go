@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestAgent(name string) *domain.Agent {
+	return &domain.Agent{ID: uuid.New(), CustomName: name}
+}
+
+// turnAgents returns the names of the agents given turns
+func turnAgents(turns []agentTurn) []string {
+	names := make([]string, len(turns))
+	for i, turn := range turns {
+		names[i] = turn.agent.GetName()
+	}
+	return names
+}
+
+func equalNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMentionedAgents(t *testing.T) {
+	nova, atlas := newTestAgent("Nova"), newTestAgent("Atlas")
+	participants := []*domain.Agent{nova, atlas}
+
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"@Nova can you draft the plan?", []string{"Nova"}},
+		{"@nova and @ATLAS, please sync", []string{"Nova", "Atlas"}},
+		{"Nova, no mention here", []string{}},
+		{"@Orion is not in this conversation", []string{}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, agent := range mentionedAgents(tt.content, participants) {
+			got = append(got, agent.GetName())
+		}
+		if !equalNames(got, tt.want) {
+			t.Errorf("mentionedAgents(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestUserMessageTurnsRoutesMentions(t *testing.T) {
+	ctx := context.Background()
+	s := &ChatService{}
+	nova, atlas, orion := newTestAgent("Nova"), newTestAgent("Atlas"), newTestAgent("Orion")
+	participants := []*domain.Agent{nova, atlas, orion}
+	conversation := &domain.Conversation{ID: uuid.New(), Type: domain.ConversationTypeGroup}
+
+	message := &domain.Message{Content: "@atlas and @Orion review this"}
+	turns := s.userMessageTurns(ctx, conversation, message, participants, "review this")
+	if got := turnAgents(turns); !equalNames(got, []string{"Atlas", "Orion"}) {
+		t.Errorf("turns go to %v, want [Atlas Orion]", got)
+	}
+	for _, turn := range turns {
+		if turn.input != "review this" {
+			t.Errorf("%s gets input %q, want the message", turn.agent.GetName(), turn.input)
+		}
+	}
+
+	// Without a mention nobody answers in a group using mentions
+	message = &domain.Message{Content: "anyone there?"}
+	if turns := s.userMessageTurns(ctx, conversation, message, participants, message.Content); len(turns) != 0 {
+		t.Errorf("unmentioned group message gets turns for %v, want none", turnAgents(turns))
+	}
+}
+
+func TestUserMessageTurnsDirectConversation(t *testing.T) {
+	s := &ChatService{}
+	nova := newTestAgent("Nova")
+	conversation := &domain.Conversation{ID: uuid.New(), Type: domain.ConversationTypeDirect}
+	message := &domain.Message{Content: "hello"}
+
+	turns := s.userMessageTurns(context.Background(), conversation, message, []*domain.Agent{nova}, "hello")
+	if got := turnAgents(turns); !equalNames(got, []string{"Nova"}) {
+		t.Errorf("turns go to %v, want [Nova]", got)
+	}
+}
+
+func TestUserMessageTurnsAllModeRequiresAdvancedOrchestration(t *testing.T) {
+	tests := []struct {
+		tier domain.SubscriptionTier
+		want []string
+	}{
+		{domain.TierBusiness, []string{"Nova", "Atlas"}},
+		// Offices that lost advanced orchestration fall back to mentions
+		{domain.TierProfessional, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.tier), func(t *testing.T) {
+			subscriptions, m := newTestSubscriptionService(t)
+			s := &ChatService{subscriptionService: subscriptions}
+			officeID := uuid.New()
+			participants := []*domain.Agent{newTestAgent("Nova"), newTestAgent("Atlas")}
+			conversation := &domain.Conversation{
+				ID:                uuid.New(),
+				OfficeID:          officeID,
+				Type:              domain.ConversationTypeGroup,
+				OrchestrationMode: domain.OrchestrationAll,
+			}
+
+			m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(&domain.Subscription{Tier: tt.tier}, nil)
+
+			message := &domain.Message{Content: "status update please"}
+			turns := s.userMessageTurns(context.Background(), conversation, message, participants, message.Content)
+			if got := turnAgents(turns); !equalNames(got, tt.want) {
+				t.Errorf("turns go to %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegationTurns(t *testing.T) {
+	nova, atlas, orion := newTestAgent("Nova"), newTestAgent("Atlas"), newTestAgent("Orion")
+	participants := []*domain.Agent{nova, atlas, orion}
+	task := &domain.Task{ID: uuid.New(), AgentID: nova.ID}
+
+	// An agent mentioning itself does not delegate to itself
+	reply := &domain.Message{Content: "I (@Nova) will write it; @orion please test it"}
+	turns := delegationTurns(participants, task, reply)
+	if got := turnAgents(turns); !equalNames(got, []string{"Orion"}) {
+		t.Fatalf("delegated to %v, want [Orion]", got)
+	}
+	if turns[0].input != delegatedInput(nova, reply.Content) {
+		t.Errorf("delegated input = %q", turns[0].input)
+	}
+
+	// Agents that left the conversation delegate nothing
+	task = &domain.Task{ID: uuid.New(), AgentID: uuid.New()}
+	if turns := delegationTurns(participants, task, reply); len(turns) != 0 {
+		t.Errorf("former participant delegated to %v, want nobody", turnAgents(turns))
+	}
+}
+
+func TestModeratorTurns(t *testing.T) {
+	s := &ChatService{}
+	moderator, atlas, orion := newTestAgent("Mod"), newTestAgent("Atlas"), newTestAgent("Orion")
+	participants := []*domain.Agent{moderator, atlas, orion}
+	conversation := &domain.Conversation{ID: uuid.New(), Type: domain.ConversationTypeGroup, ModeratorID: &moderator.ID}
+	reply := &domain.Message{Content: "@Atlas take the backend, @Mod will follow up"}
+
+	tests := []struct {
+		name    string
+		agentID uuid.UUID
+		trigger *domain.Message
+		want    []string
+	}{
+		{"routes the message to mentioned agents", moderator.ID,
+			&domain.Message{SenderType: domain.SenderTypeUser, Content: "build the API"}, []string{"Atlas"}},
+		{"ignores messages that mention someone", moderator.ID,
+			&domain.Message{SenderType: domain.SenderTypeUser, Content: "@Orion build the API"}, []string{}},
+		{"ignores agent messages", moderator.ID,
+			&domain.Message{SenderType: domain.SenderTypeAgent, Content: "build the API"}, []string{}},
+		{"ignores other agents' replies", atlas.ID,
+			&domain.Message{SenderType: domain.SenderTypeUser, Content: "build the API"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &domain.Task{ID: uuid.New(), AgentID: tt.agentID}
+			turns := s.moderatorTurns(conversation, participants, task, tt.trigger, reply)
+			if got := turnAgents(turns); !equalNames(got, tt.want) {
+				t.Errorf("turns go to %v, want %v", got, tt.want)
+			}
+			for _, turn := range turns {
+				if turn.input != routedInput(moderator, tt.trigger.Content) {
+					t.Errorf("%s gets input %q", turn.agent.GetName(), turn.input)
+				}
+			}
+		})
+	}
+}
+
+func TestDepartmentTurnsGoToTheLead(t *testing.T) {
+	ctx := context.Background()
+	departments := mocks.NewMockDepartmentRepository(gomock.NewController(t))
+	s := &ChatService{departmentRepo: departments}
+	lead, atlas := newTestAgent("Lead"), newTestAgent("Atlas")
+	participants := []*domain.Agent{lead, atlas}
+	conversation := &domain.Conversation{ID: uuid.New(), Type: domain.ConversationTypeGroup}
+	department := &domain.Department{ID: uuid.New(), Name: "Research", LeadAgentID: &lead.ID, ConversationID: &conversation.ID}
+	departments.EXPECT().GetByConversationID(gomock.Any(), conversation.ID).Return(department, nil)
+
+	message := &domain.Message{Content: "summarize the survey"}
+	turns := s.departmentTurns(ctx, conversation, message, participants, message.Content)
+	if got := turnAgents(turns); !equalNames(got, []string{"Lead"}) {
+		t.Fatalf("turns go to %v, want [Lead]", got)
+	}
+	if turns[0].input != departmentInput(department, lead, participants, message.Content) {
+		t.Errorf("the lead gets input %q", turns[0].input)
+	}
+
+	// Mentioned members answer themselves, without a department lookup
+	message = &domain.Message{Content: "@Atlas summarize the survey"}
+	if turns := s.departmentTurns(ctx, conversation, message, participants, message.Content); turns != nil {
+		t.Errorf("mentioning message gets department turns for %v, want none", turnAgents(turns))
+	}
+
+	// Other conversations are left to their orchestration mode
+	other := &domain.Conversation{ID: uuid.New(), Type: domain.ConversationTypeGroup}
+	departments.EXPECT().GetByConversationID(gomock.Any(), other.ID).Return(nil, domain.ErrNotFound)
+	message = &domain.Message{Content: "summarize the survey"}
+	if turns := s.departmentTurns(ctx, other, message, participants, message.Content); turns != nil {
+		t.Errorf("conversation without a department gets turns for %v, want none", turnAgents(turns))
+	}
+}
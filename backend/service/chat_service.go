@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -11,24 +16,54 @@ import (
 
 // ChatService handles chat-related operations
 type ChatService struct {
-	conversationRepo domain.ConversationRepository
-	messageRepo      domain.MessageRepository
-	agentRepo        domain.AgentRepository
-	taskService      *TaskService
+	conversationRepo    domain.ConversationRepository
+	messageRepo         domain.MessageRepository
+	readRepo            domain.ConversationReadRepository
+	agentRepo           domain.AgentRepository
+	departmentRepo      domain.DepartmentRepository
+	txManager           domain.TxManager
+	attachmentService   *AttachmentService
+	subscriptionService *SubscriptionService
+	taskService         *TaskService
+	costEstimates       *CostEstimateService
+	events              domain.EventPublisher
+	webhooks            *WebhookDispatcher
+	audit               *AuditService
+	moderation          *ContentModerationService
 }
 
 // NewChatService creates a new ChatService instance
 func NewChatService(
 	conversationRepo domain.ConversationRepository,
 	messageRepo domain.MessageRepository,
+	readRepo domain.ConversationReadRepository,
 	agentRepo domain.AgentRepository,
+	departmentRepo domain.DepartmentRepository,
+	txManager domain.TxManager,
+	attachmentService *AttachmentService,
+	subscriptionService *SubscriptionService,
 	taskService *TaskService,
+	costEstimates *CostEstimateService,
+	events domain.EventPublisher,
+	webhooks *WebhookDispatcher,
+	audit *AuditService,
+	moderation *ContentModerationService,
 ) *ChatService {
 	return &ChatService{
-		conversationRepo: conversationRepo,
-		messageRepo:      messageRepo,
-		agentRepo:        agentRepo,
-		taskService:      taskService,
+		conversationRepo:    conversationRepo,
+		messageRepo:         messageRepo,
+		readRepo:            readRepo,
+		agentRepo:           agentRepo,
+		departmentRepo:      departmentRepo,
+		txManager:           txManager,
+		attachmentService:   attachmentService,
+		subscriptionService: subscriptionService,
+		taskService:         taskService,
+		costEstimates:       costEstimates,
+		events:              events,
+		webhooks:            webhooks,
+		audit:               audit,
+		moderation:          moderation,
 	}
 }
 
@@ -40,26 +75,43 @@ type CreateConversationInput struct {
 	AgentIDs []uuid.UUID
 }
 
-// CreateConversation creates a new conversation
+// CreateConversation creates a new conversation between the office's agents
 func (s *ChatService) CreateConversation(ctx context.Context, input CreateConversationInput) (*domain.Conversation, error) {
-	conversation := &domain.Conversation{
-		ID:        uuid.New(),
-		OfficeID:  input.OfficeID,
-		Type:      input.Type,
-		Name:      input.Name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	for _, agentID := range input.AgentIDs {
+		_, err := officeAgent(ctx, s.agentRepo, input.OfficeID, agentID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, fmt.Errorf("%w: agent %s is not in this office", domain.ErrInvalidInput, agentID)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.conversationRepo.Create(ctx, conversation); err != nil {
-		return nil, err
+	conversation := &domain.Conversation{
+		ID:                uuid.New(),
+		OfficeID:          input.OfficeID,
+		Type:              input.Type,
+		Name:              input.Name,
+		OrchestrationMode: domain.OrchestrationMentions,
+		DebateRounds:      domain.DefaultDebateRounds,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
 
-	// Add participants
-	for _, agentID := range input.AgentIDs {
-		if err := s.conversationRepo.AddParticipant(ctx, conversation.ID, agentID); err != nil {
-			return nil, err
+	// The conversation is only created with all of its participants
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.conversationRepo.Create(ctx, conversation); err != nil {
+			return err
+		}
+		for _, agentID := range input.AgentIDs {
+			if err := s.conversationRepo.AddParticipant(ctx, conversation.ID, agentID); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Load participants
@@ -72,28 +124,133 @@ func (s *ChatService) CreateConversation(ctx context.Context, input CreateConver
 	return conversation, nil
 }
 
-// GetConversations returns all conversations for an office
-func (s *ChatService) GetConversations(ctx context.Context, officeID uuid.UUID) ([]*domain.Conversation, error) {
-	conversations, err := s.conversationRepo.GetByOfficeID(ctx, officeID)
+// GetConversations returns the conversations of an office, without archived
+// ones unless includeArchived is set, with their participants, the user's
+// unread count and the latest message of each
+func (s *ChatService) GetConversations(ctx context.Context, officeID, userID uuid.UUID, includeArchived bool) ([]*domain.Conversation, error) {
+	return s.conversationRepo.GetPreviewsByOfficeID(ctx, officeID, userID, includeArchived)
+}
+
+// GetConversation returns a conversation of the office
+func (s *ChatService) GetConversation(ctx context.Context, officeID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Load participants for each conversation
-	for _, conv := range conversations {
-		participants, err := s.conversationRepo.GetParticipants(ctx, conv.ID)
-		if err != nil {
-			return nil, err
+	participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Participants = participants
+
+	return conversation, nil
+}
+
+// UpdateConversationInput contains changes to a conversation. Nil fields are
+// left as is.
+type UpdateConversationInput struct {
+	OfficeID       uuid.UUID
+	ConversationID uuid.UUID
+	Name           *string
+	Archived       *bool
+}
+
+// UpdateConversation renames, archives or unarchives a conversation of the
+// office
+func (s *ChatService) UpdateConversation(ctx context.Context, input UpdateConversationInput) (*domain.Conversation, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		conversation.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Archived != nil && *input.Archived != conversation.IsArchived() {
+		if *input.Archived {
+			now := time.Now()
+			conversation.ArchivedAt = &now
+		} else {
+			conversation.ArchivedAt = nil
 		}
-		conv.Participants = participants
 	}
 
-	return conversations, nil
+	conversation.UpdatedAt = time.Now()
+	if err := s.conversationRepo.Update(ctx, conversation); err != nil {
+		return nil, err
+	}
+
+	return s.GetConversation(ctx, input.OfficeID, conversation.ID)
+}
+
+// DeleteConversation deletes a conversation of the office. Its messages are
+// kept and its schedules paused, so it can be restored for
+// deletionGracePeriod.
+func (s *ChatService) DeleteConversation(ctx context.Context, officeID, conversationID uuid.UUID) error {
+	conversation, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID)
+	if err != nil {
+		return err
+	}
+	if err := s.conversationRepo.SoftDelete(ctx, conversationID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionConversationDelete,
+		EntityType: domain.AuditEntityConversation,
+		EntityID:   conversationID,
+		OfficeID:   officeID,
+		Details:    map[string]any{"name": conversation.Name, "type": conversation.Type},
+	})
+	return nil
+}
+
+// RestoreConversation brings back a conversation of the office deleted
+// within deletionGracePeriod, or fails with ErrNotFound. Its schedules stay
+// paused.
+func (s *ChatService) RestoreConversation(ctx context.Context, officeID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	if err := s.conversationRepo.Restore(ctx, officeID, conversationID, restorableSince()); err != nil {
+		return nil, err
+	}
+	conversation, err := s.GetConversation(ctx, officeID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionConversationRestore,
+		EntityType: domain.AuditEntityConversation,
+		EntityID:   conversationID,
+		OfficeID:   officeID,
+		Details:    map[string]any{"name": conversation.Name, "type": conversation.Type},
+	})
+	return conversation, nil
+}
+
+// AddParticipant adds an active agent of the office to a group conversation
+func (s *ChatService) AddParticipant(ctx context.Context, officeID, conversationID, agentID uuid.UUID) (*domain.Conversation, error) {
+	conversation, err := s.groupConversation(ctx, officeID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := officeAgent(ctx, s.agentRepo, officeID, agentID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !agent.IsActive) {
+		return nil, fmt.Errorf("%w: agent %s is not an active agent of this office", domain.ErrInvalidInput, agentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.conversationRepo.AddParticipant(ctx, conversation.ID, agent.ID); err != nil {
+		return nil, err
+	}
+	return s.GetConversation(ctx, officeID, conversation.ID)
 }
 
-// GetConversation returns a conversation by ID
-func (s *ChatService) GetConversation(ctx context.Context, conversationID uuid.UUID) (*domain.Conversation, error) {
-	conversation, err := s.conversationRepo.GetByID(ctx, conversationID)
+// RemoveParticipant removes an agent from a group conversation. The last
+// participant cannot be removed.
+func (s *ChatService) RemoveParticipant(ctx context.Context, officeID, conversationID, agentID uuid.UUID) (*domain.Conversation, error) {
+	conversation, err := s.groupConversation(ctx, officeID, conversationID)
 	if err != nil {
 		return nil, err
 	}
@@ -102,11 +259,93 @@ func (s *ChatService) GetConversation(ctx context.Context, conversationID uuid.U
 	if err != nil {
 		return nil, err
 	}
-	conversation.Participants = participants
+	found := false
+	for _, agent := range participants {
+		if agent.ID == agentID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: agent %s is not a participant", domain.ErrInvalidInput, agentID)
+	}
+	if len(participants) == 1 {
+		return nil, fmt.Errorf("%w: a conversation needs at least one participant", domain.ErrInvalidInput)
+	}
+
+	if err := s.conversationRepo.RemoveParticipant(ctx, conversation.ID, agentID); err != nil {
+		return nil, err
+	}
+	return s.GetConversation(ctx, officeID, conversation.ID)
+}
 
+// groupConversation returns a group conversation of the office. Direct
+// conversations keep the participant they were created with.
+func (s *ChatService) groupConversation(ctx context.Context, officeID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.Type != domain.ConversationTypeGroup {
+		return nil, fmt.Errorf("%w: participants can only be changed in group conversations", domain.ErrInvalidInput)
+	}
 	return conversation, nil
 }
 
+// MarkReadInput contains input for marking a conversation as read
+type MarkReadInput struct {
+	OfficeID       uuid.UUID
+	UserID         uuid.UUID
+	ConversationID uuid.UUID
+	// MessageID is the last message read; nil means the latest message
+	MessageID *uuid.UUID
+}
+
+// MarkRead records that the user has read a conversation of the office up to
+// a message. The read position only moves forward. It returns nil when the
+// conversation has no messages yet.
+func (s *ChatService) MarkRead(ctx context.Context, input MarkReadInput) (*domain.ConversationRead, error) {
+	if _, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID); err != nil {
+		return nil, err
+	}
+
+	var message *domain.Message
+	var err error
+	if input.MessageID != nil {
+		message, err = s.messageRepo.GetByID(ctx, *input.MessageID)
+		if errors.Is(err, domain.ErrNotFound) || (err == nil && message.ConversationID != input.ConversationID) {
+			return nil, fmt.Errorf("%w: message_id must be a message of this conversation", domain.ErrInvalidInput)
+		}
+	} else {
+		message, err = s.messageRepo.GetLatestByConversationID(ctx, input.ConversationID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	read, err := s.readRepo.MarkRead(ctx, &domain.ConversationRead{
+		ConversationID:    input.ConversationID,
+		UserID:            input.UserID,
+		LastReadMessageID: message.ID,
+		LastReadAt:        message.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := s.readRepo.GetActivity(ctx, input.UserID, []uuid.UUID{input.ConversationID})
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := activity[input.ConversationID]; ok {
+		read.UnreadCount = a.UnreadCount
+	}
+	return read, nil
+}
+
 // SendMessageInput contains input for sending a message
 type SendMessageInput struct {
 	OfficeID       uuid.UUID
@@ -114,60 +353,390 @@ type SendMessageInput struct {
 	SenderType     domain.SenderType
 	SenderID       uuid.UUID
 	Content        string
+	// ParentMessageID makes the message a reply in that message's thread
+	ParentMessageID *uuid.UUID
+	// AttachmentIDs are files the sender uploaded to the conversation to
+	// send with the message
+	AttachmentIDs []uuid.UUID
 }
 
-// SendMessage sends a message in a conversation
+// SendMessage sends a message in a conversation of the office
 func (s *ChatService) SendMessage(ctx context.Context, input SendMessageInput) (*domain.Message, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var threadID *uuid.UUID
+	if input.ParentMessageID != nil {
+		parent, err := s.messageRepo.GetByID(ctx, *input.ParentMessageID)
+		if errors.Is(err, domain.ErrNotFound) || (err == nil && parent.ConversationID != conversation.ID) {
+			return nil, fmt.Errorf("%w: parent_message_id must be a message of this conversation", domain.ErrInvalidInput)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Replies to replies join the same thread
+		threadID = &parent.ID
+		if parent.ParentMessageID != nil {
+			threadID = parent.ParentMessageID
+		}
+	}
+
+	var attachments []*domain.Attachment
+	if len(input.AttachmentIDs) > 0 {
+		if input.SenderType != domain.SenderTypeUser {
+			return nil, fmt.Errorf("%w: only users can send attachments", domain.ErrInvalidInput)
+		}
+		attachments, err = s.attachmentService.PendingAttachments(ctx, conversation.ID, input.SenderID, input.AttachmentIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if strings.TrimSpace(input.Content) == "" && len(attachments) == 0 {
+		return nil, fmt.Errorf("%w: a message needs content or attachments", domain.ErrInvalidInput)
+	}
+	// Agents cannot answer while the subscription is not active, so the
+	// message is refused rather than left unanswered
+	if input.SenderType != domain.SenderTypeAgent && !conversation.IsArchived() {
+		if err := s.subscriptionService.RequireActive(ctx, input.OfficeID); err != nil {
+			return nil, err
+		}
+	}
+
 	message := &domain.Message{
-		ID:             uuid.New(),
-		OfficeID:       input.OfficeID,
-		ConversationID: input.ConversationID,
-		SenderType:     input.SenderType,
-		SenderID:       input.SenderID,
-		Content:        input.Content,
-		Metadata:       make(map[string]any),
-		CreatedAt:      time.Now(),
+		ID:              uuid.New(),
+		OfficeID:        input.OfficeID,
+		ConversationID:  input.ConversationID,
+		ParentMessageID: threadID,
+		SenderType:      input.SenderType,
+		SenderID:        input.SenderID,
+		Content:         input.Content,
+		Metadata:        make(map[string]any),
+		CreatedAt:       time.Now(),
+	}
+	// What users and visitors send agents is screened; agents' replies are
+	// not
+	if input.SenderType != domain.SenderTypeAgent {
+		if err := s.screenMessage(ctx, message); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.messageRepo.Create(ctx, message); err != nil {
 		return nil, err
 	}
+	if len(attachments) > 0 {
+		if err := s.attachmentService.AttachToMessage(ctx, attachments, message.ID); err != nil {
+			return nil, err
+		}
+		message.Attachments = attachments
+	}
 
-	// If message is from user, trigger agent processing
-	if input.SenderType == domain.SenderTypeUser {
-		go s.processUserMessage(context.Background(), message)
+	// Show the message to the office's other open sessions
+	payload := map[string]any{
+		"message_id":      message.ID.String(),
+		"conversation_id": message.ConversationID.String(),
+		"sender_type":     string(message.SenderType),
+		"sender_id":       message.SenderID.String(),
+		"content":         message.Content,
+	}
+	if message.ParentMessageID != nil {
+		payload["parent_message_id"] = message.ParentMessageID.String()
+	}
+	if len(message.Attachments) > 0 {
+		payload["attachments"] = message.Attachments
+	}
+	err = s.events.Publish(ctx, domain.NewEvent(message.OfficeID, domain.EventNewMessage, payload))
+	if err != nil {
+		log.Printf("Failed to publish message %s: %v", message.ID, err)
+	}
+	s.webhooks.Dispatch(ctx, message.OfficeID, domain.WebhookEventMessageCreated, payload)
+
+	// Agents answer users and widget visitors, but not in archived
+	// conversations
+	if input.SenderType != domain.SenderTypeAgent && !conversation.IsArchived() {
+		go s.processUserMessage(context.Background(), conversation, message)
 	}
 
 	return message, nil
 }
 
-// GetMessages returns messages for a conversation
-func (s *ChatService) GetMessages(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*domain.Message, error) {
-	if limit <= 0 {
-		limit = 50
+// GetMessages returns a page of messages for a conversation of the office
+// along with the total message count
+func (s *ChatService) GetMessages(
+	ctx context.Context,
+	officeID uuid.UUID,
+	conversationID uuid.UUID,
+	page domain.PageRequest,
+) ([]*domain.Message, int, error) {
+	if _, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID); err != nil {
+		return nil, 0, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+	messages, err := s.messageRepo.GetByConversationID(ctx, conversationID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.messageRepo.CountByConversationID(ctx, conversationID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.loadDetails(ctx, messages...); err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// EditMessageInput contains input for editing a message
+type EditMessageInput struct {
+	OfficeID  uuid.UUID
+	UserID    uuid.UUID
+	MessageID uuid.UUID
+	Content   string
+}
+
+// EditMessage changes the content of one of the user's own messages. The
+// previous content is appended to the message's edit history.
+func (s *ChatService) EditMessage(ctx context.Context, input EditMessageInput) (*domain.Message, error) {
+	message, err := officeMessage(ctx, s.messageRepo, input.OfficeID, input.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderType != domain.SenderTypeUser || message.SenderID != input.UserID {
+		return nil, fmt.Errorf("%w: only your own messages can be edited", domain.ErrForbidden)
+	}
+	if message.IsDeleted() {
+		return nil, fmt.Errorf("%w: deleted messages cannot be edited", domain.ErrInvalidInput)
+	}
+
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return nil, fmt.Errorf("%w: content is required", domain.ErrInvalidInput)
+	}
+	if content != message.Content {
+		now := time.Now()
+		if message.Metadata == nil {
+			message.Metadata = make(map[string]any)
+		}
+		history, _ := message.Metadata["edit_history"].([]any)
+		message.Metadata["edit_history"] = append(history, map[string]any{
+			"content":   message.Content,
+			"edited_at": now,
+		})
+		message.Content = content
+		message.EditedAt = &now
+		if err := s.screenMessage(ctx, message); err != nil {
+			return nil, err
+		}
+
+		if err := s.messageRepo.Update(ctx, message); err != nil {
+			return nil, err
+		}
+		s.publishMessageEvent(ctx, message, domain.EventMessageEdited, map[string]any{
+			"content":   message.Content,
+			"edited_at": now,
+		})
+	}
+
+	if err := s.loadDetails(ctx, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// screenMessage runs a message's content through content moderation
+func (s *ChatService) screenMessage(ctx context.Context, message *domain.Message) error {
+	target := ModerationTarget{
+		Type:     domain.ModerationContentMessage,
+		Content:  message.Content,
+		EntityID: message.ID,
+		OfficeID: message.OfficeID,
+	}
+	if message.SenderType == domain.SenderTypeUser {
+		target.UserID = message.SenderID
+	}
+	_, err := s.moderation.Screen(ctx, target)
+	return err
+}
+
+// DeleteMessage deletes one of the user's own messages or an agent's
+// message, erasing its content and attachments
+func (s *ChatService) DeleteMessage(ctx context.Context, officeID, userID, messageID uuid.UUID) error {
+	message, err := officeMessage(ctx, s.messageRepo, officeID, messageID)
+	if err != nil {
+		return err
+	}
+	if message.SenderType == domain.SenderTypeUser && message.SenderID != userID {
+		return fmt.Errorf("%w: only your own and agents' messages can be deleted", domain.ErrForbidden)
+	}
+	if message.IsDeleted() {
+		return nil
+	}
+
+	if err := s.messageRepo.SoftDelete(ctx, message.ID); err != nil {
+		return err
+	}
+	// Deleted messages show no attachments, so a failure only leaves files behind
+	if err := s.attachmentService.DeleteForMessage(ctx, message.ID); err != nil {
+		log.Printf("Failed to delete attachments of message %s: %v", message.ID, err)
+	}
+	s.publishMessageEvent(ctx, message, domain.EventMessageDeleted, nil)
+	return nil
+}
+
+// ReactionInput contains input for adding or removing a reaction
+type ReactionInput struct {
+	OfficeID  uuid.UUID
+	UserID    uuid.UUID
+	MessageID uuid.UUID
+	Emoji     string
+}
+
+// AddReaction adds the user's emoji reaction to a message
+func (s *ChatService) AddReaction(ctx context.Context, input ReactionInput) (*domain.Message, error) {
+	if !isEmoji(input.Emoji) {
+		return nil, fmt.Errorf("%w: emoji must be a single emoji", domain.ErrInvalidInput)
+	}
+	return s.react(ctx, input, true)
+}
+
+// RemoveReaction removes the user's emoji reaction from a message
+func (s *ChatService) RemoveReaction(ctx context.Context, input ReactionInput) (*domain.Message, error) {
+	return s.react(ctx, input, false)
+}
+
+// react adds or removes a reaction and announces it to the office
+func (s *ChatService) react(ctx context.Context, input ReactionInput, add bool) (*domain.Message, error) {
+	message, err := officeMessage(ctx, s.messageRepo, input.OfficeID, input.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.IsDeleted() {
+		return nil, fmt.Errorf("%w: deleted messages cannot be reacted to", domain.ErrInvalidInput)
+	}
+
+	eventType := domain.EventReactionAdded
+	if add {
+		err = s.messageRepo.AddReaction(ctx, message.ID, input.UserID, input.Emoji)
+	} else {
+		eventType = domain.EventReactionRemoved
+		err = s.messageRepo.RemoveReaction(ctx, message.ID, input.UserID, input.Emoji)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishMessageEvent(ctx, message, eventType, map[string]any{
+		"user_id": input.UserID.String(),
+		"emoji":   input.Emoji,
+	})
+
+	if err := s.loadDetails(ctx, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// loadDetails fills in the reactions and attachments of messages
+func (s *ChatService) loadDetails(ctx context.Context, messages ...*domain.Message) error {
+	ids := make([]uuid.UUID, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	reactions, err := s.messageRepo.GetReactions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	attachments, err := s.attachmentService.GetForMessages(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		message.Reactions = reactions[message.ID]
+		message.Attachments = attachments[message.ID]
+	}
+	return nil
+}
+
+// publishMessageEvent announces a change to a message to the office's
+// clients, logging rather than failing when the event cannot be published
+func (s *ChatService) publishMessageEvent(ctx context.Context, message *domain.Message, eventType domain.EventType, payload map[string]any) {
+	if payload == nil {
+		payload = make(map[string]any)
+	}
+	payload["message_id"] = message.ID.String()
+	payload["conversation_id"] = message.ConversationID.String()
+
+	if err := s.events.Publish(ctx, domain.NewEvent(message.OfficeID, eventType, payload)); err != nil {
+		log.Printf("Failed to publish %s for message %s: %v", eventType, message.ID, err)
 	}
-	return s.messageRepo.GetByConversationID(ctx, conversationID, limit, offset)
+}
+
+// isEmoji reports whether s looks like a single emoji, possibly with
+// modifiers such as skin tones or joiners: a few runes, at least one of
+// them a symbol, and no letters, digits or spaces
+func isEmoji(s string) bool {
+	if s == "" || utf8.RuneCountInString(s) > 8 || len(s) > 32 {
+		return false
+	}
+	symbol := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsSpace(r), unicode.IsControl(r):
+			return false
+		case unicode.Is(unicode.So, r):
+			symbol = true
+		}
+	}
+	return symbol
 }
 
 // processUserMessage handles agent response generation (runs async)
-func (s *ChatService) processUserMessage(ctx context.Context, message *domain.Message) {
+func (s *ChatService) processUserMessage(ctx context.Context, conversation *domain.Conversation, message *domain.Message) {
 	// Get conversation participants
 	participants, err := s.conversationRepo.GetParticipants(ctx, message.ConversationID)
-	if err != nil {
+	if err != nil || len(participants) == 0 {
 		return
 	}
 
-	// Determine which agents should respond
-	respondingAgents := s.determineRespondingAgents(message.Content, participants)
+	// Replies carry their thread so agents answer in context
+	input := message.Content
+	if message.ParentMessageID != nil {
+		thread, err := s.threadMessages(ctx, *message.ParentMessageID)
+		if err != nil {
+			log.Printf("Failed to load thread of message %s: %v", message.ID, err)
+		} else {
+			input = threadInput(thread, message, participants)
+		}
+	}
+
+	// Create tasks for the agents the orchestration mode picks, or the lead
+	// of a department, unless the cost policy holds them back
+	turns := s.departmentTurns(ctx, conversation, message, participants, input)
+	if turns == nil {
+		turns = s.userMessageTurns(ctx, conversation, message, participants, input)
+	}
+	for _, turn := range turns {
+		allowed := s.costEstimates.Allow(ctx, CostEstimateInput{
+			OfficeID:       message.OfficeID,
+			AgentID:        turn.agent.ID,
+			ConversationID: message.ConversationID,
+			MessageID:      message.ID,
+			Input:          turn.input,
+		})
+		if !allowed {
+			continue
+		}
 
-	// Create tasks for responding agents
-	for _, agent := range respondingAgents {
 		_, err := s.taskService.CreateTask(ctx, CreateTaskInput{
 			OfficeID:       message.OfficeID,
 			ConversationID: message.ConversationID,
 			MessageID:      message.ID,
-			AgentID:        agent.ID,
-			Input:          message.Content,
+			AgentID:        turn.agent.ID,
+			Input:          turn.input,
 		})
 		if err != nil {
 			// Log error but continue
@@ -176,17 +745,90 @@ func (s *ChatService) processUserMessage(ctx context.Context, message *domain.Me
 	}
 }
 
-// determineRespondingAgents determines which agents should respond to a message
-func (s *ChatService) determineRespondingAgents(content string, participants []*domain.Agent) []*domain.Agent {
-	var respondingAgents []*domain.Agent
+// GetThread returns a message of the office with the replies in its thread.
+// For a reply, the whole thread it belongs to is returned.
+func (s *ChatService) GetThread(ctx context.Context, officeID, messageID uuid.UUID) (*domain.Message, []*domain.Message, error) {
+	message, err := officeMessage(ctx, s.messageRepo, officeID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.ParentMessageID != nil {
+		if message, err = s.messageRepo.GetByID(ctx, *message.ParentMessageID); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	// Check for @mentions
+	replies, err := s.messageRepo.GetReplies(ctx, message.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if replies == nil {
+		replies = []*domain.Message{}
+	}
+	if err := s.loadDetails(ctx, append([]*domain.Message{message}, replies...)...); err != nil {
+		return nil, nil, err
+	}
+	return message, replies, nil
+}
+
+// threadMessages returns a thread's first message followed by its replies
+func (s *ChatService) threadMessages(ctx context.Context, threadID uuid.UUID) ([]*domain.Message, error) {
+	root, err := s.messageRepo.GetByID(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := s.messageRepo.GetReplies(ctx, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*domain.Message{root}, replies...), nil
+}
+
+// threadContextLength is how many earlier thread messages are quoted in the
+// input of a task answering a reply
+const threadContextLength = 20
+
+// threadInput builds the input of a task answering a thread reply: the
+// thread's first message and its latest replies before the reply, then the
+// reply itself
+func threadInput(thread []*domain.Message, reply *domain.Message, participants []*domain.Agent) string {
+	names := make(map[uuid.UUID]string, len(participants))
 	for _, agent := range participants {
-		agentName := agent.GetName()
-		if strings.Contains(strings.ToLower(content), "@"+strings.ToLower(agentName)) {
-			respondingAgents = append(respondingAgents, agent)
+		names[agent.ID] = agent.GetName()
+	}
+
+	var earlier []*domain.Message
+	for _, m := range thread {
+		if m.ID != reply.ID && !m.IsDeleted() {
+			earlier = append(earlier, m)
 		}
 	}
+	if len(earlier) > threadContextLength {
+		// Always keep the message that started the thread
+		earlier = append(earlier[:1], earlier[len(earlier)-threadContextLength+1:]...)
+	}
+
+	var b strings.Builder
+	b.WriteString("This message is a reply in a thread. Earlier messages in the thread:\n")
+	for _, m := range earlier {
+		sender := "User"
+		if m.SenderType == domain.SenderTypeAgent {
+			sender = names[m.SenderID]
+			if sender == "" {
+				sender = "Agent"
+			}
+		}
+		fmt.Fprintf(&b, "%s: %s\n", sender, m.Content)
+	}
+	b.WriteString("\nReply:\n")
+	b.WriteString(reply.Content)
+	return b.String()
+}
+
+// determineRespondingAgents determines which agents should respond to a message
+func (s *ChatService) determineRespondingAgents(content string, participants []*domain.Agent) []*domain.Agent {
+	// Check for @mentions
+	respondingAgents := mentionedAgents(content, participants)
 
 	// If no mentions and direct conversation, first agent responds
 	if len(respondingAgents) == 0 && len(participants) == 1 {
@@ -195,3 +837,15 @@ func (s *ChatService) determineRespondingAgents(content string, participants []*
 
 	return respondingAgents
 }
+
+// mentionedAgents returns the participants @mentioned in content
+func mentionedAgents(content string, participants []*domain.Agent) []*domain.Agent {
+	var mentioned []*domain.Agent
+	for _, agent := range participants {
+		agentName := agent.GetName()
+		if strings.Contains(strings.ToLower(content), "@"+strings.ToLower(agentName)) {
+			mentioned = append(mentioned, agent)
+		}
+	}
+	return mentioned
+}
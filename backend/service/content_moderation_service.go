@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// moderationExcerptLength caps how many characters of flagged content are
+// kept for review
+const moderationExcerptLength = 4000
+
+// ModerationActions sets what happens to flagged content of each type.
+// Types without an action are not screened.
+type ModerationActions map[domain.ModerationContentType]domain.ModerationAction
+
+// ContentModerationService screens user-written content and keeps the
+// queue of flagged content admins review
+type ContentModerationService struct {
+	flagRepo   domain.ModerationFlagRepository
+	moderators []domain.ContentModerator
+	actions    ModerationActions
+	audit      *AuditService
+}
+
+// NewContentModerationService creates a new ContentModerationService.
+// Moderators are asked in order until one flags the content, so cheap ones
+// such as a blocklist should come first.
+func NewContentModerationService(
+	flagRepo domain.ModerationFlagRepository,
+	moderators []domain.ContentModerator,
+	actions ModerationActions,
+	audit *AuditService,
+) *ContentModerationService {
+	return &ContentModerationService{
+		flagRepo:   flagRepo,
+		moderators: moderators,
+		actions:    actions,
+		audit:      audit,
+	}
+}
+
+// ModerationTarget is user-written content to screen
+type ModerationTarget struct {
+	Type    domain.ModerationContentType
+	Content string
+	// EntityID is what the content is saved as, such as the message
+	EntityID uuid.UUID
+	// OfficeID and UserID are where the content came from, if known
+	OfficeID uuid.UUID
+	UserID   uuid.UUID
+}
+
+// Screen runs content through the moderators and reports whether it was
+// flagged. Flagged content is queued for admin review and, when its type's
+// action is block, refused with ErrContentBlocked. Moderators that fail are
+// skipped, so an unreachable moderation API does not stop offices from
+// working.
+func (s *ContentModerationService) Screen(ctx context.Context, target ModerationTarget) (bool, error) {
+	action := s.actions[target.Type]
+	if action != domain.ModerationActionFlag && action != domain.ModerationActionBlock {
+		return false, nil
+	}
+	if len(s.moderators) == 0 || strings.TrimSpace(target.Content) == "" {
+		return false, nil
+	}
+
+	var result domain.ModerationResult
+	for _, moderator := range s.moderators {
+		var err error
+		result, err = moderator.Moderate(ctx, target.Content)
+		if err != nil {
+			log.Printf("Failed to moderate %s: %v", target.Type, err)
+			continue
+		}
+		if result.Flagged {
+			break
+		}
+	}
+	if !result.Flagged {
+		return false, nil
+	}
+
+	flag := &domain.ModerationFlag{
+		ID:          uuid.New(),
+		ContentType: target.Type,
+		OfficeID:    nullableID(target.OfficeID),
+		UserID:      nullableID(target.UserID),
+		Content:     moderationExcerpt(target.Content),
+		Categories:  result.Categories,
+		Action:      action,
+		Status:      domain.ModerationFlagPending,
+		CreatedAt:   time.Now(),
+	}
+	// Blocked content is never saved, so there is nothing to point at
+	if action == domain.ModerationActionFlag {
+		flag.EntityID = nullableID(target.EntityID)
+	}
+	if err := s.flagRepo.Create(ctx, flag); err != nil {
+		log.Printf("Failed to queue flagged %s for review: %v", target.Type, err)
+	}
+
+	if action == domain.ModerationActionBlock {
+		return true, domain.WithDetails(
+			fmt.Errorf("%w: the %s breaks the content policy", domain.ErrContentBlocked, strings.ReplaceAll(string(target.Type), "_", " ")),
+			map[string]any{"categories": result.Categories},
+		)
+	}
+	return true, nil
+}
+
+// ListFlags returns a page of the moderation queue, oldest first
+func (s *ContentModerationService) ListFlags(ctx context.Context, filter domain.ModerationFlagFilter, limit, offset int) ([]*domain.ModerationFlag, int, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.flagRepo.List(ctx, filter, limit, offset)
+}
+
+// ResolveFlag records an admin's decision on a pending flag. Acting on the
+// content itself, such as deleting a message, is left to the admin.
+func (s *ContentModerationService) ResolveFlag(
+	ctx context.Context,
+	adminID uuid.UUID,
+	flagID uuid.UUID,
+	status domain.ModerationFlagStatus,
+	note string,
+) (*domain.ModerationFlag, error) {
+	if status != domain.ModerationFlagDismissed && status != domain.ModerationFlagUpheld {
+		return nil, fmt.Errorf("%w: status must be dismissed or upheld", domain.ErrInvalidInput)
+	}
+	note = strings.TrimSpace(note)
+
+	if err := s.flagRepo.Resolve(ctx, flagID, status, adminID, note); err != nil {
+		return nil, err
+	}
+	flag, err := s.flagRepo.GetByID(ctx, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	event := AuditEvent{
+		Action:     domain.AuditActionAdminResolveFlag,
+		ActorID:    adminID,
+		EntityType: domain.AuditEntityModeration,
+		EntityID:   flagID,
+		Before:     map[string]any{"status": domain.ModerationFlagPending},
+		After:      map[string]any{"status": flag.Status},
+		Details:    map[string]any{"content_type": flag.ContentType, "action": flag.Action, "note": note},
+	}
+	if flag.OfficeID != nil {
+		event.OfficeID = *flag.OfficeID
+	}
+	s.audit.Record(ctx, event)
+	return flag, nil
+}
+
+// moderationExcerpt cuts content down to moderationExcerptLength characters
+func moderationExcerpt(content string) string {
+	if utf8.RuneCountInString(content) <= moderationExcerptLength {
+		return content
+	}
+	return string([]rune(content)[:moderationExcerptLength])
+}
+
+// nullableID returns nil for uuid.Nil
+func nullableID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
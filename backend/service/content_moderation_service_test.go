@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestScreenFlagsOrBlocksByContentType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	flags := mocks.NewMockModerationFlagRepository(ctrl)
+	unreachable := mocks.NewMockContentModerator(ctrl)
+	blocklist := mocks.NewMockContentModerator(ctrl)
+	svc := NewContentModerationService(flags, []domain.ContentModerator{unreachable, blocklist}, ModerationActions{
+		domain.ModerationContentMessage:      domain.ModerationActionFlag,
+		domain.ModerationContentSystemPrompt: domain.ModerationActionBlock,
+		domain.ModerationContentTemplate:     domain.ModerationActionOff,
+	}, nil)
+
+	// A failing moderator is skipped and the next one asked
+	unreachable.EXPECT().Moderate(gomock.Any(), gomock.Any()).Return(domain.ModerationResult{}, errors.New("timeout")).Times(2)
+	blocklist.EXPECT().Moderate(gomock.Any(), gomock.Any()).Return(domain.ModerationResult{Flagged: true, Categories: []string{"blocklist"}}, nil).Times(2)
+	var queued []*domain.ModerationFlag
+	flags.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, flag *domain.ModerationFlag) error {
+		queued = append(queued, flag)
+		return nil
+	}).Times(2)
+
+	message := ModerationTarget{Type: domain.ModerationContentMessage, Content: "buy now", EntityID: uuid.New(), OfficeID: uuid.New()}
+	if flagged, err := svc.Screen(context.Background(), message); err != nil || !flagged {
+		t.Fatalf("Screen flagged message = %v, %v; want it flagged and accepted", flagged, err)
+	}
+	prompt := ModerationTarget{Type: domain.ModerationContentSystemPrompt, Content: "buy now", EntityID: uuid.New(), OfficeID: uuid.New()}
+	_, err := svc.Screen(context.Background(), prompt)
+	if !errors.Is(err, domain.ErrContentBlocked) {
+		t.Fatalf("Screen blocked prompt error = %v, want ErrContentBlocked", err)
+	}
+	if details := domain.ErrorDetails(err); details == nil {
+		t.Error("blocked prompt error has no categories")
+	}
+
+	if queued[0].Action != domain.ModerationActionFlag || queued[0].EntityID == nil || *queued[0].EntityID != message.EntityID {
+		t.Errorf("flagged message queued as %+v, want a flag of the message", queued[0])
+	}
+	// Blocked content was never saved, so its flag points at nothing
+	if queued[1].Action != domain.ModerationActionBlock || queued[1].EntityID != nil || queued[1].Status != domain.ModerationFlagPending {
+		t.Errorf("blocked prompt queued as %+v, want a pending block without an entity", queued[1])
+	}
+
+	// Templates are not screened at all
+	template := ModerationTarget{Type: domain.ModerationContentTemplate, Content: "buy now", EntityID: uuid.New()}
+	if flagged, err := svc.Screen(context.Background(), template); err != nil || flagged {
+		t.Errorf("Screen with moderation off = %v, %v; want it accepted unscreened", flagged, err)
+	}
+}
+
+func TestResolveModerationFlag(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	flags := mocks.NewMockModerationFlagRepository(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc := NewContentModerationService(flags, nil, nil, NewAuditService(auditRepo, nil, nil))
+	adminID, officeID := uuid.New(), uuid.New()
+	flag := &domain.ModerationFlag{ID: uuid.New(), OfficeID: &officeID, Status: domain.ModerationFlagDismissed}
+
+	if _, err := svc.ResolveFlag(context.Background(), adminID, flag.ID, domain.ModerationFlagPending, ""); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("ResolveFlag back to pending error = %v, want ErrInvalidInput", err)
+	}
+
+	flags.EXPECT().Resolve(gomock.Any(), flag.ID, domain.ModerationFlagDismissed, adminID, "false positive").Return(nil)
+	flags.EXPECT().GetByID(gomock.Any(), flag.ID).Return(flag, nil)
+	auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, entry *domain.AuditEntry) error {
+		if entry.Action != domain.AuditActionAdminResolveFlag || *entry.ActorID != adminID || *entry.OfficeID != officeID {
+			t.Errorf("audit entry = %s by %v in %v, want the admin resolving the office's flag", entry.Action, entry.ActorID, entry.OfficeID)
+		}
+		return nil
+	})
+	resolved, err := svc.ResolveFlag(context.Background(), adminID, flag.ID, domain.ModerationFlagDismissed, " false positive ")
+	if err != nil || resolved != flag {
+		t.Fatalf("ResolveFlag = %+v, %v; want the dismissed flag", resolved, err)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// CostPolicy decides what happens to a task whose estimated cost exceeds the
+// office's remaining budget
+type CostPolicy string
+
+const (
+	// CostPolicyOff dispatches tasks without estimating them
+	CostPolicyOff CostPolicy = "off"
+	// CostPolicyWarn dispatches the task and warns the office
+	CostPolicyWarn CostPolicy = "warn"
+	// CostPolicyBlock warns the office and does not dispatch the task
+	CostPolicyBlock CostPolicy = "block"
+)
+
+// costEstimateTimeout bounds each estimate by the orchestrator, which holds
+// up the task it is made for
+const costEstimateTimeout = 10 * time.Second
+
+// CostEstimateService estimates what a task will cost before it is
+// dispatched. The orchestrator picks the model the same way it would for the
+// task and prices the task's context on it.
+type CostEstimateService struct {
+	agentRepo        domain.AgentRepository
+	conversationRepo domain.ConversationRepository
+	creditService    *CreditService
+	contextBuilder   *TaskContextBuilder
+	events           domain.EventPublisher
+	orchestratorURL  string
+	policy           CostPolicy
+	httpClient       *http.Client
+}
+
+// NewCostEstimateService creates a new CostEstimateService applying policy
+// to the tasks it checks
+func NewCostEstimateService(
+	agentRepo domain.AgentRepository,
+	conversationRepo domain.ConversationRepository,
+	creditService *CreditService,
+	contextBuilder *TaskContextBuilder,
+	events domain.EventPublisher,
+	orchestratorURL string,
+	policy CostPolicy,
+) *CostEstimateService {
+	return &CostEstimateService{
+		agentRepo:        agentRepo,
+		conversationRepo: conversationRepo,
+		creditService:    creditService,
+		contextBuilder:   contextBuilder,
+		events:           events,
+		orchestratorURL:  orchestratorURL,
+		policy:           policy,
+		httpClient: &http.Client{
+			Timeout: costEstimateTimeout,
+		},
+	}
+}
+
+// CostEstimateInput describes the task to estimate. ConversationID is
+// optional; without it the estimate leaves out conversation history.
+// MessageID, if set, is the message the task answers.
+type CostEstimateInput struct {
+	OfficeID       uuid.UUID
+	AgentID        uuid.UUID
+	ConversationID uuid.UUID
+	MessageID      uuid.UUID
+	Input          string
+}
+
+// orchestratorEstimate is the orchestrator's estimate of a task's cost
+type orchestratorEstimate struct {
+	Model                 string `json:"model"`
+	Provider              string `json:"provider"`
+	EstimatedCredits      int64  `json:"estimated_credits"`
+	EstimatedInputTokens  int    `json:"estimated_input_tokens"`
+	EstimatedOutputTokens int    `json:"estimated_output_tokens"`
+}
+
+// Estimate returns the estimated cost of the office's agent answering the
+// input, and whether it exceeds the office's remaining budget
+func (s *CostEstimateService) Estimate(ctx context.Context, input CostEstimateInput) (*domain.CostEstimate, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID); err != nil {
+		return nil, err
+	}
+	if input.ConversationID != uuid.Nil {
+		if _, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The estimate is priced on the context the task would be sent with
+	task := &domain.Task{
+		ID:             uuid.New(),
+		OfficeID:       input.OfficeID,
+		ConversationID: input.ConversationID,
+		MessageID:      input.MessageID,
+		AgentID:        input.AgentID,
+		Input:          input.Input,
+	}
+	request := OrchestratorRequest{
+		TaskID:         task.ID.String(),
+		AgentID:        task.AgentID.String(),
+		OfficeID:       task.OfficeID.String(),
+		ConversationID: task.ConversationID.String(),
+		Input:          task.Input,
+	}
+	if err := s.contextBuilder.Build(ctx, task, &request); err != nil {
+		return nil, fmt.Errorf("failed to load task context: %w", err)
+	}
+
+	estimate, err := s.requestEstimate(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.creditService.RemainingBudget(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CostEstimate{
+		AgentID:          input.AgentID,
+		Model:            estimate.Model,
+		Provider:         estimate.Provider,
+		EstimatedCredits: estimate.EstimatedCredits,
+		InputTokens:      estimate.EstimatedInputTokens,
+		OutputTokens:     estimate.EstimatedOutputTokens,
+		RemainingBudget:  remaining,
+		ExceedsBudget:    estimate.EstimatedCredits > remaining,
+	}, nil
+}
+
+// requestEstimate asks the orchestrator to price a task
+func (s *CostEstimateService) requestEstimate(ctx context.Context, request OrchestratorRequest) (*orchestratorEstimate, error) {
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.orchestratorURL+"/estimate", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach orchestrator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("orchestrator estimate returned status %d", resp.StatusCode)
+	}
+
+	var estimate orchestratorEstimate
+	if err := json.NewDecoder(resp.Body).Decode(&estimate); err != nil {
+		return nil, fmt.Errorf("failed to decode orchestrator estimate: %w", err)
+	}
+	return &estimate, nil
+}
+
+// Allow reports whether a task may be dispatched under the cost policy. When
+// its estimate exceeds the office's remaining budget the office is sent a
+// cost_warning event, and the task is held back if the policy blocks. Tasks
+// that cannot be estimated are allowed; the orchestrator still refuses work
+// the office cannot pay for.
+func (s *CostEstimateService) Allow(ctx context.Context, input CostEstimateInput) bool {
+	if s.policy == CostPolicyOff {
+		return true
+	}
+
+	estimate, err := s.Estimate(ctx, input)
+	if err != nil {
+		log.Printf("Failed to estimate cost of task for agent %s: %v", input.AgentID, err)
+		return true
+	}
+	if !estimate.ExceedsBudget {
+		return true
+	}
+
+	blocked := s.policy == CostPolicyBlock
+	payload := map[string]any{
+		"conversation_id":   input.ConversationID,
+		"message_id":        input.MessageID,
+		"agent_id":          input.AgentID,
+		"model":             estimate.Model,
+		"estimated_credits": estimate.EstimatedCredits,
+		"remaining_budget":  estimate.RemainingBudget,
+		"blocked":           blocked,
+	}
+	if err := s.events.Publish(ctx, domain.NewEvent(input.OfficeID, domain.EventCostWarning, payload)); err != nil {
+		log.Printf("Failed to publish cost warning: %v", err)
+	}
+	return !blocked
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
@@ -10,15 +12,27 @@ import (
 
 // CreditService handles credit-related business logic
 type CreditService struct {
-	creditRepo domain.CreditRepository
-	officeRepo domain.OfficeRepository
+	creditRepo          domain.CreditRepository
+	officeRepo          domain.OfficeRepository
+	idempotencyRepo     domain.IdempotencyRepository
+	txManager           domain.TxManager
+	notificationService *NotificationService
 }
 
 // NewCreditService creates a new CreditService instance
-func NewCreditService(creditRepo domain.CreditRepository, officeRepo domain.OfficeRepository) *CreditService {
+func NewCreditService(
+	creditRepo domain.CreditRepository,
+	officeRepo domain.OfficeRepository,
+	idempotencyRepo domain.IdempotencyRepository,
+	txManager domain.TxManager,
+	notificationService *NotificationService,
+) *CreditService {
 	return &CreditService{
-		creditRepo: creditRepo,
-		officeRepo: officeRepo,
+		creditRepo:          creditRepo,
+		officeRepo:          officeRepo,
+		idempotencyRepo:     idempotencyRepo,
+		txManager:           txManager,
+		notificationService: notificationService,
 	}
 }
 
@@ -49,44 +63,267 @@ func (s *CreditService) EnsureWallet(ctx context.Context, officeID uuid.UUID) (*
 	return nil, err
 }
 
-// AddCredits adds credits to an office's wallet
+// AddCredits tops up an office's wallet. A non-empty idempotencyKey (such as
+// a payment ID) makes retries return the original transaction instead of
+// adding the credits again.
 func (s *CreditService) AddCredits(
 	ctx context.Context,
 	officeID uuid.UUID,
 	amount int64,
 	txType domain.TransactionType,
 	description string,
+	idempotencyKey string,
 ) (*domain.CreditTransaction, error) {
+	key, err := newIdempotencyKey(officeID, domain.IdempotencyScopeCreditTopUp, idempotencyKey, amount, txType)
+	if err != nil {
+		return nil, err
+	}
 	wallet, err := s.EnsureWallet(ctx, officeID)
 	if err != nil {
 		return nil, err
 	}
-	return s.creditRepo.AddCredits(ctx, wallet.ID, amount, txType, description, "", nil)
+
+	return s.transactOnce(ctx, key, func(ctx context.Context) (*domain.CreditTransaction, error) {
+		return s.creditRepo.AddCredits(ctx, wallet.ID, amount, txType, description, "", nil)
+	})
 }
 
-// ConsumeCreditsForTask deducts credits from an office's wallet for task execution
+// ConsumeCreditsForTask deducts credits from an office's wallet for task
+// execution. A non-empty idempotencyKey makes retries of the same request
+// return the original transaction instead of deducting the credits twice.
 func (s *CreditService) ConsumeCreditsForTask(
 	ctx context.Context,
 	officeID uuid.UUID,
 	taskID uuid.UUID,
 	credits int64,
 	description string,
+	idempotencyKey string,
+) (*domain.CreditTransaction, error) {
+	key, err := newIdempotencyKey(officeID, domain.IdempotencyScopeCreditConsume, idempotencyKey, taskID, credits)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transactOnce(ctx, key, func(ctx context.Context) (*domain.CreditTransaction, error) {
+		return s.consumeCreditsForTask(ctx, officeID, taskID, credits, description)
+	})
+}
+
+// consumeCreditsForTask deducts the credits. The balance is checked in the
+// same database transaction as the debit, so concurrent tasks cannot
+// overdraw the wallet.
+func (s *CreditService) consumeCreditsForTask(
+	ctx context.Context,
+	officeID uuid.UUID,
+	taskID uuid.UUID,
+	credits int64,
+	description string,
 ) (*domain.CreditTransaction, error) {
 	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
 
-	// Check if sufficient balance
-	hasSufficient, currentBalance, err := s.creditRepo.HasSufficientBalance(ctx, wallet.ID, credits)
+	return s.creditRepo.ConsumeCredits(ctx, wallet.ID, credits, taskID, description)
+}
+
+// transactOnce runs a wallet transaction at most once per idempotency key,
+// loading the original transaction on replays
+func (s *CreditService) transactOnce(
+	ctx context.Context,
+	key *domain.IdempotencyKey,
+	op func(ctx context.Context) (*domain.CreditTransaction, error),
+) (*domain.CreditTransaction, error) {
+	var tx *domain.CreditTransaction
+	txID, replayed, err := runIdempotent(ctx, s.txManager, s.idempotencyRepo, key, func(ctx context.Context) (uuid.UUID, error) {
+		var err error
+		if tx, err = op(ctx); err != nil {
+			return uuid.Nil, err
+		}
+		return tx.ID, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to check balance: %w", err)
+		return nil, err
 	}
-	if !hasSufficient {
-		return nil, fmt.Errorf("insufficient credits: has %d, needs %d", currentBalance, credits)
+	if replayed {
+		return s.creditRepo.GetTransactionByID(ctx, txID)
 	}
+	return tx, nil
+}
 
-	return s.creditRepo.ConsumeCredits(ctx, wallet.ID, credits, taskID, description)
+// RefundTaskCredits returns any credits still charged for a task to the
+// office's wallet, returning the amount refunded. Repeated calls refund nothing.
+func (s *CreditService) RefundTaskCredits(ctx context.Context, officeID uuid.UUID, taskID uuid.UUID) (int64, error) {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	net, err := s.creditRepo.GetTaskNetCredits(ctx, wallet.ID, taskID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task credits: %w", err)
+	}
+	if net >= 0 {
+		return 0, nil
+	}
+
+	if _, err := s.creditRepo.AddCredits(ctx, wallet.ID, -net, domain.TransactionTypeRefund,
+		"Refund for cancelled task", "task", &taskID); err != nil {
+		return 0, fmt.Errorf("failed to refund credits: %w", err)
+	}
+	return -net, nil
+}
+
+// CheckBudgetAlerts records a budget_alert notification for every hourly or daily
+// limit whose alert threshold was crossed by the most recent consumption of
+// consumedCredits. Limits that were already past the threshold before this
+// consumption are not alerted again.
+func (s *CreditService) CheckBudgetAlerts(
+	ctx context.Context,
+	officeID uuid.UUID,
+	consumedCredits int64,
+) ([]*domain.Notification, error) {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	if wallet.HourlyLimit == nil && wallet.DailyLimit == nil {
+		return nil, nil
+	}
+
+	var notifications []*domain.Notification
+	for _, l := range budgetLimits(wallet, time.Now()) {
+		used, err := s.creditRepo.GetConsumedSince(ctx, wallet.ID, l.since)
+		if err != nil {
+			return notifications, fmt.Errorf("failed to get %s usage: %w", l.period, err)
+		}
+
+		// Threshold is expressed as "% remaining", so alert once usage reaches the complement
+		alertAt := *l.limit * int64(100-wallet.BudgetAlertThreshold) / 100
+		if used < alertAt || used-consumedCredits >= alertAt {
+			continue
+		}
+
+		alert := domain.BudgetAlert{
+			Period:      l.period,
+			Limit:       *l.limit,
+			Used:        used,
+			Remaining:   max(*l.limit-used, 0),
+			PercentUsed: float64(used) * 100 / float64(*l.limit),
+			Threshold:   wallet.BudgetAlertThreshold,
+		}
+
+		notification, err := s.notificationService.Notify(
+			ctx,
+			officeID,
+			domain.NotificationTypeBudgetAlert,
+			fmt.Sprintf("Budget alert: %.0f%% of %s limit used", alert.PercentUsed, alert.Period),
+			fmt.Sprintf("Your office has used %d of its %d %s credit limit (%d remaining).", alert.Used, alert.Limit, alert.Period, alert.Remaining),
+			map[string]any{
+				"period":       alert.Period,
+				"limit":        alert.Limit,
+				"used":         alert.Used,
+				"remaining":    alert.Remaining,
+				"percent_used": alert.PercentUsed,
+				"threshold":    alert.Threshold,
+			},
+		)
+		if err != nil {
+			return notifications, fmt.Errorf("failed to store budget alert: %w", err)
+		}
+		if notification != nil {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications, nil
+}
+
+// RemainingBudget returns how many credits the office can still spend: its
+// balance, or less if its hourly or daily limit leaves less
+func (s *CreditService) RemainingBudget(ctx context.Context, officeID uuid.UUID) (int64, error) {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	remaining := wallet.Balance
+	for _, l := range budgetLimits(wallet, time.Now()) {
+		used, err := s.creditRepo.GetConsumedSince(ctx, wallet.ID, l.since)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get %s usage: %w", l.period, err)
+		}
+		remaining = min(remaining, max(*l.limit-used, 0))
+	}
+	return remaining, nil
+}
+
+// budgetLimit is a wallet's spending limit for the period starting at since
+type budgetLimit struct {
+	period domain.BudgetPeriod
+	limit  *int64
+	since  time.Time
+}
+
+// budgetLimits returns the hourly and daily limits the wallet sets, for the
+// periods containing now
+func budgetLimits(wallet *domain.CreditWallet, now time.Time) []budgetLimit {
+	limits := []budgetLimit{
+		{domain.BudgetPeriodHourly, wallet.HourlyLimit, now.Truncate(time.Hour)},
+		{domain.BudgetPeriodDaily, wallet.DailyLimit, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())},
+	}
+	set := limits[:0]
+	for _, l := range limits {
+		if l.limit != nil && *l.limit > 0 {
+			set = append(set, l)
+		}
+	}
+	return set
+}
+
+// CheckLowBalance sends a low_credits notification if the most recent
+// consumption of consumedCredits took the balance below the wallet's low
+// balance threshold. Offices already below it are not warned again, and a
+// threshold of 0 turns the warning off.
+func (s *CreditService) CheckLowBalance(
+	ctx context.Context,
+	officeID uuid.UUID,
+	balance int64,
+	consumedCredits int64,
+) (*domain.Notification, error) {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	threshold := wallet.LowBalanceThreshold
+	if balance >= threshold || balance+consumedCredits < threshold {
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("Your office has %d credits left. Agents stop working on tasks when credits run out.", balance)
+	if wallet.AutoTopUpEnabled {
+		message = fmt.Sprintf("Your office has %d credits left. Auto top-up will buy the %s credit pack unless today's top-ups are used up.",
+			balance, wallet.AutoTopUpPackage)
+	}
+	notification, err := s.notificationService.Notify(
+		ctx,
+		officeID,
+		domain.NotificationTypeLowCredits,
+		"Your credits are running low",
+		message,
+		map[string]any{
+			"balance":             balance,
+			"threshold":           threshold,
+			"auto_top_up_enabled": wallet.AutoTopUpEnabled,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store low credit warning: %w", err)
+	}
+	return notification, nil
 }
 
 // CheckSufficientCredits checks if an office has enough credits for a task
@@ -102,21 +339,28 @@ func (s *CreditService) CheckSufficientCredits(
 	return s.creditRepo.HasSufficientBalance(ctx, wallet.ID, requiredCredits)
 }
 
-// GetTransactionHistory returns transaction history for an office
+// GetTransactionHistory returns a page of transaction history for an office along with the total count
 func (s *CreditService) GetTransactionHistory(
 	ctx context.Context,
 	officeID uuid.UUID,
-	limit int,
-	offset int,
-) ([]*domain.CreditTransaction, error) {
-	if limit <= 0 {
-		limit = 50
+	page domain.PageRequest,
+) ([]*domain.CreditTransaction, int, error) {
+	if page.Limit <= 0 {
+		page.Limit = 50
 	}
 	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	transactions, err := s.creditRepo.GetTransactions(ctx, wallet.ID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.creditRepo.CountTransactions(ctx, wallet.ID)
+	if err != nil {
+		return nil, 0, err
 	}
-	return s.creditRepo.GetTransactions(ctx, wallet.ID, limit, offset)
+	return transactions, total, nil
 }
 
 // RefundCredits refunds credits for a failed task
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestCreditService(t *testing.T) (*CreditService, *mocks.MockCreditRepository, *mocks.MockIdempotencyRepository) {
+	ctrl := gomock.NewController(t)
+	creditRepo := mocks.NewMockCreditRepository(ctrl)
+	idempotencyRepo := mocks.NewMockIdempotencyRepository(ctrl)
+	svc := NewCreditService(creditRepo, mocks.NewMockOfficeRepository(ctrl), idempotencyRepo, newTestTxManager(ctrl), nil)
+	return svc, creditRepo, idempotencyRepo
+}
+
+func TestConsumeCreditsForTaskDebitsOfficeWallet(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, _ := newTestCreditService(t)
+	officeID, taskID := uuid.New(), uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID, Balance: 100}
+	debit := &domain.CreditTransaction{ID: uuid.New(), WalletID: wallet.ID, Amount: -25, BalanceAfter: 75}
+
+	creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	creditRepo.EXPECT().ConsumeCredits(gomock.Any(), wallet.ID, int64(25), taskID, "Task execution").Return(debit, nil)
+
+	tx, err := svc.ConsumeCreditsForTask(ctx, officeID, taskID, 25, "Task execution", "")
+	if err != nil {
+		t.Fatalf("ConsumeCreditsForTask: %v", err)
+	}
+	if tx != debit {
+		t.Errorf("ConsumeCreditsForTask = %+v, want the wallet's debit", tx)
+	}
+}
+
+func TestConsumeCreditsForTaskInsufficientCredits(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, _ := newTestCreditService(t)
+	officeID := uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID, Balance: 10}
+
+	creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	creditRepo.EXPECT().ConsumeCredits(gomock.Any(), wallet.ID, int64(25), gomock.Any(), gomock.Any()).
+		Return(nil, domain.ErrInsufficientCredits)
+
+	_, err := svc.ConsumeCreditsForTask(ctx, officeID, uuid.New(), 25, "Task execution", "")
+	if !errors.Is(err, domain.ErrInsufficientCredits) {
+		t.Errorf("ConsumeCreditsForTask error = %v, want ErrInsufficientCredits", err)
+	}
+}
+
+func TestConsumeCreditsForTaskWithoutWallet(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, _ := newTestCreditService(t)
+	officeID := uuid.New()
+
+	creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+
+	_, err := svc.ConsumeCreditsForTask(ctx, officeID, uuid.New(), 25, "Task execution", "")
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ConsumeCreditsForTask error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestConsumeCreditsForTaskReplayDoesNotDebitAgain(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, idempotencyRepo := newTestCreditService(t)
+	officeID, taskID := uuid.New(), uuid.New()
+	original := &domain.CreditTransaction{ID: uuid.New(), Amount: -25}
+
+	key, err := newIdempotencyKey(officeID, domain.IdempotencyScopeCreditConsume, "retry-1", taskID, int64(25))
+	if err != nil {
+		t.Fatalf("newIdempotencyKey: %v", err)
+	}
+	key.ResourceID = &original.ID
+
+	// The key was completed by the first request, so only the original
+	// transaction is loaded
+	idempotencyRepo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(key, nil)
+	creditRepo.EXPECT().GetTransactionByID(gomock.Any(), original.ID).Return(original, nil)
+
+	tx, err := svc.ConsumeCreditsForTask(ctx, officeID, taskID, 25, "Task execution", "retry-1")
+	if err != nil {
+		t.Fatalf("ConsumeCreditsForTask: %v", err)
+	}
+	if tx != original {
+		t.Errorf("ConsumeCreditsForTask = %+v, want the original transaction", tx)
+	}
+}
+
+func TestConsumeCreditsForTaskReleasesKeyOnFailure(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, idempotencyRepo := newTestCreditService(t)
+	officeID := uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+
+	idempotencyRepo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(nil, nil)
+	creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	creditRepo.EXPECT().ConsumeCredits(gomock.Any(), wallet.ID, int64(25), gomock.Any(), gomock.Any()).
+		Return(nil, domain.ErrInsufficientCredits)
+	idempotencyRepo.EXPECT().Release(gomock.Any(), gomock.Any()).Return(nil)
+
+	_, err := svc.ConsumeCreditsForTask(ctx, officeID, uuid.New(), 25, "Task execution", "retry-2")
+	if !errors.Is(err, domain.ErrInsufficientCredits) {
+		t.Errorf("ConsumeCreditsForTask error = %v, want ErrInsufficientCredits", err)
+	}
+}
+
+func TestConsumeCreditsForTaskFailsWhenTheKeyCannotComplete(t *testing.T) {
+	ctx := context.Background()
+	svc, creditRepo, idempotencyRepo := newTestCreditService(t)
+	officeID := uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+
+	// The debit and the key's completion share a transaction, so a failed
+	// completion rolls the debit back and frees the key for a retry
+	idempotencyRepo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(nil, nil)
+	creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	creditRepo.EXPECT().ConsumeCredits(gomock.Any(), wallet.ID, int64(25), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ uuid.UUID, _ int64, _ uuid.UUID, _ string) (*domain.CreditTransaction, error) {
+			if !inTx(ctx) {
+				t.Error("ConsumeCredits ran outside the transaction")
+			}
+			return &domain.CreditTransaction{ID: uuid.New()}, nil
+		})
+	completeErr := errors.New("connection lost")
+	idempotencyRepo.EXPECT().Complete(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ *domain.IdempotencyKey, _ uuid.UUID) error {
+			if !inTx(ctx) {
+				t.Error("Complete ran outside the transaction")
+			}
+			return completeErr
+		})
+	idempotencyRepo.EXPECT().Release(gomock.Any(), gomock.Any()).Return(nil)
+
+	_, err := svc.ConsumeCreditsForTask(ctx, officeID, uuid.New(), 25, "Task execution", "retry-3")
+	if !errors.Is(err, completeErr) {
+		t.Errorf("ConsumeCreditsForTask error = %v, want the failed completion", err)
+	}
+}
+
+func TestRefundTaskCredits(t *testing.T) {
+	ctx := context.Background()
+	officeID, taskID := uuid.New(), uuid.New()
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+
+	t.Run("refunds the net charge", func(t *testing.T) {
+		svc, creditRepo, _ := newTestCreditService(t)
+		creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+		creditRepo.EXPECT().GetTaskNetCredits(gomock.Any(), wallet.ID, taskID).Return(int64(-30), nil)
+		creditRepo.EXPECT().AddCredits(gomock.Any(), wallet.ID, int64(30), domain.TransactionTypeRefund,
+			gomock.Any(), "task", &taskID).Return(&domain.CreditTransaction{}, nil)
+
+		refunded, err := svc.RefundTaskCredits(ctx, officeID, taskID)
+		if err != nil {
+			t.Fatalf("RefundTaskCredits: %v", err)
+		}
+		if refunded != 30 {
+			t.Errorf("refunded %d credits, want 30", refunded)
+		}
+	})
+
+	t.Run("refunds nothing twice", func(t *testing.T) {
+		svc, creditRepo, _ := newTestCreditService(t)
+		creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+		creditRepo.EXPECT().GetTaskNetCredits(gomock.Any(), wallet.ID, taskID).Return(int64(0), nil)
+
+		refunded, err := svc.RefundTaskCredits(ctx, officeID, taskID)
+		if err != nil {
+			t.Fatalf("RefundTaskCredits: %v", err)
+		}
+		if refunded != 0 {
+			t.Errorf("refunded %d credits, want 0", refunded)
+		}
+	})
+
+	t.Run("office without a wallet", func(t *testing.T) {
+		svc, creditRepo, _ := newTestCreditService(t)
+		creditRepo.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+
+		refunded, err := svc.RefundTaskCredits(ctx, officeID, taskID)
+		if err != nil || refunded != 0 {
+			t.Errorf("RefundTaskCredits = %d, %v; want 0 and no error", refunded, err)
+		}
+	})
+}
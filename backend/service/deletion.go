@@ -0,0 +1,13 @@
+package service
+
+import "time"
+
+// deletionGracePeriod is how long deleted agents, conversations and offices
+// can be restored. Retention purges deleted agents and conversations later,
+// once they are older than the office's tier keeps data.
+const deletionGracePeriod = 30 * 24 * time.Hour
+
+// restorableSince returns the time resources deleted after can be restored
+func restorableSince() time.Time {
+	return time.Now().Add(-deletionGracePeriod)
+}
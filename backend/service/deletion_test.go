@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// expectGracePeriod fails the test unless deletedAfter is
+// deletionGracePeriod ago
+func expectGracePeriod(t *testing.T, deletedAfter time.Time) {
+	t.Helper()
+	if ago := time.Since(deletedAfter); ago < deletionGracePeriod-time.Minute || ago > deletionGracePeriod+time.Minute {
+		t.Errorf("restores things deleted in the last %v, want %v", ago, deletionGracePeriod)
+	}
+}
+
+func TestRestoreAgentWithinGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	subscriptions, m := newTestSubscriptionService(t)
+	svc := NewAgentService(agents, nil, nil, nil, newTestTxManager(ctrl), subscriptions, NewAuditService(auditRepo, m.offices, nil), nil)
+
+	officeID := uuid.New()
+	agent := newOfficeAgent(officeID, &domain.AgentTemplate{ID: uuid.New(), Name: "Alex"}, "")
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+	locked := agents.EXPECT().LockOffice(gomock.Any(), officeID).Return(nil)
+	agents.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return([]*domain.Agent{}, nil).After(locked)
+	agents.EXPECT().Restore(gomock.Any(), officeID, agent.ID, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _, _ uuid.UUID, deletedAfter time.Time) error {
+			if !inTx(ctx) {
+				t.Error("Restore ran outside the transaction holding the office lock")
+			}
+			expectGracePeriod(t, deletedAfter)
+			return nil
+		})
+	agents.EXPECT().GetByID(gomock.Any(), agent.ID).Return(agent, nil)
+	auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, entry *domain.AuditEntry) error {
+			if entry.Action != domain.AuditActionAgentRestore || entry.EntityID != agent.ID {
+				t.Errorf("audit entry = %s of %s, want the agent's restore", entry.Action, entry.EntityID)
+			}
+			return nil
+		})
+
+	restored, err := svc.RestoreAgent(context.Background(), officeID, agent.ID)
+	if err != nil || restored != agent {
+		t.Fatalf("RestoreAgent = %v, %v; want the agent", restored, err)
+	}
+}
+
+func TestRestoreAgentRespectsAgentLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	subscriptions, m := newTestSubscriptionService(t)
+	svc := NewAgentService(agents, nil, nil, nil, newTestTxManager(ctrl), subscriptions, nil, nil)
+
+	// Solo allows 3 agents; Restore must not be called
+	officeID := uuid.New()
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+	agents.EXPECT().LockOffice(gomock.Any(), officeID).Return(nil)
+	agents.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(make([]*domain.Agent, 3), nil)
+
+	_, err := svc.RestoreAgent(context.Background(), officeID, uuid.New())
+	if !errors.Is(err, domain.ErrTierLimitExceeded) {
+		t.Errorf("RestoreAgent error = %v, want ErrTierLimitExceeded", err)
+	}
+}
+
+func TestDeleteOfficeKeepsCurrentAndLastOffice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	svc := NewOfficeService(offices, nil)
+
+	userID := uuid.New()
+	current := &domain.Office{ID: uuid.New(), UserID: userID, Name: "Main"}
+	other := &domain.Office{ID: uuid.New(), UserID: userID, Name: "Side"}
+	offices.EXPECT().GetByID(gomock.Any(), current.ID).Return(current, nil)
+	offices.EXPECT().GetByID(gomock.Any(), other.ID).Return(other, nil)
+	offices.EXPECT().GetByUserID(gomock.Any(), userID).Return([]*domain.Office{other}, nil)
+
+	if err := svc.DeleteOffice(context.Background(), userID, current.ID, current.ID); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("DeleteOffice of the current office error = %v, want ErrInvalidInput", err)
+	}
+	// The session's office was deleted elsewhere, leaving other as the only one
+	if err := svc.DeleteOffice(context.Background(), userID, current.ID, other.ID); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("DeleteOffice of the only office error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRestoreOffice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc := NewOfficeService(offices, NewAuditService(auditRepo, offices, nil))
+
+	userID := uuid.New()
+	deletedAt := time.Now().Add(-time.Hour)
+	deleted := &domain.Office{ID: uuid.New(), UserID: userID, Name: "Side", DeletedAt: &deletedAt}
+	offices.EXPECT().GetByID(gomock.Any(), deleted.ID).Return(deleted, nil).Times(2)
+
+	// Someone else's office is not found
+	if _, err := svc.RestoreOffice(context.Background(), uuid.New(), deleted.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("RestoreOffice of another user's office error = %v, want ErrNotFound", err)
+	}
+
+	offices.EXPECT().Restore(gomock.Any(), deleted.ID, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, deletedAfter time.Time) error {
+			expectGracePeriod(t, deletedAfter)
+			return nil
+		})
+	auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+	office, err := svc.RestoreOffice(context.Background(), userID, deleted.ID)
+	if err != nil || office.DeletedAt != nil {
+		t.Fatalf("RestoreOffice = %+v, %v; want the office no longer deleted", office, err)
+	}
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDepartmentNameLength        = 100
+	maxDepartmentDescriptionLength = 2000
+)
+
+// DepartmentService organizes an office's agents into departments and
+// reporting lines. Each department has a group conversation with its
+// members, where the lead answers messages that mention no one and
+// delegates to the other members by @mentioning them.
+type DepartmentService struct {
+	departmentRepo   domain.DepartmentRepository
+	agentRepo        domain.AgentRepository
+	conversationRepo domain.ConversationRepository
+	txManager        domain.TxManager
+	chatService      *ChatService
+}
+
+// NewDepartmentService creates a new DepartmentService instance
+func NewDepartmentService(
+	departmentRepo domain.DepartmentRepository,
+	agentRepo domain.AgentRepository,
+	conversationRepo domain.ConversationRepository,
+	txManager domain.TxManager,
+	chatService *ChatService,
+) *DepartmentService {
+	return &DepartmentService{
+		departmentRepo:   departmentRepo,
+		agentRepo:        agentRepo,
+		conversationRepo: conversationRepo,
+		txManager:        txManager,
+		chatService:      chatService,
+	}
+}
+
+// GetOrgChart returns the office's departments and reporting lines
+func (s *DepartmentService) GetOrgChart(ctx context.Context, officeID uuid.UUID) (*domain.OrgChart, error) {
+	departments, err := s.departmentRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.departmentRepo.GetReportingLines(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if departments == nil {
+		departments = []*domain.Department{}
+	}
+	if lines == nil {
+		lines = []*domain.ReportingLine{}
+	}
+	return &domain.OrgChart{Departments: departments, ReportingLines: lines}, nil
+}
+
+// GetDepartments returns the office's departments by name
+func (s *DepartmentService) GetDepartments(ctx context.Context, officeID uuid.UUID) ([]*domain.Department, error) {
+	return s.departmentRepo.GetByOfficeID(ctx, officeID)
+}
+
+// GetDepartment returns a department of the office
+func (s *DepartmentService) GetDepartment(ctx context.Context, officeID, departmentID uuid.UUID) (*domain.Department, error) {
+	department, err := s.departmentRepo.GetByID(ctx, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(department.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return department, nil
+}
+
+// CreateDepartmentInput contains input for creating a department. The lead
+// is a member whether or not MemberIDs lists it.
+type CreateDepartmentInput struct {
+	OfficeID    uuid.UUID
+	Name        string
+	Description string
+	LeadAgentID uuid.UUID
+	MemberIDs   []uuid.UUID
+}
+
+// CreateDepartment creates a department of active agents of the office with
+// its group conversation. Agents already in another department return
+// ErrAlreadyExists.
+func (s *DepartmentService) CreateDepartment(ctx context.Context, input CreateDepartmentInput) (*domain.Department, error) {
+	name, err := departmentName(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	description, err := departmentDescription(input.Description)
+	if err != nil {
+		return nil, err
+	}
+	if input.LeadAgentID == uuid.Nil {
+		return nil, fmt.Errorf("%w: lead_agent_id is required", domain.ErrInvalidInput)
+	}
+
+	// The lead joins first, then the members in the order given
+	memberIDs := []uuid.UUID{input.LeadAgentID}
+	for _, agentID := range input.MemberIDs {
+		if !slices.Contains(memberIDs, agentID) {
+			memberIDs = append(memberIDs, agentID)
+		}
+	}
+	for _, agentID := range memberIDs {
+		if _, err := s.activeAgent(ctx, input.OfficeID, agentID); err != nil {
+			return nil, err
+		}
+	}
+
+	leadID := input.LeadAgentID
+	department := &domain.Department{
+		ID:          uuid.New(),
+		OfficeID:    input.OfficeID,
+		Name:        name,
+		Description: description,
+		LeadAgentID: &leadID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		conversation, err := s.chatService.CreateConversation(ctx, CreateConversationInput{
+			OfficeID: input.OfficeID,
+			Type:     domain.ConversationTypeGroup,
+			Name:     name,
+			AgentIDs: memberIDs,
+		})
+		if err != nil {
+			return err
+		}
+		department.ConversationID = &conversation.ID
+
+		if err := s.departmentRepo.Create(ctx, department); err != nil {
+			if errors.Is(err, domain.ErrAlreadyExists) {
+				return fmt.Errorf("%w: the office already has a department named %q", domain.ErrAlreadyExists, name)
+			}
+			return err
+		}
+		for _, agentID := range memberIDs {
+			if err := s.addMember(ctx, department.ID, agentID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.departmentRepo.GetByID(ctx, department.ID)
+}
+
+// UpdateDepartmentInput contains changes to a department. Nil fields are
+// left as is.
+type UpdateDepartmentInput struct {
+	OfficeID     uuid.UUID
+	DepartmentID uuid.UUID
+	Name         *string
+	Description  *string
+	LeadAgentID  *uuid.UUID
+}
+
+// UpdateDepartment renames a department, along with its conversation,
+// changes its description, or hands the lead to another member
+func (s *DepartmentService) UpdateDepartment(ctx context.Context, input UpdateDepartmentInput) (*domain.Department, error) {
+	department, err := s.GetDepartment(ctx, input.OfficeID, input.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := false
+	if input.Name != nil {
+		name, err := departmentName(*input.Name)
+		if err != nil {
+			return nil, err
+		}
+		renamed = name != department.Name
+		department.Name = name
+	}
+	if input.Description != nil {
+		description, err := departmentDescription(*input.Description)
+		if err != nil {
+			return nil, err
+		}
+		department.Description = description
+	}
+	if input.LeadAgentID != nil {
+		if !slices.Contains(department.MemberIDs, *input.LeadAgentID) {
+			return nil, fmt.Errorf("%w: the lead must be a member of the department", domain.ErrInvalidInput)
+		}
+		if _, err := s.activeAgent(ctx, input.OfficeID, *input.LeadAgentID); err != nil {
+			return nil, err
+		}
+		department.LeadAgentID = input.LeadAgentID
+	}
+
+	department.UpdatedAt = time.Now()
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.departmentRepo.Update(ctx, department); err != nil {
+			if errors.Is(err, domain.ErrAlreadyExists) {
+				return fmt.Errorf("%w: the office already has a department named %q", domain.ErrAlreadyExists, department.Name)
+			}
+			return err
+		}
+		if !renamed || department.ConversationID == nil {
+			return nil
+		}
+		// The conversation may have been deleted since
+		conversation, err := s.conversationRepo.GetByID(ctx, *department.ConversationID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		conversation.Name = department.Name
+		conversation.UpdatedAt = department.UpdatedAt
+		return s.conversationRepo.Update(ctx, conversation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.departmentRepo.GetByID(ctx, department.ID)
+}
+
+// DeleteDepartment removes a department of the office. Its conversation is
+// kept as a plain group conversation and its members' reporting lines are
+// left as they are.
+func (s *DepartmentService) DeleteDepartment(ctx context.Context, officeID, departmentID uuid.UUID) error {
+	if _, err := s.GetDepartment(ctx, officeID, departmentID); err != nil {
+		return err
+	}
+	return s.departmentRepo.Delete(ctx, departmentID)
+}
+
+// AddMember adds an active agent of the office to a department and its
+// conversation. Agents already in a department return ErrAlreadyExists.
+func (s *DepartmentService) AddMember(ctx context.Context, officeID, departmentID, agentID uuid.UUID) (*domain.Department, error) {
+	department, err := s.GetDepartment(ctx, officeID, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.activeAgent(ctx, officeID, agentID); err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.addMember(ctx, department.ID, agentID); err != nil {
+			return err
+		}
+		if department.ConversationID == nil {
+			return nil
+		}
+		return s.conversationRepo.AddParticipant(ctx, *department.ConversationID, agentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.departmentRepo.GetByID(ctx, department.ID)
+}
+
+// RemoveMember removes an agent from a department and its conversation.
+// The lead cannot be removed until another member leads the department.
+func (s *DepartmentService) RemoveMember(ctx context.Context, officeID, departmentID, agentID uuid.UUID) (*domain.Department, error) {
+	department, err := s.GetDepartment(ctx, officeID, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(department.MemberIDs, agentID) {
+		return nil, fmt.Errorf("%w: agent %s is not a member of the department", domain.ErrInvalidInput, agentID)
+	}
+	if department.LeadAgentID != nil && *department.LeadAgentID == agentID {
+		return nil, fmt.Errorf("%w: make another member the lead before removing this one", domain.ErrInvalidInput)
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.departmentRepo.RemoveMember(ctx, department.ID, agentID); err != nil {
+			return err
+		}
+		if department.ConversationID == nil {
+			return nil
+		}
+		return s.conversationRepo.RemoveParticipant(ctx, *department.ConversationID, agentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.departmentRepo.GetByID(ctx, department.ID)
+}
+
+// SetManager makes one of the office's agents report to another, or to no
+// one when managerID is nil. Reporting lines cannot form a cycle.
+func (s *DepartmentService) SetManager(ctx context.Context, officeID, agentID uuid.UUID, managerID *uuid.UUID) error {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return err
+	}
+	if managerID == nil {
+		return s.departmentRepo.SetManager(ctx, agentID, nil)
+	}
+
+	if *managerID == agentID {
+		return fmt.Errorf("%w: an agent cannot report to itself", domain.ErrInvalidInput)
+	}
+	_, err := officeAgent(ctx, s.agentRepo, officeID, *managerID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: manager %s is not in this office", domain.ErrInvalidInput, *managerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	lines, err := s.departmentRepo.GetReportingLines(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	if reportsTo(lines, *managerID, agentID) {
+		return fmt.Errorf("%w: agent %s already reports to agent %s", domain.ErrInvalidInput, *managerID, agentID)
+	}
+	return s.departmentRepo.SetManager(ctx, agentID, managerID)
+}
+
+// addMember adds an agent to a department, saying which agent is already
+// in one
+func (s *DepartmentService) addMember(ctx context.Context, departmentID, agentID uuid.UUID) error {
+	err := s.departmentRepo.AddMember(ctx, departmentID, agentID)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		return fmt.Errorf("%w: agent %s is already in a department", domain.ErrAlreadyExists, agentID)
+	}
+	return err
+}
+
+// activeAgent loads an active agent of the office, reporting any other as
+// invalid input
+func (s *DepartmentService) activeAgent(ctx context.Context, officeID, agentID uuid.UUID) (*domain.Agent, error) {
+	agent, err := officeAgent(ctx, s.agentRepo, officeID, agentID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !agent.IsActive) {
+		return nil, fmt.Errorf("%w: agent %s is not an active agent of this office", domain.ErrInvalidInput, agentID)
+	}
+	return agent, err
+}
+
+// reportsTo reports whether agentID reports to managerID, directly or
+// through other managers
+func reportsTo(lines []*domain.ReportingLine, agentID, managerID uuid.UUID) bool {
+	managers := make(map[uuid.UUID]uuid.UUID, len(lines))
+	for _, line := range lines {
+		managers[line.AgentID] = line.ManagerID
+	}
+	// Each step moves up one manager; existing lines have no cycles, so the
+	// walk ends within len(lines) steps
+	for range len(lines) {
+		manager, ok := managers[agentID]
+		if !ok {
+			return false
+		}
+		if manager == managerID {
+			return true
+		}
+		agentID = manager
+	}
+	return false
+}
+
+func departmentName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxDepartmentNameLength {
+		return "", fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxDepartmentNameLength)
+	}
+	return name, nil
+}
+
+func departmentDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxDepartmentDescriptionLength {
+		return "", fmt.Errorf("%w: description must be at most %d characters", domain.ErrInvalidInput, maxDepartmentDescriptionLength)
+	}
+	return description, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSetManagerRejectsCycles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	departments := mocks.NewMockDepartmentRepository(ctrl)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	svc := NewDepartmentService(departments, agents, nil, nil, nil)
+	ctx := context.Background()
+
+	officeID := uuid.New()
+	ceo, cto, engineer := uuid.New(), uuid.New(), uuid.New()
+	agents.EXPECT().GetByID(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, id uuid.UUID) (*domain.Agent, error) {
+			return &domain.Agent{ID: id, OfficeID: officeID, IsActive: true}, nil
+		})
+	// The engineer reports to the CTO, who reports to the CEO
+	departments.EXPECT().GetReportingLines(gomock.Any(), officeID).AnyTimes().Return([]*domain.ReportingLine{
+		{AgentID: engineer, ManagerID: cto},
+		{AgentID: cto, ManagerID: ceo},
+	}, nil)
+
+	if err := svc.SetManager(ctx, officeID, ceo, &engineer); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("CEO reporting to the engineer error = %v, want ErrInvalidInput", err)
+	}
+	if err := svc.SetManager(ctx, officeID, ceo, &ceo); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("CEO reporting to itself error = %v, want ErrInvalidInput", err)
+	}
+
+	departments.EXPECT().SetManager(gomock.Any(), engineer, &ceo).Return(nil)
+	if err := svc.SetManager(ctx, officeID, engineer, &ceo); err != nil {
+		t.Errorf("engineer reporting to the CEO: %v", err)
+	}
+}
+
+func TestRemoveMemberKeepsTheLead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	departments := mocks.NewMockDepartmentRepository(ctrl)
+	svc := NewDepartmentService(departments, nil, nil, nil, nil)
+
+	officeID, lead := uuid.New(), uuid.New()
+	department := &domain.Department{ID: uuid.New(), OfficeID: officeID, LeadAgentID: &lead, MemberIDs: []uuid.UUID{lead, uuid.New()}}
+	departments.EXPECT().GetByID(gomock.Any(), department.ID).Return(department, nil)
+
+	_, err := svc.RemoveMember(context.Background(), officeID, department.ID, lead)
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("RemoveMember of the lead error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// maxDocumentBytes bounds the content of one document version
+const maxDocumentBytes = 1 << 20
+
+// DocumentService handles the deliverables agents produce
+type DocumentService struct {
+	documentRepo domain.DocumentRepository
+	taskRepo     domain.TaskRepository
+	events       domain.EventPublisher
+}
+
+// NewDocumentService creates a new DocumentService instance
+func NewDocumentService(
+	documentRepo domain.DocumentRepository,
+	taskRepo domain.TaskRepository,
+	events domain.EventPublisher,
+) *DocumentService {
+	return &DocumentService{
+		documentRepo: documentRepo,
+		taskRepo:     taskRepo,
+		events:       events,
+	}
+}
+
+// SaveDocumentInput contains a document an agent saved while running a task.
+// A nil DocumentID creates a document; otherwise a new version of it is
+// saved. An empty Format is Markdown.
+type SaveDocumentInput struct {
+	TaskID     uuid.UUID
+	DocumentID *uuid.UUID
+	Title      string
+	Format     domain.DocumentFormat
+	Content    string
+}
+
+// SaveDocument stores a deliverable of a task's agent in the task's office
+// and conversation. New versions keep the document's format.
+func (s *DocumentService) SaveDocument(ctx context.Context, input SaveDocumentInput) (*domain.Document, error) {
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return nil, fmt.Errorf("%w: a document needs a title", domain.ErrInvalidInput)
+	}
+	if len(input.Content) > maxDocumentBytes {
+		return nil, fmt.Errorf("%w: documents are at most %d bytes", domain.ErrInvalidInput, maxDocumentBytes)
+	}
+	format := input.Format
+	if format == "" {
+		format = domain.DocumentFormatMarkdown
+	}
+	if !format.IsValid() {
+		return nil, fmt.Errorf("%w: unknown document format %q", domain.ErrInvalidInput, format)
+	}
+
+	task, err := s.taskRepo.GetByID(ctx, input.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	version := &domain.DocumentVersion{
+		ID:        uuid.New(),
+		Version:   1,
+		TaskID:    &task.ID,
+		Title:     title,
+		Content:   input.Content,
+		CreatedAt: now,
+	}
+
+	var document *domain.Document
+	if input.DocumentID == nil {
+		document = &domain.Document{
+			ID:             uuid.New(),
+			OfficeID:       task.OfficeID,
+			ConversationID: &task.ConversationID,
+			AgentID:        &task.AgentID,
+			Title:          title,
+			Format:         format,
+			Content:        input.Content,
+			Version:        1,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		version.DocumentID = document.ID
+		if err := s.documentRepo.Create(ctx, document, version); err != nil {
+			return nil, err
+		}
+	} else {
+		if document, err = s.GetDocument(ctx, task.OfficeID, *input.DocumentID); err != nil {
+			return nil, err
+		}
+		if input.Format != "" && format != document.Format {
+			return nil, fmt.Errorf("%w: the document is %s, not %s", domain.ErrInvalidInput, document.Format, format)
+		}
+		version.DocumentID = document.ID
+		if err := s.documentRepo.AddVersion(ctx, document, version); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.events.Publish(ctx, domain.NewEvent(document.OfficeID, domain.EventDocumentSaved, map[string]any{
+		"document_id":     document.ID.String(),
+		"conversation_id": task.ConversationID.String(),
+		"agent_id":        task.AgentID.String(),
+		"task_id":         task.ID.String(),
+		"title":           document.Title,
+		"format":          document.Format,
+		"version":         document.Version,
+	}))
+	if err != nil {
+		log.Printf("Failed to publish document %s for office %s: %v", document.ID, document.OfficeID, err)
+	}
+	return document, nil
+}
+
+// ListDocuments returns a page of an office's documents, without their
+// content, newest first
+func (s *DocumentService) ListDocuments(ctx context.Context, filter domain.DocumentFilter, page domain.PageRequest) ([]*domain.Document, int, error) {
+	if page.Limit <= 0 {
+		page.Limit = 20
+	}
+
+	documents, err := s.documentRepo.List(ctx, filter, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.documentRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return documents, total, nil
+}
+
+// GetDocument returns a document of the office with its latest content
+func (s *DocumentService) GetDocument(ctx context.Context, officeID, documentID uuid.UUID) (*domain.Document, error) {
+	document, err := s.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(document.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// GetDocumentVersions returns the version history of a document of the
+// office, newest first, without the versions' content
+func (s *DocumentService) GetDocumentVersions(ctx context.Context, officeID, documentID uuid.UUID) ([]*domain.DocumentVersion, error) {
+	if _, err := s.GetDocument(ctx, officeID, documentID); err != nil {
+		return nil, err
+	}
+	return s.documentRepo.GetVersions(ctx, documentID)
+}
+
+// GetDocumentVersion returns a document of the office as it was at a
+// version, with that version's title and content
+func (s *DocumentService) GetDocumentVersion(ctx context.Context, officeID, documentID uuid.UUID, version int) (*domain.Document, error) {
+	document, err := s.GetDocument(ctx, officeID, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if version == document.Version {
+		return document, nil
+	}
+
+	v, err := s.documentRepo.GetVersion(ctx, documentID, version)
+	if err != nil {
+		return nil, err
+	}
+	document.Title = v.Title
+	document.Content = v.Content
+	document.Version = v.Version
+	document.UpdatedAt = v.CreatedAt
+	return document, nil
+}
@@ -3,26 +3,69 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
-	"github.com/denys89/syn-office/backend/repository"
 	"github.com/google/uuid"
 )
 
 // EarningsService handles marketplace earnings business logic
 type EarningsService struct {
-	earningsRepo    *repository.EarningsRepository
-	marketplaceRepo *repository.MarketplaceRepository
+	earningsRepo    domain.EarningsRepository
+	marketplaceRepo domain.MarketplaceRepository
+	purchaseRepo    domain.TemplatePurchaseRepository
+	bundleRepo      domain.TemplateBundleRepository
+	refundRepo      domain.RefundRequestRepository
+	creditRepo      domain.CreditRepository
+	idempotencyRepo domain.IdempotencyRepository
+	txManager       domain.TxManager
+	// billing checks and refunds purchases paid through Stripe; nil when
+	// Stripe is not configured
+	billing       domain.BillingProvider
+	notifications *NotificationService
+	audit         *AuditService
+	// creditsPerDollar prices templates bought with credits; 0 only allows
+	// cards
+	creditsPerDollar int64
+	// subscriptionService tells the seats of offices buying templates under
+	// a personal license
+	subscriptionService *SubscriptionService
 }
 
 // NewEarningsService creates a new earnings service
 func NewEarningsService(
-	earningsRepo *repository.EarningsRepository,
-	marketplaceRepo *repository.MarketplaceRepository,
+	earningsRepo domain.EarningsRepository,
+	marketplaceRepo domain.MarketplaceRepository,
+	purchaseRepo domain.TemplatePurchaseRepository,
+	bundleRepo domain.TemplateBundleRepository,
+	refundRepo domain.RefundRequestRepository,
+	creditRepo domain.CreditRepository,
+	idempotencyRepo domain.IdempotencyRepository,
+	txManager domain.TxManager,
+	billing domain.BillingProvider,
+	notifications *NotificationService,
+	audit *AuditService,
+	creditsPerDollar int64,
+	subscriptionService *SubscriptionService,
 ) *EarningsService {
 	return &EarningsService{
-		earningsRepo:    earningsRepo,
-		marketplaceRepo: marketplaceRepo,
+		earningsRepo:     earningsRepo,
+		marketplaceRepo:  marketplaceRepo,
+		purchaseRepo:     purchaseRepo,
+		bundleRepo:       bundleRepo,
+		refundRepo:       refundRepo,
+		creditRepo:       creditRepo,
+		idempotencyRepo:  idempotencyRepo,
+		txManager:        txManager,
+		billing:          billing,
+		notifications:    notifications,
+		audit:            audit,
+		creditsPerDollar: creditsPerDollar,
+
+		subscriptionService: subscriptionService,
 	}
 }
 
@@ -34,60 +77,232 @@ const (
 	MinPayoutCents         = 1000 // $10.00
 )
 
-// PurchaseTemplate processes a marketplace template purchase
+// PurchaseTemplate processes a marketplace template purchase and returns the
+// author earning it recorded. A non-empty idempotencyKey makes retries of the
+// same purchase return the original earning instead of failing as a
+// duplicate.
 func (s *EarningsService) PurchaseTemplate(
 	ctx context.Context,
 	templateID uuid.UUID,
 	purchaserID uuid.UUID,
 	purchaserOfficeID uuid.UUID,
 	stripePaymentIntentID string,
+	idempotencyKey string,
+) (uuid.UUID, error) {
+	key, err := newIdempotencyKey(purchaserOfficeID, domain.IdempotencyScopeTemplatePurchase, idempotencyKey,
+		templateID, stripePaymentIntentID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	earningID, _, err := runIdempotent(ctx, s.txManager, s.idempotencyRepo, key, func(ctx context.Context) (uuid.UUID, error) {
+		return s.purchaseTemplate(ctx, templateID, purchaserID, purchaserOfficeID, stripePaymentIntentID)
+	})
+	return earningID, err
+}
+
+// purchaseTemplate records the sale and grants the office the template
+func (s *EarningsService) purchaseTemplate(
+	ctx context.Context,
+	templateID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	stripePaymentIntentID string,
 ) (uuid.UUID, error) {
+	template, err := s.purchasableTemplate(ctx, templateID, purchaserID, purchaserOfficeID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	err = s.verifyPayment(ctx, stripePaymentIntentID, template.PriceCents, map[string]string{
+		"template_id": template.ID.String(),
+		"office_id":   purchaserOfficeID.String(),
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	purchase, err := s.recordPurchase(ctx, template, purchaserID, purchaserOfficeID, stripePaymentIntentID, 0)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return *purchase.EarningID, nil
+}
+
+// purchasableTemplate retrieves a premium template listed in the
+// marketplace that the office does not own yet, whose license covers it and
+// that the purchaser did not write
+func (s *EarningsService) purchasableTemplate(ctx context.Context, templateID, purchaserID, officeID uuid.UUID) (*domain.AgentTemplate, error) {
 	// Get template details
 	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
 	if err != nil {
-		return uuid.Nil, errors.New("template not found")
+		return nil, err
+	}
+	if template.Status != "approved" || !template.IsPublic {
+		return nil, domain.ErrNotFound
 	}
 
 	// Validate author exists
 	if template.AuthorID == nil {
-		return uuid.Nil, errors.New("template has no author")
+		return nil, fmt.Errorf("%w: template has no author", domain.ErrInvalidInput)
+	}
+	if *template.AuthorID == purchaserID {
+		return nil, fmt.Errorf("%w: you cannot buy your own template", domain.ErrInvalidInput)
 	}
 
 	// Validate price
 	if template.PriceCents < MinPriceCents {
-		return uuid.Nil, errors.New("template price below minimum")
-	}
-
-	// Record the sale
-	earningID, err := s.earningsRepo.RecordSale(
-		ctx,
-		*template.AuthorID,
-		templateID,
-		purchaserID,
-		purchaserOfficeID,
-		template.PriceCents,
-		stripePaymentIntentID,
-	)
+		return nil, fmt.Errorf("%w: template price below minimum", domain.ErrInvalidInput)
+	}
+
+	// Prevent paying twice for the same template
+	owned, err := s.purchaseRepo.HasPurchased(ctx, officeID, templateID)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
+	}
+	if owned {
+		return nil, domain.ErrAlreadyExists
+	}
+	if err := requireTemplateLicense(ctx, s.subscriptionService, officeID, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// verifyPayment checks that a card payment went through for priceCents, that
+// it was made for the purchase metadata describes and that no sale was
+// recorded with it yet
+func (s *EarningsService) verifyPayment(ctx context.Context, paymentIntentID string, priceCents int, metadata map[string]string) error {
+	if s.billing == nil {
+		return fmt.Errorf("%w: card payments are not available", domain.ErrInvalidInput)
+	}
+	intent, err := s.billing.GetPaymentIntent(ctx, paymentIntentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: payment %s not found", domain.ErrInvalidInput, paymentIntentID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check payment: %w", err)
+	}
+
+	if intent.Status != domain.PaymentIntentSucceeded {
+		return fmt.Errorf("%w: payment %s is %s", domain.ErrPaymentDeclined, intent.ID, intent.Status)
+	}
+	if intent.AmountCents != int64(priceCents) || !strings.EqualFold(intent.Currency, invoiceCurrency) {
+		return fmt.Errorf("%w: payment %s does not pay the price of %s", domain.ErrInvalidInput, intent.ID, formatCents(priceCents))
+	}
+	for key, value := range metadata {
+		if intent.Metadata[key] != value {
+			return fmt.Errorf("%w: payment %s was not made for this purchase", domain.ErrInvalidInput, intent.ID)
+		}
+	}
+
+	used, err := s.earningsRepo.IsPaymentIntentUsed(ctx, intent.ID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("%w: payment %s has already been used", domain.ErrInvalidInput, intent.ID)
+	}
+	return nil
+}
+
+// recordPurchase records the sale of a template and grants the office
+// access to it together, with the license the office accepts by buying it,
+// taking priceCredits from the office's wallet when it pays with credits. A
+// concurrent purchase that got there first, or a wallet that cannot cover
+// the price, rolls the sale back.
+func (s *EarningsService) recordPurchase(
+	ctx context.Context,
+	template *domain.AgentTemplate,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	stripePaymentIntentID string,
+	priceCredits int64,
+) (*domain.TemplatePurchase, error) {
+	now := time.Now()
+	purchase := &domain.TemplatePurchase{
+		ID:           uuid.New(),
+		OfficeID:     purchaserOfficeID,
+		TemplateID:   template.ID,
+		PurchasedBy:  purchaserID,
+		PriceCents:   template.PriceCents,
+		PriceCredits: priceCredits,
+		Status:       domain.TemplatePurchaseStatusActive,
+		CreatedAt:    now,
+		License:      newLicenseAcceptance(template, now),
+	}
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		earningID, err := s.earningsRepo.RecordSale(
+			ctx,
+			*template.AuthorID,
+			template.ID,
+			purchaserID,
+			purchaserOfficeID,
+			template.PriceCents,
+			stripePaymentIntentID,
+		)
+		if err != nil {
+			return err
+		}
+
+		purchase.EarningID = &earningID
+		if err := s.purchaseRepo.Create(ctx, purchase); err != nil {
+			return err
+		}
+		if priceCredits > 0 {
+			return s.chargeCredits(ctx, template, purchase)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Increment download (purchase) count
-	_ = s.marketplaceRepo.IncrementDownload(ctx, templateID)
+	_ = s.marketplaceRepo.IncrementDownload(ctx, template.ID)
+
+	_, authorEarning := s.CalculateCommission(template.PriceCents)
+	_, err = s.notifications.NotifyUser(ctx, *template.AuthorID, domain.NotificationTypeMarketplaceSale,
+		fmt.Sprintf("%s was purchased", template.Name),
+		fmt.Sprintf("An office bought %s for %s. You earned %s.", template.Name, formatCents(template.PriceCents), formatCents(authorEarning)),
+		map[string]any{
+			"template_id":          template.ID.String(),
+			"earning_id":           purchase.EarningID.String(),
+			"sale_amount_cents":    template.PriceCents,
+			"author_earning_cents": authorEarning,
+		},
+	)
+	if err != nil {
+		// The sale is recorded; the author still sees it in their earnings
+		log.Printf("Failed to notify author of template %s sale: %v", template.ID, err)
+	}
 
-	return earningID, nil
+	return purchase, nil
 }
 
-// GetAuthorEarnings retrieves earnings for an author
+// GetOfficePurchases returns the premium templates an office owns
+func (s *EarningsService) GetOfficePurchases(ctx context.Context, officeID uuid.UUID) ([]*domain.TemplatePurchase, error) {
+	return s.purchaseRepo.GetByOfficeID(ctx, officeID)
+}
+
+// GetAuthorEarnings retrieves a page of earnings for an author along with the total count
 func (s *EarningsService) GetAuthorEarnings(
 	ctx context.Context,
 	authorID uuid.UUID,
 	limit, offset int,
-) ([]domain.AuthorEarning, error) {
+) ([]domain.AuthorEarning, int, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	return s.earningsRepo.GetAuthorEarnings(ctx, authorID, limit, offset)
+	earnings, err := s.earningsRepo.GetAuthorEarnings(ctx, authorID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.earningsRepo.CountAuthorEarnings(ctx, authorID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return earnings, total, nil
 }
 
 // GetAuthorBalance retrieves the author's balance
@@ -114,7 +329,7 @@ func (s *EarningsService) RequestPayout(
 ) (uuid.UUID, error) {
 	// Validate minimum payout
 	if amountCents < MinPayoutCents {
-		return uuid.Nil, errors.New("minimum payout is $10.00")
+		return uuid.Nil, fmt.Errorf("%w: minimum payout is $10.00", domain.ErrInvalidInput)
 	}
 
 	// Check available balance
@@ -124,11 +339,28 @@ func (s *EarningsService) RequestPayout(
 	}
 
 	if balance.AvailableBalanceCents < int64(amountCents) {
-		return uuid.Nil, errors.New("insufficient balance for payout")
+		return uuid.Nil, domain.WithDetails(
+			fmt.Errorf("%w: insufficient balance for payout", domain.ErrInvalidInput),
+			map[string]any{"available_balance_cents": balance.AvailableBalanceCents},
+		)
 	}
 
 	// Create payout request
-	return s.earningsRepo.RequestPayout(ctx, authorID, amountCents)
+	payoutID, err := s.earningsRepo.RequestPayout(ctx, authorID, amountCents)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionPayoutRequest,
+		EntityType: domain.AuditEntityPayout,
+		EntityID:   payoutID,
+		OfficeID:   auditActorFrom(ctx).OfficeID,
+		ActorID:    authorID,
+		Before:     map[string]any{"available_balance_cents": balance.AvailableBalanceCents},
+		After:      map[string]any{"available_balance_cents": balance.AvailableBalanceCents - int64(amountCents)},
+		Details:    map[string]any{"amount_cents": amountCents},
+	})
+	return payoutID, nil
 }
 
 // GetPayoutRequests retrieves payout requests for an author
@@ -143,13 +375,78 @@ func (s *EarningsService) GetPayoutRequests(
 	return s.earningsRepo.GetPayoutRequests(ctx, authorID, limit, offset)
 }
 
+// GetPayoutRequest retrieves a single payout request
+func (s *EarningsService) GetPayoutRequest(ctx context.Context, payoutID uuid.UUID) (*domain.PayoutRequest, error) {
+	return s.earningsRepo.GetPayoutRequest(ctx, payoutID)
+}
+
 // CompletePayout marks a payout as completed (admin/system use)
 func (s *EarningsService) CompletePayout(
 	ctx context.Context,
 	payoutID uuid.UUID,
 	stripeTransferID string,
 ) error {
-	return s.earningsRepo.CompletePayout(ctx, payoutID, stripeTransferID)
+	if err := s.earningsRepo.CompletePayout(ctx, payoutID, stripeTransferID); err != nil {
+		return err
+	}
+
+	payout, err := s.earningsRepo.GetPayoutRequest(ctx, payoutID)
+	if err == nil {
+		_, err = s.notifications.NotifyUser(ctx, payout.AuthorID, domain.NotificationTypePayoutProcessed,
+			"Payout sent",
+			fmt.Sprintf("Your payout of %s has been sent.", formatCents(payout.AmountCents)),
+			map[string]any{
+				"payout_id":    payout.ID.String(),
+				"amount_cents": payout.AmountCents,
+			},
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to notify author of payout %s: %v", payoutID, err)
+	}
+	return nil
+}
+
+// ListPayoutRequests retrieves every author's payout requests with the
+// given status, oldest first (admin use)
+func (s *EarningsService) ListPayoutRequests(
+	ctx context.Context,
+	status domain.PayoutStatus,
+	limit, offset int,
+) ([]domain.PayoutRequest, int, error) {
+	return s.earningsRepo.ListPayoutRequests(ctx, status, limit, offset)
+}
+
+// RejectPayout fails a payout that has not been sent, returning its amount
+// to the author's balance, and tells the author why (admin use)
+func (s *EarningsService) RejectPayout(
+	ctx context.Context,
+	payoutID uuid.UUID,
+	reason string,
+) (*domain.PayoutRequest, error) {
+	payout, err := s.earningsRepo.FailPayout(ctx, payoutID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.notifications.NotifyUser(ctx, payout.AuthorID, domain.NotificationTypePayoutFailed,
+		"Payout not sent",
+		fmt.Sprintf("Your payout of %s was not sent and is back in your balance: %s", formatCents(payout.AmountCents), reason),
+		map[string]any{
+			"payout_id":    payout.ID.String(),
+			"amount_cents": payout.AmountCents,
+			"reason":       reason,
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify author of rejected payout %s: %v", payoutID, err)
+	}
+	return payout, nil
+}
+
+// formatCents prints an amount of US cents in dollars
+func formatCents(cents int) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
 }
 
 // CalculateCommission calculates platform commission and author earnings
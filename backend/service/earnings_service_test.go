@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCalculateCommission(t *testing.T) {
+	svc := &EarningsService{}
+	tests := []struct {
+		sale, commission, authorEarning int
+	}{
+		{0, 0, 0},
+		{MinPriceCents, 39, 160},
+		{1000, 200, 800},
+		{999, 199, 800},
+		{1234, 246, 988},
+		{100000, 20000, 80000},
+	}
+	for _, tt := range tests {
+		commission, authorEarning := svc.CalculateCommission(tt.sale)
+		if commission != tt.commission || authorEarning != tt.authorEarning {
+			t.Errorf("CalculateCommission(%d) = %d, %d; want %d, %d",
+				tt.sale, commission, authorEarning, tt.commission, tt.authorEarning)
+		}
+		// The platform rounds its share down, so no cent is lost
+		if commission+authorEarning != tt.sale {
+			t.Errorf("CalculateCommission(%d) splits into %d", tt.sale, commission+authorEarning)
+		}
+	}
+}
+
+// testCreditsPerDollar prices templates at 5 credits a cent, as the default
+// configuration does
+const testCreditsPerDollar = 500
+
+// earningsMocks are the repositories behind an EarningsService under test
+type earningsMocks struct {
+	earnings    *mocks.MockEarningsRepository
+	marketplace *mocks.MockMarketplaceRepository
+	purchases   *mocks.MockTemplatePurchaseRepository
+	bundles     *mocks.MockTemplateBundleRepository
+	refunds     *mocks.MockRefundRequestRepository
+	credits     *mocks.MockCreditRepository
+	billing     *mocks.MockBillingProvider
+	offices     *mocks.MockOfficeRepository
+	audit       *mocks.MockAuditRepository
+	subs        *mocks.MockSubscriptionRepository
+}
+
+func newTestEarningsService(t *testing.T) (*EarningsService, earningsMocks) {
+	ctrl := gomock.NewController(t)
+	m := earningsMocks{
+		earnings:    mocks.NewMockEarningsRepository(ctrl),
+		marketplace: mocks.NewMockMarketplaceRepository(ctrl),
+		purchases:   mocks.NewMockTemplatePurchaseRepository(ctrl),
+		bundles:     mocks.NewMockTemplateBundleRepository(ctrl),
+		refunds:     mocks.NewMockRefundRequestRepository(ctrl),
+		credits:     mocks.NewMockCreditRepository(ctrl),
+		billing:     mocks.NewMockBillingProvider(ctrl),
+		offices:     mocks.NewMockOfficeRepository(ctrl),
+		audit:       mocks.NewMockAuditRepository(ctrl),
+		subs:        mocks.NewMockSubscriptionRepository(ctrl),
+	}
+
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+
+	users := mocks.NewMockUserRepository(ctrl)
+	notifications := NewNotificationService(
+		mocks.NewMockNotificationRepository(ctrl), mocks.NewMockNotificationPreferenceRepository(ctrl),
+		m.offices, users, mocks.NewMockMailer(ctrl), mocks.NewMockEventPublisher(ctrl), newTestWebhookDispatcher(ctrl), "")
+	audit := NewAuditService(m.audit, m.offices, users)
+
+	subscriptions := NewSubscriptionService(m.subs, m.credits, mocks.NewMockAgentRepository(ctrl), txManager,
+		nil, notifications, audit, testTiersPath)
+	svc := NewEarningsService(m.earnings, m.marketplace, m.purchases, m.bundles, m.refunds, m.credits,
+		mocks.NewMockIdempotencyRepository(ctrl), txManager, m.billing, notifications, audit, testCreditsPerDollar, subscriptions)
+	return svc, m
+}
+
+// paymentOf is a card payment that went through for amountCents, made for
+// metadata
+func paymentOf(id string, amountCents int64, metadata map[string]string) *domain.PaymentIntent {
+	return &domain.PaymentIntent{
+		ID: id, Status: domain.PaymentIntentSucceeded, AmountCents: amountCents, Currency: "usd", Metadata: metadata,
+	}
+}
+
+// expectPayment has Stripe report that the office paid for the template
+// with the payment, which has not been used for a sale yet
+func expectPayment(m earningsMocks, id string, amountCents int64, templateID, officeID uuid.UUID) {
+	m.billing.EXPECT().GetPaymentIntent(gomock.Any(), id).Return(paymentOf(id, amountCents, map[string]string{
+		"template_id": templateID.String(), "office_id": officeID.String(),
+	}), nil)
+	m.earnings.EXPECT().IsPaymentIntentUsed(gomock.Any(), id).Return(false, nil)
+}
+
+func TestPurchaseTemplateRecordsSaleAndGrantsTemplate(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+	authorID, purchaserID, officeID := uuid.New(), uuid.New(), uuid.New()
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Analyst", AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true,
+	}
+	earningID := uuid.New()
+
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+	m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+	expectPayment(m, "pi_123", 1500, template.ID, officeID)
+	m.earnings.EXPECT().RecordSale(gomock.Any(), authorID, template.ID, purchaserID, officeID, 1500, "pi_123").
+		Return(earningID, nil)
+	m.purchases.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, purchase *domain.TemplatePurchase) error {
+			if purchase.OfficeID != officeID || purchase.TemplateID != template.ID || purchase.PriceCents != 1500 ||
+				purchase.EarningID == nil || *purchase.EarningID != earningID ||
+				purchase.Status != domain.TemplatePurchaseStatusActive {
+				t.Errorf("purchase = %+v, want an active purchase of the template linked to the earning", purchase)
+			}
+			return nil
+		})
+	m.marketplace.EXPECT().IncrementDownload(gomock.Any(), template.ID).Return(nil)
+	// The author has no office to notify
+	m.offices.EXPECT().GetByUserID(gomock.Any(), authorID).Return(nil, nil)
+
+	got, err := svc.PurchaseTemplate(ctx, template.ID, purchaserID, officeID, "pi_123", "")
+	if err != nil {
+		t.Fatalf("PurchaseTemplate: %v", err)
+	}
+	if got != earningID {
+		t.Errorf("PurchaseTemplate = %s, want earning %s", got, earningID)
+	}
+}
+
+func TestPurchaseTemplateRejected(t *testing.T) {
+	authorID := uuid.New()
+	tests := []struct {
+		name     string
+		template *domain.AgentTemplate
+		owned    bool
+		want     error
+	}{
+		{"without author", &domain.AgentTemplate{ID: uuid.New(), PriceCents: 1500, Status: "approved", IsPublic: true}, false, domain.ErrInvalidInput},
+		{"below minimum price", &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: MinPriceCents - 1, Status: "approved", IsPublic: true}, false, domain.ErrInvalidInput},
+		{"already owned", &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true}, true, domain.ErrAlreadyExists},
+		{"pending", &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "pending", IsPublic: true}, false, domain.ErrNotFound},
+		{"private", &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "approved"}, false, domain.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestEarningsService(t)
+			officeID := uuid.New()
+
+			// No sale is recorded in any of these cases
+			m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), tt.template.ID).Return(tt.template, nil)
+			m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, tt.template.ID).Return(tt.owned, nil).MaxTimes(1)
+
+			_, err := svc.PurchaseTemplate(context.Background(), tt.template.ID, uuid.New(), officeID, "pi_123", "")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("PurchaseTemplate error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurchaseTemplateRequiresAPaymentForIt(t *testing.T) {
+	authorID, officeID := uuid.New(), uuid.New()
+	template := &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true}
+	metadata := map[string]string{"template_id": template.ID.String(), "office_id": officeID.String()}
+	unpaid := paymentOf("pi_123", 1500, metadata)
+	unpaid.Status = "requires_payment_method"
+
+	tests := []struct {
+		name    string
+		payment *domain.PaymentIntent
+		used    bool
+		want    error
+	}{
+		{"unpaid", unpaid, false, domain.ErrPaymentDeclined},
+		{"wrong amount", paymentOf("pi_123", 199, metadata), false, domain.ErrInvalidInput},
+		{"for another template", paymentOf("pi_123", 1500, map[string]string{
+			"template_id": uuid.NewString(), "office_id": officeID.String(),
+		}), false, domain.ErrInvalidInput},
+		{"used for another sale", paymentOf("pi_123", 1500, metadata), true, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestEarningsService(t)
+
+			// No sale is recorded in any of these cases
+			m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+			m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+			m.billing.EXPECT().GetPaymentIntent(gomock.Any(), "pi_123").Return(tt.payment, nil)
+			m.earnings.EXPECT().IsPaymentIntentUsed(gomock.Any(), "pi_123").Return(tt.used, nil).MaxTimes(1)
+
+			_, err := svc.PurchaseTemplate(context.Background(), template.ID, uuid.New(), officeID, "pi_123", "")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("PurchaseTemplate error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurchaseTemplateOfOwnTemplate(t *testing.T) {
+	svc, m := newTestEarningsService(t)
+	authorID := uuid.New()
+	template := &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true}
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+
+	_, err := svc.PurchaseTemplate(context.Background(), template.ID, authorID, uuid.New(), "pi_123", "")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("PurchaseTemplate of the purchaser's own template error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestPurchaseTemplateConcurrentPurchaseRollsBackSale(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+	authorID, officeID := uuid.New(), uuid.New()
+	template := &domain.AgentTemplate{ID: uuid.New(), AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true}
+
+	// A purchase made in the meantime fails the transaction, so the
+	// download is not counted
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+	m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+	expectPayment(m, "pi_123", 1500, template.ID, officeID)
+	m.earnings.EXPECT().RecordSale(gomock.Any(), authorID, template.ID, gomock.Any(), officeID, 1500, gomock.Any()).
+		Return(uuid.New(), nil)
+	m.purchases.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.ErrAlreadyExists)
+
+	_, err := svc.PurchaseTemplate(ctx, template.ID, uuid.New(), officeID, "pi_123", "")
+	if !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("PurchaseTemplate error = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestPurchaseTemplateRequiresLicenseCoveringOffice(t *testing.T) {
+	authorID := uuid.New()
+	license := domain.TemplateLicense{Type: domain.LicensePersonal, ResaleProhibited: true, Terms: "One user only."}
+
+	t.Run("office with more seats", func(t *testing.T) {
+		svc, m := newTestEarningsService(t)
+		officeID := uuid.New()
+		template := &domain.AgentTemplate{
+			ID: uuid.New(), Name: "Analyst", AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true, License: license,
+		}
+		m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+		m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+		m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(&domain.Subscription{Tier: domain.TierProfessional}, nil)
+
+		_, err := svc.PurchaseTemplate(context.Background(), template.ID, uuid.New(), officeID, "pi_123", "")
+		if !errors.Is(err, domain.ErrLicenseRequired) {
+			t.Errorf("PurchaseTemplate error = %v, want ErrLicenseRequired", err)
+		}
+	})
+
+	t.Run("single seat office", func(t *testing.T) {
+		svc, m := newTestEarningsService(t)
+		officeID := uuid.New()
+		template := &domain.AgentTemplate{
+			ID: uuid.New(), Name: "Analyst", AuthorID: &authorID, PriceCents: 1500, Version: "1.2.0", Status: "approved", IsPublic: true,
+			License: license,
+		}
+		m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+		m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+		// Offices without a subscription are on the single seat free tier
+		m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+		expectPayment(m, "pi_123", 1500, template.ID, officeID)
+		m.earnings.EXPECT().RecordSale(gomock.Any(), authorID, template.ID, gomock.Any(), officeID, 1500, "pi_123").
+			Return(uuid.New(), nil)
+		m.purchases.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, purchase *domain.TemplatePurchase) error {
+				if a := purchase.License; a == nil || a.License != license || a.Version != "1.2.0" || a.AcceptedAt.IsZero() {
+					t.Errorf("purchase license = %+v, want the accepted personal license of version 1.2.0", a)
+				}
+				return nil
+			})
+		m.marketplace.EXPECT().IncrementDownload(gomock.Any(), template.ID).Return(nil)
+		m.offices.EXPECT().GetByUserID(gomock.Any(), authorID).Return(nil, nil)
+
+		if _, err := svc.PurchaseTemplate(context.Background(), template.ID, uuid.New(), officeID, "pi_123", ""); err != nil {
+			t.Fatalf("PurchaseTemplate: %v", err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// Features of TierFeatures that RequireFeature gates
+const (
+	FeatureWebResearch           = "web_research"
+	FeatureAdvancedOrchestration = "advanced_orchestration"
+	FeatureAnalytics             = "analytics"
+	FeatureAPIAccess             = "api_access"
+	FeatureCustomPrompts         = "custom_prompts"
+)
+
+// featureFlags reads each gated feature from a tier's features
+var featureFlags = map[string]func(*domain.TierFeatures) bool{
+	FeatureWebResearch:           func(f *domain.TierFeatures) bool { return f.WebResearch },
+	FeatureAdvancedOrchestration: func(f *domain.TierFeatures) bool { return f.AdvancedOrchestration },
+	FeatureAnalytics:             func(f *domain.TierFeatures) bool { return f.Analytics },
+	FeatureAPIAccess:             func(f *domain.TierFeatures) bool { return f.APIAccess },
+	FeatureCustomPrompts:         func(f *domain.TierFeatures) bool { return f.CustomPrompts },
+}
+
+// IsFeature reports whether RequireFeature can check the named feature
+func IsFeature(feature string) bool {
+	_, ok := featureFlags[feature]
+	return ok
+}
+
+// RequireFeature returns ErrUpgradeRequired unless the office's tier
+// includes the feature. The error's details name the feature, the office's
+// tier and the cheapest tier that includes it.
+func (s *SubscriptionService) RequireFeature(ctx context.Context, officeID uuid.UUID, feature string) error {
+	included, ok := featureFlags[feature]
+	if !ok {
+		return fmt.Errorf("unknown feature %q", feature)
+	}
+	return s.requireIncluded(ctx, officeID, "feature", feature, included)
+}
+
+// RequireSkill returns ErrUpgradeRequired unless the office's tier includes
+// the skill, with details like RequireFeature's
+func (s *SubscriptionService) RequireSkill(ctx context.Context, officeID uuid.UUID, skill string) error {
+	return s.requireIncluded(ctx, officeID, "skill", skill, func(f *domain.TierFeatures) bool {
+		return slices.Contains(f.Skills, skill)
+	})
+}
+
+// requireIncluded returns ErrUpgradeRequired unless included holds for the
+// office's tier; kind names what key is in the error's details
+func (s *SubscriptionService) requireIncluded(
+	ctx context.Context,
+	officeID uuid.UUID,
+	kind string,
+	key string,
+	included func(*domain.TierFeatures) bool,
+) error {
+	tier := domain.TierSolo
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	switch {
+	case err == nil:
+		tier = sub.Tier
+	case !errors.Is(err, domain.ErrNotFound):
+		return err
+	}
+	tierDef, err := s.GetTier(tier)
+	if err != nil {
+		return err
+	}
+	if included(&tierDef.Features) {
+		return nil
+	}
+
+	name := strings.ReplaceAll(key, "_", " ")
+	details := map[string]any{kind: key, "current_tier": tier}
+	required, ok := s.cheapestTierWith(included)
+	if !ok {
+		return domain.WithDetails(fmt.Errorf("%w: %s is not available on any tier", domain.ErrUpgradeRequired, name), details)
+	}
+	details["required_tier"] = required
+	return domain.WithDetails(
+		fmt.Errorf("%w: %s requires the %s tier or higher", domain.ErrUpgradeRequired, name, s.config().tiers[required].Name),
+		details,
+	)
+}
+
+// cheapestTierWith returns the lowest tier, by compareTiers, whose features
+// satisfy included
+func (s *SubscriptionService) cheapestTierWith(included func(*domain.TierFeatures) bool) (domain.SubscriptionTier, bool) {
+	var candidates []domain.SubscriptionTier
+	for tier, def := range s.config().tiers {
+		if included(&def.Features) {
+			candidates = append(candidates, tier)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	slices.SortFunc(candidates, s.compareTiers)
+	return candidates[0], true
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/xlsx"
+	"github.com/google/uuid"
+)
+
+// exportBatchSize is how many rows exports read, and write out, at a time
+const exportBatchSize = 500
+
+// ExportFormat is a file format exports are written in
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatXLSX ExportFormat = "xlsx"
+)
+
+// Export is a table ready to be written. Its rows are read in batches while
+// it is written, so large exports are never held in memory.
+type Export struct {
+	// Name names the file and the sheet, such as "usage"
+	Name    string
+	Columns []string
+	// batches calls emit with each batch of rows, in order
+	batches func(ctx context.Context, emit func(rows [][]any) error) error
+}
+
+// Write writes the export's header and rows in format. After each batch w
+// is flushed if it has a Flush method, so the rows go out as they are read.
+func (e *Export) Write(ctx context.Context, w io.Writer, format ExportFormat) error {
+	var table exportTable
+	switch format {
+	case ExportFormatCSV:
+		table = &csvTable{w: csv.NewWriter(w)}
+	case ExportFormatXLSX:
+		x, err := xlsx.NewWriter(w, e.Name)
+		if err != nil {
+			return err
+		}
+		table = x
+	default:
+		return fmt.Errorf("%w: unknown export format %q", domain.ErrInvalidInput, format)
+	}
+
+	header := make([]any, len(e.Columns))
+	for i, column := range e.Columns {
+		header[i] = column
+	}
+	if err := table.WriteRow(header...); err != nil {
+		return err
+	}
+
+	flusher, _ := w.(interface{ Flush() error })
+	err := e.batches(ctx, func(rows [][]any) error {
+		for _, row := range rows {
+			if err := table.WriteRow(row...); err != nil {
+				return err
+			}
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			return flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return table.Close()
+}
+
+// exportTable is a file format's writer
+type exportTable interface {
+	WriteRow(cells ...any) error
+	Flush() error
+	Close() error
+}
+
+// csvTable writes rows as CSV
+type csvTable struct {
+	w *csv.Writer
+}
+
+func (t *csvTable) WriteRow(cells ...any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = csvCell(cell)
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) Flush() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+func (t *csvTable) Close() error {
+	return t.Flush()
+}
+
+// csvCell formats a cell for CSV. Text starting like a formula is prefixed
+// with an apostrophe so spreadsheets do not evaluate descriptions written
+// by users.
+func csvCell(cell any) string {
+	switch v := cell.(type) {
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ExportService builds CSV and Excel exports of an office's usage, credit
+// transactions and its members' template earnings. How far back an export
+// may reach is limited by the office's tier.
+type ExportService struct {
+	analyticsService    *AnalyticsService
+	creditRepo          domain.CreditRepository
+	earningsRepo        domain.EarningsRepository
+	subscriptionService *SubscriptionService
+}
+
+// NewExportService creates a new ExportService instance
+func NewExportService(
+	analyticsService *AnalyticsService,
+	creditRepo domain.CreditRepository,
+	earningsRepo domain.EarningsRepository,
+	subscriptionService *SubscriptionService,
+) *ExportService {
+	return &ExportService{
+		analyticsService:    analyticsService,
+		creditRepo:          creditRepo,
+		earningsRepo:        earningsRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// ExportUsage exports the office's usage per day over the last days days in
+// its timezone, newest first
+func (s *ExportService) ExportUsage(ctx context.Context, officeID uuid.UUID, days int) (*Export, error) {
+	if _, err := s.exportSince(ctx, officeID, days); err != nil {
+		return nil, err
+	}
+	// There is a row per day, so the usage is read before the export starts
+	// and errors can still be reported
+	usage, _, err := s.analyticsService.GetDailyUsage(ctx, officeID, UsagePeriod{Days: days})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		Name: "usage",
+		Columns: []string{
+			"date", "credits_consumed", "tasks_executed", "tasks_succeeded", "tasks_failed",
+			"input_tokens", "output_tokens", "total_tokens", "local_model_tasks", "paid_model_tasks", "estimated_usd",
+		},
+		batches: func(ctx context.Context, emit func([][]any) error) error {
+			rows := make([][]any, len(usage))
+			for i, u := range usage {
+				rows[i] = []any{
+					u.Date, u.CreditsConsumed, u.TasksExecuted, u.TasksSucceeded, u.TasksFailed,
+					u.InputTokens, u.OutputTokens, u.TotalTokens, u.LocalModelTasks, u.PaidModelTasks, u.EstimatedUSD,
+				}
+			}
+			return emit(rows)
+		},
+	}, nil
+}
+
+// ExportTransactions exports the office's credit transactions over the last
+// days days, newest first
+func (s *ExportService) ExportTransactions(ctx context.Context, officeID uuid.UUID, days int) (*Export, error) {
+	since, err := s.exportSince(ctx, officeID, days)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		Name:    "credit-transactions",
+		Columns: []string{"id", "created_at", "type", "amount", "balance_after", "reference_type", "reference_id", "description"},
+		batches: func(ctx context.Context, emit func([][]any) error) error {
+			page := domain.PageRequest{Limit: exportBatchSize}
+			for {
+				batch, err := s.creditRepo.GetTransactions(ctx, wallet.ID, page)
+				if err != nil {
+					return err
+				}
+				rows := make([][]any, 0, len(batch))
+				for _, tx := range batch {
+					if tx.CreatedAt.Before(since) {
+						return emit(rows)
+					}
+					var referenceID any
+					if tx.ReferenceID != nil {
+						referenceID = tx.ReferenceID.String()
+					}
+					rows = append(rows, []any{
+						tx.ID.String(), tx.CreatedAt, string(tx.Type), tx.Amount, tx.BalanceAfter,
+						tx.ReferenceType, referenceID, tx.Description,
+					})
+				}
+				if err := emit(rows); err != nil || len(batch) < page.Limit {
+					return err
+				}
+				last := batch[len(batch)-1]
+				page.Cursor = &domain.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+			}
+		},
+	}, nil
+}
+
+// ExportEarnings exports the author's template sales over the last days
+// days, newest first. The window is limited by the tier of the office the
+// author is signed in to.
+func (s *ExportService) ExportEarnings(ctx context.Context, officeID, authorID uuid.UUID, days int) (*Export, error) {
+	since, err := s.exportSince(ctx, officeID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Export{
+		Name: "earnings",
+		Columns: []string{
+			"id", "created_at", "template_id", "status",
+			"sale_amount_cents", "commission_cents", "author_earning_cents", "stripe_payment_intent_id",
+		},
+		batches: func(ctx context.Context, emit func([][]any) error) error {
+			for offset := 0; ; offset += exportBatchSize {
+				batch, err := s.earningsRepo.GetAuthorEarnings(ctx, authorID, exportBatchSize, offset)
+				if err != nil {
+					return err
+				}
+				rows := make([][]any, 0, len(batch))
+				for _, e := range batch {
+					if e.CreatedAt.Before(since) {
+						return emit(rows)
+					}
+					rows = append(rows, []any{
+						e.ID.String(), e.CreatedAt, e.TemplateID.String(), e.Status,
+						e.SaleAmountCents, e.CommissionCents, e.AuthorEarningCents, e.StripePaymentIntentID,
+					})
+				}
+				if err := emit(rows); err != nil || len(batch) < exportBatchSize {
+					return err
+				}
+			}
+		},
+	}, nil
+}
+
+// exportSince checks days against the export window of the office's tier
+// and returns when the window starts
+func (s *ExportService) exportSince(ctx context.Context, officeID uuid.UUID, days int) (time.Time, error) {
+	if days < 1 {
+		return time.Time{}, fmt.Errorf("%w: days must be at least 1", domain.ErrInvalidInput)
+	}
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, officeID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if features.MaxExportDays == 0 {
+		return time.Time{}, domain.WithDetails(
+			fmt.Errorf("%w: exports require a higher tier", domain.ErrFeatureNotAvailable),
+			map[string]any{"feature": "exports"},
+		)
+	}
+	if features.MaxExportDays != unlimited && days > features.MaxExportDays {
+		return time.Time{}, domain.WithDetails(
+			fmt.Errorf("%w: exports on this tier cover at most %d days", domain.ErrTierLimitExceeded, features.MaxExportDays),
+			map[string]any{"limit": features.MaxExportDays, "current": days},
+		)
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
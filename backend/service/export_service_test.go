@@ -0,0 +1,150 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestExportService creates an ExportService for an office on tier, with
+// mocks for the credit and earnings repositories
+func newTestExportService(t *testing.T, officeID uuid.UUID, tier domain.SubscriptionTier) (*ExportService, *mocks.MockCreditRepository, *mocks.MockEarningsRepository) {
+	subs, m := newTestSubscriptionService(t)
+	ctrl := gomock.NewController(t)
+	earnings := mocks.NewMockEarningsRepository(ctrl)
+
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).
+		Return(&domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: tier}, nil).AnyTimes()
+	analytics := NewAnalyticsService(mocks.NewMockAnalyticsRepository(ctrl), m.credits, m.offices, false)
+	return NewExportService(analytics, m.credits, earnings, subs), m.credits, earnings
+}
+
+func TestExportWindowIsLimitedByTier(t *testing.T) {
+	officeID := uuid.New()
+
+	tests := []struct {
+		name    string
+		tier    domain.SubscriptionTier
+		days    int
+		wantErr error
+	}{
+		{"within the window", domain.TierProfessional, 90, nil},
+		{"past the window", domain.TierProfessional, 91, domain.ErrTierLimitExceeded},
+		{"unlimited", domain.TierEnterprise, 3650, nil},
+		{"no days", domain.TierEnterprise, 0, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, _ := newTestExportService(t, officeID, tt.tier)
+
+			_, err := svc.ExportEarnings(context.Background(), officeID, uuid.New(), tt.days)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExportEarnings(%d days) error = %v, want %v", tt.days, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportTransactionsPagesUntilTheWindowStarts(t *testing.T) {
+	officeID := uuid.New()
+	svc, credits, _ := newTestExportService(t, officeID, domain.TierProfessional)
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+
+	// A full batch within the window, then one that runs past its start
+	now := time.Now()
+	first := make([]*domain.CreditTransaction, exportBatchSize)
+	for i := range first {
+		first[i] = &domain.CreditTransaction{ID: uuid.New(), Type: domain.TransactionTypeConsumption, Amount: -1, CreatedAt: now.Add(-time.Duration(i) * time.Minute)}
+	}
+	first[0].Description = "=HYPERLINK(\"http://example.com\")"
+	second := []*domain.CreditTransaction{
+		{ID: uuid.New(), Type: domain.TransactionTypeConsumption, Amount: -2, CreatedAt: now.AddDate(0, 0, -6)},
+		{ID: uuid.New(), Type: domain.TransactionTypeConsumption, Amount: -3, CreatedAt: now.AddDate(0, 0, -8)},
+	}
+
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	gomock.InOrder(
+		credits.EXPECT().GetTransactions(gomock.Any(), wallet.ID, domain.PageRequest{Limit: exportBatchSize}).Return(first, nil),
+		credits.EXPECT().GetTransactions(gomock.Any(), wallet.ID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ uuid.UUID, page domain.PageRequest) ([]*domain.CreditTransaction, error) {
+				last := first[len(first)-1]
+				if page.Cursor == nil || page.Cursor.ID != last.ID {
+					t.Errorf("second page cursor = %+v, want the last transaction of the first", page.Cursor)
+				}
+				return second, nil
+			}),
+	)
+
+	export, err := svc.ExportTransactions(context.Background(), officeID, 7)
+	if err != nil {
+		t.Fatalf("ExportTransactions: %v", err)
+	}
+	var out bytes.Buffer
+	if err := export.Write(context.Background(), &out, ExportFormatCSV); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the CSV: %v", err)
+	}
+	if want := 1 + exportBatchSize + 1; len(records) != want {
+		t.Fatalf("got %d records, want a header and %d transactions", len(records), want-1)
+	}
+	if strings.Join(records[0], ",") != strings.Join(export.Columns, ",") {
+		t.Errorf("header = %v, want %v", records[0], export.Columns)
+	}
+	if got := records[1][7]; !strings.HasPrefix(got, "'=") {
+		t.Errorf("description = %q, want the formula escaped", got)
+	}
+	if got := records[len(records)-1][3]; got != "-2" {
+		t.Errorf("last amount = %q, want -2", got)
+	}
+}
+
+func TestExportWritesXLSX(t *testing.T) {
+	export := &Export{
+		Name:    "earnings",
+		Columns: []string{"name", "amount"},
+		batches: func(ctx context.Context, emit func([][]any) error) error {
+			return emit([][]any{{"Tom & Jerry <3", 1250}})
+		},
+	}
+
+	var out bytes.Buffer
+	if err := export.Write(context.Background(), &out, ExportFormatXLSX); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(r)
+			sheet = string(data)
+		}
+	}
+	for _, want := range []string{"<t xml:space=\"preserve\">amount</t>", "Tom &amp; Jerry &lt;3", "<v>1250</v>", "</sheetData></worksheet>"} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet does not contain %q:\n%s", want, sheet)
+		}
+	}
+}
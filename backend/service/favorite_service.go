@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// FavoriteService manages users' favorite templates and tells them when a
+// favorited premium template goes on sale or publishes a new version
+type FavoriteService struct {
+	favoriteRepo    domain.TemplateFavoriteRepository
+	marketplaceRepo domain.MarketplaceRepository
+	notifications   *NotificationService
+}
+
+// NewFavoriteService creates a new FavoriteService instance
+func NewFavoriteService(
+	favoriteRepo domain.TemplateFavoriteRepository,
+	marketplaceRepo domain.MarketplaceRepository,
+	notifications *NotificationService,
+) *FavoriteService {
+	return &FavoriteService{
+		favoriteRepo:    favoriteRepo,
+		marketplaceRepo: marketplaceRepo,
+		notifications:   notifications,
+	}
+}
+
+// AddFavorite saves a listed template to the user's favorites, or changes
+// whether an existing favorite notifies
+func (s *FavoriteService) AddFavorite(ctx context.Context, userID, templateID uuid.UUID, notify bool) (*domain.TemplateFavorite, error) {
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.Status != "approved" || !template.IsPublic {
+		return nil, domain.ErrNotFound
+	}
+
+	favorite := &domain.TemplateFavorite{
+		UserID:     userID,
+		TemplateID: templateID,
+		Notify:     notify,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.favoriteRepo.Add(ctx, favorite); err != nil {
+		return nil, err
+	}
+	favorite.Template = template
+	return favorite, nil
+}
+
+// RemoveFavorite removes a template from the user's favorites
+func (s *FavoriteService) RemoveFavorite(ctx context.Context, userID, templateID uuid.UUID) error {
+	return s.favoriteRepo.Remove(ctx, userID, templateID)
+}
+
+// ListFavorites returns a page of the user's favorites, newest first
+func (s *FavoriteService) ListFavorites(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.TemplateFavorite, int, error) {
+	return s.favoriteRepo.ListByUser(ctx, userID, limit, offset)
+}
+
+// NotifyTemplateUpdated is called when a template is listed after a change.
+// Users who favorited it and asked to be notified hear once of each price
+// drop and new version of a premium template. It is best effort: failures
+// are logged, not returned.
+func (s *FavoriteService) NotifyTemplateUpdated(ctx context.Context, template *domain.AgentTemplate) {
+	if template.Status != "approved" || !template.IsPublic {
+		return
+	}
+
+	updates, err := s.favoriteRepo.TakeUpdates(ctx, template.ID, template.Version, template.PriceCents)
+	if err != nil {
+		log.Printf("Failed to get favorites of template %s to notify: %v", template.ID, err)
+		return
+	}
+
+	for _, u := range updates {
+		if !u.Notify || !template.IsPremium {
+			continue
+		}
+		var title, message string
+		switch {
+		case u.PreviousPriceCents > 0 && template.PriceCents < u.PreviousPriceCents:
+			title = "Favorite template on sale"
+			message = fmt.Sprintf("%q is now %s, down from %s.",
+				template.Name, formatCents(template.PriceCents), formatCents(u.PreviousPriceCents))
+		case template.Version != u.PreviousVersion:
+			title = "Favorite template updated"
+			message = fmt.Sprintf("%q published version %s.", template.Name, template.Version)
+		default:
+			continue
+		}
+
+		_, err := s.notifications.NotifyUser(ctx, u.UserID, domain.NotificationTypeFavoriteUpdate, title, message,
+			map[string]any{
+				"template_id": template.ID.String(),
+				"version":     template.Version,
+				"price_cents": template.PriceCents,
+			},
+		)
+		if err != nil {
+			log.Printf("Failed to notify user %s of favorite template %s: %v", u.UserID, template.ID, err)
+		}
+	}
+}
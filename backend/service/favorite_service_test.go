@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+type favoriteMocks struct {
+	favorites   *mocks.MockTemplateFavoriteRepository
+	marketplace *mocks.MockMarketplaceRepository
+	offices     *mocks.MockOfficeRepository
+}
+
+func newTestFavoriteService(t *testing.T) (*FavoriteService, favoriteMocks) {
+	ctrl := gomock.NewController(t)
+	m := favoriteMocks{
+		favorites:   mocks.NewMockTemplateFavoriteRepository(ctrl),
+		marketplace: mocks.NewMockMarketplaceRepository(ctrl),
+		offices:     mocks.NewMockOfficeRepository(ctrl),
+	}
+	notifications := NewNotificationService(
+		mocks.NewMockNotificationRepository(ctrl), mocks.NewMockNotificationPreferenceRepository(ctrl),
+		m.offices, mocks.NewMockUserRepository(ctrl), mocks.NewMockMailer(ctrl), mocks.NewMockEventPublisher(ctrl),
+		newTestWebhookDispatcher(ctrl), "")
+	return NewFavoriteService(m.favorites, m.marketplace, notifications), m
+}
+
+func TestAddFavoriteRequiresListedTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template domain.AgentTemplate
+		want     error
+	}{
+		{"approved public", domain.AgentTemplate{Status: "approved", IsPublic: true}, nil},
+		{"pending", domain.AgentTemplate{Status: "pending", IsPublic: true}, domain.ErrNotFound},
+		{"private", domain.AgentTemplate{Status: "approved"}, domain.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestFavoriteService(t)
+			userID, template := uuid.New(), tt.template
+			template.ID = uuid.New()
+			m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(&template, nil)
+			if tt.want == nil {
+				m.favorites.EXPECT().Add(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, f *domain.TemplateFavorite) error {
+						if f.UserID != userID || f.TemplateID != template.ID || !f.Notify || f.CreatedAt.IsZero() {
+							t.Errorf("added %+v, want the user's notifying favorite", f)
+						}
+						return nil
+					})
+			}
+
+			_, err := svc.AddFavorite(context.Background(), userID, template.ID, true)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("AddFavorite error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyTemplateUpdatedTellsFavoritesOfSalesAndVersions(t *testing.T) {
+	svc, m := newTestFavoriteService(t)
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Helper", Status: "approved", IsPublic: true,
+		IsPremium: true, PriceCents: 999, Version: "1.1.0",
+	}
+	onSale, newVersion, raised, muted := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	m.favorites.EXPECT().TakeUpdates(gomock.Any(), template.ID, "1.1.0", 999).Return([]domain.FavoriteUpdate{
+		{UserID: onSale, Notify: true, PreviousVersion: "1.1.0", PreviousPriceCents: 1999},
+		{UserID: newVersion, Notify: true, PreviousVersion: "1.0.0", PreviousPriceCents: 999},
+		{UserID: raised, Notify: true, PreviousVersion: "1.1.0", PreviousPriceCents: 499},
+		{UserID: muted, Notify: false, PreviousVersion: "1.0.0", PreviousPriceCents: 1999},
+	}, nil)
+	// Only the users told look up an office to notify; they have none
+	m.offices.EXPECT().GetByUserID(gomock.Any(), onSale).Return(nil, nil)
+	m.offices.EXPECT().GetByUserID(gomock.Any(), newVersion).Return(nil, nil)
+
+	svc.NotifyTemplateUpdated(context.Background(), template)
+}
+
+func TestNotifyTemplateUpdatedSkipsUnlistedTemplates(t *testing.T) {
+	svc, _ := newTestFavoriteService(t)
+
+	// A pending template is not taken from the favorites, so its change is
+	// notified once it is approved
+	svc.NotifyTemplateUpdated(context.Background(), &domain.AgentTemplate{ID: uuid.New(), Status: "pending", IsPublic: true})
+}
@@ -1,38 +1,53 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
-	"github.com/denys89/syn-office/backend/repository"
 	"github.com/google/uuid"
 )
 
 // FeedbackService handles feedback-related operations
 type FeedbackService struct {
-	feedbackRepo *repository.FeedbackRepository
-	agentRepo    domain.AgentRepository
-	officeRepo   domain.OfficeRepository
+	feedbackRepo    domain.FeedbackRepository
+	agentRepo       domain.AgentRepository
+	memoryService   *MemoryService
+	learningStats   *LearningStatsService
+	orchestratorURL string
+	httpClient      *http.Client
 }
 
 // NewFeedbackService creates a new FeedbackService instance
 func NewFeedbackService(
-	feedbackRepo *repository.FeedbackRepository,
+	feedbackRepo domain.FeedbackRepository,
 	agentRepo domain.AgentRepository,
-	officeRepo domain.OfficeRepository,
+	memoryService *MemoryService,
+	learningStats *LearningStatsService,
+	orchestratorURL string,
 ) *FeedbackService {
 	return &FeedbackService{
-		feedbackRepo: feedbackRepo,
-		agentRepo:    agentRepo,
-		officeRepo:   officeRepo,
+		feedbackRepo:    feedbackRepo,
+		agentRepo:       agentRepo,
+		memoryService:   memoryService,
+		learningStats:   learningStats,
+		orchestratorURL: orchestratorURL,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
 	}
 }
 
 // CreateMessageFeedback creates feedback for a specific message
 func (s *FeedbackService) CreateMessageFeedback(
 	ctx context.Context,
-	userID uuid.UUID,
+	officeID uuid.UUID,
 	messageID uuid.UUID,
 	feedbackType domain.FeedbackType,
 	rating int,
@@ -44,27 +59,18 @@ func (s *FeedbackService) CreateMessageFeedback(
 	if err != nil {
 		return nil, err
 	}
+	if err := ensureOffice(message.OfficeID, officeID); err != nil {
+		return nil, err
+	}
 
 	// Verify message is from an agent (not user)
 	if message.SenderType != domain.SenderTypeAgent {
 		return nil, domain.ErrInvalidInput
 	}
 
-	// Verify user has access to this office (check they have at least one office)
-	offices, err := s.officeRepo.GetByUserID(ctx, userID)
-	if err != nil || len(offices) == 0 {
-		return nil, domain.ErrForbidden
-	}
-	// Verify the message's office belongs to the user
-	hasAccess := false
-	for _, office := range offices {
-		if office.ID == message.OfficeID {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return nil, domain.ErrForbidden
+	// A correction is only useful if it says what the agent should have said
+	if feedbackType == domain.FeedbackTypeCorrection && strings.TrimSpace(correctionContent) == "" {
+		return nil, domain.ErrInvalidInput
 	}
 
 	// Create feedback
@@ -85,9 +91,105 @@ func (s *FeedbackService) CreateMessageFeedback(
 		return nil, err
 	}
 
+	// Turn corrections into memories so the agent applies them in future tasks
+	if feedback.FeedbackType == domain.FeedbackTypeCorrection {
+		if _, err := s.learnFromCorrection(ctx, feedback); err != nil {
+			log.Printf("Failed to create correction memory for feedback %s: %v", feedback.ID, err)
+		}
+	}
+
+	s.learningStats.MarkDirty(feedback.AgentID)
+
 	return feedback, nil
 }
 
+// learnFromCorrection stores a correction memory for the agent and syncs it to the orchestrator
+func (s *FeedbackService) learnFromCorrection(ctx context.Context, feedback *domain.AgentFeedback) (*domain.AgentMemory, error) {
+	key := "correction:" + feedback.ID.String()
+	if feedback.MessageID != nil {
+		// One correction memory per message; a newer correction replaces the old one
+		key = "correction:" + feedback.MessageID.String()
+	}
+
+	value := fmt.Sprintf("Original response: %s\nCorrected response: %s", feedback.OriginalContent, feedback.CorrectionContent)
+	if feedback.Comment != "" {
+		value += "\nUser note: " + feedback.Comment
+	}
+
+	memory, err := s.memoryService.UpsertMemory(ctx, CreateMemoryInput{
+		OfficeID:   feedback.OfficeID,
+		AgentID:    feedback.AgentID,
+		Key:        key,
+		Value:      value,
+		MemoryType: domain.MemoryTypeCorrection,
+		Source:     domain.MemorySourceFeedback,
+		SourceID:   &feedback.ID,
+		Metadata: map[string]any{
+			"original_content":   feedback.OriginalContent,
+			"correction_content": feedback.CorrectionContent,
+			"message_id":         feedback.MessageID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go s.syncMemoryToOrchestrator(context.Background(), memory)
+
+	return memory, nil
+}
+
+// MemorySyncRequest represents a memory pushed to the orchestrator for indexing
+type MemorySyncRequest struct {
+	MemoryID        string         `json:"memory_id"`
+	AgentID         string         `json:"agent_id"`
+	OfficeID        string         `json:"office_id"`
+	Key             string         `json:"key"`
+	Value           string         `json:"value"`
+	MemoryType      string         `json:"memory_type"`
+	ImportanceScore float64        `json:"importance_score"`
+	Source          string         `json:"source"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+}
+
+// syncMemoryToOrchestrator notifies the orchestrator of a new memory so it is used in semantic recall
+func (s *FeedbackService) syncMemoryToOrchestrator(ctx context.Context, memory *domain.AgentMemory) {
+	request := MemorySyncRequest{
+		MemoryID:        memory.ID.String(),
+		AgentID:         memory.AgentID.String(),
+		OfficeID:        memory.OfficeID.String(),
+		Key:             memory.Key,
+		Value:           memory.Value,
+		MemoryType:      memory.MemoryType,
+		ImportanceScore: memory.ImportanceScore,
+		Source:          memory.Source,
+	}
+
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		log.Printf("Failed to encode memory sync for %s: %v", memory.ID, err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.orchestratorURL+"/memories", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		log.Printf("Failed to build memory sync for %s: %v", memory.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to sync memory %s to orchestrator: %v", memory.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Orchestrator rejected memory %s: status %d", memory.ID, resp.StatusCode)
+	}
+}
+
 // FeedbackSummary represents aggregated feedback statistics
 type FeedbackSummary struct {
 	AgentID           string  `json:"agent_id"`
@@ -103,31 +205,13 @@ type FeedbackSummary struct {
 // GetAgentFeedbackSummary returns aggregated feedback stats for an agent
 func (s *FeedbackService) GetAgentFeedbackSummary(
 	ctx context.Context,
-	userID uuid.UUID,
+	officeID uuid.UUID,
 	agentID uuid.UUID,
 ) (*FeedbackSummary, error) {
-	// Verify agent exists and user has access
-	agent, err := s.agentRepo.GetByID(ctx, agentID)
-	if err != nil {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
 		return nil, err
 	}
 
-	// Verify user owns this office
-	offices, err := s.officeRepo.GetByUserID(ctx, userID)
-	if err != nil {
-		return nil, domain.ErrForbidden
-	}
-	hasAccess := false
-	for _, office := range offices {
-		if office.ID == agent.OfficeID {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return nil, domain.ErrForbidden
-	}
-
 	// Get feedback counts
 	positive, negative, correction, avgRating, err := s.feedbackRepo.GetFeedbackSummary(ctx, agentID)
 	if err != nil {
@@ -161,32 +245,14 @@ func (s *FeedbackService) GetAgentFeedbackSummary(
 // GetAgentMemories returns memories for an agent
 func (s *FeedbackService) GetAgentMemories(
 	ctx context.Context,
-	userID uuid.UUID,
+	officeID uuid.UUID,
 	agentID uuid.UUID,
 	memoryType string,
 	limit int,
 ) ([]*domain.AgentMemory, error) {
-	// Verify agent exists and user has access
-	agent, err := s.agentRepo.GetByID(ctx, agentID)
-	if err != nil {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
 		return nil, err
 	}
 
-	// Verify user owns this office
-	offices, err := s.officeRepo.GetByUserID(ctx, userID)
-	if err != nil {
-		return nil, domain.ErrForbidden
-	}
-	hasAccess := false
-	for _, office := range offices {
-		if office.ID == agent.OfficeID {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		return nil, domain.ErrForbidden
-	}
-
 	return s.feedbackRepo.GetAgentMemories(ctx, agentID, memoryType, limit)
 }
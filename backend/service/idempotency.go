@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_keys.idempotency_key column
+const maxIdempotencyKeyLength = 255
+
+// newIdempotencyKey builds the key record for a request, fingerprinting the
+// request parameters. An empty key means the request is not idempotent and
+// yields nil.
+func newIdempotencyKey(officeID uuid.UUID, scope, key string, params ...any) (*domain.IdempotencyKey, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%w: idempotency key must be at most %d characters", domain.ErrInvalidInput, maxIdempotencyKeyLength)
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = fmt.Sprint(p)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+
+	return &domain.IdempotencyKey{
+		OfficeID:    officeID,
+		Scope:       scope,
+		Key:         key,
+		RequestHash: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// runIdempotent runs op at most once per idempotency key and returns the ID
+// of the resource it created. Replaying the same request returns the ID
+// recorded by the first run with replayed set; op is not called again.
+// op and the key's completion commit in one transaction, so a key is never
+// left claimed after its operation went through, to be run again once the
+// claim expires. A nil key runs op unconditionally.
+func runIdempotent(
+	ctx context.Context,
+	txManager domain.TxManager,
+	repo domain.IdempotencyRepository,
+	key *domain.IdempotencyKey,
+	op func(ctx context.Context) (uuid.UUID, error),
+) (resourceID uuid.UUID, replayed bool, err error) {
+	if key == nil {
+		resourceID, err = op(ctx)
+		return resourceID, false, err
+	}
+
+	existing, err := repo.Claim(ctx, key)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if existing != nil {
+		if existing.RequestHash != key.RequestHash {
+			return uuid.Nil, false, domain.ErrIdempotencyKeyReused
+		}
+		if existing.ResourceID == nil {
+			return uuid.Nil, false, domain.ErrRequestInProgress
+		}
+		return *existing.ResourceID, true, nil
+	}
+
+	err = txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if resourceID, err = op(ctx); err != nil {
+			return err
+		}
+		if err := repo.Complete(ctx, key, resourceID); err != nil {
+			return fmt.Errorf("failed to complete idempotency key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// Let the client retry a request that did not go through
+		if releaseErr := repo.Release(ctx, key); releaseErr != nil {
+			log.Printf("Failed to release idempotency key %s/%s: %v", key.Scope, key.Key, releaseErr)
+		}
+		return uuid.Nil, false, err
+	}
+	return resourceID, false, nil
+}
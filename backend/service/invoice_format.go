@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/pdf"
+)
+
+// invoiceDateFormat is how invoices print dates; they are in UTC
+const invoiceDateFormat = "January 2, 2006"
+
+// WriteInvoicePDF writes a manual invoice as a PDF document
+func WriteInvoicePDF(w io.Writer, invoice *domain.Invoice) error {
+	title := "Invoice " + invoice.Number
+	doc := pdf.New(title)
+	doc.Paragraph(title, pdf.Title)
+	doc.Space(4)
+
+	summary := []string{
+		"Issued " + invoice.CreatedAt.UTC().Format(invoiceDateFormat),
+		"Billing period " + invoice.PeriodStart.UTC().Format(invoiceDateFormat) + " to " + invoice.PeriodEnd.UTC().Format(invoiceDateFormat),
+	}
+	if invoice.DueAt != nil {
+		summary = append(summary, "Due "+invoice.DueAt.UTC().Format(invoiceDateFormat))
+	}
+	summary = append(summary, "Status: "+string(invoice.Status))
+	for _, line := range summary {
+		doc.Paragraph(line, pdf.Small)
+	}
+
+	doc.Space(12)
+	doc.Paragraph("Charges", pdf.Heading)
+	if len(invoice.Lines) == 0 {
+		doc.Paragraph("No charges.", pdf.Body)
+	}
+	for _, line := range invoice.Lines {
+		text := line.Description
+		if line.Quantity != 1 {
+			text = fmt.Sprintf("%d x %s", line.Quantity, text)
+		}
+		doc.Paragraph(text+": "+formatAmount(line.AmountCents, invoice.Currency), pdf.Body)
+	}
+
+	doc.Space(12)
+	doc.Paragraph("Total: "+formatAmount(invoice.AmountCents, invoice.Currency), pdf.Heading)
+	if invoice.AmountPaidCents > 0 {
+		doc.Paragraph("Paid: "+formatAmount(invoice.AmountPaidCents, invoice.Currency), pdf.Body)
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// formatAmount prints an amount in cents, e.g. "29.00 USD"
+func formatAmount(cents int64, currency string) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, strings.ToUpper(currency))
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxKnowledgeDocumentBytes bounds the content of one knowledge document
+	maxKnowledgeDocumentBytes      = 1 << 20
+	maxKnowledgeDocumentsPerOffice = 200
+	maxKnowledgeTitleLength        = 255
+	defaultKnowledgeSearchLimit    = 10
+	maxKnowledgeSearchLimit        = 50
+	maxKnowledgeQueryLength        = 1000
+	// knowledgeChunkRunes is the most characters of a chunk, about 400
+	// tokens; chunks end at paragraphs, or else at whitespace
+	knowledgeChunkRunes = 1600
+	// maxKnowledgeQueryRunes is how much of a task's input is searched for
+	// relevant knowledge
+	maxKnowledgeQueryRunes = 2000
+	// knowledgeEmbedInterval is how often new chunks are embedded, and
+	// knowledgeEmbedBatch how many at a time
+	knowledgeEmbedInterval = time.Minute
+	knowledgeEmbedBatch    = 100
+)
+
+// KnowledgeService manages offices' knowledge bases: notes and text files
+// all of an office's agents share, such as company information and style
+// guides. Documents are split into chunks, embedded in the background, and
+// tasks are given the chunks most relevant to their input.
+type KnowledgeService struct {
+	knowledgeRepo domain.KnowledgeRepository
+	embedder      domain.Embedder
+}
+
+// NewKnowledgeService creates a new KnowledgeService instance. Without an
+// embedder, chunks are found by their words instead of their meaning.
+func NewKnowledgeService(knowledgeRepo domain.KnowledgeRepository, embedder domain.Embedder) *KnowledgeService {
+	return &KnowledgeService{knowledgeRepo: knowledgeRepo, embedder: embedder}
+}
+
+// CreateKnowledgeInput is a note, or an uploaded file when Filename is set.
+// A file's title defaults to its name.
+type CreateKnowledgeInput struct {
+	OfficeID uuid.UUID
+	UserID   uuid.UUID
+	Title    string
+	Content  string
+	Filename string
+}
+
+// CreateDocument adds a note or text file to the office's knowledge base
+func (s *KnowledgeService) CreateDocument(ctx context.Context, input CreateKnowledgeInput) (*domain.KnowledgeDocument, error) {
+	title := strings.TrimSpace(input.Title)
+	source := domain.KnowledgeSourceNote
+	if input.Filename != "" {
+		source = domain.KnowledgeSourceFile
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(input.Filename), filepath.Ext(input.Filename))
+		}
+	}
+	if title == "" || utf8.RuneCountInString(title) > maxKnowledgeTitleLength {
+		return nil, fmt.Errorf("%w: title is required and must be at most %d characters", domain.ErrInvalidInput, maxKnowledgeTitleLength)
+	}
+	if len(input.Content) > maxKnowledgeDocumentBytes {
+		return nil, fmt.Errorf("%w: knowledge documents are at most %d bytes", domain.ErrInvalidInput, maxKnowledgeDocumentBytes)
+	}
+	if !utf8.ValidString(input.Content) || strings.ContainsRune(input.Content, 0) {
+		return nil, fmt.Errorf("%w: knowledge documents must be UTF-8 text", domain.ErrInvalidInput)
+	}
+	pieces := chunkKnowledge(input.Content)
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("%w: content is required", domain.ErrInvalidInput)
+	}
+
+	count, err := s.knowledgeRepo.CountByOfficeID(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxKnowledgeDocumentsPerOffice {
+		return nil, fmt.Errorf("%w: an office can have at most %d knowledge documents", domain.ErrInvalidInput, maxKnowledgeDocumentsPerOffice)
+	}
+
+	document := &domain.KnowledgeDocument{
+		ID:         uuid.New(),
+		OfficeID:   input.OfficeID,
+		Title:      title,
+		Source:     source,
+		Filename:   input.Filename,
+		Content:    input.Content,
+		SizeBytes:  len(input.Content),
+		ChunkCount: len(pieces),
+		CreatedBy:  nullableID(input.UserID),
+		CreatedAt:  time.Now(),
+	}
+	chunks := make([]*domain.KnowledgeChunk, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = &domain.KnowledgeChunk{
+			ID:         uuid.New(),
+			DocumentID: document.ID,
+			OfficeID:   document.OfficeID,
+			Position:   i,
+			Content:    piece,
+		}
+	}
+	if err := s.knowledgeRepo.Create(ctx, document, chunks); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// GetDocuments lists the office's knowledge documents, newest first
+func (s *KnowledgeService) GetDocuments(ctx context.Context, officeID uuid.UUID) ([]*domain.KnowledgeDocument, error) {
+	return s.knowledgeRepo.GetByOfficeID(ctx, officeID)
+}
+
+// GetDocument returns one of the office's knowledge documents with its
+// content
+func (s *KnowledgeService) GetDocument(ctx context.Context, officeID, documentID uuid.UUID) (*domain.KnowledgeDocument, error) {
+	document, err := s.knowledgeRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(document.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// DeleteDocument removes one of the office's knowledge documents
+func (s *KnowledgeService) DeleteDocument(ctx context.Context, officeID, documentID uuid.UUID) error {
+	if _, err := s.GetDocument(ctx, officeID, documentID); err != nil {
+		return err
+	}
+	return s.knowledgeRepo.Delete(ctx, documentID)
+}
+
+// Search returns the office's knowledge chunks most relevant to the query
+func (s *KnowledgeService) Search(ctx context.Context, officeID uuid.UUID, query string, limit int) ([]*domain.KnowledgeMatch, error) {
+	switch {
+	case limit <= 0:
+		limit = defaultKnowledgeSearchLimit
+	case limit > maxKnowledgeSearchLimit:
+		limit = maxKnowledgeSearchLimit
+	}
+	query = strings.TrimSpace(query)
+	if query == "" || len(query) > maxKnowledgeQueryLength {
+		return nil, fmt.Errorf("%w: query is required and must be at most %d characters", domain.ErrInvalidInput, maxKnowledgeQueryLength)
+	}
+	return s.search(ctx, officeID, query, limit)
+}
+
+// Relevant returns the office's knowledge chunks most relevant to a task's
+// input, for its context
+func (s *KnowledgeService) Relevant(ctx context.Context, officeID uuid.UUID, input string, limit int) ([]*domain.KnowledgeMatch, error) {
+	if runes := []rune(input); len(runes) > maxKnowledgeQueryRunes {
+		input = string(runes[:maxKnowledgeQueryRunes])
+	}
+	if input = strings.TrimSpace(input); input == "" || limit <= 0 {
+		return nil, nil
+	}
+	return s.search(ctx, officeID, input, limit)
+}
+
+// search searches by the embedding of the query, falling back to its words
+// when it cannot be embedded
+func (s *KnowledgeService) search(ctx context.Context, officeID uuid.UUID, query string, limit int) ([]*domain.KnowledgeMatch, error) {
+	if s.embedder != nil {
+		embedding, err := s.embedder.Embed(ctx, query)
+		if err == nil {
+			return s.knowledgeRepo.SearchByEmbedding(ctx, officeID, embedding, limit)
+		}
+		log.Printf("Failed to embed knowledge query, matching words instead: %v", err)
+	}
+	return s.knowledgeRepo.SearchByText(ctx, officeID, query, limit)
+}
+
+// Run embeds new knowledge chunks every minute until ctx is cancelled.
+// Without an embedder it returns at once.
+func (s *KnowledgeService) Run(ctx context.Context) {
+	if s.embedder == nil {
+		return
+	}
+	ticker := time.NewTicker(knowledgeEmbedInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.EmbedPending(ctx); err != nil {
+				log.Printf("Failed to embed knowledge chunks: %v", err)
+			}
+		}
+	}
+}
+
+// EmbedPending embeds a batch of the chunks without an embedding, stopping
+// at the first one the embedder fails on
+func (s *KnowledgeService) EmbedPending(ctx context.Context) error {
+	chunks, err := s.knowledgeRepo.GetUnembedded(ctx, knowledgeEmbedBatch)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		embedding, err := s.embedder.Embed(ctx, chunk.Content)
+		if err != nil {
+			return fmt.Errorf("chunk %s: %w", chunk.ID, err)
+		}
+		// The document may have been deleted meanwhile
+		if err := s.knowledgeRepo.SetEmbedding(ctx, chunk.ID, embedding); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkKnowledge splits content into chunks of whole paragraphs of at most
+// knowledgeChunkRunes characters; longer paragraphs are split at whitespace
+func chunkKnowledge(content string) []string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var chunks []string
+	var current strings.Builder
+	currentRunes := 0
+	flush := func() {
+		if currentRunes > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentRunes = 0
+		}
+	}
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		for _, piece := range splitLongParagraph(strings.TrimSpace(paragraph)) {
+			runes := utf8.RuneCountInString(piece)
+			if currentRunes > 0 && currentRunes+2+runes > knowledgeChunkRunes {
+				flush()
+			}
+			if currentRunes > 0 {
+				current.WriteString("\n\n")
+				currentRunes += 2
+			}
+			current.WriteString(piece)
+			currentRunes += runes
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitLongParagraph splits a paragraph longer than knowledgeChunkRunes at
+// the last whitespace before the limit, or at the limit when there is none
+// in its second half
+func splitLongParagraph(paragraph string) []string {
+	if paragraph == "" {
+		return nil
+	}
+	runes := []rune(paragraph)
+	var pieces []string
+	for len(runes) > knowledgeChunkRunes {
+		cut := knowledgeChunkRunes
+		for i := knowledgeChunkRunes; i > knowledgeChunkRunes/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+		pieces = append(pieces, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		pieces = append(pieces, string(runes))
+	}
+	return pieces
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestChunkKnowledgeKeepsParagraphsWithinTheLimit(t *testing.T) {
+	short := "Our brand voice is friendly and direct."
+	long := strings.Repeat("word ", knowledgeChunkRunes/4)
+	chunks := chunkKnowledge(short + "\r\n\r\n\n\n" + short + "\n\n" + long)
+
+	if len(chunks) != 3 {
+		t.Fatalf("chunkKnowledge = %d chunks, want the short paragraphs together and the long one split in two", len(chunks))
+	}
+	if chunks[0] != short+"\n\n"+short {
+		t.Errorf("first chunk = %q, want both short paragraphs", chunks[0])
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > knowledgeChunkRunes || strings.TrimSpace(chunk) != chunk {
+			t.Errorf("chunk %d has %d characters or untrimmed space, want at most %d", i, n, knowledgeChunkRunes)
+		}
+	}
+	if !strings.HasSuffix(chunks[1], " word") {
+		t.Errorf("long paragraph split inside a word: %q", chunks[1][len(chunks[1])-10:])
+	}
+}
+
+func TestCreateDocumentTitlesFilesByName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	knowledge := mocks.NewMockKnowledgeRepository(ctrl)
+	svc := NewKnowledgeService(knowledge, nil)
+	officeID := uuid.New()
+
+	knowledge.EXPECT().CountByOfficeID(gomock.Any(), officeID).Return(3, nil)
+	knowledge.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Len(1)).Return(nil)
+	document, err := svc.CreateDocument(context.Background(), CreateKnowledgeInput{
+		OfficeID: officeID,
+		Content:  "# Style guide\n\nWrite in British English.",
+		Filename: "docs/style-guide.md",
+	})
+	if err != nil {
+		t.Fatalf("CreateDocument: %v", err)
+	}
+	if document.Title != "style-guide" || document.Source != domain.KnowledgeSourceFile || document.ChunkCount != 1 {
+		t.Errorf("CreateDocument = %+v, want a one chunk file titled by its name", document)
+	}
+
+	_, err = svc.CreateDocument(context.Background(), CreateKnowledgeInput{OfficeID: officeID, Title: "Logo", Content: "\x89PNG\x00"})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("CreateDocument of binary content error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRelevantKnowledgeFallsBackToWords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	knowledge := mocks.NewMockKnowledgeRepository(ctrl)
+	embedder := mocks.NewMockEmbedder(ctrl)
+	svc := NewKnowledgeService(knowledge, embedder)
+	officeID := uuid.New()
+
+	embedder.EXPECT().Embed(gomock.Any(), "Draft the launch email").Return(nil, errors.New("embeddings: api returned 503"))
+	knowledge.EXPECT().SearchByText(gomock.Any(), officeID, "Draft the launch email", 5).Return(nil, nil)
+	if _, err := svc.Relevant(context.Background(), officeID, " Draft the launch email ", 5); err != nil {
+		t.Fatalf("Relevant: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// learningStatsFlushInterval is how often queued agents have their stats recomputed
+const learningStatsFlushInterval = 2 * time.Second
+
+// LearningStatsService keeps per-agent learning stats up to date in the background
+type LearningStatsService struct {
+	statsRepo domain.LearningStatsRepository
+	agentRepo domain.AgentRepository
+	queue     chan uuid.UUID
+}
+
+// NewLearningStatsService creates a new LearningStatsService instance
+func NewLearningStatsService(statsRepo domain.LearningStatsRepository, agentRepo domain.AgentRepository) *LearningStatsService {
+	return &LearningStatsService{
+		statsRepo: statsRepo,
+		agentRepo: agentRepo,
+		queue:     make(chan uuid.UUID, 1024),
+	}
+}
+
+// MarkDirty queues an agent for recomputation after a feedback, memory or task event.
+// It never blocks; if the queue is full the update is dropped and picked up on the next event.
+func (s *LearningStatsService) MarkDirty(agentID uuid.UUID) {
+	select {
+	case s.queue <- agentID:
+	default:
+		log.Printf("Learning stats queue full, skipping refresh for agent %s", agentID)
+	}
+}
+
+// Run processes queued agents until ctx is cancelled, coalescing repeated
+// events for the same agent into a single recomputation per flush interval
+func (s *LearningStatsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(learningStatsFlushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[uuid.UUID]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(context.Background(), pending)
+			return
+		case agentID := <-s.queue:
+			pending[agentID] = struct{}{}
+		case <-ticker.C:
+			s.flush(ctx, pending)
+		}
+	}
+}
+
+// flush recomputes stats for every pending agent and clears the set
+func (s *LearningStatsService) flush(ctx context.Context, pending map[uuid.UUID]struct{}) {
+	for agentID := range pending {
+		if err := s.statsRepo.Refresh(ctx, agentID); err != nil {
+			log.Printf("Failed to refresh learning stats for agent %s: %v", agentID, err)
+		}
+		delete(pending, agentID)
+	}
+}
+
+// GetStats returns the learning stats of an agent in the given office,
+// computing them on first access
+func (s *LearningStatsService) GetStats(ctx context.Context, officeID, agentID uuid.UUID) (*domain.AgentLearningStats, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.statsRepo.GetByAgentID(ctx, agentID)
+	if err == domain.ErrNotFound {
+		if err := s.statsRepo.Refresh(ctx, agentID); err != nil {
+			return nil, err
+		}
+		return s.statsRepo.GetByAgentID(ctx, agentID)
+	}
+	return stats, err
+}
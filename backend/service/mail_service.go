@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// mailPollInterval is how often the mail worker looks for due emails
+	// when no new ones are queued
+	mailPollInterval = 15 * time.Second
+	mailBatchSize    = 20
+	// mailSendTimeout bounds one delivery; mailSendLease, longer, is how
+	// long a claimed email is left to its worker before another retries it
+	mailSendTimeout = time.Minute
+	mailSendLease   = 2 * time.Minute
+	// mailMaxAttempts is how often delivery is tried before an email is
+	// given up on
+	mailMaxAttempts = 8
+	// mailRetryBaseDelay is the delay before the first retry; each further
+	// retry doubles it up to mailRetryMaxDelay
+	mailRetryBaseDelay = 30 * time.Second
+	mailRetryMaxDelay  = time.Hour
+)
+
+// MailService queues outgoing email in the outbox and delivers it through
+// the mail provider in the background, retrying with backoff when the
+// provider fails. It is the domain.Mailer the other services send with.
+type MailService struct {
+	outboxRepo domain.EmailOutboxRepository
+	provider   domain.Mailer
+	// wake tells Run that an email was queued
+	wake chan struct{}
+}
+
+// NewMailService creates a new MailService delivering through provider
+func NewMailService(outboxRepo domain.EmailOutboxRepository, provider domain.Mailer) *MailService {
+	return &MailService{
+		outboxRepo: outboxRepo,
+		provider:   provider,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Send queues the email for delivery. It only fails if the email cannot be
+// queued; delivery failures are retried and logged.
+func (s *MailService) Send(ctx context.Context, email domain.Email) error {
+	if email.To == "" {
+		return errors.New("mail: recipient is required")
+	}
+
+	now := time.Now()
+	err := s.outboxRepo.Create(ctx, &domain.OutboxEmail{
+		ID:            uuid.New(),
+		Email:         email,
+		Status:        domain.OutboxEmailPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers queued emails until ctx is cancelled
+func (s *MailService) Run(ctx context.Context) {
+	ticker := time.NewTicker(mailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.deliverDue(ctx)
+	}
+}
+
+// deliverDue delivers due emails in batches until none are left
+func (s *MailService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		emails, err := s.outboxRepo.ClaimDue(ctx, time.Now(), mailSendLease, mailBatchSize)
+		if err != nil {
+			log.Printf("Failed to load queued emails: %v", err)
+			return
+		}
+
+		for _, email := range emails {
+			s.deliver(ctx, email)
+		}
+		if len(emails) < mailBatchSize {
+			return
+		}
+	}
+}
+
+// deliver sends one claimed email and records the outcome
+func (s *MailService) deliver(ctx context.Context, email *domain.OutboxEmail) {
+	sendCtx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	err := s.provider.Send(sendCtx, email.Email)
+	cancel()
+
+	if err == nil {
+		if err := s.outboxRepo.MarkSent(ctx, email.ID); err != nil {
+			log.Printf("Failed to mark email %s sent: %v", email.ID, err)
+		}
+		return
+	}
+
+	var nextAttemptAt *time.Time
+	if email.Attempts < mailMaxAttempts {
+		next := time.Now().Add(mailRetryDelay(email.Attempts))
+		nextAttemptAt = &next
+		log.Printf("Failed to send %s email %s (attempt %d), retrying at %s: %v",
+			email.Email.Template, email.ID, email.Attempts, next.Format(time.RFC3339), err)
+	} else {
+		log.Printf("Giving up on %s email %s after %d attempts: %v", email.Email.Template, email.ID, email.Attempts, err)
+	}
+
+	if err := s.outboxRepo.MarkFailed(ctx, email.ID, err.Error(), nextAttemptAt); err != nil {
+		log.Printf("Failed to record failure of email %s: %v", email.ID, err)
+	}
+}
+
+// mailRetryDelay returns the backoff before the retry following the given
+// attempt
+func mailRetryDelay(attempt int) time.Duration {
+	delay := mailRetryBaseDelay
+	for i := 1; i < attempt && delay < mailRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, mailRetryMaxDelay)
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// Bundle limits
+const (
+	MinBundleTemplates = 2
+	MaxBundleTemplates = 10
+)
+
+// BundleInput contains the author-editable fields of a bundle. Nil fields
+// are left unchanged on update.
+type BundleInput struct {
+	Name        *string     `json:"name"`
+	Description *string     `json:"description"`
+	PriceCents  *int        `json:"price_cents"`
+	TemplateIDs []uuid.UUID `json:"template_ids"`
+	IsPublic    *bool       `json:"is_public"`
+}
+
+// CreateBundle puts several of the author's premium templates on sale
+// together. Bundles whose text content moderation flags are saved unlisted.
+func (s *MarketplaceService) CreateBundle(ctx context.Context, authorID uuid.UUID, input BundleInput) (*domain.TemplateBundle, error) {
+	author, err := s.profileRepo.GetByUserID(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	bundle := &domain.TemplateBundle{
+		ID:          uuid.New(),
+		AuthorID:    authorID,
+		AuthorName:  author.DisplayName,
+		IsPublic:    true,
+		TemplateIDs: []uuid.UUID{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	applyBundleInput(bundle, input)
+
+	if err := s.prepareBundle(ctx, bundle); err != nil {
+		return nil, err
+	}
+	if err := s.bundleRepo.Create(ctx, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// UpdateBundle edits a bundle owned by the author. Its templates and price
+// are checked again, so a bundle unlisted by changes to its templates is
+// listed again once it is fixed.
+func (s *MarketplaceService) UpdateBundle(ctx context.Context, authorID, bundleID uuid.UUID, input BundleInput) (*domain.TemplateBundle, error) {
+	bundle, err := s.bundleRepo.GetByID(ctx, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.AuthorID != authorID {
+		return nil, domain.ErrTemplateNotOwned
+	}
+
+	applyBundleInput(bundle, input)
+	bundle.UpdatedAt = time.Now()
+
+	if err := s.prepareBundle(ctx, bundle); err != nil {
+		return nil, err
+	}
+	if err := s.bundleRepo.Update(ctx, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// GetAuthorBundles returns every bundle of an author, including unlisted ones
+func (s *MarketplaceService) GetAuthorBundles(ctx context.Context, authorID uuid.UUID) ([]domain.TemplateBundle, error) {
+	return s.bundleRepo.GetByAuthor(ctx, authorID)
+}
+
+// ListBundles returns a page of the bundles on sale
+func (s *MarketplaceService) ListBundles(ctx context.Context, filter domain.BundleFilter) ([]domain.TemplateBundle, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	return s.bundleRepo.List(ctx, filter)
+}
+
+// GetBundleDetails returns a bundle on sale with its templates
+func (s *MarketplaceService) GetBundleDetails(ctx context.Context, id uuid.UUID) (*domain.TemplateBundle, error) {
+	bundle, err := s.bundleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Unlisted bundles are only visible to their author via /author/bundles
+	if !bundle.Listed {
+		return nil, domain.ErrNotFound
+	}
+	return bundle, nil
+}
+
+// GetFeaturedBundles returns featured bundles
+func (s *MarketplaceService) GetFeaturedBundles(ctx context.Context) ([]domain.TemplateBundle, error) {
+	featured := true
+	bundles, _, err := s.bundleRepo.List(ctx, domain.BundleFilter{
+		IsFeatured: &featured,
+		Limit:      10,
+	})
+	return bundles, err
+}
+
+// SearchBundles searches bundles by query
+func (s *MarketplaceService) SearchBundles(ctx context.Context, query string, limit int) ([]domain.TemplateBundle, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	bundles, _, err := s.bundleRepo.List(ctx, domain.BundleFilter{
+		Search: query,
+		Limit:  limit,
+	})
+	return bundles, err
+}
+
+// applyBundleInput copies the provided fields onto a bundle
+func applyBundleInput(b *domain.TemplateBundle, input BundleInput) {
+	if input.Name != nil {
+		b.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		b.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.PriceCents != nil {
+		b.PriceCents = *input.PriceCents
+	}
+	if input.TemplateIDs != nil {
+		b.TemplateIDs = input.TemplateIDs
+	}
+	if input.IsPublic != nil {
+		b.IsPublic = *input.IsPublic
+	}
+}
+
+// prepareBundle checks that a bundle only holds the author's approved
+// premium templates and is cheaper than they are separately, loads the
+// templates onto it and screens its text
+func (s *MarketplaceService) prepareBundle(ctx context.Context, b *domain.TemplateBundle) error {
+	switch {
+	case b.Name == "" || len(b.Name) > MaxTemplateNameLength:
+		return fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, MaxTemplateNameLength)
+	case len(b.TemplateIDs) < MinBundleTemplates || len(b.TemplateIDs) > MaxBundleTemplates:
+		return fmt.Errorf("%w: a bundle holds %d to %d templates", domain.ErrInvalidInput, MinBundleTemplates, MaxBundleTemplates)
+	}
+
+	templates := make([]domain.AgentTemplate, 0, len(b.TemplateIDs))
+	listPrice := 0
+	seen := map[uuid.UUID]bool{}
+	for _, id := range b.TemplateIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: template %s is listed twice", domain.ErrInvalidInput, id)
+		}
+		seen[id] = true
+
+		t, err := s.marketplaceRepo.GetTemplateByID(ctx, id)
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("%w: template %s not found", domain.ErrInvalidInput, id)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.AuthorID == nil || *t.AuthorID != b.AuthorID:
+			return domain.ErrTemplateNotOwned
+		case t.Status != "approved" || !t.IsPublic:
+			return fmt.Errorf("%w: %s must be approved and public to be bundled", domain.ErrInvalidInput, t.Name)
+		case t.PriceCents < MinPriceCents:
+			return fmt.Errorf("%w: %s is free; bundles only hold premium templates", domain.ErrInvalidInput, t.Name)
+		}
+		templates = append(templates, *t)
+		listPrice += t.PriceCents
+	}
+
+	if b.PriceCents < MinPriceCents || b.PriceCents >= listPrice {
+		return domain.WithDetails(
+			fmt.Errorf("%w: price_cents must be at least %d and below the %d the templates cost separately",
+				domain.ErrInvalidInput, MinPriceCents, listPrice),
+			map[string]any{"list_price_cents": listPrice},
+		)
+	}
+
+	flagged, err := s.moderation.Screen(ctx, ModerationTarget{
+		Type:     domain.ModerationContentTemplate,
+		Content:  strings.Join([]string{b.Name, b.Description}, "\n\n"),
+		EntityID: b.ID,
+		UserID:   b.AuthorID,
+	})
+	if err != nil {
+		return err
+	}
+	if flagged {
+		b.IsPublic = false
+	}
+
+	b.Templates = templates
+	b.ListPriceCents = listPrice
+	b.Listed = b.IsPublic
+	return nil
+}
@@ -2,22 +2,60 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
-	"github.com/denys89/syn-office/backend/repository"
 	"github.com/google/uuid"
 )
 
 type MarketplaceService struct {
-	marketplaceRepo *repository.MarketplaceRepository
+	marketplaceRepo domain.MarketplaceRepository
+	profileRepo     domain.AuthorProfileRepository
+	versionRepo     domain.TemplateVersionRepository
+	bundleRepo      domain.TemplateBundleRepository
+	txManager       domain.TxManager
+	moderation      *ContentModerationService
+	favorites       *FavoriteService
+	review          TemplateReviewConfig
 }
 
-func NewMarketplaceService(marketplaceRepo *repository.MarketplaceRepository) *MarketplaceService {
-	return &MarketplaceService{marketplaceRepo: marketplaceRepo}
+// TemplateReviewConfig decides which template submissions skip admin review
+type TemplateReviewConfig struct {
+	// AutoApprove publishes submissions without admin review, unless
+	// content moderation flagged them or their risk score is above
+	// RiskThreshold
+	AutoApprove   bool
+	RiskThreshold int
+}
+
+func NewMarketplaceService(
+	marketplaceRepo domain.MarketplaceRepository,
+	profileRepo domain.AuthorProfileRepository,
+	versionRepo domain.TemplateVersionRepository,
+	bundleRepo domain.TemplateBundleRepository,
+	txManager domain.TxManager,
+	moderation *ContentModerationService,
+	favorites *FavoriteService,
+	review TemplateReviewConfig,
+) *MarketplaceService {
+	return &MarketplaceService{
+		marketplaceRepo: marketplaceRepo,
+		profileRepo:     profileRepo,
+		versionRepo:     versionRepo,
+		bundleRepo:      bundleRepo,
+		txManager:       txManager,
+		moderation:      moderation,
+		favorites:       favorites,
+		review:          review,
+	}
 }
 
 // ListAgents returns agents with marketplace filtering
-func (s *MarketplaceService) ListAgents(ctx context.Context, filter repository.MarketplaceFilter) ([]domain.AgentTemplate, int, error) {
+func (s *MarketplaceService) ListAgents(ctx context.Context, filter domain.MarketplaceFilter) ([]domain.AgentTemplate, int, error) {
 	// Set defaults
 	if filter.Limit <= 0 {
 		filter.Limit = 20
@@ -28,15 +66,23 @@ func (s *MarketplaceService) ListAgents(ctx context.Context, filter repository.M
 	return s.marketplaceRepo.ListTemplates(ctx, filter)
 }
 
-// GetAgentDetails returns a single agent template by ID
+// GetAgentDetails returns a single published agent template by ID
 func (s *MarketplaceService) GetAgentDetails(ctx context.Context, id uuid.UUID) (*domain.AgentTemplate, error) {
-	return s.marketplaceRepo.GetTemplateByID(ctx, id)
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Unmoderated submissions are only visible to their author via /author/templates
+	if template.Status != "approved" {
+		return nil, domain.ErrNotFound
+	}
+	return template, nil
 }
 
 // GetFeaturedAgents returns featured agents
 func (s *MarketplaceService) GetFeaturedAgents(ctx context.Context) ([]domain.AgentTemplate, error) {
 	featured := true
-	templates, _, err := s.marketplaceRepo.ListTemplates(ctx, repository.MarketplaceFilter{
+	templates, _, err := s.marketplaceRepo.ListTemplates(ctx, domain.MarketplaceFilter{
 		IsFeatured: &featured,
 		Limit:      10,
 	})
@@ -53,7 +99,7 @@ func (s *MarketplaceService) SearchAgents(ctx context.Context, query string, lim
 	if limit <= 0 {
 		limit = 20
 	}
-	templates, _, err := s.marketplaceRepo.ListTemplates(ctx, repository.MarketplaceFilter{
+	templates, _, err := s.marketplaceRepo.ListTemplates(ctx, domain.MarketplaceFilter{
 		Search: query,
 		Limit:  limit,
 	})
@@ -83,15 +129,524 @@ func (s *MarketplaceService) AddReview(ctx context.Context, userID, templateID u
 	return s.marketplaceRepo.CreateReview(ctx, review)
 }
 
-// GetReviews returns reviews for a template
-func (s *MarketplaceService) GetReviews(ctx context.Context, templateID uuid.UUID, limit, offset int) ([]domain.AgentReview, error) {
+// UpdateReview edits a review written by the user
+func (s *MarketplaceService) UpdateReview(ctx context.Context, userID, templateID, reviewID uuid.UUID, rating int, title, text string) (*domain.AgentReview, error) {
+	if rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("%w: rating must be between 1 and 5", domain.ErrInvalidInput)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: review_text is required", domain.ErrInvalidInput)
+	}
+
+	review, err := s.getOwnReview(ctx, userID, templateID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rating = rating
+	review.Title = title
+	review.ReviewText = text
+	if err := s.marketplaceRepo.UpdateReview(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// DeleteReview removes a review written by the user
+func (s *MarketplaceService) DeleteReview(ctx context.Context, userID, templateID, reviewID uuid.UUID) error {
+	if _, err := s.getOwnReview(ctx, userID, templateID, reviewID); err != nil {
+		return err
+	}
+	return s.marketplaceRepo.DeleteReview(ctx, reviewID)
+}
+
+// getOwnReview loads a review on the template and checks the user wrote it
+func (s *MarketplaceService) getOwnReview(ctx context.Context, userID, templateID, reviewID uuid.UUID) (*domain.AgentReview, error) {
+	review, err := s.getTemplateReview(ctx, templateID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != userID {
+		return nil, domain.ErrForbidden
+	}
+	return review, nil
+}
+
+// GetReviews returns a page of reviews for a template along with the total review count
+func (s *MarketplaceService) GetReviews(ctx context.Context, templateID uuid.UUID, limit, offset int) ([]domain.AgentReview, int, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	return s.marketplaceRepo.GetReviews(ctx, templateID, limit, offset)
+	reviews, err := s.marketplaceRepo.GetReviews(ctx, templateID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.marketplaceRepo.CountReviews(ctx, templateID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
+
+// MaxReviewReplyLength caps the size of an author reply
+const MaxReviewReplyLength = 2000
+
+// VoteReview records whether a user found a review helpful
+func (s *MarketplaceService) VoteReview(ctx context.Context, userID, templateID, reviewID uuid.UUID, helpful bool) error {
+	review, err := s.getTemplateReview(ctx, templateID, reviewID)
+	if err != nil {
+		return err
+	}
+	if review.UserID == userID {
+		return fmt.Errorf("%w: you cannot vote on your own review", domain.ErrInvalidInput)
+	}
+	return s.marketplaceRepo.VoteReview(ctx, reviewID, userID, helpful)
+}
+
+// RemoveReviewVote withdraws a user's vote on a review
+func (s *MarketplaceService) RemoveReviewVote(ctx context.Context, userID, templateID, reviewID uuid.UUID) error {
+	if _, err := s.getTemplateReview(ctx, templateID, reviewID); err != nil {
+		return err
+	}
+	return s.marketplaceRepo.DeleteReviewVote(ctx, reviewID, userID)
+}
+
+// ReplyToReview posts the template author's reply to a review. Each review
+// accepts a single reply; use UpdateReviewReply to change it.
+func (s *MarketplaceService) ReplyToReview(ctx context.Context, authorID, templateID, reviewID uuid.UUID, text string) (*domain.ReviewReply, error) {
+	text, err := s.authorizeReply(ctx, authorID, templateID, reviewID, text)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reply := &domain.ReviewReply{
+		ID:        uuid.New(),
+		ReviewID:  reviewID,
+		AuthorID:  authorID,
+		ReplyText: text,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.marketplaceRepo.CreateReviewReply(ctx, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// UpdateReviewReply edits the author's existing reply to a review
+func (s *MarketplaceService) UpdateReviewReply(ctx context.Context, authorID, templateID, reviewID uuid.UUID, text string) (*domain.ReviewReply, error) {
+	text, err := s.authorizeReply(ctx, authorID, templateID, reviewID, text)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &domain.ReviewReply{
+		ReviewID:  reviewID,
+		ReplyText: text,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.marketplaceRepo.UpdateReviewReply(ctx, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// authorizeReply checks that the user authored the reviewed template and returns the trimmed reply text
+func (s *MarketplaceService) authorizeReply(ctx context.Context, authorID, templateID, reviewID uuid.UUID, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > MaxReviewReplyLength {
+		return "", fmt.Errorf("%w: reply_text is required and must be at most %d characters", domain.ErrInvalidInput, MaxReviewReplyLength)
+	}
+
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return "", err
+	}
+	if template.AuthorID == nil || *template.AuthorID != authorID {
+		return "", domain.ErrTemplateNotOwned
+	}
+
+	if _, err := s.getTemplateReview(ctx, templateID, reviewID); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// getTemplateReview loads a review and checks it belongs to the template in the URL
+func (s *MarketplaceService) getTemplateReview(ctx context.Context, templateID, reviewID uuid.UUID) (*domain.AgentReview, error) {
+	review, err := s.marketplaceRepo.GetReviewByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.TemplateID != templateID {
+		return nil, domain.ErrNotFound
+	}
+	return review, nil
 }
 
 // IncrementDownload increments download count when agent is added to office
 func (s *MarketplaceService) IncrementDownload(ctx context.Context, templateID uuid.UUID) error {
 	return s.marketplaceRepo.IncrementDownload(ctx, templateID)
 }
+
+// =============================================================================
+// Author Template Submission
+// =============================================================================
+
+// Template submission limits
+const (
+	MaxTemplateNameLength = 100
+	MaxTemplateRoleLength = 100
+	MaxTemplateSkillTags  = 10
+)
+
+// TemplateInput contains the author-editable fields of a template.
+// Nil fields are left unchanged on update.
+type TemplateInput struct {
+	Name         *string  `json:"name"`
+	Role         *string  `json:"role"`
+	SystemPrompt *string  `json:"system_prompt"`
+	Description  *string  `json:"description"`
+	Category     *string  `json:"category"`
+	AvatarURL    *string  `json:"avatar_url"`
+	PriceCents   *int     `json:"price_cents"`
+	SkillTags    []string `json:"skill_tags"`
+	// License replaces the template's license; submissions without one get
+	// the team license
+	License *domain.TemplateLicense `json:"license"`
+}
+
+// SubmitTemplate creates a new template authored by the user, pending moderation
+func (s *MarketplaceService) SubmitTemplate(ctx context.Context, authorID uuid.UUID, input TemplateInput) (*domain.AgentTemplate, error) {
+	author, err := s.profileRepo.GetByUserID(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &domain.AgentTemplate{
+		ID:         uuid.New(),
+		AuthorID:   &authorID,
+		AuthorName: author.DisplayName,
+		Category:   "general",
+		IsPublic:   true,
+		SkillTags:  []string{},
+		Version:    "1.0.0",
+		Status:     "pending",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		License:    domain.TemplateLicense{Type: domain.LicenseTeam},
+	}
+	applyTemplateInput(template, input)
+
+	if err := s.validateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	if err := s.reviewTemplate(ctx, authorID, template); err != nil {
+		return nil, err
+	}
+
+	if err := s.marketplaceRepo.CreateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// UpdateTemplate edits a template owned by the author. Any edit sends the
+// template back to pending so it is re-moderated before being listed again.
+func (s *MarketplaceService) UpdateTemplate(ctx context.Context, authorID, templateID uuid.UUID, input TemplateInput) (*domain.AgentTemplate, error) {
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.AuthorID == nil || *template.AuthorID != authorID {
+		return nil, domain.ErrTemplateNotOwned
+	}
+
+	applyTemplateInput(template, input)
+	template.Status = "pending"
+	template.UpdatedAt = time.Now()
+
+	if err := s.validateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	if err := s.reviewTemplate(ctx, authorID, template); err != nil {
+		return nil, err
+	}
+
+	if err := s.marketplaceRepo.UpdateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	s.favorites.NotifyTemplateUpdated(ctx, template)
+	return template, nil
+}
+
+// GetAuthorTemplates returns every template submitted by an author, including pending and rejected ones
+func (s *MarketplaceService) GetAuthorTemplates(ctx context.Context, authorID uuid.UUID) ([]domain.AgentTemplate, error) {
+	return s.marketplaceRepo.GetTemplatesByAuthor(ctx, authorID)
+}
+
+// =============================================================================
+// Template Versioning
+// =============================================================================
+
+// MaxChangelogLength caps the size of a version changelog
+const MaxChangelogLength = 5000
+
+// PublishVersionInput describes a new template release. Only the fields that
+// define agent behaviour are versioned; nil fields keep their current value.
+type PublishVersionInput struct {
+	Version      string   `json:"version"`
+	Changelog    string   `json:"changelog"`
+	SystemPrompt *string  `json:"system_prompt"`
+	SkillTags    []string `json:"skill_tags"`
+	// License replaces the template's license from this version on
+	License *domain.TemplateLicense `json:"license"`
+}
+
+// PublishVersion releases a new version of an approved template. The version
+// is staged until it is moderated, while the approved version stays listed
+// and hireable; versions approved automatically go live right away. The
+// current version is snapshotted first so agents pinned to it keep working
+// unchanged.
+func (s *MarketplaceService) PublishVersion(ctx context.Context, authorID, templateID uuid.UUID, input PublishVersionInput) (*domain.TemplateVersion, error) {
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.AuthorID == nil || *template.AuthorID != authorID {
+		return nil, domain.ErrTemplateNotOwned
+	}
+	if template.Status != "approved" {
+		return nil, fmt.Errorf("%w: only approved templates can publish a new version", domain.ErrInvalidInput)
+	}
+	if _, err := s.versionRepo.GetPending(ctx, templateID); err == nil {
+		return nil, fmt.Errorf("%w: a version of the template is already awaiting review", domain.ErrAlreadyExists)
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	input.Version = strings.TrimSpace(input.Version)
+	input.Changelog = strings.TrimSpace(input.Changelog)
+	newer, err := isNewerVersion(input.Version, template.Version)
+	if err != nil {
+		return nil, err
+	}
+	if !newer {
+		return nil, fmt.Errorf("%w: version must be greater than %s", domain.ErrInvalidInput, template.Version)
+	}
+	if input.Changelog == "" || len(input.Changelog) > MaxChangelogLength {
+		return nil, fmt.Errorf("%w: changelog is required and must be at most %d characters", domain.ErrInvalidInput, MaxChangelogLength)
+	}
+
+	now := time.Now()
+	next := *template
+	applyTemplateInput(&next, TemplateInput{SystemPrompt: input.SystemPrompt, SkillTags: input.SkillTags, License: input.License})
+	next.Version = input.Version
+	next.Status = "pending"
+	next.UpdatedAt = now
+
+	if err := s.validateTemplate(ctx, &next); err != nil {
+		return nil, err
+	}
+	if err := s.reviewTemplate(ctx, authorID, &next); err != nil {
+		return nil, err
+	}
+
+	version := newTemplateVersion(&next, input.Changelog, now)
+	if next.Status != "approved" {
+		version.Status = "pending"
+		if err := s.versionRepo.Create(ctx, version); err != nil {
+			return nil, err
+		}
+		return version, nil
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := goLiveWithVersion(ctx, s.marketplaceRepo, s.versionRepo, template, &next); err != nil {
+			return err
+		}
+		return s.versionRepo.Create(ctx, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.favorites.NotifyTemplateUpdated(ctx, &next)
+	return version, nil
+}
+
+// goLiveWithVersion replaces the live template with next, snapshotting the
+// current version first. Call it within a transaction together with
+// recording next's version: the template only changes together with its
+// version history.
+func goLiveWithVersion(
+	ctx context.Context,
+	marketplaceRepo domain.MarketplaceRepository,
+	versionRepo domain.TemplateVersionRepository,
+	template, next *domain.AgentTemplate,
+) error {
+	previous := newTemplateVersion(template, "", next.UpdatedAt)
+	if err := versionRepo.Create(ctx, previous); err != nil && !errors.Is(err, domain.ErrAlreadyExists) {
+		return err
+	}
+	return marketplaceRepo.UpdateTemplate(ctx, next)
+}
+
+// GetTemplateVersions returns the approved versions of a published template
+func (s *MarketplaceService) GetTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]*domain.TemplateVersion, error) {
+	if _, err := s.GetAgentDetails(ctx, templateID); err != nil {
+		return nil, err
+	}
+	return s.versionRepo.GetByTemplateID(ctx, templateID)
+}
+
+// newTemplateVersion snapshots the versioned fields of a template
+func newTemplateVersion(t *domain.AgentTemplate, changelog string, now time.Time) *domain.TemplateVersion {
+	return &domain.TemplateVersion{
+		ID:           uuid.New(),
+		TemplateID:   t.ID,
+		Version:      t.Version,
+		SystemPrompt: t.SystemPrompt,
+		SkillTags:    t.SkillTags,
+		Changelog:    changelog,
+		CreatedAt:    now,
+		License:      t.License,
+		Status:       "approved",
+		RiskScore:    t.RiskScore,
+		RiskFindings: t.RiskFindings,
+	}
+}
+
+// isNewerVersion reports whether candidate is a greater MAJOR.MINOR.PATCH version than current
+func isNewerVersion(candidate, current string) (bool, error) {
+	next, ok := parseVersion(candidate)
+	if !ok {
+		return false, fmt.Errorf("%w: version must be in MAJOR.MINOR.PATCH format", domain.ErrInvalidInput)
+	}
+	prev, ok := parseVersion(current)
+	if !ok {
+		// Legacy free-form versions are superseded by any valid one
+		return true, nil
+	}
+	for i := range next {
+		if next[i] != prev[i] {
+			return next[i] > prev[i], nil
+		}
+	}
+	return false, nil
+}
+
+// parseVersion parses a MAJOR.MINOR.PATCH version string
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+// applyTemplateInput copies the provided fields onto a template
+func applyTemplateInput(t *domain.AgentTemplate, input TemplateInput) {
+	if input.Name != nil {
+		t.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Role != nil {
+		t.Role = strings.TrimSpace(*input.Role)
+	}
+	if input.SystemPrompt != nil {
+		t.SystemPrompt = strings.TrimSpace(*input.SystemPrompt)
+	}
+	if input.Description != nil {
+		t.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Category != nil {
+		t.Category = strings.TrimSpace(*input.Category)
+	}
+	if input.AvatarURL != nil {
+		t.AvatarURL = strings.TrimSpace(*input.AvatarURL)
+	}
+	if input.PriceCents != nil {
+		t.PriceCents = *input.PriceCents
+		t.IsPremium = t.PriceCents > 0
+	}
+	if input.SkillTags != nil {
+		tags := make([]string, 0, len(input.SkillTags))
+		for _, tag := range input.SkillTags {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		t.SkillTags = tags
+	}
+	if input.License != nil {
+		t.License = *input.License
+	}
+}
+
+// reviewTemplate runs the text of a template submission through content
+// moderation and the security scanner, which sets its risk score, and
+// approves it when it may skip admin review
+func (s *MarketplaceService) reviewTemplate(ctx context.Context, authorID uuid.UUID, t *domain.AgentTemplate) error {
+	flagged, err := s.moderation.Screen(ctx, ModerationTarget{
+		Type:     domain.ModerationContentTemplate,
+		Content:  strings.Join([]string{t.Name, t.Role, t.Description, t.SystemPrompt, t.License.Terms}, "\n\n"),
+		EntityID: t.ID,
+		UserID:   authorID,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.RiskScore, t.RiskFindings = scanTemplateRisk(t)
+	if s.review.AutoApprove && !flagged && t.RiskScore <= s.review.RiskThreshold {
+		t.Status = "approved"
+	}
+	return nil
+}
+
+// validateTemplate checks that a submitted template is complete and priced correctly
+func (s *MarketplaceService) validateTemplate(ctx context.Context, t *domain.AgentTemplate) error {
+	switch {
+	case t.Name == "" || len(t.Name) > MaxTemplateNameLength:
+		return fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, MaxTemplateNameLength)
+	case t.Role == "" || len(t.Role) > MaxTemplateRoleLength:
+		return fmt.Errorf("%w: role is required and must be at most %d characters", domain.ErrInvalidInput, MaxTemplateRoleLength)
+	case t.SystemPrompt == "":
+		return fmt.Errorf("%w: system_prompt is required", domain.ErrInvalidInput)
+	case len(t.SkillTags) > MaxTemplateSkillTags:
+		return fmt.Errorf("%w: at most %d skill tags are allowed", domain.ErrInvalidInput, MaxTemplateSkillTags)
+	case t.PriceCents < 0 || (t.PriceCents > 0 && t.PriceCents < MinPriceCents):
+		return fmt.Errorf("%w: price_cents must be 0 (free) or at least %d", domain.ErrInvalidInput, MinPriceCents)
+	}
+	if err := validateLicense(&t.License); err != nil {
+		return err
+	}
+
+	exists, err := s.marketplaceRepo.CategoryExists(ctx, t.Category)
+	if err != nil {
+		return err
+	}
+	if !exists && t.Category != "general" {
+		return fmt.Errorf("%w: unknown category %q", domain.ErrInvalidInput, t.Category)
+	}
+
+	if t.AuthorID != nil {
+		copied, err := s.marketplaceRepo.IsResaleProhibitedCopy(ctx, t.SystemPrompt, *t.AuthorID)
+		if err != nil {
+			return err
+		}
+		if copied {
+			return fmt.Errorf("%w: the system prompt copies a template whose license prohibits resale", domain.ErrLicenseRequired)
+		}
+	}
+	return nil
+}
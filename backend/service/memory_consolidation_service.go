@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// memoryConsolidationPollInterval is how often memories decay and agents
+	// due for consolidation are looked for
+	memoryConsolidationPollInterval = time.Hour
+	// memoryConsolidationInterval is how long an agent's memories stay
+	// consolidated before they are consolidated again
+	memoryConsolidationInterval = 24 * time.Hour
+	// memoryConsolidationBatch caps how many agents one poll consolidates
+	memoryConsolidationBatch = 100
+)
+
+// MemoryConsolidationConfig tunes memory consolidation
+type MemoryConsolidationConfig struct {
+	// HalfLife is how long a memory takes to lose half its importance; zero
+	// turns decay off
+	HalfLife time.Duration
+	// MaxPerAgent caps the memories an agent keeps, archiving the least
+	// important; zero turns the cap off
+	MaxPerAgent int
+	// DuplicateSimilarity is the cosine similarity from which two memories'
+	// embeddings are taken to say the same
+	DuplicateSimilarity float64
+}
+
+// MemoryConsolidationService keeps agents' memories from piling up in the
+// background: near-duplicates are merged into the most recently updated one,
+// importance decays over time, and the least important memories past the cap
+// are archived. Archived memories are no longer recalled.
+type MemoryConsolidationService struct {
+	memoryRepo    domain.AgentMemoryRepository
+	statsRepo     domain.LearningStatsRepository
+	learningStats *LearningStatsService
+	txManager     domain.TxManager
+	config        MemoryConsolidationConfig
+}
+
+// NewMemoryConsolidationService creates a new MemoryConsolidationService instance
+func NewMemoryConsolidationService(
+	memoryRepo domain.AgentMemoryRepository,
+	statsRepo domain.LearningStatsRepository,
+	learningStats *LearningStatsService,
+	txManager domain.TxManager,
+	config MemoryConsolidationConfig,
+) *MemoryConsolidationService {
+	return &MemoryConsolidationService{
+		memoryRepo:    memoryRepo,
+		statsRepo:     statsRepo,
+		learningStats: learningStats,
+		txManager:     txManager,
+		config:        config,
+	}
+}
+
+// Run decays memories and consolidates the agents that are due every hour
+// until ctx is cancelled
+func (s *MemoryConsolidationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(memoryConsolidationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDue(ctx)
+		}
+	}
+}
+
+// runDue decays every memory and consolidates a batch of the agents not
+// consolidated for a day
+func (s *MemoryConsolidationService) runDue(ctx context.Context) {
+	now := time.Now()
+	if s.config.HalfLife > 0 {
+		if _, err := s.memoryRepo.DecayImportance(ctx, s.config.HalfLife, now); err != nil {
+			log.Printf("Failed to decay memory importance: %v", err)
+		}
+	}
+
+	agentIDs, err := s.memoryRepo.GetConsolidationDue(ctx, now.Add(-memoryConsolidationInterval), memoryConsolidationBatch)
+	if err != nil {
+		log.Printf("Failed to load agents due for memory consolidation: %v", err)
+		return
+	}
+	for _, agentID := range agentIDs {
+		if err := s.Consolidate(ctx, agentID); err != nil {
+			log.Printf("Failed to consolidate memories of agent %s: %v", agentID, err)
+		}
+	}
+}
+
+// Consolidate merges the agent's near-duplicate memories and archives those
+// past the cap, then records the consolidation in the agent's learning stats
+func (s *MemoryConsolidationService) Consolidate(ctx context.Context, agentID uuid.UUID) error {
+	memories, err := s.memoryRepo.GetByAgentID(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	pairs, err := s.memoryRepo.FindDuplicates(ctx, agentID, s.config.DuplicateSimilarity)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		for _, group := range duplicateGroups(memories, pairs) {
+			survivor, importance, merged := mergePlan(group)
+			if err := s.memoryRepo.Merge(ctx, survivor, importance, merged, now); err != nil {
+				return err
+			}
+		}
+		if s.config.MaxPerAgent > 0 {
+			if _, err := s.memoryRepo.ArchiveOverCap(ctx, agentID, s.config.MaxPerAgent, now); err != nil {
+				return err
+			}
+		}
+		return s.statsRepo.RecordConsolidation(ctx, agentID, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.learningStats.MarkDirty(agentID)
+	return nil
+}
+
+// duplicateGroups joins memories that are duplicates of each other, directly
+// or through another, into groups of two or more. Pairs with memories that
+// are not among memories are ignored.
+func duplicateGroups(memories []*domain.AgentMemory, pairs []domain.MemoryPair) [][]*domain.AgentMemory {
+	byID := make(map[uuid.UUID]*domain.AgentMemory, len(memories))
+	parent := make(map[uuid.UUID]uuid.UUID, len(memories))
+	for _, m := range memories {
+		byID[m.ID] = m
+		parent[m.ID] = m.ID
+	}
+	var root func(id uuid.UUID) uuid.UUID
+	root = func(id uuid.UUID) uuid.UUID {
+		if parent[id] != id {
+			parent[id] = root(parent[id])
+		}
+		return parent[id]
+	}
+	for _, pair := range pairs {
+		if byID[pair.FirstID] == nil || byID[pair.SecondID] == nil {
+			continue
+		}
+		parent[root(pair.FirstID)] = root(pair.SecondID)
+	}
+
+	members := make(map[uuid.UUID][]*domain.AgentMemory)
+	var roots []uuid.UUID
+	for _, m := range memories {
+		r := root(m.ID)
+		if members[r] == nil {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], m)
+	}
+	var groups [][]*domain.AgentMemory
+	for _, r := range roots {
+		if len(members[r]) > 1 {
+			groups = append(groups, members[r])
+		}
+	}
+	return groups
+}
+
+// mergePlan picks the group's most recently updated memory, the more
+// important among equals, to survive with the group's highest importance
+func mergePlan(group []*domain.AgentMemory) (survivor uuid.UUID, importance float64, merged []uuid.UUID) {
+	best := group[0]
+	for _, m := range group[1:] {
+		if m.UpdatedAt.After(best.UpdatedAt) ||
+			(m.UpdatedAt.Equal(best.UpdatedAt) && m.ImportanceScore > best.ImportanceScore) {
+			best = m
+		}
+	}
+	for _, m := range group {
+		importance = max(importance, m.ImportanceScore)
+		if m != best {
+			merged = append(merged, m.ID)
+		}
+	}
+	return best.ID, importance, merged
+}
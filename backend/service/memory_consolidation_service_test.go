@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDuplicateGroupsJoinsTransitiveDuplicates(t *testing.T) {
+	now := time.Now()
+	a := &domain.AgentMemory{ID: uuid.New(), ImportanceScore: 0.9, UpdatedAt: now.Add(-time.Hour)}
+	b := &domain.AgentMemory{ID: uuid.New(), ImportanceScore: 0.4, UpdatedAt: now}
+	c := &domain.AgentMemory{ID: uuid.New(), ImportanceScore: 0.5, UpdatedAt: now.Add(-2 * time.Hour)}
+	d := &domain.AgentMemory{ID: uuid.New(), ImportanceScore: 0.5, UpdatedAt: now}
+
+	groups := duplicateGroups([]*domain.AgentMemory{a, b, c, d}, []domain.MemoryPair{
+		{FirstID: a.ID, SecondID: b.ID},
+		{FirstID: c.ID, SecondID: b.ID},
+		// an archived memory is not among the agent's memories
+		{FirstID: d.ID, SecondID: uuid.New()},
+	})
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("duplicateGroups = %v, want a, b and c in one group", groups)
+	}
+
+	survivor, importance, merged := mergePlan(groups[0])
+	if survivor != b.ID || importance != 0.9 {
+		t.Errorf("mergePlan = %s with %v, want the most recent memory with the highest importance", survivor, importance)
+	}
+	if !slices.Contains(merged, a.ID) || !slices.Contains(merged, c.ID) || len(merged) != 2 {
+		t.Errorf("mergePlan merged = %v, want a and c", merged)
+	}
+}
+
+func TestConsolidateMergesDuplicatesThenArchivesOverCap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	memories := mocks.NewMockAgentMemoryRepository(ctrl)
+	stats := mocks.NewMockLearningStatsRepository(ctrl)
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+	svc := NewMemoryConsolidationService(memories, stats, NewLearningStatsService(stats, nil), txManager, MemoryConsolidationConfig{
+		MaxPerAgent:         2,
+		DuplicateSimilarity: 0.95,
+	})
+
+	agentID := uuid.New()
+	now := time.Now()
+	older := &domain.AgentMemory{ID: uuid.New(), AgentID: agentID, Key: "Client Tone", ImportanceScore: 0.8, UpdatedAt: now.Add(-time.Hour)}
+	newer := &domain.AgentMemory{ID: uuid.New(), AgentID: agentID, Key: "client_tone", ImportanceScore: 0.3, UpdatedAt: now}
+	memories.EXPECT().GetByAgentID(gomock.Any(), agentID).Return([]*domain.AgentMemory{older, newer}, nil)
+	memories.EXPECT().FindDuplicates(gomock.Any(), agentID, 0.95).Return([]domain.MemoryPair{{FirstID: older.ID, SecondID: newer.ID}}, nil)
+
+	gomock.InOrder(
+		memories.EXPECT().Merge(gomock.Any(), newer.ID, 0.8, []uuid.UUID{older.ID}, gomock.Any()).Return(nil),
+		memories.EXPECT().ArchiveOverCap(gomock.Any(), agentID, 2, gomock.Any()).Return(int64(0), nil),
+		stats.EXPECT().RecordConsolidation(gomock.Any(), agentID, gomock.Any()).Return(nil),
+	)
+
+	if err := svc.Consolidate(context.Background(), agentID); err != nil {
+		t.Fatalf("Consolidate: %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// defaultImportanceScores gives each memory type a starting importance when none is provided
+var defaultImportanceScores = map[string]float64{
+	domain.MemoryTypeCorrection: 0.90,
+	domain.MemoryTypePreference: 0.70,
+	domain.MemoryTypeInsight:    0.60,
+	domain.MemoryTypeFact:       0.50,
+	domain.MemoryTypeTaskResult: 0.40,
+}
+
+const (
+	defaultMemorySearchLimit = 10
+	maxMemorySearchLimit     = 50
+	maxMemoryQueryLength     = 1000
+)
+
+// MemoryService handles agent memory management
+type MemoryService struct {
+	memoryRepo    domain.AgentMemoryRepository
+	agentRepo     domain.AgentRepository
+	learningStats *LearningStatsService
+	embedder      domain.Embedder
+}
+
+// NewMemoryService creates a new MemoryService instance. Without an
+// embedder, searches by query match the memories' text instead of their
+// meaning.
+func NewMemoryService(
+	memoryRepo domain.AgentMemoryRepository,
+	agentRepo domain.AgentRepository,
+	learningStats *LearningStatsService,
+	embedder domain.Embedder,
+) *MemoryService {
+	return &MemoryService{
+		memoryRepo:    memoryRepo,
+		agentRepo:     agentRepo,
+		learningStats: learningStats,
+		embedder:      embedder,
+	}
+}
+
+// CreateMemoryInput represents input for creating an agent memory
+type CreateMemoryInput struct {
+	OfficeID        uuid.UUID
+	AgentID         uuid.UUID
+	Key             string
+	Value           string
+	MemoryType      string
+	ImportanceScore *float64
+	Source          string
+	SourceID        *uuid.UUID
+	Metadata        map[string]any
+}
+
+// UpdateMemoryInput represents the editable fields of an agent memory
+type UpdateMemoryInput struct {
+	Key             *string
+	Value           *string
+	MemoryType      *string
+	ImportanceScore *float64
+	Metadata        map[string]any
+}
+
+// CreateMemory adds a new memory to an agent in the given office
+func (s *MemoryService) CreateMemory(ctx context.Context, input CreateMemoryInput) (*domain.AgentMemory, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID); err != nil {
+		return nil, err
+	}
+
+	memory, err := newMemory(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.memoryRepo.Create(ctx, memory); err != nil {
+		return nil, err
+	}
+	s.learningStats.MarkDirty(memory.AgentID)
+	return memory, nil
+}
+
+// UpsertMemory creates a memory or overwrites the agent's existing memory with the same key
+func (s *MemoryService) UpsertMemory(ctx context.Context, input CreateMemoryInput) (*domain.AgentMemory, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID); err != nil {
+		return nil, err
+	}
+
+	memory, err := newMemory(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.memoryRepo.Upsert(ctx, memory); err != nil {
+		return nil, err
+	}
+	s.learningStats.MarkDirty(memory.AgentID)
+	return memory, nil
+}
+
+// UpdateMemory edits an existing memory belonging to an agent in the given office
+func (s *MemoryService) UpdateMemory(
+	ctx context.Context,
+	officeID uuid.UUID,
+	agentID uuid.UUID,
+	memoryID uuid.UUID,
+	input UpdateMemoryInput,
+) (*domain.AgentMemory, error) {
+	memory, err := s.getAgentMemory(ctx, officeID, agentID, memoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Key != nil {
+		memory.Key = strings.TrimSpace(*input.Key)
+	}
+	if input.Value != nil {
+		memory.Value = strings.TrimSpace(*input.Value)
+	}
+	if input.MemoryType != nil {
+		memory.MemoryType = *input.MemoryType
+	}
+	if input.ImportanceScore != nil {
+		memory.ImportanceScore = *input.ImportanceScore
+	}
+	if input.Metadata != nil {
+		memory.Metadata = input.Metadata
+	}
+
+	if err := validateMemory(memory); err != nil {
+		return nil, err
+	}
+
+	if err := s.memoryRepo.Update(ctx, memory); err != nil {
+		return nil, err
+	}
+	s.learningStats.MarkDirty(memory.AgentID)
+	return memory, nil
+}
+
+// DeleteMemory removes a memory belonging to an agent in the given office
+func (s *MemoryService) DeleteMemory(ctx context.Context, officeID, agentID, memoryID uuid.UUID) error {
+	if _, err := s.getAgentMemory(ctx, officeID, agentID, memoryID); err != nil {
+		return err
+	}
+	if err := s.memoryRepo.Delete(ctx, memoryID); err != nil {
+		return err
+	}
+	s.learningStats.MarkDirty(agentID)
+	return nil
+}
+
+// SearchMemories returns the memories of the office's agent most relevant to
+// the query, at most limit
+func (s *MemoryService) SearchMemories(ctx context.Context, officeID, agentID uuid.UUID, query string, limit int) ([]*domain.MemoryMatch, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+	return s.searchMemories(ctx, agentID, query, nil, limit)
+}
+
+// RetrieveMemories returns the agent's memories most similar to the
+// embedding, or to the query when no embedding is given, for the
+// orchestrator to build a task's context with
+func (s *MemoryService) RetrieveMemories(ctx context.Context, agentID uuid.UUID, query string, embedding []float32, limit int) ([]*domain.MemoryMatch, error) {
+	if _, err := s.agentRepo.GetByID(ctx, agentID); err != nil {
+		return nil, err
+	}
+	return s.searchMemories(ctx, agentID, query, embedding, limit)
+}
+
+// StoreEmbedding saves the embedding the orchestrator made of a memory's
+// key and value, and the ID of its vector in the orchestrator's store
+func (s *MemoryService) StoreEmbedding(ctx context.Context, memoryID uuid.UUID, embedding []float32, vectorID string) error {
+	if err := validateEmbedding(embedding); err != nil {
+		return err
+	}
+	return s.memoryRepo.SetEmbedding(ctx, memoryID, embedding, vectorID)
+}
+
+// searchMemories searches by the embedding if given, else by the embedding
+// of the query, falling back to matching the query's text when it cannot
+// be embedded
+func (s *MemoryService) searchMemories(ctx context.Context, agentID uuid.UUID, query string, embedding []float32, limit int) ([]*domain.MemoryMatch, error) {
+	switch {
+	case limit <= 0:
+		limit = defaultMemorySearchLimit
+	case limit > maxMemorySearchLimit:
+		limit = maxMemorySearchLimit
+	}
+
+	if embedding != nil {
+		if err := validateEmbedding(embedding); err != nil {
+			return nil, err
+		}
+		return s.memoryRepo.SearchByEmbedding(ctx, agentID, embedding, limit)
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" || len(query) > maxMemoryQueryLength {
+		return nil, fmt.Errorf("%w: query is required and must be at most %d characters", domain.ErrInvalidInput, maxMemoryQueryLength)
+	}
+	if s.embedder != nil {
+		embedding, err := s.embedder.Embed(ctx, query)
+		if err == nil {
+			return s.memoryRepo.SearchByEmbedding(ctx, agentID, embedding, limit)
+		}
+		log.Printf("Failed to embed memory query, matching text instead: %v", err)
+	}
+	return s.memoryRepo.SearchByText(ctx, agentID, query, limit)
+}
+
+// validateEmbedding checks an embedding's size and that its values are
+// numbers
+func validateEmbedding(embedding []float32) error {
+	if len(embedding) != domain.MemoryEmbeddingDimensions {
+		return fmt.Errorf("%w: embedding must have %d dimensions", domain.ErrInvalidInput, domain.MemoryEmbeddingDimensions)
+	}
+	for _, v := range embedding {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("%w: embedding values must be finite", domain.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// getAgentMemory loads a memory and verifies it belongs to the office's agent
+func (s *MemoryService) getAgentMemory(ctx context.Context, officeID, agentID, memoryID uuid.UUID) (*domain.AgentMemory, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+
+	memory, err := s.memoryRepo.GetByID(ctx, memoryID)
+	if err != nil {
+		return nil, err
+	}
+	if memory.AgentID != agentID {
+		return nil, domain.ErrNotFound
+	}
+	return memory, nil
+}
+
+// newMemory builds a validated memory from input, applying type and source defaults
+func newMemory(input CreateMemoryInput) (*domain.AgentMemory, error) {
+	memoryType := input.MemoryType
+	if memoryType == "" {
+		memoryType = domain.MemoryTypeFact
+	}
+	source := input.Source
+	if source == "" {
+		source = domain.MemorySourceUser
+	}
+
+	memory := &domain.AgentMemory{
+		ID:         uuid.New(),
+		OfficeID:   input.OfficeID,
+		AgentID:    input.AgentID,
+		Key:        strings.TrimSpace(input.Key),
+		Value:      strings.TrimSpace(input.Value),
+		MemoryType: memoryType,
+		Source:     source,
+		SourceID:   input.SourceID,
+		Metadata:   input.Metadata,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if input.ImportanceScore != nil {
+		memory.ImportanceScore = *input.ImportanceScore
+	} else {
+		memory.ImportanceScore = defaultImportanceScores[memoryType]
+	}
+	if memory.Metadata == nil {
+		memory.Metadata = map[string]any{}
+	}
+
+	if err := validateMemory(memory); err != nil {
+		return nil, err
+	}
+	return memory, nil
+}
+
+// validateMemory checks required fields, memory type and importance range
+func validateMemory(memory *domain.AgentMemory) error {
+	if memory.Key == "" || memory.Value == "" || len(memory.Key) > 255 {
+		return domain.ErrInvalidInput
+	}
+	if _, ok := defaultImportanceScores[memory.MemoryType]; !ok {
+		return domain.ErrInvalidInput
+	}
+	if memory.ImportanceScore < 0 || memory.ImportanceScore > 1 {
+		return domain.ErrInvalidInput
+	}
+	return nil
+}
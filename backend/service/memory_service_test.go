@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSearchMemoriesEmbedsQueryAndFallsBackToText(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	memories := mocks.NewMockAgentMemoryRepository(ctrl)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	embedder := mocks.NewMockEmbedder(ctrl)
+	svc := NewMemoryService(memories, agents, nil, embedder)
+
+	officeID := uuid.New()
+	agent := &domain.Agent{ID: uuid.New(), OfficeID: officeID}
+	agents.EXPECT().GetByID(gomock.Any(), agent.ID).Return(agent, nil).Times(2)
+	embedding := make([]float32, domain.MemoryEmbeddingDimensions)
+
+	embedder.EXPECT().Embed(gomock.Any(), "client tone").Return(embedding, nil)
+	memories.EXPECT().SearchByEmbedding(gomock.Any(), agent.ID, embedding, defaultMemorySearchLimit).Return(nil, nil)
+	if _, err := svc.SearchMemories(context.Background(), officeID, agent.ID, " client tone ", 0); err != nil {
+		t.Fatalf("SearchMemories: %v", err)
+	}
+
+	embedder.EXPECT().Embed(gomock.Any(), "client tone").Return(nil, errors.New("embeddings: api returned 503"))
+	memories.EXPECT().SearchByText(gomock.Any(), agent.ID, "client tone", maxMemorySearchLimit).Return(nil, nil)
+	if _, err := svc.SearchMemories(context.Background(), officeID, agent.ID, "client tone", 500); err != nil {
+		t.Fatalf("SearchMemories with the embeddings API down: %v", err)
+	}
+}
+
+func TestStoreEmbeddingChecksDimensions(t *testing.T) {
+	svc := NewMemoryService(nil, nil, nil, nil)
+	if err := svc.StoreEmbedding(context.Background(), uuid.New(), []float32{0.1, 0.2}, ""); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("StoreEmbedding of 2 dimensions error = %v, want ErrInvalidInput", err)
+	}
+}
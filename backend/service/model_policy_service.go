@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// ModelPolicyService manages the model policies offices route their tasks
+// with. Policies may only name providers the office's tier includes.
+type ModelPolicyService struct {
+	policyRepo          domain.ModelPolicyRepository
+	agentRepo           domain.AgentRepository
+	subscriptionService *SubscriptionService
+}
+
+// NewModelPolicyService creates a new ModelPolicyService instance
+func NewModelPolicyService(
+	policyRepo domain.ModelPolicyRepository,
+	agentRepo domain.AgentRepository,
+	subscriptionService *SubscriptionService,
+) *ModelPolicyService {
+	return &ModelPolicyService{
+		policyRepo:          policyRepo,
+		agentRepo:           agentRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// ListPolicies returns the office's policy, if it has one, followed by the
+// policies of its agents
+func (s *ModelPolicyService) ListPolicies(ctx context.Context, officeID uuid.UUID) ([]*domain.ModelPolicy, error) {
+	policies, err := s.policyRepo.ListByOffice(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = []*domain.ModelPolicy{}
+	}
+	return policies, nil
+}
+
+// GetPolicy returns the office's policy, or with agentID the office agent's
+// own policy
+func (s *ModelPolicyService) GetPolicy(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) (*domain.ModelPolicy, error) {
+	if err := s.ensureAgent(ctx, officeID, agentID); err != nil {
+		return nil, err
+	}
+	return s.policyRepo.Get(ctx, officeID, agentID)
+}
+
+// SetModelPolicyInput contains input for setting a model policy. A nil
+// AgentID sets the office's policy.
+type SetModelPolicyInput struct {
+	OfficeID          uuid.UUID
+	AgentID           *uuid.UUID
+	AllowedProviders  []string
+	PreferredModel    string
+	PreferredProvider string
+	MaxCreditsPerTask *int64
+}
+
+// SetPolicy creates or replaces a model policy after checking it against the
+// office's tier
+func (s *ModelPolicyService) SetPolicy(ctx context.Context, input SetModelPolicyInput) (*domain.ModelPolicy, error) {
+	if err := s.ensureAgent(ctx, input.OfficeID, input.AgentID); err != nil {
+		return nil, err
+	}
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := newModelPolicy(input, features)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policyRepo.Upsert(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// newModelPolicy validates a model policy against the office's tier
+// features and returns it. Providers the tier does not include are
+// ErrFeatureNotAvailable; other invalid policies are ErrInvalidInput.
+func newModelPolicy(input SetModelPolicyInput, features *domain.TierFeatures) (*domain.ModelPolicy, error) {
+	if (input.PreferredModel == "") != (input.PreferredProvider == "") {
+		return nil, fmt.Errorf("%w: preferred model and provider must be set together", domain.ErrInvalidInput)
+	}
+	if input.MaxCreditsPerTask != nil && *input.MaxCreditsPerTask <= 0 {
+		return nil, fmt.Errorf("%w: max credits per task must be positive", domain.ErrInvalidInput)
+	}
+
+	allowed := []string{}
+	for _, provider := range input.AllowedProviders {
+		if !slices.Contains(features.ModelAccess, provider) {
+			return nil, fmt.Errorf("%w: your tier does not include %s models", domain.ErrFeatureNotAvailable, provider)
+		}
+		if !slices.Contains(allowed, provider) {
+			allowed = append(allowed, provider)
+		}
+	}
+	if input.PreferredProvider != "" {
+		if !slices.Contains(features.ModelAccess, input.PreferredProvider) {
+			return nil, fmt.Errorf("%w: your tier does not include %s models", domain.ErrFeatureNotAvailable, input.PreferredProvider)
+		}
+		if len(allowed) > 0 && !slices.Contains(allowed, input.PreferredProvider) {
+			return nil, fmt.Errorf("%w: preferred provider %s is not one of the allowed providers", domain.ErrInvalidInput, input.PreferredProvider)
+		}
+	}
+
+	now := time.Now()
+	return &domain.ModelPolicy{
+		ID:                uuid.New(),
+		OfficeID:          input.OfficeID,
+		AgentID:           input.AgentID,
+		AllowedProviders:  allowed,
+		PreferredModel:    input.PreferredModel,
+		PreferredProvider: input.PreferredProvider,
+		MaxCreditsPerTask: input.MaxCreditsPerTask,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}, nil
+}
+
+// DeletePolicy removes the office's policy, or with agentID the agent's own
+// policy, after which the agent follows the office's
+func (s *ModelPolicyService) DeletePolicy(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) error {
+	if err := s.ensureAgent(ctx, officeID, agentID); err != nil {
+		return err
+	}
+	return s.policyRepo.Delete(ctx, officeID, agentID)
+}
+
+// ensureAgent checks that agentID, if set, is an agent of the office
+func (s *ModelPolicyService) ensureAgent(ctx context.Context, officeID uuid.UUID, agentID *uuid.UUID) error {
+	if agentID == nil {
+		return nil
+	}
+	_, err := officeAgent(ctx, s.agentRepo, officeID, *agentID)
+	return err
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// ModerationService handles admin review of marketplace template submissions
+type ModerationService struct {
+	marketplaceRepo     domain.MarketplaceRepository
+	versionRepo         domain.TemplateVersionRepository
+	officeRepo          domain.OfficeRepository
+	txManager           domain.TxManager
+	notificationService *NotificationService
+	favorites           *FavoriteService
+}
+
+// NewModerationService creates a new ModerationService instance
+func NewModerationService(
+	marketplaceRepo domain.MarketplaceRepository,
+	versionRepo domain.TemplateVersionRepository,
+	officeRepo domain.OfficeRepository,
+	txManager domain.TxManager,
+	notificationService *NotificationService,
+	favorites *FavoriteService,
+) *ModerationService {
+	return &ModerationService{
+		marketplaceRepo:     marketplaceRepo,
+		versionRepo:         versionRepo,
+		officeRepo:          officeRepo,
+		txManager:           txManager,
+		notificationService: notificationService,
+		favorites:           favorites,
+	}
+}
+
+// GetPendingTemplates returns the moderation queue, oldest submissions first.
+// Templates with a new version awaiting review carry it as their
+// PendingVersion.
+func (s *ModerationService) GetPendingTemplates(ctx context.Context, limit, offset int) ([]domain.AgentTemplate, int, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	templates, total, err := s.marketplaceRepo.GetPendingTemplates(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range templates {
+		version, err := s.versionRepo.GetPending(ctx, templates[i].ID)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		templates[i].PendingVersion = version
+	}
+	return templates, total, nil
+}
+
+// ApproveTemplate publishes a pending template, or makes its pending version
+// live, and notifies its author. The returned notifications have been stored
+// and pushed to connected clients.
+func (s *ModerationService) ApproveTemplate(ctx context.Context, adminID, templateID uuid.UUID) (*domain.AgentTemplate, []*domain.Notification, error) {
+	var version *domain.TemplateVersion
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if version, err = s.approveVersion(ctx, adminID, templateID); err != nil {
+			return err
+		}
+		err = s.marketplaceRepo.SetTemplateStatus(ctx, templateID, "approved", "", adminID)
+		if errors.Is(err, domain.ErrNotFound) && version != nil {
+			// Only the version awaited review
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.favorites.NotifyTemplateUpdated(ctx, template)
+
+	message := fmt.Sprintf("Your template %q is now live in the marketplace.", template.Name)
+	if version != nil {
+		message = fmt.Sprintf("Version %s of your template %q is now live in the marketplace.", version.Version, template.Name)
+	}
+	notifications, err := s.notifyAuthor(ctx, template, version,
+		domain.NotificationTypeTemplateApproved,
+		"Template approved",
+		message,
+	)
+	return template, notifications, err
+}
+
+// approveVersion replaces the live template with its pending version,
+// returning nil if it has none
+func (s *ModerationService) approveVersion(ctx context.Context, adminID, templateID uuid.UUID) (*domain.TemplateVersion, error) {
+	version, err := s.versionRepo.GetPending(ctx, templateID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.versionRepo.SetStatus(ctx, version.ID, "approved", "", adminID); err != nil {
+		return nil, err
+	}
+	version.Status = "approved"
+
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	next := *template
+	next.Version = version.Version
+	next.SystemPrompt = version.SystemPrompt
+	next.SkillTags = version.SkillTags
+	next.License = version.License
+	next.RiskScore, next.RiskFindings = version.RiskScore, version.RiskFindings
+	next.UpdatedAt = time.Now()
+	return version, goLiveWithVersion(ctx, s.marketplaceRepo, s.versionRepo, template, &next)
+}
+
+// RejectTemplate rejects a pending template, or its pending version, with a
+// reason and notifies its author. A template whose version is rejected stays
+// listed at its approved version.
+func (s *ModerationService) RejectTemplate(ctx context.Context, adminID, templateID uuid.UUID, reason string) (*domain.AgentTemplate, []*domain.Notification, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, nil, fmt.Errorf("%w: a rejection reason is required", domain.ErrInvalidInput)
+	}
+
+	var version *domain.TemplateVersion
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		version, err = s.versionRepo.GetPending(ctx, templateID)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			version = nil
+		case err != nil:
+			return err
+		default:
+			if err := s.versionRepo.SetStatus(ctx, version.ID, "rejected", reason, adminID); err != nil {
+				return err
+			}
+			version.Status, version.RejectionReason = "rejected", reason
+		}
+		err = s.marketplaceRepo.SetTemplateStatus(ctx, templateID, "rejected", reason, adminID)
+		if errors.Is(err, domain.ErrNotFound) && version != nil {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message := fmt.Sprintf("Your template %q was not approved: %s", template.Name, reason)
+	if version != nil {
+		message = fmt.Sprintf("Version %s of your template %q was not approved: %s", version.Version, template.Name, reason)
+	}
+	notifications, err := s.notifyAuthor(ctx, template, version,
+		domain.NotificationTypeTemplateRejected,
+		"Template rejected",
+		message,
+	)
+	return template, notifications, err
+}
+
+// notifyAuthor stores a notification in each of the author's offices about
+// the template, or its version if one was reviewed
+func (s *ModerationService) notifyAuthor(
+	ctx context.Context,
+	template *domain.AgentTemplate,
+	version *domain.TemplateVersion,
+	notificationType domain.NotificationType,
+	title string,
+	message string,
+) ([]*domain.Notification, error) {
+	if template.AuthorID == nil {
+		return nil, nil
+	}
+
+	offices, err := s.officeRepo.GetByUserID(ctx, *template.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]any{
+		"template_id": template.ID.String(),
+		"name":        template.Name,
+		"status":      template.Status,
+	}
+	if template.RejectionReason != "" {
+		payload["rejection_reason"] = template.RejectionReason
+	}
+	if version != nil {
+		payload["version"] = version.Version
+		payload["version_status"] = version.Status
+		if version.RejectionReason != "" {
+			payload["rejection_reason"] = version.RejectionReason
+		}
+	}
+
+	var notifications []*domain.Notification
+	for _, office := range offices {
+		notification, err := s.notificationService.Notify(ctx, office.ID, notificationType, title, message, payload)
+		if err != nil {
+			return notifications, err
+		}
+		if notification != nil {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
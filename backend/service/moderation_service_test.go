@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPublishVersionStagesItWithoutTouchingTheLiveTemplate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	marketplace := mocks.NewMockMarketplaceRepository(ctrl)
+	versions := mocks.NewMockTemplateVersionRepository(ctrl)
+	svc := NewMarketplaceService(marketplace, nil, versions, nil, newTestTxManager(ctrl),
+		NewContentModerationService(nil, nil, nil, nil), nil, TemplateReviewConfig{})
+
+	authorID := uuid.New()
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Helper", Role: "Assistant", SystemPrompt: "Answer billing questions.",
+		AuthorID: &authorID, Category: "general", Version: "1.0.0", Status: "approved",
+		License: domain.TemplateLicense{Type: domain.LicenseTeam},
+	}
+	marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil).Times(2)
+	versions.EXPECT().GetPending(gomock.Any(), template.ID).Return(nil, domain.ErrNotFound)
+	marketplace.EXPECT().CategoryExists(gomock.Any(), "general").Return(true, nil)
+	marketplace.EXPECT().IsResaleProhibitedCopy(gomock.Any(), gomock.Any(), authorID).Return(false, nil)
+	// No UpdateTemplate: the approved version stays live
+	var staged *domain.TemplateVersion
+	versions.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, v *domain.TemplateVersion) error {
+		staged = v
+		return nil
+	})
+
+	prompt := "Answer billing and refund questions."
+	version, err := svc.PublishVersion(context.Background(), authorID, template.ID, PublishVersionInput{
+		Version: "1.1.0", Changelog: "Handles refunds", SystemPrompt: &prompt,
+	})
+	if err != nil {
+		t.Fatalf("PublishVersion: %v", err)
+	}
+	if version != staged || version.Status != "pending" || version.SystemPrompt != prompt || version.Version != "1.1.0" {
+		t.Errorf("PublishVersion = %+v, want the new version staged as pending", version)
+	}
+	if template.SystemPrompt != "Answer billing questions." || template.Version != "1.0.0" || template.Status != "approved" {
+		t.Errorf("template = %+v, want it unchanged", template)
+	}
+
+	// One version awaits review at a time
+	versions.EXPECT().GetPending(gomock.Any(), template.ID).Return(staged, nil)
+	_, err = svc.PublishVersion(context.Background(), authorID, template.ID, PublishVersionInput{
+		Version: "1.2.0", Changelog: "More", SystemPrompt: &prompt,
+	})
+	if !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("PublishVersion with a version awaiting review error = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestApproveTemplateMakesItsPendingVersionLive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	marketplace := mocks.NewMockMarketplaceRepository(ctrl)
+	versions := mocks.NewMockTemplateVersionRepository(ctrl)
+	favorites, _ := newTestFavoriteService(t)
+	svc := NewModerationService(marketplace, versions, nil, newTestTxManager(ctrl), nil, favorites)
+
+	adminID := uuid.New()
+	live := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Helper", SystemPrompt: "Answer billing questions.", Version: "1.0.0", Status: "approved",
+	}
+	staged := &domain.TemplateVersion{
+		ID: uuid.New(), TemplateID: live.ID, Version: "1.1.0", SystemPrompt: "Answer billing and refund questions.",
+		Status: "pending", RiskScore: 5, License: domain.TemplateLicense{Type: domain.LicensePersonal},
+	}
+	versions.EXPECT().GetPending(gomock.Any(), live.ID).Return(staged, nil)
+	versions.EXPECT().SetStatus(gomock.Any(), staged.ID, "approved", "", adminID).Return(nil)
+	marketplace.EXPECT().GetTemplateByID(gomock.Any(), live.ID).Return(live, nil)
+	versions.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, v *domain.TemplateVersion) error {
+		if !inTx(ctx) || v.Version != "1.0.0" || v.SystemPrompt != live.SystemPrompt {
+			t.Errorf("snapshot %+v, want version 1.0.0 in the transaction", v)
+		}
+		return nil
+	})
+	var updated *domain.AgentTemplate
+	marketplace.EXPECT().UpdateTemplate(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, next *domain.AgentTemplate) error {
+		if !inTx(ctx) {
+			t.Error("the template was updated outside the transaction")
+		}
+		updated = next
+		return nil
+	})
+	// The template itself was approved already
+	marketplace.EXPECT().SetTemplateStatus(gomock.Any(), live.ID, "approved", "", adminID).Return(domain.ErrNotFound)
+	marketplace.EXPECT().GetTemplateByID(gomock.Any(), live.ID).DoAndReturn(func(context.Context, uuid.UUID) (*domain.AgentTemplate, error) {
+		return updated, nil
+	})
+
+	template, _, err := svc.ApproveTemplate(context.Background(), adminID, live.ID)
+	if err != nil {
+		t.Fatalf("ApproveTemplate: %v", err)
+	}
+	if template.Version != "1.1.0" || template.SystemPrompt != staged.SystemPrompt || template.Status != "approved" ||
+		template.License != staged.License || template.RiskScore != 5 {
+		t.Errorf("ApproveTemplate = %+v, want the staged version live", template)
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/mail"
+	"github.com/google/uuid"
+)
+
+// NotificationService handles persisted office notifications
+type NotificationService struct {
+	notificationRepo domain.NotificationRepository
+	preferenceRepo   domain.NotificationPreferenceRepository
+	officeRepo       domain.OfficeRepository
+	userRepo         domain.UserRepository
+	mailer           domain.Mailer
+	events           domain.EventPublisher
+	// webhooks receive the notifications that are also webhook events
+	webhooks *WebhookDispatcher
+	// appURL is the frontend base URL emailed links point to
+	appURL string
+}
+
+// NewNotificationService creates a new NotificationService instance
+func NewNotificationService(
+	notificationRepo domain.NotificationRepository,
+	preferenceRepo domain.NotificationPreferenceRepository,
+	officeRepo domain.OfficeRepository,
+	userRepo domain.UserRepository,
+	mailer domain.Mailer,
+	events domain.EventPublisher,
+	webhooks *WebhookDispatcher,
+	appURL string,
+) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		officeRepo:       officeRepo,
+		userRepo:         userRepo,
+		mailer:           mailer,
+		events:           events,
+		webhooks:         webhooks,
+		appURL:           appURL,
+	}
+}
+
+// Notify tells an office's owner about something, as their preferences for
+// the notification type say. In-app notifications are stored and pushed to
+// connected clients; offline clients receive them as unread notifications
+// when they next connect. Emails go to verified addresses only. It returns
+// nil when the owner turned in-app notifications of the type off.
+// Notifications that are webhook events, such as task_completed, also go to
+// the office's webhooks subscribed to them, whatever the preferences.
+func (s *NotificationService) Notify(
+	ctx context.Context,
+	officeID uuid.UUID,
+	notificationType domain.NotificationType,
+	title string,
+	message string,
+	payload map[string]any,
+) (*domain.Notification, error) {
+	if eventType, ok := notificationWebhookEvents[notificationType]; ok {
+		data := maps.Clone(payload)
+		if data == nil {
+			data = make(map[string]any, 2)
+		}
+		data["title"], data["message"] = title, message
+		s.webhooks.Dispatch(ctx, officeID, eventType, data)
+	}
+
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.userRepo.GetByID(ctx, office.UserID)
+	if err != nil {
+		return nil, err
+	}
+	preference, err := s.preference(ctx, owner.ID, notificationType)
+	if err != nil {
+		return nil, err
+	}
+
+	var notification *domain.Notification
+	if preference.InApp {
+		notification = &domain.Notification{
+			ID:        uuid.New(),
+			OfficeID:  officeID,
+			Type:      notificationType,
+			Title:     title,
+			Message:   message,
+			Payload:   payload,
+			CreatedAt: time.Now(),
+		}
+		if err := s.notificationRepo.Create(ctx, notification); err != nil {
+			return nil, err
+		}
+		if err := s.events.Publish(ctx, notification.Event()); err != nil {
+			log.Printf("Failed to publish notification %s: %v", notification.ID, err)
+		}
+	}
+
+	if preference.Email && owner.EmailVerifiedAt != nil {
+		// Types without a template of their own get the generic one
+		template := string(notificationType)
+		if !mail.Exists(template) {
+			template = mail.Notification
+		}
+		email, err := mail.Render(template, owner.Email, mail.NotificationData{
+			Name:    owner.Name,
+			Title:   title,
+			Message: message,
+			Payload: payload,
+			AppURL:  s.appURL,
+		})
+		if err == nil {
+			err = s.mailer.Send(ctx, email)
+		}
+		if err != nil {
+			// The in-app notification is stored either way
+			log.Printf("Failed to email %s notification to user %s: %v", notificationType, owner.ID, err)
+		}
+	}
+	return notification, nil
+}
+
+// NotifyUser notifies a user who is not acting for a particular office, such
+// as a template author, in the first office they created
+func (s *NotificationService) NotifyUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	notificationType domain.NotificationType,
+	title string,
+	message string,
+	payload map[string]any,
+) (*domain.Notification, error) {
+	offices, err := s.officeRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(offices) == 0 {
+		return nil, nil
+	}
+	return s.Notify(ctx, offices[0].ID, notificationType, title, message, payload)
+}
+
+// GetUnread returns the most recent unread notifications for an office
+func (s *NotificationService) GetUnread(ctx context.Context, officeID uuid.UUID, limit int) ([]*domain.Notification, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.notificationRepo.GetUnread(ctx, officeID, limit)
+}
+
+// ListNotifications returns a page of an office's notifications, newest
+// first, with the total matching
+func (s *NotificationService) ListNotifications(
+	ctx context.Context,
+	officeID uuid.UUID,
+	unreadOnly bool,
+	page domain.PageRequest,
+) ([]*domain.Notification, int, error) {
+	if page.Limit <= 0 {
+		page.Limit = 20
+	}
+
+	notifications, err := s.notificationRepo.List(ctx, officeID, unreadOnly, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.notificationRepo.Count(ctx, officeID, unreadOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// MarkRead marks one of an office's notifications as read and tells the
+// office's other clients
+func (s *NotificationService) MarkRead(ctx context.Context, officeID, notificationID uuid.UUID) error {
+	if err := s.notificationRepo.MarkRead(ctx, officeID, notificationID); err != nil {
+		return err
+	}
+	s.publishRead(ctx, officeID, map[string]any{
+		"notification_ids": []string{notificationID.String()},
+	})
+	return nil
+}
+
+// MarkAllRead marks all of an office's notifications as read and returns
+// how many were unread
+func (s *NotificationService) MarkAllRead(ctx context.Context, officeID uuid.UUID) (int, error) {
+	count, err := s.notificationRepo.MarkAllRead(ctx, officeID)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		s.publishRead(ctx, officeID, map[string]any{"all": true})
+	}
+	return count, nil
+}
+
+// publishRead emits a notifications_read event, logging failures
+func (s *NotificationService) publishRead(ctx context.Context, officeID uuid.UUID, payload map[string]any) {
+	if err := s.events.Publish(ctx, domain.NewEvent(officeID, domain.EventNotificationsRead, payload)); err != nil {
+		log.Printf("Failed to publish read notifications for office %s: %v", officeID, err)
+	}
+}
+
+// GetPreferences returns a user's preference for every notification type,
+// defaults included
+func (s *NotificationService) GetPreferences(ctx context.Context, userID uuid.UUID) ([]domain.NotificationPreference, error) {
+	chosen, err := s.preferenceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[domain.NotificationType]domain.NotificationPreference, len(chosen))
+	for _, p := range chosen {
+		byType[p.Type] = *p
+	}
+
+	preferences := make([]domain.NotificationPreference, len(domain.NotificationTypes))
+	for i, t := range domain.NotificationTypes {
+		if p, ok := byType[t]; ok {
+			preferences[i] = p
+		} else {
+			preferences[i] = domain.DefaultNotificationPreference(t)
+		}
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences stores a user's preferences for the types given; other
+// types keep theirs. It returns the preferences for every type.
+func (s *NotificationService) UpdatePreferences(
+	ctx context.Context,
+	userID uuid.UUID,
+	preferences []domain.NotificationPreference,
+) ([]domain.NotificationPreference, error) {
+	changed := make([]*domain.NotificationPreference, len(preferences))
+	for i := range preferences {
+		if !preferences[i].Type.IsValid() {
+			return nil, fmt.Errorf("%w: unknown notification type %q", domain.ErrInvalidInput, preferences[i].Type)
+		}
+		changed[i] = &preferences[i]
+	}
+	if err := s.preferenceRepo.Upsert(ctx, userID, changed); err != nil {
+		return nil, err
+	}
+	return s.GetPreferences(ctx, userID)
+}
+
+// preference returns a user's preference for a notification type
+func (s *NotificationService) preference(
+	ctx context.Context,
+	userID uuid.UUID,
+	notificationType domain.NotificationType,
+) (domain.NotificationPreference, error) {
+	chosen, err := s.preferenceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return domain.NotificationPreference{}, err
+	}
+	for _, p := range chosen {
+		if p.Type == notificationType {
+			return *p, nil
+		}
+	}
+	return domain.DefaultNotificationPreference(notificationType), nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// OAuthService signs users in through OAuth providers. A provider account
+// is linked to the user with the same verified email, or to a new account
+// if there is none; afterwards the user gets the same session as a
+// password login. Accounts whose email is not verified yet are not linked.
+type OAuthService struct {
+	providers    map[string]domain.OAuthProvider
+	identityRepo domain.OAuthIdentityRepository
+	userRepo     domain.UserRepository
+	authService  *AuthService
+	// publicURL is this backend's public base URL, which providers redirect
+	// back to
+	publicURL string
+}
+
+// NewOAuthService creates a new OAuthService instance
+func NewOAuthService(
+	providers []domain.OAuthProvider,
+	identityRepo domain.OAuthIdentityRepository,
+	userRepo domain.UserRepository,
+	authService *AuthService,
+	publicURL string,
+) *OAuthService {
+	byName := make(map[string]domain.OAuthProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &OAuthService{
+		providers:    byName,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+		publicURL:    strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// Providers returns the names of the configured providers
+func (s *OAuthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasProvider reports whether provider is configured
+func (s *OAuthService) HasProvider(provider string) bool {
+	_, ok := s.providers[provider]
+	return ok
+}
+
+// AuthCodeURL returns the provider's consent URL. It returns ErrNotFound
+// for providers that are unknown or not configured.
+func (s *OAuthService) AuthCodeURL(provider, state string) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", domain.ErrNotFound
+	}
+	return p.AuthCodeURL(state, s.redirectURL(provider)), nil
+}
+
+// Login completes a sign-in with the code the provider redirected back with
+func (s *OAuthService) Login(ctx context.Context, provider, code string) (*AuthResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	profile, err := p.Exchange(ctx, code, s.redirectURL(provider))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s sign-in failed: %v", domain.ErrUnauthorized, provider, err)
+	}
+
+	identity, err := s.identityRepo.GetByProviderUserID(ctx, provider, profile.ProviderUserID)
+	switch {
+	case err == nil:
+		user, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return s.session(ctx, user, provider)
+	case !errors.Is(err, domain.ErrNotFound):
+		return nil, err
+	}
+
+	// First sign-in with this provider account. Linking by email is only
+	// safe if the provider has confirmed the user owns the address.
+	if profile.Email == "" || !profile.EmailVerified {
+		return nil, fmt.Errorf("%w: your %s account has no verified email address", domain.ErrInvalidInput, provider)
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, profile.Email)
+	switch {
+	case err == nil:
+		// Anyone can sign up with an address they do not own. Linking the
+		// owner's provider account to it would leave its password with
+		// whoever signed up, so the owner proves the account is theirs
+		// first, by verifying the email or resetting the password.
+		if user.EmailVerifiedAt == nil {
+			return nil, fmt.Errorf("%w: an account with the email of your %s account exists but is not verified",
+				domain.ErrAlreadyExists, provider)
+		}
+		err = s.link(ctx, user, provider, profile)
+	case errors.Is(err, domain.ErrNotFound):
+		user, err = s.createUser(ctx, provider, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.session(ctx, user, provider)
+}
+
+// link records that the provider account signs the user in
+func (s *OAuthService) link(ctx context.Context, user *domain.User, provider string, profile *domain.OAuthProfile) error {
+	return s.identityRepo.Create(ctx, &domain.OAuthIdentity{
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		Provider:       provider,
+		ProviderUserID: profile.ProviderUserID,
+		Email:          profile.Email,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// session signs the user in and records the sign-in
+func (s *OAuthService) session(ctx context.Context, user *domain.User, provider string) (*AuthResponse, error) {
+	resp, err := s.authService.session(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.authService.recordLogin(ctx, resp, "oauth:"+provider)
+	return resp, nil
+}
+
+// createUser creates an account for a provider profile, linked to the
+// provider account. It has no password until the user sets one through a
+// password reset.
+func (s *OAuthService) createUser(ctx context.Context, provider string, profile *domain.OAuthProfile) (*domain.User, error) {
+	name := profile.Name
+	if name == "" {
+		name, _, _ = strings.Cut(profile.Email, "@")
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		ID:              uuid.New(),
+		Email:           profile.Email,
+		Name:            name,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	link := func(ctx context.Context) error { return s.link(ctx, user, provider, profile) }
+	if _, err := s.authService.createAccount(ctx, user, link); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// redirectURL is the callback URL registered with the provider
+func (s *OAuthService) redirectURL(provider string) string {
+	return s.publicURL + "/api/v1/auth/oauth/" + provider + "/callback"
+}
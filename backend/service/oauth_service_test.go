@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestOAuthLoginDoesNotLinkUnverifiedAccounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := mocks.NewMockOAuthProvider(ctrl)
+	identities := mocks.NewMockOAuthIdentityRepository(ctrl)
+	users := mocks.NewMockUserRepository(ctrl)
+	provider.EXPECT().Name().Return("google")
+	svc := NewOAuthService([]domain.OAuthProvider{provider}, identities, users, nil, "https://api.example.com")
+
+	// Someone signed up with the owner's address without verifying it
+	squatter := &domain.User{ID: uuid.New(), Email: "owner@example.com", PasswordHash: "squatter's"}
+	provider.EXPECT().Exchange(gomock.Any(), "code", gomock.Any()).
+		Return(&domain.OAuthProfile{ProviderUserID: "g-1", Email: squatter.Email, EmailVerified: true}, nil)
+	identities.EXPECT().GetByProviderUserID(gomock.Any(), "google", "g-1").Return(nil, domain.ErrNotFound)
+	users.EXPECT().GetByEmail(gomock.Any(), squatter.Email).Return(squatter, nil)
+
+	_, err := svc.Login(context.Background(), "google", "code")
+	if !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Login error = %v, want ErrAlreadyExists", err)
+	}
+}
@@ -0,0 +1,698 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// OfficeArchiveService exports offices as portable JSON archives and
+// imports archives as new offices, so an office can move between
+// environments and self-hosted instances
+type OfficeArchiveService struct {
+	officeService       *OfficeService
+	officeRepo          domain.OfficeRepository
+	agentRepo           domain.AgentRepository
+	agentTemplateRepo   domain.AgentTemplateRepository
+	agentSkillRepo      domain.AgentSkillRepository
+	memoryRepo          domain.AgentMemoryRepository
+	embedder            domain.Embedder
+	conversationRepo    domain.ConversationRepository
+	messageRepo         domain.MessageRepository
+	modelPolicyRepo     domain.ModelPolicyRepository
+	webResearchRepo     domain.WebResearchRepository
+	txManager           domain.TxManager
+	agentService        *AgentService
+	subscriptionService *SubscriptionService
+	moderation          *ContentModerationService
+	audit               *AuditService
+}
+
+// NewOfficeArchiveService creates a new OfficeArchiveService instance
+func NewOfficeArchiveService(
+	officeService *OfficeService,
+	officeRepo domain.OfficeRepository,
+	agentRepo domain.AgentRepository,
+	agentTemplateRepo domain.AgentTemplateRepository,
+	agentSkillRepo domain.AgentSkillRepository,
+	memoryRepo domain.AgentMemoryRepository,
+	embedder domain.Embedder,
+	conversationRepo domain.ConversationRepository,
+	messageRepo domain.MessageRepository,
+	modelPolicyRepo domain.ModelPolicyRepository,
+	webResearchRepo domain.WebResearchRepository,
+	txManager domain.TxManager,
+	agentService *AgentService,
+	subscriptionService *SubscriptionService,
+	moderation *ContentModerationService,
+	audit *AuditService,
+) *OfficeArchiveService {
+	return &OfficeArchiveService{
+		officeService:       officeService,
+		officeRepo:          officeRepo,
+		agentRepo:           agentRepo,
+		agentTemplateRepo:   agentTemplateRepo,
+		agentSkillRepo:      agentSkillRepo,
+		memoryRepo:          memoryRepo,
+		embedder:            embedder,
+		conversationRepo:    conversationRepo,
+		messageRepo:         messageRepo,
+		modelPolicyRepo:     modelPolicyRepo,
+		webResearchRepo:     webResearchRepo,
+		txManager:           txManager,
+		agentService:        agentService,
+		subscriptionService: subscriptionService,
+		moderation:          moderation,
+		audit:               audit,
+	}
+}
+
+// ExportOffice returns one of the user's offices as an archive. Offices
+// owned by someone else are reported as not found.
+func (s *OfficeArchiveService) ExportOffice(ctx context.Context, userID, officeID uuid.UUID) (*domain.OfficeArchive, error) {
+	office, err := s.officeService.ownedOffice(ctx, userID, officeID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &domain.OfficeArchive{
+		Version:       domain.OfficeArchiveVersion,
+		ExportedAt:    time.Now(),
+		Office:        domain.ArchivedOffice{Name: office.Name, Timezone: office.Timezone},
+		Agents:        []*domain.ArchivedAgent{},
+		Conversations: []*domain.ArchivedConversation{},
+	}
+
+	agents, err := s.agentRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		archived, err := s.exportAgent(ctx, agent)
+		if err != nil {
+			return nil, err
+		}
+		archive.Agents = append(archive.Agents, archived)
+	}
+
+	conversations, err := s.conversationRepo.GetByOfficeID(ctx, officeID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, conversation := range conversations {
+		archived, err := s.exportConversation(ctx, conversation)
+		if err != nil {
+			return nil, err
+		}
+		archive.Conversations = append(archive.Conversations, archived)
+	}
+
+	archive.Settings.ModelPolicies, err = s.modelPolicyRepo.ListByOffice(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if archive.Settings.ModelPolicies == nil {
+		archive.Settings.ModelPolicies = []*domain.ModelPolicy{}
+	}
+	archive.Settings.WebResearch, err = s.webResearchRepo.GetSettings(ctx, officeID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionOfficeExport,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   officeID,
+		OfficeID:   officeID,
+		Details:    map[string]any{"agents": len(archive.Agents), "conversations": len(archive.Conversations)},
+	})
+	return archive, nil
+}
+
+// exportAgent archives an agent with its skills and memories
+func (s *OfficeArchiveService) exportAgent(ctx context.Context, agent *domain.Agent) (*domain.ArchivedAgent, error) {
+	skills, err := s.agentSkillRepo.GetByAgentID(ctx, agent.ID)
+	if err != nil {
+		return nil, err
+	}
+	memories, err := s.memoryRepo.GetByAgentID(ctx, agent.ID)
+	if err != nil {
+		return nil, err
+	}
+	if memories == nil {
+		memories = []*domain.AgentMemory{}
+	}
+
+	archived := &domain.ArchivedAgent{
+		ID:                 agent.ID,
+		TemplateID:         agent.TemplateID,
+		CustomName:         agent.CustomName,
+		CustomSystemPrompt: agent.CustomSystemPrompt,
+		CustomAvatarURL:    agent.CustomAvatarURL,
+		Skills:             skills,
+		Memories:           memories,
+		CreatedAt:          agent.CreatedAt,
+	}
+	if agent.Template != nil {
+		archived.TemplateRole = agent.Template.Role
+	}
+	return archived, nil
+}
+
+// exportConversation archives a conversation with its participants and its
+// undeleted messages
+func (s *OfficeArchiveService) exportConversation(ctx context.Context, conversation *domain.Conversation) (*domain.ArchivedConversation, error) {
+	participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	archived := &domain.ArchivedConversation{
+		ID:                conversation.ID,
+		Type:              conversation.Type,
+		Name:              conversation.Name,
+		AgentIDs:          make([]uuid.UUID, len(participants)),
+		OrchestrationMode: conversation.OrchestrationMode,
+		ModeratorID:       conversation.ModeratorID,
+		DebateRounds:      conversation.DebateRounds,
+		ArchivedAt:        conversation.ArchivedAt,
+		Messages:          []*domain.Message{},
+		CreatedAt:         conversation.CreatedAt,
+		UpdatedAt:         conversation.UpdatedAt,
+	}
+	for i, agent := range participants {
+		archived.AgentIDs[i] = agent.ID
+	}
+
+	page := domain.PageRequest{Limit: transcriptPageSize}
+	for {
+		messages, err := s.messageRepo.GetByConversationID(ctx, conversation.ID, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			if !message.IsDeleted() {
+				archived.Messages = append(archived.Messages, message)
+			}
+		}
+		if len(messages) < page.Limit {
+			return archived, nil
+		}
+		last := messages[len(messages)-1]
+		page.Cursor = &domain.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// ImportOfficeResult is an office imported from an archive with what was
+// imported into it and what was left out
+type ImportOfficeResult struct {
+	Office        *domain.Office `json:"office"`
+	Agents        int            `json:"agents"`
+	Conversations int            `json:"conversations"`
+	Messages      int            `json:"messages"`
+	Memories      int            `json:"memories"`
+	// SkippedAgents are the archive's agents whose template is neither
+	// installed nor stood in for by a built-in template, is awaiting or
+	// refused moderation, is a premium template the new office has not
+	// bought, or is licensed for smaller offices. Their messages are kept.
+	SkippedAgents []uuid.UUID `json:"skipped_agents"`
+	// DroppedPrompts are the archive's agents imported without their
+	// custom system prompt, which the new office's tier does not include
+	DroppedPrompts []uuid.UUID `json:"dropped_prompts"`
+	// DroppedModelPolicies are the archive's model policies left out
+	// because they are invalid or use providers the new office's tier does
+	// not include
+	DroppedModelPolicies []uuid.UUID `json:"dropped_model_policies"`
+	// DroppedWebResearch reports the archive's web research settings left
+	// out because they are invalid or the new office's tier does not include
+	// web research
+	DroppedWebResearch bool `json:"dropped_web_research"`
+}
+
+// officeImport is an archive being imported into a new office
+type officeImport struct {
+	userID   uuid.UUID
+	office   *domain.Office
+	ids      archiveIDs
+	result   *ImportOfficeResult
+	memories []*domain.AgentMemory
+}
+
+// ImportOffice recreates an archive as a new office of the user, giving
+// everything in it new IDs. The new office starts on the free tier, so it
+// fails with ErrTierLimitExceeded when the archive's agents do not fit it.
+// The office is created in one transaction, so a failed import leaves
+// nothing behind.
+func (s *OfficeArchiveService) ImportOffice(ctx context.Context, userID uuid.UUID, archive *domain.OfficeArchive) (*ImportOfficeResult, error) {
+	office, err := newImportedOffice(userID, archive)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := s.archiveTemplates(ctx, archive.Agents)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &officeImport{
+		userID: userID,
+		office: office,
+		ids:    newArchiveIDs(),
+		result: &ImportOfficeResult{
+			Office:               office,
+			SkippedAgents:        []uuid.UUID{},
+			DroppedPrompts:       []uuid.UUID{},
+			DroppedModelPolicies: []uuid.UUID{},
+		},
+	}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.officeRepo.Create(ctx, office); err != nil {
+			return err
+		}
+		if err := s.importAgents(ctx, imp, archive.Agents, templates); err != nil {
+			return err
+		}
+		if err := s.importConversations(ctx, imp, archive.Conversations); err != nil {
+			return err
+		}
+		return s.importSettings(ctx, imp, archive.Settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.embedMemories(ctx, imp.memories)
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionOfficeImport,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   office.ID,
+		OfficeID:   office.ID,
+		Details:    map[string]any{"name": office.Name, "agents": imp.result.Agents, "conversations": imp.result.Conversations},
+	})
+	return imp.result, nil
+}
+
+// newImportedOffice validates an archive's format and office and returns
+// the office to create for it
+func newImportedOffice(userID uuid.UUID, archive *domain.OfficeArchive) (*domain.Office, error) {
+	if archive.Version != domain.OfficeArchiveVersion {
+		return nil, fmt.Errorf("%w: unsupported archive version %d", domain.ErrInvalidInput, archive.Version)
+	}
+	name := strings.TrimSpace(archive.Office.Name)
+	if name == "" || len(name) > maxOfficeNameLength {
+		return nil, fmt.Errorf("%w: the archive's office needs a name of at most %d characters", domain.ErrInvalidInput, maxOfficeNameLength)
+	}
+	timezone := archive.Office.Timezone
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+		timezone = "UTC"
+	}
+	return &domain.Office{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Timezone:  timezone,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// archiveTemplates returns the template to hire each archived agent from,
+// keyed by the agent's archive ID: the agent's template if it is
+// installed, or else the built-in template with its role. Agents with
+// neither are left out.
+func (s *OfficeArchiveService) archiveTemplates(ctx context.Context, agents []*domain.ArchivedAgent) (map[uuid.UUID]*domain.AgentTemplate, error) {
+	templates := make(map[uuid.UUID]*domain.AgentTemplate, len(agents))
+	for _, agent := range agents {
+		template, err := s.agentTemplateRepo.GetByID(ctx, agent.TemplateID)
+		if errors.Is(err, domain.ErrNotFound) && agent.TemplateRole != "" {
+			var builtIn *domain.AgentTemplate
+			builtIn, err = s.agentTemplateRepo.GetByRole(ctx, agent.TemplateRole)
+			if err == nil {
+				template, err = s.agentTemplateRepo.GetByID(ctx, builtIn.ID)
+			}
+		}
+		switch {
+		case err == nil:
+			templates[agent.ID] = template
+		case !errors.Is(err, domain.ErrNotFound):
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// importAgents hires the archived agents into the new office with their
+// skills and memories
+func (s *OfficeArchiveService) importAgents(ctx context.Context, imp *officeImport, archived []*domain.ArchivedAgent, templates map[uuid.UUID]*domain.AgentTemplate) error {
+	customPrompts, err := s.subscriptionService.CheckCustomPrompts(ctx, imp.office.ID)
+	if err != nil {
+		return err
+	}
+
+	var agents []*domain.Agent
+	var entries []*domain.ArchivedAgent
+	for _, entry := range archived {
+		template, ok := templates[entry.ID]
+		if ok {
+			err := s.agentService.requireSelectable(ctx, imp.office.ID, imp.userID, template)
+			if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrPurchaseRequired) || errors.Is(err, domain.ErrLicenseRequired) {
+				ok = false
+			} else if err != nil {
+				return err
+			}
+		}
+		if !ok {
+			imp.result.SkippedAgents = append(imp.result.SkippedAgents, entry.ID)
+			continue
+		}
+
+		agent, err := s.newImportedAgent(ctx, imp, template, entry, customPrompts)
+		if err != nil {
+			return err
+		}
+		imp.ids.agents[entry.ID] = agent.ID
+		agents = append(agents, agent)
+		entries = append(entries, entry)
+	}
+	if len(agents) == 0 {
+		return nil
+	}
+
+	if err := s.agentService.requireAgentCapacity(ctx, imp.office.ID, 0, len(agents)); err != nil {
+		return err
+	}
+	if err := s.agentRepo.CreateMany(ctx, agents); err != nil {
+		return err
+	}
+	imp.result.Agents = len(agents)
+
+	for i, agent := range agents {
+		if len(entries[i].Skills) > 0 {
+			if err := s.agentSkillRepo.Set(ctx, agent.ID, entries[i].Skills); err != nil {
+				return err
+			}
+		}
+		for _, memory := range entries[i].Memories {
+			if err := s.importMemory(ctx, imp, agent, memory); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newImportedAgent returns the new office's agent for an archived agent,
+// with its custom system prompt if the office's tier includes custom
+// prompts and the prompt passes moderation
+func (s *OfficeArchiveService) newImportedAgent(
+	ctx context.Context,
+	imp *officeImport,
+	template *domain.AgentTemplate,
+	entry *domain.ArchivedAgent,
+	customPrompts bool,
+) (*domain.Agent, error) {
+	name := strings.TrimSpace(entry.CustomName)
+	if len(name) > maxAgentNameLength {
+		return nil, fmt.Errorf("%w: agent %s: custom_name must be at most %d characters", domain.ErrInvalidInput, entry.ID, maxAgentNameLength)
+	}
+	avatarURL := strings.TrimSpace(entry.CustomAvatarURL)
+	if err := validateAvatarURL(avatarURL); err != nil {
+		return nil, fmt.Errorf("agent %s: %w", entry.ID, err)
+	}
+
+	agent := newOfficeAgent(imp.office.ID, template, name)
+	agent.CustomAvatarURL = avatarURL
+
+	prompt := strings.TrimSpace(entry.CustomSystemPrompt)
+	if prompt == "" {
+		return agent, nil
+	}
+	if !customPrompts {
+		imp.result.DroppedPrompts = append(imp.result.DroppedPrompts, entry.ID)
+		return agent, nil
+	}
+	if len(prompt) > maxAgentPromptLength {
+		return nil, fmt.Errorf("%w: agent %s: custom_system_prompt must be at most %d characters", domain.ErrInvalidInput, entry.ID, maxAgentPromptLength)
+	}
+	_, err := s.moderation.Screen(ctx, ModerationTarget{
+		Type:     domain.ModerationContentSystemPrompt,
+		Content:  prompt,
+		EntityID: agent.ID,
+		OfficeID: imp.office.ID,
+		UserID:   imp.userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	agent.CustomSystemPrompt = prompt
+	return agent, nil
+}
+
+// importMemory saves an archived memory for the imported agent
+func (s *OfficeArchiveService) importMemory(ctx context.Context, imp *officeImport, agent *domain.Agent, memory *domain.AgentMemory) error {
+	if strings.TrimSpace(memory.Key) == "" {
+		return fmt.Errorf("%w: memories need a key", domain.ErrInvalidInput)
+	}
+	imported := *memory
+	imported.ID = imp.ids.id(memory.ID)
+	imported.OfficeID = imp.office.ID
+	imported.AgentID = agent.ID
+	imported.VectorID = ""
+	if memory.SourceID != nil {
+		sourceID := imp.ids.id(*memory.SourceID)
+		imported.SourceID = &sourceID
+	}
+	if err := s.memoryRepo.Create(ctx, &imported); err != nil {
+		return err
+	}
+	imp.memories = append(imp.memories, &imported)
+	imp.result.Memories++
+	return nil
+}
+
+// embedMemories embeds imported memories, which archives carry without
+// their embeddings, so that searches by meaning find them. Without an
+// embeddings API, or once it fails, the rest are only found by their text
+// until agents save them again.
+func (s *OfficeArchiveService) embedMemories(ctx context.Context, memories []*domain.AgentMemory) {
+	if s.embedder == nil {
+		return
+	}
+	for i, memory := range memories {
+		embedding, err := s.embedder.Embed(ctx, memory.Key+": "+memory.Value)
+		if err != nil {
+			log.Printf("Failed to embed imported memories, leaving %d unembedded: %v", len(memories)-i, err)
+			return
+		}
+		if err := s.memoryRepo.SetEmbedding(ctx, memory.ID, embedding, ""); err != nil {
+			log.Printf("Failed to store the embedding of imported memory %s: %v", memory.ID, err)
+		}
+	}
+}
+
+// importConversations recreates the archived conversations with their
+// imported participants and their messages. Messages from users are
+// attributed to the importing user.
+func (s *OfficeArchiveService) importConversations(ctx context.Context, imp *officeImport, archived []*domain.ArchivedConversation) error {
+	for _, entry := range archived {
+		conversation, err := newImportedConversation(imp, entry)
+		if err != nil {
+			return err
+		}
+		if err := s.conversationRepo.Create(ctx, conversation); err != nil {
+			return err
+		}
+		for _, agentID := range entry.AgentIDs {
+			if id, ok := imp.ids.agents[agentID]; ok {
+				if err := s.conversationRepo.AddParticipant(ctx, conversation.ID, id); err != nil {
+					return err
+				}
+			}
+		}
+		// Creating conversations does not set their archival
+		if conversation.ArchivedAt != nil {
+			if err := s.conversationRepo.Update(ctx, conversation); err != nil {
+				return err
+			}
+		}
+
+		for _, message := range entry.Messages {
+			if err := s.importMessage(ctx, imp, conversation, message); err != nil {
+				return err
+			}
+		}
+		imp.result.Conversations++
+	}
+	return nil
+}
+
+// newImportedConversation returns the new office's conversation for an
+// archived one. Its moderator is kept if it was imported; otherwise a
+// moderated conversation goes back to mentions.
+func newImportedConversation(imp *officeImport, entry *domain.ArchivedConversation) (*domain.Conversation, error) {
+	if entry.Type != domain.ConversationTypeDirect && entry.Type != domain.ConversationTypeGroup {
+		return nil, fmt.Errorf("%w: conversation %s has unknown type %q", domain.ErrInvalidInput, entry.ID, entry.Type)
+	}
+	mode := entry.OrchestrationMode
+	switch mode {
+	case "":
+		mode = domain.OrchestrationMentions
+	case domain.OrchestrationMentions, domain.OrchestrationRoundRobin, domain.OrchestrationModerator,
+		domain.OrchestrationAll, domain.OrchestrationDebate:
+	default:
+		return nil, fmt.Errorf("%w: conversation %s has unknown orchestration mode %q", domain.ErrInvalidInput, entry.ID, mode)
+	}
+	rounds := entry.DebateRounds
+	if rounds < 1 || rounds > maxDebateRounds {
+		rounds = domain.DefaultDebateRounds
+	}
+
+	conversation := &domain.Conversation{
+		ID:                imp.ids.id(entry.ID),
+		OfficeID:          imp.office.ID,
+		Type:              entry.Type,
+		Name:              entry.Name,
+		ArchivedAt:        entry.ArchivedAt,
+		OrchestrationMode: mode,
+		DebateRounds:      rounds,
+		CreatedAt:         entry.CreatedAt,
+		UpdatedAt:         entry.UpdatedAt,
+	}
+	if entry.ModeratorID != nil {
+		if moderatorID, ok := imp.ids.agents[*entry.ModeratorID]; ok {
+			conversation.ModeratorID = &moderatorID
+		}
+	}
+	if conversation.ModeratorID == nil && mode == domain.OrchestrationModerator {
+		conversation.OrchestrationMode = domain.OrchestrationMentions
+	}
+	return conversation, nil
+}
+
+// importMessage saves an archived message to the imported conversation.
+// Replies whose thread was not archived become top-level messages.
+func (s *OfficeArchiveService) importMessage(ctx context.Context, imp *officeImport, conversation *domain.Conversation, message *domain.Message) error {
+	imported := &domain.Message{
+		ID:             imp.ids.id(message.ID),
+		OfficeID:       conversation.OfficeID,
+		ConversationID: conversation.ID,
+		SenderType:     message.SenderType,
+		Content:        message.Content,
+		Metadata:       message.Metadata,
+		CreatedAt:      message.CreatedAt,
+		EditedAt:       message.EditedAt,
+	}
+	switch message.SenderType {
+	case domain.SenderTypeUser:
+		imported.SenderID = imp.userID
+	case domain.SenderTypeAgent:
+		imported.SenderID = imp.ids.agent(message.SenderID)
+	case domain.SenderTypeVisitor, domain.SenderTypeSystem:
+		imported.SenderID = imp.ids.id(message.SenderID)
+	default:
+		return fmt.Errorf("%w: message %s has unknown sender type %q", domain.ErrInvalidInput, message.ID, message.SenderType)
+	}
+	if message.ParentMessageID != nil {
+		if parentID, ok := imp.ids.ids[*message.ParentMessageID]; ok {
+			imported.ParentMessageID = &parentID
+		}
+	}
+
+	if err := s.messageRepo.Create(ctx, imported); err != nil {
+		return err
+	}
+	imp.result.Messages++
+	// Creating messages does not set their edit time
+	if imported.EditedAt != nil {
+		return s.messageRepo.Update(ctx, imported)
+	}
+	return nil
+}
+
+// importSettings applies the archived settings to the new office, checked
+// as when they are set against its tier. Model policies of agents that were
+// not imported are left out; invalid model policies and web research
+// settings, and those the tier does not include, are dropped.
+func (s *OfficeArchiveService) importSettings(ctx context.Context, imp *officeImport, settings domain.ArchivedSettings) error {
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, imp.office.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range settings.ModelPolicies {
+		input := SetModelPolicyInput{
+			OfficeID:          imp.office.ID,
+			AllowedProviders:  policy.AllowedProviders,
+			PreferredModel:    policy.PreferredModel,
+			PreferredProvider: policy.PreferredProvider,
+			MaxCreditsPerTask: policy.MaxCreditsPerTask,
+		}
+		if policy.AgentID != nil {
+			agentID, ok := imp.ids.agents[*policy.AgentID]
+			if !ok {
+				continue
+			}
+			input.AgentID = &agentID
+		}
+		imported, err := newModelPolicy(input, features)
+		if err != nil {
+			imp.result.DroppedModelPolicies = append(imp.result.DroppedModelPolicies, policy.ID)
+			continue
+		}
+		if err := s.modelPolicyRepo.Upsert(ctx, imported); err != nil {
+			return err
+		}
+	}
+
+	if settings.WebResearch != nil {
+		imported := &domain.WebResearchSettings{OfficeID: imp.office.ID, AllowedDomains: []string{}}
+		input := UpdateWebResearchSettingsInput{
+			AllowedDomains:     settings.WebResearch.AllowedDomains,
+			MaxSearchesPerTask: &settings.WebResearch.MaxSearchesPerTask,
+		}
+		if !features.WebResearch || applyWebResearchSettings(imported, input) != nil {
+			imp.result.DroppedWebResearch = true
+			return nil
+		}
+		if err := s.webResearchRepo.UpsertSettings(ctx, imported); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveIDs maps the IDs of an archive to those of the office it is
+// imported into. Agents are mapped apart, so that only imported agents are
+// referred to as agents.
+type archiveIDs struct {
+	agents map[uuid.UUID]uuid.UUID
+	ids    map[uuid.UUID]uuid.UUID
+}
+
+func newArchiveIDs() archiveIDs {
+	return archiveIDs{agents: map[uuid.UUID]uuid.UUID{}, ids: map[uuid.UUID]uuid.UUID{}}
+}
+
+// id returns the new ID for an archive ID, the same one every time
+func (ids archiveIDs) id(archived uuid.UUID) uuid.UUID {
+	if id, ok := ids.ids[archived]; ok {
+		return id
+	}
+	id := uuid.New()
+	ids.ids[archived] = id
+	return id
+}
+
+// agent returns the new ID of an archived agent, or for agents that were
+// not imported, such as those since removed from the office, a new ID of
+// their own
+func (ids archiveIDs) agent(archived uuid.UUID) uuid.UUID {
+	if id, ok := ids.agents[archived]; ok {
+		return id
+	}
+	return ids.id(archived)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+type officeArchiveMocks struct {
+	offices       *mocks.MockOfficeRepository
+	templates     *mocks.MockAgentTemplateRepository
+	agents        *mocks.MockAgentRepository
+	skills        *mocks.MockAgentSkillRepository
+	memories      *mocks.MockAgentMemoryRepository
+	conversations *mocks.MockConversationRepository
+	messages      *mocks.MockMessageRepository
+	policies      *mocks.MockModelPolicyRepository
+	research      *mocks.MockWebResearchRepository
+	subs          *mocks.MockSubscriptionRepository
+}
+
+func newTestOfficeArchiveService(t *testing.T) (*OfficeArchiveService, officeArchiveMocks) {
+	ctrl := gomock.NewController(t)
+	subscriptions, sm := newTestSubscriptionService(t)
+	m := officeArchiveMocks{
+		offices:       mocks.NewMockOfficeRepository(ctrl),
+		templates:     mocks.NewMockAgentTemplateRepository(ctrl),
+		agents:        mocks.NewMockAgentRepository(ctrl),
+		skills:        mocks.NewMockAgentSkillRepository(ctrl),
+		memories:      mocks.NewMockAgentMemoryRepository(ctrl),
+		conversations: mocks.NewMockConversationRepository(ctrl),
+		messages:      mocks.NewMockMessageRepository(ctrl),
+		policies:      mocks.NewMockModelPolicyRepository(ctrl),
+		research:      mocks.NewMockWebResearchRepository(ctrl),
+		subs:          sm.subs,
+	}
+	sm.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+
+	agentService := NewAgentService(m.agents, m.templates, mocks.NewMockTemplatePurchaseRepository(ctrl),
+		mocks.NewMockAgentChangeRepository(ctrl), txManager, subscriptions, nil, nil)
+	svc := NewOfficeArchiveService(NewOfficeService(m.offices, nil), m.offices, m.agents, m.templates, m.skills, m.memories, nil,
+		m.conversations, m.messages, m.policies, m.research, txManager, agentService, subscriptions,
+		nil, NewAuditService(sm.audit, sm.offices, nil))
+	return svc, m
+}
+
+func TestImportOfficeRemapsIDs(t *testing.T) {
+	svc, m := newTestOfficeArchiveService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	template := &domain.AgentTemplate{ID: uuid.New(), Role: "Engineer", Version: "1.0.0", Status: "approved"}
+	engineerID, goneID := uuid.New(), uuid.New()
+	questionID, answerID := uuid.New(), uuid.New()
+
+	archive := &domain.OfficeArchive{
+		Version: domain.OfficeArchiveVersion,
+		Office:  domain.ArchivedOffice{Name: "Acme", Timezone: "Mars/Olympus"},
+		Agents: []*domain.ArchivedAgent{
+			{ID: engineerID, TemplateID: template.ID, CustomName: "Ada", CustomSystemPrompt: "Be terse.",
+				Memories: []*domain.AgentMemory{{ID: uuid.New(), Key: "stack", Value: "Go", SourceID: &questionID}}},
+			// Neither its template nor a built-in one for its role is installed
+			{ID: goneID, TemplateID: uuid.New(), TemplateRole: "Astronaut"},
+		},
+		Conversations: []*domain.ArchivedConversation{{
+			ID:                uuid.New(),
+			Type:              domain.ConversationTypeGroup,
+			AgentIDs:          []uuid.UUID{engineerID, goneID},
+			OrchestrationMode: domain.OrchestrationModerator,
+			ModeratorID:       &goneID,
+			Messages: []*domain.Message{
+				{ID: questionID, SenderType: domain.SenderTypeUser, SenderID: uuid.New(), Content: "Stack?"},
+				{ID: answerID, SenderType: domain.SenderTypeAgent, SenderID: engineerID, Content: "Go", ParentMessageID: &questionID},
+				{ID: uuid.New(), SenderType: domain.SenderTypeAgent, SenderID: goneID, Content: "Bye"},
+			},
+		}},
+		Settings: domain.ArchivedSettings{ModelPolicies: []*domain.ModelPolicy{
+			{ID: uuid.New(), PreferredModel: "llama3", PreferredProvider: "ollama"},
+			{ID: uuid.New(), AgentID: &goneID, PreferredModel: "gpt-4o", PreferredProvider: "openai"},
+		}},
+	}
+
+	var office *domain.Office
+	m.offices.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o *domain.Office) error {
+		office = o
+		return nil
+	})
+	m.templates.EXPECT().GetByID(gomock.Any(), template.ID).Return(template, nil)
+	m.templates.EXPECT().GetByID(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound)
+	m.templates.EXPECT().GetByRole(gomock.Any(), "Astronaut").Return(nil, domain.ErrNotFound)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), gomock.Any()).Return(&domain.Subscription{Tier: domain.TierSolo}, nil).AnyTimes()
+
+	var agent *domain.Agent
+	m.agents.EXPECT().CreateMany(gomock.Any(), gomock.Len(1)).DoAndReturn(func(_ context.Context, agents []*domain.Agent) error {
+		agent = agents[0]
+		return nil
+	})
+	var memory *domain.AgentMemory
+	m.memories.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, mem *domain.AgentMemory) error {
+		memory = mem
+		return nil
+	})
+	var conversation *domain.Conversation
+	m.conversations.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, c *domain.Conversation) error {
+		conversation = c
+		return nil
+	})
+	var participants []uuid.UUID
+	m.conversations.EXPECT().AddParticipant(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _, agentID uuid.UUID) error {
+		participants = append(participants, agentID)
+		return nil
+	})
+	var messages []*domain.Message
+	m.messages.EXPECT().Create(gomock.Any(), gomock.Any()).Times(3).DoAndReturn(func(_ context.Context, msg *domain.Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	var policies []*domain.ModelPolicy
+	m.policies.EXPECT().Upsert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, p *domain.ModelPolicy) error {
+		policies = append(policies, p)
+		return nil
+	})
+
+	result, err := svc.ImportOffice(ctx, userID, archive)
+	if err != nil {
+		t.Fatalf("ImportOffice: %v", err)
+	}
+
+	if office.UserID != userID || office.Name != "Acme" || office.Timezone != "UTC" {
+		t.Errorf("office = %+v, want the user's Acme in UTC for the unknown timezone", office)
+	}
+	if len(result.SkippedAgents) != 1 || result.SkippedAgents[0] != goneID {
+		t.Errorf("skipped agents = %v, want the agent without a template", result.SkippedAgents)
+	}
+	// The solo tier does not include custom prompts
+	if len(result.DroppedPrompts) != 1 || agent.CustomSystemPrompt != "" || agent.CustomName != "Ada" {
+		t.Errorf("agent = %+v, dropped prompts %v; want Ada without her prompt", agent, result.DroppedPrompts)
+	}
+	if agent.ID == engineerID || agent.OfficeID != office.ID {
+		t.Errorf("agent %s in office %s, want a new ID in the new office", agent.ID, agent.OfficeID)
+	}
+	if memory.AgentID != agent.ID || *memory.SourceID != messages[0].ID {
+		t.Errorf("memory of %s from %s, want it remapped to the agent and the message", memory.AgentID, *memory.SourceID)
+	}
+	if len(participants) != 1 || participants[0] != agent.ID {
+		t.Errorf("participants = %v, want only the imported agent", participants)
+	}
+	if conversation.ModeratorID != nil || conversation.OrchestrationMode != domain.OrchestrationMentions {
+		t.Errorf("conversation mode = %s with moderator %v, want mentions without the skipped moderator",
+			conversation.OrchestrationMode, conversation.ModeratorID)
+	}
+
+	question, answer, bye := messages[0], messages[1], messages[2]
+	if question.ID == questionID || question.SenderID != userID || question.ConversationID != conversation.ID {
+		t.Errorf("question = %+v, want a new ID from the importing user", question)
+	}
+	if answer.SenderID != agent.ID || answer.ParentMessageID == nil || *answer.ParentMessageID != question.ID {
+		t.Errorf("answer = %+v, want it from the imported agent in the question's thread", answer)
+	}
+	if bye.SenderID == goneID || bye.SenderID == agent.ID {
+		t.Errorf("message of the skipped agent sent by %s, want a new ID of its own", bye.SenderID)
+	}
+	if len(policies) != 1 || policies[0].AgentID != nil || policies[0].OfficeID != office.ID {
+		t.Errorf("policies = %+v, want only the office's policy", policies)
+	}
+}
+
+func TestImportOfficeDropsSettingsOutsideTheTier(t *testing.T) {
+	svc, m := newTestOfficeArchiveService(t)
+	maxCredits := int64(0)
+	premium, unbounded := uuid.New(), uuid.New()
+	archive := &domain.OfficeArchive{
+		Version: domain.OfficeArchiveVersion,
+		Office:  domain.ArchivedOffice{Name: "Acme", Timezone: "UTC"},
+		Settings: domain.ArchivedSettings{
+			ModelPolicies: []*domain.ModelPolicy{
+				{ID: uuid.New(), AllowedProviders: []string{"groq"}},
+				{ID: premium, AllowedProviders: []string{"anthropic"}, PreferredModel: "claude", PreferredProvider: "anthropic"},
+				{ID: unbounded, MaxCreditsPerTask: &maxCredits},
+			},
+			WebResearch: &domain.WebResearchSettings{AllowedDomains: []string{"example.com"}, MaxSearchesPerTask: 10000},
+		},
+	}
+
+	m.offices.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), gomock.Any()).Return(&domain.Subscription{Tier: domain.TierSolo}, nil).AnyTimes()
+	var policies []*domain.ModelPolicy
+	m.policies.EXPECT().Upsert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, p *domain.ModelPolicy) error {
+		policies = append(policies, p)
+		return nil
+	})
+	// The solo tier has no web research, so its settings are never stored
+
+	result, err := svc.ImportOffice(context.Background(), uuid.New(), archive)
+	if err != nil {
+		t.Fatalf("ImportOffice: %v", err)
+	}
+	if len(policies) != 1 || policies[0].AllowedProviders[0] != "groq" {
+		t.Errorf("policies = %+v, want only the groq policy", policies)
+	}
+	if len(result.DroppedModelPolicies) != 2 || result.DroppedModelPolicies[0] != premium || result.DroppedModelPolicies[1] != unbounded {
+		t.Errorf("dropped model policies = %v, want the premium provider's and the non-positive credit cap's", result.DroppedModelPolicies)
+	}
+	if !result.DroppedWebResearch {
+		t.Error("web research settings were not dropped, want them left out of the free office")
+	}
+}
+
+func TestApplyWebResearchSettingsBoundsSearches(t *testing.T) {
+	settings := &domain.WebResearchSettings{AllowedDomains: []string{}, MaxSearchesPerTask: 5}
+	searches := maxWebSearchesPerTask + 1
+	err := applyWebResearchSettings(settings, UpdateWebResearchSettingsInput{AllowedDomains: []string{"Example.com"}, MaxSearchesPerTask: &searches})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("applyWebResearchSettings error = %v, want ErrInvalidInput", err)
+	}
+	if settings.MaxSearchesPerTask != 5 || len(settings.AllowedDomains) != 0 {
+		t.Errorf("settings = %+v, want them left as they were", settings)
+	}
+}
+
+func TestImportOfficeRejectsInvalidArchives(t *testing.T) {
+	svc, m := newTestOfficeArchiveService(t)
+	ctx := context.Background()
+	office := domain.ArchivedOffice{Name: "Acme", Timezone: "UTC"}
+
+	_, err := svc.ImportOffice(ctx, uuid.New(), &domain.OfficeArchive{Version: 2, Office: office})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("ImportOffice of version 2 error = %v, want ErrInvalidInput", err)
+	}
+
+	m.offices.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound).AnyTimes()
+	_, err = svc.ImportOffice(ctx, uuid.New(), &domain.OfficeArchive{
+		Version: domain.OfficeArchiveVersion,
+		Office:  office,
+		Conversations: []*domain.ArchivedConversation{{
+			ID: uuid.New(), Type: domain.ConversationTypeDirect, CreatedAt: time.Now(), OrchestrationMode: "anarchy",
+		}},
+	})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("ImportOffice of an unknown orchestration mode error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestExportOfficeOfSomeoneElse(t *testing.T) {
+	svc, m := newTestOfficeArchiveService(t)
+	officeID := uuid.New()
+	m.offices.EXPECT().GetByID(gomock.Any(), officeID).Return(&domain.Office{ID: officeID, UserID: uuid.New()}, nil)
+
+	_, err := svc.ExportOffice(context.Background(), uuid.New(), officeID)
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ExportOffice error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const maxOfficeNameLength = 255
+
+// OfficeService manages the offices a user owns
+type OfficeService struct {
+	officeRepo domain.OfficeRepository
+	audit      *AuditService
+}
+
+// NewOfficeService creates a new OfficeService instance
+func NewOfficeService(officeRepo domain.OfficeRepository, audit *AuditService) *OfficeService {
+	return &OfficeService{officeRepo: officeRepo, audit: audit}
+}
+
+// GetOffices returns the user's offices, oldest first
+func (s *OfficeService) GetOffices(ctx context.Context, userID uuid.UUID) ([]*domain.Office, error) {
+	return s.officeRepo.GetByUserID(ctx, userID)
+}
+
+// UpdateOfficeInput represents changes to an office; nil fields are kept
+type UpdateOfficeInput struct {
+	Name *string
+	// Timezone is an IANA time zone such as Europe/Berlin
+	Timezone *string
+}
+
+// UpdateOffice renames one of the user's offices or changes its timezone.
+// Offices owned by someone else are reported as not found. A new timezone
+// applies to usage recorded from then on; earlier days keep their dates.
+func (s *OfficeService) UpdateOffice(ctx context.Context, userID, officeID uuid.UUID, input UpdateOfficeInput) (*domain.Office, error) {
+	office, err := s.ownedOffice(ctx, userID, officeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || len(name) > maxOfficeNameLength {
+			return nil, fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxOfficeNameLength)
+		}
+		office.Name = name
+	}
+	if input.Timezone != nil {
+		timezone := strings.TrimSpace(*input.Timezone)
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+			return nil, fmt.Errorf("%w: unknown timezone %q", domain.ErrInvalidInput, timezone)
+		}
+		office.Timezone = timezone
+	}
+
+	office.UpdatedAt = time.Now()
+	if err := s.officeRepo.Update(ctx, office); err != nil {
+		return nil, err
+	}
+	return office, nil
+}
+
+// DeleteOffice deletes one of the user's offices other than the one the
+// session is signed in to, currentOfficeID. The user must keep at least one
+// office. The office stops running schedules, its API keys and widget tokens
+// are revoked and its subscription is not renewed; it can be restored for
+// deletionGracePeriod.
+func (s *OfficeService) DeleteOffice(ctx context.Context, userID, currentOfficeID, officeID uuid.UUID) error {
+	office, err := s.ownedOffice(ctx, userID, officeID)
+	if err != nil {
+		return err
+	}
+	if officeID == currentOfficeID {
+		return fmt.Errorf("%w: you cannot delete the office you are signed in to; switch to another office first", domain.ErrInvalidInput)
+	}
+	offices, err := s.officeRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(offices) < 2 {
+		return fmt.Errorf("%w: you cannot delete your only office", domain.ErrInvalidInput)
+	}
+
+	if err := s.officeRepo.SoftDelete(ctx, officeID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionOfficeDelete,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   officeID,
+		OfficeID:   officeID,
+		Details:    map[string]any{"name": office.Name},
+	})
+	return nil
+}
+
+// RestoreOffice brings back one of the user's offices deleted within
+// deletionGracePeriod, or fails with ErrNotFound. Its schedules, API keys
+// and subscription renewal stay off.
+func (s *OfficeService) RestoreOffice(ctx context.Context, userID, officeID uuid.UUID) (*domain.Office, error) {
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if office.UserID != userID || office.DeletedAt == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	if err := s.officeRepo.Restore(ctx, officeID, restorableSince()); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionOfficeRestore,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   officeID,
+		OfficeID:   officeID,
+		Details:    map[string]any{"name": office.Name},
+	})
+	office.DeletedAt = nil
+	office.UpdatedAt = time.Now()
+	return office, nil
+}
+
+// ownedOffice loads one of the user's offices that is not deleted. Offices
+// owned by someone else are reported as not found.
+func (s *OfficeService) ownedOffice(ctx context.Context, userID, officeID uuid.UUID) (*domain.Office, error) {
+	office, err := s.officeRepo.GetByID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if office.UserID != userID || office.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	return office, nil
+}
+
+// officeLocation returns the timezone an office counts days in, UTC if it
+// has none or it is unknown
+func officeLocation(office *domain.Office) *time.Location {
+	if office.Timezone != "" {
+		if loc, err := time.LoadLocation(office.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// OnboardingService hires curated starter teams into offices. Applying a
+// preset hires its members, starts a group conversation with them and posts
+// its welcome message in one transaction, so a failed onboarding leaves the
+// office as it was.
+type OnboardingService struct {
+	agentTemplateRepo domain.AgentTemplateRepository
+	agentRepo         domain.AgentRepository
+	txManager         domain.TxManager
+	agentService      *AgentService
+	chatService       *ChatService
+	presets           []domain.OnboardingPreset
+}
+
+// NewOnboardingService creates a new OnboardingService instance offering
+// the presets of the file at presetsPath. Without the file, or with an
+// invalid one, which is logged, no presets are offered.
+func NewOnboardingService(
+	agentTemplateRepo domain.AgentTemplateRepository,
+	agentRepo domain.AgentRepository,
+	txManager domain.TxManager,
+	agentService *AgentService,
+	chatService *ChatService,
+	presetsPath string,
+) *OnboardingService {
+	presets, err := readOnboardingPresets(presetsPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Printf("Onboarding presets file %s not found, offering no presets", presetsPath)
+	case err != nil:
+		log.Printf("Invalid onboarding presets file %s, offering no presets: %v", presetsPath, err)
+	}
+	return &OnboardingService{
+		agentTemplateRepo: agentTemplateRepo,
+		agentRepo:         agentRepo,
+		txManager:         txManager,
+		agentService:      agentService,
+		chatService:       chatService,
+		presets:           presets,
+	}
+}
+
+// GetPresets returns the presets offered, in the file's order
+func (s *OnboardingService) GetPresets() []domain.OnboardingPreset {
+	return s.presets
+}
+
+// ApplyPresetInput selects the preset to hire into an office
+type ApplyPresetInput struct {
+	OfficeID uuid.UUID
+	Preset   string
+}
+
+// OnboardingResult is a hired starter team: all of its agents, those the
+// office already had included, and its conversation with the welcome message
+type OnboardingResult struct {
+	Agents       []*domain.Agent      `json:"agents"`
+	Conversation *domain.Conversation `json:"conversation"`
+	Welcome      *domain.Message      `json:"welcome"`
+}
+
+// ApplyPreset hires a preset's team into the office. Members whose template
+// the office already has an agent from are not hired again; that agent
+// joins the conversation instead. It fails with ErrTierLimitExceeded when the new
+// agents do not fit the office's tier.
+func (s *OnboardingService) ApplyPreset(ctx context.Context, input ApplyPresetInput) (*OnboardingResult, error) {
+	preset, ok := s.preset(input.Preset)
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	selections := make([]AgentSelection, len(preset.Members))
+	for i, member := range preset.Members {
+		template, err := s.agentTemplateRepo.GetByRole(ctx, member.Role)
+		if err != nil {
+			// The presets file names a template that is not installed
+			return nil, fmt.Errorf("onboarding preset %s: template for role %s: %v", preset.Key, member.Role, err)
+		}
+		selections[i] = AgentSelection{TemplateID: template.ID, CustomName: member.Name}
+	}
+
+	result := &OnboardingResult{}
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		hired, err := s.agentService.SelectMultipleAgents(ctx, SelectMultipleAgentsInput{OfficeID: input.OfficeID, Agents: selections})
+		if err != nil {
+			return err
+		}
+		// Reloaded so the team includes the agents the office already had
+		agents, err := s.agentRepo.GetByOfficeID(ctx, input.OfficeID)
+		if err != nil {
+			return err
+		}
+		for _, selection := range selections {
+			agent := teamAgent(hired.Agents, agents, selection.TemplateID)
+			if agent == nil {
+				return fmt.Errorf("onboarding preset %s: no agent from template %s", preset.Key, selection.TemplateID)
+			}
+			result.Agents = append(result.Agents, agent)
+		}
+
+		agentIDs := make([]uuid.UUID, len(result.Agents))
+		for i, agent := range result.Agents {
+			agentIDs[i] = agent.ID
+		}
+		result.Conversation, err = s.chatService.CreateConversation(ctx, CreateConversationInput{
+			OfficeID: input.OfficeID,
+			Type:     domain.ConversationTypeGroup,
+			Name:     preset.ConversationName,
+			AgentIDs: agentIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Welcome, err = s.chatService.SendMessage(ctx, SendMessageInput{
+			OfficeID:       input.OfficeID,
+			ConversationID: result.Conversation.ID,
+			SenderType:     domain.SenderTypeAgent,
+			SenderID:       result.Agents[0].ID,
+			Content:        preset.Welcome,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// preset returns the offered preset with the key
+func (s *OnboardingService) preset(key string) (domain.OnboardingPreset, bool) {
+	for _, preset := range s.presets {
+		if preset.Key == key {
+			return preset, true
+		}
+	}
+	return domain.OnboardingPreset{}, false
+}
+
+// teamAgent returns the agent from the template, preferring one just hired
+func teamAgent(hired, agents []*domain.Agent, templateID uuid.UUID) *domain.Agent {
+	for _, agent := range hired {
+		if agent.TemplateID == templateID {
+			return agent
+		}
+	}
+	for _, agent := range agents {
+		if agent.TemplateID == templateID {
+			return agent
+		}
+	}
+	return nil
+}
+
+// readOnboardingPresets reads and validates a presets file
+func readOnboardingPresets(path string) ([]domain.OnboardingPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseOnboardingPresets(data)
+}
+
+// parseOnboardingPresets parses and validates the YAML of a presets file
+func parseOnboardingPresets(data []byte) ([]domain.OnboardingPreset, error) {
+	var file struct {
+		Presets []domain.OnboardingPreset `yaml:"presets"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	keys := make(map[string]bool, len(file.Presets))
+	for i := range file.Presets {
+		preset := &file.Presets[i]
+		preset.Welcome = strings.TrimSpace(preset.Welcome)
+		if preset.Key == "" || preset.Name == "" || preset.Welcome == "" {
+			return nil, fmt.Errorf("%w: preset %d needs a key, a name and a welcome message", domain.ErrInvalidInput, i+1)
+		}
+		if keys[preset.Key] {
+			return nil, fmt.Errorf("%w: preset %s is defined twice", domain.ErrInvalidInput, preset.Key)
+		}
+		keys[preset.Key] = true
+		if preset.ConversationName == "" {
+			preset.ConversationName = preset.Name
+		}
+
+		if len(preset.Members) == 0 {
+			return nil, fmt.Errorf("%w: preset %s has no members", domain.ErrInvalidInput, preset.Key)
+		}
+		roles := make(map[string]bool, len(preset.Members))
+		for _, member := range preset.Members {
+			if member.Role == "" || roles[member.Role] {
+				return nil, fmt.Errorf("%w: preset %s needs a different role for each member", domain.ErrInvalidInput, preset.Key)
+			}
+			if len(member.Name) > maxAgentNameLength {
+				return nil, fmt.Errorf("%w: preset %s names an agent longer than %d characters", domain.ErrInvalidInput, preset.Key, maxAgentNameLength)
+			}
+			roles[member.Role] = true
+		}
+	}
+	return file.Presets, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+func TestReadOnboardingPresetsShippedFile(t *testing.T) {
+	presets, err := readOnboardingPresets("../config/onboarding_presets.yaml")
+	if err != nil {
+		t.Fatalf("readOnboardingPresets: %v", err)
+	}
+	if len(presets) == 0 || presets[0].Key != "startup_squad" || len(presets[0].Members) != 3 {
+		t.Fatalf("presets = %+v, want the Startup Squad of three first", presets)
+	}
+	if strings.HasSuffix(presets[0].Welcome, "\n") {
+		t.Errorf("welcome = %q, want it trimmed", presets[0].Welcome)
+	}
+}
+
+func TestParseOnboardingPresetsRejectsInvalidFiles(t *testing.T) {
+	const valid = `
+presets:
+  - key: duo
+    name: Duo
+    welcome: Hello
+    members:
+      - role: Engineer
+      - role: Writer
+`
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantMsg string
+	}{
+		{"repeated role", "role: Writer", "role: Engineer", "different role"},
+		{"missing welcome", "welcome: Hello", "welcome: ' '", "welcome message"},
+		{"no members", "    members:", "    members: []\n    others:", "has no members"},
+		{"repeated key", "presets:", "presets:\n  - {key: duo, name: Duo, welcome: Hi, members: [{role: Planner}]}", "defined twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseOnboardingPresets([]byte(strings.Replace(valid, tt.old, tt.new, 1)))
+			if !errors.Is(err, domain.ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("parseOnboardingPresets error = %v, want ErrInvalidInput mentioning %q", err, tt.wantMsg)
+			}
+		})
+	}
+
+	presets, err := parseOnboardingPresets([]byte(valid))
+	if err != nil || len(presets) != 1 || presets[0].ConversationName != "Duo" {
+		t.Errorf("parseOnboardingPresets = %+v, %v; want the conversation named after the preset", presets, err)
+	}
+}
+
+func TestApplyPresetUnknownPreset(t *testing.T) {
+	svc := NewOnboardingService(nil, nil, nil, nil, nil, "../config/onboarding_presets.yaml")
+	_, err := svc.ApplyPreset(context.Background(), ApplyPresetInput{Preset: "dream_team"})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ApplyPreset error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// The parts of an agent's performance score and how much each weighs
+const (
+	feedbackWeight = 0.35
+	successWeight  = 0.35
+	latencyWeight  = 0.15
+	costWeight     = 0.15
+)
+
+const (
+	// targetLatencyMs is the average latency that scores 50 on latency;
+	// faster agents approach 100 and slower ones 0
+	targetLatencyMs = 5000
+	// targetCreditsPerTask is the average cost that scores 50 on cost
+	targetCreditsPerTask = 10
+)
+
+// PerformanceService scores an office's agents on the feedback they got,
+// how many of their tasks succeeded, how fast and how cheaply they ran
+type PerformanceService struct {
+	analyticsRepo domain.AnalyticsRepository
+	agentRepo     domain.AgentRepository
+}
+
+// NewPerformanceService creates a new PerformanceService instance
+func NewPerformanceService(analyticsRepo domain.AnalyticsRepository, agentRepo domain.AgentRepository) *PerformanceService {
+	return &PerformanceService{analyticsRepo: analyticsRepo, agentRepo: agentRepo}
+}
+
+// GetLeaderboard ranks the office's agents by their score over the last
+// days days up to now, with how their score moved since the days before
+func (s *PerformanceService) GetLeaderboard(ctx context.Context, officeID uuid.UUID, days int) (*domain.AgentLeaderboard, error) {
+	if days <= 0 {
+		days = defaultUsageDays
+	}
+	until := time.Now()
+	window := time.Duration(days) * 24 * time.Hour
+	since := until.Add(-window)
+
+	agents, err := s.agentRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.analyticsRepo.GetAgentStats(ctx, officeID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.analyticsRepo.GetAgentStats(ctx, officeID, since.Add(-window), since)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[uuid.UUID]domain.AgentStats, len(current))
+	for _, st := range current {
+		stats[st.AgentID] = st
+	}
+	previousScores := agentScores(previous)
+
+	board := make([]domain.AgentPerformance, 0, len(agents))
+	for _, agent := range agents {
+		p := domain.AgentPerformance{AgentID: agent.ID, Name: agent.GetName()}
+		if agent.Template != nil {
+			p.Role = agent.Template.Role
+		}
+		st, ok := stats[agent.ID]
+		if ok && st.TasksFinished > 0 {
+			score := scoreAgent(st)
+			p.Score = &score
+			p.TasksFinished = st.TasksFinished
+			p.SuccessRate = roundTo(float64(st.TasksSucceeded)/float64(st.TasksFinished)*100, 2)
+			if st.AvgLatencyMs != nil {
+				p.AvgLatencyMs = int(math.Round(*st.AvgLatencyMs))
+			}
+			p.CreditsPerTask = roundTo(float64(st.CreditsConsumed)/float64(st.TasksFinished), 2)
+			if prev, ok := previousScores[agent.ID]; ok {
+				change := roundTo(score.Score-prev, 1)
+				p.PreviousScore = &prev
+				p.ScoreChange = &change
+			}
+		}
+		p.FeedbackCount = st.PositiveFeedback + st.NegativeFeedback
+		board = append(board, p)
+	}
+
+	// Best score first; ties go to the busier agent and agents without a
+	// score come last
+	sort.SliceStable(board, func(i, j int) bool {
+		a, b := board[i], board[j]
+		if (a.Score == nil) != (b.Score == nil) {
+			return a.Score != nil
+		}
+		if a.Score == nil || a.Score.Score == b.Score.Score {
+			return a.TasksFinished > b.TasksFinished
+		}
+		return a.Score.Score > b.Score.Score
+	})
+	for i := range board {
+		if board[i].Score != nil {
+			board[i].Rank = i + 1
+		}
+	}
+
+	return &domain.AgentLeaderboard{Days: days, Since: since, Until: until, Agents: board}, nil
+}
+
+// agentScores scores the agents that finished tasks
+func agentScores(stats []domain.AgentStats) map[uuid.UUID]float64 {
+	scores := make(map[uuid.UUID]float64, len(stats))
+	for _, st := range stats {
+		if st.TasksFinished > 0 {
+			scores[st.AgentID] = scoreAgent(st).Score
+		}
+	}
+	return scores
+}
+
+// scoreAgent scores an agent that finished tasks. Feedback and success are
+// smoothed towards 50 so that a handful of tasks or ratings cannot make an
+// agent perfect or hopeless; without feedback an agent is neutral on it.
+func scoreAgent(st domain.AgentStats) domain.AgentScore {
+	feedback := float64(st.PositiveFeedback+1) / float64(st.PositiveFeedback+st.NegativeFeedback+2) * 100
+	if st.AvgRating != nil {
+		// Ratings of 1 to 5 count as much as thumbs up and down
+		feedback = (feedback + (*st.AvgRating-1)/4*100) / 2
+	}
+	success := float64(st.TasksSucceeded+1) / float64(st.TasksFinished+2) * 100
+	latency := 50.0
+	if st.AvgLatencyMs != nil {
+		latency = targetLatencyMs / (targetLatencyMs + *st.AvgLatencyMs) * 100
+	}
+	creditsPerTask := float64(st.CreditsConsumed) / float64(st.TasksFinished)
+	cost := targetCreditsPerTask / (targetCreditsPerTask + math.Max(creditsPerTask, 0)) * 100
+
+	return domain.AgentScore{
+		Score:    roundTo(feedback*feedbackWeight+success*successWeight+latency*latencyWeight+cost*costWeight, 1),
+		Feedback: roundTo(feedback, 1),
+		Success:  roundTo(success, 1),
+		Latency:  roundTo(latency, 1),
+		Cost:     roundTo(cost, 1),
+	}
+}
+
+// roundTo rounds x to places decimal places
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
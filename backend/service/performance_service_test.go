@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestScoreAgent(t *testing.T) {
+	latency := 5000.0
+	neutral := scoreAgent(domain.AgentStats{TasksFinished: 2, TasksSucceeded: 1, AvgLatencyMs: &latency, CreditsConsumed: 20})
+	if neutral != (domain.AgentScore{Score: 50, Feedback: 50, Success: 50, Latency: 50, Cost: 50}) {
+		t.Errorf("scoreAgent at every target = %+v, want 50 throughout", neutral)
+	}
+
+	praised := scoreAgent(domain.AgentStats{TasksFinished: 2, TasksSucceeded: 1, AvgLatencyMs: &latency, CreditsConsumed: 20, PositiveFeedback: 8})
+	if praised.Feedback != 90 || praised.Score <= neutral.Score {
+		t.Errorf("scoreAgent with 8 thumbs up = %+v, want feedback 90 and a higher score than %v", praised, neutral.Score)
+	}
+
+	// A single successful task is not a perfect record
+	if got := scoreAgent(domain.AgentStats{TasksFinished: 1, TasksSucceeded: 1}).Success; got != 66.7 {
+		t.Errorf("success of 1 of 1 tasks = %v, want 66.7", got)
+	}
+
+	rating := 5.0
+	if got := scoreAgent(domain.AgentStats{TasksFinished: 1, AvgRating: &rating}).Feedback; got != 75 {
+		t.Errorf("feedback with a 5 star rating and no thumbs = %v, want 75", got)
+	}
+}
+
+func TestGetLeaderboardRanksScoredAgents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	analytics := mocks.NewMockAnalyticsRepository(ctrl)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	svc := NewPerformanceService(analytics, agents)
+
+	officeID := uuid.New()
+	idle := &domain.Agent{ID: uuid.New(), CustomName: "Idle"}
+	good := &domain.Agent{ID: uuid.New(), CustomName: "Good", Template: &domain.AgentTemplate{Role: "Writer"}}
+	poor := &domain.Agent{ID: uuid.New(), CustomName: "Poor"}
+	agents.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return([]*domain.Agent{idle, poor, good}, nil)
+
+	gomock.InOrder(
+		analytics.EXPECT().GetAgentStats(gomock.Any(), officeID, gomock.Any(), gomock.Any()).Return([]domain.AgentStats{
+			{AgentID: good.ID, TasksFinished: 10, TasksSucceeded: 10, CreditsConsumed: 50, PositiveFeedback: 3},
+			{AgentID: poor.ID, TasksFinished: 10, TasksSucceeded: 2, CreditsConsumed: 400, NegativeFeedback: 3},
+			{AgentID: idle.ID, PositiveFeedback: 1},
+		}, nil),
+		analytics.EXPECT().GetAgentStats(gomock.Any(), officeID, gomock.Any(), gomock.Any()).Return([]domain.AgentStats{
+			{AgentID: good.ID, TasksFinished: 4, TasksSucceeded: 2, CreditsConsumed: 40},
+		}, nil),
+	)
+
+	board, err := svc.GetLeaderboard(context.Background(), officeID, 7)
+	if err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if board.Days != 7 || board.Until.Sub(board.Since).Hours() != 7*24 {
+		t.Errorf("window = %d days from %s to %s, want the last 7 days", board.Days, board.Since, board.Until)
+	}
+	if len(board.Agents) != 3 {
+		t.Fatalf("got %d agents, want 3", len(board.Agents))
+	}
+
+	first, second, last := board.Agents[0], board.Agents[1], board.Agents[2]
+	if first.AgentID != good.ID || first.Rank != 1 || first.Role != "Writer" || second.AgentID != poor.ID || second.Rank != 2 {
+		t.Errorf("ranking = %+v, %+v; want Good then Poor", first, second)
+	}
+	if first.SuccessRate != 100 || first.CreditsPerTask != 5 || first.FeedbackCount != 3 {
+		t.Errorf("Good = %+v, want all tasks succeeded at 5 credits each with 3 feedback", first)
+	}
+	if first.PreviousScore == nil || first.ScoreChange == nil || *first.ScoreChange <= 0 || second.ScoreChange != nil {
+		t.Errorf("trend = %v, %v; want Good improving and no trend for Poor", first.ScoreChange, second.ScoreChange)
+	}
+	if last.AgentID != idle.ID || last.Rank != 0 || last.Score != nil || last.FeedbackCount != 1 {
+		t.Errorf("last = %+v, want Idle unranked", last)
+	}
+}
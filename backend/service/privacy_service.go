@@ -0,0 +1,420 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/mail"
+	"github.com/google/uuid"
+)
+
+const (
+	// dataExportPollInterval is how often the export worker looks for
+	// requested exports when none were just requested
+	dataExportPollInterval = time.Minute
+	dataExportBatchSize    = 5
+	// dataExportLease is how long a claimed export is left to its worker
+	// before another retries it
+	dataExportLease = 30 * time.Minute
+	// dataExportMaxAttempts is how often an export is tried before it is
+	// given up on; dataExportRetryDelay is the wait between attempts
+	dataExportMaxAttempts = 3
+	dataExportRetryDelay  = 10 * time.Minute
+	// dataExportTTL is how long a built archive can be downloaded
+	dataExportTTL = 7 * 24 * time.Hour
+	// dataExportListLimit is how many of a user's exports are listed
+	dataExportListLimit = 20
+)
+
+// PrivacyService lets users download everything they stored and erase
+// their account. Exports are built in the background as a ZIP archive of
+// JSON files, kept in object storage for dataExportTTL and announced by
+// email.
+type PrivacyService struct {
+	exportRepo       domain.DataExportRepository
+	userRepo         domain.UserRepository
+	officeRepo       domain.OfficeRepository
+	conversationRepo domain.ConversationRepository
+	taskRepo         domain.TaskRepository
+	creditRepo       domain.CreditRepository
+	transcripts      *TranscriptService
+	storage          domain.ObjectStorage
+	mailer           domain.Mailer
+	audit            *AuditService
+	// appURL is the frontend base URL emailed links point to
+	appURL string
+	// wake tells Run that an export was requested
+	wake chan struct{}
+}
+
+// NewPrivacyService creates a new PrivacyService instance
+func NewPrivacyService(
+	exportRepo domain.DataExportRepository,
+	userRepo domain.UserRepository,
+	officeRepo domain.OfficeRepository,
+	conversationRepo domain.ConversationRepository,
+	taskRepo domain.TaskRepository,
+	creditRepo domain.CreditRepository,
+	transcripts *TranscriptService,
+	storage domain.ObjectStorage,
+	mailer domain.Mailer,
+	audit *AuditService,
+	appURL string,
+) *PrivacyService {
+	return &PrivacyService{
+		exportRepo:       exportRepo,
+		userRepo:         userRepo,
+		officeRepo:       officeRepo,
+		conversationRepo: conversationRepo,
+		taskRepo:         taskRepo,
+		creditRepo:       creditRepo,
+		transcripts:      transcripts,
+		storage:          storage,
+		mailer:           mailer,
+		audit:            audit,
+		appURL:           strings.TrimRight(appURL, "/"),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// RequestExport queues an export of the user's data. A user can only have
+// one export being built at a time.
+func (s *PrivacyService) RequestExport(ctx context.Context, userID uuid.UUID) (*domain.DataExport, error) {
+	now := time.Now()
+	export := &domain.DataExport{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Status:        domain.DataExportPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: an export is already being prepared", domain.ErrAlreadyExists)
+		}
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditActionDataExport,
+		EntityType: domain.AuditEntityDataExport,
+		EntityID:   export.ID,
+	})
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return export, nil
+}
+
+// ListExports returns the user's most recent exports, newest first
+func (s *PrivacyService) ListExports(ctx context.Context, userID uuid.UUID) ([]*domain.DataExport, error) {
+	return s.exportRepo.ListByUser(ctx, userID, dataExportListLimit)
+}
+
+// OpenExport returns a ready export of the user's and its archive. The
+// caller must close the archive.
+func (s *PrivacyService) OpenExport(ctx context.Context, userID, exportID uuid.UUID) (*domain.DataExport, io.ReadCloser, error) {
+	export, err := s.exportRepo.GetByID(ctx, exportID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.UserID != userID {
+		return nil, nil, domain.ErrNotFound
+	}
+	if export.Status != domain.DataExportReady || time.Now().After(*export.ExpiresAt) {
+		return nil, nil, fmt.Errorf("%w: the export is %s", domain.ErrNotFound, dataExportState(export))
+	}
+
+	archive, err := s.storage.Get(ctx, export.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, archive, nil
+}
+
+// dataExportState describes why an export cannot be downloaded
+func dataExportState(export *domain.DataExport) string {
+	switch export.Status {
+	case domain.DataExportPending:
+		return "still being prepared"
+	case domain.DataExportFailed:
+		return "failed"
+	default:
+		return "expired"
+	}
+}
+
+// EraseAccount closes the user's account and erases everything they
+// stored, after checking their password. Records kept for accounting, such
+// as credit transactions and marketplace earnings, are anonymized instead.
+func (s *PrivacyService) EraseAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return err
+	}
+
+	storageKeys, err := s.userRepo.Erase(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditActionAccountErase,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   user.ID,
+		Details:    map[string]any{"files_deleted": len(storageKeys)},
+	})
+
+	// Failures only leave unreferenced objects behind, so they are logged
+	for _, key := range storageKeys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete erased file %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Run builds requested exports and removes expired archives until ctx is
+// cancelled
+func (s *PrivacyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(dataExportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expire(ctx)
+		case <-s.wake:
+		}
+		s.buildDue(ctx)
+	}
+}
+
+// buildDue builds due exports in batches until none are left
+func (s *PrivacyService) buildDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		exports, err := s.exportRepo.ClaimDue(ctx, time.Now(), dataExportLease, dataExportBatchSize)
+		if err != nil {
+			log.Printf("Failed to load requested data exports: %v", err)
+			return
+		}
+
+		for _, export := range exports {
+			s.build(ctx, export)
+		}
+		if len(exports) < dataExportBatchSize {
+			return
+		}
+	}
+}
+
+// build assembles and stores one claimed export, emails the user that it
+// is ready and records the outcome
+func (s *PrivacyService) build(ctx context.Context, export *domain.DataExport) {
+	user, err := s.userRepo.GetByID(ctx, export.UserID)
+	if err == nil {
+		err = s.store(ctx, export, user)
+	}
+	if err != nil {
+		// An account closed since the request is not retried
+		var nextAttemptAt *time.Time
+		if export.Attempts < dataExportMaxAttempts && !errors.Is(err, domain.ErrNotFound) {
+			next := time.Now().Add(dataExportRetryDelay)
+			nextAttemptAt = &next
+		}
+		log.Printf("Failed to build data export %s (attempt %d): %v", export.ID, export.Attempts, err)
+		if err := s.exportRepo.MarkFailed(ctx, export.ID, "the export could not be prepared", nextAttemptAt); err != nil {
+			log.Printf("Failed to record failure of data export %s: %v", export.ID, err)
+		}
+		return
+	}
+
+	message, err := mail.Render("data_export_ready", user.Email, mail.AccountData{
+		Name: user.Name,
+		Link: s.appURL + "/office",
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, message)
+	}
+	if err != nil {
+		log.Printf("Failed to send data export email to user %s: %v", user.ID, err)
+	}
+}
+
+// store writes the user's archive to a temporary file, uploads it and
+// marks the export ready
+func (s *PrivacyService) store(ctx context.Context, export *domain.DataExport, user *domain.User) error {
+	file, err := os.CreateTemp("", "data-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := s.writeArchive(ctx, file, user); err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key := "exports/" + user.ID.String() + "/" + export.ID.String() + ".zip"
+	if err := s.storage.Put(ctx, key, file, size, "application/zip"); err != nil {
+		return err
+	}
+	return s.exportRepo.MarkReady(ctx, export.ID, key, size, time.Now().Add(dataExportTTL))
+}
+
+// writeArchive writes the user's data as a ZIP archive of JSON files:
+// profile.json and offices.json, and for each office its conversations'
+// transcripts, tasks and credit transactions
+func (s *PrivacyService) writeArchive(ctx context.Context, w io.Writer, user *domain.User) error {
+	archive := zip.NewWriter(w)
+	offices, err := s.officeRepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "profile.json", user); err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "offices.json", offices); err != nil {
+		return err
+	}
+
+	for _, office := range offices {
+		dir := "offices/" + office.ID.String() + "/"
+		conversations, err := s.conversationRepo.GetByOfficeID(ctx, office.ID, true)
+		if err != nil {
+			return err
+		}
+		for _, conversation := range conversations {
+			transcript, err := s.transcripts.GetTranscript(ctx, office.ID, conversation.ID)
+			if err != nil {
+				return err
+			}
+			if err := writeJSONFile(archive, dir+"conversations/"+conversation.ID.String()+".json", transcript); err != nil {
+				return err
+			}
+		}
+
+		tasks, err := archive.Create(dir + "tasks.json")
+		if err != nil {
+			return err
+		}
+		filter := domain.TaskFilter{OfficeID: office.ID}
+		err = writeJSONPages(tasks, func(page domain.PageRequest) ([]*domain.Task, error) {
+			return s.taskRepo.List(ctx, filter, page)
+		}, func(task *domain.Task) domain.PageCursor {
+			return domain.PageCursor{CreatedAt: task.CreatedAt, ID: task.ID}
+		})
+		if err != nil {
+			return err
+		}
+
+		wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, office.ID)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		transactions, err := archive.Create(dir + "transactions.json")
+		if err != nil {
+			return err
+		}
+		err = writeJSONPages(transactions, func(page domain.PageRequest) ([]*domain.CreditTransaction, error) {
+			return s.creditRepo.GetTransactions(ctx, wallet.ID, page)
+		}, func(tx *domain.CreditTransaction) domain.PageCursor {
+			return domain.PageCursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// writeJSONFile adds a file holding v as indented JSON to the archive
+func writeJSONFile(archive *zip.Writer, name string, v any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeJSONPages writes the rows load returns as one JSON array, loading
+// them page by page so that long histories are not held in memory
+func writeJSONPages[T any](w io.Writer, load func(domain.PageRequest) ([]T, error), cursor func(T) domain.PageCursor) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	page := domain.PageRequest{Limit: transcriptPageSize}
+	first := true
+	for {
+		batch, err := load(page)
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		if len(batch) < page.Limit {
+			break
+		}
+		next := cursor(batch[len(batch)-1])
+		page.Cursor = &next
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// expire removes the archives of expired exports from storage
+func (s *PrivacyService) expire(ctx context.Context) {
+	exports, err := s.exportRepo.GetExpired(ctx, time.Now(), dataExportBatchSize*10)
+	if err != nil {
+		log.Printf("Failed to load expired data exports: %v", err)
+		return
+	}
+	for _, export := range exports {
+		if err := s.storage.Delete(ctx, export.StorageKey); err != nil {
+			log.Printf("Failed to delete archive of data export %s: %v", export.ID, err)
+			continue
+		}
+		if err := s.exportRepo.MarkExpired(ctx, export.ID); err != nil {
+			log.Printf("Failed to mark data export %s expired: %v", export.ID, err)
+		}
+	}
+}
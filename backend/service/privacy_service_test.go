@@ -0,0 +1,199 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type privacyMocks struct {
+	exports *mocks.MockDataExportRepository
+	users   *mocks.MockUserRepository
+	offices *mocks.MockOfficeRepository
+	convs   *mocks.MockConversationRepository
+	tasks   *mocks.MockTaskRepository
+	credits *mocks.MockCreditRepository
+	storage *mocks.MockObjectStorage
+	mailer  *mocks.MockMailer
+	audit   *mocks.MockAuditRepository
+}
+
+func newTestPrivacyService(t *testing.T) (*PrivacyService, privacyMocks) {
+	ctrl := gomock.NewController(t)
+	m := privacyMocks{
+		exports: mocks.NewMockDataExportRepository(ctrl),
+		users:   mocks.NewMockUserRepository(ctrl),
+		offices: mocks.NewMockOfficeRepository(ctrl),
+		convs:   mocks.NewMockConversationRepository(ctrl),
+		tasks:   mocks.NewMockTaskRepository(ctrl),
+		credits: mocks.NewMockCreditRepository(ctrl),
+		storage: mocks.NewMockObjectStorage(ctrl),
+		mailer:  mocks.NewMockMailer(ctrl),
+		audit:   mocks.NewMockAuditRepository(ctrl),
+	}
+	svc := NewPrivacyService(m.exports, m.users, m.offices, m.convs, m.tasks, m.credits, nil, m.storage, m.mailer,
+		NewAuditService(m.audit, m.offices, m.users), "https://app.example.com/")
+	return svc, m
+}
+
+func TestRequestDataExportOneAtATime(t *testing.T) {
+	svc, m := newTestPrivacyService(t)
+	userID := uuid.New()
+
+	m.exports.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.ErrAlreadyExists)
+	if _, err := svc.RequestExport(context.Background(), userID); !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("RequestExport while one is pending error = %v, want ErrAlreadyExists", err)
+	}
+
+	m.exports.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, entry *domain.AuditEntry) error {
+			if entry.Action != domain.AuditActionDataExport || *entry.ActorID != userID {
+				t.Errorf("audit entry = %s by %v, want the user's data export", entry.Action, entry.ActorID)
+			}
+			return nil
+		})
+	export, err := svc.RequestExport(context.Background(), userID)
+	if err != nil || export.UserID != userID || export.Status != domain.DataExportPending {
+		t.Fatalf("RequestExport = %+v, %v; want a pending export of the user", export, err)
+	}
+}
+
+func TestOpenDataExportOnlyWhenReady(t *testing.T) {
+	svc, m := newTestPrivacyService(t)
+	userID := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+	ready := &domain.DataExport{ID: uuid.New(), UserID: userID, Status: domain.DataExportReady, StorageKey: "exports/a.zip", ExpiresAt: &expiresAt}
+	pending := &domain.DataExport{ID: uuid.New(), UserID: userID, Status: domain.DataExportPending}
+	m.exports.EXPECT().GetByID(gomock.Any(), ready.ID).Return(ready, nil).Times(2)
+	m.exports.EXPECT().GetByID(gomock.Any(), pending.ID).Return(pending, nil)
+
+	if _, _, err := svc.OpenExport(context.Background(), uuid.New(), ready.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("OpenExport of another user's export error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := svc.OpenExport(context.Background(), userID, pending.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("OpenExport of a pending export error = %v, want ErrNotFound", err)
+	}
+
+	m.storage.EXPECT().Get(gomock.Any(), ready.StorageKey).Return(io.NopCloser(strings.NewReader("zip")), nil)
+	if export, archive, err := svc.OpenExport(context.Background(), userID, ready.ID); err != nil || export != ready {
+		t.Errorf("OpenExport = %+v, %v; want the ready export", export, err)
+	} else {
+		archive.Close()
+	}
+}
+
+func TestEraseAccountChecksPasswordAndDeletesFiles(t *testing.T) {
+	svc, m := newTestPrivacyService(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &domain.User{ID: uuid.New(), Email: "ada@example.com", Name: "Ada", PasswordHash: string(hash)}
+	m.users.EXPECT().GetByID(gomock.Any(), user.ID).Return(user, nil).Times(2)
+
+	// Erase must not be called with the wrong password
+	if err := svc.EraseAccount(context.Background(), user.ID, "wrong"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("EraseAccount with the wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+
+	keys := []string{"offices/o/attachments/a", "exports/u/e.zip"}
+	m.users.EXPECT().Erase(gomock.Any(), user.ID).Return(keys, nil)
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, entry *domain.AuditEntry) error {
+			if entry.Action != domain.AuditActionAccountErase || entry.EntityID != user.ID {
+				t.Errorf("audit entry = %s of %s, want the account's erasure", entry.Action, entry.EntityID)
+			}
+			return nil
+		})
+	for _, key := range keys {
+		m.storage.EXPECT().Delete(gomock.Any(), key).Return(nil)
+	}
+	if err := svc.EraseAccount(context.Background(), user.ID, "correct horse"); err != nil {
+		t.Fatalf("EraseAccount: %v", err)
+	}
+}
+
+func TestBuildDataExportStoresArchive(t *testing.T) {
+	svc, m := newTestPrivacyService(t)
+	user := &domain.User{ID: uuid.New(), Email: "ada@example.com", Name: "Ada"}
+	office := &domain.Office{ID: uuid.New(), UserID: user.ID, Name: "Main"}
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: office.ID}
+	task := &domain.Task{ID: uuid.New(), OfficeID: office.ID, Input: "Summarize the report", CreatedAt: time.Now()}
+	transaction := &domain.CreditTransaction{ID: uuid.New(), WalletID: wallet.ID, Amount: -20, CreatedAt: time.Now()}
+	export := &domain.DataExport{ID: uuid.New(), UserID: user.ID, Status: domain.DataExportPending, Attempts: 1}
+
+	m.users.EXPECT().GetByID(gomock.Any(), user.ID).Return(user, nil)
+	m.offices.EXPECT().GetByUserID(gomock.Any(), user.ID).Return([]*domain.Office{office}, nil)
+	m.convs.EXPECT().GetByOfficeID(gomock.Any(), office.ID, true).Return(nil, nil)
+	m.tasks.EXPECT().List(gomock.Any(), domain.TaskFilter{OfficeID: office.ID}, gomock.Any()).Return([]*domain.Task{task}, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), office.ID).Return(wallet, nil)
+	m.credits.EXPECT().GetTransactions(gomock.Any(), wallet.ID, gomock.Any()).Return([]*domain.CreditTransaction{transaction}, nil)
+
+	var stored []byte
+	m.storage.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "application/zip").DoAndReturn(
+		func(_ context.Context, key string, body io.Reader, size int64, _ string) error {
+			if !strings.HasPrefix(key, "exports/"+user.ID.String()+"/") {
+				t.Errorf("archive stored at %s, want under the user's exports", key)
+			}
+			var err error
+			stored, err = io.ReadAll(body)
+			if int64(len(stored)) != size {
+				t.Errorf("Put size = %d, body is %d bytes", size, len(stored))
+			}
+			return err
+		})
+	m.exports.EXPECT().MarkReady(gomock.Any(), export.ID, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, _ string, _ int64, expiresAt time.Time) error {
+			if until := time.Until(expiresAt); until < dataExportTTL-time.Minute || until > dataExportTTL {
+				t.Errorf("export expires in %v, want %v", until, dataExportTTL)
+			}
+			return nil
+		})
+	m.mailer.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, email domain.Email) error {
+			if email.To != user.Email || !strings.Contains(email.Body, "https://app.example.com/office") {
+				t.Errorf("email to %s: %q, want a link to the app sent to the user", email.To, email.Body)
+			}
+			return nil
+		})
+
+	svc.build(context.Background(), export)
+
+	archive, err := zip.NewReader(bytes.NewReader(stored), int64(len(stored)))
+	if err != nil {
+		t.Fatalf("stored archive is not a ZIP: %v", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	dir := "offices/" + office.ID.String() + "/"
+	for _, name := range []string{"profile.json", "offices.json", dir + "tasks.json", dir + "transactions.json"} {
+		if files[name] == nil {
+			t.Errorf("archive has no %s", name)
+		}
+	}
+
+	contents, err := files[dir+"tasks.json"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer contents.Close()
+	var tasks []domain.Task
+	if err := json.NewDecoder(contents).Decode(&tasks); err != nil || len(tasks) != 1 || tasks[0].Input != task.Input {
+		t.Errorf("tasks.json = %+v, %v; want the office's task", tasks, err)
+	}
+}
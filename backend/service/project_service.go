@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	maxProjectNameLength        = 255
+	maxProjectDescriptionLength = 2000
+	maxBoardColumnNameLength    = 100
+	maxBoardColumns             = 20
+)
+
+// defaultBoardColumns are the columns of a project created without any
+var defaultBoardColumns = []string{"To do", "In progress", "Done"}
+
+// ProjectService manages an office's project boards: ordered columns with
+// the office's tasks placed in them as cards. Every change to a board is
+// published to the office as an EventBoardChanged.
+type ProjectService struct {
+	projectRepo domain.ProjectRepository
+	taskRepo    domain.TaskRepository
+	events      domain.EventPublisher
+}
+
+// NewProjectService creates a new ProjectService instance
+func NewProjectService(
+	projectRepo domain.ProjectRepository,
+	taskRepo domain.TaskRepository,
+	events domain.EventPublisher,
+) *ProjectService {
+	return &ProjectService{
+		projectRepo: projectRepo,
+		taskRepo:    taskRepo,
+		events:      events,
+	}
+}
+
+// CreateProjectInput contains input for creating a project. Columns name
+// its first columns in order; without any it gets "To do", "In progress"
+// and "Done".
+type CreateProjectInput struct {
+	OfficeID    uuid.UUID
+	Name        string
+	Description string
+	Columns     []string
+}
+
+// UpdateProjectInput contains changes to a project. Nil fields are left
+// as is.
+type UpdateProjectInput struct {
+	OfficeID    uuid.UUID
+	ProjectID   uuid.UUID
+	Name        *string
+	Description *string
+}
+
+// CreateProject creates a project for the office with its board
+func (s *ProjectService) CreateProject(ctx context.Context, input CreateProjectInput) (*domain.Project, error) {
+	now := time.Now()
+	project := &domain.Project{
+		ID:          uuid.New(),
+		OfficeID:    input.OfficeID,
+		Name:        strings.TrimSpace(input.Name),
+		Description: strings.TrimSpace(input.Description),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := validateProject(project); err != nil {
+		return nil, err
+	}
+
+	names := input.Columns
+	if len(names) == 0 {
+		names = defaultBoardColumns
+	}
+	if len(names) > maxBoardColumns {
+		return nil, fmt.Errorf("%w: a board can have at most %d columns", domain.ErrInvalidInput, maxBoardColumns)
+	}
+	for _, name := range names {
+		column := &domain.BoardColumn{
+			ID:        uuid.New(),
+			ProjectID: project.ID,
+			Name:      strings.TrimSpace(name),
+			CreatedAt: now,
+			Cards:     []*domain.BoardCard{},
+		}
+		if err := validateBoardColumn(column); err != nil {
+			return nil, err
+		}
+		project.Columns = append(project.Columns, column)
+	}
+
+	if err := s.projectRepo.Create(ctx, project, project.Columns); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the office already has a project named %q", domain.ErrAlreadyExists, project.Name)
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+// GetProjects returns the office's projects by name, without their boards
+func (s *ProjectService) GetProjects(ctx context.Context, officeID uuid.UUID) ([]*domain.Project, error) {
+	return s.projectRepo.GetByOfficeID(ctx, officeID)
+}
+
+// GetProject returns a project of the office, without its board
+func (s *ProjectService) GetProject(ctx context.Context, officeID, projectID uuid.UUID) (*domain.Project, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(project.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// GetBoard returns a project of the office with its columns and their
+// cards, in order
+func (s *ProjectService) GetBoard(ctx context.Context, officeID, projectID uuid.UUID) (*domain.Project, error) {
+	project, err := s.GetProject(ctx, officeID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := s.projectRepo.GetColumns(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := s.projectRepo.GetCards(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*domain.BoardColumn, len(columns))
+	for _, column := range columns {
+		column.Cards = []*domain.BoardCard{}
+		byID[column.ID] = column
+	}
+	for _, card := range cards {
+		if column, ok := byID[card.ColumnID]; ok {
+			column.Cards = append(column.Cards, card)
+		}
+	}
+	project.Columns = columns
+	if project.Columns == nil {
+		project.Columns = []*domain.BoardColumn{}
+	}
+	return project, nil
+}
+
+// UpdateProject renames a project of the office or changes its description
+func (s *ProjectService) UpdateProject(ctx context.Context, input UpdateProjectInput) (*domain.Project, error) {
+	project, err := s.GetProject(ctx, input.OfficeID, input.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		project.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		project.Description = strings.TrimSpace(*input.Description)
+	}
+	project.UpdatedAt = time.Now()
+	if err := validateProject(project); err != nil {
+		return nil, err
+	}
+
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the office already has a project named %q", domain.ErrAlreadyExists, project.Name)
+		}
+		return nil, err
+	}
+	s.publishBoardChange(ctx, project, "project_updated", nil)
+	return project, nil
+}
+
+// DeleteProject removes a project of the office with its board. Its tasks
+// are kept.
+func (s *ProjectService) DeleteProject(ctx context.Context, officeID, projectID uuid.UUID) error {
+	project, err := s.GetProject(ctx, officeID, projectID)
+	if err != nil {
+		return err
+	}
+	if err := s.projectRepo.Delete(ctx, project.ID); err != nil {
+		return err
+	}
+	s.publishBoardChange(ctx, project, "project_deleted", nil)
+	return nil
+}
+
+// CreateColumn adds a column at the end of a project's board
+func (s *ProjectService) CreateColumn(ctx context.Context, officeID, projectID uuid.UUID, name string) (*domain.BoardColumn, error) {
+	project, err := s.GetProject(ctx, officeID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	column := &domain.BoardColumn{
+		ID:        uuid.New(),
+		ProjectID: project.ID,
+		Name:      strings.TrimSpace(name),
+		CreatedAt: time.Now(),
+		Cards:     []*domain.BoardCard{},
+	}
+	if err := validateBoardColumn(column); err != nil {
+		return nil, err
+	}
+	columns, err := s.projectRepo.GetColumns(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) >= maxBoardColumns {
+		return nil, fmt.Errorf("%w: a board can have at most %d columns", domain.ErrInvalidInput, maxBoardColumns)
+	}
+
+	if err := s.projectRepo.CreateColumn(ctx, column); err != nil {
+		return nil, err
+	}
+	s.publishBoardChange(ctx, project, "column_created", map[string]any{
+		"column_id": column.ID.String(),
+	})
+	return column, nil
+}
+
+// UpdateColumnInput contains changes to a board column. Nil fields are
+// left as is; Position moves the column, counting from 0.
+type UpdateColumnInput struct {
+	OfficeID  uuid.UUID
+	ProjectID uuid.UUID
+	ColumnID  uuid.UUID
+	Name      *string
+	Position  *int
+}
+
+// UpdateColumn renames a column of a project's board or moves it
+func (s *ProjectService) UpdateColumn(ctx context.Context, input UpdateColumnInput) (*domain.BoardColumn, error) {
+	project, column, err := s.projectColumn(ctx, input.OfficeID, input.ProjectID, input.ColumnID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		column.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Position != nil {
+		column.Position = *input.Position
+	}
+	if err := validateBoardColumn(column); err != nil {
+		return nil, err
+	}
+
+	if err := s.projectRepo.UpdateColumn(ctx, column); err != nil {
+		return nil, err
+	}
+	s.publishBoardChange(ctx, project, "column_updated", map[string]any{
+		"column_id": column.ID.String(),
+		"position":  column.Position,
+	})
+	return column, nil
+}
+
+// DeleteColumn removes a column of a project's board with its cards. Their
+// tasks are kept.
+func (s *ProjectService) DeleteColumn(ctx context.Context, officeID, projectID, columnID uuid.UUID) error {
+	project, column, err := s.projectColumn(ctx, officeID, projectID, columnID)
+	if err != nil {
+		return err
+	}
+	if err := s.projectRepo.DeleteColumn(ctx, column.ID); err != nil {
+		return err
+	}
+	s.publishBoardChange(ctx, project, "column_deleted", map[string]any{
+		"column_id": column.ID.String(),
+	})
+	return nil
+}
+
+// AddCardInput contains input for placing a task on a board. A nil
+// Position puts the card at the end of the column.
+type AddCardInput struct {
+	OfficeID  uuid.UUID
+	ProjectID uuid.UUID
+	TaskID    uuid.UUID
+	ColumnID  uuid.UUID
+	Position  *int
+}
+
+// AddCard places a task of the office in a column of a project's board. A
+// task can be a card on one board only.
+func (s *ProjectService) AddCard(ctx context.Context, input AddCardInput) (*domain.BoardCard, error) {
+	project, column, err := s.projectColumn(ctx, input.OfficeID, input.ProjectID, input.ColumnID)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.taskRepo.GetByID(ctx, input.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(task.OfficeID, input.OfficeID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	card := &domain.BoardCard{
+		TaskID:    task.ID,
+		ColumnID:  column.ID,
+		Position:  cardPosition(input.Position),
+		AddedAt:   now,
+		UpdatedAt: now,
+	}
+	if err := s.projectRepo.AddCard(ctx, card); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the task is already on a board", domain.ErrAlreadyExists)
+		}
+		return nil, err
+	}
+	card.Task = task
+
+	s.publishBoardChange(ctx, project, "card_added", map[string]any{
+		"task_id":   card.TaskID.String(),
+		"column_id": card.ColumnID.String(),
+		"position":  card.Position,
+	})
+	return card, nil
+}
+
+// MoveCardInput contains input for moving a card within its board. A nil
+// Position puts the card at the end of the column.
+type MoveCardInput struct {
+	OfficeID  uuid.UUID
+	ProjectID uuid.UUID
+	TaskID    uuid.UUID
+	ColumnID  uuid.UUID
+	Position  *int
+}
+
+// MoveCard moves a card to a column of the same board and a position in it
+func (s *ProjectService) MoveCard(ctx context.Context, input MoveCardInput) (*domain.BoardCard, error) {
+	project, card, err := s.projectCard(ctx, input.OfficeID, input.ProjectID, input.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	column, err := s.projectRepo.GetColumn(ctx, input.ColumnID)
+	if err != nil {
+		return nil, err
+	}
+	if column.ProjectID != project.ID {
+		return nil, fmt.Errorf("%w: cards can only move between columns of their board", domain.ErrInvalidInput)
+	}
+
+	fromColumn := card.ColumnID
+	card.ColumnID = column.ID
+	card.Position = cardPosition(input.Position)
+	if err := s.projectRepo.MoveCard(ctx, card); err != nil {
+		return nil, err
+	}
+
+	s.publishBoardChange(ctx, project, "card_moved", map[string]any{
+		"task_id":        card.TaskID.String(),
+		"from_column_id": fromColumn.String(),
+		"column_id":      card.ColumnID.String(),
+		"position":       card.Position,
+	})
+	return card, nil
+}
+
+// RemoveCard takes a task off a project's board. The task is kept.
+func (s *ProjectService) RemoveCard(ctx context.Context, officeID, projectID, taskID uuid.UUID) error {
+	project, card, err := s.projectCard(ctx, officeID, projectID, taskID)
+	if err != nil {
+		return err
+	}
+	if err := s.projectRepo.RemoveCard(ctx, card.TaskID); err != nil {
+		return err
+	}
+	s.publishBoardChange(ctx, project, "card_removed", map[string]any{
+		"task_id":   card.TaskID.String(),
+		"column_id": card.ColumnID.String(),
+	})
+	return nil
+}
+
+// projectColumn returns a project of the office and one of its columns
+func (s *ProjectService) projectColumn(ctx context.Context, officeID, projectID, columnID uuid.UUID) (*domain.Project, *domain.BoardColumn, error) {
+	project, err := s.GetProject(ctx, officeID, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	column, err := s.projectRepo.GetColumn(ctx, columnID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if column.ProjectID != project.ID {
+		return nil, nil, domain.ErrNotFound
+	}
+	return project, column, nil
+}
+
+// projectCard returns a project of the office and the card of a task on
+// its board
+func (s *ProjectService) projectCard(ctx context.Context, officeID, projectID, taskID uuid.UUID) (*domain.Project, *domain.BoardCard, error) {
+	project, err := s.GetProject(ctx, officeID, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	card, err := s.projectRepo.GetCard(ctx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	column, err := s.projectRepo.GetColumn(ctx, card.ColumnID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if column.ProjectID != project.ID {
+		return nil, nil, domain.ErrNotFound
+	}
+	return project, card, nil
+}
+
+// publishBoardChange tells the office's clients how a project's board
+// changed, so they can refetch or patch it
+func (s *ProjectService) publishBoardChange(ctx context.Context, project *domain.Project, change string, details map[string]any) {
+	payload := map[string]any{
+		"project_id": project.ID.String(),
+		"change":     change,
+	}
+	for key, value := range details {
+		payload[key] = value
+	}
+	if err := s.events.Publish(ctx, domain.NewEvent(project.OfficeID, domain.EventBoardChanged, payload)); err != nil {
+		log.Printf("Failed to publish change to board of project %s: %v", project.ID, err)
+	}
+}
+
+// cardPosition returns the position to place a card at; past the end of
+// a column, which an unset position is, places it last
+func cardPosition(position *int) int {
+	if position == nil {
+		return -1
+	}
+	return *position
+}
+
+func validateProject(project *domain.Project) error {
+	if project.Name == "" || utf8.RuneCountInString(project.Name) > maxProjectNameLength {
+		return fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxProjectNameLength)
+	}
+	if utf8.RuneCountInString(project.Description) > maxProjectDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", domain.ErrInvalidInput, maxProjectDescriptionLength)
+	}
+	return nil
+}
+
+func validateBoardColumn(column *domain.BoardColumn) error {
+	if column.Name == "" || utf8.RuneCountInString(column.Name) > maxBoardColumnNameLength {
+		return fmt.Errorf("%w: column name is required and must be at most %d characters", domain.ErrInvalidInput, maxBoardColumnNameLength)
+	}
+	if column.Position < 0 {
+		return fmt.Errorf("%w: position must not be negative", domain.ErrInvalidInput)
+	}
+	return nil
+}
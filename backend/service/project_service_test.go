@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMoveCardStaysOnItsBoard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	projects := mocks.NewMockProjectRepository(ctrl)
+	events := mocks.NewMockEventPublisher(ctrl)
+	svc := NewProjectService(projects, nil, events)
+	ctx := context.Background()
+
+	officeID := uuid.New()
+	project := &domain.Project{ID: uuid.New(), OfficeID: officeID}
+	todo := &domain.BoardColumn{ID: uuid.New(), ProjectID: project.ID}
+	done := &domain.BoardColumn{ID: uuid.New(), ProjectID: project.ID}
+	elsewhere := &domain.BoardColumn{ID: uuid.New(), ProjectID: uuid.New()}
+	card := &domain.BoardCard{TaskID: uuid.New(), ColumnID: todo.ID}
+
+	projects.EXPECT().GetByID(gomock.Any(), project.ID).AnyTimes().Return(project, nil)
+	projects.EXPECT().GetCard(gomock.Any(), card.TaskID).AnyTimes().Return(card, nil)
+	projects.EXPECT().GetColumn(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, id uuid.UUID) (*domain.BoardColumn, error) {
+			for _, column := range []*domain.BoardColumn{todo, done, elsewhere} {
+				if column.ID == id {
+					return column, nil
+				}
+			}
+			return nil, domain.ErrNotFound
+		})
+
+	_, err := svc.MoveCard(ctx, MoveCardInput{OfficeID: officeID, ProjectID: project.ID, TaskID: card.TaskID, ColumnID: elsewhere.ID})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("MoveCard to another board error = %v, want ErrInvalidInput", err)
+	}
+	_, err = svc.MoveCard(ctx, MoveCardInput{OfficeID: uuid.New(), ProjectID: project.ID, TaskID: card.TaskID, ColumnID: done.ID})
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("MoveCard on another office's board error = %v, want ErrNotFound", err)
+	}
+
+	var published domain.Event
+	projects.EXPECT().MoveCard(gomock.Any(), gomock.Any()).Return(nil)
+	events.EXPECT().Publish(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, event domain.Event) error {
+			published = event
+			return nil
+		})
+	moved, err := svc.MoveCard(ctx, MoveCardInput{OfficeID: officeID, ProjectID: project.ID, TaskID: card.TaskID, ColumnID: done.ID})
+	if err != nil || moved.ColumnID != done.ID {
+		t.Fatalf("MoveCard = %+v, %v; want a card in Done", moved, err)
+	}
+	if published.Type != domain.EventBoardChanged || published.Payload["change"] != "card_moved" ||
+		published.Payload["from_column_id"] != todo.ID.String() {
+		t.Errorf("published %+v, want a card_moved board change from To do", published)
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// promoCodePattern is what promo codes look like once upper-cased
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// Promo code limits
+const (
+	maxPromoBonusCredits    = 1_000_000
+	maxPromoDiscountPeriods = 36
+)
+
+// errPromoCodeNotFound hides whether a code entered by a user exists but
+// cannot be redeemed
+var errPromoCodeNotFound = fmt.Errorf("%w: promo code not found", domain.ErrNotFound)
+
+// PromoService runs promotional campaigns: admins define promo codes that
+// grant bonus credits or a subscription discount, and offices redeem them
+// once each
+type PromoService struct {
+	promoRepo     domain.PromoCodeRepository
+	subRepo       domain.SubscriptionRepository
+	creditRepo    domain.CreditRepository
+	creditService *CreditService
+	txManager     domain.TxManager
+	audit         *AuditService
+}
+
+// NewPromoService creates a new PromoService instance
+func NewPromoService(
+	promoRepo domain.PromoCodeRepository,
+	subRepo domain.SubscriptionRepository,
+	creditRepo domain.CreditRepository,
+	creditService *CreditService,
+	txManager domain.TxManager,
+	audit *AuditService,
+) *PromoService {
+	return &PromoService{
+		promoRepo:     promoRepo,
+		subRepo:       subRepo,
+		creditRepo:    creditRepo,
+		creditService: creditService,
+		txManager:     txManager,
+		audit:         audit,
+	}
+}
+
+// NewPromoCode is what an admin sets when creating a promo code
+type NewPromoCode struct {
+	Code            string
+	Description     string
+	Kind            domain.PromoCodeKind
+	BonusCredits    int64
+	DiscountPercent int
+	DiscountPeriods int
+	MaxRedemptions  *int
+	StartsAt        *time.Time
+	ExpiresAt       *time.Time
+}
+
+// CreateCode validates and stores a new promo code. Codes are
+// case-insensitive and stored upper-cased.
+func (s *PromoService) CreateCode(ctx context.Context, adminID uuid.UUID, input NewPromoCode) (*domain.PromoCode, error) {
+	code := &domain.PromoCode{
+		ID:             uuid.New(),
+		Code:           normalizePromoCode(input.Code),
+		Description:    strings.TrimSpace(input.Description),
+		Kind:           input.Kind,
+		MaxRedemptions: input.MaxRedemptions,
+		StartsAt:       input.StartsAt,
+		ExpiresAt:      input.ExpiresAt,
+		CreatedBy:      &adminID,
+		CreatedAt:      time.Now(),
+	}
+	if !promoCodePattern.MatchString(code.Code) {
+		return nil, fmt.Errorf("%w: code must be 3 to 32 letters, digits, dashes or underscores", domain.ErrInvalidInput)
+	}
+
+	switch input.Kind {
+	case domain.PromoKindBonusCredits:
+		if input.BonusCredits <= 0 || input.BonusCredits > maxPromoBonusCredits {
+			return nil, fmt.Errorf("%w: bonus_credits must be between 1 and %d", domain.ErrInvalidInput, maxPromoBonusCredits)
+		}
+		code.BonusCredits = input.BonusCredits
+	case domain.PromoKindSubscriptionDiscount:
+		if input.DiscountPercent <= 0 || input.DiscountPercent > 100 {
+			return nil, fmt.Errorf("%w: discount_percent must be between 1 and 100", domain.ErrInvalidInput)
+		}
+		if input.DiscountPeriods <= 0 || input.DiscountPeriods > maxPromoDiscountPeriods {
+			return nil, fmt.Errorf("%w: discount_periods must be between 1 and %d", domain.ErrInvalidInput, maxPromoDiscountPeriods)
+		}
+		code.DiscountPercent = input.DiscountPercent
+		code.DiscountPeriods = input.DiscountPeriods
+	default:
+		return nil, fmt.Errorf("%w: kind must be %s or %s", domain.ErrInvalidInput,
+			domain.PromoKindBonusCredits, domain.PromoKindSubscriptionDiscount)
+	}
+
+	if code.MaxRedemptions != nil && *code.MaxRedemptions <= 0 {
+		return nil, fmt.Errorf("%w: max_redemptions must be positive", domain.ErrInvalidInput)
+	}
+	if code.ExpiresAt != nil {
+		if !code.ExpiresAt.After(code.CreatedAt) {
+			return nil, fmt.Errorf("%w: expires_at must be in the future", domain.ErrInvalidInput)
+		}
+		if code.StartsAt != nil && !code.ExpiresAt.After(*code.StartsAt) {
+			return nil, fmt.Errorf("%w: expires_at must be after starts_at", domain.ErrInvalidInput)
+		}
+	}
+
+	if err := s.promoRepo.Create(ctx, code); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: promo code %s exists", domain.ErrAlreadyExists, code.Code)
+		}
+		return nil, fmt.Errorf("failed to create promo code: %w", err)
+	}
+	return code, nil
+}
+
+// ListCodes returns a page of the promo codes, newest first, and how many
+// there are
+func (s *PromoService) ListCodes(ctx context.Context, limit, offset int) ([]*domain.PromoCode, int, error) {
+	return s.promoRepo.List(ctx, limit, offset)
+}
+
+// ListRedemptions returns a page of a promo code's redemptions, newest
+// first, and how many there are
+func (s *PromoService) ListRedemptions(ctx context.Context, codeID uuid.UUID, limit, offset int) ([]*domain.PromoRedemption, int, error) {
+	if _, err := s.promoRepo.GetByID(ctx, codeID); err != nil {
+		return nil, 0, err
+	}
+	return s.promoRepo.ListRedemptions(ctx, codeID, limit, offset)
+}
+
+// DeactivateCode ends a promo code's campaign before it expires. Discounts
+// already redeemed keep applying.
+func (s *PromoService) DeactivateCode(ctx context.Context, codeID uuid.UUID) (*domain.PromoCode, error) {
+	if err := s.promoRepo.Deactivate(ctx, codeID); err != nil {
+		return nil, err
+	}
+	return s.promoRepo.GetByID(ctx, codeID)
+}
+
+// GetOfficeRedemptions returns the promo codes the office redeemed, newest
+// first
+func (s *PromoService) GetOfficeRedemptions(ctx context.Context, officeID uuid.UUID) ([]*domain.PromoRedemption, error) {
+	return s.promoRepo.GetByOfficeID(ctx, officeID)
+}
+
+// Redeem redeems a promo code for the office. A bonus credits code adds a
+// bonus transaction referring to the code to the office's wallet; a
+// subscription discount code takes its percentage off the subscription
+// price of the office's next invoices. Each office and each user redeems a
+// code once.
+func (s *PromoService) Redeem(ctx context.Context, officeID, userID uuid.UUID, rawCode string) (*domain.PromoRedemption, error) {
+	normalized := normalizePromoCode(rawCode)
+	if !promoCodePattern.MatchString(normalized) {
+		return nil, errPromoCodeNotFound
+	}
+	code, err := s.promoRepo.GetByCode(ctx, normalized)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, errPromoCodeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	if err := checkRedeemable(code, time.Now()); err != nil {
+		return nil, err
+	}
+	if code.Kind == domain.PromoKindSubscriptionDiscount {
+		if err := s.checkDiscountable(ctx, officeID); err != nil {
+			return nil, err
+		}
+	}
+
+	redemption := &domain.PromoRedemption{
+		ID:          uuid.New(),
+		PromoCodeID: code.ID,
+		Code:        code.Code,
+		Kind:        code.Kind,
+		OfficeID:    officeID,
+		UserID:      &userID,
+		CreatedAt:   time.Now(),
+	}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		switch code.Kind {
+		case domain.PromoKindBonusCredits:
+			wallet, err := s.creditService.EnsureWallet(ctx, officeID)
+			if err != nil {
+				return fmt.Errorf("failed to get wallet: %w", err)
+			}
+			tx, err := s.creditRepo.AddCredits(ctx, wallet.ID, code.BonusCredits, domain.TransactionTypeBonus,
+				fmt.Sprintf("Promo code %s", code.Code), "promo_code", &code.ID)
+			if err != nil {
+				return fmt.Errorf("failed to add bonus credits: %w", err)
+			}
+			redemption.BonusCredits = code.BonusCredits
+			redemption.CreditTransactionID = &tx.ID
+		case domain.PromoKindSubscriptionDiscount:
+			redemption.DiscountPercent = code.DiscountPercent
+			redemption.DiscountPeriodsLeft = code.DiscountPeriods
+		}
+
+		err := s.promoRepo.Redeem(ctx, redemption)
+		switch {
+		case errors.Is(err, domain.ErrAlreadyExists):
+			return fmt.Errorf("%w: promo code %s was already redeemed by this office or user", domain.ErrAlreadyExists, code.Code)
+		case errors.Is(err, domain.ErrNotFound):
+			// Used up or ended since it was checked
+			return fmt.Errorf("%w: promo code %s is no longer available", domain.ErrInvalidInput, code.Code)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionPromoRedeem,
+		ActorID:    userID,
+		EntityType: domain.AuditEntityPromoCode,
+		EntityID:   code.ID,
+		OfficeID:   officeID,
+		Details: map[string]any{
+			"code":             code.Code,
+			"kind":             code.Kind,
+			"bonus_credits":    redemption.BonusCredits,
+			"discount_percent": redemption.DiscountPercent,
+			"discount_periods": redemption.DiscountPeriodsLeft,
+		},
+	})
+	return redemption, nil
+}
+
+// checkRedeemable rejects codes that are deactivated, outside their
+// campaign or used up at now
+func checkRedeemable(code *domain.PromoCode, now time.Time) error {
+	switch {
+	case code.DeactivatedAt != nil:
+		return fmt.Errorf("%w: promo code %s is no longer available", domain.ErrInvalidInput, code.Code)
+	case code.StartsAt != nil && now.Before(*code.StartsAt):
+		return fmt.Errorf("%w: promo code %s is not valid yet", domain.ErrInvalidInput, code.Code)
+	case code.ExpiresAt != nil && !now.Before(*code.ExpiresAt):
+		return fmt.Errorf("%w: promo code %s has expired", domain.ErrInvalidInput, code.Code)
+	case code.MaxRedemptions != nil && code.Redemptions >= *code.MaxRedemptions:
+		return fmt.Errorf("%w: promo code %s is no longer available", domain.ErrInvalidInput, code.Code)
+	}
+	return nil
+}
+
+// checkDiscountable rejects subscription discounts for offices whose
+// invoices are not issued here: those without a subscription and those
+// billed through Stripe. An office has one discount at a time.
+func (s *PromoService) checkDiscountable(ctx context.Context, officeID uuid.UUID) error {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("%w: the office has no subscription to discount", domain.ErrInvalidInput)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if sub.StripeSubscriptionID != "" {
+		return fmt.Errorf("%w: subscriptions billed through Stripe cannot be discounted with promo codes", domain.ErrInvalidInput)
+	}
+
+	_, err = s.promoRepo.GetActiveDiscount(ctx, officeID)
+	if err == nil {
+		return fmt.Errorf("%w: the office already has a subscription discount", domain.ErrInvalidInput)
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("failed to get subscription discount: %w", err)
+	}
+	return nil
+}
+
+// normalizePromoCode makes codes case-insensitive
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
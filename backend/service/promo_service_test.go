@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// promoMocks are the repositories behind a PromoService under test
+type promoMocks struct {
+	promos  *mocks.MockPromoCodeRepository
+	subs    *mocks.MockSubscriptionRepository
+	credits *mocks.MockCreditRepository
+	audit   *mocks.MockAuditRepository
+}
+
+func newTestPromoService(t *testing.T) (*PromoService, promoMocks) {
+	ctrl := gomock.NewController(t)
+	m := promoMocks{
+		promos:  mocks.NewMockPromoCodeRepository(ctrl),
+		subs:    mocks.NewMockSubscriptionRepository(ctrl),
+		credits: mocks.NewMockCreditRepository(ctrl),
+		audit:   mocks.NewMockAuditRepository(ctrl),
+	}
+
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+
+	offices := mocks.NewMockOfficeRepository(ctrl)
+	creditService := NewCreditService(m.credits, offices, mocks.NewMockIdempotencyRepository(ctrl), newTestTxManager(ctrl), nil)
+	audit := NewAuditService(m.audit, offices, mocks.NewMockUserRepository(ctrl))
+	return NewPromoService(m.promos, m.subs, m.credits, creditService, txManager, audit), m
+}
+
+func TestRedeemBonusCodeAddsBonusCredits(t *testing.T) {
+	svc, m := newTestPromoService(t)
+	officeID, userID := uuid.New(), uuid.New()
+	code := &domain.PromoCode{ID: uuid.New(), Code: "LAUNCH", Kind: domain.PromoKindBonusCredits, BonusCredits: 2500}
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+	bonus := &domain.CreditTransaction{ID: uuid.New()}
+
+	// Codes are matched case-insensitively
+	m.promos.EXPECT().GetByCode(gomock.Any(), "LAUNCH").Return(code, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	// The bonus refers to the code, so the campaign's credits can be traced
+	m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, int64(2500), domain.TransactionTypeBonus,
+		"Promo code LAUNCH", "promo_code", &code.ID).Return(bonus, nil)
+	m.promos.EXPECT().Redeem(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, redemption *domain.PromoRedemption) error {
+			if redemption.CreditTransactionID == nil || *redemption.CreditTransactionID != bonus.ID ||
+				redemption.UserID == nil || *redemption.UserID != userID || redemption.OfficeID != officeID {
+				t.Errorf("redemption = %+v, want the office's and user's with the bonus transaction", redemption)
+			}
+			return nil
+		})
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+	redemption, err := svc.Redeem(context.Background(), officeID, userID, " launch ")
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if redemption.BonusCredits != 2500 {
+		t.Errorf("bonus credits = %d, want 2500", redemption.BonusCredits)
+	}
+}
+
+func TestRedeemDiscountCode(t *testing.T) {
+	svc, m := newTestPromoService(t)
+	officeID := uuid.New()
+	code := &domain.PromoCode{
+		ID: uuid.New(), Code: "SPRING25", Kind: domain.PromoKindSubscriptionDiscount,
+		DiscountPercent: 25, DiscountPeriods: 3,
+	}
+
+	m.promos.EXPECT().GetByCode(gomock.Any(), "SPRING25").Return(code, nil)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(&domain.Subscription{ID: uuid.New(), OfficeID: officeID}, nil)
+	m.promos.EXPECT().GetActiveDiscount(gomock.Any(), officeID).Return(nil, domain.ErrNotFound)
+	// No credits are added
+	m.promos.EXPECT().Redeem(gomock.Any(), gomock.Any()).Return(nil)
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+	redemption, err := svc.Redeem(context.Background(), officeID, uuid.New(), "spring25")
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if redemption.DiscountPercent != 25 || redemption.DiscountPeriodsLeft != 3 {
+		t.Errorf("redemption = %+v, want 25%% off 3 invoices", redemption)
+	}
+}
+
+func TestRedeemRejected(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	limit := 100
+	bonus := func(edit func(*domain.PromoCode)) *domain.PromoCode {
+		code := &domain.PromoCode{ID: uuid.New(), Code: "LAUNCH", Kind: domain.PromoKindBonusCredits, BonusCredits: 100}
+		edit(code)
+		return code
+	}
+	tests := []struct {
+		name string
+		code *domain.PromoCode
+		want error
+	}{
+		{"unknown", nil, domain.ErrNotFound},
+		{"deactivated", bonus(func(c *domain.PromoCode) { c.DeactivatedAt = &past }), domain.ErrInvalidInput},
+		{"not started", bonus(func(c *domain.PromoCode) { c.StartsAt = &future }), domain.ErrInvalidInput},
+		{"expired", bonus(func(c *domain.PromoCode) { c.ExpiresAt = &past }), domain.ErrInvalidInput},
+		{"used up", bonus(func(c *domain.PromoCode) { c.MaxRedemptions, c.Redemptions = &limit, limit }), domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestPromoService(t)
+
+			// Nothing is redeemed in any of these cases
+			if tt.code != nil {
+				m.promos.EXPECT().GetByCode(gomock.Any(), "LAUNCH").Return(tt.code, nil)
+			} else {
+				m.promos.EXPECT().GetByCode(gomock.Any(), "LAUNCH").Return(nil, domain.ErrNotFound)
+			}
+
+			_, err := svc.Redeem(context.Background(), uuid.New(), uuid.New(), "LAUNCH")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Redeem error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemDiscountBilledThroughStripe(t *testing.T) {
+	svc, m := newTestPromoService(t)
+	officeID := uuid.New()
+	code := &domain.PromoCode{ID: uuid.New(), Code: "SPRING25", Kind: domain.PromoKindSubscriptionDiscount, DiscountPercent: 25, DiscountPeriods: 3}
+
+	// Stripe issues these invoices, so the discount could not be applied
+	m.promos.EXPECT().GetByCode(gomock.Any(), "SPRING25").Return(code, nil)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).
+		Return(&domain.Subscription{OfficeID: officeID, StripeSubscriptionID: "sub_123"}, nil)
+
+	_, err := svc.Redeem(context.Background(), officeID, uuid.New(), "SPRING25")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Redeem error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRedeemTwiceRollsBackBonus(t *testing.T) {
+	svc, m := newTestPromoService(t)
+	officeID := uuid.New()
+	code := &domain.PromoCode{ID: uuid.New(), Code: "LAUNCH", Kind: domain.PromoKindBonusCredits, BonusCredits: 100}
+
+	// The bonus is added in the redemption's transaction, which fails
+	m.promos.EXPECT().GetByCode(gomock.Any(), "LAUNCH").Return(code, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(&domain.CreditWallet{ID: uuid.New()}, nil)
+	m.credits.EXPECT().AddCredits(gomock.Any(), gomock.Any(), int64(100), domain.TransactionTypeBonus,
+		gomock.Any(), "promo_code", &code.ID).Return(&domain.CreditTransaction{ID: uuid.New()}, nil)
+	m.promos.EXPECT().Redeem(gomock.Any(), gomock.Any()).Return(domain.ErrAlreadyExists)
+
+	_, err := svc.Redeem(context.Background(), officeID, uuid.New(), "LAUNCH")
+	if !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("Redeem error = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestCreateCodeRejected(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	zero := 0
+	tests := []struct {
+		name  string
+		input NewPromoCode
+	}{
+		{"malformed code", NewPromoCode{Code: "no spaces", Kind: domain.PromoKindBonusCredits, BonusCredits: 100}},
+		{"unknown kind", NewPromoCode{Code: "LAUNCH", Kind: "free_agents"}},
+		{"bonus without credits", NewPromoCode{Code: "LAUNCH", Kind: domain.PromoKindBonusCredits}},
+		{"discount over 100%", NewPromoCode{Code: "SPRING", Kind: domain.PromoKindSubscriptionDiscount, DiscountPercent: 120, DiscountPeriods: 1}},
+		{"discount without periods", NewPromoCode{Code: "SPRING", Kind: domain.PromoKindSubscriptionDiscount, DiscountPercent: 25}},
+		{"no redemptions", NewPromoCode{Code: "LAUNCH", Kind: domain.PromoKindBonusCredits, BonusCredits: 100, MaxRedemptions: &zero}},
+		{"already expired", NewPromoCode{Code: "LAUNCH", Kind: domain.PromoKindBonusCredits, BonusCredits: 100, ExpiresAt: &past}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestPromoService(t)
+
+			_, err := svc.CreateCode(context.Background(), uuid.New(), tt.input)
+			if !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("CreateCode error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// PurchaseBundle buys every template of a bundle with one card payment. The
+// bundle's price is split between its templates in proportion to their own
+// prices; each share is recorded as a sale of its template and grants the
+// office that template, all in one transaction. An office that already owns
+// one of the templates, or that one of their licenses does not cover, cannot
+// buy the bundle, nor can its author. The card payment must have succeeded
+// for the bundle's price. A non-empty idempotencyKey makes retries return the
+// original purchases instead of failing as a duplicate.
+func (s *EarningsService) PurchaseBundle(
+	ctx context.Context,
+	bundleID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	stripePaymentIntentID string,
+	idempotencyKey string,
+) ([]*domain.TemplatePurchase, error) {
+	key, err := newIdempotencyKey(purchaserOfficeID, domain.IdempotencyScopeBundlePurchase, idempotencyKey,
+		bundleID, stripePaymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var purchases []*domain.TemplatePurchase
+	_, replayed, err := runIdempotent(ctx, s.txManager, s.idempotencyRepo, key, func(ctx context.Context) (uuid.UUID, error) {
+		var err error
+		if purchases, err = s.purchaseBundle(ctx, bundleID, purchaserID, purchaserOfficeID, stripePaymentIntentID); err != nil {
+			return uuid.Nil, err
+		}
+		return bundleID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return s.bundlePurchases(ctx, purchaserOfficeID, bundleID)
+	}
+	return purchases, nil
+}
+
+// purchaseBundle records the sale of a bundle's templates and grants the
+// office each of them
+func (s *EarningsService) purchaseBundle(
+	ctx context.Context,
+	bundleID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	stripePaymentIntentID string,
+) ([]*domain.TemplatePurchase, error) {
+	bundle, err := s.bundleRepo.GetByID(ctx, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if !bundle.Listed {
+		return nil, domain.ErrNotFound
+	}
+	if bundle.AuthorID == purchaserID {
+		return nil, fmt.Errorf("%w: you cannot buy your own bundle", domain.ErrInvalidInput)
+	}
+
+	// Prevent paying twice for a template
+	for _, t := range bundle.Templates {
+		owned, err := s.purchaseRepo.HasPurchased(ctx, purchaserOfficeID, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		if owned {
+			return nil, fmt.Errorf("%w: the office already owns %s", domain.ErrAlreadyExists, t.Name)
+		}
+		if err := requireTemplateLicense(ctx, s.subscriptionService, purchaserOfficeID, &t); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.verifyPayment(ctx, stripePaymentIntentID, bundle.PriceCents, map[string]string{
+		"bundle_id": bundle.ID.String(),
+		"office_id": purchaserOfficeID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shares := splitBundlePrice(bundle.PriceCents, bundle.Templates)
+	now := time.Now()
+	purchases := make([]*domain.TemplatePurchase, 0, len(bundle.Templates))
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		for i := range bundle.Templates {
+			t := &bundle.Templates[i]
+			earningID, err := s.earningsRepo.RecordBundleSale(ctx,
+				bundle.AuthorID, t.ID, bundle.ID, purchaserID, purchaserOfficeID, shares[i], stripePaymentIntentID)
+			if err != nil {
+				return err
+			}
+
+			purchase := &domain.TemplatePurchase{
+				ID:          uuid.New(),
+				OfficeID:    purchaserOfficeID,
+				TemplateID:  t.ID,
+				PurchasedBy: purchaserID,
+				EarningID:   &earningID,
+				PriceCents:  shares[i],
+				Status:      domain.TemplatePurchaseStatusActive,
+				CreatedAt:   now,
+				Template:    t,
+				BundleID:    &bundle.ID,
+				License:     newLicenseAcceptance(t, now),
+			}
+			if err := s.purchaseRepo.Create(ctx, purchase); err != nil {
+				return err
+			}
+			purchases = append(purchases, purchase)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authorEarning := 0
+	for i, t := range bundle.Templates {
+		// Increment download (purchase) count
+		_ = s.marketplaceRepo.IncrementDownload(ctx, t.ID)
+		_, share := s.CalculateCommission(shares[i])
+		authorEarning += share
+	}
+
+	_, err = s.notifications.NotifyUser(ctx, bundle.AuthorID, domain.NotificationTypeMarketplaceSale,
+		fmt.Sprintf("%s was purchased", bundle.Name),
+		fmt.Sprintf("An office bought your bundle %s for %s. You earned %s.", bundle.Name, formatCents(bundle.PriceCents), formatCents(authorEarning)),
+		map[string]any{
+			"bundle_id":            bundle.ID.String(),
+			"sale_amount_cents":    bundle.PriceCents,
+			"author_earning_cents": authorEarning,
+		},
+	)
+	if err != nil {
+		// The sale is recorded; the author still sees it in their earnings
+		log.Printf("Failed to notify author of bundle %s sale: %v", bundle.ID, err)
+	}
+
+	return purchases, nil
+}
+
+// bundlePurchases returns the office's active purchases that came with a
+// bundle
+func (s *EarningsService) bundlePurchases(ctx context.Context, officeID, bundleID uuid.UUID) ([]*domain.TemplatePurchase, error) {
+	all, err := s.purchaseRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	purchases := []*domain.TemplatePurchase{}
+	for _, p := range all {
+		if p.BundleID != nil && *p.BundleID == bundleID {
+			purchases = append(purchases, p)
+		}
+	}
+	return purchases, nil
+}
+
+// splitBundlePrice divides a bundle's price between its templates in
+// proportion to their own prices. The cents left over by rounding down go
+// to the first templates, one each, so the shares add up to the price.
+func splitBundlePrice(priceCents int, templates []domain.AgentTemplate) []int {
+	shares := make([]int, len(templates))
+	var listPrice int64
+	for _, t := range templates {
+		listPrice += int64(t.PriceCents)
+	}
+	if listPrice <= 0 {
+		return shares
+	}
+
+	rest := priceCents
+	for i, t := range templates {
+		shares[i] = int(int64(priceCents) * int64(t.PriceCents) / listPrice)
+		rest -= shares[i]
+	}
+	for i := 0; rest > 0; i++ {
+		shares[i%len(shares)]++
+		rest--
+	}
+	return shares
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSplitBundlePrice(t *testing.T) {
+	tests := []struct {
+		price  int
+		prices []int
+		want   []int
+	}{
+		{3000, []int{2000, 2000}, []int{1500, 1500}},
+		{1000, []int{1500, 500}, []int{750, 250}},
+		// The cent lost rounding down goes to the first template
+		{1001, []int{1000, 1000}, []int{501, 500}},
+		{2000, []int{999, 999, 999}, []int{667, 667, 666}},
+	}
+	for _, tt := range tests {
+		templates := make([]domain.AgentTemplate, len(tt.prices))
+		for i, p := range tt.prices {
+			templates[i].PriceCents = p
+		}
+		got := splitBundlePrice(tt.price, templates)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitBundlePrice(%d, %v) = %v, want %v", tt.price, tt.prices, got, tt.want)
+		}
+	}
+}
+
+func TestPurchaseBundleRecordsEachShare(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+	authorID, purchaserID, officeID := uuid.New(), uuid.New(), uuid.New()
+	bundle := &domain.TemplateBundle{
+		ID: uuid.New(), AuthorID: authorID, Name: "Product Team", PriceCents: 3000, Listed: true,
+		Templates: []domain.AgentTemplate{
+			{ID: uuid.New(), Name: "Planner", AuthorID: &authorID, PriceCents: 3000},
+			{ID: uuid.New(), Name: "Designer", AuthorID: &authorID, PriceCents: 1000},
+		},
+	}
+
+	m.bundles.EXPECT().GetByID(gomock.Any(), bundle.ID).Return(bundle, nil)
+	m.billing.EXPECT().GetPaymentIntent(gomock.Any(), "pi_123").Return(paymentOf("pi_123", 3000, map[string]string{
+		"bundle_id": bundle.ID.String(), "office_id": officeID.String(),
+	}), nil)
+	m.earnings.EXPECT().IsPaymentIntentUsed(gomock.Any(), "pi_123").Return(false, nil)
+	var shares []int
+	for _, template := range bundle.Templates {
+		m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+		m.earnings.EXPECT().RecordBundleSale(gomock.Any(), authorID, template.ID, bundle.ID, purchaserID, officeID, gomock.Any(), "pi_123").
+			DoAndReturn(func(_ context.Context, _, _, _, _, _ uuid.UUID, cents int, _ string) (uuid.UUID, error) {
+				shares = append(shares, cents)
+				return uuid.New(), nil
+			})
+		m.marketplace.EXPECT().IncrementDownload(gomock.Any(), template.ID).Return(nil)
+	}
+	m.purchases.EXPECT().Create(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, purchase *domain.TemplatePurchase) error {
+			if purchase.BundleID == nil || *purchase.BundleID != bundle.ID || purchase.EarningID == nil {
+				t.Errorf("purchase = %+v, want it linked to the bundle and its earning", purchase)
+			}
+			return nil
+		})
+	// The author has no office to notify
+	m.offices.EXPECT().GetByUserID(gomock.Any(), authorID).Return(nil, nil)
+
+	purchases, err := svc.PurchaseBundle(ctx, bundle.ID, purchaserID, officeID, "pi_123", "")
+	if err != nil {
+		t.Fatalf("PurchaseBundle: %v", err)
+	}
+	if !slices.Equal(shares, []int{2250, 750}) {
+		t.Errorf("recorded shares %v, want the price split 3:1", shares)
+	}
+	if len(purchases) != 2 || purchases[0].PriceCents != 2250 || purchases[1].PriceCents != 750 {
+		t.Errorf("purchases = %+v, want one per template at its share", purchases)
+	}
+}
+
+func TestPurchaseBundleRejected(t *testing.T) {
+	authorID := uuid.New()
+	template := domain.AgentTemplate{ID: uuid.New(), Name: "Planner", AuthorID: &authorID, PriceCents: 3000}
+	tests := []struct {
+		name      string
+		listed    bool
+		owned     bool
+		purchaser uuid.UUID
+		want      error
+	}{
+		{"not listed", false, false, uuid.New(), domain.ErrNotFound},
+		{"template already owned", true, true, uuid.New(), domain.ErrAlreadyExists},
+		{"by its author", true, false, authorID, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestEarningsService(t)
+			officeID := uuid.New()
+			bundle := &domain.TemplateBundle{ID: uuid.New(), AuthorID: authorID, PriceCents: 2000, Listed: tt.listed,
+				Templates: []domain.AgentTemplate{template}}
+
+			// No sale is recorded in any of these cases
+			m.bundles.EXPECT().GetByID(gomock.Any(), bundle.ID).Return(bundle, nil)
+			m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(tt.owned, nil).MaxTimes(1)
+
+			_, err := svc.PurchaseBundle(context.Background(), bundle.ID, tt.purchaser, officeID, "pi_123", "")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("PurchaseBundle error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurchaseBundleRequiresAPaymentForIt(t *testing.T) {
+	authorID, officeID := uuid.New(), uuid.New()
+	template := domain.AgentTemplate{ID: uuid.New(), Name: "Planner", AuthorID: &authorID, PriceCents: 3000}
+	bundle := &domain.TemplateBundle{ID: uuid.New(), AuthorID: authorID, PriceCents: 2000, Listed: true,
+		Templates: []domain.AgentTemplate{template}}
+	metadata := map[string]string{"bundle_id": bundle.ID.String(), "office_id": officeID.String()}
+	unpaid := paymentOf("pi_123", 2000, metadata)
+	unpaid.Status = "processing"
+
+	tests := []struct {
+		name    string
+		payment *domain.PaymentIntent
+		used    bool
+		want    error
+	}{
+		{"unpaid", unpaid, false, domain.ErrPaymentDeclined},
+		{"wrong amount", paymentOf("pi_123", 3000, metadata), false, domain.ErrInvalidInput},
+		{"used for another sale", paymentOf("pi_123", 2000, metadata), true, domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestEarningsService(t)
+
+			// No sale is recorded in any of these cases
+			m.bundles.EXPECT().GetByID(gomock.Any(), bundle.ID).Return(bundle, nil)
+			m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+			m.billing.EXPECT().GetPaymentIntent(gomock.Any(), "pi_123").Return(tt.payment, nil)
+			m.earnings.EXPECT().IsPaymentIntentUsed(gomock.Any(), "pi_123").Return(tt.used, nil).MaxTimes(1)
+
+			_, err := svc.PurchaseBundle(context.Background(), bundle.ID, uuid.New(), officeID, "pi_123", "")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("PurchaseBundle error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
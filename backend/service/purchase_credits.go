@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// errCreditPurchasesDisabled rejects credit purchases when no conversion
+// rate is configured
+var errCreditPurchasesDisabled = fmt.Errorf("%w: templates cannot be bought with credits", domain.ErrInvalidInput)
+
+// CreditPrice is what a premium template costs when bought with credits
+type CreditPrice struct {
+	TemplateID       uuid.UUID `json:"template_id"`
+	PriceCents       int       `json:"price_cents"`
+	PriceCredits     int64     `json:"price_credits"`
+	CreditsPerDollar int64     `json:"credits_per_dollar"`
+}
+
+// GetCreditPrice returns what a premium template costs in credits. Like
+// purchases, it hides templates that are not listed as not found.
+func (s *EarningsService) GetCreditPrice(ctx context.Context, templateID uuid.UUID) (*CreditPrice, error) {
+	if s.creditsPerDollar <= 0 {
+		return nil, errCreditPurchasesDisabled
+	}
+	template, err := s.marketplaceRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.Status != "approved" || !template.IsPublic {
+		return nil, domain.ErrNotFound
+	}
+	if template.PriceCents < MinPriceCents {
+		return nil, fmt.Errorf("%w: template is not for sale", domain.ErrInvalidInput)
+	}
+	return &CreditPrice{
+		TemplateID:       templateID,
+		PriceCents:       template.PriceCents,
+		PriceCredits:     s.creditPrice(template.PriceCents),
+		CreditsPerDollar: s.creditsPerDollar,
+	}, nil
+}
+
+// PurchaseTemplateWithCredits buys a premium template with the office's
+// wallet credits instead of a card. The credits are consumed, the author
+// earns the template's price in cents and the office gains the template, all
+// in one transaction. A non-empty idempotencyKey makes retries return the
+// original purchase instead of failing as a duplicate.
+func (s *EarningsService) PurchaseTemplateWithCredits(
+	ctx context.Context,
+	templateID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+	idempotencyKey string,
+) (*domain.TemplatePurchase, error) {
+	if s.creditsPerDollar <= 0 {
+		return nil, errCreditPurchasesDisabled
+	}
+	key, err := newIdempotencyKey(purchaserOfficeID, domain.IdempotencyScopeCreditPurchase, idempotencyKey, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	var purchase *domain.TemplatePurchase
+	purchaseID, replayed, err := runIdempotent(ctx, s.txManager, s.idempotencyRepo, key, func(ctx context.Context) (uuid.UUID, error) {
+		var err error
+		if purchase, err = s.purchaseTemplateWithCredits(ctx, templateID, purchaserID, purchaserOfficeID); err != nil {
+			return uuid.Nil, err
+		}
+		return purchase.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return s.purchaseRepo.GetByID(ctx, purchaseID)
+	}
+	return purchase, nil
+}
+
+// purchaseTemplateWithCredits checks the office can afford the template
+// before recording the purchase and taking the credits
+func (s *EarningsService) purchaseTemplateWithCredits(
+	ctx context.Context,
+	templateID uuid.UUID,
+	purchaserID uuid.UUID,
+	purchaserOfficeID uuid.UUID,
+) (*domain.TemplatePurchase, error) {
+	template, err := s.purchasableTemplate(ctx, templateID, purchaserID, purchaserOfficeID)
+	if err != nil {
+		return nil, err
+	}
+	credits := s.creditPrice(template.PriceCents)
+
+	var balance int64
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, purchaserOfficeID)
+	switch {
+	case err == nil:
+		balance = wallet.Balance
+	case !errors.Is(err, domain.ErrNotFound):
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	if balance < credits {
+		return nil, domain.WithDetails(
+			fmt.Errorf("%w: %s costs %d credits and the office has %d",
+				domain.ErrInsufficientCredits, template.Name, credits, balance),
+			map[string]any{"price_credits": credits, "balance": balance},
+		)
+	}
+
+	return s.recordPurchase(ctx, template, purchaserID, purchaserOfficeID, "", credits)
+}
+
+// chargeCredits takes a purchase's price in credits from the office's
+// wallet. The wallet is locked while it is debited, so a balance spent in
+// the meantime fails the purchase with domain.ErrInsufficientCredits.
+func (s *EarningsService) chargeCredits(ctx context.Context, template *domain.AgentTemplate, purchase *domain.TemplatePurchase) error {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, purchase.OfficeID)
+	if err != nil {
+		return fmt.Errorf("failed to get wallet: %w", err)
+	}
+	_, err = s.creditRepo.AddCredits(ctx, wallet.ID, -purchase.PriceCredits, domain.TransactionTypeConsumption,
+		fmt.Sprintf("Purchase of %s", template.Name), "purchase", &purchase.ID)
+	return err
+}
+
+// creditPrice converts a price in cents to credits, rounding up
+func (s *EarningsService) creditPrice(priceCents int) int64 {
+	return (int64(priceCents)*s.creditsPerDollar + 99) / 100
+}
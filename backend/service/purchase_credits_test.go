@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreditPriceRoundsUp(t *testing.T) {
+	tests := []struct {
+		rate, cents int
+		want        int64
+	}{
+		{500, 1500, 7500},
+		{500, MinPriceCents, 995},
+		{333, MinPriceCents, 663},
+		{1, 1050, 11},
+	}
+	for _, tt := range tests {
+		svc := &EarningsService{creditsPerDollar: int64(tt.rate)}
+		if got := svc.creditPrice(tt.cents); got != tt.want {
+			t.Errorf("creditPrice(%d) at %d credits a dollar = %d, want %d", tt.cents, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestPurchaseTemplateWithCreditsConsumesCredits(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+	authorID, purchaserID, officeID := uuid.New(), uuid.New(), uuid.New()
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Analyst", AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true,
+	}
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID, Balance: 10000}
+	earningID := uuid.New()
+
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+	m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil).Times(2)
+	// The author earns the price in cents, as for a card sale
+	m.earnings.EXPECT().RecordSale(gomock.Any(), authorID, template.ID, purchaserID, officeID, 1500, "").
+		Return(earningID, nil)
+	var purchaseID uuid.UUID
+	m.purchases.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, purchase *domain.TemplatePurchase) error {
+			if purchase.PriceCredits != 7500 || purchase.PriceCents != 1500 {
+				t.Errorf("purchase = %+v, want 7500 credits for 1500 cents", purchase)
+			}
+			purchaseID = purchase.ID
+			return nil
+		})
+	m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, int64(-7500), domain.TransactionTypeConsumption,
+		gomock.Any(), "purchase", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ uuid.UUID, _ int64, _ domain.TransactionType, _, _ string, refID *uuid.UUID) (*domain.CreditTransaction, error) {
+			if refID == nil || *refID != purchaseID {
+				t.Errorf("consumption refers to %v, want the purchase %s", refID, purchaseID)
+			}
+			return &domain.CreditTransaction{ID: uuid.New()}, nil
+		})
+	m.marketplace.EXPECT().IncrementDownload(gomock.Any(), template.ID).Return(nil)
+	m.offices.EXPECT().GetByUserID(gomock.Any(), authorID).Return(nil, nil)
+
+	purchase, err := svc.PurchaseTemplateWithCredits(ctx, template.ID, purchaserID, officeID, "")
+	if err != nil {
+		t.Fatalf("PurchaseTemplateWithCredits: %v", err)
+	}
+	if purchase.EarningID == nil || *purchase.EarningID != earningID {
+		t.Errorf("purchase earning = %v, want %s", purchase.EarningID, earningID)
+	}
+}
+
+func TestPurchaseTemplateWithCreditsInsufficientBalance(t *testing.T) {
+	svc, m := newTestEarningsService(t)
+	authorID, officeID := uuid.New(), uuid.New()
+	template := &domain.AgentTemplate{
+		ID: uuid.New(), Name: "Analyst", AuthorID: &authorID, PriceCents: 1500, Status: "approved", IsPublic: true,
+	}
+
+	// No sale is recorded
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+	m.purchases.EXPECT().HasPurchased(gomock.Any(), officeID, template.ID).Return(false, nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(&domain.CreditWallet{ID: uuid.New(), Balance: 7499}, nil)
+
+	_, err := svc.PurchaseTemplateWithCredits(context.Background(), template.ID, uuid.New(), officeID, "")
+	if !errors.Is(err, domain.ErrInsufficientCredits) {
+		t.Fatalf("PurchaseTemplateWithCredits error = %v, want ErrInsufficientCredits", err)
+	}
+	if details := domain.ErrorDetails(err); details["price_credits"] != int64(7500) || details["balance"] != int64(7499) {
+		t.Errorf("details = %v, want the price and balance", details)
+	}
+}
+
+func TestPurchaseTemplateWithCreditsDisabled(t *testing.T) {
+	svc, _ := newTestEarningsService(t)
+	svc.creditsPerDollar = 0
+
+	_, err := svc.PurchaseTemplateWithCredits(context.Background(), uuid.New(), uuid.New(), uuid.New(), "")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("PurchaseTemplateWithCredits error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestGetCreditPriceHidesUnlistedTemplates(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+
+	for name, template := range map[string]*domain.AgentTemplate{
+		"pending review": {ID: uuid.New(), PriceCents: 1500, Status: "pending", IsPublic: true},
+		"private":        {ID: uuid.New(), PriceCents: 1500, Status: "approved", IsPublic: false},
+	} {
+		m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), template.ID).Return(template, nil)
+		if _, err := svc.GetCreditPrice(ctx, template.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("%s: GetCreditPrice error = %v, want ErrNotFound", name, err)
+		}
+	}
+
+	listed := &domain.AgentTemplate{ID: uuid.New(), PriceCents: 1500, Status: "approved", IsPublic: true}
+	m.marketplace.EXPECT().GetTemplateByID(gomock.Any(), listed.ID).Return(listed, nil)
+	price, err := svc.GetCreditPrice(ctx, listed.ID)
+	if err != nil || price.PriceCredits != 7500 {
+		t.Errorf("GetCreditPrice = %+v, %v; want 7500 credits", price, err)
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// RequestRefund asks for one of the office's template purchases to be
+// refunded. An admin approves or rejects the request.
+func (s *EarningsService) RequestRefund(
+	ctx context.Context,
+	officeID, userID, purchaseID uuid.UUID,
+	reason string,
+) (*domain.RefundRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", domain.ErrInvalidInput)
+	}
+
+	purchase, err := s.purchaseRepo.GetByID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if purchase.OfficeID != officeID {
+		return nil, domain.ErrNotFound
+	}
+	if purchase.Status != domain.TemplatePurchaseStatusActive {
+		return nil, fmt.Errorf("%w: the purchase was already refunded", domain.ErrInvalidInput)
+	}
+	if purchase.EarningID == nil {
+		return nil, fmt.Errorf("%w: the purchase has no sale to refund", domain.ErrInvalidInput)
+	}
+	// A bundle's card payment is refunded in full by Stripe, so its
+	// templates cannot be refunded one at a time
+	if purchase.BundleID != nil {
+		return nil, fmt.Errorf("%w: templates bought in a bundle cannot be refunded one at a time", domain.ErrInvalidInput)
+	}
+
+	request := &domain.RefundRequest{
+		ID:          uuid.New(),
+		PurchaseID:  purchaseID,
+		OfficeID:    officeID,
+		RequestedBy: &userID,
+		Reason:      reason,
+		Status:      domain.RefundRequestStatusPending,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.refundRepo.Create(ctx, request); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionRefundRequest,
+		EntityType: domain.AuditEntityRefund,
+		EntityID:   request.ID,
+		OfficeID:   officeID,
+		ActorID:    userID,
+		Details: map[string]any{
+			"purchase_id": purchaseID,
+			"template_id": purchase.TemplateID,
+			"price_cents": purchase.PriceCents,
+			"reason":      reason,
+		},
+	})
+	return s.refundRepo.GetByID(ctx, request.ID)
+}
+
+// GetOfficeRefundRequests returns the office's refund requests, newest first
+func (s *EarningsService) GetOfficeRefundRequests(ctx context.Context, officeID uuid.UUID) ([]*domain.RefundRequest, error) {
+	return s.refundRepo.GetByOfficeID(ctx, officeID)
+}
+
+// GetRefundRequest retrieves a single refund request
+func (s *EarningsService) GetRefundRequest(ctx context.Context, id uuid.UUID) (*domain.RefundRequest, error) {
+	return s.refundRepo.GetByID(ctx, id)
+}
+
+// ListRefundRequests retrieves every office's refund requests with the
+// given status, oldest first (admin use)
+func (s *EarningsService) ListRefundRequests(
+	ctx context.Context,
+	status domain.RefundRequestStatus,
+	limit, offset int,
+) ([]*domain.RefundRequest, int, error) {
+	return s.refundRepo.List(ctx, status, limit, offset)
+}
+
+// ApproveRefund refunds a pending request's purchase (admin use). The
+// payment is refunded through Stripe first; then, together, the sale is
+// reversed in the author's earnings, the office loses the template and the
+// request is closed. Should that fail after Stripe refunded the payment,
+// approving again does not refund it twice. Purchases paid with credits
+// have them returned to the office's wallet instead.
+func (s *EarningsService) ApproveRefund(ctx context.Context, refundID, reviewerID uuid.UUID) (*domain.RefundRequest, error) {
+	request, err := s.pendingRefund(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if request.EarningID == nil {
+		return nil, fmt.Errorf("%w: the purchase has no sale to refund", domain.ErrInvalidInput)
+	}
+	sale, err := s.earningsRepo.GetEarning(ctx, *request.EarningID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sale.StripePaymentIntentID != "" {
+		if s.billing == nil {
+			return nil, errors.New("cannot refund a Stripe payment: Stripe is not configured")
+		}
+		request.StripeRefundID, err = s.billing.RefundPayment(ctx, sale.StripePaymentIntentID, "purchase-refund-"+request.ID.String())
+		if err != nil {
+			return nil, fmt.Errorf("refund payment: %w", err)
+		}
+	}
+
+	var reversal *domain.AuthorEarning
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if reversal, err = s.earningsRepo.ReverseSale(ctx, sale.ID); err != nil {
+			return err
+		}
+		if err := s.purchaseRepo.Revoke(ctx, request.PurchaseID); err != nil {
+			return err
+		}
+		if request.PriceCredits > 0 {
+			if err := s.returnCredits(ctx, request); err != nil {
+				return err
+			}
+		}
+		request.Status = domain.RefundRequestStatusRefunded
+		request.ReviewedBy = &reviewerID
+		return s.refundRepo.Resolve(ctx, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	returned := fmt.Sprintf("%s is on its way back to you", formatCents(request.PriceCents))
+	if request.PriceCredits > 0 {
+		returned = fmt.Sprintf("%d credits were returned to your wallet", request.PriceCredits)
+	}
+	s.notifyRefund(ctx, request.OfficeID, "Refund approved",
+		fmt.Sprintf("Your purchase of %s was refunded. %s, and the template can no longer be hired.",
+			request.TemplateName, returned),
+		request)
+	_, err = s.notifications.NotifyUser(ctx, sale.AuthorID, domain.NotificationTypeMarketplaceRefund,
+		fmt.Sprintf("A sale of %s was refunded", request.TemplateName),
+		fmt.Sprintf("An office's purchase of %s was refunded. Your %s share was taken back out of your balance.",
+			request.TemplateName, formatCents(sale.AuthorEarningCents)),
+		map[string]any{
+			"template_id":          request.TemplateID.String(),
+			"earning_id":           reversal.ID.String(),
+			"refund_of":            sale.ID.String(),
+			"author_earning_cents": reversal.AuthorEarningCents,
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify author of refunded sale %s: %v", sale.ID, err)
+	}
+	return request, nil
+}
+
+// returnCredits gives the credits a purchase was paid with back to the
+// office's wallet
+func (s *EarningsService) returnCredits(ctx context.Context, request *domain.RefundRequest) error {
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, request.OfficeID)
+	if err != nil {
+		return fmt.Errorf("failed to get wallet: %w", err)
+	}
+	_, err = s.creditRepo.AddCredits(ctx, wallet.ID, request.PriceCredits, domain.TransactionTypeRefund,
+		fmt.Sprintf("Refund of %s", request.TemplateName), "purchase", &request.PurchaseID)
+	return err
+}
+
+// RejectRefund turns down a pending request, telling the office why
+// (admin use)
+func (s *EarningsService) RejectRefund(ctx context.Context, refundID, reviewerID uuid.UUID, reason string) (*domain.RefundRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", domain.ErrInvalidInput)
+	}
+	request, err := s.pendingRefund(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = domain.RefundRequestStatusRejected
+	request.RejectionReason = reason
+	request.ReviewedBy = &reviewerID
+	if err := s.refundRepo.Resolve(ctx, request); err != nil {
+		return nil, err
+	}
+
+	s.notifyRefund(ctx, request.OfficeID, "Refund declined",
+		fmt.Sprintf("Your request to refund %s was declined: %s", request.TemplateName, reason),
+		request)
+	return request, nil
+}
+
+// pendingRefund retrieves a refund request that has not been decided yet
+func (s *EarningsService) pendingRefund(ctx context.Context, refundID uuid.UUID) (*domain.RefundRequest, error) {
+	request, err := s.refundRepo.GetByID(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != domain.RefundRequestStatusPending {
+		return nil, fmt.Errorf("%w: refund request is %s, not pending", domain.ErrInvalidInput, request.Status)
+	}
+	return request, nil
+}
+
+// notifyRefund tells the office that requested a refund how it was decided
+func (s *EarningsService) notifyRefund(ctx context.Context, officeID uuid.UUID, title, message string, request *domain.RefundRequest) {
+	_, err := s.notifications.Notify(ctx, officeID, domain.NotificationTypeMarketplaceRefund, title, message,
+		map[string]any{
+			"refund_request_id": request.ID.String(),
+			"purchase_id":       request.PurchaseID.String(),
+			"template_id":       request.TemplateID.String(),
+			"status":            string(request.Status),
+			"price_cents":       request.PriceCents,
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify office %s of refund request %s: %v", officeID, request.ID, err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// pendingRefundFixture is a pending refund request of a Stripe-paid sale
+func pendingRefundFixture() (*domain.RefundRequest, *domain.AuthorEarning) {
+	sale := &domain.AuthorEarning{
+		ID:                    uuid.New(),
+		AuthorID:              uuid.New(),
+		SaleAmountCents:       1500,
+		AuthorEarningCents:    1200,
+		StripePaymentIntentID: "pi_123",
+		Status:                "completed",
+	}
+	request := &domain.RefundRequest{
+		ID:           uuid.New(),
+		PurchaseID:   uuid.New(),
+		OfficeID:     uuid.New(),
+		Status:       domain.RefundRequestStatusPending,
+		TemplateID:   uuid.New(),
+		TemplateName: "Analyst",
+		PriceCents:   1500,
+		EarningID:    &sale.ID,
+	}
+	return request, sale
+}
+
+func TestApproveRefundRefundsPaymentAndReversesSale(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestEarningsService(t)
+	request, sale := pendingRefundFixture()
+	reviewerID := uuid.New()
+
+	m.refunds.EXPECT().GetByID(gomock.Any(), request.ID).Return(request, nil)
+	m.earnings.EXPECT().GetEarning(gomock.Any(), sale.ID).Return(sale, nil)
+	// The request's ID keys the Stripe refund, so approving again after a
+	// failure does not refund twice
+	m.billing.EXPECT().RefundPayment(gomock.Any(), "pi_123", "purchase-refund-"+request.ID.String()).Return("re_123", nil)
+	m.earnings.EXPECT().ReverseSale(gomock.Any(), sale.ID).
+		Return(&domain.AuthorEarning{ID: uuid.New(), AuthorEarningCents: -1200, RefundOf: &sale.ID}, nil)
+	m.purchases.EXPECT().Revoke(gomock.Any(), request.PurchaseID).Return(nil)
+	m.refunds.EXPECT().Resolve(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, resolved *domain.RefundRequest) error {
+			if resolved.Status != domain.RefundRequestStatusRefunded || resolved.StripeRefundID != "re_123" ||
+				resolved.ReviewedBy == nil || *resolved.ReviewedBy != reviewerID {
+				t.Errorf("resolved = %+v, want refunded by the reviewer with the Stripe refund", resolved)
+			}
+			return nil
+		})
+	// Neither the office nor the author can be notified; that is only logged
+	m.offices.EXPECT().GetByID(gomock.Any(), request.OfficeID).Return(nil, domain.ErrNotFound)
+	m.offices.EXPECT().GetByUserID(gomock.Any(), sale.AuthorID).Return(nil, nil)
+
+	got, err := svc.ApproveRefund(ctx, request.ID, reviewerID)
+	if err != nil {
+		t.Fatalf("ApproveRefund: %v", err)
+	}
+	if got.Status != domain.RefundRequestStatusRefunded {
+		t.Errorf("status = %s, want refunded", got.Status)
+	}
+}
+
+func TestApproveRefundKeepsSaleWhenStripeFails(t *testing.T) {
+	svc, m := newTestEarningsService(t)
+	request, sale := pendingRefundFixture()
+
+	// Nothing is reversed or revoked
+	m.refunds.EXPECT().GetByID(gomock.Any(), request.ID).Return(request, nil)
+	m.earnings.EXPECT().GetEarning(gomock.Any(), sale.ID).Return(sale, nil)
+	m.billing.EXPECT().RefundPayment(gomock.Any(), "pi_123", gomock.Any()).Return("", errors.New("charge already refunded"))
+
+	if _, err := svc.ApproveRefund(context.Background(), request.ID, uuid.New()); err == nil {
+		t.Error("ApproveRefund succeeded although Stripe did not refund the payment")
+	}
+}
+
+func TestApproveRefundOfDecidedRequest(t *testing.T) {
+	svc, m := newTestEarningsService(t)
+	request, _ := pendingRefundFixture()
+	request.Status = domain.RefundRequestStatusRejected
+
+	m.refunds.EXPECT().GetByID(gomock.Any(), request.ID).Return(request, nil)
+
+	_, err := svc.ApproveRefund(context.Background(), request.ID, uuid.New())
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("ApproveRefund error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRequestRefundRejected(t *testing.T) {
+	officeID, earningID, bundleID := uuid.New(), uuid.New(), uuid.New()
+	tests := []struct {
+		name     string
+		reason   string
+		purchase *domain.TemplatePurchase
+		want     error
+	}{
+		{"without reason", "  ", nil, domain.ErrInvalidInput},
+		{"of another office", "Not what was described",
+			&domain.TemplatePurchase{OfficeID: uuid.New(), Status: domain.TemplatePurchaseStatusActive, EarningID: &earningID},
+			domain.ErrNotFound},
+		{"already refunded", "Not what was described",
+			&domain.TemplatePurchase{OfficeID: officeID, Status: domain.TemplatePurchaseStatusRefunded, EarningID: &earningID},
+			domain.ErrInvalidInput},
+		{"without sale", "Not what was described",
+			&domain.TemplatePurchase{OfficeID: officeID, Status: domain.TemplatePurchaseStatusActive},
+			domain.ErrInvalidInput},
+		{"bought in a bundle", "Not what was described",
+			&domain.TemplatePurchase{OfficeID: officeID, Status: domain.TemplatePurchaseStatusActive, EarningID: &earningID, BundleID: &bundleID},
+			domain.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestEarningsService(t)
+			purchaseID := uuid.New()
+
+			// No request is opened in any of these cases
+			if tt.purchase != nil {
+				tt.purchase.ID = purchaseID
+				m.purchases.EXPECT().GetByID(gomock.Any(), purchaseID).Return(tt.purchase, nil)
+			}
+
+			_, err := svc.RequestRefund(context.Background(), officeID, uuid.New(), purchaseID, tt.reason)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("RequestRefund error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApproveRefundReturnsCredits(t *testing.T) {
+	svc, m := newTestEarningsService(t)
+	request, sale := pendingRefundFixture()
+	request.PriceCredits = 7500
+	sale.StripePaymentIntentID = ""
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: request.OfficeID}
+
+	// Stripe is not involved; the credits go back to the wallet
+	m.refunds.EXPECT().GetByID(gomock.Any(), request.ID).Return(request, nil)
+	m.earnings.EXPECT().GetEarning(gomock.Any(), sale.ID).Return(sale, nil)
+	m.earnings.EXPECT().ReverseSale(gomock.Any(), sale.ID).Return(&domain.AuthorEarning{ID: uuid.New()}, nil)
+	m.purchases.EXPECT().Revoke(gomock.Any(), request.PurchaseID).Return(nil)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), request.OfficeID).Return(wallet, nil)
+	m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, int64(7500), domain.TransactionTypeRefund,
+		gomock.Any(), "purchase", &request.PurchaseID).Return(&domain.CreditTransaction{ID: uuid.New()}, nil)
+	m.refunds.EXPECT().Resolve(gomock.Any(), gomock.Any()).Return(nil)
+	m.offices.EXPECT().GetByID(gomock.Any(), request.OfficeID).Return(nil, domain.ErrNotFound)
+	m.offices.EXPECT().GetByUserID(gomock.Any(), sale.AuthorID).Return(nil, nil)
+
+	if _, err := svc.ApproveRefund(context.Background(), request.ID, uuid.New()); err != nil {
+		t.Fatalf("ApproveRefund: %v", err)
+	}
+}
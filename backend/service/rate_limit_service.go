@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// rateLimitPeriod is the window request limits are expressed over
+	rateLimitPeriod = time.Minute
+	// authRateLimit is the per-client limit on login and registration,
+	// low enough to slow password guessing
+	authRateLimit = 10
+	// defaultRateLimit applies to offices whose tier priority is unknown
+	defaultRateLimit = 60
+
+	// officeLimitTTL is how long an office's limit is cached, so tier
+	// changes take effect within a minute without a lookup per request
+	officeLimitTTL = time.Minute
+)
+
+// priorityRateLimits maps tier priorities to requests per minute
+var priorityRateLimits = map[string]int{
+	"low":     60,
+	"normal":  300,
+	"high":    600,
+	"highest": 1200,
+}
+
+// RateLimitService meters API requests: per office, at a rate set by the
+// office's subscription tier, per client on the auth endpoints and per
+// token on the chat widget endpoints
+type RateLimitService struct {
+	limiter             domain.RateLimiter
+	subscriptionService *SubscriptionService
+
+	mu           sync.Mutex
+	officeLimits map[uuid.UUID]cachedRateLimit
+}
+
+// cachedRateLimit is an office's limit and when it was looked up
+type cachedRateLimit struct {
+	limit   int
+	fetched time.Time
+}
+
+// NewRateLimitService creates a new RateLimitService instance
+func NewRateLimitService(limiter domain.RateLimiter, subscriptionService *SubscriptionService) *RateLimitService {
+	return &RateLimitService{
+		limiter:             limiter,
+		subscriptionService: subscriptionService,
+		officeLimits:        make(map[uuid.UUID]cachedRateLimit),
+	}
+}
+
+// TakeOffice counts a request against the office's per-minute limit
+func (s *RateLimitService) TakeOffice(ctx context.Context, officeID uuid.UUID) (domain.RateLimit, error) {
+	limit, err := s.officeLimit(ctx, officeID)
+	if err != nil {
+		return domain.RateLimit{}, err
+	}
+	return s.limiter.Take(ctx, "office:"+officeID.String(), limit, rateLimitPeriod)
+}
+
+// TakeAuth counts an auth request from a client address
+func (s *RateLimitService) TakeAuth(ctx context.Context, clientIP string) (domain.RateLimit, error) {
+	return s.limiter.Take(ctx, "auth:"+clientIP, authRateLimit, rateLimitPeriod)
+}
+
+// TakeWidget counts a chat widget request against the token's per-minute
+// limit, shared by all of the token's visitors
+func (s *RateLimitService) TakeWidget(ctx context.Context, tokenID uuid.UUID, limit int) (domain.RateLimit, error) {
+	return s.limiter.Take(ctx, "widget:"+tokenID.String(), limit, rateLimitPeriod)
+}
+
+// officeLimit returns the office's requests per minute, derived from its
+// tier's priority. Offices without a subscription get the default limit.
+func (s *RateLimitService) officeLimit(ctx context.Context, officeID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	cached, ok := s.officeLimits[officeID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetched) < officeLimitTTL {
+		return cached.limit, nil
+	}
+
+	limit := defaultRateLimit
+	sub, err := s.subscriptionService.GetSubscriptionByOffice(ctx, officeID)
+	switch {
+	case err == nil:
+		if tierDef, err := s.subscriptionService.GetTier(sub.Tier); err == nil {
+			if l, ok := priorityRateLimits[tierDef.Features.Priority]; ok {
+				limit = l
+			}
+		}
+	case !errors.Is(err, domain.ErrNotFound):
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired entries now and then so offices that went quiet do not
+	// accumulate
+	if len(s.officeLimits) > 10000 {
+		for id, c := range s.officeLimits {
+			if time.Since(c.fetched) >= officeLimitTTL {
+				delete(s.officeLimits, id)
+			}
+		}
+	}
+	s.officeLimits[officeID] = cachedRateLimit{limit: limit, fetched: time.Now()}
+	return limit, nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// retentionPollInterval is how often the retention worker checks
+	// whether a run is due; retentionRunInterval is how long after a run
+	// the next one is
+	retentionPollInterval = time.Hour
+	retentionRunInterval  = 24 * time.Hour
+	retentionBatchSize    = 100
+)
+
+// RetentionConfig configures the retention worker
+type RetentionConfig struct {
+	// DryRun only reports what would be purged
+	DryRun bool
+	// Exempt entities are never purged
+	Exempt []domain.RetentionEntity
+}
+
+// RetentionService purges office data older than the office's tier retains
+// it, and reports what each run purged
+type RetentionService struct {
+	retentionRepo       domain.RetentionRepository
+	subscriptionService *SubscriptionService
+	storage             domain.ObjectStorage
+	config              RetentionConfig
+}
+
+// NewRetentionService creates a new RetentionService instance
+func NewRetentionService(
+	retentionRepo domain.RetentionRepository,
+	subscriptionService *SubscriptionService,
+	storage domain.ObjectStorage,
+	config RetentionConfig,
+) *RetentionService {
+	return &RetentionService{
+		retentionRepo:       retentionRepo,
+		subscriptionService: subscriptionService,
+		storage:             storage,
+		config:              config,
+	}
+}
+
+// Run purges old data once a day until ctx is cancelled
+func (s *RetentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(retentionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.runDue(ctx) {
+				continue
+			}
+			if _, err := s.Purge(ctx, nil); err != nil {
+				log.Printf("Retention run failed: %v", err)
+			}
+		}
+	}
+}
+
+// runDue reports whether a day has passed since the last run started
+func (s *RetentionService) runDue(ctx context.Context) bool {
+	latest, err := s.retentionRepo.GetLatestRun(ctx)
+	if errors.Is(err, domain.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Printf("Failed to load the latest retention run: %v", err)
+		return false
+	}
+	return time.Since(latest.StartedAt) >= retentionRunInterval
+}
+
+// Purge deletes every office's data that is older than its tier's
+// retention period, except for the exempt entities, and returns the run's
+// report. Tiers without a retention period keep data indefinitely. A dry
+// run deletes nothing and reports what it would have purged; dryRun
+// overrides the configured mode when set.
+func (s *RetentionService) Purge(ctx context.Context, dryRun *bool) (*domain.RetentionRun, error) {
+	var entities []domain.RetentionEntity
+	for _, entity := range domain.RetentionEntities {
+		if !slices.Contains(s.config.Exempt, entity) {
+			entities = append(entities, entity)
+		}
+	}
+
+	run := &domain.RetentionRun{
+		ID:        uuid.New(),
+		DryRun:    s.config.DryRun,
+		Exempt:    s.config.Exempt,
+		StartedAt: time.Now(),
+	}
+	if dryRun != nil {
+		run.DryRun = *dryRun
+	}
+	if err := s.retentionRepo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if len(entities) > 0 {
+		for tier, tierDef := range s.subscriptionService.GetAllTiers() {
+			if tierDef.Features.RetentionDays <= 0 {
+				continue
+			}
+			if err := s.purgeTier(ctx, run, tier, tierDef.Features.RetentionDays, entities); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	if err := s.retentionRepo.FinishRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// purgeTier purges the data of the offices on tier that is older than days
+func (s *RetentionService) purgeTier(ctx context.Context, run *domain.RetentionRun, tier domain.SubscriptionTier, days int, entities []domain.RetentionEntity) error {
+	cutoff := run.StartedAt.AddDate(0, 0, -days)
+
+	afterID := uuid.Nil
+	for {
+		officeIDs, err := s.retentionRepo.ListOffices(ctx, tier, afterID, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, officeID := range officeIDs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			counts, storageKeys, err := s.retentionRepo.Purge(ctx, officeID, cutoff, entities, run.DryRun)
+			if err != nil {
+				log.Printf("Failed to purge old data of office %s: %v", officeID, err)
+				continue
+			}
+			for _, key := range storageKeys {
+				if err := s.storage.Delete(ctx, key); err != nil {
+					log.Printf("Failed to delete purged attachment contents %s: %v", key, err)
+				}
+			}
+			if counts.Empty() {
+				continue
+			}
+
+			purge := &domain.RetentionPurge{
+				ID:            uuid.New(),
+				RunID:         run.ID,
+				OfficeID:      officeID,
+				Tier:          tier,
+				RetentionDays: days,
+				Cutoff:        cutoff,
+				Deleted:       counts,
+				CreatedAt:     time.Now(),
+			}
+			if err := s.retentionRepo.CreatePurge(ctx, purge); err != nil {
+				log.Printf("Failed to record the purge of office %s: %v", officeID, err)
+			}
+			run.OfficesPurged++
+			run.Deleted.Add(counts)
+		}
+
+		if len(officeIDs) < retentionBatchSize {
+			return nil
+		}
+		afterID = officeIDs[len(officeIDs)-1]
+	}
+}
+
+// ListRuns returns a page of retention runs, most recent first, and the
+// total number of runs
+func (s *RetentionService) ListRuns(ctx context.Context, limit, offset int) ([]*domain.RetentionRun, int, error) {
+	return s.retentionRepo.ListRuns(ctx, limit, offset)
+}
+
+// GetRun returns a retention run with what it purged from each office
+func (s *RetentionService) GetRun(ctx context.Context, id uuid.UUID) (*domain.RetentionRun, error) {
+	run, err := s.retentionRepo.GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	run.Purges, err = s.retentionRepo.ListPurges(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
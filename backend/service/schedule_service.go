@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// schedulePollInterval is how often the scheduler looks for due schedules
+	schedulePollInterval = 30 * time.Second
+	scheduleBatchSize    = 50
+
+	maxScheduleNameLength = 255
+)
+
+// ScheduleService manages scheduled agent work and fires due schedules
+// by creating tasks
+type ScheduleService struct {
+	scheduleRepo     domain.ScheduledTaskRepository
+	agentRepo        domain.AgentRepository
+	conversationRepo domain.ConversationRepository
+	taskService      *TaskService
+}
+
+// NewScheduleService creates a new ScheduleService instance
+func NewScheduleService(
+	scheduleRepo domain.ScheduledTaskRepository,
+	agentRepo domain.AgentRepository,
+	conversationRepo domain.ConversationRepository,
+	taskService *TaskService,
+) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:     scheduleRepo,
+		agentRepo:        agentRepo,
+		conversationRepo: conversationRepo,
+		taskService:      taskService,
+	}
+}
+
+// CreateScheduleInput represents input for scheduling agent work. Exactly
+// one of CronExpression and RunAt must be set.
+type CreateScheduleInput struct {
+	OfficeID       uuid.UUID
+	AgentID        uuid.UUID
+	ConversationID uuid.UUID
+	Name           string
+	Input          string
+	CronExpression string
+	RunAt          *time.Time
+	Timezone       string
+	// TaskTemplateID is the task template Input was filled in from, if any
+	TaskTemplateID *uuid.UUID
+}
+
+// UpdateScheduleInput represents the editable fields of a schedule. Setting
+// CronExpression turns the schedule recurring and setting RunAt makes it a
+// one-off.
+type UpdateScheduleInput struct {
+	ConversationID *uuid.UUID
+	Name           *string
+	Input          *string
+	CronExpression *string
+	RunAt          *time.Time
+	Timezone       *string
+	IsActive       *bool
+}
+
+// CreateSchedule schedules work for an agent in the given office
+func (s *ScheduleService) CreateSchedule(ctx context.Context, input CreateScheduleInput) (*domain.ScheduledTask, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &domain.ScheduledTask{
+		ID:             uuid.New(),
+		OfficeID:       input.OfficeID,
+		AgentID:        input.AgentID,
+		ConversationID: input.ConversationID,
+		Name:           strings.TrimSpace(input.Name),
+		Input:          strings.TrimSpace(input.Input),
+		CronExpression: strings.TrimSpace(input.CronExpression),
+		RunAt:          input.RunAt,
+		Timezone:       strings.TrimSpace(input.Timezone),
+		IsActive:       true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		TaskTemplateID: input.TaskTemplateID,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+
+	if err := s.prepare(ctx, schedule, now); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetSchedules returns all schedules of an agent in the given office
+func (s *ScheduleService) GetSchedules(ctx context.Context, officeID, agentID uuid.UUID) ([]*domain.ScheduledTask, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+	return s.scheduleRepo.GetByAgentID(ctx, agentID)
+}
+
+// GetSchedule returns a schedule belonging to an agent in the given office
+func (s *ScheduleService) GetSchedule(ctx context.Context, officeID, agentID, scheduleID uuid.UUID) (*domain.ScheduledTask, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.scheduleRepo.GetByID(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.AgentID != agentID {
+		return nil, domain.ErrNotFound
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule edits a schedule and recomputes its next run
+func (s *ScheduleService) UpdateSchedule(
+	ctx context.Context,
+	officeID uuid.UUID,
+	agentID uuid.UUID,
+	scheduleID uuid.UUID,
+	input UpdateScheduleInput,
+) (*domain.ScheduledTask, error) {
+	schedule, err := s.GetSchedule(ctx, officeID, agentID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.CronExpression != nil && input.RunAt != nil {
+		return nil, fmt.Errorf("%w: set either cron_expression or run_at, not both", domain.ErrInvalidInput)
+	}
+
+	if input.ConversationID != nil {
+		schedule.ConversationID = *input.ConversationID
+	}
+	if input.Name != nil {
+		schedule.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Input != nil {
+		schedule.Input = strings.TrimSpace(*input.Input)
+	}
+	if input.CronExpression != nil {
+		schedule.CronExpression = strings.TrimSpace(*input.CronExpression)
+		schedule.RunAt = nil
+	}
+	if input.RunAt != nil {
+		schedule.RunAt = input.RunAt
+		schedule.CronExpression = ""
+	}
+	if input.Timezone != nil {
+		schedule.Timezone = strings.TrimSpace(*input.Timezone)
+	}
+	if input.IsActive != nil {
+		schedule.IsActive = *input.IsActive
+	}
+
+	if err := s.prepare(ctx, schedule, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRepo.Update(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// DeleteSchedule removes a schedule and its run history. Tasks it already
+// created are kept.
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, officeID, agentID, scheduleID uuid.UUID) error {
+	if _, err := s.GetSchedule(ctx, officeID, agentID, scheduleID); err != nil {
+		return err
+	}
+	return s.scheduleRepo.Delete(ctx, scheduleID)
+}
+
+// GetRuns returns a page of a schedule's run history, newest first, with the
+// total number of runs
+func (s *ScheduleService) GetRuns(
+	ctx context.Context,
+	officeID uuid.UUID,
+	agentID uuid.UUID,
+	scheduleID uuid.UUID,
+	limit int,
+	offset int,
+) ([]*domain.ScheduledTaskRun, int, error) {
+	if _, err := s.GetSchedule(ctx, officeID, agentID, scheduleID); err != nil {
+		return nil, 0, err
+	}
+
+	runs, err := s.scheduleRepo.GetRuns(ctx, scheduleID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.scheduleRepo.CountRuns(ctx, scheduleID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
+
+// Run fires due schedules until ctx is cancelled
+func (s *ScheduleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.fireDue(ctx)
+		}
+	}
+}
+
+// fireDue fires one batch of due schedules
+func (s *ScheduleService) fireDue(ctx context.Context) {
+	schedules, err := s.scheduleRepo.GetDue(ctx, time.Now(), scheduleBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		s.fire(ctx, schedule)
+	}
+}
+
+// fire claims a schedule's due run, creates its task and records the run.
+// Recurring schedules advance to their next occurrence after now, so runs
+// missed while the backend was down are collapsed into a single run.
+// One-off schedules are deactivated once fired.
+func (s *ScheduleService) fire(ctx context.Context, schedule *domain.ScheduledTask) {
+	scheduledFor := *schedule.NextRunAt
+	now := time.Now()
+
+	var nextRunAt *time.Time
+	if schedule.IsRecurring() {
+		next, err := nextCronRun(schedule.CronExpression, schedule.Timezone, now)
+		if err != nil {
+			log.Printf("Deactivating schedule %s: %v", schedule.ID, err)
+		} else {
+			nextRunAt = &next
+		}
+	}
+
+	claimed, err := s.scheduleRepo.ClaimRun(ctx, schedule, nextRunAt)
+	if err != nil {
+		log.Printf("Failed to claim schedule %s: %v", schedule.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	run := &domain.ScheduledTaskRun{
+		ID:           uuid.New(),
+		ScheduleID:   schedule.ID,
+		Status:       domain.ScheduledTaskRunCreated,
+		ScheduledFor: scheduledFor,
+		CreatedAt:    now,
+	}
+
+	task, err := s.createTask(ctx, schedule)
+	if err != nil {
+		log.Printf("Schedule %s failed to create task: %v", schedule.ID, err)
+		run.Status = domain.ScheduledTaskRunFailed
+		run.Error = err.Error()
+	} else {
+		run.TaskID = &task.ID
+	}
+
+	if err := s.scheduleRepo.CreateRun(ctx, run); err != nil {
+		log.Printf("Failed to record run of schedule %s: %v", schedule.ID, err)
+	}
+}
+
+// createTask creates the task for a schedule run if its agent is still active
+// and its conversation is not archived
+func (s *ScheduleService) createTask(ctx context.Context, schedule *domain.ScheduledTask) (*domain.Task, error) {
+	agent, err := s.agentRepo.GetByID(ctx, schedule.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	if !agent.IsActive {
+		return nil, fmt.Errorf("agent %s is inactive", agent.ID)
+	}
+
+	conversation, err := s.conversationRepo.GetByID(ctx, schedule.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.IsArchived() {
+		return nil, fmt.Errorf("conversation %s is archived", conversation.ID)
+	}
+
+	return s.taskService.CreateTask(ctx, CreateTaskInput{
+		OfficeID:       schedule.OfficeID,
+		ConversationID: schedule.ConversationID,
+		AgentID:        schedule.AgentID,
+		Input:          schedule.Input,
+	})
+}
+
+// prepare validates a schedule and sets its next run. Inactive schedules
+// have no next run.
+func (s *ScheduleService) prepare(ctx context.Context, schedule *domain.ScheduledTask, now time.Time) error {
+	if schedule.Name == "" || len(schedule.Name) > maxScheduleNameLength {
+		return fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxScheduleNameLength)
+	}
+	if schedule.Input == "" {
+		return fmt.Errorf("%w: input is required", domain.ErrInvalidInput)
+	}
+	if (schedule.CronExpression == "") == (schedule.RunAt == nil) {
+		return fmt.Errorf("%w: exactly one of cron_expression or run_at is required", domain.ErrInvalidInput)
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", domain.ErrInvalidInput, schedule.Timezone)
+	}
+	if err := s.checkConversation(ctx, schedule); err != nil {
+		return err
+	}
+
+	schedule.NextRunAt = nil
+	if !schedule.IsActive {
+		return nil
+	}
+
+	if !schedule.IsRecurring() {
+		if !schedule.RunAt.After(now) {
+			return fmt.Errorf("%w: run_at must be in the future", domain.ErrInvalidInput)
+		}
+		runAt := schedule.RunAt.UTC()
+		schedule.RunAt = &runAt
+		schedule.NextRunAt = &runAt
+		return nil
+	}
+
+	next, err := nextCronRun(schedule.CronExpression, schedule.Timezone, now)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+	schedule.NextRunAt = &next
+	return nil
+}
+
+// checkConversation verifies the schedule posts into a conversation of its
+// office that the agent takes part in
+func (s *ScheduleService) checkConversation(ctx context.Context, schedule *domain.ScheduledTask) error {
+	return checkAgentConversation(ctx, s.conversationRepo, schedule.OfficeID, schedule.AgentID, schedule.ConversationID)
+}
+
+// checkAgentConversation verifies that work dispatched to an agent outside
+// of a chat message posts into a conversation of its office that the agent
+// takes part in
+func checkAgentConversation(
+	ctx context.Context,
+	conversationRepo domain.ConversationRepository,
+	officeID uuid.UUID,
+	agentID uuid.UUID,
+	conversationID uuid.UUID,
+) error {
+	invalid := fmt.Errorf("%w: conversation_id must be a conversation of this office the agent takes part in", domain.ErrInvalidInput)
+
+	if conversationID == uuid.Nil {
+		return invalid
+	}
+	conversation, err := conversationRepo.GetByID(ctx, conversationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return invalid
+	}
+	if err != nil {
+		return err
+	}
+	if conversation.OfficeID != officeID {
+		return invalid
+	}
+
+	participants, err := conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return err
+	}
+	for _, agent := range participants {
+		if agent.ID == agentID {
+			return nil
+		}
+	}
+	return invalid
+}
+
+// nextCronRun returns the first occurrence of a cron expression after now,
+// evaluated in the given IANA time zone
+func nextCronRun(expr, timezone string, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cron, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next, err := cron.Next(now.In(loc))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return next.UTC(), nil
+}
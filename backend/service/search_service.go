@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+const maxSearchQueryLength = 200
+
+// searchMarks renders the match delimiters of raw headlines as HTML
+var searchMarks = strings.NewReplacer(domain.SearchMarkStart, "<mark>", domain.SearchMarkEnd, "</mark>")
+
+// SearchService searches everything an office's agents and users wrote:
+// messages, task outputs and documents
+type SearchService struct {
+	searchRepo domain.SearchRepository
+}
+
+// NewSearchService creates a new SearchService instance
+func NewSearchService(searchRepo domain.SearchRepository) *SearchService {
+	return &SearchService{searchRepo: searchRepo}
+}
+
+// Search returns a page of the office's content matching the filter, best
+// matches first, with the total number of matches
+func (s *SearchService) Search(ctx context.Context, filter domain.SearchFilter, page domain.PageRequest) ([]*domain.SearchResult, int, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Query == "" || utf8.RuneCountInString(filter.Query) > maxSearchQueryLength {
+		return nil, 0, fmt.Errorf("%w: q is required and must be at most %d characters", domain.ErrInvalidInput, maxSearchQueryLength)
+	}
+	for _, t := range filter.Types {
+		if !slices.Contains(domain.SearchResultTypes, t) {
+			return nil, 0, fmt.Errorf("%w: unknown type %q", domain.ErrInvalidInput, t)
+		}
+	}
+	if page.Limit <= 0 {
+		page.Limit = 20
+	}
+
+	results, err := s.searchRepo.Search(ctx, filter, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.searchRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, result := range results {
+		result.Headline = renderHeadline(result.Headline)
+	}
+	return results, total, nil
+}
+
+// renderHeadline escapes a raw headline as HTML, marking its matched words
+// with <mark> tags
+func renderHeadline(headline string) string {
+	return searchMarks.Replace(html.EscapeString(headline))
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSearchRendersHeadlines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockSearchRepository(ctrl)
+	svc := NewSearchService(repo)
+
+	filter := domain.SearchFilter{OfficeID: uuid.New(), Query: " launch plan "}
+	raw := "The " + domain.SearchMarkStart + "launch" + domain.SearchMarkEnd + " <script> & " +
+		domain.SearchMarkStart + "plan" + domain.SearchMarkEnd
+	trimmed := filter
+	trimmed.Query = "launch plan"
+	repo.EXPECT().Search(gomock.Any(), trimmed, domain.PageRequest{Limit: 20}).
+		Return([]*domain.SearchResult{{Type: domain.SearchResultMessage, Headline: raw}}, nil)
+	repo.EXPECT().Count(gomock.Any(), trimmed).Return(1, nil)
+
+	results, total, err := svc.Search(context.Background(), filter, domain.PageRequest{})
+	if err != nil || total != 1 || len(results) != 1 {
+		t.Fatalf("Search = %v, %d, %v; want one result", results, total, err)
+	}
+	want := "The <mark>launch</mark> &lt;script&gt; &amp; <mark>plan</mark>"
+	if results[0].Headline != want {
+		t.Errorf("headline = %q, want %q", results[0].Headline, want)
+	}
+}
+
+func TestSearchValidatesTheFilter(t *testing.T) {
+	svc := NewSearchService(nil)
+	for name, filter := range map[string]domain.SearchFilter{
+		"empty query":  {Query: "  "},
+		"unknown type": {Query: "plan", Types: []domain.SearchResultType{"agent"}},
+	} {
+		if _, _, err := svc.Search(context.Background(), filter, domain.PageRequest{}); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxSecretsPerOffice caps what a task can be handed at once
+	maxSecretsPerOffice        = 50
+	maxSecretValueLength       = 8192
+	maxSecretDescriptionLength = 500
+	// secretHintLength is how much of the end of a value its hint shows;
+	// values shorter than minHintedSecretLength get no hint
+	secretHintLength      = 4
+	minHintedSecretLength = 16
+)
+
+// secretNamePattern is the form of secret names, as environment variables
+// are usually named
+var secretNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,63}$`)
+
+// SecretService manages the encrypted secrets offices keep for their
+// agents' tools and hands them to the orchestrator for running tasks
+type SecretService struct {
+	secretRepo domain.OfficeSecretRepository
+	agentRepo  domain.AgentRepository
+	taskRepo   domain.TaskRepository
+	cipher     domain.SecretCipher
+	audit      *AuditService
+}
+
+// NewSecretService creates a new SecretService instance. Without a cipher
+// the vault is unavailable and every call returns ErrSecretsUnavailable.
+func NewSecretService(
+	secretRepo domain.OfficeSecretRepository,
+	agentRepo domain.AgentRepository,
+	taskRepo domain.TaskRepository,
+	cipher domain.SecretCipher,
+	audit *AuditService,
+) *SecretService {
+	return &SecretService{
+		secretRepo: secretRepo,
+		agentRepo:  agentRepo,
+		taskRepo:   taskRepo,
+		cipher:     cipher,
+		audit:      audit,
+	}
+}
+
+// CreateSecretInput represents input for storing a secret
+type CreateSecretInput struct {
+	OfficeID    uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	Value       string
+	Description string
+	// AgentIDs limits the secret to these agents; empty makes it available
+	// to all of the office's agents
+	AgentIDs []uuid.UUID
+}
+
+// UpdateSecretInput contains the changeable fields of a secret. Nil fields
+// are left unchanged; an empty AgentIDs makes the secret available to all
+// agents.
+type UpdateSecretInput struct {
+	Value       *string
+	Description *string
+	AgentIDs    []uuid.UUID
+}
+
+// TaskSecret is a decrypted secret handed to the orchestrator
+type TaskSecret struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CreateSecret encrypts and stores a new secret of the office
+func (s *SecretService) CreateSecret(ctx context.Context, input CreateSecretInput) (*domain.OfficeSecret, error) {
+	if s.cipher == nil {
+		return nil, domain.ErrSecretsUnavailable
+	}
+	name := strings.TrimSpace(input.Name)
+	if !secretNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be upper case letters, digits and underscores, starting with a letter, at most 64 characters", domain.ErrInvalidInput)
+	}
+	description := strings.TrimSpace(input.Description)
+	if err := validateSecretValue(input.Value); err != nil {
+		return nil, err
+	}
+	if err := validateSecretDescription(description); err != nil {
+		return nil, err
+	}
+	agentIDs, err := s.officeAgentIDs(ctx, input.OfficeID, input.AgentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.secretRepo.GetByOfficeID(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxSecretsPerOffice {
+		return nil, fmt.Errorf("%w: an office can have at most %d secrets", domain.ErrInvalidInput, maxSecretsPerOffice)
+	}
+
+	now := time.Now()
+	secret := &domain.OfficeSecret{
+		ID:          uuid.New(),
+		OfficeID:    input.OfficeID,
+		Name:        name,
+		Description: description,
+		AgentIDs:    agentIDs,
+		CreatedBy:   nullableID(input.UserID),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.seal(secret, input.Value); err != nil {
+		return nil, err
+	}
+	if err := s.secretRepo.Create(ctx, secret); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the office already has a secret named %s", domain.ErrAlreadyExists, name)
+		}
+		return nil, err
+	}
+
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionSecretCreate,
+		EntityType: domain.AuditEntitySecret,
+		EntityID:   secret.ID,
+		OfficeID:   secret.OfficeID,
+		After:      secretAuditState(secret),
+	})
+	return secret, nil
+}
+
+// GetSecrets returns the office's secrets by name, without their values
+func (s *SecretService) GetSecrets(ctx context.Context, officeID uuid.UUID) ([]*domain.OfficeSecret, error) {
+	if s.cipher == nil {
+		return nil, domain.ErrSecretsUnavailable
+	}
+	return s.secretRepo.GetByOfficeID(ctx, officeID)
+}
+
+// UpdateSecret replaces the value, description or agents of one of the
+// office's secrets
+func (s *SecretService) UpdateSecret(ctx context.Context, officeID, secretID uuid.UUID, input UpdateSecretInput) (*domain.OfficeSecret, error) {
+	if s.cipher == nil {
+		return nil, domain.ErrSecretsUnavailable
+	}
+	secret, err := s.officeSecret(ctx, officeID, secretID)
+	if err != nil {
+		return nil, err
+	}
+	before := secretAuditState(secret)
+
+	if input.Description != nil {
+		secret.Description = strings.TrimSpace(*input.Description)
+		if err := validateSecretDescription(secret.Description); err != nil {
+			return nil, err
+		}
+	}
+	if input.AgentIDs != nil {
+		if secret.AgentIDs, err = s.officeAgentIDs(ctx, officeID, input.AgentIDs); err != nil {
+			return nil, err
+		}
+	}
+	if input.Value != nil {
+		if err := validateSecretValue(*input.Value); err != nil {
+			return nil, err
+		}
+		if err := s.seal(secret, *input.Value); err != nil {
+			return nil, err
+		}
+	}
+	secret.UpdatedAt = time.Now()
+
+	if err := s.secretRepo.Update(ctx, secret); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionSecretUpdate,
+		EntityType: domain.AuditEntitySecret,
+		EntityID:   secret.ID,
+		OfficeID:   secret.OfficeID,
+		Before:     before,
+		After:      secretAuditState(secret),
+		Details:    map[string]any{"value_changed": input.Value != nil},
+	})
+	return secret, nil
+}
+
+// DeleteSecret removes one of the office's secrets
+func (s *SecretService) DeleteSecret(ctx context.Context, officeID, secretID uuid.UUID) error {
+	if s.cipher == nil {
+		return domain.ErrSecretsUnavailable
+	}
+	secret, err := s.officeSecret(ctx, officeID, secretID)
+	if err != nil {
+		return err
+	}
+	if err := s.secretRepo.Delete(ctx, secret.ID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionSecretDelete,
+		EntityType: domain.AuditEntitySecret,
+		EntityID:   secret.ID,
+		OfficeID:   secret.OfficeID,
+		Before:     secretAuditState(secret),
+	})
+	return nil
+}
+
+// TaskSecrets decrypts the secrets of the task's office that its agent may
+// use, or only those named. Secrets are only handed out while the task is
+// running, and every secret handed out is recorded in the office's audit
+// log with the task.
+func (s *SecretService) TaskSecrets(ctx context.Context, taskID uuid.UUID, names []string) ([]TaskSecret, error) {
+	if s.cipher == nil {
+		return nil, domain.ErrSecretsUnavailable
+	}
+	task, err := runningTask(ctx, s.taskRepo, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := s.secretRepo.GetByOfficeID(ctx, task.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+	var opened []TaskSecret
+	var ids []uuid.UUID
+	for _, secret := range secrets {
+		if !secret.AvailableTo(task.AgentID) || (len(names) > 0 && !slices.Contains(names, secret.Name)) {
+			continue
+		}
+		value, err := s.cipher.Open(secret.Ciphertext, secretAdditionalData(secret))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", secret.Name, err)
+		}
+		opened = append(opened, TaskSecret{Name: secret.Name, Value: string(value)})
+		ids = append(ids, secret.ID)
+	}
+
+	if err := s.secretRepo.MarkAccessed(ctx, ids, time.Now()); err != nil {
+		log.Printf("Failed to record access to secrets of task %s: %v", task.ID, err)
+	}
+	for i, id := range ids {
+		s.audit.Record(ctx, AuditEvent{
+			Action:     domain.AuditActionSecretAccess,
+			EntityType: domain.AuditEntitySecret,
+			EntityID:   id,
+			OfficeID:   task.OfficeID,
+			Details: map[string]any{
+				"name":     opened[i].Name,
+				"task_id":  task.ID,
+				"agent_id": task.AgentID,
+			},
+		})
+	}
+	return opened, nil
+}
+
+// seal encrypts value into the secret and sets its hint
+func (s *SecretService) seal(secret *domain.OfficeSecret, value string) error {
+	ciphertext, err := s.cipher.Seal([]byte(value), secretAdditionalData(secret))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	secret.Ciphertext = ciphertext
+	secret.Hint = ""
+	if runes := []rune(value); len(runes) >= minHintedSecretLength {
+		secret.Hint = string(runes[len(runes)-secretHintLength:])
+	}
+	return nil
+}
+
+// officeSecret returns the secret if it belongs to the office, and
+// ErrNotFound otherwise
+func (s *SecretService) officeSecret(ctx context.Context, officeID, secretID uuid.UUID) (*domain.OfficeSecret, error) {
+	secret, err := s.secretRepo.GetByID(ctx, secretID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(secret.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// officeAgentIDs checks that the agents belong to the office and drops
+// duplicates
+func (s *SecretService) officeAgentIDs(ctx context.Context, officeID uuid.UUID, agentIDs []uuid.UUID) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for _, id := range agentIDs {
+		if slices.Contains(ids, id) {
+			continue
+		}
+		if _, err := officeAgent(ctx, s.agentRepo, officeID, id); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return nil, fmt.Errorf("%w: unknown agent %s", domain.ErrInvalidInput, id)
+			}
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// validateSecretValue checks that a secret's value is text of a sensible
+// size
+func validateSecretValue(value string) error {
+	switch {
+	case value == "" || len(value) > maxSecretValueLength:
+		return fmt.Errorf("%w: value is required and must be at most %d bytes", domain.ErrInvalidInput, maxSecretValueLength)
+	case !utf8.ValidString(value):
+		return fmt.Errorf("%w: value must be UTF-8 text", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+func validateSecretDescription(description string) error {
+	if len(description) > maxSecretDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", domain.ErrInvalidInput, maxSecretDescriptionLength)
+	}
+	return nil
+}
+
+// secretAdditionalData binds a ciphertext to its office and secret
+func secretAdditionalData(secret *domain.OfficeSecret) []byte {
+	return slices.Concat(secret.OfficeID[:], secret.ID[:])
+}
+
+// secretAuditState is what the audit log records of a secret; never its
+// value
+func secretAuditState(secret *domain.OfficeSecret) map[string]any {
+	return map[string]any{
+		"name":        secret.Name,
+		"description": secret.Description,
+		"agent_ids":   secret.AgentIDs,
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateSecretSealsValueWithHint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	secrets := mocks.NewMockOfficeSecretRepository(ctrl)
+	cipher := mocks.NewMockSecretCipher(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc := NewSecretService(secrets, nil, nil, cipher, NewAuditService(auditRepo, nil, nil))
+	officeID := uuid.New()
+
+	if _, err := svc.CreateSecret(context.Background(), CreateSecretInput{OfficeID: officeID, Name: "github-token", Value: "x"}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("CreateSecret with a lower case name error = %v, want ErrInvalidInput", err)
+	}
+
+	secrets.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, nil)
+	cipher.EXPECT().Seal([]byte("ghp_0123456789abcdef"), gomock.Any()).Return([]byte("sealed"), nil)
+	secrets.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+	auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, entry *domain.AuditEntry) error {
+		if entry.Action != domain.AuditActionSecretCreate || entry.After["value"] != nil {
+			t.Errorf("audit entry = %s %v, want a create without the value", entry.Action, entry.After)
+		}
+		return nil
+	})
+	secret, err := svc.CreateSecret(context.Background(), CreateSecretInput{OfficeID: officeID, Name: "GITHUB_TOKEN", Value: "ghp_0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("CreateSecret: %v", err)
+	}
+	if string(secret.Ciphertext) != "sealed" || secret.Hint != "cdef" || len(secret.AgentIDs) != 0 {
+		t.Errorf("secret = %+v, want the sealed value with its hint for all agents", secret)
+	}
+}
+
+func TestTaskSecretsOnlyWhileRunningAndForTheAgent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	secrets := mocks.NewMockOfficeSecretRepository(ctrl)
+	tasks := mocks.NewMockTaskRepository(ctrl)
+	cipher := mocks.NewMockSecretCipher(ctrl)
+	auditRepo := mocks.NewMockAuditRepository(ctrl)
+	svc := NewSecretService(secrets, nil, tasks, cipher, NewAuditService(auditRepo, nil, nil))
+
+	officeID, agentID := uuid.New(), uuid.New()
+	running := &domain.Task{ID: uuid.New(), OfficeID: officeID, AgentID: agentID, Status: domain.TaskStatusWorking}
+	done := &domain.Task{ID: uuid.New(), OfficeID: officeID, AgentID: agentID, Status: domain.TaskStatusDone}
+	shared := &domain.OfficeSecret{ID: uuid.New(), OfficeID: officeID, Name: "GITHUB_TOKEN", Ciphertext: []byte("a")}
+	own := &domain.OfficeSecret{ID: uuid.New(), OfficeID: officeID, Name: "DEPLOY_KEY", AgentIDs: []uuid.UUID{agentID}, Ciphertext: []byte("b")}
+	other := &domain.OfficeSecret{ID: uuid.New(), OfficeID: officeID, Name: "STRIPE_KEY", AgentIDs: []uuid.UUID{uuid.New()}, Ciphertext: []byte("c")}
+
+	tasks.EXPECT().GetByID(gomock.Any(), done.ID).Return(done, nil)
+	if _, err := svc.TaskSecrets(context.Background(), done.ID, nil); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("TaskSecrets of a finished task error = %v, want ErrForbidden", err)
+	}
+
+	tasks.EXPECT().GetByID(gomock.Any(), running.ID).Return(running, nil)
+	secrets.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return([]*domain.OfficeSecret{shared, own, other}, nil)
+	cipher.EXPECT().Open(gomock.Any(), gomock.Any()).DoAndReturn(func(ciphertext, additionalData []byte) ([]byte, error) {
+		return append([]byte("value-"), ciphertext...), nil
+	}).Times(2)
+	secrets.EXPECT().MarkAccessed(gomock.Any(), []uuid.UUID{shared.ID, own.ID}, gomock.Any()).Return(nil)
+	var audited []uuid.UUID
+	auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, entry *domain.AuditEntry) error {
+		if entry.Action != domain.AuditActionSecretAccess || entry.Details["task_id"] != running.ID {
+			t.Errorf("audit entry = %s %v, want an access by the task", entry.Action, entry.Details)
+		}
+		audited = append(audited, entry.EntityID)
+		return nil
+	}).Times(2)
+
+	opened, err := svc.TaskSecrets(context.Background(), running.ID, nil)
+	if err != nil {
+		t.Fatalf("TaskSecrets: %v", err)
+	}
+	if len(opened) != 2 || opened[0] != (TaskSecret{"GITHUB_TOKEN", "value-a"}) || opened[1] != (TaskSecret{"DEPLOY_KEY", "value-b"}) {
+		t.Errorf("TaskSecrets = %+v, want the shared secret and the agent's own", opened)
+	}
+	if len(audited) != 2 {
+		t.Errorf("audited %d accesses, want 2", len(audited))
+	}
+}
+
+func TestSecretsUnavailableWithoutCipher(t *testing.T) {
+	svc := NewSecretService(nil, nil, nil, nil, nil)
+	if _, err := svc.GetSecrets(context.Background(), uuid.New()); !errors.Is(err, domain.ErrSecretsUnavailable) {
+		t.Errorf("GetSecrets without a cipher error = %v, want ErrSecretsUnavailable", err)
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// skillCatalog is every skill agents can be given, in the order they are
+// listed. Code execution is off until enabled for an agent.
+var skillCatalog = []domain.Skill{
+	{
+		Key:            domain.SkillWebSearch,
+		Name:           "Web search",
+		Description:    "Search the web and read the results",
+		DefaultEnabled: true,
+	},
+	{
+		Key:         domain.SkillCodeExec,
+		Name:        "Code execution",
+		Description: "Run code in a sandbox for calculations and data analysis",
+	},
+	{
+		Key:            domain.SkillEmailDraft,
+		Name:           "Email drafting",
+		Description:    "Draft emails for a person to review and send",
+		DefaultEnabled: true,
+	},
+	{
+		Key:            domain.SkillCalendar,
+		Name:           "Calendar",
+		Description:    "Check availability and propose meeting times",
+		DefaultEnabled: true,
+	},
+}
+
+// catalogSkill returns the catalog's skill with the key
+func catalogSkill(key string) (domain.Skill, bool) {
+	for _, skill := range skillCatalog {
+		if skill.Key == key {
+			return skill, true
+		}
+	}
+	return domain.Skill{}, false
+}
+
+// SkillService manages which skills each agent is given. The orchestrator
+// is sent the skills an agent uses with each of its tasks and only permits
+// their tools.
+type SkillService struct {
+	skillRepo           domain.AgentSkillRepository
+	agentRepo           domain.AgentRepository
+	subscriptionService *SubscriptionService
+}
+
+// NewSkillService creates a new SkillService instance
+func NewSkillService(
+	skillRepo domain.AgentSkillRepository,
+	agentRepo domain.AgentRepository,
+	subscriptionService *SubscriptionService,
+) *SkillService {
+	return &SkillService{
+		skillRepo:           skillRepo,
+		agentRepo:           agentRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// Catalog returns every skill agents can be given
+func (s *SkillService) Catalog() []domain.Skill {
+	return slices.Clone(skillCatalog)
+}
+
+// GetAgentSkills returns the catalog as configured for one of the office's
+// agents
+func (s *SkillService) GetAgentSkills(ctx context.Context, officeID, agentID uuid.UUID) ([]*domain.AgentSkill, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+	return s.agentSkills(ctx, officeID, agentID)
+}
+
+// UpdateAgentSkills enables or disables skills, by key, for one of the
+// office's agents, leaving the others as they are. Enabling a skill the
+// office's tier does not include returns ErrUpgradeRequired; disabling one
+// is always allowed.
+func (s *SkillService) UpdateAgentSkills(ctx context.Context, officeID, agentID uuid.UUID, skills map[string]bool) ([]*domain.AgentSkill, error) {
+	if _, err := officeAgent(ctx, s.agentRepo, officeID, agentID); err != nil {
+		return nil, err
+	}
+	if len(skills) == 0 {
+		return nil, fmt.Errorf("%w: skills is required", domain.ErrInvalidInput)
+	}
+	for _, key := range slices.Sorted(maps.Keys(skills)) {
+		if _, ok := catalogSkill(key); !ok {
+			return nil, fmt.Errorf("%w: unknown skill %q", domain.ErrInvalidInput, key)
+		}
+		if skills[key] {
+			if err := s.subscriptionService.RequireSkill(ctx, officeID, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.skillRepo.Set(ctx, agentID, skills); err != nil {
+		return nil, err
+	}
+	return s.agentSkills(ctx, officeID, agentID)
+}
+
+// EnabledSkills returns the keys of the skills an agent uses, those both
+// enabled for it and available on the office's tier, in catalog order
+func (s *SkillService) EnabledSkills(ctx context.Context, officeID, agentID uuid.UUID) ([]string, error) {
+	skills, err := s.agentSkills(ctx, officeID, agentID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make([]string, 0, len(skills))
+	for _, skill := range skills {
+		if skill.Enabled && skill.Available {
+			enabled = append(enabled, skill.Key)
+		}
+	}
+	return enabled, nil
+}
+
+// agentSkills combines the catalog, the agent's configuration and the
+// office's tier
+func (s *SkillService) agentSkills(ctx context.Context, officeID, agentID uuid.UUID) ([]*domain.AgentSkill, error) {
+	features, err := s.subscriptionService.GetOfficeFeatures(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	configured, err := s.skillRepo.GetByAgentID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	skills := make([]*domain.AgentSkill, len(skillCatalog))
+	for i, skill := range skillCatalog {
+		enabled, ok := configured[skill.Key]
+		if !ok {
+			enabled = skill.DefaultEnabled
+		}
+		agentSkill := &domain.AgentSkill{
+			Skill:     skill,
+			Enabled:   enabled,
+			Available: slices.Contains(features.Skills, skill.Key),
+		}
+		if !agentSkill.Available {
+			agentSkill.RequiredTier, _ = s.subscriptionService.cheapestTierWith(func(f *domain.TierFeatures) bool {
+				return slices.Contains(f.Skills, skill.Key)
+			})
+		}
+		skills[i] = agentSkill
+	}
+	return skills, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestEnabledSkillsKeepsToTheTier(t *testing.T) {
+	subscriptions, m := newTestSubscriptionService(t)
+	skills := mocks.NewMockAgentSkillRepository(gomock.NewController(t))
+	svc := NewSkillService(skills, nil, subscriptions)
+	officeID, agentID := uuid.New(), uuid.New()
+
+	// code_exec is enabled but the professional tier does not include it
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(&domain.Subscription{Tier: domain.TierProfessional}, nil)
+	skills.EXPECT().GetByAgentID(gomock.Any(), agentID).Return(map[string]bool{
+		domain.SkillCodeExec:   true,
+		domain.SkillEmailDraft: false,
+	}, nil)
+
+	enabled, err := svc.EnabledSkills(context.Background(), officeID, agentID)
+	if err != nil {
+		t.Fatalf("EnabledSkills: %v", err)
+	}
+	if want := []string{domain.SkillWebSearch, domain.SkillCalendar}; !slices.Equal(enabled, want) {
+		t.Errorf("EnabledSkills = %v, want %v", enabled, want)
+	}
+}
+
+func TestUpdateAgentSkillsRequiresTheTier(t *testing.T) {
+	subscriptions, m := newTestSubscriptionService(t)
+	ctrl := gomock.NewController(t)
+	skills := mocks.NewMockAgentSkillRepository(ctrl)
+	agents := mocks.NewMockAgentRepository(ctrl)
+	svc := NewSkillService(skills, agents, subscriptions)
+	officeID, agentID := uuid.New(), uuid.New()
+
+	agents.EXPECT().GetByID(gomock.Any(), agentID).Return(&domain.Agent{ID: agentID, OfficeID: officeID}, nil).AnyTimes()
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(nil, domain.ErrNotFound).AnyTimes()
+
+	_, err := svc.UpdateAgentSkills(context.Background(), officeID, agentID, map[string]bool{domain.SkillWebSearch: true})
+	if !errors.Is(err, domain.ErrUpgradeRequired) {
+		t.Fatalf("UpdateAgentSkills enabling web_search on solo error = %v, want ErrUpgradeRequired", err)
+	}
+	if details := domain.ErrorDetails(err); details["skill"] != domain.SkillWebSearch || details["required_tier"] != domain.TierProfessional {
+		t.Errorf("upgrade details = %v, want web_search and the professional tier", details)
+	}
+
+	_, err = svc.UpdateAgentSkills(context.Background(), officeID, agentID, map[string]bool{"teleport": false})
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("UpdateAgentSkills of an unknown skill error = %v, want ErrInvalidInput", err)
+	}
+
+	// Disabling needs no tier
+	skills.EXPECT().Set(gomock.Any(), agentID, map[string]bool{domain.SkillWebSearch: false}).Return(nil)
+	skills.EXPECT().GetByAgentID(gomock.Any(), agentID).Return(map[string]bool{domain.SkillWebSearch: false}, nil)
+	updated, err := svc.UpdateAgentSkills(context.Background(), officeID, agentID, map[string]bool{domain.SkillWebSearch: false})
+	if err != nil {
+		t.Fatalf("UpdateAgentSkills disabling web_search: %v", err)
+	}
+	if updated[0].Key != domain.SkillWebSearch || updated[0].Enabled || updated[0].Available {
+		t.Errorf("web_search = %+v, want it disabled and unavailable on solo", updated[0])
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// CancelSubscription cancels the office's subscription. By default it stays
+// active until the end of the billing period; an immediate cancellation ends
+// it now. Either way the office keeps read access to its data for its tier's
+// retention period after the subscription ends.
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, officeID uuid.UUID, immediate bool) (*domain.Subscription, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == domain.SubscriptionStatusCancelled {
+		return nil, fmt.Errorf("%w: subscription is already cancelled", domain.ErrInvalidInput)
+	}
+	if !immediate && sub.CancelAtPeriodEnd {
+		return sub, nil
+	}
+
+	if err := s.syncBilling(sub, func(billing domain.BillingProvider, id string) error {
+		return billing.CancelSubscription(ctx, id, !immediate)
+	}); err != nil {
+		return nil, err
+	}
+
+	if !immediate {
+		if err := s.subRepo.SetCancelAtPeriodEnd(ctx, sub.ID, true); err != nil {
+			return nil, err
+		}
+		s.notifyStatusChanged(ctx, sub, "Your subscription will end",
+			fmt.Sprintf("Your subscription stays active until %s and then ends.", sub.CurrentPeriodEnd.Format("January 2, 2006")))
+		return s.subRepo.GetByID(ctx, sub.ID)
+	}
+
+	if err := s.subRepo.Cancel(ctx, sub.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	s.notifyStatusChanged(ctx, sub, "Your subscription has ended",
+		"Your subscription was cancelled. Agents no longer run tasks, but your conversations stay readable for your plan's retention period.")
+	return s.subRepo.GetByID(ctx, sub.ID)
+}
+
+// PauseSubscription pauses the office's active subscription. Agents do not
+// run tasks while it is paused.
+func (s *SubscriptionService) PauseSubscription(ctx context.Context, officeID uuid.UUID) (*domain.Subscription, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != domain.SubscriptionStatusActive && sub.Status != domain.SubscriptionStatusTrialing {
+		return nil, fmt.Errorf("%w: a %s subscription cannot be paused", domain.ErrInvalidInput, sub.Status)
+	}
+
+	if err := s.syncBilling(sub, func(billing domain.BillingProvider, id string) error {
+		return billing.PauseSubscription(ctx, id)
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.subRepo.UpdateStatus(ctx, sub.ID, domain.SubscriptionStatusPaused); err != nil {
+		return nil, err
+	}
+
+	s.notifyStatusChanged(ctx, sub, "Your subscription is paused",
+		"Your subscription is paused. Agents will not run tasks until you resume it.")
+	return s.subRepo.GetByID(ctx, sub.ID)
+}
+
+// ResumeSubscription resumes the office's paused subscription
+func (s *SubscriptionService) ResumeSubscription(ctx context.Context, officeID uuid.UUID) (*domain.Subscription, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != domain.SubscriptionStatusPaused {
+		return nil, fmt.Errorf("%w: only a paused subscription can be resumed", domain.ErrInvalidInput)
+	}
+
+	if err := s.syncBilling(sub, func(billing domain.BillingProvider, id string) error {
+		return billing.ResumeSubscription(ctx, id)
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.subRepo.UpdateStatus(ctx, sub.ID, domain.SubscriptionStatusActive); err != nil {
+		return nil, err
+	}
+
+	s.notifyStatusChanged(ctx, sub, "Your subscription is active again",
+		"Your subscription was resumed and your agents are back at work.")
+	return s.subRepo.GetByID(ctx, sub.ID)
+}
+
+// RequireActive returns ErrSubscriptionInactive if the office's
+// subscription is cancelled, paused or unpaid, when its agents may not run
+// tasks. Offices without a subscription are on the free tier and may.
+func (s *SubscriptionService) RequireActive(ctx context.Context, officeID uuid.UUID) error {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch sub.Status {
+	case domain.SubscriptionStatusCancelled, domain.SubscriptionStatusPaused, domain.SubscriptionStatusUnpaid:
+		return domain.WithDetails(
+			fmt.Errorf("%w: your subscription is %s", domain.ErrSubscriptionInactive, sub.Status),
+			map[string]any{"status": sub.Status},
+		)
+	}
+	return nil
+}
+
+// syncBilling applies a change to the subscription's Stripe subscription,
+// if it has one, before it is made here
+func (s *SubscriptionService) syncBilling(sub *domain.Subscription, change func(domain.BillingProvider, string) error) error {
+	if sub.StripeSubscriptionID == "" {
+		return nil
+	}
+	if s.billing == nil {
+		log.Printf("Not syncing subscription %s with Stripe: no Stripe key is configured", sub.ID)
+		return nil
+	}
+	if err := change(s.billing, sub.StripeSubscriptionID); err != nil {
+		return fmt.Errorf("failed to update Stripe subscription: %w", err)
+	}
+	return nil
+}
+
+// applyDueCancellations ends one batch of subscriptions set to cancel at
+// the end of a period that has ended. Stripe ends its side on its own.
+func (s *SubscriptionService) applyDueCancellations(ctx context.Context) {
+	subs, err := s.subRepo.GetDueCancellations(ctx, time.Now(), tierChangeBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due cancellations: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.subRepo.Cancel(ctx, sub.ID, sub.CurrentPeriodEnd); err != nil {
+			log.Printf("Failed to cancel subscription %s: %v", sub.ID, err)
+			continue
+		}
+		s.notifyStatusChanged(ctx, sub, "Your subscription has ended",
+			"Your subscription reached the end of its billing period. Agents no longer run tasks, but your conversations stay readable for your plan's retention period.")
+	}
+}
+
+// notifyStatusChanged tells an office its subscription was cancelled, paused
+// or resumed
+func (s *SubscriptionService) notifyStatusChanged(ctx context.Context, sub *domain.Subscription, title, body string) {
+	_, err := s.notifications.Notify(ctx, sub.OfficeID, domain.NotificationTypeSubscriptionUpdated, title, body,
+		map[string]any{"subscription_id": sub.ID.String(), "tier": sub.Tier})
+	if err != nil {
+		log.Printf("Failed to notify office %s of its subscription change: %v", sub.OfficeID, err)
+	}
+}
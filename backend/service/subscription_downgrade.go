@@ -0,0 +1,263 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// tierChangePollInterval is how often the subscription worker looks for
+	// downgrades, cancellations, trial ends and renewals that are due
+	tierChangePollInterval = time.Minute
+	tierChangeBatchSize    = 50
+
+	// officeSeats is the number of seats an office takes up. Offices belong
+	// to a single user.
+	officeSeats = 1
+)
+
+// DowngradeInput contains input for moving an office to a lower tier
+type DowngradeInput struct {
+	OfficeID uuid.UUID
+	Tier     domain.SubscriptionTier
+	// Immediate downgrades now instead of at the end of the billing period,
+	// giving up the unused credits of the current tier
+	Immediate bool
+}
+
+// TierDowngrade describes a downgrade that was applied or scheduled
+type TierDowngrade struct {
+	Tier        domain.SubscriptionTier `json:"tier"`
+	Immediate   bool                    `json:"immediate"`
+	EffectiveAt time.Time               `json:"effective_at"`
+	// CreditsRemoved is the credits taken back by an immediate downgrade
+	CreditsRemoved int64 `json:"credits_removed"`
+}
+
+// DowngradeTier moves an office to a lower tier. The office must already fit
+// the lower tier's limits. By default the downgrade is scheduled for the end
+// of the billing period, keeping the current tier until then; an immediate
+// downgrade applies it now and takes back the unused credits of the current
+// tier.
+func (s *SubscriptionService) DowngradeTier(ctx context.Context, input DowngradeInput) (*TierDowngrade, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, input.OfficeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tierDef, err := s.GetTier(input.Tier)
+	if err != nil {
+		return nil, err
+	}
+	oldTierDef, err := s.GetTier(sub.Tier)
+	if err != nil {
+		return nil, err
+	}
+	if s.compareTiers(input.Tier, sub.Tier) >= 0 {
+		return nil, fmt.Errorf("%w: %s is not a lower tier than %s", domain.ErrInvalidInput, input.Tier, sub.Tier)
+	}
+	if err := s.requireFits(ctx, input.OfficeID, tierDef); err != nil {
+		return nil, err
+	}
+
+	if !input.Immediate {
+		if err := s.subRepo.ScheduleTierChange(ctx, sub.ID, input.Tier, sub.CurrentPeriodEnd); err != nil {
+			return nil, err
+		}
+		_, err := s.notifications.Notify(ctx, input.OfficeID, domain.NotificationTypeSubscriptionUpdated,
+			fmt.Sprintf("Your office moves to %s on %s", tierDef.Name, sub.CurrentPeriodEnd.Format("January 2, 2006")),
+			fmt.Sprintf("Your subscription stays on the %s tier until the end of its billing period and then changes to the %s tier.", oldTierDef.Name, tierDef.Name),
+			map[string]any{
+				"old_tier":     sub.Tier,
+				"new_tier":     input.Tier,
+				"effective_at": sub.CurrentPeriodEnd,
+			},
+		)
+		if err != nil {
+			log.Printf("Failed to notify office %s of its scheduled downgrade: %v", input.OfficeID, err)
+		}
+		s.audit.Record(ctx, AuditEvent{
+			Action:     domain.AuditActionTierChange,
+			EntityType: domain.AuditEntityOffice,
+			EntityID:   input.OfficeID,
+			OfficeID:   input.OfficeID,
+			Before:     map[string]any{"tier": sub.Tier},
+			After:      map[string]any{"tier": sub.Tier, "pending_tier": input.Tier},
+			Details:    map[string]any{"change": "scheduled_downgrade", "effective_at": sub.CurrentPeriodEnd},
+		})
+		return &TierDowngrade{Tier: input.Tier, EffectiveAt: sub.CurrentPeriodEnd}, nil
+	}
+
+	// The tier only changes together with the credits it gives up
+	now := time.Now()
+	var removed int64
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.subRepo.UpdateTier(ctx, sub.ID, input.Tier); err != nil {
+			return err
+		}
+		if err := s.subRepo.ClearTierChange(ctx, sub.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+		removed, err = s.clawBackCredits(ctx, sub, oldTierDef, tierDef, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyTierChanged(ctx, input.OfficeID, sub.Tier, input.Tier, tierDef)
+	s.recordTierChange(ctx, sub, input.Tier, map[string]any{"change": "downgrade", "credits_removed": removed})
+	return &TierDowngrade{Tier: input.Tier, Immediate: true, EffectiveAt: now, CreditsRemoved: removed}, nil
+}
+
+// CancelDowngrade cancels the office's scheduled downgrade. It returns
+// ErrNotFound if none is pending.
+func (s *SubscriptionService) CancelDowngrade(ctx context.Context, officeID uuid.UUID) error {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	return s.subRepo.ClearTierChange(ctx, sub.ID)
+}
+
+// requireFits returns ErrTierLimitExceeded, with the limit and the current
+// count as details, unless the office's agents and seats fit within the
+// tier's limits
+func (s *SubscriptionService) requireFits(ctx context.Context, officeID uuid.UUID, tierDef *domain.TierDefinition) error {
+	agents, err := s.agentRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	if limit := tierDef.Features.MaxAgents; limit != -1 && len(agents) > limit {
+		return domain.WithDetails(
+			fmt.Errorf("%w: the %s tier allows at most %d agents; remove %d first",
+				domain.ErrTierLimitExceeded, tierDef.Name, limit, len(agents)-limit),
+			map[string]any{"limit": limit, "current": len(agents)},
+		)
+	}
+	if limit := tierDef.Features.MaxSeats; limit != -1 && officeSeats > limit {
+		return domain.WithDetails(
+			fmt.Errorf("%w: the %s tier allows at most %d seats", domain.ErrTierLimitExceeded, tierDef.Name, limit),
+			map[string]any{"limit": limit, "current": officeSeats},
+		)
+	}
+	return nil
+}
+
+// clawBackCredits takes back the credits an immediate downgrade gives up:
+// the difference between the two tiers' monthly credits, pro-rated to the
+// part of the billing period that is left. Credits already spent are not
+// taken back, so the wallet never goes below zero.
+func (s *SubscriptionService) clawBackCredits(ctx context.Context, sub *domain.Subscription, oldTierDef, tierDef *domain.TierDefinition, now time.Time) (int64, error) {
+	difference := oldTierDef.Features.MonthlyCredits - tierDef.Features.MonthlyCredits
+	period := sub.CurrentPeriodEnd.Sub(sub.CurrentPeriodStart)
+	remaining := sub.CurrentPeriodEnd.Sub(now)
+	if difference <= 0 || period <= 0 || remaining <= 0 {
+		return 0, nil
+	}
+	amount := difference * int64(min(remaining, period)) / int64(period)
+
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, sub.OfficeID)
+	if err != nil {
+		return 0, err
+	}
+	amount = min(amount, wallet.Balance)
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	_, err = s.creditRepo.AddCredits(
+		ctx, wallet.ID, -amount,
+		domain.TransactionTypeAdjustment,
+		"Tier downgrade unused credits",
+		"subscription", &sub.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// Run applies downgrades and cancellations as their billing periods end,
+// ends trials that have run out and renews the periods of everything else,
+// until ctx is cancelled
+func (s *SubscriptionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(tierChangePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.applyDueDowngrades(ctx)
+			s.applyDueCancellations(ctx)
+			s.endDueTrials(ctx)
+			s.renewDuePeriods(ctx)
+		}
+	}
+}
+
+// applyDueDowngrades applies one batch of due downgrades. Agents added since
+// a downgrade was scheduled are kept, but count against the lower limit.
+func (s *SubscriptionService) applyDueDowngrades(ctx context.Context) {
+	subs, err := s.subRepo.GetDueTierChanges(ctx, time.Now(), tierChangeBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due downgrades: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		newTier := *sub.PendingTier
+		tierDef, err := s.GetTier(newTier)
+		if err != nil {
+			log.Printf("Cancelling downgrade of subscription %s: %v", sub.ID, err)
+			if err := s.subRepo.ClearTierChange(ctx, sub.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+				log.Printf("Failed to cancel downgrade of subscription %s: %v", sub.ID, err)
+			}
+			continue
+		}
+
+		err = s.subRepo.ApplyTierChange(ctx, sub.ID, newTier)
+		if errors.Is(err, domain.ErrNotFound) {
+			// Cancelled or replaced since it was loaded
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to apply downgrade of subscription %s: %v", sub.ID, err)
+			continue
+		}
+		s.notifyTierChanged(ctx, sub.OfficeID, sub.Tier, newTier, tierDef)
+	}
+}
+
+// compareTiers orders tiers by monthly price. Tiers without a price, such as
+// those sold on request, rank above all others.
+func (s *SubscriptionService) compareTiers(a, b domain.SubscriptionTier) int {
+	var pa, pb *float64
+	if def, ok := s.config().tiers[a]; ok {
+		pa = def.PriceMonthlyUSD
+	}
+	if def, ok := s.config().tiers[b]; ok {
+		pb = def.PriceMonthlyUSD
+	}
+	switch {
+	case pa == nil && pb == nil:
+		return cmp.Compare(a, b)
+	case pa == nil:
+		return 1
+	case pb == nil:
+		return -1
+	}
+	return cmp.Or(cmp.Compare(*pa, *pb), cmp.Compare(a, b))
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// renewDuePeriods renews one batch of subscriptions whose billing period has
+// ended
+func (s *SubscriptionService) renewDuePeriods(ctx context.Context) {
+	subs, err := s.subRepo.GetDueRenewals(ctx, time.Now(), tierChangeBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due renewals: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.renewPeriod(ctx, sub); err != nil {
+			log.Printf("Failed to renew subscription %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// renewPeriod starts the subscription's next billing period with its tier's
+// credits. Of the credits the ended period was allocated, including those
+// it carried over, the unused ones are carried over as far as the tier's
+// rollover percentage allows and expire otherwise. Allocated credits count
+// as spent before purchased ones. A subscription more than one period
+// behind is renewed a period at a time.
+func (s *SubscriptionService) renewPeriod(ctx context.Context, sub *domain.Subscription) error {
+	tierDef, err := s.GetTier(sub.Tier)
+	if err != nil {
+		return err
+	}
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, sub.OfficeID)
+	if err != nil {
+		return err
+	}
+
+	renewal := &domain.PeriodRenewal{
+		SubscriptionID: sub.ID,
+		WalletID:       wallet.ID,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		NextPeriodEnd:  nextPeriodEnd(sub.CurrentPeriodEnd, sub.BillingInterval),
+	}
+	// Tiers with unlimited credits (-1) have no allocation to renew
+	if tierDef.Features.MonthlyCredits > 0 {
+		renewal.Credits = tierDef.Features.MonthlyCredits * periodMonths(sub.BillingInterval)
+
+		unused, err := s.unusedPeriodCredits(ctx, sub, wallet.ID)
+		if err != nil {
+			return err
+		}
+		percent := min(max(tierDef.Features.RolloverPercent, 0), 100)
+		renewal.RolloverCredits = unused * int64(percent) / 100
+		renewal.ExpiredCredits = max(min(unused-renewal.RolloverCredits, wallet.Balance), 0)
+	}
+
+	err = s.subRepo.RenewPeriod(ctx, renewal)
+	if errors.Is(err, domain.ErrNotFound) {
+		// Renewed elsewhere since it was loaded
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Your %s subscription renewed until %s.", tierDef.Name, renewal.NextPeriodEnd.Format("January 2, 2006"))
+	if renewal.Credits > 0 {
+		body += fmt.Sprintf(" %d credits were added.", renewal.Credits)
+	}
+	if renewal.RolloverCredits > 0 {
+		body += fmt.Sprintf(" %d unused credits carried over.", renewal.RolloverCredits)
+	}
+	_, err = s.notifications.Notify(ctx, sub.OfficeID, domain.NotificationTypeSubscriptionRenewed,
+		"Your subscription renewed", body,
+		map[string]any{
+			"subscription_id":  sub.ID.String(),
+			"tier":             sub.Tier,
+			"period_end":       renewal.NextPeriodEnd,
+			"credits":          renewal.Credits,
+			"rollover_credits": renewal.RolloverCredits,
+			"expired_credits":  renewal.ExpiredCredits,
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify office %s of its renewal: %v", sub.OfficeID, err)
+	}
+	return nil
+}
+
+// unusedPeriodCredits returns how many of the credits allocated to the
+// subscription's current period, including rollover, were not consumed
+func (s *SubscriptionService) unusedPeriodCredits(ctx context.Context, sub *domain.Subscription, walletID uuid.UUID) (int64, error) {
+	allocs, err := s.subRepo.GetAllocationsBySubscription(ctx, sub.ID, 10)
+	if err != nil {
+		return 0, err
+	}
+	var alloc *domain.CreditAllocation
+	for _, candidate := range allocs {
+		// Trial credits expire with the trial
+		if candidate.Source != trialAllocationSource && candidate.PeriodStart.Before(sub.CurrentPeriodEnd) {
+			alloc = candidate
+			break
+		}
+	}
+	if alloc == nil {
+		return 0, nil
+	}
+
+	consumed, err := s.creditRepo.GetConsumedSince(ctx, walletID, alloc.PeriodStart)
+	if err != nil {
+		return 0, err
+	}
+	return max(alloc.CreditsAllocated+alloc.RolloverCredits-consumed, 0), nil
+}
+
+// nextPeriodEnd returns the end of the billing period starting at start
+func nextPeriodEnd(start time.Time, interval domain.BillingInterval) time.Time {
+	return start.AddDate(0, int(periodMonths(interval)), 0)
+}
+
+// periodMonths returns the length of a billing period in months
+func periodMonths(interval domain.BillingInterval) int64 {
+	if interval == domain.BillingIntervalYearly {
+		return 12
+	}
+	return 1
+}
@@ -2,135 +2,101 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"os"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
-	"gopkg.in/yaml.v3"
 )
 
 // SubscriptionService handles subscription business logic
 type SubscriptionService struct {
 	subRepo    domain.SubscriptionRepository
 	creditRepo domain.CreditRepository
-	tiers      map[domain.SubscriptionTier]*domain.TierDefinition
-	tiersPath  string
+	txManager  domain.TxManager
+	// tiers is the live tier configuration, loaded from tiersPath
+	tiers     atomic.Pointer[tierConfig]
+	tiersPath string
+
+	// notifications tells offices their tier changed
+	notifications *NotificationService
+
+	// agentRepo counts the agents an office must fit into a lower tier
+	agentRepo domain.AgentRepository
+
+	// billing, if set, is told of cancellations and pauses of subscriptions
+	// billed through Stripe
+	billing domain.BillingProvider
+
+	// audit records the tier changes offices make
+	audit *AuditService
 }
 
 // NewSubscriptionService creates a new subscription service
 func NewSubscriptionService(
 	subRepo domain.SubscriptionRepository,
 	creditRepo domain.CreditRepository,
+	agentRepo domain.AgentRepository,
+	txManager domain.TxManager,
+	billing domain.BillingProvider,
+	notifications *NotificationService,
+	audit *AuditService,
 	tiersPath string,
 ) *SubscriptionService {
 	svc := &SubscriptionService{
-		subRepo:    subRepo,
-		creditRepo: creditRepo,
-		tiersPath:  tiersPath,
-		tiers:      make(map[domain.SubscriptionTier]*domain.TierDefinition),
+		subRepo:       subRepo,
+		creditRepo:    creditRepo,
+		agentRepo:     agentRepo,
+		txManager:     txManager,
+		billing:       billing,
+		notifications: notifications,
+		audit:         audit,
+		tiersPath:     tiersPath,
 	}
 	svc.loadTiers()
 	return svc
 }
 
-// TierConfig represents the YAML structure
-type TierConfig struct {
-	Tiers map[string]domain.TierDefinition `yaml:"tiers"`
-}
-
-// loadTiers loads tier definitions from YAML
-func (s *SubscriptionService) loadTiers() error {
-	data, err := os.ReadFile(s.tiersPath)
-	if err != nil {
-		// Use defaults if file not found
-		s.loadDefaultTiers()
-		return nil
-	}
-
-	var config TierConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		s.loadDefaultTiers()
-		return err
-	}
-
-	for tierKey, tierDef := range config.Tiers {
-		tier := domain.SubscriptionTier(tierKey)
-		def := tierDef // Copy to avoid pointer issues
-		s.tiers[tier] = &def
-	}
-	return nil
-}
-
-// loadDefaultTiers sets up default tier definitions
-func (s *SubscriptionService) loadDefaultTiers() {
-	s.tiers[domain.TierSolo] = &domain.TierDefinition{
-		Name:        "Solo Founder",
-		Description: "Perfect for individual developers",
-		Features: domain.TierFeatures{
-			MaxAgents:      3,
-			MonthlyCredits: 1000,
-			MaxSeats:       1,
-			ModelAccess:    []string{"ollama", "groq"},
-			Priority:       "low",
-			RetentionDays:  30,
-		},
-	}
-	s.tiers[domain.TierProfessional] = &domain.TierDefinition{
-		Name:        "Professional",
-		Description: "For power users and small teams",
-		Features: domain.TierFeatures{
-			MaxAgents:      10,
-			MonthlyCredits: 10000,
-			MaxSeats:       5,
-			ModelAccess:    []string{"ollama", "groq", "openai"},
-			Priority:       "normal",
-			RetentionDays:  90,
-			WebResearch:    true,
-			APIAccess:      true,
-		},
-	}
-	s.tiers[domain.TierBusiness] = &domain.TierDefinition{
-		Name:        "Business",
-		Description: "For growing teams",
-		Features: domain.TierFeatures{
-			MaxAgents:             50,
-			MonthlyCredits:        50000,
-			MaxSeats:              20,
-			ModelAccess:           []string{"ollama", "groq", "openai", "anthropic"},
-			Priority:              "high",
-			RetentionDays:         365,
-			WebResearch:           true,
-			AdvancedOrchestration: true,
-			Analytics:             true,
-			APIAccess:             true,
-		},
-	}
-}
-
 // GetTier returns the tier definition for a tier
 func (s *SubscriptionService) GetTier(tier domain.SubscriptionTier) (*domain.TierDefinition, error) {
-	def, ok := s.tiers[tier]
+	def, ok := s.config().tiers[tier]
 	if !ok {
-		return nil, errors.New("tier not found")
+		return nil, fmt.Errorf("%w: unknown tier %q", domain.ErrInvalidInput, tier)
 	}
 	return def, nil
 }
 
 // GetAllTiers returns all tier definitions
 func (s *SubscriptionService) GetAllTiers() map[domain.SubscriptionTier]*domain.TierDefinition {
-	return s.tiers
+	return s.config().tiers
+}
+
+// GetCreditPackages returns the add-on credit packs, by name
+func (s *SubscriptionService) GetCreditPackages() map[string]domain.CreditPackage {
+	return s.config().creditPackages
 }
 
 // GetSubscriptionByOffice gets subscription for an office
 func (s *SubscriptionService) GetSubscriptionByOffice(ctx context.Context, officeID uuid.UUID) (*domain.Subscription, error) {
-	return s.subRepo.GetByOfficeID(ctx, officeID)
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	setTrialDaysLeft(sub, time.Now())
+	return sub, nil
 }
 
 // GetSubscriptionSummary gets subscription with usage summary
 func (s *SubscriptionService) GetSubscriptionSummary(ctx context.Context, officeID uuid.UUID) (*domain.SubscriptionSummary, error) {
-	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	sub, err := s.GetSubscriptionByOffice(ctx, officeID)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +127,9 @@ func (s *SubscriptionService) GetSubscriptionSummary(ctx context.Context, office
 	return summary, nil
 }
 
-// UpgradeTier upgrades an office's subscription tier
+// UpgradeTier upgrades an office's subscription tier. Moving to a lower
+// tier is a downgrade and goes through DowngradeTier instead. Upgrading
+// cancels any downgrade still pending.
 func (s *SubscriptionService) UpgradeTier(ctx context.Context, officeID uuid.UUID, newTier domain.SubscriptionTier) error {
 	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
 	if err != nil {
@@ -172,10 +140,8 @@ func (s *SubscriptionService) UpgradeTier(ctx context.Context, officeID uuid.UUI
 	if err != nil {
 		return err
 	}
-
-	// Update tier
-	if err := s.subRepo.UpdateTier(ctx, sub.ID, newTier); err != nil {
-		return err
+	if s.compareTiers(newTier, sub.Tier) < 0 {
+		return fmt.Errorf("%w: %s is a lower tier than %s; downgrade instead", domain.ErrInvalidInput, newTier, sub.Tier)
 	}
 
 	// Allocate additional credits for the new tier (pro-rated for current period)
@@ -185,24 +151,102 @@ func (s *SubscriptionService) UpgradeTier(ctx context.Context, officeID uuid.UUI
 		additionalCredits -= oldTierDef.Features.MonthlyCredits
 	}
 
-	if additionalCredits > 0 {
+	// The tier only changes together with its credits
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.subRepo.UpdateTier(ctx, sub.ID, newTier); err != nil {
+			return err
+		}
+		if err := s.subRepo.ClearTierChange(ctx, sub.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+		if additionalCredits <= 0 {
+			return nil
+		}
+
 		wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
 		if err != nil {
 			return err
 		}
-
 		_, err = s.creditRepo.AddCredits(
 			ctx, wallet.ID, additionalCredits,
 			domain.TransactionTypeSubscription,
 			"Tier upgrade credit allocation",
 			"subscription", &sub.ID,
 		)
-		if err != nil {
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifyTierChanged(ctx, officeID, sub.Tier, newTier, tierDef)
+	s.recordTierChange(ctx, sub, newTier, map[string]any{"change": "upgrade", "credits_added": max(additionalCredits, 0)})
+	return nil
+}
+
+// recordTierChange adds a tier change the office made to the audit log
+func (s *SubscriptionService) recordTierChange(ctx context.Context, sub *domain.Subscription, newTier domain.SubscriptionTier, details map[string]any) {
+	before := map[string]any{"tier": sub.Tier}
+	if sub.PendingTier != nil {
+		before["pending_tier"] = *sub.PendingTier
+	}
+	s.audit.Record(ctx, AuditEvent{
+		Action:     domain.AuditActionTierChange,
+		EntityType: domain.AuditEntityOffice,
+		EntityID:   sub.OfficeID,
+		OfficeID:   sub.OfficeID,
+		Before:     before,
+		After:      map[string]any{"tier": newTier},
+		Details:    details,
+	})
+}
+
+// SetTier moves an office straight to a tier as an admin override. Unlike
+// upgrades and downgrades it neither checks the office fits the tier nor
+// changes its credits; any pending tier change is cancelled. It returns the
+// subscription as it was before the change.
+func (s *SubscriptionService) SetTier(ctx context.Context, officeID uuid.UUID, newTier domain.SubscriptionTier) (*domain.Subscription, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	tierDef, err := s.GetTier(newTier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.subRepo.UpdateTier(ctx, sub.ID, newTier); err != nil {
+			return err
+		}
+		if err := s.subRepo.ClearTierChange(ctx, sub.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
 			return err
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if sub.Tier != newTier {
+		s.notifyTierChanged(ctx, officeID, sub.Tier, newTier, tierDef)
+	}
+	return sub, nil
+}
+
+// notifyTierChanged tells an office its subscription moved to a new tier
+func (s *SubscriptionService) notifyTierChanged(ctx context.Context, officeID uuid.UUID, oldTier, newTier domain.SubscriptionTier, tierDef *domain.TierDefinition) {
+	_, err := s.notifications.Notify(ctx, officeID, domain.NotificationTypeSubscriptionUpdated,
+		fmt.Sprintf("Your office is now on %s", tierDef.Name),
+		fmt.Sprintf("Your subscription changed to the %s tier, with %d credits a month.", tierDef.Name, tierDef.Features.MonthlyCredits),
+		map[string]any{
+			"old_tier": oldTier,
+			"new_tier": newTier,
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify office %s of its tier change: %v", officeID, err)
+	}
 }
 
 // AllocateMonthlyCredits allocates credits for a new billing period
@@ -232,18 +276,20 @@ func (s *SubscriptionService) AllocateMonthlyCredits(ctx context.Context, subscr
 		Source:           "subscription",
 	}
 
-	if err := s.subRepo.CreateAllocation(ctx, alloc); err != nil {
-		return err
-	}
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.subRepo.CreateAllocation(ctx, alloc); err != nil {
+			return err
+		}
 
-	// Add credits to wallet
-	_, err = s.creditRepo.AddCredits(
-		ctx, wallet.ID, tierDef.Features.MonthlyCredits,
-		domain.TransactionTypeSubscription,
-		"Monthly credit allocation",
-		"subscription", &sub.ID,
-	)
-	return err
+		// Add credits to wallet
+		_, err := s.creditRepo.AddCredits(
+			ctx, wallet.ID, tierDef.Features.MonthlyCredits,
+			domain.TransactionTypeSubscription,
+			"Monthly credit allocation",
+			"subscription", &sub.ID,
+		)
+		return err
+	})
 }
 
 // CheckModelAccess checks if a tier has access to a specific model provider
@@ -266,27 +312,124 @@ func (s *SubscriptionService) CheckModelAccess(ctx context.Context, officeID uui
 	return false, nil
 }
 
-// CheckAgentLimit checks if office can create more agents
-func (s *SubscriptionService) CheckAgentLimit(ctx context.Context, officeID uuid.UUID, currentCount int) (bool, int, error) {
+// CheckAPIAccess checks if an office's tier includes programmatic API access
+func (s *SubscriptionService) CheckAPIAccess(ctx context.Context, officeID uuid.UUID) (bool, error) {
 	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
 	if err != nil {
-		return false, 0, err
+		return false, err
 	}
 
 	tierDef, err := s.GetTier(sub.Tier)
+	if err != nil {
+		return false, err
+	}
+	return tierDef.Features.APIAccess, nil
+}
+
+// CheckCustomPrompts checks if an office's tier allows custom agent system
+// prompts. Offices without a subscription are treated as on the free tier,
+// which does not.
+func (s *SubscriptionService) CheckCustomPrompts(ctx context.Context, officeID uuid.UUID) (bool, error) {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	tierDef, err := s.GetTier(sub.Tier)
+	if err != nil {
+		return false, err
+	}
+	return tierDef.Features.CustomPrompts, nil
+}
+
+// GetOfficeFeatures returns the features of the office's tier. Offices
+// without a subscription get the free tier's features.
+func (s *SubscriptionService) GetOfficeFeatures(ctx context.Context, officeID uuid.UUID) (*domain.TierFeatures, error) {
+	tier := domain.TierSolo
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	switch {
+	case err == nil:
+		tier = sub.Tier
+	case !errors.Is(err, domain.ErrNotFound):
+		return nil, err
+	}
+
+	tierDef, err := s.GetTier(tier)
+	if err != nil {
+		return nil, err
+	}
+	return &tierDef.Features, nil
+}
+
+// CheckAgentLimit checks if office can create more agents. Offices without a
+// subscription get the free tier's limit.
+func (s *SubscriptionService) CheckAgentLimit(ctx context.Context, officeID uuid.UUID, currentCount int) (bool, int, error) {
+	tier := domain.TierSolo
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	switch {
+	case err == nil:
+		tier = sub.Tier
+	case !errors.Is(err, domain.ErrNotFound):
+		return false, 0, err
+	}
+
+	tierDef, err := s.GetTier(tier)
 	if err != nil {
 		return false, 0, err
 	}
 
 	limit := tierDef.Features.MaxAgents
-	if limit == -1 { // Unlimited
-		return true, -1, nil
+	if limit == unlimited {
+		return true, unlimited, nil
 	}
 
 	return currentCount < limit, limit, nil
 }
 
-// ProcessStripeWebhook handles Stripe webhook events
+// stripeWebhookTolerance is how old a signed Stripe webhook may be, against
+// replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+// VerifyStripeSignature checks a webhook payload against its
+// Stripe-Signature header, "t=<unix time>,v1=<hex HMAC-SHA256>", signed with
+// the endpoint's secret within the last few minutes
+func VerifyStripeSignature(payload []byte, header, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed Stripe signature", domain.ErrUnauthorized)
+	}
+	if age := now.Sub(time.Unix(signedAt, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return fmt.Errorf("%w: Stripe signature has expired", domain.ErrUnauthorized)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: invalid Stripe signature", domain.ErrUnauthorized)
+}
+
+// ProcessStripeWebhook handles Stripe webhook events, which the caller has
+// checked with VerifyStripeSignature
 func (s *SubscriptionService) ProcessStripeWebhook(ctx context.Context, eventType string, data map[string]any) error {
 	// Stub for Stripe webhook handling
 	// Will be implemented when Stripe integration is added
@@ -300,7 +443,48 @@ func (s *SubscriptionService) ProcessStripeWebhook(ctx context.Context, eventTyp
 	case "invoice.paid":
 		// Handle successful renewal - allocate monthly credits
 	case "invoice.payment_failed":
-		// Handle failed payment - update status
+		return s.handlePaymentFailed(ctx, data)
+	}
+	return nil
+}
+
+// handlePaymentFailed marks the subscription of a failed invoice past due
+// and tells its office
+func (s *SubscriptionService) handlePaymentFailed(ctx context.Context, data map[string]any) error {
+	invoice, _ := data["object"].(map[string]any)
+	stripeSubscriptionID, _ := invoice["subscription"].(string)
+	if stripeSubscriptionID == "" {
+		// One-off invoices have no subscription to update
+		return nil
+	}
+
+	sub, err := s.subRepo.GetByStripeID(ctx, stripeSubscriptionID)
+	if errors.Is(err, domain.ErrNotFound) {
+		log.Printf("Ignoring failed payment for unknown Stripe subscription %s", stripeSubscriptionID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.subRepo.UpdateStatus(ctx, sub.ID, domain.SubscriptionStatusPastDue); err != nil {
+		return err
+	}
+
+	tierName := string(sub.Tier)
+	if tierDef, err := s.GetTier(sub.Tier); err == nil {
+		tierName = tierDef.Name
+	}
+	_, err = s.notifications.Notify(ctx, sub.OfficeID, domain.NotificationTypePaymentFailed,
+		"Subscription payment failed",
+		fmt.Sprintf("We could not charge the payment for your %s subscription. Please update your payment method to keep your plan.", tierName),
+		map[string]any{
+			"subscription_id": sub.ID.String(),
+			"tier":            tierName,
+			"invoice_id":      invoice["id"],
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to notify office %s of its failed payment: %v", sub.OfficeID, err)
 	}
 	return nil
 }
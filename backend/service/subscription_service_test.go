@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// subscriptionMocks are the repositories behind a SubscriptionService
+// under test
+type subscriptionMocks struct {
+	subs    *mocks.MockSubscriptionRepository
+	credits *mocks.MockCreditRepository
+	offices *mocks.MockOfficeRepository
+	audit   *mocks.MockAuditRepository
+}
+
+// testTiersPath is the tier configuration the server ships with
+const testTiersPath = "../config/subscription_tiers.yaml"
+
+// newTestSubscriptionService creates a SubscriptionService on the shipped
+// tiers whose transactions run fn directly
+func newTestSubscriptionService(t *testing.T) (*SubscriptionService, subscriptionMocks) {
+	ctrl := gomock.NewController(t)
+	m := subscriptionMocks{
+		subs:    mocks.NewMockSubscriptionRepository(ctrl),
+		credits: mocks.NewMockCreditRepository(ctrl),
+		offices: mocks.NewMockOfficeRepository(ctrl),
+		audit:   mocks.NewMockAuditRepository(ctrl),
+	}
+
+	txManager := mocks.NewMockTxManager(ctrl)
+	txManager.EXPECT().WithinTx(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) })
+
+	users := mocks.NewMockUserRepository(ctrl)
+	notifications := NewNotificationService(
+		mocks.NewMockNotificationRepository(ctrl), mocks.NewMockNotificationPreferenceRepository(ctrl),
+		m.offices, users, mocks.NewMockMailer(ctrl), mocks.NewMockEventPublisher(ctrl), newTestWebhookDispatcher(ctrl), "")
+	audit := NewAuditService(m.audit, m.offices, users)
+
+	svc := NewSubscriptionService(m.subs, m.credits, mocks.NewMockAgentRepository(ctrl), txManager,
+		nil, notifications, audit, testTiersPath)
+	return svc, m
+}
+
+// expectTierChangeSideEffects accepts the notification, which fails for the
+// unknown office and is only logged, and the audit entry of a tier change
+func (m subscriptionMocks) expectTierChangeSideEffects() {
+	m.offices.EXPECT().GetByID(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound).AnyTimes()
+	m.audit.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+}
+
+func TestUpgradeTierAddsCreditDifference(t *testing.T) {
+	tests := []struct {
+		from, to domain.SubscriptionTier
+	}{
+		{domain.TierSolo, domain.TierProfessional},
+		{domain.TierSolo, domain.TierBusiness},
+		{domain.TierProfessional, domain.TierBusiness},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			ctx := context.Background()
+			svc, m := newTestSubscriptionService(t)
+			officeID := uuid.New()
+			sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: tt.from}
+			wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+
+			from, _ := svc.GetTier(tt.from)
+			to, _ := svc.GetTier(tt.to)
+			want := to.Features.MonthlyCredits - from.Features.MonthlyCredits
+
+			m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+			m.subs.EXPECT().UpdateTier(gomock.Any(), sub.ID, tt.to).Return(nil)
+			m.subs.EXPECT().ClearTierChange(gomock.Any(), sub.ID).Return(domain.ErrNotFound)
+			m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+			m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, want, domain.TransactionTypeSubscription,
+				gomock.Any(), "subscription", &sub.ID).Return(&domain.CreditTransaction{}, nil)
+			m.expectTierChangeSideEffects()
+
+			if err := svc.UpgradeTier(ctx, officeID, tt.to); err != nil {
+				t.Fatalf("UpgradeTier: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpgradeTierToUnlimitedTierAddsNoCredits(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestSubscriptionService(t)
+	officeID := uuid.New()
+	sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: domain.TierBusiness}
+
+	// Unlimited is configured as -1 credits, which must not debit the wallet
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+	m.subs.EXPECT().UpdateTier(gomock.Any(), sub.ID, domain.TierEnterprise).Return(nil)
+	m.subs.EXPECT().ClearTierChange(gomock.Any(), sub.ID).Return(domain.ErrNotFound)
+	m.expectTierChangeSideEffects()
+
+	if err := svc.UpgradeTier(ctx, officeID, domain.TierEnterprise); err != nil {
+		t.Fatalf("UpgradeTier: %v", err)
+	}
+}
+
+func TestUpgradeTierToCurrentTierAddsNoCredits(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestSubscriptionService(t)
+	officeID := uuid.New()
+	sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: domain.TierProfessional}
+
+	// No wallet calls are expected
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+	m.subs.EXPECT().UpdateTier(gomock.Any(), sub.ID, domain.TierProfessional).Return(nil)
+	m.subs.EXPECT().ClearTierChange(gomock.Any(), sub.ID).Return(nil)
+	m.expectTierChangeSideEffects()
+
+	if err := svc.UpgradeTier(ctx, officeID, domain.TierProfessional); err != nil {
+		t.Fatalf("UpgradeTier: %v", err)
+	}
+}
+
+func TestUpgradeTierRejectsLowerTier(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestSubscriptionService(t)
+	officeID := uuid.New()
+	sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: domain.TierBusiness}
+
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+
+	err := svc.UpgradeTier(ctx, officeID, domain.TierSolo)
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("UpgradeTier error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestUpgradeTierUnknownTier(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestSubscriptionService(t)
+	officeID := uuid.New()
+	sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: domain.TierSolo}
+
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+
+	err := svc.UpgradeTier(ctx, officeID, "platinum")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("UpgradeTier error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestUpgradeTierFailsWithItsCredits(t *testing.T) {
+	ctx := context.Background()
+	svc, m := newTestSubscriptionService(t)
+	officeID := uuid.New()
+	sub := &domain.Subscription{ID: uuid.New(), OfficeID: officeID, Tier: domain.TierSolo}
+	wallet := &domain.CreditWallet{ID: uuid.New(), OfficeID: officeID}
+	errWallet := errors.New("wallet unavailable")
+
+	// The failed allocation fails the upgrade, so nothing is notified or
+	// audited
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), officeID).Return(sub, nil)
+	m.subs.EXPECT().UpdateTier(gomock.Any(), sub.ID, domain.TierProfessional).Return(nil)
+	m.subs.EXPECT().ClearTierChange(gomock.Any(), sub.ID).Return(domain.ErrNotFound)
+	m.credits.EXPECT().GetWalletByOfficeID(gomock.Any(), officeID).Return(wallet, nil)
+	m.credits.EXPECT().AddCredits(gomock.Any(), wallet.ID, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, errWallet)
+
+	if err := svc.UpgradeTier(ctx, officeID, domain.TierProfessional); !errors.Is(err, errWallet) {
+		t.Errorf("UpgradeTier error = %v, want %v", err, errWallet)
+	}
+}
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"type":"invoice.payment_failed"}`)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		wantErr bool
+	}{
+		{"valid", signWebhook("whsec_test", now, payload), payload, false},
+		{"valid among rotated secrets", signWebhook("whsec_old", now, payload) + ",v1=" + strings.SplitN(signWebhook("whsec_test", now, payload), "v1=", 2)[1], payload, false},
+		{"other secret", signWebhook("whsec_other", now, payload), payload, true},
+		{"tampered payload", signWebhook("whsec_test", now, payload), []byte(`{"type":"invoice.paid"}`), true},
+		{"too old", signWebhook("whsec_test", now.Add(-10*time.Minute), payload), payload, true},
+		{"missing", "", payload, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyStripeSignature(tt.payload, tt.header, "whsec_test", now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyStripeSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrUnauthorized) {
+				t.Errorf("VerifyStripeSignature() error = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
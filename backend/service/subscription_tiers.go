@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// tierWatchInterval is how often WatchTiers checks the tiers file for changes
+const tierWatchInterval = 10 * time.Second
+
+// unlimited is the value of a tier limit, such as monthly credits, that has
+// no limit
+const unlimited = -1
+
+// modelProviders are the providers tiers may grant model access to
+var modelProviders = []string{"ollama", "groq", "openai", "anthropic"}
+
+// TierConfig represents the YAML structure
+type TierConfig struct {
+	Tiers          map[string]domain.TierDefinition `yaml:"tiers"`
+	Trial          *domain.TrialDefinition          `yaml:"trial"`
+	CreditPackages map[string]domain.CreditPackage  `yaml:"credit_packages"`
+}
+
+// tierConfig is the live tier configuration. A loaded configuration is
+// never changed; reloading swaps in a new one.
+type tierConfig struct {
+	tiers map[domain.SubscriptionTier]*domain.TierDefinition
+	// trial is the trial new offices start on, nil without trials
+	trial *domain.TrialDefinition
+	// creditPackages are the add-on credit packs, by name
+	creditPackages map[string]domain.CreditPackage
+	// modTime is when the file it was loaded from last changed
+	modTime time.Time
+}
+
+// ListedTier is a tier with its definition
+type ListedTier struct {
+	ID domain.SubscriptionTier
+	*domain.TierDefinition
+}
+
+// config returns the live tier configuration
+func (s *SubscriptionService) config() *tierConfig {
+	return s.tiers.Load()
+}
+
+// loadTiers loads the tiers file at startup. Without the file the built-in
+// defaults are used; an invalid file is logged and the defaults are used
+// too, so a bad deploy does not keep the server from starting.
+func (s *SubscriptionService) loadTiers() {
+	config, err := readTierConfig(s.tiersPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log.Printf("Tiers file %s not found, using the built-in tiers", s.tiersPath)
+		config = defaultTierConfig()
+	case err != nil:
+		log.Printf("Invalid tiers file %s, using the built-in tiers: %v", s.tiersPath, err)
+		config = defaultTierConfig()
+	}
+	s.tiers.Store(config)
+}
+
+// ReloadTiers loads the tiers file again and makes it the live
+// configuration. An invalid file fails with ErrInvalidInput and the current
+// tiers stay live.
+func (s *SubscriptionService) ReloadTiers() error {
+	config, err := readTierConfig(s.tiersPath)
+	if err != nil {
+		return err
+	}
+	s.tiers.Store(config)
+	log.Printf("Reloaded %d tiers from %s", len(config.tiers), s.tiersPath)
+	return nil
+}
+
+// WatchTiers reloads the tiers file whenever it changes, until ctx is
+// cancelled. Changes that fail validation are logged and ignored.
+func (s *SubscriptionService) WatchTiers(ctx context.Context) {
+	ticker := time.NewTicker(tierWatchInterval)
+	defer ticker.Stop()
+
+	// rejected is when the file last changed to an invalid configuration
+	var rejected time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.tiersPath)
+			if err != nil || info.ModTime().Equal(s.config().modTime) || info.ModTime().Equal(rejected) {
+				continue
+			}
+			if err := s.ReloadTiers(); err != nil {
+				log.Printf("Ignoring change to tiers file %s: %v", s.tiersPath, err)
+				rejected = info.ModTime()
+			}
+		}
+	}
+}
+
+// readTierConfig reads and validates a tiers file
+func readTierConfig(path string) (*tierConfig, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := parseTierConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	config.modTime = info.ModTime()
+	return config, nil
+}
+
+// parseTierConfig parses and validates the YAML of a tiers file
+func parseTierConfig(data []byte) (*tierConfig, error) {
+	var file TierConfig
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	config := &tierConfig{
+		tiers:          make(map[domain.SubscriptionTier]*domain.TierDefinition, len(file.Tiers)),
+		trial:          file.Trial,
+		creditPackages: file.CreditPackages,
+	}
+	for key, def := range file.Tiers {
+		if err := validateTier(key, &def.Features); err != nil {
+			return nil, err
+		}
+		def := def // Copy to avoid pointer issues
+		config.tiers[domain.SubscriptionTier(key)] = &def
+	}
+
+	// Offices without a subscription are treated as solo
+	if _, ok := config.tiers[domain.TierSolo]; !ok {
+		return nil, fmt.Errorf("%w: tier %s is required", domain.ErrInvalidInput, domain.TierSolo)
+	}
+	if trial := config.trial; trial != nil {
+		if trial.Days < 0 || trial.Credits < 0 {
+			return nil, fmt.Errorf("%w: trial days and credits may not be negative", domain.ErrInvalidInput)
+		}
+		if _, ok := config.tiers[trial.Tier]; trial.Days > 0 && !ok {
+			return nil, fmt.Errorf("%w: trial tier %q is not defined", domain.ErrInvalidInput, trial.Tier)
+		}
+	}
+	for name, pkg := range config.creditPackages {
+		if pkg.Credits <= 0 || pkg.PriceUSD < 0 {
+			return nil, fmt.Errorf("%w: credit package %s needs positive credits and a price of at least 0", domain.ErrInvalidInput, name)
+		}
+	}
+	return config, nil
+}
+
+// validateTier rejects features a tier cannot have
+func validateTier(key string, features *domain.TierFeatures) error {
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"monthly credits", features.MonthlyCredits},
+		{"max agents", int64(features.MaxAgents)},
+		{"max seats", int64(features.MaxSeats)},
+		{"retention days", int64(features.RetentionDays)},
+		{"max export days", int64(features.MaxExportDays)},
+	}
+	for _, limit := range limits {
+		if limit.value < unlimited {
+			return fmt.Errorf("%w: tier %s has negative %s; use %d for unlimited",
+				domain.ErrInvalidInput, key, limit.name, unlimited)
+		}
+	}
+	for _, provider := range features.ModelAccess {
+		if !slices.Contains(modelProviders, provider) {
+			return fmt.Errorf("%w: tier %s grants access to unknown provider %q", domain.ErrInvalidInput, key, provider)
+		}
+	}
+	if features.RolloverPercent < 0 || features.RolloverPercent > 100 {
+		return fmt.Errorf("%w: tier %s has a rollover percent outside 0 to 100", domain.ErrInvalidInput, key)
+	}
+	for _, skill := range features.Skills {
+		if _, ok := catalogSkill(skill); !ok {
+			return fmt.Errorf("%w: tier %s includes unknown skill %q", domain.ErrInvalidInput, key, skill)
+		}
+	}
+	return nil
+}
+
+// ListTiers returns the live tiers, lowest first
+func (s *SubscriptionService) ListTiers() []ListedTier {
+	tiers := s.config().tiers
+	ids := make([]domain.SubscriptionTier, 0, len(tiers))
+	for id := range tiers {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, s.compareTiers)
+
+	listed := make([]ListedTier, len(ids))
+	for i, id := range ids {
+		listed[i] = ListedTier{ID: id, TierDefinition: tiers[id]}
+	}
+	return listed
+}
+
+// defaultTierConfig is the configuration used when the tiers file is
+// missing
+func defaultTierConfig() *tierConfig {
+	return &tierConfig{
+		trial: &domain.TrialDefinition{Tier: domain.TierProfessional, Days: 14, Credits: 2000},
+		tiers: map[domain.SubscriptionTier]*domain.TierDefinition{
+			domain.TierSolo: {
+				Name:        "Solo Founder",
+				Description: "Perfect for individual developers",
+				Features: domain.TierFeatures{
+					MaxAgents:      3,
+					MonthlyCredits: 1000,
+					MaxSeats:       1,
+					ModelAccess:    []string{"ollama", "groq"},
+					Priority:       "low",
+					RetentionDays:  30,
+					MaxExportDays:  30,
+					// Attachments
+					MaxAttachmentMB: 10,
+					AttachmentTypes: []string{"image/*", "text/plain", "text/markdown", "text/csv", "application/pdf"},
+					// Skills
+					Skills: []string{domain.SkillEmailDraft},
+				},
+			},
+			domain.TierProfessional: {
+				Name:        "Professional",
+				Description: "For power users and small teams",
+				Features: domain.TierFeatures{
+					MaxAgents:      10,
+					MonthlyCredits: 10000,
+					MaxSeats:       5,
+					ModelAccess:    []string{"ollama", "groq", "openai"},
+					Priority:       "normal",
+					RetentionDays:  90,
+					MaxExportDays:  90,
+					WebResearch:    true,
+					APIAccess:      true,
+					CustomPrompts:  true,
+					// Attachments
+					MaxAttachmentMB: 25,
+					AttachmentTypes: []string{
+						"image/*", "text/*", "application/pdf", "application/json",
+						"application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint",
+						"application/vnd.openxmlformats-officedocument.*",
+					},
+					RolloverPercent: 25,
+					// Skills
+					Skills: []string{domain.SkillWebSearch, domain.SkillEmailDraft, domain.SkillCalendar},
+				},
+			},
+			domain.TierBusiness: {
+				Name:        "Business",
+				Description: "For growing teams",
+				Features: domain.TierFeatures{
+					MaxAgents:             50,
+					MonthlyCredits:        50000,
+					MaxSeats:              20,
+					ModelAccess:           []string{"ollama", "groq", "openai", "anthropic"},
+					Priority:              "high",
+					RetentionDays:         365,
+					MaxExportDays:         365,
+					WebResearch:           true,
+					AdvancedOrchestration: true,
+					Analytics:             true,
+					APIAccess:             true,
+					CustomPrompts:         true,
+					MaxAttachmentMB:       50,
+					AttachmentTypes:       []string{"*/*"},
+
+					RolloverPercent: 50,
+					// Skills
+					Skills: []string{domain.SkillWebSearch, domain.SkillCodeExec, domain.SkillEmailDraft, domain.SkillCalendar},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// testTierYAML is a valid tiers file with a trial
+const testTierYAML = `
+tiers:
+  solo:
+    name: Solo
+    features:
+      monthly_credits: 1000
+      model_access: [ollama]
+  enterprise:
+    name: Enterprise
+    features:
+      monthly_credits: -1
+      max_agents: -1
+      model_access: [ollama, anthropic]
+trial:
+  tier: solo
+  days: 7
+  credits: 100
+`
+
+func TestParseTierConfigShippedFile(t *testing.T) {
+	data, err := os.ReadFile(testTiersPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseTierConfig(data)
+	if err != nil {
+		t.Fatalf("parseTierConfig: %v", err)
+	}
+	for _, tier := range []domain.SubscriptionTier{domain.TierSolo, domain.TierProfessional, domain.TierBusiness, domain.TierEnterprise} {
+		if config.tiers[tier] == nil {
+			t.Errorf("tier %s is missing", tier)
+		}
+	}
+}
+
+func TestParseTierConfigRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantMsg string
+	}{
+		{"unknown provider", "[ollama]", "[ollama, skynet]", `unknown provider "skynet"`},
+		{"negative credits", "monthly_credits: 1000", "monthly_credits: -5", "negative monthly credits"},
+		{"negative limit", "max_agents: -1", "max_agents: -2", "negative max agents"},
+		{"missing solo tier", "  solo:", "  starter:", "tier solo is required"},
+		{"undefined trial tier", "tier: solo", "tier: gold", `trial tier "gold"`},
+		{"negative trial credits", "days: 7\n  credits: 100", "days: 7\n  credits: -100", "trial days and credits"},
+		{"unknown skill", "model_access: [ollama]", "model_access: [ollama]\n      skills: [teleport]", `unknown skill "teleport"`},
+		{"malformed YAML", "tiers:", "tiers: [", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Replace(testTierYAML, tt.old, tt.new, 1)
+			_, err := parseTierConfig([]byte(data))
+			if !errors.Is(err, domain.ErrInvalidInput) {
+				t.Fatalf("parseTierConfig error = %v, want ErrInvalidInput", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("parseTierConfig error = %q, want it to mention %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestReloadTiersKeepsLiveTiersOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiers.yaml")
+	if err := os.WriteFile(path, []byte(testTierYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc := &SubscriptionService{tiersPath: path}
+	svc.loadTiers()
+	if _, err := svc.GetTier(domain.TierEnterprise); err != nil {
+		t.Fatalf("GetTier(enterprise) after load: %v", err)
+	}
+
+	invalid := strings.Replace(testTierYAML, "monthly_credits: 1000", "monthly_credits: -5", 1)
+	if err := os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ReloadTiers(); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Fatalf("ReloadTiers error = %v, want ErrInvalidInput", err)
+	}
+	if solo, _ := svc.GetTier(domain.TierSolo); solo.Features.MonthlyCredits != 1000 {
+		t.Errorf("solo has %d monthly credits after a rejected reload, want the live 1000", solo.Features.MonthlyCredits)
+	}
+
+	// Removing a tier takes effect on the next valid reload
+	valid := strings.Replace(testTierYAML, "  enterprise:", "  business:", 1)
+	if err := os.WriteFile(path, []byte(valid), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ReloadTiers(); err != nil {
+		t.Fatalf("ReloadTiers: %v", err)
+	}
+	if _, err := svc.GetTier(domain.TierEnterprise); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("GetTier(enterprise) after reload error = %v, want the tier gone", err)
+	}
+	if tiers := svc.ListTiers(); len(tiers) != 2 {
+		t.Errorf("ListTiers returned %d tiers, want 2", len(tiers))
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// trialAllocationSource marks the credit allocation a trial comes with
+const trialAllocationSource = "trial"
+
+// StartTrial puts a new office's subscription on the configured trial and
+// gives it the trial's credits. It does nothing if trials are turned off.
+func (s *SubscriptionService) StartTrial(ctx context.Context, officeID uuid.UUID) error {
+	trial := s.config().trial
+	if trial == nil || trial.Days <= 0 {
+		return nil
+	}
+	if _, err := s.GetTier(trial.Tier); err != nil {
+		return fmt.Errorf("trial tier: %w", err)
+	}
+
+	// The trial only starts together with its credits
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		return s.startTrial(ctx, officeID, trial)
+	})
+}
+
+// startTrial stores the trial of StartTrial and allocates its credits
+func (s *SubscriptionService) startTrial(ctx context.Context, officeID uuid.UUID, trial *domain.TrialDefinition) error {
+	sub, err := s.subRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	end := start.AddDate(0, 0, trial.Days)
+	if err := s.subRepo.StartTrial(ctx, sub.ID, trial.Tier, start, end); err != nil {
+		return err
+	}
+	if trial.Credits <= 0 {
+		return nil
+	}
+
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	alloc := &domain.CreditAllocation{
+		SubscriptionID:   sub.ID,
+		WalletID:         wallet.ID,
+		PeriodStart:      start,
+		PeriodEnd:        end,
+		CreditsAllocated: trial.Credits,
+		Source:           trialAllocationSource,
+	}
+	if err := s.subRepo.CreateAllocation(ctx, alloc); err != nil {
+		return err
+	}
+	_, err = s.creditRepo.AddCredits(
+		ctx, wallet.ID, trial.Credits,
+		domain.TransactionTypeBonus,
+		"Trial credit allocation",
+		"subscription", &sub.ID,
+	)
+	return err
+}
+
+// setTrialDaysLeft fills in the days left in a trialing subscription's trial
+func setTrialDaysLeft(sub *domain.Subscription, now time.Time) {
+	if days, ok := sub.DaysLeftInTrial(now); ok {
+		sub.TrialDaysLeft = &days
+	}
+}
+
+// endDueTrials ends one batch of trials that have run out
+func (s *SubscriptionService) endDueTrials(ctx context.Context) {
+	subs, err := s.subRepo.GetDueTrials(ctx, time.Now(), tierChangeBatchSize)
+	if err != nil {
+		log.Printf("Failed to load due trials: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.endTrial(ctx, sub); err != nil {
+			log.Printf("Failed to end trial of subscription %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// endTrial converts a trial billed through Stripe into an active
+// subscription of its tier. Other trials expire: the office moves to the
+// free tier and the trial credits it has not used are removed. Trial credits
+// count as spent before any others.
+func (s *SubscriptionService) endTrial(ctx context.Context, sub *domain.Subscription) error {
+	if sub.StripeSubscriptionID != "" {
+		err := s.subRepo.EndTrial(ctx, sub.ID, sub.Tier, domain.SubscriptionStatusActive)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tierDef, err := s.GetTier(sub.Tier); err == nil {
+			s.notifyStatusChanged(ctx, sub, "Your trial has ended",
+				fmt.Sprintf("Your trial is over and your %s subscription is now active.", tierDef.Name))
+		}
+		return nil
+	}
+
+	err := s.subRepo.EndTrial(ctx, sub.ID, domain.TierSolo, domain.SubscriptionStatusActive)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.expireTrialCredits(ctx, sub); err != nil {
+		log.Printf("Failed to remove unused trial credits of subscription %s: %v", sub.ID, err)
+	}
+
+	tierName := string(domain.TierSolo)
+	if tierDef, err := s.GetTier(domain.TierSolo); err == nil {
+		tierName = tierDef.Name
+	}
+	s.notifyStatusChanged(ctx, sub, "Your trial has ended",
+		fmt.Sprintf("Your trial is over and your office is now on the %s tier. Upgrade to keep the features you tried.", tierName))
+	return nil
+}
+
+// expireTrialCredits removes the credits of a trial's allocation that were
+// not used during the trial, never more than the wallet holds
+func (s *SubscriptionService) expireTrialCredits(ctx context.Context, sub *domain.Subscription) error {
+	allocs, err := s.subRepo.GetAllocationsBySubscription(ctx, sub.ID, 10)
+	if err != nil {
+		return err
+	}
+	var trialAlloc *domain.CreditAllocation
+	for _, alloc := range allocs {
+		if alloc.Source == trialAllocationSource {
+			trialAlloc = alloc
+			break
+		}
+	}
+	if trialAlloc == nil {
+		return nil
+	}
+
+	wallet, err := s.creditRepo.GetWalletByOfficeID(ctx, sub.OfficeID)
+	if err != nil {
+		return err
+	}
+	consumed, err := s.creditRepo.GetConsumedSince(ctx, wallet.ID, trialAlloc.PeriodStart)
+	if err != nil {
+		return err
+	}
+	amount := min(trialAlloc.CreditsAllocated-consumed, wallet.Balance)
+	if amount <= 0 {
+		return nil
+	}
+
+	_, err = s.creditRepo.AddCredits(
+		ctx, wallet.ID, -amount,
+		domain.TransactionTypeAdjustment,
+		"Unused trial credits expired",
+		"subscription", &sub.ID,
+	)
+	return err
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// summaryMaxMessages and summaryMaxChars bound the new messages one run
+	// summarizes; longer stretches are summarized over several runs
+	summaryMaxMessages = 200
+	summaryMaxChars    = 48000
+
+	// summaryPollInterval is how often the worker looks for long
+	// conversations, summarizing up to summaryBatchSize of them at a time
+	summaryPollInterval = time.Minute
+	summaryBatchSize    = 10
+)
+
+// summarySenderName is the name summaries are shown to agents with
+const summarySenderName = "Conversation summary"
+
+// summaryPrompt asks the agent for a summary. The previous summary, if any,
+// and the new messages follow it.
+const summaryPrompt = `Summarize the conversation below for the people and agents taking part in it. ` +
+	`Keep the decisions made, open questions, action items with their owners and the facts later messages ` +
+	`depend on; leave out greetings and small talk. Write at most 300 words and reply with the summary only.`
+
+// SummaryConfig sets when conversations are summarized
+type SummaryConfig struct {
+	// CacheWindow is how long a completed summary is returned instead of
+	// summarizing the conversation again
+	CacheWindow time.Duration
+	// AutoMessages is how many messages since its latest summary get a
+	// conversation summarized in the background; zero turns that off
+	AutoMessages int
+}
+
+// SummaryService summarizes conversations with one of their agents. Each
+// summary covers the messages since the previous one, together with it, so
+// agents can be given a long conversation's summary instead of all of it.
+type SummaryService struct {
+	summaryRepo      domain.ConversationSummaryRepository
+	conversationRepo domain.ConversationRepository
+	messageRepo      domain.MessageRepository
+	agentRepo        domain.AgentRepository
+	userRepo         domain.UserRepository
+	taskService      *TaskService
+	creditService    *CreditService
+	events           domain.EventPublisher
+	config           SummaryConfig
+}
+
+// NewSummaryService creates a new SummaryService instance
+func NewSummaryService(
+	summaryRepo domain.ConversationSummaryRepository,
+	conversationRepo domain.ConversationRepository,
+	messageRepo domain.MessageRepository,
+	agentRepo domain.AgentRepository,
+	userRepo domain.UserRepository,
+	taskService *TaskService,
+	creditService *CreditService,
+	events domain.EventPublisher,
+	config SummaryConfig,
+) *SummaryService {
+	return &SummaryService{
+		summaryRepo:      summaryRepo,
+		conversationRepo: conversationRepo,
+		messageRepo:      messageRepo,
+		agentRepo:        agentRepo,
+		userRepo:         userRepo,
+		taskService:      taskService,
+		creditService:    creditService,
+		events:           events,
+		config:           config,
+	}
+}
+
+// SummarizeInput selects the conversation to summarize and, optionally, the
+// participating agent to summarize it; by default the first participant does
+type SummarizeInput struct {
+	OfficeID       uuid.UUID
+	ConversationID uuid.UUID
+	AgentID        uuid.UUID
+}
+
+// Summarize starts summarizing a conversation of the office and returns
+// the pending run, reporting true. A run still pending, a summary completed
+// within the cache window or one no messages were sent since is returned
+// instead, reporting false.
+func (s *SummaryService) Summarize(ctx context.Context, input SummarizeInput) (*domain.ConversationSummary, bool, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, input.OfficeID, input.ConversationID)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.summarize(ctx, conversation, input.AgentID, false)
+}
+
+// GetSummary returns the latest summarization run of a conversation of the
+// office
+func (s *SummaryService) GetSummary(ctx context.Context, officeID, conversationID uuid.UUID) (*domain.ConversationSummary, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.summaryRepo.GetLatest(ctx, conversation.ID)
+}
+
+func (s *SummaryService) summarize(ctx context.Context, conversation *domain.Conversation, agentID uuid.UUID, auto bool) (*domain.ConversationSummary, bool, error) {
+	latest, err := s.summaryRepo.GetLatest(ctx, conversation.ID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, false, err
+	}
+	if latest != nil {
+		switch {
+		case latest.Status == domain.SummaryStatusPending:
+			return latest, false, nil
+		case latest.Status == domain.SummaryStatusDone && time.Since(latest.CreatedAt) < s.config.CacheWindow:
+			return latest, false, nil
+		}
+	}
+
+	previous, err := s.summaryRepo.GetLatestDone(ctx, conversation.ID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, false, err
+	}
+	var after *domain.PageCursor
+	if previous != nil {
+		after = &domain.PageCursor{CreatedAt: previous.ThroughAt, ID: previous.ThroughMessageID}
+	}
+	messages, err := s.messageRepo.GetNextByConversationID(ctx, conversation.ID, after, summaryMaxMessages)
+	if err != nil {
+		return nil, false, err
+	}
+	messages, through := summarizable(messages)
+	if len(messages) == 0 {
+		if previous != nil {
+			return previous, false, nil
+		}
+		return nil, false, fmt.Errorf("%w: the conversation has no messages to summarize", domain.ErrInvalidInput)
+	}
+
+	participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	agent, err := summaryAgent(participants, agentID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sufficient, _, err := s.creditService.CheckSufficientCredits(ctx, conversation.OfficeID, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	if !sufficient {
+		return nil, false, fmt.Errorf("%w: no credits left to summarize the conversation", domain.ErrInsufficientCredits)
+	}
+
+	input, err := s.summaryInput(ctx, previous, messages, participants)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The pending run claims the conversation before its task is created,
+	// so concurrent requests start and charge a single run
+	summary := &domain.ConversationSummary{
+		ID:               uuid.New(),
+		OfficeID:         conversation.OfficeID,
+		ConversationID:   conversation.ID,
+		AgentID:          agent.ID,
+		Status:           domain.SummaryStatusPending,
+		ThroughMessageID: through.ID,
+		ThroughAt:        through.CreatedAt,
+		MessageCount:     len(messages),
+		Auto:             auto,
+		CreatedAt:        time.Now(),
+	}
+	err = s.summaryRepo.Create(ctx, summary)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		pending, err := s.summaryRepo.GetLatest(ctx, conversation.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		return pending, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	task, err := s.taskService.CreateTask(ctx, CreateTaskInput{
+		OfficeID:       conversation.OfficeID,
+		ConversationID: conversation.ID,
+		AgentID:        agent.ID,
+		Input:          input,
+		Kind:           domain.TaskKindSummary,
+	})
+	if err != nil {
+		if failErr := s.summaryRepo.Fail(ctx, summary.ID); failErr != nil {
+			log.Printf("Failed to release the summary claim on conversation %s: %v", conversation.ID, failErr)
+		}
+		return nil, false, err
+	}
+	summary.TaskID = &task.ID
+	if err := s.summaryRepo.SetTask(ctx, summary.ID, task.ID); err != nil {
+		return nil, false, err
+	}
+	return summary, true, nil
+}
+
+// summarizable returns the messages, oldest first, that fit in one run with
+// the last of them it covers. Earlier summaries and empty messages are
+// skipped, but still covered.
+func summarizable(messages []*domain.Message) ([]*domain.Message, *domain.Message) {
+	var kept []*domain.Message
+	var through *domain.Message
+	chars := 0
+	for _, message := range messages {
+		chars += utf8.RuneCountInString(message.Content)
+		if chars > summaryMaxChars && len(kept) > 0 {
+			break
+		}
+		through = message
+		if message.SenderType != domain.SenderTypeSystem && strings.TrimSpace(message.Content) != "" {
+			kept = append(kept, message)
+		}
+	}
+	return kept, through
+}
+
+// summaryAgent returns the participant to summarize the conversation: the
+// requested agent, or the first participant when agentID is zero
+func summaryAgent(participants []*domain.Agent, agentID uuid.UUID) (*domain.Agent, error) {
+	for _, agent := range participants {
+		if agentID == uuid.Nil || agent.ID == agentID {
+			return agent, nil
+		}
+	}
+	if agentID != uuid.Nil {
+		return nil, fmt.Errorf("%w: agent_id must be an agent taking part in the conversation", domain.ErrInvalidInput)
+	}
+	return nil, fmt.Errorf("%w: the conversation has no agents to summarize it", domain.ErrInvalidInput)
+}
+
+// summaryInput builds a summary task's input: the prompt, the previous
+// summary and a transcript of the new messages
+func (s *SummaryService) summaryInput(ctx context.Context, previous *domain.ConversationSummary, messages []*domain.Message, participants []*domain.Agent) (string, error) {
+	var b strings.Builder
+	b.WriteString(summaryPrompt)
+	if previous != nil {
+		b.WriteString("\n\nSummary so far:\n")
+		b.WriteString(previous.Content)
+		b.WriteString("\n\nMessages since:")
+	} else {
+		b.WriteString("\n\nMessages:")
+	}
+
+	names := newSenderNames(s.agentRepo, s.userRepo, participants)
+	for _, message := range messages {
+		name, err := names.get(ctx, message.SenderType, message.SenderID)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n\n[%s] %s: %s", message.CreatedAt.UTC().Format(time.RFC3339), name, message.Content)
+	}
+	return b.String(), nil
+}
+
+// CompleteSummary stores the output of a summary task as its run's summary,
+// posting it as the conversation's pinned summary message. It reports
+// false for tasks that are not summary tasks, whose output is a reply.
+func (s *SummaryService) CompleteSummary(ctx context.Context, taskID uuid.UUID, output string) (bool, error) {
+	summary, err := s.summaryRepo.GetByTaskID(ctx, taskID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	content := strings.TrimSpace(output)
+	if content == "" {
+		return true, fmt.Errorf("summary task %s returned no summary", taskID)
+	}
+
+	now := time.Now()
+	message := &domain.Message{
+		ID:             uuid.New(),
+		OfficeID:       summary.OfficeID,
+		ConversationID: summary.ConversationID,
+		SenderType:     domain.SenderTypeSystem,
+		SenderID:       summary.ID,
+		Content:        content,
+		Metadata: map[string]any{
+			"summary_id":    summary.ID.String(),
+			"agent_id":      summary.AgentID.String(),
+			"message_count": summary.MessageCount,
+		},
+		CreatedAt: now,
+		PinnedAt:  &now,
+	}
+	summary.Content = content
+	summary.MessageID = &message.ID
+	summary.CompletedAt = &now
+	summary.Status = domain.SummaryStatusDone
+
+	err = s.summaryRepo.Complete(ctx, summary, message)
+	if errors.Is(err, domain.ErrNotFound) {
+		// A repeated callback; the summary is already posted
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	payload := map[string]any{
+		"message_id":      message.ID.String(),
+		"conversation_id": message.ConversationID.String(),
+		"sender_type":     string(message.SenderType),
+		"sender_id":       message.SenderID.String(),
+		"content":         message.Content,
+		"pinned_at":       now,
+	}
+	if err := s.events.Publish(ctx, domain.NewEvent(summary.OfficeID, domain.EventNewMessage, payload)); err != nil {
+		log.Printf("Failed to publish summary message %s: %v", message.ID, err)
+	}
+	err = s.events.Publish(ctx, domain.NewEvent(summary.OfficeID, domain.EventConversationSummarized, map[string]any{
+		"conversation_id": summary.ConversationID.String(),
+		"summary_id":      summary.ID.String(),
+		"message_id":      message.ID.String(),
+		"agent_id":        summary.AgentID.String(),
+		"auto":            summary.Auto,
+	}))
+	if err != nil {
+		log.Printf("Failed to publish summary of conversation %s: %v", summary.ConversationID, err)
+	}
+	return true, nil
+}
+
+// Run summarizes conversations that grew long since their latest summary
+// until ctx is cancelled. It does nothing unless AutoMessages is set.
+func (s *SummaryService) Run(ctx context.Context) {
+	if s.config.AutoMessages <= 0 {
+		return
+	}
+	ticker := time.NewTicker(summaryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.summarizeLong(ctx)
+		}
+	}
+}
+
+// summarizeLong starts summarizing one batch of long conversations.
+// Conversations with a run started within the cache window, or the poll
+// interval if that is longer, are left for later.
+func (s *SummaryService) summarizeLong(ctx context.Context) {
+	since := time.Now().Add(-max(s.config.CacheWindow, summaryPollInterval))
+	ids, err := s.summaryRepo.GetUnsummarized(ctx, s.config.AutoMessages, since, summaryBatchSize)
+	if err != nil {
+		log.Printf("Failed to load conversations to summarize: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		conversation, err := s.conversationRepo.GetByID(ctx, id)
+		if err != nil {
+			log.Printf("Failed to load conversation %s to summarize: %v", id, err)
+			continue
+		}
+		if _, _, err := s.summarize(ctx, conversation, uuid.Nil, true); err != nil {
+			log.Printf("Failed to summarize conversation %s: %v", id, err)
+		}
+	}
+}
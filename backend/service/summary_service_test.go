@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSummarizeReturnsCachedRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	summaries := mocks.NewMockConversationSummaryRepository(ctrl)
+	conversations := mocks.NewMockConversationRepository(ctrl)
+	messages := mocks.NewMockMessageRepository(ctrl)
+	svc := NewSummaryService(summaries, conversations, messages, nil, nil, nil, nil, nil, SummaryConfig{CacheWindow: 10 * time.Minute})
+	ctx := context.Background()
+
+	conversation := &domain.Conversation{ID: uuid.New(), OfficeID: uuid.New()}
+	conversations.EXPECT().GetByID(gomock.Any(), conversation.ID).AnyTimes().Return(conversation, nil)
+	input := SummarizeInput{OfficeID: conversation.OfficeID, ConversationID: conversation.ID}
+
+	for name, latest := range map[string]*domain.ConversationSummary{
+		"pending":     {ID: uuid.New(), Status: domain.SummaryStatusPending, CreatedAt: time.Now().Add(-time.Hour)},
+		"recent done": {ID: uuid.New(), Status: domain.SummaryStatusDone, CreatedAt: time.Now().Add(-time.Minute)},
+	} {
+		summaries.EXPECT().GetLatest(gomock.Any(), conversation.ID).Return(latest, nil)
+		summary, started, err := svc.Summarize(ctx, input)
+		if err != nil || started || summary != latest {
+			t.Errorf("%s: Summarize = %+v, %t, %v; want the latest run", name, summary, started, err)
+		}
+	}
+
+	// An older summary is returned while nothing but summaries was posted since
+	previous := &domain.ConversationSummary{
+		ID: uuid.New(), Status: domain.SummaryStatusDone, CreatedAt: time.Now().Add(-time.Hour),
+		ThroughMessageID: uuid.New(), ThroughAt: time.Now().Add(-time.Hour),
+	}
+	summaries.EXPECT().GetLatest(gomock.Any(), conversation.ID).Return(previous, nil)
+	summaries.EXPECT().GetLatestDone(gomock.Any(), conversation.ID).Return(previous, nil)
+	after := &domain.PageCursor{CreatedAt: previous.ThroughAt, ID: previous.ThroughMessageID}
+	messages.EXPECT().GetNextByConversationID(gomock.Any(), conversation.ID, after, summaryMaxMessages).
+		Return([]*domain.Message{{ID: uuid.New(), SenderType: domain.SenderTypeSystem, Content: "Earlier summary"}}, nil)
+	summary, started, err := svc.Summarize(ctx, input)
+	if err != nil || started || summary != previous {
+		t.Errorf("Summarize without new messages = %+v, %t, %v; want the previous summary", summary, started, err)
+	}
+}
+
+func TestSummarizeLosingTheClaimReturnsThePendingRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	summaries := mocks.NewMockConversationSummaryRepository(ctrl)
+	conversations := mocks.NewMockConversationRepository(ctrl)
+	messages := mocks.NewMockMessageRepository(ctrl)
+	credits := mocks.NewMockCreditRepository(ctrl)
+	creditService := NewCreditService(credits, mocks.NewMockOfficeRepository(ctrl), mocks.NewMockIdempotencyRepository(ctrl), newTestTxManager(ctrl), nil)
+	// No task service: a run that loses the claim must not start a task
+	svc := NewSummaryService(summaries, conversations, messages, nil, nil, nil, creditService, nil, SummaryConfig{})
+	ctx := context.Background()
+
+	conversation := &domain.Conversation{ID: uuid.New(), OfficeID: uuid.New()}
+	agent := &domain.Agent{ID: uuid.New()}
+	wallet := &domain.CreditWallet{ID: uuid.New()}
+	conversations.EXPECT().GetByID(gomock.Any(), conversation.ID).Return(conversation, nil)
+	conversations.EXPECT().GetParticipants(gomock.Any(), conversation.ID).Return([]*domain.Agent{agent}, nil)
+	messages.EXPECT().GetNextByConversationID(gomock.Any(), conversation.ID, nil, summaryMaxMessages).
+		Return([]*domain.Message{{ID: uuid.New(), SenderType: domain.SenderTypeAgent, SenderID: agent.ID, Content: "Launch moves to May."}}, nil)
+	credits.EXPECT().GetWalletByOfficeID(gomock.Any(), conversation.OfficeID).Return(wallet, nil)
+	credits.EXPECT().HasSufficientBalance(gomock.Any(), wallet.ID, int64(1)).Return(true, int64(100), nil)
+
+	// Another request claimed the conversation after the pending check
+	pending := &domain.ConversationSummary{ID: uuid.New(), Status: domain.SummaryStatusPending}
+	gomock.InOrder(
+		summaries.EXPECT().GetLatest(gomock.Any(), conversation.ID).Return(nil, domain.ErrNotFound),
+		summaries.EXPECT().GetLatest(gomock.Any(), conversation.ID).Return(pending, nil),
+	)
+	summaries.EXPECT().GetLatestDone(gomock.Any(), conversation.ID).Return(nil, domain.ErrNotFound)
+	summaries.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.ErrAlreadyExists)
+
+	summary, started, err := svc.Summarize(ctx, SummarizeInput{OfficeID: conversation.OfficeID, ConversationID: conversation.ID})
+	if err != nil || started || summary != pending {
+		t.Errorf("Summarize = %+v, %t, %v; want the other request's pending run", summary, started, err)
+	}
+}
+
+func TestCompleteSummaryPostsPinnedMessage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	summaries := mocks.NewMockConversationSummaryRepository(ctrl)
+	events := mocks.NewMockEventPublisher(ctrl)
+	svc := NewSummaryService(summaries, nil, nil, nil, nil, nil, nil, events, SummaryConfig{})
+	ctx := context.Background()
+
+	chatTask := uuid.New()
+	summaries.EXPECT().GetByTaskID(gomock.Any(), chatTask).Return(nil, domain.ErrNotFound)
+	if handled, err := svc.CompleteSummary(ctx, chatTask, "A reply"); handled || err != nil {
+		t.Errorf("CompleteSummary of a chat task = %t, %v; want it left to the chat", handled, err)
+	}
+
+	taskID := uuid.New()
+	run := &domain.ConversationSummary{ID: uuid.New(), OfficeID: uuid.New(), ConversationID: uuid.New(), TaskID: &taskID, Auto: true}
+	summaries.EXPECT().GetByTaskID(gomock.Any(), taskID).Return(run, nil)
+	var posted *domain.Message
+	summaries.EXPECT().Complete(gomock.Any(), run, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ *domain.ConversationSummary, message *domain.Message) error {
+			posted = message
+			return nil
+		})
+	var published []domain.EventType
+	events.EXPECT().Publish(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(_ context.Context, event domain.Event) error {
+			published = append(published, event.Type)
+			return nil
+		})
+
+	handled, err := svc.CompleteSummary(ctx, taskID, "  Launch moves to May.\n")
+	if !handled || err != nil {
+		t.Fatalf("CompleteSummary = %t, %v; want the summary stored", handled, err)
+	}
+	if posted.SenderType != domain.SenderTypeSystem || posted.SenderID != run.ID || posted.PinnedAt == nil ||
+		posted.Content != "Launch moves to May." {
+		t.Errorf("posted %+v, want a pinned system message with the trimmed summary", posted)
+	}
+	if run.Status != domain.SummaryStatusDone || run.MessageID == nil || *run.MessageID != posted.ID {
+		t.Errorf("run = %+v, want it done with the posted message", run)
+	}
+	if len(published) != 2 || published[0] != domain.EventNewMessage || published[1] != domain.EventConversationSummarized {
+		t.Errorf("published %v, want new_message and conversation_summarized", published)
+	}
+}
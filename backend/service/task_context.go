@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// TaskContextConfig bounds the context sent to the orchestrator with a task
+type TaskContextConfig struct {
+	// HistoryMessages is the most conversation messages to send
+	HistoryMessages int
+	// Memories is the most agent memories to send
+	Memories int
+	// TokenBudget is the estimated tokens memories, knowledge and history
+	// may take together; memories are given room first, then knowledge,
+	// then the newest messages
+	TokenBudget int
+
+	// KnowledgeChunks is the most chunks of the office's knowledge base to
+	// send, those most relevant to the task's input
+	KnowledgeChunks int
+}
+
+// OrchestratorAgent is who the agent running a task is
+type OrchestratorAgent struct {
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	SystemPrompt string `json:"system_prompt"`
+}
+
+// OrchestratorMessage is an earlier conversation message. Role is
+// "assistant" for the task agent's own messages and "user" for everyone
+// else's, including other agents.
+type OrchestratorMessage struct {
+	Role       string    `json:"role"`
+	SenderName string    `json:"sender_name"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrchestratorMemory is one of the agent's long-term memories
+type OrchestratorMemory struct {
+	Key        string  `json:"key"`
+	Value      string  `json:"value"`
+	Type       string  `json:"type"`
+	Importance float64 `json:"importance"`
+}
+
+// OrchestratorKnowledge is a chunk of the office's knowledge base
+type OrchestratorKnowledge struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// OrchestratorModelPolicy is the office's or agent's model policy. Empty
+// AllowedProviders allows every provider.
+type OrchestratorModelPolicy struct {
+	AllowedProviders  []string `json:"allowed_providers"`
+	PreferredModel    string   `json:"preferred_model,omitempty"`
+	MaxCreditsPerTask *int64   `json:"max_credits_per_task,omitempty"`
+}
+
+// TaskContextBuilder assembles what an agent needs to know to run a task:
+// its identity and system prompt, its most important memories, the office's
+// knowledge relevant to the task, the recent conversation and the model
+// policy it runs under
+type TaskContextBuilder struct {
+	messageRepo     domain.MessageRepository
+	agentRepo       domain.AgentRepository
+	memoryRepo      domain.AgentMemoryRepository
+	userRepo        domain.UserRepository
+	modelPolicyRepo domain.ModelPolicyRepository
+	knowledge       *KnowledgeService
+	skills          *SkillService
+	config          TaskContextConfig
+
+	// summaryRepo provides the latest summary of a task's conversation,
+	// which stands in for the messages it covers
+	summaryRepo domain.ConversationSummaryRepository
+}
+
+// NewTaskContextBuilder creates a new TaskContextBuilder instance
+func NewTaskContextBuilder(
+	messageRepo domain.MessageRepository,
+	agentRepo domain.AgentRepository,
+	memoryRepo domain.AgentMemoryRepository,
+	userRepo domain.UserRepository,
+	modelPolicyRepo domain.ModelPolicyRepository,
+	knowledge *KnowledgeService,
+	skills *SkillService,
+	summaryRepo domain.ConversationSummaryRepository,
+	config TaskContextConfig,
+) *TaskContextBuilder {
+	return &TaskContextBuilder{
+		messageRepo:     messageRepo,
+		agentRepo:       agentRepo,
+		memoryRepo:      memoryRepo,
+		userRepo:        userRepo,
+		modelPolicyRepo: modelPolicyRepo,
+		knowledge:       knowledge,
+		skills:          skills,
+		config:          config,
+		summaryRepo:     summaryRepo,
+	}
+}
+
+// Build fills in the agent, skills, memories, knowledge, history and model
+// policy of a task's orchestrator request. The history is the conversation
+// before the task's message; for a thread reply, before the thread, which
+// the task's input already quotes. Once the conversation is summarized, the
+// history starts with the summary and leaves out the messages it covers.
+// Summary tasks get no tools, memories, knowledge or history: their input
+// is the conversation to summarize.
+func (b *TaskContextBuilder) Build(ctx context.Context, task *domain.Task, request *OrchestratorRequest) error {
+	agent, err := b.agentRepo.GetByID(ctx, task.AgentID)
+	if err != nil {
+		return err
+	}
+	request.Agent = &OrchestratorAgent{Name: agent.GetName(), SystemPrompt: agent.GetSystemPrompt()}
+	if agent.Template != nil {
+		request.Agent.Role = agent.Template.Role
+	}
+
+	policy, err := b.modelPolicyRepo.GetEffective(ctx, task.OfficeID, task.AgentID)
+	switch {
+	case err == nil:
+		request.ModelPolicy = &OrchestratorModelPolicy{
+			AllowedProviders:  policy.AllowedProviders,
+			PreferredModel:    policy.PreferredModel,
+			MaxCreditsPerTask: policy.MaxCreditsPerTask,
+		}
+	case !errors.Is(err, domain.ErrNotFound):
+		return err
+	}
+
+	if task.Kind == domain.TaskKindSummary {
+		request.Skills = []string{}
+		request.Memories = []OrchestratorMemory{}
+		request.History = []OrchestratorMessage{}
+		return nil
+	}
+
+	request.Skills, err = b.skills.EnabledSkills(ctx, task.OfficeID, task.AgentID)
+	if err != nil {
+		return err
+	}
+
+	budget := b.config.TokenBudget
+	memories, err := b.memoryRepo.GetByAgentID(ctx, task.AgentID)
+	if err != nil {
+		return err
+	}
+	// Memories are listed most important first
+	request.Memories = make([]OrchestratorMemory, 0, b.config.Memories)
+	for _, memory := range memories {
+		if len(request.Memories) == b.config.Memories {
+			break
+		}
+		tokens := estimateTokens(memory.Key) + estimateTokens(memory.Value)
+		if tokens > budget {
+			continue
+		}
+		budget -= tokens
+		request.Memories = append(request.Memories, OrchestratorMemory{
+			Key:        memory.Key,
+			Value:      memory.Value,
+			Type:       memory.MemoryType,
+			Importance: memory.ImportanceScore,
+		})
+	}
+
+	if b.config.KnowledgeChunks > 0 {
+		// Chunks are listed most relevant first
+		chunks, err := b.knowledge.Relevant(ctx, task.OfficeID, task.Input, b.config.KnowledgeChunks)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			tokens := estimateTokens(chunk.DocumentTitle) + estimateTokens(chunk.Content)
+			if tokens > budget {
+				continue
+			}
+			budget -= tokens
+			request.Knowledge = append(request.Knowledge, OrchestratorKnowledge{Title: chunk.DocumentTitle, Content: chunk.Content})
+		}
+	}
+
+	history, err := b.history(ctx, task)
+	if err != nil {
+		return err
+	}
+	summary, err := b.summary(ctx, task)
+	if err != nil {
+		return err
+	}
+	if summary != nil {
+		history = slices.DeleteFunc(history, func(message *domain.Message) bool {
+			return message.SenderType == domain.SenderTypeSystem || !message.CreatedAt.After(summary.ThroughAt)
+		})
+		if tokens := estimateTokens(summary.Content); tokens <= budget {
+			budget -= tokens
+		} else {
+			summary = nil
+		}
+	}
+	// Keep the newest messages that fit
+	first := len(history)
+	for first > 0 {
+		tokens := estimateTokens(history[first-1].Content)
+		if tokens > budget {
+			break
+		}
+		budget -= tokens
+		first--
+	}
+
+	names := newSenderNames(b.agentRepo, b.userRepo, []*domain.Agent{agent})
+	request.History = make([]OrchestratorMessage, 0, len(history)-first+1)
+	if summary != nil {
+		request.History = append(request.History, OrchestratorMessage{
+			Role:       "user",
+			SenderName: summarySenderName,
+			Content:    summary.Content,
+			CreatedAt:  *summary.CompletedAt,
+		})
+	}
+	for _, message := range history[first:] {
+		name, err := names.get(ctx, message.SenderType, message.SenderID)
+		if err != nil {
+			return err
+		}
+		role := "user"
+		if message.SenderType == domain.SenderTypeAgent && message.SenderID == task.AgentID {
+			role = "assistant"
+		}
+		request.History = append(request.History, OrchestratorMessage{
+			Role:       role,
+			SenderName: name,
+			Content:    message.Content,
+			CreatedAt:  message.CreatedAt,
+		})
+	}
+	return nil
+}
+
+// history loads the conversation messages before a task's message, oldest
+// first. Tasks without a message, such as scheduled ones, get the latest.
+func (b *TaskContextBuilder) history(ctx context.Context, task *domain.Task) ([]*domain.Message, error) {
+	if b.config.HistoryMessages <= 0 {
+		return nil, nil
+	}
+
+	var before *domain.PageCursor
+	if task.MessageID != uuid.Nil {
+		message, err := b.messageRepo.GetByID(ctx, task.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		// The input of a reply already quotes its whole thread
+		if message.ParentMessageID != nil {
+			if message, err = b.messageRepo.GetByID(ctx, *message.ParentMessageID); err != nil {
+				return nil, err
+			}
+		}
+		before = &domain.PageCursor{CreatedAt: message.CreatedAt, ID: message.ID}
+	}
+	return b.messageRepo.GetRecentByConversationID(ctx, task.ConversationID, before, b.config.HistoryMessages)
+}
+
+// summary loads the latest summary of a task's conversation, or returns nil
+// if it has none or no history is sent
+func (b *TaskContextBuilder) summary(ctx context.Context, task *domain.Task) (*domain.ConversationSummary, error) {
+	if b.config.HistoryMessages <= 0 || task.ConversationID == uuid.Nil {
+		return nil, nil
+	}
+	summary, err := b.summaryRepo.GetLatestDone(ctx, task.ConversationID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	return summary, err
+}
+
+// estimateTokens roughly counts the tokens of text, at four characters per
+// token
+func estimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/4 + 1
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// DelegationConfig bounds the sub-tasks agents delegate to each other
+type DelegationConfig struct {
+	// MaxDepth is how many delegations deep a task tree may grow
+	MaxDepth int
+	// MaxTasks is how many sub-tasks one task tree may hold
+	MaxTasks int
+}
+
+// errDelegationLimit marks delegations refused because the task tree is as
+// deep or as large as DelegationConfig allows
+var errDelegationLimit = errors.New("delegation limit reached")
+
+// DelegateInput contains input for delegating a sub-task
+type DelegateInput struct {
+	// Parent is the task whose agent delegated
+	Parent *domain.Task
+	// MessageID is the parent agent's reply that delegated
+	MessageID uuid.UUID
+	AgentID   uuid.UUID
+	Input     string
+}
+
+// Delegate creates a sub-task of a task for another agent. It is refused
+// once the tree reaches the configured depth or size, or while the office
+// has no credits left.
+func (s *TaskService) Delegate(ctx context.Context, input DelegateInput) (*domain.Task, error) {
+	parent := input.Parent
+	depth := parent.Depth + 1
+	if depth > s.delegation.MaxDepth {
+		return nil, fmt.Errorf("%w: task trees are at most %d delegations deep", errDelegationLimit, s.delegation.MaxDepth)
+	}
+
+	rootID := parent.ID
+	if parent.RootTaskID != nil {
+		rootID = *parent.RootTaskID
+	}
+	count, err := s.taskRepo.CountByRootID(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= s.delegation.MaxTasks {
+		return nil, fmt.Errorf("%w: task trees hold at most %d sub-tasks", errDelegationLimit, s.delegation.MaxTasks)
+	}
+
+	sufficient, _, err := s.creditService.CheckSufficientCredits(ctx, parent.OfficeID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !sufficient {
+		return nil, fmt.Errorf("%w: no credits left for delegated tasks", domain.ErrInsufficientCredits)
+	}
+
+	parentID := parent.ID
+	task := &domain.Task{
+		ID:             uuid.New(),
+		OfficeID:       parent.OfficeID,
+		ConversationID: parent.ConversationID,
+		MessageID:      input.MessageID,
+		AgentID:        input.AgentID,
+		Status:         domain.TaskStatusPending,
+		Input:          input.Input,
+		TokenUsage:     make(map[string]int),
+		MaxAttempts:    DefaultTaskMaxAttempts,
+		CreatedAt:      time.Now(),
+		ParentTaskID:   &parentID,
+		RootTaskID:     &rootID,
+		Depth:          depth,
+	}
+	if err := s.start(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetSubtaskTree returns a task of the office with the sub-tasks delegated
+// from it, recursively, oldest first
+func (s *TaskService) GetSubtaskTree(ctx context.Context, officeID, taskID uuid.UUID) (*domain.TaskTree, error) {
+	task, err := s.GetOfficeTask(ctx, officeID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	rootID := task.ID
+	if task.RootTaskID != nil {
+		rootID = *task.RootTaskID
+	}
+	tasks, err := s.taskRepo.GetByRootID(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[uuid.UUID][]*domain.Task)
+	for _, t := range tasks {
+		if t.ParentTaskID != nil {
+			children[*t.ParentTaskID] = append(children[*t.ParentTaskID], t)
+		}
+	}
+	var build func(t *domain.Task) *domain.TaskTree
+	build = func(t *domain.Task) *domain.TaskTree {
+		tree := &domain.TaskTree{Task: t, Subtasks: []*domain.TaskTree{}}
+		for _, child := range children[t.ID] {
+			tree.Subtasks = append(tree.Subtasks, build(child))
+		}
+		return tree
+	}
+	return build(task), nil
+}
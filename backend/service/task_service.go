@@ -4,28 +4,83 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/denys89/syn-office/backend/domain"
 	"github.com/google/uuid"
 )
 
+const (
+	// DefaultTaskMaxAttempts is how many dispatch attempts a task gets before
+	// it is dead-lettered
+	DefaultTaskMaxAttempts = 3
+
+	// taskRetryBaseDelay is the delay before the first retry; each further
+	// retry doubles it up to taskRetryMaxDelay
+	taskRetryBaseDelay = 5 * time.Second
+	taskRetryMaxDelay  = 5 * time.Minute
+
+	// taskRetryPollInterval is how often the retry worker looks for tasks to dispatch
+	taskRetryPollInterval = 10 * time.Second
+	taskRetryBatchSize    = 20
+
+	// orchestratorAttachmentURLTTL is how long the orchestrator can download
+	// a task's attachments; URLs are signed again on every dispatch
+	orchestratorAttachmentURLTTL = time.Hour
+)
+
 // TaskService handles task-related operations
 type TaskService struct {
-	taskRepo        domain.TaskRepository
-	orchestratorURL string
-	httpClient      *http.Client
+	taskRepo          domain.TaskRepository
+	creditService     *CreditService
+	analytics         *AnalyticsService
+	attachmentService *AttachmentService
+	contextBuilder    *TaskContextBuilder
+	events            domain.EventPublisher
+	orchestratorURL   string
+	delegation        DelegationConfig
+	httpClient        *http.Client
+
+	// inFlight holds the IDs of tasks this instance is waiting on the
+	// orchestrator for
+	inFlight sync.Map // task ID -> struct{}
+
+	// subscriptionService holds back tasks of offices whose subscription is
+	// not active
+	subscriptionService *SubscriptionService
 }
 
 // NewTaskService creates a new TaskService instance
-func NewTaskService(taskRepo domain.TaskRepository, orchestratorURL string) *TaskService {
+func NewTaskService(
+	taskRepo domain.TaskRepository,
+	creditService *CreditService,
+	subscriptionService *SubscriptionService,
+	analytics *AnalyticsService,
+	attachmentService *AttachmentService,
+	contextBuilder *TaskContextBuilder,
+	events domain.EventPublisher,
+	orchestratorURL string,
+	delegation DelegationConfig,
+) *TaskService {
 	return &TaskService{
-		taskRepo:        taskRepo,
-		orchestratorURL: orchestratorURL,
+		taskRepo:          taskRepo,
+		creditService:     creditService,
+		analytics:         analytics,
+		attachmentService: attachmentService,
+		contextBuilder:    contextBuilder,
+		events:            events,
+		orchestratorURL:   orchestratorURL,
+		delegation:        delegation,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		subscriptionService: subscriptionService,
 	}
 }
 
@@ -36,6 +91,8 @@ type CreateTaskInput struct {
 	MessageID      uuid.UUID
 	AgentID        uuid.UUID
 	Input          string
+	// Kind defaults to a chat task
+	Kind domain.TaskKind
 }
 
 // CreateTask creates a new task and sends it to the orchestrator
@@ -49,17 +106,30 @@ func (s *TaskService) CreateTask(ctx context.Context, input CreateTaskInput) (*d
 		Status:         domain.TaskStatusPending,
 		Input:          input.Input,
 		TokenUsage:     make(map[string]int),
+		MaxAttempts:    DefaultTaskMaxAttempts,
 		CreatedAt:      time.Now(),
+		Kind:           input.Kind,
 	}
+	if err := s.start(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
 
+// start saves a new task and sends it to the orchestrator. Offices whose
+// subscription is not active cannot start tasks.
+func (s *TaskService) start(ctx context.Context, task *domain.Task) error {
+	if err := s.subscriptionService.RequireActive(ctx, task.OfficeID); err != nil {
+		return err
+	}
 	if err := s.taskRepo.Create(ctx, task); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Send task to orchestrator asynchronously
-	go s.sendToOrchestrator(context.Background(), task)
+	go s.dispatch(context.Background(), task)
 
-	return task, nil
+	return nil
 }
 
 // GetTask returns a task by ID
@@ -67,17 +137,182 @@ func (s *TaskService) GetTask(ctx context.Context, taskID uuid.UUID) (*domain.Ta
 	return s.taskRepo.GetByID(ctx, taskID)
 }
 
-// GetTasksByAgent returns tasks for an agent
-func (s *TaskService) GetTasksByAgent(ctx context.Context, agentID uuid.UUID, limit, offset int) ([]*domain.Task, error) {
+// ListTasks returns a page of an office's tasks matching the filter along
+// with the total number of matches
+func (s *TaskService) ListTasks(ctx context.Context, filter domain.TaskFilter, page domain.PageRequest) ([]*domain.Task, int, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, 0, fmt.Errorf("%w: unknown task status %q", domain.ErrInvalidInput, filter.Status)
+	}
+	if page.Limit <= 0 {
+		page.Limit = 20
+	}
+
+	tasks, err := s.taskRepo.List(ctx, filter, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.taskRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// GetOfficeTask returns a task, hiding tasks of other offices as not found
+func (s *TaskService) GetOfficeTask(ctx context.Context, officeID, taskID uuid.UUID) (*domain.Task, error) {
+	task, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(task.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// CancelTask stops an unfinished task of the office: the task is marked
+// cancelled, the orchestrator is asked to stop it and any credits it consumed
+// are refunded. Clients are sent a task_cancelled event.
+func (s *TaskService) CancelTask(ctx context.Context, officeID, taskID uuid.UUID) (*domain.Task, error) {
+	task, err := s.GetOfficeTask(ctx, officeID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.IsFinished() {
+		return nil, fmt.Errorf("%w: task has already finished", domain.ErrInvalidInput)
+	}
+
+	cancelled, err := s.taskRepo.Cancel(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, fmt.Errorf("%w: task has already finished", domain.ErrInvalidInput)
+	}
+	wasRunning := task.Status == domain.TaskStatusThinking || task.Status == domain.TaskStatusWorking
+	task.Status = domain.TaskStatusCancelled
+	task.NextAttemptAt = nil
+
+	// Stop the orchestrator before refunding so credits it consumes while
+	// winding down are refunded too
+	if wasRunning {
+		if err := s.cancelOnOrchestrator(ctx, task.ID); err != nil {
+			log.Printf("Failed to cancel task %s on orchestrator: %v", task.ID, err)
+		}
+	}
+	refunded, err := s.creditService.RefundTaskCredits(ctx, task.OfficeID, task.ID)
+	if err != nil {
+		log.Printf("Failed to refund credits for task %s: %v", task.ID, err)
+	}
+
+	s.publishStatus(ctx, task)
+	payload := map[string]any{
+		"task_id":          task.ID.String(),
+		"agent_id":         task.AgentID.String(),
+		"refunded_credits": refunded,
+	}
+	if task.ConversationID != uuid.Nil {
+		payload["conversation_id"] = task.ConversationID.String()
+	}
+	if err := s.events.Publish(ctx, domain.NewEvent(task.OfficeID, domain.EventTaskCancelled, payload)); err != nil {
+		log.Printf("Failed to publish cancellation of task %s: %v", task.ID, err)
+	}
+
+	return task, nil
+}
+
+// CancelRequest asks the orchestrator to stop a running task
+type CancelRequest struct {
+	TaskID string `json:"task_id"`
+}
+
+// cancelOnOrchestrator asks the orchestrator to stop executing a task
+func (s *TaskService) cancelOnOrchestrator(ctx context.Context, taskID uuid.UUID) error {
+	jsonBody, err := json.Marshal(CancelRequest{TaskID: taskID.String()})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.orchestratorURL+"/cancel", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetTasksByAgent returns tasks for an agent of the office
+func (s *TaskService) GetTasksByAgent(ctx context.Context, officeID, agentID uuid.UUID, limit, offset int) ([]*domain.Task, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	return s.taskRepo.GetByAgentID(ctx, agentID, limit, offset)
+	filter := domain.TaskFilter{OfficeID: officeID, AgentID: agentID}
+	return s.taskRepo.List(ctx, filter, domain.PageRequest{Limit: limit, Offset: offset})
 }
 
 // UpdateTaskStatus updates the status of a task
 func (s *TaskService) UpdateTaskStatus(ctx context.Context, taskID uuid.UUID, status domain.TaskStatus, output, errMsg string) error {
-	return s.taskRepo.UpdateStatus(ctx, taskID, status, output, errMsg)
+	task, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	return s.setStatus(ctx, task, status, output, errMsg)
+}
+
+// setStatus persists a task status change and publishes it
+func (s *TaskService) setStatus(ctx context.Context, task *domain.Task, status domain.TaskStatus, output, errMsg string) error {
+	if err := s.taskRepo.UpdateStatus(ctx, task.ID, status, output, errMsg); err != nil {
+		return err
+	}
+	task.Status = status
+	s.publishStatus(ctx, task)
+	return nil
+}
+
+// publishStatus broadcasts agent presence for the task's status so connected
+// clients can show what each agent is doing: agent_typing while thinking,
+// agent_working while working and agent_idle once the task is done or has failed
+func (s *TaskService) publishStatus(ctx context.Context, task *domain.Task) {
+	var eventType domain.EventType
+	switch task.Status {
+	case domain.TaskStatusThinking:
+		eventType = domain.EventAgentTyping
+	case domain.TaskStatusWorking:
+		eventType = domain.EventAgentWorking
+	case domain.TaskStatusDone, domain.TaskStatusFailed, domain.TaskStatusDeadLetter, domain.TaskStatusCancelled:
+		eventType = domain.EventAgentIdle
+	default:
+		return
+	}
+
+	payload := map[string]any{
+		"task_id":  task.ID.String(),
+		"agent_id": task.AgentID.String(),
+		"status":   string(task.Status),
+	}
+	if task.ConversationID != uuid.Nil {
+		payload["conversation_id"] = task.ConversationID.String()
+	}
+
+	if err := s.events.Publish(ctx, domain.NewEvent(task.OfficeID, eventType, payload)); err != nil {
+		log.Printf("Failed to publish status of task %s: %v", task.ID, err)
+	}
+}
+
+// OrchestratorResponse is the result the orchestrator returns from /execute
+type OrchestratorResponse struct {
+	TaskID string            `json:"task_id"`
+	Status domain.TaskStatus `json:"status"`
 }
 
 // OrchestratorRequest represents a request to the agent orchestrator
@@ -87,51 +322,274 @@ type OrchestratorRequest struct {
 	OfficeID       string `json:"office_id"`
 	ConversationID string `json:"conversation_id"`
 	Input          string `json:"input"`
+	// Attempt numbers dispatches of the task so the orchestrator can key
+	// side effects such as credit consumption per attempt
+	Attempt int `json:"attempt"`
+	// Attachments are the files sent with the task's message
+	Attachments []OrchestratorAttachment `json:"attachments,omitempty"`
+	// Agent, Memories and History are the context the agent answers in,
+	// assembled by TaskContextBuilder
+	Agent    *OrchestratorAgent    `json:"agent,omitempty"`
+	Memories []OrchestratorMemory  `json:"memories"`
+	History  []OrchestratorMessage `json:"history"`
+	// ModelPolicy constrains the model the task is routed to; nil leaves
+	// the choice to the orchestrator
+	ModelPolicy *OrchestratorModelPolicy `json:"model_policy,omitempty"`
+	// Knowledge is the office's shared knowledge relevant to the task,
+	// assembled by TaskContextBuilder
+	Knowledge []OrchestratorKnowledge `json:"knowledge,omitempty"`
+	// Skills are the keys of the skills the agent uses; the orchestrator
+	// only permits their tools
+	Skills []string `json:"skills"`
+	// Kind tells the orchestrator what the output is for; it saves the
+	// output of chat tasks as the agent's reply
+	Kind domain.TaskKind `json:"kind"`
 }
 
-// sendToOrchestrator sends a task to the Python orchestrator
-func (s *TaskService) sendToOrchestrator(ctx context.Context, task *domain.Task) {
-	// Update status to thinking
-	_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusThinking, "", "")
+// OrchestratorAttachment references a file the orchestrator can download
+// from URL until it expires
+type OrchestratorAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	URL         string `json:"url"`
+}
 
+// dispatch claims a pending or due task and sends it to the orchestrator.
+// If the orchestrator is unavailable the dispatch is retried with exponential
+// backoff until the task runs out of attempts, at which point it is dead-lettered.
+func (s *TaskService) dispatch(ctx context.Context, task *domain.Task) {
+	claimed, err := s.taskRepo.ClaimForDispatch(ctx, task)
+	if err != nil {
+		log.Printf("Failed to claim task %s: %v", task.ID, err)
+		return
+	}
+	if !claimed {
+		// Already picked up by another dispatcher
+		return
+	}
+
+	s.inFlight.Store(task.ID, struct{}{})
+	defer s.inFlight.Delete(task.ID)
+
+	// Claiming moved the task to thinking
+	s.publishStatus(ctx, task)
+
+	err = s.sendToOrchestrator(ctx, task)
+	switch {
+	case err == nil:
+	case errors.Is(err, errOrchestratorUnavailable):
+		s.dispatchFailed(ctx, task, err.Error())
+	default:
+		// The orchestrator may have started on the task, so retrying could
+		// run it twice; leave it for a manual retry
+		_ = s.setStatus(ctx, task, domain.TaskStatusFailed, "", err.Error())
+	}
+}
+
+// orchestratorAttachments signs download URLs for the attachments of the
+// task's message. Tasks without a message, such as scheduled ones, have none.
+func (s *TaskService) orchestratorAttachments(ctx context.Context, task *domain.Task) ([]OrchestratorAttachment, error) {
+	if task.MessageID == uuid.Nil {
+		return nil, nil
+	}
+	byMessage, err := s.attachmentService.GetForMessages(ctx, task.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []OrchestratorAttachment
+	for _, attachment := range byMessage[task.MessageID] {
+		url, _, err := s.attachmentService.SignedURL(ctx, attachment, orchestratorAttachmentURLTTL)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, OrchestratorAttachment{
+			ID:          attachment.ID.String(),
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			SizeBytes:   attachment.SizeBytes,
+			URL:         url,
+		})
+	}
+	return refs, nil
+}
+
+// errOrchestratorUnavailable marks dispatch failures where the orchestrator
+// did not accept the task, which are safe to retry automatically
+var errOrchestratorUnavailable = errors.New("orchestrator unavailable")
+
+// sendError classifies an error from calling the orchestrator. Timeouts are
+// not retryable since the orchestrator may still be working on the task.
+func sendError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	return fmt.Errorf("%w: %v", errOrchestratorUnavailable, err)
+}
+
+// sendToOrchestrator sends a task to the Python orchestrator
+func (s *TaskService) sendToOrchestrator(ctx context.Context, task *domain.Task) error {
 	request := OrchestratorRequest{
 		TaskID:         task.ID.String(),
 		AgentID:        task.AgentID.String(),
 		OfficeID:       task.OfficeID.String(),
 		ConversationID: task.ConversationID.String(),
 		Input:          task.Input,
+		Attempt:        task.Attempts,
+		Kind:           task.Kind,
+	}
+	attachments, err := s.orchestratorAttachments(ctx, task)
+	if err != nil {
+		// Nothing was sent yet, so the dispatch can be retried
+		return fmt.Errorf("%w: loading attachments: %v", errOrchestratorUnavailable, err)
+	}
+	request.Attachments = attachments
+	if err := s.contextBuilder.Build(ctx, task, &request); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			// The agent or message is gone; retrying cannot help
+			return fmt.Errorf("loading task context: %w", err)
+		}
+		return fmt.Errorf("%w: loading task context: %v", errOrchestratorUnavailable, err)
 	}
 
 	jsonBody, err := json.Marshal(request)
 	if err != nil {
-		_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusFailed, "", err.Error())
-		return
+		return err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", s.orchestratorURL+"/execute", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusFailed, "", err.Error())
-		return
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	// Update status to working
-	_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusWorking, "", "")
+	_ = s.setStatus(ctx, task, domain.TaskStatusWorking, "", "")
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusFailed, "", err.Error())
-		return
+		return sendError(err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		_ = s.taskRepo.UpdateStatus(ctx, task.ID, domain.TaskStatusFailed, "", "orchestrator returned non-OK status")
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: status %d", errOrchestratorUnavailable, resp.StatusCode)
+	default:
+		return fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
+	}
+
+	// The orchestrator has already stored the final status and output (the
+	// message itself arrives via the task-complete webhook); only publish it
+	var result OrchestratorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Status == "" {
+		result.Status = domain.TaskStatusDone
+	}
+	task.Status = result.Status
+	s.publishStatus(ctx, task)
+	return nil
+}
+
+// dispatchFailed schedules a retry for a task the orchestrator did not
+// accept, or dead-letters it once its attempts are used up
+func (s *TaskService) dispatchFailed(ctx context.Context, task *domain.Task, reason string) {
+	if task.Attempts >= task.MaxAttempts {
+		log.Printf("Task %s dead-lettered after %d attempts: %s", task.ID, task.Attempts, reason)
+		_ = s.setStatus(ctx, task, domain.TaskStatusDeadLetter, "", reason)
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(retryDelay(task.Attempts))
+	if err := s.taskRepo.ScheduleRetry(ctx, task.ID, reason, nextAttemptAt); err != nil {
+		log.Printf("Failed to schedule retry for task %s: %v", task.ID, err)
+		return
+	}
+	task.Status = domain.TaskStatusFailed
+	task.Error = reason
+	task.NextAttemptAt = &nextAttemptAt
+	s.publishStatus(ctx, task)
+}
+
+// retryDelay returns the backoff before the retry following the given attempt
+func retryDelay(attempt int) time.Duration {
+	delay := taskRetryBaseDelay
+	for i := 1; i < attempt && delay < taskRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, taskRetryMaxDelay)
+}
+
+// Run re-dispatches pending tasks and failed tasks whose retry is due until
+// ctx is cancelled
+func (s *TaskService) Run(ctx context.Context) {
+	ticker := time.NewTicker(taskRetryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.dispatchPending(ctx)
+		}
+	}
+}
+
+// dispatchPending dispatches one batch of tasks awaiting dispatch
+func (s *TaskService) dispatchPending(ctx context.Context) {
+	tasks, err := s.taskRepo.GetPending(ctx, taskRetryBatchSize)
+	if err != nil {
+		log.Printf("Failed to load pending tasks: %v", err)
 		return
 	}
 
-	// Response will be handled by webhook callback from orchestrator
+	for _, task := range tasks {
+		if _, busy := s.inFlight.Load(task.ID); busy {
+			continue
+		}
+		go s.dispatch(context.Background(), task)
+	}
+}
+
+// RetryTask manually re-queues a failed or dead-lettered task of the office.
+// The retry worker dispatches it on its next pass.
+func (s *TaskService) RetryTask(ctx context.Context, officeID, taskID uuid.UUID) (*domain.Task, error) {
+	task, err := s.GetOfficeTask(ctx, officeID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != domain.TaskStatusFailed && task.Status != domain.TaskStatusDeadLetter {
+		return nil, fmt.Errorf("%w: only failed tasks can be retried", domain.ErrInvalidInput)
+	}
+	if err := s.subscriptionService.RequireActive(ctx, officeID); err != nil {
+		return nil, err
+	}
+
+	if err := s.setStatus(ctx, task, domain.TaskStatusPending, "", ""); err != nil {
+		return nil, err
+	}
+	task.NextAttemptAt = nil
+	return task, nil
+}
+
+// RequeueInFlight marks tasks still being dispatched by this instance as
+// pending so they are picked up again after a restart. It is called during
+// shutdown, once the HTTP server has drained.
+func (s *TaskService) RequeueInFlight(ctx context.Context) (int, error) {
+	var ids []uuid.UUID
+	s.inFlight.Range(func(key, _ any) bool {
+		ids = append(ids, key.(uuid.UUID))
+		return true
+	})
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.taskRepo.RequeueInProgress(ctx, ids, "interrupted by server shutdown")
 }
 
 // HandleOrchestratorCallback handles the callback from the orchestrator
@@ -141,5 +599,5 @@ func (s *TaskService) HandleOrchestratorCallback(ctx context.Context, taskID uui
 		status = domain.TaskStatusFailed
 	}
 
-	return s.taskRepo.UpdateStatus(ctx, taskID, status, output, errMsg)
+	return s.UpdateTaskStatus(ctx, taskID, status, output, errMsg)
 }
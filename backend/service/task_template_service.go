@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTaskTemplateNameLength        = 255
+	maxTaskTemplateDescriptionLength = 1000
+	maxTaskTemplateBodyLength        = 8000
+	maxTaskTemplatePlaceholders      = 20
+)
+
+// placeholderPattern matches a {{placeholder}} of a task template body;
+// spaces inside the braces are ignored
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// TaskTemplateService manages an office's task templates: reusable task
+// input with {{placeholders}}, filled in to dispatch to an agent right away
+// or on a schedule. Schedules keep the input they were filled in with.
+type TaskTemplateService struct {
+	templateRepo     domain.TaskTemplateRepository
+	scheduleRepo     domain.ScheduledTaskRepository
+	agentRepo        domain.AgentRepository
+	conversationRepo domain.ConversationRepository
+	taskService      *TaskService
+	scheduleService  *ScheduleService
+}
+
+// NewTaskTemplateService creates a new TaskTemplateService instance
+func NewTaskTemplateService(
+	templateRepo domain.TaskTemplateRepository,
+	scheduleRepo domain.ScheduledTaskRepository,
+	agentRepo domain.AgentRepository,
+	conversationRepo domain.ConversationRepository,
+	taskService *TaskService,
+	scheduleService *ScheduleService,
+) *TaskTemplateService {
+	return &TaskTemplateService{
+		templateRepo:     templateRepo,
+		scheduleRepo:     scheduleRepo,
+		agentRepo:        agentRepo,
+		conversationRepo: conversationRepo,
+		taskService:      taskService,
+		scheduleService:  scheduleService,
+	}
+}
+
+// CreateTaskTemplateInput contains input for creating a task template
+type CreateTaskTemplateInput struct {
+	OfficeID    uuid.UUID
+	Name        string
+	Description string
+	Body        string
+	Defaults    map[string]string
+}
+
+// UpdateTaskTemplateInput contains changes to a task template. Nil fields
+// are left as is; Defaults replaces all of the template's defaults.
+type UpdateTaskTemplateInput struct {
+	OfficeID    uuid.UUID
+	TemplateID  uuid.UUID
+	Name        *string
+	Description *string
+	Body        *string
+	Defaults    map[string]string
+}
+
+// CreateTemplate creates a task template for the office
+func (s *TaskTemplateService) CreateTemplate(ctx context.Context, input CreateTaskTemplateInput) (*domain.TaskTemplate, error) {
+	now := time.Now()
+	template := &domain.TaskTemplate{
+		ID:          uuid.New(),
+		OfficeID:    input.OfficeID,
+		Name:        strings.TrimSpace(input.Name),
+		Description: strings.TrimSpace(input.Description),
+		Body:        strings.TrimSpace(input.Body),
+		Defaults:    input.Defaults,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := prepareTaskTemplate(template); err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.Create(ctx, template); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the office already has a task template named %q", domain.ErrAlreadyExists, template.Name)
+		}
+		return nil, err
+	}
+	return template, nil
+}
+
+// GetTemplates returns the office's task templates by name
+func (s *TaskTemplateService) GetTemplates(ctx context.Context, officeID uuid.UUID) ([]*domain.TaskTemplate, error) {
+	templates, err := s.templateRepo.GetByOfficeID(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		template.Placeholders = templatePlaceholders(template.Body)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a task template of the office
+func (s *TaskTemplateService) GetTemplate(ctx context.Context, officeID, templateID uuid.UUID) (*domain.TaskTemplate, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureOffice(template.OfficeID, officeID); err != nil {
+		return nil, err
+	}
+	template.Placeholders = templatePlaceholders(template.Body)
+	return template, nil
+}
+
+// UpdateTemplate edits a task template of the office. Schedules filled in
+// from it keep their input.
+func (s *TaskTemplateService) UpdateTemplate(ctx context.Context, input UpdateTaskTemplateInput) (*domain.TaskTemplate, error) {
+	template, err := s.GetTemplate(ctx, input.OfficeID, input.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		template.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		template.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Body != nil {
+		template.Body = strings.TrimSpace(*input.Body)
+	}
+	if input.Defaults != nil {
+		template.Defaults = input.Defaults
+	} else if input.Body != nil {
+		// Defaults of placeholders the new body no longer has are dropped
+		for name := range template.Defaults {
+			if !slices.Contains(templatePlaceholders(template.Body), name) {
+				delete(template.Defaults, name)
+			}
+		}
+	}
+	template.UpdatedAt = time.Now()
+	if err := prepareTaskTemplate(template); err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.Update(ctx, template); err != nil {
+		if errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, fmt.Errorf("%w: the office already has a task template named %q", domain.ErrAlreadyExists, template.Name)
+		}
+		return nil, err
+	}
+	return template, nil
+}
+
+// DeleteTemplate removes a task template of the office. Schedules filled in
+// from it keep running with their input.
+func (s *TaskTemplateService) DeleteTemplate(ctx context.Context, officeID, templateID uuid.UUID) error {
+	if _, err := s.GetTemplate(ctx, officeID, templateID); err != nil {
+		return err
+	}
+	return s.templateRepo.Delete(ctx, templateID)
+}
+
+// RunTaskTemplateInput contains input for dispatching a task template to an
+// agent. Values fill in the template's placeholders by name.
+type RunTaskTemplateInput struct {
+	OfficeID       uuid.UUID
+	TemplateID     uuid.UUID
+	AgentID        uuid.UUID
+	ConversationID uuid.UUID
+	Values         map[string]string
+}
+
+// RunTemplate fills in a task template and dispatches it as a task to an
+// active agent of the office, in a conversation the agent takes part in
+func (s *TaskTemplateService) RunTemplate(ctx context.Context, input RunTaskTemplateInput) (*domain.Task, error) {
+	template, err := s.GetTemplate(ctx, input.OfficeID, input.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	taskInput, err := fillTaskTemplate(template, input.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !agent.IsActive) {
+		return nil, fmt.Errorf("%w: agent %s is not an active agent of this office", domain.ErrInvalidInput, input.AgentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAgentConversation(ctx, s.conversationRepo, input.OfficeID, agent.ID, input.ConversationID); err != nil {
+		return nil, err
+	}
+
+	return s.taskService.CreateTask(ctx, CreateTaskInput{
+		OfficeID:       input.OfficeID,
+		ConversationID: input.ConversationID,
+		AgentID:        agent.ID,
+		Input:          taskInput,
+	})
+}
+
+// ScheduleTaskTemplateInput contains input for scheduling a task template.
+// Exactly one of CronExpression and RunAt must be set; Name defaults to the
+// template's.
+type ScheduleTaskTemplateInput struct {
+	RunTaskTemplateInput
+	Name           string
+	CronExpression string
+	RunAt          *time.Time
+	Timezone       string
+}
+
+// ScheduleTemplate fills in a task template and schedules it for an agent,
+// once or recurring
+func (s *TaskTemplateService) ScheduleTemplate(ctx context.Context, input ScheduleTaskTemplateInput) (*domain.ScheduledTask, error) {
+	template, err := s.GetTemplate(ctx, input.OfficeID, input.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	taskInput, err := fillTaskTemplate(template, input.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	name := input.Name
+	if strings.TrimSpace(name) == "" {
+		name = template.Name
+	}
+	return s.scheduleService.CreateSchedule(ctx, CreateScheduleInput{
+		OfficeID:       input.OfficeID,
+		AgentID:        input.AgentID,
+		ConversationID: input.ConversationID,
+		Name:           name,
+		Input:          taskInput,
+		CronExpression: input.CronExpression,
+		RunAt:          input.RunAt,
+		Timezone:       input.Timezone,
+		TaskTemplateID: &template.ID,
+	})
+}
+
+// GetTemplateSchedules returns the schedules filled in from a task template
+// of the office, newest first
+func (s *TaskTemplateService) GetTemplateSchedules(ctx context.Context, officeID, templateID uuid.UUID) ([]*domain.ScheduledTask, error) {
+	if _, err := s.GetTemplate(ctx, officeID, templateID); err != nil {
+		return nil, err
+	}
+	return s.scheduleRepo.GetByTaskTemplateID(ctx, templateID)
+}
+
+// prepareTaskTemplate validates a task template and sets its placeholders.
+// Defaults must be for placeholders of the body.
+func prepareTaskTemplate(template *domain.TaskTemplate) error {
+	if template.Name == "" || utf8.RuneCountInString(template.Name) > maxTaskTemplateNameLength {
+		return fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxTaskTemplateNameLength)
+	}
+	if utf8.RuneCountInString(template.Description) > maxTaskTemplateDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", domain.ErrInvalidInput, maxTaskTemplateDescriptionLength)
+	}
+	if template.Body == "" || utf8.RuneCountInString(template.Body) > maxTaskTemplateBodyLength {
+		return fmt.Errorf("%w: body is required and must be at most %d characters", domain.ErrInvalidInput, maxTaskTemplateBodyLength)
+	}
+
+	template.Placeholders = templatePlaceholders(template.Body)
+	if len(template.Placeholders) > maxTaskTemplatePlaceholders {
+		return fmt.Errorf("%w: a template can have at most %d placeholders", domain.ErrInvalidInput, maxTaskTemplatePlaceholders)
+	}
+	for _, name := range slices.Sorted(maps.Keys(template.Defaults)) {
+		if !slices.Contains(template.Placeholders, name) {
+			return fmt.Errorf("%w: the body has no placeholder {{%s}} to default", domain.ErrInvalidInput, name)
+		}
+	}
+	if template.Defaults == nil {
+		template.Defaults = map[string]string{}
+	}
+	return nil
+}
+
+// templatePlaceholders returns the names of the placeholders in a task
+// template body, in the order they first appear
+func templatePlaceholders(body string) []string {
+	names := []string{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// fillTaskTemplate replaces a template's placeholders with values, or else
+// their defaults. Values for placeholders the template does not have, and
+// placeholders left without a value, are invalid input.
+func fillTaskTemplate(template *domain.TaskTemplate, values map[string]string) (string, error) {
+	placeholders := templatePlaceholders(template.Body)
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if !slices.Contains(placeholders, name) {
+			return "", fmt.Errorf("%w: the template has no placeholder {{%s}}", domain.ErrInvalidInput, name)
+		}
+	}
+
+	filled := make(map[string]string, len(placeholders))
+	var missing []string
+	for _, name := range placeholders {
+		value := strings.TrimSpace(values[name])
+		if value == "" {
+			value = template.Defaults[name]
+		}
+		if value == "" {
+			missing = append(missing, name)
+		}
+		filled[name] = value
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: values are required for %s", domain.ErrInvalidInput, strings.Join(missing, ", "))
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(template.Body, func(placeholder string) string {
+		return filled[placeholderPattern.FindStringSubmatch(placeholder)[1]]
+	}), nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+func TestFillTaskTemplate(t *testing.T) {
+	template := &domain.TaskTemplate{
+		Body:     "Write the {{ period }} status report for {{team}}. Keep it under {{words}} words; {{team}} reads it.",
+		Defaults: map[string]string{"words": "300"},
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"fills values and defaults", map[string]string{"period": "weekly", "team": "Platform"},
+			"Write the weekly status report for Platform. Keep it under 300 words; Platform reads it.", false},
+		{"values replace defaults", map[string]string{"period": "monthly", "team": "Ops", "words": "500"},
+			"Write the monthly status report for Ops. Keep it under 500 words; Ops reads it.", false},
+		{"requires placeholders without a default", map[string]string{"period": "weekly", "team": " "}, "", true},
+		{"rejects unknown placeholders", map[string]string{"period": "weekly", "team": "Ops", "tone": "dry"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fillTaskTemplate(template, tt.values)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("fillTaskTemplate error = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("fillTaskTemplate = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrepareTaskTemplate(t *testing.T) {
+	template := &domain.TaskTemplate{Name: "Blog post", Body: "Draft a post about {{topic}} for {{audience}} about {{topic}}"}
+	if err := prepareTaskTemplate(template); err != nil {
+		t.Fatalf("prepareTaskTemplate: %v", err)
+	}
+	if !slices.Equal(template.Placeholders, []string{"topic", "audience"}) {
+		t.Errorf("placeholders = %v, want [topic audience]", template.Placeholders)
+	}
+
+	template.Defaults = map[string]string{"length": "short"}
+	if err := prepareTaskTemplate(template); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("default for a missing placeholder error = %v, want ErrInvalidInput", err)
+	}
+}
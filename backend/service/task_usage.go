@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// RecordUsageInput contains what the orchestrator reports a finished task
+// cost
+type RecordUsageInput struct {
+	TaskID uuid.UUID
+	// Attempt is the dispatch attempt that ran the task. With the task ID it
+	// keys the charge, so a repeated report does not charge twice; zero uses
+	// the task's current attempt.
+	Attempt   int
+	AgentRole string
+	Usage     domain.TaskUsage
+}
+
+// RecordUsage charges the office for a finished task, then stores the usage
+// on the task and adds it to the office's usage analytics. The office is
+// alerted if the charge crossed a budget threshold or left it low on
+// credits. It returns the credit transaction, or nil if the task cost no
+// credits; nothing is recorded if the charge fails. Tasks cancelled before
+// they finished are not charged: their credits were refunded on cancellation.
+func (s *TaskService) RecordUsage(ctx context.Context, input RecordUsageInput) (*domain.CreditTransaction, error) {
+	task, err := s.taskRepo.GetByID(ctx, input.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == domain.TaskStatusCancelled {
+		return nil, nil
+	}
+	usage := input.Usage
+	if usage.InputTokens < 0 || usage.OutputTokens < 0 || usage.Credits < 0 || usage.LatencyMs < 0 {
+		return nil, fmt.Errorf("%w: usage must not be negative", domain.ErrInvalidInput)
+	}
+
+	var tx *domain.CreditTransaction
+	if usage.Credits > 0 {
+		attempt := input.Attempt
+		if attempt <= 0 {
+			attempt = task.Attempts
+		}
+		tx, err = s.creditService.ConsumeCreditsForTask(ctx, task.OfficeID, task.ID, usage.Credits,
+			fmt.Sprintf("Task execution using %s", usage.Model), fmt.Sprintf("%s:%d", task.ID, attempt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to charge credits: %w", err)
+		}
+
+		if _, err := s.creditService.CheckBudgetAlerts(ctx, task.OfficeID, usage.Credits); err != nil {
+			log.Printf("Budget alert check failed: %v", err)
+		}
+		if _, err := s.creditService.CheckLowBalance(ctx, task.OfficeID, tx.BalanceAfter, usage.Credits); err != nil {
+			log.Printf("Low credit check failed: %v", err)
+		}
+	}
+
+	if err := s.taskRepo.RecordUsage(ctx, task.ID, usage); err != nil {
+		return tx, fmt.Errorf("failed to store task usage: %w", err)
+	}
+
+	err = s.analytics.RecordTaskUsage(ctx, task.OfficeID, task.AgentID, input.AgentRole, usage.Model, usage.Provider,
+		int(usage.Credits), usage.InputTokens, usage.OutputTokens, usage.IsLocalModel, usage.USDCost, true)
+	if err != nil {
+		return tx, fmt.Errorf("failed to record usage analytics: %w", err)
+	}
+	return tx, nil
+}
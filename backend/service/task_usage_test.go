@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCompletionAfterCancellationIsNotChargedOrFollowedUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := mocks.NewMockTaskRepository(ctrl)
+	// No credit service, analytics or conversations: nothing may be charged,
+	// recorded or delegated
+	svc := NewTaskService(tasks, nil, nil, nil, nil, nil, nil, "", DelegationConfig{})
+	chat := &ChatService{taskService: svc}
+
+	task := &domain.Task{
+		ID: uuid.New(), OfficeID: uuid.New(), AgentID: uuid.New(), ConversationID: uuid.New(),
+		MessageID: uuid.New(), Status: domain.TaskStatusCancelled, Attempts: 1,
+	}
+	tasks.EXPECT().GetByID(gomock.Any(), task.ID).Return(task, nil).Times(2)
+
+	tx, err := svc.RecordUsage(context.Background(), RecordUsageInput{
+		TaskID: task.ID, Attempt: 1, Usage: domain.TaskUsage{Model: "gpt-4o", InputTokens: 900, OutputTokens: 300, Credits: 12},
+	})
+	if err != nil || tx != nil {
+		t.Errorf("RecordUsage of a cancelled task = %v, %v; want no charge", tx, err)
+	}
+	if err := chat.handleAgentReply(context.Background(), task.ID, nil); err != nil {
+		t.Errorf("handleAgentReply of a cancelled task: %v", err)
+	}
+}
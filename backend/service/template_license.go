@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxPersonalLicenseSeats is the most seats an office's tier may have
+	// for the office to use templates under a personal license; offices on
+	// larger tiers need templates with a team license
+	MaxPersonalLicenseSeats = 1
+	// MaxLicenseTermsLength caps the size of a template's license text
+	MaxLicenseTermsLength = 20000
+)
+
+// validateLicense checks a template's license, defaulting a missing type to
+// the team license
+func validateLicense(license *domain.TemplateLicense) error {
+	license.Terms = strings.TrimSpace(license.Terms)
+	if license.Type == "" {
+		license.Type = domain.LicenseTeam
+	}
+	switch {
+	case license.Type != domain.LicensePersonal && license.Type != domain.LicenseTeam:
+		return fmt.Errorf("%w: license type must be %s or %s", domain.ErrInvalidInput, domain.LicensePersonal, domain.LicenseTeam)
+	case len(license.Terms) > MaxLicenseTermsLength:
+		return fmt.Errorf("%w: license terms must be at most %d characters", domain.ErrInvalidInput, MaxLicenseTermsLength)
+	}
+	return nil
+}
+
+// requireTemplateLicense returns ErrLicenseRequired, with the license and
+// the office's seats as details, when the template's license does not cover
+// the office. An office's seats are those its tier allows.
+func requireTemplateLicense(ctx context.Context, subscriptionService *SubscriptionService, officeID uuid.UUID, template *domain.AgentTemplate) error {
+	if template.License.Type != domain.LicensePersonal {
+		return nil
+	}
+	features, err := subscriptionService.GetOfficeFeatures(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	if seats := features.MaxSeats; seats == -1 || seats > MaxPersonalLicenseSeats {
+		return domain.WithDetails(
+			fmt.Errorf("%w: %s has a personal license, which covers offices with at most %d seats",
+				domain.ErrLicenseRequired, template.Name, MaxPersonalLicenseSeats),
+			map[string]any{"license": template.License.Type, "seats": seats, "max_seats": MaxPersonalLicenseSeats},
+		)
+	}
+	return nil
+}
+
+// newLicenseAcceptance records the template's current license as accepted
+func newLicenseAcceptance(template *domain.AgentTemplate, now time.Time) *domain.LicenseAcceptance {
+	return &domain.LicenseAcceptance{
+		License:    template.License,
+		Version:    template.Version,
+		AcceptedAt: now,
+	}
+}
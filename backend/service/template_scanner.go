@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// Categories of template scan findings
+const (
+	riskExfiltration       = "exfiltration"
+	riskToolAbuse          = "tool_abuse"
+	riskHiddenInstructions = "hidden_instructions"
+)
+
+// maxRiskScore caps a template's risk score
+const maxRiskScore = 100
+
+// findingExcerptLength caps how many characters of a match a finding keeps
+const findingExcerptLength = 120
+
+// templateRule is a pattern of risky instructions in a template. A rule
+// counts once however often it matches.
+type templateRule struct {
+	id       string
+	category string
+	weight   int
+	pattern  *regexp.Regexp
+}
+
+// templateRules are the patterns the template scanner looks for. Templates
+// run with their buyers' conversations, memories and tools, so the rules
+// look for prompts that try to send that data out, misuse tools or hide
+// what the agent is told from the office.
+var templateRules = []templateRule{
+	{"send_data_out", riskExfiltration, 40, regexp.MustCompile(
+		`(?i)\b(send|post|upload|forward|transmit|leak|exfiltrate|copy)\b[^.\n]{0,80}` +
+			`\b(conversations?|chat history|messages|secrets?|api[ _-]?keys?|credentials?|passwords?|tokens?|environment variables|system prompt|user data|personal data)\b` +
+			`[^.\n]{0,80}\b(to|into)\b[^.\n]{0,40}(https?://|webhook|e-?mail|server|endpoint|url)`)},
+	{"data_in_url", riskExfiltration, 30, regexp.MustCompile(
+		`(?i)!\[[^\]]*\]\(https?://[^)\s]*\?[^)\s]*=|https?://\S*(\{\{|\$\{|\{[a-z_]+\})`)},
+	{"reveal_secrets", riskExfiltration, 25, regexp.MustCompile(
+		`(?i)\b(reveal|print|output|show|list|dump)\b[^.\n]{0,40}\b(api[ _-]?keys?|secrets?|credentials?|passwords?|access tokens?|environment variables)\b`)},
+	{"pipe_to_shell", riskToolAbuse, 40, regexp.MustCompile(
+		`(?i)\b(curl|wget)\b[^\n|]{0,200}\|\s*(ba|z)?sh\b`)},
+	{"destructive_command", riskToolAbuse, 30, regexp.MustCompile(
+		`(?i)\brm\s+-rf\b|\bdrop\s+(table|database)\b|\bdelete\s+(all|every)\s+(files|data|records|rows)\b`)},
+	{"arbitrary_execution", riskToolAbuse, 30, regexp.MustCompile(
+		`(?i)\b(execute|run|eval)\b[^.\n]{0,30}\b(any|arbitrary|all)\b[^.\n]{0,20}\b(commands?|code|scripts?|shell)\b`)},
+	{"disable_safety", riskToolAbuse, 30, regexp.MustCompile(
+		`(?i)\b(disable|bypass|ignore|turn off|circumvent)\b[^.\n]{0,30}\b(safety|guardrails?|moderation|content (policy|filters?)|restrictions)\b`)},
+	{"skip_confirmation", riskToolAbuse, 20, regexp.MustCompile(
+		`(?i)\bwithout\b[^.\n]{0,20}\b(asking|telling|informing|notifying|confirmation|consent|permission)\b`)},
+	{"override_instructions", riskHiddenInstructions, 30, regexp.MustCompile(
+		`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,30}\b(previous|prior|above|earlier|all|system|original)\b[^.\n]{0,20}\b(instructions?|prompts?|rules|guidelines)\b`)},
+	{"conceal_from_user", riskHiddenInstructions, 15, regexp.MustCompile(
+		`(?i)\b(do not|don't|never)\b[^.\n]{0,20}\b(tell|reveal|mention|disclose|inform|let)\b[^.\n]{0,30}\b(user|customer|office|anyone)\b`)},
+	{"invisible_characters", riskHiddenInstructions, 40, regexp.MustCompile(
+		`[\x{200B}-\x{200F}\x{202A}-\x{202E}\x{2060}-\x{2064}\x{2066}-\x{2069}\x{FEFF}\x{E0000}-\x{E007F}]`)},
+	{"html_comment", riskHiddenInstructions, 20, regexp.MustCompile(`<!--[\s\S]*?-->`)},
+	{"encoded_payload", riskHiddenInstructions, 20, regexp.MustCompile(`[A-Za-z0-9+/]{120,}={0,2}`)},
+}
+
+// scanTemplateRisk scans the author-written text of a template for risky
+// instructions and returns its risk score, the sum of the weights of the
+// rules it matched up to maxRiskScore, with what each rule found
+func scanTemplateRisk(t *domain.AgentTemplate) (int, []domain.TemplateFinding) {
+	text := strings.Join([]string{t.Name, t.Role, t.Description, t.SystemPrompt}, "\n\n")
+
+	var score int
+	var findings []domain.TemplateFinding
+	for _, rule := range templateRules {
+		match := rule.pattern.FindString(text)
+		if match == "" {
+			continue
+		}
+		score += rule.weight
+		findings = append(findings, domain.TemplateFinding{
+			Rule:     rule.id,
+			Category: rule.category,
+			Excerpt:  findingExcerpt(match),
+		})
+	}
+	return min(score, maxRiskScore), findings
+}
+
+// findingExcerpt shortens a match for review. Invisible characters are
+// shown by their code point, or the excerpt could look empty.
+func findingExcerpt(match string) string {
+	if utf8.RuneCountInString(match) > findingExcerptLength {
+		match = string([]rune(match)[:findingExcerptLength]) + "…"
+	}
+	var b strings.Builder
+	for _, r := range match {
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "%U", r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxViewedTemplates caps how many templates one report of impressions
+	// may list, a page of marketplace results at most
+	maxViewedTemplates = 100
+	// templateVisitorPurgeInterval is how often the visitors of past days
+	// are forgotten
+	templateVisitorPurgeInterval = time.Hour
+)
+
+// TemplateViewService counts marketplace impressions and views of templates
+// and reports to authors how they turn into hires and sales. Impressions and
+// views are days in UTC, as the marketplace is not any office's.
+type TemplateViewService struct {
+	viewRepo domain.TemplateViewRepository
+}
+
+// NewTemplateViewService creates a new TemplateViewService instance
+func NewTemplateViewService(viewRepo domain.TemplateViewRepository) *TemplateViewService {
+	return &TemplateViewService{viewRepo: viewRepo}
+}
+
+// Run forgets the visitors of past days every hour until ctx is cancelled.
+// They are only needed to count each visitor once on their day.
+func (s *TemplateViewService) Run(ctx context.Context) {
+	ticker := time.NewTicker(templateVisitorPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.viewRepo.PurgeVisitors(ctx, utcToday(time.Now())); err != nil {
+				log.Printf("Failed to purge template visitors: %v", err)
+			}
+		}
+	}
+}
+
+// RecordViews counts the visitor's impressions or views of the templates,
+// each once a day. visitor identifies the signed in user or the browser's
+// session; it is only stored hashed together with the day, so visitors
+// cannot be followed from one day to the next.
+func (s *TemplateViewService) RecordViews(ctx context.Context, kind domain.TemplateViewKind, templateIDs []uuid.UUID, visitor string) error {
+	if kind != domain.TemplateImpression && kind != domain.TemplateDetailView {
+		return fmt.Errorf("%w: unknown view kind %q", domain.ErrInvalidInput, kind)
+	}
+	if visitor == "" {
+		return fmt.Errorf("%w: the visitor is unknown", domain.ErrInvalidInput)
+	}
+	if len(templateIDs) > maxViewedTemplates {
+		return fmt.Errorf("%w: at most %d templates can be reported at once", domain.ErrInvalidInput, maxViewedTemplates)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(templateIDs))
+	ids := make([]uuid.UUID, 0, len(templateIDs))
+	for _, id := range templateIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	day := utcToday(time.Now())
+	_, err := s.viewRepo.RecordViews(ctx, ids, kind, visitorHash(visitor, day), day)
+	return err
+}
+
+// GetAuthorAnalytics reports how the author's templates did over the last
+// days days up to today
+func (s *TemplateViewService) GetAuthorAnalytics(ctx context.Context, authorID uuid.UUID, days int) (*domain.TemplateAnalytics, error) {
+	dates, err := usageDates(UsagePeriod{Days: days}, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.viewRepo.GetAuthorTemplateStats(ctx, authorID, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &domain.TemplateAnalytics{
+		Days:      dates.Days(),
+		From:      dates.From.Format(usageDateLayout),
+		To:        dates.To.Format(usageDateLayout),
+		Templates: make([]domain.TemplateStats, 0, len(stats)),
+	}
+	totals := &analytics.Totals
+	for _, st := range stats {
+		setFunnelRates(&st.TemplateFunnel)
+		analytics.Templates = append(analytics.Templates, st)
+
+		totals.Impressions += st.Impressions
+		totals.Views += st.Views
+		totals.Hires += st.Hires
+		totals.Sales += st.Sales
+		totals.RevenueCents += st.RevenueCents
+	}
+	setFunnelRates(totals)
+	return analytics, nil
+}
+
+// setFunnelRates works out the funnel's view and conversion rates
+func setFunnelRates(f *domain.TemplateFunnel) {
+	f.ViewRate, f.ConversionRate = nil, nil
+	if f.Impressions > 0 {
+		rate := roundTo(float64(f.Views)/float64(f.Impressions)*100, 2)
+		f.ViewRate = &rate
+	}
+	if f.Views > 0 {
+		rate := roundTo(float64(f.Hires)/float64(f.Views)*100, 2)
+		f.ConversionRate = &rate
+	}
+}
+
+// visitorHash is how visitor is stored for day
+func visitorHash(visitor string, day time.Time) string {
+	sum := sha256.Sum256([]byte(day.Format(usageDateLayout) + "|" + visitor))
+	return hex.EncodeToString(sum[:])
+}
+
+// utcToday is the date of t in UTC, as midnight
+func utcToday(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRecordViewsCountsEachTemplateOnceWithoutTheVisitor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	views := mocks.NewMockTemplateViewRepository(ctrl)
+	svc := NewTemplateViewService(views)
+
+	a, b := uuid.New(), uuid.New()
+	views.EXPECT().RecordViews(gomock.Any(), []uuid.UUID{a, b}, domain.TemplateImpression, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ []uuid.UUID, _ domain.TemplateViewKind, hash string, _ any) (int64, error) {
+			if len(hash) != 64 || strings.Contains(hash, "session") {
+				t.Errorf("visitor hash = %q, want a sha256 hex digest", hash)
+			}
+			return 2, nil
+		})
+	if err := svc.RecordViews(context.Background(), domain.TemplateImpression, []uuid.UUID{a, b, a}, "session:abc"); err != nil {
+		t.Fatalf("RecordViews: %v", err)
+	}
+
+	tooMany := make([]uuid.UUID, maxViewedTemplates+1)
+	if err := svc.RecordViews(context.Background(), domain.TemplateImpression, tooMany, "session:abc"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("RecordViews of %d templates error = %v, want ErrInvalidInput", len(tooMany), err)
+	}
+	if err := svc.RecordViews(context.Background(), "click", []uuid.UUID{a}, "session:abc"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("RecordViews of an unknown kind error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestGetAuthorAnalyticsWorksOutRates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	views := mocks.NewMockTemplateViewRepository(ctrl)
+	svc := NewTemplateViewService(views)
+
+	authorID := uuid.New()
+	views.EXPECT().GetAuthorTemplateStats(gomock.Any(), authorID, gomock.Any()).Return([]domain.TemplateStats{
+		{Name: "Popular", TemplateFunnel: domain.TemplateFunnel{Impressions: 200, Views: 40, Hires: 10, Sales: 2, RevenueCents: 998}},
+		{Name: "Unseen"},
+	}, nil)
+
+	analytics, err := svc.GetAuthorAnalytics(context.Background(), authorID, 7)
+	if err != nil {
+		t.Fatalf("GetAuthorAnalytics: %v", err)
+	}
+	if analytics.Days != 7 || len(analytics.Templates) != 2 {
+		t.Fatalf("analytics = %+v, want 7 days of both templates", analytics)
+	}
+
+	popular, unseen := analytics.Templates[0], analytics.Templates[1]
+	if popular.ViewRate == nil || *popular.ViewRate != 20 || popular.ConversionRate == nil || *popular.ConversionRate != 25 {
+		t.Errorf("Popular rates = %v, %v; want 20%% viewed and 25%% hired", popular.ViewRate, popular.ConversionRate)
+	}
+	if unseen.ViewRate != nil || unseen.ConversionRate != nil {
+		t.Errorf("Unseen rates = %v, %v; want none", unseen.ViewRate, unseen.ConversionRate)
+	}
+	if totals := analytics.Totals; totals.Impressions != 200 || totals.Hires != 10 || totals.RevenueCents != 998 || *totals.ViewRate != 20 {
+		t.Errorf("totals = %+v, want Popular's", totals)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/pdf"
+	"github.com/google/uuid"
+)
+
+// transcriptTimeFormat is how transcripts print times; they are in UTC
+const transcriptTimeFormat = "2006-01-02 15:04 UTC"
+
+// WriteTranscriptMarkdown writes a transcript as a Markdown document. Agent
+// replies are already Markdown and are written as they are.
+func WriteTranscriptMarkdown(w io.Writer, t *domain.ConversationTranscript) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %s\n\n", transcriptTitle(t.Conversation))
+	for _, line := range transcriptSummary(t) {
+		fmt.Fprintf(b, "%s  \n", line)
+	}
+	b.WriteString("\n---\n")
+
+	for _, m := range t.Messages {
+		fmt.Fprintf(b, "\n### %s\n\n", messageHeading(m))
+		if m.IsDeleted() {
+			b.WriteString("_This message was deleted._\n")
+			continue
+		}
+		if m.Content != "" {
+			fmt.Fprintf(b, "%s\n", strings.TrimRight(m.Content, "\n"))
+		}
+		if len(m.Attachments) > 0 {
+			b.WriteString("\n**Attachments:**\n\n")
+			for _, a := range m.Attachments {
+				fmt.Fprintf(b, "- `%s` (%s, %s)\n", a.Filename, a.ContentType, formatSize(a.SizeBytes))
+			}
+		}
+		if len(m.Tasks) > 0 {
+			b.WriteString("\n**Agent tasks:**\n\n")
+			for _, line := range taskLines(t, m.Tasks) {
+				fmt.Fprintf(b, "- %s\n", line)
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// WriteTranscriptPDF writes a transcript as a PDF document
+func WriteTranscriptPDF(w io.Writer, t *domain.ConversationTranscript) error {
+	doc := pdf.New(transcriptTitle(t.Conversation))
+	doc.Paragraph(transcriptTitle(t.Conversation), pdf.Title)
+	doc.Space(4)
+	for _, line := range transcriptSummary(t) {
+		doc.Paragraph(line, pdf.Small)
+	}
+
+	for _, m := range t.Messages {
+		indent := 0.0
+		if m.ParentMessageID != nil {
+			indent = 20
+		}
+		doc.Space(12)
+		doc.Paragraph(messageHeading(m), pdf.Style{Size: pdf.Heading.Size, Bold: true, Indent: indent})
+		if m.IsDeleted() {
+			doc.Paragraph("This message was deleted.", pdf.Style{Size: pdf.Body.Size, Gray: 0.4, Indent: indent})
+			continue
+		}
+		doc.Paragraph(m.Content, pdf.Style{Size: pdf.Body.Size, Indent: indent})
+		for _, a := range m.Attachments {
+			doc.Paragraph(fmt.Sprintf("Attachment: %s (%s, %s)", a.Filename, a.ContentType, formatSize(a.SizeBytes)),
+				pdf.Style{Size: pdf.Small.Size, Gray: pdf.Small.Gray, Indent: indent})
+		}
+		for _, line := range taskLines(t, m.Tasks) {
+			doc.Paragraph("Task: "+line, pdf.Style{Size: pdf.Small.Size, Gray: pdf.Small.Gray, Indent: indent})
+		}
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// transcriptTitle names a conversation: by its name, or else by its agents
+func transcriptTitle(c *domain.Conversation) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	names := make([]string, len(c.Participants))
+	for i, agent := range c.Participants {
+		names[i] = agent.GetName()
+	}
+	if len(names) == 0 {
+		return "Conversation"
+	}
+	return "Conversation with " + strings.Join(names, ", ")
+}
+
+// transcriptSummary is the lines printed under a transcript's title
+func transcriptSummary(t *domain.ConversationTranscript) []string {
+	count := fmt.Sprintf("%d messages", len(t.Messages))
+	if len(t.Messages) == 1 {
+		count = "1 message"
+	}
+	lines := []string{"Exported " + t.ExportedAt.UTC().Format(transcriptTimeFormat) + ", " + count}
+	if len(t.Conversation.Participants) > 0 {
+		agents := make([]string, len(t.Conversation.Participants))
+		for i, agent := range t.Conversation.Participants {
+			agents[i] = agent.GetName()
+			if agent.Template != nil && agent.Template.Role != "" {
+				agents[i] += " (" + agent.Template.Role + ")"
+			}
+		}
+		lines = append(lines, "Agents: "+strings.Join(agents, ", "))
+	}
+	if t.Conversation.IsArchived() {
+		lines = append(lines, "Archived "+t.Conversation.ArchivedAt.UTC().Format(transcriptTimeFormat))
+	}
+	if t.Truncated {
+		lines = append(lines, fmt.Sprintf("Only the first %d messages are included", len(t.Messages)))
+	}
+	return lines
+}
+
+// messageHeading is a message's sender and time, marking thread replies
+// and edits
+func messageHeading(m *domain.TranscriptMessage) string {
+	heading := m.SenderName + " - " + m.CreatedAt.UTC().Format(transcriptTimeFormat)
+	if m.ParentMessageID != nil {
+		heading = "Reply: " + heading
+	}
+	if m.EditedAt != nil {
+		heading += " (edited)"
+	}
+	return heading
+}
+
+// taskLines describes the agent tasks a message started. The output of a
+// finished task is the agent's reply, so only failures are spelled out.
+func taskLines(t *domain.ConversationTranscript, tasks []*domain.Task) []string {
+	lines := make([]string, len(tasks))
+	for i, task := range tasks {
+		line := agentName(t, task.AgentID) + ": " + string(task.Status)
+		if task.CompletedAt != nil {
+			line += ", " + task.CompletedAt.UTC().Format(transcriptTimeFormat) +
+				" after " + task.CompletedAt.Sub(task.CreatedAt).Round(time.Second).String()
+		}
+		if task.Error != "" {
+			line += " - " + task.Error
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// agentName finds an agent's name among the transcript's participants and
+// senders
+func agentName(t *domain.ConversationTranscript, agentID uuid.UUID) string {
+	for _, agent := range t.Conversation.Participants {
+		if agent.ID == agentID {
+			return agent.GetName()
+		}
+	}
+	for _, m := range t.Messages {
+		if m.SenderType == domain.SenderTypeAgent && m.SenderID == agentID {
+			return m.SenderName
+		}
+	}
+	return "Removed agent"
+}
+
+// formatSize prints a byte count in B, KB or MB
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
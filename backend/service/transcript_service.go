@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxTranscriptMessages bounds the messages, and separately the tasks,
+	// loaded for one export
+	maxTranscriptMessages = 10000
+	// transcriptPageSize is how many rows are loaded per query
+	transcriptPageSize = 500
+)
+
+// TranscriptService builds conversation transcripts for export
+type TranscriptService struct {
+	conversationRepo  domain.ConversationRepository
+	messageRepo       domain.MessageRepository
+	taskRepo          domain.TaskRepository
+	agentRepo         domain.AgentRepository
+	userRepo          domain.UserRepository
+	attachmentService *AttachmentService
+}
+
+// NewTranscriptService creates a new TranscriptService instance
+func NewTranscriptService(
+	conversationRepo domain.ConversationRepository,
+	messageRepo domain.MessageRepository,
+	taskRepo domain.TaskRepository,
+	agentRepo domain.AgentRepository,
+	userRepo domain.UserRepository,
+	attachmentService *AttachmentService,
+) *TranscriptService {
+	return &TranscriptService{
+		conversationRepo:  conversationRepo,
+		messageRepo:       messageRepo,
+		taskRepo:          taskRepo,
+		agentRepo:         agentRepo,
+		userRepo:          userRepo,
+		attachmentService: attachmentService,
+	}
+}
+
+// GetTranscript loads the whole history of a conversation of the office:
+// every message with its sender's name, reactions and attachments, and the
+// agent tasks each message started
+func (s *TranscriptService) GetTranscript(ctx context.Context, officeID, conversationID uuid.UUID) (*domain.ConversationTranscript, error) {
+	conversation, err := officeConversation(ctx, s.conversationRepo, officeID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	participants, err := s.conversationRepo.GetParticipants(ctx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Participants = participants
+
+	messages, truncated, err := s.messages(ctx, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.tasks(ctx, officeID, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	reactions, err := s.messageRepo.GetReactions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.attachmentService.GetForMessages(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := newSenderNames(s.agentRepo, s.userRepo, participants)
+	transcript := &domain.ConversationTranscript{
+		Conversation: conversation,
+		Messages:     make([]*domain.TranscriptMessage, len(messages)),
+		Truncated:    truncated,
+		ExportedAt:   time.Now(),
+	}
+	for i, message := range messages {
+		message.Reactions = reactions[message.ID]
+		message.Attachments = attachments[message.ID]
+		name, err := names.get(ctx, message.SenderType, message.SenderID)
+		if err != nil {
+			return nil, err
+		}
+		transcript.Messages[i] = &domain.TranscriptMessage{
+			Message:    message,
+			SenderName: name,
+			Tasks:      tasks[message.ID],
+		}
+	}
+	return transcript, nil
+}
+
+// messages loads a conversation's messages, oldest first, up to
+// maxTranscriptMessages. It reports whether there were more.
+func (s *TranscriptService) messages(ctx context.Context, conversationID uuid.UUID) ([]*domain.Message, bool, error) {
+	var messages []*domain.Message
+	page := domain.PageRequest{Limit: transcriptPageSize}
+	for len(messages) < maxTranscriptMessages {
+		batch, err := s.messageRepo.GetByConversationID(ctx, conversationID, page)
+		if err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, batch...)
+		if len(batch) < page.Limit {
+			return messages, false, nil
+		}
+		last := batch[len(batch)-1]
+		page.Cursor = &domain.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	total, err := s.messageRepo.CountByConversationID(ctx, conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+	return messages[:maxTranscriptMessages], total > maxTranscriptMessages, nil
+}
+
+// tasks loads a conversation's tasks grouped by the message that started
+// them, oldest first. Tasks without a message, such as scheduled ones, are
+// left out; their output is in the agent's reply.
+func (s *TranscriptService) tasks(ctx context.Context, officeID, conversationID uuid.UUID) (map[uuid.UUID][]*domain.Task, error) {
+	filter := domain.TaskFilter{OfficeID: officeID, ConversationID: conversationID}
+	page := domain.PageRequest{Limit: transcriptPageSize}
+
+	// Tasks are listed newest first
+	var all []*domain.Task
+	for len(all) < maxTranscriptMessages {
+		batch, err := s.taskRepo.List(ctx, filter, page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, batch...)
+		if len(batch) < page.Limit {
+			break
+		}
+		last := batch[len(batch)-1]
+		page.Cursor = &domain.PageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	byMessage := make(map[uuid.UUID][]*domain.Task)
+	for i := len(all) - 1; i >= 0; i-- {
+		if task := all[i]; task.MessageID != uuid.Nil {
+			byMessage[task.MessageID] = append(byMessage[task.MessageID], task)
+		}
+	}
+	return byMessage, nil
+}
+
+// senderNames looks up and caches the display names of message senders
+type senderNames struct {
+	agentRepo domain.AgentRepository
+	userRepo  domain.UserRepository
+	names     map[uuid.UUID]string
+}
+
+func newSenderNames(agentRepo domain.AgentRepository, userRepo domain.UserRepository, participants []*domain.Agent) *senderNames {
+	n := &senderNames{agentRepo: agentRepo, userRepo: userRepo, names: make(map[uuid.UUID]string)}
+	for _, agent := range participants {
+		n.names[agent.ID] = agent.GetName()
+	}
+	return n
+}
+
+// get returns a sender's name. Widget visitors, summaries, agents removed
+// since and deleted users get a placeholder.
+func (n *senderNames) get(ctx context.Context, senderType domain.SenderType, senderID uuid.UUID) (string, error) {
+	if name, ok := n.names[senderID]; ok {
+		return name, nil
+	}
+
+	var name string
+	var err error
+	switch senderType {
+	case domain.SenderTypeAgent:
+		var agent *domain.Agent
+		if agent, err = n.agentRepo.GetByID(ctx, senderID); err == nil {
+			name = agent.GetName()
+		} else {
+			name = "Removed agent"
+		}
+	case domain.SenderTypeVisitor:
+		name = "Website visitor"
+	case domain.SenderTypeSystem:
+		name = summarySenderName
+	default:
+		var user *domain.User
+		if user, err = n.userRepo.GetByID(ctx, senderID); err == nil {
+			name = user.Name
+		} else {
+			name = "Deleted user"
+		}
+	}
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return "", err
+	}
+	n.names[senderID] = name
+	return name, nil
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxWebSearchesPerTask caps what an office may allow a task
+	maxWebSearchesPerTask = 50
+	maxAllowedDomains     = 100
+	maxWebSearchQueryLen  = 500
+	maxWebSearchResults   = 50
+	maxWebSearchProvider  = 50
+	// webSearchCachePurgeInterval is how often expired results are deleted
+	// from caches that keep them
+	webSearchCachePurgeInterval = time.Hour
+)
+
+// domainNamePattern is the form of allowed domains once normalized
+var domainNamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// webSearchNamespace derives the IDs of searches logged with an
+// idempotency key, so retries are recorded once
+var webSearchNamespace = uuid.MustParse("3c8f3a57-5d0e-4b89-9a4e-6f1f2f0b7d21")
+
+// WebResearchConfig is how web research is metered
+type WebResearchConfig struct {
+	// SearchCredits is what a search not answered from the cache costs
+	SearchCredits int64
+	// DefaultMaxSearches is how many searches a task may run when its
+	// office did not choose
+	DefaultMaxSearches int
+	// CacheTTL is how long search results are reused
+	CacheTTL time.Duration
+}
+
+// WebResearchService holds offices' web research settings and meters the
+// searches the orchestrator runs for their tasks. Results are cached by
+// query for all offices, so repeated research is answered without
+// searching or charging again.
+type WebResearchService struct {
+	researchRepo        domain.WebResearchRepository
+	cache               domain.WebSearchCache
+	taskRepo            domain.TaskRepository
+	creditService       *CreditService
+	subscriptionService *SubscriptionService
+	config              WebResearchConfig
+}
+
+// NewWebResearchService creates a new WebResearchService instance
+func NewWebResearchService(
+	researchRepo domain.WebResearchRepository,
+	cache domain.WebSearchCache,
+	taskRepo domain.TaskRepository,
+	creditService *CreditService,
+	subscriptionService *SubscriptionService,
+	config WebResearchConfig,
+) *WebResearchService {
+	return &WebResearchService{
+		researchRepo:        researchRepo,
+		cache:               cache,
+		taskRepo:            taskRepo,
+		creditService:       creditService,
+		subscriptionService: subscriptionService,
+		config:              config,
+	}
+}
+
+// UpdateWebResearchSettingsInput contains the changeable web research
+// settings. Nil fields are left unchanged; an empty AllowedDomains allows
+// every domain.
+type UpdateWebResearchSettingsInput struct {
+	AllowedDomains     []string
+	MaxSearchesPerTask *int
+}
+
+// WebResearchLookup answers the orchestrator before it searches: with the
+// cached results if the query was searched recently, and otherwise with
+// how many searches the task has left
+type WebResearchLookup struct {
+	Cached         bool                     `json:"cached"`
+	Results        []domain.WebSearchResult `json:"results,omitempty"`
+	AllowedDomains []string                 `json:"allowed_domains"`
+	SearchesUsed   int                      `json:"searches_used"`
+	SearchesLeft   int                      `json:"searches_left"`
+	SearchCredits  int64                    `json:"search_credits"`
+}
+
+// LogWebSearchInput represents a search the orchestrator ran for a task
+type LogWebSearchInput struct {
+	TaskID   uuid.UUID
+	Query    string
+	Provider string
+	Results  []domain.WebSearchResult
+	// IdempotencyKey makes retries return the original charge instead of
+	// charging the search twice
+	IdempotencyKey string
+}
+
+// WebSearchLog is the outcome of logging a search
+type WebSearchLog struct {
+	Search *domain.WebSearch `json:"search"`
+	// Results are the search's results in the office's allowed domains
+	Results       []domain.WebSearchResult `json:"results"`
+	SearchesUsed  int                      `json:"searches_used"`
+	SearchesLeft  int                      `json:"searches_left"`
+	TransactionID *uuid.UUID               `json:"transaction_id,omitempty"`
+	NewBalance    *int64                   `json:"new_balance,omitempty"`
+}
+
+// Run deletes expired search results every hour until ctx is cancelled
+func (s *WebResearchService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webSearchCachePurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.cache.PurgeExpired(ctx); err != nil {
+				log.Printf("Failed to purge web search cache: %v", err)
+			}
+		}
+	}
+}
+
+// GetSettings returns the office's web research settings, or the defaults
+// if it never changed them
+func (s *WebResearchService) GetSettings(ctx context.Context, officeID uuid.UUID) (*domain.WebResearchSettings, error) {
+	settings, err := s.researchRepo.GetSettings(ctx, officeID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return &domain.WebResearchSettings{
+			OfficeID:           officeID,
+			AllowedDomains:     []string{},
+			MaxSearchesPerTask: s.config.DefaultMaxSearches,
+		}, nil
+	}
+	return settings, err
+}
+
+// UpdateSettings changes the office's web research settings
+func (s *WebResearchService) UpdateSettings(ctx context.Context, officeID uuid.UUID, input UpdateWebResearchSettingsInput) (*domain.WebResearchSettings, error) {
+	settings, err := s.GetSettings(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebResearchSettings(settings, input); err != nil {
+		return nil, err
+	}
+	if err := s.researchRepo.UpsertSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// applyWebResearchSettings validates the changes to web research settings
+// and makes them, or returns ErrInvalidInput leaving settings as they were
+func applyWebResearchSettings(settings *domain.WebResearchSettings, input UpdateWebResearchSettingsInput) error {
+	domains := settings.AllowedDomains
+	if input.AllowedDomains != nil {
+		var err error
+		if domains, err = normalizeDomains(input.AllowedDomains); err != nil {
+			return err
+		}
+	}
+	if input.MaxSearchesPerTask != nil {
+		if *input.MaxSearchesPerTask < 0 || *input.MaxSearchesPerTask > maxWebSearchesPerTask {
+			return fmt.Errorf("%w: max searches per task must be between 0 and %d", domain.ErrInvalidInput, maxWebSearchesPerTask)
+		}
+		settings.MaxSearchesPerTask = *input.MaxSearchesPerTask
+	}
+	settings.AllowedDomains = domains
+	settings.UpdatedAt = time.Now()
+	return nil
+}
+
+// Lookup answers a running task's query from the cache, recording the
+// search for free, or reports how many searches the task has left
+func (s *WebResearchService) Lookup(ctx context.Context, taskID uuid.UUID, query string) (*WebResearchLookup, error) {
+	task, settings, err := s.researchTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	query, err = normalizeSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.researchRepo.CountSearches(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := &WebResearchLookup{
+		AllowedDomains: settings.AllowedDomains,
+		SearchesUsed:   used,
+		SearchesLeft:   max(settings.MaxSearchesPerTask-used, 0),
+		SearchCredits:  s.config.SearchCredits,
+	}
+	results, ok, err := s.cache.Get(ctx, searchCacheKey(query))
+	if err != nil {
+		// A cache outage only costs a search
+		log.Printf("Failed to read web search cache: %v", err)
+		return lookup, nil
+	}
+	if !ok {
+		return lookup, nil
+	}
+
+	lookup.Cached = true
+	lookup.Results = filterResults(results, settings.AllowedDomains)
+	search := &domain.WebSearch{
+		ID:          uuid.New(),
+		OfficeID:    task.OfficeID,
+		TaskID:      task.ID,
+		AgentID:     task.AgentID,
+		Query:       query,
+		Cached:      true,
+		ResultCount: len(lookup.Results),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.researchRepo.RecordSearch(ctx, search); err != nil {
+		return nil, err
+	}
+	return lookup, nil
+}
+
+// LogSearch charges a running task's office for a search the orchestrator
+// ran and caches its results. Tasks cannot run more searches than their
+// office allows.
+func (s *WebResearchService) LogSearch(ctx context.Context, input LogWebSearchInput) (*WebSearchLog, error) {
+	task, settings, err := s.researchTask(ctx, input.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	query, err := normalizeSearchQuery(input.Query)
+	if err != nil {
+		return nil, err
+	}
+	provider := strings.TrimSpace(input.Provider)
+	if len(provider) > maxWebSearchProvider {
+		return nil, fmt.Errorf("%w: provider must be at most %d characters", domain.ErrInvalidInput, maxWebSearchProvider)
+	}
+	if len(input.Results) > maxWebSearchResults {
+		return nil, fmt.Errorf("%w: a search can log at most %d results", domain.ErrInvalidInput, maxWebSearchResults)
+	}
+
+	used, err := s.researchRepo.CountSearches(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used >= settings.MaxSearchesPerTask {
+		return nil, domain.WithDetails(
+			fmt.Errorf("%w: the task may run at most %d web searches", domain.ErrRateLimited, settings.MaxSearchesPerTask),
+			map[string]any{"limit": settings.MaxSearchesPerTask, "current": used},
+		)
+	}
+
+	searchLog := &WebSearchLog{}
+	if s.config.SearchCredits > 0 {
+		tx, err := s.creditService.ConsumeCreditsForTask(ctx, task.OfficeID, task.ID, s.config.SearchCredits,
+			"Web search", input.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.creditService.CheckBudgetAlerts(ctx, task.OfficeID, s.config.SearchCredits); err != nil {
+			log.Printf("Budget alert check failed: %v", err)
+		}
+		if _, err := s.creditService.CheckLowBalance(ctx, task.OfficeID, tx.BalanceAfter, s.config.SearchCredits); err != nil {
+			log.Printf("Low credit check failed: %v", err)
+		}
+		searchLog.TransactionID = &tx.ID
+		searchLog.NewBalance = &tx.BalanceAfter
+	}
+
+	if err := s.cache.Set(ctx, searchCacheKey(query), input.Results, s.config.CacheTTL); err != nil {
+		log.Printf("Failed to cache web search results: %v", err)
+	}
+
+	searchLog.Results = filterResults(input.Results, settings.AllowedDomains)
+	searchLog.Search = &domain.WebSearch{
+		ID:          uuid.New(),
+		OfficeID:    task.OfficeID,
+		TaskID:      task.ID,
+		AgentID:     task.AgentID,
+		Query:       query,
+		Provider:    provider,
+		ResultCount: len(searchLog.Results),
+		Credits:     s.config.SearchCredits,
+		CreatedAt:   time.Now(),
+	}
+	if input.IdempotencyKey != "" {
+		searchLog.Search.ID = uuid.NewSHA1(webSearchNamespace, []byte(task.ID.String()+":"+input.IdempotencyKey))
+	}
+	if err := s.researchRepo.RecordSearch(ctx, searchLog.Search); err != nil {
+		return nil, err
+	}
+
+	if used, err = s.researchRepo.CountSearches(ctx, task.ID); err != nil {
+		return nil, err
+	}
+	searchLog.SearchesUsed = used
+	searchLog.SearchesLeft = max(settings.MaxSearchesPerTask-used, 0)
+	return searchLog, nil
+}
+
+// researchTask loads a running task whose office's tier includes web
+// research, and the office's settings
+func (s *WebResearchService) researchTask(ctx context.Context, taskID uuid.UUID) (*domain.Task, *domain.WebResearchSettings, error) {
+	task, err := runningTask(ctx, s.taskRepo, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.subscriptionService.RequireFeature(ctx, task.OfficeID, FeatureWebResearch); err != nil {
+		return nil, nil, err
+	}
+	settings, err := s.GetSettings(ctx, task.OfficeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, settings, nil
+}
+
+// normalizeSearchQuery lower cases a query and collapses its whitespace,
+// so the same question is cached once
+func normalizeSearchQuery(query string) (string, error) {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if query == "" || len(query) > maxWebSearchQueryLen {
+		return "", fmt.Errorf("%w: query is required and must be at most %d characters", domain.ErrInvalidInput, maxWebSearchQueryLen)
+	}
+	return query, nil
+}
+
+// searchCacheKey is the cache key of a normalized query
+func searchCacheKey(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeDomains reduces URLs and wildcards such as https://www.example.com/docs
+// or *.example.com to their domain and drops duplicates
+func normalizeDomains(domains []string) ([]string, error) {
+	if len(domains) > maxAllowedDomains {
+		return nil, fmt.Errorf("%w: at most %d domains can be allowed", domain.ErrInvalidInput, maxAllowedDomains)
+	}
+	normalized := []string{}
+	for _, d := range domains {
+		name := strings.ToLower(strings.TrimSpace(d))
+		if i := strings.Index(name, "://"); i >= 0 {
+			name = name[i+3:]
+		}
+		if i := strings.IndexAny(name, "/?#"); i >= 0 {
+			name = name[:i]
+		}
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[:i]
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "*."), "www.")
+		if !domainNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: %q is not a domain", domain.ErrInvalidInput, d)
+		}
+		if !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// filterResults keeps the results in the allowed domains or their
+// subdomains; no allowed domains keeps every result
+func filterResults(results []domain.WebSearchResult, allowedDomains []string) []domain.WebSearchResult {
+	filtered := []domain.WebSearchResult{}
+	for _, result := range results {
+		if len(allowedDomains) == 0 || inAllowedDomain(result.URL, allowedDomains) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+func inAllowedDomain(rawURL string, allowedDomains []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range allowedDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
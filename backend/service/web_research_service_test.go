@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestWebResearchService creates a WebResearchService for a running task
+// of an office on a tier with web research
+func newTestWebResearchService(t *testing.T, task *domain.Task) (*WebResearchService, *mocks.MockWebResearchRepository, *mocks.MockWebSearchCache) {
+	ctrl := gomock.NewController(t)
+	research := mocks.NewMockWebResearchRepository(ctrl)
+	cache := mocks.NewMockWebSearchCache(ctrl)
+	tasks := mocks.NewMockTaskRepository(ctrl)
+	tasks.EXPECT().GetByID(gomock.Any(), task.ID).Return(task, nil)
+
+	subscriptions, m := newTestSubscriptionService(t)
+	m.subs.EXPECT().GetByOfficeID(gomock.Any(), task.OfficeID).
+		Return(&domain.Subscription{OfficeID: task.OfficeID, Tier: domain.TierProfessional}, nil)
+
+	svc := NewWebResearchService(research, cache, tasks, nil, subscriptions, WebResearchConfig{SearchCredits: 2, DefaultMaxSearches: 5})
+	return svc, research, cache
+}
+
+func TestNormalizeDomains(t *testing.T) {
+	got, err := normalizeDomains([]string{"https://www.Example.com/docs", "*.example.com", "go.dev:443", " pkg.go.dev "})
+	if err != nil {
+		t.Fatalf("normalizeDomains: %v", err)
+	}
+	if want := []string{"example.com", "go.dev", "pkg.go.dev"}; !slices.Equal(got, want) {
+		t.Errorf("normalizeDomains = %v, want %v", got, want)
+	}
+	if _, err := normalizeDomains([]string{"localhost"}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("normalizeDomains(localhost) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestLookupServesCachedResultsInAllowedDomainsForFree(t *testing.T) {
+	task := &domain.Task{ID: uuid.New(), OfficeID: uuid.New(), AgentID: uuid.New(), Status: domain.TaskStatusWorking}
+	svc, research, cache := newTestWebResearchService(t, task)
+
+	research.EXPECT().GetSettings(gomock.Any(), task.OfficeID).
+		Return(&domain.WebResearchSettings{OfficeID: task.OfficeID, AllowedDomains: []string{"go.dev"}, MaxSearchesPerTask: 3}, nil)
+	research.EXPECT().CountSearches(gomock.Any(), task.ID).Return(1, nil)
+	cache.EXPECT().Get(gomock.Any(), searchCacheKey("go generics")).Return([]domain.WebSearchResult{
+		{Title: "Tutorial", URL: "https://go.dev/doc/tutorial/generics"},
+		{Title: "Package", URL: "https://pkg.go.dev/golang.org/x/exp/constraints"},
+		{Title: "Blog", URL: "https://example.com/go-generics"},
+	}, true, nil)
+	research.EXPECT().RecordSearch(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, search *domain.WebSearch) error {
+		if !search.Cached || search.Credits != 0 || search.Query != "go generics" {
+			t.Errorf("recorded search = %+v, want a free cached search of the normalized query", search)
+		}
+		return nil
+	})
+
+	lookup, err := svc.Lookup(context.Background(), task.ID, "  Go   Generics ")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if !lookup.Cached || len(lookup.Results) != 2 || lookup.SearchesLeft != 2 {
+		t.Errorf("Lookup = %+v, want the 2 cached results in go.dev with 2 searches left", lookup)
+	}
+}
+
+func TestLogSearchRefusedOverTheTaskLimit(t *testing.T) {
+	task := &domain.Task{ID: uuid.New(), OfficeID: uuid.New(), AgentID: uuid.New(), Status: domain.TaskStatusThinking}
+	svc, research, _ := newTestWebResearchService(t, task)
+
+	research.EXPECT().GetSettings(gomock.Any(), task.OfficeID).Return(nil, domain.ErrNotFound)
+	research.EXPECT().CountSearches(gomock.Any(), task.ID).Return(5, nil)
+
+	_, err := svc.LogSearch(context.Background(), LogWebSearchInput{TaskID: task.ID, Query: "go generics"})
+	if !errors.Is(err, domain.ErrRateLimited) {
+		t.Fatalf("LogSearch over the default limit error = %v, want ErrRateLimited", err)
+	}
+	if details := domain.ErrorDetails(err); details["limit"] != 5 {
+		t.Errorf("LogSearch error details = %v, want the limit", err)
+	}
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// webhookPollInterval is how often the delivery worker looks for due
+	// deliveries when no new ones are queued
+	webhookPollInterval = 15 * time.Second
+	webhookBatchSize    = 20
+	// webhookTimeout bounds one request; webhookLease, longer, is how long
+	// a claimed delivery is left to its worker before another retries it
+	webhookTimeout = 10 * time.Second
+	webhookLease   = time.Minute
+	// webhookMaxAttempts is how often delivery is tried before an event is
+	// given up on
+	webhookMaxAttempts = 8
+	// webhookRetryBaseDelay is the delay before the first retry; each
+	// further retry doubles it up to webhookRetryMaxDelay
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = 2 * time.Hour
+	// webhookResponseExcerpt is how much of a failed response's body is
+	// kept in the delivery log
+	webhookResponseExcerpt = 256
+)
+
+// Headers sent with every webhook delivery
+const (
+	// WebhookSignatureHeader carries "t=<unix time>,v1=<signature>", where
+	// the signature is the hex HMAC-SHA256, keyed with the webhook's secret,
+	// of the time, a dot and the request body
+	WebhookSignatureHeader = "X-Synoffice-Signature"
+	WebhookEventHeader     = "X-Synoffice-Event"
+	WebhookDeliveryHeader  = "X-Synoffice-Delivery"
+)
+
+// notificationWebhookEvents are the webhook events of the notifications
+// NotificationService.Notify dispatches
+var notificationWebhookEvents = map[domain.NotificationType]domain.WebhookEventType{
+	domain.NotificationTypeTaskCompleted:       domain.WebhookEventTaskCompleted,
+	domain.NotificationTypeLowCredits:          domain.WebhookEventCreditsLow,
+	domain.NotificationTypeSubscriptionUpdated: domain.WebhookEventSubscriptionUpdated,
+}
+
+// WebhookPayload is the body of a webhook delivery. Every delivery of an
+// event carries the same ID, so receivers can drop repeats.
+type WebhookPayload struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      domain.WebhookEventType `json:"type"`
+	OfficeID  uuid.UUID               `json:"office_id"`
+	CreatedAt time.Time               `json:"created_at"`
+	Data      map[string]any          `json:"data"`
+}
+
+// WebhookDispatcher queues events for the webhooks subscribed to them and
+// delivers them in the background, retrying with backoff until the
+// receiver answers with a 2xx status
+type WebhookDispatcher struct {
+	webhookRepo  domain.WebhookRepository
+	deliveryRepo domain.WebhookDeliveryRepository
+	httpClient   *http.Client
+	// wake tells Run that a delivery was queued
+	wake chan struct{}
+}
+
+// NewWebhookDispatcher creates a new WebhookDispatcher. Unless
+// allowPrivateNetworks is set, deliveries to loopback, private and
+// link-local addresses are refused, so webhooks cannot reach the
+// platform's internal services.
+func NewWebhookDispatcher(
+	webhookRepo domain.WebhookRepository,
+	deliveryRepo domain.WebhookDeliveryRepository,
+	allowPrivateNetworks bool,
+) *WebhookDispatcher {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivateNetworks {
+		dialer.Control = refusePrivateAddresses
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Through a proxy the dialer would only see the proxy's address
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookDispatcher{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		httpClient: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: transport,
+			// Redirects are reported as the response they are
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// refusePrivateAddresses is the dialer control rejecting connections to
+// addresses that are not public
+func refusePrivateAddresses(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
+
+// Dispatch queues an event for the office's webhooks subscribed to it.
+// Failures are logged; the change the event announces stands either way.
+// Within a transaction the deliveries commit with it.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, officeID uuid.UUID, eventType domain.WebhookEventType, data map[string]any) {
+	webhooks, err := d.webhookRepo.GetSubscribed(ctx, officeID, eventType)
+	if err != nil {
+		log.Printf("Failed to load webhooks of office %s for %s: %v", officeID, eventType, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	eventID, now := uuid.New(), time.Now()
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        eventID,
+		Type:      eventType,
+		OfficeID:  officeID,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode %s webhook event of office %s: %v", eventType, officeID, err)
+		return
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, len(webhooks))
+	for i, webhook := range webhooks {
+		deliveries[i] = &domain.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       payload,
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+	}
+	if err := d.deliveryRepo.Create(ctx, deliveries); err != nil {
+		log.Printf("Failed to queue %s webhook event of office %s: %v", eventType, officeID, err)
+		return
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.deliverDue(ctx)
+	}
+}
+
+// deliverDue delivers due events in batches until none are left
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.deliveryRepo.ClaimDue(ctx, time.Now(), webhookLease, webhookBatchSize)
+		if err != nil {
+			log.Printf("Failed to load queued webhook deliveries: %v", err)
+			return
+		}
+
+		// Sent side by side, so slow receivers cannot hold the batch past
+		// its lease
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliver sends one claimed delivery and records the attempt
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	webhook, err := d.webhookRepo.GetByID(ctx, delivery.WebhookID)
+	if errors.Is(err, domain.ErrNotFound) {
+		// Deleted meanwhile, along with its deliveries
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load webhook %s of delivery %s: %v", delivery.WebhookID, delivery.ID, err)
+		return
+	}
+
+	attempt := d.send(ctx, webhook, delivery)
+	status := domain.WebhookDeliveryDelivered
+	var nextAttemptAt *time.Time
+	switch {
+	case attempt.Error == "":
+	case delivery.Attempts < webhookMaxAttempts:
+		status = domain.WebhookDeliveryPending
+		next := time.Now().Add(webhookRetryDelay(delivery.Attempts))
+		nextAttemptAt = &next
+	default:
+		status = domain.WebhookDeliveryFailed
+		log.Printf("Giving up on %s delivery %s to webhook %s after %d attempts: %s",
+			delivery.EventType, delivery.ID, webhook.ID, delivery.Attempts, attempt.Error)
+	}
+
+	if err := d.deliveryRepo.RecordAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		log.Printf("Failed to record attempt %d of webhook delivery %s: %v", delivery.Attempts, delivery.ID, err)
+	}
+}
+
+// send makes one delivery attempt. The attempt's Error is empty when the
+// receiver answered with a 2xx status.
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) domain.WebhookDeliveryAttempt {
+	start := time.Now()
+	attempt := domain.WebhookDeliveryAttempt{Attempt: delivery.Attempts, AttemptedAt: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Synoffice-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookSignatureHeader, signWebhook(webhook.Secret, start, delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	attempt.DurationMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseExcerpt))
+		attempt.Error = fmt.Sprintf("status %d: %s", resp.StatusCode, bytes.TrimSpace(excerpt))
+	}
+	return attempt
+}
+
+// signWebhook returns the WebhookSignatureHeader value of a payload sent at
+// the given time
+func signWebhook(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns the backoff before the retry following the
+// given attempt
+func webhookRetryDelay(attempt int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempt && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMaxDelay)
+}
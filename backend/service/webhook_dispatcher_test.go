@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestWebhookDispatcher creates a WebhookDispatcher for offices without
+// webhooks
+func newTestWebhookDispatcher(ctrl *gomock.Controller) *WebhookDispatcher {
+	webhooks := mocks.NewMockWebhookRepository(ctrl)
+	webhooks.EXPECT().GetSubscribed(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	return NewWebhookDispatcher(webhooks, mocks.NewMockWebhookDeliveryRepository(ctrl), false)
+}
+
+func TestSignWebhookIsHMACOfTimeAndBody(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	payload := []byte(`{"type":"task.completed"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(payload)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := signWebhook("whsec_test", at, payload); got != want {
+		t.Errorf("signWebhook() = %q, want %q", got, want)
+	}
+	if signWebhook("whsec_other", at, payload) == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestWebhookRetryDelayDoublesUpToMax(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{9, webhookRetryMaxDelay},
+		{50, webhookRetryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempt); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRefusePrivateAddresses(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"127.0.0.1:80", true},
+		{"10.1.2.3:443", true},
+		{"192.168.0.10:443", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:443", true},
+		{"0.0.0.0:80", true},
+		{"93.184.216.34:443", false},
+		{"[2606:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		err := refusePrivateAddresses("tcp", tt.address, nil)
+		if (err != nil) != tt.refused {
+			t.Errorf("refusePrivateAddresses(%q) = %v, want refused %v", tt.address, err, tt.refused)
+		}
+	}
+}
+
+func TestDispatchQueuesDeliveryPerSubscribedWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	webhooks := mocks.NewMockWebhookRepository(ctrl)
+	deliveries := mocks.NewMockWebhookDeliveryRepository(ctrl)
+	d := NewWebhookDispatcher(webhooks, deliveries, false)
+	officeID := uuid.New()
+
+	subscribed := []*domain.Webhook{{ID: uuid.New(), OfficeID: officeID}, {ID: uuid.New(), OfficeID: officeID}}
+	webhooks.EXPECT().GetSubscribed(gomock.Any(), officeID, domain.WebhookEventCreditsLow).Return(subscribed, nil)
+	deliveries.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, queued []*domain.WebhookDelivery) error {
+		if len(queued) != 2 {
+			t.Fatalf("queued %d deliveries, want 2", len(queued))
+		}
+		if queued[0].EventID != queued[1].EventID {
+			t.Error("deliveries of one event carry different event ids")
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(queued[0].Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Type != domain.WebhookEventCreditsLow || payload.OfficeID != officeID || payload.Data["balance"] != float64(5) {
+			t.Errorf("payload = %+v", payload)
+		}
+		for i, delivery := range queued {
+			if delivery.WebhookID != subscribed[i].ID || delivery.Status != domain.WebhookDeliveryPending {
+				t.Errorf("delivery %d = %+v", i, delivery)
+			}
+		}
+		return nil
+	})
+
+	d.Dispatch(context.Background(), officeID, domain.WebhookEventCreditsLow, map[string]any{"balance": 5})
+}
+
+func TestDeliverRecordsOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+		want     domain.WebhookDeliveryStatus
+		retry    bool
+	}{
+		{"accepted", http.StatusNoContent, 1, domain.WebhookDeliveryDelivered, false},
+		{"server error is retried", http.StatusInternalServerError, 1, domain.WebhookDeliveryPending, true},
+		{"redirect is not followed", http.StatusFound, 1, domain.WebhookDeliveryPending, true},
+		{"last attempt gives up", http.StatusServiceUnavailable, webhookMaxAttempts, domain.WebhookDeliveryFailed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := []byte(`{"id":"1"}`)
+			var got *http.Request
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "http://example.com")
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("receiver says no"))
+			}))
+			defer server.Close()
+
+			ctrl := gomock.NewController(t)
+			webhooks := mocks.NewMockWebhookRepository(ctrl)
+			deliveries := mocks.NewMockWebhookDeliveryRepository(ctrl)
+			// Private addresses are allowed so the test server is reachable
+			d := NewWebhookDispatcher(webhooks, deliveries, true)
+
+			webhook := &domain.Webhook{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}
+			delivery := &domain.WebhookDelivery{
+				ID: uuid.New(), WebhookID: webhook.ID, EventType: domain.WebhookEventTaskCompleted,
+				Payload: payload, Attempts: tt.attempts,
+			}
+			webhooks.EXPECT().GetByID(gomock.Any(), webhook.ID).Return(webhook, nil)
+			deliveries.EXPECT().RecordAttempt(gomock.Any(), delivery.ID, gomock.Any(), tt.want, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ uuid.UUID, attempt domain.WebhookDeliveryAttempt, _ domain.WebhookDeliveryStatus, next *time.Time) error {
+					if attempt.StatusCode == nil || *attempt.StatusCode != tt.status {
+						t.Errorf("recorded status code %v, want %d", attempt.StatusCode, tt.status)
+					}
+					if tt.want == domain.WebhookDeliveryDelivered && attempt.Error != "" {
+						t.Errorf("accepted attempt recorded error %q", attempt.Error)
+					}
+					if tt.want != domain.WebhookDeliveryDelivered && !strings.Contains(attempt.Error, "receiver says no") {
+						t.Errorf("error %q does not quote the response", attempt.Error)
+					}
+					if (next != nil) != tt.retry {
+						t.Errorf("next attempt = %v, want retry %v", next, tt.retry)
+					}
+					return nil
+				})
+
+			d.deliver(context.Background(), delivery)
+
+			if got == nil {
+				t.Fatal("webhook was not called")
+			}
+			if got.Header.Get(WebhookEventHeader) != "task.completed" || got.Header.Get(WebhookDeliveryHeader) != delivery.ID.String() {
+				t.Errorf("headers = %v", got.Header)
+			}
+			signature := got.Header.Get(WebhookSignatureHeader)
+			timestamp := strings.TrimPrefix(strings.SplitN(signature, ",", 2)[0], "t=")
+			mac := hmac.New(sha256.New, []byte(webhook.Secret))
+			mac.Write([]byte(timestamp + "." + string(body)))
+			if !strings.HasSuffix(signature, ",v1="+hex.EncodeToString(mac.Sum(nil))) {
+				t.Errorf("signature %q does not verify", signature)
+			}
+		})
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook on a loopback address was called")
+	}))
+	defer server.Close()
+
+	ctrl := gomock.NewController(t)
+	webhooks := mocks.NewMockWebhookRepository(ctrl)
+	deliveries := mocks.NewMockWebhookDeliveryRepository(ctrl)
+	d := NewWebhookDispatcher(webhooks, deliveries, false)
+
+	webhook := &domain.Webhook{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}
+	delivery := &domain.WebhookDelivery{ID: uuid.New(), WebhookID: webhook.ID, Payload: []byte(`{}`), Attempts: 1}
+	webhooks.EXPECT().GetByID(gomock.Any(), webhook.ID).Return(webhook, nil)
+	deliveries.EXPECT().RecordAttempt(gomock.Any(), delivery.ID, gomock.Any(), domain.WebhookDeliveryPending, gomock.Not(gomock.Nil())).
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, attempt domain.WebhookDeliveryAttempt, _ domain.WebhookDeliveryStatus, _ *time.Time) error {
+			if attempt.StatusCode != nil || !strings.Contains(attempt.Error, "not a public address") {
+				t.Errorf("attempt = %+v", attempt)
+			}
+			return nil
+		})
+
+	d.deliver(context.Background(), delivery)
+}
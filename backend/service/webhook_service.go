@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// webhookSecretPrefix marks generated webhook secrets
+	webhookSecretPrefix = "whsec_"
+	// minWebhookSecretLength is the shortest secret an office may choose
+	minWebhookSecretLength = 16
+	maxWebhookURLLength    = 2048
+	// maxWebhooksPerOffice caps the deliveries each event can fan out to
+	maxWebhooksPerOffice = 10
+)
+
+// WebhookService manages the webhooks of offices whose tier includes API
+// access. WebhookDispatcher delivers their events.
+type WebhookService struct {
+	webhookRepo         domain.WebhookRepository
+	deliveryRepo        domain.WebhookDeliveryRepository
+	subscriptionService *SubscriptionService
+}
+
+// NewWebhookService creates a new WebhookService instance
+func NewWebhookService(
+	webhookRepo domain.WebhookRepository,
+	deliveryRepo domain.WebhookDeliveryRepository,
+	subscriptionService *SubscriptionService,
+) *WebhookService {
+	return &WebhookService{
+		webhookRepo:         webhookRepo,
+		deliveryRepo:        deliveryRepo,
+		subscriptionService: subscriptionService,
+	}
+}
+
+// CreateWebhookInput represents input for registering a webhook
+type CreateWebhookInput struct {
+	OfficeID uuid.UUID
+	URL      string
+	// Secret signs deliveries; one is generated when it is empty
+	Secret     string
+	EventTypes []domain.WebhookEventType
+}
+
+// CreateWebhook registers a webhook for an office whose tier includes API
+// access. It returns the webhook's secret, which is not shown again.
+func (s *WebhookService) CreateWebhook(ctx context.Context, input CreateWebhookInput) (*domain.Webhook, string, error) {
+	target, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" ||
+		len(target.String()) > maxWebhookURLLength {
+		return nil, "", fmt.Errorf("%w: url must be an http or https URL of at most %d characters", domain.ErrInvalidInput, maxWebhookURLLength)
+	}
+	if target.User != nil {
+		return nil, "", fmt.Errorf("%w: url may not contain credentials; verify deliveries by their signature", domain.ErrInvalidInput)
+	}
+	if input.Secret != "" && len(input.Secret) < minWebhookSecretLength {
+		return nil, "", fmt.Errorf("%w: secret must be at least %d characters", domain.ErrInvalidInput, minWebhookSecretLength)
+	}
+	if len(input.EventTypes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one event type is required", domain.ErrInvalidInput)
+	}
+	var eventTypes []domain.WebhookEventType
+	for _, eventType := range input.EventTypes {
+		if !slices.Contains(domain.WebhookEventTypes, eventType) {
+			return nil, "", fmt.Errorf("%w: unknown event type %q", domain.ErrInvalidInput, eventType)
+		}
+		if !slices.Contains(eventTypes, eventType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	if err := s.subscriptionService.RequireFeature(ctx, input.OfficeID, FeatureAPIAccess); err != nil {
+		return nil, "", err
+	}
+	existing, err := s.webhookRepo.GetByOfficeID(ctx, input.OfficeID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(existing) >= maxWebhooksPerOffice {
+		return nil, "", fmt.Errorf("%w: an office can have at most %d webhooks", domain.ErrInvalidInput, maxWebhooksPerOffice)
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, "", fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+	}
+
+	webhook := &domain.Webhook{
+		ID:         uuid.New(),
+		OfficeID:   input.OfficeID,
+		URL:        target.String(),
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, "", err
+	}
+	return webhook, secret, nil
+}
+
+// GetWebhooks returns an office's webhooks, newest first
+func (s *WebhookService) GetWebhooks(ctx context.Context, officeID uuid.UUID) ([]*domain.Webhook, error) {
+	return s.webhookRepo.GetByOfficeID(ctx, officeID)
+}
+
+// DeleteWebhook removes one of the office's webhooks. Its queued deliveries
+// are dropped.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, officeID, webhookID uuid.UUID) error {
+	if _, err := s.officeWebhook(ctx, officeID, webhookID); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(ctx, webhookID)
+}
+
+// GetDeliveries returns a page of the deliveries to one of the office's
+// webhooks, newest first, with every attempt made and the status code it
+// got
+func (s *WebhookService) GetDeliveries(ctx context.Context, officeID, webhookID uuid.UUID, limit, offset int) ([]*domain.WebhookDelivery, int, error) {
+	if _, err := s.officeWebhook(ctx, officeID, webhookID); err != nil {
+		return nil, 0, err
+	}
+	return s.deliveryRepo.GetByWebhookID(ctx, webhookID, limit, offset)
+}
+
+// officeWebhook returns the webhook if it belongs to the office, and
+// ErrNotFound otherwise
+func (s *WebhookService) officeWebhook(ctx context.Context, officeID, webhookID uuid.UUID) (*domain.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.OfficeID != officeID {
+		return nil, domain.ErrNotFound
+	}
+	return webhook, nil
+}
+
+// newWebhookSecret generates a webhook secret
+func newWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// widgetTokenPrefix marks chat widget tokens. Unlike API keys they are
+	// published in the embedding page, so they only reach one agent.
+	widgetTokenPrefix = "synw_"
+
+	maxWidgetTokenNameLength = 100
+	maxWidgetOrigins         = 20
+	// defaultWidgetRateLimit and maxWidgetRateLimit bound the requests a
+	// token accepts per minute
+	defaultWidgetRateLimit = 20
+	maxWidgetRateLimit     = 600
+	// maxVisitorMessageLength caps visitor messages, in characters
+	maxVisitorMessageLength = 4000
+)
+
+// WidgetService manages chat widget tokens and the conversations visitors
+// hold through them. A token exposes one agent of its office; each visitor
+// session is a direct conversation with that agent, so its tasks are
+// charged to the office like any other.
+type WidgetService struct {
+	tokenRepo   domain.WidgetTokenRepository
+	sessionRepo domain.WidgetSessionRepository
+	agentRepo   domain.AgentRepository
+	txManager   domain.TxManager
+	chatService *ChatService
+}
+
+// NewWidgetService creates a new WidgetService instance
+func NewWidgetService(
+	tokenRepo domain.WidgetTokenRepository,
+	sessionRepo domain.WidgetSessionRepository,
+	agentRepo domain.AgentRepository,
+	txManager domain.TxManager,
+	chatService *ChatService,
+) *WidgetService {
+	return &WidgetService{
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		agentRepo:   agentRepo,
+		txManager:   txManager,
+		chatService: chatService,
+	}
+}
+
+// CreateWidgetTokenInput represents input for creating a widget token
+type CreateWidgetTokenInput struct {
+	OfficeID       uuid.UUID
+	UserID         uuid.UUID
+	AgentID        uuid.UUID
+	Name           string
+	AllowedOrigins []string
+	// RateLimit is requests per minute; zero means the default
+	RateLimit int
+}
+
+// CreateWidgetToken creates a token exposing one of the office's active
+// agents. The returned secret is the only time the full token is available.
+func (s *WidgetService) CreateWidgetToken(ctx context.Context, input CreateWidgetTokenInput) (*domain.WidgetToken, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxWidgetTokenNameLength {
+		return nil, "", fmt.Errorf("%w: name is required and must be at most %d characters", domain.ErrInvalidInput, maxWidgetTokenNameLength)
+	}
+	origins, err := normalizeWidgetOrigins(input.AllowedOrigins)
+	if err != nil {
+		return nil, "", err
+	}
+	rateLimit := input.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultWidgetRateLimit
+	}
+	if rateLimit < 1 || rateLimit > maxWidgetRateLimit {
+		return nil, "", fmt.Errorf("%w: rate_limit must be between 1 and %d requests per minute", domain.ErrInvalidInput, maxWidgetRateLimit)
+	}
+
+	agent, err := officeAgent(ctx, s.agentRepo, input.OfficeID, input.AgentID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !agent.IsActive) {
+		return nil, "", fmt.Errorf("%w: agent %s is not an active agent of this office", domain.ErrInvalidInput, input.AgentID)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := newSecretToken(widgetTokenPrefix)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate widget token: %w", err)
+	}
+
+	token := &domain.WidgetToken{
+		ID:             uuid.New(),
+		OfficeID:       input.OfficeID,
+		AgentID:        agent.ID,
+		CreatedBy:      &input.UserID,
+		Name:           name,
+		Prefix:         secret[:apiKeyDisplayLength],
+		TokenHash:      hashAPIKey(secret),
+		AllowedOrigins: origins,
+		RateLimit:      rateLimit,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// GetWidgetTokens returns an office's widget tokens, including revoked ones,
+// with the credits their conversations used
+func (s *WidgetService) GetWidgetTokens(ctx context.Context, officeID uuid.UUID) ([]*domain.WidgetToken, error) {
+	return s.tokenRepo.GetByOfficeID(ctx, officeID)
+}
+
+// RevokeWidgetToken revokes one of the office's tokens. Its visitors are
+// refused from then on; their conversations stay with the office.
+func (s *WidgetService) RevokeWidgetToken(ctx context.Context, officeID, tokenID uuid.UUID) error {
+	token, err := s.tokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if token.OfficeID != officeID {
+		return domain.ErrNotFound
+	}
+	return s.tokenRepo.Revoke(ctx, tokenID)
+}
+
+// Authenticate resolves a token presented by a page served from origin.
+// Unknown and revoked tokens are rejected with ErrUnauthorized and origins
+// the token does not allow with ErrForbidden.
+func (s *WidgetService) Authenticate(ctx context.Context, secret, origin string) (*domain.WidgetToken, error) {
+	if !strings.HasPrefix(secret, widgetTokenPrefix) {
+		return nil, domain.ErrUnauthorized
+	}
+	token, err := s.tokenRepo.GetActiveByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !token.AllowsOrigin(origin) {
+		return nil, fmt.Errorf("%w: this widget may not be embedded on %q", domain.ErrForbidden, origin)
+	}
+	return token, nil
+}
+
+// VisitorMessageInput represents a message a visitor sends through a widget
+type VisitorMessageInput struct {
+	// SessionID continues an earlier session; nil starts a new one
+	SessionID *uuid.UUID
+	Content   string
+}
+
+// SendVisitorMessage posts a visitor's message to the token's agent,
+// starting a session and its conversation on the first message. The agent's
+// reply arrives over the session's WebSocket channel.
+func (s *WidgetService) SendVisitorMessage(ctx context.Context, token *domain.WidgetToken, input VisitorMessageInput) (*domain.WidgetSession, *domain.Message, error) {
+	content := strings.TrimSpace(input.Content)
+	if content == "" || utf8.RuneCountInString(content) > maxVisitorMessageLength {
+		return nil, nil, fmt.Errorf("%w: content is required and must be at most %d characters", domain.ErrInvalidInput, maxVisitorMessageLength)
+	}
+
+	agent, err := officeAgent(ctx, s.agentRepo, token.OfficeID, token.AgentID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !agent.IsActive) {
+		return nil, nil, fmt.Errorf("%w: this widget's agent is not available", domain.ErrForbidden)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session *domain.WidgetSession
+	if input.SessionID != nil {
+		if session, err = s.tokenSession(ctx, token, *input.SessionID); err != nil {
+			return nil, nil, err
+		}
+	} else if session, err = s.startSession(ctx, token, agent); err != nil {
+		return nil, nil, err
+	}
+
+	message, err := s.chatService.SendMessage(ctx, SendMessageInput{
+		OfficeID:       token.OfficeID,
+		ConversationID: session.ConversationID,
+		SenderType:     domain.SenderTypeVisitor,
+		SenderID:       session.ID,
+		Content:        content,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.tokenRepo.RecordMessage(ctx, token.ID); err != nil {
+		log.Printf("Failed to count message of widget token %s: %v", token.ID, err)
+	}
+	if err := s.sessionRepo.Touch(ctx, session.ID); err != nil {
+		log.Printf("Failed to touch widget session %s: %v", session.ID, err)
+	}
+	return session, message, nil
+}
+
+// GetVisitorMessages returns a page of a session's conversation
+func (s *WidgetService) GetVisitorMessages(ctx context.Context, token *domain.WidgetToken, sessionID uuid.UUID, page domain.PageRequest) ([]*domain.Message, int, error) {
+	session, err := s.tokenSession(ctx, token, sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.chatService.GetMessages(ctx, token.OfficeID, session.ConversationID, page)
+}
+
+// GetSession returns one of the token's sessions, for the visitor's
+// WebSocket channel
+func (s *WidgetService) GetSession(ctx context.Context, token *domain.WidgetToken, sessionID uuid.UUID) (*domain.WidgetSession, error) {
+	return s.tokenSession(ctx, token, sessionID)
+}
+
+// startSession creates a visitor session and its conversation
+func (s *WidgetService) startSession(ctx context.Context, token *domain.WidgetToken, agent *domain.Agent) (*domain.WidgetSession, error) {
+	var session *domain.WidgetSession
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		conversation, err := s.chatService.CreateConversation(ctx, CreateConversationInput{
+			OfficeID: token.OfficeID,
+			Type:     domain.ConversationTypeDirect,
+			Name:     token.Name + " visitor",
+			AgentIDs: []uuid.UUID{agent.ID},
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		session = &domain.WidgetSession{
+			ID:             uuid.New(),
+			TokenID:        token.ID,
+			ConversationID: conversation.ID,
+			CreatedAt:      now,
+			LastSeenAt:     now,
+		}
+		return s.sessionRepo.Create(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// tokenSession returns the session if it was started through the token,
+// and ErrNotFound otherwise
+func (s *WidgetService) tokenSession(ctx context.Context, token *domain.WidgetToken, sessionID uuid.UUID) (*domain.WidgetSession, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.TokenID != token.ID {
+		return nil, domain.ErrNotFound
+	}
+	return session, nil
+}
+
+// normalizeWidgetOrigins validates allowed origins and reduces them to
+// scheme://host[:port], lowercased, as browsers send them
+func normalizeWidgetOrigins(origins []string) ([]string, error) {
+	if len(origins) > maxWidgetOrigins {
+		return nil, fmt.Errorf("%w: at most %d allowed origins", domain.ErrInvalidInput, maxWidgetOrigins)
+	}
+	normalized := []string{}
+	for _, origin := range origins {
+		u, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("%w: %q is not an origin such as https://example.com", domain.ErrInvalidInput, origin)
+		}
+		o := strings.ToLower(u.Scheme + "://" + u.Host)
+		if !slices.Contains(normalized, o) {
+			normalized = append(normalized, o)
+		}
+	}
+	return normalized, nil
+}
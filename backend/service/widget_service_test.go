@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/denys89/syn-office/backend/domain/mocks"
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+)
+
+// widgetMocks are the repositories behind a WidgetService under test
+type widgetMocks struct {
+	tokens   *mocks.MockWidgetTokenRepository
+	sessions *mocks.MockWidgetSessionRepository
+	agents   *mocks.MockAgentRepository
+}
+
+// newTestWidgetService creates a WidgetService without a chat service, for
+// the paths that do not send messages
+func newTestWidgetService(t *testing.T) (*WidgetService, widgetMocks) {
+	ctrl := gomock.NewController(t)
+	m := widgetMocks{
+		tokens:   mocks.NewMockWidgetTokenRepository(ctrl),
+		sessions: mocks.NewMockWidgetSessionRepository(ctrl),
+		agents:   mocks.NewMockAgentRepository(ctrl),
+	}
+	return NewWidgetService(m.tokens, m.sessions, m.agents, mocks.NewMockTxManager(ctrl), nil), m
+}
+
+func TestCreateWidgetTokenNormalizesOrigins(t *testing.T) {
+	svc, m := newTestWidgetService(t)
+	officeID := uuid.New()
+	agent := &domain.Agent{ID: uuid.New(), OfficeID: officeID, IsActive: true}
+
+	m.agents.EXPECT().GetByID(gomock.Any(), agent.ID).Return(agent, nil)
+	m.tokens.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+	token, secret, err := svc.CreateWidgetToken(context.Background(), CreateWidgetTokenInput{
+		OfficeID:       officeID,
+		UserID:         uuid.New(),
+		AgentID:        agent.ID,
+		Name:           "Support",
+		AllowedOrigins: []string{"https://Example.com/", "https://example.com", "http://localhost:3000"},
+	})
+	if err != nil {
+		t.Fatalf("CreateWidgetToken: %v", err)
+	}
+	if !strings.HasPrefix(secret, widgetTokenPrefix) || token.TokenHash != hashAPIKey(secret) {
+		t.Errorf("secret %q does not match the stored hash", secret)
+	}
+	if want := []string{"https://example.com", "http://localhost:3000"}; strings.Join(token.AllowedOrigins, " ") != strings.Join(want, " ") {
+		t.Errorf("AllowedOrigins = %v, want %v", token.AllowedOrigins, want)
+	}
+	if token.RateLimit != defaultWidgetRateLimit {
+		t.Errorf("RateLimit = %d, want the default %d", token.RateLimit, defaultWidgetRateLimit)
+	}
+}
+
+func TestCreateWidgetTokenRejectsInvalidInput(t *testing.T) {
+	officeID := uuid.New()
+	active := &domain.Agent{ID: uuid.New(), OfficeID: officeID, IsActive: true}
+	inactive := &domain.Agent{ID: uuid.New(), OfficeID: officeID}
+	foreign := &domain.Agent{ID: uuid.New(), OfficeID: uuid.New(), IsActive: true}
+
+	tests := []struct {
+		name  string
+		input CreateWidgetTokenInput
+	}{
+		{"no name", CreateWidgetTokenInput{AgentID: active.ID}},
+		{"origin with path", CreateWidgetTokenInput{Name: "w", AgentID: active.ID, AllowedOrigins: []string{"https://example.com/chat"}}},
+		{"origin without scheme", CreateWidgetTokenInput{Name: "w", AgentID: active.ID, AllowedOrigins: []string{"example.com"}}},
+		{"rate limit too high", CreateWidgetTokenInput{Name: "w", AgentID: active.ID, RateLimit: maxWidgetRateLimit + 1}},
+		{"inactive agent", CreateWidgetTokenInput{Name: "w", AgentID: inactive.ID}},
+		{"agent of another office", CreateWidgetTokenInput{Name: "w", AgentID: foreign.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestWidgetService(t)
+			for _, agent := range []*domain.Agent{active, inactive, foreign} {
+				m.agents.EXPECT().GetByID(gomock.Any(), agent.ID).Return(agent, nil).AnyTimes()
+			}
+			tt.input.OfficeID = officeID
+
+			_, _, err := svc.CreateWidgetToken(context.Background(), tt.input)
+			if !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("CreateWidgetToken() error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWidgetToken(t *testing.T) {
+	secret := widgetTokenPrefix + "secret"
+	token := &domain.WidgetToken{ID: uuid.New(), AllowedOrigins: []string{"https://example.com"}}
+
+	tests := []struct {
+		name    string
+		secret  string
+		origin  string
+		found   bool
+		wantErr error
+	}{
+		{"allowed origin", secret, "https://example.com", true, nil},
+		{"origin case", secret, "https://EXAMPLE.com", true, nil},
+		{"other origin", secret, "https://evil.example", true, domain.ErrForbidden},
+		{"no origin", secret, "", true, domain.ErrForbidden},
+		{"unknown token", secret, "https://example.com", false, domain.ErrUnauthorized},
+		{"API key", apiKeyPrefix + "secret", "https://example.com", false, domain.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, m := newTestWidgetService(t)
+			if tt.found {
+				m.tokens.EXPECT().GetActiveByHash(gomock.Any(), hashAPIKey(tt.secret)).Return(token, nil)
+			} else {
+				m.tokens.EXPECT().GetActiveByHash(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound).AnyTimes()
+			}
+
+			got, err := svc.Authenticate(context.Background(), tt.secret, tt.origin)
+			if tt.wantErr == nil && (err != nil || got != token) {
+				t.Errorf("Authenticate() = %v, %v, want the token", got, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWidgetSessionOfAnotherTokenIsNotFound(t *testing.T) {
+	svc, m := newTestWidgetService(t)
+	token := &domain.WidgetToken{ID: uuid.New(), OfficeID: uuid.New()}
+	session := &domain.WidgetSession{ID: uuid.New(), TokenID: uuid.New(), ConversationID: uuid.New()}
+	m.sessions.EXPECT().GetByID(gomock.Any(), session.ID).Return(session, nil)
+
+	if _, err := svc.GetSession(context.Background(), token, session.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetSession() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSendVisitorMessageToUnavailableAgent(t *testing.T) {
+	svc, m := newTestWidgetService(t)
+	token := &domain.WidgetToken{ID: uuid.New(), OfficeID: uuid.New(), AgentID: uuid.New()}
+	m.agents.EXPECT().GetByID(gomock.Any(), token.AgentID).
+		Return(&domain.Agent{ID: token.AgentID, OfficeID: token.OfficeID}, nil)
+
+	_, _, err := svc.SendVisitorMessage(context.Background(), token, VisitorMessageInput{Content: "hello"})
+	if !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("SendVisitorMessage() error = %v, want ErrForbidden", err)
+	}
+}
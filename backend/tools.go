@@ -0,0 +1,8 @@
+//go:build tools
+
+// Package main pins the versions of the code generators go generate runs.
+package main
+
+import (
+	_ "go.uber.org/mock/mockgen"
+)
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+const (
+	// DefaultEmbeddingsAPIURL is OpenAI's embeddings endpoint
+	DefaultEmbeddingsAPIURL = "https://api.openai.com/v1/embeddings"
+	// embeddingsAPITimeout bounds each call to the embeddings API
+	embeddingsAPITimeout = 10 * time.Second
+)
+
+// APIEmbedder embeds text with an OpenAI compatible embeddings API
+type APIEmbedder struct {
+	apiKey     string
+	url        string
+	model      string
+	httpClient *http.Client
+}
+
+// NewAPIEmbedder creates a new APIEmbedder calling url, or OpenAI's
+// endpoint when it is empty. The model must embed into
+// domain.MemoryEmbeddingDimensions, or be able to shorten its embeddings
+// to them as text-embedding-3 models do.
+func NewAPIEmbedder(apiKey, url, model string) (*APIEmbedder, error) {
+	if apiKey == "" {
+		return nil, errors.New("embeddings: api key is required")
+	}
+	if model == "" {
+		return nil, errors.New("embeddings: model is required")
+	}
+	if url == "" {
+		url = DefaultEmbeddingsAPIURL
+	}
+	return &APIEmbedder{
+		apiKey:     apiKey,
+		url:        url,
+		model:      model,
+		httpClient: &http.Client{Timeout: embeddingsAPITimeout},
+	}, nil
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed returns the embedding of text
+func (e *APIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	payload, err := json.Marshal(map[string]any{
+		"input":      text,
+		"model":      e.model,
+		"dimensions": domain.MemoryEmbeddingDimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("embeddings: api returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var response embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("embeddings: invalid response: %w", err)
+	}
+	if len(response.Data) == 0 || len(response.Data[0].Embedding) != domain.MemoryEmbeddingDimensions {
+		return nil, fmt.Errorf("embeddings: expected one embedding of %d dimensions", domain.MemoryEmbeddingDimensions)
+	}
+	return response.Data[0].Embedding, nil
+}
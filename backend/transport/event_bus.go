@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// EventBus publishes realtime events and delivers them to local subscribers
+type EventBus interface {
+	domain.EventPublisher
+	domain.EventSubscriber
+}
+
+// LocalEventBus delivers events to subscribers in the same process. It is
+// sufficient when a single backend replica serves every WebSocket client.
+type LocalEventBus struct {
+	mu       sync.RWMutex
+	handlers []func(domain.Event)
+}
+
+// NewLocalEventBus creates a new LocalEventBus
+func NewLocalEventBus() *LocalEventBus {
+	return &LocalEventBus{}
+}
+
+// Subscribe registers a handler called for every published event
+func (b *LocalEventBus) Subscribe(handler func(domain.Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event to all subscribers before returning
+func (b *LocalEventBus) Publish(ctx context.Context, event domain.Event) error {
+	b.deliver(event)
+	return nil
+}
+
+// deliver calls every subscribed handler with the event
+func (b *LocalEventBus) deliver(event domain.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
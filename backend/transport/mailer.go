@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"context"
+	"log"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// LogMailer writes emails to the log instead of sending them, so password
+// reset and verification links can be followed in development without a
+// mail server
+type LogMailer struct{}
+
+// NewLogMailer creates a new LogMailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the email
+func (m *LogMailer) Send(ctx context.Context, email domain.Email) error {
+	log.Printf("[Mail] To: %s\nSubject: %s\n\n%s", email.To, email.Subject, email.Body)
+	return nil
+}
@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// blocklistCategory is the category KeywordModerator reports matches under
+const blocklistCategory = "blocklist"
+
+// KeywordModerator flags text matching a blocklist of words, phrases and
+// regular expressions
+type KeywordModerator struct {
+	patterns []*regexp.Regexp
+}
+
+// NewKeywordModerator creates a new KeywordModerator. Rules prefixed with
+// "re:" are regular expressions; the others are words or phrases matched
+// case-insensitively as a whole.
+func NewKeywordModerator(rules []string) (*KeywordModerator, error) {
+	m := &KeywordModerator{}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		expr, ok := strings.CutPrefix(rule, "re:")
+		if !ok {
+			expr = `(?i)(^|\W)` + regexp.QuoteMeta(rule) + `(\W|$)`
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("moderation: invalid rule %q: %w", rule, err)
+		}
+		m.patterns = append(m.patterns, pattern)
+	}
+	return m, nil
+}
+
+// Moderate flags text matching any rule
+func (m *KeywordModerator) Moderate(_ context.Context, text string) (domain.ModerationResult, error) {
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(text) {
+			return domain.ModerationResult{Flagged: true, Categories: []string{blocklistCategory}}, nil
+		}
+	}
+	return domain.ModerationResult{}, nil
+}
+
+const (
+	// DefaultModerationAPIURL is OpenAI's moderation endpoint
+	DefaultModerationAPIURL = "https://api.openai.com/v1/moderations"
+	// moderationAPITimeout bounds each call to the moderation API
+	moderationAPITimeout = 10 * time.Second
+)
+
+// APIModerator screens text with an OpenAI compatible moderation API
+type APIModerator struct {
+	apiKey     string
+	url        string
+	model      string
+	httpClient *http.Client
+}
+
+// NewAPIModerator creates a new APIModerator calling url, or OpenAI's
+// endpoint when it is empty. An empty model uses the API's default.
+func NewAPIModerator(apiKey, url, model string) (*APIModerator, error) {
+	if apiKey == "" {
+		return nil, errors.New("moderation: api key is required")
+	}
+	if url == "" {
+		url = DefaultModerationAPIURL
+	}
+	return &APIModerator{
+		apiKey:     apiKey,
+		url:        url,
+		model:      model,
+		httpClient: &http.Client{Timeout: moderationAPITimeout},
+	}, nil
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate asks the API whether text breaks its content policy
+func (m *APIModerator) Moderate(ctx context.Context, text string) (domain.ModerationResult, error) {
+	request := map[string]any{"input": text}
+	if m.model != "" {
+		request["model"] = m.model
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return domain.ModerationResult{}, fmt.Errorf("moderation: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url, bytes.NewReader(payload))
+	if err != nil {
+		return domain.ModerationResult{}, fmt.Errorf("moderation: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return domain.ModerationResult{}, fmt.Errorf("moderation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return domain.ModerationResult{}, fmt.Errorf("moderation: api returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var response moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return domain.ModerationResult{}, fmt.Errorf("moderation: invalid response: %w", err)
+	}
+
+	var result domain.ModerationResult
+	for _, r := range response.Results {
+		if !r.Flagged {
+			continue
+		}
+		result.Flagged = true
+		for category, flagged := range r.Categories {
+			if flagged {
+				result.Categories = append(result.Categories, category)
+			}
+		}
+	}
+	sort.Strings(result.Categories)
+	return result, nil
+}
@@ -0,0 +1,245 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// oauthHTTPTimeout bounds each call to a provider
+const oauthHTTPTimeout = 10 * time.Second
+
+// oauthClient implements the parts of the OAuth2 authorization code flow
+// that are the same for every provider
+type oauthClient struct {
+	clientID     string
+	clientSecret string
+	authURL      string
+	tokenURL     string
+	scopes       []string
+	httpClient   *http.Client
+}
+
+func newOAuthClient(clientID, clientSecret, authURL, tokenURL string, scopes ...string) oauthClient {
+	return oauthClient{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		authURL:      authURL,
+		tokenURL:     tokenURL,
+		scopes:       scopes,
+		httpClient:   &http.Client{Timeout: oauthHTTPTimeout},
+	}
+}
+
+// authCodeURL builds the provider's consent URL
+func (c oauthClient) authCodeURL(state, redirectURL string, extra url.Values) string {
+	params := url.Values{
+		"client_id":     {c.clientID},
+		"redirect_uri":  {redirectURL},
+		"response_type": {"code"},
+		"scope":         {strings.Join(c.scopes, " ")},
+		"state":         {state},
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return c.authURL + "?" + params.Encode()
+}
+
+// exchange redeems an authorization code for an access token
+func (c oauthClient) exchange(ctx context.Context, code, redirectURL string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	// GitHub reports a bad code with 200 and an error field, so both the
+	// status and the body are checked
+	if err := c.do(req, &token); err != nil && token.Error == "" {
+		return "", err
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("oauth: token exchange failed: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("oauth: token response has no access token")
+	}
+	return token.AccessToken, nil
+}
+
+// get fetches a provider API resource with the user's access token
+func (c oauthClient) get(ctx context.Context, rawURL, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return c.do(req, v)
+}
+
+// do sends req and decodes its JSON response into v, also on error statuses
+// so callers can read error fields
+func (c oauthClient) do(req *http.Request, v any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("oauth: %w", err)
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: %s %s returned %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("oauth: invalid response from %s: %w", req.URL.Redacted(), decodeErr)
+	}
+	return nil
+}
+
+// GoogleOAuthProvider signs users in with their Google account
+type GoogleOAuthProvider struct {
+	client oauthClient
+}
+
+// NewGoogleOAuthProvider creates a provider for a Google OAuth client
+func NewGoogleOAuthProvider(clientID, clientSecret string) *GoogleOAuthProvider {
+	return &GoogleOAuthProvider{client: newOAuthClient(clientID, clientSecret,
+		"https://accounts.google.com/o/oauth2/v2/auth",
+		"https://oauth2.googleapis.com/token",
+		"openid", "email", "profile",
+	)}
+}
+
+// Name returns "google"
+func (p *GoogleOAuthProvider) Name() string { return "google" }
+
+// AuthCodeURL returns Google's consent URL, asking the user to pick an
+// account so signing out of one is respected
+func (p *GoogleOAuthProvider) AuthCodeURL(state, redirectURL string) string {
+	return p.client.authCodeURL(state, redirectURL, url.Values{"prompt": {"select_account"}})
+}
+
+// Exchange redeems the code and reads the user's OpenID profile
+func (p *GoogleOAuthProvider) Exchange(ctx context.Context, code, redirectURL string) (*domain.OAuthProfile, error) {
+	accessToken, err := p.client.exchange(ctx, code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := p.client.get(ctx, "https://openidconnect.googleapis.com/v1/userinfo", accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, errors.New("oauth: google profile has no subject")
+	}
+
+	return &domain.OAuthProfile{
+		ProviderUserID: info.Sub,
+		Email:          info.Email,
+		EmailVerified:  info.EmailVerified,
+		Name:           info.Name,
+	}, nil
+}
+
+// GitHubOAuthProvider signs users in with their GitHub account
+type GitHubOAuthProvider struct {
+	client oauthClient
+}
+
+// NewGitHubOAuthProvider creates a provider for a GitHub OAuth app
+func NewGitHubOAuthProvider(clientID, clientSecret string) *GitHubOAuthProvider {
+	return &GitHubOAuthProvider{client: newOAuthClient(clientID, clientSecret,
+		"https://github.com/login/oauth/authorize",
+		"https://github.com/login/oauth/access_token",
+		"read:user", "user:email",
+	)}
+}
+
+// Name returns "github"
+func (p *GitHubOAuthProvider) Name() string { return "github" }
+
+// AuthCodeURL returns GitHub's consent URL
+func (p *GitHubOAuthProvider) AuthCodeURL(state, redirectURL string) string {
+	return p.client.authCodeURL(state, redirectURL, nil)
+}
+
+// Exchange redeems the code and reads the user's profile. The profile's
+// public email may be missing or unverified, so the primary verified
+// address is looked up separately.
+func (p *GitHubOAuthProvider) Exchange(ctx context.Context, code, redirectURL string) (*domain.OAuthProfile, error) {
+	accessToken, err := p.client.exchange(ctx, code, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.client.get(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("oauth: github profile has no id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.client.get(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &domain.OAuthProfile{
+		ProviderUserID: strconv.FormatInt(user.ID, 10),
+		Name:           user.Name,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email = e.Email
+			profile.EmailVerified = e.Verified
+			break
+		}
+	}
+	return profile, nil
+}
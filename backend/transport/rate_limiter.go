@@ -0,0 +1,91 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped from memory
+const rateLimitSweepInterval = time.Minute
+
+// LocalRateLimiter keeps token buckets in memory. Each replica enforces its
+// own limits, so it is only exact when a single replica serves all traffic.
+type LocalRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is a bucket's level at a point in time
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewLocalRateLimiter creates a new LocalRateLimiter
+func NewLocalRateLimiter() *LocalRateLimiter {
+	return &LocalRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take counts a request against key's bucket
+func (l *LocalRateLimiter) Take(ctx context.Context, key string, limit int, period time.Duration) (domain.RateLimit, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.period = period
+
+	elapsed := now.Sub(bucket.updated)
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+float64(limit)*elapsed.Seconds()/period.Seconds())
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return bucketState(bucket.tokens, allowed, limit, period), nil
+}
+
+// sweep drops buckets that have refilled completely; they are recreated
+// full on their next use
+func (l *LocalRateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= bucket.period {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// bucketState describes a bucket holding tokens after a request was counted
+func bucketState(tokens float64, allowed bool, limit int, period time.Duration) domain.RateLimit {
+	perToken := period / time.Duration(limit)
+	state := domain.RateLimit{
+		Limit:     limit,
+		Remaining: int(tokens),
+		Allowed:   allowed,
+		Reset:     time.Duration((float64(limit) - tokens) * float64(perToken)),
+	}
+	if !allowed {
+		state.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return state
+}
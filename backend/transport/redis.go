@@ -0,0 +1,184 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisConn is a minimal RESP client covering the handful of commands the
+// event bus needs (AUTH, PUBLISH, SUBSCRIBE). It is not safe for concurrent use.
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+const redisDialTimeout = 5 * time.Second
+
+// redisCommandTimeout bounds writing a command and reading its reply, so a
+// stalled server fails the command instead of blocking its caller
+const redisCommandTimeout = 5 * time.Second
+
+// dialRedis connects to a redis:// or rediss:// URL and authenticates if the
+// URL carries credentials
+func dialRedis(ctx context.Context, rawURL string) (*redisConn, error) {
+	u, err := parseRedisURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var conn net.Conn
+	if u.Scheme == "rediss" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	if u.User != nil {
+		args := []string{"AUTH"}
+		password, hasPassword := u.User.Password()
+		if username := u.User.Username(); username != "" && hasPassword {
+			args = append(args, username, password)
+		} else if hasPassword {
+			args = append(args, password)
+		} else {
+			args = append(args, username)
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// parseRedisURL validates a redis:// or rediss:// connection URL
+func parseRedisURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis url scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// Close closes the underlying connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and reads its reply within redisCommandTimeout
+func (c *redisConn) do(args ...string) (any, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(redisCommandTimeout)); err != nil {
+		return nil, err
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	// Subscriptions wait on replies indefinitely
+	if err := c.conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// send writes a command as an array of bulk strings within
+// redisCommandTimeout
+func (c *redisConn) send(args ...string) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(redisCommandTimeout)); err != nil {
+		return err
+	}
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// readReply reads one RESP value. Simple and bulk strings are returned as
+// string, integers as int64, arrays as []any and nil replies as nil. Error
+// replies are returned as a redisError.
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+	"github.com/google/uuid"
+)
+
+// redisOfficeChannelPrefix prefixes the per-office pub/sub channels. Each
+// message on synoffice:office:<office_id> is a WebSocket message ready to be
+// sent to that office's clients.
+const redisOfficeChannelPrefix = "synoffice:office:"
+
+// redisReconnectDelay is how long the subscriber waits before reconnecting
+const redisReconnectDelay = 2 * time.Second
+
+// RedisEventBus relays events through Redis pub/sub so that every backend
+// replica delivers them to its own WebSocket clients. While subscribed,
+// published events are not delivered locally directly; they arrive back
+// through the subscription like events from any other replica.
+//
+// If Redis is unreachable the bus falls back to in-memory delivery, so
+// clients connected to this replica keep receiving events, and rejoins
+// Redis as soon as the subscription is re-established.
+type RedisEventBus struct {
+	url   string
+	local *LocalEventBus
+
+	// subscribed is true while the subscription is live. Events are only
+	// published through Redis then; otherwise this replica would never
+	// receive its own events back.
+	subscribed atomic.Bool
+
+	mu  sync.Mutex // guards pub
+	pub *redisConn
+}
+
+// NewRedisEventBus creates a bus publishing through the Redis server at
+// redisURL. Call Run to connect and start receiving events.
+func NewRedisEventBus(redisURL string) (*RedisEventBus, error) {
+	if _, err := parseRedisURL(redisURL); err != nil {
+		return nil, err
+	}
+	return &RedisEventBus{
+		url:   redisURL,
+		local: NewLocalEventBus(),
+	}, nil
+}
+
+// Subscribe registers a handler called for every event received from Redis
+func (b *RedisEventBus) Subscribe(handler func(domain.Event)) {
+	b.local.Subscribe(handler)
+}
+
+// Publish sends an event to all replicas, or only to local subscribers
+// while Redis is unavailable
+func (b *RedisEventBus) Publish(ctx context.Context, event domain.Event) error {
+	if !b.subscribed.Load() {
+		b.local.deliver(event)
+		return nil
+	}
+
+	if err := b.publish(ctx, event); err != nil {
+		log.Printf("Redis publish failed, delivering %s event locally: %v", event.Type, err)
+		b.local.deliver(event)
+	}
+	return nil
+}
+
+// publish sends an event on its office channel
+func (b *RedisEventBus) publish(ctx context.Context, event domain.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pub == nil {
+		if b.pub, err = dialRedis(ctx, b.url); err != nil {
+			return err
+		}
+	}
+	if _, err := b.pub.do("PUBLISH", redisOfficeChannelPrefix+event.OfficeID.String(), string(data)); err != nil {
+		// Drop the connection so the next publish redials
+		b.pub.Close()
+		b.pub = nil
+		return err
+	}
+	return nil
+}
+
+// Run receives events from Redis and delivers them to subscribers until ctx
+// is cancelled, reconnecting whenever the subscription drops
+func (b *RedisEventBus) Run(ctx context.Context) {
+	for {
+		if err := b.receive(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Redis event bus unavailable, delivering events locally: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(redisReconnectDelay):
+		}
+	}
+}
+
+// receive subscribes to all office channels and delivers messages until the
+// connection fails or ctx is cancelled
+func (b *RedisEventBus) receive(ctx context.Context) error {
+	conn, err := dialRedis(ctx, b.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer b.subscribed.Store(false)
+
+	// Unblock the pending read on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.send("PSUBSCRIBE", redisOfficeChannelPrefix+"*"); err != nil {
+		return err
+	}
+
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			return err
+		}
+
+		// Pushed messages are ["pmessage", pattern, channel, payload]
+		msg, ok := reply.([]any)
+		if !ok || len(msg) == 0 {
+			continue
+		}
+		switch msg[0] {
+		case "psubscribe":
+			b.subscribed.Store(true)
+			log.Println("Redis event bus connected")
+		case "pmessage":
+			if len(msg) != 4 {
+				continue
+			}
+			channel, _ := msg[2].(string)
+			payload, _ := msg[3].(string)
+			b.deliver(channel, payload)
+		}
+	}
+}
+
+// deliver decodes a message received on an office channel and hands it to
+// local subscribers
+func (b *RedisEventBus) deliver(channel, payload string) {
+	officeID, err := uuid.Parse(strings.TrimPrefix(channel, redisOfficeChannelPrefix))
+	if err != nil {
+		log.Printf("Ignoring event on unexpected channel %q", channel)
+		return
+	}
+
+	var event domain.Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Failed to decode event from redis: %v", err)
+		return
+	}
+	event.OfficeID = officeID
+	b.local.deliver(event)
+}
+
+// Close releases the publishing connection
+func (b *RedisEventBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pub == nil {
+		return nil
+	}
+	err := b.pub.Close()
+	b.pub = nil
+	return err
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// redisRateLimitPrefix prefixes the bucket keys, e.g.
+// synoffice:ratelimit:office:<office_id>
+const redisRateLimitPrefix = "synoffice:ratelimit:"
+
+// tokenBucketScript refills and takes from a bucket atomically, using the
+// Redis clock so replicas agree on elapsed time. It returns whether the
+// request is allowed and the tokens left, as a string to keep the fraction.
+const tokenBucketScript = `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + (now - ts) * limit / period)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`
+
+// RedisRateLimiter keeps token buckets in Redis, so limits hold across all
+// backend replicas. While Redis is unreachable it falls back to enforcing
+// limits per replica in memory.
+type RedisRateLimiter struct {
+	url   string
+	local *LocalRateLimiter
+
+	// degraded is true while requests are metered locally; it makes the
+	// fallback log once per outage instead of once per request
+	degraded atomic.Bool
+	// retryAt is when to try Redis again during an outage, in Unix
+	// nanoseconds, so requests do not each wait on a failing dial
+	retryAt atomic.Int64
+
+	mu   sync.Mutex // guards conn
+	conn *redisConn
+}
+
+// NewRedisRateLimiter creates a limiter backed by the Redis server at
+// redisURL. It connects on first use.
+func NewRedisRateLimiter(redisURL string) (*RedisRateLimiter, error) {
+	if _, err := parseRedisURL(redisURL); err != nil {
+		return nil, err
+	}
+	return &RedisRateLimiter{
+		url:   redisURL,
+		local: NewLocalRateLimiter(),
+	}, nil
+}
+
+// Take counts a request against key's bucket
+func (l *RedisRateLimiter) Take(ctx context.Context, key string, limit int, period time.Duration) (domain.RateLimit, error) {
+	if l.degraded.Load() && time.Now().UnixNano() < l.retryAt.Load() {
+		return l.local.Take(ctx, key, limit, period)
+	}
+
+	state, err := l.take(ctx, key, limit, period)
+	if err != nil {
+		l.retryAt.Store(time.Now().Add(redisReconnectDelay).UnixNano())
+		if !l.degraded.Swap(true) {
+			log.Printf("Redis rate limiter unavailable, limiting per replica: %v", err)
+		}
+		return l.local.Take(ctx, key, limit, period)
+	}
+	if l.degraded.Swap(false) {
+		log.Println("Redis rate limiter reconnected")
+	}
+	return state, nil
+}
+
+// take runs the token bucket script for key
+func (l *RedisRateLimiter) take(ctx context.Context, key string, limit int, period time.Duration) (domain.RateLimit, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+	if l.conn == nil {
+		if l.conn, err = dialRedis(ctx, l.url); err != nil {
+			return domain.RateLimit{}, err
+		}
+	}
+
+	reply, err := l.conn.do("EVAL", tokenBucketScript, "1", redisRateLimitPrefix+key,
+		strconv.Itoa(limit), strconv.FormatInt(period.Milliseconds(), 10))
+	if err != nil {
+		// Drop the connection so the next request redials
+		l.conn.Close()
+		l.conn = nil
+		return domain.RateLimit{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return domain.RateLimit{}, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return domain.RateLimit{}, fmt.Errorf("redis: unexpected token count %v", values[1])
+	}
+	return bucketState(tokens, allowed == 1, limit, period), nil
+}
+
+// Close closes the Redis connection
+func (l *RedisRateLimiter) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn = nil
+	return err
+}
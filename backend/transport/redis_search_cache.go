@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+// redisSearchCachePrefix prefixes the cached results' keys, e.g.
+// synoffice:websearch:<query hash>
+const redisSearchCachePrefix = "synoffice:websearch:"
+
+// RedisSearchCache keeps web search results in Redis, which expires them
+// itself
+type RedisSearchCache struct {
+	url string
+
+	mu   sync.Mutex // guards conn
+	conn *redisConn
+}
+
+// NewRedisSearchCache creates a cache backed by the Redis server at
+// redisURL. It connects on first use.
+func NewRedisSearchCache(redisURL string) (*RedisSearchCache, error) {
+	if _, err := parseRedisURL(redisURL); err != nil {
+		return nil, err
+	}
+	return &RedisSearchCache{url: redisURL}, nil
+}
+
+// Get returns the results cached for key
+func (c *RedisSearchCache) Get(ctx context.Context, key string) ([]domain.WebSearchResult, bool, error) {
+	reply, err := c.do(ctx, "GET", redisSearchCachePrefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected search cache reply %v", reply)
+	}
+	var results []domain.WebSearchResult
+	if err := json.Unmarshal([]byte(raw), &results); err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// Set caches results for key until the TTL passes
+func (c *RedisSearchCache) Set(ctx context.Context, key string, results []domain.WebSearchResult, ttl time.Duration) error {
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, "SET", redisSearchCachePrefix+key, string(resultsJSON), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// PurgeExpired does nothing, as Redis expires the results itself
+func (c *RedisSearchCache) PurgeExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// do runs a command, dialing first if there is no connection
+func (c *RedisSearchCache) do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	if c.conn == nil {
+		if c.conn, err = dialRedis(ctx, c.url); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.conn.do(args...)
+	if err != nil {
+		// Drop the connection so the next command redials
+		c.conn.Close()
+		c.conn = nil
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Close closes the Redis connection
+func (c *RedisSearchCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,296 @@
+package transport
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+const (
+	// s3HTTPTimeout bounds each call to the storage, including the upload
+	// or download of the object
+	s3HTTPTimeout = 5 * time.Minute
+	// s3UnsignedPayload skips hashing request bodies, which would mean
+	// reading uploads twice; requests are still signed
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// s3MaxPresignTTL is the longest validity Signature Version 4 allows
+	s3MaxPresignTTL = 7 * 24 * time.Hour
+)
+
+// S3Config configures an S3Storage
+type S3Config struct {
+	// Endpoint is the storage's base URL, e.g. http://minio:9000. It
+	// defaults to AWS S3 in Region.
+	Endpoint string
+	// PublicEndpoint, when set, is the base URL used in pre-signed URLs,
+	// for storages that clients reach under another name than the backend
+	PublicEndpoint  string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// as MinIO expects
+	PathStyle bool
+}
+
+// S3Storage keeps objects in an S3 compatible bucket, such as AWS S3 or
+// MinIO. Requests are authenticated with AWS Signature Version 4.
+type S3Storage struct {
+	cfg        S3Config
+	endpoint   *url.URL
+	public     *url.URL
+	httpClient *http.Client
+}
+
+// NewS3Storage creates a storage for the configured bucket
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access key ID and secret access key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+
+	endpoint, err := parseS3Endpoint(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	public := endpoint
+	if cfg.PublicEndpoint != "" {
+		if public, err = parseS3Endpoint(cfg.PublicEndpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3Storage{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		public:     public,
+		httpClient: &http.Client{Timeout: s3HTTPTimeout},
+	}, nil
+}
+
+func parseS3Endpoint(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimRight(raw, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", raw)
+	}
+	return u, nil
+}
+
+// Put uploads the object
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", s.objectURL(s.endpoint, key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads the object
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.objectURL(s.endpoint, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete deletes the object; missing objects are not an error
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", s.objectURL(s.endpoint, key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PresignGet returns a URL that downloads the object as an attachment
+// named filename
+func (s *S3Storage) PresignGet(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > s3MaxPresignTTL {
+		return "", fmt.Errorf("s3: presigned URLs must expire within %s", s3MaxPresignTTL)
+	}
+
+	u, err := url.Parse(s.objectURL(s.public, key))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	query := map[string]string{
+		"X-Amz-Algorithm":              "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":             s.cfg.AccessKeyID + "/" + s.scope(now),
+		"X-Amz-Date":                   now.Format("20060102T150405Z"),
+		"X-Amz-Expires":                strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders":          "host",
+		"response-content-disposition": mime.FormatMediaType("attachment", map[string]string{"filename": filename}),
+	}
+	u.RawQuery = canonicalS3Query(query)
+
+	signature := s.signature(now, "GET", u, []string{"host:" + u.Host}, "host", s3UnsignedPayload)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// do signs and sends req. Error statuses are returned as errors, 404 as
+// ErrNotFound, and their bodies are closed.
+func (s *S3Storage) do(req *http.Request) (*http.Response, error) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	headers := []string{
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + s3UnsignedPayload,
+		"x-amz-date:" + amzDate,
+	}
+	signature := s.signature(now, req.Method, req.URL, headers, signedHeaders, s3UnsignedPayload)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, s.scope(now), signedHeaders, signature))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, domain.ErrNotFound
+	}
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = xml.Unmarshal(body, &s3Err)
+	return nil, fmt.Errorf("s3: %s %s returned %d %s %s", req.Method, req.URL.Path, resp.StatusCode, s3Err.Code, s3Err.Message)
+}
+
+// objectURL returns the object's URL under base, with the key escaped the
+// way signatures expect
+func (s *S3Storage) objectURL(base *url.URL, key string) string {
+	u := *base
+	path := "/" + s3Escape(key, false)
+	if s.cfg.PathStyle {
+		path = "/" + s3Escape(s.cfg.Bucket, true) + path
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	return u.Scheme + "://" + u.Host + u.EscapedPath() + path
+}
+
+// scope is the credential scope of requests signed at t
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature computes the Signature Version 4 signature of a request. The
+// headers are the canonical "name:value" lines of signedHeaders, in order.
+func (s *S3Storage) signature(t time.Time, method string, u *url.URL, headers []string, signedHeaders, payloadHash string) string {
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + s.scope(t) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalS3Query encodes query parameters sorted by name, as signatures
+// expect
+func canonicalS3Query(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = s3Escape(name, true) + "=" + s3Escape(params[name], true)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters and,
+// unless encodeSlash is set, slashes
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
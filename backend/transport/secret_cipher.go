@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// aesCipherVersion prefixes AESCipher ciphertexts, so the format or key can
+// change without losing what was sealed before
+const aesCipherVersion byte = 1
+
+// AESCipher seals secrets with AES-256-GCM under a master key
+type AESCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESCipher creates a new AESCipher from a base64 encoded 32 byte master
+// key, such as one made with `openssl rand -base64 32`
+func NewAESCipher(masterKey string) (*AESCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: master key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets: master key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESCipher{aead: aead}, nil
+}
+
+// Seal encrypts plaintext under a random nonce, which is kept in front of
+// the ciphertext
+func (c *AESCipher) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	out := make([]byte, 1+c.aead.NonceSize(), 1+c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	out[0] = aesCipherVersion
+	if _, err := rand.Read(out[1:]); err != nil {
+		return nil, fmt.Errorf("secrets: generate nonce: %w", err)
+	}
+	return c.aead.Seal(out, out[1:], plaintext, additionalData), nil
+}
+
+// Open decrypts a ciphertext made by Seal with the same additional data
+func (c *AESCipher) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	headerSize := 1 + c.aead.NonceSize()
+	if len(ciphertext) < headerSize+c.aead.Overhead() || ciphertext[0] != aesCipherVersion {
+		return nil, errors.New("secrets: malformed ciphertext")
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[1:headerSize], ciphertext[headerSize:], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: decrypt: %w", err)
+	}
+	return plaintext, nil
+}
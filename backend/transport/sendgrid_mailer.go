@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+const (
+	sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+	// sendGridTimeout bounds each call to SendGrid
+	sendGridTimeout = 15 * time.Second
+)
+
+// SendGridMailer sends email through SendGrid's v3 mail API
+type SendGridMailer struct {
+	apiKey     string
+	from       *mail.Address
+	httpClient *http.Client
+}
+
+// NewSendGridMailer creates a new SendGridMailer. from is the sender, e.g.
+// "Synoffice <no-reply@example.com>", and must be verified with SendGrid.
+func NewSendGridMailer(apiKey, from string) (*SendGridMailer, error) {
+	if apiKey == "" {
+		return nil, errors.New("sendgrid: api key is required")
+	}
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("sendgrid: invalid from address %q: %w", from, err)
+	}
+	return &SendGridMailer{
+		apiKey:     apiKey,
+		from:       address,
+		httpClient: &http.Client{Timeout: sendGridTimeout},
+	}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Send delivers the email
+func (m *SendGridMailer) Send(ctx context.Context, email domain.Email) error {
+	// SendGrid requires text/plain to come before text/html
+	content := []sendGridContent{{Type: "text/plain", Value: email.Body}}
+	if email.HTMLBody != "" {
+		content = append(content, sendGridContent{Type: "text/html", Value: email.HTMLBody})
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{
+			{"to": []sendGridAddress{{Email: email.To}}},
+		},
+		"from":    sendGridAddress{Email: m.from.Address, Name: m.from.Name},
+		"subject": email.Subject,
+		"content": content,
+	})
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sendGridURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// SendGrid accepts mail with 202 and explains rejections in the body
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("sendgrid: send returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
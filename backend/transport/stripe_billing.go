@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denys89/syn-office/backend/domain"
+)
+
+const (
+	stripeAPIURL = "https://api.stripe.com/v1"
+	// stripeTimeout bounds each call to Stripe
+	stripeTimeout = 15 * time.Second
+)
+
+// StripeBilling manages subscriptions through Stripe's API
+type StripeBilling struct {
+	secretKey  string
+	httpClient *http.Client
+}
+
+// NewStripeBilling creates a new StripeBilling authenticated with the
+// account's secret key
+func NewStripeBilling(secretKey string) (*StripeBilling, error) {
+	if secretKey == "" {
+		return nil, errors.New("stripe: secret key is required")
+	}
+	return &StripeBilling{
+		secretKey:  secretKey,
+		httpClient: &http.Client{Timeout: stripeTimeout},
+	}, nil
+}
+
+// CancelSubscription cancels the subscription now, or at the end of its
+// current period
+func (b *StripeBilling) CancelSubscription(ctx context.Context, subscriptionID string, atPeriodEnd bool) error {
+	if atPeriodEnd {
+		return b.updateSubscription(ctx, "POST", subscriptionID, url.Values{"cancel_at_period_end": {"true"}})
+	}
+	return b.updateSubscription(ctx, "DELETE", subscriptionID, nil)
+}
+
+// PauseSubscription voids the subscription's invoices until it is resumed
+func (b *StripeBilling) PauseSubscription(ctx context.Context, subscriptionID string) error {
+	return b.updateSubscription(ctx, "POST", subscriptionID, url.Values{"pause_collection[behavior]": {"void"}})
+}
+
+// ResumeSubscription collects payments for the subscription again
+func (b *StripeBilling) ResumeSubscription(ctx context.Context, subscriptionID string) error {
+	// An empty value unsets pause_collection
+	return b.updateSubscription(ctx, "POST", subscriptionID, url.Values{"pause_collection": {""}})
+}
+
+// ListInvoices returns the customer's most recent invoices
+func (b *StripeBilling) ListInvoices(ctx context.Context, customerID string, limit int) ([]*domain.Invoice, error) {
+	query := url.Values{"customer": {customerID}, "limit": {strconv.Itoa(limit)}}
+	var list struct {
+		Data []stripeInvoice `json:"data"`
+	}
+	if err := b.do(ctx, "GET", "/invoices?"+query.Encode(), nil, &list); err != nil {
+		return nil, err
+	}
+
+	invoices := make([]*domain.Invoice, len(list.Data))
+	for i := range list.Data {
+		invoices[i] = list.Data[i].invoice()
+	}
+	return invoices, nil
+}
+
+// UpcomingInvoice previews the customer's next invoice, including invoice
+// items such as add-on credit purchases that are waiting to be billed
+func (b *StripeBilling) UpcomingInvoice(ctx context.Context, customerID string) (*domain.Invoice, error) {
+	query := url.Values{"customer": {customerID}}
+	var upcoming stripeInvoice
+	err := b.do(ctx, "GET", "/invoices/upcoming?"+query.Encode(), nil, &upcoming)
+	var stripeErr *stripeError
+	if errors.As(err, &stripeErr) && stripeErr.Code == "invoice_upcoming_none" {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := upcoming.invoice()
+	invoice.Status = domain.InvoiceStatusUpcoming
+	return invoice, nil
+}
+
+// RefundPayment refunds the payment intent's charge in full
+func (b *StripeBilling) RefundPayment(ctx context.Context, paymentIntentID, idempotencyKey string) (string, error) {
+	var refund struct {
+		ID string `json:"id"`
+	}
+	form := url.Values{"payment_intent": {paymentIntentID}}
+	if err := b.doIdempotent(ctx, "POST", "/refunds", form, idempotencyKey, &refund); err != nil {
+		return "", err
+	}
+	return refund.ID, nil
+}
+
+// ChargePaymentMethod creates and confirms a payment intent charging the
+// customer's saved payment method off-session. Stripe refusing the payment
+// method, or asking the customer to authenticate it, is
+// domain.ErrPaymentDeclined.
+func (b *StripeBilling) ChargePaymentMethod(ctx context.Context, charge domain.PaymentCharge) (string, error) {
+	form := url.Values{
+		"customer":       {charge.CustomerID},
+		"payment_method": {charge.PaymentMethodID},
+		"amount":         {strconv.FormatInt(charge.AmountCents, 10)},
+		"currency":       {charge.Currency},
+		"description":    {charge.Description},
+		"confirm":        {"true"},
+		"off_session":    {"true"},
+	}
+	for key, value := range charge.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+
+	var intent struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	err := b.doIdempotent(ctx, "POST", "/payment_intents", form, charge.IdempotencyKey, &intent)
+	var stripeErr *stripeError
+	if errors.As(err, &stripeErr) && stripeErr.StatusCode == http.StatusPaymentRequired {
+		return "", fmt.Errorf("%w: %s", domain.ErrPaymentDeclined, stripeErr.Message)
+	}
+	if err != nil {
+		return "", err
+	}
+	if intent.Status != domain.PaymentIntentSucceeded {
+		return "", fmt.Errorf("%w: payment %s is %s", domain.ErrPaymentDeclined, intent.ID, intent.Status)
+	}
+	return intent.ID, nil
+}
+
+// GetPaymentIntent retrieves a payment intent
+func (b *StripeBilling) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*domain.PaymentIntent, error) {
+	var intent struct {
+		ID       string            `json:"id"`
+		Status   string            `json:"status"`
+		Amount   int64             `json:"amount"`
+		Currency string            `json:"currency"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	err := b.do(ctx, "GET", "/payment_intents/"+url.PathEscape(paymentIntentID), nil, &intent)
+	var stripeErr *stripeError
+	if errors.As(err, &stripeErr) && stripeErr.StatusCode == http.StatusNotFound {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain.PaymentIntent{
+		ID:          intent.ID,
+		Status:      intent.Status,
+		AmountCents: intent.Amount,
+		Currency:    intent.Currency,
+		Metadata:    intent.Metadata,
+	}, nil
+}
+
+// updateSubscription sends a form encoded request for a subscription
+func (b *StripeBilling) updateSubscription(ctx context.Context, method, subscriptionID string, form url.Values) error {
+	return b.do(ctx, method, "/subscriptions/"+url.PathEscape(subscriptionID), form, nil)
+}
+
+// stripeError is an error response from Stripe
+type stripeError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Code and Message are Stripe's explanation, if it gave one
+	Code    string
+	Message string
+}
+
+func (e *stripeError) Error() string {
+	return fmt.Sprintf("stripe: %s %s returned %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// do sends a form encoded request to Stripe and decodes the response into
+// out, if given
+func (b *StripeBilling) do(ctx context.Context, method, path string, form url.Values, out any) error {
+	return b.doIdempotent(ctx, method, path, form, "", out)
+}
+
+// doIdempotent is do with an idempotency key, which makes Stripe answer
+// retries of a request with the first one's response
+func (b *StripeBilling) doIdempotent(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, stripeAPIURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("stripe: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		// Stripe explains failures in error.code and error.message
+		var errBody struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		// The query string may hold customer IDs; leave it out of errors
+		path, _, _ = strings.Cut(path, "?")
+		stripeErr := &stripeError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(body))}
+		if json.Unmarshal(body, &errBody) == nil && errBody.Error.Message != "" {
+			stripeErr.Code = errBody.Error.Code
+			stripeErr.Message = errBody.Error.Message
+		}
+		return stripeErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("stripe: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// stripeInvoice is the part of Stripe's invoice object the API reports.
+// Times are Unix seconds and amounts are in the currency's smallest unit.
+type stripeInvoice struct {
+	ID               string `json:"id"`
+	Number           string `json:"number"`
+	Status           string `json:"status"`
+	Currency         string `json:"currency"`
+	Total            int64  `json:"total"`
+	AmountPaid       int64  `json:"amount_paid"`
+	PeriodStart      int64  `json:"period_start"`
+	PeriodEnd        int64  `json:"period_end"`
+	Created          int64  `json:"created"`
+	DueDate          *int64 `json:"due_date"`
+	NextPaymentAt    *int64 `json:"next_payment_attempt"`
+	HostedInvoiceURL string `json:"hosted_invoice_url"`
+	InvoicePDF       string `json:"invoice_pdf"`
+	Transitions      struct {
+		PaidAt *int64 `json:"paid_at"`
+	} `json:"status_transitions"`
+	Lines struct {
+		Data []struct {
+			Description string `json:"description"`
+			Quantity    int64  `json:"quantity"`
+			Amount      int64  `json:"amount"`
+		} `json:"data"`
+	} `json:"lines"`
+}
+
+// invoice converts the Stripe invoice
+func (si *stripeInvoice) invoice() *domain.Invoice {
+	invoice := &domain.Invoice{
+		ID:              si.ID,
+		Source:          domain.InvoiceSourceStripe,
+		Number:          si.Number,
+		Status:          domain.InvoiceStatus(si.Status),
+		Currency:        si.Currency,
+		AmountCents:     si.Total,
+		AmountPaidCents: si.AmountPaid,
+		PeriodStart:     time.Unix(si.PeriodStart, 0),
+		PeriodEnd:       time.Unix(si.PeriodEnd, 0),
+		Lines:           make([]domain.InvoiceLine, len(si.Lines.Data)),
+		HostedURL:       si.HostedInvoiceURL,
+		PDFURL:          si.InvoicePDF,
+		CreatedAt:       time.Unix(si.Created, 0),
+		DueAt:           unixTime(si.DueDate),
+		PaidAt:          unixTime(si.Transitions.PaidAt),
+	}
+	// Upcoming invoices have no due date; they are charged at the next
+	// payment attempt
+	if invoice.DueAt == nil {
+		invoice.DueAt = unixTime(si.NextPaymentAt)
+	}
+	for i, line := range si.Lines.Data {
+		invoice.Lines[i] = domain.InvoiceLine{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			AmountCents: line.Amount,
+		}
+	}
+	return invoice
+}
+
+// unixTime converts optional Unix seconds
+func unixTime(seconds *int64) *time.Time {
+	if seconds == nil {
+		return nil
+	}
+	t := time.Unix(*seconds, 0)
+	return &t
+}
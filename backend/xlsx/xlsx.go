@@ -0,0 +1,169 @@
+// Package xlsx writes a single worksheet as an Excel (Office Open XML)
+// workbook by hand. Rows are streamed into the archive as they are written,
+// so a sheet never has to fit in memory. Cells are numbers or inline
+// strings; there are no styles, formulas or shared strings.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRows is the most rows a worksheet can hold
+const maxRows = 1 << 20
+
+// ErrTooManyRows is returned by WriteRow once the sheet is full
+var ErrTooManyRows = fmt.Errorf("xlsx: a sheet holds at most %d rows", maxRows)
+
+// The parts of the package besides the worksheet
+const (
+	contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	sheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetEnd = `</sheetData></worksheet>`
+)
+
+// Writer writes a workbook with one worksheet, a row at a time
+type Writer struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+	err   error
+}
+
+// NewWriter starts a workbook on w whose only sheet is named sheetName.
+// Close must be called to finish it.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	z := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+	}
+	for _, part := range parts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sheet comes last so that rows can be written into it until Close
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	sheet.WriteString(sheetStart)
+	return &Writer{zip: z, sheet: sheet}, nil
+}
+
+// WriteRow appends a row. Integers and floats are written as numbers, times
+// as RFC 3339 text and anything else as its fmt.Sprint text.
+func (w *Writer) WriteRow(cells ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.rows == maxRows {
+		return ErrTooManyRows
+	}
+	w.rows++
+
+	fmt.Fprintf(w.sheet, `<row r="%d">`, w.rows)
+	for _, cell := range cells {
+		if number, ok := numberText(cell); ok {
+			fmt.Fprintf(w.sheet, `<c><v>%s</v></c>`, number)
+			continue
+		}
+		var text string
+		switch v := cell.(type) {
+		case string:
+			text = v
+		case time.Time:
+			text = v.UTC().Format(time.RFC3339)
+		case nil:
+		default:
+			text = fmt.Sprint(v)
+		}
+		fmt.Fprintf(w.sheet, `<c t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, escape(text))
+	}
+	_, w.err = w.sheet.WriteString(`</row>`)
+	return w.err
+}
+
+// Flush writes buffered rows to the archive. The archive compresses them,
+// so not all of them necessarily reach the underlying writer yet.
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.sheet.Flush()
+	}
+	if w.err == nil {
+		w.err = w.zip.Flush()
+	}
+	return w.err
+}
+
+// Close finishes the sheet and the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.sheet.WriteString(sheetEnd)
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// numberText formats numeric cells
+func numberText(cell any) (string, bool) {
+	switch v := cell.(type) {
+	case int:
+		return strconv.Itoa(v), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// escape escapes text for XML. Characters XML cannot hold, such as most
+// control characters, become U+FFFD.
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -18,7 +18,8 @@ interface Review {
 }
 
 interface ReviewsResponse {
-    reviews: Review[];
+    items: Review[];
+    total: number;
 }
 
 const roleColors: Record<string, string> = {
@@ -181,7 +182,7 @@ export default function AgentDetailPage() {
         try {
             const res = await fetch(`${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'}/api/v1/marketplace/agents/${agentId}/reviews`);
             const data: ReviewsResponse = await res.json();
-            setReviews(data.reviews || []);
+            setReviews(data.items || []);
         } catch (error) {
             console.error('Failed to load reviews:', error);
         }
@@ -233,7 +233,7 @@ function PlanCard({
     loading,
 }: {
     name: string;
-    price: number;
+    price: number | null;
     credits: number;
     features: string[];
     isCurrent: boolean;
@@ -259,11 +259,17 @@ function PlanCard({
             <div className="text-center pt-4">
                 <h3 className="text-xl font-bold">{name}</h3>
                 <div className="mt-4">
-                    <span className="text-4xl font-bold">${(price / 100).toFixed(0)}</span>
-                    <span className="text-[var(--muted)]">/month</span>
+                    {price === null ? (
+                        <span className="text-4xl font-bold">Custom</span>
+                    ) : (
+                        <>
+                            <span className="text-4xl font-bold">${(price / 100).toFixed(0)}</span>
+                            <span className="text-[var(--muted)]">/month</span>
+                        </>
+                    )}
                 </div>
                 <div className="text-sm text-[var(--muted)] mt-1">
-                    {credits.toLocaleString()} credits/month
+                    {credits < 0 ? 'Unlimited' : credits.toLocaleString()} credits/month
                 </div>
             </div>
             <ul className="mt-6 space-y-3">
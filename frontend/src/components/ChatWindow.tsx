@@ -21,9 +21,10 @@ export function ChatWindow({ conversationId, agents, userId }: ChatWindowProps)
     useEffect(() => {
         loadMessages();
 
-        // Subscribe to new messages from agents
+        // Subscribe to new messages from agents and other sessions
         const unsubMessage = wsClient.on('new_message', (data: WSMessage) => {
             const payload = data.payload as {
+                message_id?: string;
                 conversation_id: string;
                 sender_type: string;
                 sender_id: string;
@@ -33,7 +34,7 @@ export function ChatWindow({ conversationId, agents, userId }: ChatWindowProps)
             // Only add messages for this conversation
             if (payload.conversation_id === conversationId) {
                 const newMessage: Message = {
-                    id: `ws-${Date.now()}`,
+                    id: payload.message_id || `ws-${Date.now()}`,
                     office_id: '',
                     conversation_id: conversationId,
                     sender_type: payload.sender_type as 'user' | 'agent',
@@ -41,14 +42,25 @@ export function ChatWindow({ conversationId, agents, userId }: ChatWindowProps)
                     content: payload.content,
                     created_at: new Date().toISOString(),
                 };
-                setMessages((prev) => [...prev, newMessage]);
+                setMessages((prev) => {
+                    // Our own messages are already shown optimistically
+                    if (prev.some((m) => m.id === newMessage.id)) return prev;
+                    if (
+                        newMessage.sender_type === 'user' &&
+                        newMessage.sender_id === userId &&
+                        prev.some((m) => m.id.startsWith('temp-') && m.content === newMessage.content)
+                    ) {
+                        return prev;
+                    }
+                    return [...prev, newMessage];
+                });
             }
         });
 
         return () => {
             unsubMessage();
         };
-    }, [conversationId]);
+    }, [conversationId, userId]);
 
     useEffect(() => {
         scrollToBottom();
@@ -57,7 +69,7 @@ export function ChatWindow({ conversationId, agents, userId }: ChatWindowProps)
     const loadMessages = async () => {
         setIsLoading(true);
         try {
-            const { messages: loadedMessages } = await api.getMessages(conversationId);
+            const { items: loadedMessages } = await api.getMessages(conversationId);
             setMessages(loadedMessages || []);
         } catch (error) {
             console.error('Failed to load messages:', error);
@@ -91,8 +103,12 @@ export function ChatWindow({ conversationId, agents, userId }: ChatWindowProps)
 
         try {
             const message = await api.sendMessage(conversationId, content);
-            // Replace temp message with real one
-            setMessages((prev) => prev.map((m) => (m.id === tempMessage.id ? message : m)));
+            // Replace temp message with real one, unless it already arrived over the WebSocket
+            setMessages((prev) =>
+                prev.some((m) => m.id === message.id)
+                    ? prev.filter((m) => m.id !== tempMessage.id)
+                    : prev.map((m) => (m.id === tempMessage.id ? message : m))
+            );
         } catch (error) {
             console.error('Failed to send message:', error);
             // Remove temp message on error
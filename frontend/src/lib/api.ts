@@ -53,8 +53,8 @@ class ApiClient {
         });
 
         if (!response.ok) {
-            const error = await response.json().catch(() => ({ error: 'Request failed' }));
-            throw new Error(error.error || 'Request failed');
+            const error = await response.json().catch(() => ({ message: 'Request failed' }));
+            throw new Error(error.message || 'Request failed');
         }
 
         return response.json();
@@ -119,9 +119,11 @@ class ApiClient {
         });
     }
 
-    async getMessages(conversationId: string, limit = 50, offset = 0) {
-        return this.request<{ messages: Message[] }>(
-            `/conversations/${conversationId}/messages?limit=${limit}&offset=${offset}`
+    async getMessages(conversationId: string, limit = 50, cursor?: string) {
+        const params = new URLSearchParams({ limit: String(limit) });
+        if (cursor) params.set('cursor', cursor);
+        return this.request<Page<Message>>(
+            `/conversations/${conversationId}/messages?${params}`
         );
     }
 
@@ -266,6 +268,12 @@ export interface Conversation {
     updated_at: string;
 }
 
+export interface Page<T> {
+    items: T[];
+    total: number;
+    next_cursor?: string;
+}
+
 export interface Message {
     id: string;
     office_id: string;
@@ -336,7 +344,9 @@ export interface Wallet {
 export interface Tier {
     id: string;
     name: string;
-    price_monthly_cents: number;
+    // null for tiers sold on request
+    price_monthly_cents: number | null;
+    description: string;
     credits_per_period: number;
     features: string[];
 }
@@ -0,0 +1,25 @@
+-- Office Notifications
+-- Migration: 009_notifications.sql
+-- Persists notifications (budget alerts, etc.) so they survive WebSocket reconnects
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+
+    -- Notification type: budget_alert
+    notification_type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    payload JSONB DEFAULT '{}',
+
+    is_read BOOLEAN NOT NULL DEFAULT FALSE,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_office_created ON notifications(office_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_office_unread ON notifications(office_id) WHERE is_read = FALSE;
+
+-- Speeds up per-period consumption sums used for budget alerts
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_wallet_type_created
+    ON credit_transactions(wallet_id, transaction_type, created_at DESC);
@@ -0,0 +1,15 @@
+-- Marketplace Moderation
+-- Migration: 010_marketplace_moderation.sql
+-- Adds user roles for admin access and review tracking for template moderation
+
+-- User roles: user, admin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
+
+-- Moderation outcome on templates
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+
+-- Moderation queue lookups
+CREATE INDEX IF NOT EXISTS idx_agent_templates_pending ON agent_templates(created_at) WHERE status = 'pending';
@@ -0,0 +1,32 @@
+-- Template Purchase Entitlements
+-- Migration: 011_template_purchases.sql
+-- Tracks which offices own which premium templates
+
+CREATE TABLE IF NOT EXISTS template_purchases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    purchased_by UUID NOT NULL REFERENCES users(id),
+    earning_id UUID REFERENCES author_earnings(id) ON DELETE SET NULL,
+    price_cents INT NOT NULL DEFAULT 0,
+
+    -- Status: active, refunded
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT unique_office_template_purchase UNIQUE (office_id, template_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_purchases_office ON template_purchases(office_id);
+CREATE INDEX IF NOT EXISTS idx_template_purchases_template ON template_purchases(template_id);
+
+-- Backfill entitlements from sales recorded before this table existed
+INSERT INTO template_purchases (office_id, template_id, purchased_by, earning_id, price_cents, status, created_at)
+SELECT DISTINCT ON (purchaser_office_id, template_id)
+    purchaser_office_id, template_id, purchaser_id, id, sale_amount_cents,
+    CASE WHEN status = 'refunded' THEN 'refunded' ELSE 'active' END,
+    created_at
+FROM author_earnings
+ORDER BY purchaser_office_id, template_id, created_at DESC
+ON CONFLICT (office_id, template_id) DO NOTHING;
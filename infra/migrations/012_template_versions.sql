@@ -0,0 +1,35 @@
+-- Template Version History
+-- Migration: 012_template_versions.sql
+-- Snapshots each published template version and pins agents to the version they run
+
+CREATE TABLE IF NOT EXISTS template_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    version VARCHAR(20) NOT NULL,
+
+    -- Snapshot of the behaviour-defining fields at publish time
+    system_prompt TEXT NOT NULL,
+    skill_tags JSONB NOT NULL DEFAULT '[]',
+
+    changelog TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT unique_template_version UNIQUE (template_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_versions_template ON template_versions(template_id, created_at DESC);
+
+-- Version of the template an agent was instantiated from (or last upgraded to)
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS template_version VARCHAR(20);
+
+-- Snapshot the current state of every template as its initial version
+INSERT INTO template_versions (template_id, version, system_prompt, skill_tags, changelog, created_at)
+SELECT id, COALESCE(version, '1.0.0'), system_prompt, COALESCE(skill_tags, '[]'), 'Initial version', COALESCE(updated_at, created_at)
+FROM agent_templates
+ON CONFLICT (template_id, version) DO NOTHING;
+
+-- Existing agents run whatever version their template is currently on
+UPDATE agents a
+SET template_version = COALESCE(t.version, '1.0.0')
+FROM agent_templates t
+WHERE a.template_id = t.id AND a.template_version IS NULL;
@@ -0,0 +1,28 @@
+-- Review Engagement
+-- Migration: 013_review_engagement.sql
+-- Helpful/unhelpful votes on reviews and a single author reply per review
+
+CREATE TABLE IF NOT EXISTS review_votes (
+    review_id UUID NOT NULL REFERENCES agent_reviews(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    is_helpful BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (review_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS review_replies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    review_id UUID NOT NULL REFERENCES agent_reviews(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reply_text TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- Authors get one reply per review; edits update it in place
+    CONSTRAINT unique_review_reply UNIQUE (review_id)
+);
+
+-- Verified-purchase lookups join reviews against purchases by template
+CREATE INDEX IF NOT EXISTS idx_template_purchases_template_buyer ON template_purchases(template_id, purchased_by);
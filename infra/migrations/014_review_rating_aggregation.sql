@@ -0,0 +1,39 @@
+-- Review Rating Aggregation
+-- Migration: 014_review_rating_aggregation.sql
+-- Keeps rating_average/rating_count in sync across review edits and deletions
+
+-- Recompute both the old and new template so a row is never double-counted
+CREATE OR REPLACE FUNCTION update_template_rating()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE agent_templates SET
+            rating_average = COALESCE((SELECT AVG(rating)::DECIMAL(3,2) FROM agent_reviews WHERE template_id = OLD.template_id), 0),
+            rating_count = (SELECT COUNT(*) FROM agent_reviews WHERE template_id = OLD.template_id)
+        WHERE id = OLD.template_id;
+    END IF;
+
+    IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND NEW.template_id IS DISTINCT FROM OLD.template_id) THEN
+        UPDATE agent_templates SET
+            rating_average = COALESCE((SELECT AVG(rating)::DECIMAL(3,2) FROM agent_reviews WHERE template_id = NEW.template_id), 0),
+            rating_count = (SELECT COUNT(*) FROM agent_reviews WHERE template_id = NEW.template_id)
+        WHERE id = NEW.template_id;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Text-only edits don't change the aggregate
+DROP TRIGGER IF EXISTS update_template_rating_on_review ON agent_reviews;
+CREATE TRIGGER update_template_rating_on_review
+AFTER INSERT OR DELETE OR UPDATE OF rating, template_id ON agent_reviews
+FOR EACH ROW EXECUTE FUNCTION update_template_rating();
+
+-- Repair aggregates for templates whose reviews predate the trigger
+UPDATE agent_templates t SET
+    rating_average = COALESCE((SELECT AVG(rating)::DECIMAL(3,2) FROM agent_reviews WHERE template_id = t.id), 0),
+    rating_count = (SELECT COUNT(*) FROM agent_reviews WHERE template_id = t.id);
@@ -0,0 +1,9 @@
+-- Keyset Pagination Indexes
+-- Migration: 015_keyset_pagination.sql
+-- Supports (created_at, id) cursors for message and credit transaction history
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_id
+    ON messages(conversation_id, created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_wallet_created_id
+    ON credit_transactions(wallet_id, created_at DESC, id DESC);
@@ -0,0 +1,16 @@
+-- Task Retries
+-- Migration: 016_task_retries.sql
+-- Retry bookkeeping for orchestrator dispatch and a dead_letter state for
+-- tasks that exhausted their attempts
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS max_attempts INTEGER NOT NULL DEFAULT 3;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'thinking', 'working', 'done', 'failed', 'dead_letter'));
+
+-- Dispatch queue scanned by the retry worker
+CREATE INDEX IF NOT EXISTS idx_tasks_dispatch_queue ON tasks(created_at)
+    WHERE status = 'pending' OR (status = 'failed' AND next_attempt_at IS NOT NULL);
@@ -0,0 +1,10 @@
+-- Task Cancellation
+-- Migration: 017_task_cancellation.sql
+-- Lets users cancel tasks and supports filtered task listings per office
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'thinking', 'working', 'done', 'failed', 'dead_letter', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS idx_tasks_office_created_id
+    ON tasks(office_id, created_at DESC, id DESC);
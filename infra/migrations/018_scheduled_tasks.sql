@@ -0,0 +1,56 @@
+-- Scheduled Tasks
+-- Migration: 018_scheduled_tasks.sql
+-- Lets offices schedule agent work at a fixed time or on a recurring cron schedule
+
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+
+    name VARCHAR(255) NOT NULL,
+    input TEXT NOT NULL,
+
+    -- Exactly one of: a 5-field cron expression (recurring) or a fixed run time (one-off)
+    cron_expression VARCHAR(100),
+    run_at TIMESTAMPTZ,
+    -- IANA time zone the cron expression is evaluated in
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT scheduled_tasks_timing_check
+        CHECK ((cron_expression IS NULL) <> (run_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_agent ON scheduled_tasks(agent_id, created_at DESC);
+
+-- Lets the scheduler find due schedules without scanning inactive ones
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_due
+    ON scheduled_tasks(next_run_at) WHERE is_active = TRUE;
+
+DROP TRIGGER IF EXISTS update_scheduled_tasks_updated_at ON scheduled_tasks;
+CREATE TRIGGER update_scheduled_tasks_updated_at BEFORE UPDATE ON scheduled_tasks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per time a schedule fired
+CREATE TABLE IF NOT EXISTS scheduled_task_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_id UUID NOT NULL REFERENCES scheduled_tasks(id) ON DELETE CASCADE,
+    task_id UUID REFERENCES tasks(id) ON DELETE SET NULL,
+
+    -- Run status: created, failed
+    status VARCHAR(20) NOT NULL CHECK (status IN ('created', 'failed')),
+    error TEXT,
+
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_task_runs_schedule
+    ON scheduled_task_runs(schedule_id, created_at DESC);
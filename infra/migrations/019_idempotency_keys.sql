@@ -0,0 +1,23 @@
+-- Idempotency Keys
+-- Migration: 019_idempotency_keys.sql
+-- Records processed idempotency keys so retried credit and purchase requests replay the original result
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+
+    -- Operation the key applies to: credit_consume, credit_topup, template_purchase
+    scope VARCHAR(50) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+
+    -- Fingerprint of the request, so a key reused for a different request is rejected
+    request_hash VARCHAR(64) NOT NULL,
+    -- Transaction or earning created by the first request; NULL while it is still running
+    resource_id UUID,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+
+    PRIMARY KEY (office_id, scope, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
@@ -0,0 +1,61 @@
+-- Wallet Balance Guard
+-- Migration: 020_wallet_balance_guard.sql
+-- Enforces non-negative wallet balances and gives wallet errors stable SQLSTATEs the backend can map
+
+ALTER TABLE credit_wallets DROP CONSTRAINT IF EXISTS credit_wallets_balance_non_negative;
+ALTER TABLE credit_wallets ADD CONSTRAINT credit_wallets_balance_non_negative CHECK (balance >= 0);
+
+-- Same behaviour as 004_credit_system.sql, but the wallet row lock covers the
+-- balance check and failures raise no_data_found / check_violation instead of
+-- a generic exception
+CREATE OR REPLACE FUNCTION update_wallet_balance(
+    p_wallet_id UUID,
+    p_amount BIGINT,
+    p_transaction_type VARCHAR(20),
+    p_reference_type VARCHAR(50) DEFAULT NULL,
+    p_reference_id UUID DEFAULT NULL,
+    p_description TEXT DEFAULT NULL,
+    p_metadata JSONB DEFAULT NULL
+) RETURNS credit_transactions AS $$
+DECLARE
+    v_wallet credit_wallets;
+    v_transaction credit_transactions;
+BEGIN
+    -- Lock the wallet row so concurrent debits are serialized
+    SELECT * INTO v_wallet FROM credit_wallets WHERE id = p_wallet_id FOR UPDATE;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Wallet not found: %', p_wallet_id
+            USING ERRCODE = 'no_data_found';
+    END IF;
+
+    -- Check for insufficient balance on debit
+    IF p_amount < 0 AND (v_wallet.balance + p_amount) < 0 THEN
+        RAISE EXCEPTION 'Insufficient balance: has %, needs %', v_wallet.balance, ABS(p_amount)
+            USING ERRCODE = 'check_violation',
+                  CONSTRAINT = 'credit_wallets_balance_non_negative',
+                  DETAIL = format('has %s, needs %s', v_wallet.balance, ABS(p_amount));
+    END IF;
+
+    -- Update wallet balance
+    UPDATE credit_wallets SET
+        balance = balance + p_amount,
+        total_purchased = CASE WHEN p_transaction_type = 'purchase' THEN total_purchased + p_amount ELSE total_purchased END,
+        total_bonus = CASE WHEN p_transaction_type IN ('bonus', 'subscription') THEN total_bonus + p_amount ELSE total_bonus END,
+        total_consumed = CASE WHEN p_transaction_type = 'consumption' THEN total_consumed + ABS(p_amount) ELSE total_consumed END,
+        updated_at = NOW()
+    WHERE id = p_wallet_id
+    RETURNING * INTO v_wallet;
+
+    -- Create transaction record
+    INSERT INTO credit_transactions (
+        wallet_id, transaction_type, amount, balance_after,
+        reference_type, reference_id, description, metadata
+    ) VALUES (
+        p_wallet_id, p_transaction_type, p_amount, v_wallet.balance,
+        p_reference_type, p_reference_id, p_description, p_metadata
+    ) RETURNING * INTO v_transaction;
+
+    RETURN v_transaction;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,30 @@
+-- API Keys
+-- Migration: 021_api_keys.sql
+-- Lets offices on tiers with API access call the API with scoped keys instead of a browser session
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    -- User the key acts on behalf of
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    name VARCHAR(100) NOT NULL,
+    -- First characters of the key, shown so users can tell keys apart
+    key_prefix VARCHAR(16) NOT NULL,
+    -- SHA-256 of the key; the key itself is only shown once, when it is created
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    -- Granted scopes: read, write
+    scopes TEXT[] NOT NULL,
+
+    usage_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT api_keys_scopes_check
+        CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['read', 'write']::TEXT[])
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_office ON api_keys(office_id, created_at DESC);
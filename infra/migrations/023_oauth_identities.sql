@@ -0,0 +1,17 @@
+-- OAuth Identities
+-- Migration: 023_oauth_identities.sql
+-- Links users to their Google or GitHub accounts for social login
+
+CREATE TABLE IF NOT EXISTS oauth_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    -- The user's stable ID at the provider; emails can change
+    provider_user_id VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (provider, provider_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_identities_user ON oauth_identities(user_id);
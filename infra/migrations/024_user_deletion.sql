@@ -0,0 +1,6 @@
+-- User Deletion
+-- Migration: 024_user_deletion.sql
+-- Soft-deletes accounts: financial and marketplace records keep referring to the user,
+-- while their personal details are erased and they can no longer sign in
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
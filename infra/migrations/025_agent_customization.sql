@@ -0,0 +1,23 @@
+-- Agent Customization
+-- Migration: 025_agent_customization.sql
+-- Lets offices override an agent's avatar and records every customization change
+
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS custom_avatar_url VARCHAR(500);
+
+CREATE TABLE IF NOT EXISTS agent_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    -- User who made the change
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+
+    -- Changed field: custom_name, custom_system_prompt, custom_avatar_url
+    field VARCHAR(50) NOT NULL,
+    -- NULL when the field was unset, i.e. the template's value applied
+    old_value TEXT,
+    new_value TEXT,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_changes_agent ON agent_changes(agent_id, created_at DESC);
@@ -0,0 +1,5 @@
+-- Conversation Archiving
+-- Migration: 026_conversation_archiving.sql
+-- Archived conversations are hidden from the default listing and agents stop responding in them
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
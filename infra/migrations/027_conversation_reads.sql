@@ -0,0 +1,15 @@
+-- Conversation Reads
+-- Migration: 027_conversation_reads.sql
+-- Tracks the last message each user has read in each conversation, for unread badges
+
+CREATE TABLE IF NOT EXISTS conversation_reads (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    -- Position of the last read message; later messages are unread
+    last_read_message_id UUID NOT NULL,
+    last_read_at TIMESTAMPTZ NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, user_id)
+);
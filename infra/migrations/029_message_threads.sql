@@ -0,0 +1,7 @@
+-- Message Threads
+-- Migration: 029_message_threads.sql
+-- Replies point at the first message of their thread
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS parent_message_id UUID REFERENCES messages(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages(parent_message_id, created_at) WHERE parent_message_id IS NOT NULL;
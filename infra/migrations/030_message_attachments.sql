@@ -0,0 +1,20 @@
+-- Message Attachments
+-- Migration: 030_message_attachments.sql
+-- Stores metadata of files attached to chat messages; the contents live in object storage
+
+CREATE TABLE IF NOT EXISTS message_attachments (
+    id UUID PRIMARY KEY,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    -- Files are uploaded before the message they belong to is sent
+    message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+    uploaded_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key VARCHAR(512) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message ON message_attachments(message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_message_attachments_conversation ON message_attachments(conversation_id);
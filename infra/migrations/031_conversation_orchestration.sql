@@ -0,0 +1,12 @@
+-- Conversation Orchestration
+-- Migration: 031_conversation_orchestration.sql
+-- How the agents of a group conversation take turns answering
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS orchestration_mode VARCHAR(20) NOT NULL DEFAULT 'mentions'
+    CHECK (orchestration_mode IN ('mentions', 'round_robin', 'moderator', 'all', 'debate'));
+
+-- The agent that routes messages in moderator mode
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS moderator_agent_id UUID REFERENCES agents(id) ON DELETE SET NULL;
+
+-- How many times each agent speaks in debate mode
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS debate_rounds INT NOT NULL DEFAULT 2 CHECK (debate_rounds BETWEEN 1 AND 5);
@@ -0,0 +1,13 @@
+-- Task Delegation
+-- Migration: 032_task_delegation.sql
+-- Sub-tasks that agents delegate to each other by @mentioning them, forming trees
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_task_id UUID REFERENCES tasks(id) ON DELETE CASCADE;
+
+-- The task at the top of the tree, so a whole tree loads and counts at once
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS root_task_id UUID REFERENCES tasks(id) ON DELETE CASCADE;
+
+-- How many delegations below the root the task is; root tasks are 0
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_root ON tasks(root_task_id, created_at) WHERE root_task_id IS NOT NULL;
@@ -0,0 +1,33 @@
+-- Agent Documents
+-- Migration: 033_documents.sql
+-- Deliverables agents produce, such as reports and specs, with every saved version
+
+CREATE TABLE IF NOT EXISTS documents (
+    id UUID PRIMARY KEY,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    -- Documents belong to the office and outlive the conversation and agent
+    conversation_id UUID REFERENCES conversations(id) ON DELETE SET NULL,
+    agent_id UUID REFERENCES agents(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    format VARCHAR(20) NOT NULL CHECK (format IN ('markdown', 'text', 'html', 'json', 'csv')),
+    -- The latest version; earlier ones are kept in document_versions
+    content TEXT NOT NULL,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_office ON documents(office_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_documents_conversation ON documents(conversation_id) WHERE conversation_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    id UUID PRIMARY KEY,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    -- The task whose agent saved this version
+    task_id UUID REFERENCES tasks(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (document_id, version)
+);
@@ -0,0 +1,17 @@
+-- Notification Preferences
+-- Migration: 034_notification_preferences.sql
+-- Per-user choice of which notification types are shown in-app and which are emailed
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- budget_alert, task_completed, marketplace_sale, payout_processed,
+    -- subscription_updated, template_approved or template_rejected
+    notification_type VARCHAR(50) NOT NULL,
+    in_app BOOLEAN NOT NULL,
+    email BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, notification_type)
+);
+
+-- Keyset pagination of an office's notifications
+CREATE INDEX IF NOT EXISTS idx_notifications_office_created_id ON notifications(office_id, created_at DESC, id DESC);
@@ -0,0 +1,24 @@
+-- Email Outbox
+-- Migration: 035_email_outbox.sql
+-- Outgoing emails are queued here and delivered by a worker, which retries
+-- them with backoff when the mail provider fails
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    to_address VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    -- Name of the template the email was rendered from, for troubleshooting
+    template VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    -- When a pending email is next tried; claiming an email pushes it into
+    -- the future so other replicas leave it alone while it is being sent
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,12 @@
+-- Task Usage
+-- Migration: 036_task_usage.sql
+-- What running a task cost, as reported by the orchestrator when it completes
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS model_name VARCHAR(100);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
+
+-- How long generating the reply took
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS latency_ms INT;
+
+-- Credits the office was charged for the task
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS credits_consumed INT NOT NULL DEFAULT 0;
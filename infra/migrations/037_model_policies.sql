@@ -0,0 +1,23 @@
+-- Model Policies
+-- Migration: 037_model_policies.sql
+-- Per-office and per-agent constraints on which models the orchestrator routes tasks to
+
+CREATE TABLE IF NOT EXISTS model_policies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    -- NULL for the office's policy; an agent's own policy replaces it
+    agent_id UUID REFERENCES agents(id) ON DELETE CASCADE,
+
+    -- Providers tasks may run on; empty allows every provider of the tier
+    allowed_providers TEXT[] NOT NULL DEFAULT '{}',
+    -- Model tried first when it can handle the task
+    preferred_model VARCHAR(100) NOT NULL DEFAULT '',
+    preferred_provider VARCHAR(50) NOT NULL DEFAULT '',
+    -- Models estimated to cost more credits per task are not used
+    max_credits_per_task INT CHECK (max_credits_per_task > 0),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT model_policies_office_agent_key UNIQUE NULLS NOT DISTINCT (office_id, agent_id)
+);
@@ -0,0 +1,10 @@
+-- Subscription Downgrades
+-- Migration: 038_subscription_downgrades.sql
+-- Downgrades scheduled for the end of a subscription's billing period
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pending_tier VARCHAR(20);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pending_tier_at TIMESTAMPTZ;
+
+-- Finding the downgrades that are due
+CREATE INDEX IF NOT EXISTS idx_subscriptions_pending_tier_at ON subscriptions(pending_tier_at)
+    WHERE pending_tier IS NOT NULL;
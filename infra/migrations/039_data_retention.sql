@@ -0,0 +1,40 @@
+-- Data Retention
+-- Migration: 039_data_retention.sql
+-- Runs of the retention worker and what each purged from each office, for the admin report
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id UUID PRIMARY KEY,
+    -- A dry run only counts what it would purge
+    dry_run BOOLEAN NOT NULL,
+    -- Entities the run was configured to leave alone
+    exempt_entities TEXT[] NOT NULL DEFAULT '{}',
+    offices_purged INT NOT NULL DEFAULT 0,
+    messages_deleted BIGINT NOT NULL DEFAULT 0,
+    attachments_deleted BIGINT NOT NULL DEFAULT 0,
+    tasks_deleted BIGINT NOT NULL DEFAULT 0,
+    usage_rows_deleted BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started ON retention_runs(started_at DESC);
+
+CREATE TABLE IF NOT EXISTS retention_purges (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL REFERENCES retention_runs(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    tier VARCHAR(20) NOT NULL,
+    retention_days INT NOT NULL,
+    -- Data created before this was purged
+    cutoff TIMESTAMPTZ NOT NULL,
+    messages_deleted BIGINT NOT NULL DEFAULT 0,
+    attachments_deleted BIGINT NOT NULL DEFAULT 0,
+    tasks_deleted BIGINT NOT NULL DEFAULT 0,
+    usage_rows_deleted BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_purges_run ON retention_purges(run_id, created_at);
+
+-- Retention looks up old tasks per office
+CREATE INDEX IF NOT EXISTS idx_tasks_office_created ON tasks(office_id, created_at);
@@ -0,0 +1,28 @@
+-- Invoices
+-- Migration: 040_invoices.sql
+-- Invoices the backend issues for offices on a paid tier that are not billed through Stripe
+
+CREATE SEQUENCE IF NOT EXISTS invoice_numbers;
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    number VARCHAR(32) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('draft', 'open', 'paid', 'void', 'uncollectible')),
+    currency VARCHAR(3) NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    amount_paid_cents BIGINT NOT NULL DEFAULT 0,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    -- Charges as [{"description", "quantity", "amount_cents"}]
+    lines JSONB NOT NULL DEFAULT '[]'::jsonb,
+    due_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- Each period is invoiced once
+    CONSTRAINT unique_invoice_period UNIQUE (subscription_id, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_office ON invoices(office_id, created_at DESC);
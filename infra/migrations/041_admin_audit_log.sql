@@ -0,0 +1,24 @@
+-- Admin Audit Log
+-- Migration: 041_admin_audit_log.sql
+-- Every action an admin takes through the back office, such as credit adjustments, tier changes and payout decisions
+
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id UUID PRIMARY KEY,
+    admin_id UUID NOT NULL REFERENCES users(id),
+    -- For example credits.adjust, subscription.change_tier or payout.reject
+    action VARCHAR(50) NOT NULL,
+    -- What the action was taken on: office, payout, template or retention_run
+    target_type VARCHAR(30) NOT NULL,
+    target_id UUID NOT NULL,
+    -- The office affected, if any; kept when the office is deleted
+    office_id UUID,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created ON admin_audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_admin ON admin_audit_log(admin_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_office ON admin_audit_log(office_id, created_at DESC);
+
+-- Back-office listing of failed tasks across offices
+CREATE INDEX IF NOT EXISTS idx_tasks_failed ON tasks(created_at DESC) WHERE status IN ('failed', 'dead_letter');
@@ -0,0 +1,48 @@
+-- Audit Log
+-- Migration: 042_audit_log.sql
+-- Append-only log of sensitive operations: logins, tier changes, agent deletions, payout requests and every admin action.
+-- It replaces admin_audit_log, whose entries move here.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY,
+    -- The user who acted, or NULL for the platform itself; users and
+    -- offices are not referenced so entries outlive them
+    actor_id UUID,
+    office_id UUID,
+    -- For example auth.login, agent.delete or admin.credits.adjust
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id UUID NOT NULL,
+    before JSONB,
+    after JSONB,
+    details JSONB,
+    ip VARCHAR(45),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_office ON audit_log(office_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at DESC);
+
+CREATE OR REPLACE FUNCTION prevent_audit_log_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_change();
+
+DO $$
+BEGIN
+    IF to_regclass('admin_audit_log') IS NOT NULL THEN
+        INSERT INTO audit_log (id, actor_id, office_id, action, entity_type, entity_id, details, created_at)
+        SELECT id, admin_id, office_id, 'admin.' || action, target_type, target_id, details, created_at
+        FROM admin_audit_log
+        ON CONFLICT (id) DO NOTHING;
+
+        DROP TABLE admin_audit_log;
+    END IF;
+END $$;
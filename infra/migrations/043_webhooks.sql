@@ -0,0 +1,59 @@
+-- Webhooks
+-- Migration: 043_webhooks.sql
+-- Lets offices on tiers with API access receive events at their own URLs.
+-- Deliveries are queued and sent by a worker, which signs them with the
+-- webhook's secret and retries them with backoff.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    -- Key of the HMAC-SHA256 signature sent with each delivery; it is only
+    -- shown when the webhook is created
+    secret TEXT NOT NULL,
+    -- Events delivered: task.completed, message.created, credits.low,
+    -- subscription.updated
+    event_types TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT webhooks_event_types_check
+        CHECK (cardinality(event_types) > 0 AND event_types <@ ARRAY[
+            'task.completed', 'message.created', 'credits.low', 'subscription.updated'
+        ]::TEXT[])
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_office ON webhooks(office_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    -- An event sent to several webhooks has one delivery for each
+    event_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    -- Request body, signed again on every attempt
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    -- When a pending delivery is next tried; claiming a delivery pushes it
+    -- into the future so other replicas leave it alone while it is sent
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+
+-- One row per attempt, for the delivery log
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    -- HTTP status of the response, NULL when no response was received
+    status_code INT,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INT NOT NULL DEFAULT 0,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id, attempt);
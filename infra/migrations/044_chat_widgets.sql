@@ -0,0 +1,43 @@
+-- Chat Widgets
+-- Migration: 044_chat_widgets.sql
+-- Lets an office embed one of its agents on its own site. A widget token
+-- exposes a single agent through the public widget endpoints; every visitor
+-- session gets its own conversation, whose tasks are charged to the office.
+
+CREATE TABLE IF NOT EXISTS widget_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    -- Start of the token, kept in the clear so owners can tell tokens apart
+    token_prefix VARCHAR(20) NOT NULL,
+    -- SHA-256 of the full token; the token itself is never stored
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    -- Origins pages embedding the widget may be served from; empty allows any
+    allowed_origins TEXT[] NOT NULL DEFAULT '{}',
+    -- Requests per minute the token accepts, across all of its visitors
+    rate_limit INT NOT NULL CHECK (rate_limit > 0),
+    message_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_widget_tokens_office ON widget_tokens(office_id, created_at DESC);
+
+-- A visitor's chat through a widget token
+CREATE TABLE IF NOT EXISTS widget_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token_id UUID NOT NULL REFERENCES widget_tokens(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL UNIQUE REFERENCES conversations(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_widget_sessions_token ON widget_sessions(token_id);
+
+-- Widget visitors are neither users nor agents
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_type_check
+    CHECK (sender_type IN ('user', 'agent', 'visitor'));
@@ -0,0 +1,84 @@
+-- Office Timezones
+-- Migration: 045_office_timezones.sql
+-- Each office picks the timezone its usage analytics count days in. Usage is
+-- bucketed by the office's local date from now on; rows recorded before this
+-- migration keep the server's dates.
+
+ALTER TABLE offices ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE OR REPLACE FUNCTION record_task_usage(
+    p_office_id UUID,
+    p_agent_id UUID,
+    p_agent_role VARCHAR,
+    p_model_name VARCHAR,
+    p_provider VARCHAR,
+    p_credits INT,
+    p_input_tokens INT,
+    p_output_tokens INT,
+    p_is_local_model BOOLEAN,
+    p_usd_cost DECIMAL,
+    p_success BOOLEAN
+) RETURNS VOID AS $$
+DECLARE
+    -- The office's local date, so days end at its midnight
+    v_date DATE := (NOW() AT TIME ZONE COALESCE(
+        (SELECT timezone FROM offices WHERE id = p_office_id), 'UTC'))::DATE;
+BEGIN
+    -- Update daily usage
+    INSERT INTO usage_daily (
+        office_id, date, credits_consumed, tasks_executed, 
+        tasks_succeeded, tasks_failed,
+        input_tokens, output_tokens, total_tokens,
+        local_model_tasks, paid_model_tasks, estimated_usd
+    ) VALUES (
+        p_office_id, v_date, p_credits, 1,
+        CASE WHEN p_success THEN 1 ELSE 0 END,
+        CASE WHEN p_success THEN 0 ELSE 1 END,
+        p_input_tokens, p_output_tokens, p_input_tokens + p_output_tokens,
+        CASE WHEN p_is_local_model THEN 1 ELSE 0 END,
+        CASE WHEN p_is_local_model THEN 0 ELSE 1 END,
+        p_usd_cost
+    )
+    ON CONFLICT (office_id, date) DO UPDATE SET
+        credits_consumed = usage_daily.credits_consumed + EXCLUDED.credits_consumed,
+        tasks_executed = usage_daily.tasks_executed + 1,
+        tasks_succeeded = usage_daily.tasks_succeeded + EXCLUDED.tasks_succeeded,
+        tasks_failed = usage_daily.tasks_failed + EXCLUDED.tasks_failed,
+        input_tokens = usage_daily.input_tokens + EXCLUDED.input_tokens,
+        output_tokens = usage_daily.output_tokens + EXCLUDED.output_tokens,
+        total_tokens = usage_daily.total_tokens + EXCLUDED.total_tokens,
+        local_model_tasks = usage_daily.local_model_tasks + EXCLUDED.local_model_tasks,
+        paid_model_tasks = usage_daily.paid_model_tasks + EXCLUDED.paid_model_tasks,
+        estimated_usd = usage_daily.estimated_usd + EXCLUDED.estimated_usd,
+        updated_at = NOW();
+
+    -- Update model usage
+    INSERT INTO usage_by_model (
+        office_id, date, model_name, provider,
+        task_count, credits_consumed, input_tokens, output_tokens, estimated_usd
+    ) VALUES (
+        p_office_id, v_date, p_model_name, p_provider,
+        1, p_credits, p_input_tokens, p_output_tokens, p_usd_cost
+    )
+    ON CONFLICT (office_id, date, model_name) DO UPDATE SET
+        task_count = usage_by_model.task_count + 1,
+        credits_consumed = usage_by_model.credits_consumed + EXCLUDED.credits_consumed,
+        input_tokens = usage_by_model.input_tokens + EXCLUDED.input_tokens,
+        output_tokens = usage_by_model.output_tokens + EXCLUDED.output_tokens,
+        estimated_usd = usage_by_model.estimated_usd + EXCLUDED.estimated_usd;
+
+    -- Update agent usage
+    INSERT INTO usage_by_agent (
+        office_id, date, agent_id, agent_role,
+        task_count, credits_consumed, input_tokens, output_tokens
+    ) VALUES (
+        p_office_id, v_date, p_agent_id, p_agent_role,
+        1, p_credits, p_input_tokens, p_output_tokens
+    )
+    ON CONFLICT (office_id, date, agent_id) DO UPDATE SET
+        task_count = usage_by_agent.task_count + 1,
+        credits_consumed = usage_by_agent.credits_consumed + EXCLUDED.credits_consumed,
+        input_tokens = usage_by_agent.input_tokens + EXCLUDED.input_tokens,
+        output_tokens = usage_by_agent.output_tokens + EXCLUDED.output_tokens;
+END;
+$$ LANGUAGE plpgsql;
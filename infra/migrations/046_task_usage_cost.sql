@@ -0,0 +1,12 @@
+-- Task Usage Cost
+-- Migration: 046_task_usage_cost.sql
+-- Keeps the rest of what a task's usage report said on the task, so usage
+-- analytics can be rebuilt from tasks when rollups are missing. Tasks
+-- reported before this migration count as local when run on ollama and as
+-- costing nothing.
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS is_local_model BOOLEAN;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS usd_cost DECIMAL(10, 4);
+
+-- Rebuilding a range of days finds the office's tasks by when they finished
+CREATE INDEX IF NOT EXISTS idx_tasks_office_completed ON tasks(office_id, completed_at);
@@ -0,0 +1,25 @@
+-- Template Views
+-- Migration: 047_template_views.sql
+-- Counts how often marketplace templates are listed (impressions) and have
+-- their details opened (views) per day, so authors can see how browsing
+-- turns into hires and sales.
+
+-- Each visitor counts once per template, kind and day. Visitors are stored
+-- as a hash of their user or session, and only until their day is over.
+CREATE TABLE IF NOT EXISTS template_view_visitors (
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('impression', 'view')),
+    visitor_hash VARCHAR(64) NOT NULL,
+    PRIMARY KEY (template_id, date, kind, visitor_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_view_visitors_date ON template_view_visitors(date);
+
+CREATE TABLE IF NOT EXISTS template_views (
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    impressions INTEGER NOT NULL DEFAULT 0,
+    views INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (template_id, date)
+);
@@ -0,0 +1,37 @@
+-- Purchase Refunds
+-- Migration: 048_purchase_refunds.sql
+-- Offices can ask for a template purchase to be refunded and an admin
+-- approves or rejects the request. An approved refund is paid back through
+-- Stripe, reversed in the author's earnings by a negative earning and ends
+-- the office's entitlement to the template.
+
+CREATE TABLE IF NOT EXISTS purchase_refund_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    purchase_id UUID NOT NULL REFERENCES template_purchases(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'refunded', 'rejected')),
+    rejection_reason TEXT,
+    stripe_refund_id VARCHAR(100),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+-- A purchase has at most one open request
+CREATE UNIQUE INDEX IF NOT EXISTS idx_purchase_refund_requests_open
+    ON purchase_refund_requests(purchase_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_purchase_refund_requests_status ON purchase_refund_requests(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_purchase_refund_requests_office ON purchase_refund_requests(office_id, created_at DESC);
+
+-- A refunded sale keeps its earning, marked refunded, and gains a reversal:
+-- an earning with the negated amounts that refers to it
+ALTER TABLE author_earnings ADD COLUMN IF NOT EXISTS refund_of UUID REFERENCES author_earnings(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_author_earnings_refund_of ON author_earnings(refund_of) WHERE refund_of IS NOT NULL;
+
+ALTER TABLE author_earnings DROP CONSTRAINT IF EXISTS author_earnings_sale_amount_cents_check;
+ALTER TABLE author_earnings DROP CONSTRAINT IF EXISTS author_earnings_sale_amount_check;
+ALTER TABLE author_earnings ADD CONSTRAINT author_earnings_sale_amount_check
+    CHECK (sale_amount_cents >= 199 OR refund_of IS NOT NULL);
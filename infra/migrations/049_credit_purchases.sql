@@ -0,0 +1,8 @@
+-- Credit Purchases
+-- Migration: 049_credit_purchases.sql
+-- Offices can pay for a premium template with wallet credits instead of a
+-- card. The author still earns the template's price in cents.
+
+-- What the office paid in credits; NULL for purchases paid by card
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS price_credits BIGINT
+    CHECK (price_credits > 0);
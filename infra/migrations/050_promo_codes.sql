@@ -0,0 +1,44 @@
+-- Promo Codes
+-- Migration: 050_promo_codes.sql
+-- Admins define promo codes for campaigns. Redeeming one grants the office
+-- bonus credits or takes a percentage off the subscription price of its next
+-- invoices. Each office and each user redeems a code at most once.
+
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(32) NOT NULL UNIQUE CHECK (code = UPPER(code)),
+    description TEXT,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('bonus_credits', 'subscription_discount')),
+    bonus_credits BIGINT NOT NULL DEFAULT 0 CHECK (bonus_credits >= 0),
+    discount_percent INTEGER NOT NULL DEFAULT 0 CHECK (discount_percent BETWEEN 0 AND 100),
+    discount_periods INTEGER NOT NULL DEFAULT 0 CHECK (discount_periods >= 0),
+    max_redemptions INTEGER CHECK (max_redemptions > 0),
+    redemption_count INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    deactivated_at TIMESTAMPTZ,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (max_redemptions IS NULL OR redemption_count <= max_redemptions)
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    bonus_credits BIGINT NOT NULL DEFAULT 0,
+    -- The bonus transaction, whose reference is the promo code
+    credit_transaction_id UUID REFERENCES credit_transactions(id) ON DELETE SET NULL,
+    discount_percent INTEGER NOT NULL DEFAULT 0,
+    discount_periods_left INTEGER NOT NULL DEFAULT 0 CHECK (discount_periods_left >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (promo_code_id, office_id),
+    UNIQUE (promo_code_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_office ON promo_redemptions(office_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_code ON promo_redemptions(promo_code_id, created_at DESC);
+-- Discounts still to be applied, found when invoicing
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_discount
+    ON promo_redemptions(office_id) WHERE discount_periods_left > 0;
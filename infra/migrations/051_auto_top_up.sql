@@ -0,0 +1,43 @@
+-- Low Balance Settings and Auto Top-Up
+-- Migration: 051_auto_top_up.sql
+-- Each wallet sets the balance below which its office is warned. Offices
+-- billed through Stripe can also have a credit pack bought with a saved
+-- payment method whenever the balance drops below it, a limited number of
+-- times a day.
+
+ALTER TABLE credit_wallets
+    ADD COLUMN IF NOT EXISTS low_balance_threshold BIGINT NOT NULL DEFAULT 100 CHECK (low_balance_threshold >= 0),
+    ADD COLUMN IF NOT EXISTS auto_top_up_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS auto_top_up_package VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS auto_top_up_payment_method VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS auto_top_up_max_per_day INTEGER NOT NULL DEFAULT 1
+        CHECK (auto_top_up_max_per_day BETWEEN 1 AND 10);
+
+-- Wallets due a top-up are looked for every minute
+CREATE INDEX IF NOT EXISTS idx_credit_wallets_auto_top_up
+    ON credit_wallets(id) WHERE auto_top_up_enabled;
+
+CREATE TABLE IF NOT EXISTS credit_auto_top_ups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES credit_wallets(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    package VARCHAR(50) NOT NULL,
+    credits BIGINT NOT NULL CHECK (credits > 0),
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    -- Retries charge the payment method the top-up was first charged to
+    payment_method_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'succeeded', 'failed')),
+    stripe_payment_intent_id VARCHAR(100),
+    -- The purchase transaction adding the credits, whose reference is the
+    -- top-up
+    credit_transaction_id UUID REFERENCES credit_transactions(id) ON DELETE SET NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+-- A wallet has at most one top-up being charged
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_auto_top_ups_pending
+    ON credit_auto_top_ups(wallet_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_credit_auto_top_ups_wallet ON credit_auto_top_ups(wallet_id, created_at DESC);
@@ -0,0 +1,21 @@
+-- Soft Delete
+-- Migration: 052_soft_delete.sql
+-- Deleted agents, conversations and offices are hidden and can be restored for a grace period.
+-- Retention purges deleted agents and conversations once they are older than the office's tier keeps data;
+-- deleted offices keep their rows for billing and audit records.
+
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE offices ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Deleting an agent used to only deactivate it
+UPDATE agents SET deleted_at = updated_at WHERE NOT is_active AND deleted_at IS NULL;
+
+-- Retention looks up each office's deleted rows
+CREATE INDEX IF NOT EXISTS idx_agents_office_deleted ON agents(office_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_conversations_office_deleted ON conversations(office_id, deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE retention_runs ADD COLUMN IF NOT EXISTS conversations_deleted BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE retention_runs ADD COLUMN IF NOT EXISTS agents_deleted BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE retention_purges ADD COLUMN IF NOT EXISTS conversations_deleted BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE retention_purges ADD COLUMN IF NOT EXISTS agents_deleted BIGINT NOT NULL DEFAULT 0;
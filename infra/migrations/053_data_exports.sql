@@ -0,0 +1,27 @@
+-- Data Exports
+-- Migration: 053_data_exports.sql
+-- Users can download an archive of all their data, which a worker assembles in the background.
+-- Archives are kept in object storage for a few days and then removed.
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed', 'expired')),
+    attempts INT NOT NULL DEFAULT 0,
+    -- When a pending export is next tried; claiming an export pushes it into
+    -- the future so other replicas leave it alone while it is being built
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    storage_key VARCHAR(500) NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    -- When a ready archive is removed from storage
+    expires_at TIMESTAMPTZ
+);
+
+-- A user has at most one export being built
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_pending ON data_exports(user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_due ON data_exports(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_data_exports_expiring ON data_exports(expires_at) WHERE status = 'ready';
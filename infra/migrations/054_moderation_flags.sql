@@ -0,0 +1,24 @@
+-- Content Moderation
+-- Migration: 054_moderation_flags.sql
+-- Messages, custom system prompts and marketplace templates flagged by content moderation are
+-- queued here for admin review, including content that was blocked.
+
+CREATE TABLE IF NOT EXISTS moderation_flags (
+    id UUID PRIMARY KEY,
+    content_type VARCHAR(20) NOT NULL CHECK (content_type IN ('message', 'system_prompt', 'template')),
+    -- The message, agent or template the content was saved as; NULL for blocked content
+    entity_id UUID,
+    office_id UUID REFERENCES offices(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    action VARCHAR(20) NOT NULL CHECK (action IN ('flag', 'block')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dismissed', 'upheld')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_flags_queue ON moderation_flags(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_flags_user ON moderation_flags(user_id);
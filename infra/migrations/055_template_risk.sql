@@ -0,0 +1,7 @@
+-- Template Security Scan
+-- Migration: 055_template_risk.sql
+-- Submitted templates are scanned for prompt-injection patterns, such as instructions to exfiltrate
+-- data, abuse tools or hide instructions from users. The score and what was found are kept for review.
+
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS risk_score INT NOT NULL DEFAULT 0;
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS risk_findings JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,20 @@
+-- Secrets Vault
+-- Migration: 056_office_secrets.sql
+-- Credentials offices keep for their agents' tools. Values are encrypted by the backend with
+-- AES-256-GCM under SECRETS_MASTER_KEY and only decrypted for the orchestrator.
+
+CREATE TABLE IF NOT EXISTS office_secrets (
+    id UUID PRIMARY KEY,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- Agents the secret is limited to; empty for all of the office's agents
+    agent_ids UUID[] NOT NULL DEFAULT '{}',
+    ciphertext BYTEA NOT NULL,
+    hint VARCHAR(8) NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_accessed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (office_id, name)
+);
@@ -0,0 +1,38 @@
+-- Web Research
+-- Migration: 057_web_research.sql
+-- Offices' web research settings, the searches their agents ran and the results cache shared by
+-- all offices so repeated queries are not paid for again.
+
+CREATE TABLE IF NOT EXISTS office_web_research_settings (
+    office_id UUID PRIMARY KEY REFERENCES offices(id) ON DELETE CASCADE,
+    -- Domains results are limited to, with their subdomains; empty for any domain
+    allowed_domains TEXT[] NOT NULL DEFAULT '{}',
+    max_searches_per_task INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS web_searches (
+    id UUID PRIMARY KEY,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    query TEXT NOT NULL,
+    provider VARCHAR(50) NOT NULL DEFAULT '',
+    cached BOOLEAN NOT NULL DEFAULT FALSE,
+    result_count INTEGER NOT NULL DEFAULT 0,
+    credits BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_web_searches_task ON web_searches(task_id) WHERE NOT cached;
+CREATE INDEX IF NOT EXISTS idx_web_searches_office ON web_searches(office_id, created_at DESC);
+
+-- Keyed by the SHA-256 of the normalized query
+CREATE TABLE IF NOT EXISTS web_search_cache (
+    key_hash VARCHAR(64) PRIMARY KEY,
+    results JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_web_search_cache_expires ON web_search_cache(expires_at);
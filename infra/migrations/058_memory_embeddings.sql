@@ -0,0 +1,11 @@
+-- Vector Memory Search
+-- Migration: 058_memory_embeddings.sql
+-- Stores the embeddings of agent memories in pgvector for semantic retrieval. The orchestrator embeds
+-- "<key>: <value>" with text-embedding-3-small; an embedding is dropped when its memory changes.
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+ALTER TABLE agent_memories ADD COLUMN IF NOT EXISTS embedding vector(1536);
+
+CREATE INDEX IF NOT EXISTS idx_agent_memories_embedding ON agent_memories
+    USING hnsw (embedding vector_cosine_ops);
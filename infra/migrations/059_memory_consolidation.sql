@@ -0,0 +1,27 @@
+-- Memory Consolidation
+-- Migration: 059_memory_consolidation.sql
+-- Agent memories are periodically consolidated: near-duplicates are merged into the most recent one,
+-- importance decays with a half-life, and the least important memories past a cap per agent are
+-- archived. Archived memories are kept but no longer recalled.
+
+ALTER TABLE agent_memories ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+-- merged or capped
+ALTER TABLE agent_memories ADD COLUMN IF NOT EXISTS archive_reason VARCHAR(20);
+ALTER TABLE agent_memories ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES agent_memories(id) ON DELETE SET NULL;
+-- When importance_score last decayed; decay runs from updated_at before the first time
+ALTER TABLE agent_memories ADD COLUMN IF NOT EXISTS importance_decayed_at TIMESTAMPTZ;
+
+-- Decaying and archiving are not edits, so they leave updated_at alone
+DROP TRIGGER IF EXISTS update_agent_memories_updated_at ON agent_memories;
+CREATE TRIGGER update_agent_memories_updated_at BEFORE UPDATE ON agent_memories
+    FOR EACH ROW
+    WHEN ((NEW.importance_decayed_at IS NULL OR NEW.importance_decayed_at IS NOT DISTINCT FROM OLD.importance_decayed_at)
+        AND (NEW.archived_at IS NULL OR NEW.archived_at IS NOT DISTINCT FROM OLD.archived_at))
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX IF NOT EXISTS idx_agent_memories_active ON agent_memories(agent_id, importance_score DESC)
+    WHERE archived_at IS NULL;
+
+ALTER TABLE agent_learning_stats ADD COLUMN IF NOT EXISTS merged_memory_count INTEGER DEFAULT 0;
+ALTER TABLE agent_learning_stats ADD COLUMN IF NOT EXISTS archived_memory_count INTEGER DEFAULT 0;
+ALTER TABLE agent_learning_stats ADD COLUMN IF NOT EXISTS last_consolidated_at TIMESTAMPTZ;
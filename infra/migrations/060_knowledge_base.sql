@@ -0,0 +1,40 @@
+-- Knowledge Base
+-- Migration: 060_knowledge_base.sql
+-- Notes and files an office's agents all share, such as company information and style guides.
+-- Documents are split into chunks, embedded in the background, so that tasks are given the chunks
+-- relevant to them.
+
+CREATE TABLE IF NOT EXISTS knowledge_documents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    -- note or file
+    source VARCHAR(20) NOT NULL,
+    filename VARCHAR(255),
+    content TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    chunk_count INTEGER NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_office ON knowledge_documents(office_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS knowledge_chunks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    document_id UUID NOT NULL REFERENCES knowledge_documents(id) ON DELETE CASCADE,
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    embedding vector(1536),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (document_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_office ON knowledge_chunks(office_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_unembedded ON knowledge_chunks(created_at)
+    WHERE embedding IS NULL;
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_embedding ON knowledge_chunks
+    USING hnsw (embedding vector_cosine_ops);
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_text ON knowledge_chunks
+    USING gin (to_tsvector('english', content));
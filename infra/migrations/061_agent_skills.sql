@@ -0,0 +1,12 @@
+-- Agent Skills
+-- Migration: 061_agent_skills.sql
+-- Which skills of the catalog, such as web_search or code_exec, each agent is given. Skills never
+-- configured for an agent keep their catalog default; the office's tier decides which are available.
+
+CREATE TABLE IF NOT EXISTS agent_skills (
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    skill VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (agent_id, skill)
+);
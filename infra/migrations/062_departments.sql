@@ -0,0 +1,36 @@
+-- Departments and Reporting Lines
+-- Migration: 062_departments.sql
+-- Offices organize their agents into departments led by one of their members. Each department has a
+-- group conversation with its members, where messages that mention no one go to the lead. Agents can
+-- also report to a manager agent.
+
+CREATE TABLE IF NOT EXISTS departments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    lead_agent_id UUID REFERENCES agents(id) ON DELETE SET NULL,
+    conversation_id UUID REFERENCES conversations(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (office_id, name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_departments_conversation ON departments(conversation_id);
+
+-- An agent belongs to at most one department
+CREATE TABLE IF NOT EXISTS department_members (
+    department_id UUID NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL UNIQUE REFERENCES agents(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (department_id, agent_id)
+);
+
+CREATE TABLE IF NOT EXISTS agent_reporting_lines (
+    agent_id UUID PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    manager_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (agent_id <> manager_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_reporting_lines_manager ON agent_reporting_lines(manager_id);
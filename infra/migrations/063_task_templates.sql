@@ -0,0 +1,23 @@
+-- Task Templates
+-- Migration: 063_task_templates.sql
+-- Reusable task instructions with {{placeholders}}, such as a weekly status report, that an office
+-- fills in to dispatch to an agent once or on a schedule. Schedules keep the input they were created
+-- with when their template changes or is deleted.
+
+CREATE TABLE IF NOT EXISTS task_templates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    defaults JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (office_id, name)
+);
+
+ALTER TABLE scheduled_tasks
+    ADD COLUMN IF NOT EXISTS task_template_id UUID REFERENCES task_templates(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_task_template ON scheduled_tasks(task_template_id)
+    WHERE task_template_id IS NOT NULL;
@@ -0,0 +1,35 @@
+-- Project Boards
+-- Migration: 064_project_boards.sql
+-- Kanban-style boards over an office's tasks: each project has ordered columns, and tasks are placed
+-- in them as cards. A task is a card on at most one board; removing a card leaves the task as is.
+
+CREATE TABLE IF NOT EXISTS projects (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (office_id, name)
+);
+
+-- Positions count from 0 within their project or column
+CREATE TABLE IF NOT EXISTS board_columns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_board_columns_project ON board_columns(project_id, position);
+
+CREATE TABLE IF NOT EXISTS board_cards (
+    task_id UUID PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    column_id UUID NOT NULL REFERENCES board_columns(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_board_cards_column ON board_cards(column_id, position);
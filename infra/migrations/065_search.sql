@@ -0,0 +1,13 @@
+-- Office Search
+-- Migration: 065_search.sql
+-- Full-text indexes for searching an office's messages, task outputs and documents. Queries must
+-- use the same expressions for the indexes to apply.
+
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages
+    USING gin (to_tsvector('english', content))
+    WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks
+    USING gin (to_tsvector('english', output))
+    WHERE output IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_search ON documents
+    USING gin (to_tsvector('english', title || ' ' || content));
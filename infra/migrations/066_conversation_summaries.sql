@@ -0,0 +1,39 @@
+-- Conversation Summaries
+-- Migration: 066_conversation_summaries.sql
+-- Agents summarize conversations on request, or once they grow long. Each run summarizes the
+-- messages since the previous one, together with its summary; the latest summary is kept on the
+-- conversation and posted as a pinned system message.
+
+-- Summary tasks run like chat tasks, but their output becomes a summary instead of a reply
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'chat'
+    CHECK (kind IN ('chat', 'summary'));
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS summarized_at TIMESTAMPTZ;
+
+-- Summaries are posted by the system; their sender_id is the summary
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_type_check
+    CHECK (sender_type IN ('user', 'agent', 'visitor', 'system'));
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+
+-- through_message_id and through_at are the last message a run covers; the next run starts after
+-- it. completed_at is set once the summary arrives, and a run whose task failed is never completed.
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    office_id UUID NOT NULL REFERENCES offices(id) ON DELETE CASCADE,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    task_id UUID UNIQUE REFERENCES tasks(id) ON DELETE SET NULL,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    through_message_id UUID NOT NULL,
+    through_at TIMESTAMPTZ NOT NULL,
+    message_count INTEGER NOT NULL,
+    auto BOOLEAN NOT NULL DEFAULT false,
+    content TEXT NOT NULL DEFAULT '',
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_summaries_conversation
+    ON conversation_summaries(conversation_id, created_at DESC);
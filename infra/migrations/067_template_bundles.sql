@@ -0,0 +1,89 @@
+-- Template Bundles
+-- Migration: 067_template_bundles.sql
+-- Authors can sell several of their premium templates together for less
+-- than they cost one by one. Buying a bundle grants the office each member
+-- template, and the bundle's price is split between the members' sales.
+
+CREATE TABLE IF NOT EXISTS template_bundles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    author_id UUID NOT NULL REFERENCES users(id),
+    author_name VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    price_cents INT NOT NULL CHECK (price_cents >= 199),
+    is_featured BOOLEAN NOT NULL DEFAULT false,
+    is_public BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_bundles_author ON template_bundles(author_id);
+CREATE INDEX IF NOT EXISTS idx_template_bundles_featured ON template_bundles(is_featured, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS template_bundle_items (
+    bundle_id UUID NOT NULL REFERENCES template_bundles(id) ON DELETE CASCADE,
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (bundle_id, template_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_bundle_items_template ON template_bundle_items(template_id);
+
+-- Entitlements and sales that came with a bundle
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS bundle_id UUID REFERENCES template_bundles(id);
+ALTER TABLE author_earnings ADD COLUMN IF NOT EXISTS bundle_id UUID REFERENCES template_bundles(id);
+CREATE INDEX IF NOT EXISTS idx_author_earnings_bundle ON author_earnings(bundle_id) WHERE bundle_id IS NOT NULL;
+
+-- A member's share of a bundle sale can be below the minimum template price
+ALTER TABLE author_earnings DROP CONSTRAINT IF EXISTS author_earnings_sale_amount_check;
+ALTER TABLE author_earnings ADD CONSTRAINT author_earnings_sale_amount_check
+    CHECK (sale_amount_cents >= 199 OR refund_of IS NOT NULL OR bundle_id IS NOT NULL);
+
+-- record_marketplace_sale gains the bundle a sale is a share of. The old
+-- signature is dropped first so six-argument calls are not ambiguous.
+DROP FUNCTION IF EXISTS record_marketplace_sale(UUID, UUID, UUID, UUID, INT, VARCHAR);
+
+CREATE OR REPLACE FUNCTION record_marketplace_sale(
+    p_author_id UUID,
+    p_template_id UUID,
+    p_purchaser_id UUID,
+    p_purchaser_office_id UUID,
+    p_sale_amount_cents INT,
+    p_stripe_payment_intent_id VARCHAR DEFAULT NULL,
+    p_bundle_id UUID DEFAULT NULL
+) RETURNS UUID AS $$
+DECLARE
+    v_commission_cents INT;
+    v_author_earning_cents INT;
+    v_earning_id UUID;
+BEGIN
+    -- Validate minimum price; bundle shares are checked as a whole
+    IF p_bundle_id IS NULL AND p_sale_amount_cents < 199 THEN
+        RAISE EXCEPTION 'Sale amount below minimum ($1.99)';
+    END IF;
+
+    -- Calculate commission (20% platform, 80% author)
+    v_commission_cents := FLOOR(p_sale_amount_cents * 0.20);
+    v_author_earning_cents := p_sale_amount_cents - v_commission_cents;
+
+    -- Insert earning record
+    INSERT INTO author_earnings (
+        author_id, template_id, purchaser_id, purchaser_office_id,
+        sale_amount_cents, commission_cents, author_earning_cents,
+        stripe_payment_intent_id, bundle_id
+    ) VALUES (
+        p_author_id, p_template_id, p_purchaser_id, p_purchaser_office_id,
+        p_sale_amount_cents, v_commission_cents, v_author_earning_cents,
+        p_stripe_payment_intent_id, p_bundle_id
+    ) RETURNING id INTO v_earning_id;
+
+    -- Update author balance
+    INSERT INTO author_balances (author_id, total_earned_cents, updated_at)
+    VALUES (p_author_id, v_author_earning_cents, NOW())
+    ON CONFLICT (author_id) DO UPDATE SET
+        total_earned_cents = author_balances.total_earned_cents + v_author_earning_cents,
+        updated_at = NOW();
+
+    RETURN v_earning_id;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,28 @@
+-- Author Profiles
+-- Migration: 068_author_profiles.sql
+-- Template authors get a public marketplace profile with a display name, a
+-- bio and links, and can ask to be verified. Admins grant the verified
+-- badge. Authors without a row are shown under their account name.
+
+CREATE TABLE IF NOT EXISTS author_profiles (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    display_name VARCHAR(100) NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    -- [{"label": "...", "url": "https://..."}]
+    links JSONB NOT NULL DEFAULT '[]',
+    -- none, pending, verified or rejected
+    verification_status VARCHAR(20) NOT NULL DEFAULT 'none',
+    verification_message TEXT NOT NULL DEFAULT '',
+    verification_requested_at TIMESTAMPTZ,
+    verification_rejection_reason TEXT NOT NULL DEFAULT '',
+    verification_reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    verification_reviewed_at TIMESTAMPTZ,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The admin verification queue
+CREATE INDEX IF NOT EXISTS idx_author_profiles_verification
+    ON author_profiles(verification_requested_at)
+    WHERE verification_status = 'pending';
@@ -0,0 +1,38 @@
+-- Template Favorites
+-- Migration: 069_template_favorites.sql
+-- Users save marketplace templates to their favorites, and may ask to be
+-- told when a favorited premium template goes on sale or publishes a new
+-- version. Each favorite remembers the version and price its user last
+-- heard of, so every change is notified once.
+
+CREATE TABLE IF NOT EXISTS template_favorites (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template_id UUID NOT NULL REFERENCES agent_templates(id) ON DELETE CASCADE,
+    notify BOOLEAN NOT NULL DEFAULT false,
+    notified_version VARCHAR(20) NOT NULL,
+    notified_price_cents INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, template_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_favorites_template ON template_favorites(template_id);
+CREATE INDEX IF NOT EXISTS idx_template_favorites_user ON template_favorites(user_id, created_at DESC);
+
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS favorite_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE FUNCTION update_template_favorite_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE agent_templates SET favorite_count = favorite_count + 1 WHERE id = NEW.template_id;
+        RETURN NEW;
+    END IF;
+    UPDATE agent_templates SET favorite_count = GREATEST(favorite_count - 1, 0) WHERE id = OLD.template_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_template_favorite_count_on_favorite ON template_favorites;
+CREATE TRIGGER update_template_favorite_count_on_favorite
+AFTER INSERT OR DELETE ON template_favorites
+FOR EACH ROW EXECUTE FUNCTION update_template_favorite_count();
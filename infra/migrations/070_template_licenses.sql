@@ -0,0 +1,29 @@
+-- Template Licenses
+-- Migration: 070_template_licenses.sql
+-- Templates are sold under a personal or a team license, optionally
+-- prohibiting resale, with the author's license terms. The license is
+-- snapshotted with each version, and each purchase records the license the
+-- office accepted. Templates published before licensing keep the
+-- unrestricted team license.
+
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS license_type VARCHAR(20) NOT NULL DEFAULT 'team'
+    CHECK (license_type IN ('personal', 'team'));
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS license_resale_prohibited BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE agent_templates ADD COLUMN IF NOT EXISTS license_terms TEXT NOT NULL DEFAULT '';
+
+-- Submissions copying a resale-prohibited template are looked up by prompt,
+-- ignoring case and runs of whitespace
+CREATE INDEX IF NOT EXISTS idx_agent_templates_resale_prohibited
+    ON agent_templates(md5(btrim(lower(regexp_replace(system_prompt, '\s+', ' ', 'g')))))
+    WHERE license_resale_prohibited;
+
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS license_type VARCHAR(20) NOT NULL DEFAULT 'team';
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS license_resale_prohibited BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS license_terms TEXT NOT NULL DEFAULT '';
+
+-- The license accepted at purchase; NULL for purchases made before licensing
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS license_type VARCHAR(20);
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS license_resale_prohibited BOOLEAN;
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS license_terms TEXT;
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS license_version VARCHAR(20);
+ALTER TABLE template_purchases ADD COLUMN IF NOT EXISTS license_accepted_at TIMESTAMPTZ;
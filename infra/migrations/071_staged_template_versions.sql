@@ -0,0 +1,20 @@
+-- Staged Template Versions
+-- Migration: 071_staged_template_versions.sql
+-- A new version of an approved template waits in template_versions until an
+-- admin approves it, and only then replaces the live template. Meanwhile the
+-- approved version stays listed and hireable. Versions published before
+-- staging were live, so they are approved.
+
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved'
+    CHECK (status IN ('pending', 'approved', 'rejected'));
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS rejection_reason TEXT;
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+-- The security scan of the staged version, shown to admins reviewing it
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS risk_score INT NOT NULL DEFAULT 0;
+ALTER TABLE template_versions ADD COLUMN IF NOT EXISTS risk_findings JSONB NOT NULL DEFAULT '[]';
+
+-- A template has at most one version awaiting review
+CREATE UNIQUE INDEX IF NOT EXISTS idx_template_versions_pending
+    ON template_versions(template_id) WHERE status = 'pending';
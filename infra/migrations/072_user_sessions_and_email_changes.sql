@@ -0,0 +1,9 @@
+-- Session Revocation and Email Changes
+-- Migration: 072_user_sessions_and_email_changes.sql
+-- Signed-in sessions carry the user's token version, and changing or
+-- resetting the password moves it on, ending every earlier session. A new
+-- email address is kept pending until the link sent to it is followed, and
+-- only then replaces the verified one.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255);
@@ -0,0 +1,32 @@
+-- Summary Claims
+-- Migration: 073_summary_claims.sql
+-- A summarization run claims its conversation by being stored pending before
+-- its task is created, so concurrent requests cannot both start and charge a
+-- run. The claim ends when the run completes, when its task could not be
+-- created, or, once its task has failed or gone, when the next run claims
+-- the conversation.
+
+ALTER TABLE conversation_summaries ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'done', 'failed'));
+
+UPDATE conversation_summaries s SET status = CASE
+    WHEN s.completed_at IS NOT NULL THEN 'done'
+    WHEN EXISTS (
+        SELECT 1 FROM tasks t WHERE t.id = s.task_id
+            AND (t.status IN ('pending', 'thinking', 'working')
+                OR (t.status = 'failed' AND t.next_attempt_at IS NOT NULL))
+    ) THEN 'pending'
+    ELSE 'failed'
+END;
+
+-- Only a conversation's newest run may still hold its claim
+UPDATE conversation_summaries s SET status = 'failed'
+WHERE s.status = 'pending' AND EXISTS (
+    SELECT 1 FROM conversation_summaries newer
+    WHERE newer.conversation_id = s.conversation_id AND newer.status = 'pending'
+        AND (newer.created_at, newer.id) > (s.created_at, s.id)
+);
+
+-- A conversation has at most one run in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_summaries_pending
+    ON conversation_summaries(conversation_id) WHERE status = 'pending';
@@ -20,7 +20,8 @@ echo Running migrations...
 echo.
 
 REM Run each migration file in order
-for %%f in (infra\migrations\*.sql) do (
+REM Down migrations only run through the migrate command
+for %%f in (infra\migrations\*.sql) do echo %%f| findstr /e /i /l ".down.sql" >nul || (
     echo Running migration: %%f
     psql %DATABASE_URL% -f "%%f"
     if errorlevel 1 (
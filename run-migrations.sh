@@ -20,6 +20,8 @@ echo ""
 
 # Run each migration file in order
 for migration in infra/migrations/*.sql; do
+    # Down migrations only run through the migrate command
+    case "$migration" in *.down.sql) continue ;; esac
     echo "Running migration: $migration"
     psql "$DATABASE_URL" -f "$migration"
     if [ $? -ne 0 ]; then